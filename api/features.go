@@ -3,6 +3,8 @@ package api
 import (
 	"fmt"
 	"strings"
+
+	"github.com/tetratelabs/wazero/internal/internalapi"
 )
 
 // CoreFeatures is a bit flag of WebAssembly Core specification features. See
@@ -210,5 +212,6 @@ func featureName(f CoreFeatures) string {
 		// match https://github.com/WebAssembly/spec/blob/wg-2.0.draft1/proposals/simd/SIMD.md
 		return "simd"
 	}
-	return ""
+	// The features defined in the experimental package, which cannot be imported here, register their names.
+	return internalapi.CoreFeatureName(uint64(f))
 }
@@ -107,6 +107,16 @@ const (
 	//
 	// Note: The usage of this type is toggled with api.CoreFeatureBulkMemoryOperations.
 	ValueTypeExternref ValueType = 0x6f
+
+	// ValueTypeFuncref is a funcref type.
+	//
+	// Note: in wazero, funcref type values are opaque references to a
+	// function, which are only exposed as the elements of a Table. Use
+	// Table.Function and Table.SetFunction to convert them from and to a
+	// Function.
+	//
+	// Note: The usage of this type is toggled with api.CoreFeatureReferenceTypes.
+	ValueTypeFuncref ValueType = 0x70
 )
 
 // ValueTypeName returns the type name of the given ValueType as a string.
@@ -125,6 +135,8 @@ func ValueTypeName(t ValueType) string {
 		return "f64"
 	case ValueTypeExternref:
 		return "externref"
+	case ValueTypeFuncref:
+		return "funcref"
 	}
 	return "unknown"
 }
@@ -147,6 +159,9 @@ type Module interface {
 	Name() string
 
 	// Memory returns a memory defined in this module or nil if there are none wasn't.
+	//
+	// Note: When the module has multiple memories, this returns the one at index zero.
+	// See experimental.MemoryAt to access the others.
 	Memory() Memory
 
 	// ExportedFunction returns a function exported from this module or nil if it wasn't.
@@ -164,7 +179,12 @@ type Module interface {
 	// definitions in this module, keyed on export name.
 	ExportedFunctionDefinitions() map[string]FunctionDefinition
 
-	// TODO: Table
+	// ExportedTable returns a table exported from this module or nil if it wasn't.
+	ExportedTable(name string) Table
+
+	// ExportedTableDefinitions returns all the exported table definitions in
+	// this module, keyed on export name.
+	ExportedTableDefinitions() map[string]TableDefinition
 
 	// ExportedMemory returns a memory exported from this module or nil if it wasn't.
 	//
@@ -178,7 +198,7 @@ type Module interface {
 	// in this module, keyed on export name.
 	//
 	// Note: As of WebAssembly Core Specification 2.0, there can be at most one
-	// memory.
+	// memory, unless experimental.CoreFeaturesMultiMemory is enabled.
 	ExportedMemoryDefinitions() map[string]MemoryDefinition
 
 	// ExportedGlobal a global exported from this module or nil if it wasn't.
@@ -289,6 +309,35 @@ type MemoryDefinition interface {
 	internalapi.WazeroOnly
 }
 
+// TableDefinition is a WebAssembly table exported in a module
+// (wazero.CompiledModule). Units are in elements.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#exports%E2%91%A0
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type TableDefinition interface {
+	ExportDefinition
+
+	// RefType returns the type of the elements, either ValueTypeFuncref or
+	// ValueTypeExternref.
+	//
+	// Note: Other reference types are possible with experimental features,
+	// such as experimental.CoreFeaturesGC.
+	RefType() ValueType
+
+	// Min returns the possibly zero initial count of elements.
+	Min() uint32
+
+	// Max returns the possibly zero max count of elements, or false if
+	// unbounded.
+	Max() (uint32, bool)
+
+	internalapi.WazeroOnly
+}
+
 // FunctionDefinition is a WebAssembly function exported in a module
 // (wazero.CompiledModule).
 //
@@ -571,6 +620,9 @@ type Memory interface {
 	//   - This overflows (returns zero) if the memory has the maximum 65536 pages.
 	// 	   As a workaround until wazero v2 to fix the return type, use Grow(0) to obtain the current pages and
 	//     multiply by 65536.
+	//   - A 64-bit memory can be larger than 4GiB, in which case this and the
+	//     accessors below cannot reach above 4GiB. Use experimental.AsMemory64
+	//     to access such a memory with 64-bit offsets.
 	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#-hrefsyntax-instr-memorymathsfmemorysize%E2%91%A0
 	Size() uint32
@@ -683,6 +735,81 @@ type Memory interface {
 	internalapi.WazeroOnly
 }
 
+// Table allows access to the elements of a table exported from an
+// instantiated module (wazero.Runtime InstantiateModule).
+//
+// The elements are references encoded as uint64, depending on the
+// TableDefinition.RefType:
+//
+//   - ValueTypeExternref - the uintptr passed from the host, as described on
+//     ValueTypeExternref.
+//   - ValueTypeFuncref - an opaque reference to a function. Use Function and
+//     SetFunction instead of Get and Set to convert them from and to a
+//     Function.
+//
+// In both cases, zero is the null reference.
+//
+// For example, to dispatch to a function stored in the table:
+//
+//	fn, err := module.ExportedTable("__indirect_function_table").Function(i)
+//	if err != nil {
+//		return err
+//	} else if fn == nil {
+//		return errors.New("null function")
+//	}
+//	results, err := fn.Call(ctx)
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#table-instances%E2%91%A0
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+//   - Like Memory, this is not synchronized with the Wasm code accessing the
+//     same table concurrently.
+type Table interface {
+	// Definition is metadata about this table from its defining module.
+	Definition() TableDefinition
+
+	// Size returns the current count of elements.
+	Size() uint32
+
+	// Grow increases the table by the delta in elements, each initialized to
+	// the reference init. The return val is the previous count of elements,
+	// or an error if the delta exceeds TableDefinition.Max.
+	//
+	// Note: This is the same as the "table.grow" instruction defined in the
+	// WebAssembly Core Specification, except returns an error instead of -1.
+	// The init of a table of ValueTypeFuncref must be the null reference
+	// (zero), which can be replaced by SetFunction.
+	//
+	// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/exec/instructions.html#xref-syntax-instructions-syntax-instr-table-mathsf-table-grow-x
+	Grow(delta uint32, init uint64) (previousSize uint32, err error)
+
+	// Get returns the reference at the offset or an error if out of range.
+	Get(offset uint32) (uint64, error)
+
+	// Set stores the reference at the offset or returns an error if out of
+	// range. A table of ValueTypeFuncref only accepts the null reference
+	// (zero), and SetFunction stores the others.
+	Set(offset uint32, v uint64) error
+
+	// Function returns the function at the offset of a table of
+	// ValueTypeFuncref, or nil if the reference is null. This returns an
+	// error if the offset is out of range or the table has another type.
+	Function(offset uint32) (Function, error)
+
+	// SetFunction stores a reference to the function at the offset of a table
+	// of ValueTypeFuncref, or the null reference if nil. This returns an error
+	// if the offset is out of range, the table has another type, or the
+	// function is not one of a Module of the same wazero.Runtime, such as an
+	// ExportedFunction, or can't be referenced by the table, see
+	// wazero.NewRuntimeConfigTiered.
+	SetFunction(offset uint32, fn Function) error
+
+	internalapi.WazeroOnly
+}
+
 // CustomSection contains the name and raw data of a custom section.
 //
 // # Notes
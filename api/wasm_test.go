@@ -42,6 +42,7 @@ func TestValueTypeName(t *testing.T) {
 		{"f32", ValueTypeF32, "f32"},
 		{"f64", ValueTypeF64, "f64"},
 		{"externref", ValueTypeExternref, "externref"},
+		{"funcref", ValueTypeFuncref, "funcref"},
 		{"unknown", 100, "unknown"},
 	}
 
@@ -0,0 +1,244 @@
+package wazero
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	goruntime "runtime"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/version"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// artifactMagic is the header of the artifacts written by Runtime.ExportCompiledModule.
+var artifactMagic = []byte{'W', 'A', 'Z', 'E', 'R', 'O', 'A', 'O', 'T'}
+
+// artifactFormatVersion is incremented when the layout of the artifacts changes.
+const artifactFormatVersion = 1
+
+var artifactCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// artifactHeader is the metadata written at the beginning of an artifact, which must match the Runtime importing it.
+type artifactHeader struct {
+	wazeroVersion, engine, goarch, goos string
+	// cpuFeatures is platform.CompilerCpuFeatures, which the CPU importing the artifact must have at least.
+	cpuFeatures  uint64
+	coreFeatures api.CoreFeatures
+	// moduleID is the wasm.ModuleID of the compiled module, which covers the configuration affecting the native code
+	// such as function listeners, RuntimeConfig.WithCloseOnContextDone and the fuel costs.
+	moduleID wasm.ModuleID
+}
+
+// artifactHeader returns the artifactHeader for the modules compiled by this runtime.
+func (r *runtime) artifactHeader() artifactHeader {
+	return artifactHeader{
+		wazeroVersion: version.GetWazeroVersion(),
+		engine:        "compiler",
+		goarch:        goruntime.GOARCH,
+		goos:          goruntime.GOOS,
+		cpuFeatures:   platform.CompilerCpuFeatures(),
+		coreFeatures:  r.enabledFeatures,
+	}
+}
+
+// ExportCompiledModule implements Runtime.ExportCompiledModule
+func (r *runtime) ExportCompiledModule(_ context.Context, compiled CompiledModule, w io.Writer) error {
+	if err := r.failIfClosed(); err != nil {
+		return err
+	}
+	engine, err := r.artifactEngine()
+	if err != nil {
+		return err
+	}
+	c, ok := compiled.(*compiledModule)
+	if !ok || c.compiledEngine != r.store.Engine {
+		return errors.New("compiled module must be compiled by this Runtime")
+	} else if c.module.IsHostModule {
+		return errors.New("host modules cannot be exported")
+	} else if c.binary == nil {
+		return errors.New("compiled module must be compiled with RuntimeConfig.WithCompiledModuleExport enabled")
+	}
+
+	var code bytes.Buffer
+	if err = engine.ExportCompiledModule(c.module, &code); err != nil {
+		return fmt.Errorf("error exporting compiled module: %w", err)
+	}
+
+	h := r.artifactHeader()
+	h.moduleID = c.module.ID
+
+	buf := bytes.NewBuffer(nil)
+	buf.Write(artifactMagic)
+	buf.WriteByte(artifactFormatVersion)
+	for _, s := range []string{h.wazeroVersion, h.engine, h.goarch, h.goos} {
+		buf.WriteByte(byte(len(s)))
+		buf.WriteString(s)
+	}
+	buf.Write(binary.LittleEndian.AppendUint64(nil, h.cpuFeatures))
+	buf.Write(binary.LittleEndian.AppendUint64(nil, uint64(h.coreFeatures)))
+	buf.Write(h.moduleID[:])
+	buf.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(c.binary))))
+	buf.Write(c.binary)
+	buf.Write(binary.LittleEndian.AppendUint64(nil, uint64(code.Len())))
+	buf.Write(code.Bytes())
+	// Append the checksum of everything above.
+	buf.Write(binary.LittleEndian.AppendUint32(nil, crc32.Checksum(buf.Bytes(), artifactCRC)))
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// ImportCompiledModule implements Runtime.ImportCompiledModule
+func (r *runtime) ImportCompiledModule(ctx context.Context, reader io.Reader) (CompiledModule, error) {
+	if err := r.failIfClosed(); err != nil {
+		return nil, err
+	}
+	engine, err := r.artifactEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	artifact, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading artifact: %w", err)
+	}
+	h, source, code, err := decodeArtifact(artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	want := r.artifactHeader()
+	for _, f := range []struct{ name, got, want string }{
+		{"wazero version", h.wazeroVersion, want.wazeroVersion},
+		{"engine", h.engine, want.engine},
+		{"architecture", h.goarch, want.goarch},
+		{"operating system", h.goos, want.goos},
+	} {
+		if f.got != f.want {
+			return nil, fmt.Errorf("artifact was compiled for %s %s, but this runtime has %s", f.name, f.got, f.want)
+		}
+	}
+	if missing := h.cpuFeatures &^ want.cpuFeatures; missing != 0 {
+		return nil, fmt.Errorf("artifact was compiled for CPU features %#x, but this CPU lacks %#x", h.cpuFeatures, missing)
+	} else if h.coreFeatures != want.coreFeatures {
+		return nil, fmt.Errorf("artifact was compiled with core features %s, but this runtime has %s", h.coreFeatures, want.coreFeatures)
+	}
+
+	c, listeners, err := r.decodeModule(ctx, source)
+	if err != nil {
+		return nil, err
+	} else if c.module.ID != h.moduleID {
+		return nil, errors.New("artifact was compiled with different function listeners, " +
+			"RuntimeConfig.WithCloseOnContextDone or fuel costs than this runtime")
+	}
+	if err = engine.ImportCompiledModule(ctx, c.module, listeners, r.ensureTermination, bytes.NewReader(code)); err != nil {
+		return nil, fmt.Errorf("error importing compiled module: %w", err)
+	}
+	return c, nil
+}
+
+// artifactEngine returns the engine of this runtime if it supports the artifacts, or an error otherwise.
+func (r *runtime) artifactEngine() (wasm.ArtifactEngine, error) {
+	if engine, ok := r.store.Engine.(wasm.ArtifactEngine); ok && r.engineKind == engineKindCompiler {
+		return engine, nil
+	}
+	return nil, errors.New("compiled modules can only be exported or imported with the compiler")
+}
+
+// decodeArtifact decodes the artifact written by runtime.ExportCompiledModule into the header, the source Wasm binary
+// and the native code exported by the engine.
+func decodeArtifact(artifact []byte) (h artifactHeader, source, code []byte, err error) {
+	if !bytes.HasPrefix(artifact, artifactMagic) {
+		err = errors.New("invalid artifact: magic number mismatch")
+		return
+	}
+	if len(artifact) < len(artifactMagic)+5 {
+		err = errors.New("invalid artifact: too short")
+		return
+	}
+	body, checksum := artifact[:len(artifact)-4], binary.LittleEndian.Uint32(artifact[len(artifact)-4:])
+	if expected := crc32.Checksum(body, artifactCRC); checksum != expected {
+		err = fmt.Errorf("invalid artifact: checksum mismatch (expected %d, got %d)", expected, checksum)
+		return
+	}
+
+	rest := body[len(artifactMagic):]
+	if v := rest[0]; v != artifactFormatVersion {
+		err = fmt.Errorf("invalid artifact: unsupported format version %d", v)
+		return
+	}
+	rest = rest[1:]
+
+	truncated := errors.New("invalid artifact: truncated")
+	readString := func() (string, bool) {
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return "", false
+		}
+		s := string(rest[1 : 1+rest[0]])
+		rest = rest[1+rest[0]:]
+		return s, true
+	}
+	readBytes := func(n uint64) ([]byte, bool) {
+		if uint64(len(rest)) < n {
+			return nil, false
+		}
+		b := rest[:n]
+		rest = rest[n:]
+		return b, true
+	}
+	readUint64 := func() (uint64, bool) {
+		b, ok := readBytes(8)
+		if !ok {
+			return 0, false
+		}
+		return binary.LittleEndian.Uint64(b), true
+	}
+
+	for _, s := range []*string{&h.wazeroVersion, &h.engine, &h.goarch, &h.goos} {
+		var ok bool
+		if *s, ok = readString(); !ok {
+			err = truncated
+			return
+		}
+	}
+	var v uint64
+	var ok bool
+	if h.cpuFeatures, ok = readUint64(); !ok {
+		err = truncated
+		return
+	}
+	if v, ok = readUint64(); !ok {
+		err = truncated
+		return
+	}
+	h.coreFeatures = api.CoreFeatures(v)
+	id, ok := readBytes(uint64(len(h.moduleID)))
+	if !ok {
+		err = truncated
+		return
+	}
+	copy(h.moduleID[:], id)
+	if v, ok = readUint64(); !ok {
+		err = truncated
+		return
+	}
+	if source, ok = readBytes(v); !ok {
+		err = truncated
+		return
+	}
+	if v, ok = readUint64(); !ok {
+		err = truncated
+		return
+	}
+	if code, ok = readBytes(v); !ok {
+		err = truncated
+		return
+	}
+	return
+}
@@ -0,0 +1,190 @@
+package wazero
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestRuntime_ExportImportCompiledModule(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	ctx := context.Background()
+
+	exporter := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler().WithCompiledModuleExport(true))
+	defer exporter.Close(ctx)
+	compiled, err := exporter.CompileModule(ctx, facWasm)
+	require.NoError(t, err)
+
+	var artifact bytes.Buffer
+	require.NoError(t, exporter.ExportCompiledModule(ctx, compiled, &artifact))
+
+	importer := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler())
+	defer importer.Close(ctx)
+	imported, err := importer.ImportCompiledModule(ctx, bytes.NewReader(artifact.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, compiled.Name(), imported.Name())
+	require.Equal(t, len(compiled.ExportedFunctions()), len(imported.ExportedFunctions()))
+
+	// The imported module can be exported again when the importer retains the source binary.
+	err = importer.ExportCompiledModule(ctx, imported, &bytes.Buffer{})
+	require.EqualError(t, err, "compiled module must be compiled with RuntimeConfig.WithCompiledModuleExport enabled")
+	reexporter := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler().WithCompiledModuleExport(true))
+	defer reexporter.Close(ctx)
+	reimported, err := reexporter.ImportCompiledModule(ctx, bytes.NewReader(artifact.Bytes()))
+	require.NoError(t, err)
+	var reexported bytes.Buffer
+	require.NoError(t, reexporter.ExportCompiledModule(ctx, reimported, &reexported))
+	require.Equal(t, artifact.Bytes(), reexported.Bytes())
+
+	mod, err := importer.InstantiateModule(ctx, imported, NewModuleConfig())
+	require.NoError(t, err)
+	results, err := mod.ExportedFunction("fac-ssa").Call(ctx, 5)
+	require.NoError(t, err)
+	require.Equal(t, uint64(120), results[0])
+}
+
+func TestRuntime_ExportImportCompiledModule_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("interpreter", func(t *testing.T) {
+		r := NewRuntimeWithConfig(ctx, NewRuntimeConfigInterpreter())
+		defer r.Close(ctx)
+		compiled, err := r.CompileModule(ctx, facWasm)
+		require.NoError(t, err)
+
+		err = r.ExportCompiledModule(ctx, compiled, &bytes.Buffer{})
+		require.EqualError(t, err, "compiled modules can only be exported or imported with the compiler")
+		_, err = r.ImportCompiledModule(ctx, bytes.NewReader(nil))
+		require.EqualError(t, err, "compiled modules can only be exported or imported with the compiler")
+	})
+
+	if !platform.CompilerSupported() {
+		return
+	}
+
+	t.Run("not retained", func(t *testing.T) {
+		r := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler())
+		defer r.Close(ctx)
+		compiled, err := r.CompileModule(ctx, facWasm)
+		require.NoError(t, err)
+		err = r.ExportCompiledModule(ctx, compiled, &bytes.Buffer{})
+		require.EqualError(t, err, "compiled module must be compiled with RuntimeConfig.WithCompiledModuleExport enabled")
+	})
+
+	r := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler().WithCompiledModuleExport(true))
+	defer r.Close(ctx)
+	compiled, err := r.CompileModule(ctx, facWasm)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, r.ExportCompiledModule(ctx, compiled, &buf))
+	artifact := buf.Bytes()
+
+	t.Run("other runtime", func(t *testing.T) {
+		other := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler().WithCompiledModuleExport(true))
+		defer other.Close(ctx)
+		err := other.ExportCompiledModule(ctx, compiled, &bytes.Buffer{})
+		require.EqualError(t, err, "compiled module must be compiled by this Runtime")
+	})
+
+	// resign replaces the checksum of the modified artifact, so that the decoding proceeds to the validation.
+	resign := func(a []byte) []byte {
+		body := a[:len(a)-4]
+		return binary.LittleEndian.AppendUint32(append([]byte{}, body...), crc32.Checksum(body, artifactCRC))
+	}
+
+	// withCPUFeatures replaces the CPU features in the header of the artifact.
+	withCPUFeatures := func(cpuFeatures uint64) []byte {
+		a := bytes.Clone(artifact)
+		offset := len(artifactMagic) + 1
+		for i := 0; i < 4; i++ { // Skip the wazero version, engine, architecture and operating system.
+			offset += 1 + int(a[offset])
+		}
+		binary.LittleEndian.PutUint64(a[offset:], cpuFeatures)
+		return resign(a)
+	}
+
+	tests := []struct {
+		name        string
+		artifact    func() []byte
+		expectedErr string
+	}{
+		{
+			name:        "not an artifact",
+			artifact:    func() []byte { return facWasm },
+			expectedErr: "invalid artifact: magic number mismatch",
+		},
+		{
+			name: "checksum",
+			artifact: func() []byte {
+				a := bytes.Clone(artifact)
+				a[len(a)-10]++
+				return a
+			},
+			expectedErr: "invalid artifact: checksum mismatch",
+		},
+		{
+			name: "format version",
+			artifact: func() []byte {
+				a := bytes.Clone(artifact)
+				a[len(artifactMagic)] = 0xff
+				return resign(a)
+			},
+			expectedErr: "invalid artifact: unsupported format version 255",
+		},
+		{
+			name: "truncated",
+			artifact: func() []byte {
+				return resign(artifact[:len(artifactMagic)+20])
+			},
+			expectedErr: "invalid artifact: truncated",
+		},
+		{
+			name: "wazero version",
+			artifact: func() []byte {
+				a := bytes.Clone(artifact)
+				a[len(artifactMagic)+2]++
+				return resign(a)
+			},
+			expectedErr: "artifact was compiled for wazero version",
+		},
+		{
+			name:        "CPU features",
+			artifact:    func() []byte { return withCPUFeatures(^platform.CompilerCpuFeatures()) },
+			expectedErr: "artifact was compiled for CPU features",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			importer := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler())
+			defer importer.Close(ctx)
+			_, err := importer.ImportCompiledModule(ctx, bytes.NewReader(tc.artifact()))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+
+	t.Run("fewer CPU features", func(t *testing.T) {
+		// The code compiled on a CPU without the optional features still runs on this one.
+		importer := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler())
+		defer importer.Close(ctx)
+		_, err := importer.ImportCompiledModule(ctx, bytes.NewReader(withCPUFeatures(0)))
+		require.NoError(t, err)
+	})
+
+	t.Run("close on context done", func(t *testing.T) {
+		importer := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler().WithCloseOnContextDone(true))
+		defer importer.Close(ctx)
+		_, err := importer.ImportCompiledModule(ctx, bytes.NewReader(artifact))
+		require.EqualError(t, err, "artifact was compiled with different function listeners, "+
+			"RuntimeConfig.WithCloseOnContextDone or fuel costs than this runtime")
+	})
+}
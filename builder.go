@@ -2,6 +2,7 @@ package wazero
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -137,6 +138,106 @@ type HostFunctionBuilder interface {
 	Export(name string) HostModuleBuilder
 }
 
+// HostMemoryBuilder defines a memory in a host module, so that a WebAssembly
+// binary (e.g. %.wasm file) which imports its memory, such as one compiled
+// with `--import-memory`, can use it.
+//
+// Here's an example of a memory of one to ten pages:
+//
+//	hostModuleBuilder.NewMemoryBuilder().
+//		WithMinPages(1).WithMaxPages(10).
+//		Export("memory")
+//
+// After instantiation, the host reads and writes the memory with
+// api.Module ExportedMemory.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type HostMemoryBuilder interface {
+	// WithMinPages defines the initial count of pages. Defaults to zero.
+	WithMinPages(pages uint32) HostMemoryBuilder
+
+	// WithMaxPages defines the maximum count of pages. Defaults to the limit
+	// of RuntimeConfig.WithMemoryLimitPages.
+	WithMaxPages(pages uint32) HostMemoryBuilder
+
+	// WithShared makes the memory shared between threads. This requires
+	// experimental.CoreFeaturesThreads to be enabled and WithMaxPages to be
+	// called.
+	WithShared() HostMemoryBuilder
+
+	// Export exports this to the HostModuleBuilder as the given name, e.g.
+	// "memory"
+	Export(name string) HostModuleBuilder
+}
+
+// HostGlobalBuilder defines a global in a host module, so that a WebAssembly
+// binary (e.g. %.wasm file) can import it.
+//
+// Here's an example of a mutable global initialized to 1024:
+//
+//	hostModuleBuilder.NewGlobalBuilder().
+//		WithValue(api.ValueTypeI32, api.EncodeU32(1024)).WithMutable().
+//		Export("__stack_pointer")
+//
+// After instantiation, the host reads the global with api.Module
+// ExportedGlobal, and writes it by casting the result to api.MutableGlobal.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type HostGlobalBuilder interface {
+	// WithValue defines the type and the initial value of the global, encoded
+	// as documented on api.ValueType. The type must be api.ValueTypeI32,
+	// api.ValueTypeI64, api.ValueTypeF32 or api.ValueTypeF64. Defaults to an
+	// api.ValueTypeI32 of zero.
+	WithValue(valueType api.ValueType, value uint64) HostGlobalBuilder
+
+	// WithMutable makes the global mutable, so that the importing module can
+	// set it.
+	WithMutable() HostGlobalBuilder
+
+	// Export exports this to the HostModuleBuilder as the given name, e.g.
+	// "__stack_pointer"
+	Export(name string) HostModuleBuilder
+}
+
+// HostTableBuilder defines a table in a host module, so that a WebAssembly
+// binary (e.g. %.wasm file) can import it. All elements are initially null.
+//
+// Here's an example of a table of functions which can grow up to 100
+// elements:
+//
+//	hostModuleBuilder.NewTableBuilder().
+//		WithMin(10).WithMax(100).
+//		Export("__indirect_function_table")
+//
+// After instantiation, the host reads and writes the table with api.Module
+// ExportedTable.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type HostTableBuilder interface {
+	// WithRefType defines the type of the elements, api.ValueTypeFuncref or
+	// api.ValueTypeExternref. Defaults to api.ValueTypeFuncref.
+	WithRefType(refType api.ValueType) HostTableBuilder
+
+	// WithMin defines the initial count of elements. Defaults to zero.
+	WithMin(elements uint32) HostTableBuilder
+
+	// WithMax defines the maximum count of elements. Defaults to unbounded.
+	WithMax(elements uint32) HostTableBuilder
+
+	// Export exports this to the HostModuleBuilder as the given name, e.g.
+	// "__indirect_function_table"
+	Export(name string) HostModuleBuilder
+}
+
 // HostModuleBuilder is a way to define host functions (in Go), so that a
 // WebAssembly binary (e.g. %.wasm file) can import and use them.
 //
@@ -179,6 +280,8 @@ type HostFunctionBuilder interface {
 //     are deferred until Compile.
 //   - Functions are indexed in order of calls to NewFunctionBuilder as
 //     insertion ordering is needed by ABI such as Emscripten (invoke_*).
+//     Likewise, memories, globals and tables are indexed in order of their
+//     first export. Exporting the same name again replaces the definition.
 //   - The semantics of host functions assumes the existence of an "importing module" because, for example, the host function needs access to
 //     the memory of the importing module. Therefore, direct use of ExportedFunction is forbidden for host modules.
 //     Practically speaking, it is usually meaningless to directly call a host function from Go code as it is already somewhere in Go code.
@@ -188,6 +291,18 @@ type HostModuleBuilder interface {
 	// NewFunctionBuilder begins the definition of a host function.
 	NewFunctionBuilder() HostFunctionBuilder
 
+	// NewMemoryBuilder begins the definition of a memory.
+	//
+	// Note: Defining more than one memory requires
+	// experimental.CoreFeaturesMultiMemory to be enabled.
+	NewMemoryBuilder() HostMemoryBuilder
+
+	// NewGlobalBuilder begins the definition of a global.
+	NewGlobalBuilder() HostGlobalBuilder
+
+	// NewTableBuilder begins the definition of a table.
+	NewTableBuilder() HostTableBuilder
+
 	// Compile returns a CompiledModule that can be instantiated by Runtime.
 	Compile(context.Context) (CompiledModule, error)
 
@@ -221,6 +336,9 @@ type hostModuleBuilder struct {
 	moduleName     string
 	exportNames    []string
 	nameToHostFunc map[string]*wasm.HostFunc
+	memories       []*hostMemoryBuilder
+	globals        []wasm.HostGlobal
+	tables         []wasm.HostTable
 }
 
 // NewHostModuleBuilder implements Runtime.NewHostModuleBuilder
@@ -315,14 +433,177 @@ func (b *hostModuleBuilder) NewFunctionBuilder() HostFunctionBuilder {
 	return &hostFunctionBuilder{b: b}
 }
 
+// hostMemoryBuilder implements HostMemoryBuilder
+type hostMemoryBuilder struct {
+	b          *hostModuleBuilder
+	exportName string
+	minPages   uint32
+	maxPages   *uint32
+	shared     bool
+}
+
+// NewMemoryBuilder implements HostModuleBuilder.NewMemoryBuilder
+func (b *hostModuleBuilder) NewMemoryBuilder() HostMemoryBuilder {
+	return &hostMemoryBuilder{b: b}
+}
+
+// WithMinPages implements HostMemoryBuilder.WithMinPages
+func (h *hostMemoryBuilder) WithMinPages(pages uint32) HostMemoryBuilder {
+	h.minPages = pages
+	return h
+}
+
+// WithMaxPages implements HostMemoryBuilder.WithMaxPages
+func (h *hostMemoryBuilder) WithMaxPages(pages uint32) HostMemoryBuilder {
+	h.maxPages = &pages
+	return h
+}
+
+// WithShared implements HostMemoryBuilder.WithShared
+func (h *hostMemoryBuilder) WithShared() HostMemoryBuilder {
+	h.shared = true
+	return h
+}
+
+// Export implements HostMemoryBuilder.Export
+func (h *hostMemoryBuilder) Export(exportName string) HostModuleBuilder {
+	ret := *h
+	ret.exportName = exportName
+	h.b.memories = exportHostDefinition(h.b.memories, &ret, func(m *hostMemoryBuilder) string { return m.exportName })
+	return h.b
+}
+
+// hostGlobalBuilder implements HostGlobalBuilder
+type hostGlobalBuilder struct {
+	b *hostModuleBuilder
+	g wasm.HostGlobal
+}
+
+// NewGlobalBuilder implements HostModuleBuilder.NewGlobalBuilder
+func (b *hostModuleBuilder) NewGlobalBuilder() HostGlobalBuilder {
+	return &hostGlobalBuilder{b: b, g: wasm.HostGlobal{Type: wasm.GlobalType{ValType: api.ValueTypeI32}}}
+}
+
+// WithValue implements HostGlobalBuilder.WithValue
+func (h *hostGlobalBuilder) WithValue(valueType api.ValueType, value uint64) HostGlobalBuilder {
+	h.g.Type.ValType, h.g.Val = valueType, value
+	return h
+}
+
+// WithMutable implements HostGlobalBuilder.WithMutable
+func (h *hostGlobalBuilder) WithMutable() HostGlobalBuilder {
+	h.g.Type.Mutable = true
+	return h
+}
+
+// Export implements HostGlobalBuilder.Export
+func (h *hostGlobalBuilder) Export(exportName string) HostModuleBuilder {
+	g := h.g
+	g.ExportName = exportName
+	h.b.globals = exportHostDefinition(h.b.globals, g, func(g wasm.HostGlobal) string { return g.ExportName })
+	return h.b
+}
+
+// hostTableBuilder implements HostTableBuilder
+type hostTableBuilder struct {
+	b *hostModuleBuilder
+	t wasm.HostTable
+}
+
+// NewTableBuilder implements HostModuleBuilder.NewTableBuilder
+func (b *hostModuleBuilder) NewTableBuilder() HostTableBuilder {
+	return &hostTableBuilder{b: b, t: wasm.HostTable{Table: wasm.Table{Type: api.ValueTypeFuncref}}}
+}
+
+// WithRefType implements HostTableBuilder.WithRefType
+func (h *hostTableBuilder) WithRefType(refType api.ValueType) HostTableBuilder {
+	h.t.Table.Type = refType
+	return h
+}
+
+// WithMin implements HostTableBuilder.WithMin
+func (h *hostTableBuilder) WithMin(elements uint32) HostTableBuilder {
+	h.t.Table.Min = elements
+	return h
+}
+
+// WithMax implements HostTableBuilder.WithMax
+func (h *hostTableBuilder) WithMax(elements uint32) HostTableBuilder {
+	h.t.Table.Max = &elements
+	return h
+}
+
+// Export implements HostTableBuilder.Export
+func (h *hostTableBuilder) Export(exportName string) HostModuleBuilder {
+	t := h.t
+	t.ExportName = exportName
+	h.b.tables = exportHostDefinition(h.b.tables, t, func(t wasm.HostTable) string { return t.ExportName })
+	return h.b
+}
+
+// exportHostDefinition appends def to defs, or replaces the one already exported with the same name.
+func exportHostDefinition[T any](defs []T, def T, exportName func(T) string) []T {
+	name := exportName(def)
+	for i := range defs {
+		if exportName(defs[i]) == name {
+			defs[i] = def
+			return defs
+		}
+	}
+	return append(defs, def)
+}
+
+// hostMemories returns the memories defined in this builder, sized and validated according to the runtime
+// configuration.
+func (b *hostModuleBuilder) hostMemories() ([]wasm.HostMemory, error) {
+	if len(b.memories) == 0 {
+		return nil, nil
+	}
+
+	limit := b.r.memoryLimitPages
+	if limit > wasm.MemoryLimitPages {
+		limit = wasm.MemoryLimitPages
+	}
+	ret := make([]wasm.HostMemory, len(b.memories))
+	for i, h := range b.memories {
+		mem := wasm.Memory{Min: h.minPages, Cap: h.minPages, Max: limit, IsShared: h.shared}
+		if h.maxPages != nil {
+			mem.IsMaxEncoded = true
+			// As for a memory of a Wasm binary, a valid max over the runtime limit is lowered to it.
+			if mem.Max = *h.maxPages; mem.Max > limit && mem.Max <= wasm.MemoryLimitPages {
+				mem.Max = limit
+			}
+		}
+		if b.r.memoryCapacityFromMax {
+			mem.Cap = mem.Max
+		}
+		if err := mem.Validate(limit); err != nil {
+			return nil, fmt.Errorf("memory[%s.%s] %w", b.moduleName, h.exportName, err)
+		}
+		ret[i] = wasm.HostMemory{ExportName: h.exportName, Memory: mem}
+	}
+	return ret, nil
+}
+
 // Compile implements HostModuleBuilder.Compile
 func (b *hostModuleBuilder) Compile(ctx context.Context) (CompiledModule, error) {
 	module, err := wasm.NewHostModule(b.moduleName, b.exportNames, b.nameToHostFunc, b.r.enabledFeatures)
 	if err != nil {
 		return nil, err
-	} else if err = module.Validate(b.r.enabledFeatures); err != nil {
+	}
+
+	memories, err := b.hostMemories()
+	if err != nil {
+		return nil, err
+	} else if err = module.AddHostDefinitions(memories, b.globals, b.tables, b.r.enabledFeatures); err != nil {
+		return nil, err
+	}
+
+	if err = module.Validate(b.r.enabledFeatures); err != nil {
 		return nil, err
 	}
+	module.BuildMemoryDefinitions()
+	module.BuildTableDefinitions()
 
 	c := &compiledModule{module: module, compiledEngine: b.r.store.Engine}
 	listeners, err := buildFunctionListeners(ctx, module)
@@ -302,6 +302,66 @@ func TestNewHostModuleBuilder_Compile(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "memory, globals and table",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("host").
+					NewMemoryBuilder().WithMinPages(1).WithMaxPages(2).Export("memory").
+					NewGlobalBuilder().WithValue(i64, 42).WithMutable().Export("g1").
+					NewGlobalBuilder().Export("g0").
+					NewTableBuilder().WithRefType(api.ValueTypeExternref).WithMin(1).Export("table")
+			},
+			expected: &wasm.Module{
+				MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true}},
+				GlobalSection: []wasm.Global{
+					{Type: wasm.GlobalType{ValType: i64, Mutable: true}, Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: []byte{42}}},
+					{Type: wasm.GlobalType{ValType: i32}, Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}}},
+				},
+				TableSection: []wasm.Table{{Min: 1, Type: api.ValueTypeExternref}},
+				ExportSection: []wasm.Export{
+					{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+					{Name: "g1", Type: wasm.ExternTypeGlobal, Index: 0},
+					{Name: "g0", Type: wasm.ExternTypeGlobal, Index: 1},
+					{Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+				},
+				Exports: map[string]*wasm.Export{
+					"memory": {Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+					"g1":     {Name: "g1", Type: wasm.ExternTypeGlobal, Index: 0},
+					"g0":     {Name: "g0", Type: wasm.ExternTypeGlobal, Index: 1},
+					"table":  {Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+				},
+				NameSection: &wasm.NameSection{ModuleName: "host"},
+			},
+		},
+		{
+			name: "memory overwritten, without max",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("host").
+					NewFunctionBuilder().WithFunc(uint32_uint32).Export("1").
+					NewMemoryBuilder().WithMinPages(1).WithMaxPages(2).Export("memory").
+					NewMemoryBuilder().WithMinPages(3).Export("memory")
+			},
+			expected: &wasm.Module{
+				TypeSection: []wasm.FunctionType{
+					{Params: []api.ValueType{i32}, Results: []api.ValueType{i32}},
+				},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []wasm.Code{wasm.MustParseGoReflectFuncCode(uint32_uint32)},
+				MemorySection:   []wasm.Memory{{Min: 3, Cap: 3, Max: wasm.MemoryLimitPages}},
+				ExportSection: []wasm.Export{
+					{Name: "1", Type: wasm.ExternTypeFunc, Index: 0},
+					{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+				},
+				Exports: map[string]*wasm.Export{
+					"1":      {Name: "1", Type: wasm.ExternTypeFunc, Index: 0},
+					"memory": {Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+				},
+				NameSection: &wasm.NameSection{
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "1"}},
+					ModuleName:    "host",
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -350,6 +410,47 @@ func TestNewHostModuleBuilder_Compile_Errors(t *testing.T) {
 			},
 			expectedErr: `func[host.fn] param[0] is unsupported: string`,
 		},
+		{
+			name: "memory min over max",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").NewMemoryBuilder().
+					WithMinPages(2).WithMaxPages(1).Export("memory")
+			},
+			expectedErr: `memory[host.memory] min 2 pages (128 Ki) > max 1 pages (64 Ki)`,
+		},
+		{
+			name: "shared memory without threads",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").NewMemoryBuilder().
+					WithMaxPages(1).WithShared().Export("memory")
+			},
+			expectedErr: `memory[host.memory] shared memory requested but threads feature not enabled`,
+		},
+		{
+			name: "unsupported global type",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").NewGlobalBuilder().
+					WithValue(api.ValueTypeExternref, 0).Export("g")
+			},
+			expectedErr: `global[host.g] type externref is unsupported`,
+		},
+		{
+			name: "table min over max",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").NewTableBuilder().
+					WithMin(2).WithMax(1).Export("table")
+			},
+			expectedErr: `table[host.table] min 2 > max 1`,
+		},
+		{
+			name: "export name conflict",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").
+					NewFunctionBuilder().WithFunc(func() {}).Export("fn").
+					NewGlobalBuilder().Export("fn")
+			},
+			expectedErr: `global[host.fn] export name conflicts with another func`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -21,7 +22,8 @@ import (
 // # Notes
 //
 //   - This is an interface for decoupling, not third-party implementations.
-//     All implementations are in wazero.
+//     All implementations are in wazero. To plug in a custom storage, see
+//     CompilationCacheStore.
 //   - Instances of this can be reused across multiple runtimes, if configured
 //     via RuntimeConfig.
 //   - The cache check happens before the compilation, so if multiple Goroutines are
@@ -41,6 +43,79 @@ func NewCompilationCache() CompilationCache {
 	return &cache{}
 }
 
+// CompilationCacheKey is the 256-bit unique identifier of a compiled module in CompilationCacheStore.
+type CompilationCacheKey = [32]byte
+
+// CompilationCacheStore is a pluggable storage of the compiled modules behind a CompilationCache. This allows
+// the compilation results to be shared beyond a process, e.g. in an in-memory LRU, a content-addressed store or a
+// network store shared by multiple hosts.
+//
+// See NewCompilationCacheWithStore and NewDirCompilationCacheStore.
+//
+// # Notes
+//
+//   - Implementations must be safe for concurrent use by multiple goroutines.
+//   - The content is opaque and specific to the version of wazero, CPU features and RuntimeConfig, which are
+//     reflected in the key. The content of stale versions is deleted via Delete when detected.
+type CompilationCacheStore interface {
+	// Get returns the content added for the key with ok=true, or ok=false with err=nil if not found. content.Close
+	// is called by the caller.
+	//
+	// Note: the returned content doesn't go through the validation of the Wasm binary, so the store must return
+	// the content exactly as given to Add.
+	Get(key CompilationCacheKey) (content io.ReadCloser, ok bool, err error)
+
+	// Add stores the content for the key, overwriting the existing one if any.
+	Add(key CompilationCacheKey, content io.Reader) error
+
+	// Delete removes the content for the key if any. This is called when the content is no longer usable, for
+	// example when it was produced by a different version of wazero.
+	Delete(key CompilationCacheKey) error
+}
+
+// NewCompilationCacheWithStore is like wazero.NewCompilationCache except the compilation results are also
+// written to and read from the given store.
+func NewCompilationCacheWithStore(store CompilationCacheStore) CompilationCache {
+	return &cache{fileCache: store}
+}
+
+// DirCompilationCacheStore is the CompilationCacheStore persisting the compilation results into a directory.
+//
+// Each entry is checksummed, so corrupted entries are treated as a miss and replaced rather than failing the
+// compilation.
+type DirCompilationCacheStore interface {
+	CompilationCacheStore
+
+	// Size returns the total size of the entries in bytes.
+	Size() int64
+
+	// Prune removes corrupted entries and the leftovers of interrupted writes, and evicts the least recently used
+	// entries until the total size fits the limit. This also accounts for the entries written by other processes
+	// sharing the same directory, which this store otherwise learns about only when restarted.
+	Prune() error
+}
+
+// NewDirCompilationCacheStore returns a DirCompilationCacheStore writing into a wazero-version specific
+// subdirectory of dirname, which is created if it doesn't exist.
+//
+// When maxBytes is positive, the least recently used entries are evicted whenever the total size exceeds it.
+// Otherwise, the size is unlimited.
+//
+// Here's an example:
+//
+//	store, _ := wazero.NewDirCompilationCacheStore("/var/cache/wazero", 512<<20)
+//	_ = store.Prune() // Apply the limit to the entries written by other processes.
+//	cache := wazero.NewCompilationCacheWithStore(store)
+//	defer cache.Close(ctx)
+//	config := wazero.NewRuntimeConfig().WithCompilationCache(cache)
+func NewDirCompilationCacheStore(dirname string, maxBytes int64) (DirCompilationCacheStore, error) {
+	dirname, err := versionedCacheDir(dirname, version.GetWazeroVersion())
+	if err != nil {
+		return nil, err
+	}
+	return filecache.NewDir(dirname, maxBytes)
+}
+
 // NewCompilationCacheWithDir is like wazero.NewCompilationCache except the result also writes
 // state into the directory specified by `dirname` parameter.
 //
@@ -86,26 +161,33 @@ func (c *cache) Close(_ context.Context) (err error) {
 }
 
 func (c *cache) ensuresFileCache(dir string, wazeroVersion string) error {
-	// Resolve a potentially relative directory into an absolute one.
-	var err error
-	dir, err = filepath.Abs(dir)
+	dirname, err := versionedCacheDir(dir, wazeroVersion)
 	if err != nil {
 		return err
 	}
+	c.fileCache = filecache.New(dirname)
+	return nil
+}
+
+// versionedCacheDir ensures the wazero-version specific subdirectory of dir, and returns its absolute path.
+func versionedCacheDir(dir string, wazeroVersion string) (string, error) {
+	// Resolve a potentially relative directory into an absolute one.
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
 
 	// Ensure the user-supplied directory.
 	if err = mkdir(dir); err != nil {
-		return err
+		return "", err
 	}
 
 	// Create a version-specific directory to avoid conflicts.
 	dirname := path.Join(dir, "wazero-"+wazeroVersion+"-"+goruntime.GOARCH+"-"+goruntime.GOOS)
 	if err = mkdir(dirname); err != nil {
-		return err
+		return "", err
 	}
-
-	c.fileCache = filecache.New(dirname)
-	return nil
+	return dirname, nil
 }
 
 func mkdir(dirname string) error {
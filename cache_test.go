@@ -1,12 +1,15 @@
 package wazero
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	goruntime "runtime"
+	"sync"
 	"testing"
 
 	"github.com/tetratelabs/wazero/internal/platform"
@@ -223,7 +226,7 @@ func requireChdirToTemp(t *testing.T) (string, string) {
 
 func TestCache_Close(t *testing.T) {
 	t.Run("all engines", func(t *testing.T) {
-		c := &cache{engs: [engineKindCount]wasm.Engine{&mockEngine{}, &mockEngine{}}}
+		c := &cache{engs: [engineKindCount]wasm.Engine{&mockEngine{}, &mockEngine{}, &mockEngine{}}}
 		err := c.Close(testCtx)
 		require.NoError(t, err)
 		for i := engineKind(0); i < engineKindCount; i++ {
@@ -243,3 +246,103 @@ func TestCache_Close(t *testing.T) {
 		require.True(t, c.engs[engineKindCompiler].(*mockEngine).closed)
 	})
 }
+
+// mapStore is a CompilationCacheStore backed by a map, standing in for a third-party store.
+type mapStore struct {
+	mux     sync.Mutex
+	entries map[CompilationCacheKey][]byte
+	gets    int
+}
+
+func (s *mapStore) Get(key CompilationCacheKey) (io.ReadCloser, bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.gets++
+	b, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	return io.NopCloser(bytes.NewReader(b)), true, nil
+}
+
+func (s *mapStore) Add(key CompilationCacheKey, content io.Reader) error {
+	b, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.entries[key] = b
+	return nil
+}
+
+func (s *mapStore) Delete(key CompilationCacheKey) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func TestNewCompilationCacheWithStore(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	ctx := context.Background()
+	store := &mapStore{entries: map[CompilationCacheKey][]byte{}}
+
+	compile := func() {
+		c := NewCompilationCacheWithStore(store)
+		defer c.Close(ctx)
+		r := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler().WithCompilationCache(c))
+		defer r.Close(ctx)
+		_, err := r.CompileModule(ctx, facWasm)
+		require.NoError(t, err)
+	}
+
+	compile()
+	require.Equal(t, 1, len(store.entries))
+	require.Equal(t, 1, store.gets)
+
+	// The second cache has an empty in-memory cache, so the compiled module is read from the store.
+	compile()
+	require.Equal(t, 1, len(store.entries))
+	require.Equal(t, 2, store.gets)
+}
+
+func TestNewDirCompilationCacheStore(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	compile := func(store CompilationCacheStore, bin []byte) {
+		c := NewCompilationCacheWithStore(store)
+		defer c.Close(ctx)
+		r := NewRuntimeWithConfig(ctx, NewRuntimeConfigCompiler().WithCompilationCache(c))
+		defer r.Close(ctx)
+		_, err := r.CompileModule(ctx, bin)
+		require.NoError(t, err)
+	}
+
+	store, err := NewDirCompilationCacheStore(dir, 0)
+	require.NoError(t, err)
+	compile(store, facWasm)
+	facSize := store.Size()
+	require.True(t, facSize > 0)
+	compile(store, memGrowWasm)
+	require.True(t, store.Size() > facSize)
+
+	// Reopening with a limit only fitting one of them evicts the least recently used on Prune.
+	total := store.Size()
+	limited, err := NewDirCompilationCacheStore(dir, total-1)
+	require.NoError(t, err)
+	require.Equal(t, total, limited.Size())
+	require.NoError(t, limited.Prune())
+	require.True(t, limited.Size() < total)
+
+	notDir := path.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(notDir, nil, 0o600))
+	_, err = NewDirCompilationCacheStore(notDir, 0)
+	require.Contains(t, err.Error(), "is not dir")
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -18,7 +20,10 @@ import (
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/experimental/coverage"
+	"github.com/tetratelabs/wazero/experimental/debugging"
 	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/experimental/profiling"
 	"github.com/tetratelabs/wazero/experimental/sock"
 	"github.com/tetratelabs/wazero/experimental/sysfs"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
@@ -27,6 +32,10 @@ import (
 	"github.com/tetratelabs/wazero/sys"
 )
 
+// compiledModuleExt is the file extension of the modules written by "wazero compile -o", which "wazero run" loads
+// instead of compiling.
+const compiledModuleExt = ".wazero"
+
 func main() {
 	os.Exit(doMain(os.Stdout, os.Stderr))
 }
@@ -57,6 +66,8 @@ func doMain(stdOut io.Writer, stdErr logging.Writer) int {
 		return doCompile(flag.Args()[1:], stdErr)
 	case "run":
 		return doRun(flag.Args()[1:], stdOut, stdErr)
+	case "debug":
+		return doDebug(flag.Args()[1:], stdOut, stdErr)
 	case "version":
 		fmt.Fprintln(stdOut, version.GetWazeroVersion())
 		return 0
@@ -92,6 +103,11 @@ func doCompile(args []string, stdErr io.Writer) int {
 
 	cacheDir := cacheDirFlag(flags)
 
+	var output string
+	flags.StringVar(&output, "o", "",
+		"Writes the compiled native code to the given path, which can be run later without recompiling. "+
+			"The file is only valid for the same wazero version, platform and CPU.")
+
 	_ = flags.Parse(args)
 
 	if help {
@@ -128,6 +144,9 @@ func doCompile(args []string, stdErr io.Writer) int {
 	} else if cache != nil {
 		c = c.WithCompilationCache(cache)
 	}
+	if output != "" {
+		c = c.WithCompiledModuleExport(true)
+	}
 
 	ctx := context.Background()
 	rt := wazero.NewRuntimeWithConfig(ctx, c)
@@ -139,6 +158,11 @@ func doCompile(args []string, stdErr io.Writer) int {
 			fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
 			return 1
 		}
+		if count == 1 && output != "" {
+			if rc := writeCompiledModule(ctx, rt, compiledModule, output, stdErr); rc != 0 {
+				return rc
+			}
+		}
 		if err := compiledModule.Close(ctx); err != nil {
 			fmt.Fprintf(stdErr, "error releasing compiled module: %v\n", err)
 			return 1
@@ -148,8 +172,32 @@ func doCompile(args []string, stdErr io.Writer) int {
 	return 0
 }
 
+func writeCompiledModule(ctx context.Context, rt wazero.Runtime, compiled wazero.CompiledModule, path string, stdErr io.Writer) int {
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error creating output file: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	if err = rt.ExportCompiledModule(ctx, compiled, f); err != nil {
+		fmt.Fprintf(stdErr, "error exporting compiled module: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
 func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
-	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	return runWasm("run", args, stdOut, stdErr)
+}
+
+// doDebug is like doRun, but runs the binary on the interpreter once a client of the Debug Adapter Protocol connects.
+func doDebug(args []string, stdOut io.Writer, stdErr logging.Writer) int {
+	return runWasm("debug", args, stdOut, stdErr)
+}
+
+// runWasm runs the binary for the command, which is either "run" or "debug".
+func runWasm(cmd string, args []string, stdOut io.Writer, stdErr logging.Writer) int {
+	flags := flag.NewFlagSet(cmd, flag.ExitOnError)
 	flags.SetOutput(stdErr)
 
 	var help bool
@@ -206,6 +254,32 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 			"Enables memory profiling and writes the profile at the given path.")
 	}
 
+	var guestCPUProfile string
+	flags.StringVar(&guestCPUProfile, "guest-cpuprofile", "",
+		"Enables cpu profiling of the wasm binary and writes the profile in the pprof format at the given path. "+
+			"This is not supported by the interpreter.")
+
+	var coverProfile string
+	flags.StringVar(&coverProfile, "coverprofile", "",
+		"Enables code coverage of the wasm binary and writes the profile at the given path, "+
+			"in the lcov format if the path ends with .lcov or .info, or the Go cover format otherwise. "+
+			"The lines are read from the DWARF sections, and the binary runs on the interpreter.")
+
+	var dapAddr string
+	debug := cmd == "debug"
+	if debug {
+		flags.StringVar(&dapAddr, "addr", "localhost:4711",
+			"Address to listen for the client of the Debug Adapter Protocol in the form of <host:port>. "+
+				"The binary runs once the client connects and completes the configuration, such as the breakpoints.")
+	}
+
+	var gdbAddr string
+	if !debug {
+		flags.StringVar(&gdbAddr, "gdb", "",
+			"Address to listen for the client of the GDB remote serial protocol, such as LLDB, in the form of <host:port>. "+
+				"When set, the binary runs on the interpreter once the client connects, stopped at its entry.")
+	}
+
 	cacheDir := cacheDirFlag(flags)
 
 	_ = flags.Parse(args)
@@ -215,6 +289,11 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 		return 0
 	}
 
+	if debug || gdbAddr != "" || coverProfile != "" {
+		// The debugger and the coverage are only supported by the interpreter.
+		useInterpreter = true
+	}
+
 	if flags.NArg() < 1 {
 		fmt.Fprintln(stdErr, "missing path to wasm file")
 		printRunUsage(stdErr, flags)
@@ -230,6 +309,11 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 		defer stopCPUProfile()
 	}
 
+	if guestCPUProfile != "" && useInterpreter {
+		fmt.Fprintln(stdErr, "guest cpu profiling is not supported by the interpreter")
+		return 1
+	}
+
 	wasmPath := flags.Arg(0)
 	wasmArgs := flags.Args()[1:]
 	if len(wasmArgs) > 1 {
@@ -298,6 +382,44 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 		ctx = sock.WithConfig(ctx, sockCfg)
 	}
 
+	// serve serves the protocol of the debugger if any.
+	var serve func(context.Context, io.ReadWriter, *debugging.Debugger, func(context.Context) error) error
+	var serverName, serverAddr string
+	switch {
+	case debug:
+		serve, serverName, serverAddr = debugging.ServeDAP, "debug adapter", dapAddr
+	case gdbAddr != "":
+		serve, serverName, serverAddr = debugging.ServeGDB, "gdb stub", gdbAddr
+	}
+
+	var debugger *debugging.Debugger
+	var debugListener net.Listener
+	if serve != nil {
+		if debugListener, err = net.Listen("tcp", serverAddr); err != nil {
+			fmt.Fprintf(stdErr, "error listening for %s: %v\n", serverName, err)
+			return 1
+		}
+		defer debugListener.Close()
+		fmt.Fprintf(stdErr, "%s listening on %s\n", serverName, debugListener.Addr())
+		debugger = debugging.NewDebugger()
+		ctx = debugging.WithDebugger(ctx, debugger)
+		// Let the binary exit when the client disconnects.
+		rtc = rtc.WithCloseOnContextDone(true)
+	}
+
+	var profiler *profiling.Profiler
+	if guestCPUProfile != "" {
+		profiler = profiling.NewProfiler(profiling.DefaultSampleRate)
+		ctx = profiling.WithProfiler(ctx, profiler)
+		defer writeGuestCPUProfile(stdErr, guestCPUProfile, profiler)
+	}
+
+	if coverProfile != "" {
+		collector := coverage.NewCollector()
+		ctx = coverage.WithCollector(ctx, collector)
+		defer writeCoverProfile(stdErr, coverProfile, collector)
+	}
+
 	rt := wazero.NewRuntimeWithConfig(ctx, rtc)
 	defer rt.Close(ctx)
 
@@ -317,28 +439,47 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 		conf = conf.WithEnv(env[i], env[i+1])
 	}
 
-	guest, err := rt.CompileModule(ctx, wasm)
+	var guest wazero.CompiledModule
+	if filepath.Ext(wasmPath) == compiledModuleExt {
+		// The file was written by "wazero compile -o".
+		guest, err = rt.ImportCompiledModule(ctx, bytes.NewReader(wasm))
+	} else {
+		guest, err = rt.CompileModule(ctx, wasm)
+	}
 	if err != nil {
 		fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
 		return 1
 	}
 
-	switch detectImports(guest.ImportedFunctions()) {
-	case modeWasi:
-		wasi_snapshot_preview1.MustInstantiate(ctx, rt)
-		_, err = rt.InstantiateModule(ctx, guest, conf)
-	case modeWasiUnstable:
-		// Instantiate the current WASI functions under the wasi_unstable
-		// instead of wasi_snapshot_preview1.
-		wasiBuilder := rt.NewHostModuleBuilder("wasi_unstable")
-		wasi_snapshot_preview1.NewFunctionExporter().ExportFunctions(wasiBuilder)
-		_, err = wasiBuilder.Instantiate(ctx)
-		if err == nil {
-			// Instantiate our binary, but using the old import names.
+	if profiler != nil {
+		profiler.Start()
+	}
+
+	instantiate := func(ctx context.Context) (err error) {
+		switch detectImports(guest.ImportedFunctions()) {
+		case modeWasi:
+			wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+			_, err = rt.InstantiateModule(ctx, guest, conf)
+		case modeWasiUnstable:
+			// Instantiate the current WASI functions under the wasi_unstable
+			// instead of wasi_snapshot_preview1.
+			wasiBuilder := rt.NewHostModuleBuilder("wasi_unstable")
+			wasi_snapshot_preview1.NewFunctionExporter().ExportFunctions(wasiBuilder)
+			_, err = wasiBuilder.Instantiate(ctx)
+			if err == nil {
+				// Instantiate our binary, but using the old import names.
+				_, err = rt.InstantiateModule(ctx, guest, conf)
+			}
+		case modeDefault:
 			_, err = rt.InstantiateModule(ctx, guest, conf)
 		}
-	case modeDefault:
-		_, err = rt.InstantiateModule(ctx, guest, conf)
+		return
+	}
+
+	if serve != nil {
+		err = serveDebugger(ctx, debugListener, debugger, serve, instantiate)
+	} else {
+		err = instantiate(ctx)
 	}
 
 	if err != nil {
@@ -357,6 +498,23 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 	return 0
 }
 
+// serveDebugger serves the debugger by serve for the first client connecting to the listener, which runs the binary
+// by instantiate.
+func serveDebugger(
+	ctx context.Context,
+	ln net.Listener,
+	d *debugging.Debugger,
+	serve func(context.Context, io.ReadWriter, *debugging.Debugger, func(context.Context) error) error,
+	instantiate func(context.Context) error,
+) error {
+	conn, err := ln.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return serve(ctx, conn, d, instantiate)
+}
+
 func validateMounts(mounts sliceFlag, stdErr logging.Writer) (rc int, rootPath string, config wazero.FSConfig) {
 	config = wazero.NewFSConfig()
 	for _, mount := range mounts {
@@ -483,6 +641,7 @@ func printUsage(stdErr io.Writer) {
 	fmt.Fprintln(stdErr, "Commands:")
 	fmt.Fprintln(stdErr, "  compile\tPre-compiles a WebAssembly binary")
 	fmt.Fprintln(stdErr, "  run\t\tRuns a WebAssembly binary")
+	fmt.Fprintln(stdErr, "  debug\t\tRuns a WebAssembly binary with a Debug Adapter Protocol server")
 	fmt.Fprintln(stdErr, "  version\tDisplays the version of wazero CLI")
 }
 
@@ -498,7 +657,7 @@ func printCompileUsage(stdErr io.Writer, flags *flag.FlagSet) {
 func printRunUsage(stdErr io.Writer, flags *flag.FlagSet) {
 	fmt.Fprintln(stdErr, "wazero CLI")
 	fmt.Fprintln(stdErr)
-	fmt.Fprintln(stdErr, "Usage:\n  wazero run <options> <path to wasm file> [--] <wasm args>")
+	fmt.Fprintf(stdErr, "Usage:\n  wazero %s <options> <path to wasm file> [--] <wasm args>\n", flags.Name())
 	fmt.Fprintln(stdErr)
 	fmt.Fprintln(stdErr, "Options:")
 	flags.PrintDefaults()
@@ -536,6 +695,37 @@ func writeHeapProfile(stdErr io.Writer, path string) {
 	}
 }
 
+func writeGuestCPUProfile(stdErr io.Writer, path string, profiler *profiling.Profiler) {
+	profiler.Stop()
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error creating guest cpu profile output: %v\n", err)
+		return
+	}
+	defer f.Close()
+	if err := profiler.WriteProfile(f); err != nil {
+		fmt.Fprintf(stdErr, "error writing guest cpu profile: %v\n", err)
+	}
+}
+
+func writeCoverProfile(stdErr io.Writer, path string, collector *coverage.Collector) {
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error creating coverage profile output: %v\n", err)
+		return
+	}
+	defer f.Close()
+	switch filepath.Ext(path) {
+	case ".lcov", ".info":
+		err = collector.WriteLCOV(f)
+	default:
+		err = collector.WriteGoCover(f)
+	}
+	if err != nil {
+		fmt.Fprintf(stdErr, "error writing coverage profile: %v\n", err)
+	}
+}
+
 type sliceFlag []string
 
 func (f *sliceFlag) String() string {
@@ -1,23 +1,31 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	_ "embed"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/textproto"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental/logging"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 	"github.com/tetratelabs/wazero/internal/internalapi"
 	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/dwarftestdata"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/version"
 	"github.com/tetratelabs/wazero/sys"
@@ -144,6 +152,30 @@ func TestCompile(t *testing.T) {
 	}
 }
 
+func TestCompile_Output(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	tmpDir := t.TempDir()
+
+	wasmPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+	outputPath := filepath.Join(tmpDir, "test.wazero")
+
+	exitCode, stdout, stderr := runMain(t, "", []string{"compile", "-o", outputPath, wasmPath})
+	require.Equal(t, 0, exitCode, stderr)
+	require.Zero(t, stdout)
+
+	exitCode, stdout, stderr = runMain(t, "", []string{"run", outputPath, "hello world"})
+	require.Equal(t, 0, exitCode, stderr)
+	require.Equal(t, "test.wazero\x00hello world\x00", stdout)
+
+	// The configuration must match the one used to compile.
+	exitCode, _, stderr = runMain(t, "", []string{"run", "-timeout=10s", outputPath})
+	require.Equal(t, 1, exitCode)
+	require.Contains(t, stderr, "WithCloseOnContextDone")
+}
+
 func requireChdirToTemp(t *testing.T) (string, string) {
 	tmpDir := t.TempDir()
 	oldwd, err := os.Getwd()
@@ -230,6 +262,8 @@ func TestRun(t *testing.T) {
 
 	cpuProfile := filepath.Join(t.TempDir(), "cpu.out")
 	memProfile := filepath.Join(t.TempDir(), "mem.out")
+	guestCPUProfile := filepath.Join(t.TempDir(), "guest.pb.gz")
+	coverProfile := filepath.Join(t.TempDir(), "cover.out")
 
 	type test struct {
 		name             string
@@ -448,6 +482,25 @@ func TestRun(t *testing.T) {
 				require.NoError(t, exist(memProfile))
 			},
 		},
+		{
+			name:       "enable guest cpu profiling",
+			wazeroOpts: []string{"-guest-cpuprofile=" + guestCPUProfile},
+			wasm:       wasmWasiRandomGet,
+			test: func(t *testing.T) {
+				require.NoError(t, exist(guestCPUProfile))
+			},
+		},
+		{
+			name:       "enable code coverage",
+			wazeroOpts: []string{"-coverprofile=" + coverProfile},
+			wasm:       wasmWasiRandomGet,
+			test: func(t *testing.T) {
+				// The binary has no DWARF sections, so has no lines.
+				b, err := os.ReadFile(coverProfile)
+				require.NoError(t, err)
+				require.Equal(t, "mode: count\n", string(b))
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -523,6 +576,10 @@ func TestRun_Errors(t *testing.T) {
 			message: "timeout duration may not be negative",
 			args:    []string{"-timeout=-10s", wasmPath},
 		},
+		{
+			message: "guest cpu profiling is not supported by the interpreter",
+			args:    []string{"-interpreter", "-guest-cpuprofile=guest.pb.gz", wasmPath},
+		},
 	}
 
 	for _, tc := range tests {
@@ -536,6 +593,151 @@ func TestRun_Errors(t *testing.T) {
 	}
 }
 
+func TestRun_coverprofile(t *testing.T) {
+	tmpDir := t.TempDir()
+	wasmPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, dwarftestdata.ZigCCWasm, 0o700))
+	coverProfile := filepath.Join(tmpDir, "cover.lcov")
+
+	// The binary traps, but the coverage is still written.
+	exitCode, _, stderr := runMain(t, "", []string{"run", "-coverprofile=" + coverProfile, wasmPath})
+	require.NotEqual(t, 0, exitCode)
+	require.Contains(t, stderr, "unreachable")
+
+	b, err := os.ReadFile(coverProfile)
+	require.NoError(t, err)
+	lcov := string(b)
+	require.Contains(t, lcov, "zig-cc/main.c\n")
+	require.Contains(t, lcov, "DA:7,1\nDA:8,0\n")
+	require.Contains(t, lcov, "LF:8\nLH:6\n")
+}
+
+func TestDebug(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o700))
+
+	addr := freeAddr(t)
+	var exitCode int
+	var stdout, stderr string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exitCode, stdout, stderr = runMain(t, "", []string{"debug", "-addr=" + addr, wasmPath, "hello"})
+	}()
+
+	conn := dial(t, addr)
+	defer conn.Close()
+	r := textproto.NewReader(bufio.NewReader(conn))
+
+	// Stops at the entry, and then runs to the end.
+	writeDAPRequest(t, conn, 1, "initialize", map[string]interface{}{"adapterID": "wazero"})
+	writeDAPRequest(t, conn, 2, "launch", map[string]interface{}{"stopOnEntry": true})
+	writeDAPRequest(t, conn, 3, "configurationDone", nil)
+	msg := readDAPMessage(t, r, "stopped")
+	require.Equal(t, "entry", msg["body"].(map[string]interface{})["reason"])
+	writeDAPRequest(t, conn, 4, "stackTrace", map[string]interface{}{"threadId": 1})
+	msg = readDAPMessage(t, r, "stackTrace")
+	require.NotEqual(t, 0, len(msg["body"].(map[string]interface{})["stackFrames"].([]interface{})))
+	writeDAPRequest(t, conn, 5, "continue", map[string]interface{}{"threadId": 1})
+	msg = readDAPMessage(t, r, "exited")
+	require.Equal(t, float64(0), msg["body"].(map[string]interface{})["exitCode"])
+	writeDAPRequest(t, conn, 6, "disconnect", nil)
+	readDAPMessage(t, r, "disconnect")
+
+	<-done
+	require.Equal(t, 0, exitCode, stderr)
+	require.Equal(t, "test.wasm\x00hello\x00", stdout)
+	require.Contains(t, stderr, "debug adapter listening on "+addr)
+}
+
+func TestRun_GDB(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o700))
+
+	addr := freeAddr(t)
+	var exitCode int
+	var stdout, stderr string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exitCode, stdout, stderr = runMain(t, "", []string{"run", "-gdb=" + addr, wasmPath, "hello"})
+	}()
+
+	conn := dial(t, addr)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// Continues from the entry to the exit.
+	_, err := io.WriteString(conn, "$c#63")
+	require.NoError(t, err)
+	reply, err := r.ReadString('#')
+	require.NoError(t, err)
+	require.Equal(t, "+$W00#", reply)
+	require.NoError(t, conn.Close())
+
+	<-done
+	require.Equal(t, 0, exitCode, stderr)
+	require.Equal(t, "test.wasm\x00hello\x00", stdout)
+	require.Contains(t, stderr, "gdb stub listening on "+addr)
+}
+
+// freeAddr returns the address of a free local port.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// dial connects to the address, retrying until the server listens.
+func dial(t *testing.T, addr string) (conn net.Conn) {
+	var err error
+	for i := 0; i < 100; i++ {
+		if conn, err = net.Dial("tcp", addr); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	return
+}
+
+func TestDebug_Errors(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o700))
+
+	exitCode, _, stderr := runMain(t, "", []string{"debug", "-addr=invalid", wasmPath})
+	require.Equal(t, 1, exitCode)
+	require.Contains(t, stderr, "error listening for debug adapter")
+}
+
+func writeDAPRequest(t *testing.T, w io.Writer, seq int, command string, args interface{}) {
+	content, err := json.Marshal(map[string]interface{}{
+		"seq": seq, "type": "request", "command": command, "arguments": args,
+	})
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	require.NoError(t, err)
+}
+
+// readDAPMessage reads the messages until the response or the event of the given name, and returns it.
+func readDAPMessage(t *testing.T, r *textproto.Reader, name string) map[string]interface{} {
+	for {
+		header, err := r.ReadMIMEHeader()
+		require.NoError(t, err)
+		n, err := strconv.Atoi(header.Get("Content-Length"))
+		require.NoError(t, err)
+		content := make([]byte, n)
+		_, err = io.ReadFull(r.R, content)
+		require.NoError(t, err)
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(content, &msg))
+		if msg["command"] == name || msg["event"] == name {
+			return msg
+		}
+	}
+}
+
 var _ api.FunctionDefinition = importer{}
 
 type importer struct {
@@ -683,6 +885,7 @@ Usage:
 Commands:
   compile	Pre-compiles a WebAssembly binary
   run		Runs a WebAssembly binary
+  debug		Runs a WebAssembly binary with a Debug Adapter Protocol server
   version	Displays the version of wazero CLI
 `, stderr)
 }
@@ -54,14 +54,18 @@ type RuntimeConfig interface {
 
 	// WithMemoryLimitPages overrides the maximum pages allowed per memory. The
 	// default is 65536, allowing 4GB total memory per instance if the maximum is
-	// not encoded in a Wasm binary. Setting a value larger than default will panic.
+	// not encoded in a Wasm binary. Setting a value larger than 2^31 will panic.
 	//
 	// This example reduces the largest possible memory size from 4GB to 128KB:
 	//	rConfig = wazero.NewRuntimeConfig().WithMemoryLimitPages(2)
 	//
-	// Note: Wasm has 32-bit memory and each page is 65536 (2^16) bytes. This
-	// implies a max of 65536 (2^16) addressable pages.
-	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#grow-mem
+	// Notes:
+	//   - Wasm has 32-bit memory and each page is 65536 (2^16) bytes. This
+	//     implies a max of 65536 (2^16) addressable pages, so a limit larger
+	//     than default only applies to 64-bit memories.
+	//     See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#grow-mem
+	//   - 64-bit memories are only allowed when experimental.CoreFeaturesMemory64
+	//     is enabled.
 	WithMemoryLimitPages(memoryLimitPages uint32) RuntimeConfig
 
 	// WithMemoryCapacityFromMax eagerly allocates max memory, unless max is
@@ -168,8 +172,75 @@ type RuntimeConfig interface {
 	// When the invocations of api.Function are closed due to this, sys.ExitError is raised to the callers and
 	// the api.Module from which the functions are derived is made closed.
 	WithCloseOnContextDone(bool) RuntimeConfig
+
+	// WithCompilationWorkers sets the maximum number of goroutines used to compile the functions of a module
+	// concurrently. Defaults to zero, which means runtime.GOMAXPROCS(0).
+	//
+	// Setting this to one compiles functions one after another on the calling goroutine, which is useful to bound the
+	// CPU usage of Runtime.CompileModule, e.g. when compiling in the background of a latency-sensitive service.
+	//
+	// Notes:
+	//   - This only affects the compiler: the interpreter ignores this setting.
+	//   - The compiled code is the same regardless of the number of workers, so this doesn't affect the
+	//     CompilationCache.
+	WithCompilationWorkers(workers int) RuntimeConfig
+
+	// WithLazyCompilation compiles the functions of a module on their first call instead of in
+	// Runtime.CompileModule. Defaults to false.
+	//
+	// This reduces the time and memory used by Runtime.CompileModule for large modules of which only a few functions
+	// are called, at the cost of pausing calls while the functions they reach are compiled. When the
+	// CompilationCache is configured, functions are added to it one by one as they are compiled, so that other
+	// runtimes sharing it don't compile them again.
+	//
+	// Notes:
+	//   - This only affects the compiler: the interpreter ignores this setting.
+	//   - Functions call each other through an indirection, which makes the calls slightly slower.
+	//   - An error compiling a function is returned by the call reaching it.
+	WithLazyCompilation(bool) RuntimeConfig
+
+	// WithSymbolExport writes the symbols of the compiled code in the given formats, so that profilers such as
+	// Linux perf attribute the samples to the Wasm functions instead of unknown addresses. Defaults to zero, which
+	// writes nothing.
+	//
+	// For example, this records a profile with the source lines of the functions compiled with DWARF:
+	//
+	//	config := wazero.NewRuntimeConfig().WithSymbolExport(wazero.SymbolExportJITDump)
+	//
+	//	$ perf record -k mono -g ./app
+	//	$ perf inject --jit -i perf.data -o perf.jit.data
+	//	$ perf report -i perf.jit.data
+	//
+	// Notes:
+	//   - This only affects the compiler: the interpreter ignores this setting.
+	//   - This doesn't change the compiled code, so this doesn't affect the CompilationCache. However, the runtimes
+	//     sharing a CompilationCache share the compiler, which uses the setting of the first runtime.
+	//   - An error opening the files is returned by Runtime.CompileModule.
+	WithSymbolExport(SymbolExport) RuntimeConfig
+
+	// WithCompiledModuleExport retains a copy of the source binary of the modules compiled by Runtime.CompileModule
+	// or imported by Runtime.ImportCompiledModule, which Runtime.ExportCompiledModule embeds in the artifact.
+	// Defaults to false, so that compiled modules don't hold their source binary.
+	//
+	// Notes:
+	//   - This only affects the compiler, as only it supports Runtime.ExportCompiledModule.
+	//   - This doesn't change the compiled code, so this doesn't affect the CompilationCache.
+	WithCompiledModuleExport(bool) RuntimeConfig
 }
 
+// SymbolExport is a set of the formats in which RuntimeConfig.WithSymbolExport writes the symbols of the compiled code.
+type SymbolExport uint8
+
+const (
+	// SymbolExportPerfMap writes the names and the address ranges of the functions into /tmp/perf-<pid>.map, which is
+	// read by "perf report".
+	SymbolExportPerfMap SymbolExport = 1 << iota
+
+	// SymbolExportJITDump writes the machine code of the functions along with their names and source lines into
+	// /tmp/jit-<pid>.dump, which is read by "perf inject --jit". This is only supported on Linux.
+	SymbolExportJITDump
+)
+
 // NewRuntimeConfig returns a RuntimeConfig using the compiler if it is supported in this environment,
 // or the interpreter otherwise.
 func NewRuntimeConfig() RuntimeConfig {
@@ -190,6 +261,10 @@ type runtimeConfig struct {
 	cache                 CompilationCache
 	storeCustomSections   bool
 	ensureTermination     bool
+	compilationWorkers    int
+	lazyCompilation       bool
+	symbolExport          SymbolExport
+	compiledModuleExport  bool
 }
 
 // engineLessConfig helps avoid copy/pasting the wrong defaults.
@@ -206,6 +281,7 @@ const (
 	engineKindAuto engineKind = iota - 1
 	engineKindCompiler
 	engineKindInterpreter
+	engineKindTiered
 	engineKindCount
 )
 
@@ -226,6 +302,14 @@ const (
 //     support compiler. Use NewRuntimeConfig to safely detect and fallback to
 //     NewRuntimeConfigInterpreter if needed.
 //
+//   - On riscv64, modules using SIMD fail to compile, as the compiler has no vector instruction support there.
+//     NewRuntimeConfig chooses the interpreter if api.CoreFeatureSIMD is enabled, and NewRuntimeConfigTiered runs
+//     such modules on the interpreter.
+//
+//   - Modules using experimental.CoreFeaturesTailCall fail to compile if a function listener is configured, or if
+//     they tail call a function whose parameters passed on the native stack differ in size from the caller's. See
+//     experimental.CoreFeaturesTailCall.
+//
 //   - If you are using wazero in buildmode=c-archive or c-shared, make sure that you set up the alternate signal stack
 //     by using, e.g. `sigaltstack` combined with `SA_ONSTACK` flag on `sigaction` on Linux,
 //     before calling any api.Function. This is because the Go runtime does not set up the alternate signal stack
@@ -245,6 +329,37 @@ func NewRuntimeConfigInterpreter() RuntimeConfig {
 	return ret
 }
 
+// NewRuntimeConfigTiered interprets WebAssembly modules until they are compiled into runtime.GOARCH-specific assembly,
+// and then switches to the compiled code.
+//
+// Runtime.CompileModule only prepares modules for the interpreter before returning, and lets the compiler compile them
+// in the background. This minimizes the latency of the first function calls, e.g. in serverless environments, at the
+// cost of running them slower until the compilation is done. When the compilation is done, module instances switch to
+// the compiled code at their next function call. The CompilationCache is used as with NewRuntimeConfigCompiler, so
+// cached modules switch right away.
+//
+// # Notes
+//
+//   - This uses the interpreter only if the runtime.GOOS or runtime.GOARCH does not support the compiler.
+//   - Modules which the compiler doesn't support keep running on the interpreter, e.g. the ones using SIMD on
+//     riscv64, as the compiler has no vector instruction support there.
+//   - Module instances switch to the compiled code if they only import from host modules, so not globals, tables or
+//     tags. Module instances which don't satisfy this keep running on the interpreter, since modules running on
+//     different engines can't be linked.
+//   - Other modules may import from a named module instance at any time, and then run its functions on the
+//     interpreter. So a named instance only switches if it has no tables, element segments, mutable globals or
+//     globals of reference types, which would be stale on the interpreter. Instances are named after the module name
+//     in the name section by default, so instantiate them with ModuleConfig.WithName("") to let them switch anyway.
+//   - Module instances exporting tables keep running on the interpreter, as api.Table.SetFunction may store the
+//     functions of any module into them. Setting the function of an instance which switched fails if that instance
+//     has the state above, and otherwise keeps it from switching.
+//   - Host functions, function listeners and snapshots work the same on both engines.
+func NewRuntimeConfigTiered() RuntimeConfig {
+	ret := engineLessConfig.clone()
+	ret.engineKind = engineKindTiered
+	return ret
+}
+
 // clone makes a deep copy of this runtime config.
 func (c *runtimeConfig) clone() *runtimeConfig {
 	ret := *c // copy except maps which share a ref
@@ -265,12 +380,44 @@ func (c *runtimeConfig) WithCloseOnContextDone(ensure bool) RuntimeConfig {
 	return ret
 }
 
+// WithCompilationWorkers implements RuntimeConfig.WithCompilationWorkers
+func (c *runtimeConfig) WithCompilationWorkers(workers int) RuntimeConfig {
+	ret := c.clone()
+	// This panics instead of returning an error as it is unlikely.
+	if workers < 0 {
+		panic(fmt.Errorf("compilationWorkers invalid: %d < 0", workers))
+	}
+	ret.compilationWorkers = workers
+	return ret
+}
+
+// WithLazyCompilation implements RuntimeConfig.WithLazyCompilation
+func (c *runtimeConfig) WithLazyCompilation(lazy bool) RuntimeConfig {
+	ret := c.clone()
+	ret.lazyCompilation = lazy
+	return ret
+}
+
+// WithSymbolExport implements RuntimeConfig.WithSymbolExport
+func (c *runtimeConfig) WithSymbolExport(formats SymbolExport) RuntimeConfig {
+	ret := c.clone()
+	ret.symbolExport = formats
+	return ret
+}
+
+// WithCompiledModuleExport implements RuntimeConfig.WithCompiledModuleExport
+func (c *runtimeConfig) WithCompiledModuleExport(export bool) RuntimeConfig {
+	ret := c.clone()
+	ret.compiledModuleExport = export
+	return ret
+}
+
 // WithMemoryLimitPages implements RuntimeConfig.WithMemoryLimitPages
 func (c *runtimeConfig) WithMemoryLimitPages(memoryLimitPages uint32) RuntimeConfig {
 	ret := c.clone()
 	// This panics instead of returning an error as it is unlikely.
-	if memoryLimitPages > wasm.MemoryLimitPages {
-		panic(fmt.Errorf("memoryLimitPages invalid: %d > %d", memoryLimitPages, wasm.MemoryLimitPages))
+	if memoryLimitPages > wasm.MemoryLimitPages64 {
+		panic(fmt.Errorf("memoryLimitPages invalid: %d > %d", memoryLimitPages, wasm.MemoryLimitPages64))
 	}
 	ret.memoryLimitPages = memoryLimitPages
 	return ret
@@ -335,7 +482,7 @@ type CompiledModule interface {
 	//
 	// ## Notes
 	//   - As of WebAssembly Core Specification 2.0, there can be at most one
-	//     memory.
+	//     memory, unless experimental.CoreFeaturesMultiMemory is enabled.
 	//   - Unlike ExportedMemories, there is no unique constraint on imports.
 	ImportedMemories() []api.MemoryDefinition
 
@@ -343,9 +490,19 @@ type CompiledModule interface {
 	// (api.MemoryDefinition) in this module keyed on export name.
 	//
 	// Note: As of WebAssembly Core Specification 2.0, there can be at most one
-	// memory.
+	// memory, unless experimental.CoreFeaturesMultiMemory is enabled.
 	ExportedMemories() map[string]api.MemoryDefinition
 
+	// ImportedTables returns all the imported tables
+	// (api.TableDefinition) in this module or nil if there are none.
+	//
+	// Note: Unlike ExportedTables, there is no unique constraint on imports.
+	ImportedTables() []api.TableDefinition
+
+	// ExportedTables returns all the exported tables
+	// (api.TableDefinition) in this module keyed on export name.
+	ExportedTables() map[string]api.TableDefinition
+
 	// CustomSections returns all the custom sections
 	// (api.CustomSection) in this module keyed on the section name.
 	CustomSections() []api.CustomSection
@@ -367,6 +524,9 @@ type compiledModule struct {
 	// closeWithModule prevents leaking compiled code when a module is compiled implicitly.
 	closeWithModule bool
 	typeIDs         []wasm.FunctionTypeID
+	// binary is a copy of the source Wasm binary, retained for Runtime.ExportCompiledModule when
+	// RuntimeConfig.WithCompiledModuleExport is enabled. This is nil otherwise, and for host modules.
+	binary []byte
 }
 
 // Name implements CompiledModule.Name
@@ -404,6 +564,16 @@ func (c *compiledModule) ExportedMemories() map[string]api.MemoryDefinition {
 	return c.module.ExportedMemories()
 }
 
+// ImportedTables implements CompiledModule.ImportedTables
+func (c *compiledModule) ImportedTables() []api.TableDefinition {
+	return c.module.ImportedTables()
+}
+
+// ExportedTables implements CompiledModule.ExportedTables
+func (c *compiledModule) ExportedTables() map[string]api.TableDefinition {
+	return c.module.ExportedTables()
+}
+
 // CustomSections implements CompiledModule.CustomSections
 func (c *compiledModule) CustomSections() []api.CustomSection {
 	ret := make([]api.CustomSection, len(c.module.CustomSections))
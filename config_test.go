@@ -72,6 +72,26 @@ func TestRuntimeConfig(t *testing.T) {
 			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithCloseOnContextDone(true) },
 			expected: &runtimeConfig{ensureTermination: true},
 		},
+		{
+			name:     "WithCompilationWorkers",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithCompilationWorkers(4) },
+			expected: &runtimeConfig{compilationWorkers: 4},
+		},
+		{
+			name:     "WithLazyCompilation",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithLazyCompilation(true) },
+			expected: &runtimeConfig{lazyCompilation: true},
+		},
+		{
+			name:     "WithSymbolExport",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithSymbolExport(SymbolExportPerfMap | SymbolExportJITDump) },
+			expected: &runtimeConfig{symbolExport: SymbolExportPerfMap | SymbolExportJITDump},
+		},
+		{
+			name:     "WithCompiledModuleExport",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithCompiledModuleExport(true) },
+			expected: &runtimeConfig{compiledModuleExport: true},
+		},
 	}
 
 	for _, tt := range tests {
@@ -89,9 +109,17 @@ func TestRuntimeConfig(t *testing.T) {
 	t.Run("memoryLimitPages invalid panics", func(t *testing.T) {
 		err := require.CapturePanic(func() {
 			input := &runtimeConfig{}
-			input.WithMemoryLimitPages(wasm.MemoryLimitPages + 1)
+			input.WithMemoryLimitPages(wasm.MemoryLimitPages64 + 1)
+		})
+		require.EqualError(t, err, "memoryLimitPages invalid: 2147483649 > 2147483648")
+	})
+
+	t.Run("compilationWorkers invalid panics", func(t *testing.T) {
+		err := require.CapturePanic(func() {
+			input := &runtimeConfig{}
+			input.WithCompilationWorkers(-1)
 		})
-		require.EqualError(t, err, "memoryLimitPages invalid: 65537 > 65536")
+		require.EqualError(t, err, "compilationWorkers invalid: -1 < 0")
 	})
 }
 
@@ -0,0 +1,210 @@
+// Package coverage collects the code coverage of the guest code, which can be written as an lcov tracefile or a Go
+// cover profile.
+//
+// Collector counts the executions of the blocks of the instructions executed all together, which end at the control
+// instructions and the calls. The blocks are mapped to the source lines by the DWARF sections, so the Wasm binaries
+// must be built with the debug information.
+//
+// Here's an example of collecting the coverage of a run:
+//
+//	c := coverage.NewCollector()
+//	ctx = coverage.WithCollector(ctx, c)
+//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+//	_, _ = r.Instantiate(ctx, wasm) // Compile with the same ctx.
+//	_ = c.WriteLCOV(f)
+//
+// # Notes
+//
+//   - This is an experimental API and subject to change.
+//   - The coverage is only supported by the interpreter. The compiler ignores it.
+//   - A block is counted right before its last instruction, so the instructions before a trap in the same block are
+//     not counted.
+//   - The modules without the DWARF sections are excluded from the profiles.
+package coverage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	internalcoverage "github.com/tetratelabs/wazero/internal/coverage"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// WithCollector returns a context which enables the collector for the modules compiled and the functions called with
+// it. The same collector can be used by multiple modules and concurrent calls.
+//
+// Note: The modules must be compiled with the returned context, as the collector requires the counters of the blocks
+// in the compiled code. This changes the compiled code, so the modules are cached separately from those without the
+// collector.
+func WithCollector(ctx context.Context, c *Collector) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, expctxkeys.CoverageCollectorKey{}, (*collector)(c))
+}
+
+// Collector counts the executions of the blocks in the calls made with a context returned by WithCollector. This is
+// created by NewCollector.
+type Collector struct {
+	// counts maps the functions executed to the execution counts of their blocks, which are incremented atomically so
+	// that the concurrent calls don't contend on a lock. This is replaced by Reset.
+	counts atomic.Pointer[sync.Map]
+}
+
+// NewCollector returns a Collector which has counted nothing.
+func NewCollector() *Collector {
+	c := &Collector{}
+	c.Reset()
+	return c
+}
+
+// Reset clears the counts. The blocks executed concurrently may be counted either before or after it.
+func (c *Collector) Reset() {
+	c.counts.Store(&sync.Map{})
+}
+
+// Line is a source line of the instructions in the DWARF sections.
+type Line struct {
+	// File is the path of the source file in the DWARF sections.
+	File string
+	// Line is the line number, starting from 1.
+	Line int64
+	// Count is the number of the executions of the line, which is the largest count of the blocks including its
+	// instructions.
+	Count uint64
+}
+
+// Lines returns the lines of the modules whose functions have been executed so far, sorted by the files and the line
+// numbers. The lines which have never been executed have zero Count.
+func (c *Collector) Lines() []Line {
+	// Group the counts of the blocks per module.
+	modules := map[*wasm.Module][]countedBlock{}
+	c.counts.Load().Range(func(key, value any) bool {
+		f, counts := key.(*internalcoverage.Function), value.([]uint64)
+		for i, b := range f.Blocks {
+			modules[f.Module] = append(modules[f.Module], countedBlock{Block: b, count: atomic.LoadUint64(&counts[i])})
+		}
+		return true
+	})
+
+	type key struct {
+		file string
+		line int64
+	}
+	lines := map[key]*Line{}
+	for m, blocks := range modules {
+		sort.Slice(blocks, func(i, j int) bool { return blocks[i].Start < blocks[j].Start })
+		rows := m.DWARFLines.Rows()
+		for i := range rows {
+			row := &rows[i]
+			if row.EndSequence || row.Line == 0 {
+				continue
+			}
+			// The row spans the instructions up to the next row, which exists as a sequence ends with EndSequence.
+			end := row.Address + 1
+			if i+1 < len(rows) {
+				end = rows[i+1].Address
+			}
+			k := key{row.File, row.Line}
+			l, ok := lines[k]
+			if !ok {
+				l = &Line{File: row.File, Line: row.Line}
+				lines[k] = l
+			}
+			if count := maxCount(blocks, row.Address, end); count > l.Count {
+				l.Count = count
+			}
+		}
+	}
+
+	ret := make([]Line, 0, len(lines))
+	for _, l := range lines {
+		ret = append(ret, *l)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].File != ret[j].File {
+			return ret[i].File < ret[j].File
+		}
+		return ret[i].Line < ret[j].Line
+	})
+	return ret
+}
+
+// countedBlock is a block with its execution count.
+type countedBlock struct {
+	internalcoverage.Block
+	count uint64
+}
+
+// maxCount returns the largest count of the blocks overlapping the range of the source offsets [start, end), where
+// the blocks are sorted and don't overlap each other.
+func maxCount(blocks []countedBlock, start, end uint64) (count uint64) {
+	for i := sort.Search(len(blocks), func(i int) bool { return blocks[i].End > start }); i < len(blocks); i++ {
+		b := &blocks[i]
+		if b.Start >= end {
+			break
+		}
+		if b.count > count {
+			count = b.count
+		}
+	}
+	return
+}
+
+// WriteLCOV writes the Lines as an lcov tracefile, which can be read by genhtml for example.
+//
+// See https://github.com/linux-test-project/lcov/blob/master/man/geninfo.1
+func (c *Collector) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	lines := c.Lines()
+	for len(lines) > 0 {
+		file := lines[0].File
+		n := 1
+		for n < len(lines) && lines[n].File == file {
+			n++
+		}
+		hit := 0
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", file)
+		for _, l := range lines[:n] {
+			fmt.Fprintf(bw, "DA:%d,%d\n", l.Line, l.Count)
+			if l.Count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", n, hit)
+		lines = lines[n:]
+	}
+	return bw.Flush()
+}
+
+// WriteGoCover writes the Lines as a Go cover profile in the "count" mode, which is the format of "go test
+// -coverprofile". Each line is a block with a statement, which spans from the beginning of the line to the next one.
+func (c *Collector) WriteGoCover(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "mode: count")
+	for _, l := range c.Lines() {
+		fmt.Fprintf(bw, "%s:%d.1,%d.1 1 %d\n", l.File, l.Line, l.Line+1, l.Count)
+	}
+	return bw.Flush()
+}
+
+// collector implements internalcoverage.Collector for Collector.
+type collector Collector
+
+var _ internalcoverage.Collector = (*collector)(nil)
+
+// Hit implements internalcoverage.Collector.
+func (h *collector) Hit(f *internalcoverage.Function, block int) {
+	counts := (*Collector)(h).counts.Load()
+	value, ok := counts.Load(f)
+	if !ok {
+		value, _ = counts.LoadOrStore(f, make([]uint64, len(f.Blocks)))
+	}
+	atomic.AddUint64(&value.([]uint64)[block], 1)
+}
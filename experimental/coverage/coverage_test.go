@@ -0,0 +1,125 @@
+package coverage_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental/coverage"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/dwarftestdata"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestCollector(t *testing.T) {
+	c := coverage.NewCollector()
+	ctx := coverage.WithCollector(context.Background(), c)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	compiled, err := r.CompileModule(ctx, dwarftestdata.ZigCCWasm)
+	require.NoError(t, err)
+
+	// Runs main twice, which traps at the line 7 each time.
+	for _, name := range []string{"1", "2"} {
+		_, err = r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(name))
+		require.Error(t, err)
+	}
+
+	lines := c.Lines()
+	require.Equal(t, 8, len(lines))
+	file := lines[0].File
+	require.True(t, strings.HasSuffix(file, "zig-cc/main.c"), file)
+	var actual []string
+	for _, l := range lines {
+		require.Equal(t, file, l.File)
+		actual = append(actual, fmt.Sprintf("%d:%d", l.Line, l.Count))
+	}
+	// The lines after the trap are never executed.
+	require.Equal(t, []string{"3:2", "5:2", "6:2", "7:2", "8:0", "10:2", "11:2", "12:0"}, actual)
+
+	var lcov bytes.Buffer
+	require.NoError(t, c.WriteLCOV(&lcov))
+	require.Equal(t, "TN:\nSF:"+file+`
+DA:3,2
+DA:5,2
+DA:6,2
+DA:7,2
+DA:8,0
+DA:10,2
+DA:11,2
+DA:12,0
+LF:8
+LH:6
+end_of_record
+`, lcov.String())
+
+	var cover bytes.Buffer
+	require.NoError(t, c.WriteGoCover(&cover))
+	coverLines := strings.Split(cover.String(), "\n")
+	require.Equal(t, 10, len(coverLines))
+	require.Equal(t, "mode: count", coverLines[0])
+	require.Equal(t, file+":7.1,8.1 1 2", coverLines[4])
+	require.Equal(t, file+":8.1,9.1 1 0", coverLines[5])
+
+	c.Reset()
+	require.Equal(t, 0, len(c.Lines()))
+}
+
+func TestCollector_concurrent(t *testing.T) {
+	c := coverage.NewCollector()
+	ctx := coverage.WithCollector(context.Background(), c)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	compiled, err := r.CompileModule(ctx, dwarftestdata.ZigCCWasm)
+	require.NoError(t, err)
+
+	const n = 16
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			_, _ = r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(name))
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	// Every run is counted, and each traps at the line 7.
+	counts := map[int64]uint64{}
+	for _, l := range c.Lines() {
+		counts[l.Line] = l.Count
+	}
+	require.Equal(t, uint64(n), counts[7])
+	require.Equal(t, uint64(0), counts[8])
+}
+
+func TestCollector_withoutDWARF(t *testing.T) {
+	c := coverage.NewCollector()
+	ctx := coverage.WithCollector(context.Background(), c)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	mod, err := r.Instantiate(ctx, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+		ExportSection:   []wasm.Export{{Name: "f", Type: wasm.ExternTypeFunc, Index: 0}},
+	}))
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("f").Call(ctx)
+	require.NoError(t, err)
+
+	// The module is executed, but it has no lines.
+	require.Equal(t, 0, len(c.Lines()))
+	var lcov bytes.Buffer
+	require.NoError(t, c.WriteLCOV(&lcov))
+	require.Equal(t, "", lcov.String())
+}
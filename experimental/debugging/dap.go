@@ -0,0 +1,535 @@
+package debugging
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// ServeDAP serves the Debug Adapter Protocol over conn with the debugger until the client disconnects or conn is
+// closed, e.g. for the "debugAdapter" of VS Code connecting to a TCP port.
+//
+// run is called in a goroutine once the client completes the configuration after the "launch" or "attach" request,
+// which sets the breakpoints for example. It must make the calls to debug, and return the error of them if any. Its
+// context is derived from ctx, and canceled when the client disconnects, which should make the calls exit e.g. by
+// wazero.RuntimeConfig WithCloseOnContextDone. This returns the error returned by run, or nil if it isn't called.
+//
+// The calls are presented as a single thread. The scopes of the frames are the locals, the operand stack and the
+// globals, and the memory can be read at the values of the i32 locals and globals as the addresses.
+//
+// See https://microsoft.github.io/debug-adapter-protocol/specification
+func ServeDAP(ctx context.Context, conn io.ReadWriter, d *Debugger, run func(context.Context) error) error {
+	s := &dapServer{
+		d:               d,
+		r:               textproto.NewReader(bufio.NewReader(conn)),
+		w:               conn,
+		lineBreakpoints: map[string][]int{},
+	}
+	defer d.Detach()
+
+	quit := make(chan struct{})
+	defer close(quit)
+	go s.sendStops(quit)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var done chan error
+	for {
+		req, err := s.read()
+		if err != nil {
+			break
+		}
+		if req.Command == "configurationDone" && done == nil {
+			s.respond(req, nil)
+			done = make(chan error, 1)
+			go s.run(runCtx, run, done)
+			continue
+		}
+		if disconnect := s.handle(req); disconnect {
+			break
+		}
+	}
+
+	if done == nil {
+		return nil
+	}
+	// Let the calls exit.
+	cancel()
+	d.Detach()
+	return <-done
+}
+
+// dapThreadID is the ID of the only thread, which represents all the calls.
+const dapThreadID = 1
+
+// dapServer serves the Debug Adapter Protocol for ServeDAP.
+type dapServer struct {
+	d *Debugger
+	r *textproto.Reader
+
+	mux sync.Mutex
+	w   io.Writer
+	// seq is the sequence number of the last message sent.
+	seq int
+
+	// lineBreakpoints are the IDs of the line breakpoints per source path.
+	lineBreakpoints map[string][]int
+	// functionBreakpoints are the IDs of the function breakpoints.
+	functionBreakpoints []int
+}
+
+// dapRequest is a request from the client.
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// dapResponse is a response to a dapRequest.
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// dapEvent is an event sent to the client.
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// read reads the next request, which is prefixed by the headers as in HTTP.
+func (s *dapServer) read() (*dapRequest, error) {
+	header, err := s.r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %w", err)
+	}
+	content := make([]byte, n)
+	if _, err = io.ReadFull(s.r.R, content); err != nil {
+		return nil, err
+	}
+	var req dapRequest
+	if err = json.Unmarshal(content, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// send sends the message after setting its sequence number by setSeq.
+func (s *dapServer) send(msg interface{}, setSeq func(seq int)) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.seq++
+	setSeq(s.seq)
+	content, err := json.Marshal(msg)
+	if err != nil {
+		panic(err) // The messages are always encodable.
+	}
+	// The errors are ignored, as the next read fails if the client is gone.
+	_, _ = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(content), content)
+}
+
+func (s *dapServer) respond(req *dapRequest, body interface{}) {
+	res := &dapResponse{Type: "response", RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body}
+	s.send(res, func(seq int) { res.Seq = seq })
+}
+
+func (s *dapServer) respondError(req *dapRequest, err error) {
+	res := &dapResponse{Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: err.Error()}
+	s.send(res, func(seq int) { res.Seq = seq })
+}
+
+func (s *dapServer) event(event string, body interface{}) {
+	ev := &dapEvent{Type: "event", Event: event, Body: body}
+	s.send(ev, func(seq int) { ev.Seq = seq })
+}
+
+// sendStops sends the "stopped" events of the calls until quit is closed.
+func (s *dapServer) sendStops(quit <-chan struct{}) {
+	for {
+		select {
+		case <-quit:
+			return
+		case stop := <-s.d.Stops():
+			body := map[string]interface{}{
+				"reason":            stop.Reason.String(),
+				"threadId":          dapThreadID,
+				"allThreadsStopped": true,
+				"hitBreakpointIds":  stop.Breakpoints,
+			}
+			if stop.Err != nil {
+				body["text"] = stop.Err.Error()
+			}
+			s.event("stopped", body)
+		}
+	}
+}
+
+// run calls run, and then sends the events of its exit.
+func (s *dapServer) run(ctx context.Context, run func(context.Context) error, done chan<- error) {
+	err := run(ctx)
+	exitCode := uint32(0)
+	if exitErr := (*sys.ExitError)(nil); errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		exitCode = 1
+		s.event("output", map[string]interface{}{"category": "stderr", "output": err.Error() + "\n"})
+	}
+	s.event("exited", map[string]interface{}{"exitCode": exitCode})
+	s.event("terminated", nil)
+	done <- err
+}
+
+// handle handles the request other than "configurationDone", and returns true if the client disconnects.
+func (s *dapServer) handle(req *dapRequest) (disconnect bool) {
+	var err error
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsSteppingGranularity":      true,
+			"supportsReadMemoryRequest":        true,
+			"exceptionBreakpointFilters": []interface{}{
+				map[string]interface{}{"filter": dapTrapFilter, "label": "Traps"},
+			},
+		})
+		s.event("initialized", nil)
+	case "launch", "attach":
+		var args struct {
+			StopOnEntry bool `json:"stopOnEntry"`
+		}
+		if err = s.unmarshal(req, &args); err == nil {
+			if args.StopOnEntry {
+				s.d.StopOnEntry()
+			}
+			s.respond(req, nil)
+		}
+	case "disconnect":
+		s.respond(req, nil)
+		return true
+	case "setBreakpoints":
+		err = s.setBreakpoints(req)
+	case "setFunctionBreakpoints":
+		err = s.setFunctionBreakpoints(req)
+	case "setExceptionBreakpoints":
+		err = s.setExceptionBreakpoints(req)
+	case "threads":
+		s.respond(req, map[string]interface{}{
+			"threads": []interface{}{map[string]interface{}{"id": dapThreadID, "name": "wasm"}},
+		})
+	case "stackTrace":
+		err = s.stackTrace(req)
+	case "scopes":
+		err = s.scopes(req)
+	case "variables":
+		err = s.variables(req)
+	case "readMemory":
+		err = s.readMemory(req)
+	case "continue":
+		s.respond(req, map[string]interface{}{"allThreadsContinued": true})
+		s.d.Continue()
+	case "next", "stepIn", "stepOut":
+		var args struct {
+			Granularity string `json:"granularity"`
+		}
+		if err = s.unmarshal(req, &args); err == nil {
+			g := GranularityLine
+			if args.Granularity == "instruction" {
+				g = GranularityInstruction
+			}
+			s.respond(req, nil)
+			switch req.Command {
+			case "next":
+				s.d.StepOver(g)
+			case "stepIn":
+				s.d.StepIn(g)
+			default:
+				s.d.StepOut()
+			}
+		}
+	case "pause":
+		s.respond(req, nil)
+		s.d.Pause()
+	default:
+		err = fmt.Errorf("unsupported command: %s", req.Command)
+	}
+	if err != nil {
+		s.respondError(req, err)
+	}
+	return false
+}
+
+func (s *dapServer) unmarshal(req *dapRequest, args interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	return json.Unmarshal(req.Arguments, args)
+}
+
+func (s *dapServer) setBreakpoints(req *dapRequest) error {
+	var args struct {
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []struct {
+			Line int64 `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := s.unmarshal(req, &args); err != nil {
+		return err
+	}
+	path := args.Source.Path
+	for _, id := range s.lineBreakpoints[path] {
+		s.d.ClearBreakpoint(id)
+	}
+	ids := make([]int, 0, len(args.Breakpoints))
+	breakpoints := make([]interface{}, 0, len(args.Breakpoints))
+	for _, bp := range args.Breakpoints {
+		id := s.d.SetLineBreakpoint(path, bp.Line)
+		ids = append(ids, id)
+		breakpoints = append(breakpoints, map[string]interface{}{"id": id, "verified": true, "line": bp.Line})
+	}
+	s.lineBreakpoints[path] = ids
+	s.respond(req, map[string]interface{}{"breakpoints": breakpoints})
+	return nil
+}
+
+func (s *dapServer) setFunctionBreakpoints(req *dapRequest) error {
+	var args struct {
+		Breakpoints []struct {
+			Name string `json:"name"`
+		} `json:"breakpoints"`
+	}
+	if err := s.unmarshal(req, &args); err != nil {
+		return err
+	}
+	for _, id := range s.functionBreakpoints {
+		s.d.ClearBreakpoint(id)
+	}
+	s.functionBreakpoints = s.functionBreakpoints[:0]
+	breakpoints := make([]interface{}, 0, len(args.Breakpoints))
+	for _, bp := range args.Breakpoints {
+		id := s.d.SetFunctionBreakpoint(bp.Name)
+		s.functionBreakpoints = append(s.functionBreakpoints, id)
+		breakpoints = append(breakpoints, map[string]interface{}{"id": id, "verified": true})
+	}
+	s.respond(req, map[string]interface{}{"breakpoints": breakpoints})
+	return nil
+}
+
+// dapTrapFilter is the filter of the exception breakpoints which stops the calls at the traps.
+const dapTrapFilter = "trap"
+
+func (s *dapServer) setExceptionBreakpoints(req *dapRequest) error {
+	var args struct {
+		Filters []string `json:"filters"`
+	}
+	if err := s.unmarshal(req, &args); err != nil {
+		return err
+	}
+	trap := false
+	breakpoints := make([]interface{}, 0, len(args.Filters))
+	for _, filter := range args.Filters {
+		trap = trap || filter == dapTrapFilter
+		breakpoints = append(breakpoints, map[string]interface{}{"verified": filter == dapTrapFilter})
+	}
+	s.d.StopOnTrap(trap)
+	s.respond(req, map[string]interface{}{"breakpoints": breakpoints})
+	return nil
+}
+
+var errNotStopped = errors.New("not stopped")
+
+func (s *dapServer) stackTrace(req *dapRequest) error {
+	var args struct {
+		StartFrame int `json:"startFrame"`
+		Levels     int `json:"levels"`
+	}
+	if err := s.unmarshal(req, &args); err != nil {
+		return err
+	}
+	stop := s.d.Stopped()
+	if stop == nil {
+		return errNotStopped
+	}
+	frames := stop.Frames
+	if args.StartFrame > len(frames) {
+		args.StartFrame = len(frames)
+	}
+	end := len(frames)
+	if args.Levels > 0 && args.StartFrame+args.Levels < end {
+		end = args.StartFrame + args.Levels
+	}
+	stackFrames := make([]interface{}, 0, end-args.StartFrame)
+	for i := args.StartFrame; i < end; i++ {
+		f := &frames[i]
+		frame := map[string]interface{}{
+			// The IDs start at 1, as zero means no frame.
+			"id":                          i + 1,
+			"name":                        f.Function.DebugName(),
+			"line":                        f.Line,
+			"column":                      f.Column,
+			"instructionPointerReference": fmt.Sprintf("%#x", f.SourceOffset),
+		}
+		if f.File != "" {
+			name := f.File[strings.LastIndexByte(f.File, '/')+1:]
+			frame["source"] = map[string]interface{}{"name": name, "path": f.File}
+		}
+		stackFrames = append(stackFrames, frame)
+	}
+	s.respond(req, map[string]interface{}{"stackFrames": stackFrames, "totalFrames": len(frames)})
+	return nil
+}
+
+// The scopes of a frame, whose variablesReference is (frame ID) * dapScopeNum + (scope).
+const (
+	dapScopeLocals = iota
+	dapScopeStack
+	dapScopeGlobals
+	dapScopeNum
+)
+
+func (s *dapServer) scopes(req *dapRequest) error {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := s.unmarshal(req, &args); err != nil {
+		return err
+	}
+	f, err := s.frame(args.FrameID)
+	if err != nil {
+		return err
+	}
+	ref := args.FrameID * dapScopeNum
+	s.respond(req, map[string]interface{}{"scopes": []interface{}{
+		map[string]interface{}{"name": "Locals", "presentationHint": "locals", "variablesReference": ref + dapScopeLocals,
+			"namedVariables": len(f.Locals), "expensive": false},
+		map[string]interface{}{"name": "Operand Stack", "variablesReference": ref + dapScopeStack,
+			"indexedVariables": len(f.Stack), "expensive": false},
+		map[string]interface{}{"name": "Globals", "variablesReference": ref + dapScopeGlobals,
+			"namedVariables": len(f.Globals), "expensive": false},
+	}})
+	return nil
+}
+
+func (s *dapServer) variables(req *dapRequest) error {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := s.unmarshal(req, &args); err != nil {
+		return err
+	}
+	f, err := s.frame(args.VariablesReference / dapScopeNum)
+	if err != nil {
+		return err
+	}
+	var variables []interface{}
+	switch args.VariablesReference % dapScopeNum {
+	case dapScopeLocals:
+		variables = dapValues(f.Locals, f.Memory != nil)
+	case dapScopeStack:
+		for i, v := range f.Stack {
+			variables = append(variables, map[string]interface{}{
+				"name": fmt.Sprintf("[%d]", i), "value": fmt.Sprintf("%#x", v), "variablesReference": 0,
+			})
+		}
+	case dapScopeGlobals:
+		variables = dapValues(f.Globals, f.Memory != nil)
+	}
+	if variables == nil {
+		variables = []interface{}{}
+	}
+	s.respond(req, map[string]interface{}{"variables": variables})
+	return nil
+}
+
+// dapValues returns the variables of the locals or the globals named by their index, where the i32 values can be
+// the memory references if hasMemory.
+func dapValues(values []Value, hasMemory bool) []interface{} {
+	ret := make([]interface{}, 0, len(values))
+	for i, v := range values {
+		variable := map[string]interface{}{
+			"name": fmt.Sprintf("$%d", i), "value": v.String(), "type": wasm.ValueTypeName(v.Type),
+			"variablesReference": 0,
+		}
+		if hasMemory && v.Type == api.ValueTypeI32 {
+			variable["memoryReference"] = fmt.Sprintf("%#x", uint32(v.Lo))
+		}
+		ret = append(ret, variable)
+	}
+	return ret
+}
+
+// frame returns the frame of the stopped call with the ID.
+func (s *dapServer) frame(id int) (*Frame, error) {
+	stop := s.d.Stopped()
+	if stop == nil {
+		return nil, errNotStopped
+	}
+	if id < 1 || id > len(stop.Frames) {
+		return nil, fmt.Errorf("invalid frame: %d", id)
+	}
+	return &stop.Frames[id-1], nil
+}
+
+func (s *dapServer) readMemory(req *dapRequest) error {
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int64  `json:"offset"`
+		Count           int64  `json:"count"`
+	}
+	if err := s.unmarshal(req, &args); err != nil {
+		return err
+	}
+	f, err := s.frame(1)
+	if err != nil {
+		return err
+	}
+	if f.Memory == nil {
+		return errors.New("no memory")
+	}
+	ref, err := strconv.ParseUint(args.MemoryReference, 0, 64)
+	if err != nil {
+		return fmt.Errorf("invalid memory reference: %w", err)
+	}
+	addr := int64(ref) + args.Offset
+	size := int64(f.Memory.Size())
+	var data []byte
+	if 0 <= addr && addr < size && args.Count > 0 {
+		n := args.Count
+		if addr+n > size {
+			n = size - addr
+		}
+		data, _ = f.Memory.Read(uint32(addr), uint32(n))
+	}
+	s.respond(req, map[string]interface{}{
+		"address":         fmt.Sprintf("%#x", addr),
+		"data":            base64.StdEncoding.EncodeToString(data),
+		"unreadableBytes": args.Count - int64(len(data)),
+	})
+	return nil
+}
@@ -0,0 +1,154 @@
+package debugging_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental/debugging"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestServeDAP(t *testing.T) {
+	d := debugging.NewDebugger()
+	ctx := debugging.WithDebugger(context.Background(), d)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	mod, err := r.Instantiate(ctx, addWasm)
+	require.NoError(t, err)
+
+	server, conn := net.Pipe()
+	defer conn.Close()
+	var results []uint64
+	served := make(chan error)
+	go func() {
+		served <- debugging.ServeDAP(ctx, server, d, func(ctx context.Context) (err error) {
+			results, err = mod.ExportedFunction("run").Call(ctx, 40)
+			return
+		})
+		server.Close()
+	}()
+	c := &dapClient{t: t, conn: conn, r: textproto.NewReader(bufio.NewReader(conn))}
+
+	body := c.request("initialize", map[string]interface{}{"adapterID": "wazero"})
+	require.Equal(t, true, body["supportsConfigurationDoneRequest"])
+	c.expectEvent("initialized")
+	c.request("launch", map[string]interface{}{})
+	body = c.request("setFunctionBreakpoints", map[string]interface{}{
+		"breakpoints": []interface{}{map[string]interface{}{"name": "add"}},
+	})
+	require.Equal(t, 1, len(body["breakpoints"].([]interface{})))
+	c.request("configurationDone", nil)
+
+	body = c.expectEvent("stopped")
+	require.Equal(t, "breakpoint", body["reason"])
+	body = c.request("threads", nil)
+	require.Equal(t, 1, len(body["threads"].([]interface{})))
+
+	body = c.request("stackTrace", map[string]interface{}{"threadId": 1})
+	var names []string
+	for _, f := range body["stackFrames"].([]interface{}) {
+		names = append(names, f.(map[string]interface{})["name"].(string))
+	}
+	require.Equal(t, []string{"math.add", "math.run"}, names)
+
+	body = c.request("scopes", map[string]interface{}{"frameId": 1})
+	scopes := body["scopes"].([]interface{})
+	require.Equal(t, 3, len(scopes))
+	locals := scopes[0].(map[string]interface{})
+	require.Equal(t, "Locals", locals["name"])
+	body = c.request("variables", map[string]interface{}{"variablesReference": locals["variablesReference"]})
+	var values []string
+	for _, v := range body["variables"].([]interface{}) {
+		v := v.(map[string]interface{})
+		values = append(values, fmt.Sprintf("%s %s=%s", v["type"], v["name"], v["value"]))
+	}
+	require.Equal(t, []string{"i32 $0=40", "i32 $1=2", "f64 $2=0"}, values)
+	first := body["variables"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, "0x28", first["memoryReference"])
+
+	body = c.request("readMemory", map[string]interface{}{"memoryReference": "0x28", "offset": 4, "count": 4})
+	require.Equal(t, "0x2c", body["address"])
+	require.Equal(t, "AAAAAA==", body["data"])
+
+	c.request("next", map[string]interface{}{"threadId": 1, "granularity": "instruction"})
+	body = c.expectEvent("stopped")
+	require.Equal(t, "step", body["reason"])
+	body = c.request("scopes", map[string]interface{}{"frameId": 1})
+	stack := body["scopes"].([]interface{})[1].(map[string]interface{})
+	body = c.request("variables", map[string]interface{}{"variablesReference": stack["variablesReference"]})
+	require.Equal(t, "0x28", body["variables"].([]interface{})[0].(map[string]interface{})["value"])
+
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	body = c.expectEvent("exited")
+	require.Equal(t, float64(0), body["exitCode"])
+	c.expectEvent("terminated")
+
+	// The requests about the frames fail while running.
+	c.send("stackTrace", map[string]interface{}{"threadId": 1})
+	res := c.read()
+	require.Equal(t, false, res["success"])
+	require.Equal(t, "not stopped", res["message"])
+
+	c.request("disconnect", nil)
+	require.NoError(t, <-served)
+	require.Equal(t, []uint64{43}, results)
+}
+
+// dapClient is the client of the Debug Adapter Protocol for the tests.
+type dapClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *textproto.Reader
+	seq  int
+}
+
+// request sends a request and returns the body of the successful response.
+func (c *dapClient) request(command string, args interface{}) map[string]interface{} {
+	c.send(command, args)
+	res := c.read()
+	require.Equal(c.t, "response", res["type"])
+	require.Equal(c.t, command, res["command"])
+	require.Equal(c.t, true, res["success"], res["message"])
+	body, _ := res["body"].(map[string]interface{})
+	return body
+}
+
+// expectEvent reads the event and returns its body.
+func (c *dapClient) expectEvent(event string) map[string]interface{} {
+	msg := c.read()
+	require.Equal(c.t, "event", msg["type"])
+	require.Equal(c.t, event, msg["event"])
+	body, _ := msg["body"].(map[string]interface{})
+	return body
+}
+
+func (c *dapClient) send(command string, args interface{}) {
+	c.seq++
+	content, err := json.Marshal(map[string]interface{}{
+		"seq": c.seq, "type": "request", "command": command, "arguments": args,
+	})
+	require.NoError(c.t, err)
+	_, err = fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	require.NoError(c.t, err)
+}
+
+func (c *dapClient) read() map[string]interface{} {
+	header, err := c.r.ReadMIMEHeader()
+	require.NoError(c.t, err)
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	require.NoError(c.t, err)
+	content := make([]byte, n)
+	_, err = io.ReadFull(c.r.R, content)
+	require.NoError(c.t, err)
+	var msg map[string]interface{}
+	require.NoError(c.t, json.Unmarshal(content, &msg))
+	return msg
+}
@@ -0,0 +1,589 @@
+// Package debugging includes a source-level debugger of the guest code, which can also be served over the Debug
+// Adapter Protocol (DAP) by ServeDAP or the GDB remote serial protocol by ServeGDB.
+//
+// Debugger stops the calls at the breakpoints set by the function names or the source lines in the DWARF sections,
+// and steps them over the source lines or the Wasm instructions. While a call is stopped, its Stop has the frames with
+// their locals, operand stack, globals and memory. The calls can also be stopped at the traps by StopOnTrap.
+//
+// Here's an example of debugging a call:
+//
+//	d := debugging.NewDebugger()
+//	ctx = debugging.WithDebugger(ctx, d)
+//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+//	mod, _ := r.Instantiate(ctx, wasm) // Compile with the same ctx.
+//
+//	d.SetLineBreakpoint("main.c", 5)
+//	go func() { _, _ = mod.ExportedFunction("run").Call(ctx) }()
+//	stop := <-d.Stops() // Stopped at main.c:5.
+//	d.StepOver(debugging.GranularityLine)
+//	stop = <-d.Stops() // Stopped at the next line.
+//	d.Continue()
+//
+// # Notes
+//
+//   - This is an experimental API and subject to change.
+//   - The debugger is only supported by the interpreter. The compiler ignores it.
+//   - While a call is stopped, the other calls made with the same Debugger are stopped before their next instruction.
+//     The steps apply to whichever call reaches the next instruction first, so they are only meaningful for a single
+//     call at a time.
+//   - The frames don't include the guest frames below a host function calling back into the guest.
+package debugging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tetratelabs/wazero/api"
+	internaldebugging "github.com/tetratelabs/wazero/internal/debugging"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// WithDebugger returns a context which enables the debugger for the modules compiled and the functions called with
+// it. The same debugger can be used by multiple modules and concurrent calls.
+//
+// Note: The modules must be compiled with the returned context, as the debugger requires the hooks before each
+// instruction in the compiled code. This changes the compiled code, so the modules are cached separately from those
+// without the debugger.
+func WithDebugger(ctx context.Context, d *Debugger) context.Context {
+	if d == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, expctxkeys.DebuggerKey{}, (*hook)(d))
+}
+
+// Reason is the reason why a call is stopped.
+type Reason uint8
+
+const (
+	// ReasonEntry is when the call is stopped at its first instruction as requested by StopOnEntry.
+	ReasonEntry Reason = iota + 1
+	// ReasonBreakpoint is when the call reaches a breakpoint.
+	ReasonBreakpoint
+	// ReasonStep is when the call completes a step requested by StepIn, StepOver or StepOut.
+	ReasonStep
+	// ReasonPause is when the call is stopped as requested by Pause.
+	ReasonPause
+	// ReasonTrap is when the call traps, which is stopped before its frames are unwound as requested by StopOnTrap.
+	ReasonTrap
+)
+
+// String implements fmt.Stringer, which returns the reason in the Debug Adapter Protocol.
+func (r Reason) String() string {
+	switch r {
+	case ReasonEntry:
+		return "entry"
+	case ReasonBreakpoint:
+		return "breakpoint"
+	case ReasonStep:
+		return "step"
+	case ReasonPause:
+		return "pause"
+	case ReasonTrap:
+		return "exception"
+	}
+	return fmt.Sprintf("Reason(%d)", r)
+}
+
+// Granularity is the unit of a step.
+type Granularity uint8
+
+const (
+	// GranularityLine steps to another source line in the DWARF sections, or to another instruction if the module
+	// doesn't have them.
+	GranularityLine Granularity = iota
+	// GranularityInstruction steps to another Wasm instruction.
+	GranularityInstruction
+)
+
+// Stop is a call stopped before an instruction, which is valid until the call is resumed.
+type Stop struct {
+	// Reason is why the call is stopped.
+	Reason Reason
+	// Breakpoints are the IDs of the breakpoints at the instruction if Reason is ReasonBreakpoint.
+	Breakpoints []int
+	// Err is the error of the trap if Reason is ReasonTrap.
+	Err error
+	// Frames are the frames of the call, starting from the innermost one which is about to execute the instruction,
+	// or which trapped at it.
+	Frames []Frame
+}
+
+// Frame is a frame of a stopped call.
+type Frame struct {
+	// Function is the definition of the function of the frame.
+	Function api.FunctionDefinition
+	// SourceOffset is the offset of the current instruction of the frame in the code section of the Wasm binary,
+	// which is the call instruction except for the innermost frame.
+	SourceOffset uint64
+	// File, Line and Column are the source code location of the current instruction in the DWARF sections, which are
+	// zero if not available.
+	File         string
+	Line, Column int64
+	// Locals are the values of the params and the locals of Function.
+	Locals []Value
+	// Stack is the operand stack of the frame from the bottom. The values are untyped, and a v128 value takes two
+	// elements for its lower and higher 64 bits.
+	Stack []uint64
+	// Globals are the values of the globals of the module defining Function, including the imported ones.
+	Globals []Value
+	// Memory is the memory of the module defining Function, or nil if it has none.
+	Memory api.Memory
+
+	// dwarfLines is the DWARF line information of the module defining Function, or nil if not available.
+	dwarfLines *wasmdebug.DWARFLines
+	// source is the module defining Function, or nil for the host functions.
+	source *wasm.Module
+}
+
+// Value is the value of a local or a global.
+type Value struct {
+	// Type is the type of the value, which can also be one of the reference types not in api.ValueType.
+	Type api.ValueType
+	// Lo is the value encoded in the same way as api.Function params, or the lower 64 bits of a v128 value.
+	Lo uint64
+	// Hi is the higher 64 bits of a v128 value.
+	Hi uint64
+}
+
+// String implements fmt.Stringer.
+func (v Value) String() string {
+	switch v.Type {
+	case api.ValueTypeI32:
+		return fmt.Sprint(int32(v.Lo))
+	case api.ValueTypeI64:
+		return fmt.Sprint(int64(v.Lo))
+	case api.ValueTypeF32:
+		return fmt.Sprint(api.DecodeF32(v.Lo))
+	case api.ValueTypeF64:
+		return fmt.Sprint(api.DecodeF64(v.Lo))
+	case wasm.ValueTypeV128:
+		return fmt.Sprintf("%#016x%016x", v.Hi, v.Lo)
+	default:
+		return fmt.Sprintf("%#x", v.Lo)
+	}
+}
+
+// Debugger stops and steps the calls made with a context returned by WithDebugger. This is created by NewDebugger.
+type Debugger struct {
+	mux sync.Mutex
+	// resumed is broadcast when the stopped call is resumed.
+	resumed *sync.Cond
+	// stops is where the stops are sent.
+	stops chan *Stop
+	// detached is closed by Detach.
+	detached chan struct{}
+
+	breakpoints      []breakpoint
+	nextBreakpointID int
+	// lineTables caches the line tables of the modules with the DWARF sections.
+	lineTables map[*wasmdebug.DWARFLines]*lineTable
+
+	// stopOnTrap is set by StopOnTrap.
+	stopOnTrap bool
+	// step is the step requested when the call is resumed.
+	step step
+	// stopped is non-nil while a call is stopped.
+	stopped *Stop
+}
+
+// breakpoint is a breakpoint at the entry of the function, the source line, or the instruction of the module.
+type breakpoint struct {
+	id       int
+	function string
+	file     string
+	line     int64
+	module   *wasm.Module
+	offset   uint64
+}
+
+type stepKind uint8
+
+const (
+	stepNone stepKind = iota
+	stepEntry
+	stepPause
+	stepIn
+	stepOver
+	stepOut
+)
+
+// step is the condition to stop the next call other than the breakpoints.
+type step struct {
+	kind        stepKind
+	instruction bool
+	// depth, file and line are where the step starts.
+	depth int
+	file  string
+	line  int64
+}
+
+// NewDebugger returns a Debugger which stops nothing until breakpoints or steps are set.
+func NewDebugger() *Debugger {
+	d := &Debugger{
+		stops:      make(chan *Stop),
+		detached:   make(chan struct{}),
+		lineTables: map[*wasmdebug.DWARFLines]*lineTable{},
+	}
+	d.resumed = sync.NewCond(&d.mux)
+	return d
+}
+
+// Stops returns the channel which receives the calls as they are stopped. A stopped call blocks until it is received
+// and then resumed.
+func (d *Debugger) Stops() <-chan *Stop {
+	return d.stops
+}
+
+// Stopped returns the call stopped currently, or nil if none.
+func (d *Debugger) Stopped() *Stop {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return d.stopped
+}
+
+// SetFunctionBreakpoint sets a breakpoint at the entry of the functions with the given name, which is either the
+// name in the name section, the DebugName of api.FunctionDefinition, or an export name. This returns the ID of the
+// breakpoint.
+func (d *Debugger) SetFunctionBreakpoint(name string) int {
+	return d.setBreakpoint(breakpoint{function: name})
+}
+
+// SetLineBreakpoint sets a breakpoint at the beginning of the statements at the source line, according to the DWARF
+// sections of the modules. file matches the file names in them which are the same or end with "/" + file, and vice
+// versa. This returns the ID of the breakpoint.
+func (d *Debugger) SetLineBreakpoint(file string, line int64) int {
+	return d.setBreakpoint(breakpoint{file: file, line: line})
+}
+
+// setInstructionBreakpoint sets a breakpoint at the instruction of the module at the offset in the code section, and
+// returns the ID of the breakpoint.
+func (d *Debugger) setInstructionBreakpoint(module *wasm.Module, offset uint64) int {
+	return d.setBreakpoint(breakpoint{module: module, offset: offset})
+}
+
+func (d *Debugger) setBreakpoint(bp breakpoint) int {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.nextBreakpointID++
+	bp.id = d.nextBreakpointID
+	d.breakpoints = append(d.breakpoints, bp)
+	d.invalidateLineBreakpoints()
+	return bp.id
+}
+
+// ClearBreakpoint clears the breakpoint of the given ID, or does nothing if not found.
+func (d *Debugger) ClearBreakpoint(id int) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	for i := range d.breakpoints {
+		if d.breakpoints[i].id == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			d.invalidateLineBreakpoints()
+			return
+		}
+	}
+}
+
+// invalidateLineBreakpoints clears the source offsets of the line breakpoints, which are resolved again as needed.
+func (d *Debugger) invalidateLineBreakpoints() {
+	for _, t := range d.lineTables {
+		t.breakpoints = nil
+	}
+}
+
+// StopOnTrap sets whether to stop the calls with ReasonTrap when they trap, before their frames are unwound. The exits
+// with sys.ExitError are not stopped. Resuming the stopped call in any way continues the unwinding.
+func (d *Debugger) StopOnTrap(enabled bool) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.stopOnTrap = enabled
+}
+
+// StopOnEntry stops the next call at the next instruction with ReasonEntry, which is the first instruction of the
+// call if no call is ongoing.
+func (d *Debugger) StopOnEntry() {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.stopped == nil {
+		d.step = step{kind: stepEntry}
+	}
+}
+
+// Pause stops the ongoing call, or the next call, at the next instruction with ReasonPause. This does nothing if a
+// call is already stopped.
+func (d *Debugger) Pause() {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.stopped == nil {
+		d.step = step{kind: stepPause}
+	}
+}
+
+// Continue resumes the stopped call until it reaches a breakpoint. This does nothing if no call is stopped.
+func (d *Debugger) Continue() {
+	d.resume(stepNone, false)
+}
+
+// StepIn resumes the stopped call until it reaches another line or instruction, including in the functions it calls.
+// This does nothing if no call is stopped.
+func (d *Debugger) StepIn(g Granularity) {
+	d.resume(stepIn, g == GranularityInstruction)
+}
+
+// StepOver is like StepIn, but doesn't stop in the functions called by the current one.
+func (d *Debugger) StepOver(g Granularity) {
+	d.resume(stepOver, g == GranularityInstruction)
+}
+
+// StepOut resumes the stopped call until the current function returns. This does nothing if no call is stopped.
+func (d *Debugger) StepOut() {
+	d.resume(stepOut, false)
+}
+
+func (d *Debugger) resume(kind stepKind, instruction bool) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	stop := d.stopped
+	if stop == nil {
+		return
+	}
+	d.step = step{}
+	if stop.Reason != ReasonTrap {
+		// The trapped call only unwinds the frames, so there's nothing to step.
+		top := &stop.Frames[0]
+		d.step = step{kind: kind, instruction: instruction, depth: len(stop.Frames)}
+		if top.dwarfLines != nil {
+			d.step.file, d.step.line = d.lineTable(top.dwarfLines).find(top.SourceOffset)
+		}
+	}
+	d.stopped = nil
+	d.resumed.Broadcast()
+}
+
+// Detach resumes the stopped call, if any, and stops debugging. The calls are never stopped again after this.
+func (d *Debugger) Detach() {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	select {
+	case <-d.detached:
+		return
+	default:
+	}
+	close(d.detached)
+	d.breakpoints, d.stopOnTrap, d.step, d.stopped = nil, false, step{}, nil
+	d.resumed.Broadcast()
+}
+
+// hook implements internaldebugging.Debugger for Debugger, whose Step has a different meaning.
+type hook Debugger
+
+var _ internaldebugging.Debugger = (*hook)(nil)
+
+// Step implements internaldebugging.Debugger.
+func (h *hook) Step(_ context.Context, s internaldebugging.Stack) {
+	d := (*Debugger)(h)
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	for d.stopped != nil {
+		d.resumed.Wait()
+	}
+	if len(d.breakpoints) == 0 && d.step.kind == stepNone {
+		return
+	}
+
+	top := s.Frame(0)
+	reason, ids := d.check(s.Depth(), &top)
+	if reason == 0 {
+		return
+	}
+	d.stop(s, &Stop{Reason: reason, Breakpoints: ids})
+}
+
+// Trap implements internaldebugging.Debugger.
+func (h *hook) Trap(_ context.Context, s internaldebugging.Stack, err error) {
+	d := (*Debugger)(h)
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	for d.stopped != nil {
+		d.resumed.Wait()
+	}
+	if exitErr := (*sys.ExitError)(nil); !d.stopOnTrap || errors.As(err, &exitErr) {
+		return
+	}
+	d.stop(s, &Stop{Reason: ReasonTrap, Err: err})
+}
+
+// stop stops the call of the stack, and waits until it is resumed. This must be called with the lock.
+func (d *Debugger) stop(s internaldebugging.Stack, stop *Stop) {
+	stop.Frames = make([]Frame, s.Depth())
+	for i := range stop.Frames {
+		stop.Frames[i] = newFrame(s.Frame(i))
+	}
+	d.stopped, d.step = stop, step{}
+
+	// Send the stop without the lock, so that the receiver can call the other methods meanwhile.
+	d.mux.Unlock()
+	select {
+	case d.stops <- stop:
+	case <-d.detached:
+	}
+	d.mux.Lock()
+	for d.stopped == stop {
+		d.resumed.Wait()
+	}
+}
+
+// check returns the reason to stop the call before the instruction of top, or zero if it shouldn't be stopped.
+func (d *Debugger) check(depth int, top *internaldebugging.Frame) (Reason, []int) {
+	var table *lineTable
+	if top.DWARFLines != nil {
+		table = d.lineTable(top.DWARFLines)
+	}
+
+	var ids []int
+	for i := range d.breakpoints {
+		bp := &d.breakpoints[i]
+		if bp.function != "" && top.SourceOffset == top.BodyOffset && functionHasName(top.Function, bp.function) {
+			ids = append(ids, bp.id)
+		} else if bp.module != nil && top.SourceOffset == bp.offset && top.Module.Source == bp.module {
+			ids = append(ids, bp.id)
+		}
+	}
+	if table != nil {
+		ids = append(ids, table.breakpointsAt(top.SourceOffset, d.breakpoints)...)
+	}
+	if len(ids) > 0 {
+		return ReasonBreakpoint, ids
+	}
+
+	st := &d.step
+	switch st.kind {
+	case stepEntry:
+		return ReasonEntry, nil
+	case stepPause:
+		return ReasonPause, nil
+	case stepIn, stepOver, stepOut:
+		switch {
+		case depth < st.depth:
+			// The function of the step has returned.
+			return ReasonStep, nil
+		case st.kind == stepOut, st.kind == stepOver && depth > st.depth:
+		case st.instruction || st.line == 0:
+			return ReasonStep, nil
+		case table == nil:
+			// Stepped into a module without the DWARF sections.
+			return ReasonStep, nil
+		default:
+			// Stop at another line, skipping the instructions without lines such as the ones generated by compilers.
+			file, line := table.find(top.SourceOffset)
+			if line != 0 && (depth != st.depth || line != st.line || file != st.file) {
+				return ReasonStep, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// functionHasName returns true if name is one of the names of the function.
+func functionHasName(def api.FunctionDefinition, name string) bool {
+	if def.Name() == name || def.DebugName() == name {
+		return true
+	}
+	for _, export := range def.ExportNames() {
+		if export == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newFrame returns a copy of the frame of the stopped call.
+func newFrame(f internaldebugging.Frame) Frame {
+	ret := Frame{Function: f.Function, SourceOffset: f.SourceOffset, dwarfLines: f.DWARFLines}
+	if locations := f.DWARFLines.Locations(f.SourceOffset); len(locations) > 0 {
+		ret.File, ret.Line, ret.Column = locations[0].File, locations[0].Line, locations[0].Column
+	}
+	ret.Locals = make([]Value, len(f.LocalTypes))
+	for i, slot := 0, 0; i < len(f.LocalTypes); i++ {
+		v := &ret.Locals[i]
+		v.Type, v.Lo = f.LocalTypes[i], f.Locals[slot]
+		if slot++; v.Type == wasm.ValueTypeV128 {
+			v.Hi = f.Locals[slot]
+			slot++
+		}
+	}
+	ret.Stack = append([]uint64{}, f.Stack...)
+	if m := f.Module; m != nil {
+		if !m.Source.IsHostModule {
+			ret.source = m.Source
+		}
+		ret.Globals = make([]Value, len(m.Globals))
+		for i, g := range m.Globals {
+			lo, hi := g.Value()
+			ret.Globals[i] = Value{Type: g.Type.ValType, Lo: lo, Hi: hi}
+		}
+		if m.MemoryInstance != nil {
+			ret.Memory = m.MemoryInstance
+		}
+	}
+	return ret
+}
+
+// lineTable returns the line table of the module with the DWARF sections.
+func (d *Debugger) lineTable(lines *wasmdebug.DWARFLines) *lineTable {
+	t, ok := d.lineTables[lines]
+	if !ok {
+		t = &lineTable{rows: lines.Rows()}
+		d.lineTables[lines] = t
+	}
+	return t
+}
+
+// lineTable is the line table of a module, which also has the source offsets of the line breakpoints.
+type lineTable struct {
+	rows []wasmdebug.Row
+	// breakpoints maps the source offsets to the IDs of the line breakpoints there, which is nil until resolved.
+	breakpoints map[uint64][]int
+}
+
+// find returns the source line of the instruction at the offset, or zero if not available.
+func (t *lineTable) find(offset uint64) (file string, line int64) {
+	i := sort.Search(len(t.rows), func(i int) bool { return t.rows[i].Address > offset }) - 1
+	if i < 0 || t.rows[i].EndSequence {
+		return
+	}
+	return t.rows[i].File, t.rows[i].Line
+}
+
+// breakpointsAt returns the IDs of the line breakpoints at the offset, resolving all of them first if needed.
+func (t *lineTable) breakpointsAt(offset uint64, breakpoints []breakpoint) []int {
+	if t.breakpoints == nil {
+		t.breakpoints = map[uint64][]int{}
+		for i := range breakpoints {
+			bp := &breakpoints[i]
+			if bp.file == "" {
+				continue
+			}
+			for _, row := range t.rows {
+				if row.IsStmt && !row.EndSequence && row.Line == bp.line && sameFile(row.File, bp.file) {
+					if ids := t.breakpoints[row.Address]; len(ids) == 0 || ids[len(ids)-1] != bp.id {
+						t.breakpoints[row.Address] = append(ids, bp.id)
+					}
+				}
+			}
+		}
+	}
+	return t.breakpoints[offset]
+}
+
+// sameFile returns true if the file names are the same, or either one is a path ending with the other.
+func sameFile(a, b string) bool {
+	return a == b || strings.HasSuffix(a, "/"+b) || strings.HasSuffix(b, "/"+a)
+}
@@ -0,0 +1,231 @@
+package debugging_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/debugging"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/dwarftestdata"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// addWasm exports "run", which calls "add" with its param and 2 and then adds 1 to the result.
+var addWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{wasm.ValueTypeI32}, Results: []wasm.ValueType{wasm.ValueTypeI32}},
+		{Params: []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI32}, Results: []wasm.ValueType{wasm.ValueTypeI32}},
+	},
+	FunctionSection: []wasm.Index{0, 1},
+	GlobalSection: []wasm.Global{{
+		Type: wasm.GlobalType{ValType: wasm.ValueTypeI64, Mutable: true},
+		Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: []byte{42}},
+	}},
+	MemorySection: []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true}},
+	CodeSection: []wasm.Code{
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 2, wasm.OpcodeCall, 1, // offset 0
+			wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, // offset 6
+			wasm.OpcodeEnd, // offset 9
+		}},
+		{LocalTypes: []wasm.ValueType{wasm.ValueTypeF64}, Body: []byte{
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, // offset 0
+			wasm.OpcodeEnd, // offset 5
+		}},
+	},
+	ExportSection: []wasm.Export{{Name: "run", Type: wasm.ExternTypeFunc, Index: 0}},
+	NameSection: &wasm.NameSection{
+		ModuleName:    "math",
+		FunctionNames: wasm.NameMap{{Index: 0, Name: "run"}, {Index: 1, Name: "add"}},
+	},
+})
+
+func TestDebugger_function(t *testing.T) {
+	d := debugging.NewDebugger()
+	ctx := debugging.WithDebugger(context.Background(), d)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	mod, err := r.Instantiate(ctx, addWasm)
+	require.NoError(t, err)
+
+	results := call(ctx, mod.ExportedFunction("run"), 40)
+	id := d.SetFunctionBreakpoint("add")
+
+	// Stops at the entry of add, where the locals are initialized and its params are not on the operand stack.
+	stop := <-d.Stops()
+	require.Equal(t, debugging.ReasonBreakpoint, stop.Reason)
+	require.Equal(t, []int{id}, stop.Breakpoints)
+	require.Equal(t, 2, len(stop.Frames))
+	add, run := &stop.Frames[0], &stop.Frames[1]
+	require.Equal(t, "math.add", add.Function.DebugName())
+	require.Equal(t, []debugging.Value{
+		{Type: api.ValueTypeI32, Lo: 40}, {Type: api.ValueTypeI32, Lo: 2}, {Type: api.ValueTypeF64},
+	}, add.Locals)
+	require.Equal(t, 0, len(add.Stack))
+	require.Equal(t, "math.run", run.Function.DebugName())
+	require.Equal(t, []debugging.Value{{Type: api.ValueTypeI32, Lo: 40}}, run.Locals)
+	require.Equal(t, 0, len(run.Stack))
+	require.Equal(t, []debugging.Value{{Type: api.ValueTypeI64, Lo: 42}}, add.Globals)
+	require.Equal(t, "42", add.Globals[0].String())
+	require.Equal(t, uint32(wasm.MemoryPageSize), add.Memory.Size())
+	// The caller is at the call instruction, which is before add in the code section.
+	require.True(t, run.SourceOffset < add.SourceOffset)
+
+	// Each step executes one instruction.
+	d.StepIn(debugging.GranularityInstruction)
+	stop = <-d.Stops()
+	require.Equal(t, debugging.ReasonStep, stop.Reason)
+	require.Equal(t, add.SourceOffset+2, stop.Frames[0].SourceOffset)
+	require.Equal(t, []uint64{40}, stop.Frames[0].Stack)
+	d.StepOver(debugging.GranularityInstruction)
+	stop = <-d.Stops()
+	require.Equal(t, []uint64{40, 2}, stop.Frames[0].Stack)
+
+	// Steps out to the instruction after the call, where the result is on the operand stack.
+	d.StepOut()
+	stop = <-d.Stops()
+	require.Equal(t, debugging.ReasonStep, stop.Reason)
+	require.Equal(t, 1, len(stop.Frames))
+	require.Equal(t, run.SourceOffset+2, stop.Frames[0].SourceOffset)
+	require.Equal(t, []uint64{42}, stop.Frames[0].Stack)
+
+	d.Continue()
+	require.Equal(t, []uint64{43}, <-results)
+
+	// The breakpoint stops the next call again until cleared.
+	results = call(ctx, mod.ExportedFunction("run"), 1)
+	stop = <-d.Stops()
+	require.Equal(t, "math.add", stop.Frames[0].Function.DebugName())
+	d.ClearBreakpoint(id)
+	d.Continue()
+	require.Equal(t, []uint64{4}, <-results)
+	results = call(ctx, mod.ExportedFunction("run"), 2)
+	require.Equal(t, []uint64{5}, <-results)
+}
+
+func TestDebugger_StopOnEntry(t *testing.T) {
+	d := debugging.NewDebugger()
+	ctx := debugging.WithDebugger(context.Background(), d)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	mod, err := r.Instantiate(ctx, addWasm)
+	require.NoError(t, err)
+
+	d.StopOnEntry()
+	results := call(ctx, mod.ExportedFunction("run"), 1)
+	stop := <-d.Stops()
+	require.Equal(t, debugging.ReasonEntry, stop.Reason)
+	require.Equal(t, "math.run", stop.Frames[0].Function.DebugName())
+
+	// Steps into the call.
+	for i := 0; i < 3; i++ {
+		d.StepIn(debugging.GranularityLine)
+		stop = <-d.Stops()
+	}
+	require.Equal(t, "math.add", stop.Frames[0].Function.DebugName())
+
+	// Stepping over the last instruction returns to the caller.
+	for i := 0; i < 4; i++ {
+		d.StepOver(debugging.GranularityLine)
+		stop = <-d.Stops()
+	}
+	require.Equal(t, "math.run", stop.Frames[0].Function.DebugName())
+
+	d.Detach()
+	require.Equal(t, []uint64{4}, <-results)
+	require.Nil(t, d.Stopped())
+}
+
+func TestDebugger_line(t *testing.T) {
+	d := debugging.NewDebugger()
+	ctx := debugging.WithDebugger(context.Background(), d)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	compiled, err := r.CompileModule(ctx, dwarftestdata.ZigCCWasm)
+	require.NoError(t, err)
+
+	id := d.SetLineBreakpoint("zig-cc/main.c", 5)
+	done := make(chan error)
+	go func() {
+		_, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+		done <- err
+	}()
+
+	stop := <-d.Stops()
+	require.Equal(t, debugging.ReasonBreakpoint, stop.Reason)
+	require.Equal(t, []int{id}, stop.Breakpoints)
+	require.True(t, strings.HasSuffix(stop.Frames[0].File, "zig-cc/main.c"), stop.Frames[0].File)
+	require.Equal(t, int64(5), stop.Frames[0].Line)
+	require.Equal(t, "a", stop.Frames[0].Function.Name())
+	require.Equal(t, int64(11), stop.Frames[1].Line)
+	require.Equal(t, d.Stopped(), stop)
+
+	// Steps over the lines.
+	for _, line := range []int64{6, 7} {
+		d.StepOver(debugging.GranularityLine)
+		stop = <-d.Stops()
+		require.Equal(t, debugging.ReasonStep, stop.Reason)
+		require.Equal(t, int64(line), stop.Frames[0].Line)
+	}
+
+	// The line 7 traps at the bounds check.
+	d.Continue()
+	err = <-done
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "main.c:7:18")
+}
+
+func TestDebugger_StopOnTrap(t *testing.T) {
+	d := debugging.NewDebugger()
+	ctx := debugging.WithDebugger(context.Background(), d)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	compiled, err := r.CompileModule(ctx, dwarftestdata.ZigCCWasm)
+	require.NoError(t, err)
+
+	d.StopOnTrap(true)
+	done := make(chan error)
+	go func() {
+		_, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+		done <- err
+	}()
+
+	// Stopped at the trap with the frames before unwound.
+	stop := <-d.Stops()
+	require.Equal(t, debugging.ReasonTrap, stop.Reason)
+	require.Equal(t, "unreachable", stop.Err.Error())
+	require.Equal(t, int64(7), stop.Frames[0].Line)
+	require.Equal(t, "a", stop.Frames[0].Function.Name())
+
+	// The steps are ignored, and the trap ends the call.
+	d.StepIn(debugging.GranularityInstruction)
+	err = <-done
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "main.c:7:18")
+}
+
+func TestReason_String(t *testing.T) {
+	require.Equal(t, "entry", debugging.ReasonEntry.String())
+	require.Equal(t, "breakpoint", debugging.ReasonBreakpoint.String())
+	require.Equal(t, "step", debugging.ReasonStep.String())
+	require.Equal(t, "pause", debugging.ReasonPause.String())
+	require.Equal(t, "exception", debugging.ReasonTrap.String())
+	require.Equal(t, "Reason(0)", debugging.Reason(0).String())
+}
+
+// call calls the function in a goroutine, and returns the channel receiving the results.
+func call(ctx context.Context, f api.Function, params ...uint64) <-chan []uint64 {
+	results := make(chan []uint64, 1)
+	go func() {
+		ret, _ := f.Call(ctx, params...)
+		results <- ret
+	}()
+	return results
+}
@@ -0,0 +1,659 @@
+package debugging
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// ServeGDB serves the GDB remote serial protocol over conn with the debugger until the client detaches or kills the
+// target, or conn is closed, e.g. for "process connect --plugin wasm connect://localhost:1234" of LLDB.
+//
+// run is called in a goroutine right away, and must make the calls to debug like ServeDAP. The first call is stopped
+// at its entry, so that the client connects to the stopped target. The traps stop the calls too, and are reported as
+// the signals, e.g. SIGSEGV for the out of bounds memory accesses. Its context is derived from ctx, and canceled when
+// the client kills the target or disconnects without detaching. This returns the error returned by run.
+//
+// The target is presented as the Wasm target which the WebAssembly plugin of LLDB expects:
+//   - The calls are presented as a single thread, whose only register is the "pc".
+//   - The modules are the libraries, whose IDs are in the order the calls are stopped in them. The code address of a
+//     module is 0x4000000000000000 | id<<32 | the offset in its Wasm binary, where its binary can be read, and the
+//     address of its memory is id<<32 | the offset in the memory.
+//   - The packets "qWasmCallStack", "qWasmLocal", "qWasmGlobal", "qWasmStackValue" and "qWasmMem" read the frames of
+//     the stopped call, which exclude the host functions.
+//
+// See https://sourceware.org/gdb/current/onlinedocs/gdb.html/Remote-Protocol.html and
+// https://lldb.llvm.org/resources/lldbgdbremote.html
+func ServeGDB(ctx context.Context, conn io.ReadWriter, d *Debugger, run func(context.Context) error) error {
+	s := &gdbServer{d: d, r: bufio.NewReader(conn), w: conn, breakpoints: map[uint64]int{}}
+	defer d.Detach()
+	d.StopOnEntry()
+	d.StopOnTrap(true)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- run(runCtx)
+	}()
+	var err error
+	select {
+	case stop := <-d.Stops():
+		s.stopped(stop)
+	case err = <-done:
+		s.exited(err)
+		done = nil
+	}
+
+	packets := make(chan string)
+	go s.readPackets(packets)
+	running, detach := false, false
+loop:
+	for {
+		select {
+		case p, ok := <-packets:
+			if !ok {
+				break loop
+			}
+			if p == gdbInterrupt {
+				if running {
+					d.Pause()
+				}
+				continue
+			} else if p == gdbInvalid {
+				if !s.noAck {
+					s.write("-") // Let the client send it again.
+				}
+				continue
+			}
+			if !s.noAck {
+				s.write("+")
+			}
+			switch reply, action := s.handle(p); action {
+			case gdbReply:
+				s.send(reply)
+			case gdbResume:
+				running = s.exit == ""
+				if !running {
+					s.send(s.exit)
+				}
+			case gdbDetach:
+				s.send(reply)
+				detach = true
+				break loop
+			case gdbKill:
+				break loop
+			}
+		case stop := <-d.Stops():
+			s.stopped(stop)
+			s.send(s.stopReply())
+			running = false
+		case err = <-done:
+			s.exited(err)
+			done = nil
+			if running {
+				s.send(s.exit)
+				running = false
+			}
+		}
+	}
+
+	if !detach {
+		// Let the calls exit.
+		cancel()
+	}
+	d.Detach()
+	if done != nil {
+		err = <-done
+	}
+	return err
+}
+
+// gdbInterrupt is the packet of the interrupt by the client, which is a single byte of Ctrl-C outside the packets.
+const gdbInterrupt = "\x03"
+
+// gdbInvalid is the packet whose checksum is invalid.
+const gdbInvalid = "\x00"
+
+// gdbAction is what the server does after handling a packet.
+type gdbAction uint8
+
+const (
+	// gdbReply sends the reply.
+	gdbReply gdbAction = iota
+	// gdbResume resumes the stopped call, and sends the stop reply once it stops or exits.
+	gdbResume
+	// gdbDetach sends the reply, and then lets the calls run without the debugger.
+	gdbDetach
+	// gdbKill makes the calls exit.
+	gdbKill
+)
+
+// The signals reported to the client, whose numbers are the same in GDB and Linux.
+const (
+	gdbSIGINT  = 2
+	gdbSIGILL  = 4
+	gdbSIGTRAP = 5
+	gdbSIGABRT = 6
+	gdbSIGFPE  = 8
+	gdbSIGSEGV = 11
+)
+
+// gdbTriple is the target triple which makes LLDB use its WebAssembly plugin.
+const gdbTriple = "wasm32-unknown-unknown-wasm"
+
+// gdbCodeSpace is the bits of the code addresses, which are otherwise the addresses of the memories.
+const gdbCodeSpace = uint64(1) << 62
+
+// gdbServer serves the GDB remote serial protocol for ServeGDB.
+type gdbServer struct {
+	d *Debugger
+	r *bufio.Reader
+	w io.Writer
+	// noAck is true once the client starts the no acknowledgment mode.
+	noAck bool
+
+	// stop is the stopped call, or nil while running or after the exit.
+	stop *Stop
+	// frames are the frames of stop excluding the host functions, whose indices are the frame indices of the packets.
+	frames []*Frame
+	// exit is the stop reply of the exit once run returns, or empty until then.
+	exit string
+
+	// modules are the modules the calls have been stopped in, whose indices are their IDs.
+	modules []*gdbModule
+	// breakpoints are the IDs of the breakpoints per code address.
+	breakpoints map[uint64]int
+}
+
+// gdbModule is a module presented as a library.
+type gdbModule struct {
+	source *wasm.Module
+	// codeSectionOffset is the offset of the code section contents in the binary, to which the source offsets are
+	// relative.
+	codeSectionOffset uint64
+	// memory is the memory of the module in the last stop, or nil if it has none.
+	memory api.Memory
+}
+
+// readPackets sends the data of the packets to packets until conn fails, and then closes it. The packets with the
+// invalid checksums are sent as gdbInvalid, and the acknowledgments from the client are ignored.
+func (s *gdbServer) readPackets(packets chan<- string) {
+	defer close(packets)
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case gdbInterrupt[0]:
+			packets <- gdbInterrupt
+		case '$':
+			data, err := s.r.ReadString('#')
+			if err != nil {
+				return
+			}
+			data = data[:len(data)-1]
+			checksum := make([]byte, 2)
+			if _, err = io.ReadFull(s.r, checksum); err != nil {
+				return
+			}
+			if sum, err := strconv.ParseUint(string(checksum), 16, 8); err != nil || uint8(sum) != gdbChecksum(data) {
+				packets <- gdbInvalid
+			} else {
+				packets <- gdbUnescape(data)
+			}
+		}
+	}
+}
+
+// send sends the packet with the data, escaping the special characters.
+func (s *gdbServer) send(data string) {
+	var b strings.Builder
+	b.WriteByte('$')
+	var sum uint8
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c == '$' || c == '#' || c == '}' || c == '*' {
+			b.WriteByte('}')
+			sum += '}'
+			c ^= 0x20
+		}
+		b.WriteByte(c)
+		sum += c
+	}
+	fmt.Fprintf(&b, "#%02x", sum)
+	s.write(b.String())
+}
+
+func (s *gdbServer) write(data string) {
+	// The errors are ignored, as the next read fails if the client is gone.
+	_, _ = io.WriteString(s.w, data)
+}
+
+func gdbChecksum(data string) (sum uint8) {
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return
+}
+
+// gdbUnescape returns the data of a packet without the escapes.
+func gdbUnescape(data string) string {
+	if !strings.Contains(data, "}") {
+		return data
+	}
+	var b strings.Builder
+	for i := 0; i < len(data); i++ {
+		if c := data[i]; c == '}' && i+1 < len(data) {
+			i++
+			b.WriteByte(data[i] ^ 0x20)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// stopped sets the stopped call, adding the modules of its frames.
+func (s *gdbServer) stopped(stop *Stop) {
+	s.stop, s.frames = stop, s.frames[:0]
+	for i := range stop.Frames {
+		f := &stop.Frames[i]
+		if f.source == nil {
+			continue
+		}
+		s.frames = append(s.frames, f)
+		_, m := s.module(f.source)
+		m.memory = f.Memory
+	}
+}
+
+// exited sets the stop reply of the exit with the error of run.
+func (s *gdbServer) exited(err error) {
+	s.stop, s.frames = nil, nil
+	if exitErr := (*sys.ExitError)(nil); errors.As(err, &exitErr) {
+		s.exit = fmt.Sprintf("W%02x", uint8(exitErr.ExitCode()))
+	} else if err != nil {
+		s.exit = fmt.Sprintf("X%02x", gdbSignal(err))
+	} else {
+		s.exit = "W00"
+	}
+}
+
+// module returns the ID and the gdbModule of the source, adding it if not yet.
+func (s *gdbServer) module(source *wasm.Module) (uint64, *gdbModule) {
+	for id, m := range s.modules {
+		if m.source == source {
+			return uint64(id), m
+		}
+	}
+	m := &gdbModule{source: source, codeSectionOffset: codeSectionOffset(source.DebugBinary)}
+	s.modules = append(s.modules, m)
+	return uint64(len(s.modules) - 1), m
+}
+
+// codeSectionOffset returns the offset of the code section contents in the Wasm binary, or zero if not found.
+func codeSectionOffset(bin []byte) uint64 {
+	offset := uint64(8) // Skips the magic number and the version.
+	for offset < uint64(len(bin)) {
+		id := bin[offset]
+		size, n, err := leb128.LoadUint32(bin[offset+1:])
+		if err != nil {
+			break
+		}
+		offset += 1 + n
+		if id == wasm.SectionIDCode {
+			return offset
+		}
+		offset += uint64(size)
+	}
+	return 0
+}
+
+// pc returns the code address of the current instruction of the frame.
+func (s *gdbServer) pc(f *Frame) uint64 {
+	id, m := s.module(f.source)
+	return gdbCodeSpace | id<<32 | (m.codeSectionOffset + f.SourceOffset)
+}
+
+// stopReply returns the reply of the stop, or of the exit if exited.
+func (s *gdbServer) stopReply() string {
+	stop := s.stop
+	if stop == nil {
+		return s.exit
+	}
+	signal, reason := gdbSIGTRAP, "signal"
+	switch stop.Reason {
+	case ReasonBreakpoint:
+		reason = "breakpoint"
+	case ReasonStep:
+		reason = "trace"
+	case ReasonPause:
+		signal = gdbSIGINT
+	case ReasonTrap:
+		signal, reason = gdbSignal(stop.Err), "exception"
+	}
+	reply := fmt.Sprintf("T%02xthread:1;", signal)
+	if len(s.frames) > 0 {
+		pc := s.pc(s.frames[0])
+		reply += fmt.Sprintf("thread-pcs:%x;00:%s;", pc, gdbHex(pc))
+	}
+	reply += "reason:" + reason + ";"
+	if stop.Err != nil {
+		reply += "description:" + hex.EncodeToString([]byte(stop.Err.Error())) + ";"
+	}
+	return reply
+}
+
+// gdbSignal returns the signal of the trap.
+func gdbSignal(err error) int {
+	for _, e := range []error{
+		wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess,
+		wasmruntime.ErrRuntimeInvalidTableAccess,
+		wasmruntime.ErrRuntimeStackOverflow,
+		wasmruntime.ErrRuntimeOutOfBoundsArrayAccess,
+	} {
+		if errors.Is(err, e) {
+			return gdbSIGSEGV
+		}
+	}
+	for _, e := range []error{
+		wasmruntime.ErrRuntimeIntegerDivideByZero,
+		wasmruntime.ErrRuntimeIntegerOverflow,
+		wasmruntime.ErrRuntimeInvalidConversionToInteger,
+	} {
+		if errors.Is(err, e) {
+			return gdbSIGFPE
+		}
+	}
+	for _, e := range []error{
+		wasmruntime.ErrRuntimeUnreachable,
+		wasmruntime.ErrRuntimeIndirectCallTypeMismatch,
+	} {
+		if errors.Is(err, e) {
+			return gdbSIGILL
+		}
+	}
+	return gdbSIGABRT
+}
+
+// gdbHex returns the hex of the uint64 in little endian, which is the byte order of the target.
+func gdbHex(v uint64) string {
+	return hex.EncodeToString(binary.LittleEndian.AppendUint64(nil, v))
+}
+
+// handle handles the packet other than the interrupt, and returns the reply and what to do with it. The empty reply
+// means that the packet isn't supported.
+func (s *gdbServer) handle(p string) (string, gdbAction) {
+	switch {
+	case p == "?":
+		return s.stopReply(), gdbReply
+	case p == "c", strings.HasPrefix(p, "C"), strings.HasPrefix(p, "vCont;c"), strings.HasPrefix(p, "vCont;C"):
+		return s.resume(s.d.Continue)
+	case p == "s", strings.HasPrefix(p, "S"), strings.HasPrefix(p, "vCont;s"), strings.HasPrefix(p, "vCont;S"):
+		return s.resume(func() { s.d.StepIn(GranularityInstruction) })
+	case p == "vCont?":
+		return "vCont;c;C;s;S", gdbReply
+	case p == "D", strings.HasPrefix(p, "D;"):
+		return "OK", gdbDetach
+	case p == "k":
+		return "", gdbKill
+	case strings.HasPrefix(p, "vKill"):
+		return "", gdbKill
+	case p == "QStartNoAckMode":
+		s.noAck = true
+		return "OK", gdbReply
+	case strings.HasPrefix(p, "qSupported"):
+		return "PacketSize=4000;QStartNoAckMode+;qXfer:libraries:read+", gdbReply
+	case p == "qHostInfo":
+		return fmt.Sprintf("triple:%s;ptrsize:4;endian:little;", hex.EncodeToString([]byte(gdbTriple))), gdbReply
+	case p == "qProcessInfo":
+		return fmt.Sprintf("pid:1;parent-pid:1;triple:%s;ptrsize:4;endian:little;",
+			hex.EncodeToString([]byte(gdbTriple))), gdbReply
+	case p == "qfThreadInfo":
+		return "m1", gdbReply
+	case p == "qsThreadInfo":
+		return "l", gdbReply
+	case p == "qC":
+		return "QC1", gdbReply
+	case p == "qAttached":
+		return "1", gdbReply
+	case strings.HasPrefix(p, "H"), strings.HasPrefix(p, "T"):
+		// There's only one thread to select, which is alive.
+		return "OK", gdbReply
+	case strings.HasPrefix(p, "qThreadStopInfo"):
+		return s.stopReply(), gdbReply
+	case strings.HasPrefix(p, "qRegisterInfo"):
+		if p != "qRegisterInfo0" {
+			return "E45", gdbReply
+		}
+		return "name:pc;alt-name:pc;bitsize:64;offset:0;encoding:uint;format:hex;set:General Purpose Registers;" +
+			"gcc:16;dwarf:16;generic:pc;", gdbReply
+	case p == "g", strings.HasPrefix(p, "p0;"), p == "p0":
+		if len(s.frames) == 0 {
+			return "E01", gdbReply
+		}
+		return gdbHex(s.pc(s.frames[0])), gdbReply
+	case strings.HasPrefix(p, "p"):
+		return "E45", gdbReply
+	case strings.HasPrefix(p, "qXfer:libraries:read::"):
+		return s.libraries(strings.TrimPrefix(p, "qXfer:libraries:read::")), gdbReply
+	case strings.HasPrefix(p, "qWasmCallStack"):
+		var b []byte
+		for _, f := range s.frames {
+			b = binary.LittleEndian.AppendUint64(b, s.pc(f))
+		}
+		return hex.EncodeToString(b), gdbReply
+	case strings.HasPrefix(p, "qWasmLocal:"):
+		return s.value(strings.TrimPrefix(p, "qWasmLocal:"), func(f *Frame) []Value { return f.Locals }), gdbReply
+	case strings.HasPrefix(p, "qWasmGlobal:"):
+		return s.value(strings.TrimPrefix(p, "qWasmGlobal:"), func(f *Frame) []Value { return f.Globals }), gdbReply
+	case strings.HasPrefix(p, "qWasmStackValue:"):
+		return s.value(strings.TrimPrefix(p, "qWasmStackValue:"), func(f *Frame) []Value {
+			values := make([]Value, len(f.Stack))
+			for i, v := range f.Stack {
+				values[i] = Value{Type: api.ValueTypeI64, Lo: v}
+			}
+			return values
+		}), gdbReply
+	case strings.HasPrefix(p, "qWasmMem:"):
+		return s.wasmMemory(strings.TrimPrefix(p, "qWasmMem:")), gdbReply
+	case strings.HasPrefix(p, "m"):
+		return s.readMemory(p[1:]), gdbReply
+	case strings.HasPrefix(p, "Z0,"), strings.HasPrefix(p, "Z1,"):
+		return s.setBreakpoint(p[3:]), gdbReply
+	case strings.HasPrefix(p, "z0,"), strings.HasPrefix(p, "z1,"):
+		return s.clearBreakpoint(p[3:]), gdbReply
+	}
+	return "", gdbReply
+}
+
+// resume resumes the stopped call by the function if stopped.
+func (s *gdbServer) resume(resume func()) (string, gdbAction) {
+	if s.stop != nil {
+		s.stop, s.frames = nil, s.frames[:0]
+		resume()
+	}
+	return "", gdbResume
+}
+
+// libraries returns the part of the library list at "offset,length".
+func (s *gdbServer) libraries(args string) string {
+	offset, length, ok := gdbParseRange(args)
+	if !ok {
+		return "E01"
+	}
+	var b strings.Builder
+	b.WriteString("<library-list>")
+	for id, m := range s.modules {
+		name := fmt.Sprintf("module%d.wasm", id)
+		if ns := m.source.NameSection; ns != nil && ns.ModuleName != "" {
+			name = ns.ModuleName
+		}
+		b.WriteString(`<library name="`)
+		_ = xml.EscapeText(&b, []byte(name))
+		fmt.Fprintf(&b, `"><section address="%#x"/></library>`, gdbCodeSpace|uint64(id)<<32)
+	}
+	b.WriteString("</library-list>")
+	list := b.String()
+	if offset >= uint64(len(list)) {
+		return "l"
+	}
+	if end := offset + length; end < uint64(len(list)) {
+		return "m" + list[offset:end]
+	}
+	return "l" + list[offset:]
+}
+
+// value returns the hex of the value at "frame;index" of the values of the frame.
+func (s *gdbServer) value(args string, values func(f *Frame) []Value) string {
+	frame, index, ok := strings.Cut(args, ";")
+	f, err := s.frame(frame)
+	if !ok || err != nil {
+		return "E01"
+	}
+	i, err := strconv.Atoi(index)
+	vs := values(f)
+	if err != nil || i < 0 || i >= len(vs) {
+		return "E02"
+	}
+	v := vs[i]
+	var b []byte
+	switch v.Type {
+	case api.ValueTypeI32, api.ValueTypeF32:
+		b = binary.LittleEndian.AppendUint32(b, uint32(v.Lo))
+	case wasm.ValueTypeV128:
+		b = binary.LittleEndian.AppendUint64(b, v.Lo)
+		b = binary.LittleEndian.AppendUint64(b, v.Hi)
+	default:
+		b = binary.LittleEndian.AppendUint64(b, v.Lo)
+	}
+	return hex.EncodeToString(b)
+}
+
+// frame returns the frame at the decimal index.
+func (s *gdbServer) frame(index string) (*Frame, error) {
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(s.frames) {
+		return nil, errNotStopped
+	}
+	return s.frames[i], nil
+}
+
+// wasmMemory returns the hex of the memory of the frame at "frame;address;length".
+func (s *gdbServer) wasmMemory(args string) string {
+	frame, rest, ok := strings.Cut(args, ";")
+	f, err := s.frame(frame)
+	if !ok || err != nil {
+		return "E01"
+	}
+	addr, length, ok := gdbParseRange(strings.Replace(rest, ";", ",", 1))
+	if !ok {
+		return "E01"
+	}
+	return gdbReadMemory(f.Memory, addr, length)
+}
+
+// readMemory returns the hex of the binary or the memory of a module at "address,length".
+func (s *gdbServer) readMemory(args string) string {
+	addr, length, ok := gdbParseRange(args)
+	if !ok {
+		return "E01"
+	}
+	id, offset := (addr&^gdbCodeSpace)>>32, addr&0xffffffff
+	if id >= uint64(len(s.modules)) {
+		return "E03"
+	}
+	m := s.modules[id]
+	if addr&gdbCodeSpace == 0 {
+		return gdbReadMemory(m.memory, offset, length)
+	}
+	bin := m.source.DebugBinary
+	if offset >= uint64(len(bin)) {
+		return "E03"
+	}
+	if end := offset + length; end < uint64(len(bin)) {
+		bin = bin[:end]
+	}
+	return hex.EncodeToString(bin[offset:])
+}
+
+// gdbReadMemory returns the hex of the memory at the range, which is truncated at the end of the memory.
+func gdbReadMemory(mem api.Memory, offset, length uint64) string {
+	if mem == nil || offset >= uint64(mem.Size()) {
+		return "E03"
+	}
+	if end := offset + length; end > uint64(mem.Size()) {
+		length = uint64(mem.Size()) - offset
+	}
+	b, _ := mem.Read(uint32(offset), uint32(length))
+	return hex.EncodeToString(b)
+}
+
+// setBreakpoint sets the breakpoint at "address,kind".
+func (s *gdbServer) setBreakpoint(args string) string {
+	addr, _, ok := gdbParseRange(args)
+	if !ok {
+		return "E01"
+	}
+	if _, ok = s.breakpoints[addr]; ok {
+		return "OK"
+	}
+	id := (addr &^ gdbCodeSpace) >> 32
+	if addr&gdbCodeSpace == 0 || id >= uint64(len(s.modules)) {
+		return "E03"
+	}
+	m := s.modules[id]
+	offset := addr & 0xffffffff
+	if offset < m.codeSectionOffset {
+		return "E03"
+	}
+	s.breakpoints[addr] = s.d.setInstructionBreakpoint(m.source, offset-m.codeSectionOffset)
+	return "OK"
+}
+
+// clearBreakpoint clears the breakpoint at "address,kind".
+func (s *gdbServer) clearBreakpoint(args string) string {
+	addr, _, ok := gdbParseRange(args)
+	if !ok {
+		return "E01"
+	}
+	if id, ok := s.breakpoints[addr]; ok {
+		s.d.ClearBreakpoint(id)
+		delete(s.breakpoints, addr)
+	}
+	return "OK"
+}
+
+// gdbParseRange parses the hex numbers of "address,length".
+func gdbParseRange(args string) (addr, length uint64, ok bool) {
+	a, l, ok := strings.Cut(args, ",")
+	if !ok {
+		return
+	}
+	var err error
+	if addr, err = strconv.ParseUint(a, 16, 64); err != nil {
+		return 0, 0, false
+	}
+	if length, err = strconv.ParseUint(l, 16, 64); err != nil {
+		return 0, 0, false
+	}
+	return addr, length, true
+}
@@ -0,0 +1,184 @@
+package debugging_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental/debugging"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestServeGDB(t *testing.T) {
+	d := debugging.NewDebugger()
+	ctx := debugging.WithDebugger(context.Background(), d)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	mod, err := r.Instantiate(ctx, addWasm)
+	require.NoError(t, err)
+
+	server, conn := net.Pipe()
+	defer conn.Close()
+	var results []uint64
+	served := make(chan error)
+	go func() {
+		served <- debugging.ServeGDB(ctx, server, d, func(ctx context.Context) (err error) {
+			// The breakpoint set in the first call is hit by the second one.
+			for _, param := range []uint64{40, 1} {
+				if results, err = mod.ExportedFunction("run").Call(ctx, param); err != nil {
+					return
+				}
+			}
+			return
+		})
+		server.Close()
+	}()
+	c := &gdbClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	require.Equal(t, "OK", c.request("QStartNoAckMode"))
+	require.Contains(t, c.request("qSupported:xmlRegisters=i386"), "qXfer:libraries:read+")
+	require.Equal(t, "", c.request("qUnknown"))
+	require.Equal(t, "m1", c.request("qfThreadInfo"))
+	require.True(t, strings.HasPrefix(c.request("qRegisterInfo0"), "name:pc;"))
+	require.Equal(t, "E45", c.request("qRegisterInfo1"))
+	require.Equal(t, `l<library-list><library name="math"><section address="0x4000000000000000"/></library></library-list>`,
+		c.request("qXfer:libraries:read::0,1000"))
+	require.Equal(t, "m<libr", c.request("qXfer:libraries:read::0,5"))
+	require.Equal(t, "0061736d01000000", c.request("m4000000000000000,8"))
+
+	// Stopped at the entry of run.
+	reply := c.request("?")
+	require.True(t, strings.HasPrefix(reply, "T05thread:1;thread-pcs:40000000000000"), reply)
+	runPC := c.callStack()[0]
+	require.Equal(t, uint8(wasm.OpcodeLocalGet), c.readCode(runPC))
+
+	// Steps into add.
+	for i := 0; i < 3; i++ {
+		require.Contains(t, c.request("s"), "reason:trace;")
+	}
+	pcs := c.callStack()
+	require.Equal(t, 2, len(pcs))
+	addPC := pcs[0]
+	require.Equal(t, runPC+4, pcs[1]) // The call instruction.
+	require.Equal(t, hex.EncodeToString(binary.LittleEndian.AppendUint64(nil, addPC)), c.request("p0"))
+	require.Equal(t, "28000000", c.request("qWasmLocal:0;0"))
+	require.Equal(t, "02000000", c.request("qWasmLocal:0;1"))
+	require.Equal(t, "0000000000000000", c.request("qWasmLocal:0;2"))
+	require.Equal(t, "E02", c.request("qWasmLocal:0;3"))
+	require.Equal(t, "28000000", c.request("qWasmLocal:1;0"))
+	require.Equal(t, "E01", c.request("qWasmLocal:2;0"))
+	require.Equal(t, "2a00000000000000", c.request("qWasmGlobal:0;0"))
+	require.Equal(t, "E02", c.request("qWasmStackValue:0;0"))
+	require.Equal(t, "0000", c.request("qWasmMem:0;fffe;8")) // Truncated at the end of the memory.
+	require.Equal(t, "0000", c.request("m0,2"))
+
+	// The second call stops at the breakpoint.
+	require.Equal(t, "OK", c.request(fmt.Sprintf("Z0,%x,1", addPC)))
+	require.Contains(t, c.request("c"), fmt.Sprintf("thread-pcs:%x;", addPC))
+	require.Equal(t, "01000000", c.request("qWasmLocal:0;0"))
+	require.Equal(t, "OK", c.request(fmt.Sprintf("z0,%x,1", addPC)))
+	require.Equal(t, "W00", c.request("vCont;c"))
+	require.Equal(t, "W00", c.request("?"))
+
+	require.Equal(t, "OK", c.request("D"))
+	require.NoError(t, <-served)
+	require.Equal(t, []uint64{4}, results)
+}
+
+func TestServeGDB_trap(t *testing.T) {
+	d := debugging.NewDebugger()
+	ctx := debugging.WithDebugger(context.Background(), d)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	mod, err := r.Instantiate(ctx, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{{
+			Params: []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI32}, Results: []wasm.ValueType{wasm.ValueTypeI32},
+		}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32DivS, wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{{Name: "div", Type: wasm.ExternTypeFunc, Index: 0}},
+	}))
+	require.NoError(t, err)
+
+	server, conn := net.Pipe()
+	defer conn.Close()
+	served := make(chan error)
+	go func() {
+		served <- debugging.ServeGDB(ctx, server, d, func(ctx context.Context) error {
+			_, err := mod.ExportedFunction("div").Call(ctx, 1, 0)
+			return err
+		})
+		server.Close()
+	}()
+	c := &gdbClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	// The trap stops the call at the division as SIGFPE, and then ends it.
+	reply := c.request("c")
+	require.True(t, strings.HasPrefix(reply, "T08thread:1;"), reply)
+	require.Contains(t, reply, "reason:exception;description:"+hex.EncodeToString([]byte("integer divide by zero"))+";")
+	require.Equal(t, "00000000", c.request("qWasmLocal:0;1"))
+	require.Equal(t, "X08", c.request("c"))
+
+	// Closing the connection ends serving.
+	require.NoError(t, conn.Close())
+	err = <-served
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "integer divide by zero")
+}
+
+// gdbClient is the client of the GDB remote serial protocol for the tests, which doesn't acknowledge the packets.
+type gdbClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// request sends the packet and returns the data of the reply.
+func (c *gdbClient) request(data string) string {
+	var sum uint8
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	_, err := fmt.Fprintf(c.conn, "$%s#%02x", data, sum)
+	require.NoError(c.t, err)
+
+	// Skips the acknowledgments.
+	b, err := c.r.ReadByte()
+	for ; err == nil && b == '+'; b, err = c.r.ReadByte() {
+	}
+	require.NoError(c.t, err)
+	require.Equal(c.t, byte('$'), b)
+	reply, err := c.r.ReadString('#')
+	require.NoError(c.t, err)
+	_, err = c.r.Discard(2)
+	require.NoError(c.t, err)
+	return reply[:len(reply)-1]
+}
+
+// callStack returns the code addresses of the frames.
+func (c *gdbClient) callStack() (pcs []uint64) {
+	b, err := hex.DecodeString(c.request("qWasmCallStack:1"))
+	require.NoError(c.t, err)
+	for i := 0; i < len(b); i += 8 {
+		pcs = append(pcs, binary.LittleEndian.Uint64(b[i:]))
+	}
+	return
+}
+
+// readCode reads the byte of the binary at the code address.
+func (c *gdbClient) readCode(pc uint64) uint8 {
+	b, err := strconv.ParseUint(c.request(fmt.Sprintf("m%x,1", pc)), 16, 8)
+	require.NoError(c.t, err)
+	return uint8(b)
+}
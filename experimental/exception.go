@@ -0,0 +1,47 @@
+package experimental
+
+import "github.com/tetratelabs/wazero/api"
+
+// Tag is a WebAssembly tag, which classifies the exceptions thrown by the
+// `throw` instruction. Tags are compared by identity: each instantiation of a
+// module defining a tag creates a distinct tag.
+//
+// See CoreFeaturesExceptionHandling
+type Tag interface {
+	// ParamTypes are the types of the values carried by exceptions of this tag.
+	ParamTypes() []api.ValueType
+}
+
+// Exception is a WebAssembly exception.
+//
+// When a Wasm function throws an exception which isn't caught, api.Function
+// Call returns an *Exception as the error. A host function can throw an
+// exception to its caller via panic:
+//
+//	panic(&experimental.Exception{Tag: tag, Values: []uint64{42}})
+//
+// See CoreFeaturesExceptionHandling
+type Exception struct {
+	// Tag is the tag of the exception, for example the result of ExportedTag.
+	Tag Tag
+
+	// Values are the values carried by the exception, encoded like the
+	// parameters of api.Function. The length must match Tag.ParamTypes, except
+	// api.ValueTypeV128 values which take two elements.
+	Values []uint64
+}
+
+// Error implements error.
+func (e *Exception) Error() string {
+	return "uncaught wasm exception"
+}
+
+// ExportedTag returns a tag exported from this module or nil if it wasn't.
+//
+// See CoreFeaturesExceptionHandling
+func ExportedTag(mod api.Module, name string) Tag {
+	if m, ok := mod.(interface{ ExportedTag(string) Tag }); ok {
+		return m.ExportedTag(name)
+	}
+	return nil
+}
@@ -1,6 +1,16 @@
 package experimental
 
-import "github.com/tetratelabs/wazero/api"
+import (
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/internalapi"
+)
+
+// featureName registers the name returned by api.CoreFeatures String for the feature, which matches the name of its
+// proposal. This returns the feature, so that each name is declared next to the constant.
+func featureName(feature api.CoreFeatures, name string) api.CoreFeatures {
+	internalapi.RegisterCoreFeatureName(uint64(feature), name)
+	return feature
+}
 
 // CoreFeaturesThreads enables threads instructions ("threads").
 //
@@ -13,3 +23,157 @@ import "github.com/tetratelabs/wazero/api"
 //     binaries will use a theroetical maximum like 4GB, so if using such a binary on a system
 //     without mmap, consider editing the binary to reduce the max size setting of memory.
 const CoreFeaturesThreads = api.CoreFeatureSIMD << 1
+
+var _ = featureName(CoreFeaturesThreads, "threads")
+
+// CoreFeaturesTailCall enables tail call instructions ("tail-call").
+//
+// # Notes
+//
+//   - Adds the `return_call` and `return_call_indirect` instructions.
+//   - The callee replaces the frame of the current function, so unbounded mutual
+//     recursion via tail calls does not exhaust the call stack.
+//   - In the interpreter, when a function listener is configured, tail calls
+//     are executed as a call followed by a return in order to report the
+//     results of each function to the listener.
+//   - The compiler rejects a module using tail calls if a function listener
+//     is configured, or if it tail calls a function whose parameters passed on
+//     the native stack differ in size from the caller's. Use the interpreter
+//     for such modules. wazero.NewRuntimeConfigTiered keeps them on it.
+//
+// See https://github.com/WebAssembly/tail-call/blob/main/proposals/tail-call/Overview.md
+const CoreFeaturesTailCall = CoreFeaturesThreads << 1
+
+var _ = featureName(CoreFeaturesTailCall, "tail-call")
+
+// CoreFeaturesExceptionHandling enables exception handling instructions
+// ("exception-handling").
+//
+// # Notes
+//
+//   - Adds the tag section, the `exnref` value type and the `throw`,
+//     `throw_ref` and `try_table` instructions.
+//   - Host functions can throw and catch guest exceptions. See Exception.
+//   - An `exnref` is only valid during the outermost function call that
+//     produced it: it cannot be used after the call returns, and it cannot
+//     be stored in tables.
+//
+// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md
+const CoreFeaturesExceptionHandling = CoreFeaturesTailCall << 1
+
+var _ = featureName(CoreFeaturesExceptionHandling, "exception-handling")
+
+// CoreFeaturesMultiMemory enables multiple memories ("multi-memory").
+//
+// # Notes
+//
+//   - A module can define and import more than one memory, and memory
+//     instructions and active data segments encode the index of the memory
+//     they use.
+//   - api.Module Memory returns the memory at index zero. Use MemoryAt to get
+//     other memories by index.
+//
+// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md
+const CoreFeaturesMultiMemory = CoreFeaturesExceptionHandling << 1
+
+var _ = featureName(CoreFeaturesMultiMemory, "multi-memory")
+
+// CoreFeaturesMemory64 enables 64-bit linear memories ("memory64").
+//
+// # Notes
+//
+//   - A memory declared with 64-bit limits is addressed with i64 operands and
+//     memory offsets, and memory.size and memory.grow use i64 page counts.
+//   - api.Memory accessors take uint32 offsets. Use AsMemory64 to read and
+//     write a memory above 4GiB from a host function.
+//   - The size of a 64-bit memory is still bounded by the memory limit of the
+//     runtime, which defaults to 4GiB. Raise it with
+//     wazero.RuntimeConfig WithMemoryLimitPages.
+//
+// See https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
+const CoreFeaturesMemory64 = CoreFeaturesMultiMemory << 1
+
+var _ = featureName(CoreFeaturesMemory64, "memory64")
+
+// CoreFeaturesExtendedConst enables the extended constant expressions
+// proposal. This allows i32.add, i32.sub, i32.mul and their i64 equivalents
+// in constant expressions, such as global initializers and data or element
+// segment offsets, which LLVM emits for position-independent code.
+//
+// Note: As with other constant expressions, global.get can only refer to
+// imported globals.
+//
+// See https://github.com/WebAssembly/extended-const/blob/main/proposals/extended-const/Overview.md
+const CoreFeaturesExtendedConst = CoreFeaturesMemory64 << 1
+
+var _ = featureName(CoreFeaturesExtendedConst, "extended-const")
+
+// CoreFeaturesFunctionReferences enables typed function references
+// ("function-references").
+//
+// # Notes
+//
+//   - Adds the reference types "(ref null ht)" and "(ref ht)" and the
+//     `call_ref`, `return_call_ref`, `ref.as_non_null`, `br_on_null` and
+//     `br_on_non_null` instructions.
+//   - wazero does not distinguish nullable from non-nullable references, nor
+//     a typed function reference from a funcref, during validation. Instead,
+//     `call_ref` checks the type of the callee at runtime, as
+//     `call_indirect` does.
+//
+// See https://github.com/WebAssembly/function-references/blob/main/proposals/function-references/Overview.md
+const CoreFeaturesFunctionReferences = CoreFeaturesExtendedConst << 1
+
+var _ = featureName(CoreFeaturesFunctionReferences, "function-references")
+
+// CoreFeaturesGC enables garbage collection ("gc"). This requires
+// CoreFeaturesFunctionReferences to also be enabled.
+//
+// # Notes
+//
+//   - Adds struct and array types, subtyping and recursion groups to the
+//     type section, and the `struct.*`, `array.*`, `ref.i31`, `ref.test`,
+//     `ref.cast`, `br_on_cast` and conversion instructions.
+//   - Structs and arrays are allocated in a heap owned by the runtime and
+//     shared by all its modules. Objects are collected when they are no longer
+//     reachable from a global, table or element segment of an instantiated
+//     module or from the stack of a function call in progress. Collection
+//     waits until the other function calls in progress allocate or call host
+//     functions, so the heap may grow meanwhile.
+//   - A reference returned to the host is not kept alive by the host. Using
+//     it after the object was collected traps.
+//   - This is only supported by the interpreter. wazero.NewRuntimeConfig
+//     selects the interpreter when this feature is enabled, and the compiler
+//     fails to compile the modules using any of the above.
+//   - Values are stored in 64-bit slots, so a packed i8 or i16 array takes as
+//     much memory as an i64 one.
+//
+// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md
+const CoreFeaturesGC = CoreFeaturesFunctionReferences << 1
+
+var _ = featureName(CoreFeaturesGC, "gc")
+
+// CoreFeaturesRelaxedSIMD enables relaxed SIMD ("relaxed-simd"). This
+// requires api.CoreFeatureSIMD to also be enabled.
+//
+// # Notes
+//
+//   - Adds the `relaxed_swizzle`, `relaxed_trunc`, `relaxed_madd`,
+//     `relaxed_nmadd`, `relaxed_laneselect`, `relaxed_min`, `relaxed_max`,
+//     `relaxed_q15mulr_s` and `relaxed_dot` instructions.
+//   - The proposal lets the result of these depend on the host. wazero
+//     instead chooses the same result on every platform and in both the
+//     compiler and the interpreter, so that their results can be compared.
+//   - `relaxed_swizzle` is `i8x16.swizzle`, `relaxed_trunc` is the
+//     corresponding saturating `trunc_sat`, `relaxed_laneselect` is
+//     `v128.bitselect`, `relaxed_min` and `relaxed_max` are `min` and `max`,
+//     and `relaxed_q15mulr_s` is `i16x8.q15mulr_sat_s`.
+//   - `relaxed_madd` and `relaxed_nmadd` round the product before the
+//     addition, i.e. they are never fused.
+//   - `relaxed_dot` treats both operands as signed, and the sums of adjacent
+//     products of `i16x8.relaxed_dot_i8x16_i7x16_s` wrap around.
+//
+// See https://github.com/WebAssembly/relaxed-simd/blob/main/proposals/relaxed-simd/Overview.md
+const CoreFeaturesRelaxedSIMD = CoreFeaturesGC << 1
+
+var _ = featureName(CoreFeaturesRelaxedSIMD, "relaxed-simd")
@@ -0,0 +1,40 @@
+package experimental_test
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestCoreFeatures_String(t *testing.T) {
+	tests := []struct {
+		feature  api.CoreFeatures
+		expected string
+	}{
+		{feature: experimental.CoreFeaturesThreads, expected: "threads"},
+		{feature: experimental.CoreFeaturesTailCall, expected: "tail-call"},
+		{feature: experimental.CoreFeaturesExceptionHandling, expected: "exception-handling"},
+		{feature: experimental.CoreFeaturesMultiMemory, expected: "multi-memory"},
+		{feature: experimental.CoreFeaturesMemory64, expected: "memory64"},
+		{feature: experimental.CoreFeaturesExtendedConst, expected: "extended-const"},
+		{feature: experimental.CoreFeaturesFunctionReferences, expected: "function-references"},
+		{feature: experimental.CoreFeaturesGC, expected: "gc"},
+		{feature: experimental.CoreFeaturesRelaxedSIMD, expected: "relaxed-simd"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.expected, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.feature.String())
+			require.EqualError(t, api.CoreFeaturesV2.RequireEnabled(tc.feature),
+				"feature \""+tc.expected+"\" is disabled")
+		})
+	}
+
+	// Every feature following api.CoreFeatureSIMD is defined in this package, and is named.
+	for f := experimental.CoreFeaturesThreads; f <= experimental.CoreFeaturesRelaxedSIMD; f <<= 1 {
+		require.NotEqual(t, "", f.String(), "feature %#x", uint64(f))
+	}
+}
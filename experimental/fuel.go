@@ -0,0 +1,112 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+)
+
+// FuelCosts is the fuel consumed by each class of instruction executed by a
+// module compiled with WithFuelMetering.
+//
+// Fuel is charged deterministically at block and loop boundaries, as well as
+// before calls, for all the instructions executed since the previous charge.
+// This means the exact same function call consumes the exact same amount of
+// fuel regardless of the engine or the platform.
+type FuelCosts struct {
+	// Control is the cost of control instructions, e.g. block, loop, br and
+	// return, as well as nop, drop, select and the exception instructions.
+	Control uint32
+	// Call is the cost of call instructions, e.g. call and call_indirect. This
+	// doesn't include the fuel consumed by the callee.
+	Call uint32
+	// Variable is the cost of local and global instructions, e.g. local.get
+	// and global.set.
+	Variable uint32
+	// Memory is the cost of memory instructions, e.g. i32.load, memory.grow
+	// and memory.copy, as well as atomic instructions.
+	Memory uint32
+	// Reference is the cost of table, reference and GC instructions, e.g.
+	// table.get, ref.func and struct.new.
+	Reference uint32
+	// Numeric is the cost of scalar numeric instructions, e.g. i32.const,
+	// i64.add and f64.sqrt.
+	Numeric uint32
+	// Vector is the cost of vector instructions, e.g. v128.load and i32x4.add.
+	Vector uint32
+}
+
+// DefaultFuelCosts returns FuelCosts which charge one unit of fuel per
+// instruction.
+func DefaultFuelCosts() FuelCosts {
+	return FuelCosts{Control: 1, Call: 1, Variable: 1, Memory: 1, Reference: 1, Numeric: 1, Vector: 1}
+}
+
+// WithFuelMetering enables fuel metering in the modules compiled with the
+// returned context, using the given cost for each class of instruction.
+//
+// Calls of the functions defined by these modules consume the fuel set in the
+// call context by WithFuel. When it runs out, the call fails with an error
+// wrapping sys.ErrOutOfFuel.
+//
+// Here's an example of billing a call by the work it did:
+//
+//	ctx = experimental.WithFuelMetering(ctx, experimental.DefaultFuelCosts())
+//	mod, _ := r.Instantiate(ctx, wasm)
+//
+//	callCtx := experimental.WithFuel(ctx, 1_000_000)
+//	_, err := mod.ExportedFunction("run").Call(callCtx)
+//	if errors.Is(err, sys.ErrOutOfFuel) {
+//		// The call did more work than allowed.
+//	}
+//	used := 1_000_000 - experimental.RemainingFuel(callCtx)
+//	--snip--
+//
+// Notes:
+//   - The costs are part of the compiled code, so modules compiled with
+//     different costs are cached separately.
+//   - Host functions don't consume fuel, but can charge for their work with
+//     AddFuel.
+func WithFuelMetering(ctx context.Context, costs FuelCosts) context.Context {
+	return context.WithValue(ctx, expctxkeys.FuelCostsKey{}, &costs)
+}
+
+// WithFuel returns a context whose function calls can consume up to the given
+// fuel, when executing modules compiled with WithFuelMetering.
+//
+// The remaining fuel is shared by all the calls made with the returned
+// context, including nested calls made by host functions, and can be read
+// with RemainingFuel after or during a call.
+//
+// Note: The remaining fuel isn't safe for concurrent use, so the returned
+// context must not be used by concurrent calls.
+func WithFuel(ctx context.Context, fuel int64) context.Context {
+	return context.WithValue(ctx, expctxkeys.FuelKey{}, &fuel)
+}
+
+// RemainingFuel returns the fuel remaining in the context set by WithFuel, or
+// zero if there is none.
+//
+// This is typically called by a host function with its context, or by the
+// caller of a function after the call.
+func RemainingFuel(ctx context.Context) int64 {
+	if fuel, ok := ctx.Value(expctxkeys.FuelKey{}).(*int64); ok {
+		return *fuel
+	}
+	return 0
+}
+
+// AddFuel adds delta to the fuel remaining in the context set by WithFuel, and
+// returns the result. A negative delta consumes fuel instead, e.g. to charge
+// for the work done by a host function. This has no effect if the context has
+// no fuel.
+//
+// This is typically called by a host function with its context to refill the
+// fuel of the ongoing call.
+func AddFuel(ctx context.Context, delta int64) int64 {
+	if fuel, ok := ctx.Value(expctxkeys.FuelKey{}).(*int64); ok {
+		*fuel += delta
+		return *fuel
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package experimental_test
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestWithFuelMetering(t *testing.T) {
+	decorated := experimental.WithFuelMetering(testCtx, experimental.DefaultFuelCosts())
+	require.Equal(t, experimental.DefaultFuelCosts(), *decorated.Value(expctxkeys.FuelCostsKey{}).(*experimental.FuelCosts))
+}
+
+func TestWithFuel(t *testing.T) {
+	require.Equal(t, int64(0), experimental.RemainingFuel(testCtx))
+	require.Equal(t, int64(0), experimental.AddFuel(testCtx, 10))
+
+	ctx := experimental.WithFuel(testCtx, 10)
+	require.Equal(t, int64(10), experimental.RemainingFuel(ctx))
+	require.Equal(t, int64(15), experimental.AddFuel(ctx, 5))
+	require.Equal(t, int64(12), experimental.AddFuel(ctx, -3))
+	require.Equal(t, int64(12), experimental.RemainingFuel(ctx))
+}
@@ -3,7 +3,9 @@ package experimental
 import (
 	"context"
 
+	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/internalapi"
 )
 
 // MemoryAllocator is a memory allocation hook,
@@ -50,3 +52,117 @@ func WithMemoryAllocator(ctx context.Context, allocator MemoryAllocator) context
 	}
 	return ctx
 }
+
+// MemoryAt returns the memory at the given index in the memory index space
+// of the module, which begins with imported memories, or nil if there is no
+// memory at the index.
+//
+// Note: Memory returns the same memory as MemoryAt with index zero.
+//
+// See CoreFeaturesMultiMemory
+func MemoryAt(mod api.Module, index uint32) api.Memory {
+	if m, ok := mod.(interface{ MemoryAt(uint32) api.Memory }); ok {
+		return m.MemoryAt(index)
+	}
+	if index == 0 {
+		return mod.Memory()
+	}
+	return nil
+}
+
+// Memory64 is a variant of api.Memory which addresses the memory with
+// 64-bit offsets, so that host functions can access a memory larger than
+// 4GiB.
+//
+// Methods are the same as documented on api.Memory, except offsets, sizes
+// and pages are uint64.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+//   - Use AsMemory64 to get the Memory64 of an api.Memory.
+//
+// See CoreFeaturesMemory64
+type Memory64 interface {
+	// Definition is metadata about this memory from its defining module.
+	Definition() api.MemoryDefinition
+
+	// Size returns the memory size in bytes available.
+	Size() uint64
+
+	// Grow increases memory by the delta in pages (65536 bytes per page).
+	// The return val is the previous memory size in pages, or false if the
+	// delta was ignored as it exceeds api.MemoryDefinition Max.
+	Grow(deltaPages uint64) (previousPages uint64, ok bool)
+
+	// ReadUint8 reads a single byte from the underlying buffer at the offset or returns false if out of range.
+	ReadUint8(offset uint64) (byte, bool)
+
+	// ReadUint16Le reads a uint16 in little-endian encoding from the underlying buffer at the offset in or returns
+	// false if out of range.
+	ReadUint16Le(offset uint64) (uint16, bool)
+
+	// ReadUint32Le reads a uint32 in little-endian encoding from the underlying buffer at the offset in or returns
+	// false if out of range.
+	ReadUint32Le(offset uint64) (uint32, bool)
+
+	// ReadFloat32Le reads a float32 from 32 IEEE 754 little-endian encoded bits in the underlying buffer at the offset
+	// or returns false if out of range.
+	ReadFloat32Le(offset uint64) (float32, bool)
+
+	// ReadUint64Le reads a uint64 in little-endian encoding from the underlying buffer at the offset or returns false
+	// if out of range.
+	ReadUint64Le(offset uint64) (uint64, bool)
+
+	// ReadFloat64Le reads a float64 from 64 IEEE 754 little-endian encoded bits in the underlying buffer at the offset
+	// or returns false if out of range.
+	ReadFloat64Le(offset uint64) (float64, bool)
+
+	// Read reads byteCount bytes from the underlying buffer at the offset or
+	// returns false if out of range. The result is a view of the underlying
+	// memory, the same as api.Memory Read.
+	Read(offset, byteCount uint64) ([]byte, bool)
+
+	// WriteUint8 writes the value to the underlying buffer at the offset in or returns false if out of range.
+	WriteUint8(offset uint64, v byte) bool
+
+	// WriteUint16Le writes the value in little-endian encoding to the underlying buffer at the offset in or returns
+	// false if out of range.
+	WriteUint16Le(offset uint64, v uint16) bool
+
+	// WriteUint32Le writes the value in little-endian encoding to the underlying buffer at the offset in or returns
+	// false if out of range.
+	WriteUint32Le(offset uint64, v uint32) bool
+
+	// WriteFloat32Le writes the value in 32 IEEE 754 little-endian encoded bits to the underlying buffer at the offset
+	// or returns false if out of range.
+	WriteFloat32Le(offset uint64, v float32) bool
+
+	// WriteUint64Le writes the value in little-endian encoding to the underlying buffer at the offset in or returns
+	// false if out of range.
+	WriteUint64Le(offset uint64, v uint64) bool
+
+	// WriteFloat64Le writes the value in 64 IEEE 754 little-endian encoded bits to the underlying buffer at the offset
+	// or returns false if out of range.
+	WriteFloat64Le(offset uint64, v float64) bool
+
+	// Write writes the slice to the underlying buffer at the offset or returns false if out of range.
+	Write(offset uint64, v []byte) bool
+
+	// WriteString writes the string to the underlying buffer at the offset or returns false if out of range.
+	WriteString(offset uint64, v string) bool
+
+	internalapi.WazeroOnly
+}
+
+// AsMemory64 returns the Memory64 variant of the given memory, or nil if the
+// memory was not created by wazero.
+//
+// Note: this works for both 32-bit and 64-bit memories.
+func AsMemory64(mem api.Memory) Memory64 {
+	if m, ok := mem.(interface{ AsMemory64() Memory64 }); ok {
+		return m.AsMemory64()
+	}
+	return nil
+}
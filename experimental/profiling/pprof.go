@@ -0,0 +1,217 @@
+package profiling
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// The field numbers of the messages in the pprof format.
+// See https://github.com/google/pprof/blob/main/proto/profile.proto
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID      = 1
+	locationAddress = 3
+	locationLine    = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+)
+
+// writeProfile writes the samples as a gzip-compressed pprof profile. The IDs of the frames in the keys of samples
+// are the indexes of frames plus one, which are also used as the location IDs.
+func writeProfile(w io.Writer, frames []frameKey, samples map[string]int64, period time.Duration, start time.Time, duration time.Duration) error {
+	var b, msg protobuf
+	strings := stringTable{indexes: map[string]int64{}}
+	strings.index("") // The first string must be empty.
+
+	valueType := func(typ, unit string) []byte {
+		msg.reset()
+		msg.int64(valueTypeType, strings.index(typ))
+		msg.int64(valueTypeUnit, strings.index(unit))
+		return msg.bytes()
+	}
+	b.message(profileSampleType, valueType("samples", "count"))
+	b.message(profileSampleType, valueType("cpu", "nanoseconds"))
+
+	var locationIDs []uint64
+	for key, count := range samples {
+		locationIDs = locationIDs[:0]
+		for k := []byte(key); len(k) > 0; {
+			id, n := binary.Uvarint(k)
+			locationIDs = append(locationIDs, id)
+			k = k[n:]
+		}
+		msg.reset()
+		msg.uint64s(sampleLocationID, locationIDs)
+		msg.int64s(sampleValue, []int64{count, count * period.Nanoseconds()})
+		b.message(profileSample, msg.bytes())
+	}
+
+	functionIDs := map[api.FunctionDefinition]uint64{}
+	var functions []api.FunctionDefinition
+	functionFiles := map[api.FunctionDefinition]string{}
+	var line protobuf
+	for i, f := range frames {
+		id, ok := functionIDs[f.function]
+		if !ok {
+			functions = append(functions, f.function)
+			id = uint64(len(functions))
+			functionIDs[f.function] = id
+		}
+
+		var lineNumber int64
+		if locations := f.dwarfLines.Locations(f.sourceOffset); len(locations) > 0 {
+			// The last one is in the function of the frame, into which the others are inlined if any.
+			l := locations[len(locations)-1]
+			lineNumber = l.Line
+			if _, ok := functionFiles[f.function]; !ok {
+				functionFiles[f.function] = l.File
+			}
+		}
+
+		line.reset()
+		line.uint64(lineFunctionID, id)
+		line.int64(lineLine, lineNumber)
+		msg.reset()
+		msg.uint64(locationID, uint64(i+1))
+		msg.uint64(locationAddress, f.sourceOffset)
+		msg.message(locationLine, line.bytes())
+		b.message(profileLocation, msg.bytes())
+	}
+
+	for i, f := range functions {
+		msg.reset()
+		msg.uint64(functionID, uint64(i+1))
+		name := strings.index(f.DebugName())
+		msg.int64(functionName, name)
+		msg.int64(functionSystemName, name)
+		msg.int64(functionFilename, strings.index(functionFiles[f]))
+		b.message(profileFunction, msg.bytes())
+	}
+
+	for _, s := range strings.strings {
+		b.string(profileStringTable, s)
+	}
+	b.int64(profileTimeNanos, start.UnixNano())
+	b.int64(profileDurationNanos, duration.Nanoseconds())
+	b.message(profilePeriodType, valueType("cpu", "nanoseconds"))
+	b.int64(profilePeriod, period.Nanoseconds())
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// stringTable is the string table of a profile.
+type stringTable struct {
+	strings []string
+	indexes map[string]int64
+}
+
+// index returns the index of s in the table, adding it if not found.
+func (t *stringTable) index(s string) int64 {
+	if i, ok := t.indexes[s]; ok {
+		return i
+	}
+	i := int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.indexes[s] = i
+	return i
+}
+
+// protobuf is a minimal encoder of the protocol buffers, which is enough for the pprof format.
+type protobuf struct {
+	buf []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protobuf) reset() {
+	b.buf = b.buf[:0]
+}
+
+func (b *protobuf) bytes() []byte {
+	return b.buf
+}
+
+func (b *protobuf) key(field, wireType int) {
+	b.buf = binary.AppendUvarint(b.buf, uint64(field<<3|wireType))
+}
+
+func (b *protobuf) uint64(field int, x uint64) {
+	b.key(field, wireVarint)
+	b.buf = binary.AppendUvarint(b.buf, x)
+}
+
+func (b *protobuf) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protobuf) uint64s(field int, xs []uint64) {
+	var n int
+	for _, x := range xs {
+		n += uvarintLen(x)
+	}
+	b.key(field, wireBytes)
+	b.buf = binary.AppendUvarint(b.buf, uint64(n))
+	for _, x := range xs {
+		b.buf = binary.AppendUvarint(b.buf, x)
+	}
+}
+
+func (b *protobuf) int64s(field int, xs []int64) {
+	us := make([]uint64, len(xs))
+	for i, x := range xs {
+		us[i] = uint64(x)
+	}
+	b.uint64s(field, us)
+}
+
+func (b *protobuf) string(field int, s string) {
+	b.key(field, wireBytes)
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(s)))
+	b.buf = append(b.buf, s...)
+}
+
+// message appends the encoded message, which must not be backed by b.
+func (b *protobuf) message(field int, msg []byte) {
+	b.key(field, wireBytes)
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(msg)))
+	b.buf = append(b.buf, msg...)
+}
+
+func uvarintLen(x uint64) (n int) {
+	for n = 1; x >= 0x80; n++ {
+		x >>= 7
+	}
+	return
+}
@@ -0,0 +1,118 @@
+package profiling_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/profiling"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// loopWasm exports "outer", which calls "inner" the given number of times, which loops 100 times calling the
+// imported "env.yield" so that the profiler can run even with GOMAXPROCS=1.
+var loopWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{wasm.ValueTypeI32}}, {}},
+	ImportSection:   []wasm.Import{{Module: "env", Name: "yield", Type: wasm.ExternTypeFunc, DescFunc: 1}},
+	FunctionSection: []wasm.Index{0, 1},
+	CodeSection: []wasm.Code{
+		{Body: []byte{
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeCall, 2,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeLocalTee, 0,
+			wasm.OpcodeBrIf, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+		{LocalTypes: []wasm.ValueType{wasm.ValueTypeI32}, Body: []byte{
+			wasm.OpcodeI32Const, 0xe4, 0x00, wasm.OpcodeLocalSet, 0, // 100
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeCall, 0,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeLocalTee, 0,
+			wasm.OpcodeBrIf, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+	},
+	ExportSection: []wasm.Export{{Name: "outer", Type: wasm.ExternTypeFunc, Index: 1}},
+	NameSection: &wasm.NameSection{
+		ModuleName:    "loop",
+		FunctionNames: wasm.NameMap{{Index: 1, Name: "outer"}, {Index: 2, Name: "inner"}},
+	},
+})
+
+func TestProfiler(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	p := profiling.NewProfiler(1000)
+	ctx := profiling.WithProfiler(context.Background(), p)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler())
+	defer r.Close(ctx)
+	mod, err := instantiateLoop(ctx, r)
+	require.NoError(t, err)
+	outer := mod.ExportedFunction("outer")
+
+	p.Start()
+	for deadline := time.Now().Add(200 * time.Millisecond); time.Now().Before(deadline); {
+		_, err = outer.Call(ctx, 10_000)
+		require.NoError(t, err)
+	}
+	p.Stop()
+
+	var buf bytes.Buffer
+	require.NoError(t, p.WriteProfile(&buf))
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	profile, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	// The function names are in the string table, and the sample type is the CPU time.
+	for _, s := range []string{"loop.outer", "loop.inner", "cpu", "nanoseconds"} {
+		require.True(t, bytes.Contains(profile, []byte(s)), s)
+	}
+}
+
+func TestProfiler_notStarted(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	p := profiling.NewProfiler(profiling.DefaultSampleRate)
+	ctx := profiling.WithProfiler(context.Background(), p)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler())
+	defer r.Close(ctx)
+	mod, err := instantiateLoop(ctx, r)
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("outer").Call(ctx, 10)
+	require.NoError(t, err)
+
+	// An empty profile is still valid.
+	var buf bytes.Buffer
+	require.NoError(t, p.WriteProfile(&buf))
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	profile, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(profile, []byte("loop.outer")))
+}
+
+func instantiateLoop(ctx context.Context, r wazero.Runtime) (api.Module, error) {
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(runtime.Gosched).Export("yield").
+		Instantiate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.Instantiate(ctx, loopWasm)
+}
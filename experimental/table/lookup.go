@@ -16,7 +16,10 @@ import (
 //   - `tableOffset` is the offset of the lookup target in the table.
 //   - `expectedParamTypes` and `expectedResultTypes` are used to check the type of the function found in the table.
 //
-// Note: the returned api.Function is always valid, i.e. not nil, if this returns without panic.
+// # Notes
+//
+//   - The returned api.Function is always valid, i.e. not nil, if this returns without panic.
+//   - To access an exported table without panicking, see api.Module ExportedTable.
 func LookupFunction(
 	module api.Module, tableIndex uint32, tableOffset uint32,
 	expectedParamTypes, expectedResultTypes []api.ValueType,
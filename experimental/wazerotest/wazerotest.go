@@ -99,6 +99,18 @@ func (m *Module) ExportedMemoryDefinitions() map[string]api.MemoryDefinition {
 	return m.exportedMemoryDefinitions
 }
 
+// ExportedTable implements the same method as documented on api.Module.
+//
+// Note: A Module has no tables, so this always returns nil.
+func (m *Module) ExportedTable(string) api.Table {
+	return nil
+}
+
+// ExportedTableDefinitions implements the same method as documented on api.Module.
+func (m *Module) ExportedTableDefinitions() map[string]api.TableDefinition {
+	return map[string]api.TableDefinition{}
+}
+
 // ExportedGlobal implements the same method as documented on api.Module.
 func (m *Module) ExportedGlobal(name string) api.Global {
 	m.once.Do(m.initialize)
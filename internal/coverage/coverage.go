@@ -0,0 +1,28 @@
+// Package coverage defines the contract between the interpreter and the collector in experimental/coverage.
+package coverage
+
+import "github.com/tetratelabs/wazero/internal/wasm"
+
+// Collector is notified of the blocks executed by the functions compiled with wasm.Module Coverage.
+type Collector interface {
+	// Hit is called when the block of the function at the index in Function.Blocks is executed.
+	Hit(f *Function, block int)
+}
+
+// Function is a function compiled with the blocks to count, which is shared by the instances of the module.
+type Function struct {
+	// Module is the module defining the function.
+	Module *wasm.Module
+	// Index is the index of the function in the module, including the imported ones.
+	Index wasm.Index
+	// Blocks are the blocks of the function in the order of the source offsets.
+	Blocks []Block
+}
+
+// Block is a range of the instructions which are executed all together unless one of them traps. A block is counted
+// right before its last instruction, which is the one charging the fuel according to wasm.FuelCost.
+type Block struct {
+	// Start and End are the source offsets in the code section of the first instruction, and of the last one plus
+	// one, which is less than the offset of the next instruction.
+	Start, End uint64
+}
@@ -0,0 +1,53 @@
+// Package debugging defines the contract between the interpreter and the debugger in experimental/debugging.
+package debugging
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
+)
+
+// Debugger is notified of the instructions executed by the functions compiled with wasm.Module Debugging.
+type Debugger interface {
+	// Step is called before each instruction with the stack of the call, whose innermost frame is about to execute
+	// it. The call is suspended until Step returns, and the stack is only valid during the call.
+	Step(ctx context.Context, stack Stack)
+
+	// Trap is called when the call panics with err, before its frames are unwound. The innermost frame is the one
+	// which panicked, and the stack is only valid during the call.
+	Trap(ctx context.Context, stack Stack, err error)
+}
+
+// Stack is the stack of a call suspended before an instruction or at a trap.
+type Stack interface {
+	// Depth returns the number of the frames.
+	Depth() int
+
+	// Frame returns the i-th frame from the innermost one, where 0 <= i < Depth.
+	Frame(i int) Frame
+}
+
+// Frame is a frame of a Stack.
+type Frame struct {
+	// Function is the definition of the function of the frame.
+	Function api.FunctionDefinition
+	// Module is the instance of the module defining Function.
+	Module *wasm.ModuleInstance
+	// DWARFLines is the DWARF line information of the module defining Function, or nil if not available.
+	DWARFLines *wasmdebug.DWARFLines
+	// SourceOffset is the offset of the current instruction of the frame in the code section of the Wasm binary,
+	// which is the call instruction except for the innermost frame. This is zero for host functions.
+	SourceOffset uint64
+	// BodyOffset is the offset of the first instruction of Function in the code section, so SourceOffset equals to
+	// this when the function is entered. This is zero for host functions.
+	BodyOffset uint64
+	// LocalTypes are the types of the params and the locals of Function.
+	LocalTypes []wasm.ValueType
+	// Locals are the values of the params and the locals in the order of LocalTypes, where a v128 value takes two
+	// elements for its lower and higher 64 bits.
+	Locals []uint64
+	// Stack is the operand stack of the frame from the bottom, which excludes the params passed to the callee.
+	Stack []uint64
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/coverage"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -21,6 +23,7 @@ const (
 	controlFrameKindLoop
 	controlFrameKindIfWithElse
 	controlFrameKindIfWithoutElse
+	controlFrameKindTryTable
 )
 
 type (
@@ -34,8 +37,35 @@ type (
 		originalStackLenWithoutParamUint64 int
 		blockType                          *wasm.FunctionType
 		kind                               controlFrameKind
+		// catches are the catch clauses of controlFrameKindTryTable.
+		catches []wasm.CatchClause
+		// handlerStart is the index of the first operation covered by the catch clauses of controlFrameKindTryTable.
+		handlerStart int
 	}
 	controlFrames struct{ frames []controlFrame }
+
+	// exceptionHandler holds the catch clauses of a try_table instruction.
+	exceptionHandler struct {
+		// start and end are the range of operations [start, end) covered by the catch clauses.
+		start, end uint64
+		// stackHeight is the height of the stack in uint64 when entering the try_table minus its params, counted from
+		// the first param of the function.
+		stackHeight int
+		catches     []exceptionCatch
+	}
+
+	// exceptionCatch is a catch clause of exceptionHandler.
+	exceptionCatch struct {
+		// tag is the index of the tag caught by this clause unless all is true.
+		tag wasm.Index
+		// all is true if this clause catches all exceptions, and doesn't push the payload.
+		all bool
+		// ref is true if this clause pushes the exnref of the caught exception.
+		ref bool
+		// pad is the label of the operations which branch to the target of this clause with the payload on the stack.
+		// This is resolved to the index of the operation when lowering to the engine friendly struct.
+		pad uint64
+	}
 )
 
 func (c *controlFrame) ensureContinuation() {
@@ -57,7 +87,8 @@ func (c *controlFrame) asLabel() label {
 	case controlFrameKindFunction:
 		return newLabel(labelKindReturn, 0)
 	case controlFrameKindIfWithElse,
-		controlFrameKindIfWithoutElse:
+		controlFrameKindIfWithoutElse,
+		controlFrameKindTryTable:
 		return newLabel(labelKindContinuation, c.frameID)
 	}
 	panic(fmt.Sprintf("unreachable: a bug in interpreterir implementation: %v", c.kind))
@@ -180,6 +211,8 @@ type compiler struct {
 	// localIndexToStackHeightInUint64 maps the local index (starting with function params) to the stack height
 	// where the local is places. This is the necessary mapping for functions who contain vector type locals.
 	localIndexToStackHeightInUint64 []int
+	// fusionBarrier is the index of the first operation which can be fused into a superinstruction. See fuse.
+	fusionBarrier int
 
 	// types hold all the function types in the module where the targe function exists.
 	types []wasm.FunctionType
@@ -187,6 +220,10 @@ type compiler struct {
 	funcs []uint32
 	// globals holds the global types for all declared globals in the module where the target function exists.
 	globals []wasm.GlobalType
+	// memories holds the memory types for all declared memories in the module where the target function exists.
+	memories []*wasm.Memory
+	// hasMemory64 is true if any of memories is 64-bit.
+	hasMemory64 bool
 
 	// needSourceOffset is true if this module requires DWARF based stack trace.
 	needSourceOffset bool
@@ -194,6 +231,16 @@ type compiler struct {
 	bodyOffsetInCodeSection uint64
 
 	ensureTermination bool
+	// fuelCosts is non-nil when the fuel consumed by the instructions must be charged.
+	fuelCosts *experimental.FuelCosts
+	// fuel is the fuel consumed by the instructions since the last operationKindConsumeFuel.
+	fuel uint64
+	// debugging is true when operationKindDebugStep must be emitted before each instruction.
+	debugging bool
+	// coverage is true when operationKindCoverage must be emitted before the last instruction of each block.
+	coverage bool
+	// coverageStart is the offset of the first instruction of the current block if coverage, or -1 if not started.
+	coverageStart int64
 	// Pre-allocated bytes.Reader to be used in various places.
 	br             *bytes.Reader
 	funcTypeToSigs funcTypeToIRSignatures
@@ -226,7 +273,7 @@ const (
 	memoryTypeNone memoryType = iota
 	// memoryTypeStandard indicates there is a non-shared memory.
 	memoryTypeStandard
-	// memoryTypeShared indicates there is a shared memory.
+	// memoryTypeShared indicates there is at least one shared memory.
 	memoryTypeShared
 )
 
@@ -254,6 +301,12 @@ type compilationResult struct {
 	LabelCallers map[label]uint32
 	// UsesMemory is true if this function might use memory.
 	UsesMemory bool
+	// ExceptionHandlers holds the catch clauses of try_table instructions in this function. As they are added at the
+	// end of each try_table, the handlers of the inner try_table come first.
+	ExceptionHandlers []exceptionHandler
+	// CoverageBlocks holds the blocks in this function counted by operationKindCoverage, whose indexes are the
+	// operands. Non nil only when the given Wasm module has Coverage.
+	CoverageBlocks []coverage.Block
 
 	// The following fields are per-module values, not per-function.
 
@@ -276,7 +329,7 @@ type compilationResult struct {
 // newCompiler returns the new *compiler for the given parameters.
 // Use compiler.Next function to get compilation result per function.
 func newCompiler(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 int, module *wasm.Module, ensureTermination bool) (*compiler, error) {
-	functions, globals, mem, tables, err := module.AllDeclarations()
+	functions, globals, memories, tables, err := module.AllDeclarations()
 	if err != nil {
 		return nil, err
 	}
@@ -285,14 +338,16 @@ func newCompiler(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 in
 		len(module.DataSection) > 0, len(module.ElementSection) > 0
 
 	var mt memoryType
-	switch {
-	case mem == nil:
-		mt = memoryTypeNone
-	case mem.IsShared:
-		mt = memoryTypeShared
-	default:
+	if len(memories) > 0 {
 		mt = memoryTypeStandard
 	}
+	var hasMemory64 bool
+	for _, mem := range memories {
+		if mem.IsShared {
+			mt = memoryTypeShared
+		}
+		hasMemory64 = hasMemory64 || mem.Is64
+	}
 
 	types := module.TypeSection
 
@@ -312,16 +367,23 @@ func newCompiler(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 in
 			LabelCallers:        map[label]uint32{},
 		},
 		globals:           globals,
+		memories:          memories,
+		hasMemory64:       hasMemory64,
 		funcs:             functions,
 		types:             types,
 		ensureTermination: ensureTermination,
+		fuelCosts:         module.FuelCosts,
 		br:                bytes.NewReader(nil),
 		funcTypeToSigs: funcTypeToIRSignatures{
 			indirectCalls: make([]*signature, len(types)),
 			directCalls:   make([]*signature, len(types)),
+			refCalls:      make([]*signature, len(types)),
 			wasmTypes:     types,
 		},
-		needSourceOffset: module.DWARFLines != nil,
+		// The debugger needs the source offsets of the instructions regardless of DWARF.
+		needSourceOffset: module.DWARFLines != nil || module.Debugging,
+		debugging:        module.Debugging,
+		coverage:         module.Coverage,
 	}
 	return c, nil
 }
@@ -336,6 +398,8 @@ func (c *compiler) Next() (*compilationResult, error) {
 	c.result.Operations = c.result.Operations[:0]
 	c.result.IROperationSourceOffsetsInWasmBinary = c.result.IROperationSourceOffsetsInWasmBinary[:0]
 	c.result.UsesMemory = false
+	c.result.ExceptionHandlers = c.result.ExceptionHandlers[:0]
+	c.result.CoverageBlocks = c.result.CoverageBlocks[:0]
 	// Clears the existing entries in LabelCallers.
 	for frameID := uint32(0); frameID <= c.currentFrameID; frameID++ {
 		for k := labelKind(0); k < labelKindNum; k++ {
@@ -348,6 +412,9 @@ func (c *compiler) Next() (*compilationResult, error) {
 	c.currentFrameID = 0
 	c.stackLenInUint64 = 0
 	c.unreachableState.on, c.unreachableState.depth = false, 0
+	c.fuel = 0
+	c.fusionBarrier = 0
+	c.coverageStart = -1
 
 	if err := c.compile(sig, code.Body, code.LocalTypes, code.BodyOffsetInCodeSection); err != nil {
 		return nil, err
@@ -417,6 +484,36 @@ func (c *compiler) handleInstruction() error {
 		)
 	}
 
+	// Let the debugger stop before the instruction, including the fuel charged for it.
+	if c.debugging && !c.unreachableState.on {
+		c.emit(newOperationDebugStep())
+	}
+
+	// Count the block before its last instruction, which executes all the others in the block.
+	if c.coverage && !c.unreachableState.on {
+		if c.coverageStart < 0 {
+			c.coverageStart = int64(c.currentOpPC)
+		}
+		if wasm.IsBlockBoundary(c.body[c.pc:]) {
+			c.emit(newOperationCoverage(uint64(len(c.result.CoverageBlocks))))
+			c.result.CoverageBlocks = append(c.result.CoverageBlocks, coverage.Block{
+				Start: uint64(c.coverageStart) + c.bodyOffsetInCodeSection,
+				End:   c.currentOpPC + c.bodyOffsetInCodeSection + 1,
+			})
+			c.coverageStart = -1
+		}
+	}
+
+	// Charge the fuel consumed so far before the instruction if it is a boundary, including its own cost.
+	if c.fuelCosts != nil && !c.unreachableState.on {
+		cost, charge := wasm.FuelCost(c.fuelCosts, c.body[c.pc:])
+		c.fuel += uint64(cost)
+		if charge && c.fuel > 0 {
+			c.emit(newOperationConsumeFuel(c.fuel))
+			c.fuel = 0
+		}
+	}
+
 	var peekValueType unsignedType
 	if len(c.stack) > 0 {
 		peekValueType = c.stackPeek()
@@ -440,7 +537,7 @@ operatorSwitch:
 		// Nop is noop!
 	case wasm.OpcodeBlock:
 		c.br.Reset(c.body[c.pc+1:])
-		bt, num, err := wasm.DecodeBlockType(c.types, c.br, c.enabledFeatures)
+		bt, num, err := wasm.DecodeBlockType(c.module, c.br, c.enabledFeatures)
 		if err != nil {
 			return fmt.Errorf("reading block type for block instruction: %w", err)
 		}
@@ -463,9 +560,48 @@ operatorSwitch:
 		}
 		c.controlFrames.push(frame)
 
+	case wasm.OpcodeTryTable:
+		c.br.Reset(c.body[c.pc+1:])
+		bt, num, err := wasm.DecodeBlockType(c.module, c.br, c.enabledFeatures)
+		if err != nil {
+			return fmt.Errorf("reading block type for try_table instruction: %w", err)
+		}
+		catches, catchesNum, err := wasm.DecodeCatchClauses(c.body[c.pc+1+num:])
+		if err != nil {
+			return fmt.Errorf("reading catch clauses for try_table instruction: %w", err)
+		}
+		c.pc += num + catchesNum
+
+		if c.unreachableState.on {
+			// If it is currently in unreachable,
+			// just remove the entire block.
+			c.unreachableState.depth++
+			break operatorSwitch
+		}
+
+		// Create a new frame -- entering this try_table.
+		frame := controlFrame{
+			frameID:                            c.nextFrameID(),
+			originalStackLenWithoutParam:       len(c.stack) - len(bt.Params),
+			originalStackLenWithoutParamUint64: c.stackLenInUint64 - bt.ParamNumInUint64,
+			kind:                               controlFrameKindTryTable,
+			blockType:                          bt,
+			catches:                            catches,
+			handlerStart:                       len(c.result.Operations),
+		}
+		c.controlFrames.push(frame)
+		c.fusionBarrier = len(c.result.Operations)
+	case wasm.OpcodeThrow:
+		c.emit(newOperationThrow(index))
+		// throw is stack-polymorphic, and mark the state as unreachable.
+		c.markUnreachable()
+	case wasm.OpcodeThrowRef:
+		c.emit(newOperationThrowRef())
+		// throw_ref is stack-polymorphic, and mark the state as unreachable.
+		c.markUnreachable()
 	case wasm.OpcodeLoop:
 		c.br.Reset(c.body[c.pc+1:])
-		bt, num, err := wasm.DecodeBlockType(c.types, c.br, c.enabledFeatures)
+		bt, num, err := wasm.DecodeBlockType(c.module, c.br, c.enabledFeatures)
 		if err != nil {
 			return fmt.Errorf("reading block type for loop instruction: %w", err)
 		}
@@ -508,7 +644,7 @@ operatorSwitch:
 		}
 	case wasm.OpcodeIf:
 		c.br.Reset(c.body[c.pc+1:])
-		bt, num, err := wasm.DecodeBlockType(c.types, c.br, c.enabledFeatures)
+		bt, num, err := wasm.DecodeBlockType(c.module, c.br, c.enabledFeatures)
 		if err != nil {
 			return fmt.Errorf("reading block type for if instruction: %w", err)
 		}
@@ -615,6 +751,10 @@ operatorSwitch:
 				c.stackPush(wasmValueTypeTounsignedType(t))
 			}
 
+			if frame.kind == controlFrameKindTryTable {
+				c.emitCatchPads(frame)
+			}
+
 			continuationLabel := newLabel(labelKindContinuation, frame.frameID)
 			if frame.kind == controlFrameKindIfWithoutElse {
 				// Emit the else label.
@@ -673,6 +813,14 @@ operatorSwitch:
 			c.emit(dropOp)
 			c.emit(newOperationBr(continuationLabel))
 			c.emit(newOperationLabel(continuationLabel))
+		case controlFrameKindTryTable:
+			continuationLabel := newLabel(labelKindContinuation, frame.frameID)
+			c.result.LabelCallers[continuationLabel]++
+			c.emit(dropOp)
+			c.emit(newOperationBr(continuationLabel))
+			// The catch clauses are placed between the end of the body and the continuation.
+			c.emitCatchPads(frame)
+			c.emit(newOperationLabel(continuationLabel))
 		case controlFrameKindLoop, controlFrameKindBlockWithoutContinuationLabel:
 			c.emit(
 				dropOp,
@@ -814,6 +962,34 @@ operatorSwitch:
 		c.emit(
 			newOperationCallIndirect(typeIndex, tableIndex),
 		)
+	case wasm.OpcodeTailCallReturnCall:
+		calleeType := &c.types[c.funcs[index]]
+		c.emit(newOperationDrop(c.getTailCallDropRange(calleeType, false)))
+		c.emit(newOperationTailCallReturnCall(index))
+		// return_call is stack-polymorphic like return, so mark the state as unreachable.
+		c.markUnreachable()
+	case wasm.OpcodeTailCallReturnCallIndirect:
+		typeIndex := index
+		tableIndex, n, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return fmt.Errorf("read target for return_call_indirect: %w", err)
+		}
+		c.pc += n
+		calleeType := &c.types[typeIndex]
+		c.emit(newOperationDrop(c.getTailCallDropRange(calleeType, true)))
+		c.emit(newOperationTailCallReturnCallIndirect(typeIndex, tableIndex))
+		// return_call_indirect is stack-polymorphic like return, so mark the state as unreachable.
+		c.markUnreachable()
+	case wasm.OpcodeCallRef:
+		c.emit(
+			newOperationCallRef(index),
+		)
+	case wasm.OpcodeReturnCallRef:
+		calleeType := &c.types[index]
+		c.emit(newOperationDrop(c.getTailCallDropRange(calleeType, true)))
+		c.emit(newOperationTailCallReturnCallRef(index))
+		// return_call_ref is stack-polymorphic like return, so mark the state as unreachable.
+		c.markUnreachable()
 	case wasm.OpcodeDrop:
 		r := inclusiveRange{Start: 0, End: 0}
 		if peekValueType == unsignedTypeV128 {
@@ -832,8 +1008,16 @@ operatorSwitch:
 			newOperationSelect(isTargetVector),
 		)
 	case wasm.OpcodeTypedSelect:
-		// Skips two bytes: vector size fixed to 1, and the value type for select.
+		// Skips the vector size fixed to 1, and the value type for select.
 		c.pc += 2
+		if t := c.body[c.pc]; t == wasm.ValueTypePrefixRefNull || t == wasm.ValueTypePrefixRef {
+			// Typed references are followed by the heap type.
+			_, num, err := leb128.LoadInt64(c.body[c.pc+1:])
+			if err != nil {
+				return fmt.Errorf("failed to read heap type for typed select: %v", err)
+			}
+			c.pc += num
+		}
 		// If it is on the unreachable state, ignore the instruction.
 		if c.unreachableState.on {
 			break operatorSwitch
@@ -844,48 +1028,14 @@ operatorSwitch:
 			newOperationSelect(isTargetVector),
 		)
 	case wasm.OpcodeLocalGet:
-		depth := c.localDepth(index)
-		if isVector := c.localType(index) == wasm.ValueTypeV128; !isVector {
-			c.emit(
-				// -1 because we already manipulated the stack before
-				// called localDepth ^^.
-				newOperationPick(depth-1, isVector),
-			)
-		} else {
-			c.emit(
-				// -2 because we already manipulated the stack before
-				// called localDepth ^^.
-				newOperationPick(depth-2, isVector),
-			)
-		}
+		isVector := c.localType(index) == wasm.ValueTypeV128
+		c.emit(newOperationLocalGet(c.localSlot(index), isVector))
 	case wasm.OpcodeLocalSet:
-		depth := c.localDepth(index)
-
 		isVector := c.localType(index) == wasm.ValueTypeV128
-		if isVector {
-			c.emit(
-				// +2 because we already popped the operands for this operation from the c.stack before
-				// called localDepth ^^,
-				newOperationSet(depth+2, isVector),
-			)
-		} else {
-			c.emit(
-				// +1 because we already popped the operands for this operation from the c.stack before
-				// called localDepth ^^,
-				newOperationSet(depth+1, isVector),
-			)
-		}
+		c.emit(newOperationLocalSet(c.localSlot(index), isVector))
 	case wasm.OpcodeLocalTee:
-		depth := c.localDepth(index)
 		isVector := c.localType(index) == wasm.ValueTypeV128
-		if isVector {
-			c.emit(newOperationPick(1, isVector))
-			c.emit(newOperationSet(depth+2, isVector))
-		} else {
-			c.emit(
-				newOperationPick(0, isVector))
-			c.emit(newOperationSet(depth+1, isVector))
-		}
+		c.emit(newOperationLocalTee(c.localSlot(index), isVector))
 	case wasm.OpcodeGlobalGet:
 		c.emit(
 			newOperationGlobalGet(index),
@@ -1052,15 +1202,21 @@ operatorSwitch:
 		)
 	case wasm.OpcodeMemorySize:
 		c.result.UsesMemory = true
-		c.pc++ // Skip the reserved one byte.
+		memoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemorySizeName)
+		if err != nil {
+			return err
+		}
 		c.emit(
-			newOperationMemorySize(),
+			newOperationMemorySize(memoryIndex),
 		)
 	case wasm.OpcodeMemoryGrow:
 		c.result.UsesMemory = true
-		c.pc++ // Skip the reserved one byte.
+		memoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryGrowName)
+		if err != nil {
+			return err
+		}
 		c.emit(
-			newOperationMemoryGrow(),
+			newOperationMemoryGrow(memoryIndex),
 		)
 	case wasm.OpcodeI32Const:
 		val, num, err := leb128.LoadInt32(c.body[c.pc+1:])
@@ -1615,7 +1771,12 @@ operatorSwitch:
 			newOperationRefFunc(index),
 		)
 	case wasm.OpcodeRefNull:
-		c.pc++ // Skip the type of reftype as every ref value is opaque pointer.
+		// Skip the heap type as every ref value is opaque pointer.
+		_, num, err := leb128.LoadInt64(c.body[c.pc+1:])
+		if err != nil {
+			return fmt.Errorf("failed to read heap type for ref.null: %v", err)
+		}
+		c.pc += num
 		c.emit(
 			newOperationConstI64(0),
 		)
@@ -1624,6 +1785,40 @@ operatorSwitch:
 		c.emit(
 			newOperationEqz(unsignedInt64),
 		)
+	case wasm.OpcodeRefAsNonNull:
+		c.emit(
+			newOperationRefAsNonNull(),
+		)
+	case wasm.OpcodeRefEq:
+		// Simply compare the opaque pointers (i64).
+		c.emit(
+			newOperationEq(unsignedTypeI64),
+		)
+	case wasm.OpcodeBrOnNull, wasm.OpcodeBrOnNonNull:
+		targetIndex, n, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return fmt.Errorf("read the target for %s: %w", wasm.InstructionName(op), err)
+		}
+		c.pc += n
+
+		if c.unreachableState.on {
+			// If it is currently in unreachable, the branch is no-op.
+			break operatorSwitch
+		}
+
+		// Duplicate the reference, and check if it is null.
+		c.emit(newOperationPick(0, false))
+		c.emit(newOperationEqz(unsignedInt64))
+		c.stackPush(unsignedTypeI32)
+		if op == wasm.OpcodeBrOnNull {
+			c.emitBranchOnReference(targetIndex, false)
+		} else {
+			c.emit(newOperationEqz(unsignedInt32))
+			c.emitBranchOnReference(targetIndex, true)
+			// Drop the null reference when not branching.
+			c.emit(newOperationDrop(inclusiveRange{Start: 0, End: 0}))
+			c.stackPop()
+		}
 	case wasm.OpcodeTableGet:
 		c.pc++
 		tableIndex, num, err := leb128.LoadUint32(c.body[c.pc:])
@@ -1644,6 +1839,10 @@ operatorSwitch:
 		c.emit(
 			newOperationTableSet(tableIndex),
 		)
+	case wasm.OpcodeGCPrefix:
+		if err := c.compileGCInstruction(); err != nil {
+			return err
+		}
 	case wasm.OpcodeMiscPrefix:
 		c.pc++
 		// A misc opcode is encoded as an unsigned variable 32-bit integer.
@@ -1691,9 +1890,13 @@ operatorSwitch:
 			if err != nil {
 				return fmt.Errorf("reading i32.const value: %v", err)
 			}
-			c.pc += num + 1 // +1 to skip the memory index which is fixed to zero.
+			c.pc += num
+			memoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryInitName)
+			if err != nil {
+				return err
+			}
 			c.emit(
-				newOperationMemoryInit(dataIndex),
+				newOperationMemoryInit(dataIndex, memoryIndex),
 			)
 		case wasm.OpcodeMiscDataDrop:
 			dataIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -1706,15 +1909,25 @@ operatorSwitch:
 			)
 		case wasm.OpcodeMiscMemoryCopy:
 			c.result.UsesMemory = true
-			c.pc += 2 // +2 to skip two memory indexes which are fixed to zero.
+			dstMemoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryCopyName)
+			if err != nil {
+				return err
+			}
+			srcMemoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryCopyName)
+			if err != nil {
+				return err
+			}
 			c.emit(
-				newOperationMemoryCopy(),
+				newOperationMemoryCopy(dstMemoryIndex, srcMemoryIndex),
 			)
 		case wasm.OpcodeMiscMemoryFill:
 			c.result.UsesMemory = true
-			c.pc += 1 // +1 to skip the memory index which is fixed to zero.
+			memoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryFillName)
+			if err != nil {
+				return err
+			}
 			c.emit(
-				newOperationMemoryFill(),
+				newOperationMemoryFill(memoryIndex),
 			)
 		case wasm.OpcodeMiscTableInit:
 			elemIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -1791,6 +2004,13 @@ operatorSwitch:
 		}
 	case wasm.OpcodeVecPrefix:
 		c.pc++
+		if relaxedOp, ok := wasm.RelaxedVectorOpcode(c.body[c.pc:]); ok {
+			c.pc++ // Skip the second byte of the opcode.
+			if err := c.compileRelaxedVectorInstruction(relaxedOp); err != nil {
+				return err
+			}
+			break
+		}
 		switch vecOp := c.body[c.pc]; vecOp {
 		case wasm.OpcodeVecV128Const:
 			c.pc++
@@ -3432,6 +3652,51 @@ operatorSwitch:
 	return nil
 }
 
+// compileRelaxedVectorInstruction emits the operations of the relaxed vector instruction of the given opcode.
+// The result of each is the one of the deterministic choices described in experimental.CoreFeaturesRelaxedSIMD.
+func (c *compiler) compileRelaxedVectorInstruction(op wasm.OpcodeVecRelaxed) error {
+	switch op {
+	case wasm.OpcodeVecI8x16RelaxedSwizzle:
+		c.emit(newOperationV128Swizzle())
+	case wasm.OpcodeVecI32x4RelaxedTruncF32x4S:
+		c.emit(newOperationV128ITruncSatFromF(shapeF32x4, true))
+	case wasm.OpcodeVecI32x4RelaxedTruncF32x4U:
+		c.emit(newOperationV128ITruncSatFromF(shapeF32x4, false))
+	case wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero:
+		c.emit(newOperationV128ITruncSatFromF(shapeF64x2, true))
+	case wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero:
+		c.emit(newOperationV128ITruncSatFromF(shapeF64x2, false))
+	case wasm.OpcodeVecF32x4RelaxedMadd:
+		c.emit(newOperationV128RelaxedMadd(shapeF32x4, false))
+	case wasm.OpcodeVecF32x4RelaxedNmadd:
+		c.emit(newOperationV128RelaxedMadd(shapeF32x4, true))
+	case wasm.OpcodeVecF64x2RelaxedMadd:
+		c.emit(newOperationV128RelaxedMadd(shapeF64x2, false))
+	case wasm.OpcodeVecF64x2RelaxedNmadd:
+		c.emit(newOperationV128RelaxedMadd(shapeF64x2, true))
+	case wasm.OpcodeVecI8x16RelaxedLaneselect, wasm.OpcodeVecI16x8RelaxedLaneselect,
+		wasm.OpcodeVecI32x4RelaxedLaneselect, wasm.OpcodeVecI64x2RelaxedLaneselect:
+		c.emit(newOperationV128Bitselect())
+	case wasm.OpcodeVecF32x4RelaxedMin:
+		c.emit(newOperationV128Min(shapeF32x4, false))
+	case wasm.OpcodeVecF32x4RelaxedMax:
+		c.emit(newOperationV128Max(shapeF32x4, false))
+	case wasm.OpcodeVecF64x2RelaxedMin:
+		c.emit(newOperationV128Min(shapeF64x2, false))
+	case wasm.OpcodeVecF64x2RelaxedMax:
+		c.emit(newOperationV128Max(shapeF64x2, false))
+	case wasm.OpcodeVecI16x8RelaxedQ15mulrS:
+		c.emit(newOperationV128Q15mulrSatS())
+	case wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+		c.emit(newOperationV128RelaxedDot(false))
+	case wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS:
+		c.emit(newOperationV128RelaxedDot(true))
+	default:
+		return fmt.Errorf("unsupported relaxed vector instruction in interpreterir: %#x", op)
+	}
+	return nil
+}
+
 func (c *compiler) nextFrameID() (id uint32) {
 	id = c.currentFrameID + 1
 	c.currentFrameID++
@@ -3445,6 +3710,11 @@ func (c *compiler) applyToStack(opcode wasm.Opcode) (index uint32, err error) {
 		// and it DOES affect the signature of opcode.
 		wasm.OpcodeCall,
 		wasm.OpcodeCallIndirect,
+		wasm.OpcodeTailCallReturnCall,
+		wasm.OpcodeTailCallReturnCallIndirect,
+		wasm.OpcodeCallRef,
+		wasm.OpcodeReturnCallRef,
+		wasm.OpcodeThrow,
 		wasm.OpcodeLocalGet,
 		wasm.OpcodeLocalSet,
 		wasm.OpcodeLocalTee,
@@ -3473,6 +3743,9 @@ func (c *compiler) applyToStack(opcode wasm.Opcode) (index uint32, err error) {
 	if err != nil {
 		return 0, err
 	}
+	if c.hasMemory64 {
+		s = c.memory64Signature(opcode, s)
+	}
 
 	// Manipulate the stack according to the signature.
 	// Note that the following algorithm assumes that
@@ -3548,12 +3821,211 @@ func (c *compiler) emit(op unionOperation) {
 				return
 			}
 		}
-		c.result.Operations = append(c.result.Operations, op)
 		if c.needSourceOffset {
+			// Each operation keeps the source offset of its own instruction, so nothing is fused.
 			c.result.IROperationSourceOffsetsInWasmBinary = append(c.result.IROperationSourceOffsetsInWasmBinary,
 				c.currentOpPC+c.bodyOffsetInCodeSection)
+		} else if fused, n := c.fuse(op); n > 0 {
+			// The fused operation replaces the last n operations.
+			op = fused
+			c.result.Operations = c.result.Operations[:len(c.result.Operations)-n]
+		}
+		c.result.Operations = append(c.result.Operations, op)
+	}
+}
+
+// fuse tries to fuse op with the operations emitted last into a superinstruction, which operates on the register slots
+// of locals directly instead of going through the value stack. It returns the superinstruction and the number of the
+// last operations replaced by it, or zero if op cannot be fused.
+//
+// Only the operations after fusionBarrier are fused, as the indexes of the operations before it are already recorded.
+// Labels are never fused, so the branch targets are preserved. The superinstruction spans several instructions, so
+// this is not called when the source offset of each instruction is needed, e.g. by the StackIterator or the debugger.
+func (c *compiler) fuse(op unionOperation) (fused unionOperation, n int) {
+	ops := c.result.Operations[c.fusionBarrier:]
+	last := func(i int) *unionOperation {
+		if i > len(ops) {
+			return nil
+		}
+		return &ops[len(ops)-i]
+	}
+	isLocalGet := func(o *unionOperation) bool {
+		return o != nil && o.Kind == operationKindLocalGet && !o.B3
+	}
+	isConst := func(o *unionOperation, typ unsignedType) bool {
+		return o != nil && (typ == unsignedTypeI32 && o.Kind == operationKindConstI32 ||
+			typ == unsignedTypeI64 && o.Kind == operationKindConstI64)
+	}
+
+	if a, typ, ok := arithOpOf(op); ok {
+		x1, x2 := last(2), last(1)
+		if isLocalGet(x1) && isConst(x2, typ) {
+			// local.get x1; const c; add (or any other arithOp)
+			return newOperationArithLocalConst(typ, a, int(x1.U1), x2.U1, -1), 2
+		} else if isLocalGet(x1) && isLocalGet(x2) {
+			// local.get x1; local.get x2; add
+			return newOperationArithLocals(typ, a, int(x1.U1), int(x2.U1), -1), 2
+		} else if isLocalGet(x2) {
+			// local.get x2; add
+			return newOperationArithLocal(typ, a, int(x2.U1), -1), 1
+		}
+		return
+	}
+	if cmp, typ, ok := compareOpOf(op); ok {
+		x1, x2 := last(2), last(1)
+		if op.Kind == operationKindEqz {
+			if isLocalGet(x2) {
+				// local.get x; eqz
+				return newOperationCompareLocalConst(typ, compareOpEq, int(x2.U1), 0), 1
+			}
+		} else if isLocalGet(x1) && isConst(x2, typ) {
+			// local.get x1; const c; lt_s (or any other compareOp)
+			return newOperationCompareLocalConst(typ, cmp, int(x1.U1), x2.U1), 2
+		} else if isLocalGet(x1) && isLocalGet(x2) {
+			// local.get x1; local.get x2; lt_s
+			return newOperationCompareLocals(typ, cmp, int(x1.U1), int(x2.U1)), 2
 		}
+		return
 	}
+
+	switch op.Kind {
+	case operationKindLocalSet:
+		if op.B3 {
+			return
+		}
+		if x := last(1); x != nil && !x.B3 {
+			// Stores the result of the superinstruction into the local directly instead of pushing it.
+			switch x.Kind {
+			case operationKindArithLocal, operationKindArithLocals, operationKindArithLocalConst:
+				fused = *x
+				fused.B3, fused.U3 = true, op.U1
+				return fused, 1
+			}
+		}
+	case operationKindBrIf:
+		x := last(1)
+		if x == nil {
+			return
+		}
+		thenTarget, elseTarget, thenDrop := label(op.U1), label(op.U2), inclusiveRangeFromU64(op.U3)
+		switch x.Kind {
+		case operationKindCompareLocals:
+			// The condition is computed from the locals without being pushed.
+			return newOperationBrIfCompareLocals(unsignedType(x.B1), compareOp(x.B2), int(x.U1), int(x.U2),
+				thenTarget, elseTarget, thenDrop), 1
+		case operationKindCompareLocalConst:
+			return newOperationBrIfCompareLocalConst(unsignedType(x.B1), compareOp(x.B2), int(x.U1), x.U2,
+				thenTarget, elseTarget, thenDrop), 1
+		case operationKindEqz:
+			// eqz; br_if branches if the value is zero.
+			fused = op
+			fused.B3 = true
+			return fused, 1
+		default:
+			if cmp, typ, ok := compareOpOf(*x); ok {
+				// The operands of the comparison are popped by the branch.
+				return newOperationBrIfCompare(typ, cmp, thenTarget, elseTarget, thenDrop), 1
+			}
+		}
+	}
+	return
+}
+
+// arithOpOf returns the arithOp of the binary integer operation op which can be fused into a superinstruction.
+func arithOpOf(op unionOperation) (a arithOp, typ unsignedType, ok bool) {
+	switch op.Kind {
+	case operationKindAdd, operationKindSub, operationKindMul:
+		typ = unsignedType(op.B1)
+		if typ != unsignedTypeI32 && typ != unsignedTypeI64 {
+			return
+		}
+		switch op.Kind {
+		case operationKindAdd:
+			a = arithOpAdd
+		case operationKindSub:
+			a = arithOpSub
+		default:
+			a = arithOpMul
+		}
+	case operationKindAnd, operationKindOr, operationKindXor, operationKindShl:
+		if unsignedInt(op.B1) == unsignedInt64 {
+			typ = unsignedTypeI64
+		}
+		switch op.Kind {
+		case operationKindAnd:
+			a = arithOpAnd
+		case operationKindOr:
+			a = arithOpOr
+		case operationKindXor:
+			a = arithOpXor
+		default:
+			a = arithOpShl
+		}
+	case operationKindShr:
+		switch signedInt(op.B1) {
+		case signedInt32:
+			a = arithOpShrS
+		case signedInt64:
+			typ, a = unsignedTypeI64, arithOpShrS
+		case signedUint32:
+			a = arithOpShrU
+		case signedUint64:
+			typ, a = unsignedTypeI64, arithOpShrU
+		}
+	default:
+		return
+	}
+	return a, typ, true
+}
+
+// compareOpOf returns the compareOp of the integer comparison op which can be fused into a superinstruction. eqz is
+// returned as compareOpEq, as it is the equality with zero.
+func compareOpOf(op unionOperation) (c compareOp, typ unsignedType, ok bool) {
+	switch op.Kind {
+	case operationKindEq, operationKindNe:
+		typ = unsignedType(op.B1)
+		if typ != unsignedTypeI32 && typ != unsignedTypeI64 {
+			return
+		}
+		if c = compareOpEq; op.Kind == operationKindNe {
+			c = compareOpNe
+		}
+		return c, typ, true
+	case operationKindEqz:
+		if unsignedInt(op.B1) == unsignedInt64 {
+			typ = unsignedTypeI64
+		}
+		return compareOpEq, typ, true
+	case operationKindLt, operationKindGt, operationKindLe, operationKindGe:
+		var signed bool
+		switch signedType(op.B1) {
+		case signedTypeInt32:
+			signed = true
+		case signedTypeUint32:
+		case signedTypeInt64:
+			typ, signed = unsignedTypeI64, true
+		case signedTypeUint64:
+			typ = unsignedTypeI64
+		default:
+			return
+		}
+		switch op.Kind {
+		case operationKindLt:
+			c = compareOpLtU
+		case operationKindGt:
+			c = compareOpGtU
+		case operationKindLe:
+			c = compareOpLeU
+		default:
+			c = compareOpGeU
+		}
+		if signed {
+			// The signed variant of each comparison precedes the unsigned one.
+			c--
+		}
+		return c, typ, true
+	}
+	return
 }
 
 // Emit const expression with default values of the given type.
@@ -3562,7 +4034,9 @@ func (c *compiler) emitDefaultValue(t wasm.ValueType) {
 	case wasm.ValueTypeI32:
 		c.stackPush(unsignedTypeI32)
 		c.emit(newOperationConstI32(0))
-	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref,
+		wasm.ValueTypeAnyref, wasm.ValueTypeEqref, wasm.ValueTypeI31ref, wasm.ValueTypeStructref, wasm.ValueTypeArrayref,
+		wasm.ValueTypeNullref, wasm.ValueTypeNullexternref, wasm.ValueTypeNullfuncref:
 		c.stackPush(unsignedTypeI64)
 		c.emit(newOperationConstI64(0))
 	case wasm.ValueTypeF32:
@@ -3577,11 +4051,10 @@ func (c *compiler) emitDefaultValue(t wasm.ValueType) {
 	}
 }
 
-// Returns the "depth" (starting from top of the stack)
-// of the n-th local.
-func (c *compiler) localDepth(index wasm.Index) int {
-	height := c.localIndexToStackHeightInUint64[index]
-	return c.stackLenInUint64 - 1 - height
+// localSlot returns the register slot of the n-th local, which is the location in the uint64 value stack counted from
+// the first param of the function.
+func (c *compiler) localSlot(index wasm.Index) int {
+	return c.localIndexToStackHeightInUint64[index]
 }
 
 func (c *compiler) localType(index wasm.Index) (t wasm.ValueType) {
@@ -3616,6 +4089,279 @@ func (c *compiler) getFrameDropRange(frame *controlFrame, isEnd bool) inclusiveR
 	}
 }
 
+// compileGCInstruction lowers the instruction prefixed by wasm.OpcodeGCPrefix at c.pc, and advances c.pc to its last
+// byte. As the stack effects depend on the immediates, c.stack is manipulated here instead of in applyToStack.
+func (c *compiler) compileGCInstruction() error {
+	gcOp, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+	if err != nil {
+		return fmt.Errorf("failed to read gc opcode: %v", err)
+	}
+	c.pc += num
+	readIndex := func() (uint32, error) {
+		v, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return 0, fmt.Errorf("read immediate of %s: %v", wasm.GCInstructionName(gcOp), err)
+		}
+		c.pc += num
+		return v, nil
+	}
+	readHeapType := func() (int64, error) {
+		v, num, err := leb128.LoadInt64(c.body[c.pc+1:])
+		if err != nil {
+			return 0, fmt.Errorf("read heap type of %s: %v", wasm.GCInstructionName(gcOp), err)
+		}
+		c.pc += num
+		return v, nil
+	}
+
+	// Read the immediates first, as c.pc must be advanced even in the unreachable state.
+	var typeIndex, index uint32
+	var heapType int64
+	var flags byte
+	switch gcOp {
+	case wasm.OpcodeGCStructNew, wasm.OpcodeGCStructNewDefault, wasm.OpcodeGCArrayNew, wasm.OpcodeGCArrayNewDefault,
+		wasm.OpcodeGCArrayGet, wasm.OpcodeGCArrayGetS, wasm.OpcodeGCArrayGetU, wasm.OpcodeGCArraySet, wasm.OpcodeGCArrayFill:
+		typeIndex, err = readIndex()
+	case wasm.OpcodeGCStructGet, wasm.OpcodeGCStructGetS, wasm.OpcodeGCStructGetU, wasm.OpcodeGCStructSet,
+		wasm.OpcodeGCArrayNewFixed, wasm.OpcodeGCArrayNewData, wasm.OpcodeGCArrayNewElem, wasm.OpcodeGCArrayCopy,
+		wasm.OpcodeGCArrayInitData, wasm.OpcodeGCArrayInitElem:
+		if typeIndex, err = readIndex(); err == nil {
+			index, err = readIndex()
+		}
+	case wasm.OpcodeGCRefTest, wasm.OpcodeGCRefTestNull, wasm.OpcodeGCRefCast, wasm.OpcodeGCRefCastNull:
+		heapType, err = readHeapType()
+	case wasm.OpcodeGCBrOnCast, wasm.OpcodeGCBrOnCastFail:
+		c.pc++
+		flags = c.body[c.pc]
+		if index, err = readIndex(); err == nil {
+			// Only the target type matters at runtime.
+			if _, err = readHeapType(); err == nil {
+				heapType, err = readHeapType()
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.unreachableState.on {
+		return nil
+	}
+
+	pop := func(n int) {
+		for i := 0; i < n; i++ {
+			c.stackPop()
+		}
+	}
+	pushField := func(f *wasm.FieldType) {
+		c.stackPush(wasmValueTypeTounsignedType(f.ValueType()))
+	}
+	switch gcOp {
+	case wasm.OpcodeGCStructNew, wasm.OpcodeGCStructNewDefault:
+		isDefault := gcOp == wasm.OpcodeGCStructNewDefault
+		if !isDefault {
+			pop(len(c.module.CompositeTypes[typeIndex].Fields))
+		}
+		c.stackPush(unsignedTypeI64)
+		c.emit(newOperationStructNew(typeIndex, isDefault))
+	case wasm.OpcodeGCStructGet, wasm.OpcodeGCStructGetS, wasm.OpcodeGCStructGetU:
+		pop(1)
+		pushField(&c.module.CompositeTypes[typeIndex].Fields[index])
+		c.emit(newOperationStructGet(typeIndex, index, gcOp == wasm.OpcodeGCStructGetS))
+	case wasm.OpcodeGCStructSet:
+		pop(2)
+		c.emit(newOperationStructSet(typeIndex, index))
+	case wasm.OpcodeGCArrayNew, wasm.OpcodeGCArrayNewDefault:
+		isDefault := gcOp == wasm.OpcodeGCArrayNewDefault
+		if isDefault {
+			pop(1)
+		} else {
+			pop(2)
+		}
+		c.stackPush(unsignedTypeI64)
+		c.emit(newOperationArrayNew(typeIndex, isDefault))
+	case wasm.OpcodeGCArrayNewFixed:
+		pop(int(index))
+		c.stackPush(unsignedTypeI64)
+		c.emit(newOperationArrayNewFixed(typeIndex, index))
+	case wasm.OpcodeGCArrayNewData, wasm.OpcodeGCArrayNewElem:
+		pop(2)
+		c.stackPush(unsignedTypeI64)
+		if gcOp == wasm.OpcodeGCArrayNewData {
+			c.emit(newOperationArrayNewData(typeIndex, index))
+		} else {
+			c.emit(newOperationArrayNewElem(typeIndex, index))
+		}
+	case wasm.OpcodeGCArrayGet, wasm.OpcodeGCArrayGetS, wasm.OpcodeGCArrayGetU:
+		pop(2)
+		pushField(&c.module.CompositeTypes[typeIndex].Fields[0])
+		c.emit(newOperationArrayGet(typeIndex, gcOp == wasm.OpcodeGCArrayGetS))
+	case wasm.OpcodeGCArraySet:
+		pop(3)
+		c.emit(newOperationArraySet(typeIndex))
+	case wasm.OpcodeGCArrayLen:
+		pop(1)
+		c.stackPush(unsignedTypeI32)
+		c.emit(newOperationArrayLen())
+	case wasm.OpcodeGCArrayFill:
+		pop(4)
+		c.emit(newOperationArrayFill(typeIndex))
+	case wasm.OpcodeGCArrayCopy:
+		pop(5)
+		c.emit(newOperationArrayCopy(typeIndex, index))
+	case wasm.OpcodeGCArrayInitData, wasm.OpcodeGCArrayInitElem:
+		pop(4)
+		if gcOp == wasm.OpcodeGCArrayInitData {
+			c.emit(newOperationArrayInitData(typeIndex, index))
+		} else {
+			c.emit(newOperationArrayInitElem(typeIndex, index))
+		}
+	case wasm.OpcodeGCRefTest, wasm.OpcodeGCRefTestNull:
+		pop(1)
+		c.stackPush(unsignedTypeI32)
+		c.emit(newOperationRefTest(heapType, gcOp == wasm.OpcodeGCRefTestNull))
+	case wasm.OpcodeGCRefCast, wasm.OpcodeGCRefCastNull:
+		c.emit(newOperationRefCast(heapType, gcOp == wasm.OpcodeGCRefCastNull))
+	case wasm.OpcodeGCBrOnCast, wasm.OpcodeGCBrOnCastFail:
+		// Duplicate the reference, and test if it is an instance of the target type, whose nullability is the
+		// second bit of the flags.
+		c.emit(newOperationPick(0, false))
+		c.emit(newOperationRefTest(heapType, flags&0b10 != 0))
+		c.stackPush(unsignedTypeI32)
+		if gcOp == wasm.OpcodeGCBrOnCastFail {
+			c.emit(newOperationEqz(unsignedInt32))
+		}
+		c.emitBranchOnReference(index, true)
+	case wasm.OpcodeGCAnyConvertExtern:
+		c.emit(newOperationAnyConvertExtern())
+	case wasm.OpcodeGCExternConvertAny:
+		c.emit(newOperationExternConvertAny())
+	case wasm.OpcodeGCRefI31:
+		pop(1)
+		c.stackPush(unsignedTypeI64)
+		c.emit(newOperationRefI31())
+	case wasm.OpcodeGCI31GetS, wasm.OpcodeGCI31GetU:
+		pop(1)
+		c.stackPush(unsignedTypeI32)
+		c.emit(newOperationI31Get(gcOp == wasm.OpcodeGCI31GetS))
+	default:
+		return fmt.Errorf("unsupported gc instruction in interpreterir: %s", wasm.GCInstructionName(gcOp))
+	}
+	return nil
+}
+
+// emitBranchOnReference emits the conditional branch of br_on_null, br_on_non_null, br_on_cast and br_on_cast_fail
+// to the target frame, where the condition is on the top of the stack above the reference. The reference is passed to
+// the target as the last value if passReference is true, and dropped otherwise. In both cases, the reference remains
+// on the stack when not branching.
+func (c *compiler) emitBranchOnReference(targetIndex uint32, passReference bool) {
+	c.stackPop() // The condition.
+
+	targetFrame := c.controlFrames.get(int(targetIndex))
+	targetFrame.ensureContinuation()
+	continuationLabel := newLabel(labelKindHeader, c.nextFrameID())
+	c.result.LabelCallers[continuationLabel]++
+	if passReference {
+		drop := c.getFrameDropRange(targetFrame, false)
+		target := targetFrame.asLabel()
+		c.result.LabelCallers[target]++
+		c.emit(newOperationBrIf(target, continuationLabel, drop))
+	} else {
+		// The reference must be dropped before dropping the values below the results of the target, so branch
+		// into the intermediate block which does both.
+		thenLabel := newLabel(labelKindHeader, c.nextFrameID())
+		c.result.LabelCallers[thenLabel]++
+		c.emit(newOperationBrIf(thenLabel, continuationLabel, nopinclusiveRange))
+		c.emit(newOperationLabel(thenLabel))
+
+		c.emit(newOperationDrop(inclusiveRange{Start: 0, End: 0}))
+		c.stackPop()
+		drop := c.getFrameDropRange(targetFrame, false)
+		target := targetFrame.asLabel()
+		c.result.LabelCallers[target]++
+		c.emit(newOperationDrop(drop))
+		c.emit(newOperationBr(target))
+		c.stackPush(unsignedTypeI64)
+	}
+	c.emit(newOperationLabel(continuationLabel))
+}
+
+// getTailCallDropRange returns the range of the stack to be dropped before a tail call so that only
+// the callee's params (and the table offset for the indirect variant) remain above the current function frame.
+//
+// Note: this must be called after applyToStack, which already replaced the params with the callee's results.
+func (c *compiler) getTailCallDropRange(callee *wasm.FunctionType, indirect bool) inclusiveRange {
+	keep := callee.ParamNumInUint64
+	if indirect {
+		keep++ // The offset in the table.
+	}
+	stackLenBeforeCall := c.stackLenInUint64 - callee.ResultNumInUint64 + keep
+	end := stackLenBeforeCall - 1 - c.controlFrames.functionFrame().originalStackLenWithoutParamUint64
+	if keep <= end {
+		return inclusiveRange{Start: int32(keep), End: int32(end)}
+	} else {
+		return nopinclusiveRange
+	}
+}
+
+// emitCatchPads emits the operations for the catch clauses of the try_table frame which has just ended, and records
+// them as an exceptionHandler. Each clause starts with the stack at the height when entering the try_table plus the
+// values pushed by the clause, and branches to its target label.
+//
+// The stack is left as the continuation of the try_table expects.
+func (c *compiler) emitCatchPads(frame *controlFrame) {
+	if len(frame.catches) == 0 {
+		return
+	}
+
+	c.fusionBarrier = len(c.result.Operations)
+	h := exceptionHandler{
+		start:       uint64(frame.handlerStart),
+		end:         uint64(len(c.result.Operations)),
+		stackHeight: frame.originalStackLenWithoutParamUint64,
+		catches:     make([]exceptionCatch, 0, len(frame.catches)),
+	}
+	for i := range frame.catches {
+		clause := &frame.catches[i]
+		pad := newLabel(labelKindHeader, c.nextFrameID())
+		c.result.LabelCallers[pad]++
+		c.emit(newOperationLabel(pad))
+
+		c.stackSwitchAt(frame)
+		catch := exceptionCatch{tag: clause.Tag, pad: uint64(pad)}
+		switch clause.Kind {
+		case wasm.CatchKindCatch, wasm.CatchKindCatchRef:
+			for _, t := range c.module.TagType(clause.Tag).Params {
+				c.stackPush(wasmValueTypeTounsignedType(t))
+			}
+			catch.ref = clause.Kind == wasm.CatchKindCatchRef
+		case wasm.CatchKindCatchAll:
+			catch.all = true
+		case wasm.CatchKindCatchAllRef:
+			catch.all, catch.ref = true, true
+		}
+		if catch.ref {
+			c.stackPush(unsignedTypeI64)
+		}
+
+		// The labels of catch clauses are relative to the outside of the try_table which is already popped.
+		targetFrame := c.controlFrames.get(int(clause.Label))
+		targetFrame.ensureContinuation()
+		dropOp := newOperationDrop(c.getFrameDropRange(targetFrame, false))
+		target := targetFrame.asLabel()
+		c.result.LabelCallers[target]++
+		c.emit(dropOp)
+		c.emit(newOperationBr(target))
+		h.catches = append(h.catches, catch)
+	}
+	c.result.ExceptionHandlers = append(c.result.ExceptionHandlers, h)
+
+	c.stackSwitchAt(frame)
+	for _, t := range frame.blockType.Results {
+		c.stackPush(wasmValueTypeTounsignedType(t))
+	}
+}
+
 func (c *compiler) readMemoryArg(tag string) (memoryArg, error) {
 	c.result.UsesMemory = true
 	alignment, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -3623,10 +4369,36 @@ func (c *compiler) readMemoryArg(tag string) (memoryArg, error) {
 		return memoryArg{}, fmt.Errorf("reading alignment for %s: %w", tag, err)
 	}
 	c.pc += num
-	offset, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+	var memoryIndex uint32
+	if alignment&wasm.MemArgMemoryIndexFlag != 0 && c.enabledFeatures.IsEnabled(experimental.CoreFeaturesMultiMemory) {
+		alignment &^= wasm.MemArgMemoryIndexFlag
+		memoryIndex, num, err = leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return memoryArg{}, fmt.Errorf("reading memory index for %s: %w", tag, err)
+		}
+		c.pc += num
+	}
+	var offset uint64
+	if int(memoryIndex) < len(c.memories) && c.memories[memoryIndex].Is64 {
+		offset, num, err = leb128.LoadUint64(c.body[c.pc+1:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(c.body[c.pc+1:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		return memoryArg{}, fmt.Errorf("reading offset for %s: %w", tag, err)
 	}
 	c.pc += num
-	return memoryArg{Offset: offset, Alignment: alignment}, nil
+	return memoryArg{Offset: offset, Alignment: alignment, MemoryIndex: memoryIndex}, nil
+}
+
+// readMemoryIndex reads the memory index immediate of memory.size, memory.grow and bulk memory instructions.
+func (c *compiler) readMemoryIndex(tag string) (uint32, error) {
+	memoryIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+	if err != nil {
+		return 0, fmt.Errorf("reading memory index for %s: %w", tag, err)
+	}
+	c.pc += num
+	return memoryIndex, nil
 }
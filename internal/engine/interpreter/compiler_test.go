@@ -6,9 +6,11 @@ import (
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/coverage"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
 )
 
 var (
@@ -80,7 +82,7 @@ func TestCompile(t *testing.T) {
 			},
 			expected: &compilationResult{
 				Operations: []unionOperation{ // begin with params: [$x]
-					newOperationPick(0, false),                         // [$x, $x]
+					newOperationLocalGet(0, false),                     // [$x, $x]
 					newOperationDrop(inclusiveRange{Start: 1, End: 1}), // [$x]
 					newOperationBr(newLabel(labelKindReturn, 0)),       // return!
 				},
@@ -156,8 +158,8 @@ func TestCompile(t *testing.T) {
 			},
 			expected: &compilationResult{
 				Operations: []unionOperation{ // begin with params: [$delta]
-					newOperationPick(0, false),                         // [$delta, $delta]
-					newOperationMemoryGrow(),                           // [$delta, $old_size]
+					newOperationLocalGet(0, false),                     // [$delta, $delta]
+					newOperationMemoryGrow(0),                          // [$delta, $old_size]
 					newOperationDrop(inclusiveRange{Start: 1, End: 1}), // [$old_size]
 					newOperationBr(newLabel(labelKindReturn, 0)),       // return!
 				},
@@ -267,7 +269,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 	module := &wasm.Module{
 		TypeSection:     []wasm.FunctionType{v_v},
 		FunctionSection: []wasm.Index{0},
-		MemorySection:   &wasm.Memory{Min: 1},
+		MemorySection:   []wasm.Memory{{Min: 1}},
 		DataSection: []wasm.DataSegment{
 			{
 				OffsetExpression: wasm.ConstantExpression{
@@ -297,7 +299,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 			newOperationConstI32(16),                     // [16]
 			newOperationConstI32(0),                      // [16, 0]
 			newOperationConstI32(7),                      // [16, 0, 7]
-			newOperationMemoryInit(1),                    // []
+			newOperationMemoryInit(1, 0),                 // []
 			newOperationDataDrop(1),                      // []
 			newOperationBr(newLabel(labelKindReturn, 0)), // return!
 		},
@@ -348,8 +350,8 @@ func TestCompile_MultiValue(t *testing.T) {
 			},
 			expected: &compilationResult{
 				Operations: []unionOperation{ // begin with params: [$x, $y]
-					newOperationPick(0, false),                         // [$x, $y, $y]
-					newOperationPick(2, false),                         // [$x, $y, $y, $x]
+					newOperationLocalGet(1, false),                     // [$x, $y, $y]
+					newOperationLocalGet(0, false),                     // [$x, $y, $y, $x]
 					newOperationDrop(inclusiveRange{Start: 2, End: 3}), // [$y, $x]
 					newOperationBr(newLabel(labelKindReturn, 0)),       // return!
 				},
@@ -440,8 +442,8 @@ func TestCompile_MultiValue(t *testing.T) {
 			//	)
 			expected: &compilationResult{
 				Operations: []unionOperation{ // begin with params: [$0]
-					newOperationConstI32(1),    // [$0, 1]
-					newOperationPick(1, false), // [$0, 1, $0]
+					newOperationConstI32(1),        // [$0, 1]
+					newOperationLocalGet(0, false), // [$0, 1, $0]
 					newOperationBrIf( // [$0, 1]
 						newLabel(labelKindHeader, 2),
 						newLabel(labelKindElse, 2),
@@ -497,9 +499,9 @@ func TestCompile_MultiValue(t *testing.T) {
 			//	)
 			expected: &compilationResult{
 				Operations: []unionOperation{ // begin with params: [$0]
-					newOperationConstI32(1),    // [$0, 1]
-					newOperationConstI32(2),    // [$0, 1, 2]
-					newOperationPick(2, false), // [$0, 1, 2, $0]
+					newOperationConstI32(1),        // [$0, 1]
+					newOperationConstI32(2),        // [$0, 1, 2]
+					newOperationLocalGet(0, false), // [$0, 1, 2, $0]
 					newOperationBrIf( // [$0, 1, 2]
 						newLabel(labelKindHeader, 2),
 						newLabel(labelKindElse, 2),
@@ -553,9 +555,9 @@ func TestCompile_MultiValue(t *testing.T) {
 			//	)
 			expected: &compilationResult{
 				Operations: []unionOperation{ // begin with params: [$0]
-					newOperationConstI32(1),    // [$0, 1]
-					newOperationConstI32(2),    // [$0, 1, 2]
-					newOperationPick(2, false), // [$0, 1, 2, $0]
+					newOperationConstI32(1),        // [$0, 1]
+					newOperationConstI32(2),        // [$0, 1, 2]
+					newOperationLocalGet(0, false), // [$0, 1, 2, $0]
 					newOperationBrIf( // [$0, 1, 2]
 						newLabel(labelKindHeader, 2),
 						newLabel(labelKindElse, 2),
@@ -616,7 +618,7 @@ func TestCompile_NonTrappingFloatToIntConversion(t *testing.T) {
 
 	expected := &compilationResult{
 		Operations: []unionOperation{ // begin with params: [$0]
-			newOperationPick(0, false), // [$0, $0]
+			newOperationLocalGet(0, false), // [$0, $0]
 			newOperationITruncFromF( // [$0, i32.trunc_sat_f32_s($0)]
 				f32,
 				signedInt32,
@@ -649,7 +651,7 @@ func TestCompile_SignExtensionOps(t *testing.T) {
 
 	expected := &compilationResult{
 		Operations: []unionOperation{ // begin with params: [$0]
-			newOperationPick(0, false),                         // [$0, $0]
+			newOperationLocalGet(0, false),                     // [$0, $0]
 			newOperationSignExtend32From8(),                    // [$0, i32.extend8_s($0)]
 			newOperationDrop(inclusiveRange{Start: 1, End: 1}), // [i32.extend8_s($0)]
 			newOperationBr(newLabel(labelKindReturn, 0)),       // return!
@@ -978,7 +980,7 @@ func TestCompile_Locals(t *testing.T) {
 				}}},
 			},
 			expected: []unionOperation{
-				newOperationPick(1, true), // [param[0].low, param[0].high] -> [param[0].low, param[0].high, param[0].low, param[0].high]
+				newOperationLocalGet(0, true), // [param[0].low, param[0].high] -> [param[0].low, param[0].high, param[0].low, param[0].high]
 				newOperationDrop(inclusiveRange{Start: 0, End: 3}),
 				newOperationBr(newLabel(labelKindReturn, 0)), // return!
 			},
@@ -994,7 +996,7 @@ func TestCompile_Locals(t *testing.T) {
 				}}},
 			},
 			expected: []unionOperation{
-				newOperationPick(0, false), // [param[0]] -> [param[0], param[0]]
+				newOperationLocalGet(0, false), // [param[0]] -> [param[0], param[0]]
 				newOperationDrop(inclusiveRange{Start: 0, End: 1}),
 				newOperationBr(newLabel(labelKindReturn, 0)), // return!
 			},
@@ -1014,7 +1016,7 @@ func TestCompile_Locals(t *testing.T) {
 			},
 			expected: []unionOperation{
 				newOperationV128Const(0, 0),
-				newOperationPick(1, true), // [p[0].low, p[0].high] -> [p[0].low, p[0].high, p[0].low, p[0].high]
+				newOperationLocalGet(0, true), // [p[0].low, p[0].high] -> [p[0].low, p[0].high, p[0].low, p[0].high]
 				newOperationDrop(inclusiveRange{Start: 0, End: 3}),
 				newOperationBr(newLabel(labelKindReturn, 0)), // return!
 			},
@@ -1036,7 +1038,7 @@ func TestCompile_Locals(t *testing.T) {
 				// [p[0].lo, p[1].hi] -> [p[0].lo, p[1].hi, 0x01, 0x02]
 				newOperationV128Const(0x01, 0x02),
 				// [p[0].lo, p[1].hi, 0x01, 0x02] -> [0x01, 0x02]
-				newOperationSet(3, true),
+				newOperationLocalSet(0, true),
 				newOperationDrop(inclusiveRange{Start: 0, End: 1}),
 				newOperationBr(newLabel(labelKindReturn, 0)), // return!
 			},
@@ -1054,7 +1056,7 @@ func TestCompile_Locals(t *testing.T) {
 			},
 			expected: []unionOperation{
 				newOperationConstI32(0x1),
-				newOperationSet(1, false),
+				newOperationLocalSet(0, false),
 				newOperationDrop(inclusiveRange{Start: 0, End: 0}),
 				newOperationBr(newLabel(labelKindReturn, 0)), // return!
 			},
@@ -1080,7 +1082,7 @@ func TestCompile_Locals(t *testing.T) {
 				// [p[0].lo, p[1].hi] -> [p[0].lo, p[1].hi, 0x01, 0x02]
 				newOperationV128Const(0x01, 0x02),
 				// [p[0].lo, p[1].hi, 0x01, 0x02] -> [0x01, 0x02]
-				newOperationSet(3, true),
+				newOperationLocalSet(0, true),
 				newOperationDrop(inclusiveRange{Start: 0, End: 1}),
 				newOperationBr(newLabel(labelKindReturn, 0)), // return!
 			},
@@ -1101,10 +1103,8 @@ func TestCompile_Locals(t *testing.T) {
 			expected: []unionOperation{
 				// [p[0].lo, p[1].hi] -> [p[0].lo, p[1].hi, 0x01, 0x02]
 				newOperationV128Const(0x01, 0x02),
-				// [p[0].lo, p[1].hi, 0x01, 0x02] -> [p[0].lo, p[1].hi, 0x01, 0x02, 0x01, 0x02]
-				newOperationPick(1, true),
-				// [p[0].lo, p[1].hi, 0x01, 0x02, 0x01, 0x02] -> [0x01, 0x02, 0x01, 0x02]
-				newOperationSet(5, true),
+				// [p[0].lo, p[1].hi, 0x01, 0x02] -> [0x01, 0x02, 0x01, 0x02]
+				newOperationLocalTee(0, true),
 				newOperationDrop(inclusiveRange{Start: 0, End: 3}),
 				newOperationBr(newLabel(labelKindReturn, 0)), // return!
 			},
@@ -1122,8 +1122,7 @@ func TestCompile_Locals(t *testing.T) {
 			},
 			expected: []unionOperation{
 				newOperationConstF32(math.Float32frombits(1)),
-				newOperationPick(0, false),
-				newOperationSet(2, false),
+				newOperationLocalTee(0, false),
 				newOperationDrop(inclusiveRange{Start: 0, End: 1}),
 				newOperationBr(newLabel(labelKindReturn, 0)), // return!
 			},
@@ -1148,10 +1147,8 @@ func TestCompile_Locals(t *testing.T) {
 				newOperationV128Const(0, 0),
 				// [p[0].lo, p[1].hi] -> [p[0].lo, p[1].hi, 0x01, 0x02]
 				newOperationV128Const(0x01, 0x02),
-				// [p[0].lo, p[1].hi, 0x01, 0x02] -> [p[0].lo, p[1].hi, 0x01, 0x02, 0x01, 0x02]
-				newOperationPick(1, true),
-				// [p[0].lo, p[1].hi, 0x01, 0x02, 0x01, 0x2] -> [0x01, 0x02, 0x01, 0x02]
-				newOperationSet(5, true),
+				// [p[0].lo, p[1].hi, 0x01, 0x02] -> [0x01, 0x02, 0x01, 0x02]
+				newOperationLocalTee(0, true),
 				newOperationDrop(inclusiveRange{Start: 0, End: 3}),
 				newOperationBr(newLabel(labelKindReturn, 0)), // return!
 			},
@@ -1172,6 +1169,187 @@ func TestCompile_Locals(t *testing.T) {
 	}
 }
 
+func TestCompile_Superinstructions(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		expected []unionOperation
+	}{
+		{
+			name: "local.get local.get i32.add",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			},
+			expected: []unionOperation{
+				newOperationArithLocals(unsignedTypeI32, arithOpAdd, 0, 1, -1), // [$0, $1, $0+$1]
+				newOperationDrop(inclusiveRange{Start: 1, End: 2}),
+				newOperationBr(newLabel(labelKindReturn, 0)), // return!
+			},
+		},
+		{
+			name: "local.get i32.const i32.sub local.set",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeLocalSet, 1,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeEnd,
+			},
+			expected: []unionOperation{
+				newOperationArithLocalConst(unsignedTypeI32, arithOpSub, 0, 1, 1), // [$0, $0-1]
+				newOperationLocalGet(1, false),                                    // [$0, $1, $1]
+				newOperationDrop(inclusiveRange{Start: 1, End: 2}),
+				newOperationBr(newLabel(labelKindReturn, 0)), // return!
+			},
+		},
+		{
+			name: "i32.const local.get i32.add local.set",
+			body: []byte{
+				wasm.OpcodeI32Const, 2, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Add, wasm.OpcodeLocalSet, 1,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeEnd,
+			},
+			expected: []unionOperation{
+				newOperationConstI32(2),                                   // [$0, $1, 2]
+				newOperationArithLocal(unsignedTypeI32, arithOpAdd, 0, 1), // [$0, 2+$0]
+				newOperationLocalGet(1, false),                            // [$0, $1, $1]
+				newOperationDrop(inclusiveRange{Start: 1, End: 2}),
+				newOperationBr(newLabel(labelKindReturn, 0)), // return!
+			},
+		},
+		{
+			name: "local.get i32.const i32.shr_u local.set",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 3, wasm.OpcodeI32ShrU, wasm.OpcodeLocalSet, 1,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeEnd,
+			},
+			expected: []unionOperation{
+				newOperationArithLocalConst(unsignedTypeI32, arithOpShrU, 0, 3, 1), // [$0, $0>>3]
+				newOperationLocalGet(1, false),                                     // [$0, $1, $1]
+				newOperationDrop(inclusiveRange{Start: 1, End: 2}),
+				newOperationBr(newLabel(labelKindReturn, 0)), // return!
+			},
+		},
+		{
+			name: "local.get local.get i32.lt_s",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32LtS,
+				wasm.OpcodeEnd,
+			},
+			expected: []unionOperation{
+				newOperationCompareLocals(unsignedTypeI32, compareOpLtS, 0, 1), // [$0, $1, $0<$1]
+				newOperationDrop(inclusiveRange{Start: 1, End: 2}),
+				newOperationBr(newLabel(labelKindReturn, 0)), // return!
+			},
+		},
+		{
+			name: "local.get i32.eqz",
+			body: []byte{
+				wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Eqz,
+				wasm.OpcodeEnd,
+			},
+			expected: []unionOperation{
+				newOperationCompareLocalConst(unsignedTypeI32, compareOpEq, 1, 0), // [$0, $1, $1==0]
+				newOperationDrop(inclusiveRange{Start: 1, End: 2}),
+				newOperationBr(newLabel(labelKindReturn, 0)), // return!
+			},
+		},
+		{
+			name: "local.get i32.const i32.ge_u br_if",
+			body: []byte{
+				wasm.OpcodeBlock, 0x40,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 10, wasm.OpcodeI32GeU, wasm.OpcodeBrIf, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeEnd,
+			},
+			expected: []unionOperation{
+				newOperationBrIfCompareLocalConst(unsignedTypeI32, compareOpGeU, 0, 10,
+					newLabel(labelKindContinuation, 2), newLabel(labelKindHeader, 3), nopinclusiveRange),
+				newOperationLabel(newLabel(labelKindHeader, 3)),
+				newOperationBr(newLabel(labelKindContinuation, 2)),
+				newOperationLabel(newLabel(labelKindContinuation, 2)),
+				newOperationLocalGet(1, false), // [$0, $1, $1]
+				newOperationDrop(inclusiveRange{Start: 1, End: 2}),
+				newOperationBr(newLabel(labelKindReturn, 0)), // return!
+			},
+		},
+		{
+			name: "i32.ne br_if",
+			body: []byte{
+				wasm.OpcodeBlock, 0x40,
+				wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 2, wasm.OpcodeI32Ne, wasm.OpcodeBrIf, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeEnd,
+			},
+			expected: []unionOperation{
+				newOperationConstI32(1), // [$0, $1, 1]
+				newOperationConstI32(2), // [$0, $1, 1, 2]
+				newOperationBrIfCompare(unsignedTypeI32, compareOpNe,
+					newLabel(labelKindContinuation, 2), newLabel(labelKindHeader, 3), nopinclusiveRange),
+				newOperationLabel(newLabel(labelKindHeader, 3)),
+				newOperationBr(newLabel(labelKindContinuation, 2)),
+				newOperationLabel(newLabel(labelKindContinuation, 2)),
+				newOperationLocalGet(1, false), // [$0, $1, $1]
+				newOperationDrop(inclusiveRange{Start: 1, End: 2}),
+				newOperationBr(newLabel(labelKindReturn, 0)), // return!
+			},
+		},
+		{
+			name: "not fused across label",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeLoop, 0x40, wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			},
+			expected: []unionOperation{
+				newOperationLocalGet(0, false), // [$0, $1, $0]
+				newOperationBr(newLabel(labelKindHeader, 2)),
+				newOperationLabel(newLabel(labelKindHeader, 2)),
+				newOperationArithLocal(unsignedTypeI32, arithOpAdd, 1, -1), // [$0, $1, $0+$1]
+				newOperationDrop(inclusiveRange{Start: 1, End: 2}),
+				newOperationBr(newLabel(labelKindReturn, 0)), // return!
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mod := &wasm.Module{
+				TypeSection:     []wasm.FunctionType{i32i32_i32},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []wasm.Code{{Body: tc.body}},
+			}
+			c, err := newCompiler(api.CoreFeaturesV2, 0, mod, false)
+			require.NoError(t, err)
+
+			actual, err := c.Next()
+			require.NoError(t, err)
+			msg := fmt.Sprintf("\nhave:\n\t%s\nwant:\n\t%s", format(actual.Operations), format(tc.expected))
+			require.Equal(t, tc.expected, actual.Operations, msg)
+
+			// Nothing is fused when the source offset of each instruction is needed.
+			mod.DWARFLines = &wasmdebug.DWARFLines{}
+			c, err = newCompiler(api.CoreFeaturesV2, 0, mod, false)
+			require.NoError(t, err)
+			actual, err = c.Next()
+			require.NoError(t, err)
+			require.Equal(t, len(actual.Operations), len(actual.IROperationSourceOffsetsInWasmBinary))
+			for _, op := range actual.Operations {
+				switch op.Kind {
+				case operationKindArithLocal, operationKindArithLocals, operationKindArithLocalConst,
+					operationKindCompareLocals, operationKindCompareLocalConst,
+					operationKindBrIfCompare, operationKindBrIfCompareLocals, operationKindBrIfCompareLocalConst:
+					t.Fatalf("unexpected superinstruction %s", op)
+				}
+			}
+		})
+	}
+}
+
 func TestCompile_Vec(t *testing.T) {
 	addV128Const := func(in []byte) []byte {
 		return append(in, wasm.OpcodeVecPrefix,
@@ -2641,7 +2819,7 @@ func TestCompile_Vec(t *testing.T) {
 			module := &wasm.Module{
 				TypeSection:     []wasm.FunctionType{v_v},
 				FunctionSection: []wasm.Index{0},
-				MemorySection:   &wasm.Memory{},
+				MemorySection:   []wasm.Memory{{}},
 				CodeSection:     []wasm.Code{{Body: tc.body}},
 			}
 			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false)
@@ -3703,7 +3881,7 @@ func TestCompiler_threads(t *testing.T) {
 			module := &wasm.Module{
 				TypeSection:     []wasm.FunctionType{v_v},
 				FunctionSection: []wasm.Index{0},
-				MemorySection:   &wasm.Memory{},
+				MemorySection:   []wasm.Memory{{}},
 				CodeSection:     []wasm.Code{{Body: body}},
 			}
 			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false)
@@ -3719,3 +3897,72 @@ func TestCompiler_threads(t *testing.T) {
 		})
 	}
 }
+
+func TestCompile_Debugging(t *testing.T) {
+	mod := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{i32i32_i32},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{
+			Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			},
+			BodyOffsetInCodeSection: 10,
+		}},
+		Debugging: true,
+	}
+	c, err := newCompiler(api.CoreFeaturesV2, 0, mod, false)
+	require.NoError(t, err)
+
+	actual, err := c.Next()
+	require.NoError(t, err)
+	// The superinstructions are not fused across the instructions, so the debugger can stop at each of them.
+	require.Equal(t, `.entrypoint
+	DebugStep
+	LocalGet 0 (is_vector=false)
+	DebugStep
+	LocalGet 1 (is_vector=false)
+	DebugStep
+	i32.Add
+	DebugStep
+	Drop 4294967298..0
+	Br .return
+`, format(actual.Operations))
+	// The source offsets are available without DWARF.
+	require.Equal(t, []uint64{10, 10, 12, 12, 14, 14, 15, 15, 15}, actual.IROperationSourceOffsetsInWasmBinary)
+}
+
+func TestCompile_Coverage(t *testing.T) {
+	mod := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{i32i32_i32},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{
+			Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeBrIf, 0, // offset 0
+				wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, // offset 6
+				wasm.OpcodeEnd, // offset 9
+			},
+			BodyOffsetInCodeSection: 10,
+		}},
+		Coverage: true,
+	}
+	c, err := newCompiler(api.CoreFeaturesV2, 0, mod, false)
+	require.NoError(t, err)
+
+	actual, err := c.Next()
+	require.NoError(t, err)
+	// Each block is counted before its last instruction.
+	require.Equal(t, `.entrypoint
+	LocalGet 0 (is_vector=false)
+	LocalGet 1 (is_vector=false)
+	Coverage 0
+	BrIf .return, .L2
+.L2
+	ConstI32 0x1
+	i32.Add
+	Coverage 1
+	Drop 4294967298..0
+	Br .return
+`, format(actual.Operations))
+	require.Equal(t, []coverage.Block{{Start: 10, End: 15}, {Start: 16, End: 20}}, actual.CoverageBlocks)
+}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/coverage"
+	"github.com/tetratelabs/wazero/internal/debugging"
 	"github.com/tetratelabs/wazero/internal/expctxkeys"
 	"github.com/tetratelabs/wazero/internal/filecache"
 	"github.com/tetratelabs/wazero/internal/internalapi"
@@ -19,6 +21,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/internal/wasmdebug"
 	"github.com/tetratelabs/wazero/internal/wasmruntime"
+	"github.com/tetratelabs/wazero/sys"
 )
 
 // callStackCeiling is the maximum WebAssembly call frame stack height. This allows wazero to raise
@@ -85,6 +88,20 @@ type moduleEngine struct {
 	parentEngine *engine
 }
 
+// moduleEngineOf returns the *moduleEngine of the given wasm.ModuleEngine, which is either one or a
+// wasm.TieredModuleEngine delegating to one.
+func moduleEngineOf(me wasm.ModuleEngine) *moduleEngine {
+	if ret, ok := me.(*moduleEngine); ok {
+		return ret
+	}
+	for _, tier := range me.(wasm.TieredModuleEngine).ModuleEngines() {
+		if ret, ok := tier.(*moduleEngine); ok {
+			return ret
+		}
+	}
+	panic("BUG: the module doesn't run on the interpreter")
+}
+
 // GetGlobalValue implements the same method as documented on wasm.ModuleEngine.
 func (e *moduleEngine) GetGlobalValue(wasm.Index) (lo, hi uint64) {
 	panic("BUG: GetGlobalValue should never be called on interpreter mode")
@@ -120,6 +137,18 @@ type callEngine struct {
 
 	// stackiterator for Listeners to walk frames and stack.
 	stackIterator stackIterator
+
+	// exceptionRefs holds the exceptions referenced by exnref values during the current call.
+	exceptionRefs wasm.ExceptionRefs
+
+	// fuel is the fuel remaining for the current call, set by experimental.WithFuel, or nil if unlimited.
+	fuel *int64
+
+	// debugger is notified of operationKindDebugStep, set by experimental/debugging.WithDebugger, or nil if none.
+	debugger debugging.Debugger
+
+	// coverage is notified of operationKindCoverage, set by experimental/coverage.WithCollector, or nil if none.
+	coverage coverage.Collector
 }
 
 func (e *moduleEngine) newCallEngine(compiled *function) *callEngine {
@@ -209,6 +238,12 @@ type compiledFunction struct {
 	hostFn              interface{}
 	ensureTermination   bool
 	index               wasm.Index
+	// handlers are the catch clauses of try_table instructions, where the pads are resolved to the index in body.
+	handlers []exceptionHandler
+	// localTypes are the types of the params and the locals, which are only set for the debugger.
+	localTypes []wasm.ValueType
+	// coverage has the blocks counted by operationKindCoverage, which is only set for the coverage.
+	coverage *coverage.Function
 }
 
 type function struct {
@@ -351,6 +386,51 @@ func (f internalFunction) SourceOffsetForPC(pc experimental.ProgramCounter) uint
 	return 0
 }
 
+// debugStack implements debugging.Stack for callEngine.
+type debugStack callEngine
+
+var _ debugging.Stack = (*debugStack)(nil)
+
+// Depth implements the same method as documented on debugging.Stack.
+func (s *debugStack) Depth() int {
+	return len(s.frames)
+}
+
+// Frame implements the same method as documented on debugging.Stack.
+func (s *debugStack) Frame(i int) debugging.Frame {
+	frame := s.frames[len(s.frames)-1-i]
+	f := frame.f
+	ret := debugging.Frame{Function: f.definition(), Module: f.moduleInstance}
+	compiled := f.parent
+	if compiled.hostFn != nil {
+		return ret
+	}
+	ret.DWARFLines = compiled.source.DWARFLines
+	if offsets := compiled.offsetsInWasmBinary; frame.pc < uint64(len(offsets)) {
+		ret.SourceOffset = offsets[frame.pc]
+	}
+	ret.BodyOffset = compiled.source.CodeSection[compiled.index-compiled.source.ImportFunctionCount].BodyOffsetInCodeSection
+
+	// The params and the locals are placed in the register slots followed by the operand stack, which ends where
+	// the params of the callee start.
+	locals := frame.base - f.funcType.ParamNumInUint64
+	slots := 0
+	for _, t := range compiled.localTypes {
+		if slots++; t == wasm.ValueTypeV128 {
+			slots++
+		}
+	}
+	end := len(s.stack)
+	if i > 0 {
+		callee := s.frames[len(s.frames)-i]
+		end = callee.base - callee.f.funcType.ParamNumInUint64
+	}
+	ret.LocalTypes = compiled.localTypes
+	ret.Locals = s.stack[locals : locals+slots]
+	ret.Stack = s.stack[locals+slots : end]
+	return ret
+}
+
 // interpreter mode doesn't maintain call frames in the stack, so pass the zero size to the IR.
 const callFrameStackSize = 0
 
@@ -388,6 +468,17 @@ func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners
 				def := module.FunctionDefinition(uint32(i) + module.ImportFunctionCount)
 				return fmt.Errorf("failed to lower func[%s] to interpreterir: %w", def.DebugName(), err)
 			}
+			if module.Debugging {
+				sig := &module.TypeSection[module.FunctionSection[i]]
+				compiled.localTypes = append(append([]wasm.ValueType{}, sig.Params...), codeSeg.LocalTypes...)
+			}
+			if module.Coverage {
+				compiled.coverage = &coverage.Function{
+					Module: module,
+					Index:  imported + uint32(i),
+					Blocks: append([]coverage.Block{}, ir.CoverageBlocks...),
+				}
+			}
 		}
 		compiled.source = module
 		compiled.ensureTermination = ensureTermination
@@ -435,6 +526,16 @@ func (e *engine) lowerIR(ir *compilationResult, ret *compiledFunction) error {
 		copy(ret.offsetsInWasmBinary, offsets)
 	}
 
+	if len(ir.ExceptionHandlers) > 0 {
+		ret.handlers = make([]exceptionHandler, len(ir.ExceptionHandlers))
+		for i := range ir.ExceptionHandlers {
+			h := &ir.ExceptionHandlers[i]
+			ret.handlers[i] = *h
+			ret.handlers[i].catches = make([]exceptionCatch, len(h.catches))
+			copy(ret.handlers[i].catches, h.catches)
+		}
+	}
+
 	labelAddressResolutions := [labelKindNum][]uint64{}
 
 	// First, we iterate all labels, and resolve the address.
@@ -464,7 +565,7 @@ func (e *engine) lowerIR(ir *compilationResult, ret *compiledFunction) error {
 		switch op.Kind {
 		case operationKindBr:
 			e.setLabelAddress(&op.U1, label(op.U1), labelAddressResolutions)
-		case operationKindBrIf:
+		case operationKindBrIf, operationKindBrIfCompare, operationKindBrIfCompareLocals, operationKindBrIfCompareLocalConst:
 			e.setLabelAddress(&op.U1, label(op.U1), labelAddressResolutions)
 			e.setLabelAddress(&op.U2, label(op.U2), labelAddressResolutions)
 		case operationKindBrTable:
@@ -474,6 +575,12 @@ func (e *engine) lowerIR(ir *compilationResult, ret *compiledFunction) error {
 			}
 		}
 	}
+	for i := range ret.handlers {
+		catches := ret.handlers[i].catches
+		for j := range catches {
+			e.setLabelAddress(&catches[j].pad, label(catches[j].pad), labelAddressResolutions)
+		}
+	}
 	return nil
 }
 
@@ -488,12 +595,12 @@ func (e *engine) setLabelAddress(op *uint64, label label, labelAddressResolution
 
 // ResolveImportedFunction implements wasm.ModuleEngine.
 func (e *moduleEngine) ResolveImportedFunction(index, descFunc, indexInImportedModule wasm.Index, importedModuleEngine wasm.ModuleEngine) {
-	imported := importedModuleEngine.(*moduleEngine)
+	imported := moduleEngineOf(importedModuleEngine)
 	e.functions[index] = imported.functions[indexInImportedModule]
 }
 
 // ResolveImportedMemory implements wasm.ModuleEngine.
-func (e *moduleEngine) ResolveImportedMemory(wasm.ModuleEngine) {}
+func (e *moduleEngine) ResolveImportedMemory(wasm.Index, wasm.Index, wasm.ModuleEngine) {}
 
 // DoneInstantiation implements wasm.ModuleEngine.
 func (e *moduleEngine) DoneInstantiation() {}
@@ -503,6 +610,11 @@ func (e *moduleEngine) FunctionInstanceReference(funcIndex wasm.Index) wasm.Refe
 	return uintptr(unsafe.Pointer(&e.functions[funcIndex]))
 }
 
+// FunctionReference implements the same method as documented on wasm.ModuleEngine.
+func (e *moduleEngine) FunctionReference(m *wasm.ModuleInstance, funcIndex wasm.Index) (wasm.Reference, error) {
+	return moduleEngineOf(m.Engine).FunctionInstanceReference(funcIndex), nil
+}
+
 // NewFunction implements the same method as documented on wasm.ModuleEngine.
 func (e *moduleEngine) NewFunction(index wasm.Index) (ce api.Function) {
 	// Note: The input parameters are pre-validated, so a compiled function is only absent on close. Updates to
@@ -528,11 +640,22 @@ func (e *moduleEngine) LookupFunction(t *wasm.TableInstance, typeId wasm.Functio
 	return tf.moduleInstance, tf.parent.index
 }
 
+// LookupFunctionReference implements the same method as documented on wasm.ModuleEngine.
+func (e *moduleEngine) LookupFunctionReference(ref wasm.Reference) (*wasm.ModuleInstance, wasm.Index) {
+	tf := functionFromUintptr(ref)
+	return tf.moduleInstance, tf.parent.index
+}
+
 // Definition implements the same method as documented on api.Function.
 func (ce *callEngine) Definition() api.FunctionDefinition {
 	return ce.f.definition()
 }
 
+// ModuleFunction implements the same method as documented on wasm.ModuleFunction.
+func (ce *callEngine) ModuleFunction() (*wasm.ModuleInstance, wasm.Index) {
+	return ce.f.moduleInstance, ce.f.parent.index
+}
+
 func (f *function) definition() api.FunctionDefinition {
 	compiled := f.parent
 	return compiled.source.FunctionDefinition(compiled.index)
@@ -573,6 +696,9 @@ func (ce *callEngine) call(ctx context.Context, params, results []uint64) (_ []u
 	if ctx.Value(expctxkeys.EnableSnapshotterKey{}) != nil {
 		ctx = context.WithValue(ctx, expctxkeys.SnapshotterKey{}, ce)
 	}
+	ce.fuel, _ = ctx.Value(expctxkeys.FuelKey{}).(*int64)
+	ce.debugger, _ = ctx.Value(expctxkeys.DebuggerKey{}).(debugging.Debugger)
+	ce.coverage, _ = ctx.Value(expctxkeys.CoverageCollectorKey{}).(coverage.Collector)
 
 	defer func() {
 		// If the module closed during the call, and the call didn't err for another reason, set an ExitError.
@@ -584,8 +710,15 @@ func (ce *callEngine) call(ctx context.Context, params, results []uint64) (_ []u
 		if v := recover(); v != nil {
 			err = ce.recoverOnCall(ctx, m, v)
 		}
+		// exnref values are only valid during the call.
+		ce.exceptionRefs.Reset()
 	}()
 
+	if heap := m.GCHeap(); heap != nil {
+		heap.EnterCall(ce)
+		defer heap.ExitCall(ce)
+	}
+
 	ce.pushValues(params)
 
 	if ce.f.parent.ensureTermination {
@@ -623,6 +756,12 @@ func (ce *callEngine) recoverOnCall(ctx context.Context, m *wasm.ModuleInstance,
 		panic(s)
 	}
 
+	if d := ce.debugger; d != nil && len(ce.frames) > 0 {
+		if e, ok := v.(error); ok {
+			d.Trap(ctx, (*debugStack)(ce), e)
+		}
+	}
+
 	builder := wasmdebug.NewErrorBuilder()
 	frameCount := len(ce.frames)
 	functionListeners := make([]functionListenerInvocation, 0, 16)
@@ -698,7 +837,7 @@ func (ce *callEngine) callGoFunc(ctx context.Context, m *wasm.ModuleInstance, f
 func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance, f *function) {
 	frame := &callFrame{f: f, base: len(ce.stack)}
 	moduleInst := f.moduleInstance
-	functions := moduleInst.Engine.(*moduleEngine).functions
+	functions := moduleEngineOf(moduleInst.Engine).functions
 	memoryInst := moduleInst.MemoryInstance
 	globals := moduleInst.Globals
 	tables := moduleInst.Tables
@@ -706,6 +845,8 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 	dataInstances := moduleInst.DataInstances
 	elementInstances := moduleInst.ElementInstances
 	ce.pushFrame(frame)
+	// locals is the index of the first register slot of the frame, where the params and locals are placed.
+	locals := frame.base - f.funcType.ParamNumInUint64
 	body := frame.f.parent.body
 	bodyLen := uint64(len(body))
 	for frame.pc < bodyLen {
@@ -719,12 +860,46 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				panic(err)
 			}
 			frame.pc++
+		case operationKindConsumeFuel:
+			if fuel := ce.fuel; fuel != nil {
+				if *fuel < int64(op.U1) {
+					panic(sys.ErrOutOfFuel)
+				}
+				*fuel -= int64(op.U1)
+			}
+			frame.pc++
+		case operationKindDebugStep:
+			if d := ce.debugger; d != nil {
+				d.Step(ctx, (*debugStack)(ce))
+			}
+			frame.pc++
+		case operationKindCoverage:
+			if c := ce.coverage; c != nil {
+				c.Hit(frame.f.parent.coverage, int(op.U1))
+			}
+			frame.pc++
 		case operationKindUnreachable:
 			panic(wasmruntime.ErrRuntimeUnreachable)
 		case operationKindBr:
 			frame.pc = op.U1
 		case operationKindBrIf:
-			if ce.popValue() > 0 {
+			if (ce.popValue() != 0) != op.B3 {
+				ce.drop(op.U3)
+				frame.pc = op.U1
+			} else {
+				frame.pc = op.U2
+			}
+		case operationKindBrIfCompare, operationKindBrIfCompareLocals, operationKindBrIfCompareLocalConst:
+			var v1, v2 uint64
+			switch op.Kind {
+			case operationKindBrIfCompare:
+				v2, v1 = ce.popValue(), ce.popValue()
+			case operationKindBrIfCompareLocals:
+				v1, v2 = ce.stack[locals+int(op.Us[0])], ce.stack[locals+int(op.Us[1])]
+			default:
+				v1, v2 = ce.stack[locals+int(op.Us[0])], op.Us[1]
+			}
+			if compare(compareOp(op.B2), unsignedType(op.B1), v1, v2) {
 				ce.drop(op.U3)
 				frame.pc = op.U1
 			} else {
@@ -740,6 +915,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.drop(op.Us[v+1])
 			frame.pc = op.Us[v]
 		case operationKindCall:
+			caught := false
 			func() {
 				if ctx.Value(expctxkeys.EnableSnapshotterKey{}) != nil {
 					defer func() {
@@ -755,27 +931,79 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 						}
 					}()
 				}
+				if frame.f.parent.handlers != nil {
+					defer ce.catchFromCallee(ctx, m, frame, len(ce.frames)-1, &caught)
+				}
 				ce.callFunction(ctx, f.moduleInstance, &functions[op.U1])
 			}()
-			frame.pc++
-		case operationKindCallIndirect:
-			offset := ce.popValue()
-			table := tables[op.U2]
-			if offset >= uint64(len(table.References)) {
-				panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+			if !caught {
+				frame.pc++
 			}
-			rawPtr := table.References[offset]
-			if rawPtr == 0 {
-				panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+		case operationKindCallIndirect, operationKindCallRef:
+			var tf *function
+			if op.Kind == operationKindCallIndirect {
+				tf = ce.popIndirectCallTarget(tables[op.U2], typeIDs[op.U1])
+			} else {
+				tf = ce.popCallRefTarget(typeIDs[op.U1])
 			}
-
-			tf := functionFromUintptr(rawPtr)
-			if tf.typeID != typeIDs[op.U1] {
-				panic(wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+			if frame.f.parent.handlers != nil {
+				if ce.callFunctionWithHandlers(ctx, m, frame, tf) {
+					break
+				}
+			} else {
+				ce.callFunction(ctx, f.moduleInstance, tf)
 			}
-
-			ce.callFunction(ctx, f.moduleInstance, tf)
 			frame.pc++
+		case operationKindThrow:
+			tag := moduleInst.Tags[op.U1]
+			values := make([]uint64, tag.Type.ParamNumInUint64)
+			ce.popValues(values)
+			ce.throw(frame, &experimental.Exception{Tag: tag, Values: values})
+		case operationKindThrowRef:
+			ref := ce.popValue()
+			if ref == 0 {
+				panic(wasmruntime.ErrRuntimeNullExceptionReference)
+			}
+			exc := ce.exceptionRefs.Get(ref)
+			if exc == nil {
+				panic(wasmruntime.ErrRuntimeInvalidExceptionReference)
+			}
+			ce.throw(frame, exc)
+		case operationKindTailCallReturnCall, operationKindTailCallReturnCallIndirect, operationKindTailCallReturnCallRef:
+			var tf *function
+			switch op.Kind {
+			case operationKindTailCallReturnCall:
+				tf = &functions[op.U1]
+			case operationKindTailCallReturnCallIndirect:
+				tf = ce.popIndirectCallTarget(tables[op.U2], typeIDs[op.U1])
+			default:
+				tf = ce.popCallRefTarget(typeIDs[op.U1])
+			}
+
+			if tf.parent.hostFn != nil || tf.parent.listener != nil {
+				// Host functions and functions with a listener are called as usual, and then
+				// the current function returns their results as-is.
+				ce.callFunction(ctx, f.moduleInstance, tf)
+				frame.pc = bodyLen
+				break
+			}
+
+			// At this point, only the callee's params remain in the current frame, so replace
+			// the current frame with the callee's one instead of growing the call stack.
+			m, f = f.moduleInstance, tf
+			frame = &callFrame{f: f, base: len(ce.stack)}
+			ce.frames[len(ce.frames)-1] = frame
+			locals = frame.base - f.funcType.ParamNumInUint64
+			moduleInst = f.moduleInstance
+			functions = moduleEngineOf(moduleInst.Engine).functions
+			memoryInst = moduleInst.MemoryInstance
+			globals = moduleInst.Globals
+			tables = moduleInst.Tables
+			typeIDs = moduleInst.TypeIDs
+			dataInstances = moduleInst.DataInstances
+			elementInstances = moduleInst.ElementInstances
+			body = frame.f.parent.body
+			bodyLen = uint64(len(body))
 		case operationKindDrop:
 			ce.drop(op.U1)
 			frame.pc++
@@ -814,6 +1042,57 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.stack[index] = ce.popValue()
 			}
 			frame.pc++
+		case operationKindLocalGet:
+			index := locals + int(op.U1)
+			ce.pushValue(ce.stack[index])
+			if op.B3 { // V128 value target.
+				ce.pushValue(ce.stack[index+1])
+			}
+			frame.pc++
+		case operationKindLocalSet:
+			index := locals + int(op.U1)
+			if op.B3 { // V128 value target.
+				ce.stack[index+1] = ce.popValue()
+			}
+			ce.stack[index] = ce.popValue()
+			frame.pc++
+		case operationKindLocalTee:
+			index := locals + int(op.U1)
+			if op.B3 { // V128 value target.
+				top := len(ce.stack) - 2
+				ce.stack[index], ce.stack[index+1] = ce.stack[top], ce.stack[top+1]
+			} else {
+				ce.stack[index] = ce.stack[len(ce.stack)-1]
+			}
+			frame.pc++
+		case operationKindArithLocal, operationKindArithLocals, operationKindArithLocalConst:
+			var v1, v2 uint64
+			switch op.Kind {
+			case operationKindArithLocal:
+				v1, v2 = ce.popValue(), ce.stack[locals+int(op.U1)]
+			case operationKindArithLocals:
+				v1, v2 = ce.stack[locals+int(op.U1)], ce.stack[locals+int(op.U2)]
+			default:
+				v1, v2 = ce.stack[locals+int(op.U1)], op.U2
+			}
+			v := arith(arithOp(op.B2), unsignedType(op.B1), v1, v2)
+			if op.B3 {
+				ce.stack[locals+int(op.U3)] = v
+			} else {
+				ce.pushValue(v)
+			}
+			frame.pc++
+		case operationKindCompareLocals, operationKindCompareLocalConst:
+			v1, v2 := ce.stack[locals+int(op.U1)], op.U2
+			if op.Kind == operationKindCompareLocals {
+				v2 = ce.stack[locals+int(op.U2)]
+			}
+			if compare(compareOp(op.B2), unsignedType(op.B1), v1, v2) {
+				ce.pushValue(1)
+			} else {
+				ce.pushValue(0)
+			}
+			frame.pc++
 		case operationKindGlobalGet:
 			g := globals[op.U1]
 			ce.pushValue(g.Val)
@@ -829,16 +1108,17 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			g.Val = ce.popValue()
 			frame.pc++
 		case operationKindLoad:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
 			case unsignedTypeI32, unsignedTypeF32:
-				if val, ok := memoryInst.ReadUint32Le(offset); !ok {
+				if val, ok := memory.ReadUint32Le(offset); !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				} else {
 					ce.pushValue(uint64(val))
 				}
 			case unsignedTypeI64, unsignedTypeF64:
-				if val, ok := memoryInst.ReadUint64Le(offset); !ok {
+				if val, ok := memory.ReadUint64Le(offset); !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				} else {
 					ce.pushValue(val)
@@ -846,7 +1126,8 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindLoad8:
-			val, ok := memoryInst.ReadByte(ce.popMemoryOffset(op))
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
+			val, ok := memory.ReadUint8(ce.popMemoryOffset(op))
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
@@ -861,8 +1142,8 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindLoad16:
-
-			val, ok := memoryInst.ReadUint16Le(ce.popMemoryOffset(op))
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
+			val, ok := memory.ReadUint16Le(ce.popMemoryOffset(op))
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
@@ -877,7 +1158,8 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindLoad32:
-			val, ok := memoryInst.ReadUint32Le(ce.popMemoryOffset(op))
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
+			val, ok := memory.ReadUint32Le(ce.popMemoryOffset(op))
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
@@ -889,47 +1171,57 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindStore:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
 			case unsignedTypeI32, unsignedTypeF32:
-				if !memoryInst.WriteUint32Le(offset, uint32(val)) {
+				if !memory.WriteUint32Le(offset, uint32(val)) {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 			case unsignedTypeI64, unsignedTypeF64:
-				if !memoryInst.WriteUint64Le(offset, val) {
+				if !memory.WriteUint64Le(offset, val) {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 			}
 			frame.pc++
 		case operationKindStore8:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := byte(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteByte(offset, val) {
+			if !memory.WriteUint8(offset, val) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindStore16:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := uint16(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteUint16Le(offset, val) {
+			if !memory.WriteUint16Le(offset, val) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindStore32:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := uint32(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteUint32Le(offset, val) {
+			if !memory.WriteUint32Le(offset, val) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindMemorySize:
-			ce.pushValue(uint64(memoryInst.Pages()))
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
+			ce.pushValue(uint64(memory.Pages()))
 			frame.pc++
 		case operationKindMemoryGrow:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			n := ce.popValue()
-			if res, ok := memoryInst.Grow(uint32(n)); !ok {
-				ce.pushValue(uint64(0xffffffff)) // = -1 in signed 32-bit integer.
+			if res, ok := memory.Grow(n); !ok {
+				if memory.Is64 {
+					ce.pushValue(math.MaxUint64) // = -1 in signed 64-bit integer.
+				} else {
+					ce.pushValue(uint64(0xffffffff)) // = -1 in signed 32-bit integer.
+				}
 			} else {
 				ce.pushValue(uint64(res))
 			}
@@ -1692,42 +1984,44 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(uint64(v))
 			frame.pc++
 		case operationKindMemoryInit:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			dataInstance := dataInstances[op.U1]
 			copySize := ce.popValue()
 			inDataOffset := ce.popValue()
 			inMemoryOffset := ce.popValue()
 			if inDataOffset+copySize > uint64(len(dataInstance)) ||
-				inMemoryOffset+copySize > uint64(len(memoryInst.Buffer)) {
+				!inMemoryBounds(memory, inMemoryOffset, copySize) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			} else if copySize != 0 {
-				copy(memoryInst.Buffer[inMemoryOffset:inMemoryOffset+copySize], dataInstance[inDataOffset:])
+				copy(memory.Buffer[inMemoryOffset:inMemoryOffset+copySize], dataInstance[inDataOffset:])
 			}
 			frame.pc++
 		case operationKindDataDrop:
 			dataInstances[op.U1] = nil
 			frame.pc++
 		case operationKindMemoryCopy:
-			memLen := uint64(len(memoryInst.Buffer))
+			dst, src := memoryAt(moduleInst, memoryInst, op.U3), memoryAt(moduleInst, memoryInst, op.U1)
 			copySize := ce.popValue()
 			sourceOffset := ce.popValue()
 			destinationOffset := ce.popValue()
-			if sourceOffset+copySize > memLen || destinationOffset+copySize > memLen {
+			if !inMemoryBounds(src, sourceOffset, copySize) || !inMemoryBounds(dst, destinationOffset, copySize) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			} else if copySize != 0 {
-				copy(memoryInst.Buffer[destinationOffset:],
-					memoryInst.Buffer[sourceOffset:sourceOffset+copySize])
+				copy(dst.Buffer[destinationOffset:],
+					src.Buffer[sourceOffset:sourceOffset+copySize])
 			}
 			frame.pc++
 		case operationKindMemoryFill:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			fillSize := ce.popValue()
 			value := byte(ce.popValue())
 			offset := ce.popValue()
-			if fillSize+offset > uint64(len(memoryInst.Buffer)) {
+			if !inMemoryBounds(memory, offset, fillSize) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			} else if fillSize != 0 {
 				// Uses the copy trick for faster filling buffer.
 				// https://gist.github.com/taylorza/df2f89d5f9ab3ffd06865062a4cf015d
-				buf := memoryInst.Buffer[offset : offset+fillSize]
+				buf := memory.Buffer[offset : offset+fillSize]
 				buf[0] = value
 				for i := 1; i < len(buf); i *= 2 {
 					copy(buf[i:], buf[:i])
@@ -1764,6 +2058,106 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 		case operationKindRefFunc:
 			ce.pushValue(uint64(uintptr(unsafe.Pointer(&functions[op.U1]))))
 			frame.pc++
+		case operationKindRefAsNonNull:
+			if ce.stack[len(ce.stack)-1] == 0 {
+				panic(wasmruntime.ErrRuntimeNullReference)
+			}
+			frame.pc++
+		case operationKindRefTest:
+			ref := ce.popValue()
+			if refTest(moduleInst, ref, wasm.HeapType(op.U1), op.B3) {
+				ce.pushValue(1)
+			} else {
+				ce.pushValue(0)
+			}
+			frame.pc++
+		case operationKindRefCast:
+			if !refTest(moduleInst, ce.stack[len(ce.stack)-1], wasm.HeapType(op.U1), op.B3) {
+				panic(wasmruntime.ErrRuntimeCastFailure)
+			}
+			frame.pc++
+		case operationKindStructNew:
+			ce.structNew(moduleInst, moduleInst.GCTypes[op.U1], op.B3)
+			frame.pc++
+		case operationKindStructGet:
+			t := moduleInst.GCTypes[op.U1]
+			obj := gcObjectOf(moduleInst.GCHeap(), ce.popValue(), t, wasmruntime.ErrRuntimeNullStructReference)
+			ce.pushField(&t.Fields[op.U2], obj.Values[t.FieldOffset(wasm.Index(op.U2)):], op.B3)
+			frame.pc++
+		case operationKindStructSet:
+			t := moduleInst.GCTypes[op.U1]
+			f := &t.Fields[op.U2]
+			lo, hi := ce.popField(f)
+			obj := gcObjectOf(moduleInst.GCHeap(), ce.popValue(), t, wasmruntime.ErrRuntimeNullStructReference)
+			setField(f, obj.Values[t.FieldOffset(wasm.Index(op.U2)):], lo, hi)
+			frame.pc++
+		case operationKindArrayNew:
+			ce.arrayNew(moduleInst, moduleInst.GCTypes[op.U1], op.B3)
+			frame.pc++
+		case operationKindArrayNewFixed:
+			ce.arrayNewFixed(moduleInst, moduleInst.GCTypes[op.U1], op.U2)
+			frame.pc++
+		case operationKindArrayNewData:
+			ce.arrayNewData(moduleInst, moduleInst.GCTypes[op.U1], dataInstances[op.U2])
+			frame.pc++
+		case operationKindArrayNewElem:
+			ce.arrayNewElem(moduleInst, moduleInst.GCTypes[op.U1], elementInstances[op.U2])
+			frame.pc++
+		case operationKindArrayGet:
+			t := moduleInst.GCTypes[op.U1]
+			index := uint64(uint32(ce.popValue()))
+			obj := gcObjectOf(moduleInst.GCHeap(), ce.popValue(), t, wasmruntime.ErrRuntimeNullArrayReference)
+			if index >= uint64(obj.ArrayLen()) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+			}
+			ce.pushField(&t.Fields[0], obj.Values[index*uint64(t.Size()):], op.B3)
+			frame.pc++
+		case operationKindArraySet:
+			t := moduleInst.GCTypes[op.U1]
+			f := &t.Fields[0]
+			lo, hi := ce.popField(f)
+			index := uint64(uint32(ce.popValue()))
+			obj := gcObjectOf(moduleInst.GCHeap(), ce.popValue(), t, wasmruntime.ErrRuntimeNullArrayReference)
+			if index >= uint64(obj.ArrayLen()) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+			}
+			setField(f, obj.Values[index*uint64(t.Size()):], lo, hi)
+			frame.pc++
+		case operationKindArrayLen:
+			obj := gcObjectOf(moduleInst.GCHeap(), ce.popValue(), nil, wasmruntime.ErrRuntimeNullArrayReference)
+			ce.pushValue(uint64(obj.ArrayLen()))
+			frame.pc++
+		case operationKindArrayFill:
+			ce.arrayFill(moduleInst, moduleInst.GCTypes[op.U1])
+			frame.pc++
+		case operationKindArrayCopy:
+			ce.arrayCopy(moduleInst, moduleInst.GCTypes[op.U1], moduleInst.GCTypes[op.U2])
+			frame.pc++
+		case operationKindArrayInitData:
+			ce.arrayInitData(moduleInst, moduleInst.GCTypes[op.U1], dataInstances[op.U2])
+			frame.pc++
+		case operationKindArrayInitElem:
+			ce.arrayInitElem(moduleInst, moduleInst.GCTypes[op.U1], elementInstances[op.U2])
+			frame.pc++
+		case operationKindRefI31:
+			ce.pushValue(wasm.GCRefI31(uint32(ce.popValue())))
+			frame.pc++
+		case operationKindI31Get:
+			ref := ce.popValue()
+			if ref == 0 {
+				panic(wasmruntime.ErrRuntimeNullI31Reference)
+			}
+			ce.pushValue(uint64(wasm.GCRefI31Value(ref, op.B3)))
+			frame.pc++
+		case operationKindAnyConvertExtern:
+			// The reference is kept on the stack while converting, as this may collect garbage.
+			top := len(ce.stack) - 1
+			ce.stack[top] = moduleInst.GCHeap().AnyConvertExtern(ce.stack[top])
+			frame.pc++
+		case operationKindExternConvertAny:
+			top := len(ce.stack) - 1
+			ce.stack[top] = moduleInst.GCHeap().ExternConvertAny(ce.stack[top])
+			frame.pc++
 		case operationKindTableGet:
 			table := tables[op.U1]
 
@@ -1906,21 +2300,22 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindV128Load:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			offset := ce.popMemoryOffset(op)
 			switch op.B1 {
 			case v128LoadType128:
-				lo, ok := memoryInst.ReadUint64Le(offset)
+				lo, ok := memory.ReadUint64Le(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(lo)
-				hi, ok := memoryInst.ReadUint64Le(offset + 8)
+				hi, ok := memory.ReadUint64Le(offset + 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(hi)
 			case v128LoadType8x8s:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memory.Read(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1931,7 +2326,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					uint64(uint16(int8(data[7])))<<48 | uint64(uint16(int8(data[6])))<<32 | uint64(uint16(int8(data[5])))<<16 | uint64(uint16(int8(data[4]))),
 				)
 			case v128LoadType8x8u:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memory.Read(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1942,7 +2337,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					uint64(data[7])<<48 | uint64(data[6])<<32 | uint64(data[5])<<16 | uint64(data[4]),
 				)
 			case v128LoadType16x4s:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memory.Read(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1955,7 +2350,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 						uint64(uint32(int16(binary.LittleEndian.Uint16(data[4:])))),
 				)
 			case v128LoadType16x4u:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memory.Read(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1966,21 +2361,21 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					uint64(binary.LittleEndian.Uint16(data[6:]))<<32 | uint64(binary.LittleEndian.Uint16(data[4:])),
 				)
 			case v128LoadType32x2s:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memory.Read(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(uint64(int32(binary.LittleEndian.Uint32(data))))
 				ce.pushValue(uint64(int32(binary.LittleEndian.Uint32(data[4:]))))
 			case v128LoadType32x2u:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memory.Read(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(uint64(binary.LittleEndian.Uint32(data)))
 				ce.pushValue(uint64(binary.LittleEndian.Uint32(data[4:])))
 			case v128LoadType8Splat:
-				v, ok := memoryInst.ReadByte(offset)
+				v, ok := memory.ReadUint8(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1989,7 +2384,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.pushValue(v8)
 				ce.pushValue(v8)
 			case v128LoadType16Splat:
-				v, ok := memoryInst.ReadUint16Le(offset)
+				v, ok := memory.ReadUint16Le(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1997,7 +2392,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.pushValue(v4)
 				ce.pushValue(v4)
 			case v128LoadType32Splat:
-				v, ok := memoryInst.ReadUint32Le(offset)
+				v, ok := memory.ReadUint32Le(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2005,21 +2400,21 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.pushValue(vv)
 				ce.pushValue(vv)
 			case v128LoadType64Splat:
-				lo, ok := memoryInst.ReadUint64Le(offset)
+				lo, ok := memory.ReadUint64Le(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(lo)
 				ce.pushValue(lo)
 			case v128LoadType32zero:
-				lo, ok := memoryInst.ReadUint32Le(offset)
+				lo, ok := memory.ReadUint32Le(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(uint64(lo))
 				ce.pushValue(0)
 			case v128LoadType64zero:
-				lo, ok := memoryInst.ReadUint64Le(offset)
+				lo, ok := memory.ReadUint64Le(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2028,11 +2423,12 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindV128LoadLane:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch op.B1 {
 			case 8:
-				b, ok := memoryInst.ReadByte(offset)
+				b, ok := memory.ReadUint8(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2044,7 +2440,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					hi = (hi & ^(0xff << s)) | uint64(b)<<s
 				}
 			case 16:
-				b, ok := memoryInst.ReadUint16Le(offset)
+				b, ok := memory.ReadUint16Le(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2056,7 +2452,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					hi = (hi & ^(0xff_ff << s)) | uint64(b)<<s
 				}
 			case 32:
-				b, ok := memoryInst.ReadUint32Le(offset)
+				b, ok := memory.ReadUint32Le(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2068,7 +2464,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					hi = (hi & ^(0xff_ff_ff_ff << s)) | uint64(b)<<s
 				}
 			case 64:
-				b, ok := memoryInst.ReadUint64Le(offset)
+				b, ok := memory.ReadUint64Le(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2082,48 +2478,50 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(hi)
 			frame.pc++
 		case operationKindV128Store:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			// Write the upper bytes first to trigger an early error if the memory access is out of bounds.
 			// Otherwise, the lower bytes might be written to memory, but the upper bytes might not.
-			if uint64(offset)+8 > math.MaxUint32 {
+			if offset+8 < offset {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
-			if ok := memoryInst.WriteUint64Le(offset+8, hi); !ok {
+			if ok := memory.WriteUint64Le(offset+8, hi); !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
-			if ok := memoryInst.WriteUint64Le(offset, lo); !ok {
+			if ok := memory.WriteUint64Le(offset, lo); !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindV128StoreLane:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			var ok bool
 			switch op.B1 {
 			case 8:
 				if op.B2 < 8 {
-					ok = memoryInst.WriteByte(offset, byte(lo>>(op.B2*8)))
+					ok = memory.WriteUint8(offset, byte(lo>>(op.B2*8)))
 				} else {
-					ok = memoryInst.WriteByte(offset, byte(hi>>((op.B2-8)*8)))
+					ok = memory.WriteUint8(offset, byte(hi>>((op.B2-8)*8)))
 				}
 			case 16:
 				if op.B2 < 4 {
-					ok = memoryInst.WriteUint16Le(offset, uint16(lo>>(op.B2*16)))
+					ok = memory.WriteUint16Le(offset, uint16(lo>>(op.B2*16)))
 				} else {
-					ok = memoryInst.WriteUint16Le(offset, uint16(hi>>((op.B2-4)*16)))
+					ok = memory.WriteUint16Le(offset, uint16(hi>>((op.B2-4)*16)))
 				}
 			case 32:
 				if op.B2 < 2 {
-					ok = memoryInst.WriteUint32Le(offset, uint32(lo>>(op.B2*32)))
+					ok = memory.WriteUint32Le(offset, uint32(lo>>(op.B2*32)))
 				} else {
-					ok = memoryInst.WriteUint32Le(offset, uint32(hi>>((op.B2-2)*32)))
+					ok = memory.WriteUint32Le(offset, uint32(hi>>((op.B2-2)*32)))
 				}
 			case 64:
 				if op.B2 == 0 {
-					ok = memoryInst.WriteUint64Le(offset, lo)
+					ok = memory.WriteUint64Le(offset, lo)
 				} else {
-					ok = memoryInst.WriteUint64Le(offset, hi)
+					ok = memory.WriteUint64Le(offset, hi)
 				}
 			}
 			if !ok {
@@ -3976,13 +4374,36 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(retLo)
 			ce.pushValue(retHi)
 			frame.pc++
+		case operationKindV128RelaxedMadd:
+			cHi, cLo := ce.popValue(), ce.popValue()
+			x2Hi, x2Lo := ce.popValue(), ce.popValue()
+			x1Hi, x1Lo := ce.popValue(), ce.popValue()
+			lo, hi := v128RelaxedMadd(op.B1, op.B3, x1Hi, x1Lo, x2Hi, x2Lo, cHi, cLo)
+			ce.pushValue(lo)
+			ce.pushValue(hi)
+			frame.pc++
+		case operationKindV128RelaxedDot:
+			var cHi, cLo uint64
+			if op.B3 {
+				cHi, cLo = ce.popValue(), ce.popValue()
+			}
+			x2Hi, x2Lo := ce.popValue(), ce.popValue()
+			x1Hi, x1Lo := ce.popValue(), ce.popValue()
+			lo, hi := v128RelaxedDot(x1Hi, x1Lo, x2Hi, x2Lo)
+			if op.B3 {
+				lo, hi = v128RelaxedDotAdd(lo, hi, cLo, cHi)
+			}
+			ce.pushValue(lo)
+			ce.pushValue(hi)
+			frame.pc++
 		case operationKindAtomicMemoryWait:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			timeout := int64(ce.popValue())
 			exp := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			// Runtime instead of validation error because the spec intends to allow binaries to include
 			// such instructions as long as they are not executed.
-			if !memoryInst.Shared {
+			if !memory.Shared {
 				panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
 			}
 
@@ -3991,41 +4412,42 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				if offset%4 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				if int(offset) > len(memoryInst.Buffer)-4 {
+				if !inMemoryBounds(memory, offset, 4) {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
-				ce.pushValue(memoryInst.Wait32(offset, uint32(exp), timeout, func(mem *wasm.MemoryInstance, offset uint32) uint32 {
+				ce.pushValue(memory.Wait32(offset, uint32(exp), timeout, func(mem *wasm.MemoryInstance, offset uint64) uint32 {
 					mem.Mux.Lock()
 					defer mem.Mux.Unlock()
-					value, _ := mem.ReadUint32Le(offset)
+					value, _ := mem.As64().ReadUint32Le(offset)
 					return value
 				}))
 			case unsignedTypeI64:
 				if offset%8 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				if int(offset) > len(memoryInst.Buffer)-8 {
+				if !inMemoryBounds(memory, offset, 8) {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
-				ce.pushValue(memoryInst.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint32) uint64 {
+				ce.pushValue(memory.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint64) uint64 {
 					mem.Mux.Lock()
 					defer mem.Mux.Unlock()
-					value, _ := mem.ReadUint64Le(offset)
+					value, _ := mem.As64().ReadUint64Le(offset)
 					return value
 				}))
 			}
 			frame.pc++
 		case operationKindAtomicMemoryNotify:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			count := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			if offset%4 != 0 {
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
 			// Just a bounds check
-			if offset >= memoryInst.Size() {
+			if offset >= memory.Size() {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
-			res := memoryInst.Notify(offset, uint32(count))
+			res := memory.Notify(offset, uint32(count))
 			ce.pushValue(uint64(res))
 			frame.pc++
 		case operationKindAtomicFence:
@@ -4038,15 +4460,16 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindAtomicLoad:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
 			case unsignedTypeI32:
 				if offset%4 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				memoryInst.Mux.Lock()
-				val, ok := memoryInst.ReadUint32Le(offset)
-				memoryInst.Mux.Unlock()
+				memory.Mux.Lock()
+				val, ok := memory.ReadUint32Le(offset)
+				memory.Mux.Unlock()
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -4055,9 +4478,9 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				if offset%8 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				memoryInst.Mux.Lock()
-				val, ok := memoryInst.ReadUint64Le(offset)
-				memoryInst.Mux.Unlock()
+				memory.Mux.Lock()
+				val, ok := memory.ReadUint64Le(offset)
+				memory.Mux.Unlock()
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -4065,29 +4488,32 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindAtomicLoad8:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			offset := ce.popMemoryOffset(op)
-			memoryInst.Mux.Lock()
-			val, ok := memoryInst.ReadByte(offset)
-			memoryInst.Mux.Unlock()
+			memory.Mux.Lock()
+			val, ok := memory.ReadUint8(offset)
+			memory.Mux.Unlock()
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			ce.pushValue(uint64(val))
 			frame.pc++
 		case operationKindAtomicLoad16:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			offset := ce.popMemoryOffset(op)
 			if offset%2 != 0 {
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
-			memoryInst.Mux.Lock()
-			val, ok := memoryInst.ReadUint16Le(offset)
-			memoryInst.Mux.Unlock()
+			memory.Mux.Lock()
+			val, ok := memory.ReadUint16Le(offset)
+			memory.Mux.Unlock()
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			ce.pushValue(uint64(val))
 			frame.pc++
 		case operationKindAtomicStore:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
@@ -4095,9 +4521,9 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				if offset%4 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				memoryInst.Mux.Lock()
-				ok := memoryInst.WriteUint32Le(offset, uint32(val))
-				memoryInst.Mux.Unlock()
+				memory.Mux.Lock()
+				ok := memory.WriteUint32Le(offset, uint32(val))
+				memory.Mux.Unlock()
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -4105,38 +4531,41 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				if offset%8 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				memoryInst.Mux.Lock()
-				ok := memoryInst.WriteUint64Le(offset, val)
-				memoryInst.Mux.Unlock()
+				memory.Mux.Lock()
+				ok := memory.WriteUint64Le(offset, val)
+				memory.Mux.Unlock()
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 			}
 			frame.pc++
 		case operationKindAtomicStore8:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := byte(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			memoryInst.Mux.Lock()
-			ok := memoryInst.WriteByte(offset, val)
-			memoryInst.Mux.Unlock()
+			memory.Mux.Lock()
+			ok := memory.WriteUint8(offset, val)
+			memory.Mux.Unlock()
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindAtomicStore16:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := uint16(ce.popValue())
 			offset := ce.popMemoryOffset(op)
 			if offset%2 != 0 {
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
-			memoryInst.Mux.Lock()
-			ok := memoryInst.WriteUint16Le(offset, val)
-			memoryInst.Mux.Unlock()
+			memory.Mux.Lock()
+			ok := memory.WriteUint16Le(offset, val)
+			memory.Mux.Unlock()
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindAtomicRMW:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
@@ -4144,10 +4573,10 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				if offset%4 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				memoryInst.Mux.Lock()
-				old, ok := memoryInst.ReadUint32Le(offset)
+				memory.Mux.Lock()
+				old, ok := memory.ReadUint32Le(offset)
 				if !ok {
-					memoryInst.Mux.Unlock()
+					memory.Mux.Unlock()
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				var newVal uint32
@@ -4165,17 +4594,17 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				case atomicArithmeticOpNop:
 					newVal = uint32(val)
 				}
-				memoryInst.WriteUint32Le(offset, newVal)
-				memoryInst.Mux.Unlock()
+				memory.WriteUint32Le(offset, newVal)
+				memory.Mux.Unlock()
 				ce.pushValue(uint64(old))
 			case unsignedTypeI64:
 				if offset%8 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				memoryInst.Mux.Lock()
-				old, ok := memoryInst.ReadUint64Le(offset)
+				memory.Mux.Lock()
+				old, ok := memory.ReadUint64Le(offset)
 				if !ok {
-					memoryInst.Mux.Unlock()
+					memory.Mux.Unlock()
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				var newVal uint64
@@ -4193,18 +4622,19 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				case atomicArithmeticOpNop:
 					newVal = val
 				}
-				memoryInst.WriteUint64Le(offset, newVal)
-				memoryInst.Mux.Unlock()
+				memory.WriteUint64Le(offset, newVal)
+				memory.Mux.Unlock()
 				ce.pushValue(old)
 			}
 			frame.pc++
 		case operationKindAtomicRMW8:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
-			memoryInst.Mux.Lock()
-			old, ok := memoryInst.ReadByte(offset)
+			memory.Mux.Lock()
+			old, ok := memory.ReadUint8(offset)
 			if !ok {
-				memoryInst.Mux.Unlock()
+				memory.Mux.Unlock()
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			arg := byte(val)
@@ -4223,20 +4653,21 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			case atomicArithmeticOpNop:
 				newVal = arg
 			}
-			memoryInst.WriteByte(offset, newVal)
-			memoryInst.Mux.Unlock()
+			memory.WriteUint8(offset, newVal)
+			memory.Mux.Unlock()
 			ce.pushValue(uint64(old))
 			frame.pc++
 		case operationKindAtomicRMW16:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			if offset%2 != 0 {
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
-			memoryInst.Mux.Lock()
-			old, ok := memoryInst.ReadUint16Le(offset)
+			memory.Mux.Lock()
+			old, ok := memory.ReadUint16Le(offset)
 			if !ok {
-				memoryInst.Mux.Unlock()
+				memory.Mux.Unlock()
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			arg := uint16(val)
@@ -4255,11 +4686,12 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			case atomicArithmeticOpNop:
 				newVal = arg
 			}
-			memoryInst.WriteUint16Le(offset, newVal)
-			memoryInst.Mux.Unlock()
+			memory.WriteUint16Le(offset, newVal)
+			memory.Mux.Unlock()
 			ce.pushValue(uint64(old))
 			frame.pc++
 		case operationKindAtomicRMWCmpxchg:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			rep := ce.popValue()
 			exp := ce.popValue()
 			offset := ce.popMemoryOffset(op)
@@ -4268,67 +4700,69 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				if offset%4 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				memoryInst.Mux.Lock()
-				old, ok := memoryInst.ReadUint32Le(offset)
+				memory.Mux.Lock()
+				old, ok := memory.ReadUint32Le(offset)
 				if !ok {
-					memoryInst.Mux.Unlock()
+					memory.Mux.Unlock()
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				if old == uint32(exp) {
-					memoryInst.WriteUint32Le(offset, uint32(rep))
+					memory.WriteUint32Le(offset, uint32(rep))
 				}
-				memoryInst.Mux.Unlock()
+				memory.Mux.Unlock()
 				ce.pushValue(uint64(old))
 			case unsignedTypeI64:
 				if offset%8 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				memoryInst.Mux.Lock()
-				old, ok := memoryInst.ReadUint64Le(offset)
+				memory.Mux.Lock()
+				old, ok := memory.ReadUint64Le(offset)
 				if !ok {
-					memoryInst.Mux.Unlock()
+					memory.Mux.Unlock()
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				if old == exp {
-					memoryInst.WriteUint64Le(offset, rep)
+					memory.WriteUint64Le(offset, rep)
 				}
-				memoryInst.Mux.Unlock()
+				memory.Mux.Unlock()
 				ce.pushValue(old)
 			}
 			frame.pc++
 		case operationKindAtomicRMW8Cmpxchg:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			rep := byte(ce.popValue())
 			exp := byte(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			memoryInst.Mux.Lock()
-			old, ok := memoryInst.ReadByte(offset)
+			memory.Mux.Lock()
+			old, ok := memory.ReadUint8(offset)
 			if !ok {
-				memoryInst.Mux.Unlock()
+				memory.Mux.Unlock()
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			if old == exp {
-				memoryInst.WriteByte(offset, rep)
+				memory.WriteUint8(offset, rep)
 			}
-			memoryInst.Mux.Unlock()
+			memory.Mux.Unlock()
 			ce.pushValue(uint64(old))
 			frame.pc++
 		case operationKindAtomicRMW16Cmpxchg:
+			memory := memoryAt(moduleInst, memoryInst, op.U3)
 			rep := uint16(ce.popValue())
 			exp := uint16(ce.popValue())
 			offset := ce.popMemoryOffset(op)
 			if offset%2 != 0 {
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
-			memoryInst.Mux.Lock()
-			old, ok := memoryInst.ReadUint16Le(offset)
+			memory.Mux.Lock()
+			old, ok := memory.ReadUint16Le(offset)
 			if !ok {
-				memoryInst.Mux.Unlock()
+				memory.Mux.Unlock()
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			if old == exp {
-				memoryInst.WriteUint16Le(offset, rep)
+				memory.WriteUint16Le(offset, rep)
 			}
-			memoryInst.Mux.Unlock()
+			memory.Mux.Unlock()
 			ce.pushValue(uint64(old))
 			frame.pc++
 		default:
@@ -4338,6 +4772,91 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 	ce.popFrame()
 }
 
+// arith returns the result of the binary integer operation a of type typ, which is either unsignedTypeI32 or
+// unsignedTypeI64, applied to v1 and v2.
+func arith(a arithOp, typ unsignedType, v1, v2 uint64) (v uint64) {
+	if typ == unsignedTypeI32 {
+		x1, x2 := uint32(v1), uint32(v2)
+		switch a {
+		case arithOpAdd:
+			v = uint64(x1 + x2)
+		case arithOpSub:
+			v = uint64(x1 - x2)
+		case arithOpMul:
+			v = uint64(x1 * x2)
+		case arithOpAnd:
+			v = uint64(x1 & x2)
+		case arithOpOr:
+			v = uint64(x1 | x2)
+		case arithOpXor:
+			v = uint64(x1 ^ x2)
+		case arithOpShl:
+			v = uint64(x1 << (x2 % 32))
+		case arithOpShrS:
+			v = uint64(uint32(int32(x1) >> (x2 % 32)))
+		case arithOpShrU:
+			v = uint64(x1 >> (x2 % 32))
+		}
+		return
+	}
+	switch a {
+	case arithOpAdd:
+		v = v1 + v2
+	case arithOpSub:
+		v = v1 - v2
+	case arithOpMul:
+		v = v1 * v2
+	case arithOpAnd:
+		v = v1 & v2
+	case arithOpOr:
+		v = v1 | v2
+	case arithOpXor:
+		v = v1 ^ v2
+	case arithOpShl:
+		v = v1 << (v2 % 64)
+	case arithOpShrS:
+		v = uint64(int64(v1) >> (v2 % 64))
+	case arithOpShrU:
+		v = v1 >> (v2 % 64)
+	}
+	return
+}
+
+// compare returns true if the integer comparison c of type typ, which is either unsignedTypeI32 or unsignedTypeI64,
+// holds for v1 and v2.
+func compare(c compareOp, typ unsignedType, v1, v2 uint64) bool {
+	if typ == unsignedTypeI32 {
+		// Extends the 32-bit values by their signedness so that the comparisons are done in 64 bits.
+		if c == compareOpLtS || c == compareOpGtS || c == compareOpLeS || c == compareOpGeS {
+			v1, v2 = uint64(int32(v1)), uint64(int32(v2))
+		} else {
+			v1, v2 = uint64(uint32(v1)), uint64(uint32(v2))
+		}
+	}
+	switch c {
+	case compareOpEq:
+		return v1 == v2
+	case compareOpNe:
+		return v1 != v2
+	case compareOpLtS:
+		return int64(v1) < int64(v2)
+	case compareOpLtU:
+		return v1 < v2
+	case compareOpGtS:
+		return int64(v1) > int64(v2)
+	case compareOpGtU:
+		return v1 > v2
+	case compareOpLeS:
+		return int64(v1) <= int64(v2)
+	case compareOpLeU:
+		return v1 <= v2
+	case compareOpGeS:
+		return int64(v1) >= int64(v2)
+	default:
+		return v1 >= v2
+	}
+}
+
 func wasmCompatMax32bits(v1, v2 uint32) uint64 {
 	return uint64(math.Float32bits(moremath.WasmCompatMax32(
 		math.Float32frombits(v1),
@@ -4545,14 +5064,466 @@ func (ce *callEngine) callNativeFuncWithListener(ctx context.Context, m *wasm.Mo
 	return ctx
 }
 
+// throw transfers the control to the catch clause of the current frame matching the exception, or unwinds the
+// current frame by panicking with the exception if there's none.
+func (ce *callEngine) throw(frame *callFrame, exc *experimental.Exception) {
+	if !ce.catch(frame, exc) {
+		panic(exc)
+	}
+}
+
+// catch searches the catch clauses covering the current position of the frame for the one matching the exception.
+// If found, this pushes the values given by the clause at the stack height of the try_table, and moves the program
+// counter to the clause.
+func (ce *callEngine) catch(frame *callFrame, exc *experimental.Exception) bool {
+	f := frame.f
+	for i := range f.parent.handlers {
+		h := &f.parent.handlers[i]
+		if frame.pc < h.start || frame.pc >= h.end {
+			continue
+		}
+		for j := range h.catches {
+			c := &h.catches[j]
+			if !c.all {
+				if tag := f.moduleInstance.Tags[c.tag]; exc.Tag != experimental.Tag(tag) {
+					continue
+				} else if len(exc.Values) != tag.Type.ParamNumInUint64 {
+					panic(fmt.Errorf("invalid exception: %d values for tag with %d params in uint64",
+						len(exc.Values), tag.Type.ParamNumInUint64))
+				}
+			}
+
+			ce.stack = ce.stack[:frame.base-f.funcType.ParamNumInUint64+h.stackHeight]
+			if !c.all {
+				ce.pushValues(exc.Values)
+			}
+			if c.ref {
+				ce.pushValue(ce.exceptionRefs.Ref(exc))
+			}
+			frame.pc = c.pad
+			return true
+		}
+	}
+	return false
+}
+
+// callFunctionWithHandlers calls the function like callFunction from the frame which has catch clauses, and returns
+// true if the function threw an exception caught by the frame.
+func (ce *callEngine) callFunctionWithHandlers(ctx context.Context, m *wasm.ModuleInstance, frame *callFrame, f *function) (caught bool) {
+	defer ce.catchFromCallee(ctx, m, frame, len(ce.frames)-1, &caught)
+	ce.callFunction(ctx, frame.f.moduleInstance, f)
+	return
+}
+
+// catchFromCallee must be deferred when calling a function from the frame at frameIndex which has catch clauses. If
+// the callee threw an exception caught by the frame, this discards the frames of the callee, and sets caught to true.
+func (ce *callEngine) catchFromCallee(ctx context.Context, m *wasm.ModuleInstance, frame *callFrame, frameIndex int, caught *bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	exc, ok := r.(*experimental.Exception)
+	if !ok || !ce.catch(frame, exc) {
+		panic(r)
+	}
+
+	for i := len(ce.frames) - 1; i > frameIndex; i-- {
+		if f := ce.frames[i].f; f.parent.listener != nil {
+			f.parent.listener.Abort(ctx, m, f.definition(), exc)
+		}
+		ce.frames[i] = nil
+	}
+	ce.frames = ce.frames[:frameIndex+1]
+	*caught = true
+}
+
+// popIndirectCallTarget takes an offset off the stack, and returns the function at the offset in the table
+// after ensuring that it exists and its type matches the given typeID.
+func (ce *callEngine) popIndirectCallTarget(table *wasm.TableInstance, typeID wasm.FunctionTypeID) *function {
+	offset := ce.popValue()
+	if offset >= uint64(len(table.References)) {
+		panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+	}
+	rawPtr := table.References[offset]
+	if rawPtr == 0 {
+		panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+	}
+
+	tf := functionFromUintptr(rawPtr)
+	if tf.typeID != typeID {
+		panic(wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+	}
+	return tf
+}
+
+// popCallRefTarget takes a function reference off the stack, and returns the referenced function after ensuring
+// that it is non-null and its type matches the given typeID.
+func (ce *callEngine) popCallRefTarget(typeID wasm.FunctionTypeID) *function {
+	ref := ce.popValue()
+	if ref == 0 {
+		panic(wasmruntime.ErrRuntimeNullFunctionReference)
+	}
+	tf := functionFromUintptr(uintptr(ref))
+	if tf.typeID != typeID {
+		panic(wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+	}
+	return tf
+}
+
+// ScanGCRoots implements wasm.GCRootScanner.
+//
+// As the stack isn't typed, every value on it is reported, which might keep unreachable objects alive.
+func (ce *callEngine) ScanGCRoots(mark func(v uint64)) {
+	for _, v := range ce.stack {
+		mark(v)
+	}
+	for _, exc := range ce.exceptionRefs.Exceptions() {
+		for _, v := range exc.Values {
+			mark(v)
+		}
+	}
+}
+
+// refTest returns true if ref is an instance of the heap type. ref must be in the same type hierarchy as the heap
+// type, which is ensured by validation.
+func refTest(moduleInst *wasm.ModuleInstance, ref uint64, heapType wasm.HeapType, nullable bool) bool {
+	if ref == 0 {
+		return nullable
+	}
+	switch heapType {
+	case wasm.HeapTypeAny, wasm.HeapTypeFunc, wasm.HeapTypeExtern, wasm.HeapTypeExn:
+		return true
+	case wasm.HeapTypeNone, wasm.HeapTypeNoFunc, wasm.HeapTypeNoExtern:
+		return false
+	}
+
+	if heapType >= 0 {
+		if int(heapType) < len(moduleInst.GCTypes) {
+			if t := moduleInst.GCTypes[heapType]; t != nil {
+				obj := moduleInst.GCHeap().Get(ref)
+				return obj != nil && obj.Type.IsSubtypeOf(t)
+			}
+		}
+		// Otherwise, this is a function type.
+		return functionFromUintptr(uintptr(ref)).typeID == moduleInst.TypeIDs[heapType]
+	}
+
+	if wasm.IsGCRefI31(ref) {
+		return heapType == wasm.HeapTypeEq || heapType == wasm.HeapTypeI31
+	}
+	obj := moduleInst.GCHeap().Get(ref)
+	if obj == nil || obj.Type == nil {
+		// Boxed external references are only instances of any.
+		return false
+	}
+	switch heapType {
+	case wasm.HeapTypeEq:
+		return true
+	case wasm.HeapTypeStruct:
+		return obj.Type.Kind == wasm.CompositeTypeKindStruct
+	case wasm.HeapTypeArray:
+		return obj.Type.Kind == wasm.CompositeTypeKindArray
+	}
+	return false
+}
+
+// gcObjectOf returns the struct or array referenced by ref after ensuring that it is an instance of the type t, or
+// an array if t is nil. nullErr is raised if ref is null.
+func gcObjectOf(heap *wasm.GCHeap, ref uint64, t *wasm.GCType, nullErr error) *wasm.GCObject {
+	if ref == 0 {
+		panic(nullErr)
+	}
+	obj := heap.Get(ref)
+	if obj == nil {
+		panic(wasmruntime.ErrRuntimeInvalidGCReference)
+	}
+	if t == nil {
+		if obj.Type == nil || obj.Type.Kind != wasm.CompositeTypeKindArray {
+			panic(wasmruntime.ErrRuntimeCastFailure)
+		}
+	} else if !obj.Type.IsSubtypeOf(t) {
+		panic(wasmruntime.ErrRuntimeCastFailure)
+	}
+	return obj
+}
+
+// pushField pushes the value of the field stored at the beginning of values.
+func (ce *callEngine) pushField(f *wasm.FieldType, values []uint64, signed bool) {
+	if f.StorageType == wasm.ValueTypeV128 {
+		ce.pushValue(values[0])
+		ce.pushValue(values[1])
+	} else {
+		ce.pushValue(wasm.UnpackFieldValue(f, values[0], signed))
+	}
+}
+
+// popField pops the value of the field. hi is only used by v128 fields.
+func (ce *callEngine) popField(f *wasm.FieldType) (lo, hi uint64) {
+	if f.StorageType == wasm.ValueTypeV128 {
+		hi = ce.popValue()
+	}
+	lo = ce.popValue()
+	return
+}
+
+// setField stores the value of the field at the beginning of values.
+func setField(f *wasm.FieldType, values []uint64, lo, hi uint64) {
+	values[0] = wasm.PackFieldValue(f, lo)
+	if f.StorageType == wasm.ValueTypeV128 {
+		values[1] = hi
+	}
+}
+
+// packFields truncates the packed fields of the struct or the elements of the array in values.
+func packFields(t *wasm.GCType, values []uint64) {
+	if t.Kind == wasm.CompositeTypeKindArray {
+		if f := &t.Fields[0]; f.IsPacked() {
+			for i, v := range values {
+				values[i] = wasm.PackFieldValue(f, v)
+			}
+		}
+		return
+	}
+	for i := range t.Fields {
+		if f := &t.Fields[i]; f.IsPacked() {
+			offset := t.FieldOffset(wasm.Index(i))
+			values[offset] = wasm.PackFieldValue(f, values[offset])
+		}
+	}
+}
+
+// structNew allocates the struct of the type t, whose fields are popped from the stack unless isDefault is true.
+func (ce *callEngine) structNew(moduleInst *wasm.ModuleInstance, t *wasm.GCType, isDefault bool) {
+	// Allocate before popping the fields so that the references among them are kept alive.
+	ref, obj := moduleInst.GCHeap().Allocate(t, t.Size())
+	if !isDefault {
+		// The fields are laid out in the same way as on the stack.
+		fields := len(ce.stack) - t.Size()
+		copy(obj.Values, ce.stack[fields:])
+		packFields(t, obj.Values)
+		ce.stack = ce.stack[:fields]
+	}
+	ce.pushValue(ref)
+}
+
+// allocateArray allocates the array of the type t with the length after ensuring it doesn't exceed the limit.
+func allocateArray(moduleInst *wasm.ModuleInstance, t *wasm.GCType, length uint64) (uint64, *wasm.GCObject) {
+	size := length * uint64(t.Size())
+	if size > wasm.MaximumGCObjectSize {
+		panic(wasmruntime.ErrRuntimeArrayTooLarge)
+	}
+	return moduleInst.GCHeap().Allocate(t, int(size))
+}
+
+// arrayNew pops the length and, unless isDefault is true, the initial value of the elements, and pushes the
+// array of the type t.
+func (ce *callEngine) arrayNew(moduleInst *wasm.ModuleInstance, t *wasm.GCType, isDefault bool) {
+	length := uint64(uint32(ce.stack[len(ce.stack)-1]))
+	ref, obj := allocateArray(moduleInst, t, length)
+	ce.popValue()
+	if !isDefault {
+		f := &t.Fields[0]
+		lo, hi := ce.popField(f)
+		lo = wasm.PackFieldValue(f, lo)
+		if size := t.Size(); size == 2 {
+			for i := 0; i < len(obj.Values); i += size {
+				obj.Values[i], obj.Values[i+1] = lo, hi
+			}
+		} else {
+			for i := range obj.Values {
+				obj.Values[i] = lo
+			}
+		}
+	}
+	ce.pushValue(ref)
+}
+
+// arrayNewFixed pops the length elements, and pushes the array of the type t holding them.
+func (ce *callEngine) arrayNewFixed(moduleInst *wasm.ModuleInstance, t *wasm.GCType, length uint64) {
+	ref, obj := allocateArray(moduleInst, t, length)
+	elements := len(ce.stack) - len(obj.Values)
+	copy(obj.Values, ce.stack[elements:])
+	packFields(t, obj.Values)
+	ce.stack = ce.stack[:elements]
+	ce.pushValue(ref)
+}
+
+// arrayNewData pops the offset in the data instance and the length, and pushes the array of the type t initialized
+// with the data.
+func (ce *callEngine) arrayNewData(moduleInst *wasm.ModuleInstance, t *wasm.GCType, data wasm.DataInstance) {
+	length := uint64(uint32(ce.stack[len(ce.stack)-1]))
+	offset := uint64(uint32(ce.stack[len(ce.stack)-2]))
+	elemSize := storageTypeByteSize(&t.Fields[0])
+	if offset+length*elemSize > uint64(len(data)) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	ref, obj := allocateArray(moduleInst, t, length)
+	_, _ = ce.popValue(), ce.popValue()
+	loadArrayElements(&t.Fields[0], obj.Values, data[offset:])
+	ce.pushValue(ref)
+}
+
+// arrayNewElem pops the offset in the element instance and the length, and pushes the array of the type t
+// initialized with the references.
+func (ce *callEngine) arrayNewElem(moduleInst *wasm.ModuleInstance, t *wasm.GCType, elem wasm.ElementInstance) {
+	length := uint64(uint32(ce.stack[len(ce.stack)-1]))
+	offset := uint64(uint32(ce.stack[len(ce.stack)-2]))
+	if offset+length > uint64(len(elem)) {
+		panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+	}
+	ref, obj := allocateArray(moduleInst, t, length)
+	_, _ = ce.popValue(), ce.popValue()
+	for i := range obj.Values {
+		obj.Values[i] = uint64(elem[offset+uint64(i)])
+	}
+	ce.pushValue(ref)
+}
+
+// arrayFill pops the length, the value, the offset and the array of the type t, and sets the value to the elements
+// in the range.
+func (ce *callEngine) arrayFill(moduleInst *wasm.ModuleInstance, t *wasm.GCType) {
+	f := &t.Fields[0]
+	length := uint64(uint32(ce.popValue()))
+	lo, hi := ce.popField(f)
+	offset := uint64(uint32(ce.popValue()))
+	obj := gcObjectOf(moduleInst.GCHeap(), ce.popValue(), t, wasmruntime.ErrRuntimeNullArrayReference)
+	if offset+length > uint64(obj.ArrayLen()) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+	}
+	size := uint64(t.Size())
+	for i := offset; i < offset+length; i++ {
+		setField(f, obj.Values[i*size:], lo, hi)
+	}
+}
+
+// arrayCopy pops the length, the source offset, the source array of the type src, the destination offset and the
+// destination array of the type dst, and copies the elements.
+func (ce *callEngine) arrayCopy(moduleInst *wasm.ModuleInstance, dst, src *wasm.GCType) {
+	heap := moduleInst.GCHeap()
+	length := uint64(uint32(ce.popValue()))
+	srcOffset := uint64(uint32(ce.popValue()))
+	srcObj := gcObjectOf(heap, ce.popValue(), src, wasmruntime.ErrRuntimeNullArrayReference)
+	dstOffset := uint64(uint32(ce.popValue()))
+	dstObj := gcObjectOf(heap, ce.popValue(), dst, wasmruntime.ErrRuntimeNullArrayReference)
+	if dstOffset+length > uint64(dstObj.ArrayLen()) || srcOffset+length > uint64(srcObj.ArrayLen()) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+	}
+	// The sizes of the elements are the same as ensured by validation.
+	size := uint64(dst.Size())
+	copy(dstObj.Values[dstOffset*size:], srcObj.Values[srcOffset*size:(srcOffset+length)*size])
+}
+
+// arrayInitData pops the length, the offset in the data instance, the offset in the array and the array of the type
+// t, and copies the data into the elements.
+func (ce *callEngine) arrayInitData(moduleInst *wasm.ModuleInstance, t *wasm.GCType, data wasm.DataInstance) {
+	length := uint64(uint32(ce.popValue()))
+	srcOffset := uint64(uint32(ce.popValue()))
+	dstOffset := uint64(uint32(ce.popValue()))
+	obj := gcObjectOf(moduleInst.GCHeap(), ce.popValue(), t, wasmruntime.ErrRuntimeNullArrayReference)
+	if dstOffset+length > uint64(obj.ArrayLen()) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+	}
+	elemSize := storageTypeByteSize(&t.Fields[0])
+	if srcOffset+length*elemSize > uint64(len(data)) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	size := uint64(t.Size())
+	loadArrayElements(&t.Fields[0], obj.Values[dstOffset*size:(dstOffset+length)*size], data[srcOffset:])
+}
+
+// arrayInitElem pops the length, the offset in the element instance, the offset in the array and the array of the
+// type t, and copies the references into the elements.
+func (ce *callEngine) arrayInitElem(moduleInst *wasm.ModuleInstance, t *wasm.GCType, elem wasm.ElementInstance) {
+	length := uint64(uint32(ce.popValue()))
+	srcOffset := uint64(uint32(ce.popValue()))
+	dstOffset := uint64(uint32(ce.popValue()))
+	obj := gcObjectOf(moduleInst.GCHeap(), ce.popValue(), t, wasmruntime.ErrRuntimeNullArrayReference)
+	if dstOffset+length > uint64(obj.ArrayLen()) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+	}
+	if srcOffset+length > uint64(len(elem)) {
+		panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+	}
+	for i := uint64(0); i < length; i++ {
+		obj.Values[dstOffset+i] = uint64(elem[srcOffset+i])
+	}
+}
+
+// storageTypeByteSize returns the number of bytes of an array element in a data segment.
+func storageTypeByteSize(f *wasm.FieldType) uint64 {
+	switch f.StorageType {
+	case wasm.StorageTypeI8:
+		return 1
+	case wasm.StorageTypeI16:
+		return 2
+	case wasm.ValueTypeI32, wasm.ValueTypeF32:
+		return 4
+	case wasm.ValueTypeV128:
+		return 16
+	default:
+		return 8
+	}
+}
+
+// loadArrayElements loads the elements from the little-endian encoded data until values is filled.
+func loadArrayElements(f *wasm.FieldType, values []uint64, data []byte) {
+	switch f.StorageType {
+	case wasm.StorageTypeI8:
+		for i := range values {
+			values[i] = uint64(data[i])
+		}
+	case wasm.StorageTypeI16:
+		for i := range values {
+			values[i] = uint64(binary.LittleEndian.Uint16(data[i*2:]))
+		}
+	case wasm.ValueTypeI32, wasm.ValueTypeF32:
+		for i := range values {
+			values[i] = uint64(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+	default: // i64, f64 and v128, whose halves are loaded as two elements.
+		for i := range values {
+			values[i] = binary.LittleEndian.Uint64(data[i*8:])
+		}
+	}
+}
+
 // popMemoryOffset takes a memory offset off the stack for use in load and store instructions.
 // As the top of stack value is 64-bit, this ensures it is in range before returning it.
-func (ce *callEngine) popMemoryOffset(op *unionOperation) uint32 {
-	offset := op.U2 + ce.popValue()
-	if offset > math.MaxUint32 {
+func (ce *callEngine) popMemoryOffset(op *unionOperation) uint64 {
+	addr := ce.popValue()
+	offset := op.U2 + addr
+	if offset < addr { // Only possible with a 64-bit memory.
 		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 	}
-	return uint32(offset)
+	return offset
+}
+
+// memoryAt returns the memory at the given index of moduleInst, where memoryInst is the one at index zero.
+//
+// The memory is accessed with 64-bit offsets regardless of its type, as the 32-bit address operands are zero-extended.
+func memoryAt(moduleInst *wasm.ModuleInstance, memoryInst *wasm.MemoryInstance, index uint64) wasm.MemoryInstance64 {
+	if index == 0 {
+		return memoryInst.As64()
+	}
+	return moduleInst.Memories[index].As64()
+}
+
+// inMemoryBounds returns true if the memory has byteCount bytes at the given offset.
+func inMemoryBounds(memory wasm.MemoryInstance64, offset, byteCount uint64) bool {
+	size := uint64(len(memory.Buffer))
+	return byteCount <= size && offset <= size-byteCount
+}
+
+// callGoFuncAtSafepoint calls the Go function while the other calls can collect garbage. The Go function works on a
+// copy of the stack elements, so that ce.stack doesn't change while it's scanned.
+func (ce *callEngine) callGoFuncAtSafepoint(ctx context.Context, m *wasm.ModuleInstance, f *function, stack []uint64, heap *wasm.GCHeap) {
+	copied := append([]uint64(nil), stack...)
+	heap.EnterHostCall()
+	defer func() {
+		heap.ExitHostCall()
+		copy(stack, copied)
+	}()
+	ce.callGoFunc(ctx, m, f, copied)
 }
 
 func (ce *callEngine) callGoFuncWithStack(ctx context.Context, m *wasm.ModuleInstance, f *function) {
@@ -4572,7 +5543,11 @@ func (ce *callEngine) callGoFuncWithStack(ctx context.Context, m *wasm.ModuleIns
 
 	// Pass the stack elements to the go function.
 	stack := ce.stack[len(ce.stack)-stackLen:]
-	ce.callGoFunc(ctx, m, f, stack)
+	if heap := m.GCHeap(); heap != nil {
+		ce.callGoFuncAtSafepoint(ctx, m, f, stack, heap)
+	} else {
+		ce.callGoFunc(ctx, m, f, stack)
+	}
 
 	// Shrink the stack when there were more parameters than results.
 	if shrinkLen := paramLen - resultLen; shrinkLen > 0 {
@@ -4594,3 +5569,56 @@ func v128Dot(x1Hi, x1Lo, x2Hi, x2Lo uint64) (uint64, uint64) {
 	r8 := int32(int16(x1Hi>>48)) * int32(int16(x2Hi>>48))
 	return uint64(uint32(r1+r2)) | (uint64(uint32(r3+r4)) << 32), uint64(uint32(r5+r6)) | (uint64(uint32(r7+r8)) << 32)
 }
+
+// v128RelaxedMadd returns x1*x2+c, or -(x1*x2)+c if negate is true, in the given float shape. The product is rounded
+// before the addition: the explicit conversions prevent the Go compiler from fusing them on the platforms with FMA.
+func v128RelaxedMadd(shape shape, negate bool, x1Hi, x1Lo, x2Hi, x2Lo, cHi, cLo uint64) (uint64, uint64) {
+	switch shape {
+	case shapeF32x4:
+		madd := func(x1, x2, c uint64) uint64 {
+			var ret uint64
+			for i := 0; i < 64; i += 32 {
+				p := float32(math.Float32frombits(uint32(x1>>i)) * math.Float32frombits(uint32(x2>>i)))
+				if negate {
+					p = -p
+				}
+				ret |= uint64(math.Float32bits(p+math.Float32frombits(uint32(c>>i)))) << i
+			}
+			return ret
+		}
+		return madd(x1Lo, x2Lo, cLo), madd(x1Hi, x2Hi, cHi)
+	default: // shapeF64x2
+		madd := func(x1, x2, c uint64) uint64 {
+			p := float64(math.Float64frombits(x1) * math.Float64frombits(x2))
+			if negate {
+				p = -p
+			}
+			return math.Float64bits(p + math.Float64frombits(c))
+		}
+		return madd(x1Lo, x2Lo, cLo), madd(x1Hi, x2Hi, cHi)
+	}
+}
+
+// v128RelaxedDot returns the i16x8 sums of the products of adjacent signed i8 lanes of x1 and x2. The sums wrap around.
+func v128RelaxedDot(x1Hi, x1Lo, x2Hi, x2Lo uint64) (uint64, uint64) {
+	dot := func(x1, x2 uint64) (ret uint64) {
+		for i := 0; i < 64; i += 16 {
+			r := int16(int8(x1>>i))*int16(int8(x2>>i)) + int16(int8(x1>>(i+8)))*int16(int8(x2>>(i+8)))
+			ret |= uint64(uint16(r)) << i
+		}
+		return
+	}
+	return dot(x1Lo, x2Lo), dot(x1Hi, x2Hi)
+}
+
+// v128RelaxedDotAdd returns c plus the i32x4 sums of adjacent signed i16 lanes of the result of v128RelaxedDot.
+func v128RelaxedDotAdd(lo, hi, cLo, cHi uint64) (uint64, uint64) {
+	add := func(x, c uint64) (ret uint64) {
+		for i := 0; i < 64; i += 32 {
+			r := int32(int16(x>>i)) + int32(int16(x>>(i+16))) + int32(c>>i)
+			ret |= uint64(uint32(r)) << i
+		}
+		return
+	}
+	return add(lo, cLo), add(hi, cHi)
+}
@@ -244,6 +244,7 @@ func TestInterpreter_NonTrappingFloatToIntConversion(t *testing.T) {
 
 					ce := &callEngine{}
 					f := &function{
+						funcType:       &wasm.FunctionType{},
 						moduleInstance: &wasm.ModuleInstance{Engine: &moduleEngine{}},
 						parent:         &compiledFunction{body: body},
 					}
@@ -307,6 +308,7 @@ func TestInterpreter_CallEngine_callNativeFunc_signExtend(t *testing.T) {
 			t.Run(fmt.Sprintf("%s(i32.const(0x%x))", wasm.InstructionName(tc.opcode), tc.in), func(t *testing.T) {
 				ce := &callEngine{}
 				f := &function{
+					funcType:       &wasm.FunctionType{},
 					moduleInstance: &wasm.ModuleInstance{Engine: &moduleEngine{}},
 					parent: &compiledFunction{body: []unionOperation{
 						{Kind: operationKindConstI32, U1: uint64(uint32(tc.in))},
@@ -361,6 +363,7 @@ func TestInterpreter_CallEngine_callNativeFunc_signExtend(t *testing.T) {
 			t.Run(fmt.Sprintf("%s(i64.const(0x%x))", wasm.InstructionName(tc.opcode), tc.in), func(t *testing.T) {
 				ce := &callEngine{}
 				f := &function{
+					funcType:       &wasm.FunctionType{},
 					moduleInstance: &wasm.ModuleInstance{Engine: &moduleEngine{}},
 					parent: &compiledFunction{body: []unionOperation{
 						{Kind: operationKindConstI64, U1: uint64(tc.in)},
@@ -445,6 +445,92 @@ func (o operationKind) String() (ret string) {
 		ret = "operationKindAtomicRMW8Cmpxchg"
 	case operationKindAtomicRMW16Cmpxchg:
 		ret = "operationKindAtomicRMW16Cmpxchg"
+	case operationKindTailCallReturnCall:
+		ret = "TailCallReturnCall"
+	case operationKindTailCallReturnCallIndirect:
+		ret = "TailCallReturnCallIndirect"
+	case operationKindThrow:
+		ret = "Throw"
+	case operationKindThrowRef:
+		ret = "ThrowRef"
+	case operationKindCallRef:
+		ret = "CallRef"
+	case operationKindTailCallReturnCallRef:
+		ret = "TailCallReturnCallRef"
+	case operationKindRefAsNonNull:
+		ret = "RefAsNonNull"
+	case operationKindRefTest:
+		ret = "RefTest"
+	case operationKindRefCast:
+		ret = "RefCast"
+	case operationKindStructNew:
+		ret = "StructNew"
+	case operationKindStructGet:
+		ret = "StructGet"
+	case operationKindStructSet:
+		ret = "StructSet"
+	case operationKindArrayNew:
+		ret = "ArrayNew"
+	case operationKindArrayNewFixed:
+		ret = "ArrayNewFixed"
+	case operationKindArrayNewData:
+		ret = "ArrayNewData"
+	case operationKindArrayNewElem:
+		ret = "ArrayNewElem"
+	case operationKindArrayGet:
+		ret = "ArrayGet"
+	case operationKindArraySet:
+		ret = "ArraySet"
+	case operationKindArrayLen:
+		ret = "ArrayLen"
+	case operationKindArrayFill:
+		ret = "ArrayFill"
+	case operationKindArrayCopy:
+		ret = "ArrayCopy"
+	case operationKindArrayInitData:
+		ret = "ArrayInitData"
+	case operationKindArrayInitElem:
+		ret = "ArrayInitElem"
+	case operationKindRefI31:
+		ret = "RefI31"
+	case operationKindI31Get:
+		ret = "I31Get"
+	case operationKindAnyConvertExtern:
+		ret = "AnyConvertExtern"
+	case operationKindExternConvertAny:
+		ret = "ExternConvertAny"
+	case operationKindV128RelaxedMadd:
+		ret = "V128RelaxedMadd"
+	case operationKindV128RelaxedDot:
+		ret = "V128RelaxedDot"
+	case operationKindConsumeFuel:
+		ret = "ConsumeFuel"
+	case operationKindLocalGet:
+		ret = "LocalGet"
+	case operationKindLocalSet:
+		ret = "LocalSet"
+	case operationKindLocalTee:
+		ret = "LocalTee"
+	case operationKindArithLocal:
+		ret = "ArithLocal"
+	case operationKindArithLocals:
+		ret = "ArithLocals"
+	case operationKindArithLocalConst:
+		ret = "ArithLocalConst"
+	case operationKindCompareLocals:
+		ret = "CompareLocals"
+	case operationKindCompareLocalConst:
+		ret = "CompareLocalConst"
+	case operationKindBrIfCompare:
+		ret = "BrIfCompare"
+	case operationKindBrIfCompareLocals:
+		ret = "BrIfCompareLocals"
+	case operationKindBrIfCompareLocalConst:
+		ret = "BrIfCompareLocalConst"
+	case operationKindDebugStep:
+		ret = "DebugStep"
+	case operationKindCoverage:
+		ret = "Coverage"
 	default:
 		panic(fmt.Errorf("unknown operation %d", o))
 	}
@@ -768,6 +854,95 @@ const (
 	// operationKindAtomicRMW16Cmpxchg is the kind for NewOperationAtomicRMW16Cmpxchg.
 	operationKindAtomicRMW16Cmpxchg
 
+	// operationKindTailCallReturnCall is the Kind for NewOperationTailCallReturnCall.
+	operationKindTailCallReturnCall
+	// operationKindTailCallReturnCallIndirect is the Kind for NewOperationTailCallReturnCallIndirect.
+	operationKindTailCallReturnCallIndirect
+
+	// operationKindThrow is the Kind for NewOperationThrow.
+	operationKindThrow
+	// operationKindThrowRef is the Kind for NewOperationThrowRef.
+	operationKindThrowRef
+
+	// operationKindCallRef is the Kind for NewOperationCallRef.
+	operationKindCallRef
+	// operationKindTailCallReturnCallRef is the Kind for NewOperationTailCallReturnCallRef.
+	operationKindTailCallReturnCallRef
+	// operationKindRefAsNonNull is the Kind for NewOperationRefAsNonNull.
+	operationKindRefAsNonNull
+	// operationKindRefTest is the Kind for NewOperationRefTest.
+	operationKindRefTest
+	// operationKindRefCast is the Kind for NewOperationRefCast.
+	operationKindRefCast
+	// operationKindStructNew is the Kind for NewOperationStructNew.
+	operationKindStructNew
+	// operationKindStructGet is the Kind for NewOperationStructGet.
+	operationKindStructGet
+	// operationKindStructSet is the Kind for NewOperationStructSet.
+	operationKindStructSet
+	// operationKindArrayNew is the Kind for NewOperationArrayNew.
+	operationKindArrayNew
+	// operationKindArrayNewFixed is the Kind for NewOperationArrayNewFixed.
+	operationKindArrayNewFixed
+	// operationKindArrayNewData is the Kind for NewOperationArrayNewData.
+	operationKindArrayNewData
+	// operationKindArrayNewElem is the Kind for NewOperationArrayNewElem.
+	operationKindArrayNewElem
+	// operationKindArrayGet is the Kind for NewOperationArrayGet.
+	operationKindArrayGet
+	// operationKindArraySet is the Kind for NewOperationArraySet.
+	operationKindArraySet
+	// operationKindArrayLen is the Kind for NewOperationArrayLen.
+	operationKindArrayLen
+	// operationKindArrayFill is the Kind for NewOperationArrayFill.
+	operationKindArrayFill
+	// operationKindArrayCopy is the Kind for NewOperationArrayCopy.
+	operationKindArrayCopy
+	// operationKindArrayInitData is the Kind for NewOperationArrayInitData.
+	operationKindArrayInitData
+	// operationKindArrayInitElem is the Kind for NewOperationArrayInitElem.
+	operationKindArrayInitElem
+	// operationKindRefI31 is the Kind for NewOperationRefI31.
+	operationKindRefI31
+	// operationKindI31Get is the Kind for NewOperationI31Get.
+	operationKindI31Get
+	// operationKindAnyConvertExtern is the Kind for NewOperationAnyConvertExtern.
+	operationKindAnyConvertExtern
+	// operationKindExternConvertAny is the Kind for NewOperationExternConvertAny.
+	operationKindExternConvertAny
+	// operationKindV128RelaxedMadd is the Kind for NewOperationV128RelaxedMadd.
+	operationKindV128RelaxedMadd
+	// operationKindV128RelaxedDot is the Kind for NewOperationV128RelaxedDot.
+	operationKindV128RelaxedDot
+	// operationKindConsumeFuel is the Kind for NewOperationConsumeFuel.
+	operationKindConsumeFuel
+	// operationKindLocalGet is the Kind for NewOperationLocalGet.
+	operationKindLocalGet
+	// operationKindLocalSet is the Kind for NewOperationLocalSet.
+	operationKindLocalSet
+	// operationKindLocalTee is the Kind for NewOperationLocalTee.
+	operationKindLocalTee
+	// operationKindArithLocal is the Kind for NewOperationArithLocal.
+	operationKindArithLocal
+	// operationKindArithLocals is the Kind for NewOperationArithLocals.
+	operationKindArithLocals
+	// operationKindArithLocalConst is the Kind for NewOperationArithLocalConst.
+	operationKindArithLocalConst
+	// operationKindCompareLocals is the Kind for NewOperationCompareLocals.
+	operationKindCompareLocals
+	// operationKindCompareLocalConst is the Kind for NewOperationCompareLocalConst.
+	operationKindCompareLocalConst
+	// operationKindBrIfCompare is the Kind for NewOperationBrIfCompare.
+	operationKindBrIfCompare
+	// operationKindBrIfCompareLocals is the Kind for NewOperationBrIfCompareLocals.
+	operationKindBrIfCompareLocals
+	// operationKindBrIfCompareLocalConst is the Kind for NewOperationBrIfCompareLocalConst.
+	operationKindBrIfCompareLocalConst
+	// operationKindDebugStep is the Kind for NewOperationDebugStep.
+	operationKindDebugStep
+	// operationKindCoverage is the Kind for NewOperationCoverage.
+	operationKindCoverage
+
 	// operationKindEnd is always placed at the bottom of this iota definition to be used in the test.
 	operationKindEnd
 )
@@ -891,10 +1066,49 @@ func (o unionOperation) String() string {
 		operationKindTableSize,
 		operationKindTableGrow,
 		operationKindTableFill,
-		operationKindBuiltinFunctionCheckExitCode:
+		operationKindBuiltinFunctionCheckExitCode,
+		operationKindDebugStep,
+		operationKindThrowRef,
+		operationKindRefAsNonNull,
+		operationKindArrayLen,
+		operationKindRefI31,
+		operationKindAnyConvertExtern,
+		operationKindExternConvertAny:
 		return o.Kind.String()
 
+	case operationKindThrow,
+		operationKindConsumeFuel,
+		operationKindCoverage,
+		operationKindCallRef,
+		operationKindTailCallReturnCallRef:
+		return fmt.Sprintf("%s %d", o.Kind, o.U1)
+
+	case operationKindRefTest, operationKindRefCast:
+		return fmt.Sprintf("%s %d (nullable=%v)", o.Kind, int64(o.U1), o.B3)
+
+	case operationKindStructNew, operationKindArrayNew:
+		return fmt.Sprintf("%s %d (default=%v)", o.Kind, o.U1, o.B3)
+
+	case operationKindStructGet:
+		return fmt.Sprintf("%s %d %d (signed=%v)", o.Kind, o.U1, o.U2, o.B3)
+
+	case operationKindArrayGet, operationKindI31Get:
+		return fmt.Sprintf("%s %d (signed=%v)", o.Kind, o.U1, o.B3)
+
+	case operationKindStructSet,
+		operationKindArrayNewFixed,
+		operationKindArrayNewData,
+		operationKindArrayNewElem,
+		operationKindArrayCopy,
+		operationKindArrayInitData,
+		operationKindArrayInitElem:
+		return fmt.Sprintf("%s %d %d", o.Kind, o.U1, o.U2)
+
+	case operationKindArraySet, operationKindArrayFill:
+		return fmt.Sprintf("%s %d", o.Kind, o.U1)
+
 	case operationKindCall,
+		operationKindTailCallReturnCall,
 		operationKindGlobalGet,
 		operationKindGlobalSet:
 		return fmt.Sprintf("%s %d", o.Kind, o.B1)
@@ -908,6 +1122,9 @@ func (o unionOperation) String() string {
 	case operationKindBrIf:
 		thenTarget := label(o.U1)
 		elseTarget := label(o.U2)
+		if o.B3 {
+			return fmt.Sprintf("%s.eqz %s, %s", o.Kind, thenTarget, elseTarget)
+		}
 		return fmt.Sprintf("%s %s, %s", o.Kind, thenTarget, elseTarget)
 
 	case operationKindBrTable:
@@ -922,7 +1139,7 @@ func (o unionOperation) String() string {
 		}
 		return fmt.Sprintf("%s [%s] %s", o.Kind, strings.Join(targets, ","), defaultLabel)
 
-	case operationKindCallIndirect:
+	case operationKindCallIndirect, operationKindTailCallReturnCallIndirect:
 		return fmt.Sprintf("%s: type=%d, table=%d", o.Kind, o.U1, o.U2)
 
 	case operationKindDrop:
@@ -933,6 +1150,41 @@ func (o unionOperation) String() string {
 	case operationKindPick, operationKindSet:
 		return fmt.Sprintf("%s %d (is_vector=%v)", o.Kind, o.U1, o.B3)
 
+	case operationKindLocalGet, operationKindLocalSet, operationKindLocalTee:
+		return fmt.Sprintf("%s %d (is_vector=%v)", o.Kind, o.U1, o.B3)
+
+	case operationKindArithLocal, operationKindArithLocals, operationKindArithLocalConst:
+		var operands string
+		switch o.Kind {
+		case operationKindArithLocal:
+			operands = fmt.Sprintf("%d", o.U1)
+		case operationKindArithLocals:
+			operands = fmt.Sprintf("%d %d", o.U1, o.U2)
+		default:
+			operands = fmt.Sprintf("%d $%d", o.U1, int64(o.U2))
+		}
+		if o.B3 {
+			return fmt.Sprintf("%s.%s %s %s -> %d", unsignedType(o.B1), o.Kind, arithOp(o.B2), operands, o.U3)
+		}
+		return fmt.Sprintf("%s.%s %s %s", unsignedType(o.B1), o.Kind, arithOp(o.B2), operands)
+
+	case operationKindCompareLocals:
+		return fmt.Sprintf("%s.%s %s %d %d", unsignedType(o.B1), o.Kind, compareOp(o.B2), o.U1, o.U2)
+
+	case operationKindCompareLocalConst:
+		return fmt.Sprintf("%s.%s %s %d $%d", unsignedType(o.B1), o.Kind, compareOp(o.B2), o.U1, int64(o.U2))
+
+	case operationKindBrIfCompare, operationKindBrIfCompareLocals, operationKindBrIfCompareLocalConst:
+		var operands string
+		switch {
+		case len(o.Us) < 2:
+		case o.Kind == operationKindBrIfCompareLocals:
+			operands = fmt.Sprintf(" %d %d", o.Us[0], o.Us[1])
+		case o.Kind == operationKindBrIfCompareLocalConst:
+			operands = fmt.Sprintf(" %d $%d", o.Us[0], int64(o.Us[1]))
+		}
+		return fmt.Sprintf("%s.%s %s%s, %s, %s", unsignedType(o.B1), o.Kind, compareOp(o.B2), operands, label(o.U1), label(o.U2))
+
 	case operationKindLoad, operationKindStore:
 		return fmt.Sprintf("%s.%s (align=%d, offset=%d)", unsignedType(o.B1), o.Kind, o.U1, o.U2)
 
@@ -1073,6 +1325,18 @@ func (o unionOperation) String() string {
 		operationKindV128Narrow:
 		return o.Kind.String()
 
+	case operationKindV128RelaxedMadd:
+		if o.B3 {
+			return fmt.Sprintf("%s.%sNeg", o.Kind, shapeName(o.B1))
+		}
+		return fmt.Sprintf("%s.%s", o.Kind, shapeName(o.B1))
+
+	case operationKindV128RelaxedDot:
+		if o.B3 {
+			return fmt.Sprintf("%s.Add", o.Kind)
+		}
+		return o.Kind.String()
+
 	case operationKindV128ITruncSatFromF:
 		if o.B3 {
 			return fmt.Sprintf("%s.%sS", o.Kind, shapeName(o.B1))
@@ -1128,7 +1392,7 @@ func newOperationBr(target label) unionOperation {
 // NewOperationBrIf is a constructor for unionOperation with operationKindBrIf.
 //
 // The engines are expected to pop a value and branch into U1 label if the value equals 1.
-// Otherwise, the code branches into U2 label.
+// Otherwise, the code branches into U2 label. If B3 is set, which is i32.eqz fused in, the condition is inverted.
 func newOperationBrIf(thenTarget, elseTarget label, thenDrop inclusiveRange) unionOperation {
 	return unionOperation{
 		Kind: operationKindBrIf,
@@ -1279,7 +1543,12 @@ type memoryArg struct {
 
 	// Offset is the address offset added to the instruction's dynamic address operand, yielding a 33-bit effective
 	// address that is the zero-based index at which the memory is accessed. Default to zero.
-	Offset uint32
+	//
+	// With a 64-bit memory, the offset is 64-bit, and the effective address overflowing 64-bit is out of bounds.
+	Offset uint64
+
+	// MemoryIndex is the index of the accessed memory, which is non-zero only with multiple memories. Default to zero.
+	MemoryIndex uint32
 }
 
 // NewOperationLoad is a constructor for unionOperation with operationKindLoad.
@@ -1289,7 +1558,7 @@ type memoryArg struct {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise load the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationLoad(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationLoad8 is a constructor for unionOperation with operationKindLoad8.
@@ -1299,7 +1568,7 @@ func newOperationLoad(unsignedType unsignedType, arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise load the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationLoad8(signedInt signedInt, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindLoad8, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindLoad8, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationLoad16 is a constructor for unionOperation with operationKindLoad16.
@@ -1309,7 +1578,7 @@ func newOperationLoad8(signedInt signedInt, arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise load the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationLoad16(signedInt signedInt, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindLoad16, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindLoad16, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationLoad32 is a constructor for unionOperation with operationKindLoad32.
@@ -1323,7 +1592,7 @@ func newOperationLoad32(signed bool, arg memoryArg) unionOperation {
 	if signed {
 		sigB = 1
 	}
-	return unionOperation{Kind: operationKindLoad32, B1: sigB, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindLoad32, B1: sigB, U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationStore is a constructor for unionOperation with operationKindStore.
@@ -1333,7 +1602,7 @@ func newOperationLoad32(signed bool, arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationStore(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationStore8 is a constructor for unionOperation with operationKindStore8.
@@ -1343,7 +1612,7 @@ func newOperationStore(unsignedType unsignedType, arg memoryArg) unionOperation
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationStore8(arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindStore8, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindStore8, U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationStore16 is a constructor for unionOperation with operationKindStore16.
@@ -1353,7 +1622,7 @@ func newOperationStore8(arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationStore16(arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindStore16, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindStore16, U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationStore32 is a constructor for unionOperation with operationKindStore32.
@@ -1363,7 +1632,7 @@ func newOperationStore16(arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationStore32(arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindStore32, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindStore32, U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationMemorySize is a constructor for unionOperation with operationKindMemorySize.
@@ -1371,8 +1640,8 @@ func newOperationStore32(arg memoryArg) unionOperation {
 // This corresponds to wasm.OpcodeMemorySize.
 //
 // The engines are expected to push the current page size of the memory onto the stack.
-func newOperationMemorySize() unionOperation {
-	return unionOperation{Kind: operationKindMemorySize}
+func newOperationMemorySize(memoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemorySize, U3: uint64(memoryIndex)}
 }
 
 // NewOperationMemoryGrow is a constructor for unionOperation with operationKindMemoryGrow.
@@ -1382,8 +1651,8 @@ func newOperationMemorySize() unionOperation {
 // The engines are expected to pop one value from the top of the stack, then
 // execute wasm.MemoryInstance Grow with the value, and push the previous
 // page size of the memory onto the stack.
-func newOperationMemoryGrow() unionOperation {
-	return unionOperation{Kind: operationKindMemoryGrow}
+func newOperationMemoryGrow(memoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemoryGrow, U3: uint64(memoryIndex)}
 }
 
 // NewOperationConstI32 is a constructor for unionOperation with OperationConstI32.
@@ -1865,9 +2134,9 @@ func newOperationSignExtend64From32() unionOperation {
 // This corresponds to wasm.OpcodeMemoryInitName.
 //
 // dataIndex is the index of the data instance in ModuleInstance.DataInstances
-// by which this operation instantiates a part of the memory.
-func newOperationMemoryInit(dataIndex uint32) unionOperation {
-	return unionOperation{Kind: operationKindMemoryInit, U1: uint64(dataIndex)}
+// by which this operation instantiates a part of the memory at memoryIndex.
+func newOperationMemoryInit(dataIndex, memoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemoryInit, U1: uint64(dataIndex), U3: uint64(memoryIndex)}
 }
 
 // NewOperationDataDrop implements Operation.
@@ -1883,13 +2152,13 @@ func newOperationDataDrop(dataIndex uint32) unionOperation {
 // NewOperationMemoryCopy is a consuctor for unionOperation with operationKindMemoryCopy.
 //
 // This corresponds to wasm.OpcodeMemoryCopyName.
-func newOperationMemoryCopy() unionOperation {
-	return unionOperation{Kind: operationKindMemoryCopy}
+func newOperationMemoryCopy(dstMemoryIndex, srcMemoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemoryCopy, U1: uint64(srcMemoryIndex), U3: uint64(dstMemoryIndex)}
 }
 
 // NewOperationMemoryFill is a consuctor for unionOperation with operationKindMemoryFill.
-func newOperationMemoryFill() unionOperation {
-	return unionOperation{Kind: operationKindMemoryFill}
+func newOperationMemoryFill(memoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemoryFill, U3: uint64(memoryIndex)}
 }
 
 // NewOperationTableInit is a constructor for unionOperation with operationKindTableInit.
@@ -2060,7 +2329,7 @@ const (
 //	wasm.OpcodeVecV128Load32SplatName wasm.OpcodeVecV128Load64SplatName wasm.OpcodeVecV128Load32zeroName
 //	wasm.OpcodeVecV128Load64zeroName
 func newOperationV128Load(loadType v128LoadType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindV128Load, B1: loadType, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindV128Load, B1: loadType, U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationV128LoadLane is a constructor for unionOperation with operationKindV128LoadLane.
@@ -2072,7 +2341,7 @@ func newOperationV128Load(loadType v128LoadType, arg memoryArg) unionOperation {
 // laneIndex is >=0 && <(128/LaneSize).
 // laneSize is either 8, 16, 32, or 64.
 func newOperationV128LoadLane(laneIndex, laneSize byte, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindV128LoadLane, B1: laneSize, B2: laneIndex, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindV128LoadLane, B1: laneSize, B2: laneIndex, U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationV128Store is a constructor for unionOperation with operationKindV128Store.
@@ -2084,7 +2353,8 @@ func newOperationV128Store(arg memoryArg) unionOperation {
 	return unionOperation{
 		Kind: operationKindV128Store,
 		U1:   uint64(arg.Alignment),
-		U2:   uint64(arg.Offset),
+		U2:   arg.Offset,
+		U3:   uint64(arg.MemoryIndex),
 	}
 }
 
@@ -2102,7 +2372,8 @@ func newOperationV128StoreLane(laneIndex byte, laneSize byte, arg memoryArg) uni
 		B1:   laneSize,
 		B2:   laneIndex,
 		U1:   uint64(arg.Alignment),
-		U2:   uint64(arg.Offset),
+		U2:   arg.Offset,
+		U3:   uint64(arg.MemoryIndex),
 	}
 }
 
@@ -2670,7 +2941,7 @@ const (
 //
 //	wasm.OpcodeAtomicWait32Name wasm.OpcodeAtomicWait64Name
 func newOperationAtomicMemoryWait(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicMemoryWait, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicMemoryWait, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicMemoryNotify is a constructor for unionOperation with operationKindAtomicMemoryNotify.
@@ -2679,7 +2950,7 @@ func newOperationAtomicMemoryWait(unsignedType unsignedType, arg memoryArg) unio
 //
 //	wasm.OpcodeAtomicNotifyName
 func newOperationAtomicMemoryNotify(arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicMemoryNotify, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicMemoryNotify, U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicFence is a constructor for unionOperation with operationKindAtomicFence.
@@ -2697,7 +2968,7 @@ func newOperationAtomicFence() unionOperation {
 //
 //	wasm.OpcodeAtomicI32LoadName wasm.OpcodeAtomicI64LoadName
 func newOperationAtomicLoad(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicLoad8 is a constructor for unionOperation with operationKindAtomicLoad8.
@@ -2706,7 +2977,7 @@ func newOperationAtomicLoad(unsignedType unsignedType, arg memoryArg) unionOpera
 //
 //	wasm.OpcodeAtomicI32Load8UName wasm.OpcodeAtomicI64Load8UName
 func newOperationAtomicLoad8(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicLoad8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicLoad8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicLoad16 is a constructor for unionOperation with operationKindAtomicLoad16.
@@ -2715,7 +2986,7 @@ func newOperationAtomicLoad8(unsignedType unsignedType, arg memoryArg) unionOper
 //
 //	wasm.OpcodeAtomicI32Load16UName wasm.OpcodeAtomicI64Load16UName
 func newOperationAtomicLoad16(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicLoad16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicLoad16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicStore is a constructor for unionOperation with operationKindAtomicStore.
@@ -2724,7 +2995,7 @@ func newOperationAtomicLoad16(unsignedType unsignedType, arg memoryArg) unionOpe
 //
 //	wasm.OpcodeAtomicI32StoreName wasm.OpcodeAtomicI64StoreName
 func newOperationAtomicStore(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicStore8 is a constructor for unionOperation with operationKindAtomicStore8.
@@ -2733,7 +3004,7 @@ func newOperationAtomicStore(unsignedType unsignedType, arg memoryArg) unionOper
 //
 //	wasm.OpcodeAtomicI32Store8UName wasm.OpcodeAtomicI64Store8UName
 func newOperationAtomicStore8(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicStore8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicStore8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicStore16 is a constructor for unionOperation with operationKindAtomicStore16.
@@ -2742,7 +3013,7 @@ func newOperationAtomicStore8(unsignedType unsignedType, arg memoryArg) unionOpe
 //
 //	wasm.OpcodeAtomicI32Store16UName wasm.OpcodeAtomicI64Store16UName
 func newOperationAtomicStore16(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicStore16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicStore16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicRMW is a constructor for unionOperation with operationKindAtomicRMW.
@@ -2755,7 +3026,7 @@ func newOperationAtomicStore16(unsignedType unsignedType, arg memoryArg) unionOp
 //	wasm.OpcodeAtomicI32RMWOrName wasm.OpcodeAtomicI64RmwOrName
 //	wasm.OpcodeAtomicI32RMWXorName wasm.OpcodeAtomicI64RmwXorName
 func newOperationAtomicRMW(unsignedType unsignedType, arg memoryArg, op atomicArithmeticOp) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicRMW8 is a constructor for unionOperation with operationKindAtomicRMW8.
@@ -2768,7 +3039,7 @@ func newOperationAtomicRMW(unsignedType unsignedType, arg memoryArg, op atomicAr
 //	wasm.OpcodeAtomicI32RMW8OrUName wasm.OpcodeAtomicI64Rmw8OrUName
 //	wasm.OpcodeAtomicI32RMW8XorUName wasm.OpcodeAtomicI64Rmw8XorUName
 func newOperationAtomicRMW8(unsignedType unsignedType, arg memoryArg, op atomicArithmeticOp) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW8, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW8, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicRMW16 is a constructor for unionOperation with operationKindAtomicRMW16.
@@ -2781,7 +3052,7 @@ func newOperationAtomicRMW8(unsignedType unsignedType, arg memoryArg, op atomicA
 //	wasm.OpcodeAtomicI32RMW16OrUName wasm.OpcodeAtomicI64Rmw16OrUName
 //	wasm.OpcodeAtomicI32RMW16XorUName wasm.OpcodeAtomicI64Rmw16XorUName
 func newOperationAtomicRMW16(unsignedType unsignedType, arg memoryArg, op atomicArithmeticOp) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW16, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW16, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicRMWCmpxchg is a constructor for unionOperation with operationKindAtomicRMWCmpxchg.
@@ -2790,7 +3061,7 @@ func newOperationAtomicRMW16(unsignedType unsignedType, arg memoryArg, op atomic
 //
 //	wasm.OpcodeAtomicI32RMWCmpxchgName wasm.OpcodeAtomicI64RmwCmpxchgName
 func newOperationAtomicRMWCmpxchg(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMWCmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMWCmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicRMW8Cmpxchg is a constructor for unionOperation with operationKindAtomicRMW8Cmpxchg.
@@ -2799,7 +3070,7 @@ func newOperationAtomicRMWCmpxchg(unsignedType unsignedType, arg memoryArg) unio
 //
 //	wasm.OpcodeAtomicI32RMW8CmpxchgUName wasm.OpcodeAtomicI64Rmw8CmpxchgUName
 func newOperationAtomicRMW8Cmpxchg(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW8Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW8Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
 }
 
 // NewOperationAtomicRMW16Cmpxchg is a constructor for unionOperation with operationKindAtomicRMW16Cmpxchg.
@@ -2808,5 +3079,472 @@ func newOperationAtomicRMW8Cmpxchg(unsignedType unsignedType, arg memoryArg) uni
 //
 //	wasm.OpcodeAtomicI32RMW16CmpxchgUName wasm.OpcodeAtomicI64Rmw16CmpxchgUName
 func newOperationAtomicRMW16Cmpxchg(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW16Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW16Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: arg.Offset, U3: uint64(arg.MemoryIndex)}
+}
+
+// NewOperationTailCallReturnCall is a constructor for unionOperation with operationKindTailCallReturnCall.
+//
+// This corresponds to wasm.OpcodeTailCallReturnCallName, and engines are expected to
+// replace the current function frame with the one of the function whose index equals functionIndex.
+// The parameters of the callee must be placed right above the base of the current frame.
+func newOperationTailCallReturnCall(functionIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindTailCallReturnCall, U1: uint64(functionIndex)}
+}
+
+// NewOperationTailCallReturnCallIndirect is a constructor for unionOperation with operationKindTailCallReturnCallIndirect.
+//
+// This corresponds to wasm.OpcodeTailCallReturnCallIndirectName, and performs the same checks as
+// newOperationCallIndirect before replacing the current function frame with the one of the target function.
+func newOperationTailCallReturnCallIndirect(typeIndex, tableIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindTailCallReturnCallIndirect, U1: uint64(typeIndex), U2: uint64(tableIndex)}
+}
+
+// NewOperationThrow is a constructor for unionOperation with operationKindThrow.
+//
+// This corresponds to wasm.OpcodeThrowName, and engines are expected to pop the params of the tag whose index
+// equals tagIndex as the payload, and transfer the control to the innermost matching catch clause.
+func newOperationThrow(tagIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindThrow, U1: uint64(tagIndex)}
+}
+
+// NewOperationThrowRef is a constructor for unionOperation with operationKindThrowRef.
+//
+// This corresponds to wasm.OpcodeThrowRefName, and engines are expected to pop the exnref, and rethrow the
+// exception it references.
+func newOperationThrowRef() unionOperation {
+	return unionOperation{Kind: operationKindThrowRef}
+}
+
+// NewOperationCallRef is a constructor for unionOperation with operationKindCallRef.
+//
+// This corresponds to wasm.OpcodeCallRefName, and engines are expected to pop the function reference, and call
+// the referenced function after ensuring that it is non-null and its type matches the type at typeIndex.
+func newOperationCallRef(typeIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindCallRef, U1: uint64(typeIndex)}
+}
+
+// NewOperationTailCallReturnCallRef is a constructor for unionOperation with operationKindTailCallReturnCallRef.
+//
+// This corresponds to wasm.OpcodeReturnCallRefName, and performs the same checks as newOperationCallRef before
+// replacing the current function frame with the one of the referenced function.
+func newOperationTailCallReturnCallRef(typeIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindTailCallReturnCallRef, U1: uint64(typeIndex)}
+}
+
+// NewOperationRefAsNonNull is a constructor for unionOperation with operationKindRefAsNonNull.
+//
+// This corresponds to wasm.OpcodeRefAsNonNullName, and engines are expected to trap if the reference on the top of
+// the stack is null.
+func newOperationRefAsNonNull() unionOperation {
+	return unionOperation{Kind: operationKindRefAsNonNull}
+}
+
+// NewOperationRefTest is a constructor for unionOperation with operationKindRefTest.
+//
+// This corresponds to wasm.OpcodeGCRefTestName and wasm.OpcodeGCRefTestNullName, and engines are expected to pop the
+// reference, and push 1 if it is an instance of the heap type, 0 otherwise. A null reference is an instance if
+// nullable is true.
+func newOperationRefTest(heapType int64, nullable bool) unionOperation {
+	return unionOperation{Kind: operationKindRefTest, U1: uint64(heapType), B3: nullable}
+}
+
+// NewOperationRefCast is a constructor for unionOperation with operationKindRefCast.
+//
+// This corresponds to wasm.OpcodeGCRefCastName and wasm.OpcodeGCRefCastNullName, and engines are expected to trap
+// unless the reference on the top of the stack is an instance of the heap type, following newOperationRefTest.
+func newOperationRefCast(heapType int64, nullable bool) unionOperation {
+	return unionOperation{Kind: operationKindRefCast, U1: uint64(heapType), B3: nullable}
+}
+
+// NewOperationStructNew is a constructor for unionOperation with operationKindStructNew.
+//
+// This corresponds to wasm.OpcodeGCStructNewName and wasm.OpcodeGCStructNewDefaultName, and engines are expected to
+// allocate a struct of the type at typeIndex, whose fields are popped from the stack unless isDefault is true.
+func newOperationStructNew(typeIndex uint32, isDefault bool) unionOperation {
+	return unionOperation{Kind: operationKindStructNew, U1: uint64(typeIndex), B3: isDefault}
+}
+
+// NewOperationStructGet is a constructor for unionOperation with operationKindStructGet.
+//
+// This corresponds to wasm.OpcodeGCStructGetName, wasm.OpcodeGCStructGetSName and wasm.OpcodeGCStructGetUName, and
+// engines are expected to pop the struct reference, and push the value of the field. Packed values are sign-extended
+// if signed is true.
+func newOperationStructGet(typeIndex, fieldIndex uint32, signed bool) unionOperation {
+	return unionOperation{Kind: operationKindStructGet, U1: uint64(typeIndex), U2: uint64(fieldIndex), B3: signed}
+}
+
+// NewOperationStructSet is a constructor for unionOperation with operationKindStructSet.
+//
+// This corresponds to wasm.OpcodeGCStructSetName, and engines are expected to pop the value and the struct reference,
+// and set the value to the field.
+func newOperationStructSet(typeIndex, fieldIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindStructSet, U1: uint64(typeIndex), U2: uint64(fieldIndex)}
+}
+
+// NewOperationArrayNew is a constructor for unionOperation with operationKindArrayNew.
+//
+// This corresponds to wasm.OpcodeGCArrayNewName and wasm.OpcodeGCArrayNewDefaultName, and engines are expected to pop
+// the length and, unless isDefault is true, the initial value of the elements, and allocate an array of the type.
+func newOperationArrayNew(typeIndex uint32, isDefault bool) unionOperation {
+	return unionOperation{Kind: operationKindArrayNew, U1: uint64(typeIndex), B3: isDefault}
+}
+
+// NewOperationArrayNewFixed is a constructor for unionOperation with operationKindArrayNewFixed.
+//
+// This corresponds to wasm.OpcodeGCArrayNewFixedName, and engines are expected to allocate an array of the type
+// with the length elements popped from the stack.
+func newOperationArrayNewFixed(typeIndex, length uint32) unionOperation {
+	return unionOperation{Kind: operationKindArrayNewFixed, U1: uint64(typeIndex), U2: uint64(length)}
+}
+
+// NewOperationArrayNewData is a constructor for unionOperation with operationKindArrayNewData.
+//
+// This corresponds to wasm.OpcodeGCArrayNewDataName, and engines are expected to pop the offset in the data
+// instance and the length, and allocate an array of the type initialized with the data.
+func newOperationArrayNewData(typeIndex, dataIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindArrayNewData, U1: uint64(typeIndex), U2: uint64(dataIndex)}
+}
+
+// NewOperationArrayNewElem is a constructor for unionOperation with operationKindArrayNewElem.
+//
+// This corresponds to wasm.OpcodeGCArrayNewElemName, and engines are expected to pop the offset in the element
+// instance and the length, and allocate an array of the type initialized with the references.
+func newOperationArrayNewElem(typeIndex, elemIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindArrayNewElem, U1: uint64(typeIndex), U2: uint64(elemIndex)}
+}
+
+// NewOperationArrayGet is a constructor for unionOperation with operationKindArrayGet.
+//
+// This corresponds to wasm.OpcodeGCArrayGetName, wasm.OpcodeGCArrayGetSName and wasm.OpcodeGCArrayGetUName, and
+// engines are expected to pop the index and the array reference, and push the element. Packed values are
+// sign-extended if signed is true.
+func newOperationArrayGet(typeIndex uint32, signed bool) unionOperation {
+	return unionOperation{Kind: operationKindArrayGet, U1: uint64(typeIndex), B3: signed}
+}
+
+// NewOperationArraySet is a constructor for unionOperation with operationKindArraySet.
+//
+// This corresponds to wasm.OpcodeGCArraySetName, and engines are expected to pop the value, the index and the array
+// reference, and set the value to the element.
+func newOperationArraySet(typeIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindArraySet, U1: uint64(typeIndex)}
+}
+
+// NewOperationArrayLen is a constructor for unionOperation with operationKindArrayLen.
+//
+// This corresponds to wasm.OpcodeGCArrayLenName, and engines are expected to pop the array reference, and push its
+// length.
+func newOperationArrayLen() unionOperation {
+	return unionOperation{Kind: operationKindArrayLen}
+}
+
+// NewOperationArrayFill is a constructor for unionOperation with operationKindArrayFill.
+//
+// This corresponds to wasm.OpcodeGCArrayFillName, and engines are expected to pop the length, the value, the offset
+// and the array reference, and set the value to the elements in the range.
+func newOperationArrayFill(typeIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindArrayFill, U1: uint64(typeIndex)}
+}
+
+// NewOperationArrayCopy is a constructor for unionOperation with operationKindArrayCopy.
+//
+// This corresponds to wasm.OpcodeGCArrayCopyName, and engines are expected to pop the length, the source offset, the
+// source array, the destination offset and the destination array, and copy the elements.
+func newOperationArrayCopy(dstTypeIndex, srcTypeIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindArrayCopy, U1: uint64(dstTypeIndex), U2: uint64(srcTypeIndex)}
+}
+
+// NewOperationArrayInitData is a constructor for unionOperation with operationKindArrayInitData.
+//
+// This corresponds to wasm.OpcodeGCArrayInitDataName, and engines are expected to pop the length, the offset in the
+// data instance, the offset in the array and the array reference, and copy the data into the elements.
+func newOperationArrayInitData(typeIndex, dataIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindArrayInitData, U1: uint64(typeIndex), U2: uint64(dataIndex)}
+}
+
+// NewOperationArrayInitElem is a constructor for unionOperation with operationKindArrayInitElem.
+//
+// This corresponds to wasm.OpcodeGCArrayInitElemName, and engines are expected to pop the length, the offset in the
+// element instance, the offset in the array and the array reference, and copy the references into the elements.
+func newOperationArrayInitElem(typeIndex, elemIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindArrayInitElem, U1: uint64(typeIndex), U2: uint64(elemIndex)}
+}
+
+// NewOperationRefI31 is a constructor for unionOperation with operationKindRefI31.
+//
+// This corresponds to wasm.OpcodeGCRefI31Name, and engines are expected to pop the i32 value, and push the i31
+// reference of its lower 31 bits.
+func newOperationRefI31() unionOperation {
+	return unionOperation{Kind: operationKindRefI31}
+}
+
+// NewOperationI31Get is a constructor for unionOperation with operationKindI31Get.
+//
+// This corresponds to wasm.OpcodeGCI31GetSName and wasm.OpcodeGCI31GetUName, and engines are expected to pop the
+// i31 reference, and push its value, which is sign-extended if signed is true.
+func newOperationI31Get(signed bool) unionOperation {
+	return unionOperation{Kind: operationKindI31Get, B3: signed}
+}
+
+// NewOperationAnyConvertExtern is a constructor for unionOperation with operationKindAnyConvertExtern.
+//
+// This corresponds to wasm.OpcodeGCAnyConvertExternName, and engines are expected to convert the external reference
+// on the top of the stack to an internal one.
+func newOperationAnyConvertExtern() unionOperation {
+	return unionOperation{Kind: operationKindAnyConvertExtern}
+}
+
+// NewOperationExternConvertAny is a constructor for unionOperation with operationKindExternConvertAny.
+//
+// This corresponds to wasm.OpcodeGCExternConvertAnyName, and engines are expected to convert the internal reference
+// on the top of the stack to an external one.
+func newOperationExternConvertAny() unionOperation {
+	return unionOperation{Kind: operationKindExternConvertAny}
+}
+
+// NewOperationV128RelaxedMadd is a constructor for unionOperation with operationKindV128RelaxedMadd.
+//
+// This corresponds to
+//
+//	wasm.OpcodeVecF32x4RelaxedMaddName wasm.OpcodeVecF32x4RelaxedNmaddName
+//	wasm.OpcodeVecF64x2RelaxedMaddName wasm.OpcodeVecF64x2RelaxedNmaddName
+//
+// shape is either shapeF32x4 or shapeF64x2, and negate is true for nmadd. The product is always rounded before the
+// addition, i.e. this is never fused.
+func newOperationV128RelaxedMadd(shape shape, negate bool) unionOperation {
+	return unionOperation{Kind: operationKindV128RelaxedMadd, B1: shape, B3: negate}
+}
+
+// NewOperationV128RelaxedDot is a constructor for unionOperation with operationKindV128RelaxedDot.
+//
+// This corresponds to wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16SName if add is false, or otherwise
+// wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName. Both operands are treated as signed.
+func newOperationV128RelaxedDot(add bool) unionOperation {
+	return unionOperation{Kind: operationKindV128RelaxedDot, B3: add}
+}
+
+// NewOperationConsumeFuel is a constructor for unionOperation with operationKindConsumeFuel.
+//
+// OperationConsumeFuel subtracts cost from the fuel remaining for the current call, and traps with sys.ErrOutOfFuel
+// if not enough is remaining. This is emitted by the compiler according to wasm.FuelCost when the module has FuelCosts.
+func newOperationConsumeFuel(cost uint64) unionOperation {
+	return unionOperation{Kind: operationKindConsumeFuel, U1: cost}
+}
+
+// NewOperationDebugStep is a constructor for unionOperation with operationKindDebugStep.
+//
+// OperationDebugStep notifies the debugger of the call, if any, before the instruction at its source offset, where the
+// debugger can stop the call. This is emitted by the compiler before each instruction when the module has Debugging.
+func newOperationDebugStep() unionOperation {
+	return unionOperation{Kind: operationKindDebugStep}
+}
+
+// NewOperationCoverage is a constructor for unionOperation with operationKindCoverage.
+//
+// OperationCoverage notifies the coverage collector of the call, if any, that the block at the index has been executed.
+// This is emitted by the compiler before the last instruction of each block when the module has Coverage.
+func newOperationCoverage(block uint64) unionOperation {
+	return unionOperation{Kind: operationKindCoverage, U1: block}
+}
+
+// NewOperationLocalGet is a constructor for unionOperation with operationKindLocalGet.
+//
+// The engines are expected to copy the value in the register slot of the current frame, and push the copied value onto
+// the top of the stack.
+//
+// slot is the location of the local in the uint64 value stack counted from the first param of the current frame.
+// If isTargetVector=true, this points to the location of the lower 64-bits of the vector.
+func newOperationLocalGet(slot int, isTargetVector bool) unionOperation {
+	return unionOperation{Kind: operationKindLocalGet, U1: uint64(slot), B3: isTargetVector}
+}
+
+// NewOperationLocalSet is a constructor for unionOperation with operationKindLocalSet.
+//
+// The engines are expected to pop the top value of the stack, and store it into the register slot of the current frame.
+//
+// slot is the same as in newOperationLocalGet.
+func newOperationLocalSet(slot int, isTargetVector bool) unionOperation {
+	return unionOperation{Kind: operationKindLocalSet, U1: uint64(slot), B3: isTargetVector}
+}
+
+// NewOperationLocalTee is a constructor for unionOperation with operationKindLocalTee.
+//
+// This is the same as operationKindLocalSet, except that the top value is left on the stack.
+func newOperationLocalTee(slot int, isTargetVector bool) unionOperation {
+	return unionOperation{Kind: operationKindLocalTee, U1: uint64(slot), B3: isTargetVector}
+}
+
+// arithOp is the binary integer operation performed by the arithmetic superinstructions.
+type arithOp byte
+
+const (
+	arithOpAdd arithOp = iota
+	arithOpSub
+	arithOpMul
+	arithOpAnd
+	arithOpOr
+	arithOpXor
+	arithOpShl
+	arithOpShrS
+	arithOpShrU
+)
+
+// String implements fmt.Stringer.
+func (a arithOp) String() (ret string) {
+	switch a {
+	case arithOpAdd:
+		ret = "add"
+	case arithOpSub:
+		ret = "sub"
+	case arithOpMul:
+		ret = "mul"
+	case arithOpAnd:
+		ret = "and"
+	case arithOpOr:
+		ret = "or"
+	case arithOpXor:
+		ret = "xor"
+	case arithOpShl:
+		ret = "shl"
+	case arithOpShrS:
+		ret = "shr_s"
+	case arithOpShrU:
+		ret = "shr_u"
+	}
+	return
+}
+
+// compareOp is the integer comparison performed by the comparison superinstructions.
+type compareOp byte
+
+const (
+	compareOpEq compareOp = iota
+	compareOpNe
+	compareOpLtS
+	compareOpLtU
+	compareOpGtS
+	compareOpGtU
+	compareOpLeS
+	compareOpLeU
+	compareOpGeS
+	compareOpGeU
+)
+
+// String implements fmt.Stringer.
+func (c compareOp) String() (ret string) {
+	switch c {
+	case compareOpEq:
+		ret = "eq"
+	case compareOpNe:
+		ret = "ne"
+	case compareOpLtS:
+		ret = "lt_s"
+	case compareOpLtU:
+		ret = "lt_u"
+	case compareOpGtS:
+		ret = "gt_s"
+	case compareOpGtU:
+		ret = "gt_u"
+	case compareOpLeS:
+		ret = "le_s"
+	case compareOpLeU:
+		ret = "le_u"
+	case compareOpGeS:
+		ret = "ge_s"
+	case compareOpGeU:
+		ret = "ge_u"
+	}
+	return
+}
+
+// NewOperationArithLocal is a constructor for unionOperation with operationKindArithLocal.
+//
+// This is the superinstruction fused from local.get and the binary integer operation a of type b: the engines are
+// expected to pop the top value, apply a to it and the value in the register slot `local`, and push the result. If dest
+// is non-negative, the result is stored into the register slot `dest` instead, which is local.set fused in addition.
+func newOperationArithLocal(b unsignedType, a arithOp, local, dest int) unionOperation {
+	return unionOperation{Kind: operationKindArithLocal, B1: byte(b), B2: byte(a), U1: uint64(local), U3: uint64(dest), B3: dest >= 0}
+}
+
+// NewOperationArithLocals is a constructor for unionOperation with operationKindArithLocals.
+//
+// This is the superinstruction fused from two local.get and the binary integer operation a: the engines are expected
+// to push the result of a applied to the values in the register slots `x1` and `x2`, or store it into the register
+// slot `dest` if non-negative.
+func newOperationArithLocals(b unsignedType, a arithOp, x1, x2, dest int) unionOperation {
+	return unionOperation{Kind: operationKindArithLocals, B1: byte(b), B2: byte(a), U1: uint64(x1), U2: uint64(x2), U3: uint64(dest), B3: dest >= 0}
+}
+
+// NewOperationArithLocalConst is a constructor for unionOperation with operationKindArithLocalConst.
+//
+// This is the superinstruction fused from local.get, i32.const (or i64.const) and the binary integer operation a: the
+// engines are expected to push the result of a applied to the value in the register slot `local` and c, or store it
+// into the register slot `dest` if non-negative.
+func newOperationArithLocalConst(b unsignedType, a arithOp, local int, c uint64, dest int) unionOperation {
+	return unionOperation{Kind: operationKindArithLocalConst, B1: byte(b), B2: byte(a), U1: uint64(local), U2: c, U3: uint64(dest), B3: dest >= 0}
+}
+
+// NewOperationCompareLocals is a constructor for unionOperation with operationKindCompareLocals.
+//
+// This is the superinstruction fused from two local.get and the integer comparison c of type b: the engines are
+// expected to push 1 if c holds for the values in the register slots `x1` and `x2`, or 0 otherwise.
+func newOperationCompareLocals(b unsignedType, c compareOp, x1, x2 int) unionOperation {
+	return unionOperation{Kind: operationKindCompareLocals, B1: byte(b), B2: byte(c), U1: uint64(x1), U2: uint64(x2)}
+}
+
+// NewOperationCompareLocalConst is a constructor for unionOperation with operationKindCompareLocalConst.
+//
+// This is the superinstruction fused from local.get, i32.const (or i64.const) and the integer comparison c, or from
+// local.get and i32.eqz (or i64.eqz) as the equality with zero: the engines are expected to push 1 if c holds for the
+// value in the register slot `local` and v, or 0 otherwise.
+func newOperationCompareLocalConst(b unsignedType, c compareOp, local int, v uint64) unionOperation {
+	return unionOperation{Kind: operationKindCompareLocalConst, B1: byte(b), B2: byte(c), U1: uint64(local), U2: v}
+}
+
+// NewOperationBrIfCompare is a constructor for unionOperation with operationKindBrIfCompare.
+//
+// This is the superinstruction fused from the integer comparison c of type b and operationKindBrIf: the engines are
+// expected to pop two values and branch into U1 label if c holds for them, as in operationKindBrIf.
+func newOperationBrIfCompare(b unsignedType, c compareOp, thenTarget, elseTarget label, thenDrop inclusiveRange) unionOperation {
+	return unionOperation{
+		Kind: operationKindBrIfCompare,
+		B1:   byte(b),
+		B2:   byte(c),
+		U1:   uint64(thenTarget),
+		U2:   uint64(elseTarget),
+		U3:   thenDrop.AsU64(),
+	}
+}
+
+// NewOperationBrIfCompareLocals is a constructor for unionOperation with operationKindBrIfCompareLocals.
+//
+// This is the superinstruction fused from operationKindCompareLocals and operationKindBrIf: the engines are expected to
+// branch into U1 label if c holds for the values in the register slots `x1` and `x2`, as in operationKindBrIf.
+func newOperationBrIfCompareLocals(b unsignedType, c compareOp, x1, x2 int, thenTarget, elseTarget label, thenDrop inclusiveRange) unionOperation {
+	return unionOperation{
+		Kind: operationKindBrIfCompareLocals,
+		B1:   byte(b),
+		B2:   byte(c),
+		U1:   uint64(thenTarget),
+		U2:   uint64(elseTarget),
+		U3:   thenDrop.AsU64(),
+		Us:   []uint64{uint64(x1), uint64(x2)},
+	}
+}
+
+// NewOperationBrIfCompareLocalConst is a constructor for unionOperation with operationKindBrIfCompareLocalConst.
+//
+// This is the superinstruction fused from operationKindCompareLocalConst and operationKindBrIf: the engines are
+// expected to branch into U1 label if c holds for the value in the register slot `local` and v, as in
+// operationKindBrIf.
+func newOperationBrIfCompareLocalConst(b unsignedType, c compareOp, local int, v uint64, thenTarget, elseTarget label, thenDrop inclusiveRange) unionOperation {
+	return unionOperation{
+		Kind: operationKindBrIfCompareLocalConst,
+		B1:   byte(b),
+		B2:   byte(c),
+		U1:   uint64(thenTarget),
+		U2:   uint64(elseTarget),
+		U3:   thenDrop.AsU64(),
+		Us:   []uint64{uint64(local), v},
+	}
 }
@@ -3,6 +3,8 @@ package interpreter
 import (
 	"fmt"
 
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
@@ -272,10 +274,23 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 		return signature_I32_None, nil
 	case wasm.OpcodeReturn:
 		return signature_None_None, nil
-	case wasm.OpcodeCall:
+	case wasm.OpcodeCall, wasm.OpcodeTailCallReturnCall:
 		return c.funcTypeToSigs.get(c.funcs[index], false /* direct */), nil
-	case wasm.OpcodeCallIndirect:
+	case wasm.OpcodeCallIndirect, wasm.OpcodeTailCallReturnCallIndirect:
 		return c.funcTypeToSigs.get(index, true /* call_indirect */), nil
+	case wasm.OpcodeCallRef, wasm.OpcodeReturnCallRef:
+		return c.funcTypeToSigs.getCallRef(index), nil
+	case wasm.OpcodeTryTable:
+		return signature_None_None, nil
+	case wasm.OpcodeThrow:
+		typeIndex, ok := c.module.TagTypeIndex(index)
+		if !ok {
+			return nil, fmt.Errorf("invalid tag index for throw %d", index)
+		}
+		// Tags have no results, so this pops the tag params.
+		return c.funcTypeToSigs.get(typeIndex, false), nil
+	case wasm.OpcodeThrowRef:
+		return signature_I64_None, nil
 	case wasm.OpcodeDrop:
 		return signature_Unknown_None, nil
 	case wasm.OpcodeSelect, wasm.OpcodeTypedSelect:
@@ -472,6 +487,15 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 	case wasm.OpcodeRefNull:
 		// ref.null is translated as i64.const 0.
 		return signature_None_I64, nil
+	case wasm.OpcodeRefAsNonNull:
+		// ref.as_non_null is translated as checking if the opaque pointer on the top of the stack is non-zero.
+		return signature_I64_I64, nil
+	case wasm.OpcodeRefEq:
+		// ref.eq is translated as comparing two opaque pointers as i64 values.
+		return signature_I64I64_I32, nil
+	case wasm.OpcodeBrOnNull, wasm.OpcodeBrOnNonNull, wasm.OpcodeGCPrefix:
+		// The stack is manipulated while lowering these instructions as their signatures depend on the immediates.
+		return signature_None_None, nil
 	case wasm.OpcodeMiscPrefix:
 		switch miscOp := c.body[c.pc+1]; miscOp {
 		case wasm.OpcodeMiscI32TruncSatF32S, wasm.OpcodeMiscI32TruncSatF32U:
@@ -497,6 +521,19 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 			return nil, fmt.Errorf("unsupported misc instruction in interpreterir: 0x%x", op)
 		}
 	case wasm.OpcodeVecPrefix:
+		if relaxedOp, ok := wasm.RelaxedVectorOpcode(c.body[c.pc+1:]); ok {
+			switch relaxedOp {
+			case wasm.OpcodeVecI32x4RelaxedTruncF32x4S, wasm.OpcodeVecI32x4RelaxedTruncF32x4U,
+				wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero, wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero:
+				return signature_V128_V128, nil
+			case wasm.OpcodeVecI8x16RelaxedSwizzle, wasm.OpcodeVecF32x4RelaxedMin, wasm.OpcodeVecF32x4RelaxedMax,
+				wasm.OpcodeVecF64x2RelaxedMin, wasm.OpcodeVecF64x2RelaxedMax, wasm.OpcodeVecI16x8RelaxedQ15mulrS,
+				wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+				return signature_V128V128_V128, nil
+			default:
+				return signature_V128V128V128_V32, nil
+			}
+		}
 		switch vecOp := c.body[c.pc+1]; vecOp {
 		case wasm.OpcodeVecV128Const:
 			return signature_None_V128, nil
@@ -650,6 +687,7 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 type funcTypeToIRSignatures struct {
 	directCalls   []*signature
 	indirectCalls []*signature
+	refCalls      []*signature
 	wasmTypes     []wasm.FunctionType
 }
 
@@ -694,13 +732,31 @@ func (f *funcTypeToIRSignatures) get(typeIndex wasm.Index, indirect bool) *signa
 	return sig
 }
 
+// getCallRef returns the *signature for call_ref against functions whose type is at `typeIndex`,
+// which takes the function reference in addition to the params.
+func (f *funcTypeToIRSignatures) getCallRef(typeIndex wasm.Index) *signature {
+	if sig := f.refCalls[typeIndex]; sig != nil {
+		return sig
+	}
+	direct := f.get(typeIndex, false)
+	sig := &signature{
+		in:  make([]unsignedType, 0, len(direct.in)+1), // +1 to reserve space for the function reference.
+		out: direct.out,
+	}
+	sig.in = append(append(sig.in, direct.in...), unsignedTypeI64)
+	f.refCalls[typeIndex] = sig
+	return sig
+}
+
 func wasmValueTypeTounsignedType(vt wasm.ValueType) unsignedType {
 	switch vt {
 	case wasm.ValueTypeI32:
 		return unsignedTypeI32
 	case wasm.ValueTypeI64,
 		// From interpreterir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref,
+		wasm.ValueTypeAnyref, wasm.ValueTypeEqref, wasm.ValueTypeI31ref, wasm.ValueTypeStructref, wasm.ValueTypeArrayref,
+		wasm.ValueTypeNullref, wasm.ValueTypeNullexternref, wasm.ValueTypeNullfuncref:
 		return unsignedTypeI64
 	case wasm.ValueTypeF32:
 		return unsignedTypeF32
@@ -718,7 +774,9 @@ func wasmValueTypeToUnsignedOutSignature(vt wasm.ValueType) *signature {
 		return signature_None_I32
 	case wasm.ValueTypeI64,
 		// From interpreterir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref,
+		wasm.ValueTypeAnyref, wasm.ValueTypeEqref, wasm.ValueTypeI31ref, wasm.ValueTypeStructref, wasm.ValueTypeArrayref,
+		wasm.ValueTypeNullref, wasm.ValueTypeNullexternref, wasm.ValueTypeNullfuncref:
 		return signature_None_I64
 	case wasm.ValueTypeF32:
 		return signature_None_F32
@@ -736,7 +794,9 @@ func wasmValueTypeToUnsignedInSignature(vt wasm.ValueType) *signature {
 		return signature_I32_None
 	case wasm.ValueTypeI64,
 		// From interpreterir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref,
+		wasm.ValueTypeAnyref, wasm.ValueTypeEqref, wasm.ValueTypeI31ref, wasm.ValueTypeStructref, wasm.ValueTypeArrayref,
+		wasm.ValueTypeNullref, wasm.ValueTypeNullexternref, wasm.ValueTypeNullfuncref:
 		return signature_I64_None
 	case wasm.ValueTypeF32:
 		return signature_F32_None
@@ -754,7 +814,9 @@ func wasmValueTypeToUnsignedInOutSignature(vt wasm.ValueType) *signature {
 		return signature_I32_I32
 	case wasm.ValueTypeI64,
 		// At interpreterir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref,
+		wasm.ValueTypeAnyref, wasm.ValueTypeEqref, wasm.ValueTypeI31ref, wasm.ValueTypeStructref, wasm.ValueTypeArrayref,
+		wasm.ValueTypeNullref, wasm.ValueTypeNullexternref, wasm.ValueTypeNullfuncref:
 		return signature_I64_I64
 	case wasm.ValueTypeF32:
 		return signature_F32_F32
@@ -765,3 +827,101 @@ func wasmValueTypeToUnsignedInOutSignature(vt wasm.ValueType) *signature {
 	}
 	panic("unreachable")
 }
+
+// memory64Signature returns the signature of the memory instruction op with its address operands replaced with i64
+// if the accessed memory is 64-bit. Otherwise, s is returned as-is.
+//
+// Note that this peeks the immediates without advancing c.pc, as they are read when the instruction is lowered.
+func (c *compiler) memory64Signature(op wasm.Opcode, s *signature) *signature {
+	var widenIn []int
+	var widenOut bool
+	switch op {
+	case wasm.OpcodeI32Load, wasm.OpcodeI64Load, wasm.OpcodeF32Load, wasm.OpcodeF64Load,
+		wasm.OpcodeI32Load8S, wasm.OpcodeI32Load8U, wasm.OpcodeI32Load16S, wasm.OpcodeI32Load16U,
+		wasm.OpcodeI64Load8S, wasm.OpcodeI64Load8U, wasm.OpcodeI64Load16S, wasm.OpcodeI64Load16U,
+		wasm.OpcodeI64Load32S, wasm.OpcodeI64Load32U,
+		wasm.OpcodeI32Store, wasm.OpcodeI64Store, wasm.OpcodeF32Store, wasm.OpcodeF64Store,
+		wasm.OpcodeI32Store8, wasm.OpcodeI32Store16, wasm.OpcodeI64Store8, wasm.OpcodeI64Store16, wasm.OpcodeI64Store32:
+		if c.isMemory64(c.peekMemArgMemoryIndex(c.pc + 1)) {
+			widenIn = []int{0}
+		}
+	case wasm.OpcodeMemorySize, wasm.OpcodeMemoryGrow:
+		index, _, _ := leb128.LoadUint32(c.body[c.pc+1:])
+		if c.isMemory64(index) {
+			widenIn, widenOut = make([]int, len(s.in)), true // memory.grow has the delta operand.
+		}
+	case wasm.OpcodeMiscPrefix:
+		pos := c.pc + 2
+		switch c.body[c.pc+1] {
+		case wasm.OpcodeMiscMemoryInit:
+			_, num, _ := leb128.LoadUint32(c.body[pos:])
+			index, _, _ := leb128.LoadUint32(c.body[pos+num:])
+			if c.isMemory64(index) {
+				widenIn = []int{0}
+			}
+		case wasm.OpcodeMiscMemoryCopy:
+			dst, num, _ := leb128.LoadUint32(c.body[pos:])
+			src, _, _ := leb128.LoadUint32(c.body[pos+num:])
+			dst64, src64 := c.isMemory64(dst), c.isMemory64(src)
+			if dst64 {
+				widenIn = append(widenIn, 0)
+			}
+			if src64 {
+				widenIn = append(widenIn, 1)
+			}
+			if dst64 && src64 {
+				widenIn = append(widenIn, 2)
+			}
+		case wasm.OpcodeMiscMemoryFill:
+			index, _, _ := leb128.LoadUint32(c.body[pos:])
+			if c.isMemory64(index) {
+				widenIn = []int{0, 2}
+			}
+		}
+	case wasm.OpcodeVecPrefix:
+		switch c.body[c.pc+1] {
+		case wasm.OpcodeVecV128Load, wasm.OpcodeVecV128Load8x8s, wasm.OpcodeVecV128Load8x8u,
+			wasm.OpcodeVecV128Load16x4s, wasm.OpcodeVecV128Load16x4u, wasm.OpcodeVecV128Load32x2s,
+			wasm.OpcodeVecV128Load32x2u, wasm.OpcodeVecV128Load8Splat, wasm.OpcodeVecV128Load16Splat,
+			wasm.OpcodeVecV128Load32Splat, wasm.OpcodeVecV128Load64Splat, wasm.OpcodeVecV128Load32zero,
+			wasm.OpcodeVecV128Load64zero,
+			wasm.OpcodeVecV128Load8Lane, wasm.OpcodeVecV128Load16Lane,
+			wasm.OpcodeVecV128Load32Lane, wasm.OpcodeVecV128Load64Lane,
+			wasm.OpcodeVecV128Store, wasm.OpcodeVecV128Store8Lane, wasm.OpcodeVecV128Store16Lane,
+			wasm.OpcodeVecV128Store32Lane, wasm.OpcodeVecV128Store64Lane:
+			if c.isMemory64(c.peekMemArgMemoryIndex(c.pc + 2)) {
+				widenIn = []int{0}
+			}
+		}
+	case wasm.OpcodeAtomicPrefix:
+		if c.body[c.pc+1] != wasm.OpcodeAtomicFence && c.isMemory64(c.peekMemArgMemoryIndex(c.pc+2)) {
+			widenIn = []int{0}
+		}
+	}
+
+	if len(widenIn) == 0 && !widenOut {
+		return s
+	}
+	widened := &signature{in: append([]unsignedType(nil), s.in...), out: s.out}
+	for _, i := range widenIn {
+		widened.in[i] = unsignedTypeI64
+	}
+	if widenOut {
+		widened.out = []unsignedType{unsignedTypeI64}
+	}
+	return widened
+}
+
+// peekMemArgMemoryIndex returns the memory index of the memarg immediate at pos in the body.
+func (c *compiler) peekMemArgMemoryIndex(pos uint64) uint32 {
+	align, num, _ := leb128.LoadUint32(c.body[pos:])
+	if align&wasm.MemArgMemoryIndexFlag == 0 || !c.enabledFeatures.IsEnabled(experimental.CoreFeaturesMultiMemory) {
+		return 0
+	}
+	index, _, _ := leb128.LoadUint32(c.body[pos+num:])
+	return index
+}
+
+func (c *compiler) isMemory64(index uint32) bool {
+	return int(index) < len(c.memories) && c.memories[index].Is64
+}
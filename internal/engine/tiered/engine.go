@@ -0,0 +1,171 @@
+// Package tiered implements a wasm.Engine which runs modules on the interpreter while the compiler compiles them in
+// the background, and then switches them to the compiled code.
+package tiered
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/interpreter"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo"
+	"github.com/tetratelabs/wazero/internal/filecache"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// The indexes of the ModuleEngines of each tier in moduleEngine.tiers.
+const (
+	tierInterpreter = iota
+	tierCompiler
+	tierCount
+)
+
+type (
+	// engine implements wasm.Engine.
+	engine struct {
+		interpreter, compiler wasm.Engine
+
+		// compilations are the background compilations of the modules by the compiler, keyed by module ID.
+		compilations map[wasm.ModuleID]*compilation // guarded by mux.
+		// pending is the number of background compilations in progress.
+		pending int // guarded by mux.
+		// closed is true once Close is called. If compilations are pending then, the last one closes the compiler.
+		closed bool // guarded by mux.
+		mux    sync.Mutex
+	}
+
+	// compilation is the background compilation of a module by the compiler.
+	compilation struct {
+		// done is set once the compilation is finished, after err.
+		done atomic.Bool
+		err  error
+		// deleted is true if the module is deleted before the compilation is done.
+		deleted bool // guarded by engine.mux.
+	}
+)
+
+var _ wasm.Engine = (*engine)(nil)
+
+// NewEngine returns the implementation of wasm.Engine.
+func NewEngine(ctx context.Context, enabledFeatures api.CoreFeatures, fc filecache.Cache) wasm.Engine {
+	return &engine{
+		interpreter:  interpreter.NewEngine(ctx, enabledFeatures, fc),
+		compiler:     wazevo.NewEngine(ctx, enabledFeatures, fc),
+		compilations: map[wasm.ModuleID]*compilation{},
+	}
+}
+
+// Close implements the same method as documented on wasm.Engine.
+func (e *engine) Close() (err error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.closed = true
+	e.compilations = nil
+	err = e.interpreter.Close()
+	if e.pending == 0 {
+		if compilerErr := e.compiler.Close(); err == nil {
+			err = compilerErr
+		}
+	}
+	return
+}
+
+// CompiledModuleCount implements the same method as documented on wasm.Engine.
+func (e *engine) CompiledModuleCount() uint32 {
+	return e.interpreter.CompiledModuleCount()
+}
+
+// DeleteCompiledModule implements the same method as documented on wasm.Engine.
+func (e *engine) DeleteCompiledModule(m *wasm.Module) {
+	e.interpreter.DeleteCompiledModule(m)
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if c, ok := e.compilations[m.ID]; ok {
+		delete(e.compilations, m.ID)
+		if !c.done.Load() {
+			// The compilation deletes the module once done.
+			c.deleted = true
+			return
+		}
+	}
+	e.compiler.DeleteCompiledModule(m)
+}
+
+// CompileModule implements the same method as documented on wasm.Engine.
+//
+// This only compiles the module with the interpreter before returning. The compiler compiles it in the background,
+// except for host modules, whose compilation is cheap.
+func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) error {
+	if err := e.interpreter.CompileModule(ctx, module, listeners, ensureTermination); err != nil {
+		return err
+	}
+	if module.IsHostModule {
+		return e.compiler.CompileModule(ctx, module, listeners, ensureTermination)
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if _, ok := e.compilations[module.ID]; ok || e.closed {
+		return nil
+	}
+	c := &compilation{}
+	e.compilations[module.ID] = c
+	e.pending++
+
+	// The compilation outlives the call, so it must not be canceled with it.
+	ctx = context.WithoutCancel(ctx)
+	go e.compile(ctx, c, module, listeners, ensureTermination)
+	return nil
+}
+
+// compile compiles the module with the compiler in the background.
+func (e *engine) compile(ctx context.Context, c *compilation, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			// The module keeps running on the interpreter rather than crashing the process.
+			c.err = fmt.Errorf("compiler panic: %v", r)
+		}
+		c.done.Store(true)
+
+		e.mux.Lock()
+		defer e.mux.Unlock()
+		e.pending--
+		if c.deleted && c.err == nil {
+			e.compiler.DeleteCompiledModule(module)
+		}
+		if e.closed && e.pending == 0 {
+			_ = e.compiler.Close()
+		}
+	}()
+	c.err = e.compiler.CompileModule(ctx, module, listeners, ensureTermination)
+}
+
+// NewModuleEngine implements the same method as documented on wasm.Engine.
+func (e *engine) NewModuleEngine(module *wasm.Module, instance *wasm.ModuleInstance) (wasm.ModuleEngine, error) {
+	me := &moduleEngine{parent: e, module: module, instance: instance}
+
+	interpreterME, err := e.interpreter.NewModuleEngine(module, instance)
+	if err != nil {
+		return nil, err
+	}
+	me.tiers[tierInterpreter] = interpreterME
+
+	if module.IsHostModule {
+		// Host modules run on both tiers, so that the modules importing them can run on either.
+		compilerME, err := e.compiler.NewModuleEngine(module, instance)
+		if err != nil {
+			return nil, err
+		}
+		me.tiers[tierCompiler] = compilerME
+		return me, nil
+	}
+
+	e.mux.Lock()
+	me.compilation = e.compilations[module.ID]
+	e.mux.Unlock()
+	return me, nil
+}
@@ -0,0 +1,382 @@
+package tiered_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var testCtx = context.Background()
+
+// counterWasm exports "inc", which increments the global "counter" and passes it to the imported "env.observe", and
+// "inc_indirect", which calls "inc" via its table.
+var counterWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Results: []wasm.ValueType{wasm.ValueTypeI64}, ResultNumInUint64: 1},
+		{Params: []wasm.ValueType{wasm.ValueTypeI64}, ParamNumInUint64: 1},
+	},
+	ImportSection:   []wasm.Import{{Module: "env", Name: "observe", Type: wasm.ExternTypeFunc, DescFunc: 1}},
+	FunctionSection: []wasm.Index{0, 0},
+	TableSection:    []wasm.Table{{Min: 1, Type: wasm.RefTypeFuncref}},
+	GlobalSection: []wasm.Global{{
+		Type: wasm.GlobalType{ValType: wasm.ValueTypeI64, Mutable: true},
+		Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: []byte{0}},
+	}},
+	ElementSection: []wasm.ElementSegment{{
+		OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+		Init:       []wasm.Index{1},
+		Type:       wasm.RefTypeFuncref,
+		Mode:       wasm.ElementModeActive,
+	}},
+	CodeSection: []wasm.Code{
+		{Body: []byte{
+			wasm.OpcodeGlobalGet, 0,
+			wasm.OpcodeI64Const, 1,
+			wasm.OpcodeI64Add,
+			wasm.OpcodeGlobalSet, 0,
+			wasm.OpcodeGlobalGet, 0,
+			wasm.OpcodeCall, 0,
+			wasm.OpcodeGlobalGet, 0,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeCallIndirect, 0, 0,
+			wasm.OpcodeEnd,
+		}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "inc", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "inc_indirect", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "counter", Type: wasm.ExternTypeGlobal, Index: 0},
+	},
+})
+
+// mathWasm exports "add", which adds the params and the immutable global "bias", and stores the result into the
+// exported "memory" as well as returning it.
+var mathWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{{
+		Params:            []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI32},
+		Results:           []wasm.ValueType{wasm.ValueTypeI32},
+		ParamNumInUint64:  2,
+		ResultNumInUint64: 1,
+	}},
+	FunctionSection: []wasm.Index{0},
+	MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true}},
+	GlobalSection: []wasm.Global{{
+		Type: wasm.GlobalType{ValType: wasm.ValueTypeI32},
+		Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{10}},
+	}},
+	CodeSection: []wasm.Code{{Body: []byte{
+		wasm.OpcodeI32Const, 0,
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeLocalGet, 1,
+		wasm.OpcodeI32Add,
+		wasm.OpcodeGlobalGet, 0,
+		wasm.OpcodeI32Add,
+		wasm.OpcodeI32Store, 2, 0,
+		wasm.OpcodeI32Const, 0,
+		wasm.OpcodeI32Load, 2, 0,
+		wasm.OpcodeEnd,
+	}}},
+	ExportSection: []wasm.Export{
+		{Name: "add", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+	},
+	NameSection: &wasm.NameSection{ModuleName: "math"},
+})
+
+// mathImporterWasm exports "run", which calls "math.add" and adds the result stored in "math.memory".
+var mathImporterWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{{
+		Params:            []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI32},
+		Results:           []wasm.ValueType{wasm.ValueTypeI32},
+		ParamNumInUint64:  2,
+		ResultNumInUint64: 1,
+	}},
+	ImportSection: []wasm.Import{
+		{Module: "math", Name: "add", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: "math", Name: "memory", Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1}},
+	},
+	FunctionSection: []wasm.Index{0},
+	CodeSection: []wasm.Code{{Body: []byte{
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeLocalGet, 1,
+		wasm.OpcodeCall, 0,
+		wasm.OpcodeI32Const, 0,
+		wasm.OpcodeI32Load, 2, 0,
+		wasm.OpcodeI32Add,
+		wasm.OpcodeEnd,
+	}}},
+	ExportSection: []wasm.Export{{Name: "run", Type: wasm.ExternTypeFunc, Index: 1}},
+})
+
+// eightWasm exports "eight", which returns 8, and is named so that it switches to the compiler without state.
+var eightWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection:     []wasm.FunctionType{{Results: []wasm.ValueType{wasm.ValueTypeI64}, ResultNumInUint64: 1}},
+	FunctionSection: []wasm.Index{0},
+	CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeI64Const, 8, wasm.OpcodeEnd}}},
+	ExportSection:   []wasm.Export{{Name: "eight", Type: wasm.ExternTypeFunc, Index: 0}},
+	NameSection:     &wasm.NameSection{ModuleName: "eight"},
+})
+
+// tableWasm exports the "table" of functions returning i64, and "call", which calls the function at the given offset
+// of it.
+var tableWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Results: []wasm.ValueType{wasm.ValueTypeI64}, ResultNumInUint64: 1},
+		{Params: []wasm.ValueType{wasm.ValueTypeI32}, Results: []wasm.ValueType{wasm.ValueTypeI64}, ParamNumInUint64: 1, ResultNumInUint64: 1},
+	},
+	FunctionSection: []wasm.Index{1},
+	TableSection:    []wasm.Table{{Min: 1, Type: wasm.RefTypeFuncref}},
+	CodeSection: []wasm.Code{{Body: []byte{
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeCallIndirect, 0, 0,
+		wasm.OpcodeEnd,
+	}}},
+	ExportSection: []wasm.Export{
+		{Name: "call", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+	},
+})
+
+// compiled returns true if the module runs on the compiler.
+func compiled(mod api.Module) bool {
+	me := mod.(*wasm.ModuleInstance).Engine.(wasm.TieredModuleEngine)
+	return me.ModuleEngines()[1] != nil && me.OwnsGlobals()
+}
+
+// callUntilCompiled calls fn with the params until its module runs on the compiler, and returns the number of calls.
+func callUntilCompiled(t *testing.T, ctx context.Context, mod api.Module, fn api.Function, params ...uint64) (calls uint64) {
+	deadline := time.Now().Add(time.Minute)
+	for !compiled(mod) {
+		if time.Now().After(deadline) {
+			t.Fatal("the module didn't switch to the compiler")
+		}
+		_, err := fn.Call(ctx, params...)
+		require.NoError(t, err)
+		calls++
+		time.Sleep(time.Millisecond)
+	}
+	return
+}
+
+func TestEngine(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	for _, name := range []string{"inc", "inc_indirect"} {
+		t.Run(name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigTiered())
+			defer r.Close(testCtx)
+
+			var observed []uint64
+			_, err := r.NewHostModuleBuilder("env").
+				NewFunctionBuilder().WithFunc(func(v uint64) { observed = append(observed, v) }).Export("observe").
+				Instantiate(testCtx)
+			require.NoError(t, err)
+
+			mod, err := r.InstantiateWithConfig(testCtx, counterWasm, wazero.NewModuleConfig().WithName(""))
+			require.NoError(t, err)
+			fn := mod.ExportedFunction(name)
+
+			calls := callUntilCompiled(t, testCtx, mod, fn)
+
+			// The global and the table survive the switch.
+			res, err := fn.Call(testCtx)
+			require.NoError(t, err)
+			calls++
+			require.Equal(t, []uint64{calls}, res)
+			require.Equal(t, calls, mod.ExportedGlobal("counter").Get())
+			require.Equal(t, int(calls), len(observed))
+			for i, v := range observed {
+				require.Equal(t, uint64(i+1), v)
+			}
+		})
+	}
+}
+
+func TestEngine_named(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigTiered())
+	defer r.Close(testCtx)
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(uint64) {}).Export("observe").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	compiledModule, err := r.CompileModule(testCtx, counterWasm)
+	require.NoError(t, err)
+
+	anonymous, err := r.InstantiateModule(testCtx, compiledModule, wazero.NewModuleConfig().WithName(""))
+	require.NoError(t, err)
+	callUntilCompiled(t, testCtx, anonymous, anonymous.ExportedFunction("inc"))
+
+	// Other modules may import from a named module, so it stays on the interpreter with its table and mutable global.
+	named, err := r.InstantiateModule(testCtx, compiledModule, wazero.NewModuleConfig().WithName("counter"))
+	require.NoError(t, err)
+	res, err := named.ExportedFunction("inc").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1}, res)
+	require.False(t, compiled(named))
+
+	// Modules instantiated after the compilation is done start on the compiler.
+	anonymous, err = r.InstantiateModule(testCtx, compiledModule, wazero.NewModuleConfig().WithName(""))
+	require.NoError(t, err)
+	require.True(t, compiled(anonymous))
+
+	t.Run("without state", func(t *testing.T) {
+		// The instance is named after the name section.
+		math, err := r.Instantiate(testCtx, mathWasm)
+		require.NoError(t, err)
+		require.Equal(t, "math", math.Name())
+		add := math.ExportedFunction("add")
+		callUntilCompiled(t, testCtx, math, add, 1, 2)
+
+		// The module importing from the switched module runs its functions on the interpreter.
+		importer, err := r.Instantiate(testCtx, mathImporterWasm)
+		require.NoError(t, err)
+		res, err := importer.ExportedFunction("run").Call(testCtx, 3, 4)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{34}, res)
+		require.False(t, compiled(importer))
+
+		// Both see the same memory.
+		res, err = add.Call(testCtx, 5, 6)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{21}, res)
+		v, ok := importer.(*wasm.ModuleInstance).MemoryInstance.ReadUint32Le(0)
+		require.True(t, ok)
+		require.Equal(t, uint32(21), v)
+	})
+}
+
+func TestEngine_exportedTable(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigTiered())
+	defer r.Close(testCtx)
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(uint64) {}).Export("observe").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	// The module exporting the table stays on the interpreter, as any function may be set into the table.
+	mod, err := r.InstantiateWithConfig(testCtx, tableWasm, wazero.NewModuleConfig().WithName(""))
+	require.NoError(t, err)
+	tbl := mod.ExportedTable("table")
+	call := mod.ExportedFunction("call")
+
+	t.Run("switched module", func(t *testing.T) {
+		eight, err := r.Instantiate(testCtx, eightWasm)
+		require.NoError(t, err)
+		fn := eight.ExportedFunction("eight")
+		callUntilCompiled(t, testCtx, eight, fn)
+
+		require.NoError(t, tbl.SetFunction(0, fn))
+		f, err := tbl.Function(0)
+		require.NoError(t, err)
+		require.Equal(t, "eight.$0", f.Definition().DebugName())
+		res, err := call.Call(testCtx, 0)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{8}, res)
+		require.False(t, compiled(mod))
+	})
+
+	t.Run("switched module with state", func(t *testing.T) {
+		// The interpreter of a switched module with a mutable global would see a stale value.
+		counter, err := r.InstantiateWithConfig(testCtx, counterWasm, wazero.NewModuleConfig().WithName(""))
+		require.NoError(t, err)
+		fn := counter.ExportedFunction("inc")
+		callUntilCompiled(t, testCtx, counter, fn)
+
+		err = tbl.SetFunction(0, fn)
+		require.EqualError(t, err, "function .$1 cannot be set: "+
+			"the module defining it runs on the compiler, while the table runs on the interpreter")
+	})
+}
+
+func TestEngine_listener(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	var before, observed int
+	listener := experimental.FunctionListenerFactoryFunc(func(def api.FunctionDefinition) experimental.FunctionListener {
+		if def.GoFunction() != nil {
+			return nil
+		}
+		return experimental.FunctionListenerFunc(func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {
+			before++
+		})
+	})
+	ctx := experimental.WithFunctionListenerFactory(testCtx, listener)
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigTiered())
+	defer r.Close(ctx)
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(uint64) { observed++ }).Export("observe").
+		Instantiate(ctx)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateWithConfig(ctx, counterWasm, wazero.NewModuleConfig().WithName(""))
+	require.NoError(t, err)
+	fn := mod.ExportedFunction("inc_indirect")
+	calls := callUntilCompiled(t, ctx, mod, fn)
+
+	_, err = fn.Call(ctx)
+	require.NoError(t, err)
+	calls++
+	// Both "inc_indirect" and "inc" are listened to, on either tier.
+	require.Equal(t, int(2*calls), before)
+	require.Equal(t, int(calls), observed)
+}
+
+func TestEngine_snapshot(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	ctx := experimental.WithSnapshotter(testCtx)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigTiered())
+	defer r.Close(ctx)
+
+	var restored, unreachable int
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, _ uint64) {
+		snapshot := experimental.GetSnapshotter(ctx).Snapshot()
+		restored++
+		snapshot.Restore(nil)
+		unreachable++
+	}).Export("observe").
+		Instantiate(ctx)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateWithConfig(ctx, counterWasm, wazero.NewModuleConfig().WithName(""))
+	require.NoError(t, err)
+	fn := mod.ExportedFunction("inc")
+	callUntilCompiled(t, ctx, mod, fn)
+
+	_, err = fn.Call(ctx)
+	require.NoError(t, err)
+	calls := mod.ExportedGlobal("counter").Get()
+	require.Equal(t, int(calls), restored)
+	require.Zero(t, unreachable)
+}
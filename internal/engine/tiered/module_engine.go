@@ -0,0 +1,427 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/internalapi"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+type (
+	// moduleEngine implements wasm.TieredModuleEngine.
+	//
+	// A module instance runs on the interpreter, and switches to the compiler between function calls once its
+	// compilation is done. Since the references to functions and the global variables are represented differently by
+	// each tier, this is only possible for the module instances which don't share them with modules running on the
+	// interpreter, hence the conditions in switchable. Modules importing from a switched module link to its
+	// interpreter, whose functions keep working on the memories and the immutable globals, which are shared by both.
+	moduleEngine struct {
+		parent      *engine
+		module      *wasm.Module
+		instance    *wasm.ModuleInstance
+		compilation *compilation
+
+		// tiers are the ModuleEngines of the interpreter and the compiler. The latter is set on creation for host
+		// modules, which run on both, and when switching to the compiler for the others.
+		tiers [tierCount]wasm.ModuleEngine
+		// compiled is true once the module runs on the compiler.
+		compiled atomic.Bool
+		// canSwitch is true until the module tries to switch to the compiler.
+		canSwitch atomic.Bool
+		// mux is read-locked during function calls, and locked to switch to the compiler in between.
+		mux sync.RWMutex
+
+		// importedFunctions and importedMemories are resolved again on the compiler's ModuleEngine when switching.
+		importedFunctions []importedFunction
+		importedMemories  []importedMemory
+		// importsGuestModule is true if the module imports from a module which isn't a host module.
+		importsGuestModule bool
+	}
+
+	importedFunction struct {
+		index, descFunc, indexInImportedModule wasm.Index
+		importedModuleEngine                   *moduleEngine
+	}
+
+	importedMemory struct {
+		index, indexInImportedModule wasm.Index
+		importedModuleEngine         *moduleEngine
+	}
+)
+
+var _ wasm.TieredModuleEngine = (*moduleEngine)(nil)
+
+// ModuleEngines implements wasm.TieredModuleEngine.
+func (me *moduleEngine) ModuleEngines() []wasm.ModuleEngine {
+	return me.tiers[:]
+}
+
+// current returns the ModuleEngine the module runs on. Unless this is a host module, this must be called with mux
+// locked.
+func (me *moduleEngine) current() wasm.ModuleEngine {
+	if me.compiled.Load() {
+		return me.tiers[tierCompiler]
+	}
+	return me.tiers[tierInterpreter]
+}
+
+// enter read-locks mux for a function call, after switching to the compiler if possible.
+func (me *moduleEngine) enter() {
+	if me.canSwitch.Load() && me.compilation.done.Load() && me.mux.TryLock() {
+		// No function of this module is being called, so the switch is safe.
+		me.switchToCompiler()
+		me.mux.Unlock()
+	}
+	me.mux.RLock()
+}
+
+// switchable returns true if the module instance can switch to the compiler. The instance must be:
+//   - only importing from host modules, which run on both tiers. Only guest modules export globals, tables and tags.
+//   - only holding references to functions or external values, since the others aren't supported by the compiler.
+//   - not exporting tables, since api.Table.SetFunction may store the functions of any module into them, which only
+//     have a reference for the compiler when they switched too.
+//   - if named, so that other modules may import from it at any time, keeping the interpreter valid, see
+//     interpreterStaysValid.
+func (me *moduleEngine) switchable() bool {
+	m := me.module
+	if me.compilation == nil || me.importsGuestModule ||
+		m.ImportGlobalCount > 0 || m.ImportTableCount > 0 || m.ImportTagCount > 0 {
+		return false
+	}
+	if me.instance.ModuleName != "" && !me.interpreterStaysValid() {
+		return false
+	}
+	for i := range m.ExportSection {
+		if m.ExportSection[i].Type == wasm.ExternTypeTable {
+			return false
+		}
+	}
+	for _, t := range me.instance.Tables {
+		if !switchableType(t.Type) {
+			return false
+		}
+	}
+	for _, g := range me.instance.Globals {
+		if !switchableType(g.Type.ValType) {
+			return false
+		}
+	}
+	for i := range m.ElementSection {
+		if !switchableType(m.ElementSection[i].Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// interpreterStaysValid returns true if the functions of the module keep working on the interpreter after the switch,
+// which is the case without the state converted for the compiler or owned by it: tables, element segments, and
+// mutable or reference globals.
+func (me *moduleEngine) interpreterStaysValid() bool {
+	if len(me.instance.Tables) > 0 || len(me.module.ElementSection) > 0 {
+		return false
+	}
+	for _, g := range me.instance.Globals {
+		if g.Type.Mutable || wasm.IsReferenceValueType(g.Type.ValType) {
+			return false
+		}
+	}
+	return true
+}
+
+func switchableType(vt wasm.ValueType) bool {
+	return !wasm.IsReferenceValueType(vt) || isFuncref(vt) ||
+		vt == wasm.ValueTypeExternref || vt == wasm.ValueTypeNullexternref
+}
+
+func isFuncref(vt wasm.ValueType) bool {
+	return vt == wasm.ValueTypeFuncref || vt == wasm.ValueTypeNullfuncref
+}
+
+// switchToCompiler switches the module to the compiler, unless it already tried. This must be called with mux locked.
+//
+// The state owned by the interpreter, i.e. references to functions, is converted for the compiler. If that fails,
+// e.g. because a table holds a function of another module, the module keeps running on the interpreter.
+func (me *moduleEngine) switchToCompiler() {
+	if !me.canSwitch.Swap(false) || me.compilation.err != nil || me.instance.IsClosed() {
+		return
+	}
+
+	compiler, err := me.parent.compiler.NewModuleEngine(me.module, me.instance)
+	if err != nil {
+		return // e.g. the compiled module was deleted.
+	}
+	for i := range me.importedFunctions {
+		f := &me.importedFunctions[i]
+		compiler.ResolveImportedFunction(f.index, f.descFunc, f.indexInImportedModule, f.importedModuleEngine.tiers[tierCompiler])
+	}
+	for i := range me.importedMemories {
+		m := &me.importedMemories[i]
+		compiler.ResolveImportedMemory(m.index, m.indexInImportedModule, m.importedModuleEngine.tiers[tierCompiler])
+	}
+
+	// Convert all the references before updating any, so that nothing changes on failure.
+	inst := me.instance
+	ok := true
+	tables := make([][]wasm.Reference, len(inst.Tables))
+	for i, t := range inst.Tables {
+		if isFuncref(t.Type) {
+			if tables[i], ok = me.compilerReferences(compiler, t.References); !ok {
+				return
+			}
+		}
+	}
+	elements := make([][]wasm.Reference, len(inst.ElementInstances))
+	for i, refs := range inst.ElementInstances {
+		if isFuncref(me.module.ElementSection[i].Type) {
+			if elements[i], ok = me.compilerReferences(compiler, refs); !ok {
+				return
+			}
+		}
+	}
+	globals := make([]wasm.Reference, len(inst.Globals))
+	for i, g := range inst.Globals {
+		if isFuncref(g.Type.ValType) {
+			if globals[i], ok = me.compilerReference(compiler, wasm.Reference(g.Val)); !ok {
+				return
+			}
+		}
+	}
+
+	for i, t := range inst.Tables {
+		if tables[i] != nil {
+			copy(t.References, tables[i])
+		}
+	}
+	for i, refs := range elements {
+		if refs != nil {
+			copy(inst.ElementInstances[i], refs)
+		}
+	}
+	for i, g := range inst.Globals {
+		if isFuncref(g.Type.ValType) {
+			g.Val = uint64(globals[i])
+		}
+	}
+
+	// The compiler owns the global variables from now on, so this copies their values.
+	compiler.DoneInstantiation()
+	for i, g := range inst.Globals {
+		g.Me, g.Index = me, wasm.Index(i)
+	}
+	me.tiers[tierCompiler] = compiler
+	me.compiled.Store(true)
+}
+
+// compilerReferences returns the references of the compiler corresponding to the given ones of the interpreter.
+func (me *moduleEngine) compilerReferences(compiler wasm.ModuleEngine, refs []wasm.Reference) (ret []wasm.Reference, ok bool) {
+	ret = make([]wasm.Reference, len(refs))
+	for i, ref := range refs {
+		if ret[i], ok = me.compilerReference(compiler, ref); !ok {
+			return nil, false
+		}
+	}
+	return ret, true
+}
+
+// compilerReference returns the reference of the compiler corresponding to the given one of the interpreter, or false
+// if it refers to a function which isn't defined or imported by this module.
+func (me *moduleEngine) compilerReference(compiler wasm.ModuleEngine, ref wasm.Reference) (wasm.Reference, bool) {
+	if ref == 0 {
+		return 0, true
+	}
+	inst, index := me.tiers[tierInterpreter].LookupFunctionReference(ref)
+	if inst == me.instance {
+		return compiler.FunctionInstanceReference(index), true
+	}
+	for i := range me.importedFunctions {
+		f := &me.importedFunctions[i]
+		if f.importedModuleEngine.instance == inst && f.indexInImportedModule == index {
+			return compiler.FunctionInstanceReference(f.index), true
+		}
+	}
+	return 0, false
+}
+
+// DoneInstantiation implements wasm.ModuleEngine.
+func (me *moduleEngine) DoneInstantiation() {
+	if me.module.IsHostModule {
+		me.tiers[tierInterpreter].DoneInstantiation()
+		me.tiers[tierCompiler].DoneInstantiation()
+		return
+	}
+
+	me.tiers[tierInterpreter].DoneInstantiation()
+	if me.switchable() {
+		me.canSwitch.Store(true)
+		// Switch right away if the compilation is already done, e.g. on a cache hit.
+		me.enter()
+		me.mux.RUnlock()
+	}
+}
+
+// NewFunction implements wasm.ModuleEngine.
+func (me *moduleEngine) NewFunction(index wasm.Index) api.Function {
+	if me.module.IsHostModule {
+		return me.tiers[tierInterpreter].NewFunction(index)
+	}
+	return &function{parent: me, index: index}
+}
+
+// ResolveImportedFunction implements wasm.ModuleEngine.
+func (me *moduleEngine) ResolveImportedFunction(index, descFunc, indexInImportedModule wasm.Index, importedModuleEngine wasm.ModuleEngine) {
+	imported := importedModuleEngine.(*moduleEngine)
+	me.tiers[tierInterpreter].ResolveImportedFunction(index, descFunc, indexInImportedModule, imported.tiers[tierInterpreter])
+	me.importedFunctions = append(me.importedFunctions, importedFunction{
+		index: index, descFunc: descFunc, indexInImportedModule: indexInImportedModule, importedModuleEngine: imported,
+	})
+	me.importsGuestModule = me.importsGuestModule || !imported.module.IsHostModule
+}
+
+// ResolveImportedMemory implements wasm.ModuleEngine.
+func (me *moduleEngine) ResolveImportedMemory(index, indexInImportedModule wasm.Index, importedModuleEngine wasm.ModuleEngine) {
+	imported := importedModuleEngine.(*moduleEngine)
+	me.tiers[tierInterpreter].ResolveImportedMemory(index, indexInImportedModule, imported.tiers[tierInterpreter])
+	me.importedMemories = append(me.importedMemories, importedMemory{
+		index: index, indexInImportedModule: indexInImportedModule, importedModuleEngine: imported,
+	})
+	me.importsGuestModule = me.importsGuestModule || !imported.module.IsHostModule
+}
+
+// LookupFunction implements wasm.ModuleEngine.
+func (me *moduleEngine) LookupFunction(t *wasm.TableInstance, typeId wasm.FunctionTypeID, tableOffset wasm.Index) (*wasm.ModuleInstance, wasm.Index) {
+	me.mux.RLock()
+	defer me.mux.RUnlock()
+	return me.current().LookupFunction(t, typeId, tableOffset)
+}
+
+// LookupFunctionReference implements wasm.ModuleEngine.
+func (me *moduleEngine) LookupFunctionReference(ref wasm.Reference) (*wasm.ModuleInstance, wasm.Index) {
+	me.mux.RLock()
+	defer me.mux.RUnlock()
+	return me.current().LookupFunctionReference(ref)
+}
+
+// GetGlobalValue implements wasm.ModuleEngine.
+func (me *moduleEngine) GetGlobalValue(idx wasm.Index) (lo, hi uint64) {
+	me.mux.RLock()
+	defer me.mux.RUnlock()
+	return me.current().GetGlobalValue(idx)
+}
+
+// SetGlobalValue implements wasm.ModuleEngine.
+func (me *moduleEngine) SetGlobalValue(idx wasm.Index, lo, hi uint64) {
+	me.mux.RLock()
+	defer me.mux.RUnlock()
+	me.current().SetGlobalValue(idx, lo, hi)
+}
+
+// OwnsGlobals implements wasm.ModuleEngine.
+func (me *moduleEngine) OwnsGlobals() bool {
+	return me.compiled.Load()
+}
+
+// FunctionInstanceReference implements wasm.ModuleEngine.
+func (me *moduleEngine) FunctionInstanceReference(funcIndex wasm.Index) wasm.Reference {
+	me.mux.RLock()
+	defer me.mux.RUnlock()
+	return me.current().FunctionInstanceReference(funcIndex)
+}
+
+// FunctionReference implements wasm.ModuleEngine.
+//
+// The reference is built for the tier this module runs on. A module referenced by a table on the interpreter stops
+// switching unless its interpreter stays valid, and fails if it already switched.
+func (me *moduleEngine) FunctionReference(m *wasm.ModuleInstance, funcIndex wasm.Index) (wasm.Reference, error) {
+	me.mux.RLock()
+	defer me.mux.RUnlock()
+	if m == me.instance {
+		return me.current().FunctionInstanceReference(funcIndex), nil
+	}
+
+	other := m.Engine.(*moduleEngine)
+	if other.module.IsHostModule {
+		return me.current().FunctionReference(m, funcIndex)
+	}
+	// Read-locking prevents the other module from switching in between, as it only switches with mux locked.
+	other.mux.RLock()
+	defer other.mux.RUnlock()
+	if me.compiled.Load() {
+		if !other.compiled.Load() {
+			return 0, errors.New("the module defining it runs on the interpreter, while the table runs on the compiler")
+		}
+		return me.tiers[tierCompiler].FunctionReference(m, funcIndex)
+	}
+	if !other.interpreterStaysValid() {
+		if other.compiled.Load() {
+			return 0, errors.New("the module defining it runs on the compiler, while the table runs on the interpreter")
+		}
+		other.canSwitch.Store(false)
+	}
+	return me.tiers[tierInterpreter].FunctionReference(m, funcIndex)
+}
+
+// MemoryGrown implements wasm.ModuleEngine.
+func (me *moduleEngine) MemoryGrown() {
+	me.mux.RLock()
+	defer me.mux.RUnlock()
+	for _, tier := range me.tiers {
+		if tier != nil {
+			tier.MemoryGrown()
+		}
+	}
+}
+
+// function implements api.Function by calling the function on the tier the module runs on.
+type function struct {
+	internalapi.WazeroOnlyType
+
+	parent *moduleEngine
+	index  wasm.Index
+	// functions are the api.Function of each tier, created on first use.
+	functions [tierCount]api.Function
+}
+
+// current returns the api.Function of the tier the module runs on. This must be called with mux read-locked.
+func (f *function) current() api.Function {
+	tier := tierInterpreter
+	if f.parent.compiled.Load() {
+		tier = tierCompiler
+	}
+	if f.functions[tier] == nil {
+		f.functions[tier] = f.parent.tiers[tier].NewFunction(f.index)
+	}
+	return f.functions[tier]
+}
+
+// Definition implements the same method as documented on api.Function.
+func (f *function) Definition() api.FunctionDefinition {
+	f.parent.mux.RLock()
+	defer f.parent.mux.RUnlock()
+	return f.current().Definition()
+}
+
+// Call implements the same method as documented on api.Function.
+func (f *function) Call(ctx context.Context, params ...uint64) ([]uint64, error) {
+	f.parent.enter()
+	defer f.parent.mux.RUnlock()
+	return f.current().Call(ctx, params...)
+}
+
+// CallWithStack implements the same method as documented on api.Function.
+func (f *function) CallWithStack(ctx context.Context, stack []uint64) error {
+	f.parent.enter()
+	defer f.parent.mux.RUnlock()
+	return f.current().CallWithStack(ctx, stack)
+}
+
+// ModuleFunction implements the same method as documented on wasm.ModuleFunction.
+func (f *function) ModuleFunction() (*wasm.ModuleInstance, wasm.Index) {
+	f.parent.mux.RLock()
+	defer f.parent.mux.RUnlock()
+	return f.current().(wasm.ModuleFunction).ModuleFunction()
+}
@@ -2326,8 +2326,8 @@ L2 (SSA Block: blk2):
 			require.NoError(t, err)
 
 			ssab := ssa.NewBuilder()
-			offset := wazevoapi.NewModuleContextOffsetData(tc.m, false)
-			fc := frontend.NewFrontendCompiler(tc.m, ssab, &offset, false, false, false)
+			offset := wazevoapi.NewModuleContextOffsetData(tc.m, false, false)
+			fc := frontend.NewFrontendCompiler(tc.m, ssab, &offset, false, false, false, false)
 			machine := newMachine()
 			machine.DisableStackCheck()
 			be := backend.NewCompiler(context.Background(), machine, ssab)
@@ -2350,7 +2350,7 @@ L2 (SSA Block: blk2):
 			}
 
 			// Lowers the SSA to ISA specific code.
-			be.Lower()
+			require.NoError(t, be.Lower())
 			if verbose {
 				fmt.Println("============ lowering result ============")
 				fmt.Println(be.Format())
@@ -45,7 +45,7 @@ type Compiler interface {
 	Compile(ctx context.Context) (_ []byte, _ []RelocationInfo, _ error)
 
 	// Lower lowers the given ssa.Instruction to the machine-specific instructions.
-	Lower()
+	Lower() error
 
 	// RegAlloc performs the register allocation after Lower is called.
 	RegAlloc()
@@ -146,6 +146,8 @@ type compiler struct {
 	// abis maps ssa.SignatureID to the ABI implementation.
 	abis                           []FunctionABI
 	argResultInts, argResultFloats []regalloc.RealReg
+	// lowerErr is the first error found by Lower.
+	lowerErr error
 }
 
 // SourceOffsetInfo is a data to associate the source offset with the executable offset.
@@ -158,7 +160,9 @@ type SourceOffsetInfo struct {
 
 // Compile implements Compiler.Compile.
 func (c *compiler) Compile(ctx context.Context) ([]byte, []RelocationInfo, error) {
-	c.Lower()
+	if err := c.Lower(); err != nil {
+		return nil, nil, err
+	}
 	if wazevoapi.PrintSSAToBackendIRLowering && wazevoapi.PrintEnabledIndex(ctx) {
 		fmt.Printf("[[[after lowering for %s ]]]%s\n", wazevoapi.GetCurrentFunctionName(ctx), c.Format())
 	}
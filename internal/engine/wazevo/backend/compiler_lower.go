@@ -6,11 +6,13 @@ import (
 )
 
 // Lower implements Compiler.Lower.
-func (c *compiler) Lower() {
+func (c *compiler) Lower() error {
+	c.lowerErr = nil
 	c.assignVirtualRegisters()
 	c.mach.SetCurrentABI(c.GetFunctionABI(c.ssaBuilder.Signature()))
 	c.mach.StartLoweringFunction(c.ssaBuilder.BlockIDMax())
 	c.lowerBlocks()
+	return c.lowerErr
 }
 
 // lowerBlocks lowers each block in the ssa.Builder.
@@ -66,6 +68,15 @@ func (c *compiler) lowerBlock(blk ssa.BasicBlock) {
 
 		switch cur.Opcode() {
 		case ssa.OpcodeReturn:
+			if prev := cur.Prev(); prev != nil && isTailCall(prev) {
+				c.setCurrentGroupID(prev.GroupID())
+				// The tail call returns to the caller of this function by itself.
+				if err := mach.LowerTailCall(prev); err != nil && c.lowerErr == nil {
+					c.lowerErr = err
+				}
+				prev.MarkLowered()
+				break
+			}
 			rets := cur.ReturnVals()
 			if len(rets) > 0 {
 				c.mach.LowerReturns(rets)
@@ -85,6 +96,12 @@ func (c *compiler) lowerBlock(blk ssa.BasicBlock) {
 	mach.EndBlock()
 }
 
+// isTailCall returns true if the given instruction is a tail call, which is always followed by the return of its results.
+func isTailCall(instr *ssa.Instruction) bool {
+	op := instr.Opcode()
+	return op == ssa.OpcodeTailCallReturnCall || op == ssa.OpcodeTailCallReturnCallIndirect
+}
+
 // lowerBranches is called right after StartBlock and before any LowerInstr call if
 // there are branches to the given block. br0 is the very end of the block and b1 is the before the br0 if it exists.
 // At least br0 is not nil, but br1 can be nil if there's no branching before br0.
@@ -0,0 +1,88 @@
+package amd64
+
+import (
+	"encoding/binary"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// lazyFunctionEntrySize is the size of the entries encoded by EncodeLazyFunctionEntry, padded to 16 bytes.
+const lazyFunctionEntrySize = 32
+
+// CompileLazyCompilationTrampoline implements backend.Machine.
+func (m *machine) CompileLazyCompilationTrampoline() []byte {
+	cur := m.allocateNop()
+	m.rootInstr = cur
+
+	// The entry jumps here without touching the stack, so the return address of the function is at the top of it,
+	// and this frame looks like the one of the function to the stack unwinding.
+	cur = m.setupRBPRSP(cur)
+
+	// Execution context is always the first argument.
+	execCtrPtr := raxVReg
+
+	// Save the callee saved and argument registers.
+	cur = m.saveRegistersInExecutionContext(cur, execCtrPtr, stackGrowSaveVRegs)
+
+	// The module context of the function is always the second argument, and identifies the module to compile.
+	cur = linkInstr(cur, m.allocateInstr().asMovRM(rbxVReg, newOperandMem(m.newAmodeImmReg(
+		wazevoapi.ExecutionContextOffsetCallerModuleContextPtr.U32(), execCtrPtr)), 8))
+
+	// Load the exitCode to the register.
+	exitCodeReg := r12VReg // Already saved.
+	cur = linkInstr(cur, m.allocateInstr().asImm(exitCodeReg, uint64(wazevoapi.ExitCodeLazyCompile), false))
+
+	saveRsp, saveRbp, setExitCode := m.allocateExitInstructions(execCtrPtr, exitCodeReg)
+	cur = linkInstr(cur, setExitCode)
+	cur = linkInstr(cur, saveRsp)
+	cur = linkInstr(cur, saveRbp)
+
+	// Ready to exit the execution.
+	cur = m.storeReturnAddressAndExit(cur, execCtrPtr)
+
+	// After the exit, restore the saved registers.
+	cur = m.restoreRegistersInExecutionContext(cur, execCtrPtr, stackGrowSaveVRegs)
+
+	// Finally jump to the compiled function with the same stack as the function was called with.
+	cur = m.revertRBPRSP(cur)
+	linkInstr(cur, m.allocateInstr().asJmp(newOperandMem(m.newAmodeImmReg(
+		wazevoapi.ExecutionContextOffsetLazyFunctionExecutable.U32(), execCtrPtr))))
+
+	m.encodeWithoutSSA(m.rootInstr)
+	return m.c.Buf()
+}
+
+// LazyFunctionEntrySize implements backend.Machine.
+func (m *machine) LazyFunctionEntrySize() int {
+	return lazyFunctionEntrySize
+}
+
+// EncodeLazyFunctionEntry implements backend.Machine.
+//
+// The entry is encoded as follows:
+//
+//	jmpq *slot(%rip)
+//	movq $index, ExecutionContextOffsetLazyFunctionIndex(%rax) ;; <- lazyOffset
+//	jmp trampoline
+//	int3 ...
+func (m *machine) EncodeLazyFunctionEntry(executable []byte, entryOffset, slotOffset, trampolineOffset int, index uint32) (lazyOffset int) {
+	entry := executable[entryOffset : entryOffset+lazyFunctionEntrySize]
+
+	// jmpq *rel32(%rip): FF /4 with RIP-relative addressing, relative to the next instruction.
+	entry[0], entry[1] = 0xff, 0x25
+	binary.LittleEndian.PutUint32(entry[2:], uint32(int32(slotOffset-(entryOffset+6))))
+
+	// movq $imm32, disp32(%rax): REX.W C7 /0. The index is small enough not to be affected by the sign extension.
+	entry[6], entry[7], entry[8] = 0x48, 0xc7, 0x80
+	binary.LittleEndian.PutUint32(entry[9:], wazevoapi.ExecutionContextOffsetLazyFunctionIndex.U32())
+	binary.LittleEndian.PutUint32(entry[13:], index)
+
+	// jmp rel32.
+	entry[17] = 0xe9
+	binary.LittleEndian.PutUint32(entry[18:], uint32(int32(trampolineOffset-(entryOffset+22))))
+
+	for i := 22; i < lazyFunctionEntrySize; i++ {
+		entry[i] = 0xcc
+	}
+	return entryOffset + 6
+}
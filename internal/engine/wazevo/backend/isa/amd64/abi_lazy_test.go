@@ -0,0 +1,99 @@
+package amd64
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestMachine_CompileLazyCompilationTrampoline(t *testing.T) {
+	_, _, m := newSetupWithMockContext()
+	m.CompileLazyCompilationTrampoline()
+	require.Equal(t, `
+	pushq %rbp
+	movq %rsp, %rbp
+	mov.q %rdx, 96(%rax)
+	mov.q %r12, 112(%rax)
+	mov.q %r13, 128(%rax)
+	mov.q %r14, 144(%rax)
+	mov.q %r15, 160(%rax)
+	mov.q %rcx, 176(%rax)
+	mov.q %rbx, 192(%rax)
+	mov.q %rsi, 208(%rax)
+	mov.q %rdi, 224(%rax)
+	mov.q %r8, 240(%rax)
+	mov.q %r9, 256(%rax)
+	mov.q %r10, 272(%rax)
+	mov.q %r11, 288(%rax)
+	movdqu %xmm8, 304(%rax)
+	movdqu %xmm9, 320(%rax)
+	movdqu %xmm10, 336(%rax)
+	movdqu %xmm11, 352(%rax)
+	movdqu %xmm12, 368(%rax)
+	movdqu %xmm13, 384(%rax)
+	movdqu %xmm14, 400(%rax)
+	movdqu %xmm15, 416(%rax)
+	movdqu %xmm0, 432(%rax)
+	movdqu %xmm1, 448(%rax)
+	movdqu %xmm2, 464(%rax)
+	movdqu %xmm3, 480(%rax)
+	movdqu %xmm4, 496(%rax)
+	movdqu %xmm5, 512(%rax)
+	movdqu %xmm6, 528(%rax)
+	movdqu %xmm7, 544(%rax)
+	mov.q %rbx, 8(%rax)
+	movl $28, %r12d
+	mov.l %r12, (%rax)
+	mov.q %rsp, 56(%rax)
+	mov.q %rbp, 1152(%rax)
+	lea L0, %r12
+	mov.q %r12, 48(%rax)
+	exit_sequence %rax
+L0 (SSA Block: blk0):
+	movq 96(%rax), %rdx
+	movq 112(%rax), %r12
+	movq 128(%rax), %r13
+	movq 144(%rax), %r14
+	movq 160(%rax), %r15
+	movq 176(%rax), %rcx
+	movq 192(%rax), %rbx
+	movq 208(%rax), %rsi
+	movq 224(%rax), %rdi
+	movq 240(%rax), %r8
+	movq 256(%rax), %r9
+	movq 272(%rax), %r10
+	movq 288(%rax), %r11
+	movdqu 304(%rax), %xmm8
+	movdqu 320(%rax), %xmm9
+	movdqu 336(%rax), %xmm10
+	movdqu 352(%rax), %xmm11
+	movdqu 368(%rax), %xmm12
+	movdqu 384(%rax), %xmm13
+	movdqu 400(%rax), %xmm14
+	movdqu 416(%rax), %xmm15
+	movdqu 432(%rax), %xmm0
+	movdqu 448(%rax), %xmm1
+	movdqu 464(%rax), %xmm2
+	movdqu 480(%rax), %xmm3
+	movdqu 496(%rax), %xmm4
+	movdqu 512(%rax), %xmm5
+	movdqu 528(%rax), %xmm6
+	movdqu 544(%rax), %xmm7
+	movq %rbp, %rsp
+	popq %rbp
+	jmp 1224(%rax)
+`, m.Format())
+}
+
+func TestMachine_EncodeLazyFunctionEntry(t *testing.T) {
+	m := NewBackend().(*machine)
+	executable := make([]byte, 0x3000)
+	lazyOffset := m.EncodeLazyFunctionEntry(executable, 0x40, 0x2008, 0, 0x12345)
+	require.Equal(t, 70, lazyOffset)
+	// jmpq *0x1fc2(%rip)
+	// movq $0x12345, 1216(%rax)
+	// jmp 0
+	// int3 ...
+	require.Equal(t, "ff25c21f000048c780c004000045230100e9aaffffffcccccccccccccccccccc", hex.EncodeToString(executable[0x40:0x40+lazyFunctionEntrySize]))
+}
@@ -212,6 +212,10 @@ func (i *instruction) String() string {
 		return fmt.Sprintf("call %s", ssa.FuncRef(i.u1))
 	case callIndirect:
 		return fmt.Sprintf("callq *%s", i.op1.format(true))
+	case tailCall:
+		return fmt.Sprintf("jmp %s", ssa.FuncRef(i.u1))
+	case tailCallIndirect:
+		return fmt.Sprintf("jmpq *%s", i.op1.format(true))
 	case xchg:
 		var suffix string
 		switch i.u1 {
@@ -775,6 +779,12 @@ const (
 	// Indirect call: callq (reg mem).
 	callIndirect
 
+	// Direct tail call: jmp simm32 into the callee after the epilogue of the current function.
+	tailCall
+
+	// Indirect tail call: jmpq *reg into the callee after the epilogue of the current function.
+	tailCallIndirect
+
 	// Return.
 	ret
 
@@ -1173,6 +1183,23 @@ func (i *instruction) asCallIndirect(ptr operand, abi *backend.FunctionABI) *ins
 	return i
 }
 
+func (i *instruction) asTailCall(ref ssa.FuncRef, abi *backend.FunctionABI) *instruction {
+	i.kind = tailCall
+	i.u1 = uint64(ref)
+	i.u2 = abi.ABIInfoAsUint64()
+	return i
+}
+
+func (i *instruction) asTailCallIndirect(ptr operand, abi *backend.FunctionABI) *instruction {
+	if ptr.kind != operandKindReg && ptr.kind != operandKindMem {
+		panic("BUG")
+	}
+	i.kind = tailCallIndirect
+	i.op1 = ptr
+	i.u2 = abi.ABIInfoAsUint64()
+	return i
+}
+
 func (i *instruction) asRet() *instruction {
 	i.kind = ret
 	return i
@@ -2320,6 +2347,8 @@ var defKinds = [instrMax]defKind{
 	cmove:                  defKindNone,
 	call:                   defKindCall,
 	callIndirect:           defKindCall,
+	tailCall:               defKindNone,
+	tailCallIndirect:       defKindNone,
 	ud2:                    defKindNone,
 	jmp:                    defKindNone,
 	jmpIf:                  defKindNone,
@@ -2403,6 +2432,8 @@ var useKinds = [instrMax]useKind{
 	xmmToGpr:               useKindOp1,
 	call:                   useKindCall,
 	callIndirect:           useKindCallInd,
+	tailCall:               useKindCall,
+	tailCallIndirect:       useKindCallInd,
 	ud2:                    useKindNone,
 	jmpIf:                  useKindOp1,
 	jmp:                    useKindOp1,
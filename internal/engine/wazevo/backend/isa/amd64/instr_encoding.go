@@ -1244,6 +1244,36 @@ func (i *instruction) encode(c backend.Compiler) (needsLabelResolution bool) {
 			panic("BUG: invalid operand kind")
 		}
 
+	case tailCall:
+		c.EmitByte(0xe9)
+		// Meaning that the jump target is a function value, and requires relocation.
+		c.AddRelocationInfo(ssa.FuncRef(i.u1))
+		// Note that this is zero as a placeholder for the jump target.
+		c.Emit4Bytes(0)
+
+	case tailCallIndirect:
+		// jmp *reg (or *mem) is encoded as FF /4.
+		switch op := i.op1; op.kind {
+		case operandKindReg:
+			encodeRegReg(c,
+				legacyPrefixesNone,
+				0xff, 1,
+				regEnc(4),
+				regEncodings[op.reg().RealReg()],
+				rexInfo(0).clearW(),
+			)
+		case operandKindMem:
+			encodeRegMem(c,
+				legacyPrefixesNone,
+				0xff, 1,
+				regEnc(4),
+				op.addressMode(),
+				rexInfo(0).clearW(),
+			)
+		default:
+			panic("BUG: invalid operand kind")
+		}
+
 	case xchg:
 		src, dst := regEncodings[i.op1.reg().RealReg()], i.op2
 		size := i.u1
@@ -1884,7 +1884,8 @@ func (m *machine) lowerStore(si *ssa.Instruction) {
 }
 
 func (m *machine) lowerCall(si *ssa.Instruction) {
-	isDirectCall := si.Opcode() == ssa.OpcodeCall
+	op := si.Opcode()
+	isDirectCall := op == ssa.OpcodeCall
 	var indirectCalleePtr ssa.Value
 	var directCallee ssa.FuncRef
 	var sigID ssa.SignatureID
@@ -1908,7 +1909,7 @@ func (m *machine) lowerCall(si *ssa.Instruction) {
 	for i, arg := range args {
 		reg := m.c.VRegOf(arg)
 		def := m.c.ValueDefinition(arg)
-		m.callerGenVRegToFunctionArg(calleeABI, i, reg, def, stackSlotSize)
+		m.callerGenVRegToFunctionArg(calleeABI, i, reg, def, stackSlotSize, false)
 	}
 
 	if isMemmove {
@@ -1952,9 +1953,61 @@ func (m *machine) lowerCall(si *ssa.Instruction) {
 	}
 }
 
+// LowerTailCall implements backend.Machine.
+func (m *machine) LowerTailCall(si *ssa.Instruction) error {
+	isDirectCall := si.Opcode() == ssa.OpcodeTailCallReturnCall
+	var indirectCalleePtr ssa.Value
+	var directCallee ssa.FuncRef
+	var sigID ssa.SignatureID
+	var args []ssa.Value
+	if isDirectCall {
+		directCallee, sigID, args = si.CallData()
+	} else {
+		indirectCalleePtr, sigID, args, _ = si.CallIndirectData()
+	}
+	calleeABI := m.c.GetFunctionABI(m.c.SSABuilder().ResolveSignature(sigID))
+
+	// The stack arguments are passed via the argument stack slots of the current function, so the callee
+	// must have the same layout of them as well as the result stack slots which are placed right above them.
+	if calleeABI.ArgStackSize != m.currentABI.ArgStackSize {
+		return backend.ErrTailCallStackArgs
+	}
+
+	var target operand
+	if !isDirectCall {
+		if int(calleeABI.ArgIntRealRegs) < len(intArgResultRegs) {
+			// The address must be held by the register which is neither restored by the epilogue nor used for
+			// arguments, so we move it before setting up the arguments.
+			m.InsertMove(r11VReg, m.c.VRegOf(indirectCalleePtr), ssa.TypeI64)
+			target = newOperandReg(r11VReg)
+		} else {
+			// r11 is used for the arguments, so the address is stored in the execution context instead, which is
+			// always the first argument held by rax.
+			m.insert(m.allocateInstr().asMovRM(m.c.VRegOf(indirectCalleePtr), newOperandMem(m.newAmodeImmReg(
+				wazevoapi.ExecutionContextOffsetTailCallAddress.U32(), m.c.VRegOf(args[0]))), 8))
+			target = newOperandMem(m.newAmodeImmReg(wazevoapi.ExecutionContextOffsetTailCallAddress.U32(), raxVReg))
+		}
+	}
+
+	for i, arg := range args {
+		reg := m.c.VRegOf(arg)
+		def := m.c.ValueDefinition(arg)
+		m.callerGenVRegToFunctionArg(calleeABI, i, reg, def, 0, true)
+	}
+
+	// Note that the epilogue will be inserted right before the jump after the register allocation.
+	if isDirectCall {
+		m.insert(m.allocateInstr().asTailCall(directCallee, calleeABI))
+	} else {
+		m.insert(m.allocateInstr().asTailCallIndirect(target, calleeABI))
+	}
+	return nil
+}
+
 // callerGenVRegToFunctionArg is the opposite of GenFunctionArgToVReg, which is used to generate the
-// caller side of the function call.
-func (m *machine) callerGenVRegToFunctionArg(a *backend.FunctionABI, argIndex int, reg regalloc.VReg, def backend.SSAValueDefinition, stackSlotSize int64) {
+// caller side of the function call. If isTailCall is true, the stack arguments are stored in the
+// argument stack slots of the current function.
+func (m *machine) callerGenVRegToFunctionArg(a *backend.FunctionABI, argIndex int, reg regalloc.VReg, def backend.SSAValueDefinition, stackSlotSize int64, isTailCall bool) {
 	arg := &a.Args[argIndex]
 	if def.IsFromInstr() {
 		// Constant instructions are inlined.
@@ -1966,9 +2019,15 @@ func (m *machine) callerGenVRegToFunctionArg(a *backend.FunctionABI, argIndex in
 		m.InsertMove(arg.Reg, reg, arg.Type)
 	} else {
 		store := m.allocateInstr()
-		mem := newOperandMem(m.newAmodeImmReg(
-			// -stackSlotSize because the stack pointer is not yet decreased.
-			uint32(arg.Offset-stackSlotSize), rspVReg))
+		var mem operand
+		if isTailCall {
+			// See LowerParams for the location of the stack arguments of the current function.
+			mem = newOperandMem(m.newAmodeImmRBPReg(uint32(arg.Offset + 16)))
+		} else {
+			mem = newOperandMem(m.newAmodeImmReg(
+				// -stackSlotSize because the stack pointer is not yet decreased.
+				uint32(arg.Offset-stackSlotSize), rspVReg))
+		}
 		switch arg.Type {
 		case ssa.TypeI32:
 			store.asMovRM(reg, mem, 4)
@@ -2113,19 +2172,6 @@ func (m *machine) encodeWithoutSSA(root *instruction) {
 func (m *machine) Encode(ctx context.Context) (err error) {
 	bufPtr := m.c.BufPtr()
 
-	var fn string
-	var fnIndex int
-	var labelPosToLabel map[*labelPosition]label
-	if wazevoapi.PerfMapEnabled {
-		fn = wazevoapi.GetCurrentFunctionName(ctx)
-		labelPosToLabel = make(map[*labelPosition]label)
-		for i := 0; i <= m.labelPositionPool.MaxIDEncountered(); i++ {
-			pos := m.labelPositionPool.Get(i)
-			labelPosToLabel[pos] = label(i)
-		}
-		fnIndex = wazevoapi.GetCurrentFunctionIndex(ctx)
-	}
-
 	m.labelResolutionPends = m.labelResolutionPends[:0]
 	for _, pos := range m.orderedSSABlockLabelPos {
 		offset := int64(len(*bufPtr))
@@ -2150,12 +2196,6 @@ func (m *machine) Encode(ctx context.Context) (err error) {
 				)
 			}
 		}
-
-		if wazevoapi.PerfMapEnabled {
-			l := labelPosToLabel[pos]
-			size := int64(len(*bufPtr)) - offset
-			wazevoapi.PerfMap.AddModuleEntry(fnIndex, offset, uint64(size), fmt.Sprintf("%s:::::%s", fn, l))
-		}
 	}
 
 	for i := range m.consts {
@@ -69,7 +69,7 @@ func (m *mockCompiler) TypeOf(v regalloc.VReg) (ret ssa.Type) {
 }
 func (m *mockCompiler) Finalize(context.Context) (err error) { return }
 func (m *mockCompiler) RegAlloc()                            {}
-func (m *mockCompiler) Lower()                               {}
+func (m *mockCompiler) Lower() error                         { return nil }
 func (m *mockCompiler) Format() string                       { return "" }
 func (m *mockCompiler) Init()                                {}
 
@@ -260,7 +260,8 @@ func (m *machine) resolveAddressModeForOffset(offset int64, dstBits byte, rn reg
 }
 
 func (m *machine) lowerCall(si *ssa.Instruction) {
-	isDirectCall := si.Opcode() == ssa.OpcodeCall
+	op := si.Opcode()
+	isDirectCall := op == ssa.OpcodeCall
 	var indirectCalleePtr ssa.Value
 	var directCallee ssa.FuncRef
 	var sigID ssa.SignatureID
@@ -307,6 +308,73 @@ func (m *machine) lowerCall(si *ssa.Instruction) {
 	}
 }
 
+// LowerTailCall implements backend.Machine.
+func (m *machine) LowerTailCall(si *ssa.Instruction) error {
+	isDirectCall := si.Opcode() == ssa.OpcodeTailCallReturnCall
+	var indirectCalleePtr ssa.Value
+	var directCallee ssa.FuncRef
+	var sigID ssa.SignatureID
+	var args []ssa.Value
+	if isDirectCall {
+		directCallee, sigID, args = si.CallData()
+	} else {
+		indirectCalleePtr, sigID, args, _ = si.CallIndirectData()
+	}
+	calleeABI := m.compiler.GetFunctionABI(m.compiler.SSABuilder().ResolveSignature(sigID))
+
+	// The stack arguments are passed via the argument stack slots of the current function, so the callee
+	// must have the same layout of them as well as the result stack slots which are placed right above them.
+	if calleeABI.ArgStackSize != m.currentABI.ArgStackSize {
+		return backend.ErrTailCallStackArgs
+	}
+
+	if !isDirectCall {
+		// x17 is neither restored by the epilogue nor used for arguments, so we move the address
+		// there before setting up the arguments.
+		m.InsertMove(x17VReg, m.compiler.VRegOf(indirectCalleePtr), ssa.TypeI64)
+	}
+
+	for i, arg := range args {
+		reg := m.compiler.VRegOf(arg)
+		def := m.compiler.ValueDefinition(arg)
+		m.callerGenVRegToTailCallArg(calleeABI, i, reg, def)
+	}
+
+	// Note that the epilogue will be inserted right before the branch after the register allocation.
+	tailCall := m.allocateInstr()
+	if isDirectCall {
+		tailCall.asTailCall(directCallee, calleeABI)
+	} else {
+		tailCall.asTailCallIndirect(x17VReg, calleeABI)
+	}
+	m.insert(tailCall)
+	return nil
+}
+
+// callerGenVRegToTailCallArg is the same as callerGenVRegToFunctionArg except that the stack arguments
+// are stored in the argument stack slots of the current function.
+func (m *machine) callerGenVRegToTailCallArg(a *backend.FunctionABI, argIndex int, reg regalloc.VReg, def backend.SSAValueDefinition) {
+	arg := &a.Args[argIndex]
+	if arg.Kind == backend.ABIArgKindReg {
+		m.callerGenVRegToFunctionArg(a, argIndex, reg, def, 0)
+		return
+	}
+
+	if def.IsFromInstr() {
+		// Constant instructions are inlined.
+		if inst := def.Instr; inst.Constant() {
+			m.insertLoadConstant(inst.ConstantVal(), inst.Return().Type(), reg)
+		}
+	}
+	// See LowerParams for the address mode.
+	amode := m.amodePool.Allocate()
+	*amode = addressMode{imm: arg.Offset, rn: spVReg, kind: addressModeKindArgStackSpace}
+	store := m.allocateInstr()
+	store.asStore(operandNR(reg), amode, arg.Type.Bits())
+	m.insert(store)
+	m.unresolvedAddressModes = append(m.unresolvedAddressModes, store)
+}
+
 func (m *machine) insertAddOrSubStackPointer(rd regalloc.VReg, diff int64, add bool) {
 	if imm12Operand, ok := asImm12Operand(uint64(diff)); ok {
 		alu := m.allocateInstr()
@@ -0,0 +1,96 @@
+package arm64
+
+import (
+	"encoding/binary"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// lazyFunctionEntrySize is the size of the entries encoded by EncodeLazyFunctionEntry.
+const lazyFunctionEntrySize = 8 * 4
+
+// CompileLazyCompilationTrampoline implements backend.Machine.
+func (m *machine) CompileLazyCompilationTrampoline() []byte {
+	cur := m.allocateInstr()
+	cur.asNop0()
+	m.rootInstr = cur
+
+	// The entry branches here without touching the stack and the link register, so the state is the same as at the
+	// beginning of the function. Save the callee saved and argument registers, including the link register.
+	cur = m.saveRegistersInExecutionContext(cur, saveRequiredRegs)
+
+	// The module context of the function is always the second argument, and identifies the module to compile.
+	store := m.allocateInstr()
+	storeMode := m.amodePool.Allocate()
+	*storeMode = addressMode{
+		kind: addressModeKindRegUnsignedImm12,
+		rn:   x0VReg, imm: wazevoapi.ExecutionContextOffsetCallerModuleContextPtr.I64(),
+	}
+	store.asStore(operandNR(x1VReg), storeMode, 64)
+	cur = linkInstr(cur, store)
+
+	// Save the current stack pointer.
+	cur = m.saveCurrentStackPointer(cur, x0VReg)
+
+	// Set the exit status on the execution context.
+	cur = m.setExitCode(cur, x0VReg, wazevoapi.ExitCodeLazyCompile)
+
+	// Exit the execution.
+	cur = m.storeReturnAddressAndExit(cur)
+
+	// After the exit, restore the saved registers.
+	cur = m.restoreRegistersInExecutionContext(cur, saveRequiredRegs)
+
+	// Load the address of the compiled function, and branch to it as if it was called in the first place.
+	load := m.allocateInstr()
+	mode := m.amodePool.Allocate()
+	*mode = addressMode{
+		kind: addressModeKindRegUnsignedImm12,
+		rn:   x0VReg, imm: wazevoapi.ExecutionContextOffsetLazyFunctionExecutable.I64(),
+	}
+	load.asULoad(tmpRegVReg, mode, 64)
+	cur = linkInstr(cur, load)
+
+	br := m.allocateInstr()
+	br.kind = tailCallInd
+	br.rn = operandNR(tmpRegVReg)
+	linkInstr(cur, br)
+
+	m.encode(m.rootInstr)
+	return m.compiler.Buf()
+}
+
+// LazyFunctionEntrySize implements backend.Machine.
+func (m *machine) LazyFunctionEntrySize() int {
+	return lazyFunctionEntrySize
+}
+
+// EncodeLazyFunctionEntry implements backend.Machine.
+//
+// The entry is encoded as follows, where tmp is safe to overwrite at the beginning of a function:
+//
+//	adrp tmp, slot
+//	ldr tmp, [tmp, #:lo12:slot]
+//	br tmp
+//	movz tmp, #index[0:16]           ;; <- lazyOffset
+//	movk tmp, #index[16:32], lsl 16
+//	str tmp, [x0, #ExecutionContextOffsetLazyFunctionIndex]
+//	b trampoline
+//	udf
+func (m *machine) EncodeLazyFunctionEntry(executable []byte, entryOffset, slotOffset, trampolineOffset int, index uint32) (lazyOffset int) {
+	tmp := regNumberInEncoding[tmpRegVReg.RealReg()]
+	entry := executable[entryOffset : entryOffset+lazyFunctionEntrySize]
+
+	// adrp works on 4KiB pages regardless of the page size of the OS.
+	binary.LittleEndian.PutUint32(entry[0:], encodeAdrp(tmp, int64(slotOffset>>12)-int64(entryOffset>>12)))
+	binary.LittleEndian.PutUint32(entry[4:], encodeLoadOrStore(uLoad64, tmp,
+		addressMode{kind: addressModeKindRegUnsignedImm12, rn: tmpRegVReg, imm: int64(slotOffset & 0xfff)}))
+	binary.LittleEndian.PutUint32(entry[8:], encodeUnconditionalBranchReg(tmp, false))
+	binary.LittleEndian.PutUint32(entry[12:], encodeMoveWideImmediate(0b10, tmp, uint64(index&0xffff), 0, 1))
+	binary.LittleEndian.PutUint32(entry[16:], encodeMoveWideImmediate(0b11, tmp, uint64(index>>16), 1, 1))
+	binary.LittleEndian.PutUint32(entry[20:], encodeLoadOrStore(store64, tmp,
+		addressMode{kind: addressModeKindRegUnsignedImm12, rn: x0VReg, imm: wazevoapi.ExecutionContextOffsetLazyFunctionIndex.I64()}))
+	binary.LittleEndian.PutUint32(entry[24:], encodeUnconditionalBranch(false, int64(trampolineOffset-(entryOffset+24))))
+	binary.LittleEndian.PutUint32(entry[28:], 0) // udf #0
+	return entryOffset + 12
+}
@@ -0,0 +1,119 @@
+package arm64
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestMachine_CompileLazyCompilationTrampoline(t *testing.T) {
+	_, _, m := newSetupWithMockContext()
+	m.CompileLazyCompilationTrampoline()
+	require.Equal(t, `
+	str x1, [x0, #0x60]
+	str x2, [x0, #0x70]
+	str x3, [x0, #0x80]
+	str x4, [x0, #0x90]
+	str x5, [x0, #0xa0]
+	str x6, [x0, #0xb0]
+	str x7, [x0, #0xc0]
+	str x19, [x0, #0xd0]
+	str x20, [x0, #0xe0]
+	str x21, [x0, #0xf0]
+	str x22, [x0, #0x100]
+	str x23, [x0, #0x110]
+	str x24, [x0, #0x120]
+	str x25, [x0, #0x130]
+	str x26, [x0, #0x140]
+	str x28, [x0, #0x150]
+	str x30, [x0, #0x160]
+	str q0, [x0, #0x170]
+	str q1, [x0, #0x180]
+	str q2, [x0, #0x190]
+	str q3, [x0, #0x1a0]
+	str q4, [x0, #0x1b0]
+	str q5, [x0, #0x1c0]
+	str q6, [x0, #0x1d0]
+	str q7, [x0, #0x1e0]
+	str q18, [x0, #0x1f0]
+	str q19, [x0, #0x200]
+	str q20, [x0, #0x210]
+	str q21, [x0, #0x220]
+	str q22, [x0, #0x230]
+	str q23, [x0, #0x240]
+	str q24, [x0, #0x250]
+	str q25, [x0, #0x260]
+	str q26, [x0, #0x270]
+	str q27, [x0, #0x280]
+	str q28, [x0, #0x290]
+	str q29, [x0, #0x2a0]
+	str q30, [x0, #0x2b0]
+	str q31, [x0, #0x2c0]
+	str x1, [x0, #0x8]
+	mov x27, sp
+	str x27, [x0, #0x38]
+	orr w17, wzr, #0x1c
+	str w17, [x0]
+	adr x27, #0x20
+	str x27, [x0, #0x30]
+	exit_sequence x0
+	ldr x1, [x0, #0x60]
+	ldr x2, [x0, #0x70]
+	ldr x3, [x0, #0x80]
+	ldr x4, [x0, #0x90]
+	ldr x5, [x0, #0xa0]
+	ldr x6, [x0, #0xb0]
+	ldr x7, [x0, #0xc0]
+	ldr x19, [x0, #0xd0]
+	ldr x20, [x0, #0xe0]
+	ldr x21, [x0, #0xf0]
+	ldr x22, [x0, #0x100]
+	ldr x23, [x0, #0x110]
+	ldr x24, [x0, #0x120]
+	ldr x25, [x0, #0x130]
+	ldr x26, [x0, #0x140]
+	ldr x28, [x0, #0x150]
+	ldr x30, [x0, #0x160]
+	ldr q0, [x0, #0x170]
+	ldr q1, [x0, #0x180]
+	ldr q2, [x0, #0x190]
+	ldr q3, [x0, #0x1a0]
+	ldr q4, [x0, #0x1b0]
+	ldr q5, [x0, #0x1c0]
+	ldr q6, [x0, #0x1d0]
+	ldr q7, [x0, #0x1e0]
+	ldr q18, [x0, #0x1f0]
+	ldr q19, [x0, #0x200]
+	ldr q20, [x0, #0x210]
+	ldr q21, [x0, #0x220]
+	ldr q22, [x0, #0x230]
+	ldr q23, [x0, #0x240]
+	ldr q24, [x0, #0x250]
+	ldr q25, [x0, #0x260]
+	ldr q26, [x0, #0x270]
+	ldr q27, [x0, #0x280]
+	ldr q28, [x0, #0x290]
+	ldr q29, [x0, #0x2a0]
+	ldr q30, [x0, #0x2b0]
+	ldr q31, [x0, #0x2c0]
+	ldr x27, [x0, #0x4c8]
+	br x27
+`, m.Format())
+}
+
+func TestMachine_EncodeLazyFunctionEntry(t *testing.T) {
+	m := NewBackend().(*machine)
+	executable := make([]byte, 0x3000)
+	lazyOffset := m.EncodeLazyFunctionEntry(executable, 0x40, 0x2008, 0, 0x12345)
+	require.Equal(t, 76, lazyOffset)
+	// adrp x27, #0x2000
+	// ldr x27, [x27, #0x8]
+	// br x27
+	// movz x27, #0x2345
+	// movk x27, #0x1, lsl 16
+	// str x27, [x0, #0x4c0]
+	// b 0
+	// udf #0
+	require.Equal(t, "1b0000d07b0740f960031fd6bb6884d23b00a0f21b6002f9eaffff1700000000", hex.EncodeToString(executable[0x40:0x40+lazyFunctionEntrySize]))
+}
@@ -81,6 +81,8 @@ var defKinds = [numInstructionKinds]defKind{
 	nop0:                 defKindNone,
 	call:                 defKindCall,
 	callInd:              defKindCall,
+	tailCall:             defKindNone,
+	tailCallInd:          defKindNone,
 	ret:                  defKindNone,
 	store8:               defKindNone,
 	store16:              defKindNone,
@@ -219,6 +221,8 @@ var useKinds = [numInstructionKinds]useKind{
 	nop0:                 useKindNone,
 	call:                 useKindCall,
 	callInd:              useKindCallInd,
+	tailCall:             useKindCall,
+	tailCallInd:          useKindCallInd,
 	ret:                  useKindNone,
 	store8:               useKindRNAMode,
 	store16:              useKindRNAMode,
@@ -479,6 +483,18 @@ func (i *instruction) asCallIndirect(ptr regalloc.VReg, abi *backend.FunctionABI
 	}
 }
 
+func (i *instruction) asTailCall(ref ssa.FuncRef, abi *backend.FunctionABI) {
+	i.kind = tailCall
+	i.u1 = uint64(ref)
+	i.u2 = abi.ABIInfoAsUint64()
+}
+
+func (i *instruction) asTailCallIndirect(ptr regalloc.VReg, abi *backend.FunctionABI) {
+	i.kind = tailCallInd
+	i.rn = operandNR(ptr)
+	i.u2 = abi.ABIInfoAsUint64()
+}
+
 func (i *instruction) callFuncRef() ssa.FuncRef {
 	return ssa.FuncRef(i.u1)
 }
@@ -1407,6 +1423,10 @@ func (i *instruction) String() (str string) {
 		str = fmt.Sprintf("bl %s", ssa.FuncRef(i.u1))
 	case callInd:
 		str = fmt.Sprintf("bl %s", formatVRegSized(i.rn.nr(), 64))
+	case tailCall:
+		str = fmt.Sprintf("b %s", ssa.FuncRef(i.u1))
+	case tailCallInd:
+		str = fmt.Sprintf("br %s", formatVRegSized(i.rn.nr(), 64))
 	case ret:
 		str = "ret"
 	case br:
@@ -1703,6 +1723,10 @@ const (
 	call
 	// callInd represents a machine indirect-call instruction.
 	callInd
+	// tailCall represents a machine tail-call instruction, which is a branch to the function preceded by the epilogue.
+	tailCall
+	// tailCallInd represents a machine indirect tail-call instruction, which is a branch to the register preceded by the epilogue.
+	tailCallInd
 	// ret represents a machine return instruction.
 	ret
 	// br represents an unconditional branch.
@@ -43,6 +43,12 @@ func (i *instruction) encode(m *machine) {
 		c.Emit4Bytes(encodeUnconditionalBranch(true, 0)) // 0 = placeholder
 	case callInd:
 		c.Emit4Bytes(encodeUnconditionalBranchReg(regNumberInEncoding[i.rn.realReg()], true))
+	case tailCall:
+		// Same as call, but without the link so that the callee returns to the caller of this function.
+		c.AddRelocationInfo(i.callFuncRef())
+		c.Emit4Bytes(encodeUnconditionalBranch(false, 0)) // 0 = placeholder
+	case tailCallInd:
+		c.Emit4Bytes(encodeUnconditionalBranchReg(regNumberInEncoding[i.rn.realReg()], false))
 	case store8, store16, store32, store64, fpuStore32, fpuStore64, fpuStore128:
 		c.Emit4Bytes(encodeLoadOrStore(i.kind, regNumberInEncoding[i.rn.realReg()], *i.getAmode()))
 	case uLoad8, uLoad16, uLoad32, uLoad64, sLoad8, sLoad16, sLoad32, fpuLoad32, fpuLoad64, fpuLoad128:
@@ -870,6 +876,16 @@ func encodeAdr(rd uint32, offset uint32) uint32 {
 	return offset&0b11<<29 | 0b1<<28 | offset&0b1111111111_1111111100<<3 | rd
 }
 
+// encodeAdrp encodes a PC-relative ADRP instruction, where pages is the offset in 4KiB pages.
+// https://developer.arm.com/documentation/ddi0602/2022-06/Base-Instructions/ADRP--Form-PC-relative-address-to-4KB-page-
+func encodeAdrp(rd uint32, pages int64) uint32 {
+	if pages >= 1<<20 || pages < -(1<<20) {
+		panic("BUG: too large adrp instruction")
+	}
+	imm := uint32(pages) & (1<<21 - 1)
+	return 0b1<<31 | imm&0b11<<29 | 0b1<<28 | imm>>2<<5 | rd
+}
+
 // encodeFpuCSel encodes as "Floating-point conditional select" in
 // https://developer.arm.com/documentation/ddi0596/2020-12/Index-by-Encoding/Data-Processing----Scalar-Floating-Point-and-Advanced-SIMD?lang=en
 func encodeFpuCSel(rd, rn, rm uint32, c condFlag, _64bit bool) uint32 {
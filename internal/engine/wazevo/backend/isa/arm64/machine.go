@@ -381,19 +381,6 @@ func (m *machine) resolveRelativeAddresses(ctx context.Context) {
 		// Reuse the slice to gather the unresolved conditional branches.
 		m.condBrRelocs = m.condBrRelocs[:0]
 
-		var fn string
-		var fnIndex int
-		var labelPosToLabel map[*labelPosition]label
-		if wazevoapi.PerfMapEnabled {
-			labelPosToLabel = make(map[*labelPosition]label)
-			for i := 0; i <= m.labelPositionPool.MaxIDEncountered(); i++ {
-				labelPosToLabel[m.labelPositionPool.Get(i)] = label(i)
-			}
-
-			fn = wazevoapi.GetCurrentFunctionName(ctx)
-			fnIndex = wazevoapi.GetCurrentFunctionIndex(ctx)
-		}
-
 		// Next, in order to determine the offsets of relative jumps, we have to calculate the size of each label.
 		var offset int64
 		for i, pos := range m.orderedSSABlockLabelPos {
@@ -426,11 +413,6 @@ func (m *machine) resolveRelativeAddresses(ctx context.Context) {
 				}
 			}
 
-			if wazevoapi.PerfMapEnabled {
-				if size > 0 {
-					wazevoapi.PerfMap.AddModuleEntry(fnIndex, offset, uint64(size), fmt.Sprintf("%s:::::%s", fn, labelPosToLabel[pos]))
-				}
-			}
 			offset += size
 		}
 
@@ -453,11 +435,7 @@ func (m *machine) resolveRelativeAddresses(ctx context.Context) {
 				needRerun = true
 			}
 		}
-		if needRerun {
-			if wazevoapi.PerfMapEnabled {
-				wazevoapi.PerfMap.Clear()
-			}
-		} else {
+		if !needRerun {
 			break
 		}
 	}
@@ -65,7 +65,9 @@ func (m *machine) ResolveRelocations(
 				panic("BUG in trampoline placement")
 			}
 		}
-		binary.LittleEndian.PutUint32(executable[instrOffset:instrOffset+4], encodeUnconditionalBranch(true, diff))
+		// The placeholder is either bl (call) or b (tail call), so we preserve the link bit.
+		link := binary.LittleEndian.Uint32(executable[instrOffset:instrOffset+4])&(1<<31) != 0
+		binary.LittleEndian.PutUint32(executable[instrOffset:instrOffset+4], encodeUnconditionalBranch(link, diff))
 	}
 }
 
@@ -0,0 +1,320 @@
+package riscv64
+
+import (
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+)
+
+// References:
+// * https://github.com/golang/go/blob/49d42128fd8594c172162961ead19ac95e247d24/src/cmd/compile/abi-internal.md#riscv64-architecture
+// * https://github.com/riscv-non-isa/riscv-elf-psabi-doc/blob/master/riscv-cc.adoc
+
+var (
+	intParamResultRegs   = []regalloc.RealReg{x10, x11, x12, x13, x14, x15, x16, x17}
+	floatParamResultRegs = []regalloc.RealReg{f10, f11, f12, f13, f14, f15, f16, f17}
+)
+
+var regInfo = &regalloc.RegisterInfo{
+	AllocatableRegisters: [regalloc.NumRegType][]regalloc.RealReg{
+		// We don't allocate:
+		// - x0-x4: zero, ra, sp, gp and tp.
+		// - x27: Reserved by Go runtime.
+		// - x31(=tmp) and f31(=ftmp): because of the reason described on tmp.
+		regalloc.RegTypeInt: {
+			x5, x6, x7, x8, x9, x18, x19, x20, x21, x22, x23, x24, x25, x26, x28, x29, x30,
+			// These are the argument/return registers. Less preferred in the allocation.
+			x17, x16, x15, x14, x13, x12, x11, x10,
+		},
+		regalloc.RegTypeFloat: {
+			f0, f1, f2, f3, f4, f5, f6, f7, f8, f9,
+			f18, f19, f20, f21, f22, f23, f24, f25, f26, f27, f28, f29, f30,
+			// These are the argument/return registers. Less preferred in the allocation.
+			f17, f16, f15, f14, f13, f12, f11, f10,
+		},
+	},
+	CalleeSavedRegisters: regalloc.NewRegSet(
+		x8, x9, x18, x19, x20, x21, x22, x23, x24, x25, x26,
+		f8, f9, f18, f19, f20, f21, f22, f23, f24, f25, f26, f27,
+	),
+	CallerSavedRegisters: regalloc.NewRegSet(
+		x5, x6, x7, x10, x11, x12, x13, x14, x15, x16, x17, x28, x29, x30,
+		f0, f1, f2, f3, f4, f5, f6, f7, f10, f11, f12, f13, f14, f15, f16, f17, f28, f29, f30,
+	),
+	RealRegToVReg: []regalloc.VReg{
+		x0: x0VReg, x1: x1VReg, x2: x2VReg, x3: x3VReg, x4: x4VReg, x5: x5VReg, x6: x6VReg, x7: x7VReg, x8: x8VReg, x9: x9VReg, x10: x10VReg, x11: x11VReg, x12: x12VReg, x13: x13VReg, x14: x14VReg, x15: x15VReg, x16: x16VReg, x17: x17VReg, x18: x18VReg, x19: x19VReg, x20: x20VReg, x21: x21VReg, x22: x22VReg, x23: x23VReg, x24: x24VReg, x25: x25VReg, x26: x26VReg, x27: x27VReg, x28: x28VReg, x29: x29VReg, x30: x30VReg, x31: x31VReg,
+		f0: f0VReg, f1: f1VReg, f2: f2VReg, f3: f3VReg, f4: f4VReg, f5: f5VReg, f6: f6VReg, f7: f7VReg, f8: f8VReg, f9: f9VReg, f10: f10VReg, f11: f11VReg, f12: f12VReg, f13: f13VReg, f14: f14VReg, f15: f15VReg, f16: f16VReg, f17: f17VReg, f18: f18VReg, f19: f19VReg, f20: f20VReg, f21: f21VReg, f22: f22VReg, f23: f23VReg, f24: f24VReg, f25: f25VReg, f26: f26VReg, f27: f27VReg, f28: f28VReg, f29: f29VReg, f30: f30VReg, f31: f31VReg,
+	},
+	RealRegName: func(r regalloc.RealReg) string { return regNames[r] },
+	RealRegType: func(r regalloc.RealReg) regalloc.RegType {
+		if r < f0 {
+			return regalloc.RegTypeInt
+		}
+		return regalloc.RegTypeFloat
+	},
+}
+
+// ArgsResultsRegs implements backend.Machine.
+func (m *machine) ArgsResultsRegs() (argResultInts, argResultFloats []regalloc.RealReg) {
+	return intParamResultRegs, floatParamResultRegs
+}
+
+// LowerParams implements backend.FunctionABI.
+func (m *machine) LowerParams(args []ssa.Value) {
+	a := m.currentABI
+
+	for i, ssaArg := range args {
+		if !ssaArg.Valid() {
+			continue
+		}
+		reg := m.compiler.VRegOf(ssaArg)
+		arg := &a.Args[i]
+		if arg.Kind == backend.ABIArgKindReg {
+			m.InsertMove(reg, arg.Reg, arg.Type)
+		} else {
+			//            (high address)
+			//          +-----------------+
+			//          |     .......     |
+			//          |      ret Y      |
+			//          |     .......     |
+			//          |      ret 0      |
+			//          |      arg X      |
+			//          |     .......     |
+			//          |      arg 1      |
+			//          |      arg 0      |    <-|
+			//          |   ReturnAddress |      |
+			//          +-----------------+      |
+			//          |   ...........   |      |
+			//          |   clobbered  M  |      |   argStackOffset: is unknown at this point of compilation.
+			//          |   ............  |      |
+			//          |   clobbered  0  |      |
+			//          |   spill slot N  |      |
+			//          |   ...........   |      |
+			//          |   spill slot 0  |      |
+			//   SP---> +-----------------+    <-+
+			//             (low address)
+
+			// At this point of compilation, we don't yet know how much space exist below the return address.
+			// So we instruct the address mode to add the `argStackOffset` to the offset at the later phase of compilation.
+			load := m.allocateInstr()
+			load.asLoad(loadOpFor(arg.Type), reg, addressMode{imm: arg.Offset, base: spVReg, kind: addressModeKindArgStackSpace})
+			m.insert(load)
+			m.unresolvedAddressModes = append(m.unresolvedAddressModes, load)
+		}
+	}
+}
+
+// LowerReturns lowers the given returns.
+func (m *machine) LowerReturns(rets []ssa.Value) {
+	a := m.currentABI
+
+	l := len(rets) - 1
+	for i := range rets {
+		// Reverse order in order to avoid overwriting the stack returns existing in the return registers.
+		ret := rets[l-i]
+		r := &a.Rets[l-i]
+		reg := m.compiler.VRegOf(ret)
+		if def := m.compiler.ValueDefinition(ret); def.IsFromInstr() {
+			// Constant instructions are inlined.
+			if inst := def.Instr; inst.Constant() {
+				val := inst.Return()
+				valType := val.Type()
+				v := inst.ConstantVal()
+				m.insertLoadConstant(v, valType, reg)
+			}
+		}
+		if r.Kind == backend.ABIArgKindReg {
+			m.InsertMove(r.Reg, reg, ret.Type())
+		} else {
+			// See LowerParams for the stack layout. The results are placed right above the arguments.
+			store := m.allocateInstr()
+			store.asStore(storeOpFor(r.Type), reg, addressMode{imm: r.Offset, base: spVReg, kind: addressModeKindResultStackSpace})
+			m.insert(store)
+			m.unresolvedAddressModes = append(m.unresolvedAddressModes, store)
+		}
+	}
+}
+
+// callerGenVRegToFunctionArg is the opposite of GenFunctionArgToVReg, which is used to generate the
+// caller side of the function call.
+func (m *machine) callerGenVRegToFunctionArg(a *backend.FunctionABI, argIndex int, reg regalloc.VReg, def backend.SSAValueDefinition, slotBegin int64) {
+	arg := &a.Args[argIndex]
+	if def.IsFromInstr() {
+		// Constant instructions are inlined.
+		if inst := def.Instr; inst.Constant() {
+			val := inst.Return()
+			valType := val.Type()
+			v := inst.ConstantVal()
+			m.insertLoadConstant(v, valType, reg)
+		}
+	}
+	if arg.Kind == backend.ABIArgKindReg {
+		m.InsertMove(arg.Reg, reg, arg.Type)
+	} else {
+		// Note that at this point, stack pointer is already adjusted.
+		store := m.allocateInstr()
+		store.asStore(storeOpFor(arg.Type), reg, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: arg.Offset - slotBegin})
+		m.insert(store)
+	}
+}
+
+func (m *machine) callerGenFunctionReturnVReg(a *backend.FunctionABI, retIndex int, reg regalloc.VReg, slotBegin int64) {
+	r := &a.Rets[retIndex]
+	if r.Kind == backend.ABIArgKindReg {
+		m.InsertMove(reg, r.Reg, r.Type)
+	} else {
+		ld := m.allocateInstr()
+		ld.asLoad(loadOpFor(r.Type), reg, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: a.ArgStackSize + r.Offset - slotBegin})
+		m.insert(ld)
+	}
+}
+
+func (m *machine) lowerCall(si *ssa.Instruction) {
+	op := si.Opcode()
+	isDirectCall := op == ssa.OpcodeCall
+	var indirectCalleePtr ssa.Value
+	var directCallee ssa.FuncRef
+	var sigID ssa.SignatureID
+	var args []ssa.Value
+	var isMemmove bool
+	if isDirectCall {
+		directCallee, sigID, args = si.CallData()
+	} else {
+		indirectCalleePtr, sigID, args, isMemmove = si.CallIndirectData()
+	}
+	calleeABI := m.compiler.GetFunctionABI(m.compiler.SSABuilder().ResolveSignature(sigID))
+
+	stackSlotSize := int64(calleeABI.AlignedArgResultStackSlotSize())
+	if m.maxRequiredStackSizeForCalls < stackSlotSize+16 {
+		m.maxRequiredStackSizeForCalls = stackSlotSize + 16 // return address frame.
+	}
+
+	for i, arg := range args {
+		reg := m.compiler.VRegOf(arg)
+		def := m.compiler.ValueDefinition(arg)
+		m.callerGenVRegToFunctionArg(calleeABI, i, reg, def, stackSlotSize)
+	}
+
+	if isMemmove {
+		// Go's memmove clobbers some of the registers which are callee-saved in our ABI, so we need to release them.
+		// https://github.com/golang/go/blob/go1.21.0/src/runtime/memmove_riscv64.s
+		for _, r := range memmoveClobberedCalleeSavedRegs {
+			m.insert(m.allocateInstr().asDefineUninitializedReg(regInfo.RealRegToVReg[r]))
+		}
+	}
+
+	if isDirectCall {
+		call := m.allocateInstr()
+		call.asCall(directCallee, calleeABI)
+		m.insert(call)
+	} else {
+		ptr := m.getOperand(indirectCalleePtr)
+		callInd := m.allocateInstr()
+		callInd.asCallIndirect(ptr, calleeABI)
+		m.insert(callInd)
+	}
+
+	if isMemmove {
+		for _, r := range memmoveClobberedCalleeSavedRegs {
+			m.insert(m.allocateInstr().asNopUseReg(regInfo.RealRegToVReg[r]))
+		}
+	}
+
+	var index int
+	r1, rs := si.Returns()
+	if r1.Valid() {
+		m.callerGenFunctionReturnVReg(calleeABI, 0, m.compiler.VRegOf(r1), stackSlotSize)
+		index++
+	}
+
+	for _, r := range rs {
+		m.callerGenFunctionReturnVReg(calleeABI, index, m.compiler.VRegOf(r), stackSlotSize)
+		index++
+	}
+}
+
+// memmoveClobberedCalleeSavedRegs are the registers used by Go's memmove which are callee-saved in our ABI.
+var memmoveClobberedCalleeSavedRegs = [...]regalloc.RealReg{x9, x18, x19, x20, x21}
+
+// LowerTailCall implements backend.Machine.
+func (m *machine) LowerTailCall(si *ssa.Instruction) error {
+	isDirectCall := si.Opcode() == ssa.OpcodeTailCallReturnCall
+	var indirectCalleePtr ssa.Value
+	var directCallee ssa.FuncRef
+	var sigID ssa.SignatureID
+	var args []ssa.Value
+	if isDirectCall {
+		directCallee, sigID, args = si.CallData()
+	} else {
+		indirectCalleePtr, sigID, args, _ = si.CallIndirectData()
+	}
+	calleeABI := m.compiler.GetFunctionABI(m.compiler.SSABuilder().ResolveSignature(sigID))
+
+	// The stack arguments are passed via the argument stack slots of the current function, so the callee
+	// must have the same layout of them as well as the result stack slots which are placed right above them.
+	if calleeABI.ArgStackSize != m.currentABI.ArgStackSize {
+		return backend.ErrTailCallStackArgs
+	}
+
+	if !isDirectCall {
+		// x6 is neither restored by the epilogue nor used for arguments, so we move the address
+		// there before setting up the arguments.
+		m.InsertMove(x6VReg, m.compiler.VRegOf(indirectCalleePtr), ssa.TypeI64)
+	}
+
+	for i, arg := range args {
+		reg := m.compiler.VRegOf(arg)
+		def := m.compiler.ValueDefinition(arg)
+		m.callerGenVRegToTailCallArg(calleeABI, i, reg, def)
+	}
+
+	// Note that the epilogue will be inserted right before the branch after the register allocation.
+	tailCall := m.allocateInstr()
+	if isDirectCall {
+		tailCall.asTailCall(directCallee, calleeABI)
+	} else {
+		tailCall.asTailCallIndirect(x6VReg, calleeABI)
+	}
+	m.insert(tailCall)
+	return nil
+}
+
+// callerGenVRegToTailCallArg is the same as callerGenVRegToFunctionArg except that the stack arguments
+// are stored in the argument stack slots of the current function.
+func (m *machine) callerGenVRegToTailCallArg(a *backend.FunctionABI, argIndex int, reg regalloc.VReg, def backend.SSAValueDefinition) {
+	arg := &a.Args[argIndex]
+	if arg.Kind == backend.ABIArgKindReg {
+		m.callerGenVRegToFunctionArg(a, argIndex, reg, def, 0)
+		return
+	}
+
+	if def.IsFromInstr() {
+		// Constant instructions are inlined.
+		if inst := def.Instr; inst.Constant() {
+			m.insertLoadConstant(inst.ConstantVal(), inst.Return().Type(), reg)
+		}
+	}
+	// See LowerParams for the address mode.
+	store := m.allocateInstr()
+	store.asStore(storeOpFor(arg.Type), reg, addressMode{imm: arg.Offset, base: spVReg, kind: addressModeKindArgStackSpace})
+	m.insert(store)
+	m.unresolvedAddressModes = append(m.unresolvedAddressModes, store)
+}
+
+// insertAddOrSubStackPointer inserts the instructions to add or subtract diff to/from the stack pointer,
+// and write the result into rd. The temporary register is used if diff doesn't fit in the signed 12-bit.
+func (m *machine) insertAddOrSubStackPointer(rd regalloc.VReg, diff int64, add bool) {
+	if !add {
+		diff = -diff
+	}
+	if fitsInSigned12(diff) {
+		alu := m.allocateInstr()
+		alu.asALUImm(aluOpAdd, rd, spVReg, diff)
+		m.insert(alu)
+	} else {
+		m.lowerConstantI64(tmpVReg, diff)
+		alu := m.allocateInstr()
+		alu.asALU(aluOpAdd, rd, spVReg, tmpVReg)
+		m.insert(alu)
+	}
+}
@@ -0,0 +1,174 @@
+package riscv64
+
+import (
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// CompileEntryPreamble implements backend.Machine. This assumes `entrypoint` function (in abi_entry_riscv64.s) passes:
+//
+//  1. First (execution context ptr) and Second arguments are already passed in x10, and x11.
+//  2. param/result slice ptr in x18; the pointer to []uint64{} which is used to pass arguments and accept return values.
+//  3. Go-allocated stack slice ptr in x26.
+//  4. Function executable in x24.
+//
+// also SP is the correct Go-runtime-based value, and RA is the return address to the Go-side caller.
+func (m *machine) CompileEntryPreamble(signature *ssa.Signature) []byte {
+	root := m.constructEntryPreamble(signature)
+	m.encode(root)
+	return m.compiler.Buf()
+}
+
+var (
+	executionContextPtrReg = x10VReg
+	// callee-saved regs so that they can be used in the prologue and epilogue.
+	paramResultSlicePtr      = x18VReg
+	savedExecutionContextPtr = x19VReg
+	// goAllocatedStackPtr is not used in the epilogue.
+	goAllocatedStackPtr = x26VReg
+	// functionExecutable is not used in the epilogue.
+	functionExecutable = x24VReg
+	// intTmpReg and floatTmpReg are used to move the stack arguments and results.
+	// Caller save and not used for arguments, so we can use them for whatever we want.
+	intTmpReg, floatTmpReg = x30VReg, f28VReg
+)
+
+func (m *machine) goEntryPreamblePassArg(cur *instruction, paramSlicePtr regalloc.VReg, offsetInSlice int64, arg *backend.ABIArg, argStartOffsetFromSP int64) *instruction {
+	typ := arg.Type
+	isStackArg := arg.Kind == backend.ABIArgKindStack
+
+	var loadTargetReg regalloc.VReg
+	switch {
+	case !isStackArg:
+		loadTargetReg = arg.Reg
+	case typ.IsInt():
+		loadTargetReg = intTmpReg
+	default:
+		loadTargetReg = floatTmpReg
+	}
+
+	// Note that i32 is loaded with sign-extension which is the canonical form in the registers.
+	load := m.allocateInstr()
+	load.asLoad(loadOpFor(typ), loadTargetReg, addressMode{kind: addressModeKindRegImm, base: paramSlicePtr, imm: offsetInSlice})
+	cur = linkInstr(cur, load)
+
+	if isStackArg {
+		toStack := m.allocateInstr()
+		toStack.asStore(storeOpFor(typ), loadTargetReg,
+			addressMode{kind: addressModeKindRegImm, base: spVReg, imm: argStartOffsetFromSP + arg.Offset})
+		cur = linkInstr(cur, toStack)
+	}
+	return cur
+}
+
+func (m *machine) goEntryPreamblePassResult(cur *instruction, resultSlicePtr regalloc.VReg, offsetInSlice int64, result *backend.ABIArg, resultStartOffsetFromSP int64) *instruction {
+	typ := result.Type
+	isStackArg := result.Kind == backend.ABIArgKindStack
+
+	var storeTargetReg regalloc.VReg
+	switch {
+	case !isStackArg:
+		storeTargetReg = result.Reg
+	case typ.IsInt():
+		storeTargetReg = intTmpReg
+	default:
+		storeTargetReg = floatTmpReg
+	}
+
+	if isStackArg {
+		toReg := m.allocateInstr()
+		toReg.asLoad(loadOpFor(typ), storeTargetReg,
+			addressMode{kind: addressModeKindRegImm, base: spVReg, imm: resultStartOffsetFromSP + result.Offset})
+		cur = linkInstr(cur, toReg)
+	}
+
+	store := m.allocateInstr()
+	store.asStore(storeOpFor(typ), storeTargetReg, addressMode{kind: addressModeKindRegImm, base: resultSlicePtr, imm: offsetInSlice})
+	cur = linkInstr(cur, store)
+	return cur
+}
+
+func (m *machine) constructEntryPreamble(sig *ssa.Signature) (root *instruction) {
+	abi := backend.FunctionABI{}
+	abi.Init(sig, intParamResultRegs, floatParamResultRegs)
+
+	root = m.allocateNop()
+
+	//// ----------------------------------- prologue ----------------------------------- ////
+
+	// First, we save executionContextPtrReg into a callee-saved register so that it can be used in epilogue as well.
+	// 		mv savedExecutionContextPtr, x10
+	cur := m.move64(savedExecutionContextPtr, executionContextPtrReg, root)
+
+	// Next, save the current SP and RA into the wazevo.executionContext:
+	// 		sd sp, #OriginalStackPointer(savedExecutionContextPtr)
+	// 		sd ra, #GoReturnAddress(savedExecutionContextPtr)
+	cur = m.loadOrStoreAtExecutionContext(spVReg, wazevoapi.ExecutionContextOffsetOriginalStackPointer, true, cur)
+	cur = m.loadOrStoreAtExecutionContext(raVReg, wazevoapi.ExecutionContextOffsetGoReturnAddress, true, cur)
+
+	// Then, move the Go-allocated stack pointer to SP:
+	// 		mv sp, goAllocatedStackPtr
+	cur = m.move64(spVReg, goAllocatedStackPtr, cur)
+
+	stackSlotSize := int64(abi.AlignedArgResultStackSlotSize())
+	var offsetInSlice int64
+	for i := range abi.Args {
+		if i < 2 {
+			// module context ptr and execution context ptr are passed in x10 and x11 by the Go assembly function.
+			continue
+		}
+		arg := &abi.Args[i]
+		cur = m.goEntryPreamblePassArg(cur, paramResultSlicePtr, offsetInSlice, arg, -stackSlotSize)
+		offsetInSlice += 8 // We use uint64 for all basic types.
+	}
+
+	// Call the real function.
+	call := m.allocateInstr()
+	call.asCallIndirect(functionExecutable, &abi)
+	cur = linkInstr(cur, call)
+
+	///// ----------------------------------- epilogue ----------------------------------- /////
+
+	// Store the register results into paramResultSlicePtr.
+	offsetInSlice = 0
+	for i := range abi.Rets {
+		cur = m.goEntryPreamblePassResult(cur, paramResultSlicePtr, offsetInSlice, &abi.Rets[i], abi.ArgStackSize-stackSlotSize)
+		offsetInSlice += 8 // We use uint64 for all basic types.
+	}
+
+	// Finally, restore the SP and RA, and return to the Go code.
+	// 		ld sp, #OriginalStackPointer(savedExecutionContextPtr)
+	// 		ld ra, #GoReturnAddress(savedExecutionContextPtr)
+	// 		ret ;; --> return to the Go code
+	cur = m.loadOrStoreAtExecutionContext(spVReg, wazevoapi.ExecutionContextOffsetOriginalStackPointer, false, cur)
+	cur = m.loadOrStoreAtExecutionContext(raVReg, wazevoapi.ExecutionContextOffsetGoReturnAddress, false, cur)
+	retInst := m.allocateInstr()
+	retInst.asRet()
+	linkInstr(cur, retInst)
+	return
+}
+
+func (m *machine) move64(dst, src regalloc.VReg, prev *instruction) *instruction {
+	instr := m.allocateInstr()
+	instr.asMove64(dst, src)
+	return linkInstr(prev, instr)
+}
+
+func (m *machine) loadOrStoreAtExecutionContext(d regalloc.VReg, offset wazevoapi.Offset, store bool, prev *instruction) *instruction {
+	instr := m.allocateInstr()
+	mode := addressMode{kind: addressModeKindRegImm, base: savedExecutionContextPtr, imm: offset.I64()}
+	if store {
+		instr.asStore(memOpSd, d, mode)
+	} else {
+		instr.asLoad(memOpLd, d, mode)
+	}
+	return linkInstr(prev, instr)
+}
+
+func linkInstr(prev, next *instruction) *instruction {
+	prev.next = next
+	next.prev = prev
+	return next
+}
@@ -0,0 +1,27 @@
+//go:build riscv64
+
+#include "funcdata.h"
+#include "textflag.h"
+
+// See the comments on EmitGoEntryPreamble for what this function is supposed to do.
+TEXT ·entrypoint(SB), NOSPLIT|NOFRAME, $0-48
+	MOV preambleExecutable+0(FP), X5
+	MOV functionExecutable+8(FP), X24
+	MOV executionContextPtr+16(FP), X10
+	MOV moduleContextPtr+24(FP), X11
+	MOV paramResultPtr+32(FP), X18
+	MOV goAllocatedStackSlicePtr+40(FP), X26
+	JMP (X5)
+
+TEXT ·afterGoFunctionCallEntrypoint(SB), NOSPLIT|NOFRAME, $0-32
+	MOV executable+0(FP), X5
+	MOV executionContextPtr+8(FP), X10
+	MOV stackPointer+16(FP), X6
+
+	// Save the current SP and RA(X1) into the wazevo.executionContext (stored in X10).
+	MOV X2, 24(X10) // Store SP into [X10, #ExecutionContextOffsets.OriginalStackPointer]
+	MOV X1, 32(X10) // Store RA into [X10, #ExecutionContextOffsets.GoReturnAddress]
+
+	// Load the new stack pointer (which sits somewhere in Go-allocated stack) into SP.
+	MOV X6, X2
+	JMP (X5)
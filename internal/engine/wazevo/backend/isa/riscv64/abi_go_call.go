@@ -0,0 +1,309 @@
+package riscv64
+
+import (
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+var calleeSavedRegistersSorted = []regalloc.VReg{
+	x8VReg, x9VReg, x18VReg, x19VReg, x20VReg, x21VReg, x22VReg, x23VReg, x24VReg, x25VReg, x26VReg,
+	f8VReg, f9VReg, f18VReg, f19VReg, f20VReg, f21VReg, f22VReg, f23VReg, f24VReg, f25VReg, f26VReg, f27VReg,
+}
+
+// CompileGoFunctionTrampoline implements backend.Machine.
+func (m *machine) CompileGoFunctionTrampoline(exitCode wazevoapi.ExitCode, sig *ssa.Signature, needModuleContextPtr bool) []byte {
+	argBegin := 1 // Skips exec context by default.
+	if needModuleContextPtr {
+		argBegin++
+	}
+
+	abi := &backend.FunctionABI{}
+	abi.Init(sig, intParamResultRegs, floatParamResultRegs)
+	m.currentABI = abi
+
+	cur := m.allocateInstr()
+	cur.asNop0()
+	m.rootInstr = cur
+
+	// Execution context is always the first argument.
+	execCtrPtr := x10VReg
+
+	// In the following, we create the following stack layout:
+	//
+	//                   (high address)
+	//     SP ------> +-----------------+  <----+
+	//                |     .......     |       |
+	//                |      ret Y      |       |
+	//                |     .......     |       |
+	//                |      ret 0      |       |
+	//                |      arg X      |       |  size_of_arg_ret
+	//                |     .......     |       |
+	//                |      arg 1      |       |
+	//                |      arg 0      |  <----+ <-------- originalArg0Reg
+	//                | size_of_arg_ret |
+	//                |  ReturnAddress  |
+	//                +-----------------+ <----+
+	//                |      xxxx       |      |  ;; might be padded to make it 16-byte aligned.
+	//           +--->|  arg[N]/ret[M]  |      |
+	//  sliceSize|    |   ............  |      | goCallStackSize
+	//           |    |  arg[1]/ret[1]  |      |
+	//           +--->|  arg[0]/ret[0]  | <----+ <-------- arg0ret0AddrReg
+	//                |    sliceSize    |
+	//                |   frame_size    |
+	//                +-----------------+
+	//                   (low address)
+	//
+	// where the region of "arg[0]/ret[0] ... arg[N]/ret[M]" is the stack used by the Go functions,
+	// therefore will be accessed as the usual []uint64. So that's where we need to pass/receive
+	// the arguments/return values.
+
+	// First of all, to update the SP, and create "ReturnAddress + size_of_arg_ret".
+	cur = m.createReturnAddrAndSizeOfArgRetSlot(cur)
+
+	const frameInfoSize = 16 // == frame_size + sliceSize.
+
+	// Next, we should allocate the stack for the Go function call if necessary.
+	goCallStackSize, sliceSizeInBytes := backend.GoFunctionCallRequiredStackSize(sig, argBegin)
+	cur = m.insertStackBoundsCheck(goCallStackSize+frameInfoSize, cur)
+
+	originalArg0Reg := x28VReg // Caller save and not used for arguments, so we can use it for whatever we want.
+	if m.currentABI.AlignedArgResultStackSlotSize() > 0 {
+		// At this point, SP points to `ReturnAddress`, so add 16 to get the original arg 0 slot.
+		cur = m.addsAddOrSubStackPointer(cur, originalArg0Reg, frameInfoSize, true)
+	}
+
+	// Save the callee saved registers.
+	cur = m.saveRegistersInExecutionContext(cur, calleeSavedRegistersSorted)
+
+	if needModuleContextPtr {
+		offset := wazevoapi.ExecutionContextOffsetGoFunctionCallCalleeModuleContextOpaque.I64()
+		if !fitsInSigned12(offset) {
+			panic("BUG: too large offset for goFunctionCallCalleeModuleContextOpaque in execution context")
+		}
+
+		// Module context is always the second argument.
+		moduleCtrPtr := x11VReg
+		store := m.allocateInstr()
+		store.asStore(memOpSd, moduleCtrPtr, addressMode{kind: addressModeKindRegImm, base: execCtrPtr, imm: offset})
+		cur = linkInstr(cur, store)
+	}
+
+	// Advances the stack pointer.
+	cur = m.addsAddOrSubStackPointer(cur, spVReg, goCallStackSize, false)
+
+	// Copy the pointer to x29VReg.
+	arg0ret0AddrReg := x29VReg // Caller save and not used for arguments, so we can use it for whatever we want.
+	cur = m.move64(arg0ret0AddrReg, spVReg, cur)
+
+	// Next, we need to store all the arguments to the stack in the typical Wasm stack style.
+	var offset int64
+	for i := range abi.Args[argBegin:] {
+		arg := &abi.Args[argBegin+i]
+		var v regalloc.VReg
+		if arg.Kind == backend.ABIArgKindReg {
+			v = arg.Reg
+		} else {
+			cur, v = m.goFunctionCallLoadStackArg(cur, originalArg0Reg, arg,
+				// Caller save and not used for arguments, so we can use them for whatever we want.
+				x30VReg, f28VReg)
+		}
+		cur = m.goFunctionCallStoreSlot(cur, arg0ret0AddrReg, offset, arg.Type, v)
+		offset += 8 // We use uint64 for all basic types.
+	}
+
+	// Finally, now that we've advanced SP to arg[0]/ret[0], we allocate `frame_size + sliceSize`.
+	var frameSizeReg, sliceSizeReg regalloc.VReg
+	if goCallStackSize > 0 {
+		cur = m.lowerConstantI64AndInsert(cur, tmpVReg, goCallStackSize)
+		frameSizeReg = tmpVReg
+		cur = m.lowerConstantI64AndInsert(cur, x6VReg, sliceSizeInBytes/8)
+		sliceSizeReg = x6VReg
+	} else {
+		frameSizeReg = zeroVReg
+		sliceSizeReg = zeroVReg
+	}
+	cur = m.addsAddOrSubStackPointer(cur, spVReg, frameInfoSize, false)
+	storeFrameSize := m.allocateInstr()
+	storeFrameSize.asStore(memOpSd, frameSizeReg, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: 0})
+	cur = linkInstr(cur, storeFrameSize)
+	storeSliceSize := m.allocateInstr()
+	storeSliceSize.asStore(memOpSd, sliceSizeReg, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: 8})
+	cur = linkInstr(cur, storeSliceSize)
+
+	// Set the exit status on the execution context.
+	cur = m.setExitCode(cur, x10VReg, exitCode)
+
+	// Save the current stack pointer.
+	cur = m.saveCurrentStackPointer(cur, x10VReg)
+
+	// Exit the execution.
+	cur = m.storeReturnAddressAndExit(cur)
+
+	// After the call, we need to restore the callee saved registers.
+	cur = m.restoreRegistersInExecutionContext(cur, calleeSavedRegistersSorted)
+
+	// Get the pointer to the arg[0]/ret[0]: We need to skip `frame_size + sliceSize`.
+	if len(abi.Rets) > 0 {
+		cur = m.addsAddOrSubStackPointer(cur, arg0ret0AddrReg, frameInfoSize, true)
+	}
+
+	// Advances the SP so that it points to `ReturnAddress`.
+	cur = m.addsAddOrSubStackPointer(cur, spVReg, frameInfoSize+goCallStackSize, true)
+	// And load the return address.
+	ld := m.allocateInstr()
+	ld.asLoad(memOpLd, raVReg, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: 0})
+	cur = linkInstr(cur, ld)
+	cur = m.addsAddOrSubStackPointer(cur, spVReg, 16, true)
+
+	originalRet0Reg := x28VReg // Caller save and not used for arguments, so we can use it for whatever we want.
+	if m.currentABI.RetStackSize > 0 {
+		cur = m.addsAddOrSubStackPointer(cur, originalRet0Reg, m.currentABI.ArgStackSize, true)
+	}
+
+	// Make the SP point to the original address (above the result slot).
+	if s := int64(m.currentABI.AlignedArgResultStackSlotSize()); s > 0 {
+		cur = m.addsAddOrSubStackPointer(cur, spVReg, s, true)
+	}
+
+	offset = 0
+	for i := range abi.Rets {
+		r := &abi.Rets[i]
+		if r.Kind == backend.ABIArgKindReg {
+			load := m.allocateInstr()
+			load.asLoad(loadOpFor(r.Type), r.Reg, addressMode{kind: addressModeKindRegImm, base: arg0ret0AddrReg, imm: offset})
+			cur = linkInstr(cur, load)
+		} else {
+			// First we need to load the value to a temporary just like ^^.
+			resultReg := x30VReg
+			if !r.Type.IsInt() {
+				resultReg = f28VReg
+			}
+			load := m.allocateInstr()
+			load.asLoad(loadOpFor(r.Type), resultReg, addressMode{kind: addressModeKindRegImm, base: arg0ret0AddrReg, imm: offset})
+			cur = linkInstr(cur, load)
+			store := m.allocateInstr()
+			store.asStore(storeOpFor(r.Type), resultReg, addressMode{kind: addressModeKindRegImm, base: originalRet0Reg, imm: r.Offset})
+			cur = linkInstr(cur, store)
+		}
+		offset += 8 // We use uint64 for all basic types.
+	}
+
+	ret := m.allocateInstr()
+	ret.asRet()
+	linkInstr(cur, ret)
+
+	m.encode(m.rootInstr)
+	return m.compiler.Buf()
+}
+
+func (m *machine) saveRegistersInExecutionContext(cur *instruction, regs []regalloc.VReg) *instruction {
+	offset := wazevoapi.ExecutionContextOffsetSavedRegistersBegin.I64()
+	for _, v := range regs {
+		store := m.allocateInstr()
+		store.asStore(storeOpForRegType(v.RegType()), v, addressMode{
+			kind: addressModeKindRegImm,
+			// Execution context is always the first argument.
+			base: x10VReg, imm: offset,
+		})
+		cur = linkInstr(cur, store)
+		offset += 16 // The slots are 16 bytes each for vector registers on the other architectures.
+	}
+	return cur
+}
+
+func (m *machine) restoreRegistersInExecutionContext(cur *instruction, regs []regalloc.VReg) *instruction {
+	offset := wazevoapi.ExecutionContextOffsetSavedRegistersBegin.I64()
+	for _, v := range regs {
+		load := m.allocateInstr()
+		load.asLoad(loadOpForRegType(v.RegType()), v, addressMode{
+			kind: addressModeKindRegImm,
+			// Execution context is always the first argument.
+			base: x10VReg, imm: offset,
+		})
+		cur = linkInstr(cur, load)
+		offset += 16 // The slots are 16 bytes each for vector registers on the other architectures.
+	}
+	return cur
+}
+
+func (m *machine) lowerConstantI64AndInsert(cur *instruction, dst regalloc.VReg, v int64) *instruction {
+	m.pendingInstructions = m.pendingInstructions[:0]
+	m.lowerConstantI64(dst, v)
+	for _, instr := range m.pendingInstructions {
+		cur = linkInstr(cur, instr)
+	}
+	return cur
+}
+
+func (m *machine) setExitCode(cur *instruction, execCtr regalloc.VReg, exitCode wazevoapi.ExitCode) *instruction {
+	constReg := x7VReg // Caller-saved and not used for arguments, so we can use it.
+	cur = m.lowerConstantI64AndInsert(cur, constReg, int64(exitCode))
+
+	// Set the exit status on the execution context.
+	setExistStatus := m.allocateInstr()
+	setExistStatus.asStore(memOpSw, constReg,
+		addressMode{kind: addressModeKindRegImm, base: execCtr, imm: wazevoapi.ExecutionContextOffsetExitCodeOffset.I64()})
+	cur = linkInstr(cur, setExistStatus)
+	return cur
+}
+
+func (m *machine) storeReturnAddressAndExit(cur *instruction) *instruction {
+	// Read the return address into tmp, and store it in the execution context.
+	adr := m.allocateInstr()
+	adr.asAdr(tmpVReg, 8+4+exitSequenceSize) // adr (8 bytes) + sd (4 bytes) + exit sequence.
+	cur = linkInstr(cur, adr)
+
+	storeReturnAddr := m.allocateInstr()
+	storeReturnAddr.asStore(memOpSd, tmpVReg, addressMode{
+		kind: addressModeKindRegImm,
+		// Execution context is always the first argument.
+		base: x10VReg, imm: wazevoapi.ExecutionContextOffsetGoCallReturnAddress.I64(),
+	})
+	cur = linkInstr(cur, storeReturnAddr)
+
+	// Exit the execution.
+	trapSeq := m.allocateInstr()
+	trapSeq.asExitSequence(x10VReg)
+	cur = linkInstr(cur, trapSeq)
+	return cur
+}
+
+func (m *machine) saveCurrentStackPointer(cur *instruction, execCtr regalloc.VReg) *instruction {
+	// Save the current stack pointer:
+	// 	sd sp, #stackPointerBeforeGoCall(exec_ctx)
+	sdSp := m.allocateInstr()
+	sdSp.asStore(memOpSd, spVReg, addressMode{
+		kind: addressModeKindRegImm,
+		base: execCtr, imm: wazevoapi.ExecutionContextOffsetStackPointerBeforeGoCall.I64(),
+	})
+	cur = linkInstr(cur, sdSp)
+	return cur
+}
+
+func (m *machine) goFunctionCallLoadStackArg(cur *instruction, originalArg0Reg regalloc.VReg, arg *backend.ABIArg, intVReg, floatVReg regalloc.VReg) (*instruction, regalloc.VReg) {
+	result := intVReg
+	if !arg.Type.IsInt() {
+		result = floatVReg
+	}
+	load := m.allocateInstr()
+	load.asLoad(loadOpFor(arg.Type), result, addressMode{kind: addressModeKindRegImm, base: originalArg0Reg, imm: arg.Offset})
+	cur = linkInstr(cur, load)
+	return cur, result
+}
+
+// goFunctionCallStoreSlot stores the value of the given type into the 8-byte slot in the Go-allocated stack.
+// The upper 32 bits of the slot are zeroed for 32-bit types as the Go code expects. Note that i32 values are kept
+// sign-extended in the registers, and f32 ones are NaN-boxed.
+func (m *machine) goFunctionCallStoreSlot(cur *instruction, base regalloc.VReg, offset int64, typ ssa.Type, v regalloc.VReg) *instruction {
+	store := m.allocateInstr()
+	store.asStore(storeOpFor(typ), v, addressMode{kind: addressModeKindRegImm, base: base, imm: offset})
+	cur = linkInstr(cur, store)
+	if typ.Bits() == 32 {
+		clearUpper := m.allocateInstr()
+		clearUpper.asStore(memOpSw, zeroVReg, addressMode{kind: addressModeKindRegImm, base: base, imm: offset + 4})
+		cur = linkInstr(cur, clearUpper)
+	}
+	return cur
+}
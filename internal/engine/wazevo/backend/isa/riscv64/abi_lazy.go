@@ -0,0 +1,98 @@
+package riscv64
+
+import (
+	"encoding/binary"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// lazyFunctionEntrySize is the size of the entries encoded by EncodeLazyFunctionEntry.
+const lazyFunctionEntrySize = 8 * 4
+
+// CompileLazyCompilationTrampoline implements backend.Machine.
+func (m *machine) CompileLazyCompilationTrampoline() []byte {
+	cur := m.allocateInstr()
+	cur.asNop0()
+	m.rootInstr = cur
+
+	// The entry jumps here without touching the stack and the return address register, so the state is the same as at
+	// the beginning of the function. Save the callee saved and argument registers, including the return address.
+	cur = m.saveRegistersInExecutionContext(cur, saveRequiredRegs)
+
+	// The module context of the function is always the second argument, and identifies the module to compile.
+	store := m.allocateInstr()
+	store.asStore(memOpSd, x11VReg, addressMode{
+		kind: addressModeKindRegImm,
+		base: x10VReg, imm: wazevoapi.ExecutionContextOffsetCallerModuleContextPtr.I64(),
+	})
+	cur = linkInstr(cur, store)
+
+	// Save the current stack pointer.
+	cur = m.saveCurrentStackPointer(cur, x10VReg)
+
+	// Set the exit status on the execution context.
+	cur = m.setExitCode(cur, x10VReg, wazevoapi.ExitCodeLazyCompile)
+
+	// Exit the execution.
+	cur = m.storeReturnAddressAndExit(cur)
+
+	// After the exit, restore the saved registers.
+	cur = m.restoreRegistersInExecutionContext(cur, saveRequiredRegs)
+
+	// Load the address of the compiled function, and jump to it as if it was called in the first place.
+	load := m.allocateInstr()
+	load.asLoad(memOpLd, tmpVReg, addressMode{
+		kind: addressModeKindRegImm,
+		base: x10VReg, imm: wazevoapi.ExecutionContextOffsetLazyFunctionExecutable.I64(),
+	})
+	cur = linkInstr(cur, load)
+
+	jump := m.allocateInstr()
+	jump.kind = tailCallInd
+	jump.rs1 = tmpVReg
+	linkInstr(cur, jump)
+
+	m.encode(m.rootInstr)
+	return m.compiler.Buf()
+}
+
+// LazyFunctionEntrySize implements backend.Machine.
+func (m *machine) LazyFunctionEntrySize() int {
+	return lazyFunctionEntrySize
+}
+
+// EncodeLazyFunctionEntry implements backend.Machine.
+//
+// The entry is encoded as follows, where tmp is safe to overwrite at the beginning of a function:
+//
+//	auipc tmp, %hi(slot)
+//	ld tmp, %lo(slot)(tmp)
+//	jalr zero, 0(tmp)
+//	lui tmp, %hi(index)              ;; <- lazyOffset
+//	addiw tmp, tmp, %lo(index)
+//	sd tmp, #ExecutionContextOffsetLazyFunctionIndex(x10)
+//	auipc tmp, %hi(trampoline)
+//	jalr zero, %lo(trampoline)(tmp)
+func (m *machine) EncodeLazyFunctionEntry(executable []byte, entryOffset, slotOffset, trampolineOffset int, index uint32) (lazyOffset int) {
+	t := regNumberInEncoding(tmp)
+	entry := executable[entryOffset : entryOffset+lazyFunctionEntrySize]
+
+	hi, lo := splitHiLo(int64(slotOffset - entryOffset))
+	ldOpcode, ldFunct3 := memOpLd.encoding()
+	binary.LittleEndian.PutUint32(entry[0:], encodeU(opcodeAuipc, t, hi))
+	binary.LittleEndian.PutUint32(entry[4:], encodeI(ldOpcode, t, ldFunct3, t, lo))
+	binary.LittleEndian.PutUint32(entry[8:], encodeJalr(regNumberInEncoding(zero), t, 0))
+
+	// Only the lower 32 bits of the index slot are read, so the sign-extension by addiw doesn't matter.
+	hi, lo = splitHiLo(int64(index))
+	binary.LittleEndian.PutUint32(entry[12:], encodeU(opcodeLui, t, hi))
+	binary.LittleEndian.PutUint32(entry[16:], encodeALUImm(aluOpAddw, t, t, lo))
+	sdOpcode, sdFunct3 := memOpSd.encoding()
+	binary.LittleEndian.PutUint32(entry[20:], encodeS(sdOpcode, sdFunct3, regNumberInEncoding(x10), t,
+		wazevoapi.ExecutionContextOffsetLazyFunctionIndex.I64()))
+
+	hi, lo = splitHiLo(int64(trampolineOffset - (entryOffset + 24)))
+	binary.LittleEndian.PutUint32(entry[24:], encodeU(opcodeAuipc, t, hi))
+	binary.LittleEndian.PutUint32(entry[28:], encodeJalr(regNumberInEncoding(zero), t, lo))
+	return entryOffset + 12
+}
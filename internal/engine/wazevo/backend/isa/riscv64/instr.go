@@ -0,0 +1,1516 @@
+package riscv64
+
+import (
+	"fmt"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+)
+
+type (
+	// instruction represents either a real instruction in riscv64, or the meta instructions
+	// that are convenient for code generation. For example, inline constants are also treated
+	// as instructions.
+	//
+	// Basically, each instruction knows how to get encoded in binaries. Hence, the final output of compilation
+	// can be considered equivalent to the sequence of such instructions.
+	//
+	// Each field is interpreted depending on the kind.
+	instruction struct {
+		prev, next          *instruction
+		u1, u2, u3          uint64
+		rd                  regalloc.VReg
+		rs1, rs2, rs3       regalloc.VReg
+		kind                instructionKind
+		addedBeforeRegAlloc bool
+	}
+
+	// instructionKind represents the kind of instruction.
+	// This controls how the instruction struct is interpreted.
+	instructionKind byte
+)
+
+const (
+	// nop0 represents a no-op of zero size.
+	nop0 instructionKind = iota + 1
+	// loadConst loads the 64-bit constant into the integer register with the shortest sequence of lui/addi(w)/slli.
+	loadConst
+	// loadFpuConst32 loads the 32-bit constant into the floating point register.
+	loadFpuConst32
+	// loadFpuConst64 loads the 64-bit constant into the floating point register.
+	loadFpuConst64
+	// aluRRR represents an integer operation with two register sources and a register destination.
+	aluRRR
+	// aluRRImm represents an integer operation with a register source and a 12-bit signed immediate (or shift amount),
+	// with a register destination.
+	aluRRImm
+	// load represents a load from the memory into either an integer or a floating point register.
+	load
+	// store represents a store of either an integer or a floating point register to the memory.
+	store
+	// fpuRRR represents a floating point operation with two register sources and a register destination.
+	fpuRRR
+	// fpuRR represents a floating point operation with a register source and a register destination.
+	fpuRR
+	// fpuCmp represents a floating point comparison whose result (0 or 1) is written to an integer register.
+	fpuCmp
+	// fpuToInt represents a conversion from a floating point value to an integer.
+	fpuToInt
+	// intToFpu represents a conversion from an integer to a floating point value.
+	intToFpu
+	// mov represents a 64-bit copy between integer registers.
+	mov
+	// fpuMov represents a 64-bit copy between floating point registers.
+	fpuMov
+	// condBr represents a conditional branch comparing two integer registers.
+	condBr
+	// br represents an unconditional branch.
+	br
+	// brTableSequence represents a jump-table sequence.
+	brTableSequence
+	// call represents a direct call.
+	call
+	// callInd represents an indirect call.
+	callInd
+	// tailCall represents a direct tail call.
+	tailCall
+	// tailCallInd represents an indirect tail call.
+	tailCallInd
+	// ret represents a return.
+	ret
+	// exitSequence consists of multiple instructions, and exits the execution immediately.
+	// See encodeExitSequence.
+	exitSequence
+	// adr computes the address relative to the beginning of itself into the register.
+	adr
+	// selectPseudo selects one of the two registers based on the condition register without branching
+	// in the instruction list. See encodeSelect.
+	selectPseudo
+	// fpuMinMax implements the WebAssembly semantics of fmin/fmax, which propagates NaN. See encodeFpuMinMax.
+	fpuMinMax
+	// fpuRound implements ceil, floor, trunc and nearest of floating point values. See encodeFpuRound.
+	fpuRound
+	// fence represents the memory ordering fence.
+	fence
+	// amo represents an atomic memory operation of the A extension.
+	amo
+	// atomicCas represents an atomic compare-and-swap with LR/SC loop. rd holds the expected value on entry,
+	// and the loaded value on exit.
+	atomicCas
+	// atomicCasMasked is atomicCas for 8-bit or 16-bit values, operating on the aligned 32-bit word.
+	atomicCasMasked
+	// atomicRmwMasked is a read-modify-write for 8-bit or 16-bit values, operating on the aligned 32-bit word.
+	atomicRmwMasked
+	// clearFflags clears the accrued floating point exception flags.
+	clearFflags
+	// readFflags reads the accrued floating point exception flags into the register.
+	readFflags
+	// udf is the undefined instruction. For debugging only.
+	udf
+	// defineUninitializedReg is a no-op instruction that defines a register without a defining instruction.
+	defineUninitializedReg
+	// nopUseReg is a meta instruction that uses one register and does nothing.
+	nopUseReg
+	// loadConstBlockArg represents a load of a constant block argument.
+	loadConstBlockArg
+
+	// emitSourceOffsetInfo is a dummy instruction to emit source offset info.
+	// The existence of this instruction does not affect the execution.
+	emitSourceOffsetInfo
+
+	// ------------------- do not define below this line -------------------
+	numInstructionKinds
+)
+
+// IsCall implements regalloc.Instr IsCall.
+func (i *instruction) IsCall() bool {
+	return i.kind == call
+}
+
+// IsIndirectCall implements regalloc.Instr IsIndirectCall.
+func (i *instruction) IsIndirectCall() bool {
+	return i.kind == callInd
+}
+
+// IsReturn implements regalloc.Instr IsReturn.
+func (i *instruction) IsReturn() bool {
+	return i.kind == ret
+}
+
+// IsCopy implements regalloc.Instr IsCopy.
+func (i *instruction) IsCopy() bool {
+	return i.kind == mov || i.kind == fpuMov
+}
+
+type defKind byte
+
+const (
+	defKindNone defKind = iota + 1
+	defKindRD
+	defKindCall
+)
+
+var defKinds = [numInstructionKinds]defKind{
+	nop0:                   defKindNone,
+	loadConst:              defKindRD,
+	loadFpuConst32:         defKindRD,
+	loadFpuConst64:         defKindRD,
+	aluRRR:                 defKindRD,
+	aluRRImm:               defKindRD,
+	load:                   defKindRD,
+	store:                  defKindNone,
+	fpuRRR:                 defKindRD,
+	fpuRR:                  defKindRD,
+	fpuCmp:                 defKindRD,
+	fpuToInt:               defKindRD,
+	intToFpu:               defKindRD,
+	mov:                    defKindRD,
+	fpuMov:                 defKindRD,
+	condBr:                 defKindNone,
+	br:                     defKindNone,
+	brTableSequence:        defKindNone,
+	call:                   defKindCall,
+	callInd:                defKindCall,
+	tailCall:               defKindNone,
+	tailCallInd:            defKindNone,
+	ret:                    defKindNone,
+	exitSequence:           defKindNone,
+	adr:                    defKindRD,
+	selectPseudo:           defKindRD,
+	fpuMinMax:              defKindRD,
+	fpuRound:               defKindRD,
+	fence:                  defKindNone,
+	amo:                    defKindRD,
+	atomicCas:              defKindNone,
+	atomicCasMasked:        defKindNone,
+	atomicRmwMasked:        defKindRD,
+	clearFflags:            defKindNone,
+	readFflags:             defKindRD,
+	udf:                    defKindNone,
+	defineUninitializedReg: defKindRD,
+	nopUseReg:              defKindNone,
+	loadConstBlockArg:      defKindRD,
+	emitSourceOffsetInfo:   defKindNone,
+}
+
+// Defs returns the list of regalloc.VReg that are defined by the instruction.
+// In order to reduce the number of allocations, the caller can pass the slice to be used.
+func (i *instruction) Defs(regs *[]regalloc.VReg) []regalloc.VReg {
+	*regs = (*regs)[:0]
+	switch defKinds[i.kind] {
+	case defKindNone:
+	case defKindRD:
+		*regs = append(*regs, i.rd)
+	case defKindCall:
+		_, _, retIntRealRegs, retFloatRealRegs, _ := backend.ABIInfoFromUint64(i.u2)
+		for i := byte(0); i < retIntRealRegs; i++ {
+			*regs = append(*regs, regInfo.RealRegToVReg[intParamResultRegs[i]])
+		}
+		for i := byte(0); i < retFloatRealRegs; i++ {
+			*regs = append(*regs, regInfo.RealRegToVReg[floatParamResultRegs[i]])
+		}
+	default:
+		panic(fmt.Sprintf("defKind for %v not defined", i))
+	}
+	return *regs
+}
+
+// AssignDef implements regalloc.Instr AssignDef.
+func (i *instruction) AssignDef(reg regalloc.VReg) {
+	switch defKinds[i.kind] {
+	case defKindNone:
+	case defKindRD:
+		i.rd = reg
+	case defKindCall:
+		panic("BUG: call instructions shouldn't be assigned")
+	default:
+		panic(fmt.Sprintf("defKind for %v not defined", i))
+	}
+}
+
+type useKind byte
+
+const (
+	useKindNone useKind = iota + 1
+	// useKindRS uses the valid registers among rs1, rs2 and rs3 in this order.
+	useKindRS
+	// useKindRSScratch is the same as useKindRS, but additionally uses the scratch register held in u2.
+	// The scratch register is overwritten by the instruction, so it must be defined by defineUninitializedReg
+	// and must not be used afterward.
+	useKindRSScratch
+	// useKindRSScratchRDRewrite is the same as useKindRSScratch, but RD is used both as a source and destination.
+	// A temporary register for RD must be allocated explicitly with the source copied to this
+	// register before the instruction and the value copied from this register to the instruction
+	// return register.
+	useKindRSScratchRDRewrite
+	useKindCall
+	useKindCallInd
+)
+
+var useKinds = [numInstructionKinds]useKind{
+	nop0:                   useKindNone,
+	loadConst:              useKindNone,
+	loadFpuConst32:         useKindNone,
+	loadFpuConst64:         useKindNone,
+	aluRRR:                 useKindRS,
+	aluRRImm:               useKindRS,
+	load:                   useKindRS,
+	store:                  useKindRS,
+	fpuRRR:                 useKindRS,
+	fpuRR:                  useKindRS,
+	fpuCmp:                 useKindRS,
+	fpuToInt:               useKindRS,
+	intToFpu:               useKindRS,
+	mov:                    useKindRS,
+	fpuMov:                 useKindRS,
+	condBr:                 useKindRS,
+	br:                     useKindNone,
+	brTableSequence:        useKindRS,
+	call:                   useKindCall,
+	callInd:                useKindCallInd,
+	tailCall:               useKindCall,
+	tailCallInd:            useKindCallInd,
+	ret:                    useKindNone,
+	exitSequence:           useKindRS,
+	adr:                    useKindNone,
+	selectPseudo:           useKindRS,
+	fpuMinMax:              useKindRS,
+	fpuRound:               useKindRS,
+	fence:                  useKindNone,
+	amo:                    useKindRS,
+	atomicCas:              useKindRSScratchRDRewrite,
+	atomicCasMasked:        useKindRSScratchRDRewrite,
+	atomicRmwMasked:        useKindRSScratch,
+	clearFflags:            useKindNone,
+	readFflags:             useKindNone,
+	udf:                    useKindNone,
+	defineUninitializedReg: useKindNone,
+	nopUseReg:              useKindRS,
+	loadConstBlockArg:      useKindNone,
+	emitSourceOffsetInfo:   useKindNone,
+}
+
+// Uses returns the list of regalloc.VReg that are used by the instruction.
+// In order to reduce the number of allocations, the caller can pass the slice to be used.
+func (i *instruction) Uses(regs *[]regalloc.VReg) []regalloc.VReg {
+	*regs = (*regs)[:0]
+	switch uk := useKinds[i.kind]; uk {
+	case useKindNone:
+	case useKindRS, useKindRSScratch, useKindRSScratchRDRewrite:
+		if rs1 := i.rs1; rs1.Valid() {
+			*regs = append(*regs, rs1)
+		}
+		if rs2 := i.rs2; rs2.Valid() {
+			*regs = append(*regs, rs2)
+		}
+		if rs3 := i.rs3; rs3.Valid() {
+			*regs = append(*regs, rs3)
+		}
+		if uk != useKindRS {
+			*regs = append(*regs, regalloc.VReg(i.u2))
+		}
+		if uk == useKindRSScratchRDRewrite {
+			*regs = append(*regs, i.rd)
+		}
+	case useKindCallInd:
+		*regs = append(*regs, i.rs1)
+		fallthrough
+	case useKindCall:
+		argIntRealRegs, argFloatRealRegs, _, _, _ := backend.ABIInfoFromUint64(i.u2)
+		for i := byte(0); i < argIntRealRegs; i++ {
+			*regs = append(*regs, regInfo.RealRegToVReg[intParamResultRegs[i]])
+		}
+		for i := byte(0); i < argFloatRealRegs; i++ {
+			*regs = append(*regs, regInfo.RealRegToVReg[floatParamResultRegs[i]])
+		}
+	default:
+		panic(fmt.Sprintf("useKind for %v not defined", i))
+	}
+	return *regs
+}
+
+// AssignUse implements regalloc.Instr AssignUse.
+func (i *instruction) AssignUse(index int, reg regalloc.VReg) {
+	switch uk := useKinds[i.kind]; uk {
+	case useKindNone:
+	case useKindRS, useKindRSScratch, useKindRSScratchRDRewrite:
+		// The index is the position among the valid registers, in the same order as Uses.
+		for _, r := range [...]*regalloc.VReg{&i.rs1, &i.rs2, &i.rs3} {
+			if !r.Valid() {
+				continue
+			}
+			if index == 0 {
+				*r = reg
+				return
+			}
+			index--
+		}
+		if uk != useKindRS {
+			if index == 0 {
+				i.u2 = uint64(reg)
+				return
+			}
+			index--
+		}
+		if uk == useKindRSScratchRDRewrite && index == 0 {
+			i.rd = reg
+			return
+		}
+		panic(fmt.Sprintf("BUG: invalid use index for %v", i))
+	case useKindCall:
+		panic("BUG: call instructions shouldn't be assigned")
+	case useKindCallInd:
+		i.rs1 = reg
+	default:
+		panic(fmt.Sprintf("useKind for %v not defined", i))
+	}
+}
+
+func (i *instruction) asNop0() *instruction {
+	i.kind = nop0
+	return i
+}
+
+func (i *instruction) asNop0WithLabel(l label) {
+	i.kind = nop0
+	i.u1 = uint64(l)
+}
+
+func (i *instruction) nop0Label() label {
+	return label(i.u1)
+}
+
+func (i *instruction) asLoadConst(rd regalloc.VReg, v int64) *instruction {
+	i.kind = loadConst
+	i.rd = rd
+	i.u1 = uint64(v)
+	return i
+}
+
+func (i *instruction) asLoadFpuConst32(rd regalloc.VReg, raw uint64) {
+	i.kind = loadFpuConst32
+	i.rd = rd
+	i.u1 = raw
+}
+
+func (i *instruction) asLoadFpuConst64(rd regalloc.VReg, raw uint64) {
+	i.kind = loadFpuConst64
+	i.rd = rd
+	i.u1 = raw
+}
+
+func (i *instruction) asALU(op aluOp, rd, rs1, rs2 regalloc.VReg) *instruction {
+	i.kind = aluRRR
+	i.u1 = uint64(op)
+	i.rd, i.rs1, i.rs2 = rd, rs1, rs2
+	return i
+}
+
+// asALUImm sets the instruction as the immediate form of the given op. For the shift ops, imm is the shift amount.
+func (i *instruction) asALUImm(op aluOp, rd, rs1 regalloc.VReg, imm int64) *instruction {
+	if !op.hasImmForm() {
+		panic(fmt.Sprintf("BUG: %s doesn't have the immediate form", op))
+	}
+	i.kind = aluRRImm
+	i.u1 = uint64(op)
+	i.u2 = uint64(imm)
+	i.rd, i.rs1 = rd, rs1
+	return i
+}
+
+func (i *instruction) asLoad(op memOp, rd regalloc.VReg, amode addressMode) *instruction {
+	i.kind = load
+	i.u1 = uint64(op)
+	i.rd = rd
+	i.setAmode(amode)
+	return i
+}
+
+func (i *instruction) asStore(op memOp, src regalloc.VReg, amode addressMode) *instruction {
+	i.kind = store
+	i.u1 = uint64(op)
+	i.rs2 = src
+	i.setAmode(amode)
+	return i
+}
+
+func (i *instruction) setAmode(amode addressMode) {
+	i.rs1 = amode.base
+	i.u2 = uint64(amode.imm)
+	i.u3 = uint64(amode.kind)
+}
+
+func (i *instruction) getAmode() addressMode {
+	return addressMode{kind: addressModeKind(i.u3), base: i.rs1, imm: int64(i.u2)}
+}
+
+func (i *instruction) memOp() memOp {
+	return memOp(i.u1)
+}
+
+func (i *instruction) asFpuRRR(op fpuOp, rd, rs1, rs2 regalloc.VReg, is64 bool) *instruction {
+	i.kind = fpuRRR
+	i.u1 = uint64(op)
+	i.u2 = b2u64(is64)
+	i.rd, i.rs1, i.rs2 = rd, rs1, rs2
+	return i
+}
+
+func (i *instruction) asFpuRR(op fpuUniOp, rd, rs1 regalloc.VReg, is64 bool) *instruction {
+	i.kind = fpuRR
+	i.u1 = uint64(op)
+	i.u2 = b2u64(is64)
+	i.rd, i.rs1 = rd, rs1
+	return i
+}
+
+func (i *instruction) asFpuCmp(c fpuCmpOp, rd, rs1, rs2 regalloc.VReg, is64 bool) *instruction {
+	i.kind = fpuCmp
+	i.u1 = uint64(c)
+	i.u2 = b2u64(is64)
+	i.rd, i.rs1, i.rs2 = rd, rs1, rs2
+	return i
+}
+
+func (i *instruction) asFpuToInt(rd, rs1 regalloc.VReg, signed, src64bit, dst64bit bool) *instruction {
+	i.kind = fpuToInt
+	i.rd, i.rs1 = rd, rs1
+	i.u1 = b2u64(signed)
+	i.u2 = b2u64(src64bit) | b2u64(dst64bit)<<1
+	return i
+}
+
+func (i *instruction) asIntToFpu(rd, rs1 regalloc.VReg, signed, src64bit, dst64bit bool) *instruction {
+	i.kind = intToFpu
+	i.rd, i.rs1 = rd, rs1
+	i.u1 = b2u64(signed)
+	i.u2 = b2u64(src64bit) | b2u64(dst64bit)<<1
+	return i
+}
+
+func (i *instruction) asMove64(rd, rs regalloc.VReg) *instruction {
+	i.kind = mov
+	i.rd, i.rs1 = rd, rs
+	return i
+}
+
+func (i *instruction) asFpuMov64(rd, rs regalloc.VReg) *instruction {
+	i.kind = fpuMov
+	i.rd, i.rs1 = rd, rs
+	return i
+}
+
+// asCondBr sets the instruction as the conditional branch to the target if `rs1 c rs2` holds.
+func (i *instruction) asCondBr(c brCond, rs1, rs2 regalloc.VReg, target label) *instruction {
+	i.kind = condBr
+	i.u1 = uint64(c)
+	i.u2 = uint64(target)
+	i.rs1, i.rs2 = rs1, rs2
+	return i
+}
+
+func (i *instruction) condBrCond() brCond {
+	return brCond(i.u1 & 0xff)
+}
+
+func (i *instruction) condBrLabel() label {
+	return label(i.u2)
+}
+
+func (i *instruction) setCondBrTarget(target label) {
+	i.u2 = uint64(target)
+}
+
+// condBrOffsetResolve is called when the target label is resolved.
+func (i *instruction) condBrOffsetResolve(offset int64) {
+	i.u3 = uint64(offset)
+	i.u1 |= 1 << 16 // indicate that the offset is resolved.
+}
+
+// condBrOffsetResolved returns true if condBrOffsetResolve is already called.
+func (i *instruction) condBrOffsetResolved() bool {
+	return i.u1&(1<<16) != 0
+}
+
+func (i *instruction) condBrOffset() int64 {
+	return int64(i.u3)
+}
+
+func (i *instruction) asBr(target label) *instruction {
+	if target == labelReturn {
+		panic("BUG: call site should special case for returnLabel")
+	}
+	i.kind = br
+	i.u2 = uint64(target)
+	return i
+}
+
+func (i *instruction) brLabel() label {
+	return label(i.u2)
+}
+
+// brOffsetResolve is called when the target label is resolved.
+func (i *instruction) brOffsetResolve(offset int64) {
+	i.u3 = uint64(offset)
+}
+
+func (i *instruction) brOffset() int64 {
+	return int64(i.u3)
+}
+
+// branchForm returns the branchForm of br or condBr, which determines how many instructions are needed to
+// reach the target.
+func (i *instruction) branchForm() branchForm {
+	return branchForm(i.u1 >> 8 & 0xff)
+}
+
+func (i *instruction) setBranchForm(f branchForm) {
+	i.u1 = i.u1&^(0xff<<8) | uint64(f)<<8
+}
+
+// asBrTableSequence sets the instruction as the jump-table sequence. Note that indexReg is clobbered by the sequence.
+func (i *instruction) asBrTableSequence(indexReg regalloc.VReg, targetIndex, targetCounts int) *instruction {
+	i.kind = brTableSequence
+	i.rs1 = indexReg
+	i.u1 = uint64(targetIndex)
+	i.u2 = uint64(targetCounts)
+	return i
+}
+
+func (i *instruction) asCall(ref ssa.FuncRef, abi *backend.FunctionABI) *instruction {
+	i.kind = call
+	i.u1 = uint64(ref)
+	if abi != nil {
+		i.u2 = abi.ABIInfoAsUint64()
+	}
+	return i
+}
+
+func (i *instruction) asCallIndirect(ptr regalloc.VReg, abi *backend.FunctionABI) *instruction {
+	i.kind = callInd
+	i.rs1 = ptr
+	if abi != nil {
+		i.u2 = abi.ABIInfoAsUint64()
+	}
+	return i
+}
+
+func (i *instruction) asTailCall(ref ssa.FuncRef, abi *backend.FunctionABI) *instruction {
+	i.kind = tailCall
+	i.u1 = uint64(ref)
+	i.u2 = abi.ABIInfoAsUint64()
+	return i
+}
+
+func (i *instruction) asTailCallIndirect(ptr regalloc.VReg, abi *backend.FunctionABI) *instruction {
+	i.kind = tailCallInd
+	i.rs1 = ptr
+	if abi != nil {
+		i.u2 = abi.ABIInfoAsUint64()
+	}
+	return i
+}
+
+func (i *instruction) callFuncRef() ssa.FuncRef {
+	return ssa.FuncRef(i.u1)
+}
+
+func (i *instruction) asRet() *instruction {
+	i.kind = ret
+	return i
+}
+
+func (i *instruction) asExitSequence(ctx regalloc.VReg) *instruction {
+	i.kind = exitSequence
+	i.rs1 = ctx
+	return i
+}
+
+// asAdr sets the instruction as the computation of the address `offset` bytes after the beginning of this instruction.
+func (i *instruction) asAdr(rd regalloc.VReg, offset int64) *instruction {
+	i.kind = adr
+	i.rd = rd
+	i.u1 = uint64(offset)
+	return i
+}
+
+// asSelect sets rd to x if c is non-zero, otherwise y.
+func (i *instruction) asSelect(rd, c, x, y regalloc.VReg) *instruction {
+	i.kind = selectPseudo
+	i.rd, i.rs1, i.rs2, i.rs3 = rd, c, x, y
+	return i
+}
+
+func (i *instruction) asFpuMinMax(max bool, rd, rs1, rs2 regalloc.VReg, is64 bool) *instruction {
+	i.kind = fpuMinMax
+	i.u1 = b2u64(max)
+	i.u2 = b2u64(is64)
+	i.rd, i.rs1, i.rs2 = rd, rs1, rs2
+	return i
+}
+
+func (i *instruction) asFpuRound(rm roundingMode, rd, rs1 regalloc.VReg, is64 bool) *instruction {
+	i.kind = fpuRound
+	i.u1 = uint64(rm)
+	i.u2 = b2u64(is64)
+	i.rd, i.rs1 = rd, rs1
+	return i
+}
+
+// asFence sets the instruction as `fence pred, succ`.
+func (i *instruction) asFence(pred, succ fenceSet) *instruction {
+	i.kind = fence
+	i.u1 = uint64(pred)<<4 | uint64(succ)
+	return i
+}
+
+// asAmo sets the instruction as the sequentially-consistent atomic memory operation on the given size (4 or 8) in bytes.
+func (i *instruction) asAmo(op amoOp, rd, addr, src regalloc.VReg, size uint64) *instruction {
+	i.kind = amo
+	i.u1 = uint64(op)
+	i.u3 = size
+	i.rd, i.rs1, i.rs2 = rd, addr, src
+	return i
+}
+
+// asAtomicCas sets the instruction as the compare-and-swap of the given size (4 or 8) in bytes. exp holds the expected
+// value on entry, and the loaded value on exit.
+func (i *instruction) asAtomicCas(exp, addr, repl, scratch regalloc.VReg, size uint64) *instruction {
+	i.kind = atomicCas
+	i.u2 = uint64(scratch)
+	i.u3 = size
+	i.rd, i.rs1, i.rs2 = exp, addr, repl
+	return i
+}
+
+// asAtomicCasMasked sets the instruction as the compare-and-swap of the bits selected by mask on the aligned 32-bit word.
+// exp and repl must be already shifted and masked. exp holds the loaded bits selected by mask on exit.
+func (i *instruction) asAtomicCasMasked(exp, addr, repl, mask, scratch regalloc.VReg) *instruction {
+	i.kind = atomicCasMasked
+	i.u2 = uint64(scratch)
+	i.rd, i.rs1, i.rs2, i.rs3 = exp, addr, repl, mask
+	return i
+}
+
+// asAtomicRmwMasked sets the instruction as the read-modify-write of the bits selected by mask on the aligned 32-bit word.
+// val must be already shifted. rd holds the loaded bits selected by mask.
+func (i *instruction) asAtomicRmwMasked(op atomicRmwMaskedOp, rd, addr, val, mask, scratch regalloc.VReg) *instruction {
+	i.kind = atomicRmwMasked
+	i.u1 = uint64(op)
+	i.u2 = uint64(scratch)
+	i.rd, i.rs1, i.rs2, i.rs3 = rd, addr, val, mask
+	return i
+}
+
+func (i *instruction) asClearFflags() *instruction {
+	i.kind = clearFflags
+	return i
+}
+
+func (i *instruction) asReadFflags(rd regalloc.VReg) *instruction {
+	i.kind = readFflags
+	i.rd = rd
+	return i
+}
+
+func (i *instruction) asUDF() *instruction {
+	i.kind = udf
+	return i
+}
+
+func (i *instruction) asDefineUninitializedReg(r regalloc.VReg) *instruction {
+	i.kind = defineUninitializedReg
+	i.rd = r
+	return i
+}
+
+func (i *instruction) asNopUseReg(r regalloc.VReg) *instruction {
+	i.kind = nopUseReg
+	i.rs1 = r
+	return i
+}
+
+func (i *instruction) asLoadConstBlockArg(v uint64, typ ssa.Type, dst regalloc.VReg) *instruction {
+	i.kind = loadConstBlockArg
+	i.u1 = v
+	i.u2 = uint64(typ)
+	i.rd = dst
+	return i
+}
+
+func (i *instruction) loadConstBlockArgData() (v uint64, typ ssa.Type, dst regalloc.VReg) {
+	return i.u1, ssa.Type(i.u2), i.rd
+}
+
+func (i *instruction) asEmitSourceOffsetInfo(l ssa.SourceOffset) *instruction {
+	i.kind = emitSourceOffsetInfo
+	i.u1 = uint64(l)
+	return i
+}
+
+func (i *instruction) sourceOffsetInfo() ssa.SourceOffset {
+	return ssa.SourceOffset(i.u1)
+}
+
+// String implements fmt.Stringer.
+func (i *instruction) String() (str string) {
+	switch i.kind {
+	case nop0:
+		if i.u1 != 0 {
+			str = fmt.Sprintf("%s:", label(i.u1))
+		} else {
+			str = "nop0"
+		}
+	case loadConst:
+		str = fmt.Sprintf("li %s, %#x", formatVReg(i.rd), int64(i.u1))
+	case loadFpuConst32:
+		str = fmt.Sprintf("li.s %s, %#x", formatVReg(i.rd), uint32(i.u1))
+	case loadFpuConst64:
+		str = fmt.Sprintf("li.d %s, %#x", formatVReg(i.rd), i.u1)
+	case aluRRR:
+		str = fmt.Sprintf("%s %s, %s, %s", aluOp(i.u1), formatVReg(i.rd), formatVReg(i.rs1), formatVReg(i.rs2))
+	case aluRRImm:
+		str = fmt.Sprintf("%s %s, %s, %d", aluOp(i.u1).immName(), formatVReg(i.rd), formatVReg(i.rs1), int64(i.u2))
+	case load:
+		str = fmt.Sprintf("%s %s, %s", i.memOp(), formatVReg(i.rd), i.getAmode())
+	case store:
+		str = fmt.Sprintf("%s %s, %s", i.memOp(), formatVReg(i.rs2), i.getAmode())
+	case fpuRRR:
+		str = fmt.Sprintf("%s.%s %s, %s, %s", fpuOp(i.u1), fpuSuffix(i.u2 == 1),
+			formatVReg(i.rd), formatVReg(i.rs1), formatVReg(i.rs2))
+	case fpuRR:
+		str = fmt.Sprintf("%s %s, %s", fpuUniOp(i.u1).name(i.u2 == 1), formatVReg(i.rd), formatVReg(i.rs1))
+	case fpuCmp:
+		str = fmt.Sprintf("%s.%s %s, %s, %s", fpuCmpOp(i.u1), fpuSuffix(i.u2 == 1),
+			formatVReg(i.rd), formatVReg(i.rs1), formatVReg(i.rs2))
+	case fpuToInt:
+		signed, src64bit, dst64bit := i.u1 == 1, i.u2&1 != 0, i.u2&2 != 0
+		str = fmt.Sprintf("fcvt.%s.%s %s, %s, rtz", intCvtSuffix(signed, dst64bit), fpuSuffix(src64bit),
+			formatVReg(i.rd), formatVReg(i.rs1))
+	case intToFpu:
+		signed, src64bit, dst64bit := i.u1 == 1, i.u2&1 != 0, i.u2&2 != 0
+		str = fmt.Sprintf("fcvt.%s.%s %s, %s", fpuSuffix(dst64bit), intCvtSuffix(signed, src64bit),
+			formatVReg(i.rd), formatVReg(i.rs1))
+	case mov:
+		str = fmt.Sprintf("mv %s, %s", formatVReg(i.rd), formatVReg(i.rs1))
+	case fpuMov:
+		str = fmt.Sprintf("fmv.d %s, %s", formatVReg(i.rd), formatVReg(i.rs1))
+	case condBr:
+		var target string
+		if i.condBrOffsetResolved() {
+			target = fmt.Sprintf("#%#x (L%d)", i.condBrOffset(), i.condBrLabel())
+		} else {
+			target = i.condBrLabel().String()
+		}
+		str = fmt.Sprintf("%s %s, %s, %s", i.condBrCond(), formatVReg(i.rs1), formatVReg(i.rs2), target)
+	case br:
+		str = fmt.Sprintf("j %s", i.brLabel())
+	case brTableSequence:
+		str = fmt.Sprintf("br_table_sequence %s, table_index=%d", formatVReg(i.rs1), i.u1)
+	case call:
+		str = fmt.Sprintf("call %s", ssa.FuncRef(i.u1))
+	case callInd:
+		str = fmt.Sprintf("jalr ra, 0(%s)", formatVReg(i.rs1))
+	case tailCall:
+		str = fmt.Sprintf("tail %s", ssa.FuncRef(i.u1))
+	case tailCallInd:
+		str = fmt.Sprintf("jr %s", formatVReg(i.rs1))
+	case ret:
+		str = "ret"
+	case exitSequence:
+		str = fmt.Sprintf("exit_sequence %s", formatVReg(i.rs1))
+	case adr:
+		str = fmt.Sprintf("adr %s, #%#x", formatVReg(i.rd), int64(i.u1))
+	case selectPseudo:
+		str = fmt.Sprintf("select %s, %s, %s, %s", formatVReg(i.rd), formatVReg(i.rs1), formatVReg(i.rs2), formatVReg(i.rs3))
+	case fpuMinMax:
+		name := "fmin"
+		if i.u1 == 1 {
+			name = "fmax"
+		}
+		str = fmt.Sprintf("%s_nan.%s %s, %s, %s", name, fpuSuffix(i.u2 == 1), formatVReg(i.rd), formatVReg(i.rs1), formatVReg(i.rs2))
+	case fpuRound:
+		str = fmt.Sprintf("fround.%s %s, %s, %s", fpuSuffix(i.u2 == 1), formatVReg(i.rd), formatVReg(i.rs1), roundingMode(i.u1))
+	case fence:
+		str = fmt.Sprintf("fence %s, %s", fenceSet(i.u1>>4), fenceSet(i.u1&0xf))
+	case amo:
+		str = fmt.Sprintf("%s.%s.aqrl %s, %s, (%s)", amoOp(i.u1), amoSuffix(i.u3),
+			formatVReg(i.rd), formatVReg(i.rs2), formatVReg(i.rs1))
+	case atomicCas:
+		str = fmt.Sprintf("atomic_cas.%s %s, %s, (%s), scratch=%s", amoSuffix(i.u3),
+			formatVReg(i.rd), formatVReg(i.rs2), formatVReg(i.rs1), formatVReg(regalloc.VReg(i.u2)))
+	case atomicCasMasked:
+		str = fmt.Sprintf("atomic_cas_masked %s, %s, (%s), mask=%s, scratch=%s",
+			formatVReg(i.rd), formatVReg(i.rs2), formatVReg(i.rs1), formatVReg(i.rs3), formatVReg(regalloc.VReg(i.u2)))
+	case atomicRmwMasked:
+		str = fmt.Sprintf("atomic_%s_masked %s, %s, (%s), mask=%s, scratch=%s", atomicRmwMaskedOp(i.u1),
+			formatVReg(i.rd), formatVReg(i.rs2), formatVReg(i.rs1), formatVReg(i.rs3), formatVReg(regalloc.VReg(i.u2)))
+	case clearFflags:
+		str = "fsflags zero"
+	case readFflags:
+		str = fmt.Sprintf("frflags %s", formatVReg(i.rd))
+	case udf:
+		str = "udf"
+	case defineUninitializedReg:
+		str = fmt.Sprintf("defineUninitializedReg %s", formatVReg(i.rd))
+	case nopUseReg:
+		str = fmt.Sprintf("nopUseReg %s", formatVReg(i.rs1))
+	case loadConstBlockArg:
+		str = fmt.Sprintf("load_const_block_arg %s, %#x", formatVReg(i.rd), i.u1)
+	case emitSourceOffsetInfo:
+		str = fmt.Sprintf("source_offset_info %d", ssa.SourceOffset(i.u1))
+	default:
+		panic(fmt.Sprintf("BUG: unknown instruction kind %d", i.kind))
+	}
+	return
+}
+
+func b2u64(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func fpuSuffix(is64 bool) string {
+	if is64 {
+		return "d"
+	}
+	return "s"
+}
+
+func intCvtSuffix(signed, is64 bool) string {
+	switch {
+	case signed && is64:
+		return "l"
+	case !signed && is64:
+		return "lu"
+	case signed:
+		return "w"
+	default:
+		return "wu"
+	}
+}
+
+func amoSuffix(size uint64) string {
+	if size == 8 {
+		return "d"
+	}
+	return "w"
+}
+
+// aluOp determines the type of integer operation. Instructions whose kind is one of aluRRR and aluRRImm
+// would use this type.
+type aluOp byte
+
+const (
+	aluOpAdd aluOp = iota + 1
+	aluOpSub
+	aluOpSll
+	aluOpSlt
+	aluOpSltu
+	aluOpXor
+	aluOpSrl
+	aluOpSra
+	aluOpOr
+	aluOpAnd
+	aluOpAddw
+	aluOpSubw
+	aluOpSllw
+	aluOpSrlw
+	aluOpSraw
+	aluOpMul
+	aluOpMulw
+	aluOpDiv
+	aluOpDivu
+	aluOpDivw
+	aluOpDivuw
+	aluOpRem
+	aluOpRemu
+	aluOpRemw
+	aluOpRemuw
+)
+
+// String implements fmt.Stringer.
+func (a aluOp) String() string {
+	switch a {
+	case aluOpAdd:
+		return "add"
+	case aluOpSub:
+		return "sub"
+	case aluOpSll:
+		return "sll"
+	case aluOpSlt:
+		return "slt"
+	case aluOpSltu:
+		return "sltu"
+	case aluOpXor:
+		return "xor"
+	case aluOpSrl:
+		return "srl"
+	case aluOpSra:
+		return "sra"
+	case aluOpOr:
+		return "or"
+	case aluOpAnd:
+		return "and"
+	case aluOpAddw:
+		return "addw"
+	case aluOpSubw:
+		return "subw"
+	case aluOpSllw:
+		return "sllw"
+	case aluOpSrlw:
+		return "srlw"
+	case aluOpSraw:
+		return "sraw"
+	case aluOpMul:
+		return "mul"
+	case aluOpMulw:
+		return "mulw"
+	case aluOpDiv:
+		return "div"
+	case aluOpDivu:
+		return "divu"
+	case aluOpDivw:
+		return "divw"
+	case aluOpDivuw:
+		return "divuw"
+	case aluOpRem:
+		return "rem"
+	case aluOpRemu:
+		return "remu"
+	case aluOpRemw:
+		return "remw"
+	case aluOpRemuw:
+		return "remuw"
+	}
+	panic(int(a))
+}
+
+// hasImmForm returns true if the op has the I-type counterpart.
+func (a aluOp) hasImmForm() bool {
+	switch a {
+	case aluOpAdd, aluOpSll, aluOpSlt, aluOpSltu, aluOpXor, aluOpSrl, aluOpSra, aluOpOr, aluOpAnd,
+		aluOpAddw, aluOpSllw, aluOpSrlw, aluOpSraw:
+		return true
+	}
+	return false
+}
+
+// immName returns the mnemonic of the I-type counterpart.
+func (a aluOp) immName() string {
+	switch a {
+	case aluOpAddw:
+		return "addiw"
+	case aluOpSllw:
+		return "slliw"
+	case aluOpSrlw:
+		return "srliw"
+	case aluOpSraw:
+		return "sraiw"
+	default:
+		return a.String() + "i"
+	}
+}
+
+// memOp is the kind of loads and stores.
+type memOp byte
+
+const (
+	memOpLb memOp = iota + 1
+	memOpLh
+	memOpLw
+	memOpLd
+	memOpLbu
+	memOpLhu
+	memOpLwu
+	memOpFlw
+	memOpFld
+	memOpSb
+	memOpSh
+	memOpSw
+	memOpSd
+	memOpFsw
+	memOpFsd
+)
+
+// String implements fmt.Stringer.
+func (o memOp) String() string {
+	switch o {
+	case memOpLb:
+		return "lb"
+	case memOpLh:
+		return "lh"
+	case memOpLw:
+		return "lw"
+	case memOpLd:
+		return "ld"
+	case memOpLbu:
+		return "lbu"
+	case memOpLhu:
+		return "lhu"
+	case memOpLwu:
+		return "lwu"
+	case memOpFlw:
+		return "flw"
+	case memOpFld:
+		return "fld"
+	case memOpSb:
+		return "sb"
+	case memOpSh:
+		return "sh"
+	case memOpSw:
+		return "sw"
+	case memOpSd:
+		return "sd"
+	case memOpFsw:
+		return "fsw"
+	case memOpFsd:
+		return "fsd"
+	}
+	panic(int(o))
+}
+
+// loadOpFor returns the memOp to load the value of the given type from the stack, etc.
+// Note that i32 values are kept sign-extended in the 64-bit registers, hence lw is used for them.
+func loadOpFor(typ ssa.Type) memOp {
+	switch typ {
+	case ssa.TypeI32:
+		return memOpLw
+	case ssa.TypeI64:
+		return memOpLd
+	case ssa.TypeF32:
+		return memOpFlw
+	case ssa.TypeF64:
+		return memOpFld
+	default:
+		panic("BUG: unsupported type " + typ.String())
+	}
+}
+
+// storeOpFor returns the memOp to store the value of the given type.
+func storeOpFor(typ ssa.Type) memOp {
+	switch typ {
+	case ssa.TypeI32:
+		return memOpSw
+	case ssa.TypeI64:
+		return memOpSd
+	case ssa.TypeF32:
+		return memOpFsw
+	case ssa.TypeF64:
+		return memOpFsd
+	default:
+		panic("BUG: unsupported type " + typ.String())
+	}
+}
+
+// fpuOp is the floating point binary operation.
+type fpuOp byte
+
+const (
+	fpuOpAdd fpuOp = iota + 1
+	fpuOpSub
+	fpuOpMul
+	fpuOpDiv
+	fpuOpMin
+	fpuOpMax
+	fpuOpSgnj
+	fpuOpSgnjn
+	fpuOpSgnjx
+)
+
+// String implements fmt.Stringer.
+func (f fpuOp) String() string {
+	switch f {
+	case fpuOpAdd:
+		return "fadd"
+	case fpuOpSub:
+		return "fsub"
+	case fpuOpMul:
+		return "fmul"
+	case fpuOpDiv:
+		return "fdiv"
+	case fpuOpMin:
+		return "fmin"
+	case fpuOpMax:
+		return "fmax"
+	case fpuOpSgnj:
+		return "fsgnj"
+	case fpuOpSgnjn:
+		return "fsgnjn"
+	case fpuOpSgnjx:
+		return "fsgnjx"
+	}
+	panic(int(f))
+}
+
+// fpuUniOp is the floating point operation with a single operand.
+type fpuUniOp byte
+
+const (
+	fpuUniOpSqrt fpuUniOp = iota + 1
+	// fpuUniOpCvt32To64 converts f32 to f64.
+	fpuUniOpCvt32To64
+	// fpuUniOpCvt64To32 converts f64 to f32 with round-to-nearest-even.
+	fpuUniOpCvt64To32
+	// fpuUniOpMvToInt moves the bits of the floating point register to the integer register.
+	// In the 32-bit variant, the bits are sign-extended.
+	fpuUniOpMvToInt
+	// fpuUniOpMvFromInt moves the bits of the integer register to the floating point register.
+	fpuUniOpMvFromInt
+)
+
+func (f fpuUniOp) name(is64 bool) string {
+	switch f {
+	case fpuUniOpSqrt:
+		return "fsqrt." + fpuSuffix(is64)
+	case fpuUniOpCvt32To64:
+		return "fcvt.d.s"
+	case fpuUniOpCvt64To32:
+		return "fcvt.s.d"
+	case fpuUniOpMvToInt:
+		if is64 {
+			return "fmv.x.d"
+		}
+		return "fmv.x.w"
+	case fpuUniOpMvFromInt:
+		if is64 {
+			return "fmv.d.x"
+		}
+		return "fmv.w.x"
+	}
+	panic(int(f))
+}
+
+// fpuCmpOp is the floating point comparison.
+type fpuCmpOp byte
+
+const (
+	fpuCmpOpEq fpuCmpOp = iota + 1
+	fpuCmpOpLt
+	fpuCmpOpLe
+)
+
+// String implements fmt.Stringer.
+func (f fpuCmpOp) String() string {
+	switch f {
+	case fpuCmpOpEq:
+		return "feq"
+	case fpuCmpOpLt:
+		return "flt"
+	case fpuCmpOpLe:
+		return "fle"
+	}
+	panic(int(f))
+}
+
+// roundingMode is the static rounding mode encoded in the rm field of the floating point instructions.
+type roundingMode byte
+
+const (
+	roundingModeRNE roundingMode = 0b000
+	roundingModeRTZ roundingMode = 0b001
+	roundingModeRDN roundingMode = 0b010
+	roundingModeRUP roundingMode = 0b011
+)
+
+// String implements fmt.Stringer.
+func (r roundingMode) String() string {
+	switch r {
+	case roundingModeRNE:
+		return "rne"
+	case roundingModeRTZ:
+		return "rtz"
+	case roundingModeRDN:
+		return "rdn"
+	case roundingModeRUP:
+		return "rup"
+	}
+	panic(int(r))
+}
+
+// fenceSet is the set of the memory operations ordered by the fence instruction.
+type fenceSet byte
+
+const (
+	fenceW fenceSet = 1 << iota
+	fenceR
+	fenceO
+	fenceI
+	fenceRW = fenceR | fenceW
+)
+
+// String implements fmt.Stringer.
+func (f fenceSet) String() (ret string) {
+	for _, c := range [...]struct {
+		bit  fenceSet
+		name string
+	}{{fenceI, "i"}, {fenceO, "o"}, {fenceR, "r"}, {fenceW, "w"}} {
+		if f&c.bit != 0 {
+			ret += c.name
+		}
+	}
+	return
+}
+
+// amoOp is the atomic memory operation of the A extension.
+type amoOp byte
+
+const (
+	amoOpAdd amoOp = iota + 1
+	amoOpAnd
+	amoOpOr
+	amoOpXor
+	amoOpSwap
+)
+
+// String implements fmt.Stringer.
+func (a amoOp) String() string {
+	switch a {
+	case amoOpAdd:
+		return "amoadd"
+	case amoOpAnd:
+		return "amoand"
+	case amoOpOr:
+		return "amoor"
+	case amoOpXor:
+		return "amoxor"
+	case amoOpSwap:
+		return "amoswap"
+	}
+	panic(int(a))
+}
+
+// atomicRmwMaskedOp is the operation of atomicRmwMasked.
+type atomicRmwMaskedOp byte
+
+const (
+	atomicRmwMaskedOpAdd atomicRmwMaskedOp = iota + 1
+	atomicRmwMaskedOpSub
+	atomicRmwMaskedOpAnd
+	atomicRmwMaskedOpOr
+	atomicRmwMaskedOpXor
+	atomicRmwMaskedOpXchg
+)
+
+// String implements fmt.Stringer.
+func (a atomicRmwMaskedOp) String() string {
+	switch a {
+	case atomicRmwMaskedOpAdd:
+		return "add"
+	case atomicRmwMaskedOpSub:
+		return "sub"
+	case atomicRmwMaskedOpAnd:
+		return "and"
+	case atomicRmwMaskedOpOr:
+		return "or"
+	case atomicRmwMaskedOpXor:
+		return "xor"
+	case atomicRmwMaskedOpXchg:
+		return "xchg"
+	}
+	panic(int(a))
+}
+
+// brCond is the condition of the conditional branches.
+type brCond byte
+
+const (
+	brCondEq brCond = iota + 1
+	brCondNe
+	brCondLt
+	brCondGe
+	brCondLtu
+	brCondGeu
+)
+
+// String implements fmt.Stringer.
+func (c brCond) String() string {
+	switch c {
+	case brCondEq:
+		return "beq"
+	case brCondNe:
+		return "bne"
+	case brCondLt:
+		return "blt"
+	case brCondGe:
+		return "bge"
+	case brCondLtu:
+		return "bltu"
+	case brCondGeu:
+		return "bgeu"
+	}
+	panic(int(c))
+}
+
+// invert returns the condition which holds if and only if c doesn't.
+func (c brCond) invert() brCond {
+	switch c {
+	case brCondEq:
+		return brCondNe
+	case brCondNe:
+		return brCondEq
+	case brCondLt:
+		return brCondGe
+	case brCondGe:
+		return brCondLt
+	case brCondLtu:
+		return brCondGeu
+	case brCondGeu:
+		return brCondLtu
+	}
+	panic(int(c))
+}
+
+// brCondFromSSAIntegerCmpCond returns the brCond for the given ssa.IntegerCmpCond. When swap is true,
+// the operands must be swapped.
+func brCondFromSSAIntegerCmpCond(c ssa.IntegerCmpCond) (cond brCond, swap bool) {
+	switch c {
+	case ssa.IntegerCmpCondEqual:
+		return brCondEq, false
+	case ssa.IntegerCmpCondNotEqual:
+		return brCondNe, false
+	case ssa.IntegerCmpCondSignedLessThan:
+		return brCondLt, false
+	case ssa.IntegerCmpCondSignedGreaterThanOrEqual:
+		return brCondGe, false
+	case ssa.IntegerCmpCondSignedGreaterThan:
+		return brCondLt, true
+	case ssa.IntegerCmpCondSignedLessThanOrEqual:
+		return brCondGe, true
+	case ssa.IntegerCmpCondUnsignedLessThan:
+		return brCondLtu, false
+	case ssa.IntegerCmpCondUnsignedGreaterThanOrEqual:
+		return brCondGeu, false
+	case ssa.IntegerCmpCondUnsignedGreaterThan:
+		return brCondLtu, true
+	case ssa.IntegerCmpCondUnsignedLessThanOrEqual:
+		return brCondGeu, true
+	default:
+		panic(c)
+	}
+}
+
+// branchForm is the encoding form of br and condBr, depending on the distance to the target.
+type branchForm byte
+
+const (
+	// branchFormShort is a single B-type (±4KiB) or J-type (±1MiB) instruction.
+	branchFormShort branchForm = iota
+	// branchFormLong is only for condBr: the inverted B-type instruction skipping the J-type instruction.
+	branchFormLong
+	// branchFormFar uses auipc+jalr to reach ±2GiB. For condBr, it is preceded by the inverted B-type instruction.
+	branchFormFar
+)
+
+// exitSequenceSize is the size of the instructions as in encodeExitSequence.
+const exitSequenceSize = 3 * 4
+
+// brTableSequenceOffsetTableBegin is the offset of the jump table from the beginning of brTableSequence.
+const brTableSequenceOffsetTableBegin = 6 * 4
+
+// size returns the size of the instruction in encoded bytes.
+func (i *instruction) size() int64 {
+	switch i.kind {
+	case nop0, loadConstBlockArg, emitSourceOffsetInfo, defineUninitializedReg, nopUseReg:
+		return 0
+	case exitSequence:
+		return exitSequenceSize
+	case loadConst:
+		var seq liSeq
+		return 4 * int64(seq.generate(int64(i.u1)))
+	case loadFpuConst32, loadFpuConst64:
+		if i.u1 == 0 {
+			return 4 // zero loading can be encoded as a single instruction.
+		}
+		v := int64(i.u1)
+		if i.kind == loadFpuConst32 {
+			v = int64(int32(i.u1))
+		}
+		var seq liSeq
+		return 4 * int64(seq.generate(v)+1)
+	case load, store:
+		if imm := int64(i.u2); !fitsInSigned12(imm) {
+			// li tmp, imm; add tmp, base, tmp; op 0(tmp)
+			var seq liSeq
+			return 4 * int64(seq.generate(imm)+2)
+		}
+		return 4
+	case condBr:
+		switch i.branchForm() {
+		case branchFormShort:
+			return 4
+		case branchFormLong:
+			return 8
+		default:
+			return 12
+		}
+	case br:
+		if i.branchForm() == branchFormShort {
+			return 4
+		}
+		return 8
+	case brTableSequence:
+		return brTableSequenceOffsetTableBegin + int64(i.u2)*4
+	case call, tailCall, adr:
+		return 8
+	case selectPseudo:
+		return 4 * 4
+	case fpuMinMax:
+		return 7 * 4
+	case fpuRound:
+		return 10 * 4
+	case atomicCas:
+		return 5 * 4
+	case atomicCasMasked:
+		return 8 * 4
+	case atomicRmwMasked:
+		return 8 * 4
+	default:
+		return 4
+	}
+}
+
+// addressModeKind represents the kind of riscv64 addressing mode, which is always a base register plus
+// a signed 12-bit offset in the native instructions.
+type addressModeKind byte
+
+const (
+	// addressModeKindRegImm is the base register plus the offset. When the offset doesn't fit in the signed 12-bit,
+	// the temporary register is used to compute the address at the encoding time.
+	addressModeKindRegImm addressModeKind = iota
+	// addressModeKindArgStackSpace is used to resolve the address of the argument stack space
+	// exiting right above the stack pointer. Since we don't know the exact stack space needed for a function
+	// at a compilation phase, this is used as a placeholder and further lowered to a real addressing mode like above.
+	addressModeKindArgStackSpace
+	// addressModeKindResultStackSpace is used to resolve the address of the result stack space
+	// exiting right above the stack pointer. Since we don't know the exact stack space needed for a function
+	// at a compilation phase, this is used as a placeholder and further lowered to a real addressing mode like above.
+	addressModeKindResultStackSpace
+)
+
+// addressMode represents the address of loads and stores.
+type addressMode struct {
+	kind addressModeKind
+	base regalloc.VReg
+	imm  int64
+}
+
+// String implements fmt.Stringer.
+func (a addressMode) String() string {
+	switch a.kind {
+	case addressModeKindRegImm:
+		return fmt.Sprintf("%d(%s)", a.imm, formatVReg(a.base))
+	case addressModeKindArgStackSpace:
+		return fmt.Sprintf("arg_stack_space+%d(%s)", a.imm, formatVReg(a.base))
+	case addressModeKindResultStackSpace:
+		return fmt.Sprintf("result_stack_space+%d(%s)", a.imm, formatVReg(a.base))
+	}
+	panic(int(a.kind))
+}
+
+func fitsInSigned12(v int64) bool {
+	return v >= -2048 && v <= 2047
+}
@@ -0,0 +1,821 @@
+package riscv64
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// Encode implements backend.Machine Encode.
+func (m *machine) Encode(ctx context.Context) error {
+	m.resolveRelativeAddresses(ctx)
+	m.encode(m.rootInstr)
+	if l := len(m.compiler.Buf()); l > maxFunctionExecutableSize {
+		return fmt.Errorf("function size exceeds the limit: %d > %d", l, maxFunctionExecutableSize)
+	}
+	return nil
+}
+
+func (m *machine) encode(root *instruction) {
+	for cur := root; cur != nil; cur = cur.next {
+		cur.encode(m)
+	}
+}
+
+func (i *instruction) encode(m *machine) {
+	c := m.compiler
+	switch kind := i.kind; kind {
+	case nop0, emitSourceOffsetInfo, loadConstBlockArg, defineUninitializedReg, nopUseReg:
+	case exitSequence:
+		encodeExitSequence(c, i.rs1)
+	case ret:
+		c.Emit4Bytes(encodeJalr(regNumberInEncoding(zero), regNumberInEncoding(ra), 0))
+	case br:
+		offset := i.brOffset()
+		if i.branchForm() == branchFormShort {
+			c.Emit4Bytes(encodeJal(regNumberInEncoding(zero), offset))
+		} else {
+			encodeFarJump(c, offset)
+		}
+	case condBr:
+		encodeCondBr(c, i)
+	case brTableSequence:
+		encodeBrTableSequence(c, i.rs1, m.jmpTableTargets[i.u1])
+	case call:
+		// We still don't know the exact address of the function to call, so we emit a placeholder.
+		c.AddRelocationInfo(i.callFuncRef())
+		c.Emit4Bytes(encodeU(opcodeAuipc, regNumberInEncoding(ra), 0))                // 0 = placeholder
+		c.Emit4Bytes(encodeJalr(regNumberInEncoding(ra), regNumberInEncoding(ra), 0)) // 0 = placeholder
+	case callInd:
+		c.Emit4Bytes(encodeJalr(regNumberInEncoding(ra), regNumberInEncoding(i.rs1.RealReg()), 0))
+	case tailCall:
+		// Same as call, but without the link so that the callee returns to the caller of this function.
+		c.AddRelocationInfo(i.callFuncRef())
+		c.Emit4Bytes(encodeU(opcodeAuipc, regNumberInEncoding(tmp), 0))                  // 0 = placeholder
+		c.Emit4Bytes(encodeJalr(regNumberInEncoding(zero), regNumberInEncoding(tmp), 0)) // 0 = placeholder
+	case tailCallInd:
+		c.Emit4Bytes(encodeJalr(regNumberInEncoding(zero), regNumberInEncoding(i.rs1.RealReg()), 0))
+	case loadConst:
+		encodeLoadConst(c, regNumberInEncoding(i.rd.RealReg()), int64(i.u1))
+	case loadFpuConst32, loadFpuConst64:
+		rd := regNumberInEncoding(i.rd.RealReg())
+		is64 := kind == loadFpuConst64
+		if i.u1 == 0 {
+			c.Emit4Bytes(encodeFpuRR(fpuUniOpMvFromInt, rd, regNumberInEncoding(zero), is64))
+			return
+		}
+		v := int64(i.u1)
+		if !is64 {
+			v = int64(int32(i.u1))
+		}
+		encodeLoadConst(c, regNumberInEncoding(tmp), v)
+		c.Emit4Bytes(encodeFpuRR(fpuUniOpMvFromInt, rd, regNumberInEncoding(tmp), is64))
+	case aluRRR:
+		c.Emit4Bytes(encodeALU(aluOp(i.u1),
+			regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()), regNumberInEncoding(i.rs2.RealReg())))
+	case aluRRImm:
+		c.Emit4Bytes(encodeALUImm(aluOp(i.u1),
+			regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()), int64(i.u2)))
+	case load:
+		encodeLoadOrStore(c, i.memOp(), regNumberInEncoding(i.rd.RealReg()), i.getAmode())
+	case store:
+		encodeLoadOrStore(c, i.memOp(), regNumberInEncoding(i.rs2.RealReg()), i.getAmode())
+	case fpuRRR:
+		c.Emit4Bytes(encodeFpuRRR(fpuOp(i.u1),
+			regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()), regNumberInEncoding(i.rs2.RealReg()), i.u2 == 1))
+	case fpuRR:
+		c.Emit4Bytes(encodeFpuRR(fpuUniOp(i.u1), regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()), i.u2 == 1))
+	case fpuCmp:
+		c.Emit4Bytes(encodeFpuCmp(fpuCmpOp(i.u1),
+			regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()), regNumberInEncoding(i.rs2.RealReg()), i.u2 == 1))
+	case fpuToInt:
+		signed, src64bit, dst64bit := i.u1 == 1, i.u2&1 != 0, i.u2&2 != 0
+		c.Emit4Bytes(encodeFpuToInt(regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()),
+			signed, src64bit, dst64bit, roundingModeRTZ))
+	case intToFpu:
+		signed, src64bit, dst64bit := i.u1 == 1, i.u2&1 != 0, i.u2&2 != 0
+		c.Emit4Bytes(encodeIntToFpu(regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()),
+			signed, src64bit, dst64bit))
+	case mov:
+		c.Emit4Bytes(encodeALUImm(aluOpAdd, regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()), 0))
+	case fpuMov:
+		rs := regNumberInEncoding(i.rs1.RealReg())
+		c.Emit4Bytes(encodeFpuRRR(fpuOpSgnj, regNumberInEncoding(i.rd.RealReg()), rs, rs, true))
+	case adr:
+		encodeAdr(c, regNumberInEncoding(i.rd.RealReg()), int64(i.u1))
+	case selectPseudo:
+		encodeSelect(c, i.rd.RealReg(), i.rs1.RealReg(), i.rs2.RealReg(), i.rs3.RealReg())
+	case fpuMinMax:
+		encodeFpuMinMax(c, i.u1 == 1, regNumberInEncoding(i.rd.RealReg()),
+			regNumberInEncoding(i.rs1.RealReg()), regNumberInEncoding(i.rs2.RealReg()), i.u2 == 1)
+	case fpuRound:
+		encodeFpuRound(c, roundingMode(i.u1), regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()), i.u2 == 1)
+	case fence:
+		c.Emit4Bytes(encodeFence(fenceSet(i.u1>>4), fenceSet(i.u1&0xf)))
+	case amo:
+		c.Emit4Bytes(encodeAmo(amoFunct5(amoOp(i.u1)), regNumberInEncoding(i.rd.RealReg()),
+			regNumberInEncoding(i.rs1.RealReg()), regNumberInEncoding(i.rs2.RealReg()), i.u3 == 8))
+	case atomicCas:
+		encodeAtomicCas(c, regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()),
+			regNumberInEncoding(i.rs2.RealReg()), regNumberInEncoding(regalloc.VReg(i.u2).RealReg()), i.u3 == 8)
+	case atomicCasMasked:
+		encodeAtomicCasMasked(c, regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()),
+			regNumberInEncoding(i.rs2.RealReg()), regNumberInEncoding(i.rs3.RealReg()), regNumberInEncoding(regalloc.VReg(i.u2).RealReg()))
+	case atomicRmwMasked:
+		encodeAtomicRmwMasked(c, atomicRmwMaskedOp(i.u1), regNumberInEncoding(i.rd.RealReg()), regNumberInEncoding(i.rs1.RealReg()),
+			regNumberInEncoding(i.rs2.RealReg()), regNumberInEncoding(i.rs3.RealReg()), regNumberInEncoding(regalloc.VReg(i.u2).RealReg()))
+	case clearFflags:
+		// fsflags zero == csrrw zero, fflags, zero
+		c.Emit4Bytes(encodeCSR(csrFunct3Rw, regNumberInEncoding(zero), csrFflags, regNumberInEncoding(zero)))
+	case readFflags:
+		// frflags rd == csrrs rd, fflags, zero
+		c.Emit4Bytes(encodeCSR(csrFunct3Rs, regNumberInEncoding(i.rd.RealReg()), csrFflags, regNumberInEncoding(zero)))
+	case udf:
+		c.Emit4Bytes(0) // All zero bits is defined as illegal.
+	default:
+		panic(fmt.Sprintf("BUG: encoding of %s is not implemented", i))
+	}
+}
+
+const (
+	opcodeLoad     uint32 = 0b0000011
+	opcodeLoadFp   uint32 = 0b0000111
+	opcodeMiscMem  uint32 = 0b0001111
+	opcodeOpImm    uint32 = 0b0010011
+	opcodeAuipc    uint32 = 0b0010111
+	opcodeOpImm32  uint32 = 0b0011011
+	opcodeStore    uint32 = 0b0100011
+	opcodeStoreFp  uint32 = 0b0100111
+	opcodeAmo      uint32 = 0b0101111
+	opcodeOp       uint32 = 0b0110011
+	opcodeLui      uint32 = 0b0110111
+	opcodeOp32     uint32 = 0b0111011
+	opcodeOpFp     uint32 = 0b1010011
+	opcodeBranch   uint32 = 0b1100011
+	opcodeJalr     uint32 = 0b1100111
+	opcodeJal      uint32 = 0b1101111
+	opcodeSystem   uint32 = 0b1110011
+	csrFunct3Rw    uint32 = 0b001
+	csrFunct3Rs    uint32 = 0b010
+	csrFflags      uint32 = 0x001
+	fpuFmtSingle   uint32 = 0b00
+	fpuFmtDouble   uint32 = 0b01
+	amoFunct5Lr    uint32 = 0b00010
+	amoFunct5Sc    uint32 = 0b00011
+	amoFunct3Word  uint32 = 0b010
+	amoFunct3Dword uint32 = 0b011
+)
+
+// encodeR encodes the R-type instruction.
+func encodeR(opcode, rd, funct3, rs1, rs2, funct7 uint32) uint32 {
+	return funct7<<25 | rs2<<20 | rs1<<15 | funct3<<12 | rd<<7 | opcode
+}
+
+// encodeI encodes the I-type instruction. imm must fit in the signed 12-bit.
+func encodeI(opcode, rd, funct3, rs1 uint32, imm int64) uint32 {
+	return uint32(imm&0xfff)<<20 | rs1<<15 | funct3<<12 | rd<<7 | opcode
+}
+
+// encodeS encodes the S-type instruction. imm must fit in the signed 12-bit.
+func encodeS(opcode, funct3, rs1, rs2 uint32, imm int64) uint32 {
+	return uint32(imm>>5&0x7f)<<25 | rs2<<20 | rs1<<15 | funct3<<12 | uint32(imm&0x1f)<<7 | opcode
+}
+
+// encodeB encodes the conditional branch whose offset must be even and fit in the signed 13-bit.
+func encodeB(c brCond, rs1, rs2 uint32, offset int64) uint32 {
+	var funct3 uint32
+	switch c {
+	case brCondEq:
+		funct3 = 0b000
+	case brCondNe:
+		funct3 = 0b001
+	case brCondLt:
+		funct3 = 0b100
+	case brCondGe:
+		funct3 = 0b101
+	case brCondLtu:
+		funct3 = 0b110
+	case brCondGeu:
+		funct3 = 0b111
+	default:
+		panic(c)
+	}
+	imm := uint32(offset)
+	return (imm>>12&1)<<31 | (imm>>5&0x3f)<<25 | rs2<<20 | rs1<<15 | funct3<<12 | (imm>>1&0xf)<<8 | (imm>>11&1)<<7 | opcodeBranch
+}
+
+// encodeU encodes the U-type instruction with the 20-bit immediate.
+func encodeU(opcode, rd uint32, imm20 int64) uint32 {
+	return uint32(imm20&0xfffff)<<12 | rd<<7 | opcode
+}
+
+// encodeJal encodes jal whose offset must be even and fit in the signed 21-bit.
+func encodeJal(rd uint32, offset int64) uint32 {
+	imm := uint32(offset)
+	return (imm>>20&1)<<31 | (imm>>1&0x3ff)<<21 | (imm>>11&1)<<20 | (imm>>12&0xff)<<12 | rd<<7 | opcodeJal
+}
+
+func encodeJalr(rd, rs1 uint32, offset int64) uint32 {
+	return encodeI(opcodeJalr, rd, 0, rs1, offset)
+}
+
+// splitHiLo splits the pc-relative offset into the 20-bit immediate for auipc and the signed 12-bit one
+// for the subsequent instruction.
+func splitHiLo(offset int64) (hi, lo int64) {
+	hi = (offset + 0x800) >> 12
+	lo = offset - hi<<12
+	return
+}
+
+func fitsInAuipcRange(offset int64) bool {
+	hi, _ := splitHiLo(offset)
+	return hi >= -(1<<19) && hi < 1<<19
+}
+
+func (a aluOp) encoding() (opcode, funct3, funct7 uint32) {
+	switch a {
+	case aluOpAdd:
+		return opcodeOp, 0b000, 0
+	case aluOpSub:
+		return opcodeOp, 0b000, 0b0100000
+	case aluOpSll:
+		return opcodeOp, 0b001, 0
+	case aluOpSlt:
+		return opcodeOp, 0b010, 0
+	case aluOpSltu:
+		return opcodeOp, 0b011, 0
+	case aluOpXor:
+		return opcodeOp, 0b100, 0
+	case aluOpSrl:
+		return opcodeOp, 0b101, 0
+	case aluOpSra:
+		return opcodeOp, 0b101, 0b0100000
+	case aluOpOr:
+		return opcodeOp, 0b110, 0
+	case aluOpAnd:
+		return opcodeOp, 0b111, 0
+	case aluOpAddw:
+		return opcodeOp32, 0b000, 0
+	case aluOpSubw:
+		return opcodeOp32, 0b000, 0b0100000
+	case aluOpSllw:
+		return opcodeOp32, 0b001, 0
+	case aluOpSrlw:
+		return opcodeOp32, 0b101, 0
+	case aluOpSraw:
+		return opcodeOp32, 0b101, 0b0100000
+	case aluOpMul:
+		return opcodeOp, 0b000, 1
+	case aluOpMulw:
+		return opcodeOp32, 0b000, 1
+	case aluOpDiv:
+		return opcodeOp, 0b100, 1
+	case aluOpDivu:
+		return opcodeOp, 0b101, 1
+	case aluOpDivw:
+		return opcodeOp32, 0b100, 1
+	case aluOpDivuw:
+		return opcodeOp32, 0b101, 1
+	case aluOpRem:
+		return opcodeOp, 0b110, 1
+	case aluOpRemu:
+		return opcodeOp, 0b111, 1
+	case aluOpRemw:
+		return opcodeOp32, 0b110, 1
+	case aluOpRemuw:
+		return opcodeOp32, 0b111, 1
+	}
+	panic(int(a))
+}
+
+func encodeALU(op aluOp, rd, rs1, rs2 uint32) uint32 {
+	opcode, funct3, funct7 := op.encoding()
+	return encodeR(opcode, rd, funct3, rs1, rs2, funct7)
+}
+
+func encodeALUImm(op aluOp, rd, rs1 uint32, imm int64) uint32 {
+	opcode, funct3, funct7 := op.encoding()
+	switch opcode {
+	case opcodeOp:
+		opcode = opcodeOpImm
+	case opcodeOp32:
+		opcode = opcodeOpImm32
+	}
+	switch op {
+	case aluOpSll, aluOpSrl, aluOpSra:
+		imm = imm&0x3f | int64(funct7)<<5
+	case aluOpSllw, aluOpSrlw, aluOpSraw:
+		imm = imm&0x1f | int64(funct7)<<5
+	default:
+		if !fitsInSigned12(imm) {
+			panic(fmt.Sprintf("BUG: immediate %d doesn't fit in the signed 12-bit", imm))
+		}
+	}
+	return encodeI(opcode, rd, funct3, rs1, imm)
+}
+
+func (o memOp) encoding() (opcode, funct3 uint32) {
+	switch o {
+	case memOpLb:
+		return opcodeLoad, 0b000
+	case memOpLh:
+		return opcodeLoad, 0b001
+	case memOpLw:
+		return opcodeLoad, 0b010
+	case memOpLd:
+		return opcodeLoad, 0b011
+	case memOpLbu:
+		return opcodeLoad, 0b100
+	case memOpLhu:
+		return opcodeLoad, 0b101
+	case memOpLwu:
+		return opcodeLoad, 0b110
+	case memOpFlw:
+		return opcodeLoadFp, 0b010
+	case memOpFld:
+		return opcodeLoadFp, 0b011
+	case memOpSb:
+		return opcodeStore, 0b000
+	case memOpSh:
+		return opcodeStore, 0b001
+	case memOpSw:
+		return opcodeStore, 0b010
+	case memOpSd:
+		return opcodeStore, 0b011
+	case memOpFsw:
+		return opcodeStoreFp, 0b010
+	case memOpFsd:
+		return opcodeStoreFp, 0b011
+	}
+	panic(int(o))
+}
+
+func (o memOp) isStore() bool {
+	return o >= memOpSb
+}
+
+// encodeLoadOrStore encodes the load or store of the data register. If the offset doesn't fit in the signed 12-bit,
+// the address is computed into the temporary register first.
+func encodeLoadOrStore(c backend.Compiler, op memOp, data uint32, amode addressMode) {
+	if amode.kind != addressModeKindRegImm {
+		panic("BUG: unresolved address mode: " + amode.String())
+	}
+	base, imm := regNumberInEncoding(amode.base.RealReg()), amode.imm
+	if !fitsInSigned12(imm) {
+		if op.isStore() && data == regNumberInEncoding(tmp) {
+			panic("BUG: the temporary register cannot be stored with a large offset")
+		}
+		t := regNumberInEncoding(tmp)
+		encodeLoadConst(c, t, imm)
+		c.Emit4Bytes(encodeALU(aluOpAdd, t, base, t))
+		base, imm = t, 0
+	}
+
+	opcode, funct3 := op.encoding()
+	if op.isStore() {
+		c.Emit4Bytes(encodeS(opcode, funct3, base, data, imm))
+	} else {
+		c.Emit4Bytes(encodeI(opcode, data, funct3, base, imm))
+	}
+}
+
+func fpuFmt(is64 bool) uint32 {
+	if is64 {
+		return fpuFmtDouble
+	}
+	return fpuFmtSingle
+}
+
+func encodeFpuRRR(op fpuOp, rd, rs1, rs2 uint32, is64 bool) uint32 {
+	var funct7, funct3 uint32
+	switch op {
+	case fpuOpAdd:
+		funct7, funct3 = 0b0000000, uint32(roundingModeRNE)
+	case fpuOpSub:
+		funct7, funct3 = 0b0000100, uint32(roundingModeRNE)
+	case fpuOpMul:
+		funct7, funct3 = 0b0001000, uint32(roundingModeRNE)
+	case fpuOpDiv:
+		funct7, funct3 = 0b0001100, uint32(roundingModeRNE)
+	case fpuOpSgnj:
+		funct7, funct3 = 0b0010000, 0b000
+	case fpuOpSgnjn:
+		funct7, funct3 = 0b0010000, 0b001
+	case fpuOpSgnjx:
+		funct7, funct3 = 0b0010000, 0b010
+	case fpuOpMin:
+		funct7, funct3 = 0b0010100, 0b000
+	case fpuOpMax:
+		funct7, funct3 = 0b0010100, 0b001
+	default:
+		panic(int(op))
+	}
+	return encodeR(opcodeOpFp, rd, funct3, rs1, rs2, funct7|fpuFmt(is64))
+}
+
+func encodeFpuRR(op fpuUniOp, rd, rs1 uint32, is64 bool) uint32 {
+	switch op {
+	case fpuUniOpSqrt:
+		return encodeR(opcodeOpFp, rd, uint32(roundingModeRNE), rs1, 0, 0b0101100|fpuFmt(is64))
+	case fpuUniOpCvt32To64:
+		// fcvt.d.s is always exact.
+		return encodeR(opcodeOpFp, rd, uint32(roundingModeRNE), rs1, 0, 0b0100001)
+	case fpuUniOpCvt64To32:
+		return encodeR(opcodeOpFp, rd, uint32(roundingModeRNE), rs1, 1, 0b0100000)
+	case fpuUniOpMvToInt:
+		return encodeR(opcodeOpFp, rd, 0, rs1, 0, 0b1110000|fpuFmt(is64))
+	case fpuUniOpMvFromInt:
+		return encodeR(opcodeOpFp, rd, 0, rs1, 0, 0b1111000|fpuFmt(is64))
+	default:
+		panic(int(op))
+	}
+}
+
+func encodeFpuCmp(op fpuCmpOp, rd, rs1, rs2 uint32, is64 bool) uint32 {
+	var funct3 uint32
+	switch op {
+	case fpuCmpOpEq:
+		funct3 = 0b010
+	case fpuCmpOpLt:
+		funct3 = 0b001
+	case fpuCmpOpLe:
+		funct3 = 0b000
+	default:
+		panic(int(op))
+	}
+	return encodeR(opcodeOpFp, rd, funct3, rs1, rs2, 0b1010000|fpuFmt(is64))
+}
+
+// cvtIntType returns the rs2 field of fcvt which specifies the integer type.
+func cvtIntType(signed, is64 bool) uint32 {
+	var t uint32
+	if !signed {
+		t |= 1
+	}
+	if is64 {
+		t |= 2
+	}
+	return t
+}
+
+func encodeFpuToInt(rd, rs1 uint32, signed, src64bit, dst64bit bool, rm roundingMode) uint32 {
+	return encodeR(opcodeOpFp, rd, uint32(rm), rs1, cvtIntType(signed, dst64bit), 0b1100000|fpuFmt(src64bit))
+}
+
+func encodeIntToFpu(rd, rs1 uint32, signed, src64bit, dst64bit bool) uint32 {
+	return encodeR(opcodeOpFp, rd, uint32(roundingModeRNE), rs1, cvtIntType(signed, src64bit), 0b1101000|fpuFmt(dst64bit))
+}
+
+func encodeFence(pred, succ fenceSet) uint32 {
+	return uint32(pred)<<24 | uint32(succ)<<20 | opcodeMiscMem
+}
+
+func amoFunct5(op amoOp) uint32 {
+	switch op {
+	case amoOpAdd:
+		return 0b00000
+	case amoOpSwap:
+		return 0b00001
+	case amoOpXor:
+		return 0b00100
+	case amoOpOr:
+		return 0b01000
+	case amoOpAnd:
+		return 0b01100
+	}
+	panic(int(op))
+}
+
+// encodeAmo encodes the atomic memory operation with both aq and rl bits set, i.e. sequentially consistent.
+func encodeAmo(funct5, rd, addr, src uint32, is64 bool) uint32 {
+	funct3 := amoFunct3Word
+	if is64 {
+		funct3 = amoFunct3Dword
+	}
+	return encodeR(opcodeAmo, rd, funct3, addr, src, funct5<<2|0b11)
+}
+
+func encodeCSR(funct3, rd, csr, rs1 uint32) uint32 {
+	return encodeI(opcodeSystem, rd, funct3, rs1, int64(csr))
+}
+
+// encodeCondBr encodes condBr in the form decided by resolveRelativeAddresses.
+func encodeCondBr(c backend.Compiler, i *instruction) {
+	rs1, rs2 := regNumberInEncoding(i.rs1.RealReg()), regNumberInEncoding(i.rs2.RealReg())
+	offset := i.condBrOffset()
+	cond := i.condBrCond()
+	switch i.branchForm() {
+	case branchFormShort:
+		c.Emit4Bytes(encodeB(cond, rs1, rs2, offset))
+	case branchFormLong:
+		// Skip the jal if the condition doesn't hold.
+		c.Emit4Bytes(encodeB(cond.invert(), rs1, rs2, 8))
+		c.Emit4Bytes(encodeJal(regNumberInEncoding(zero), offset-4))
+	case branchFormFar:
+		c.Emit4Bytes(encodeB(cond.invert(), rs1, rs2, 12))
+		encodeFarJump(c, offset-4)
+	}
+}
+
+// encodeFarJump encodes the jump to the pc-relative offset with auipc and jalr via the temporary register.
+func encodeFarJump(c backend.Compiler, offset int64) {
+	hi, lo := splitHiLo(offset)
+	t := regNumberInEncoding(tmp)
+	c.Emit4Bytes(encodeU(opcodeAuipc, t, hi))
+	c.Emit4Bytes(encodeJalr(regNumberInEncoding(zero), t, lo))
+}
+
+// encodeBrTableSequence encodes the jump table sequence. index is clobbered.
+//
+//	slli index, index, 2
+//	auipc tmp, 0
+//	add index, index, tmp
+//	lw index, 20(index) ;; 20 = the offset of the table from auipc.
+//	add tmp, tmp, index
+//	jalr zero, 20(tmp)  ;; the entries are relative to the beginning of the table.
+//	[offset_to_target0]
+//	[offset_to_target1]
+//	...
+func encodeBrTableSequence(c backend.Compiler, index regalloc.VReg, targets []uint32) {
+	idx, t := regNumberInEncoding(index.RealReg()), regNumberInEncoding(tmp)
+	const tableOffsetFromAuipc = brTableSequenceOffsetTableBegin - 4
+	c.Emit4Bytes(encodeALUImm(aluOpSll, idx, idx, 2))
+	c.Emit4Bytes(encodeU(opcodeAuipc, t, 0))
+	c.Emit4Bytes(encodeALU(aluOpAdd, idx, idx, t))
+	opcode, funct3 := memOpLw.encoding()
+	c.Emit4Bytes(encodeI(opcode, idx, funct3, idx, tableOffsetFromAuipc))
+	c.Emit4Bytes(encodeALU(aluOpAdd, t, t, idx))
+	c.Emit4Bytes(encodeJalr(regNumberInEncoding(zero), t, tableOffsetFromAuipc))
+	// Then emit the offsets.
+	for _, offset := range targets {
+		c.Emit4Bytes(offset)
+	}
+}
+
+// encodeExitSequence restores the stack pointer and the return address of the Go code, and returns to it.
+//
+//	ld ra, #GoReturnAddress(ctx)
+//	ld sp, #OriginalStackPointer(ctx)
+//	jalr zero, 0(ra)
+func encodeExitSequence(c backend.Compiler, ctxReg regalloc.VReg) {
+	ctx := regNumberInEncoding(ctxReg.RealReg())
+	opcode, funct3 := memOpLd.encoding()
+	c.Emit4Bytes(encodeI(opcode, regNumberInEncoding(ra), funct3, ctx, wazevoapi.ExecutionContextOffsetGoReturnAddress.I64()))
+	c.Emit4Bytes(encodeI(opcode, regNumberInEncoding(sp), funct3, ctx, wazevoapi.ExecutionContextOffsetOriginalStackPointer.I64()))
+	c.Emit4Bytes(encodeJalr(regNumberInEncoding(zero), regNumberInEncoding(ra), 0))
+}
+
+// encodeAdr computes the address `offset` bytes after the auipc into rd.
+func encodeAdr(c backend.Compiler, rd uint32, offset int64) {
+	hi, lo := splitHiLo(offset)
+	c.Emit4Bytes(encodeU(opcodeAuipc, rd, hi))
+	c.Emit4Bytes(encodeALUImm(aluOpAdd, rd, rd, lo))
+}
+
+// encodeSelect encodes the select pseudo instruction:
+//
+//	beq cond, zero, #12
+//	mv rd, x
+//	jal zero, #8
+//	mv rd, y
+//
+// where mv is fmv.d if rd is a floating point register.
+func encodeSelect(c backend.Compiler, rd, cond, x, y regalloc.RealReg) {
+	mv := func(dst, src regalloc.RealReg) uint32 {
+		d, s := regNumberInEncoding(dst), regNumberInEncoding(src)
+		if dst >= f0 {
+			return encodeFpuRRR(fpuOpSgnj, d, s, s, true)
+		}
+		return encodeALUImm(aluOpAdd, d, s, 0)
+	}
+	c.Emit4Bytes(encodeB(brCondEq, regNumberInEncoding(cond), regNumberInEncoding(zero), 12))
+	c.Emit4Bytes(mv(rd, x))
+	c.Emit4Bytes(encodeJal(regNumberInEncoding(zero), 8))
+	c.Emit4Bytes(mv(rd, y))
+}
+
+// encodeFpuMinMax encodes fmin/fmax which propagate NaN as required by WebAssembly, unlike the native ones:
+//
+//	feq tmp, a, a
+//	beq tmp, zero, #20
+//	feq tmp, b, b
+//	beq tmp, zero, #12
+//	fmin/fmax rd, a, b
+//	jal zero, #8
+//	fadd rd, a, b ;; either of them is NaN, so this results in the canonical NaN.
+func encodeFpuMinMax(c backend.Compiler, max bool, rd, a, b uint32, is64 bool) {
+	t := regNumberInEncoding(tmp)
+	op := fpuOpMin
+	if max {
+		op = fpuOpMax
+	}
+	c.Emit4Bytes(encodeFpuCmp(fpuCmpOpEq, t, a, a, is64))
+	c.Emit4Bytes(encodeB(brCondEq, t, regNumberInEncoding(zero), 20))
+	c.Emit4Bytes(encodeFpuCmp(fpuCmpOpEq, t, b, b, is64))
+	c.Emit4Bytes(encodeB(brCondEq, t, regNumberInEncoding(zero), 12))
+	c.Emit4Bytes(encodeFpuRRR(op, rd, a, b, is64))
+	c.Emit4Bytes(encodeJal(regNumberInEncoding(zero), 8))
+	c.Emit4Bytes(encodeFpuRRR(fpuOpAdd, rd, a, b, is64))
+}
+
+// encodeFpuRound encodes the rounding to the integral value in the given rounding mode. Values whose magnitude
+// is large enough to be integral, infinities and NaNs are passed through fmin to canonicalize NaNs:
+//
+//	fmv.x.d tmp, x
+//	slli tmp, tmp, 1
+//	srli tmp, tmp, 53     ;; tmp = biased exponent.
+//	addi tmp, tmp, -1075  ;; tmp >= 0 iff |x| >= 2^52.
+//	blt tmp, zero, #12
+//	fmin.d rd, x, x
+//	jal zero, #16
+//	fcvt.l.d tmp, x, rm
+//	fcvt.d.l ftmp, tmp
+//	fsgnj.d rd, ftmp, x   ;; keep the sign of x, e.g. ceil(-0.5) = -0.
+//
+// The 32-bit variant is analogous with the 8-bit exponent and the 23-bit fraction.
+func encodeFpuRound(c backend.Compiler, rm roundingMode, rd, x uint32, is64 bool) {
+	t, ft := regNumberInEncoding(tmp), regNumberInEncoding(ftmp)
+	c.Emit4Bytes(encodeFpuRR(fpuUniOpMvToInt, t, x, is64))
+	if is64 {
+		c.Emit4Bytes(encodeALUImm(aluOpSll, t, t, 1))
+		c.Emit4Bytes(encodeALUImm(aluOpSrl, t, t, 53))
+		c.Emit4Bytes(encodeALUImm(aluOpAdd, t, t, -1075))
+	} else {
+		c.Emit4Bytes(encodeALUImm(aluOpSll, t, t, 33))
+		c.Emit4Bytes(encodeALUImm(aluOpSrl, t, t, 56))
+		c.Emit4Bytes(encodeALUImm(aluOpAdd, t, t, -150))
+	}
+	c.Emit4Bytes(encodeB(brCondLt, t, regNumberInEncoding(zero), 12))
+	c.Emit4Bytes(encodeFpuRRR(fpuOpMin, rd, x, x, is64))
+	c.Emit4Bytes(encodeJal(regNumberInEncoding(zero), 16))
+	// The integers are 64-bit for f64 and 32-bit for f32, which are enough to hold the values less than 2^52 and 2^23 respectively.
+	c.Emit4Bytes(encodeFpuToInt(t, x, true, is64, is64, rm))
+	c.Emit4Bytes(encodeIntToFpu(ft, t, true, is64, is64))
+	c.Emit4Bytes(encodeFpuRRR(fpuOpSgnj, rd, ft, x, is64))
+}
+
+// encodeAtomicCas encodes the compare-and-swap loop. exp holds the loaded value at the end.
+//
+//	lr.{w,d}.aqrl tmp, (addr)
+//	bne tmp, exp, #12
+//	sc.{w,d}.aqrl scratch, repl, (addr)
+//	bne scratch, zero, #-12
+//	mv exp, tmp
+func encodeAtomicCas(c backend.Compiler, exp, addr, repl, scratch uint32, is64 bool) {
+	t := regNumberInEncoding(tmp)
+	c.Emit4Bytes(encodeAmo(amoFunct5Lr, t, addr, 0, is64))
+	c.Emit4Bytes(encodeB(brCondNe, t, exp, 12))
+	c.Emit4Bytes(encodeAmo(amoFunct5Sc, scratch, addr, repl, is64))
+	c.Emit4Bytes(encodeB(brCondNe, scratch, regNumberInEncoding(zero), -12))
+	c.Emit4Bytes(encodeALUImm(aluOpAdd, exp, t, 0))
+}
+
+// encodeAtomicCasMasked encodes the compare-and-swap loop on the bits selected by mask in the aligned 32-bit word.
+//
+//	lr.w.aqrl tmp, (addr)
+//	and scratch, tmp, mask
+//	bne scratch, exp, #20
+//	xor scratch, tmp, scratch ;; clear the masked bits.
+//	or scratch, scratch, repl
+//	sc.w.aqrl scratch, scratch, (addr)
+//	bne scratch, zero, #-24
+//	and exp, tmp, mask
+func encodeAtomicCasMasked(c backend.Compiler, exp, addr, repl, mask, scratch uint32) {
+	t := regNumberInEncoding(tmp)
+	c.Emit4Bytes(encodeAmo(amoFunct5Lr, t, addr, 0, false))
+	c.Emit4Bytes(encodeALU(aluOpAnd, scratch, t, mask))
+	c.Emit4Bytes(encodeB(brCondNe, scratch, exp, 20))
+	c.Emit4Bytes(encodeALU(aluOpXor, scratch, t, scratch))
+	c.Emit4Bytes(encodeALU(aluOpOr, scratch, scratch, repl))
+	c.Emit4Bytes(encodeAmo(amoFunct5Sc, scratch, addr, scratch, false))
+	c.Emit4Bytes(encodeB(brCondNe, scratch, regNumberInEncoding(zero), -24))
+	c.Emit4Bytes(encodeALU(aluOpAnd, exp, t, mask))
+}
+
+// encodeAtomicRmwMasked encodes the read-modify-write loop on the bits selected by mask in the aligned 32-bit word.
+//
+//	lr.w.aqrl tmp, (addr)
+//	op scratch, tmp, val
+//	xor scratch, scratch, tmp
+//	and scratch, scratch, mask
+//	xor scratch, scratch, tmp ;; scratch = tmp with the masked bits replaced by the result.
+//	sc.w.aqrl scratch, scratch, (addr)
+//	bne scratch, zero, #-24
+//	and rd, tmp, mask
+func encodeAtomicRmwMasked(c backend.Compiler, op atomicRmwMaskedOp, rd, addr, val, mask, scratch uint32) {
+	t := regNumberInEncoding(tmp)
+	c.Emit4Bytes(encodeAmo(amoFunct5Lr, t, addr, 0, false))
+	switch op {
+	case atomicRmwMaskedOpAdd:
+		c.Emit4Bytes(encodeALU(aluOpAdd, scratch, t, val))
+	case atomicRmwMaskedOpSub:
+		c.Emit4Bytes(encodeALU(aluOpSub, scratch, t, val))
+	case atomicRmwMaskedOpAnd:
+		c.Emit4Bytes(encodeALU(aluOpAnd, scratch, t, val))
+	case atomicRmwMaskedOpOr:
+		c.Emit4Bytes(encodeALU(aluOpOr, scratch, t, val))
+	case atomicRmwMaskedOpXor:
+		c.Emit4Bytes(encodeALU(aluOpXor, scratch, t, val))
+	case atomicRmwMaskedOpXchg:
+		c.Emit4Bytes(encodeALUImm(aluOpAdd, scratch, val, 0))
+	default:
+		panic(int(op))
+	}
+	c.Emit4Bytes(encodeALU(aluOpXor, scratch, scratch, t))
+	c.Emit4Bytes(encodeALU(aluOpAnd, scratch, scratch, mask))
+	c.Emit4Bytes(encodeALU(aluOpXor, scratch, scratch, t))
+	c.Emit4Bytes(encodeAmo(amoFunct5Sc, scratch, addr, scratch, false))
+	c.Emit4Bytes(encodeB(brCondNe, scratch, regNumberInEncoding(zero), -24))
+	c.Emit4Bytes(encodeALU(aluOpAnd, rd, t, mask))
+}
+
+// encodeLoadConst encodes the sequence to materialize the 64-bit constant v into rd.
+func encodeLoadConst(c backend.Compiler, rd uint32, v int64) {
+	var seq liSeq
+	n := seq.generate(v)
+	for i := 0; i < n; i++ {
+		op := &seq.ops[i]
+		switch op.kind {
+		case liOpLui:
+			c.Emit4Bytes(encodeU(opcodeLui, rd, op.imm))
+		case liOpAddi:
+			rs := rd
+			if i == 0 {
+				rs = regNumberInEncoding(zero)
+			}
+			c.Emit4Bytes(encodeALUImm(aluOpAdd, rd, rs, op.imm))
+		case liOpAddiw:
+			c.Emit4Bytes(encodeALUImm(aluOpAddw, rd, rd, op.imm))
+		case liOpSlli:
+			c.Emit4Bytes(encodeALUImm(aluOpSll, rd, rd, op.imm))
+		}
+	}
+}
+
+type (
+	// liSeq is the sequence of instructions to materialize a 64-bit constant.
+	liSeq struct {
+		ops [8]liOp
+		n   int
+	}
+	liOp struct {
+		kind liOpKind
+		imm  int64
+	}
+	liOpKind byte
+)
+
+const (
+	liOpLui liOpKind = iota
+	// liOpAddi adds the immediate to rd, or to zero if it's the first instruction.
+	liOpAddi
+	liOpAddiw
+	liOpSlli
+)
+
+// generate computes the sequence to materialize v and returns its length. The algorithm is the same as
+// the one used by LLVM: a 32-bit value is materialized with lui+addiw, and otherwise the upper bits
+// are materialized recursively and then shifted left before adding the lowest 12 bits.
+func (s *liSeq) generate(v int64) int {
+	s.n = 0
+	s.gen(v)
+	return s.n
+}
+
+func (s *liSeq) gen(v int64) {
+	lo12 := v << 52 >> 52
+	if v == int64(int32(v)) {
+		if hi20 := ((v + 0x800) >> 12) & 0xfffff; hi20 != 0 {
+			s.push(liOpLui, hi20)
+			if lo12 != 0 {
+				s.push(liOpAddiw, lo12)
+			}
+		} else {
+			s.push(liOpAddi, lo12)
+		}
+		return
+	}
+
+	v -= lo12
+	shift := bits.TrailingZeros64(uint64(v))
+	v >>= shift
+	// If the remaining bits don't fit in 12 bits, lui can absorb the lowest 12 bits of the shift.
+	if shift > 12 && !fitsInSigned12(v) && v<<12 == int64(int32(v<<12)) {
+		shift -= 12
+		v <<= 12
+	}
+	s.gen(v)
+	s.push(liOpSlli, int64(shift))
+	if lo12 != 0 {
+		s.push(liOpAddi, lo12)
+	}
+}
+
+func (s *liSeq) push(kind liOpKind, imm int64) {
+	s.ops[s.n] = liOp{kind: kind, imm: imm}
+	s.n++
+}
@@ -0,0 +1,95 @@
+package riscv64
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestInstruction_encode(t *testing.T) {
+	amode := func(base int64) addressMode { return addressMode{kind: addressModeKindRegImm, base: x6VReg, imm: base} }
+	for _, tc := range []struct {
+		setup func(*instruction)
+		want  string
+	}{
+		// The expected values of the single instructions are verified by the Go assembler (GOARCH=riscv64 go tool asm),
+		// and written in the little endian.
+		{want: "b3027300", setup: func(i *instruction) { i.asALU(aluOpAdd, x5VReg, x6VReg, x7VReg) }},
+		{want: "b3027340", setup: func(i *instruction) { i.asALU(aluOpSub, x5VReg, x6VReg, x7VReg) }},
+		{want: "bb027300", setup: func(i *instruction) { i.asALU(aluOpAddw, x5VReg, x6VReg, x7VReg) }},
+		{want: "b3127300", setup: func(i *instruction) { i.asALU(aluOpSll, x5VReg, x6VReg, x7VReg) }},
+		{want: "b3527340", setup: func(i *instruction) { i.asALU(aluOpSra, x5VReg, x6VReg, x7VReg) }},
+		{want: "b3327300", setup: func(i *instruction) { i.asALU(aluOpSltu, x5VReg, x6VReg, x7VReg) }},
+		{want: "b3027302", setup: func(i *instruction) { i.asALU(aluOpMul, x5VReg, x6VReg, x7VReg) }},
+		{want: "bb027302", setup: func(i *instruction) { i.asALU(aluOpMulw, x5VReg, x6VReg, x7VReg) }},
+		{want: "b3427302", setup: func(i *instruction) { i.asALU(aluOpDiv, x5VReg, x6VReg, x7VReg) }},
+		{want: "bb527302", setup: func(i *instruction) { i.asALU(aluOpDivuw, x5VReg, x6VReg, x7VReg) }},
+		{want: "b3727302", setup: func(i *instruction) { i.asALU(aluOpRemu, x5VReg, x6VReg, x7VReg) }},
+		{want: "93020380", setup: func(i *instruction) { i.asALUImm(aluOpAdd, x5VReg, x6VReg, -2048) }},
+		{want: "9372f30f", setup: func(i *instruction) { i.asALUImm(aluOpAnd, x5VReg, x6VReg, 255) }},
+		{want: "9312f303", setup: func(i *instruction) { i.asALUImm(aluOpSll, x5VReg, x6VReg, 63) }},
+		{want: "93520342", setup: func(i *instruction) { i.asALUImm(aluOpSra, x5VReg, x6VReg, 32) }},
+		{want: "9b52f341", setup: func(i *instruction) { i.asALUImm(aluOpSraw, x5VReg, x6VReg, 31) }},
+		{want: "9b020300", setup: func(i *instruction) { i.asALUImm(aluOpAddw, x5VReg, x6VReg, 0) }},
+		{want: "93020300", setup: func(i *instruction) { i.asMove64(x5VReg, x6VReg) }},
+		{want: "83328300", setup: func(i *instruction) { i.asLoad(memOpLd, x5VReg, amode(8)) }},
+		{want: "83428300", setup: func(i *instruction) { i.asLoad(memOpLbu, x5VReg, amode(8)) }},
+		{want: "83228300", setup: func(i *instruction) { i.asLoad(memOpLw, x5VReg, amode(8)) }},
+		{want: "83628300", setup: func(i *instruction) { i.asLoad(memOpLwu, x5VReg, amode(8)) }},
+		{want: "83128300", setup: func(i *instruction) { i.asLoad(memOpLh, x5VReg, amode(8)) }},
+		{want: "233853fe", setup: func(i *instruction) { i.asStore(memOpSd, x5VReg, amode(-16)) }},
+		{want: "23005300", setup: func(i *instruction) { i.asStore(memOpSb, x5VReg, amode(0)) }},
+		{want: "87328300", setup: func(i *instruction) { i.asLoad(memOpFld, f5VReg, amode(8)) }},
+		{want: "87228300", setup: func(i *instruction) { i.asLoad(memOpFlw, f5VReg, amode(8)) }},
+		{want: "27345300", setup: func(i *instruction) { i.asStore(memOpFsd, f5VReg, amode(8)) }},
+		{want: "d3027302", setup: func(i *instruction) { i.asFpuRRR(fpuOpAdd, f5VReg, f6VReg, f7VReg, true) }},
+		{want: "d3027308", setup: func(i *instruction) { i.asFpuRRR(fpuOpSub, f5VReg, f6VReg, f7VReg, false) }},
+		{want: "d3027322", setup: func(i *instruction) { i.asFpuRRR(fpuOpSgnj, f5VReg, f6VReg, f7VReg, true) }},
+		{want: "d3127320", setup: func(i *instruction) { i.asFpuRRR(fpuOpSgnjn, f5VReg, f6VReg, f7VReg, false) }},
+		{want: "d3227322", setup: func(i *instruction) { i.asFpuRRR(fpuOpSgnjx, f5VReg, f6VReg, f7VReg, true) }},
+		{want: "d302732a", setup: func(i *instruction) { i.asFpuRRR(fpuOpMin, f5VReg, f6VReg, f7VReg, true) }},
+		{want: "d3127328", setup: func(i *instruction) { i.asFpuRRR(fpuOpMax, f5VReg, f6VReg, f7VReg, false) }},
+		{want: "d302035a", setup: func(i *instruction) { i.asFpuRR(fpuUniOpSqrt, f5VReg, f6VReg, true) }},
+		{want: "d3021340", setup: func(i *instruction) { i.asFpuRR(fpuUniOpCvt64To32, f5VReg, f6VReg, false) }},
+		{want: "d3020342", setup: func(i *instruction) { i.asFpuRR(fpuUniOpCvt32To64, f5VReg, f6VReg, true) }},
+		{want: "d30203e2", setup: func(i *instruction) { i.asFpuRR(fpuUniOpMvToInt, x5VReg, f6VReg, true) }},
+		{want: "d30203f0", setup: func(i *instruction) { i.asFpuRR(fpuUniOpMvFromInt, f5VReg, x6VReg, false) }},
+		{want: "d32273a2", setup: func(i *instruction) { i.asFpuCmp(fpuCmpOpEq, x5VReg, f6VReg, f7VReg, true) }},
+		{want: "d31273a0", setup: func(i *instruction) { i.asFpuCmp(fpuCmpOpLt, x5VReg, f6VReg, f7VReg, false) }},
+		{want: "d30273a2", setup: func(i *instruction) { i.asFpuCmp(fpuCmpOpLe, x5VReg, f6VReg, f7VReg, true) }},
+		{want: "d31203c0", setup: func(i *instruction) { i.asFpuToInt(x5VReg, f6VReg, true, false, false) }},
+		{want: "d31233c2", setup: func(i *instruction) { i.asFpuToInt(x5VReg, f6VReg, false, true, true) }},
+		{want: "d30223d2", setup: func(i *instruction) { i.asIntToFpu(f5VReg, x6VReg, true, true, true) }},
+		{want: "d30213d0", setup: func(i *instruction) { i.asIntToFpu(f5VReg, x6VReg, false, false, false) }},
+		{want: "d3026322", setup: func(i *instruction) { i.asFpuMov64(f5VReg, f6VReg) }},
+		{want: "af327306", setup: func(i *instruction) { i.asAmo(amoOpAdd, x5VReg, x6VReg, x7VReg, 8) }},
+		{want: "af22730e", setup: func(i *instruction) { i.asAmo(amoOpSwap, x5VReg, x6VReg, x7VReg, 4) }},
+		{want: "e7000300", setup: func(i *instruction) { i.asCallIndirect(x6VReg, nil) }},
+		{want: "67800000", setup: func(i *instruction) { i.asRet() }},
+		// The followings are not from the Go assembler which has no corresponding mnemonic.
+		{want: "0f003003", setup: func(i *instruction) { i.asFence(fenceRW, fenceRW) }},
+		{want: "0f003002", setup: func(i *instruction) { i.asFence(fenceR, fenceRW) }},
+		{want: "73101000", setup: func(i *instruction) { i.asClearFflags() }},
+		{want: "f3221000", setup: func(i *instruction) { i.asReadFflags(x5VReg) }},
+		{want: "00000000", setup: func(i *instruction) { i.asUDF() }},
+		{want: "67000300", setup: func(i *instruction) { i.asTailCallIndirect(x6VReg, nil) }},
+		{want: "83300502" + "03318501" + "67800000", setup: func(i *instruction) { i.asExitSequence(x10VReg) }},
+		{want: "97020000" + "93820201", setup: func(i *instruction) { i.asAdr(x5VReg, 16) }},
+		// Loads and stores whose offset doesn't fit in the signed 12-bit.
+		{want: "b71f0000" + "9b8f0f80" + "b30ff301" + "83b20f00", setup: func(i *instruction) { i.asLoad(memOpLd, x5VReg, amode(2048)) }},
+		{want: "b70f0100" + "b30ff301" + "23a05f00", setup: func(i *instruction) { i.asStore(memOpSw, x5VReg, amode(1<<16)) }},
+	} {
+		tc := tc
+		t.Run(tc.want, func(t *testing.T) {
+			i := &instruction{}
+			tc.setup(i)
+
+			mc := &mockCompiler{}
+			m := &machine{compiler: mc}
+			m.encode(i)
+			require.Equal(t, tc.want, hex.EncodeToString(mc.buf))
+			require.Equal(t, int64(len(tc.want)/2), i.size())
+		})
+	}
+}
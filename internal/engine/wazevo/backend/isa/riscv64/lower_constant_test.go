@@ -0,0 +1,75 @@
+package riscv64
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestLiSeq_generate(t *testing.T) {
+	for _, tc := range []struct {
+		v      int64
+		expLen int
+	}{
+		{v: 0, expLen: 1},
+		{v: 1, expLen: 1},
+		{v: -1, expLen: 1},
+		{v: 2047, expLen: 1},
+		{v: -2048, expLen: 1},
+		{v: 2048, expLen: 2},
+		{v: 0x1000, expLen: 1},
+		{v: 0x12345678, expLen: 2},
+		{v: math.MaxInt32, expLen: 2},
+		{v: math.MinInt32, expLen: 1},
+		{v: math.MaxUint32, expLen: 3},
+		{v: 1 << 32, expLen: 2},
+		{v: math.MinInt64, expLen: 2},
+		{v: math.MaxInt64, expLen: 3},
+		{v: 0x123456789abcdef0, expLen: 8},
+		{v: 0x5555555555555555, expLen: 8},
+		{v: -0x7ff8000000000000, expLen: 2},
+	} {
+		var s liSeq
+		n := s.generate(tc.v)
+		require.Equal(t, tc.expLen, n, "%#x", tc.v)
+		require.Equal(t, tc.v, evalLiSeq(&s, n), "%#x", tc.v)
+	}
+}
+
+func TestLiSeq_generate_exhaustive(t *testing.T) {
+	var s liSeq
+	for _, base := range []int64{0, 1, 0x7ff, 0x800, 0xfff, 0x1000, 0x7ffff800, 0x80000000, 0xffffffff, 0x100000000} {
+		for shift := 0; shift < 64; shift++ {
+			for _, delta := range []int64{-1, 0, 1} {
+				v := base<<shift + delta
+				for _, v := range []int64{v, -v, ^v} {
+					n := s.generate(v)
+					require.True(t, n <= 8)
+					require.Equal(t, v, evalLiSeq(&s, n), "%#x", v)
+				}
+			}
+		}
+	}
+}
+
+// evalLiSeq evaluates the sequence as the hardware does.
+func evalLiSeq(s *liSeq, n int) (rd int64) {
+	for i := 0; i < n; i++ {
+		op := s.ops[i]
+		switch op.kind {
+		case liOpLui:
+			rd = int64(int32(uint32(op.imm) << 12))
+		case liOpAddi:
+			if i == 0 {
+				rd = 0
+			}
+			rd += op.imm
+		case liOpAddiw:
+			rd = int64(int32(rd + op.imm))
+		case liOpSlli:
+			rd <<= op.imm
+		}
+	}
+	return
+}
@@ -0,0 +1,1021 @@
+package riscv64
+
+// Files prefixed as lower_instr** do the instruction selection, meaning that lowering SSA level instructions
+// into machine specific instructions.
+//
+// Note that 32-bit integer values are always kept sign-extended to 64-bit in the registers, which is the
+// canonical form of RV64: the *w instructions operate on the lower 32 bits and sign-extend the results.
+// Since the sign-extension preserves both the signed and unsigned ordering, 32-bit values can be compared
+// with the 64-bit comparisons as-is.
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// fflagsNV is the invalid operation bit of the fflags CSR.
+const fflagsNV = 0x10
+
+// LowerSingleBranch implements backend.Machine.
+func (m *machine) LowerSingleBranch(br *ssa.Instruction) {
+	switch br.Opcode() {
+	case ssa.OpcodeJump:
+		_, _, targetBlkID := br.BranchData()
+		if br.IsFallthroughJump() {
+			return
+		}
+		b := m.allocateInstr()
+		targetBlk := m.compiler.SSABuilder().BasicBlock(targetBlkID)
+		if targetBlk.ReturnBlock() {
+			b.asRet()
+		} else {
+			b.asBr(ssaBlockLabel(targetBlk))
+		}
+		m.insert(b)
+	case ssa.OpcodeBrTable:
+		m.lowerBrTable(br)
+	default:
+		panic("BUG: unexpected branch opcode" + br.Opcode().String())
+	}
+}
+
+func (m *machine) lowerBrTable(i *ssa.Instruction) {
+	index, targetBlockIDs := i.BrTableData()
+	targetBlockCount := len(targetBlockIDs.View())
+	indexReg := m.getOperand(index)
+
+	// Firstly, we have to do the bounds check of the index, and
+	// set it to the default target (sitting at the end of the list) if it's out of bounds.
+	//
+	//	li maxIndexReg, #maximum_index
+	//	sltu inBounds, index, maxIndexReg
+	//	select adjustedIndex, inBounds, index, maxIndexReg
+	maxIndexReg := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.insertLoadConstant(uint64(targetBlockCount-1), ssa.TypeI64, maxIndexReg)
+	inBounds := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.insert(m.allocateInstr().asALU(aluOpSltu, inBounds, indexReg, maxIndexReg))
+	adjustedIndex := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.insert(m.allocateInstr().asSelect(adjustedIndex, inBounds, indexReg, maxIndexReg))
+
+	brSequence := m.allocateInstr()
+	tableIndex := m.addJmpTableTarget(targetBlockIDs)
+	brSequence.asBrTableSequence(adjustedIndex, tableIndex, targetBlockCount)
+	m.insert(brSequence)
+}
+
+// LowerConditionalBranch implements backend.Machine.
+func (m *machine) LowerConditionalBranch(b *ssa.Instruction) {
+	cval, args, targetBlkID := b.BranchData()
+	if len(args) > 0 {
+		panic(fmt.Sprintf(
+			"conditional branch shouldn't have args; likely a bug in critical edge splitting: from %s to %s",
+			m.currentLabelPos.sb,
+			targetBlkID,
+		))
+	}
+
+	targetBlk := m.compiler.SSABuilder().BasicBlock(targetBlkID)
+	target := ssaBlockLabel(targetBlk)
+	cvalDef := m.compiler.ValueDefinition(cval)
+
+	cbr := m.allocateInstr()
+	if m.compiler.MatchInstr(cvalDef, ssa.OpcodeIcmp) {
+		// The comparison can be fused into the branch instruction.
+		x, y, c := cvalDef.Instr.IcmpData()
+		cond, rs1, rs2 := m.lowerIcmpToBrCond(x, y, c)
+		if b.Opcode() == ssa.OpcodeBrz {
+			cond = cond.invert()
+		}
+		cbr.asCondBr(cond, rs1, rs2, target)
+		cvalDef.Instr.MarkLowered()
+	} else {
+		rs := m.getOperand(cval)
+		if b.Opcode() == ssa.OpcodeBrz {
+			cbr.asCondBr(brCondEq, rs, zeroVReg, target)
+		} else {
+			cbr.asCondBr(brCondNe, rs, zeroVReg, target)
+		}
+	}
+	m.insert(cbr)
+}
+
+// lowerIcmpToBrCond returns the condition and the operands of the conditional branch which holds iff `x c y`.
+func (m *machine) lowerIcmpToBrCond(x, y ssa.Value, c ssa.IntegerCmpCond) (cond brCond, rs1, rs2 regalloc.VReg) {
+	cond, swap := brCondFromSSAIntegerCmpCond(c)
+	rs1, rs2 = m.getOperand(x), m.getOperand(y)
+	if swap {
+		rs1, rs2 = rs2, rs1
+	}
+	return
+}
+
+// LowerInstr implements backend.Machine.
+func (m *machine) LowerInstr(instr *ssa.Instruction) {
+	if l := instr.SourceOffset(); l.Valid() {
+		info := m.allocateInstr().asEmitSourceOffsetInfo(l)
+		m.insert(info)
+	}
+
+	switch op := instr.Opcode(); op {
+	case ssa.OpcodeBrz, ssa.OpcodeBrnz, ssa.OpcodeJump, ssa.OpcodeBrTable:
+		panic("BUG: branching instructions are handled by LowerBranches")
+	case ssa.OpcodeReturn:
+		panic("BUG: return must be handled by backend.Compiler")
+	case ssa.OpcodeIconst, ssa.OpcodeF32const, ssa.OpcodeF64const: // Constant instructions are inlined.
+	case ssa.OpcodeIadd, ssa.OpcodeIsub:
+		m.lowerSubOrAdd(instr, op == ssa.OpcodeIadd)
+	case ssa.OpcodeImul:
+		x, y := instr.Arg2()
+		m.lowerALU(selectALUOp(x.Type(), aluOpMul, aluOpMulw), x, y, instr.Return())
+	case ssa.OpcodeBand:
+		x, y := instr.Arg2()
+		m.lowerALU(aluOpAnd, x, y, instr.Return())
+	case ssa.OpcodeBor:
+		x, y := instr.Arg2()
+		m.lowerALU(aluOpOr, x, y, instr.Return())
+	case ssa.OpcodeBxor:
+		x, y := instr.Arg2()
+		m.lowerALU(aluOpXor, x, y, instr.Return())
+	case ssa.OpcodeIshl:
+		x, y := instr.Arg2()
+		m.lowerShift(selectALUOp(x.Type(), aluOpSll, aluOpSllw), x, y, instr.Return())
+	case ssa.OpcodeUshr:
+		x, y := instr.Arg2()
+		m.lowerShift(selectALUOp(x.Type(), aluOpSrl, aluOpSrlw), x, y, instr.Return())
+	case ssa.OpcodeSshr:
+		x, y := instr.Arg2()
+		m.lowerShift(selectALUOp(x.Type(), aluOpSra, aluOpSraw), x, y, instr.Return())
+	case ssa.OpcodeRotl, ssa.OpcodeRotr:
+		x, y := instr.Arg2()
+		m.lowerRotate(x, y, instr.Return(), op == ssa.OpcodeRotl)
+	case ssa.OpcodeSExtend, ssa.OpcodeUExtend:
+		from, to, signed := instr.ExtendData()
+		m.lowerExtend(m.getOperand(instr.Arg()), m.compiler.VRegOf(instr.Return()), from, to, signed)
+	case ssa.OpcodeIreduce:
+		retVal := instr.Return()
+		if retVal.Type() != ssa.TypeI32 {
+			panic("BUG: Ireduce to non-i32")
+		}
+		// Sign-extends the lower 32 bits into the canonical form.
+		rs := m.getOperand(instr.Arg())
+		m.insert(m.allocateInstr().asALUImm(aluOpAddw, m.compiler.VRegOf(retVal), rs, 0))
+	case ssa.OpcodeIcmp:
+		x, y, c := instr.IcmpData()
+		m.lowerIcmp(x, y, c, instr.Return())
+	case ssa.OpcodeFcmp:
+		x, y, c := instr.FcmpData()
+		m.lowerFcmp(x, y, c, instr.Return())
+	case ssa.OpcodeSelect:
+		c, x, y := instr.SelectData()
+		rc, rx, ry := m.getOperand(c), m.getOperand(x), m.getOperand(y)
+		m.insert(m.allocateInstr().asSelect(m.compiler.VRegOf(instr.Return()), rc, rx, ry))
+	case ssa.OpcodeClz:
+		m.lowerClz(instr.Arg(), instr.Return())
+	case ssa.OpcodeCtz:
+		m.lowerCtz(instr.Arg(), instr.Return())
+	case ssa.OpcodePopcnt:
+		m.lowerPopcnt(instr.Arg(), instr.Return())
+	case ssa.OpcodeSdiv, ssa.OpcodeUdiv:
+		x, y, ctx := instr.Arg3()
+		ctxVReg := m.compiler.VRegOf(ctx)
+		rs1, rs2 := m.getOperand(x), m.getOperand(y)
+		rd := m.compiler.VRegOf(instr.Return())
+		m.lowerIDiv(ctxVReg, rd, rs1, rs2, x.Type() == ssa.TypeI64, op == ssa.OpcodeSdiv)
+	case ssa.OpcodeSrem, ssa.OpcodeUrem:
+		x, y, ctx := instr.Arg3()
+		ctxVReg := m.compiler.VRegOf(ctx)
+		rs1, rs2 := m.getOperand(x), m.getOperand(y)
+		rd := m.compiler.VRegOf(instr.Return())
+		m.lowerIRem(ctxVReg, rd, rs1, rs2, x.Type() == ssa.TypeI64, op == ssa.OpcodeSrem)
+	case ssa.OpcodeFadd, ssa.OpcodeFsub, ssa.OpcodeFmul, ssa.OpcodeFdiv:
+		m.lowerFpuBinOp(instr)
+	case ssa.OpcodeFmin, ssa.OpcodeFmax:
+		x, y := instr.Arg2()
+		rs1, rs2 := m.getOperand(x), m.getOperand(y)
+		rd := m.compiler.VRegOf(instr.Return())
+		m.insert(m.allocateInstr().asFpuMinMax(op == ssa.OpcodeFmax, rd, rs1, rs2, x.Type() == ssa.TypeF64))
+	case ssa.OpcodeSqrt:
+		m.lowerFpuUniOp(fpuUniOpSqrt, instr.Arg(), instr.Return())
+	case ssa.OpcodeFneg:
+		m.lowerFpuSignInjection(fpuOpSgnjn, instr.Arg(), instr.Arg(), instr.Return())
+	case ssa.OpcodeFabs:
+		m.lowerFpuSignInjection(fpuOpSgnjx, instr.Arg(), instr.Arg(), instr.Return())
+	case ssa.OpcodeFcopysign:
+		x, y := instr.Arg2()
+		m.lowerFpuSignInjection(fpuOpSgnj, x, y, instr.Return())
+	case ssa.OpcodeCeil:
+		m.lowerFpuRound(roundingModeRUP, instr.Arg(), instr.Return())
+	case ssa.OpcodeFloor:
+		m.lowerFpuRound(roundingModeRDN, instr.Arg(), instr.Return())
+	case ssa.OpcodeTrunc:
+		m.lowerFpuRound(roundingModeRTZ, instr.Arg(), instr.Return())
+	case ssa.OpcodeNearest:
+		m.lowerFpuRound(roundingModeRNE, instr.Arg(), instr.Return())
+	case ssa.OpcodeFdemote:
+		m.lowerFpuUniOp(fpuUniOpCvt64To32, instr.Arg(), instr.Return())
+	case ssa.OpcodeFpromote:
+		m.lowerFpuUniOp(fpuUniOpCvt32To64, instr.Arg(), instr.Return())
+	case ssa.OpcodeBitcast:
+		m.lowerBitcast(instr)
+	case ssa.OpcodeFcvtToSint, ssa.OpcodeFcvtToSintSat:
+		x, ctx := instr.Arg2()
+		result := instr.Return()
+		rs := m.getOperand(x)
+		rd := m.compiler.VRegOf(result)
+		ctxVReg := m.compiler.VRegOf(ctx)
+		m.lowerFpuToInt(rd, rs, ctxVReg, true, x.Type() == ssa.TypeF64,
+			result.Type().Bits() == 64, op == ssa.OpcodeFcvtToSintSat)
+	case ssa.OpcodeFcvtToUint, ssa.OpcodeFcvtToUintSat:
+		x, ctx := instr.Arg2()
+		result := instr.Return()
+		rs := m.getOperand(x)
+		rd := m.compiler.VRegOf(result)
+		ctxVReg := m.compiler.VRegOf(ctx)
+		m.lowerFpuToInt(rd, rs, ctxVReg, false, x.Type() == ssa.TypeF64,
+			result.Type().Bits() == 64, op == ssa.OpcodeFcvtToUintSat)
+	case ssa.OpcodeFcvtFromSint, ssa.OpcodeFcvtFromUint:
+		x := instr.Arg()
+		result := instr.Return()
+		rs := m.getOperand(x)
+		rd := m.compiler.VRegOf(result)
+		cvt := m.allocateInstr()
+		cvt.asIntToFpu(rd, rs, op == ssa.OpcodeFcvtFromSint, x.Type() == ssa.TypeI64, result.Type().Bits() == 64)
+		m.insert(cvt)
+	case ssa.OpcodeExitWithCode:
+		execCtx, code := instr.ExitWithCodeData()
+		m.lowerExitWithCode(m.compiler.VRegOf(execCtx), code)
+	case ssa.OpcodeExitIfTrueWithCode:
+		execCtx, c, code := instr.ExitIfTrueWithCodeData()
+		m.lowerExitIfTrueWithCode(m.compiler.VRegOf(execCtx), c, code)
+	case ssa.OpcodeStore, ssa.OpcodeIstore8, ssa.OpcodeIstore16, ssa.OpcodeIstore32:
+		m.lowerStore(instr)
+	case ssa.OpcodeLoad:
+		ptr, offset, typ := instr.LoadData()
+		m.lowerLoad(ptr, offset, loadOpFor(typ), instr.Return())
+	case ssa.OpcodeUload8, ssa.OpcodeUload16, ssa.OpcodeUload32, ssa.OpcodeSload8, ssa.OpcodeSload16, ssa.OpcodeSload32:
+		ptr, offset, _ := instr.LoadData()
+		m.lowerLoad(ptr, offset, extLoadOp(op), instr.Return())
+	case ssa.OpcodeCall, ssa.OpcodeCallIndirect:
+		m.lowerCall(instr)
+	case ssa.OpcodeUndefined:
+		m.insert(m.allocateInstr().asUDF())
+	case ssa.OpcodeAtomicRmw:
+		m.lowerAtomicRmw(instr)
+	case ssa.OpcodeAtomicCas:
+		m.lowerAtomicCas(instr)
+	case ssa.OpcodeAtomicLoad:
+		m.lowerAtomicLoad(instr)
+	case ssa.OpcodeAtomicStore:
+		m.lowerAtomicStore(instr)
+	case ssa.OpcodeFence:
+		m.insert(m.allocateInstr().asFence(fenceRW, fenceRW))
+	default:
+		// The vector instructions never reach here, as the modules using v128 are rejected before compilation.
+		panic("BUG: unsupported lowering of " + op.String())
+	}
+	m.FlushPendingInstructions()
+}
+
+// InsertMove implements backend.Machine.
+func (m *machine) InsertMove(dst, src regalloc.VReg, typ ssa.Type) {
+	instr := m.allocateInstr()
+	switch typ {
+	case ssa.TypeI32, ssa.TypeI64:
+		instr.asMove64(dst, src)
+	case ssa.TypeF32, ssa.TypeF64:
+		instr.asFpuMov64(dst, src)
+	default:
+		panic("BUG: unsupported type " + typ.String())
+	}
+	m.insert(instr)
+}
+
+// getOperand returns the register holding the value. Constants are inlined at the use site so that we could
+// reduce the register usage, and zero is served by the zero register.
+func (m *machine) getOperand(v ssa.Value) regalloc.VReg {
+	def := m.compiler.ValueDefinition(v)
+	if def.IsFromInstr() && def.Instr.Constant() {
+		def.Instr.MarkLowered()
+		if typ := v.Type(); typ.IsInt() && constantValue(def.Instr) == 0 {
+			return zeroVReg
+		}
+		return m.lowerConstant(def.Instr)
+	}
+	return m.compiler.VRegOf(v)
+}
+
+// getImm12 returns the integer constant value of v if it fits in the signed 12-bit immediate.
+func (m *machine) getImm12(v ssa.Value) (imm int64, ok bool) {
+	def := m.compiler.ValueDefinition(v)
+	if !def.IsFromInstr() || !def.Instr.Constant() || !v.Type().IsInt() {
+		return
+	}
+	imm = constantValue(def.Instr)
+	if ok = fitsInSigned12(imm); ok {
+		def.Instr.MarkLowered()
+	}
+	return
+}
+
+// constantValue returns the integer constant of the instruction in the canonical form in the registers.
+func constantValue(instr *ssa.Instruction) int64 {
+	v := instr.ConstantVal()
+	if instr.Return().Type().Bits() == 32 {
+		return int64(int32(v))
+	}
+	return int64(v)
+}
+
+// selectALUOp returns op64 for 64-bit integers, otherwise op32.
+func selectALUOp(typ ssa.Type, op64, op32 aluOp) aluOp {
+	if typ.Bits() == 64 {
+		return op64
+	}
+	return op32
+}
+
+// copyToTmp copies the given regalloc.VReg to a temporary register. This is called before cbr to avoid the regalloc issue
+// e.g. reload happening in the middle of the exit sequence which is not the path the normal path executes
+func (m *machine) copyToTmp(v regalloc.VReg) regalloc.VReg {
+	typ := m.compiler.TypeOf(v)
+	tmp := m.compiler.AllocateVReg(typ)
+	m.InsertMove(tmp, v, typ)
+	return tmp
+}
+
+func (m *machine) lowerSubOrAdd(si *ssa.Instruction, add bool) {
+	x, y := si.Arg2()
+	rd := m.compiler.VRegOf(si.Return())
+	if imm, ok := m.getImm12(y); ok && (add || imm != -2048) {
+		if !add {
+			imm = -imm
+		}
+		rs1 := m.getOperand(x)
+		m.insert(m.allocateInstr().asALUImm(selectALUOp(x.Type(), aluOpAdd, aluOpAddw), rd, rs1, imm))
+		return
+	}
+
+	rs1, rs2 := m.getOperand(x), m.getOperand(y)
+	var op aluOp
+	if add {
+		op = selectALUOp(x.Type(), aluOpAdd, aluOpAddw)
+	} else {
+		op = selectALUOp(x.Type(), aluOpSub, aluOpSubw)
+	}
+	m.insert(m.allocateInstr().asALU(op, rd, rs1, rs2))
+}
+
+// lowerALU lowers the binary integer operation. The immediate form is used if available.
+func (m *machine) lowerALU(op aluOp, x, y, ret ssa.Value) {
+	rd := m.compiler.VRegOf(ret)
+	if op.hasImmForm() {
+		if imm, ok := m.getImm12(y); ok {
+			rs1 := m.getOperand(x)
+			m.insert(m.allocateInstr().asALUImm(op, rd, rs1, imm))
+			return
+		}
+	}
+	rs1, rs2 := m.getOperand(x), m.getOperand(y)
+	m.insert(m.allocateInstr().asALU(op, rd, rs1, rs2))
+}
+
+// lowerShift lowers the shift. Both the native shifts and WebAssembly use the shift amount modulo the bit width.
+func (m *machine) lowerShift(op aluOp, x, y, ret ssa.Value) {
+	rd := m.compiler.VRegOf(ret)
+	rs1 := m.getOperand(x)
+	yDef := m.compiler.ValueDefinition(y)
+	if yDef.IsFromInstr() && yDef.Instr.Constant() {
+		yDef.Instr.MarkLowered()
+		amount := int64(yDef.Instr.ConstantVal()) & int64(x.Type().Bits()-1)
+		m.insert(m.allocateInstr().asALUImm(op, rd, rs1, amount))
+		return
+	}
+	rs2 := m.getOperand(y)
+	m.insert(m.allocateInstr().asALU(op, rd, rs1, rs2))
+}
+
+// lowerRotate lowers the rotation as the combination of the shifts in the opposite directions:
+//
+//	rotl rd, x, y == or rd, (sll x, y), (srl x, -y)
+//	rotr rd, x, y == or rd, (srl x, y), (sll x, -y)
+func (m *machine) lowerRotate(x, y, ret ssa.Value, left bool) {
+	sll, srl := selectALUOp(x.Type(), aluOpSll, aluOpSllw), selectALUOp(x.Type(), aluOpSrl, aluOpSrlw)
+	if !left {
+		sll, srl = srl, sll
+	}
+
+	rd := m.compiler.VRegOf(ret)
+	rs1 := m.getOperand(x)
+	hi, lo := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+	yDef := m.compiler.ValueDefinition(y)
+	if yDef.IsFromInstr() && yDef.Instr.Constant() {
+		yDef.Instr.MarkLowered()
+		bits := int64(x.Type().Bits())
+		amount := int64(yDef.Instr.ConstantVal()) & (bits - 1)
+		if amount == 0 {
+			m.insert(m.allocateInstr().asMove64(rd, rs1))
+			return
+		}
+		m.insert(m.allocateInstr().asALUImm(sll, hi, rs1, amount))
+		m.insert(m.allocateInstr().asALUImm(srl, lo, rs1, bits-amount))
+	} else {
+		rs2 := m.getOperand(y)
+		neg := m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asALU(aluOpSub, neg, zeroVReg, rs2))
+		m.insert(m.allocateInstr().asALU(sll, hi, rs1, rs2))
+		m.insert(m.allocateInstr().asALU(srl, lo, rs1, neg))
+	}
+	// The results of the 32-bit shifts are sign-extended, so is the bitwise or of them.
+	m.insert(m.allocateInstr().asALU(aluOpOr, rd, hi, lo))
+}
+
+// lowerExtend lowers the sign or zero extension of the lower `from` bits of rs into rd.
+func (m *machine) lowerExtend(rs, rd regalloc.VReg, from, to byte, signed bool) {
+	switch {
+	case from == 32 && signed:
+		m.insert(m.allocateInstr().asALUImm(aluOpAddw, rd, rs, 0))
+	case from == 8 && !signed:
+		m.insert(m.allocateInstr().asALUImm(aluOpAnd, rd, rs, 0xff))
+	default:
+		// Shift the value to the top, and then shift it back with either the arithmetic or logical shift.
+		shift := int64(64 - from)
+		tmp := m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asALUImm(aluOpSll, tmp, rs, shift))
+		if signed {
+			m.insert(m.allocateInstr().asALUImm(aluOpSra, rd, tmp, shift))
+		} else {
+			m.insert(m.allocateInstr().asALUImm(aluOpSrl, rd, tmp, shift))
+		}
+	}
+}
+
+// zeroExtend32 returns the new register holding the zero-extended lower 32 bits of rs.
+func (m *machine) zeroExtend32(rs regalloc.VReg) regalloc.VReg {
+	rd := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.lowerExtend(rs, rd, 32, 64, false)
+	return rd
+}
+
+func (m *machine) lowerIcmp(x, y ssa.Value, c ssa.IntegerCmpCond, ret ssa.Value) {
+	rd := m.compiler.VRegOf(ret)
+	switch c {
+	case ssa.IntegerCmpCondEqual, ssa.IntegerCmpCondNotEqual:
+		diff := m.compiler.AllocateVReg(ssa.TypeI64)
+		rs1, rs2 := m.getOperand(x), m.getOperand(y)
+		m.insert(m.allocateInstr().asALU(aluOpXor, diff, rs1, rs2))
+		if c == ssa.IntegerCmpCondEqual {
+			m.insert(m.allocateInstr().asALUImm(aluOpSltu, rd, diff, 1)) // seqz
+		} else {
+			m.insert(m.allocateInstr().asALU(aluOpSltu, rd, zeroVReg, diff)) // snez
+		}
+		return
+	}
+
+	// The other conditions are either `x < y` or its negation `x >= y` with the operands possibly swapped.
+	cond, rs1, rs2 := m.lowerIcmpToBrCond(x, y, c)
+	op := aluOpSlt
+	if cond == brCondLtu || cond == brCondGeu {
+		op = aluOpSltu
+	}
+	if cond == brCondLt || cond == brCondLtu {
+		m.insert(m.allocateInstr().asALU(op, rd, rs1, rs2))
+	} else {
+		lt := m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asALU(op, lt, rs1, rs2))
+		m.insert(m.allocateInstr().asALUImm(aluOpXor, rd, lt, 1))
+	}
+}
+
+func (m *machine) lowerFcmp(x, y ssa.Value, c ssa.FloatCmpCond, ret ssa.Value) {
+	rd := m.compiler.VRegOf(ret)
+	rs1, rs2 := m.getOperand(x), m.getOperand(y)
+	is64 := x.Type() == ssa.TypeF64
+	cmp := m.allocateInstr()
+	switch c {
+	case ssa.FloatCmpCondEqual:
+		cmp.asFpuCmp(fpuCmpOpEq, rd, rs1, rs2, is64)
+	case ssa.FloatCmpCondNotEqual:
+		// This is true if either of them is NaN.
+		eq := m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asFpuCmp(fpuCmpOpEq, eq, rs1, rs2, is64))
+		cmp.asALUImm(aluOpXor, rd, eq, 1)
+	case ssa.FloatCmpCondLessThan:
+		cmp.asFpuCmp(fpuCmpOpLt, rd, rs1, rs2, is64)
+	case ssa.FloatCmpCondLessThanOrEqual:
+		cmp.asFpuCmp(fpuCmpOpLe, rd, rs1, rs2, is64)
+	case ssa.FloatCmpCondGreaterThan:
+		cmp.asFpuCmp(fpuCmpOpLt, rd, rs2, rs1, is64)
+	case ssa.FloatCmpCondGreaterThanOrEqual:
+		cmp.asFpuCmp(fpuCmpOpLe, rd, rs2, rs1, is64)
+	default:
+		panic(c)
+	}
+	m.insert(cmp)
+}
+
+// lowerPopcnt lowers the population count. Since the base ISA doesn't have the bit manipulation instructions,
+// this is implemented with the well-known SWAR sequence.
+func (m *machine) lowerPopcnt(x, ret ssa.Value) {
+	rs := m.getOperand(x)
+	if x.Type().Bits() == 32 {
+		rs = m.zeroExtend32(rs)
+	}
+	m.popcnt64(m.compiler.VRegOf(ret), rs)
+}
+
+// lowerClz lowers the count of the leading zeros as the population count of the complement of the value
+// whose bits below the highest set bit are all set.
+func (m *machine) lowerClz(x, ret ssa.Value) {
+	rs := m.getOperand(x)
+	_32 := x.Type().Bits() == 32
+	if _32 {
+		rs = m.zeroExtend32(rs)
+	}
+	for shift := int64(1); shift < 64; shift <<= 1 {
+		shifted, smeared := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asALUImm(aluOpSrl, shifted, rs, shift))
+		m.insert(m.allocateInstr().asALU(aluOpOr, smeared, rs, shifted))
+		rs = smeared
+	}
+	not := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.insert(m.allocateInstr().asALUImm(aluOpXor, not, rs, -1))
+
+	rd := m.compiler.VRegOf(ret)
+	if !_32 {
+		m.popcnt64(rd, not)
+		return
+	}
+	// The upper 32 bits of the zero-extended value are counted as well.
+	cnt := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.popcnt64(cnt, not)
+	m.insert(m.allocateInstr().asALUImm(aluOpAdd, rd, cnt, -32))
+}
+
+// lowerCtz lowers the count of the trailing zeros as the population count of `~x & (x - 1)`,
+// whose bits are set only at the trailing zeros of x.
+func (m *machine) lowerCtz(x, ret ssa.Value) {
+	rs := m.getOperand(x)
+	not, minusOne, mask := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+	m.insert(m.allocateInstr().asALUImm(aluOpXor, not, rs, -1))
+	m.insert(m.allocateInstr().asALUImm(aluOpAdd, minusOne, rs, -1))
+	m.insert(m.allocateInstr().asALU(aluOpAnd, mask, not, minusOne))
+	if x.Type().Bits() == 32 {
+		mask = m.zeroExtend32(mask)
+	}
+	m.popcnt64(m.compiler.VRegOf(ret), mask)
+}
+
+// popcnt64 inserts the SWAR population count of the 64-bit value rs into rd:
+//
+//	x = x - ((x >> 1) & 0x5555555555555555)
+//	x = (x & 0x3333333333333333) + ((x >> 2) & 0x3333333333333333)
+//	x = (x + (x >> 4)) & 0x0f0f0f0f0f0f0f0f
+//	rd = (x * 0x0101010101010101) >> 56
+func (m *machine) popcnt64(rd, rs regalloc.VReg) {
+	alloc := func() regalloc.VReg { return m.compiler.AllocateVReg(ssa.TypeI64) }
+	constant := func(v uint64) regalloc.VReg {
+		r := alloc()
+		m.lowerConstantI64(r, int64(v))
+		return r
+	}
+
+	shifted, masked, x1 := alloc(), alloc(), alloc()
+	m.insert(m.allocateInstr().asALUImm(aluOpSrl, shifted, rs, 1))
+	m.insert(m.allocateInstr().asALU(aluOpAnd, masked, shifted, constant(0x5555555555555555)))
+	m.insert(m.allocateInstr().asALU(aluOpSub, x1, rs, masked))
+
+	c3 := constant(0x3333333333333333)
+	lo, shifted2, hi, x2 := alloc(), alloc(), alloc(), alloc()
+	m.insert(m.allocateInstr().asALU(aluOpAnd, lo, x1, c3))
+	m.insert(m.allocateInstr().asALUImm(aluOpSrl, shifted2, x1, 2))
+	m.insert(m.allocateInstr().asALU(aluOpAnd, hi, shifted2, c3))
+	m.insert(m.allocateInstr().asALU(aluOpAdd, x2, lo, hi))
+
+	shifted4, sum, x3 := alloc(), alloc(), alloc()
+	m.insert(m.allocateInstr().asALUImm(aluOpSrl, shifted4, x2, 4))
+	m.insert(m.allocateInstr().asALU(aluOpAdd, sum, x2, shifted4))
+	m.insert(m.allocateInstr().asALU(aluOpAnd, x3, sum, constant(0x0f0f0f0f0f0f0f0f)))
+
+	mul := alloc()
+	m.insert(m.allocateInstr().asALU(aluOpMul, mul, x3, constant(0x0101010101010101)))
+	m.insert(m.allocateInstr().asALUImm(aluOpSrl, rd, mul, 56))
+}
+
+func (m *machine) lowerIDiv(execCtxVReg, rd, rs1, rs2 regalloc.VReg, _64bit, signed bool) {
+	var op aluOp
+	switch {
+	case signed && _64bit:
+		op = aluOpDiv
+	case signed:
+		op = aluOpDivw
+	case _64bit:
+		op = aluOpDivu
+	default:
+		op = aluOpDivuw
+	}
+	// The division itself never traps.
+	m.insert(m.allocateInstr().asALU(op, rd, rs1, rs2))
+
+	// Check if rs2 is zero:
+	m.exitIfNot(execCtxVReg, brCondNe, rs2, zeroVReg, wazevoapi.ExitCodeIntegerDivisionByZero)
+
+	if signed {
+		// We need to check the signed overflow which happens iff "math.MinInt{32,64} / -1":
+		//
+		//	addi plusOne, rs2, 1
+		//	li minInt, #MinInt{32,64}
+		//	xor diff, rs1, minInt
+		//	or overflow, plusOne, diff  ;; zero iff rs2 == -1 and rs1 == MinInt{32,64}.
+		//	bne overflow, zero, #ok
+		plusOne, minInt := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asALUImm(aluOpAdd, plusOne, rs2, 1))
+		if _64bit {
+			m.lowerConstantI64(minInt, math.MinInt64)
+		} else {
+			m.lowerConstantI64(minInt, math.MinInt32)
+		}
+		diff, overflow := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asALU(aluOpXor, diff, rs1, minInt))
+		m.insert(m.allocateInstr().asALU(aluOpOr, overflow, plusOne, diff))
+		m.exitIfNot(execCtxVReg, brCondNe, overflow, zeroVReg, wazevoapi.ExitCodeIntegerOverflow)
+	}
+}
+
+func (m *machine) lowerIRem(execCtxVReg, rd, rs1, rs2 regalloc.VReg, _64bit, signed bool) {
+	var op aluOp
+	switch {
+	case signed && _64bit:
+		op = aluOpRem
+	case signed:
+		op = aluOpRemw
+	case _64bit:
+		op = aluOpRemu
+	default:
+		op = aluOpRemuw
+	}
+	// Note that the remainder of "math.MinInt{32,64} % -1" is zero as required by WebAssembly.
+	m.insert(m.allocateInstr().asALU(op, rd, rs1, rs2))
+
+	// Check if rs2 is zero:
+	m.exitIfNot(execCtxVReg, brCondNe, rs2, zeroVReg, wazevoapi.ExitCodeIntegerDivisionByZero)
+}
+
+// exitIfNot emits a conditional branch to exit with the code unless `rs1 c rs2` holds.
+func (m *machine) exitIfNot(execCtxVReg regalloc.VReg, c brCond, rs1, rs2 regalloc.VReg, code wazevoapi.ExitCode) {
+	execCtxTmp := m.copyToTmp(execCtxVReg)
+
+	cbr := m.allocateInstr()
+	m.insert(cbr)
+	m.lowerExitWithCode(execCtxTmp, code)
+	// Conditional branch target is after exit.
+	l := m.insertBrTargetLabel()
+	cbr.asCondBr(c, rs1, rs2, l)
+}
+
+func (m *machine) lowerFpuBinOp(si *ssa.Instruction) {
+	var op fpuOp
+	switch si.Opcode() {
+	case ssa.OpcodeFadd:
+		op = fpuOpAdd
+	case ssa.OpcodeFsub:
+		op = fpuOpSub
+	case ssa.OpcodeFmul:
+		op = fpuOpMul
+	case ssa.OpcodeFdiv:
+		op = fpuOpDiv
+	}
+	x, y := si.Arg2()
+	rs1, rs2 := m.getOperand(x), m.getOperand(y)
+	rd := m.compiler.VRegOf(si.Return())
+	m.insert(m.allocateInstr().asFpuRRR(op, rd, rs1, rs2, x.Type().Bits() == 64))
+}
+
+func (m *machine) lowerFpuUniOp(op fpuUniOp, in, out ssa.Value) {
+	rs := m.getOperand(in)
+	rd := m.compiler.VRegOf(out)
+	m.insert(m.allocateInstr().asFpuRR(op, rd, rs, in.Type().Bits() == 64))
+}
+
+// lowerFpuSignInjection lowers fneg, fabs and fcopysign, all of which are the sign injection:
+// the result is x with the sign bit computed from the sign bits of x and y.
+func (m *machine) lowerFpuSignInjection(op fpuOp, x, y, ret ssa.Value) {
+	rs1, rs2 := m.getOperand(x), m.getOperand(y)
+	rd := m.compiler.VRegOf(ret)
+	m.insert(m.allocateInstr().asFpuRRR(op, rd, rs1, rs2, x.Type().Bits() == 64))
+}
+
+func (m *machine) lowerFpuRound(rm roundingMode, in, out ssa.Value) {
+	rs := m.getOperand(in)
+	rd := m.compiler.VRegOf(out)
+	m.insert(m.allocateInstr().asFpuRound(rm, rd, rs, in.Type().Bits() == 64))
+}
+
+func (m *machine) lowerBitcast(instr *ssa.Instruction) {
+	v, dstType := instr.BitcastData()
+	srcType := v.Type()
+	rs := m.getOperand(v)
+	rd := m.compiler.VRegOf(instr.Return())
+	srcInt := srcType.IsInt()
+	dstInt := dstType.IsInt()
+	switch {
+	case srcInt && !dstInt: // Int to Float:
+		m.insert(m.allocateInstr().asFpuRR(fpuUniOpMvFromInt, rd, rs, srcType.Bits() == 64))
+	case !srcInt && dstInt: // Float to Int:
+		// Note that fmv.x.w sign-extends the bits, which is the canonical form of i32.
+		m.insert(m.allocateInstr().asFpuRR(fpuUniOpMvToInt, rd, rs, dstType.Bits() == 64))
+	default:
+		panic(fmt.Sprintf("BUG: invalid bitcast from %s to %s", srcType, dstType))
+	}
+}
+
+func (m *machine) lowerFpuToInt(rd, rs, ctx regalloc.VReg, signed, src64bit, dst64bit, nonTrapping bool) {
+	if nonTrapping {
+		// The conversion saturates the out of range values as required by WebAssembly,
+		// except that NaN is converted to the maximum value instead of zero. So we mask the result:
+		//
+		//	fcvt cvt, rs, rtz
+		//	feq notNaN, rs, rs
+		//	sub mask, zero, notNaN  ;; mask = NaN ? 0 : -1
+		//	and rd, cvt, mask
+		cvt, notNaN, mask := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asFpuToInt(cvt, rs, signed, src64bit, dst64bit))
+		m.insert(m.allocateInstr().asFpuCmp(fpuCmpOpEq, notNaN, rs, rs, src64bit))
+		m.insert(m.allocateInstr().asALU(aluOpSub, mask, zeroVReg, notNaN))
+		m.insert(m.allocateInstr().asALU(aluOpAnd, rd, cvt, mask))
+		return
+	}
+
+	// First of all, we have to clear the accrued flags, then do the conversion which doesn't trap inherently.
+	m.insert(m.allocateInstr().asClearFflags())
+	m.insert(m.allocateInstr().asFpuToInt(rd, rs, signed, src64bit, dst64bit))
+
+	// After the conversion, check the invalid operation flag which is set iff the input is NaN or out of range.
+	flags, invalid := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+	m.insert(m.allocateInstr().asReadFflags(flags))
+	m.insert(m.allocateInstr().asALUImm(aluOpAnd, invalid, flags, fflagsNV))
+
+	execCtx := m.copyToTmp(ctx)
+	rsTmp := m.copyToTmp(rs)
+
+	// If it is not invalid, we can return the result.
+	ok := m.allocateInstr()
+	m.insert(ok)
+
+	// Otherwise, we have to choose the exit code depending on it is overflow or NaN conversion.
+	notNaN := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.insert(m.allocateInstr().asFpuCmp(fpuCmpOpEq, notNaN, rsTmp, rsTmp, src64bit))
+	m.exitIfNot(execCtx, brCondNe, notNaN, zeroVReg, wazevoapi.ExitCodeInvalidConversionToInteger)
+	// Otherwise, it is an overflow.
+	m.lowerExitWithCode(execCtx, wazevoapi.ExitCodeIntegerOverflow)
+
+	// Conditional branch target is after exit.
+	l := m.insertBrTargetLabel()
+	ok.asCondBr(brCondEq, invalid, zeroVReg, l)
+}
+
+func (m *machine) lowerExitWithCode(execCtxVReg regalloc.VReg, code wazevoapi.ExitCode) {
+	//	li tmp1, #code
+	//	sw tmp1, #ExitCode(execCtx)
+	tmpReg1 := m.compiler.AllocateVReg(ssa.TypeI32)
+	m.lowerConstantI64(tmpReg1, int64(code))
+	setExitCode := m.allocateInstr()
+	setExitCode.asStore(memOpSw, tmpReg1, addressMode{
+		kind: addressModeKindRegImm,
+		base: execCtxVReg, imm: wazevoapi.ExecutionContextOffsetExitCodeOffset.I64(),
+	})
+	m.insert(setExitCode)
+
+	// In order to unwind the stack, we also need to push the current stack pointer:
+	//	sd sp, #StackPointerBeforeGoCall(execCtx)
+	strSpToExecCtx := m.allocateInstr()
+	strSpToExecCtx.asStore(memOpSd, spVReg, addressMode{
+		kind: addressModeKindRegImm,
+		base: execCtxVReg, imm: wazevoapi.ExecutionContextOffsetStackPointerBeforeGoCall.I64(),
+	})
+	m.insert(strSpToExecCtx)
+
+	// Also the address of this exit.
+	tmp3 := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.insert(m.allocateInstr().asAdr(tmp3, 0))
+	storeCurrentAddrToExecCtx := m.allocateInstr()
+	storeCurrentAddrToExecCtx.asStore(memOpSd, tmp3, addressMode{
+		kind: addressModeKindRegImm,
+		base: execCtxVReg, imm: wazevoapi.ExecutionContextOffsetGoCallReturnAddress.I64(),
+	})
+	m.insert(storeCurrentAddrToExecCtx)
+
+	m.insert(m.allocateInstr().asExitSequence(execCtxVReg))
+}
+
+func (m *machine) lowerExitIfTrueWithCode(execCtxVReg regalloc.VReg, c ssa.Value, code wazevoapi.ExitCode) {
+	var cond brCond
+	var rs1, rs2 regalloc.VReg
+	if condDef := m.compiler.ValueDefinition(c); m.compiler.MatchInstr(condDef, ssa.OpcodeIcmp) {
+		x, y, icmpCond := condDef.Instr.IcmpData()
+		cond, rs1, rs2 = m.lowerIcmpToBrCond(x, y, icmpCond)
+		condDef.Instr.MarkLowered()
+	} else {
+		cond, rs1, rs2 = brCondNe, m.getOperand(c), zeroVReg
+	}
+
+	// We have to skip the entire exit sequence if the condition is false.
+	m.exitIfNot(execCtxVReg, cond.invert(), rs1, rs2, code)
+}
+
+func (m *machine) lowerAtomicRmw(si *ssa.Instruction) {
+	ssaOp, size := si.AtomicRmwData()
+	addr, val := si.Arg2()
+	rs1, rs2 := m.getOperand(addr), m.getOperand(val)
+	ret := si.Return()
+	rd := m.compiler.VRegOf(ret)
+
+	if size == 1 || size == 2 {
+		var op atomicRmwMaskedOp
+		switch ssaOp {
+		case ssa.AtomicRmwOpAdd:
+			op = atomicRmwMaskedOpAdd
+		case ssa.AtomicRmwOpSub:
+			op = atomicRmwMaskedOpSub
+		case ssa.AtomicRmwOpAnd:
+			op = atomicRmwMaskedOpAnd
+		case ssa.AtomicRmwOpOr:
+			op = atomicRmwMaskedOpOr
+		case ssa.AtomicRmwOpXor:
+			op = atomicRmwMaskedOpXor
+		case ssa.AtomicRmwOpXchg:
+			op = atomicRmwMaskedOpXchg
+		default:
+			panic(fmt.Sprintf("unknown ssa atomic rmw op: %s", ssaOp))
+		}
+
+		// The bits outside the target are not modified by the sequence, so val doesn't need to be masked.
+		aligned, shift, mask := m.lowerAtomicMaskedAddress(rs1, size)
+		shiftedVal, loaded := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asALU(aluOpSll, shiftedVal, rs2, shift))
+		scratch := m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asDefineUninitializedReg(scratch))
+		m.insert(m.allocateInstr().asAtomicRmwMasked(op, loaded, aligned, shiftedVal, mask, scratch))
+		m.insert(m.allocateInstr().asALU(aluOpSrl, rd, loaded, shift))
+		return
+	}
+
+	var op amoOp
+	switch ssaOp {
+	case ssa.AtomicRmwOpAdd:
+		op = amoOpAdd
+	case ssa.AtomicRmwOpSub:
+		op = amoOpAdd
+		neg := m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asALU(aluOpSub, neg, zeroVReg, rs2))
+		rs2 = neg
+	case ssa.AtomicRmwOpAnd:
+		op = amoOpAnd
+	case ssa.AtomicRmwOpOr:
+		op = amoOpOr
+	case ssa.AtomicRmwOpXor:
+		op = amoOpXor
+	case ssa.AtomicRmwOpXchg:
+		op = amoOpSwap
+	default:
+		panic(fmt.Sprintf("unknown ssa atomic rmw op: %s", ssaOp))
+	}
+
+	if size == 4 && ret.Type() == ssa.TypeI64 {
+		// The 32-bit amo sign-extends the loaded value, so we have to zero-extend it.
+		loaded := m.compiler.AllocateVReg(ssa.TypeI64)
+		m.insert(m.allocateInstr().asAmo(op, loaded, rs1, rs2, size))
+		m.lowerExtend(loaded, rd, 32, 64, false)
+	} else {
+		m.insert(m.allocateInstr().asAmo(op, rd, rs1, rs2, size))
+	}
+}
+
+// lowerAtomicMaskedAddress computes the address of the aligned 32-bit word containing the 1 or 2 byte target at addr,
+// the bit offset of the target in the word, and the mask selecting the target bits in the word.
+func (m *machine) lowerAtomicMaskedAddress(addr regalloc.VReg, size uint64) (aligned, shift, mask regalloc.VReg) {
+	//	andi aligned, addr, -4
+	//	andi offset, addr, 3
+	//	slli shift, offset, 3
+	//	li sizeMask, #0xff or #0xffff
+	//	sll mask, sizeMask, shift
+	aligned, shift, mask = m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+	offset := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.insert(m.allocateInstr().asALUImm(aluOpAnd, aligned, addr, -4))
+	m.insert(m.allocateInstr().asALUImm(aluOpAnd, offset, addr, 3))
+	m.insert(m.allocateInstr().asALUImm(aluOpSll, shift, offset, 3))
+	sizeMask := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.lowerConstantI64(sizeMask, int64(1)<<(size*8)-1)
+	m.insert(m.allocateInstr().asALU(aluOpSll, mask, sizeMask, shift))
+	return
+}
+
+func (m *machine) lowerAtomicCas(si *ssa.Instruction) {
+	addr, exp, repl := si.Arg3()
+	size := si.AtomicTargetSize()
+	rs1, expReg, replReg := m.getOperand(addr), m.getOperand(exp), m.getOperand(repl)
+	ret := si.Return()
+	rd := m.compiler.VRegOf(ret)
+	scratch := m.compiler.AllocateVReg(ssa.TypeI64)
+
+	if size == 1 || size == 2 {
+		aligned, shift, mask := m.lowerAtomicMaskedAddress(rs1, size)
+		// Both the expected and replacement values must be masked and shifted to the target bits.
+		sizeMask := int64(1)<<(size*8) - 1
+		maskedExp, maskedRepl := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+		shiftedExp, shiftedRepl := m.compiler.AllocateVReg(ssa.TypeI64), m.compiler.AllocateVReg(ssa.TypeI64)
+		if size == 1 {
+			m.insert(m.allocateInstr().asALUImm(aluOpAnd, maskedExp, expReg, sizeMask))
+			m.insert(m.allocateInstr().asALUImm(aluOpAnd, maskedRepl, replReg, sizeMask))
+		} else {
+			m.lowerExtend(expReg, maskedExp, 16, 64, false)
+			m.lowerExtend(replReg, maskedRepl, 16, 64, false)
+		}
+		m.insert(m.allocateInstr().asALU(aluOpSll, shiftedExp, maskedExp, shift))
+		m.insert(m.allocateInstr().asALU(aluOpSll, shiftedRepl, maskedRepl, shift))
+
+		m.insert(m.allocateInstr().asDefineUninitializedReg(scratch))
+		// shiftedExp is overwritten by the loaded value, and it is not used anywhere else.
+		m.insert(m.allocateInstr().asAtomicCasMasked(shiftedExp, aligned, shiftedRepl, mask, scratch))
+		m.insert(m.allocateInstr().asALU(aluOpSrl, rd, shiftedExp, shift))
+		return
+	}
+
+	// The expected value is overwritten by the loaded value, so we need to copy it to the temporary register
+	// in case when it is used somewhere else. For the 32-bit target, the copy is the sign-extension
+	// since the loaded value is sign-extended.
+	tmp := m.compiler.AllocateVReg(ssa.TypeI64)
+	if size == 4 {
+		m.insert(m.allocateInstr().asALUImm(aluOpAddw, tmp, expReg, 0))
+	} else {
+		m.insert(m.allocateInstr().asMove64(tmp, expReg))
+	}
+	m.insert(m.allocateInstr().asDefineUninitializedReg(scratch))
+	m.insert(m.allocateInstr().asAtomicCas(tmp, rs1, replReg, scratch, size))
+	if size == 4 && ret.Type() == ssa.TypeI64 {
+		m.lowerExtend(tmp, rd, 32, 64, false)
+	} else {
+		m.insert(m.allocateInstr().asMove64(rd, tmp))
+	}
+}
+
+// lowerAtomicLoad lowers the sequentially consistent load as `fence rw,rw; load; fence r,rw`.
+func (m *machine) lowerAtomicLoad(si *ssa.Instruction) {
+	addr := si.Arg()
+	size := si.AtomicTargetSize()
+	ret := si.Return()
+
+	var op memOp
+	switch size {
+	case 1:
+		op = memOpLbu
+	case 2:
+		op = memOpLhu
+	case 4:
+		if ret.Type() == ssa.TypeI64 {
+			op = memOpLwu
+		} else {
+			op = memOpLw
+		}
+	case 8:
+		op = memOpLd
+	default:
+		panic(fmt.Sprintf("BUG: unsupported atomic load size %d", size))
+	}
+
+	rs := m.getOperand(addr)
+	m.insert(m.allocateInstr().asFence(fenceRW, fenceRW))
+	ld := m.allocateInstr()
+	ld.asLoad(op, m.compiler.VRegOf(ret), addressMode{kind: addressModeKindRegImm, base: rs})
+	m.insert(ld)
+	m.insert(m.allocateInstr().asFence(fenceR, fenceRW))
+}
+
+// lowerAtomicStore lowers the sequentially consistent store as `fence rw,w; store`.
+func (m *machine) lowerAtomicStore(si *ssa.Instruction) {
+	addr, val := si.Arg2()
+	size := si.AtomicTargetSize()
+
+	var op memOp
+	switch size {
+	case 1:
+		op = memOpSb
+	case 2:
+		op = memOpSh
+	case 4:
+		op = memOpSw
+	case 8:
+		op = memOpSd
+	default:
+		panic(fmt.Sprintf("BUG: unsupported atomic store size %d", size))
+	}
+
+	rs1, rs2 := m.getOperand(addr), m.getOperand(val)
+	m.insert(m.allocateInstr().asFence(fenceRW, fenceW))
+	st := m.allocateInstr()
+	st.asStore(op, rs2, addressMode{kind: addressModeKindRegImm, base: rs1})
+	m.insert(st)
+}
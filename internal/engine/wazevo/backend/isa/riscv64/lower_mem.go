@@ -0,0 +1,59 @@
+package riscv64
+
+import (
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+)
+
+// lowerToAddressMode converts a pointer and the offset to an addressing mode. The offset not fitting in the signed
+// 12-bit is handled at the encoding time with the temporary register. See encodeLoadOrStore.
+func (m *machine) lowerToAddressMode(ptr ssa.Value, offset uint32) addressMode {
+	return addressMode{kind: addressModeKindRegImm, base: m.getOperand(ptr), imm: int64(offset)}
+}
+
+func (m *machine) lowerLoad(ptr ssa.Value, offset uint32, op memOp, ret ssa.Value) {
+	load := m.allocateInstr()
+	load.asLoad(op, m.compiler.VRegOf(ret), m.lowerToAddressMode(ptr, offset))
+	m.insert(load)
+}
+
+// extLoadOp returns the memOp for the extending loads. Note that the zero-extended 8-bit and 16-bit values
+// are valid as sign-extended 32-bit values, so the same instruction is used regardless of the result type.
+func extLoadOp(op ssa.Opcode) memOp {
+	switch op {
+	case ssa.OpcodeUload8:
+		return memOpLbu
+	case ssa.OpcodeUload16:
+		return memOpLhu
+	case ssa.OpcodeUload32:
+		return memOpLwu
+	case ssa.OpcodeSload8:
+		return memOpLb
+	case ssa.OpcodeSload16:
+		return memOpLh
+	case ssa.OpcodeSload32:
+		return memOpLw
+	default:
+		panic("BUG: unexpected opcode " + op.String())
+	}
+}
+
+func (m *machine) lowerStore(si *ssa.Instruction) {
+	value, ptr, offset, _ := si.StoreData()
+
+	var op memOp
+	switch si.Opcode() {
+	case ssa.OpcodeIstore8:
+		op = memOpSb
+	case ssa.OpcodeIstore16:
+		op = memOpSh
+	case ssa.OpcodeIstore32:
+		op = memOpSw
+	default:
+		op = storeOpFor(value.Type())
+	}
+
+	rs := m.getOperand(value)
+	store := m.allocateInstr()
+	store.asStore(op, rs, m.lowerToAddressMode(ptr, offset))
+	m.insert(store)
+}
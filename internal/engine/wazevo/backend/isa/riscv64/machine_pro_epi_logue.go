@@ -0,0 +1,442 @@
+package riscv64
+
+import (
+	"fmt"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// PostRegAlloc implements backend.Machine.
+func (m *machine) PostRegAlloc() {
+	m.setupPrologue()
+	m.postRegAlloc()
+}
+
+// setupPrologue initializes the prologue of the function.
+func (m *machine) setupPrologue() {
+	cur := m.rootInstr
+	prevInitInst := cur.next
+
+	//
+	//                   (high address)                    (high address)
+	//         SP----> +-----------------+               +------------------+ <----+
+	//                 |     .......     |               |     .......      |      |
+	//                 |      ret Y      |               |      ret Y       |      |
+	//                 |     .......     |               |     .......      |      |
+	//                 |      ret 0      |               |      ret 0       |      |
+	//                 |      arg X      |               |      arg X       |      |  size_of_arg_ret.
+	//                 |     .......     |     ====>     |     .......      |      |
+	//                 |      arg 1      |               |      arg 1       |      |
+	//                 |      arg 0      |               |      arg 0       | <----+
+	//                 |-----------------|               |  size_of_arg_ret |
+	//                                                   |  return address  |
+	//                                                   +------------------+ <---- SP
+	//                    (low address)                     (low address)
+
+	// Saves the return address (ra) and the size_of_arg_ret below the SP.
+	// size_of_arg_ret is used for stack unwinding.
+	cur = m.createReturnAddrAndSizeOfArgRetSlot(cur)
+
+	if !m.stackBoundsCheckDisabled {
+		cur = m.insertStackBoundsCheck(m.requiredStackSize(), cur)
+	}
+
+	// Decrement SP if spillSlotSize > 0.
+	if m.spillSlotSize == 0 && len(m.spillSlots) != 0 {
+		panic(fmt.Sprintf("BUG: spillSlotSize=%d, spillSlots=%v\n", m.spillSlotSize, m.spillSlots))
+	}
+
+	if regs := m.clobberedRegs; len(regs) > 0 {
+		//
+		//            (high address)                  (high address)
+		//          +-----------------+             +-----------------+
+		//          |     .......     |             |     .......     |
+		//          |      ret Y      |             |      ret Y      |
+		//          |     .......     |             |     .......     |
+		//          |      ret 0      |             |      ret 0      |
+		//          |      arg X      |             |      arg X      |
+		//          |     .......     |             |     .......     |
+		//          |      arg 1      |             |      arg 1      |
+		//          |      arg 0      |             |      arg 0      |
+		//          | size_of_arg_ret |             | size_of_arg_ret |
+		//          |   ReturnAddress |             |  ReturnAddress  |
+		//  SP----> +-----------------+    ====>    +-----------------+
+		//             (low address)                |   clobbered 0   |
+		//                                          |   ............  |
+		//                                          |   clobbered M   |
+		//                                          +-----------------+ <----- SP
+		//                                             (low address)
+		//
+		cur = m.addsAddOrSubStackPointer(cur, spVReg, m.clobberedRegSlotSize(), false)
+		for i, vr := range regs {
+			store := m.allocateInstr()
+			store.asStore(storeOpForRegType(vr.RegType()), vr,
+				// Each register occupies 16 bytes, so that the stack pointer is kept 16-byte aligned.
+				addressMode{kind: addressModeKindRegImm, base: spVReg, imm: int64(len(regs)-1-i) * 16})
+			cur = linkInstr(cur, store)
+		}
+	}
+
+	if size := m.spillSlotSize; size > 0 {
+		// Check if size is 16-byte aligned.
+		if size&0xf != 0 {
+			panic(fmt.Errorf("BUG: spill slot size %d is not 16-byte aligned", size))
+		}
+
+		cur = m.addsAddOrSubStackPointer(cur, spVReg, size, false)
+
+		// At this point, the stack looks like:
+		//
+		//            (high address)
+		//          +------------------+
+		//          |     .......      |
+		//          |      ret Y       |
+		//          |     .......      |
+		//          |      ret 0       |
+		//          |      arg X       |
+		//          |     .......      |
+		//          |      arg 1       |
+		//          |      arg 0       |
+		//          |  size_of_arg_ret |
+		//          |   ReturnAddress  |
+		//          +------------------+
+		//          |    clobbered 0   |
+		//          |   ............   |
+		//          |    clobbered M   |
+		//          |   spill slot N   |
+		//          |   ............   |
+		//          |   spill slot 2   |
+		//          |   spill slot 0   |
+		//  SP----> +------------------+
+		//             (low address)
+	}
+
+	// We push the frame size into the stack to make it possible to unwind stack:
+	//
+	//
+	//            (high address)                  (high address)
+	//         +-----------------+                +-----------------+
+	//         |     .......     |                |     .......     |
+	//         |      ret Y      |                |      ret Y      |
+	//         |     .......     |                |     .......     |
+	//         |      ret 0      |                |      ret 0      |
+	//         |      arg X      |                |      arg X      |
+	//         |     .......     |                |     .......     |
+	//         |      arg 1      |                |      arg 1      |
+	//         |      arg 0      |                |      arg 0      |
+	//         | size_of_arg_ret |                | size_of_arg_ret |
+	//         |  ReturnAddress  |                |  ReturnAddress  |
+	//         +-----------------+      ==>       +-----------------+ <----+
+	//         |   clobbered  0  |                |   clobbered  0  |      |
+	//         |   ............  |                |   ............  |      |
+	//         |   clobbered  M  |                |   clobbered  M  |      | frame size
+	//         |   spill slot N  |                |   spill slot N  |      |
+	//         |   ............  |                |   ............  |      |
+	//         |   spill slot 0  |                |   spill slot 0  | <----+
+	// SP--->  +-----------------+                |     xxxxxx      |  ;; unused space to make it 16-byte aligned.
+	//                                            |   frame_size    |
+	//                                            +-----------------+ <---- SP
+	//            (low address)
+	//
+	cur = m.createFrameSizeSlot(cur, m.frameSize())
+
+	linkInstr(cur, prevInitInst)
+}
+
+func (m *machine) createReturnAddrAndSizeOfArgRetSlot(cur *instruction) *instruction {
+	// First we decrement the stack pointer to point the arg0 slot.
+	var sizeOfArgRetReg regalloc.VReg
+	s := int64(m.currentABI.AlignedArgResultStackSlotSize())
+	if s > 0 {
+		cur = m.addsAddOrSubStackPointer(cur, spVReg, s, false)
+		cur = m.lowerConstantI64AndInsert(cur, tmpVReg, s)
+		sizeOfArgRetReg = tmpVReg
+	} else {
+		sizeOfArgRetReg = zeroVReg
+	}
+
+	// Saves the return address (ra) and the size_of_arg_ret below the SP.
+	// size_of_arg_ret is used for stack unwinding.
+	cur = m.addsAddOrSubStackPointer(cur, spVReg, 16, false)
+	storeRa := m.allocateInstr()
+	storeRa.asStore(memOpSd, raVReg, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: 0})
+	cur = linkInstr(cur, storeRa)
+	storeSize := m.allocateInstr()
+	storeSize.asStore(memOpSd, sizeOfArgRetReg, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: 8})
+	cur = linkInstr(cur, storeSize)
+	return cur
+}
+
+func (m *machine) createFrameSizeSlot(cur *instruction, s int64) *instruction {
+	var frameSizeReg regalloc.VReg
+	if s > 0 {
+		cur = m.lowerConstantI64AndInsert(cur, tmpVReg, s)
+		frameSizeReg = tmpVReg
+	} else {
+		frameSizeReg = zeroVReg
+	}
+	cur = m.addsAddOrSubStackPointer(cur, spVReg, 16, false)
+	store := m.allocateInstr()
+	store.asStore(memOpSd, frameSizeReg, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: 0})
+	cur = linkInstr(cur, store)
+	return cur
+}
+
+// postRegAlloc does multiple things while walking through the instructions:
+// 1. Removes the redundant copy instruction.
+// 2. Inserts the epilogue.
+func (m *machine) postRegAlloc() {
+	for cur := m.rootInstr; cur != nil; cur = cur.next {
+		switch cur.kind {
+		case ret, tailCall, tailCallInd:
+			m.setupEpilogueAfter(cur.prev)
+		case loadConstBlockArg:
+			lc := cur
+			next := lc.next
+			m.pendingInstructions = m.pendingInstructions[:0]
+			m.lowerLoadConstantBlockArgAfterRegAlloc(lc)
+			for _, instr := range m.pendingInstructions {
+				cur = linkInstr(cur, instr)
+			}
+			linkInstr(cur, next)
+			m.pendingInstructions = m.pendingInstructions[:0]
+		default:
+			// Removes the redundant copy instruction.
+			if cur.IsCopy() && cur.rs1.RealReg() == cur.rd.RealReg() {
+				prev, next := cur.prev, cur.next
+				// Remove the copy instruction.
+				prev.next = next
+				if next != nil {
+					next.prev = prev
+				}
+			}
+		}
+	}
+}
+
+func (m *machine) setupEpilogueAfter(cur *instruction) {
+	prevNext := cur.next
+
+	// We've stored the frame size in the prologue, and now that we are about to return from this function, we won't need it anymore.
+	cur = m.addsAddOrSubStackPointer(cur, spVReg, 16, true)
+
+	if s := m.spillSlotSize; s > 0 {
+		// Adjust SP to the original value:
+		//
+		//            (high address)                        (high address)
+		//          +-----------------+                  +-----------------+
+		//          |     .......     |                  |     .......     |
+		//          |      ret Y      |                  |      ret Y      |
+		//          |     .......     |                  |     .......     |
+		//          |      ret 0      |                  |      ret 0      |
+		//          |      arg X      |                  |      arg X      |
+		//          |     .......     |                  |     .......     |
+		//          |      arg 1      |                  |      arg 1      |
+		//          |      arg 0      |                  |      arg 0      |
+		//          |      xxxxx      |                  |      xxxxx      |
+		//          |   ReturnAddress |                  |   ReturnAddress |
+		//          +-----------------+      ====>       +-----------------+
+		//          |    clobbered 0  |                  |    clobbered 0  |
+		//          |   ............  |                  |   ............  |
+		//          |    clobbered M  |                  |    clobbered M  |
+		//          |   spill slot N  |                  +-----------------+ <---- SP
+		//          |   ............  |
+		//          |   spill slot 0  |
+		//   SP---> +-----------------+
+		//             (low address)
+		//
+		cur = m.addsAddOrSubStackPointer(cur, spVReg, s, true)
+	}
+
+	// First we need to restore the clobbered registers.
+	if regs := m.clobberedRegs; len(regs) > 0 {
+		//            (high address)
+		//          +-----------------+                      +-----------------+
+		//          |     .......     |                      |     .......     |
+		//          |      ret Y      |                      |      ret Y      |
+		//          |     .......     |                      |     .......     |
+		//          |      ret 0      |                      |      ret 0      |
+		//          |      arg X      |                      |      arg X      |
+		//          |     .......     |                      |     .......     |
+		//          |      arg 1      |                      |      arg 1      |
+		//          |      arg 0      |                      |      arg 0      |
+		//          |      xxxxx      |                      |      xxxxx      |
+		//          |   ReturnAddress |                      |   ReturnAddress |
+		//          +-----------------+      ========>       +-----------------+ <---- SP
+		//          |   clobbered 0   |
+		//          |   ...........   |
+		//          |   clobbered M   |
+		//   SP---> +-----------------+
+		//             (low address)
+
+		l := len(regs) - 1
+		for i := range regs {
+			vr := regs[l-i] // reverse order to restore.
+			load := m.allocateInstr()
+			load.asLoad(loadOpForRegType(vr.RegType()), vr, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: int64(i) * 16})
+			cur = linkInstr(cur, load)
+		}
+		cur = m.addsAddOrSubStackPointer(cur, spVReg, m.clobberedRegSlotSize(), true)
+	}
+
+	// Reload the return address (ra).
+	//
+	//            +-----------------+          +-----------------+
+	//            |     .......     |          |     .......     |
+	//            |      ret Y      |          |      ret Y      |
+	//            |     .......     |          |     .......     |
+	//            |      ret 0      |          |      ret 0      |
+	//            |      arg X      |          |      arg X      |
+	//            |     .......     |   ===>   |     .......     |
+	//            |      arg 1      |          |      arg 1      |
+	//            |      arg 0      |          |      arg 0      |
+	//            |      xxxxx      |          +-----------------+ <---- SP
+	//            |  ReturnAddress  |
+	//    SP----> +-----------------+
+
+	ld := m.allocateInstr()
+	ld.asLoad(memOpLd, raVReg, addressMode{kind: addressModeKindRegImm, base: spVReg, imm: 0})
+	cur = linkInstr(cur, ld)
+	cur = m.addsAddOrSubStackPointer(cur, spVReg, 16, true)
+
+	if s := int64(m.currentABI.AlignedArgResultStackSlotSize()); s > 0 {
+		cur = m.addsAddOrSubStackPointer(cur, spVReg, s, true)
+	}
+
+	linkInstr(cur, prevNext)
+}
+
+// saveRequiredRegs is the set of registers that must be saved/restored during growing stack when there's insufficient
+// stack space left. Basically this is the combination of CalleeSavedRegisters plus argument registers except for x10,
+// which always points to the execution context whenever the native code is entered from Go.
+var saveRequiredRegs = []regalloc.VReg{
+	x11VReg, x12VReg, x13VReg, x14VReg, x15VReg, x16VReg, x17VReg,
+	x8VReg, x9VReg, x18VReg, x19VReg, x20VReg, x21VReg, x22VReg, x23VReg, x24VReg, x25VReg, x26VReg, raVReg,
+	f10VReg, f11VReg, f12VReg, f13VReg, f14VReg, f15VReg, f16VReg, f17VReg,
+	f8VReg, f9VReg, f18VReg, f19VReg, f20VReg, f21VReg, f22VReg, f23VReg, f24VReg, f25VReg, f26VReg, f27VReg,
+}
+
+// insertStackBoundsCheck will insert the instructions after `cur` to check the
+// stack bounds, and if there's no sufficient spaces required for the function,
+// exit the execution and try growing it in Go world.
+//
+// TODO: we should be able to share the instructions across all the functions to reduce the size of compiled executable.
+func (m *machine) insertStackBoundsCheck(requiredStackSize int64, cur *instruction) *instruction {
+	if requiredStackSize%16 != 0 {
+		panic("BUG")
+	}
+
+	// tmp = sp - requiredStackSize
+	cur = m.addsAddOrSubStackPointer(cur, tmpVReg, requiredStackSize, false)
+
+	tmp2 := x5VReg // Caller save and not used for arguments, so it is safe to use it here in the prologue.
+
+	// ld tmp2, #StackBottomPtr(executionContext)
+	ld := m.allocateInstr()
+	ld.asLoad(memOpLd, tmp2, addressMode{
+		kind: addressModeKindRegImm,
+		base: x10VReg, // execution context is always the first argument.
+		imm:  wazevoapi.ExecutionContextOffsetStackBottomPtr.I64(),
+	})
+	cur = linkInstr(cur, ld)
+
+	// bge tmp, tmp2, #imm
+	cbr := m.allocateInstr()
+	cbr.asCondBr(brCondGe, tmpVReg, tmp2, labelInvalid)
+	cur = linkInstr(cur, cbr)
+
+	// Set the required stack size and set it to the exec context.
+	{
+		// First load the requiredStackSize into the temporary register,
+		cur = m.lowerConstantI64AndInsert(cur, tmpVReg, requiredStackSize)
+		setRequiredStackSize := m.allocateInstr()
+		setRequiredStackSize.asStore(memOpSd, tmpVReg, addressMode{
+			kind: addressModeKindRegImm,
+			// Execution context is always the first argument.
+			base: x10VReg, imm: wazevoapi.ExecutionContextOffsetStackGrowRequiredSize.I64(),
+		})
+		cur = linkInstr(cur, setRequiredStackSize)
+	}
+
+	ldAddress := m.allocateInstr()
+	ldAddress.asLoad(memOpLd, tmpVReg, addressMode{
+		kind: addressModeKindRegImm,
+		base: x10VReg, // execution context is always the first argument
+		imm:  wazevoapi.ExecutionContextOffsetStackGrowCallTrampolineAddress.I64(),
+	})
+	cur = linkInstr(cur, ldAddress)
+
+	// Then jumps to the stack grow call sequence's address, meaning
+	// transferring the control to the code compiled by CompileStackGrowCallSequence.
+	call := m.allocateInstr()
+	call.asCallIndirect(tmpVReg, nil)
+	cur = linkInstr(cur, call)
+
+	// Now that we know the entire code, we can finalize how many bytes
+	// we have to skip when the stack size is sufficient.
+	var cbrOffset int64
+	for _cur := cbr; ; _cur = _cur.next {
+		cbrOffset += _cur.size()
+		if _cur == cur {
+			break
+		}
+	}
+	cbr.condBrOffsetResolve(cbrOffset)
+	return cur
+}
+
+// CompileStackGrowCallSequence implements backend.Machine.
+func (m *machine) CompileStackGrowCallSequence() []byte {
+	cur := m.allocateInstr()
+	cur.asNop0()
+	m.rootInstr = cur
+
+	// Save the callee saved and argument registers.
+	cur = m.saveRegistersInExecutionContext(cur, saveRequiredRegs)
+
+	// Save the current stack pointer.
+	cur = m.saveCurrentStackPointer(cur, x10VReg)
+
+	// Set the exit status on the execution context.
+	cur = m.setExitCode(cur, x10VReg, wazevoapi.ExitCodeGrowStack)
+
+	// Exit the execution.
+	cur = m.storeReturnAddressAndExit(cur)
+
+	// After the exit, restore the saved registers.
+	cur = m.restoreRegistersInExecutionContext(cur, saveRequiredRegs)
+
+	// Then goes back the original address of this stack grow call.
+	ret := m.allocateInstr()
+	ret.asRet()
+	linkInstr(cur, ret)
+
+	m.encode(m.rootInstr)
+	return m.compiler.Buf()
+}
+
+func (m *machine) addsAddOrSubStackPointer(cur *instruction, rd regalloc.VReg, diff int64, add bool) *instruction {
+	m.pendingInstructions = m.pendingInstructions[:0]
+	m.insertAddOrSubStackPointer(rd, diff, add)
+	for _, inserted := range m.pendingInstructions {
+		cur = linkInstr(cur, inserted)
+	}
+	return cur
+}
+
+// loadOpForRegType returns the memOp to load the entire 64-bit register of the given type.
+func loadOpForRegType(typ regalloc.RegType) memOp {
+	if typ == regalloc.RegTypeInt {
+		return memOpLd
+	}
+	return memOpFld
+}
+
+// storeOpForRegType returns the memOp to store the entire 64-bit register of the given type.
+func storeOpForRegType(typ regalloc.RegType) memOp {
+	if typ == regalloc.RegTypeInt {
+		return memOpSd
+	}
+	return memOpFsd
+}
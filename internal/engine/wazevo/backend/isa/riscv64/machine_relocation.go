@@ -0,0 +1,44 @@
+package riscv64
+
+import (
+	"encoding/binary"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
+)
+
+// maxFunctionExecutableSize is the maximum size of a function. Since the calls are encoded as auipc+jalr pairs
+// reaching ±2GiB, we don't need the trampoline islands as in arm64, and this is rather to keep the branch offsets
+// within a single function far from the limit.
+const maxFunctionExecutableSize = 1 << 30
+
+// CallTrampolineIslandInfo implements backend.Machine CallTrampolineIslandInfo.
+func (m *machine) CallTrampolineIslandInfo(_ int) (_, _ int, _ error) { return }
+
+// ResolveRelocations implements backend.Machine ResolveRelocations.
+func (m *machine) ResolveRelocations(
+	refToBinaryOffset []int,
+	_ int,
+	executable []byte,
+	relocations []backend.RelocationInfo,
+	_ []int,
+) {
+	for _, r := range relocations {
+		instrOffset := r.Offset
+		calleeFnOffset := refToBinaryOffset[r.FuncRef]
+		diff := int64(calleeFnOffset) - instrOffset
+		if !fitsInAuipcRange(diff) {
+			panic("BUG: too large executable that requires the call larger than 32-bit range")
+		}
+		patchAuipcJalr(executable[instrOffset:instrOffset+8], diff)
+	}
+}
+
+// patchAuipcJalr patches the immediates of the auipc+jalr pair emitted as the placeholder for call or tailCall so that
+// it jumps to the given offset relative to the auipc. The registers are preserved.
+func patchAuipcJalr(b []byte, offset int64) {
+	hi, lo := splitHiLo(offset)
+	auipc := binary.LittleEndian.Uint32(b[0:])
+	binary.LittleEndian.PutUint32(b[0:], auipc&0xfff|uint32(hi&0xfffff)<<12)
+	jalr := binary.LittleEndian.Uint32(b[4:])
+	binary.LittleEndian.PutUint32(b[4:], jalr&0xfffff|uint32(lo&0xfff)<<20)
+}
@@ -0,0 +1,25 @@
+package riscv64
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func Test_patchAuipcJalr(t *testing.T) {
+	for _, offset := range []int64{0, 8, -8, 2047, 2048, -2048, -2049, 0x12345678, -0x12345678, 0x7ffff7ff, -0x80000000} {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint32(b[0:], encodeU(opcodeAuipc, regNumberInEncoding(ra), 0))
+		binary.LittleEndian.PutUint32(b[4:], encodeJalr(regNumberInEncoding(ra), regNumberInEncoding(ra), 0))
+		patchAuipcJalr(b, offset)
+
+		auipc, jalr := binary.LittleEndian.Uint32(b[0:]), binary.LittleEndian.Uint32(b[4:])
+		// The registers must be preserved.
+		require.Equal(t, encodeU(opcodeAuipc, regNumberInEncoding(ra), 0), auipc&0xfff)
+		require.Equal(t, encodeJalr(regNumberInEncoding(ra), regNumberInEncoding(ra), 0), jalr&0xfffff)
+		// auipc adds the sign-extended upper 20 bits, and jalr adds the sign-extended lower 12 bits.
+		actual := int64(int32(auipc&0xfffff000)) + int64(int32(jalr)>>20)
+		require.Equal(t, offset, actual)
+	}
+}
@@ -0,0 +1,264 @@
+package riscv64
+
+import (
+	"fmt"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
+)
+
+// RISC-V specific registers.
+//
+// See https://github.com/riscv-non-isa/riscv-elf-psabi-doc/blob/master/riscv-cc.adoc#register-convention
+
+const (
+	// Integer registers. Note that we do not distinguish 32-bit and 64-bit views of the registers
+	// because they are the same from the perspective of register allocator, and
+	// the size can be determined by the type of the instruction.
+
+	x0 = regalloc.RealRegInvalid + 1 + iota
+	x1
+	x2
+	x3
+	x4
+	x5
+	x6
+	x7
+	x8
+	x9
+	x10
+	x11
+	x12
+	x13
+	x14
+	x15
+	x16
+	x17
+	x18
+	x19
+	x20
+	x21
+	x22
+	x23
+	x24
+	x25
+	x26
+	x27
+	x28
+	x29
+	x30
+	x31
+
+	// Floating point registers. Note that we do not distinguish single and double precision
+	// views of the registers, as the size can be determined by the type of the instruction.
+
+	f0
+	f1
+	f2
+	f3
+	f4
+	f5
+	f6
+	f7
+	f8
+	f9
+	f10
+	f11
+	f12
+	f13
+	f14
+	f15
+	f16
+	f17
+	f18
+	f19
+	f20
+	f21
+	f22
+	f23
+	f24
+	f25
+	f26
+	f27
+	f28
+	f29
+	f30
+	f31
+
+	// Special registers
+
+	zero = x0
+	ra   = x1
+	sp   = x2
+	// gp (x3) and tp (x4) are never touched by the generated code.
+	// g is reserved by Go runtime.
+	g = x27
+	// tmp is the integer register not allocated by the register allocator, and used as a
+	// scratch register by the pseudo instructions which expand to multiple native instructions.
+	tmp = x31
+	// ftmp is the floating point counterpart of tmp.
+	ftmp = f31
+)
+
+var (
+	x0VReg  = regalloc.FromRealReg(x0, regalloc.RegTypeInt)
+	x1VReg  = regalloc.FromRealReg(x1, regalloc.RegTypeInt)
+	x2VReg  = regalloc.FromRealReg(x2, regalloc.RegTypeInt)
+	x3VReg  = regalloc.FromRealReg(x3, regalloc.RegTypeInt)
+	x4VReg  = regalloc.FromRealReg(x4, regalloc.RegTypeInt)
+	x5VReg  = regalloc.FromRealReg(x5, regalloc.RegTypeInt)
+	x6VReg  = regalloc.FromRealReg(x6, regalloc.RegTypeInt)
+	x7VReg  = regalloc.FromRealReg(x7, regalloc.RegTypeInt)
+	x8VReg  = regalloc.FromRealReg(x8, regalloc.RegTypeInt)
+	x9VReg  = regalloc.FromRealReg(x9, regalloc.RegTypeInt)
+	x10VReg = regalloc.FromRealReg(x10, regalloc.RegTypeInt)
+	x11VReg = regalloc.FromRealReg(x11, regalloc.RegTypeInt)
+	x12VReg = regalloc.FromRealReg(x12, regalloc.RegTypeInt)
+	x13VReg = regalloc.FromRealReg(x13, regalloc.RegTypeInt)
+	x14VReg = regalloc.FromRealReg(x14, regalloc.RegTypeInt)
+	x15VReg = regalloc.FromRealReg(x15, regalloc.RegTypeInt)
+	x16VReg = regalloc.FromRealReg(x16, regalloc.RegTypeInt)
+	x17VReg = regalloc.FromRealReg(x17, regalloc.RegTypeInt)
+	x18VReg = regalloc.FromRealReg(x18, regalloc.RegTypeInt)
+	x19VReg = regalloc.FromRealReg(x19, regalloc.RegTypeInt)
+	x20VReg = regalloc.FromRealReg(x20, regalloc.RegTypeInt)
+	x21VReg = regalloc.FromRealReg(x21, regalloc.RegTypeInt)
+	x22VReg = regalloc.FromRealReg(x22, regalloc.RegTypeInt)
+	x23VReg = regalloc.FromRealReg(x23, regalloc.RegTypeInt)
+	x24VReg = regalloc.FromRealReg(x24, regalloc.RegTypeInt)
+	x25VReg = regalloc.FromRealReg(x25, regalloc.RegTypeInt)
+	x26VReg = regalloc.FromRealReg(x26, regalloc.RegTypeInt)
+	x27VReg = regalloc.FromRealReg(x27, regalloc.RegTypeInt)
+	x28VReg = regalloc.FromRealReg(x28, regalloc.RegTypeInt)
+	x29VReg = regalloc.FromRealReg(x29, regalloc.RegTypeInt)
+	x30VReg = regalloc.FromRealReg(x30, regalloc.RegTypeInt)
+	x31VReg = regalloc.FromRealReg(x31, regalloc.RegTypeInt)
+	f0VReg  = regalloc.FromRealReg(f0, regalloc.RegTypeFloat)
+	f1VReg  = regalloc.FromRealReg(f1, regalloc.RegTypeFloat)
+	f2VReg  = regalloc.FromRealReg(f2, regalloc.RegTypeFloat)
+	f3VReg  = regalloc.FromRealReg(f3, regalloc.RegTypeFloat)
+	f4VReg  = regalloc.FromRealReg(f4, regalloc.RegTypeFloat)
+	f5VReg  = regalloc.FromRealReg(f5, regalloc.RegTypeFloat)
+	f6VReg  = regalloc.FromRealReg(f6, regalloc.RegTypeFloat)
+	f7VReg  = regalloc.FromRealReg(f7, regalloc.RegTypeFloat)
+	f8VReg  = regalloc.FromRealReg(f8, regalloc.RegTypeFloat)
+	f9VReg  = regalloc.FromRealReg(f9, regalloc.RegTypeFloat)
+	f10VReg = regalloc.FromRealReg(f10, regalloc.RegTypeFloat)
+	f11VReg = regalloc.FromRealReg(f11, regalloc.RegTypeFloat)
+	f12VReg = regalloc.FromRealReg(f12, regalloc.RegTypeFloat)
+	f13VReg = regalloc.FromRealReg(f13, regalloc.RegTypeFloat)
+	f14VReg = regalloc.FromRealReg(f14, regalloc.RegTypeFloat)
+	f15VReg = regalloc.FromRealReg(f15, regalloc.RegTypeFloat)
+	f16VReg = regalloc.FromRealReg(f16, regalloc.RegTypeFloat)
+	f17VReg = regalloc.FromRealReg(f17, regalloc.RegTypeFloat)
+	f18VReg = regalloc.FromRealReg(f18, regalloc.RegTypeFloat)
+	f19VReg = regalloc.FromRealReg(f19, regalloc.RegTypeFloat)
+	f20VReg = regalloc.FromRealReg(f20, regalloc.RegTypeFloat)
+	f21VReg = regalloc.FromRealReg(f21, regalloc.RegTypeFloat)
+	f22VReg = regalloc.FromRealReg(f22, regalloc.RegTypeFloat)
+	f23VReg = regalloc.FromRealReg(f23, regalloc.RegTypeFloat)
+	f24VReg = regalloc.FromRealReg(f24, regalloc.RegTypeFloat)
+	f25VReg = regalloc.FromRealReg(f25, regalloc.RegTypeFloat)
+	f26VReg = regalloc.FromRealReg(f26, regalloc.RegTypeFloat)
+	f27VReg = regalloc.FromRealReg(f27, regalloc.RegTypeFloat)
+	f28VReg = regalloc.FromRealReg(f28, regalloc.RegTypeFloat)
+	f29VReg = regalloc.FromRealReg(f29, regalloc.RegTypeFloat)
+	f30VReg = regalloc.FromRealReg(f30, regalloc.RegTypeFloat)
+	f31VReg = regalloc.FromRealReg(f31, regalloc.RegTypeFloat)
+
+	zeroVReg = regalloc.FromRealReg(zero, regalloc.RegTypeInt)
+	raVReg   = regalloc.FromRealReg(ra, regalloc.RegTypeInt)
+	spVReg   = regalloc.FromRealReg(sp, regalloc.RegTypeInt)
+	tmpVReg  = regalloc.FromRealReg(tmp, regalloc.RegTypeInt)
+	ftmpVReg = regalloc.FromRealReg(ftmp, regalloc.RegTypeFloat)
+)
+
+// regNames uses the ABI mnemonics rather than the raw register numbers, as that is what
+// disassemblers and the RISC-V psABI documentation use.
+var regNames = [...]string{
+	x0:  "zero",
+	x1:  "ra",
+	x2:  "sp",
+	x3:  "gp",
+	x4:  "tp",
+	x5:  "t0",
+	x6:  "t1",
+	x7:  "t2",
+	x8:  "s0",
+	x9:  "s1",
+	x10: "a0",
+	x11: "a1",
+	x12: "a2",
+	x13: "a3",
+	x14: "a4",
+	x15: "a5",
+	x16: "a6",
+	x17: "a7",
+	x18: "s2",
+	x19: "s3",
+	x20: "s4",
+	x21: "s5",
+	x22: "s6",
+	x23: "s7",
+	x24: "s8",
+	x25: "s9",
+	x26: "s10",
+	x27: "s11",
+	x28: "t3",
+	x29: "t4",
+	x30: "t5",
+	x31: "t6",
+	f0:  "ft0",
+	f1:  "ft1",
+	f2:  "ft2",
+	f3:  "ft3",
+	f4:  "ft4",
+	f5:  "ft5",
+	f6:  "ft6",
+	f7:  "ft7",
+	f8:  "fs0",
+	f9:  "fs1",
+	f10: "fa0",
+	f11: "fa1",
+	f12: "fa2",
+	f13: "fa3",
+	f14: "fa4",
+	f15: "fa5",
+	f16: "fa6",
+	f17: "fa7",
+	f18: "fs2",
+	f19: "fs3",
+	f20: "fs4",
+	f21: "fs5",
+	f22: "fs6",
+	f23: "fs7",
+	f24: "fs8",
+	f25: "fs9",
+	f26: "fs10",
+	f27: "fs11",
+	f28: "ft8",
+	f29: "ft9",
+	f30: "ft10",
+	f31: "ft11",
+}
+
+func formatVReg(r regalloc.VReg) string {
+	if r.IsRealReg() {
+		return regNames[r.RealReg()]
+	}
+	switch r.RegType() {
+	case regalloc.RegTypeInt:
+		return fmt.Sprintf("x%d?", r.ID())
+	case regalloc.RegTypeFloat:
+		return fmt.Sprintf("f%d?", r.ID())
+	default:
+		panic(fmt.Sprintf("BUG: invalid register type: %d for %s", r.RegType(), r))
+	}
+}
+
+// regNumberInEncoding returns the 5-bit register number used in the instruction encoding.
+func regNumberInEncoding(r regalloc.RealReg) uint32 {
+	if r >= f0 {
+		return uint32(r - f0)
+	}
+	return uint32(r - x0)
+}
@@ -2,6 +2,7 @@ package backend
 
 import (
 	"context"
+	"errors"
 
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
@@ -62,6 +63,12 @@ type (
 		// InsertReturn inserts the return instruction to return from the current function.
 		InsertReturn()
 
+		// LowerTailCall lowers the given tail call instruction (ssa.OpcodeTailCallReturnCall or
+		// ssa.OpcodeTailCallReturnCallIndirect) as a jump into the callee after tearing down the current frame.
+		// This returns an error without inserting any instruction if the tail call cannot be lowered that way
+		// on this machine, since lowering it as a regular call would grow the stack on each tail call.
+		LowerTailCall(instr *ssa.Instruction) error
+
 		// InsertLoadConstantBlockArg inserts the instruction(s) to load the constant value into the given regalloc.VReg.
 		InsertLoadConstantBlockArg(instr *ssa.Instruction, vr regalloc.VReg)
 
@@ -103,6 +110,20 @@ type (
 		// enter the function from Go.
 		CompileEntryPreamble(signature *ssa.Signature) []byte
 
+		// CompileLazyCompilationTrampoline returns the sequence of instructions shared by the entries of the functions
+		// compiled on their first call. This exits the execution with wazevoapi.ExitCodeLazyCompile to compile the
+		// function whose index is stored in the execution context by its entry, and then jumps to the compiled function.
+		CompileLazyCompilationTrampoline() []byte
+
+		// LazyFunctionEntrySize returns the size of the entries encoded by EncodeLazyFunctionEntry.
+		LazyFunctionEntrySize() int
+
+		// EncodeLazyFunctionEntry encodes the entry of the local function of the given index at entryOffset in the
+		// executable, whose beginning must be page-aligned. The entry jumps to the address stored in the 8-byte slot at
+		// slotOffset. This returns the offset of the sequence which the slot initially points to: it stores the index in
+		// the execution context and jumps to the code compiled by CompileLazyCompilationTrampoline at trampolineOffset.
+		EncodeLazyFunctionEntry(executable []byte, entryOffset, slotOffset, trampolineOffset int, index uint32) (lazyOffset int)
+
 		// LowerParams lowers the given parameters.
 		LowerParams(params []ssa.Value)
 
@@ -117,3 +138,8 @@ type (
 		CallTrampolineIslandInfo(numFunctions int) (interval, islandSize int, err error)
 	}
 )
+
+// ErrTailCallStackArgs is returned by Machine.LowerTailCall when the callee takes the arguments passed on the stack
+// of a different size than the current function, whose area of them is reused by the callee.
+var ErrTailCallStackArgs = errors.New("tail call to the function with a different size of the arguments passed " +
+	"on the stack is not supported by the compiler: use the interpreter")
@@ -46,6 +46,18 @@ func (m mockMachine) CompileStackGrowCallSequence() []byte {
 	panic("TODO")
 }
 
+func (m mockMachine) CompileLazyCompilationTrampoline() []byte {
+	panic("TODO")
+}
+
+func (m mockMachine) LazyFunctionEntrySize() int {
+	panic("TODO")
+}
+
+func (m mockMachine) EncodeLazyFunctionEntry([]byte, int, int, int, uint32) int {
+	panic("TODO")
+}
+
 // CompileGoFunctionTrampoline implements Machine.CompileGoFunctionTrampoline.
 func (m mockMachine) CompileGoFunctionTrampoline(wazevoapi.ExitCode, *ssa.Signature, bool) []byte {
 	panic("TODO")
@@ -63,6 +75,9 @@ func (m mockMachine) PostRegAlloc() {}
 // InsertReturn implements Machine.InsertReturn.
 func (m mockMachine) InsertReturn() { panic("TODO") }
 
+// LowerTailCall implements Machine.LowerTailCall.
+func (m mockMachine) LowerTailCall(*ssa.Instruction) error { panic("TODO") }
+
 // LinkAdjacentBlocks implements Machine.LinkAdjacentBlocks.
 func (m mockMachine) LinkAdjacentBlocks(prev, next ssa.BasicBlock) { m.linkAdjacentBlocks(prev, next) }
 
@@ -3,6 +3,7 @@ package wazevo
 import (
 	"context"
 	"fmt"
+	"math"
 	"reflect"
 	"runtime"
 	"sync/atomic"
@@ -13,9 +14,11 @@ import (
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/expctxkeys"
 	"github.com/tetratelabs/wazero/internal/internalapi"
+	"github.com/tetratelabs/wazero/internal/sampling"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/internal/wasmdebug"
 	"github.com/tetratelabs/wazero/internal/wasmruntime"
+	"github.com/tetratelabs/wazero/sys"
 )
 
 type (
@@ -43,6 +46,18 @@ type (
 		execCtxPtr        uintptr
 		numberOfResults   int
 		stackIteratorImpl stackIterator
+		// exception is the exception being thrown while execCtx.exceptionPending is set.
+		exception *experimental.Exception
+		// exceptionRefs holds the exceptions referenced by exnref values during the current call.
+		exceptionRefs wasm.ExceptionRefs
+		// fuel is the fuel remaining for the current call, set by experimental.WithFuel, or nil if unlimited.
+		// This is synchronized with execCtx.fuel around Go function calls.
+		fuel *int64
+		// sampler is the profiler set by experimental/profiling.WithProfiler for the current call, or nil.
+		sampler sampling.Sampler
+		// sampleFrames and sampleReturnAddresses are reused across the stack samples.
+		sampleFrames          []sampling.Frame
+		sampleReturnAddresses []uintptr
 	}
 
 	// executionContext is the struct to be read/written by assembly functions.
@@ -90,9 +105,48 @@ type (
 		memoryWait64TrampolineAddress *byte
 		// memoryNotifyTrampolineAddress holds the address of the memory_notify trampoline function.
 		memoryNotifyTrampolineAddress *byte
+		// exceptionPending is non-zero while an exception is propagating through the native frames.
+		exceptionPending uint64
+		// exceptionPayload holds the pointer to the values of the exception caught by the last wazevoapi.ExceptionOpCatch.
+		exceptionPayload *uint64
+		// exceptionTrampolineAddress holds the address of the exception trampoline function.
+		exceptionTrampolineAddress *byte
+		// fuel holds the fuel remaining for the current call, which is decremented by the functions compiled with
+		// wasm.Module FuelCosts.
+		fuel int64
+		// lazyFunctionIndex holds the local index of the function to compile lazily, set by its entry.
+		lazyFunctionIndex uint64
+		// lazyFunctionExecutable holds the address of the function compiled lazily, to jump to once compiled.
+		lazyFunctionExecutable *byte
+		// sampleRequested is set to non-zero by the sampling profiler when a stack sample must be taken at the next
+		// safepoint of the functions compiled with wasm.Module Profiling.
+		sampleRequested uint32
+		// sampleTrampolineAddress holds the address of the sample trampoline function.
+		sampleTrampolineAddress *byte
+		// tailCallAddress holds the address of the callee of the indirect tail call which takes all the argument
+		// registers, so that the address is not held by any register. Note: only used in amd64.
+		tailCallAddress *byte
 	}
 )
 
+// loadFuel sets the fuel remaining in the execution context to the one set by experimental.WithFuel, which might have
+// been changed by Go functions.
+func (c *callEngine) loadFuel() {
+	if c.fuel != nil {
+		c.execCtx.fuel = *c.fuel
+	} else {
+		c.execCtx.fuel = math.MaxInt64
+	}
+}
+
+// saveFuel writes the fuel remaining in the execution context back to the one set by experimental.WithFuel, so that
+// Go functions and the caller observe it.
+func (c *callEngine) saveFuel() {
+	if c.fuel != nil {
+		*c.fuel = c.execCtx.fuel
+	}
+}
+
 func (c *callEngine) requiredInitialStackSize() int {
 	const initialStackSizeDefault = 10240
 	stackSize := initialStackSizeDefault
@@ -131,6 +185,11 @@ func (c *callEngine) Definition() api.FunctionDefinition {
 	return c.parent.module.Source.FunctionDefinition(c.indexInModule)
 }
 
+// ModuleFunction implements wasm.ModuleFunction.
+func (c *callEngine) ModuleFunction() (*wasm.ModuleInstance, wasm.Index) {
+	return c.parent.module, c.indexInModule
+}
+
 // Call implements api.Function.
 func (c *callEngine) Call(ctx context.Context, params ...uint64) ([]uint64, error) {
 	if c.requiredParams != len(params) {
@@ -144,7 +203,8 @@ func (c *callEngine) Call(ctx context.Context, params ...uint64) ([]uint64, erro
 	return paramResultSlice[:c.numberOfResults], nil
 }
 
-func (c *callEngine) addFrame(builder wasmdebug.ErrorBuilder, addr uintptr) (def api.FunctionDefinition, listener experimental.FunctionListener) {
+// compiledModuleOfAddr returns the compiledModule whose executable contains the address, or nil if not found.
+func (c *callEngine) compiledModuleOfAddr(addr uintptr) *compiledModule {
 	eng := c.parent.parent.parent
 	cm := eng.compiledModuleOfAddr(addr)
 	if cm == nil {
@@ -166,21 +226,92 @@ func (c *callEngine) addFrame(builder wasmdebug.ErrorBuilder, addr uintptr) (def
 			}
 		}
 	}
+	return cm
+}
 
-	if cm != nil {
-		index := cm.functionIndexOf(addr)
-		def = cm.module.FunctionDefinition(cm.module.ImportFunctionCount + index)
-		var sources []string
-		if dw := cm.module.DWARFLines; dw != nil {
-			sourceOffset := cm.getSourceOffset(addr)
-			sources = dw.Line(sourceOffset)
+// listenerForAbort is the listener of a frame of the stack unwound by a panic, which is notified of the abort.
+type listenerForAbort struct {
+	def api.FunctionDefinition
+	lsn experimental.FunctionListener
+}
+
+// addFrames adds the frames at addr to the builder, which are the ones of the inlined function and its caller if the
+// instruction at addr is inlined, and appends their listeners to listeners.
+func (c *callEngine) addFrames(builder wasmdebug.ErrorBuilder, addr uintptr, listeners []listenerForAbort) []listenerForAbort {
+	cm := c.compiledModuleOfAddr(addr)
+	if cm == nil {
+		return listeners
+	}
+	index := cm.functionIndexOf(addr)
+	if callee, callSiteOffset, ok := cm.inlinedCallAt(addr); ok {
+		listeners = c.addFrame(builder, cm, callee, cm.getSourceOffset(addr), listeners)
+		return c.addFrame(builder, cm, index, callSiteOffset, listeners)
+	}
+	var sourceOffset uint64
+	if cm.module.DWARFLines != nil {
+		sourceOffset = cm.getSourceOffset(addr)
+	}
+	return c.addFrame(builder, cm, index, sourceOffset, listeners)
+}
+
+// addFrame adds the frame of the local function of the given index to the builder, and appends its listener if any.
+func (c *callEngine) addFrame(builder wasmdebug.ErrorBuilder, cm *compiledModule, index wasm.Index, sourceOffset uint64, listeners []listenerForAbort) []listenerForAbort {
+	def := cm.module.FunctionDefinition(cm.module.ImportFunctionCount + index)
+	var sources []string
+	if dw := cm.module.DWARFLines; dw != nil {
+		sources = dw.Line(sourceOffset)
+	}
+	builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
+	if len(cm.listeners) > 0 {
+		if lsn := cm.listeners[index]; lsn != nil {
+			listeners = append(listeners, listenerForAbort{def, lsn})
 		}
-		builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
-		if len(cm.listeners) > 0 {
-			listener = cm.listeners[index]
+	}
+	return listeners
+}
+
+// sample passes the stack at the safepoint which exited with wazevoapi.ExitCodeSample to the sampler.
+func (c *callEngine) sample() {
+	if c.sampler == nil {
+		// The sample was requested right before the previous call with the sampler returned.
+		return
+	}
+	frames := c.appendSampleFrames(c.sampleFrames[:0], uintptr(unsafe.Pointer(c.execCtx.goCallReturnAddress)))
+	c.sampleReturnAddresses = unwindStack(
+		uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)),
+		c.execCtx.framePointerBeforeGoCall,
+		c.stackTop,
+		c.sampleReturnAddresses[:0],
+	)
+	for _, retAddr := range c.sampleReturnAddresses[:len(c.sampleReturnAddresses)-1] { // the last return addr is the trampoline, so we skip it.
+		frames = c.appendSampleFrames(frames, retAddr)
+	}
+	c.sampler.Sample(frames)
+	c.sampleFrames = frames
+}
+
+// appendSampleFrames is like addFrames, but appends the frames at addr to the stack sample.
+func (c *callEngine) appendSampleFrames(frames []sampling.Frame, addr uintptr) []sampling.Frame {
+	cm := c.compiledModuleOfAddr(addr)
+	if cm == nil {
+		return frames
+	}
+	frame := func(index wasm.Index, sourceOffset uint64) sampling.Frame {
+		return sampling.Frame{
+			Function:     cm.module.FunctionDefinition(cm.module.ImportFunctionCount + index),
+			DWARFLines:   cm.module.DWARFLines,
+			SourceOffset: sourceOffset,
 		}
 	}
-	return
+	index := cm.functionIndexOf(addr)
+	if callee, callSiteOffset, ok := cm.inlinedCallAt(addr); ok {
+		return append(frames, frame(callee, cm.getSourceOffset(addr)), frame(index, callSiteOffset))
+	}
+	var sourceOffset uint64
+	if cm.module.DWARFLines != nil {
+		sourceOffset = cm.getSourceOffset(addr)
+	}
+	return append(frames, frame(index, sourceOffset))
 }
 
 // CallWithStack implements api.Function.
@@ -206,6 +337,7 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 
 	p := c.parent
 	ensureTermination := p.parent.ensureTermination
+	exceptionHandling := p.parent.parent.enabledFeatures.IsEnabled(experimental.CoreFeaturesExceptionHandling)
 	m := p.module
 	if ensureTermination {
 		select {
@@ -221,7 +353,14 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 	if len(paramResultStack) > 0 {
 		paramResultPtr = &paramResultStack[0]
 	}
+	c.fuel, _ = ctx.Value(expctxkeys.FuelKey{}).(*int64)
+	if c.sampler, _ = ctx.Value(expctxkeys.SamplerKey{}).(sampling.Sampler); c.sampler != nil {
+		stop := c.sampler.Start(&c.execCtx.sampleRequested)
+		defer stop()
+	}
+	c.loadFuel()
 	defer func() {
+		c.saveFuel()
 		r := recover()
 		if s, ok := r.(*snapshot); ok {
 			// A snapshot that wasn't handled was created by a different call engine possibly from a nested wasm invocation,
@@ -229,17 +368,8 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			panic(s)
 		}
 		if r != nil {
-			type listenerForAbort struct {
-				def api.FunctionDefinition
-				lsn experimental.FunctionListener
-			}
-
-			var listeners []listenerForAbort
 			builder := wasmdebug.NewErrorBuilder()
-			def, lsn := c.addFrame(builder, uintptr(unsafe.Pointer(c.execCtx.goCallReturnAddress)))
-			if lsn != nil {
-				listeners = append(listeners, listenerForAbort{def, lsn})
-			}
+			listeners := c.addFrames(builder, uintptr(unsafe.Pointer(c.execCtx.goCallReturnAddress)), nil)
 			returnAddrs := unwindStack(
 				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)),
 				c.execCtx.framePointerBeforeGoCall,
@@ -247,25 +377,32 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 				nil,
 			)
 			for _, retAddr := range returnAddrs[:len(returnAddrs)-1] { // the last return addr is the trampoline, so we skip it.
-				def, lsn = c.addFrame(builder, retAddr)
-				if lsn != nil {
-					listeners = append(listeners, listenerForAbort{def, lsn})
-				}
+				listeners = c.addFrames(builder, retAddr, listeners)
 			}
 			err = builder.FromRecovered(r)
 
 			for _, lsn := range listeners {
 				lsn.lsn.Abort(ctx, m, lsn.def, err)
 			}
-		} else {
-			if err != wasmruntime.ErrRuntimeStackOverflow { // Stackoverflow case shouldn't be panic (to avoid extreme stack unwinding).
-				err = c.parent.module.FailIfClosed()
+		} else if _, ok := err.(*experimental.Exception); ok {
+			// The uncaught exception is reported unless the module is closed meanwhile.
+			if closedErr := c.parent.module.FailIfClosed(); closedErr != nil {
+				err = closedErr
 			}
+		} else if err == nil {
+			// Errors which aren't panics, e.g. the stack overflow, are returned as is to avoid extreme stack unwinding.
+			err = c.parent.module.FailIfClosed()
 		}
 
 		if err != nil {
 			// Ensures that we can reuse this callEngine even after an error.
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
+			c.execCtx.exceptionPending = 0
+			c.exception = nil
+		}
+		if exceptionHandling {
+			// exnref values are only valid during the call.
+			c.exceptionRefs.Reset()
 		}
 	}()
 
@@ -281,6 +418,9 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 	for {
 		switch ec := c.execCtx.exitCode; ec & wazevoapi.ExitCodeMask {
 		case wazevoapi.ExitCodeOK:
+			if c.execCtx.exceptionPending != 0 {
+				return c.exception
+			}
 			return nil
 		case wazevoapi.ExitCodeGrowStack:
 			oldsp := uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall))
@@ -300,10 +440,27 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			runtime.KeepAlive(oldStack)
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
 			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr, newsp, newfp)
+		case wazevoapi.ExitCodeSample:
+			atomic.StoreUint32(&c.execCtx.sampleRequested, 0)
+			c.sample()
+			c.execCtx.exitCode = wazevoapi.ExitCodeOK
+			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr,
+				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
+		case wazevoapi.ExitCodeLazyCompile:
+			// The trampoline stores the module context of the function, which might be imported from another module.
+			cm := c.callerModuleInstance().Engine.(*moduleEngine).parent
+			executable, err := cm.compileLazily(wasm.Index(c.execCtx.lazyFunctionIndex))
+			if err != nil {
+				return err
+			}
+			c.execCtx.lazyFunctionExecutable = executable
+			c.execCtx.exitCode = wazevoapi.ExitCodeOK
+			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr,
+				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
 		case wazevoapi.ExitCodeGrowMemory:
 			mod := c.callerModuleInstance()
-			mem := mod.MemoryInstance
 			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			mem := memoryAt(mod, uint32(s[1]))
 			argRes := &s[0]
 			if res, ok := mem.Grow(uint32(*argRes)); !ok {
 				*argRes = uint64(0xffffffff) // = -1 in signed 32-bit integer.
@@ -325,9 +482,14 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			index := wazevoapi.GoFunctionIndexFromExitCode(ec)
 			f := hostModuleGoFuncFromOpaque[api.GoFunction](index, c.execCtx.goFunctionCallCalleeModuleContextOpaque)
 			func() {
+				c.saveFuel()
+				defer c.loadFuel()
 				if snapshotEnabled {
 					defer snapshotRecoverFn(c)
 				}
+				if exceptionHandling {
+					defer exceptionRecoverFn(c)
+				}
 				f.Call(ctx, goCallStackView(c.execCtx.stackPointerBeforeGoCall))
 			}()
 			// Back to the native code.
@@ -347,13 +509,22 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			listener.Before(ctx, callerModule, def, s, c.stackIterator(true))
 			// Call into the Go function.
 			func() {
+				c.saveFuel()
+				defer c.loadFuel()
 				if snapshotEnabled {
 					defer snapshotRecoverFn(c)
 				}
+				if exceptionHandling {
+					defer exceptionRecoverFn(c)
+				}
 				f.Call(ctx, s)
 			}()
-			// Call Listener.After.
-			listener.After(ctx, callerModule, def, s)
+			// Call Listener.After, or Abort if the Go function threw an exception.
+			if c.execCtx.exceptionPending != 0 {
+				listener.Abort(ctx, callerModule, def, c.exception)
+			} else {
+				listener.After(ctx, callerModule, def, s)
+			}
 			// Back to the native code.
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
 			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr,
@@ -363,9 +534,14 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			f := hostModuleGoFuncFromOpaque[api.GoModuleFunction](index, c.execCtx.goFunctionCallCalleeModuleContextOpaque)
 			mod := c.callerModuleInstance()
 			func() {
+				c.saveFuel()
+				defer c.loadFuel()
 				if snapshotEnabled {
 					defer snapshotRecoverFn(c)
 				}
+				if exceptionHandling {
+					defer exceptionRecoverFn(c)
+				}
 				f.Call(ctx, mod, goCallStackView(c.execCtx.stackPointerBeforeGoCall))
 			}()
 			// Back to the native code.
@@ -385,13 +561,22 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			listener.Before(ctx, callerModule, def, s, c.stackIterator(true))
 			// Call into the Go function.
 			func() {
+				c.saveFuel()
+				defer c.loadFuel()
 				if snapshotEnabled {
 					defer snapshotRecoverFn(c)
 				}
+				if exceptionHandling {
+					defer exceptionRecoverFn(c)
+				}
 				f.Call(ctx, callerModule, s)
 			}()
-			// Call Listener.After.
-			listener.After(ctx, callerModule, def, s)
+			// Call Listener.After, or Abort if the Go function threw an exception.
+			if c.execCtx.exceptionPending != 0 {
+				listener.Abort(ctx, callerModule, def, c.exception)
+			} else {
+				listener.After(ctx, callerModule, def, s)
+			}
 			// Back to the native code.
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
 			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr,
@@ -400,7 +585,7 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			stack := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
 			index := wasm.Index(stack[0])
 			mod := c.callerModuleInstance()
-			listener := mod.Engine.(*moduleEngine).listeners[index]
+			listener := moduleEngineOf(mod.Engine).listeners[index]
 			def := mod.Source.FunctionDefinition(index + mod.Source.ImportFunctionCount)
 			listener.Before(ctx, mod, def, stack[1:], c.stackIterator(false))
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
@@ -410,7 +595,7 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			stack := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
 			index := wasm.Index(stack[0])
 			mod := c.callerModuleInstance()
-			listener := mod.Engine.(*moduleEngine).listeners[index]
+			listener := moduleEngineOf(mod.Engine).listeners[index]
 			def := mod.Source.FunctionDefinition(index + mod.Source.ImportFunctionCount)
 			listener.After(ctx, mod, def, stack[1:])
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
@@ -430,24 +615,24 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			mod := c.callerModuleInstance()
 			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
 			funcIndex := wasm.Index(s[0])
-			ref := mod.Engine.FunctionInstanceReference(funcIndex)
+			ref := moduleEngineOf(mod.Engine).FunctionInstanceReference(funcIndex)
 			s[0] = uint64(ref)
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
 			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr,
 				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
 		case wazevoapi.ExitCodeMemoryWait32:
 			mod := c.callerModuleInstance()
-			mem := mod.MemoryInstance
+			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			mem := memoryAt(mod, uint32(s[3]))
 			if !mem.Shared {
 				panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
 			}
 
-			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
 			timeout, exp, addr := int64(s[0]), uint32(s[1]), uintptr(s[2])
 			base := uintptr(unsafe.Pointer(&mem.Buffer[0]))
 
-			offset := uint32(addr - base)
-			res := mem.Wait32(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint32) uint32 {
+			offset := uint64(addr - base)
+			res := mem.Wait32(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint64) uint32 {
 				addr := unsafe.Add(unsafe.Pointer(&mem.Buffer[0]), offset)
 				return atomic.LoadUint32((*uint32)(addr))
 			})
@@ -457,17 +642,17 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
 		case wazevoapi.ExitCodeMemoryWait64:
 			mod := c.callerModuleInstance()
-			mem := mod.MemoryInstance
+			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			mem := memoryAt(mod, uint32(s[3]))
 			if !mem.Shared {
 				panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
 			}
 
-			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
 			timeout, exp, addr := int64(s[0]), uint64(s[1]), uintptr(s[2])
 			base := uintptr(unsafe.Pointer(&mem.Buffer[0]))
 
-			offset := uint32(addr - base)
-			res := mem.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint32) uint64 {
+			offset := uint64(addr - base)
+			res := mem.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint64) uint64 {
 				addr := unsafe.Add(unsafe.Pointer(&mem.Buffer[0]), offset)
 				return atomic.LoadUint64((*uint64)(addr))
 			})
@@ -477,16 +662,22 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
 		case wazevoapi.ExitCodeMemoryNotify:
 			mod := c.callerModuleInstance()
-			mem := mod.MemoryInstance
-
 			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			mem := memoryAt(mod, uint32(s[2]))
+
 			count, addr := uint32(s[0]), s[1]
-			offset := uint32(uintptr(addr) - uintptr(unsafe.Pointer(&mem.Buffer[0])))
+			offset := uint64(uintptr(addr) - uintptr(unsafe.Pointer(&mem.Buffer[0])))
 			res := mem.Notify(offset, count)
 			s[0] = uint64(res)
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
 			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr,
 				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
+		case wazevoapi.ExitCodeException:
+			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			s[0] = c.handleException(wazevoapi.ExceptionOp(s[0]), s[1])
+			c.execCtx.exitCode = wazevoapi.ExitCodeOK
+			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr,
+				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
 		case wazevoapi.ExitCodeUnreachable:
 			panic(wasmruntime.ErrRuntimeUnreachable)
 		case wazevoapi.ExitCodeMemoryOutOfBounds:
@@ -497,6 +688,12 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			panic(wasmruntime.ErrRuntimeInvalidTableAccess)
 		case wazevoapi.ExitCodeIndirectCallTypeMismatch:
 			panic(wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+		case wazevoapi.ExitCodeNullReference:
+			panic(wasmruntime.ErrRuntimeNullReference)
+		case wazevoapi.ExitCodeOutOfFuel:
+			panic(sys.ErrOutOfFuel)
+		case wazevoapi.ExitCodeNullFunctionReference:
+			panic(wasmruntime.ErrRuntimeNullFunctionReference)
 		case wazevoapi.ExitCodeIntegerOverflow:
 			panic(wasmruntime.ErrRuntimeIntegerOverflow)
 		case wazevoapi.ExitCodeIntegerDivisionByZero:
@@ -515,6 +712,14 @@ func (c *callEngine) callerModuleInstance() *wasm.ModuleInstance {
 	return moduleInstanceFromOpaquePtr(c.execCtx.callerModuleContextPtr)
 }
 
+// memoryAt returns the memory instance at the given memory index of the module instance.
+func memoryAt(mod *wasm.ModuleInstance, index uint32) *wasm.MemoryInstance {
+	if index == 0 {
+		return mod.MemoryInstance
+	}
+	return mod.Memories[index]
+}
+
 const callStackCeiling = uintptr(50000000) // in uint64 (8 bytes) == 400000000 bytes in total == 400mb.
 
 func (c *callEngine) growStackWithGuarded() (newSP uintptr, newFP uintptr, err error) {
@@ -587,6 +792,12 @@ type stackIterator struct {
 	pc            uint64
 
 	currentDef *wasm.FunctionDefinition
+	// caller is the caller of the function inlined at pc which is yet to be iterated, if any.
+	caller *wasm.FunctionDefinition
+	// inCaller is set while the current frame is the caller of the function inlined at pc, whose source offset at pc
+	// is callSiteOffset.
+	inCaller       bool
+	callSiteOffset uint64
 }
 
 func (si *stackIterator) reset(c *callEngine, onHostCall bool) {
@@ -599,10 +810,18 @@ func (si *stackIterator) reset(c *callEngine, onHostCall bool) {
 	si.retAddrs = si.retAddrs[:len(si.retAddrs)-1] // the last return addr is the trampoline, so we skip it.
 	si.retAddrCursor = 0
 	si.eng = c.parent.parent.parent
+	si.caller, si.inCaller = nil, false
 }
 
 // Next implements the same method as documented on experimental.StackIterator.
 func (si *stackIterator) Next() bool {
+	if si.caller != nil {
+		// The caller of the inlined function shares pc with it.
+		si.currentDef, si.caller = si.caller, nil
+		si.inCaller = true
+		return true
+	}
+	si.inCaller = false
 	if si.retAddrCursor >= len(si.retAddrs) {
 		return false
 	}
@@ -611,6 +830,11 @@ func (si *stackIterator) Next() bool {
 	cm := si.eng.compiledModuleOfAddr(addr)
 	if cm != nil {
 		index := cm.functionIndexOf(addr)
+		if callee, callSiteOffset, ok := cm.inlinedCallAt(addr); ok {
+			si.caller = cm.module.FunctionDefinition(cm.module.ImportFunctionCount + index)
+			si.callSiteOffset = callSiteOffset
+			index = callee
+		}
 		def := cm.module.FunctionDefinition(cm.module.ImportFunctionCount + index)
 		si.currentDef = def
 		si.retAddrCursor++
@@ -637,6 +861,9 @@ func (si *stackIterator) Definition() api.FunctionDefinition {
 
 // SourceOffsetForPC implements the same method as documented on experimental.InternalFunction.
 func (si *stackIterator) SourceOffsetForPC(pc experimental.ProgramCounter) uint64 {
+	if si.inCaller && uint64(pc) == si.pc {
+		return si.callSiteOffset
+	}
 	upc := uintptr(pc)
 	cm := si.eng.compiledModuleOfAddr(upc)
 	return cm.getSourceOffset(upc)
@@ -697,6 +924,68 @@ func (s *snapshot) Error() string {
 		"exported function invocation than snapshot"
 }
 
+// exceptionRecoverFn must be deferred when calling a Go function. If the Go function threw an exception, this makes it
+// pending so that the native code propagates it.
+func exceptionRecoverFn(c *callEngine) {
+	if r := recover(); r != nil {
+		if exc, ok := r.(*experimental.Exception); ok {
+			c.exception = exc
+			c.execCtx.exceptionPending = 1
+		} else {
+			panic(r)
+		}
+	}
+}
+
+// handleException does the operation of the exception builtin function called by the native code, and returns its
+// result. See wazevoapi.ExceptionOp.
+func (c *callEngine) handleException(op wazevoapi.ExceptionOp, arg uint64) uint64 {
+	switch op {
+	case wazevoapi.ExceptionOpThrow:
+		tag := c.callerModuleInstance().Tags[arg]
+		exc := &experimental.Exception{Tag: tag, Values: make([]uint64, tag.Type.ParamNumInUint64)}
+		c.exception = exc
+		c.execCtx.exceptionPending = 1
+		if len(exc.Values) == 0 {
+			return 0
+		}
+		return uint64(uintptr(unsafe.Pointer(&exc.Values[0])))
+	case wazevoapi.ExceptionOpThrowRef:
+		if arg == 0 {
+			panic(wasmruntime.ErrRuntimeNullExceptionReference)
+		}
+		exc := c.exceptionRefs.Get(arg)
+		if exc == nil {
+			panic(wasmruntime.ErrRuntimeInvalidExceptionReference)
+		}
+		c.exception = exc
+		c.execCtx.exceptionPending = 1
+		return 0
+	case wazevoapi.ExceptionOpCatch, wazevoapi.ExceptionOpCatchRef:
+		exc := c.exception
+		if arg != wazevoapi.ExceptionCatchAllTag {
+			tag := c.callerModuleInstance().Tags[arg]
+			if exc.Tag != experimental.Tag(tag) {
+				return 0
+			} else if len(exc.Values) != tag.Type.ParamNumInUint64 {
+				panic(fmt.Errorf("invalid exception: %d values for tag with %d params in uint64",
+					len(exc.Values), tag.Type.ParamNumInUint64))
+			}
+			if len(exc.Values) > 0 {
+				c.execCtx.exceptionPayload = &exc.Values[0]
+			}
+		}
+		c.exception = nil
+		c.execCtx.exceptionPending = 0
+		if op == wazevoapi.ExceptionOpCatchRef {
+			return c.exceptionRefs.Ref(exc)
+		}
+		return 1
+	default:
+		panic("BUG: invalid exception operation")
+	}
+}
+
 func snapshotRecoverFn(c *callEngine) {
 	if r := recover(); r != nil {
 		if s, ok := r.(*snapshot); ok && s.c == c {
@@ -843,17 +843,21 @@ func TestE2E(t *testing.T) {
 	} {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			for i := 0; i < 2; i++ {
+			for i := 0; i < 4; i++ {
 				var name string
-				if i == 0 {
+				if i%2 == 0 {
 					name = "no cache"
 				} else {
 					name = "with cache"
 				}
+				lazy := i >= 2
+				if lazy {
+					name += " lazy"
+				}
 				t.Run(name, func(t *testing.T) {
 					cache, err := wazero.NewCompilationCacheWithDir(tmp)
 					require.NoError(t, err)
-					config := wazero.NewRuntimeConfigCompiler().WithCompilationCache(cache)
+					config := wazero.NewRuntimeConfigCompiler().WithCompilationCache(cache).WithLazyCompilation(lazy)
 					if tc.features != 0 {
 						config = config.WithCoreFeatures(tc.features)
 					}
@@ -1076,7 +1080,7 @@ func TestE2E_stores(t *testing.T) {
 func TestE2E_reexported_memory(t *testing.T) {
 	m1 := &wasm.Module{
 		ExportSection: []wasm.Export{{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0}},
-		MemorySection: &wasm.Memory{Min: 1},
+		MemorySection: []wasm.Memory{{Min: 1}},
 		NameSection:   &wasm.NameSection{ModuleName: "m1"},
 	}
 	m2 := &wasm.Module{
@@ -1321,6 +1325,61 @@ func TestListener_long(t *testing.T) {
 `, "\n"+buf.String())
 }
 
+// TestListener_growMemory verifies that the functions see the memory grown by the listeners before them.
+func TestListener_growMemory(t *testing.T) {
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0, 0},
+		MemorySection:   []wasm.Memory{{Min: 1, Max: 10}},
+		CodeSection: []wasm.Code{
+			// Stores to and loads from the second page, which only exists once the listener grows the memory.
+			{Body: []byte{
+				wasm.OpcodeI32Const, 0x80, 0x80, 0x04, // 65536
+				wasm.OpcodeI32Const, 42,
+				wasm.OpcodeI32Store, 2, 0,
+				wasm.OpcodeI32Const, 0x80, 0x80, 0x04,
+				wasm.OpcodeI32Load, 2, 0,
+				wasm.OpcodeEnd,
+			}},
+			// Calls the above, which may be inlined.
+			{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "grown", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "call_grown", Type: wasm.ExternTypeFunc, Index: 1},
+		},
+	})
+
+	for _, name := range []string{"grown", "call_grown"} {
+		t.Run(name, func(t *testing.T) {
+			ctx := experimental.WithFunctionListenerFactory(context.Background(),
+				experimental.FunctionListenerFactoryFunc(func(def api.FunctionDefinition) experimental.FunctionListener {
+					if def.Index() != 0 {
+						return nil
+					}
+					return experimental.FunctionListenerFunc(func(_ context.Context, mod api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+						// Growing the memory moves it, as its capacity isn't reserved up to the max.
+						_, ok := mod.Memory().Grow(1)
+						require.True(t, ok)
+					})
+				}))
+			r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler())
+			defer func() {
+				require.NoError(t, r.Close(ctx))
+			}()
+
+			inst, err := r.Instantiate(ctx, bin)
+			require.NoError(t, err)
+			res, err := inst.ExportedFunction(name).Call(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{42}, res)
+			v, ok := inst.Memory().ReadUint32Le(65536)
+			require.True(t, ok)
+			require.Equal(t, uint32(42), v)
+		})
+	}
+}
+
 func TestListener_long_as_is(t *testing.T) {
 	params := []wasm.ValueType{
 		i32, i64, i32, i64, i32, i64, i32, i64, i32, i64,
@@ -1450,3 +1509,61 @@ func TestDWARF(t *testing.T) {
 	err = r.Close(ctx)
 	require.NoError(t, err)
 }
+
+// TestLazyCompilation_segments verifies that the lazily compiled functions exceeding the first reserved executable are
+// placed in another one, from which they can call each other and trap.
+func TestLazyCompilation_segments(t *testing.T) {
+	// call_indirect expands more than the reservation expects.
+	var run []byte
+	for i := 0; i < 1000; i++ {
+		run = append(run, wasm.OpcodeI32Const, 0, wasm.OpcodeCallIndirect, 0, 0)
+	}
+	run = append(run, wasm.OpcodeCall, 2, wasm.OpcodeEnd)
+
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0, 0, 0, 0},
+		GlobalSection: []wasm.Global{{
+			Type: wasm.GlobalType{ValType: i32, Mutable: true},
+			Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+		}},
+		TableSection: []wasm.Table{{Min: 2, Type: wasm.RefTypeFuncref}},
+		ElementSection: []wasm.ElementSegment{{
+			OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+			Type:       wasm.RefTypeFuncref, Mode: wasm.ElementModeActive, Init: []wasm.Index{0},
+		}},
+		CodeSection: []wasm.Code{
+			{Body: []byte{
+				wasm.OpcodeGlobalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeGlobalSet, 0, wasm.OpcodeEnd,
+			}},
+			{Body: run},
+			{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeCallIndirect, 0, 0, wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "count", Type: wasm.ExternTypeGlobal, Index: 0},
+			{Name: "run", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "trap", Type: wasm.ExternTypeFunc, Index: 3},
+		},
+		NameSection: &wasm.NameSection{FunctionNames: wasm.NameMap{
+			{Index: 0, Name: "inc"}, {Index: 1, Name: "run"}, {Index: 2, Name: "call_inc"}, {Index: 3, Name: "trap"},
+		}},
+	})
+
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler().WithLazyCompilation(true))
+	defer func() {
+		require.NoError(t, r.Close(ctx))
+	}()
+	inst, err := r.Instantiate(ctx, bin)
+	require.NoError(t, err)
+
+	_, err = inst.ExportedFunction("run").Call(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1001), inst.ExportedGlobal("count").Get())
+
+	_, err = inst.ExportedFunction("trap").Call(ctx)
+	require.EqualError(t, err, `wasm error: invalid table access
+wasm stack trace:
+	.trap()`)
+}
@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/tetratelabs/wazero/api"
@@ -16,6 +17,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/frontend"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
 	"github.com/tetratelabs/wazero/internal/filecache"
 	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/version"
@@ -27,14 +29,22 @@ type (
 	engine struct {
 		wazeroVersion   string
 		fileCache       filecache.Cache
+		enabledFeatures api.CoreFeatures
 		compiledModules map[wasm.ModuleID]*compiledModule
 		// sortedCompiledModules is a list of compiled modules sorted by the initial address of the executable.
 		sortedCompiledModules []*compiledModule
-		mux                   sync.RWMutex
+		// lazySegments are the executables of the lazily compiled modules added after the first ones, which are
+		// rare enough to be searched linearly.
+		lazySegments []lazySegmentOwner
+		mux          sync.RWMutex
 		// sharedFunctions is compiled functions shared by all modules.
 		sharedFunctions *sharedFunctions
 		// setFinalizer defaults to runtime.SetFinalizer, but overridable for tests.
 		setFinalizer func(obj interface{}, finalizer interface{})
+		// symbols writes the symbols of the compiled code if enabled by RuntimeConfig.WithSymbolExport.
+		symbols *symbolWriter
+		// symbolsErr is the error opening the files for symbols, which is returned by CompileModule.
+		symbolsErr error
 
 		// The followings are reused for compiling shared functions.
 		machine backend.Machine
@@ -58,7 +68,11 @@ type (
 		// memoryWait64Executable is a compiled trampoline executable for memory.wait64 builtin function
 		memoryWait64Executable []byte
 		// memoryNotifyExecutable is a compiled trampoline executable for memory.notify builtin function
-		memoryNotifyExecutable    []byte
+		memoryNotifyExecutable []byte
+		// exceptionExecutable is a compiled trampoline executable for the builtin function to throw and catch exceptions.
+		exceptionExecutable []byte
+		// sampleExecutable is a compiled trampoline executable for taking the stack sample requested by the profiler.
+		sampleExecutable          []byte
 		listenerBeforeTrampolines map[*wasm.FunctionType][]byte
 		listenerAfterTrampolines  map[*wasm.FunctionType][]byte
 	}
@@ -80,11 +94,21 @@ type (
 		offsets         wazevoapi.ModuleContextOffsetData
 		sharedFunctions *sharedFunctions
 		sourceMap       sourceMap
+		// lazy is set if the local functions are compiled on their first call.
+		lazy *lazyCompilation
 	}
 
 	executables struct {
 		executable     []byte
 		entryPreambles [][]byte
+		// lazySegments are the executables of a lazily compiled module added after executable.
+		lazySegments [][]byte
+	}
+
+	// lazySegmentOwner is an executable of lazySegments with the compiled module owning it.
+	lazySegmentOwner struct {
+		executable []byte
+		cm         *compiledModule
 	}
 )
 
@@ -94,14 +118,18 @@ type sourceMap struct {
 	// in other words executableOffsets[i] is the offset of the executable which corresponds to the offset of a Wasm
 	// binary pointed by wasmBinaryOffsets[i].
 	executableOffsets []uintptr
-	// wasmBinaryOffsets is the counterpart of executableOffsets.
+	// wasmBinaryOffsets is the counterpart of executableOffsets. The ones in the bodies of the inlined functions are
+	// encoded with the inlined calls, which are decoded by frontend.InlinedCallOf.
 	wasmBinaryOffsets []uint64
 }
 
-var _ wasm.Engine = (*engine)(nil)
+var _ wasm.ArtifactEngine = (*engine)(nil)
+
+// errTailCallWithListener is returned when the module using tail calls is compiled with the function listeners.
+var errTailCallWithListener = errors.New("tail calls with function listeners are not supported by the compiler: use the interpreter")
 
 // NewEngine returns the implementation of wasm.Engine.
-func NewEngine(ctx context.Context, _ api.CoreFeatures, fc filecache.Cache) wasm.Engine {
+func NewEngine(ctx context.Context, enabledFeatures api.CoreFeatures, fc filecache.Cache) wasm.Engine {
 	machine := newMachine()
 	be := backend.NewCompiler(ctx, machine, ssa.NewBuilder())
 	e := &engine{
@@ -110,17 +138,29 @@ func NewEngine(ctx context.Context, _ api.CoreFeatures, fc filecache.Cache) wasm
 		machine:         machine,
 		be:              be,
 		fileCache:       fc,
+		enabledFeatures: enabledFeatures,
 		wazeroVersion:   version.GetWazeroVersion(),
 	}
+	e.symbols, e.symbolsErr = newSymbolWriter(ctx)
 	e.compileSharedFunctions()
 	return e
 }
 
 // CompileModule implements wasm.Engine.
 func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) (err error) {
-	if wazevoapi.PerfMapEnabled {
-		wazevoapi.PerfMap.Lock()
-		defer wazevoapi.PerfMap.Unlock()
+	if module.UsesGC() {
+		return errors.New("GC is not supported by the compiler: use the interpreter")
+	}
+	if !simdSupported && module.UsesV128() {
+		return fmt.Errorf("SIMD is not supported by the compiler on %s: use the interpreter", runtime.GOARCH)
+	}
+	if len(listeners) > 0 && module.UsesTailCall() {
+		// The listener of the caller must be called after the callee returns, so the frame cannot be reused.
+		return errTailCallWithListener
+	}
+
+	if e.symbolsErr != nil {
+		return e.symbolsErr
 	}
 
 	if _, ok, err := e.getCompiledModule(module, listeners, ensureTermination); ok { // cache hit!
@@ -139,6 +179,7 @@ func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listene
 	if err = e.addCompiledModule(module, cm); err != nil {
 		return err
 	}
+	e.symbols.addModule(cm)
 
 	if wazevoapi.DeterministicCompilationVerifierEnabled {
 		for i := 0; i < wazevoapi.DeterministicCompilationVerifyingIter; i++ {
@@ -172,18 +213,14 @@ func (exec *executables) compileEntryPreambles(m *wasm.Module, machine backend.M
 		buf := machine.CompileEntryPreamble(&sig)
 		executable := mmapExecutable(buf)
 		exec.entryPreambles[i] = executable
-
-		if wazevoapi.PerfMapEnabled {
-			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&executable[0])),
-				uint64(len(executable)), fmt.Sprintf("entry_preamble::type=%s", typ.String()))
-		}
 	}
 }
 
-func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) (*compiledModule, error) {
+func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) (_ *compiledModule, err error) {
 	withListener := len(listeners) > 0
 	cm := &compiledModule{
-		offsets: wazevoapi.NewModuleContextOffsetData(module, withListener), parent: e, module: module,
+		offsets: wazevoapi.NewModuleContextOffsetData(module, withListener,
+			e.enabledFeatures.IsEnabled(experimental.CoreFeaturesFunctionReferences)), parent: e, module: module,
 		ensureTermination: ensureTermination,
 		executables:       &executables{},
 	}
@@ -197,6 +234,10 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 		return cm, nil
 	}
 
+	if lazy, _ := ctx.Value(expctxkeys.LazyCompilationKey{}).(bool); lazy {
+		return e.compileModuleLazily(ctx, cm)
+	}
+
 	rels := make([]backend.RelocationInfo, 0)
 	refToBinaryOffset := make([]int, importedFns+localFns)
 
@@ -207,17 +248,20 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 
 	needSourceInfo := module.DWARFLines != nil
 
-	// Creates new compiler instances which are reused for each function.
-	ssaBuilder := ssa.NewBuilder()
-	fe := frontend.NewFrontendCompiler(module, ssaBuilder, &cm.offsets, ensureTermination, withListener, needSourceInfo)
-	machine := newMachine()
-	be := backend.NewCompiler(ctx, machine, ssaBuilder)
+	// Creates new compiler instances which are reused for each function, one per worker.
+	compilers := make([]*functionCompiler, compilationWorkers(ctx, localFns))
+	for w := range compilers {
+		compilers[w] = e.newFunctionCompiler(ctx, cm, listeners, needSourceInfo)
+	}
+	machine, be := compilers[0].machine, compilers[0].be
 
 	cm.executables.compileEntryPreambles(module, machine, be)
-
-	totalSize := 0 // Total binary size of the executable.
-	cm.functionOffsets = make([]int, localFns)
-	bodies := make([][]byte, localFns)
+	defer func() {
+		if err != nil {
+			// The finalizer is set only on success, so the executables are released here.
+			executablesFinalizer(cm.executables)
+		}
+	}()
 
 	// Trampoline relocation related variables.
 	trampolineInterval, callTrampolineIslandSize, err := machine.CallTrampolineIslandInfo(localFns)
@@ -227,39 +271,37 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 	needCallTrampoline := callTrampolineIslandSize > 0
 	var callTrampolineIslandOffsets []int // Holds the offsets of trampoline islands.
 
-	for i := range module.CodeSection {
-		if wazevoapi.DeterministicCompilationVerifierEnabled {
-			i = wazevoapi.DeterministicCompilationVerifierGetRandomizedLocalFunctionIndex(ctx, i)
-		}
+	compiled, err := e.compileLocalWasmFunctions(ctx, module, listeners, compilers, needSourceInfo)
+	if err != nil {
+		return nil, err
+	}
 
-		fidx := wasm.Index(i + importedFns)
+	// The source map is needed for DWARF, or to restore the frames of the inlined functions.
+	needSourceMap := needSourceInfo
+	for i := range compiled {
+		needSourceMap = needSourceMap || len(compiled[i].sourceOffsets) > 0
+	}
 
-		if wazevoapi.NeedFunctionNameInContext {
-			def := module.FunctionDefinition(fidx)
-			name := def.DebugName()
-			if len(def.ExportNames()) > 0 {
-				name = def.ExportNames()[0]
-			}
-			ctx = wazevoapi.SetCurrentFunctionName(ctx, i, fmt.Sprintf("[%d/%d]%s", i, len(module.CodeSection)-1, name))
-		}
+	totalSize := 0 // Total binary size of the executable.
+	cm.functionOffsets = make([]int, localFns)
 
-		needListener := len(listeners) > 0 && listeners[i] != nil
-		body, relsPerFunc, err := e.compileLocalWasmFunction(ctx, module, wasm.Index(i), fe, ssaBuilder, be, needListener)
-		if err != nil {
-			return nil, fmt.Errorf("compile function %d/%d: %v", i, len(module.CodeSection)-1, err)
-		}
+	// Now that all the functions are compiled, lay them out in the index order so that the executable doesn't
+	// depend on the order in which they were compiled.
+	for i := range compiled {
+		cf := &compiled[i]
+		fidx := wasm.Index(i + importedFns)
 
 		// Align 16-bytes boundary.
 		totalSize = (totalSize + 15) &^ 15
 		cm.functionOffsets[i] = totalSize
 
-		if needSourceInfo {
+		if needSourceMap {
 			// At the beginning of the function, we add the offset of the function body so that
 			// we can resolve the source location of the call site of before listener call.
 			cm.sourceMap.executableOffsets = append(cm.sourceMap.executableOffsets, uintptr(totalSize))
-			cm.sourceMap.wasmBinaryOffsets = append(cm.sourceMap.wasmBinaryOffsets, module.CodeSection[i].BodyOffsetInCodeSection)
+			cm.sourceMap.wasmBinaryOffsets = append(cm.sourceMap.wasmBinaryOffsets, functionStartSourceOffset(module, wasm.Index(i)))
 
-			for _, info := range be.SourceOffsetInfo() {
+			for _, info := range cf.sourceOffsets {
 				cm.sourceMap.executableOffsets = append(cm.sourceMap.executableOffsets, uintptr(totalSize)+uintptr(info.ExecutableOffset))
 				cm.sourceMap.wasmBinaryOffsets = append(cm.sourceMap.wasmBinaryOffsets, uint64(info.SourceOffset))
 			}
@@ -270,16 +312,12 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 
 		// At this point, relocation offsets are relative to the start of the function body,
 		// so we adjust it to the start of the executable.
-		for _, r := range relsPerFunc {
+		for _, r := range cf.rels {
 			r.Offset += int64(totalSize)
 			rels = append(rels, r)
 		}
 
-		bodies[i] = body
-		totalSize += len(body)
-		if wazevoapi.PrintMachineCodeHexPerFunction {
-			fmt.Printf("[[[machine code for %s]]]\n%s\n\n", wazevoapi.GetCurrentFunctionName(ctx), hex.EncodeToString(body))
-		}
+		totalSize += len(cf.body)
 
 		if needCallTrampoline {
 			// If the total size exceeds the trampoline interval, we need to add a trampoline island.
@@ -297,16 +335,12 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 	}
 	cm.executable = executable
 
-	for i, b := range bodies {
+	for i := range compiled {
 		offset := cm.functionOffsets[i]
-		copy(executable[offset:], b)
-	}
-
-	if wazevoapi.PerfMapEnabled {
-		wazevoapi.PerfMap.Flush(uintptr(unsafe.Pointer(&executable[0])), cm.functionOffsets)
+		copy(executable[offset:], compiled[i].body)
 	}
 
-	if needSourceInfo {
+	if needSourceMap {
 		for i := range cm.sourceMap.executableOffsets {
 			cm.sourceMap.executableOffsets[i] += uintptr(unsafe.Pointer(&cm.executable[0]))
 		}
@@ -317,8 +351,8 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 		machine.ResolveRelocations(refToBinaryOffset, importedFns, executable, rels, callTrampolineIslandOffsets)
 	}
 
-	if runtime.GOARCH == "arm64" {
-		// On arm64, we cannot give all of rwx at the same time, so we change it to exec.
+	if runtime.GOARCH != "amd64" {
+		// On arm64 and riscv64, we cannot give all of rwx at the same time, so we change it to exec.
 		if err = platform.MprotectRX(executable); err != nil {
 			return nil, err
 		}
@@ -328,6 +362,177 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 	return cm, nil
 }
 
+// functionCompiler holds the compilers used by a single worker to compile local functions one after another.
+type functionCompiler struct {
+	ssaBuilder ssa.Builder
+	fe         *frontend.Compiler
+	machine    backend.Machine
+	be         backend.Compiler
+}
+
+// newFunctionCompiler returns a new functionCompiler for the local functions of the module compiled into cm.
+// listeners are the ones of the local functions if any.
+func (e *engine) newFunctionCompiler(ctx context.Context, cm *compiledModule, listeners []experimental.FunctionListener, needSourceInfo bool) *functionCompiler {
+	ssaBuilder := ssa.NewBuilder()
+	machine := newMachine()
+	exceptionHandling := e.enabledFeatures.IsEnabled(experimental.CoreFeaturesExceptionHandling)
+	fe := frontend.NewFrontendCompiler(cm.module, ssaBuilder, &cm.offsets, cm.ensureTermination, len(listeners) > 0,
+		needSourceInfo, exceptionHandling)
+	fe.EnableInlining(listeners)
+	return &functionCompiler{
+		ssaBuilder: ssaBuilder,
+		fe:         fe,
+		machine:    machine,
+		be:         backend.NewCompiler(ctx, machine, ssaBuilder),
+	}
+}
+
+// appendSourceOffsets appends the source offsets of the function compiled last to dst. All of them are kept if
+// needSourceInfo is set. Otherwise, only the ones of the inlined functions are kept so that their frames can be
+// restored from the source map, and the others are zeroed out and merged.
+func (fc *functionCompiler) appendSourceOffsets(dst []backend.SourceOffsetInfo, needSourceInfo bool) []backend.SourceOffsetInfo {
+	if needSourceInfo {
+		return append(dst, fc.be.SourceOffsetInfo()...)
+	} else if fc.fe.InlinedCalls() == 0 {
+		return dst
+	}
+	for _, info := range fc.be.SourceOffsetInfo() {
+		if _, ok := frontend.InlinedCallOf(info.SourceOffset); !ok {
+			info.SourceOffset = 0
+		}
+		if n := len(dst); n > 0 && dst[n-1].SourceOffset == info.SourceOffset {
+			continue
+		}
+		dst = append(dst, info)
+	}
+	return dst
+}
+
+// functionStartSourceOffset returns the source offset at the beginning of the local function of the given index in
+// the source map, which is zero if the module has no DWARF.
+func functionStartSourceOffset(module *wasm.Module, index wasm.Index) uint64 {
+	if module.DWARFLines == nil {
+		return 0
+	}
+	return module.CodeSection[index].BodyOffsetInCodeSection
+}
+
+// compiledFunction is the machine code of a local function before it is placed in the executable.
+type compiledFunction struct {
+	body []byte
+	// rels are the relocations relative to the start of body.
+	rels []backend.RelocationInfo
+	// sourceOffsets are the source offsets relative to the start of body. This is only set if the module has DWARF, or
+	// the function has inlined calls.
+	sourceOffsets []backend.SourceOffsetInfo
+	err           error
+}
+
+// compilationWorkers returns the number of workers used to compile the given number of local functions.
+func compilationWorkers(ctx context.Context, localFns int) int {
+	if wazevoapi.NeedSequentialCompilation {
+		return 1
+	}
+	workers, ok := ctx.Value(expctxkeys.CompilationWorkersKey{}).(int)
+	if !ok || workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > localFns {
+		workers = localFns
+	}
+	return workers
+}
+
+// compileLocalWasmFunctions compiles all the local functions of the module concurrently, one worker per compiler.
+// The results are indexed by the local function index, and don't depend on the number of workers.
+func (e *engine) compileLocalWasmFunctions(
+	ctx context.Context,
+	module *wasm.Module,
+	listeners []experimental.FunctionListener,
+	compilers []*functionCompiler,
+	needSourceInfo bool,
+) ([]compiledFunction, error) {
+	localFns := len(module.CodeSection)
+	compiled := make([]compiledFunction, localFns)
+
+	var next atomic.Int64
+	var failed atomic.Bool
+	panics := make([]any, len(compilers))
+	work := func(w int) {
+		defer func() {
+			if r := recover(); r != nil {
+				panics[w] = r
+				failed.Store(true)
+			}
+		}()
+
+		fc := compilers[w]
+		for !failed.Load() {
+			i := int(next.Add(1) - 1)
+			if i >= localFns {
+				return
+			}
+			if wazevoapi.DeterministicCompilationVerifierEnabled {
+				i = wazevoapi.DeterministicCompilationVerifierGetRandomizedLocalFunctionIndex(ctx, i)
+			}
+
+			fctx := ctx
+			if wazevoapi.NeedFunctionNameInContext {
+				def := module.FunctionDefinition(wasm.Index(i) + module.ImportFunctionCount)
+				name := def.DebugName()
+				if len(def.ExportNames()) > 0 {
+					name = def.ExportNames()[0]
+				}
+				fctx = wazevoapi.SetCurrentFunctionName(ctx, i, fmt.Sprintf("[%d/%d]%s", i, localFns-1, name))
+			}
+
+			cf := &compiled[i]
+			needListener := len(listeners) > 0 && listeners[i] != nil
+			body, rels, err := e.compileLocalWasmFunction(fctx, module, wasm.Index(i), fc.fe, fc.ssaBuilder, fc.be, needListener)
+			if err != nil {
+				cf.err = fmt.Errorf("compile function %d/%d: %v", i, localFns-1, err)
+				failed.Store(true)
+				return
+			}
+
+			// The relocations and source offsets are reused by the backend, so they must be copied.
+			cf.body = body
+			cf.rels = append(cf.rels, rels...)
+			cf.sourceOffsets = fc.appendSourceOffsets(cf.sourceOffsets, needSourceInfo)
+			if wazevoapi.PrintMachineCodeHexPerFunction {
+				fmt.Printf("[[[machine code for %s]]]\n%s\n\n", wazevoapi.GetCurrentFunctionName(fctx), hex.EncodeToString(body))
+			}
+		}
+	}
+
+	if len(compilers) == 1 {
+		work(0)
+	} else {
+		var wg sync.WaitGroup
+		for w := range compilers {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				work(w)
+			}(w)
+		}
+		wg.Wait()
+	}
+
+	for _, p := range panics {
+		if p != nil {
+			panic(p)
+		}
+	}
+	// Report the error of the lowest index so that the error doesn't depend on the scheduling of the workers.
+	for i := range compiled {
+		if err := compiled[i].err; err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
 func (e *engine) compileLocalWasmFunction(
 	ctx context.Context,
 	module *wasm.Module,
@@ -384,7 +589,10 @@ func (e *engine) compileHostModule(ctx context.Context, module *wasm.Module, lis
 	be := backend.NewCompiler(ctx, machine, ssa.NewBuilder())
 
 	num := len(module.CodeSection)
-	cm := &compiledModule{module: module, listeners: listeners, executables: &executables{}}
+	cm := &compiledModule{
+		module: module, listeners: listeners, executables: &executables{},
+		offsets: newHostModuleContextOffsetData(module),
+	}
 	cm.functionOffsets = make([]int, num)
 	totalSize := 0 // Total binary size of the executable.
 	bodies := make([][]byte, num)
@@ -439,14 +647,6 @@ func (e *engine) compileHostModule(ctx context.Context, module *wasm.Module, lis
 		}
 		body := be.Buf()
 
-		if wazevoapi.PerfMapEnabled {
-			name := module.FunctionDefinition(wasm.Index(i)).DebugName()
-			wazevoapi.PerfMap.AddModuleEntry(i,
-				int64(totalSize),
-				uint64(len(body)),
-				fmt.Sprintf("trampoline:%s", name))
-		}
-
 		// TODO: optimize as zero copy.
 		copied := make([]byte, len(body))
 		copy(copied, body)
@@ -471,12 +671,8 @@ func (e *engine) compileHostModule(ctx context.Context, module *wasm.Module, lis
 		copy(executable[offset:], b)
 	}
 
-	if wazevoapi.PerfMapEnabled {
-		wazevoapi.PerfMap.Flush(uintptr(unsafe.Pointer(&executable[0])), cm.functionOffsets)
-	}
-
-	if runtime.GOARCH == "arm64" {
-		// On arm64, we cannot give all of rwx at the same time, so we change it to exec.
+	if runtime.GOARCH != "amd64" {
+		// On arm64 and riscv64, we cannot give all of rwx at the same time, so we change it to exec.
 		if err = platform.MprotectRX(executable); err != nil {
 			return nil, err
 		}
@@ -490,6 +686,7 @@ func (e *engine) Close() (err error) {
 	e.mux.Lock()
 	defer e.mux.Unlock()
 	e.sortedCompiledModules = nil
+	e.lazySegments = nil
 	e.compiledModules = nil
 	e.sharedFunctions = nil
 	return nil
@@ -511,6 +708,9 @@ func (e *engine) DeleteCompiledModule(m *wasm.Module) {
 		if len(cm.executable) > 0 {
 			e.deleteCompiledModuleFromSortedList(cm)
 		}
+		if len(cm.executables.lazySegments) > 0 {
+			e.deleteLazySegments(cm)
+		}
 		delete(e.compiledModules, m.ID)
 	}
 }
@@ -539,10 +739,35 @@ func (e *engine) deleteCompiledModuleFromSortedList(cm *compiledModule) {
 	e.sortedCompiledModules = e.sortedCompiledModules[:len(e.sortedCompiledModules)-1]
 }
 
+// addLazySegment adds the executable of the lazily compiled module to the ones searched by compiledModuleOfAddr.
+func (e *engine) addLazySegment(cm *compiledModule, executable []byte) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.lazySegments = append(e.lazySegments, lazySegmentOwner{executable: executable, cm: cm})
+}
+
+// deleteLazySegments deletes the executables added by addLazySegment for the compiled module.
+func (e *engine) deleteLazySegments(cm *compiledModule) {
+	segments := e.lazySegments[:0]
+	for _, s := range e.lazySegments {
+		if s.cm != cm {
+			segments = append(segments, s)
+		}
+	}
+	clear(e.lazySegments[len(segments):])
+	e.lazySegments = segments
+}
+
 func (e *engine) compiledModuleOfAddr(addr uintptr) *compiledModule {
 	e.mux.RLock()
 	defer e.mux.RUnlock()
 
+	for _, s := range e.lazySegments {
+		if checkAddrInBytes(addr, s.executable) {
+			return s.cm
+		}
+	}
+
 	index := sort.Search(len(e.sortedCompiledModules), func(i int) bool {
 		return uintptr(unsafe.Pointer(&e.sortedCompiledModules[i].executable[0])) > addr
 	})
@@ -578,7 +803,7 @@ func (e *engine) NewModuleEngine(m *wasm.Module, mi *wasm.ModuleInstance) (wasm.
 	me.listeners = compiled.listeners
 
 	if m.IsHostModule {
-		me.opaque = buildHostModuleOpaque(m, compiled.listeners)
+		me.opaque = buildHostModuleOpaque(mi, compiled.listeners)
 		me.opaquePtr = &me.opaque[0]
 	} else {
 		if size := compiled.offsets.TotalSize; size != 0 {
@@ -599,14 +824,11 @@ func (e *engine) compileSharedFunctions() {
 	e.be.Init()
 	{
 		src := e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeGrowMemory, &ssa.Signature{
-			Params:  []ssa.Type{ssa.TypeI64 /* exec context */, ssa.TypeI32},
+			Params:  []ssa.Type{ssa.TypeI64 /* exec context */, ssa.TypeI32 /* num */, ssa.TypeI32 /* memory index */},
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false)
 		e.sharedFunctions.memoryGrowExecutable = mmapExecutable(src)
-		if wazevoapi.PerfMapEnabled {
-			exe := e.sharedFunctions.memoryGrowExecutable
-			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&exe[0])), uint64(len(exe)), "memory_grow_trampoline")
-		}
+		e.symbols.add(e.sharedFunctions.memoryGrowExecutable, "memory_grow_trampoline", nil)
 	}
 
 	e.be.Init()
@@ -616,10 +838,7 @@ func (e *engine) compileSharedFunctions() {
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false)
 		e.sharedFunctions.tableGrowExecutable = mmapExecutable(src)
-		if wazevoapi.PerfMapEnabled {
-			exe := e.sharedFunctions.tableGrowExecutable
-			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&exe[0])), uint64(len(exe)), "table_grow_trampoline")
-		}
+		e.symbols.add(e.sharedFunctions.tableGrowExecutable, "table_grow_trampoline", nil)
 	}
 
 	e.be.Init()
@@ -629,10 +848,16 @@ func (e *engine) compileSharedFunctions() {
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false)
 		e.sharedFunctions.checkModuleExitCode = mmapExecutable(src)
-		if wazevoapi.PerfMapEnabled {
-			exe := e.sharedFunctions.checkModuleExitCode
-			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&exe[0])), uint64(len(exe)), "check_module_exit_code_trampoline")
-		}
+		e.symbols.add(e.sharedFunctions.checkModuleExitCode, "check_module_exit_code_trampoline", nil)
+	}
+
+	e.be.Init()
+	{
+		src := e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeSample, &ssa.Signature{
+			Params: []ssa.Type{ssa.TypeI64 /* exec context */},
+		}, false)
+		e.sharedFunctions.sampleExecutable = mmapExecutable(src)
+		e.symbols.add(e.sharedFunctions.sampleExecutable, "sample_trampoline", nil)
 	}
 
 	e.be.Init()
@@ -642,65 +867,62 @@ func (e *engine) compileSharedFunctions() {
 			Results: []ssa.Type{ssa.TypeI64}, // returns the function reference.
 		}, false)
 		e.sharedFunctions.refFuncExecutable = mmapExecutable(src)
-		if wazevoapi.PerfMapEnabled {
-			exe := e.sharedFunctions.refFuncExecutable
-			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&exe[0])), uint64(len(exe)), "ref_func_trampoline")
-		}
+		e.symbols.add(e.sharedFunctions.refFuncExecutable, "ref_func_trampoline", nil)
 	}
 
 	e.be.Init()
 	{
 		src := e.machine.CompileStackGrowCallSequence()
 		e.sharedFunctions.stackGrowExecutable = mmapExecutable(src)
-		if wazevoapi.PerfMapEnabled {
-			exe := e.sharedFunctions.stackGrowExecutable
-			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&exe[0])), uint64(len(exe)), "stack_grow_trampoline")
-		}
+		e.symbols.add(e.sharedFunctions.stackGrowExecutable, "stack_grow_trampoline", nil)
 	}
 
 	e.be.Init()
 	{
 		src := e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeMemoryWait32, &ssa.Signature{
-			// exec context, timeout, expected, addr
-			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+			// exec context, timeout, expected, addr, memory index
+			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32},
 			// Returns the status.
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false)
 		e.sharedFunctions.memoryWait32Executable = mmapExecutable(src)
-		if wazevoapi.PerfMapEnabled {
-			exe := e.sharedFunctions.memoryWait32Executable
-			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&exe[0])), uint64(len(exe)), "memory_wait32_trampoline")
-		}
+		e.symbols.add(e.sharedFunctions.memoryWait32Executable, "memory_wait32_trampoline", nil)
 	}
 
 	e.be.Init()
 	{
 		src := e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeMemoryWait64, &ssa.Signature{
-			// exec context, timeout, expected, addr
-			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64},
+			// exec context, timeout, expected, addr, memory index
+			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32},
 			// Returns the status.
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false)
 		e.sharedFunctions.memoryWait64Executable = mmapExecutable(src)
-		if wazevoapi.PerfMapEnabled {
-			exe := e.sharedFunctions.memoryWait64Executable
-			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&exe[0])), uint64(len(exe)), "memory_wait64_trampoline")
-		}
+		e.symbols.add(e.sharedFunctions.memoryWait64Executable, "memory_wait64_trampoline", nil)
 	}
 
 	e.be.Init()
 	{
 		src := e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeMemoryNotify, &ssa.Signature{
-			// exec context, count, addr
-			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+			// exec context, count, addr, memory index
+			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32},
 			// Returns the number notified.
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false)
 		e.sharedFunctions.memoryNotifyExecutable = mmapExecutable(src)
-		if wazevoapi.PerfMapEnabled {
-			exe := e.sharedFunctions.memoryNotifyExecutable
-			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&exe[0])), uint64(len(exe)), "memory_notify_trampoline")
-		}
+		e.symbols.add(e.sharedFunctions.memoryNotifyExecutable, "memory_notify_trampoline", nil)
+	}
+
+	e.be.Init()
+	{
+		src := e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeException, &ssa.Signature{
+			// exec context, wazevoapi.ExceptionOp, and the tag index or exnref.
+			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+			// Returns the result of the operation.
+			Results: []ssa.Type{ssa.TypeI64},
+		}, false)
+		e.sharedFunctions.exceptionExecutable = mmapExecutable(src)
+		e.symbols.add(e.sharedFunctions.exceptionExecutable, "exception_trampoline", nil)
 	}
 
 	e.setFinalizer(e.sharedFunctions, sharedFunctionsFinalizer)
@@ -731,6 +953,12 @@ func sharedFunctionsFinalizer(sf *sharedFunctions) {
 	if err := platform.MunmapCodeSegment(sf.memoryNotifyExecutable); err != nil {
 		panic(err)
 	}
+	if err := platform.MunmapCodeSegment(sf.exceptionExecutable); err != nil {
+		panic(err)
+	}
+	if err := platform.MunmapCodeSegment(sf.sampleExecutable); err != nil {
+		panic(err)
+	}
 	for _, f := range sf.listenerBeforeTrampolines {
 		if err := platform.MunmapCodeSegment(f); err != nil {
 			panic(err)
@@ -750,6 +978,8 @@ func sharedFunctionsFinalizer(sf *sharedFunctions) {
 	sf.memoryWait32Executable = nil
 	sf.memoryWait64Executable = nil
 	sf.memoryNotifyExecutable = nil
+	sf.exceptionExecutable = nil
+	sf.sampleExecutable = nil
 	sf.listenerBeforeTrampolines = nil
 	sf.listenerAfterTrampolines = nil
 }
@@ -768,6 +998,13 @@ func executablesFinalizer(exec *executables) {
 		}
 	}
 	exec.entryPreambles = nil
+
+	for _, s := range exec.lazySegments {
+		if err := platform.MunmapCodeSegment(s); err != nil {
+			panic(err)
+		}
+	}
+	exec.lazySegments = nil
 }
 
 func mmapExecutable(src []byte) []byte {
@@ -778,8 +1015,8 @@ func mmapExecutable(src []byte) []byte {
 
 	copy(executable, src)
 
-	if runtime.GOARCH == "arm64" {
-		// On arm64, we cannot give all of rwx at the same time, so we change it to exec.
+	if runtime.GOARCH != "amd64" {
+		// On arm64 and riscv64, we cannot give all of rwx at the same time, so we change it to exec.
 		if err = platform.MprotectRX(executable); err != nil {
 			panic(err)
 		}
@@ -788,6 +1025,9 @@ func mmapExecutable(src []byte) []byte {
 }
 
 func (cm *compiledModule) functionIndexOf(addr uintptr) wasm.Index {
+	if l := cm.lazy; l != nil {
+		return l.functionIndexOf(addr, cm.functionOffsets)
+	}
 	addr -= uintptr(unsafe.Pointer(&cm.executable[0]))
 	offset := cm.functionOffsets
 	index := sort.Search(len(offset), func(i int) bool {
@@ -825,11 +1065,39 @@ func (e *engine) getListenerTrampolineForType(functionType *wasm.FunctionType) (
 	return &beforeBuf[0], &afterBuf[0]
 }
 
+// getSourceOffset returns the offset in the original Wasm binary of the instruction at pc, which is the one in the
+// inlined function if pc is in its body.
 func (cm *compiledModule) getSourceOffset(pc uintptr) uint64 {
-	offsets := cm.sourceMap.executableOffsets
-	if len(offsets) == 0 {
+	return cm.decodeSourceOffset(cm.sourceMapValue(pc))
+}
+
+// decodeSourceOffset returns the offset in the original Wasm binary of the value in the source map, which is encoded
+// with the inlined call if any.
+func (cm *compiledModule) decodeSourceOffset(offset uint64) uint64 {
+	if call, ok := frontend.InlinedCallOf(ssa.SourceOffset(offset)); ok {
+		return cm.module.CodeSection[call.Callee].BodyOffsetInCodeSection + call.CalleeOffset
+	}
+	return offset
+}
+
+// inlinedCallAt returns the local index of the function inlined at pc, and the offset in the original Wasm binary of
+// the call to it in the caller, if any.
+func (cm *compiledModule) inlinedCallAt(pc uintptr) (callee wasm.Index, callSiteOffset uint64, ok bool) {
+	call, ok := frontend.InlinedCallOf(ssa.SourceOffset(cm.sourceMapValue(pc)))
+	if !ok {
+		return
+	}
+	caller := cm.functionIndexOf(pc)
+	return call.Callee, cm.module.CodeSection[caller].BodyOffsetInCodeSection + call.CallSite, true
+}
+
+// sourceMapValue returns the value of the source map at pc as is.
+func (cm *compiledModule) sourceMapValue(pc uintptr) uint64 {
+	sm := cm.sourceMapOf(pc)
+	if sm == nil || len(sm.executableOffsets) == 0 {
 		return 0
 	}
+	offsets := sm.executableOffsets
 
 	index := sort.Search(len(offsets), func(i int) bool {
 		return offsets[i] >= pc
@@ -839,5 +1107,14 @@ func (cm *compiledModule) getSourceOffset(pc uintptr) uint64 {
 	if index < 0 {
 		return 0
 	}
-	return cm.sourceMap.wasmBinaryOffsets[index]
+	return sm.wasmBinaryOffsets[index]
+}
+
+// sourceMapOf returns the source map of the executable containing pc, which is the one of the segment for a lazily
+// compiled module, or nil if none.
+func (cm *compiledModule) sourceMapOf(pc uintptr) *sourceMap {
+	if l := cm.lazy; l != nil {
+		return l.sourceMap(pc)
+	}
+	return &cm.sourceMap
 }
@@ -5,12 +5,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
 	"runtime"
 	"unsafe"
 
+	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
@@ -27,7 +29,7 @@ var crc = crc32.MakeTable(crc32.Castagnoli)
 // fileCacheKey returns a key for the file cache.
 // In order to avoid collisions with the existing compiler, we do not use m.ID directly,
 // but instead we rehash it with magic.
-func fileCacheKey(m *wasm.Module) (ret filecache.Key) {
+func fileCacheKey(m *wasm.Module, enabledFeatures api.CoreFeatures) (ret filecache.Key) {
 	s := sha256.New()
 	s.Write(m.ID[:])
 	s.Write(magic)
@@ -37,6 +39,9 @@ func fileCacheKey(m *wasm.Module) (ret filecache.Key) {
 	// Reuse the `ret` buffer to write the first 8 bytes of the CPU features so that we can avoid the allocation.
 	binary.LittleEndian.PutUint64(ret[:8], cpu)
 	s.Write(ret[:8])
+	// Write the enabled features as they change the compiled code, e.g. exception handling.
+	binary.LittleEndian.PutUint64(ret[:8], uint64(enabledFeatures))
+	s.Write(ret[:8])
 	// Finally, write the hash to the ret buffer.
 	s.Sum(ret[:0])
 	return
@@ -57,32 +62,74 @@ func (e *engine) getCompiledModule(module *wasm.Module, listeners []experimental
 	}
 	cm, ok, err = e.getCompiledModuleFromCache(module)
 	if ok {
-		cm.parent = e
-		cm.module = module
-		cm.sharedFunctions = e.sharedFunctions
-		cm.ensureTermination = ensureTermination
-		cm.offsets = wazevoapi.NewModuleContextOffsetData(module, len(listeners) > 0)
-		if len(listeners) > 0 {
-			cm.listeners = listeners
-			cm.listenerBeforeTrampolines = make([]*byte, len(module.TypeSection))
-			cm.listenerAfterTrampolines = make([]*byte, len(module.TypeSection))
-			for i := range module.TypeSection {
-				typ := &module.TypeSection[i]
-				before, after := e.getListenerTrampolineForType(typ)
-				cm.listenerBeforeTrampolines[i] = before
-				cm.listenerAfterTrampolines[i] = after
-			}
+		e.setupDeserializedCompiledModule(module, cm, listeners, ensureTermination)
+	}
+	return
+}
+
+// setupDeserializedCompiledModule completes the compiledModule deserialized by deserializeCompiledModule for the
+// module, and adds it to the memory as if it were compiled by compileModule.
+func (e *engine) setupDeserializedCompiledModule(module *wasm.Module, cm *compiledModule, listeners []experimental.FunctionListener, ensureTermination bool) {
+	cm.parent = e
+	cm.module = module
+	cm.sharedFunctions = e.sharedFunctions
+	cm.ensureTermination = ensureTermination
+	cm.offsets = wazevoapi.NewModuleContextOffsetData(module, len(listeners) > 0,
+		e.enabledFeatures.IsEnabled(experimental.CoreFeaturesFunctionReferences))
+	if len(listeners) > 0 {
+		cm.listeners = listeners
+		cm.listenerBeforeTrampolines = make([]*byte, len(module.TypeSection))
+		cm.listenerAfterTrampolines = make([]*byte, len(module.TypeSection))
+		for i := range module.TypeSection {
+			typ := &module.TypeSection[i]
+			before, after := e.getListenerTrampolineForType(typ)
+			cm.listenerBeforeTrampolines[i] = before
+			cm.listenerAfterTrampolines[i] = after
 		}
-		e.addCompiledModuleToMemory(module, cm)
-		ssaBuilder := ssa.NewBuilder()
-		machine := newMachine()
-		be := backend.NewCompiler(context.Background(), machine, ssaBuilder)
-		cm.executables.compileEntryPreambles(module, machine, be)
+	}
+	e.addCompiledModuleToMemory(module, cm)
+	ssaBuilder := ssa.NewBuilder()
+	machine := newMachine()
+	be := backend.NewCompiler(context.Background(), machine, ssaBuilder)
+	cm.executables.compileEntryPreambles(module, machine, be)
+	e.symbols.addModule(cm)
+
+	// Set the finalizer.
+	e.setFinalizer(cm.executables, executablesFinalizer)
+}
 
-		// Set the finalizer.
-		e.setFinalizer(cm.executables, executablesFinalizer)
+// ExportCompiledModule implements wasm.ArtifactEngine.
+func (e *engine) ExportCompiledModule(module *wasm.Module, w io.Writer) error {
+	cm, ok := e.getCompiledModuleFromMemory(module)
+	if !ok {
+		return errors.New("source module must be compiled before export")
+	} else if cm.lazy != nil {
+		return errors.New("lazily compiled module cannot be exported")
 	}
-	return
+	_, err := io.Copy(w, serializeCompiledModule(e.wazeroVersion, cm))
+	return err
+}
+
+// ImportCompiledModule implements wasm.ArtifactEngine.
+func (e *engine) ImportCompiledModule(_ context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool, r io.Reader) error {
+	if module.UsesGC() {
+		return errors.New("GC is not supported by the compiler: use the interpreter")
+	}
+	if len(listeners) > 0 && module.UsesTailCall() {
+		return errTailCallWithListener
+	}
+
+	if _, ok := e.getCompiledModuleFromMemory(module); ok {
+		return nil
+	}
+	cm, staleCache, err := deserializeCompiledModule(e.wazeroVersion, io.NopCloser(r))
+	if err != nil {
+		return err
+	} else if staleCache {
+		return fmt.Errorf("compiled by a different version of wazero than %s", e.wazeroVersion)
+	}
+	e.setupDeserializedCompiledModule(module, cm, listeners, ensureTermination)
+	return nil
 }
 
 func (e *engine) addCompiledModuleToMemory(m *wasm.Module, cm *compiledModule) {
@@ -102,10 +149,11 @@ func (e *engine) getCompiledModuleFromMemory(module *wasm.Module) (cm *compiledM
 }
 
 func (e *engine) addCompiledModuleToCache(module *wasm.Module, cm *compiledModule) (err error) {
-	if e.fileCache == nil || module.IsHostModule {
+	if e.fileCache == nil || module.IsHostModule || cm.lazy != nil {
+		// The functions of lazily compiled modules are added one by one as they are compiled.
 		return
 	}
-	err = e.fileCache.Add(fileCacheKey(module), serializeCompiledModule(e.wazeroVersion, cm))
+	err = e.fileCache.Add(fileCacheKey(module, e.enabledFeatures), serializeCompiledModule(e.wazeroVersion, cm))
 	return
 }
 
@@ -116,7 +164,7 @@ func (e *engine) getCompiledModuleFromCache(module *wasm.Module) (cm *compiledMo
 
 	// Check if the entries exist in the external cache.
 	var cached io.ReadCloser
-	cached, hit, err = e.fileCache.Get(fileCacheKey(module))
+	cached, hit, err = e.fileCache.Get(fileCacheKey(module, e.enabledFeatures))
 	if !hit || err != nil {
 		return
 	}
@@ -130,7 +178,7 @@ func (e *engine) getCompiledModuleFromCache(module *wasm.Module) (cm *compiledMo
 		hit = false
 		return
 	} else if staleCache {
-		return nil, false, e.fileCache.Delete(fileCacheKey(module))
+		return nil, false, e.fileCache.Delete(fileCacheKey(module, e.enabledFeatures))
 	}
 	return
 }
@@ -246,8 +294,8 @@ func deserializeCompiledModule(wazeroVersion string, reader io.ReadCloser) (cm *
 			return nil, false, fmt.Errorf("compilationcache: checksum mismatch (expected %d, got %d)", expected, checksum)
 		}
 
-		if runtime.GOARCH == "arm64" {
-			// On arm64, we cannot give all of rwx at the same time, so we change it to exec.
+		if runtime.GOARCH != "amd64" {
+			// On arm64 and riscv64, we cannot give all of rwx at the same time, so we change it to exec.
 			if err = platform.MprotectRX(executable); err != nil {
 				return nil, false, err
 			}
@@ -301,3 +349,155 @@ func readUint64(reader io.Reader, b *[8]byte) (uint64, error) {
 	ret := binary.LittleEndian.Uint64(s)
 	return ret, nil
 }
+
+// lazyFunctionFileCacheKey returns a key for the file cache of the local function of the given index, compiled on its
+// first call. See lazyCompilation.
+func lazyFunctionFileCacheKey(m *wasm.Module, enabledFeatures api.CoreFeatures, index wasm.Index) (ret filecache.Key) {
+	// Rehash the key of the module, which covers the CPU and the enabled features, with the index.
+	ret = fileCacheKey(m, enabledFeatures)
+	s := sha256.New()
+	s.Write(ret[:])
+	binary.LittleEndian.PutUint32(ret[:4], index)
+	s.Write(ret[:4])
+	s.Sum(ret[:0])
+	return
+}
+
+func (e *engine) addLazyFunctionToCache(module *wasm.Module, index wasm.Index, cf *compiledFunction) (err error) {
+	if e.fileCache == nil {
+		return
+	}
+	err = e.fileCache.Add(lazyFunctionFileCacheKey(module, e.enabledFeatures, index), serializeLazyFunction(e.wazeroVersion, cf))
+	return
+}
+
+func (e *engine) getLazyFunctionFromCache(module *wasm.Module, index wasm.Index) (cf *compiledFunction, hit bool, err error) {
+	if e.fileCache == nil {
+		return
+	}
+
+	key := lazyFunctionFileCacheKey(module, e.enabledFeatures, index)
+	var cached io.ReadCloser
+	cached, hit, err = e.fileCache.Get(key)
+	if !hit || err != nil {
+		return
+	}
+
+	var staleCache bool
+	// Note: cached.Close is ensured to be called in deserializeLazyFunction.
+	cf, staleCache, err = deserializeLazyFunction(e.wazeroVersion, cached)
+	if err != nil {
+		hit = false
+		return
+	} else if staleCache {
+		return nil, false, e.fileCache.Delete(key)
+	}
+	return
+}
+
+// serializeLazyFunction serializes the function compiled on its first call. Unlike serializeCompiledModule, the
+// relocations are not resolved since the function can be placed anywhere in the executable.
+func serializeLazyFunction(wazeroVersion string, cf *compiledFunction) io.Reader {
+	buf := bytes.NewBuffer(nil)
+	// First 6 byte: WAZEVO header.
+	buf.Write(magic)
+	// Next 1 byte: length of version:
+	buf.WriteByte(byte(len(wazeroVersion)))
+	// Version of wazero.
+	buf.WriteString(wazeroVersion)
+	// The length of the machine code (8 bytes).
+	buf.Write(u64.LeBytes(uint64(len(cf.body))))
+	// Append the machine code.
+	buf.Write(cf.body)
+	// Append checksum.
+	buf.Write(u32.LeBytes(crc32.Checksum(cf.body, crc)))
+	// Number of relocations: 4 bytes.
+	buf.Write(u32.LeBytes(uint32(len(cf.rels))))
+	for _, r := range cf.rels {
+		// The offset of the call in the machine code (8 bytes) and the callee (4 bytes).
+		buf.Write(u64.LeBytes(uint64(r.Offset)))
+		buf.Write(u32.LeBytes(uint32(r.FuncRef)))
+	}
+	// Number of source offsets: 8 bytes.
+	buf.Write(u64.LeBytes(uint64(len(cf.sourceOffsets))))
+	for _, info := range cf.sourceOffsets {
+		buf.Write(u64.LeBytes(uint64(info.ExecutableOffset)))
+		buf.Write(u64.LeBytes(uint64(info.SourceOffset)))
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func deserializeLazyFunction(wazeroVersion string, reader io.ReadCloser) (cf *compiledFunction, staleCache bool, err error) {
+	defer reader.Close()
+
+	header := make([]byte, len(magic)+1 /* version size */)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return nil, false, fmt.Errorf("compilationcache: error reading header: %v", err)
+	}
+	if !bytes.Equal(header[:len(magic)], magic) {
+		return nil, false, fmt.Errorf(
+			"compilationcache: invalid magic number: got %s but want %s", magic, header[:len(magic)])
+	}
+	// Check the version compatibility.
+	cachedVersion := make([]byte, header[len(magic)])
+	if _, err = io.ReadFull(reader, cachedVersion); err != nil {
+		return nil, false, fmt.Errorf("compilationcache: error reading version: %v", err)
+	} else if string(cachedVersion) != wazeroVersion {
+		staleCache = true
+		return
+	}
+
+	var eightBytes [8]byte
+	bodyLen, err := readUint64(reader, &eightBytes)
+	if err != nil {
+		return nil, false, fmt.Errorf("compilationcache: error reading machine code size: %v", err)
+	}
+	cf = &compiledFunction{body: make([]byte, bodyLen)}
+	if _, err = io.ReadFull(reader, cf.body); err != nil {
+		return nil, false, fmt.Errorf("compilationcache: error reading machine code (len=%d): %v", bodyLen, err)
+	}
+	expected := crc32.Checksum(cf.body, crc)
+	if _, err = io.ReadFull(reader, eightBytes[:4]); err != nil {
+		return nil, false, fmt.Errorf("compilationcache: could not read checksum: %v", err)
+	} else if checksum := binary.LittleEndian.Uint32(eightBytes[:4]); expected != checksum {
+		return nil, false, fmt.Errorf("compilationcache: checksum mismatch (expected %d, got %d)", expected, checksum)
+	}
+
+	if _, err = io.ReadFull(reader, eightBytes[:4]); err != nil {
+		return nil, false, fmt.Errorf("compilationcache: error reading relocations size: %v", err)
+	}
+	rels := binary.LittleEndian.Uint32(eightBytes[:4])
+	for i := uint32(0); i < rels; i++ {
+		offset, err := readUint64(reader, &eightBytes)
+		if err != nil {
+			return nil, false, fmt.Errorf("compilationcache: error reading relocation[%d] offset: %v", i, err)
+		}
+		if _, err = io.ReadFull(reader, eightBytes[:4]); err != nil {
+			return nil, false, fmt.Errorf("compilationcache: error reading relocation[%d] callee: %v", i, err)
+		}
+		cf.rels = append(cf.rels, backend.RelocationInfo{
+			Offset:  int64(offset),
+			FuncRef: ssa.FuncRef(binary.LittleEndian.Uint32(eightBytes[:4])),
+		})
+	}
+
+	sourceOffsets, err := readUint64(reader, &eightBytes)
+	if err != nil {
+		return nil, false, fmt.Errorf("compilationcache: error reading source offsets size: %v", err)
+	}
+	for i := uint64(0); i < sourceOffsets; i++ {
+		executableOffset, err := readUint64(reader, &eightBytes)
+		if err != nil {
+			return nil, false, fmt.Errorf("compilationcache: error reading source offset[%d] executable offset: %v", i, err)
+		}
+		sourceOffset, err := readUint64(reader, &eightBytes)
+		if err != nil {
+			return nil, false, fmt.Errorf("compilationcache: error reading source offset[%d] wasm binary offset: %v", i, err)
+		}
+		cf.sourceOffsets = append(cf.sourceOffsets, backend.SourceOffsetInfo{
+			ExecutableOffset: int64(executableOffset),
+			SourceOffset:     ssa.SourceOffset(sourceOffset),
+		})
+	}
+	return
+}
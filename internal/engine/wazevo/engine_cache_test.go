@@ -7,6 +7,9 @@ import (
 	"io"
 	"testing"
 
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/u32"
 	"github.com/tetratelabs/wazero/internal/u64"
@@ -294,13 +297,55 @@ func TestDeserializeCompiledModule(t *testing.T) {
 	}
 }
 
+func TestSerializeLazyFunction(t *testing.T) {
+	cf := &compiledFunction{
+		body: []byte{1, 2, 3, 4, 5},
+		rels: []backend.RelocationInfo{{Offset: 1, FuncRef: 3}, {Offset: 4, FuncRef: 0}},
+		sourceOffsets: []backend.SourceOffsetInfo{
+			{ExecutableOffset: 0, SourceOffset: 10},
+			{ExecutableOffset: 2, SourceOffset: 20},
+		},
+	}
+
+	t.Run("roundtrip", func(t *testing.T) {
+		actual, staleCache, err := deserializeLazyFunction(testVersion,
+			io.NopCloser(serializeLazyFunction(testVersion, cf)))
+		require.NoError(t, err)
+		require.False(t, staleCache)
+		require.Equal(t, cf, actual)
+	})
+
+	t.Run("version mismatch", func(t *testing.T) {
+		_, staleCache, err := deserializeLazyFunction(testVersion,
+			io.NopCloser(serializeLazyFunction("1233123.1.1", cf)))
+		require.NoError(t, err)
+		require.True(t, staleCache)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		serialized, err := io.ReadAll(serializeLazyFunction(testVersion, cf))
+		require.NoError(t, err)
+		serialized[len(magic)+1+len(testVersion)+8] = 0xff // the first byte of the body.
+		_, _, err = deserializeLazyFunction(testVersion, io.NopCloser(bytes.NewReader(serialized)))
+		require.EqualError(t, err, "compilationcache: checksum mismatch (expected 3408432804, got 1397854123)")
+	})
+}
+
+func Test_lazyFunctionFileCacheKey(t *testing.T) {
+	m := &wasm.Module{}
+	key := fileCacheKey(m, api.CoreFeaturesV2)
+	require.NotEqual(t, key, lazyFunctionFileCacheKey(m, api.CoreFeaturesV2, 0))
+	require.NotEqual(t, lazyFunctionFileCacheKey(m, api.CoreFeaturesV2, 0), lazyFunctionFileCacheKey(m, api.CoreFeaturesV2, 1))
+}
+
 func Test_fileCacheKey(t *testing.T) {
 	s := sha256.New()
 	s.Write([]byte("hello world"))
 	m := &wasm.Module{}
 	s.Sum(m.ID[:0])
 	original := m.ID
-	result := fileCacheKey(m)
+	result := fileCacheKey(m, api.CoreFeaturesV2)
 	require.Equal(t, original, m.ID)
 	require.NotEqual(t, original, result)
+	require.NotEqual(t, result, fileCacheKey(m, api.CoreFeaturesV2|experimental.CoreFeaturesExceptionHandling))
 }
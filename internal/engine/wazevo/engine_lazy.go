@@ -0,0 +1,480 @@
+package wazevo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/frontend"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// lazyCompilation is the state of a compiledModule whose local functions are compiled on their first call.
+//
+// The executable of such a module is reserved at once as a segment, which is laid out as follows:
+//
+//	+--------------------+ <- 0
+//	|     trampoline     |  shared by the entries to exit the execution to compile the function.
+//	+--------------------+ <- compiledModule.functionOffsets[0]
+//	|      entry[0]      |  jumps to the address in slot[0].
+//	|      ........      |
+//	|    entry[N - 1]    |
+//	+--------------------+ <- slotsOffset (page-aligned)
+//	|      slot[0]       |  the address of the compiled function, or the one of the rest of the entry until then.
+//	|      ........      |
+//	|    slot[N - 1]     |
+//	+--------------------+ <- page-aligned
+//	|     functions      |  the compiled functions and the call trampoline islands, in the order of compilation.
+//	|      ........      |
+//	+--------------------+ <- lazySegment.used
+//	|      (unused)      |
+//	+--------------------+ <- len(lazySegment.executable)
+//
+// Functions call each other through the entries of their segment, so they don't need to be patched when their callees
+// are compiled. The pages are only backed by memory once written, so the unused part of the executable is cheap.
+//
+// When the functions exhaust the segment, another one is reserved with the same layout. Its slots are updated along
+// with the ones of the others, so the functions in any segment call the same compiled functions. The first segment is
+// compiledModule.executable, whose entries are the addresses of the functions.
+type lazyCompilation struct {
+	// slotsOffset is the offset of the slots in the segments.
+	slotsOffset int
+	// trampoline is the one at the beginning of the segments.
+	trampoline []byte
+	// pageSize is the alignment of the compiled functions on arm64, which are made executable page by page.
+	pageSize int
+	// islandInterval and islandSize are the ones returned by backend.Machine CallTrampolineIslandInfo.
+	islandInterval, islandSize int
+	// machine resolves the relocations of the compiled functions.
+	machine backend.Machine
+
+	// segments are the segments reserved so far. This is replaced when a segment is added, so that it can be read
+	// without mux.
+	segments atomic.Pointer[[]*lazySegment]
+
+	mux sync.Mutex
+	// executables are the compiled functions indexed by the local function index, nil until compiled.
+	executables []*byte // guarded by mux.
+	// compiler is created on the first compilation, and reused afterward.
+	compiler *functionCompiler // guarded by mux.
+}
+
+// lazySegment is an executable of a lazyCompilation.
+type lazySegment struct {
+	executable []byte
+	// used is the size of the executable used so far.
+	used int // guarded by lazyCompilation.mux.
+	// functions are the compiled functions sorted by their offsets in the executable.
+	functions []lazyFunction // guarded by lazyCompilation.mux.
+	// islandOffsets are the offsets of the call trampoline islands in the executable, in ascending order.
+	islandOffsets []int // guarded by lazyCompilation.mux.
+	// refToBinaryOffset maps the function references to the offsets of their entries, or of their trampolines in the
+	// last island if any.
+	refToBinaryOffset []int // guarded by lazyCompilation.mux.
+	// sourceMap is the one of the functions in the executable if any. This is replaced as the functions are compiled,
+	// so that it can be read without lazyCompilation.mux, e.g. while unwinding the stack.
+	sourceMap atomic.Pointer[sourceMap]
+}
+
+// lazyFunction is a function compiled by lazyCompilation.
+type lazyFunction struct {
+	offset, size int
+	index        wasm.Index
+}
+
+const (
+	// lazyBodySizeFactor is the ratio of the size of the compiled functions to the size of their Wasm bodies used to
+	// reserve the segments. This is generous as the reservation is cheap.
+	lazyBodySizeFactor = 32
+	// lazyFunctionSizeOverhead is the size reserved for each function in addition to lazyBodySizeFactor, e.g. for
+	// their prologue and epilogue.
+	lazyFunctionSizeOverhead = 512
+	// maxLazyExecutableSize is the maximum size of a segment, so that the offsets fit in 32-bit relocations.
+	maxLazyExecutableSize = math.MaxInt32
+)
+
+// compileModuleLazily lays out the executable of a module whose local functions are compiled on their first call.
+func (e *engine) compileModuleLazily(ctx context.Context, cm *compiledModule) (_ *compiledModule, err error) {
+	module := cm.module
+	localFns := len(module.FunctionSection)
+
+	machine := newMachine()
+	be := backend.NewCompiler(ctx, machine, ssa.NewBuilder())
+	cm.executables.compileEntryPreambles(module, machine, be)
+	defer func() {
+		if err != nil {
+			// The finalizer is set only on success, so the executables are released here.
+			executablesFinalizer(cm.executables)
+		}
+	}()
+
+	islandInterval, islandSize, err := machine.CallTrampolineIslandInfo(localFns)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &lazyCompilation{
+		pageSize:       os.Getpagesize(),
+		islandInterval: islandInterval,
+		islandSize:     islandSize,
+		machine:        machine,
+		executables:    make([]*byte, localFns),
+	}
+
+	be.Init()
+	// The trampoline is copied as the backend reuses its buffer.
+	l.trampoline = append([]byte(nil), machine.CompileLazyCompilationTrampoline()...)
+	entrySize := machine.LazyFunctionEntrySize()
+
+	entriesOffset := (len(l.trampoline) + 15) &^ 15
+	l.slotsOffset = l.alignPage(entriesOffset + entrySize*localFns)
+	cm.functionOffsets = make([]int, localFns)
+	for i := range cm.functionOffsets {
+		cm.functionOffsets[i] = entriesOffset + entrySize*i
+	}
+	l.segments.Store(&[]*lazySegment{})
+
+	segment, err := l.addSegment(cm, 0)
+	if err != nil {
+		return nil, err
+	}
+	cm.executable = segment.executable
+
+	cm.lazy = l
+	cm.sharedFunctions = e.sharedFunctions
+	e.setFinalizer(cm.executables, executablesFinalizer)
+	return cm, nil
+}
+
+// addSegment reserves a segment which has room for the uncompiled functions, and at least for a function of
+// minFunctionSize bytes.
+func (l *lazyCompilation) addSegment(cm *compiledModule, minFunctionSize int) (*lazySegment, error) {
+	module := cm.module
+	importedFns, localFns := int(module.ImportFunctionCount), len(module.FunctionSection)
+
+	headerSize := l.alignPage(l.slotsOffset + 8*localFns)
+	size := headerSize
+	uncompiled := 0
+	for i := range module.CodeSection {
+		if l.executables[i] == nil {
+			size += len(module.CodeSection[i].Body)*lazyBodySizeFactor + lazyFunctionSizeOverhead
+			uncompiled++
+		}
+	}
+	if runtime.GOARCH != "amd64" {
+		// Each function starts at a new page.
+		size += uncompiled * l.pageSize
+	}
+	if minSize := headerSize + l.alignPage(minFunctionSize) + l.pageSize; size < minSize {
+		size = minSize
+	}
+	if l.islandSize > 0 {
+		size += (size/l.islandInterval + 1) * l.alignPage(l.islandSize)
+	}
+	if size > maxLazyExecutableSize {
+		size = maxLazyExecutableSize
+	}
+	size = l.alignPage(size)
+	if (size/l.pageSize)%2 == 0 {
+		// Huge pages are used if the size is a multiple of their size, which would back the whole reservation with
+		// memory. An odd number of pages is never such a multiple.
+		size += l.pageSize
+	}
+
+	executable, err := platform.MmapCodeSegment(size)
+	if err != nil {
+		return nil, err
+	}
+	copy(executable, l.trampoline)
+
+	s := &lazySegment{executable: executable, used: headerSize, refToBinaryOffset: make([]int, importedFns+localFns)}
+	for i, offset := range cm.functionOffsets {
+		s.refToBinaryOffset[frontend.FunctionIndexToFuncRef(wasm.Index(i+importedFns))] = offset
+
+		slotOffset := l.slotsOffset + 8*i
+		lazyOffset := l.machine.EncodeLazyFunctionEntry(executable, offset, slotOffset, 0, uint32(i))
+		if compiled := l.executables[i]; compiled != nil {
+			*l.slot(executable, wasm.Index(i)) = uintptr(unsafe.Pointer(compiled))
+		} else {
+			*l.slot(executable, wasm.Index(i)) = uintptr(unsafe.Pointer(&executable[lazyOffset]))
+		}
+	}
+
+	if runtime.GOARCH != "amd64" {
+		// On arm64 and riscv64, we cannot give all of rwx at the same time, so we change the trampoline and the entries to exec.
+		// The slots and the rest stay writable.
+		if err = platform.MprotectRX(executable[:l.slotsOffset]); err != nil {
+			_ = platform.MunmapCodeSegment(executable)
+			return nil, err
+		}
+	}
+
+	segments := *l.segments.Load()
+	if len(segments) > 0 {
+		// The first segment is the executable of the compiled module, which is registered by the engine as such.
+		cm.executables.lazySegments = append(cm.executables.lazySegments, executable)
+		cm.parent.addLazySegment(cm, executable)
+	}
+	segments = append(segments[:len(segments):len(segments)], s)
+	l.segments.Store(&segments)
+	cm.parent.symbols.add(executable[:len(l.trampoline)], "lazy_compilation_trampoline", nil)
+	return s, nil
+}
+
+// segmentOf returns the segment containing the address, or nil if none.
+func (l *lazyCompilation) segmentOf(addr uintptr) *lazySegment {
+	for _, s := range *l.segments.Load() {
+		if checkAddrInBytes(addr, s.executable) {
+			return s
+		}
+	}
+	return nil
+}
+
+// alignPage aligns the offset to the page size.
+func (l *lazyCompilation) alignPage(offset int) int {
+	return (offset + l.pageSize - 1) &^ (l.pageSize - 1)
+}
+
+// slot returns the slot of the local function of the given index in the segment whose executable is given, which holds
+// the address its entry jumps to.
+func (l *lazyCompilation) slot(executable []byte, index wasm.Index) *uintptr {
+	return (*uintptr)(unsafe.Pointer(&executable[l.slotsOffset+8*int(index)]))
+}
+
+// compileLazily compiles the local function of the given index unless it is compiled already, and returns the address
+// of its executable. The compiled function is added to the file cache if any.
+func (cm *compiledModule) compileLazily(index wasm.Index) (executable *byte, err error) {
+	l := cm.lazy
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if executable = l.executables[index]; executable != nil {
+		// Another call compiled it meanwhile.
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil {
+			err = fmt.Errorf("compile function %d/%d lazily: %w", index, len(l.executables)-1, err)
+		}
+	}()
+
+	e := cm.parent
+	cf, ok, err := e.getLazyFunctionFromCache(cm.module, index)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if cf, err = l.compile(cm, index); err != nil {
+			return nil, err
+		}
+		if err = e.addLazyFunctionToCache(cm.module, index, cf); err != nil {
+			return nil, err
+		}
+	}
+	return l.place(cm, index, cf)
+}
+
+// compile compiles the local function of the given index.
+func (l *lazyCompilation) compile(cm *compiledModule, index wasm.Index) (*compiledFunction, error) {
+	e, module := cm.parent, cm.module
+	needSourceInfo := module.DWARFLines != nil
+	if l.compiler == nil {
+		l.compiler = e.newFunctionCompiler(context.Background(), cm, cm.listeners, needSourceInfo)
+	}
+	fc := l.compiler
+
+	ctx := context.Background()
+	if wazevoapi.NeedFunctionNameInContext {
+		def := module.FunctionDefinition(index + module.ImportFunctionCount)
+		ctx = wazevoapi.SetCurrentFunctionName(ctx, int(index), def.DebugName())
+	}
+	needListener := len(cm.listeners) > 0 && cm.listeners[index] != nil
+	body, rels, err := e.compileLocalWasmFunction(ctx, module, index, fc.fe, fc.ssaBuilder, fc.be, needListener)
+	if err != nil {
+		return nil, err
+	}
+	// The relocations and source offsets are reused by the backend, so they must be copied.
+	cf := &compiledFunction{body: body, rels: append([]backend.RelocationInfo(nil), rels...)}
+	cf.sourceOffsets = fc.appendSourceOffsets(cf.sourceOffsets, needSourceInfo)
+	return cf, nil
+}
+
+// place copies the compiled function of the given local index into the last segment, or a new one if exhausted, and
+// makes the entries of all the segments jump to it.
+func (l *lazyCompilation) place(cm *compiledModule, index wasm.Index, cf *compiledFunction) (*byte, error) {
+	segments := *l.segments.Load()
+	s := segments[len(segments)-1]
+	offset, ok, err := l.allocate(cm, s, len(cf.body))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if s, err = l.addSegment(cm, len(cf.body)); err != nil {
+			return nil, err
+		}
+		if offset, ok, err = l.allocate(cm, s, len(cf.body)); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, fmt.Errorf("function of %d bytes exceeds executable of %d bytes", len(cf.body), len(s.executable))
+		}
+	}
+	executable := s.executable
+	end := offset + len(cf.body)
+
+	copy(executable[offset:], cf.body)
+	for i := range cf.rels {
+		cf.rels[i].Offset += int64(offset)
+	}
+	l.machine.ResolveRelocations(s.refToBinaryOffset, int(cm.module.ImportFunctionCount), executable, cf.rels, nil)
+
+	if runtime.GOARCH != "amd64" {
+		// On arm64 and riscv64, we cannot give all of rwx at the same time, so we change the pages of the function to exec.
+		if err := platform.MprotectRX(executable[offset:l.alignPage(end)]); err != nil {
+			return nil, err
+		}
+	}
+	s.used = end
+
+	base := uintptr(unsafe.Pointer(&executable[0]))
+	if prev := s.sourceMap.Load(); cm.module.DWARFLines != nil || len(cf.sourceOffsets) > 0 || prev != nil {
+		// Functions are placed in ascending order, so the source map stays sorted. Once the source map is used, all
+		// the functions are added so that the offsets of the others don't resolve to the previous one.
+		sm := &sourceMap{}
+		if prev != nil {
+			// Appending doesn't modify the elements of the previous one, which may be read concurrently.
+			*sm = *prev
+		}
+		sm.executableOffsets = append(sm.executableOffsets, base+uintptr(offset))
+		sm.wasmBinaryOffsets = append(sm.wasmBinaryOffsets, functionStartSourceOffset(cm.module, index))
+		for _, info := range cf.sourceOffsets {
+			sm.executableOffsets = append(sm.executableOffsets, base+uintptr(offset)+uintptr(info.ExecutableOffset))
+			sm.wasmBinaryOffsets = append(sm.wasmBinaryOffsets, uint64(info.SourceOffset))
+		}
+		s.sourceMap.Store(sm)
+	}
+	cm.parent.symbols.addFunction(cm, index, executable[offset:end])
+
+	s.functions = append(s.functions, lazyFunction{offset: offset, size: len(cf.body), index: index})
+	compiled := &executable[offset]
+	l.executables[index] = compiled
+	// The function is ready, so the entries can jump to it from now on, including from the calls running concurrently.
+	for _, s := range *l.segments.Load() {
+		atomic.StoreUintptr(l.slot(s.executable, index), uintptr(unsafe.Pointer(compiled)))
+	}
+	return compiled, nil
+}
+
+// allocate returns the offset in the segment to place a function of the given size, adding a call trampoline island
+// before it if needed. ok is false if the segment is exhausted.
+func (l *lazyCompilation) allocate(cm *compiledModule, s *lazySegment, size int) (offset int, ok bool, err error) {
+	offset = l.alignFunction(s.used)
+	if l.islandSize > 0 {
+		// The functions must be close enough to either the entries or the last island to call the other functions.
+		reachable := 0
+		if n := len(s.islandOffsets); n > 0 {
+			reachable = s.islandOffsets[n-1]
+		}
+		if offset+size-reachable > l.islandInterval {
+			if offset+l.islandSize > len(s.executable) {
+				return 0, false, nil
+			}
+			if err = l.addIsland(cm, s, offset); err != nil {
+				return 0, false, err
+			}
+			offset = l.alignFunction(s.used)
+		}
+	}
+	return offset, offset+size <= len(s.executable), nil
+}
+
+// addIsland adds a call trampoline island at the offset of the segment, and makes the functions placed afterward call
+// through it.
+func (l *lazyCompilation) addIsland(cm *compiledModule, s *lazySegment, offset int) error {
+	executable := s.executable
+
+	// The island jumps to the entries, not to the trampolines of the previous island.
+	importedFns := int(cm.module.ImportFunctionCount)
+	for i, entry := range cm.functionOffsets {
+		s.refToBinaryOffset[frontend.FunctionIndexToFuncRef(wasm.Index(i+importedFns))] = entry
+	}
+	l.machine.ResolveRelocations(s.refToBinaryOffset, importedFns, executable, nil, []int{offset})
+
+	if runtime.GOARCH != "amd64" {
+		if err := platform.MprotectRX(executable[offset:l.alignPage(offset+l.islandSize)]); err != nil {
+			return err
+		}
+	}
+
+	// Each function has a trampoline of the same size in the island.
+	trampolineSize := l.islandSize / len(cm.functionOffsets)
+	for i := range cm.functionOffsets {
+		s.refToBinaryOffset[frontend.FunctionIndexToFuncRef(wasm.Index(i+importedFns))] = offset + trampolineSize*i
+	}
+	s.islandOffsets = append(s.islandOffsets, offset)
+	s.used = offset + l.islandSize
+	return nil
+}
+
+// alignFunction aligns the offset of a compiled function, to the page size on arm64 and riscv64 so that it can be made executable
+// without affecting the functions already running.
+func (l *lazyCompilation) alignFunction(offset int) int {
+	if runtime.GOARCH != "amd64" {
+		return l.alignPage(offset)
+	}
+	return (offset + 15) &^ 15
+}
+
+// functionIndexOf returns the local index of the function whose entry or compiled code contains the address, where
+// functionOffsets are the ones of the entries.
+func (l *lazyCompilation) functionIndexOf(addr uintptr, functionOffsets []int) wasm.Index {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	s := l.segmentOf(addr)
+	if s == nil {
+		panic("BUG")
+	}
+	offset := int(addr - uintptr(unsafe.Pointer(&s.executable[0])))
+	if offset < l.slotsOffset {
+		index := sort.Search(len(functionOffsets), func(i int) bool {
+			return functionOffsets[i] > offset
+		})
+		index--
+		if index < 0 {
+			panic("BUG")
+		}
+		return wasm.Index(index)
+	}
+
+	index := sort.Search(len(s.functions), func(i int) bool {
+		return s.functions[i].offset > offset
+	})
+	index--
+	if index < 0 {
+		panic("BUG")
+	}
+	return s.functions[index].index
+}
+
+// sourceMap returns the source map of the segment containing the address, or nil if none.
+func (l *lazyCompilation) sourceMap(addr uintptr) *sourceMap {
+	if s := l.segmentOf(addr); s != nil {
+		return s.sourceMap.Load()
+	}
+	return nil
+}
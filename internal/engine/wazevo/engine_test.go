@@ -1,13 +1,21 @@
 package wazevo
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"reflect"
+	"runtime"
 	"sort"
 	"testing"
 	"unsafe"
 
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/testcases"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/filecache"
 	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -32,6 +40,10 @@ func Test_sharedFunctionsFinalizer(t *testing.T) {
 	require.NoError(t, err)
 	b10, err := platform.MmapCodeSegment(100)
 	require.NoError(t, err)
+	b11, err := platform.MmapCodeSegment(100)
+	require.NoError(t, err)
+	b12, err := platform.MmapCodeSegment(100)
+	require.NoError(t, err)
 
 	sf.memoryGrowExecutable = b1
 	sf.stackGrowExecutable = b2
@@ -41,6 +53,8 @@ func Test_sharedFunctionsFinalizer(t *testing.T) {
 	sf.memoryWait32Executable = b8
 	sf.memoryWait64Executable = b9
 	sf.memoryNotifyExecutable = b10
+	sf.exceptionExecutable = b11
+	sf.sampleExecutable = b12
 
 	sharedFunctionsFinalizer(sf)
 	require.Nil(t, sf.memoryGrowExecutable)
@@ -51,6 +65,8 @@ func Test_sharedFunctionsFinalizer(t *testing.T) {
 	require.Nil(t, sf.memoryWait32Executable)
 	require.Nil(t, sf.memoryWait64Executable)
 	require.Nil(t, sf.memoryNotifyExecutable)
+	require.Nil(t, sf.exceptionExecutable)
+	require.Nil(t, sf.sampleExecutable)
 }
 
 func Test_executablesFinalizer(t *testing.T) {
@@ -104,6 +120,209 @@ func TestEngine_CompileModule(t *testing.T) {
 	}
 }
 
+func TestEngine_CompileModule_simd(t *testing.T) {
+	if simdSupported {
+		t.Skip("the backend supports SIMD")
+	}
+	ctx := context.Background()
+	e := NewEngine(ctx, api.CoreFeaturesV2, nil).(*engine)
+	m := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{wasm.ValueTypeV128}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+	}
+	err := e.CompileModule(ctx, m, nil, false)
+	require.EqualError(t, err, "SIMD is not supported by the compiler on "+runtime.GOARCH+": use the interpreter")
+}
+
+func TestEngine_compileModule_workers(t *testing.T) {
+	m := testcases.Call.Module
+	var executable []byte
+	var functionOffsets []int
+	for _, workers := range []int{1, 2, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), expctxkeys.CompilationWorkersKey{}, workers)
+			e := NewEngine(ctx, 0, nil).(*engine)
+			ff := fakeFinalizer{}
+			e.setFinalizer = ff.setFinalizer
+			defer func() {
+				for k, v := range ff {
+					v(k)
+				}
+			}()
+
+			err := e.CompileModule(ctx, m, nil, false)
+			require.NoError(t, err)
+			cm := e.compiledModules[m.ID]
+
+			// The executable must not depend on the number of workers.
+			if executable == nil {
+				executable = append(executable, cm.executable...)
+				functionOffsets = cm.functionOffsets
+			} else {
+				require.Equal(t, executable, cm.executable)
+				require.Equal(t, functionOffsets, cm.functionOffsets)
+			}
+		})
+	}
+}
+
+func TestEngine_compileModule_lazy(t *testing.T) {
+	m := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0, 0, 0},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeEnd}},
+		},
+	}
+	fc := filecache.New(t.TempDir())
+	ctx := context.WithValue(context.Background(), expctxkeys.LazyCompilationKey{}, true)
+
+	compile := func(t *testing.T) *compiledModule {
+		e := NewEngine(ctx, api.CoreFeaturesV2, fc).(*engine)
+		ff := fakeFinalizer{}
+		e.setFinalizer = ff.setFinalizer
+		t.Cleanup(func() {
+			for k, v := range ff {
+				v(k)
+			}
+		})
+
+		err := e.CompileModule(ctx, m, nil, false)
+		require.NoError(t, err)
+		cm := e.compiledModules[m.ID]
+		require.NotNil(t, cm.lazy)
+		// Nothing is compiled until called.
+		require.Equal(t, []*byte{nil, nil, nil}, cm.lazy.executables)
+		return cm
+	}
+
+	t.Run("no cache", func(t *testing.T) {
+		cm := compile(t)
+		l := cm.lazy
+
+		executable, err := cm.compileLazily(1)
+		require.NoError(t, err)
+		require.NotNil(t, executable)
+		require.NotNil(t, l.compiler)
+		require.Equal(t, []*byte{nil, executable, nil}, l.executables)
+		require.Equal(t, uintptr(unsafe.Pointer(executable)), *l.slot(cm.executable, 1))
+		require.Equal(t, wasm.Index(1), cm.functionIndexOf(uintptr(unsafe.Pointer(executable))))
+
+		// Compiling again returns the same executable.
+		again, err := cm.compileLazily(1)
+		require.NoError(t, err)
+		require.Equal(t, executable, again)
+		require.Equal(t, 1, len((*l.segments.Load())[0].functions))
+	})
+
+	t.Run("new segment", func(t *testing.T) {
+		cm := compile(t)
+		l := cm.lazy
+		first := (*l.segments.Load())[0]
+		first.used = len(first.executable)
+
+		// The first segment is exhausted, so the function is placed in another one.
+		executable, err := cm.compileLazily(1)
+		require.NoError(t, err)
+		segments := *l.segments.Load()
+		require.Equal(t, 2, len(segments))
+		require.Equal(t, [][]byte{segments[1].executable}, cm.executables.lazySegments)
+		require.True(t, checkAddrInBytes(uintptr(unsafe.Pointer(executable)), segments[1].executable))
+		require.Equal(t, wasm.Index(1), cm.functionIndexOf(uintptr(unsafe.Pointer(executable))))
+		require.Equal(t, cm, cm.parent.compiledModuleOfAddr(uintptr(unsafe.Pointer(executable))))
+
+		// The entries of both segments jump to the function.
+		for _, s := range segments {
+			require.Equal(t, uintptr(unsafe.Pointer(executable)), *l.slot(s.executable, 1))
+			require.Equal(t, wasm.Index(1), cm.functionIndexOf(uintptr(unsafe.Pointer(&s.executable[cm.functionOffsets[1]]))))
+		}
+
+		cm.parent.DeleteCompiledModule(cm.module)
+		require.Nil(t, cm.parent.compiledModuleOfAddr(uintptr(unsafe.Pointer(executable))))
+	})
+
+	t.Run("with cache", func(t *testing.T) {
+		cm := compile(t)
+		l := cm.lazy
+
+		executable, err := cm.compileLazily(1)
+		require.NoError(t, err)
+		require.NotNil(t, executable)
+		// The function is loaded from the cache, so the compiler isn't needed.
+		require.Nil(t, l.compiler)
+
+		_, err = cm.compileLazily(0)
+		require.NoError(t, err)
+		require.NotNil(t, l.compiler)
+		require.Nil(t, l.executables[2])
+	})
+}
+
+func TestEngine_CompileModule_symbols(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("jitdump is only supported on Linux")
+	}
+	ctx := context.WithValue(context.Background(), expctxkeys.PerfMapKey{}, true)
+	ctx = context.WithValue(ctx, expctxkeys.JITDumpKey{}, true)
+	e := NewEngine(ctx, 0, nil).(*engine)
+	require.NotNil(t, e.symbols)
+	ff := fakeFinalizer{}
+	e.setFinalizer = ff.setFinalizer
+	defer func() {
+		for k, v := range ff {
+			v(k)
+		}
+	}()
+
+	m := testcases.Call.Module
+	err := e.CompileModule(ctx, m, nil, false)
+	require.NoError(t, err)
+	cm := e.compiledModules[m.ID]
+	name := m.FunctionDefinition(m.ImportFunctionCount).DebugName()
+
+	perfMapPath := fmt.Sprintf("/tmp/perf-%d.map", os.Getpid())
+	defer os.Remove(perfMapPath)
+	perfMap, err := os.ReadFile(perfMapPath)
+	require.NoError(t, err)
+	require.Contains(t, string(perfMap), fmt.Sprintf("%x %x %s\n",
+		uintptr(unsafe.Pointer(&cm.executable[0])), cm.functionOffsets[1], name))
+	require.Contains(t, string(perfMap), " memory_grow_trampoline\n")
+
+	jitDumpPath := fmt.Sprintf("/tmp/jit-%d.dump", os.Getpid())
+	defer os.Remove(jitDumpPath)
+	jitDump, err := os.ReadFile(jitDumpPath)
+	require.NoError(t, err)
+	require.Equal(t, []byte("DTiJ"), jitDump[:4])
+	require.True(t, bytes.Contains(jitDump, append([]byte(name), 0)))
+	require.True(t, bytes.Contains(jitDump, cm.executable[:cm.functionOffsets[1]]))
+}
+
+func Test_compilationWorkers(t *testing.T) {
+	if wazevoapi.NeedSequentialCompilation {
+		t.Skip("the debug options force sequential compilation")
+	}
+	for _, tc := range []struct {
+		name          string
+		workers       int
+		localFns, exp int
+	}{
+		{name: "default", localFns: 1 << 20, exp: runtime.GOMAXPROCS(0)},
+		{name: "capped by config", workers: 1, localFns: 10, exp: 1},
+		{name: "capped by functions", workers: 10, localFns: 3, exp: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.workers > 0 {
+				ctx = context.WithValue(ctx, expctxkeys.CompilationWorkersKey{}, tc.workers)
+			}
+			require.Equal(t, tc.exp, compilationWorkers(ctx, tc.localFns))
+		})
+	}
+}
+
 func TestEngine_sortedCompiledModules(t *testing.T) {
 	requireEqualExisting := func(t *testing.T, e *engine, expected []uintptr) {
 		actual := make([]uintptr, 0)
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"math"
 
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -29,30 +30,58 @@ type Compiler struct {
 	tableGrowSig           ssa.Signature
 	refFuncSig             ssa.Signature
 	memmoveSig             ssa.Signature
+	exceptionSig           ssa.Signature
 	ensureTermination      bool
+	exceptionHandling      bool
+	// fuelCosts is non-nil when the fuel consumed by the instructions must be charged. See wasm.FuelCost.
+	fuelCosts *experimental.FuelCosts
+	// profiling is true if the safepoints for the sampling profiler are inserted. See wasm.Module Profiling.
+	profiling bool
+	// inlining is true if the calls to the small leaf functions are inlined. See EnableInlining.
+	inlining bool
+	// listeners are the listeners of the local functions if any, used to call them around the inlined bodies.
+	listeners []experimental.FunctionListener
+	// inlinable caches whether the local functions can be inlined, indexed by the local function index.
+	inlinable []inlinability
 
 	// Followings are reset by per function.
 
 	// wasmLocalToVariable maps the index (considered as wasm.Index of locals)
 	// to the corresponding ssa.Variable.
-	wasmLocalToVariable                   [] /* local index to */ ssa.Variable
-	wasmLocalFunctionIndex                wasm.Index
-	wasmFunctionTypeIndex                 wasm.Index
-	wasmFunctionTyp                       *wasm.FunctionType
-	wasmFunctionLocalTypes                []wasm.ValueType
-	wasmFunctionBody                      []byte
-	wasmFunctionBodyOffsetInCodeSection   uint64
-	memoryBaseVariable, memoryLenVariable ssa.Variable
-	needMemory                            bool
-	memoryShared                          bool
-	globalVariables                       []ssa.Variable
-	globalVariablesTypes                  []ssa.Type
-	mutableGlobalVariablesIndexes         []wasm.Index // index to ^.
-	needListener                          bool
-	needSourceOffsetInfo                  bool
+	wasmLocalToVariable                 [] /* local index to */ ssa.Variable
+	wasmLocalFunctionIndex              wasm.Index
+	wasmFunctionTypeIndex               wasm.Index
+	wasmFunctionTyp                     *wasm.FunctionType
+	wasmFunctionLocalTypes              []wasm.ValueType
+	wasmFunctionBody                    []byte
+	wasmFunctionBodyOffsetInCodeSection uint64
+	memoryBaseVariables                 []ssa.Variable // indexed by the memory index, imported memories first.
+	memoryLenVariables                  []ssa.Variable // indexed by the memory index, imported memories first.
+	needMemory                          bool
+	memoryShared                        []bool // indexed by the memory index, imported memories first.
+	memory64                            []bool // indexed by the memory index, imported memories first.
+	globalVariables                     []ssa.Variable
+	globalVariablesTypes                []ssa.Type
+	mutableGlobalVariablesIndexes       []wasm.Index // index to ^.
+	needListener                        bool
+	needSourceOffsetInfo                bool
+	// fuel is the fuel consumed by the instructions lowered since the last charge.
+	fuel uint64
+	// exceptionPropagateBlock is the block to return from the function with the pending exception. This is allocated lazily.
+	exceptionPropagateBlock ssa.BasicBlock
 	// br is reused during lowering.
 	br            *bytes.Reader
 	loweringState loweringState
+	// inlined is non-nil while lowering the body of an inlined callee, and holds the state of the caller.
+	inlined *inlinedCall
+	// inlinedBytes and inlinedCalls are the total size of the inlined bodies and the number of the inlined calls.
+	inlinedBytes, inlinedCalls int
+
+	// Followings are reused for the inlined calls.
+
+	inlinedCallStorage    inlinedCall
+	calleeLoweringState   loweringState
+	calleeLocalToVariable []ssa.Variable
 
 	knownSafeBounds    [] /* ssa.ValueID to */ knownSafeBound
 	knownSafeBoundsSet []ssa.ValueID
@@ -87,13 +116,16 @@ type (
 var knownSafeBoundsAtTheEndOfBlockNil = wazevoapi.NewNilVarLength[knownSafeBoundWithID]()
 
 // NewFrontendCompiler returns a frontend Compiler.
-func NewFrontendCompiler(m *wasm.Module, ssaBuilder ssa.Builder, offset *wazevoapi.ModuleContextOffsetData, ensureTermination bool, listenerOn bool, sourceInfo bool) *Compiler {
+func NewFrontendCompiler(m *wasm.Module, ssaBuilder ssa.Builder, offset *wazevoapi.ModuleContextOffsetData, ensureTermination bool, listenerOn bool, sourceInfo bool, exceptionHandling bool) *Compiler {
 	c := &Compiler{
 		m:                                 m,
 		ssaBuilder:                        ssaBuilder,
 		br:                                bytes.NewReader(nil),
 		offset:                            offset,
 		ensureTermination:                 ensureTermination,
+		exceptionHandling:                 exceptionHandling,
+		fuelCosts:                         m.FuelCosts,
+		profiling:                         m.Profiling,
 		needSourceOffsetInfo:              sourceInfo,
 		varLengthKnownSafeBoundWithIDPool: wazevoapi.NewVarLengthPool[knownSafeBoundWithID](),
 	}
@@ -130,8 +162,8 @@ func (c *Compiler) declareSignatures(listenerOn bool) {
 	}
 	c.memoryGrowSig = ssa.Signature{
 		ID: begin,
-		// Takes execution context and the page size to grow.
-		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32},
+		// Takes execution context, the page size to grow, and the memory index.
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32},
 		// Returns the previous page size.
 		Results: []ssa.Type{ssa.TypeI32},
 	}
@@ -170,8 +202,8 @@ func (c *Compiler) declareSignatures(listenerOn bool) {
 
 	c.memoryWait32Sig = ssa.Signature{
 		ID: c.memmoveSig.ID + 1,
-		// exec context, timeout, expected, addr
-		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+		// exec context, timeout, expected, addr, memory index
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32},
 		// Returns the status.
 		Results: []ssa.Type{ssa.TypeI32},
 	}
@@ -179,8 +211,8 @@ func (c *Compiler) declareSignatures(listenerOn bool) {
 
 	c.memoryWait64Sig = ssa.Signature{
 		ID: c.memoryWait32Sig.ID + 1,
-		// exec context, timeout, expected, addr
-		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64},
+		// exec context, timeout, expected, addr, memory index
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32},
 		// Returns the status.
 		Results: []ssa.Type{ssa.TypeI32},
 	}
@@ -188,12 +220,21 @@ func (c *Compiler) declareSignatures(listenerOn bool) {
 
 	c.memoryNotifySig = ssa.Signature{
 		ID: c.memoryWait64Sig.ID + 1,
-		// exec context, count, addr
-		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+		// exec context, count, addr, memory index
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32},
 		// Returns the number notified.
 		Results: []ssa.Type{ssa.TypeI32},
 	}
 	c.ssaBuilder.DeclareSignature(&c.memoryNotifySig)
+
+	c.exceptionSig = ssa.Signature{
+		ID: c.memoryNotifySig.ID + 1,
+		// exec context, wazevoapi.ExceptionOp, and the tag index or exnref.
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+		// Returns the result of the operation.
+		Results: []ssa.Type{ssa.TypeI64},
+	}
+	c.ssaBuilder.DeclareSignature(&c.exceptionSig)
 }
 
 // SignatureForWasmFunctionType returns the ssa.Signature for the given wasm.FunctionType.
@@ -226,6 +267,10 @@ func (c *Compiler) Init(idx, typIndex wasm.Index, typ *wasm.FunctionType, localT
 	c.wasmFunctionBody = body
 	c.wasmFunctionBodyOffsetInCodeSection = bodyOffsetInCodeSection
 	c.needListener = needListener
+	c.exceptionPropagateBlock = nil
+	c.fuel = 0
+	c.inlined = nil
+	c.inlinedBytes, c.inlinedCalls = 0, 0
 	c.clearSafeBounds()
 	c.varLengthKnownSafeBoundWithIDPool.Reset()
 	c.knownSafeBoundsAtTheEndOfBlocks = c.knownSafeBoundsAtTheEndOfBlocks[:0]
@@ -277,6 +322,9 @@ func (c *Compiler) LowerToSSA() {
 	}
 	c.declareWasmLocals()
 	c.declareNecessaryVariables()
+	if c.needMemory {
+		c.defineMemoryBaseLen()
+	}
 
 	c.lowerBody(entryBlock)
 }
@@ -306,20 +354,26 @@ func (c *Compiler) declareWasmLocals() {
 }
 
 func (c *Compiler) declareNecessaryVariables() {
-	if c.needMemory = c.m.MemorySection != nil; c.needMemory {
-		c.memoryShared = c.m.MemorySection.IsShared
-	} else if c.needMemory = c.m.ImportMemoryCount > 0; c.needMemory {
-		for _, imp := range c.m.ImportSection {
-			if imp.Type == wasm.ExternTypeMemory {
-				c.memoryShared = imp.DescMem.IsShared
-				break
-			}
+	c.memoryShared = c.memoryShared[:0]
+	c.memory64 = c.memory64[:0]
+	c.memoryBaseVariables = c.memoryBaseVariables[:0]
+	c.memoryLenVariables = c.memoryLenVariables[:0]
+	for _, imp := range c.m.ImportSection {
+		if imp.Type == wasm.ExternTypeMemory {
+			c.memoryShared = append(c.memoryShared, imp.DescMem.IsShared)
+			c.memory64 = append(c.memory64, imp.DescMem.Is64)
 		}
 	}
+	for i := range c.m.MemorySection {
+		c.memoryShared = append(c.memoryShared, c.m.MemorySection[i].IsShared)
+		c.memory64 = append(c.memory64, c.m.MemorySection[i].Is64)
+	}
 
-	if c.needMemory {
-		c.memoryBaseVariable = c.ssaBuilder.DeclareVariable(ssa.TypeI64)
-		c.memoryLenVariable = c.ssaBuilder.DeclareVariable(ssa.TypeI64)
+	if c.needMemory = len(c.memoryShared) > 0; c.needMemory {
+		for range c.memoryShared {
+			c.memoryBaseVariables = append(c.memoryBaseVariables, c.ssaBuilder.DeclareVariable(ssa.TypeI64))
+			c.memoryLenVariables = append(c.memoryLenVariables, c.ssaBuilder.DeclareVariable(ssa.TypeI64))
+		}
 	}
 
 	c.globalVariables = c.globalVariables[:0]
@@ -345,8 +399,8 @@ func (c *Compiler) declareWasmGlobal(typ wasm.ValueType, mutable bool) {
 	case wasm.ValueTypeI32:
 		st = ssa.TypeI32
 	case wasm.ValueTypeI64,
-		// Both externref and funcref are represented as I64 since we only support 64-bit platforms.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		// Reference types are represented as I64 since we only support 64-bit platforms.
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 		st = ssa.TypeI64
 	case wasm.ValueTypeF32:
 		st = ssa.TypeF32
@@ -372,8 +426,8 @@ func WasmTypeToSSAType(vt wasm.ValueType) ssa.Type {
 	case wasm.ValueTypeI32:
 		return ssa.TypeI32
 	case wasm.ValueTypeI64,
-		// Both externref and funcref are represented as I64 since we only support 64-bit platforms.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		// Reference types are represented as I64 since we only support 64-bit platforms.
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 		return ssa.TypeI64
 	case wasm.ValueTypeF32:
 		return ssa.TypeF32
@@ -1,6 +1,7 @@
 package frontend
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -21,6 +22,8 @@ func TestCompiler_LowerToSSA(t *testing.T) {
 		name              string
 		ensureTermination bool
 		needListener      bool
+		inlining          bool
+		profiling         bool
 		// m is the *wasm.Module to be compiled in this test.
 		m *wasm.Module
 		// targetIndex is the index of a local function to be compiled in this test.
@@ -277,6 +280,42 @@ blk1: () <-- (blk0,blk1)
 	v2:i64 = Load exec_ctx, 0x58
 	CallIndirect v2:sig2, exec_ctx
 	Jump blk1
+`,
+		},
+		{
+			name: "loop - br / profiling", m: testcases.LoopBr.Module,
+			profiling: true,
+			exp: `
+signatures:
+	sig2: i64_v
+
+blk0: (exec_ctx:i64, module_ctx:i64)
+	v2:i32 = Load exec_ctx, 0x4d0
+	Brz v2, blk2
+	Jump blk1
+
+blk1: () <-- (blk0)
+	v3:i64 = Load exec_ctx, 0x4d8
+	CallIndirect v3:sig2, exec_ctx
+	Jump blk2
+
+blk2: () <-- (blk0,blk1)
+	Jump blk3
+
+blk3: () <-- (blk2,blk6)
+	v4:i32 = Load exec_ctx, 0x4d0
+	Brz v4, blk6
+	Jump blk5
+
+blk4: ()
+
+blk5: () <-- (blk3)
+	v5:i64 = Load exec_ctx, 0x4d8
+	CallIndirect v5:sig2, exec_ctx
+	Jump blk6
+
+blk6: () <-- (blk3,blk5)
+	Jump blk3
 `,
 		},
 		{
@@ -328,9 +367,6 @@ blk2: ()
 `,
 			expAfterPasses: `
 blk0: (exec_ctx:i64, module_ctx:i64)
-	Jump fallthrough
-
-blk1: () <-- (blk0)
 	Jump blk_ret
 `,
 		},
@@ -397,9 +433,6 @@ blk1: () <-- (blk0)
 	Return v2
 
 blk2: () <-- (blk0)
-	Jump fallthrough
-
-blk3: () <-- (blk2)
 	Jump blk_ret, v2
 `,
 		},
@@ -451,9 +484,6 @@ blk2: ()
 `,
 			expAfterPasses: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
-	Jump fallthrough
-
-blk1: () <-- (blk0)
 	Return v2
 `,
 		},
@@ -490,12 +520,6 @@ blk5: () <-- (blk1)
 	Jump blk1
 
 blk4: () <-- (blk1)
-	Jump fallthrough
-
-blk3: () <-- (blk4)
-	Jump fallthrough
-
-blk2: () <-- (blk3)
 	v4:i32 = Iconst_32 0x0
 	Jump blk_ret, v4
 `,
@@ -524,7 +548,7 @@ blk4: () <-- (blk1)
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
 	Jump fallthrough, v2
 
-blk1: (v3:i32) <-- (blk0,blk3)
+blk1: (v3:i32) <-- (blk0,blk4)
 	Brnz v3, blk5
 	Jump blk4
 
@@ -532,9 +556,6 @@ blk5: () <-- (blk1)
 	Jump blk_ret
 
 blk4: () <-- (blk1)
-	Jump fallthrough
-
-blk3: () <-- (blk4)
 	v4:i32 = Iconst_32 0x1
 	Jump blk1, v4
 `,
@@ -554,6 +575,72 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 	v4:i32 = Call f2:sig2, exec_ctx, module_ctx, v2, v3
 	v5:i32, v6:i32 = Call f3:sig3, exec_ctx, module_ctx, v4
 	Jump blk_ret, v5, v6
+`,
+		},
+		{
+			name: "call / inlining", m: testcases.Call.Module,
+			inlining: true,
+			exp: `
+blk0: (exec_ctx:i64, module_ctx:i64)
+	v3:i32 = Iconst_32 0x28
+	Jump blk1, v3
+
+blk1: (v2:i32) <-- (blk0)
+	v4:i32 = Iconst_32 0x5
+	v6:i32 = Iadd v2, v4
+	Jump blk2, v6
+
+blk2: (v5:i32) <-- (blk1)
+	Jump blk3, v5, v5
+
+blk3: (v7:i32,v8:i32) <-- (blk2)
+	Jump blk_ret, v7, v8
+`,
+		},
+		{
+			name: "call / inlining / listener", m: testcases.Call.Module,
+			inlining: true, needListener: true,
+			exp: `
+signatures:
+	sig4: i64i32_v
+	sig7: i64i32i32_v
+	sig8: i64i32i32i32_v
+	sig11: i64i32i32i32_v
+
+blk0: (exec_ctx:i64, module_ctx:i64)
+	Store module_ctx, exec_ctx, 0x8
+	v2:i64 = Load module_ctx, 0x8
+	v3:i64 = Load v2, 0x0
+	v4:i32 = Iconst_32 0x0
+	CallIndirect v3:sig4, exec_ctx, v4
+	v6:i32 = Iconst_32 0x28
+	Jump blk1, v6
+
+blk1: (v5:i32) <-- (blk0)
+	v7:i32 = Iconst_32 0x5
+	v9:i32 = Iadd v5, v7
+	Jump blk2, v9
+
+blk2: (v8:i32) <-- (blk1)
+	Store module_ctx, exec_ctx, 0x8
+	v12:i64 = Load module_ctx, 0x8
+	v13:i64 = Load v12, 0x18
+	v14:i32 = Iconst_32 0x3
+	CallIndirect v13:sig7, exec_ctx, v14, v8
+	Store module_ctx, exec_ctx, 0x8
+	v15:i64 = Load module_ctx, 0x10
+	v16:i64 = Load v15, 0x18
+	v17:i32 = Iconst_32 0x3
+	CallIndirect v16:sig11, exec_ctx, v17, v8, v8
+	Jump blk3, v8, v8
+
+blk3: (v10:i32,v11:i32) <-- (blk2)
+	Store module_ctx, exec_ctx, 0x8
+	v18:i64 = Load module_ctx, 0x10
+	v19:i64 = Load v18, 0x0
+	v20:i32 = Iconst_32 0x0
+	CallIndirect v19:sig8, exec_ctx, v20, v10, v11
+	Jump blk_ret, v10, v11
 `,
 		},
 		{
@@ -783,9 +870,6 @@ blk4: () <-- (blk1)
 	Jump blk1
 
 blk3: () <-- (blk1)
-	Jump fallthrough
-
-blk2: () <-- (blk3)
 	Jump blk_ret, v2
 `,
 		},
@@ -996,9 +1080,6 @@ blk1: () <-- (blk0)
 	Return v2
 
 blk2: () <-- (blk0)
-	Jump fallthrough
-
-blk3: () <-- (blk2)
 	v5:i32 = Iconst_32 0x1
 	v6:i32 = Isub v2, v5
 	v7:i32 = Call f0:sig0, exec_ctx, module_ctx, v6
@@ -1013,14 +1094,14 @@ blk3: () <-- (blk2)
 			name: "memory_store_basic", m: testcases.MemoryStoreBasic.Module,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32)
-	v4:i64 = Iconst_64 0x4
-	v5:i64 = UExtend v2, 32->64
-	v6:i64 = Uload32 module_ctx, 0x10
-	v7:i64 = Iadd v5, v4
-	v8:i32 = Icmp lt_u, v6, v7
-	ExitIfTrue v8, exec_ctx, memory_out_of_bounds
-	v9:i64 = Load module_ctx, 0x8
-	v10:i64 = Iadd v9, v5
+	v4:i64 = Load module_ctx, 0x8
+	v5:i64 = Uload32 module_ctx, 0x10
+	v6:i64 = Iconst_64 0x4
+	v7:i64 = UExtend v2, 32->64
+	v8:i64 = Iadd v7, v6
+	v9:i32 = Icmp lt_u, v5, v8
+	ExitIfTrue v9, exec_ctx, memory_out_of_bounds
+	v10:i64 = Iadd v4, v7
 	Store v3, v10, 0x0
 	v11:i32 = Load v10, 0x0
 	Jump blk_ret, v11
@@ -1030,14 +1111,14 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32)
 			name: "memory_load_basic", m: testcases.MemoryLoadBasic.Module,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
-	v3:i64 = Iconst_64 0x4
-	v4:i64 = UExtend v2, 32->64
-	v5:i64 = Uload32 module_ctx, 0x10
-	v6:i64 = Iadd v4, v3
-	v7:i32 = Icmp lt_u, v5, v6
-	ExitIfTrue v7, exec_ctx, memory_out_of_bounds
-	v8:i64 = Load module_ctx, 0x8
-	v9:i64 = Iadd v8, v4
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = Uload32 module_ctx, 0x10
+	v5:i64 = Iconst_64 0x4
+	v6:i64 = UExtend v2, 32->64
+	v7:i64 = Iadd v6, v5
+	v8:i32 = Icmp lt_u, v4, v7
+	ExitIfTrue v8, exec_ctx, memory_out_of_bounds
+	v9:i64 = Iadd v3, v6
 	v10:i32 = Load v9, 0x0
 	Jump blk_ret, v10
 `,
@@ -1049,60 +1130,62 @@ signatures:
 	sig1: i64i64_v
 
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
-	v3:i32 = Iconst_32 0x0
-	v4:i32 = Icmp eq, v2, v3
-	Brz v4, blk2
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = Uload32 module_ctx, 0x10
+	v5:i32 = Iconst_32 0x0
+	v6:i32 = Icmp eq, v2, v5
+	Brz v6, blk2
 	Jump blk1
 
 blk1: () <-- (blk0)
 	Call f1:sig1, exec_ctx, module_ctx
-	v5:i64 = Load module_ctx, 0x8
-	v6:i64 = Uload32 module_ctx, 0x10
-	Jump blk3
+	v7:i64 = Load module_ctx, 0x8
+	v8:i64 = Uload32 module_ctx, 0x10
+	Jump blk3, v8, v7
 
 blk2: () <-- (blk0)
-	Jump blk3
+	Jump blk3, v4, v3
 
-blk3: () <-- (blk1,blk2)
-	v8:i64 = Iconst_64 0x4
-	v9:i64 = UExtend v2, 32->64
-	v10:i64 = Uload32 module_ctx, 0x10
-	v11:i64 = Iadd v9, v8
-	v12:i32 = Icmp lt_u, v10, v11
-	ExitIfTrue v12, exec_ctx, memory_out_of_bounds
-	v13:i64 = Load module_ctx, 0x8
-	v14:i64 = Iadd v13, v9
-	v15:i32 = Load v14, 0x0
-	Jump blk_ret, v15
+blk3: (v12:i64,v15:i64) <-- (blk1,blk2)
+	v10:i64 = Iconst_64 0x4
+	v11:i64 = UExtend v2, 32->64
+	v13:i64 = Iadd v11, v10
+	v14:i32 = Icmp lt_u, v12, v13
+	ExitIfTrue v14, exec_ctx, memory_out_of_bounds
+	v16:i64 = Iadd v15, v11
+	v17:i32 = Load v16, 0x0
+	Jump blk_ret, v17
 `,
 			expAfterPasses: `
 signatures:
 	sig1: i64i64_v
 
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
-	v3:i32 = Iconst_32 0x0
-	v4:i32 = Icmp eq, v2, v3
-	Brz v4, blk2
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = Uload32 module_ctx, 0x10
+	v5:i32 = Iconst_32 0x0
+	v6:i32 = Icmp eq, v2, v5
+	Brz v6, blk2
 	Jump fallthrough
 
 blk1: () <-- (blk0)
 	Call f1:sig1, exec_ctx, module_ctx
-	Jump blk3
+	v7:i64 = Load module_ctx, 0x8
+	v8:i64 = Uload32 module_ctx, 0x10
+	Jump blk3, v8, v7
 
 blk2: () <-- (blk0)
-	Jump fallthrough
+	Jump fallthrough, v4, v3
 
-blk3: () <-- (blk1,blk2)
-	v8:i64 = Iconst_64 0x4
-	v9:i64 = UExtend v2, 32->64
-	v10:i64 = Uload32 module_ctx, 0x10
-	v11:i64 = Iadd v9, v8
-	v12:i32 = Icmp lt_u, v10, v11
-	ExitIfTrue v12, exec_ctx, memory_out_of_bounds
-	v13:i64 = Load module_ctx, 0x8
-	v14:i64 = Iadd v13, v9
-	v15:i32 = Load v14, 0x0
-	Jump blk_ret, v15
+blk3: (v12:i64,v15:i64) <-- (blk1,blk2)
+	v10:i64 = Iconst_64 0x4
+	v11:i64 = UExtend v2, 32->64
+	v13:i64 = Iadd v11, v10
+	v14:i32 = Icmp lt_u, v12, v13
+	ExitIfTrue v14, exec_ctx, memory_out_of_bounds
+	v16:i64 = Iadd v15, v11
+	v17:i32 = Load v16, 0x0
+	Jump blk_ret, v17
 `,
 		},
 		{
@@ -1123,19 +1206,19 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
 			name: "memory_loads", m: testcases.MemoryLoads.Module,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
-	v3:i64 = Iconst_64 0x4
-	v4:i64 = UExtend v2, 32->64
-	v5:i64 = Uload32 module_ctx, 0x10
-	v6:i64 = Iadd v4, v3
-	v7:i32 = Icmp lt_u, v5, v6
-	ExitIfTrue v7, exec_ctx, memory_out_of_bounds
-	v8:i64 = Load module_ctx, 0x8
-	v9:i64 = Iadd v8, v4
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = Uload32 module_ctx, 0x10
+	v5:i64 = Iconst_64 0x4
+	v6:i64 = UExtend v2, 32->64
+	v7:i64 = Iadd v6, v5
+	v8:i32 = Icmp lt_u, v4, v7
+	ExitIfTrue v8, exec_ctx, memory_out_of_bounds
+	v9:i64 = Iadd v3, v6
 	v10:i32 = Load v9, 0x0
 	v11:i64 = Iconst_64 0x8
 	v12:i64 = UExtend v2, 32->64
 	v13:i64 = Iadd v12, v11
-	v14:i32 = Icmp lt_u, v5, v13
+	v14:i32 = Icmp lt_u, v4, v13
 	ExitIfTrue v14, exec_ctx, memory_out_of_bounds
 	v15:i64 = Load v9, 0x0
 	v16:f32 = Load v9, 0x0
@@ -1143,13 +1226,13 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
 	v18:i64 = Iconst_64 0x13
 	v19:i64 = UExtend v2, 32->64
 	v20:i64 = Iadd v19, v18
-	v21:i32 = Icmp lt_u, v5, v20
+	v21:i32 = Icmp lt_u, v4, v20
 	ExitIfTrue v21, exec_ctx, memory_out_of_bounds
 	v22:i32 = Load v9, 0xf
 	v23:i64 = Iconst_64 0x17
 	v24:i64 = UExtend v2, 32->64
 	v25:i64 = Iadd v24, v23
-	v26:i32 = Icmp lt_u, v5, v25
+	v26:i32 = Icmp lt_u, v4, v25
 	ExitIfTrue v26, exec_ctx, memory_out_of_bounds
 	v27:i64 = Load v9, 0xf
 	v28:f32 = Load v9, 0xf
@@ -1250,70 +1333,76 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 			exp: `
 signatures:
 	sig0: i64i64_i32
-	sig2: i64i32_i32
+	sig2: i64i32i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64)
+	v2:i64 = Load module_ctx, 0x8
+	v3:i64 = Load v2, 0x0
+	v4:i64 = Load module_ctx, 0x8
+	v5:i64 = Load v4, 0x8
 	Store module_ctx, exec_ctx, 0x8
-	v2:i64 = Load module_ctx, 0x18
-	v3:i64 = Load module_ctx, 0x20
-	v4:i32 = CallIndirect v2:sig0, exec_ctx, v3
-	v5:i64 = Load module_ctx, 0x8
-	v6:i64 = Load v5, 0x0
-	v7:i64 = Load module_ctx, 0x8
-	v8:i64 = Load v7, 0x8
+	v6:i64 = Load module_ctx, 0x18
+	v7:i64 = Load module_ctx, 0x20
+	v8:i32 = CallIndirect v6:sig0, exec_ctx, v7
 	v9:i64 = Load module_ctx, 0x8
-	v10:i32 = Load v9, 0x8
-	v11:i32 = Iconst_32 0x10
-	v12:i32 = Ushr v10, v11
-	v13:i32 = Iconst_32 0xa
-	Store module_ctx, exec_ctx, 0x8
-	v14:i64 = Load exec_ctx, 0x48
-	v15:i32 = CallIndirect v14:sig2, exec_ctx, v13
-	v16:i64 = Load module_ctx, 0x8
-	v17:i64 = Load v16, 0x0
-	v18:i64 = Load module_ctx, 0x8
-	v19:i64 = Load v18, 0x8
+	v10:i64 = Load v9, 0x0
+	v11:i64 = Load module_ctx, 0x8
+	v12:i64 = Load v11, 0x8
+	v13:i64 = Load module_ctx, 0x8
+	v14:i32 = Load v13, 0x8
+	v15:i32 = Iconst_32 0x10
+	v16:i32 = Ushr v14, v15
+	v17:i32 = Iconst_32 0xa
 	Store module_ctx, exec_ctx, 0x8
-	v20:i64 = Load module_ctx, 0x18
-	v21:i64 = Load module_ctx, 0x20
-	v22:i32 = CallIndirect v20:sig0, exec_ctx, v21
+	v18:i64 = Load exec_ctx, 0x48
+	v19:i32 = Iconst_32 0x0
+	v20:i32 = CallIndirect v18:sig2, exec_ctx, v17, v19
+	v21:i64 = Load module_ctx, 0x8
+	v22:i64 = Load v21, 0x0
 	v23:i64 = Load module_ctx, 0x8
-	v24:i64 = Load v23, 0x0
-	v25:i64 = Load module_ctx, 0x8
-	v26:i64 = Load v25, 0x8
-	v27:i64 = Load module_ctx, 0x8
-	v28:i32 = Load v27, 0x8
-	v29:i32 = Iconst_32 0x10
-	v30:i32 = Ushr v28, v29
-	Jump blk_ret, v4, v12, v22, v30
+	v24:i64 = Load v23, 0x8
+	Store module_ctx, exec_ctx, 0x8
+	v25:i64 = Load module_ctx, 0x18
+	v26:i64 = Load module_ctx, 0x20
+	v27:i32 = CallIndirect v25:sig0, exec_ctx, v26
+	v28:i64 = Load module_ctx, 0x8
+	v29:i64 = Load v28, 0x0
+	v30:i64 = Load module_ctx, 0x8
+	v31:i64 = Load v30, 0x8
+	v32:i64 = Load module_ctx, 0x8
+	v33:i32 = Load v32, 0x8
+	v34:i32 = Iconst_32 0x10
+	v35:i32 = Ushr v33, v34
+	Jump blk_ret, v8, v16, v27, v35
 `,
 			expAfterPasses: `
 signatures:
 	sig0: i64i64_i32
-	sig2: i64i32_i32
+	sig2: i64i32i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64)
 	Store module_ctx, exec_ctx, 0x8
-	v2:i64 = Load module_ctx, 0x18
-	v3:i64 = Load module_ctx, 0x20
-	v4:i32 = CallIndirect v2:sig0, exec_ctx, v3
-	v9:i64 = Load module_ctx, 0x8
-	v10:i32 = Load v9, 0x8
-	v11:i32 = Iconst_32 0x10
-	v12:i32 = Ushr v10, v11
-	v13:i32 = Iconst_32 0xa
+	v6:i64 = Load module_ctx, 0x18
+	v7:i64 = Load module_ctx, 0x20
+	v8:i32 = CallIndirect v6:sig0, exec_ctx, v7
+	v13:i64 = Load module_ctx, 0x8
+	v14:i32 = Load v13, 0x8
+	v15:i32 = Iconst_32 0x10
+	v16:i32 = Ushr v14, v15
+	v17:i32 = Iconst_32 0xa
 	Store module_ctx, exec_ctx, 0x8
-	v14:i64 = Load exec_ctx, 0x48
-	v15:i32 = CallIndirect v14:sig2, exec_ctx, v13
+	v18:i64 = Load exec_ctx, 0x48
+	v19:i32 = Iconst_32 0x0
+	v20:i32 = CallIndirect v18:sig2, exec_ctx, v17, v19
 	Store module_ctx, exec_ctx, 0x8
-	v20:i64 = Load module_ctx, 0x18
-	v21:i64 = Load module_ctx, 0x20
-	v22:i32 = CallIndirect v20:sig0, exec_ctx, v21
-	v27:i64 = Load module_ctx, 0x8
-	v28:i32 = Load v27, 0x8
-	v29:i32 = Iconst_32 0x10
-	v30:i32 = Ushr v28, v29
-	Jump blk_ret, v4, v12, v22, v30
+	v25:i64 = Load module_ctx, 0x18
+	v26:i64 = Load module_ctx, 0x20
+	v27:i32 = CallIndirect v25:sig0, exec_ctx, v26
+	v32:i64 = Load module_ctx, 0x8
+	v33:i32 = Load v32, 0x8
+	v34:i32 = Iconst_32 0x10
+	v35:i32 = Ushr v33, v34
+	Jump blk_ret, v8, v16, v27, v35
 `,
 		},
 		{
@@ -1321,43 +1410,49 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 			m:    testcases.MemorySizeGrow.Module,
 			exp: `
 signatures:
-	sig1: i64i32_i32
+	sig1: i64i32i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64)
-	v2:i32 = Iconst_32 0x1
+	v2:i64 = Load module_ctx, 0x8
+	v3:i64 = Uload32 module_ctx, 0x10
+	v4:i32 = Iconst_32 0x1
 	Store module_ctx, exec_ctx, 0x8
-	v3:i64 = Load exec_ctx, 0x48
-	v4:i32 = CallIndirect v3:sig1, exec_ctx, v2
-	v5:i64 = Load module_ctx, 0x8
-	v6:i64 = Uload32 module_ctx, 0x10
-	v7:i32 = Load module_ctx, 0x10
-	v8:i32 = Iconst_32 0x10
-	v9:i32 = Ushr v7, v8
-	v10:i32 = Iconst_32 0x1
+	v5:i64 = Load exec_ctx, 0x48
+	v6:i32 = Iconst_32 0x0
+	v7:i32 = CallIndirect v5:sig1, exec_ctx, v4, v6
+	v8:i64 = Load module_ctx, 0x8
+	v9:i64 = Uload32 module_ctx, 0x10
+	v10:i32 = Load module_ctx, 0x10
+	v11:i32 = Iconst_32 0x10
+	v12:i32 = Ushr v10, v11
+	v13:i32 = Iconst_32 0x1
 	Store module_ctx, exec_ctx, 0x8
-	v11:i64 = Load exec_ctx, 0x48
-	v12:i32 = CallIndirect v11:sig1, exec_ctx, v10
-	v13:i64 = Load module_ctx, 0x8
-	v14:i64 = Uload32 module_ctx, 0x10
-	Jump blk_ret, v4, v9, v12
+	v14:i64 = Load exec_ctx, 0x48
+	v15:i32 = Iconst_32 0x0
+	v16:i32 = CallIndirect v14:sig1, exec_ctx, v13, v15
+	v17:i64 = Load module_ctx, 0x8
+	v18:i64 = Uload32 module_ctx, 0x10
+	Jump blk_ret, v7, v12, v16
 `,
 			expAfterPasses: `
 signatures:
-	sig1: i64i32_i32
+	sig1: i64i32i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64)
-	v2:i32 = Iconst_32 0x1
+	v4:i32 = Iconst_32 0x1
 	Store module_ctx, exec_ctx, 0x8
-	v3:i64 = Load exec_ctx, 0x48
-	v4:i32 = CallIndirect v3:sig1, exec_ctx, v2
-	v7:i32 = Load module_ctx, 0x10
-	v8:i32 = Iconst_32 0x10
-	v9:i32 = Ushr v7, v8
-	v10:i32 = Iconst_32 0x1
+	v5:i64 = Load exec_ctx, 0x48
+	v6:i32 = Iconst_32 0x0
+	v7:i32 = CallIndirect v5:sig1, exec_ctx, v4, v6
+	v10:i32 = Load module_ctx, 0x10
+	v11:i32 = Iconst_32 0x10
+	v12:i32 = Ushr v10, v11
+	v13:i32 = Iconst_32 0x1
 	Store module_ctx, exec_ctx, 0x8
-	v11:i64 = Load exec_ctx, 0x48
-	v12:i32 = CallIndirect v11:sig1, exec_ctx, v10
-	Jump blk_ret, v4, v9, v12
+	v14:i64 = Load exec_ctx, 0x48
+	v15:i32 = Iconst_32 0x0
+	v16:i32 = CallIndirect v14:sig1, exec_ctx, v13, v15
+	Jump blk_ret, v7, v12, v16
 `,
 		},
 		{
@@ -1509,41 +1604,26 @@ blk7: () <-- (blk0)
 	Jump blk6
 
 blk8: () <-- (blk0)
-	Jump fallthrough
-
-blk5: () <-- (blk8)
 	v12:i32 = Iconst_32 0xc
 	v13:i32 = Iadd v3, v12
 	Return v13
 
 blk9: () <-- (blk0)
-	Jump fallthrough
-
-blk4: () <-- (blk9)
 	v14:i32 = Iconst_32 0xd
 	v15:i32 = Iadd v3, v14
 	Return v15
 
 blk10: () <-- (blk0)
-	Jump fallthrough
-
-blk3: () <-- (blk10)
 	v16:i32 = Iconst_32 0xe
 	v17:i32 = Iadd v3, v16
 	Return v17
 
 blk11: () <-- (blk0)
-	Jump fallthrough
-
-blk2: () <-- (blk11)
 	v18:i32 = Iconst_32 0xf
 	v19:i32 = Iadd v3, v18
 	Return v19
 
 blk12: () <-- (blk0)
-	Jump fallthrough
-
-blk1: () <-- (blk12)
 	v20:i32 = Iconst_32 0x10
 	v21:i32 = Iadd v3, v20
 	Return v21
@@ -1561,34 +1641,36 @@ blk6: () <-- (blk7,blk13)
 			name: "if_then_end_nesting_unreachable_if_then_else_end", m: testcases.IfThenEndNestingUnreachableIfThenElseEnd.Module,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:f64, v3:f64, v4:f64)
-	v6:i32 = Load module_ctx, 0x10
-	v7:i32 = Iconst_32 0x10
-	v8:i32 = Ushr v6, v7
-	Brz v8, blk3
+	v5:i64 = Load module_ctx, 0x8
+	v6:i64 = Uload32 module_ctx, 0x10
+	v8:i32 = Load module_ctx, 0x10
+	v9:i32 = Iconst_32 0x10
+	v10:i32 = Ushr v8, v9
+	Brz v10, blk3
 	Jump blk2
 
-blk1: (v5:i64) <-- (blk4)
+blk1: (v7:i64) <-- (blk4)
 	Jump blk_ret
 
 blk2: () <-- (blk0)
-	v9:i32 = Load module_ctx, 0x10
-	v10:i32 = Iconst_32 0x10
-	v11:i32 = Ushr v9, v10
+	v11:i32 = Load module_ctx, 0x10
+	v12:i32 = Iconst_32 0x10
+	v13:i32 = Ushr v11, v12
 	Jump blk4
 
 blk3: () <-- (blk0)
 	Jump blk4
 
 blk4: () <-- (blk2,blk3)
-	v12:i64 = Iconst_64 0x0
-	Jump blk1, v12
+	v14:i64 = Iconst_64 0x0
+	Jump blk1, v14
 `,
 			expAfterPasses: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:f64, v3:f64, v4:f64)
-	v6:i32 = Load module_ctx, 0x10
-	v7:i32 = Iconst_32 0x10
-	v8:i32 = Ushr v6, v7
-	Brz v8, blk3
+	v8:i32 = Load module_ctx, 0x10
+	v9:i32 = Iconst_32 0x10
+	v10:i32 = Ushr v8, v9
+	Brz v10, blk3
 	Jump fallthrough
 
 blk2: () <-- (blk0)
@@ -1598,9 +1680,6 @@ blk3: () <-- (blk0)
 	Jump fallthrough
 
 blk4: () <-- (blk2,blk3)
-	Jump fallthrough
-
-blk1: () <-- (blk4)
 	Jump blk_ret
 `,
 		},
@@ -1619,20 +1698,20 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:v128, v3:v128)
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 signatures:
-	sig6: i64i64i32i64_i32
+	sig6: i64i64i32i64i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i64)
+	v5:i64 = Load module_ctx, 0x8
 	Store module_ctx, exec_ctx, 0x8
-	v5:i64 = Iconst_64 0xc
-	v6:i64 = UExtend v2, 32->64
-	v7:i64 = Iconst_64 0x10
-	v8:i64 = Iadd module_ctx, v7
-	v9:i64 = AtomicLoad_64, v8
-	v10:i64 = Iadd v6, v5
-	v11:i32 = Icmp lt_u, v9, v10
-	ExitIfTrue v11, exec_ctx, memory_out_of_bounds
-	v12:i64 = Load module_ctx, 0x8
-	v13:i64 = Iadd v12, v6
+	v6:i64 = Iconst_64 0xc
+	v7:i64 = UExtend v2, 32->64
+	v8:i64 = Iconst_64 0x10
+	v9:i64 = Iadd module_ctx, v8
+	v10:i64 = AtomicLoad_64, v9
+	v11:i64 = Iadd v7, v6
+	v12:i32 = Icmp lt_u, v10, v11
+	ExitIfTrue v12, exec_ctx, memory_out_of_bounds
+	v13:i64 = Iadd v5, v7
 	v14:i64 = Iconst_64 0x8
 	v15:i64 = Iadd v13, v14
 	v16:i64 = Iconst_64 0x3
@@ -1641,8 +1720,9 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i64)
 	v19:i32 = Icmp neq, v17, v18
 	ExitIfTrue v19, exec_ctx, unaligned_atomic
 	v20:i64 = Load exec_ctx, 0x488
-	v21:i32 = CallIndirect v20:sig6, exec_ctx, v4, v3, v15
-	Jump blk_ret, v21
+	v21:i32 = Iconst_32 0x0
+	v22:i32 = CallIndirect v20:sig6, exec_ctx, v4, v3, v15, v21
+	Jump blk_ret, v22
 `,
 		},
 		{
@@ -1651,20 +1731,20 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i64)
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 signatures:
-	sig7: i64i64i64i64_i32
+	sig7: i64i64i64i64i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i64, v4:i64)
+	v5:i64 = Load module_ctx, 0x8
 	Store module_ctx, exec_ctx, 0x8
-	v5:i64 = Iconst_64 0x10
-	v6:i64 = UExtend v2, 32->64
-	v7:i64 = Iconst_64 0x10
-	v8:i64 = Iadd module_ctx, v7
-	v9:i64 = AtomicLoad_64, v8
-	v10:i64 = Iadd v6, v5
-	v11:i32 = Icmp lt_u, v9, v10
-	ExitIfTrue v11, exec_ctx, memory_out_of_bounds
-	v12:i64 = Load module_ctx, 0x8
-	v13:i64 = Iadd v12, v6
+	v6:i64 = Iconst_64 0x10
+	v7:i64 = UExtend v2, 32->64
+	v8:i64 = Iconst_64 0x10
+	v9:i64 = Iadd module_ctx, v8
+	v10:i64 = AtomicLoad_64, v9
+	v11:i64 = Iadd v7, v6
+	v12:i32 = Icmp lt_u, v10, v11
+	ExitIfTrue v12, exec_ctx, memory_out_of_bounds
+	v13:i64 = Iadd v5, v7
 	v14:i64 = Iconst_64 0x8
 	v15:i64 = Iadd v13, v14
 	v16:i64 = Iconst_64 0x7
@@ -1673,8 +1753,9 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i64, v4:i64)
 	v19:i32 = Icmp neq, v17, v18
 	ExitIfTrue v19, exec_ctx, unaligned_atomic
 	v20:i64 = Load exec_ctx, 0x490
-	v21:i32 = CallIndirect v20:sig7, exec_ctx, v4, v3, v15
-	Jump blk_ret, v21
+	v21:i32 = Iconst_32 0x0
+	v22:i32 = CallIndirect v20:sig7, exec_ctx, v4, v3, v15, v21
+	Jump blk_ret, v22
 `,
 		},
 		{
@@ -1683,20 +1764,20 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i64, v4:i64)
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 signatures:
-	sig8: i64i32i64_i32
+	sig8: i64i32i64i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32)
+	v4:i64 = Load module_ctx, 0x8
 	Store module_ctx, exec_ctx, 0x8
-	v4:i64 = Iconst_64 0xc
-	v5:i64 = UExtend v2, 32->64
-	v6:i64 = Iconst_64 0x10
-	v7:i64 = Iadd module_ctx, v6
-	v8:i64 = AtomicLoad_64, v7
-	v9:i64 = Iadd v5, v4
-	v10:i32 = Icmp lt_u, v8, v9
-	ExitIfTrue v10, exec_ctx, memory_out_of_bounds
-	v11:i64 = Load module_ctx, 0x8
-	v12:i64 = Iadd v11, v5
+	v5:i64 = Iconst_64 0xc
+	v6:i64 = UExtend v2, 32->64
+	v7:i64 = Iconst_64 0x10
+	v8:i64 = Iadd module_ctx, v7
+	v9:i64 = AtomicLoad_64, v8
+	v10:i64 = Iadd v6, v5
+	v11:i32 = Icmp lt_u, v9, v10
+	ExitIfTrue v11, exec_ctx, memory_out_of_bounds
+	v12:i64 = Iadd v4, v6
 	v13:i64 = Iconst_64 0x8
 	v14:i64 = Iadd v12, v13
 	v15:i64 = Iconst_64 0x3
@@ -1705,8 +1786,9 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32)
 	v18:i32 = Icmp neq, v16, v17
 	ExitIfTrue v18, exec_ctx, unaligned_atomic
 	v19:i64 = Load exec_ctx, 0x498
-	v20:i32 = CallIndirect v19:sig8, exec_ctx, v3, v14
-	Jump blk_ret, v20
+	v20:i32 = Iconst_32 0x0
+	v21:i32 = CallIndirect v19:sig8, exec_ctx, v3, v14, v20
+	Jump blk_ret, v21
 `,
 		},
 		{
@@ -1715,17 +1797,17 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32)
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:i64, v8:i64)
-	v9:i32 = Iconst_32 0x0
-	v10:i64 = Iconst_64 0x1
-	v11:i64 = UExtend v9, 32->64
-	v12:i64 = Iconst_64 0x10
-	v13:i64 = Iadd module_ctx, v12
-	v14:i64 = AtomicLoad_64, v13
-	v15:i64 = Iadd v11, v10
-	v16:i32 = Icmp lt_u, v14, v15
-	ExitIfTrue v16, exec_ctx, memory_out_of_bounds
-	v17:i64 = Load module_ctx, 0x8
-	v18:i64 = Iadd v17, v11
+	v9:i64 = Load module_ctx, 0x8
+	v10:i32 = Iconst_32 0x0
+	v11:i64 = Iconst_64 0x1
+	v12:i64 = UExtend v10, 32->64
+	v13:i64 = Iconst_64 0x10
+	v14:i64 = Iadd module_ctx, v13
+	v15:i64 = AtomicLoad_64, v14
+	v16:i64 = Iadd v12, v11
+	v17:i32 = Icmp lt_u, v15, v16
+	ExitIfTrue v17, exec_ctx, memory_out_of_bounds
+	v18:i64 = Iadd v9, v12
 	v19:i32 = AtomicRmw add_8, v18, v2
 	v20:i32 = Iconst_32 0x8
 	v21:i64 = Iconst_64 0x2
@@ -1736,7 +1818,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v26:i64 = Iadd v22, v21
 	v27:i32 = Icmp lt_u, v25, v26
 	ExitIfTrue v27, exec_ctx, memory_out_of_bounds
-	v28:i64 = Iadd v17, v22
+	v28:i64 = Iadd v9, v22
 	v29:i64 = Iconst_64 0x1
 	v30:i64 = Band v28, v29
 	v31:i64 = Iconst_64 0x0
@@ -1752,7 +1834,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v40:i64 = Iadd v36, v35
 	v41:i32 = Icmp lt_u, v39, v40
 	ExitIfTrue v41, exec_ctx, memory_out_of_bounds
-	v42:i64 = Iadd v17, v36
+	v42:i64 = Iadd v9, v36
 	v43:i64 = Iconst_64 0x3
 	v44:i64 = Band v42, v43
 	v45:i64 = Iconst_64 0x0
@@ -1768,7 +1850,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v54:i64 = Iadd v50, v49
 	v55:i32 = Icmp lt_u, v53, v54
 	ExitIfTrue v55, exec_ctx, memory_out_of_bounds
-	v56:i64 = Iadd v17, v50
+	v56:i64 = Iadd v9, v50
 	v57:i64 = AtomicRmw add_8, v56, v5
 	v58:i32 = Iconst_32 0x20
 	v59:i64 = Iconst_64 0x2
@@ -1779,7 +1861,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v64:i64 = Iadd v60, v59
 	v65:i32 = Icmp lt_u, v63, v64
 	ExitIfTrue v65, exec_ctx, memory_out_of_bounds
-	v66:i64 = Iadd v17, v60
+	v66:i64 = Iadd v9, v60
 	v67:i64 = Iconst_64 0x1
 	v68:i64 = Band v66, v67
 	v69:i64 = Iconst_64 0x0
@@ -1795,7 +1877,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v78:i64 = Iadd v74, v73
 	v79:i32 = Icmp lt_u, v77, v78
 	ExitIfTrue v79, exec_ctx, memory_out_of_bounds
-	v80:i64 = Iadd v17, v74
+	v80:i64 = Iadd v9, v74
 	v81:i64 = Iconst_64 0x3
 	v82:i64 = Band v80, v81
 	v83:i64 = Iconst_64 0x0
@@ -1811,7 +1893,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v92:i64 = Iadd v88, v87
 	v93:i32 = Icmp lt_u, v91, v92
 	ExitIfTrue v93, exec_ctx, memory_out_of_bounds
-	v94:i64 = Iadd v17, v88
+	v94:i64 = Iadd v9, v88
 	v95:i64 = Iconst_64 0x7
 	v96:i64 = Band v94, v95
 	v97:i64 = Iconst_64 0x0
@@ -1827,17 +1909,17 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:i64, v8:i64)
-	v9:i32 = Iconst_32 0x0
-	v10:i64 = Iconst_64 0x1
-	v11:i64 = UExtend v9, 32->64
-	v12:i64 = Iconst_64 0x10
-	v13:i64 = Iadd module_ctx, v12
-	v14:i64 = AtomicLoad_64, v13
-	v15:i64 = Iadd v11, v10
-	v16:i32 = Icmp lt_u, v14, v15
-	ExitIfTrue v16, exec_ctx, memory_out_of_bounds
-	v17:i64 = Load module_ctx, 0x8
-	v18:i64 = Iadd v17, v11
+	v9:i64 = Load module_ctx, 0x8
+	v10:i32 = Iconst_32 0x0
+	v11:i64 = Iconst_64 0x1
+	v12:i64 = UExtend v10, 32->64
+	v13:i64 = Iconst_64 0x10
+	v14:i64 = Iadd module_ctx, v13
+	v15:i64 = AtomicLoad_64, v14
+	v16:i64 = Iadd v12, v11
+	v17:i32 = Icmp lt_u, v15, v16
+	ExitIfTrue v17, exec_ctx, memory_out_of_bounds
+	v18:i64 = Iadd v9, v12
 	v19:i32 = AtomicRmw sub_8, v18, v2
 	v20:i32 = Iconst_32 0x8
 	v21:i64 = Iconst_64 0x2
@@ -1848,7 +1930,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v26:i64 = Iadd v22, v21
 	v27:i32 = Icmp lt_u, v25, v26
 	ExitIfTrue v27, exec_ctx, memory_out_of_bounds
-	v28:i64 = Iadd v17, v22
+	v28:i64 = Iadd v9, v22
 	v29:i64 = Iconst_64 0x1
 	v30:i64 = Band v28, v29
 	v31:i64 = Iconst_64 0x0
@@ -1864,7 +1946,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v40:i64 = Iadd v36, v35
 	v41:i32 = Icmp lt_u, v39, v40
 	ExitIfTrue v41, exec_ctx, memory_out_of_bounds
-	v42:i64 = Iadd v17, v36
+	v42:i64 = Iadd v9, v36
 	v43:i64 = Iconst_64 0x3
 	v44:i64 = Band v42, v43
 	v45:i64 = Iconst_64 0x0
@@ -1880,7 +1962,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v54:i64 = Iadd v50, v49
 	v55:i32 = Icmp lt_u, v53, v54
 	ExitIfTrue v55, exec_ctx, memory_out_of_bounds
-	v56:i64 = Iadd v17, v50
+	v56:i64 = Iadd v9, v50
 	v57:i64 = AtomicRmw sub_8, v56, v5
 	v58:i32 = Iconst_32 0x20
 	v59:i64 = Iconst_64 0x2
@@ -1891,7 +1973,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v64:i64 = Iadd v60, v59
 	v65:i32 = Icmp lt_u, v63, v64
 	ExitIfTrue v65, exec_ctx, memory_out_of_bounds
-	v66:i64 = Iadd v17, v60
+	v66:i64 = Iadd v9, v60
 	v67:i64 = Iconst_64 0x1
 	v68:i64 = Band v66, v67
 	v69:i64 = Iconst_64 0x0
@@ -1907,7 +1989,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v78:i64 = Iadd v74, v73
 	v79:i32 = Icmp lt_u, v77, v78
 	ExitIfTrue v79, exec_ctx, memory_out_of_bounds
-	v80:i64 = Iadd v17, v74
+	v80:i64 = Iadd v9, v74
 	v81:i64 = Iconst_64 0x3
 	v82:i64 = Band v80, v81
 	v83:i64 = Iconst_64 0x0
@@ -1923,7 +2005,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v92:i64 = Iadd v88, v87
 	v93:i32 = Icmp lt_u, v91, v92
 	ExitIfTrue v93, exec_ctx, memory_out_of_bounds
-	v94:i64 = Iadd v17, v88
+	v94:i64 = Iadd v9, v88
 	v95:i64 = Iconst_64 0x7
 	v96:i64 = Band v94, v95
 	v97:i64 = Iconst_64 0x0
@@ -1939,17 +2021,17 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:i64, v8:i64)
-	v9:i32 = Iconst_32 0x0
-	v10:i64 = Iconst_64 0x1
-	v11:i64 = UExtend v9, 32->64
-	v12:i64 = Iconst_64 0x10
-	v13:i64 = Iadd module_ctx, v12
-	v14:i64 = AtomicLoad_64, v13
-	v15:i64 = Iadd v11, v10
-	v16:i32 = Icmp lt_u, v14, v15
-	ExitIfTrue v16, exec_ctx, memory_out_of_bounds
-	v17:i64 = Load module_ctx, 0x8
-	v18:i64 = Iadd v17, v11
+	v9:i64 = Load module_ctx, 0x8
+	v10:i32 = Iconst_32 0x0
+	v11:i64 = Iconst_64 0x1
+	v12:i64 = UExtend v10, 32->64
+	v13:i64 = Iconst_64 0x10
+	v14:i64 = Iadd module_ctx, v13
+	v15:i64 = AtomicLoad_64, v14
+	v16:i64 = Iadd v12, v11
+	v17:i32 = Icmp lt_u, v15, v16
+	ExitIfTrue v17, exec_ctx, memory_out_of_bounds
+	v18:i64 = Iadd v9, v12
 	v19:i32 = AtomicRmw and_8, v18, v2
 	v20:i32 = Iconst_32 0x0
 	v21:i64 = Iconst_64 0xa
@@ -1960,7 +2042,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v26:i64 = Iadd v22, v21
 	v27:i32 = Icmp lt_u, v25, v26
 	ExitIfTrue v27, exec_ctx, memory_out_of_bounds
-	v28:i64 = Iadd v17, v22
+	v28:i64 = Iadd v9, v22
 	v29:i64 = Iconst_64 0x8
 	v30:i64 = Iadd v28, v29
 	v31:i64 = Iconst_64 0x1
@@ -1978,7 +2060,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v42:i64 = Iadd v38, v37
 	v43:i32 = Icmp lt_u, v41, v42
 	ExitIfTrue v43, exec_ctx, memory_out_of_bounds
-	v44:i64 = Iadd v17, v38
+	v44:i64 = Iadd v9, v38
 	v45:i64 = Iconst_64 0x10
 	v46:i64 = Iadd v44, v45
 	v47:i64 = Iconst_64 0x3
@@ -1996,7 +2078,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v58:i64 = Iadd v54, v53
 	v59:i32 = Icmp lt_u, v57, v58
 	ExitIfTrue v59, exec_ctx, memory_out_of_bounds
-	v60:i64 = Iadd v17, v54
+	v60:i64 = Iadd v9, v54
 	v61:i64 = Iconst_64 0x18
 	v62:i64 = Iadd v60, v61
 	v63:i64 = AtomicRmw and_8, v62, v5
@@ -2009,7 +2091,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v70:i64 = Iadd v66, v65
 	v71:i32 = Icmp lt_u, v69, v70
 	ExitIfTrue v71, exec_ctx, memory_out_of_bounds
-	v72:i64 = Iadd v17, v66
+	v72:i64 = Iadd v9, v66
 	v73:i64 = Iconst_64 0x20
 	v74:i64 = Iadd v72, v73
 	v75:i64 = Iconst_64 0x1
@@ -2027,7 +2109,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v86:i64 = Iadd v82, v81
 	v87:i32 = Icmp lt_u, v85, v86
 	ExitIfTrue v87, exec_ctx, memory_out_of_bounds
-	v88:i64 = Iadd v17, v82
+	v88:i64 = Iadd v9, v82
 	v89:i64 = Iconst_64 0x28
 	v90:i64 = Iadd v88, v89
 	v91:i64 = Iconst_64 0x3
@@ -2045,7 +2127,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v102:i64 = Iadd v98, v97
 	v103:i32 = Icmp lt_u, v101, v102
 	ExitIfTrue v103, exec_ctx, memory_out_of_bounds
-	v104:i64 = Iadd v17, v98
+	v104:i64 = Iadd v9, v98
 	v105:i64 = Iconst_64 0x30
 	v106:i64 = Iadd v104, v105
 	v107:i64 = Iconst_64 0x7
@@ -2063,17 +2145,17 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:i64, v8:i64)
-	v9:i32 = Iconst_32 0x0
-	v10:i64 = Iconst_64 0x1
-	v11:i64 = UExtend v9, 32->64
-	v12:i64 = Iconst_64 0x10
-	v13:i64 = Iadd module_ctx, v12
-	v14:i64 = AtomicLoad_64, v13
-	v15:i64 = Iadd v11, v10
-	v16:i32 = Icmp lt_u, v14, v15
-	ExitIfTrue v16, exec_ctx, memory_out_of_bounds
-	v17:i64 = Load module_ctx, 0x8
-	v18:i64 = Iadd v17, v11
+	v9:i64 = Load module_ctx, 0x8
+	v10:i32 = Iconst_32 0x0
+	v11:i64 = Iconst_64 0x1
+	v12:i64 = UExtend v10, 32->64
+	v13:i64 = Iconst_64 0x10
+	v14:i64 = Iadd module_ctx, v13
+	v15:i64 = AtomicLoad_64, v14
+	v16:i64 = Iadd v12, v11
+	v17:i32 = Icmp lt_u, v15, v16
+	ExitIfTrue v17, exec_ctx, memory_out_of_bounds
+	v18:i64 = Iadd v9, v12
 	v19:i32 = AtomicRmw or_8, v18, v2
 	v20:i32 = Iconst_32 0x0
 	v21:i64 = Iconst_64 0xa
@@ -2084,7 +2166,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v26:i64 = Iadd v22, v21
 	v27:i32 = Icmp lt_u, v25, v26
 	ExitIfTrue v27, exec_ctx, memory_out_of_bounds
-	v28:i64 = Iadd v17, v22
+	v28:i64 = Iadd v9, v22
 	v29:i64 = Iconst_64 0x8
 	v30:i64 = Iadd v28, v29
 	v31:i64 = Iconst_64 0x1
@@ -2102,7 +2184,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v42:i64 = Iadd v38, v37
 	v43:i32 = Icmp lt_u, v41, v42
 	ExitIfTrue v43, exec_ctx, memory_out_of_bounds
-	v44:i64 = Iadd v17, v38
+	v44:i64 = Iadd v9, v38
 	v45:i64 = Iconst_64 0x10
 	v46:i64 = Iadd v44, v45
 	v47:i64 = Iconst_64 0x3
@@ -2120,7 +2202,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v58:i64 = Iadd v54, v53
 	v59:i32 = Icmp lt_u, v57, v58
 	ExitIfTrue v59, exec_ctx, memory_out_of_bounds
-	v60:i64 = Iadd v17, v54
+	v60:i64 = Iadd v9, v54
 	v61:i64 = Iconst_64 0x18
 	v62:i64 = Iadd v60, v61
 	v63:i64 = AtomicRmw or_8, v62, v5
@@ -2133,7 +2215,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v70:i64 = Iadd v66, v65
 	v71:i32 = Icmp lt_u, v69, v70
 	ExitIfTrue v71, exec_ctx, memory_out_of_bounds
-	v72:i64 = Iadd v17, v66
+	v72:i64 = Iadd v9, v66
 	v73:i64 = Iconst_64 0x20
 	v74:i64 = Iadd v72, v73
 	v75:i64 = Iconst_64 0x1
@@ -2151,7 +2233,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v86:i64 = Iadd v82, v81
 	v87:i32 = Icmp lt_u, v85, v86
 	ExitIfTrue v87, exec_ctx, memory_out_of_bounds
-	v88:i64 = Iadd v17, v82
+	v88:i64 = Iadd v9, v82
 	v89:i64 = Iconst_64 0x28
 	v90:i64 = Iadd v88, v89
 	v91:i64 = Iconst_64 0x3
@@ -2169,7 +2251,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v102:i64 = Iadd v98, v97
 	v103:i32 = Icmp lt_u, v101, v102
 	ExitIfTrue v103, exec_ctx, memory_out_of_bounds
-	v104:i64 = Iadd v17, v98
+	v104:i64 = Iadd v9, v98
 	v105:i64 = Iconst_64 0x30
 	v106:i64 = Iadd v104, v105
 	v107:i64 = Iconst_64 0x7
@@ -2187,17 +2269,17 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:i64, v8:i64)
-	v9:i32 = Iconst_32 0x0
-	v10:i64 = Iconst_64 0x1
-	v11:i64 = UExtend v9, 32->64
-	v12:i64 = Iconst_64 0x10
-	v13:i64 = Iadd module_ctx, v12
-	v14:i64 = AtomicLoad_64, v13
-	v15:i64 = Iadd v11, v10
-	v16:i32 = Icmp lt_u, v14, v15
-	ExitIfTrue v16, exec_ctx, memory_out_of_bounds
-	v17:i64 = Load module_ctx, 0x8
-	v18:i64 = Iadd v17, v11
+	v9:i64 = Load module_ctx, 0x8
+	v10:i32 = Iconst_32 0x0
+	v11:i64 = Iconst_64 0x1
+	v12:i64 = UExtend v10, 32->64
+	v13:i64 = Iconst_64 0x10
+	v14:i64 = Iadd module_ctx, v13
+	v15:i64 = AtomicLoad_64, v14
+	v16:i64 = Iadd v12, v11
+	v17:i32 = Icmp lt_u, v15, v16
+	ExitIfTrue v17, exec_ctx, memory_out_of_bounds
+	v18:i64 = Iadd v9, v12
 	v19:i32 = AtomicRmw xor_8, v18, v2
 	v20:i32 = Iconst_32 0x0
 	v21:i64 = Iconst_64 0xa
@@ -2208,7 +2290,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v26:i64 = Iadd v22, v21
 	v27:i32 = Icmp lt_u, v25, v26
 	ExitIfTrue v27, exec_ctx, memory_out_of_bounds
-	v28:i64 = Iadd v17, v22
+	v28:i64 = Iadd v9, v22
 	v29:i64 = Iconst_64 0x8
 	v30:i64 = Iadd v28, v29
 	v31:i64 = Iconst_64 0x1
@@ -2226,7 +2308,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v42:i64 = Iadd v38, v37
 	v43:i32 = Icmp lt_u, v41, v42
 	ExitIfTrue v43, exec_ctx, memory_out_of_bounds
-	v44:i64 = Iadd v17, v38
+	v44:i64 = Iadd v9, v38
 	v45:i64 = Iconst_64 0x10
 	v46:i64 = Iadd v44, v45
 	v47:i64 = Iconst_64 0x3
@@ -2244,7 +2326,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v58:i64 = Iadd v54, v53
 	v59:i32 = Icmp lt_u, v57, v58
 	ExitIfTrue v59, exec_ctx, memory_out_of_bounds
-	v60:i64 = Iadd v17, v54
+	v60:i64 = Iadd v9, v54
 	v61:i64 = Iconst_64 0x18
 	v62:i64 = Iadd v60, v61
 	v63:i64 = AtomicRmw xor_8, v62, v5
@@ -2257,7 +2339,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v70:i64 = Iadd v66, v65
 	v71:i32 = Icmp lt_u, v69, v70
 	ExitIfTrue v71, exec_ctx, memory_out_of_bounds
-	v72:i64 = Iadd v17, v66
+	v72:i64 = Iadd v9, v66
 	v73:i64 = Iconst_64 0x20
 	v74:i64 = Iadd v72, v73
 	v75:i64 = Iconst_64 0x1
@@ -2275,7 +2357,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v86:i64 = Iadd v82, v81
 	v87:i32 = Icmp lt_u, v85, v86
 	ExitIfTrue v87, exec_ctx, memory_out_of_bounds
-	v88:i64 = Iadd v17, v82
+	v88:i64 = Iadd v9, v82
 	v89:i64 = Iconst_64 0x28
 	v90:i64 = Iadd v88, v89
 	v91:i64 = Iconst_64 0x3
@@ -2293,7 +2375,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v102:i64 = Iadd v98, v97
 	v103:i32 = Icmp lt_u, v101, v102
 	ExitIfTrue v103, exec_ctx, memory_out_of_bounds
-	v104:i64 = Iadd v17, v98
+	v104:i64 = Iadd v9, v98
 	v105:i64 = Iconst_64 0x30
 	v106:i64 = Iadd v104, v105
 	v107:i64 = Iconst_64 0x7
@@ -2311,17 +2393,17 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:i64, v8:i64)
-	v9:i32 = Iconst_32 0x0
-	v10:i64 = Iconst_64 0x1
-	v11:i64 = UExtend v9, 32->64
-	v12:i64 = Iconst_64 0x10
-	v13:i64 = Iadd module_ctx, v12
-	v14:i64 = AtomicLoad_64, v13
-	v15:i64 = Iadd v11, v10
-	v16:i32 = Icmp lt_u, v14, v15
-	ExitIfTrue v16, exec_ctx, memory_out_of_bounds
-	v17:i64 = Load module_ctx, 0x8
-	v18:i64 = Iadd v17, v11
+	v9:i64 = Load module_ctx, 0x8
+	v10:i32 = Iconst_32 0x0
+	v11:i64 = Iconst_64 0x1
+	v12:i64 = UExtend v10, 32->64
+	v13:i64 = Iconst_64 0x10
+	v14:i64 = Iadd module_ctx, v13
+	v15:i64 = AtomicLoad_64, v14
+	v16:i64 = Iadd v12, v11
+	v17:i32 = Icmp lt_u, v15, v16
+	ExitIfTrue v17, exec_ctx, memory_out_of_bounds
+	v18:i64 = Iadd v9, v12
 	v19:i32 = AtomicRmw xchg_8, v18, v2
 	v20:i32 = Iconst_32 0x0
 	v21:i64 = Iconst_64 0xa
@@ -2332,7 +2414,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v26:i64 = Iadd v22, v21
 	v27:i32 = Icmp lt_u, v25, v26
 	ExitIfTrue v27, exec_ctx, memory_out_of_bounds
-	v28:i64 = Iadd v17, v22
+	v28:i64 = Iadd v9, v22
 	v29:i64 = Iconst_64 0x8
 	v30:i64 = Iadd v28, v29
 	v31:i64 = Iconst_64 0x1
@@ -2350,7 +2432,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v42:i64 = Iadd v38, v37
 	v43:i32 = Icmp lt_u, v41, v42
 	ExitIfTrue v43, exec_ctx, memory_out_of_bounds
-	v44:i64 = Iadd v17, v38
+	v44:i64 = Iadd v9, v38
 	v45:i64 = Iconst_64 0x10
 	v46:i64 = Iadd v44, v45
 	v47:i64 = Iconst_64 0x3
@@ -2368,7 +2450,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v58:i64 = Iadd v54, v53
 	v59:i32 = Icmp lt_u, v57, v58
 	ExitIfTrue v59, exec_ctx, memory_out_of_bounds
-	v60:i64 = Iadd v17, v54
+	v60:i64 = Iadd v9, v54
 	v61:i64 = Iconst_64 0x18
 	v62:i64 = Iadd v60, v61
 	v63:i64 = AtomicRmw xchg_8, v62, v5
@@ -2381,7 +2463,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v70:i64 = Iadd v66, v65
 	v71:i32 = Icmp lt_u, v69, v70
 	ExitIfTrue v71, exec_ctx, memory_out_of_bounds
-	v72:i64 = Iadd v17, v66
+	v72:i64 = Iadd v9, v66
 	v73:i64 = Iconst_64 0x20
 	v74:i64 = Iadd v72, v73
 	v75:i64 = Iconst_64 0x1
@@ -2399,7 +2481,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v86:i64 = Iadd v82, v81
 	v87:i32 = Icmp lt_u, v85, v86
 	ExitIfTrue v87, exec_ctx, memory_out_of_bounds
-	v88:i64 = Iadd v17, v82
+	v88:i64 = Iadd v9, v82
 	v89:i64 = Iconst_64 0x28
 	v90:i64 = Iadd v88, v89
 	v91:i64 = Iconst_64 0x3
@@ -2417,7 +2499,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v102:i64 = Iadd v98, v97
 	v103:i32 = Icmp lt_u, v101, v102
 	ExitIfTrue v103, exec_ctx, memory_out_of_bounds
-	v104:i64 = Iadd v17, v98
+	v104:i64 = Iadd v9, v98
 	v105:i64 = Iconst_64 0x30
 	v106:i64 = Iadd v104, v105
 	v107:i64 = Iconst_64 0x7
@@ -2435,17 +2517,17 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:i64, v8:i64)
-	v9:i32 = Iconst_32 0x0
-	v10:i64 = Iconst_64 0x1
-	v11:i64 = UExtend v9, 32->64
-	v12:i64 = Iconst_64 0x10
-	v13:i64 = Iadd module_ctx, v12
-	v14:i64 = AtomicLoad_64, v13
-	v15:i64 = Iadd v11, v10
-	v16:i32 = Icmp lt_u, v14, v15
-	ExitIfTrue v16, exec_ctx, memory_out_of_bounds
-	v17:i64 = Load module_ctx, 0x8
-	v18:i64 = Iadd v17, v11
+	v9:i64 = Load module_ctx, 0x8
+	v10:i32 = Iconst_32 0x0
+	v11:i64 = Iconst_64 0x1
+	v12:i64 = UExtend v10, 32->64
+	v13:i64 = Iconst_64 0x10
+	v14:i64 = Iadd module_ctx, v13
+	v15:i64 = AtomicLoad_64, v14
+	v16:i64 = Iadd v12, v11
+	v17:i32 = Icmp lt_u, v15, v16
+	ExitIfTrue v17, exec_ctx, memory_out_of_bounds
+	v18:i64 = Iadd v9, v12
 	AtomicStore_8, v18, v2
 	v19:i32 = Iconst_32 0x0
 	v20:i64 = Iconst_64 0x1
@@ -2456,7 +2538,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v25:i64 = Iadd v21, v20
 	v26:i32 = Icmp lt_u, v24, v25
 	ExitIfTrue v26, exec_ctx, memory_out_of_bounds
-	v27:i64 = Iadd v17, v21
+	v27:i64 = Iadd v9, v21
 	v28:i32 = AtomicLoad_8, v27
 	v29:i32 = Iconst_32 0x0
 	v30:i64 = Iconst_64 0xa
@@ -2467,7 +2549,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v35:i64 = Iadd v31, v30
 	v36:i32 = Icmp lt_u, v34, v35
 	ExitIfTrue v36, exec_ctx, memory_out_of_bounds
-	v37:i64 = Iadd v17, v31
+	v37:i64 = Iadd v9, v31
 	v38:i64 = Iconst_64 0x8
 	v39:i64 = Iadd v37, v38
 	v40:i64 = Iconst_64 0x1
@@ -2485,7 +2567,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v50:i64 = Iadd v46, v45
 	v51:i32 = Icmp lt_u, v49, v50
 	ExitIfTrue v51, exec_ctx, memory_out_of_bounds
-	v52:i64 = Iadd v17, v46
+	v52:i64 = Iadd v9, v46
 	v53:i64 = Iconst_64 0x8
 	v54:i64 = Iadd v52, v53
 	v55:i64 = Iconst_64 0x1
@@ -2503,7 +2585,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v66:i64 = Iadd v62, v61
 	v67:i32 = Icmp lt_u, v65, v66
 	ExitIfTrue v67, exec_ctx, memory_out_of_bounds
-	v68:i64 = Iadd v17, v62
+	v68:i64 = Iadd v9, v62
 	v69:i64 = Iconst_64 0x10
 	v70:i64 = Iadd v68, v69
 	v71:i64 = Iconst_64 0x3
@@ -2521,7 +2603,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v81:i64 = Iadd v77, v76
 	v82:i32 = Icmp lt_u, v80, v81
 	ExitIfTrue v82, exec_ctx, memory_out_of_bounds
-	v83:i64 = Iadd v17, v77
+	v83:i64 = Iadd v9, v77
 	v84:i64 = Iconst_64 0x10
 	v85:i64 = Iadd v83, v84
 	v86:i64 = Iconst_64 0x3
@@ -2539,7 +2621,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v97:i64 = Iadd v93, v92
 	v98:i32 = Icmp lt_u, v96, v97
 	ExitIfTrue v98, exec_ctx, memory_out_of_bounds
-	v99:i64 = Iadd v17, v93
+	v99:i64 = Iadd v9, v93
 	v100:i64 = Iconst_64 0x18
 	v101:i64 = Iadd v99, v100
 	AtomicStore_8, v101, v5
@@ -2552,7 +2634,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v108:i64 = Iadd v104, v103
 	v109:i32 = Icmp lt_u, v107, v108
 	ExitIfTrue v109, exec_ctx, memory_out_of_bounds
-	v110:i64 = Iadd v17, v104
+	v110:i64 = Iadd v9, v104
 	v111:i64 = Iconst_64 0x18
 	v112:i64 = Iadd v110, v111
 	v113:i64 = AtomicLoad_8, v112
@@ -2565,7 +2647,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v120:i64 = Iadd v116, v115
 	v121:i32 = Icmp lt_u, v119, v120
 	ExitIfTrue v121, exec_ctx, memory_out_of_bounds
-	v122:i64 = Iadd v17, v116
+	v122:i64 = Iadd v9, v116
 	v123:i64 = Iconst_64 0x20
 	v124:i64 = Iadd v122, v123
 	v125:i64 = Iconst_64 0x1
@@ -2583,7 +2665,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v135:i64 = Iadd v131, v130
 	v136:i32 = Icmp lt_u, v134, v135
 	ExitIfTrue v136, exec_ctx, memory_out_of_bounds
-	v137:i64 = Iadd v17, v131
+	v137:i64 = Iadd v9, v131
 	v138:i64 = Iconst_64 0x20
 	v139:i64 = Iadd v137, v138
 	v140:i64 = Iconst_64 0x1
@@ -2601,7 +2683,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v151:i64 = Iadd v147, v146
 	v152:i32 = Icmp lt_u, v150, v151
 	ExitIfTrue v152, exec_ctx, memory_out_of_bounds
-	v153:i64 = Iadd v17, v147
+	v153:i64 = Iadd v9, v147
 	v154:i64 = Iconst_64 0x28
 	v155:i64 = Iadd v153, v154
 	v156:i64 = Iconst_64 0x3
@@ -2619,7 +2701,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v166:i64 = Iadd v162, v161
 	v167:i32 = Icmp lt_u, v165, v166
 	ExitIfTrue v167, exec_ctx, memory_out_of_bounds
-	v168:i64 = Iadd v17, v162
+	v168:i64 = Iadd v9, v162
 	v169:i64 = Iconst_64 0x28
 	v170:i64 = Iadd v168, v169
 	v171:i64 = Iconst_64 0x3
@@ -2637,7 +2719,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v182:i64 = Iadd v178, v177
 	v183:i32 = Icmp lt_u, v181, v182
 	ExitIfTrue v183, exec_ctx, memory_out_of_bounds
-	v184:i64 = Iadd v17, v178
+	v184:i64 = Iadd v9, v178
 	v185:i64 = Iconst_64 0x30
 	v186:i64 = Iadd v184, v185
 	v187:i64 = Iconst_64 0x7
@@ -2655,7 +2737,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 	v197:i64 = Iadd v193, v192
 	v198:i32 = Icmp lt_u, v196, v197
 	ExitIfTrue v198, exec_ctx, memory_out_of_bounds
-	v199:i64 = Iadd v17, v193
+	v199:i64 = Iadd v9, v193
 	v200:i64 = Iconst_64 0x30
 	v201:i64 = Iadd v199, v200
 	v202:i64 = Iconst_64 0x7
@@ -2673,17 +2755,17 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i64, v6:i64, v7:
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i32, v6:i32, v7:i32, v8:i64, v9:i64, v10:i64, v11:i64, v12:i64, v13:i64, v14:i64, v15:i64)
-	v16:i32 = Iconst_32 0x0
-	v17:i64 = Iconst_64 0x1
-	v18:i64 = UExtend v16, 32->64
-	v19:i64 = Iconst_64 0x10
-	v20:i64 = Iadd module_ctx, v19
-	v21:i64 = AtomicLoad_64, v20
-	v22:i64 = Iadd v18, v17
-	v23:i32 = Icmp lt_u, v21, v22
-	ExitIfTrue v23, exec_ctx, memory_out_of_bounds
-	v24:i64 = Load module_ctx, 0x8
-	v25:i64 = Iadd v24, v18
+	v16:i64 = Load module_ctx, 0x8
+	v17:i32 = Iconst_32 0x0
+	v18:i64 = Iconst_64 0x1
+	v19:i64 = UExtend v17, 32->64
+	v20:i64 = Iconst_64 0x10
+	v21:i64 = Iadd module_ctx, v20
+	v22:i64 = AtomicLoad_64, v21
+	v23:i64 = Iadd v19, v18
+	v24:i32 = Icmp lt_u, v22, v23
+	ExitIfTrue v24, exec_ctx, memory_out_of_bounds
+	v25:i64 = Iadd v16, v19
 	v26:i32 = AtomicCas_8, v25, v2, v3
 	v27:i32 = Iconst_32 0x0
 	v28:i64 = Iconst_64 0xa
@@ -2694,7 +2776,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i32, v6:i32, v7:
 	v33:i64 = Iadd v29, v28
 	v34:i32 = Icmp lt_u, v32, v33
 	ExitIfTrue v34, exec_ctx, memory_out_of_bounds
-	v35:i64 = Iadd v24, v29
+	v35:i64 = Iadd v16, v29
 	v36:i64 = Iconst_64 0x8
 	v37:i64 = Iadd v35, v36
 	v38:i64 = Iconst_64 0x1
@@ -2712,7 +2794,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i32, v6:i32, v7:
 	v49:i64 = Iadd v45, v44
 	v50:i32 = Icmp lt_u, v48, v49
 	ExitIfTrue v50, exec_ctx, memory_out_of_bounds
-	v51:i64 = Iadd v24, v45
+	v51:i64 = Iadd v16, v45
 	v52:i64 = Iconst_64 0x10
 	v53:i64 = Iadd v51, v52
 	v54:i64 = Iconst_64 0x3
@@ -2730,7 +2812,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i32, v6:i32, v7:
 	v65:i64 = Iadd v61, v60
 	v66:i32 = Icmp lt_u, v64, v65
 	ExitIfTrue v66, exec_ctx, memory_out_of_bounds
-	v67:i64 = Iadd v24, v61
+	v67:i64 = Iadd v16, v61
 	v68:i64 = Iconst_64 0x18
 	v69:i64 = Iadd v67, v68
 	v70:i64 = AtomicCas_8, v69, v8, v9
@@ -2743,7 +2825,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i32, v6:i32, v7:
 	v77:i64 = Iadd v73, v72
 	v78:i32 = Icmp lt_u, v76, v77
 	ExitIfTrue v78, exec_ctx, memory_out_of_bounds
-	v79:i64 = Iadd v24, v73
+	v79:i64 = Iadd v16, v73
 	v80:i64 = Iconst_64 0x20
 	v81:i64 = Iadd v79, v80
 	v82:i64 = Iconst_64 0x1
@@ -2761,7 +2843,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i32, v6:i32, v7:
 	v93:i64 = Iadd v89, v88
 	v94:i32 = Icmp lt_u, v92, v93
 	ExitIfTrue v94, exec_ctx, memory_out_of_bounds
-	v95:i64 = Iadd v24, v89
+	v95:i64 = Iadd v16, v89
 	v96:i64 = Iconst_64 0x28
 	v97:i64 = Iadd v95, v96
 	v98:i64 = Iconst_64 0x3
@@ -2779,7 +2861,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i32, v6:i32, v7:
 	v109:i64 = Iadd v105, v104
 	v110:i32 = Icmp lt_u, v108, v109
 	ExitIfTrue v110, exec_ctx, memory_out_of_bounds
-	v111:i64 = Iadd v24, v105
+	v111:i64 = Iadd v16, v105
 	v112:i64 = Iconst_64 0x30
 	v113:i64 = Iadd v111, v112
 	v114:i64 = Iconst_64 0x7
@@ -2797,6 +2879,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i32, v5:i32, v6:i32, v7:
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64)
+	v2:i64 = Load module_ctx, 0x8
 	Fence 0
 	Jump blk_ret
 `,
@@ -2850,19 +2933,19 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 						wasm.OpcodeEnd,
 					},
 				}},
-				MemorySection: &wasm.Memory{Min: 1},
+				MemorySection: []wasm.Memory{{Min: 1}},
 			},
 			features: api.CoreFeaturesV2,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
-	v3:i64 = Iconst_64 0x24
-	v4:i64 = UExtend v2, 32->64
-	v5:i64 = Uload32 module_ctx, 0x10
-	v6:i64 = Iadd v4, v3
-	v7:i32 = Icmp lt_u, v5, v6
-	ExitIfTrue v7, exec_ctx, memory_out_of_bounds
-	v8:i64 = Load module_ctx, 0x8
-	v9:i64 = Iadd v8, v4
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = Uload32 module_ctx, 0x10
+	v5:i64 = Iconst_64 0x24
+	v6:i64 = UExtend v2, 32->64
+	v7:i64 = Iadd v6, v5
+	v8:i32 = Icmp lt_u, v4, v7
+	ExitIfTrue v8, exec_ctx, memory_out_of_bounds
+	v9:i64 = Iadd v3, v6
 	v10:i32 = Load v9, 0x20
 	Brz v2, blk2
 	Jump blk1
@@ -2876,16 +2959,15 @@ blk2: () <-- (blk0)
 	v13:i64 = Iconst_64 0x34
 	v14:i64 = UExtend v2, 32->64
 	v15:i64 = Iadd v14, v13
-	v16:i32 = Icmp lt_u, v5, v15
+	v16:i32 = Icmp lt_u, v4, v15
 	ExitIfTrue v16, exec_ctx, memory_out_of_bounds
 	v17:i32 = Load v9, 0x30
 	v18:i32 = Load v9, 0x25
 	Jump blk3
 
 blk3: () <-- (blk1,blk2)
-	v20:i64 = Load module_ctx, 0x8
 	v21:i64 = UExtend v2, 32->64
-	v22:i64 = Iadd v20, v21
+	v22:i64 = Iadd v3, v21
 	v23:i32 = Load v22, 0x15
 	Jump blk_ret
 `,
@@ -2925,30 +3007,28 @@ blk3: () <-- (blk1,blk2)
 						wasm.OpcodeEnd,
 					},
 				}},
-				MemorySection: &wasm.Memory{Min: 1},
+				MemorySection: []wasm.Memory{{Min: 1}},
 			},
 			features: api.CoreFeaturesV2,
 			exp: `
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
-	v3:i64 = Iconst_64 0x14
-	v4:i64 = UExtend v2, 32->64
-	v5:i64 = Uload32 module_ctx, 0x10
-	v6:i64 = Iadd v4, v3
-	v7:i32 = Icmp lt_u, v5, v6
-	ExitIfTrue v7, exec_ctx, memory_out_of_bounds
-	v8:i64 = Load module_ctx, 0x8
-	v9:i64 = Iadd v8, v4
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = Uload32 module_ctx, 0x10
+	v5:i64 = Iconst_64 0x14
+	v6:i64 = UExtend v2, 32->64
+	v7:i64 = Iadd v6, v5
+	v8:i32 = Icmp lt_u, v4, v7
+	ExitIfTrue v8, exec_ctx, memory_out_of_bounds
+	v9:i64 = Iadd v3, v6
 	v10:i32 = Load v9, 0x10
-	Jump blk1, v2
+	Jump blk1, v2, v4, v3
 
-blk1: (v11:i32) <-- (blk0,blk6)
+blk1: (v11:i32,v14:i64,v17:i64) <-- (blk0,blk6)
 	v12:i64 = Iconst_64 0x24
 	v13:i64 = UExtend v11, 32->64
-	v14:i64 = Uload32 module_ctx, 0x10
 	v15:i64 = Iadd v13, v12
 	v16:i32 = Icmp lt_u, v14, v15
 	ExitIfTrue v16, exec_ctx, memory_out_of_bounds
-	v17:i64 = Load module_ctx, 0x8
 	v18:i64 = Iadd v17, v13
 	v19:i32 = Load v18, 0x20
 	Brz v19, blk4
@@ -2957,7 +3037,7 @@ blk1: (v11:i32) <-- (blk0,blk6)
 blk2: ()
 
 blk3: () <-- (blk1)
-	Jump blk6, v11
+	Jump blk6, v11, v14, v17
 
 blk4: () <-- (blk1)
 	Brnz v11, blk_ret
@@ -2966,17 +3046,15 @@ blk4: () <-- (blk1)
 blk5: () <-- (blk7,blk9)
 	Jump blk_ret
 
-blk6: (v20:i32) <-- (blk3)
+blk6: (v20:i32,v23:i64,v26:i64) <-- (blk3)
 	v21:i64 = Iconst_64 0x54
 	v22:i64 = UExtend v20, 32->64
-	v23:i64 = Uload32 module_ctx, 0x10
 	v24:i64 = Iadd v22, v21
 	v25:i32 = Icmp lt_u, v23, v24
 	ExitIfTrue v25, exec_ctx, memory_out_of_bounds
-	v26:i64 = Load module_ctx, 0x8
 	v27:i64 = Iadd v26, v22
 	v28:i32 = Load v27, 0x50
-	Brnz v28, blk1, v20
+	Brnz v28, blk1, v20, v23, v26
 	Jump blk8
 
 blk7: () <-- (blk8)
@@ -2987,6 +3065,183 @@ blk8: () <-- (blk6)
 
 blk9: () <-- (blk4)
 	Jump blk5
+`,
+		},
+		{
+			name: "bounds check with constant addends",
+			m: &wasm.Module{
+				TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{wasm.ValueTypeI32}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []wasm.Code{{
+					Body: []byte{
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI32Load, 0x2, 0x10, // staticOffset=0x10
+						wasm.OpcodeDrop,
+
+						// This bound check should be removed since [local0+8, local0+12) is known to be in bounds.
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI32Const, 8,
+						wasm.OpcodeI32Add,
+						wasm.OpcodeI32Load, 0x2, 0x0, // staticOffset=0
+						wasm.OpcodeDrop,
+
+						// This shouldn't be removed since [local0+0x10+4, local0+0x10+8) is not known to be in bounds.
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI32Const, 0x10,
+						wasm.OpcodeI32Add,
+						wasm.OpcodeI32Load, 0x2, 0x4, // staticOffset=4
+						wasm.OpcodeDrop,
+						wasm.OpcodeEnd,
+					},
+				}},
+				MemorySection: []wasm.Memory{{Min: 1}},
+			},
+			features: api.CoreFeaturesV2,
+			exp: `
+blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = Uload32 module_ctx, 0x10
+	v5:i64 = Iconst_64 0x14
+	v6:i64 = UExtend v2, 32->64
+	v7:i64 = Iadd v6, v5
+	v8:i32 = Icmp lt_u, v4, v7
+	ExitIfTrue v8, exec_ctx, memory_out_of_bounds
+	v9:i64 = Iadd v3, v6
+	v10:i32 = Load v9, 0x10
+	v11:i32 = Iconst_32 0x8
+	v12:i32 = Iadd v2, v11
+	v13:i64 = UExtend v12, 32->64
+	v14:i64 = Iadd v3, v13
+	v15:i32 = Load v14, 0x0
+	v16:i32 = Iconst_32 0x10
+	v17:i32 = Iadd v2, v16
+	v18:i64 = Iconst_64 0x8
+	v19:i64 = UExtend v17, 32->64
+	v20:i64 = Iadd v19, v18
+	v21:i32 = Icmp lt_u, v4, v20
+	ExitIfTrue v21, exec_ctx, memory_out_of_bounds
+	v22:i64 = Iadd v3, v19
+	v23:i32 = Load v22, 0x4
+	Jump blk_ret
+`,
+		},
+		{
+			name: "memory base and length in loop with call",
+			m: &wasm.Module{
+				TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{wasm.ValueTypeI32}}, {}},
+				FunctionSection: []wasm.Index{0, 1},
+				CodeSection: []wasm.Code{
+					{Body: []byte{
+						wasm.OpcodeLoop, 0x40, // blockSignature:vv.
+						/* */ wasm.OpcodeLocalGet, 0,
+						// The memory base and length are cached across the iterations.
+						/* */ wasm.OpcodeI32Load, 0x2, 0x0,
+						/* */ wasm.OpcodeBrIf, 0,
+						wasm.OpcodeEnd,
+
+						wasm.OpcodeLoop, 0x40, // blockSignature:vv.
+						// The loaded value is dropped, so the memory base reloaded after the call is unused.
+						/* */ wasm.OpcodeLocalGet, 0,
+						/* */ wasm.OpcodeI32Load, 0x2, 0x0,
+						/* */ wasm.OpcodeDrop,
+						/* */ wasm.OpcodeCall, 1,
+						/* */ wasm.OpcodeLocalGet, 0,
+						/* */ wasm.OpcodeBrIf, 0,
+						wasm.OpcodeEnd,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{wasm.OpcodeEnd}},
+				},
+				MemorySection: []wasm.Memory{{Min: 1}},
+			},
+			features: api.CoreFeaturesV2,
+			exp: `
+signatures:
+	sig1: i64i64_v
+
+blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = Uload32 module_ctx, 0x10
+	Jump blk1, v2, v4, v3
+
+blk1: (v5:i32,v8:i64,v11:i64) <-- (blk0,blk1)
+	v6:i64 = Iconst_64 0x4
+	v7:i64 = UExtend v5, 32->64
+	v9:i64 = Iadd v7, v6
+	v10:i32 = Icmp lt_u, v8, v9
+	ExitIfTrue v10, exec_ctx, memory_out_of_bounds
+	v12:i64 = Iadd v11, v7
+	v13:i32 = Load v12, 0x0
+	Brnz v13, blk1, v5, v8, v11
+	Jump blk3
+
+blk2: () <-- (blk3)
+	Jump blk4, v5, v8, v11
+
+blk3: () <-- (blk1)
+	Jump blk2
+
+blk4: (v14:i32,v17:i64,v20:i64) <-- (blk2,blk4)
+	v15:i64 = Iconst_64 0x4
+	v16:i64 = UExtend v14, 32->64
+	v18:i64 = Iadd v16, v15
+	v19:i32 = Icmp lt_u, v17, v18
+	ExitIfTrue v19, exec_ctx, memory_out_of_bounds
+	v21:i64 = Iadd v20, v16
+	v22:i32 = Load v21, 0x0
+	Call f1:sig1, exec_ctx, module_ctx
+	v23:i64 = Load module_ctx, 0x8
+	v24:i64 = Uload32 module_ctx, 0x10
+	Brnz v14, blk4, v14, v24, v23
+	Jump blk6
+
+blk5: () <-- (blk6)
+	Jump blk_ret
+
+blk6: () <-- (blk4)
+	Jump blk5
+`,
+			expAfterPasses: `
+signatures:
+	sig1: i64i64_v
+
+blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = Uload32 module_ctx, 0x10
+	v7:i64 = UExtend v2, 32->64
+	v26:i64 = Iconst_64 0x4
+	v9:i64 = Iadd v7, v26
+	v12:i64 = Iadd v3, v7
+	Jump fallthrough
+
+blk1: () <-- (blk0,blk7)
+	v10:i32 = Icmp lt_u, v4, v9
+	ExitIfTrue v10, exec_ctx, memory_out_of_bounds
+	v13:i32 = Load v12, 0x0
+	Brz v13, blk3
+	Jump fallthrough
+
+blk7: () <-- (blk1)
+	Jump blk1
+
+blk3: () <-- (blk1)
+	v25:i64 = Iconst_64 0x4
+	v18:i64 = Iadd v7, v25
+	Jump fallthrough, v4
+
+blk4: (v17:i64) <-- (blk3,blk8)
+	v19:i32 = Icmp lt_u, v17, v18
+	ExitIfTrue v19, exec_ctx, memory_out_of_bounds
+	Call f1:sig1, exec_ctx, module_ctx
+	v24:i64 = Uload32 module_ctx, 0x10
+	Brnz v2, blk8
+	Jump blk6
+
+blk8: () <-- (blk4)
+	Jump blk4, v24
+
+blk6: () <-- (blk4)
+	Jump blk_ret
 `,
 		},
 	} {
@@ -3003,8 +3258,18 @@ blk9: () <-- (blk4)
 
 			b := ssa.NewBuilder()
 
-			offset := wazevoapi.NewModuleContextOffsetData(tc.m, tc.needListener)
-			fc := NewFrontendCompiler(tc.m, b, &offset, tc.ensureTermination, tc.needListener, false)
+			offset := wazevoapi.NewModuleContextOffsetData(tc.m, tc.needListener, false)
+			fc := NewFrontendCompiler(tc.m, b, &offset, tc.ensureTermination, tc.needListener, false, false)
+			fc.profiling = tc.profiling
+			if tc.inlining {
+				var listeners []experimental.FunctionListener
+				if tc.needListener {
+					// Only the callee returning two values has the listener.
+					listeners = make([]experimental.FunctionListener, len(tc.m.CodeSection))
+					listeners[3] = experimental.FunctionListenerFunc(func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {})
+				}
+				fc.EnableInlining(listeners)
+			}
 			typeIndex := tc.m.FunctionSection[tc.targetIndex]
 			code := &tc.m.CodeSection[tc.targetIndex]
 			fc.Init(tc.targetIndex, typeIndex, &tc.m.TypeSection[typeIndex], code.LocalTypes, code.Body, tc.needListener, 0)
@@ -3087,14 +3352,15 @@ func TestCompiler_declareSignatures(t *testing.T) {
 			{ID: 1, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32}},
 			{ID: 2, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeF64, ssa.TypeI32}},
 			{ID: 3, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI64, ssa.TypeI32}},
-			{ID: 4, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 4, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
 			{ID: 5, Params: []ssa.Type{ssa.TypeI64}},
 			{ID: 6, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
 			{ID: 7, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI64}},
 			{ID: 8, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64}},
-			{ID: 9, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
-			{ID: 10, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
-			{ID: 11, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 9, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 10, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 11, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 12, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI64}},
 		}
 
 		require.Equal(t, len(expected), len(declaredSigs))
@@ -3126,14 +3392,15 @@ func TestCompiler_declareSignatures(t *testing.T) {
 			{ID: 10, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}},
 			{ID: 11, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}},
 			// Misc.
-			{ID: 12, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 12, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
 			{ID: 13, Params: []ssa.Type{ssa.TypeI64}},
 			{ID: 14, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
 			{ID: 15, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI64}},
 			{ID: 16, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64}},
-			{ID: 17, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
-			{ID: 18, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
-			{ID: 19, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 17, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 18, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 19, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 20, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI64}},
 		}
 		require.Equal(t, len(expected), len(declaredSigs))
 		for i := 0; i < len(declaredSigs); i++ {
@@ -3212,7 +3479,7 @@ func TestKnownSafeBound_valid(t *testing.T) {
 }
 
 func TestCompiler_finalizeKnownSafeBoundsAtTheEndOoBlock(t *testing.T) {
-	c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false)
+	c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false, false)
 	blk := c.ssaBuilder.AllocateBasicBlock()
 	require.True(t, len(c.getKnownSafeBoundsAtTheEndOfBlocks(blk.ID()).View()) == 0)
 	c.ssaBuilder.SetCurrentBlock(blk)
@@ -3233,7 +3500,7 @@ func TestCompiler_finalizeKnownSafeBoundsAtTheEndOoBlock(t *testing.T) {
 
 func TestCompiler_initializeCurrentBlockKnownBounds(t *testing.T) {
 	t.Run("single (sealed)", func(t *testing.T) {
-		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false)
+		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false, false)
 		builder := c.ssaBuilder
 		child := builder.AllocateBasicBlock()
 		{
@@ -3264,7 +3531,7 @@ func TestCompiler_initializeCurrentBlockKnownBounds(t *testing.T) {
 		require.Equal(t, ssa.Value(54321), kb.absoluteAddr)
 	})
 	t.Run("single (unsealed)", func(t *testing.T) {
-		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false)
+		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false, false)
 		builder := c.ssaBuilder
 		child := builder.AllocateBasicBlock()
 		{
@@ -3294,7 +3561,7 @@ func TestCompiler_initializeCurrentBlockKnownBounds(t *testing.T) {
 		require.NotEqual(t, ssa.Value(54321), kb.absoluteAddr)
 	})
 	t.Run("multiple predecessors", func(t *testing.T) {
-		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false)
+		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false, false)
 		builder := c.ssaBuilder
 		child := builder.AllocateBasicBlock()
 		{
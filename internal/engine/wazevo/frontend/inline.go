@@ -0,0 +1,328 @@
+package frontend
+
+import (
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+const (
+	// maxInlinedBodySize is the maximum size of the body of a function to be inlined into its callers. This must not
+	// exceed 1<<inlinedCalleeOffsetBits.
+	maxInlinedBodySize = 64
+	// maxInlinedBytesPerFunction is the maximum total size of the bodies inlined into a single function, so that the
+	// functions calling small functions many times don't blow up.
+	maxInlinedBytesPerFunction = 2048
+
+	// inlinedSourceOffsetFlag is set in the ssa.SourceOffset of the instructions lowered from the inlined bodies.
+	// Such offsets consist of the flag, the local index of the callee (27 bits, see wasm.MaximumFunctionIndex), the
+	// offset of the call in the body of the caller (inlinedCallSiteBits), and the offset of the instruction in the
+	// body of the callee (inlinedCalleeOffsetBits), so that the inlined frames can be restored from them.
+	inlinedSourceOffsetFlag = 1 << 62
+	inlinedCallSiteBits     = 24
+	inlinedCalleeOffsetBits = 8
+)
+
+type (
+	// inlinedCall holds the state of the caller while the body of the callee is lowered in place of the call.
+	inlinedCall struct {
+		// callSite is the offset of the call instruction in the body of the caller.
+		callSite int
+		// args are the arguments of the call.
+		args []ssa.Value
+		// continuation is the block following the call, whose parameters are the results of the callee.
+		continuation ssa.BasicBlock
+
+		// The followings are the ones of the caller restored once the body of the callee is lowered.
+
+		localToVariable                 []ssa.Variable
+		localFunctionIndex              wasm.Index
+		functionTypeIndex               wasm.Index
+		functionTyp                     *wasm.FunctionType
+		functionLocalTypes              []wasm.ValueType
+		functionBody                    []byte
+		functionBodyOffsetInCodeSection uint64
+		needListener                    bool
+		loweringState                   loweringState
+	}
+
+	// inlinability is the cached result of Compiler.isInlinable.
+	inlinability byte
+
+	// InlinedCall is the call to a local function inlined into the caller, restored from the ssa.SourceOffset of an
+	// instruction lowered from the body of the callee by InlinedCallOf.
+	InlinedCall struct {
+		// Callee is the local index of the inlined function.
+		Callee wasm.Index
+		// CallSite is the offset of the call instruction in the body of the caller.
+		CallSite uint64
+		// CalleeOffset is the offset of the instruction in the body of the callee.
+		CalleeOffset uint64
+	}
+)
+
+const (
+	inlinabilityUnknown inlinability = iota
+	inlinabilityYes
+	inlinabilityNo
+)
+
+// EnableInlining makes the Compiler inline the calls to the small leaf functions of the module into the callers.
+// listeners are the ones of the local functions if any, which are called around the inlined bodies as well.
+//
+// The instructions of the inlined bodies are annotated with the ssa.SourceOffset from which InlinedCallOf restores
+// the inlined frames regardless of whether the source offsets are needed otherwise.
+func (c *Compiler) EnableInlining(listeners []experimental.FunctionListener) {
+	c.inlining = true
+	c.listeners = listeners
+}
+
+// InlinedCalls returns the number of the calls inlined into the current function.
+func (c *Compiler) InlinedCalls() int {
+	return c.inlinedCalls
+}
+
+// InlinedCallOf returns the InlinedCall from the source offset of the instruction if it is lowered from the body of
+// an inlined function.
+func InlinedCallOf(offset ssa.SourceOffset) (call InlinedCall, ok bool) {
+	if offset < 0 || offset&inlinedSourceOffsetFlag == 0 {
+		return
+	}
+	v := uint64(offset) &^ inlinedSourceOffsetFlag
+	call.CalleeOffset = v & (1<<inlinedCalleeOffsetBits - 1)
+	v >>= inlinedCalleeOffsetBits
+	call.CallSite = v & (1<<inlinedCallSiteBits - 1)
+	call.Callee = wasm.Index(v >> inlinedCallSiteBits)
+	return call, true
+}
+
+// inlinedSourceOffset returns the source offset of the instruction at the offset in the body of the inlined callee.
+func inlinedSourceOffset(callee wasm.Index, callSite, calleeOffset int) ssa.SourceOffset {
+	v := uint64(callee)<<inlinedCallSiteBits | uint64(callSite)
+	v = v<<inlinedCalleeOffsetBits | uint64(calleeOffset)
+	return ssa.SourceOffset(v | inlinedSourceOffsetFlag)
+}
+
+// tryInlineCall starts lowering the body of the local function of the given index in place of the call at callSite
+// if it is inlinable, and returns true in that case. The lowering continues with the instructions of the callee, and
+// then the caller is restored by finishInlinedCall at the end of the callee.
+func (c *Compiler) tryInlineCall(fnIndex wasm.Index, callSite int) bool {
+	if !c.inlining || c.inlined != nil || fnIndex < c.m.ImportFunctionCount || callSite >= 1<<inlinedCallSiteBits {
+		return false
+	}
+	localIndex := fnIndex - c.m.ImportFunctionCount
+	code := &c.m.CodeSection[localIndex]
+	if c.inlinedBytes+len(code.Body) > maxInlinedBytesPerFunction || !c.isInlinable(localIndex) {
+		return false
+	}
+	c.inlinedBytes += len(code.Body)
+	c.inlinedCalls++
+
+	builder := c.ssaBuilder
+	typIndex := c.m.FunctionSection[localIndex]
+	typ := &c.m.TypeSection[typIndex]
+
+	// Save the state of the caller.
+	inlined := &c.inlinedCallStorage
+	inlined.callSite = callSite
+	inlined.localToVariable = c.wasmLocalToVariable
+	inlined.localFunctionIndex = c.wasmLocalFunctionIndex
+	inlined.functionTypeIndex = c.wasmFunctionTypeIndex
+	inlined.functionTyp = c.wasmFunctionTyp
+	inlined.functionLocalTypes = c.wasmFunctionLocalTypes
+	inlined.functionBody = c.wasmFunctionBody
+	inlined.functionBodyOffsetInCodeSection = c.wasmFunctionBodyOffsetInCodeSection
+	inlined.needListener = c.needListener
+
+	state := c.state()
+	tail := len(state.values) - len(typ.Params)
+	inlined.args = append(inlined.args[:0], state.values[tail:]...)
+	state.values = state.values[:tail]
+	inlined.loweringState = *state
+
+	inlined.continuation = builder.AllocateBasicBlock()
+	c.addBlockParamsFromWasmTypes(typ.Results, inlined.continuation)
+	c.inlined = inlined
+
+	// Then switch to the callee, whose parameters and locals are the new variables.
+	c.wasmLocalToVariable = c.calleeLocalToVariable[:0]
+	c.wasmLocalFunctionIndex = localIndex
+	c.wasmFunctionTypeIndex = typIndex
+	c.wasmFunctionTyp = typ
+	c.wasmFunctionLocalTypes = code.LocalTypes
+	c.wasmFunctionBody = code.Body
+	c.wasmFunctionBodyOffsetInCodeSection = code.BodyOffsetInCodeSection
+	c.needListener = len(c.listeners) > 0 && c.listeners[localIndex] != nil
+	c.loweringState = c.calleeLoweringState
+	c.loweringState.reset()
+
+	for i, arg := range inlined.args {
+		variable := builder.DeclareVariable(WasmTypeToSSAType(typ.Params[i]))
+		builder.DefineVariableInCurrentBB(variable, arg)
+		c.setWasmLocalVariable(wasm.Index(i), variable)
+	}
+	// The locals must be zero at every call, e.g. when the call is in a loop, so they are defined explicitly.
+	for i, lt := range code.LocalTypes {
+		st := WasmTypeToSSAType(lt)
+		variable := builder.DeclareVariable(st)
+		builder.DefineVariableInCurrentBB(variable, c.insertZeroValue(st))
+		c.setWasmLocalVariable(wasm.Index(len(typ.Params)+i), variable)
+	}
+
+	if c.needListener {
+		// The listener is called at the beginning of the callee.
+		builder.SetCurrentSourceOffset(inlinedSourceOffset(localIndex, callSite, 0))
+		c.callListenerBefore()
+		// The listener may grow the memory or set the globals via api.Module.
+		c.reloadAfterCall()
+	}
+
+	c.loweringState.ctrlPush(controlFrame{
+		kind:           controlFrameKindFunction,
+		blockType:      typ,
+		followingBlock: inlined.continuation,
+	})
+	return true
+}
+
+// finishInlinedCall restores the state of the caller after lowering the end of the inlined callee, and continues
+// lowering the caller in the continuation block with the results of the callee.
+func (c *Compiler) finishInlinedCall() {
+	inlined := c.inlined
+	c.inlined = nil
+
+	c.calleeLocalToVariable = c.wasmLocalToVariable
+	c.calleeLoweringState = c.loweringState
+
+	c.wasmLocalToVariable = inlined.localToVariable
+	c.wasmLocalFunctionIndex = inlined.localFunctionIndex
+	c.wasmFunctionTypeIndex = inlined.functionTypeIndex
+	c.wasmFunctionTyp = inlined.functionTyp
+	c.wasmFunctionLocalTypes = inlined.functionLocalTypes
+	c.wasmFunctionBody = inlined.functionBody
+	c.wasmFunctionBodyOffsetInCodeSection = inlined.functionBodyOffsetInCodeSection
+	c.needListener = inlined.needListener
+	c.loweringState = inlined.loweringState
+
+	state := c.state()
+	cont := inlined.continuation
+	for i := 0; i < cont.Params(); i++ {
+		state.push(cont.Param(i))
+	}
+	if cont.Preds() == 0 {
+		// The callee never returns, e.g. it always traps.
+		state.unreachable = true
+	}
+}
+
+// isFunctionReturn returns true if jumping to the block returns from the function being lowered, which is the
+// continuation of the call if the function is inlined.
+func (c *Compiler) isFunctionReturn(blk ssa.BasicBlock) bool {
+	if inlined := c.inlined; inlined != nil {
+		return blk == inlined.continuation
+	}
+	return blk.ReturnBlock()
+}
+
+// insertReturn inserts the return from the function being lowered with the given results, which is the jump to the
+// continuation of the call if the function is inlined.
+func (c *Compiler) insertReturn(results ssa.Values) {
+	builder := c.ssaBuilder
+	instr := builder.AllocateInstruction()
+	if inlined := c.inlined; inlined != nil {
+		instr.AsJump(results, inlined.continuation)
+	} else {
+		instr.AsReturn(results)
+	}
+	builder.InsertInstruction(instr)
+}
+
+// isInlinable returns true if the local function of the given index can be inlined into its callers.
+func (c *Compiler) isInlinable(localIndex wasm.Index) bool {
+	if n := int(localIndex) + 1; n > len(c.inlinable) {
+		c.inlinable = append(c.inlinable, make([]inlinability, n-len(c.inlinable))...)
+	}
+	if c.inlinable[localIndex] == inlinabilityUnknown {
+		c.inlinable[localIndex] = inlinabilityNo
+		if inlinableBody(c.m.CodeSection[localIndex].Body) {
+			c.inlinable[localIndex] = inlinabilityYes
+		}
+	}
+	return c.inlinable[localIndex] == inlinabilityYes
+}
+
+// inlinableBody returns true if the function body is small enough and only consists of the instructions which never
+// call other functions, nor throw exceptions, hence the inlined callee is always the innermost frame of the stack.
+func inlinableBody(body []byte) bool {
+	if len(body) > maxInlinedBodySize {
+		return false
+	}
+	for pc := 0; pc < len(body); {
+		op := body[pc]
+		pc++ // Now pc points to the immediates if any.
+		switch {
+		case op == wasm.OpcodeUnreachable, op == wasm.OpcodeNop, op == wasm.OpcodeElse, op == wasm.OpcodeEnd,
+			op == wasm.OpcodeReturn, op == wasm.OpcodeDrop, op == wasm.OpcodeSelect,
+			op >= wasm.OpcodeI32Eqz && op <= wasm.OpcodeI64Extend32S:
+		case op == wasm.OpcodeBlock, op == wasm.OpcodeLoop, op == wasm.OpcodeIf,
+			op == wasm.OpcodeBr, op == wasm.OpcodeBrIf,
+			op >= wasm.OpcodeLocalGet && op <= wasm.OpcodeGlobalSet,
+			op == wasm.OpcodeMemorySize, op == wasm.OpcodeMemoryGrow,
+			op == wasm.OpcodeI32Const, op == wasm.OpcodeI64Const:
+			pc += leb128Size(body[pc:])
+		case op == wasm.OpcodeF32Const:
+			pc += 4
+		case op == wasm.OpcodeF64Const:
+			pc += 8
+		case op == wasm.OpcodeBrTable:
+			count, n, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return false
+			}
+			pc += int(n)
+			for i := uint32(0); i <= count; i++ { // +1 for the default label.
+				pc += leb128Size(body[pc:])
+			}
+		case op >= wasm.OpcodeI32Load && op <= wasm.OpcodeI64Store32:
+			align, n, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return false
+			}
+			pc += int(n)
+			if align&wasm.MemArgMemoryIndexFlag != 0 {
+				pc += leb128Size(body[pc:])
+			}
+			pc += leb128Size(body[pc:]) // offset.
+		case op == wasm.OpcodeMiscPrefix:
+			miscOp, n, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return false
+			}
+			pc += int(n)
+			switch op := wasm.OpcodeMisc(miscOp); {
+			case op <= wasm.OpcodeMiscI64TruncSatF64U:
+			case op == wasm.OpcodeMiscMemoryCopy: // Destination and source memory indexes.
+				pc += leb128Size(body[pc:])
+				pc += leb128Size(body[pc:])
+			case op == wasm.OpcodeMiscMemoryFill:
+				pc += leb128Size(body[pc:])
+			default:
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// leb128Size returns the size of the LEB128 encoded value at the beginning of the bytes.
+func leb128Size(b []byte) int {
+	for i, v := range b {
+		if v&0x80 == 0 {
+			return i + 1
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,75 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestInlinedCallOf(t *testing.T) {
+	for _, exp := range []InlinedCall{
+		{Callee: 0, CallSite: 0, CalleeOffset: 0},
+		{Callee: 1, CallSite: 2, CalleeOffset: 3},
+		{Callee: wasm.MaximumFunctionIndex, CallSite: 1<<inlinedCallSiteBits - 1, CalleeOffset: maxInlinedBodySize - 1},
+	} {
+		offset := inlinedSourceOffset(exp.Callee, int(exp.CallSite), int(exp.CalleeOffset))
+		actual, ok := InlinedCallOf(offset)
+		require.True(t, ok)
+		require.Equal(t, exp, actual)
+	}
+
+	for _, offset := range []ssa.SourceOffset{-1, 0, 12345} {
+		_, ok := InlinedCallOf(offset)
+		require.False(t, ok)
+	}
+}
+
+func Test_inlinableBody(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body []byte
+		exp  bool
+	}{
+		{name: "empty", body: []byte{wasm.OpcodeEnd}, exp: true},
+		{
+			name: "add",
+			body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd},
+			exp:  true,
+		},
+		{
+			name: "load",
+			body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load, 0x2, 0x80, 0x01, wasm.OpcodeEnd},
+			exp:  true,
+		},
+		{
+			name: "br_table",
+			body: []byte{
+				wasm.OpcodeBlock, 0x40,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeBrTable, 2, 0, 0, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeF64Const, 0, 0, 0, 0, 0, 0, 0, 0, wasm.OpcodeDrop,
+				wasm.OpcodeEnd,
+			},
+			exp: true,
+		},
+		{
+			name: "memory.fill",
+			body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 0,
+				wasm.OpcodeEnd,
+			},
+			exp: true,
+		},
+		{name: "call", body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}, exp: false},
+		{name: "call_indirect", body: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeCallIndirect, 0, 0, wasm.OpcodeEnd}, exp: false},
+		{name: "table.size", body: []byte{wasm.OpcodeMiscPrefix, wasm.OpcodeMiscTableSize, 0, wasm.OpcodeDrop, wasm.OpcodeEnd}, exp: false},
+		{name: "too large", body: append(make([]byte, maxInlinedBodySize), wasm.OpcodeEnd), exp: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.exp, inlinableBody(tc.body))
+		})
+	}
+}
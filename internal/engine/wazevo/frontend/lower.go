@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/leb128"
@@ -37,6 +38,10 @@ type (
 		blockType *wasm.FunctionType
 		// clonedArgs hold the arguments to Else block.
 		clonedArgs ssa.Values
+		// catches are the catch clauses of try_table.
+		catches []wasm.CatchClause
+		// handler is the block to jump to when an exception is thrown in try_table. This is allocated lazily.
+		handler ssa.BasicBlock
 	}
 
 	controlFrameKind byte
@@ -65,6 +70,7 @@ const (
 	controlFrameKindIfWithElse
 	controlFrameKindIfWithoutElse
 	controlFrameKindBlock
+	controlFrameKindTryTable
 )
 
 // String implements fmt.Stringer for debugging.
@@ -80,6 +86,8 @@ func (k controlFrameKind) String() string {
 		return "if_without_else"
 	case controlFrameKindBlock:
 		return "block"
+	case controlFrameKindTryTable:
+		return "try_table"
 	default:
 		panic(k)
 	}
@@ -150,6 +158,11 @@ func (c *Compiler) lowerBody(entryBlk ssa.BasicBlock) {
 
 	if c.needListener {
 		c.callListenerBefore()
+		// The listener may grow the memory or set the globals via api.Module.
+		c.reloadAfterCall()
+	}
+	if c.profiling {
+		c.insertSafepoint()
 	}
 
 	// Pushes the empty control frame which corresponds to the function return.
@@ -171,6 +184,10 @@ func (c *Compiler) lowerBody(entryBlk ssa.BasicBlock) {
 			c.initializeCurrentBlockKnownBounds()
 		}
 	}
+
+	if c.exceptionPropagateBlock != nil {
+		c.lowerExceptionPropagation()
+	}
 }
 
 func (c *Compiler) state() *loweringState {
@@ -180,7 +197,9 @@ func (c *Compiler) state() *loweringState {
 func (c *Compiler) lowerCurrentOpcode() {
 	op := c.wasmFunctionBody[c.loweringState.pc]
 
-	if c.needSourceOffsetInfo {
+	if inlined := c.inlined; inlined != nil {
+		c.ssaBuilder.SetCurrentSourceOffset(inlinedSourceOffset(c.wasmLocalFunctionIndex, inlined.callSite, c.loweringState.pc))
+	} else if c.needSourceOffsetInfo || c.inlining {
 		c.ssaBuilder.SetCurrentSourceOffset(
 			ssa.SourceOffset(c.loweringState.pc) + ssa.SourceOffset(c.wasmFunctionBodyOffsetInCodeSection),
 		)
@@ -188,6 +207,17 @@ func (c *Compiler) lowerCurrentOpcode() {
 
 	builder := c.ssaBuilder
 	state := c.state()
+
+	// Charge the fuel consumed so far before the instruction if it is a boundary, including its own cost.
+	if c.fuelCosts != nil && !state.unreachable {
+		cost, charge := wasm.FuelCost(c.fuelCosts, c.wasmFunctionBody[c.loweringState.pc:])
+		c.fuel += uint64(cost)
+		if charge && c.fuel > 0 {
+			c.consumeFuel(c.fuel)
+			c.fuel = 0
+		}
+	}
+
 	switch op {
 	case wasm.OpcodeI32Const:
 		c := c.readI32s()
@@ -619,26 +649,23 @@ func (c *Compiler) lowerCurrentOpcode() {
 			c.callMemmove(dstAddr, srcAddr, copySizeInBytes)
 
 		case wasm.OpcodeMiscMemoryCopy:
-			state.pc += 2 // +2 to skip two memory indexes which are fixed to zero.
+			dstMemoryIndex := c.readI32u()
+			srcMemoryIndex := c.readI32u()
 			if state.unreachable {
 				break
 			}
 
-			copySize := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
-			srcOffset := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
-			dstOffset := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
+			// The size is i64 only when both memories are 64-bit.
+			copySize := c.popMemoryOperand(c.memory64[dstMemoryIndex] && c.memory64[srcMemoryIndex])
+			srcOffset := c.popMemoryOperand(c.memory64[srcMemoryIndex])
+			dstOffset := c.popMemoryOperand(c.memory64[dstMemoryIndex])
 
 			// Out of bounds check.
-			memLen := c.getMemoryLenValue(false)
-			c.boundsCheckInMemory(memLen, dstOffset, copySize)
-			c.boundsCheckInMemory(memLen, srcOffset, copySize)
+			c.boundsCheckInMemory(dstMemoryIndex, dstOffset, copySize)
+			c.boundsCheckInMemory(srcMemoryIndex, srcOffset, copySize)
 
-			memBase := c.getMemoryBaseValue(false)
-			dstAddr := builder.AllocateInstruction().AsIadd(memBase, dstOffset).Insert(builder).Return()
-			srcAddr := builder.AllocateInstruction().AsIadd(memBase, srcOffset).Insert(builder).Return()
+			dstAddr := builder.AllocateInstruction().AsIadd(c.getMemoryBaseValue(dstMemoryIndex, false), dstOffset).Insert(builder).Return()
+			srcAddr := builder.AllocateInstruction().AsIadd(c.getMemoryBaseValue(srcMemoryIndex, false), srcOffset).Insert(builder).Return()
 
 			c.callMemmove(dstAddr, srcAddr, copySize)
 
@@ -724,22 +751,20 @@ func (c *Compiler) lowerCurrentOpcode() {
 			builder.Seal(followingBlk)
 
 		case wasm.OpcodeMiscMemoryFill:
-			state.pc++ // Skip the memory index which is fixed to zero.
+			memoryIndex := c.readI32u()
 			if state.unreachable {
 				break
 			}
 
-			fillSize := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
+			fillSize := c.popMemoryOperand(c.memory64[memoryIndex])
 			value := state.pop()
-			offset := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
+			offset := c.popMemoryOperand(c.memory64[memoryIndex])
 
 			// Out of bounds check.
-			c.boundsCheckInMemory(c.getMemoryLenValue(false), offset, fillSize)
+			c.boundsCheckInMemory(memoryIndex, offset, fillSize)
 
 			// Calculate the base address:
-			addr := builder.AllocateInstruction().AsIadd(c.getMemoryBaseValue(false), offset).Insert(builder).Return()
+			addr := builder.AllocateInstruction().AsIadd(c.getMemoryBaseValue(memoryIndex, false), offset).Insert(builder).Return()
 
 			// Uses the copy trick for faster filling buffer: https://gist.github.com/taylorza/df2f89d5f9ab3ffd06865062a4cf015d
 			// 	buf := memoryInst.Buffer[offset : offset+fillSize]
@@ -801,7 +826,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 
 		case wasm.OpcodeMiscMemoryInit:
 			index := c.readI32u()
-			state.pc++ // Skip the memory index which is fixed to zero.
+			memoryIndex := c.readI32u()
 			if state.unreachable {
 				break
 			}
@@ -810,19 +835,18 @@ func (c *Compiler) lowerCurrentOpcode() {
 				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
 			offsetInDataInstance := builder.
 				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
-			offsetInMemory := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
+			offsetInMemory := c.popMemoryOperand(c.memory64[memoryIndex])
 
 			dataInstPtr := c.dataOrElementInstanceAddr(index, c.offset.DataInstances1stElement)
 
 			// Bounds check.
-			c.boundsCheckInMemory(c.getMemoryLenValue(false), offsetInMemory, copySize)
+			c.boundsCheckInMemory(memoryIndex, offsetInMemory, copySize)
 			c.boundsCheckInDataOrElementInstance(dataInstPtr, offsetInDataInstance, copySize, wazevoapi.ExitCodeMemoryOutOfBounds)
 
 			dataInstBaseAddr := builder.AllocateInstruction().AsLoad(dataInstPtr, 0, ssa.TypeI64).Insert(builder).Return()
 			srcAddr := builder.AllocateInstruction().AsIadd(dataInstBaseAddr, offsetInDataInstance).Insert(builder).Return()
 
-			memBase := c.getMemoryBaseValue(false)
+			memBase := c.getMemoryBaseValue(memoryIndex, false)
 			dstAddr := builder.AllocateInstruction().AsIadd(memBase, offsetInMemory).Insert(builder).Return()
 
 			c.callMemmove(dstAddr, srcAddr, copySize)
@@ -1108,7 +1132,11 @@ func (c *Compiler) lowerCurrentOpcode() {
 
 	case wasm.OpcodeSelect, wasm.OpcodeTypedSelect:
 		if op == wasm.OpcodeTypedSelect {
-			state.pc += 2 // ignores the type which is only needed during validation.
+			// Ignores the type which is only needed during validation.
+			state.pc++ // the number of types which is always one.
+			if t := c.readByte(); t == wasm.ValueTypePrefixRefNull || t == wasm.ValueTypePrefixRef {
+				c.readI64s() // the heap type of the reference type.
+			}
 		}
 
 		if state.unreachable {
@@ -1126,31 +1154,41 @@ func (c *Compiler) lowerCurrentOpcode() {
 		state.push(sl)
 
 	case wasm.OpcodeMemorySize:
-		state.pc++ // skips the memory index.
+		memoryIndex := c.readI32u()
 		if state.unreachable {
 			break
 		}
 
+		// 64-bit memories return the size as i64.
+		sizeType := ssa.TypeI32
+		if c.memory64[memoryIndex] {
+			sizeType = ssa.TypeI64
+		}
+
 		var memSizeInBytes ssa.Value
-		if c.offset.LocalMemoryBegin < 0 {
+		if offset, imported := c.memoryOffsets(memoryIndex); imported {
 			memInstPtr := builder.AllocateInstruction().
-				AsLoad(c.moduleCtxPtrValue, c.offset.ImportedMemoryBegin.U32(), ssa.TypeI64).
+				AsLoad(c.moduleCtxPtrValue, offset.U32(), ssa.TypeI64).
 				Insert(builder).
 				Return()
 
 			memSizeInBytes = builder.AllocateInstruction().
-				AsLoad(memInstPtr, memoryInstanceBufSizeOffset, ssa.TypeI32).
+				AsLoad(memInstPtr, memoryInstanceBufSizeOffset, sizeType).
 				Insert(builder).
 				Return()
 		} else {
 			memSizeInBytes = builder.AllocateInstruction().
-				AsLoad(c.moduleCtxPtrValue, c.offset.LocalMemoryLen().U32(), ssa.TypeI32).
+				AsLoad(c.moduleCtxPtrValue, (offset + 8).U32(), sizeType).
 				Insert(builder).
 				Return()
 		}
 
 		amount := builder.AllocateInstruction()
-		amount.AsIconst32(uint32(wasm.MemoryPageSizeInBits))
+		if sizeType == ssa.TypeI64 {
+			amount.AsIconst64(uint64(wasm.MemoryPageSizeInBits))
+		} else {
+			amount.AsIconst32(uint32(wasm.MemoryPageSizeInBits))
+		}
 		builder.InsertInstruction(amount)
 		memSize := builder.AllocateInstruction().
 			AsUshr(memSizeInBytes, amount.Return()).
@@ -1159,7 +1197,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		state.push(memSize)
 
 	case wasm.OpcodeMemoryGrow:
-		state.pc++ // skips the memory index.
+		memoryIndex := c.readI32u()
 		if state.unreachable {
 			break
 		}
@@ -1167,21 +1205,44 @@ func (c *Compiler) lowerCurrentOpcode() {
 		c.storeCallerModuleContext()
 
 		pages := state.pop()
+		is64 := c.memory64[memoryIndex]
+		if is64 {
+			// The memory grow trampoline takes the i32 number of pages. A delta which doesn't fit in u32 can never
+			// succeed, so it is replaced with 0xffffffff, which the trampoline always rejects.
+			maxU32 := builder.AllocateInstruction().AsIconst64(math.MaxUint32).Insert(builder).Return()
+			tooLarge := builder.AllocateInstruction().
+				AsIcmp(pages, maxU32, ssa.IntegerCmpCondUnsignedGreaterThan).
+				Insert(builder).Return()
+			clamped := builder.AllocateInstruction().AsSelect(tooLarge, maxU32, pages).Insert(builder).Return()
+			pages = builder.AllocateInstruction().AsIreduce(clamped, ssa.TypeI32).Insert(builder).Return()
+		}
+
 		memoryGrowPtr := builder.AllocateInstruction().
 			AsLoad(c.execCtxPtrValue,
 				wazevoapi.ExecutionContextOffsetMemoryGrowTrampolineAddress.U32(),
 				ssa.TypeI64,
 			).Insert(builder).Return()
 
-		args := c.allocateVarLengthValues(1, c.execCtxPtrValue, pages)
+		memoryIndexValue := builder.AllocateInstruction().AsIconst32(memoryIndex).Insert(builder).Return()
+		args := c.allocateVarLengthValues(3, c.execCtxPtrValue, pages, memoryIndexValue)
 		callGrowRet := builder.
 			AllocateInstruction().
 			AsCallIndirect(memoryGrowPtr, &c.memoryGrowSig, args).
 			Insert(builder).Return()
+		if is64 {
+			// Zero-extend the previous size, except for the failure (-1) which must be sign-extended.
+			minusOne := builder.AllocateInstruction().AsIconst32(math.MaxUint32).Insert(builder).Return()
+			failed := builder.AllocateInstruction().
+				AsIcmp(callGrowRet, minusOne, ssa.IntegerCmpCondEqual).
+				Insert(builder).Return()
+			prev := builder.AllocateInstruction().AsUExtend(callGrowRet, 32, 64).Insert(builder).Return()
+			minusOne64 := builder.AllocateInstruction().AsIconst64(math.MaxUint64).Insert(builder).Return()
+			callGrowRet = builder.AllocateInstruction().AsSelect(failed, minusOne64, prev).Insert(builder).Return()
+		}
 		state.push(callGrowRet)
 
 		// After the memory grow, reload the cached memory base and len.
-		c.reloadMemoryBaseLen()
+		c.reloadMemoryBaseLen(false)
 
 	case wasm.OpcodeI32Store,
 		wasm.OpcodeI64Store,
@@ -1193,7 +1254,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		wasm.OpcodeI64Store16,
 		wasm.OpcodeI64Store32:
 
-		_, offset := c.readMemArg()
+		_, offset, memoryIndex := c.readMemArg()
 		if state.unreachable {
 			break
 		}
@@ -1221,9 +1282,9 @@ func (c *Compiler) lowerCurrentOpcode() {
 
 		value := state.pop()
 		baseAddr := state.pop()
-		addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
+		addr := c.memOpSetup(memoryIndex, baseAddr, offset, opSize)
 		builder.AllocateInstruction().
-			AsStore(opcode, value, addr, offset).
+			AsStore(opcode, value, addr, uint32(offset)).
 			Insert(builder)
 
 	case wasm.OpcodeI32Load,
@@ -1240,7 +1301,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		wasm.OpcodeI64Load16U,
 		wasm.OpcodeI64Load32S,
 		wasm.OpcodeI64Load32U:
-		_, offset := c.readMemArg()
+		_, offset, memoryIndex := c.readMemArg()
 		if state.unreachable {
 			break
 		}
@@ -1266,37 +1327,37 @@ func (c *Compiler) lowerCurrentOpcode() {
 		}
 
 		baseAddr := state.pop()
-		addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
+		addr := c.memOpSetup(memoryIndex, baseAddr, offset, opSize)
 		load := builder.AllocateInstruction()
 		switch op {
 		case wasm.OpcodeI32Load:
-			load.AsLoad(addr, offset, ssa.TypeI32)
+			load.AsLoad(addr, uint32(offset), ssa.TypeI32)
 		case wasm.OpcodeI64Load:
-			load.AsLoad(addr, offset, ssa.TypeI64)
+			load.AsLoad(addr, uint32(offset), ssa.TypeI64)
 		case wasm.OpcodeF32Load:
-			load.AsLoad(addr, offset, ssa.TypeF32)
+			load.AsLoad(addr, uint32(offset), ssa.TypeF32)
 		case wasm.OpcodeF64Load:
-			load.AsLoad(addr, offset, ssa.TypeF64)
+			load.AsLoad(addr, uint32(offset), ssa.TypeF64)
 		case wasm.OpcodeI32Load8S:
-			load.AsExtLoad(ssa.OpcodeSload8, addr, offset, false)
+			load.AsExtLoad(ssa.OpcodeSload8, addr, uint32(offset), false)
 		case wasm.OpcodeI32Load8U:
-			load.AsExtLoad(ssa.OpcodeUload8, addr, offset, false)
+			load.AsExtLoad(ssa.OpcodeUload8, addr, uint32(offset), false)
 		case wasm.OpcodeI32Load16S:
-			load.AsExtLoad(ssa.OpcodeSload16, addr, offset, false)
+			load.AsExtLoad(ssa.OpcodeSload16, addr, uint32(offset), false)
 		case wasm.OpcodeI32Load16U:
-			load.AsExtLoad(ssa.OpcodeUload16, addr, offset, false)
+			load.AsExtLoad(ssa.OpcodeUload16, addr, uint32(offset), false)
 		case wasm.OpcodeI64Load8S:
-			load.AsExtLoad(ssa.OpcodeSload8, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeSload8, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load8U:
-			load.AsExtLoad(ssa.OpcodeUload8, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeUload8, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load16S:
-			load.AsExtLoad(ssa.OpcodeSload16, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeSload16, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load16U:
-			load.AsExtLoad(ssa.OpcodeUload16, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeUload16, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load32S:
-			load.AsExtLoad(ssa.OpcodeSload32, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeSload32, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load32U:
-			load.AsExtLoad(ssa.OpcodeUload32, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeUload32, addr, uint32(offset), true)
 		default:
 			panic("BUG")
 		}
@@ -1322,6 +1383,56 @@ func (c *Compiler) lowerCurrentOpcode() {
 			followingBlock:               followingBlk,
 			blockType:                    bt,
 		})
+	case wasm.OpcodeTryTable:
+		bt := c.readBlockType()
+		catches := c.readCatchClauses()
+
+		if state.unreachable {
+			state.unreachableDepth++
+			break
+		}
+
+		followingBlk := builder.AllocateBasicBlock()
+		c.addBlockParamsFromWasmTypes(bt.Results, followingBlk)
+
+		state.ctrlPush(controlFrame{
+			kind:                         controlFrameKindTryTable,
+			originalStackLenWithoutParam: len(state.values) - len(bt.Params),
+			followingBlock:               followingBlk,
+			blockType:                    bt,
+			catches:                      catches,
+		})
+	case wasm.OpcodeThrow:
+		tagIndex := c.readI32u()
+		if state.unreachable {
+			break
+		}
+
+		typIndex, _ := c.m.TagTypeIndex(tagIndex)
+		typ := &c.m.TypeSection[typIndex]
+		tag := builder.AllocateInstruction().AsIconst64(uint64(tagIndex)).Insert(builder).Return()
+		payload := c.callExceptionBuiltin(wazevoapi.ExceptionOpThrow, tag)
+
+		// Store the values into the exception created by the builtin.
+		tail := len(state.values) - len(typ.Params)
+		var offset uint32
+		for i, v := range state.values[tail:] {
+			builder.AllocateInstruction().AsStore(ssa.OpcodeStore, v, payload, offset).Insert(builder)
+			offset += exceptionValueSize(typ.Params[i])
+		}
+		state.values = state.values[:tail]
+
+		c.insertJumpToBlock(ssa.ValuesNil, c.exceptionHandlerBlock())
+		state.unreachable = true
+	case wasm.OpcodeThrowRef:
+		if state.unreachable {
+			break
+		}
+
+		ref := state.pop()
+		c.callExceptionBuiltin(wazevoapi.ExceptionOpThrowRef, ref)
+		c.insertJumpToBlock(ssa.ValuesNil, c.exceptionHandlerBlock())
+		state.unreachable = true
 	case wasm.OpcodeLoop:
 		bt := c.readBlockType()
 
@@ -1365,6 +1476,9 @@ func (c *Compiler) lowerCurrentOpcode() {
 				AsCallIndirect(checkModuleExitCodePtr, &c.checkModuleExitCodeSig, args).
 				Insert(builder)
 		}
+		if c.profiling {
+			c.insertSafepoint()
+		}
 	case wasm.OpcodeIf:
 		bt := c.readBlockType()
 
@@ -1469,12 +1583,18 @@ func (c *Compiler) lowerCurrentOpcode() {
 			elseBlk := ctrl.blk
 			builder.SetCurrentBlock(elseBlk)
 			c.insertJumpToBlock(ctrl.clonedArgs, followingBlk)
+		case controlFrameKindTryTable:
+			c.lowerCatchClauses(&ctrl)
 		}
 
 		builder.Seal(followingBlk)
 
 		// Ready to start translating the following block.
 		c.switchTo(ctrl.originalStackLenWithoutParam, followingBlk)
+		if ctrl.kind == controlFrameKindFunction && c.inlined != nil {
+			// This is the end of the inlined callee, so the lowering continues in the caller.
+			c.finishInlinedCall()
+		}
 
 	case wasm.OpcodeBr:
 		labelIndex := c.readI32u()
@@ -1495,47 +1615,38 @@ func (c *Compiler) lowerCurrentOpcode() {
 		}
 
 		v := state.pop()
+		c.lowerBrIf(v, labelIndex)
 
-		targetBlk, argNum := state.brTargetArgNumFor(labelIndex)
-		args := c.nPeekDup(argNum)
-		var sealTargetBlk bool
-		if c.needListener && targetBlk.ReturnBlock() { // In this case, we have to call the listener before returning.
-			// Save the currently active block.
-			current := builder.CurrentBlock()
-
-			// Allocate the trampoline block to the return where we call the listener.
-			targetBlk = builder.AllocateBasicBlock()
-			builder.SetCurrentBlock(targetBlk)
-			sealTargetBlk = true
-
-			c.callListenerAfter()
-
-			instr := builder.AllocateInstruction()
-			instr.AsReturn(args)
-			builder.InsertInstruction(instr)
-
-			args = ssa.ValuesNil
-
-			// Revert the current block.
-			builder.SetCurrentBlock(current)
+	case wasm.OpcodeBrOnNull:
+		labelIndex := c.readI32u()
+		if state.unreachable {
+			break
 		}
 
-		// Insert the conditional jump to the target block.
-		brnz := builder.AllocateInstruction()
-		brnz.AsBrnz(v, args, targetBlk)
-		builder.InsertInstruction(brnz)
+		r := state.pop()
+		zero := builder.AllocateInstruction().AsIconst64(0).Insert(builder).Return()
+		isNull := builder.AllocateInstruction().
+			AsIcmp(r, zero, ssa.IntegerCmpCondEqual).
+			Insert(builder).
+			Return()
+		c.lowerBrIf(isNull, labelIndex)
+		state.push(r)
 
-		if sealTargetBlk {
-			builder.Seal(targetBlk)
+	case wasm.OpcodeBrOnNonNull:
+		labelIndex := c.readI32u()
+		if state.unreachable {
+			break
 		}
 
-		// Insert the unconditional jump to the Else block which corresponds to after br_if.
-		elseBlk := builder.AllocateBasicBlock()
-		c.insertJumpToBlock(ssa.ValuesNil, elseBlk)
-
-		// Now start translating the instructions after br_if.
-		builder.Seal(elseBlk) // Else of br_if has the current block as the only one successor.
-		builder.SetCurrentBlock(elseBlk)
+		// The reference is passed to the target as the last argument, so we keep it on the stack during the branch.
+		r := state.peek()
+		zero := builder.AllocateInstruction().AsIconst64(0).Insert(builder).Return()
+		isNonNull := builder.AllocateInstruction().
+			AsIcmp(r, zero, ssa.IntegerCmpCondNotEqual).
+			Insert(builder).
+			Return()
+		c.lowerBrIf(isNonNull, labelIndex)
+		state.pop()
 
 	case wasm.OpcodeBrTable:
 		labels := state.tmpForBrTable[:0]
@@ -1564,16 +1675,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		if state.unreachable {
 			break
 		}
-		if c.needListener {
-			c.callListenerAfter()
-		}
-
-		results := c.nPeekDup(c.results())
-		instr := builder.AllocateInstruction()
-
-		instr.AsReturn(results)
-		builder.InsertInstruction(instr)
-		state.unreachable = true
+		c.lowerReturn()
 
 	case wasm.OpcodeUnreachable:
 		if state.unreachable {
@@ -1584,19 +1686,32 @@ func (c *Compiler) lowerCurrentOpcode() {
 		builder.InsertInstruction(exit)
 		state.unreachable = true
 
-	case wasm.OpcodeCallIndirect:
+	case wasm.OpcodeCallIndirect, wasm.OpcodeTailCallReturnCallIndirect:
 		typeIndex := c.readI32u()
 		tableIndex := c.readI32u()
 		if state.unreachable {
 			break
 		}
-		c.lowerCallIndirect(typeIndex, tableIndex)
+		c.lowerCallIndirect(typeIndex, tableIndex, op == wasm.OpcodeTailCallReturnCallIndirect)
+
+	case wasm.OpcodeCallRef, wasm.OpcodeReturnCallRef:
+		typeIndex := c.readI32u()
+		if state.unreachable {
+			break
+		}
+		c.lowerCallRef(typeIndex, op == wasm.OpcodeReturnCallRef)
 
-	case wasm.OpcodeCall:
+	case wasm.OpcodeCall, wasm.OpcodeTailCallReturnCall:
+		callSite := state.pc
 		fnIndex := c.readI32u()
 		if state.unreachable {
 			break
 		}
+		isTailCall := op == wasm.OpcodeTailCallReturnCall
+		if !isTailCall && c.tryInlineCall(fnIndex, callSite) {
+			// The lowering continues from the beginning of the body of the callee, so the pc must not be advanced.
+			return
+		}
 
 		var typIndex wasm.Index
 		if fnIndex < c.m.ImportFunctionCount {
@@ -1630,7 +1745,11 @@ func (c *Compiler) lowerCurrentOpcode() {
 		if fnIndex >= c.m.ImportFunctionCount {
 			args = args.Append(builder.VarLengthPool(), c.moduleCtxPtrValue) // This case the callee module is itself.
 			args = args.Append(builder.VarLengthPool(), vs...)
-			call.AsCall(FunctionIndexToFuncRef(fnIndex), sig, args)
+			if isTailCall {
+				call.AsTailCallReturnCall(FunctionIndexToFuncRef(fnIndex), sig, args)
+			} else {
+				call.AsCall(FunctionIndexToFuncRef(fnIndex), sig, args)
+			}
 			builder.InsertInstruction(call)
 		} else {
 			// This case we have to read the address of the imported function from the module context.
@@ -1644,7 +1763,11 @@ func (c *Compiler) lowerCurrentOpcode() {
 
 			args = args.Append(builder.VarLengthPool(), loadModuleCtxPtr.Return())
 			args = args.Append(builder.VarLengthPool(), vs...)
-			call.AsCallIndirect(loadFuncPtr.Return(), sig, args)
+			if isTailCall {
+				call.AsTailCallReturnCallIndirect(loadFuncPtr.Return(), sig, args)
+			} else {
+				call.AsCallIndirect(loadFuncPtr.Return(), sig, args)
+			}
 			builder.InsertInstruction(call)
 		}
 
@@ -1656,7 +1779,15 @@ func (c *Compiler) lowerCurrentOpcode() {
 			state.push(v)
 		}
 
+		if isTailCall {
+			// The tail call returns to the caller by itself, so nothing is executed after it.
+			c.lowerReturn()
+			break
+		}
 		c.reloadAfterCall()
+		if c.exceptionHandling {
+			c.checkPendingException(c.exceptionHandlerBlock())
+		}
 
 	case wasm.OpcodeDrop:
 		if state.unreachable {
@@ -1702,6 +1833,14 @@ func (c *Compiler) lowerCurrentOpcode() {
 
 	case wasm.OpcodeVecPrefix:
 		state.pc++
+		if relaxedOp, ok := wasm.RelaxedVectorOpcode(c.wasmFunctionBody[state.pc:]); ok {
+			state.pc++ // Skip the second byte of the opcode.
+			if state.unreachable {
+				break
+			}
+			c.lowerRelaxedVectorInstruction(relaxedOp)
+			break
+		}
 		vecOp := c.wasmFunctionBody[state.pc]
 		switch vecOp {
 		case wasm.OpcodeVecV128Const:
@@ -1716,18 +1855,18 @@ func (c *Compiler) lowerCurrentOpcode() {
 			ret := builder.AllocateInstruction().AsVconst(lo, hi).Insert(builder).Return()
 			state.push(ret)
 		case wasm.OpcodeVecV128Load:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), 16)
+			addr := c.memOpSetup(memoryIndex, baseAddr, offset, 16)
 			load := builder.AllocateInstruction()
-			load.AsLoad(addr, offset, ssa.TypeV128)
+			load.AsLoad(addr, uint32(offset), ssa.TypeV128)
 			builder.InsertInstruction(load)
 			state.push(load.Return())
 		case wasm.OpcodeVecV128Load8Lane, wasm.OpcodeVecV128Load16Lane, wasm.OpcodeVecV128Load32Lane:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			state.pc++
 			if state.unreachable {
 				break
@@ -1746,16 +1885,16 @@ func (c *Compiler) lowerCurrentOpcode() {
 			laneIndex := c.wasmFunctionBody[state.pc]
 			vector := state.pop()
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
+			addr := c.memOpSetup(memoryIndex, baseAddr, offset, opSize)
 			load := builder.AllocateInstruction().
-				AsExtLoad(loadOp, addr, offset, false).
+				AsExtLoad(loadOp, addr, uint32(offset), false).
 				Insert(builder).Return()
 			ret := builder.AllocateInstruction().
 				AsInsertlane(vector, load, laneIndex, lane).
 				Insert(builder).Return()
 			state.push(ret)
 		case wasm.OpcodeVecV128Load64Lane:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			state.pc++
 			if state.unreachable {
 				break
@@ -1763,9 +1902,9 @@ func (c *Compiler) lowerCurrentOpcode() {
 			laneIndex := c.wasmFunctionBody[state.pc]
 			vector := state.pop()
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), 8)
+			addr := c.memOpSetup(memoryIndex, baseAddr, offset, 8)
 			load := builder.AllocateInstruction().
-				AsLoad(addr, offset, ssa.TypeI64).
+				AsLoad(addr, uint32(offset), ssa.TypeI64).
 				Insert(builder).Return()
 			ret := builder.AllocateInstruction().
 				AsInsertlane(vector, load, laneIndex, ssa.VecLaneI64x2).
@@ -1773,7 +1912,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			state.push(ret)
 
 		case wasm.OpcodeVecV128Load32zero, wasm.OpcodeVecV128Load64zero:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -1787,17 +1926,17 @@ func (c *Compiler) lowerCurrentOpcode() {
 			}
 
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), uint64(scalarType.Size()))
+			addr := c.memOpSetup(memoryIndex, baseAddr, offset, uint64(scalarType.Size()))
 
 			ret := builder.AllocateInstruction().
-				AsVZeroExtLoad(addr, offset, scalarType).
+				AsVZeroExtLoad(addr, uint32(offset), scalarType).
 				Insert(builder).Return()
 			state.push(ret)
 
 		case wasm.OpcodeVecV128Load8x8u, wasm.OpcodeVecV128Load8x8s,
 			wasm.OpcodeVecV128Load16x4u, wasm.OpcodeVecV128Load16x4s,
 			wasm.OpcodeVecV128Load32x2u, wasm.OpcodeVecV128Load32x2s:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -1821,9 +1960,9 @@ func (c *Compiler) lowerCurrentOpcode() {
 				lane = ssa.VecLaneI32x4
 			}
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), 8)
+			addr := c.memOpSetup(memoryIndex, baseAddr, offset, 8)
 			load := builder.AllocateInstruction().
-				AsLoad(addr, offset, ssa.TypeF64).
+				AsLoad(addr, uint32(offset), ssa.TypeF64).
 				Insert(builder).Return()
 			ret := builder.AllocateInstruction().
 				AsWiden(load, lane, signed, true).
@@ -1831,7 +1970,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			state.push(ret)
 		case wasm.OpcodeVecV128Load8Splat, wasm.OpcodeVecV128Load16Splat,
 			wasm.OpcodeVecV128Load32Splat, wasm.OpcodeVecV128Load64Splat:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -1848,25 +1987,25 @@ func (c *Compiler) lowerCurrentOpcode() {
 				lane, opSize = ssa.VecLaneI64x2, 8
 			}
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
+			addr := c.memOpSetup(memoryIndex, baseAddr, offset, opSize)
 			ret := builder.AllocateInstruction().
-				AsLoadSplat(addr, offset, lane).
+				AsLoadSplat(addr, uint32(offset), lane).
 				Insert(builder).Return()
 			state.push(ret)
 		case wasm.OpcodeVecV128Store:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
 			value := state.pop()
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), 16)
+			addr := c.memOpSetup(memoryIndex, baseAddr, offset, 16)
 			builder.AllocateInstruction().
-				AsStore(ssa.OpcodeStore, value, addr, offset).
+				AsStore(ssa.OpcodeStore, value, addr, uint32(offset)).
 				Insert(builder)
 		case wasm.OpcodeVecV128Store8Lane, wasm.OpcodeVecV128Store16Lane,
 			wasm.OpcodeVecV128Store32Lane, wasm.OpcodeVecV128Store64Lane:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			state.pc++
 			if state.unreachable {
 				break
@@ -1887,12 +2026,12 @@ func (c *Compiler) lowerCurrentOpcode() {
 			}
 			vector := state.pop()
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
+			addr := c.memOpSetup(memoryIndex, baseAddr, offset, opSize)
 			value := builder.AllocateInstruction().
 				AsExtractlane(vector, laneIndex, lane, false).
 				Insert(builder).Return()
 			builder.AllocateInstruction().
-				AsStore(storeOp, value, addr, offset).
+				AsStore(storeOp, value, addr, uint32(offset)).
 				Insert(builder)
 		case wasm.OpcodeVecV128Not:
 			if state.unreachable {
@@ -3158,7 +3297,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		atomicOp := c.wasmFunctionBody[state.pc]
 		switch atomicOp {
 		case wasm.OpcodeAtomicMemoryWait32, wasm.OpcodeAtomicMemoryWait64:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3182,7 +3321,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			timeout := state.pop()
 			exp := state.pop()
 			baseAddr := state.pop()
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), opSize)
+			addr := c.atomicMemOpSetup(memoryIndex, baseAddr, offset, opSize)
 
 			memoryWaitPtr := builder.AllocateInstruction().
 				AsLoad(c.execCtxPtrValue,
@@ -3190,13 +3329,14 @@ func (c *Compiler) lowerCurrentOpcode() {
 					ssa.TypeI64,
 				).Insert(builder).Return()
 
-			args := c.allocateVarLengthValues(3, c.execCtxPtrValue, timeout, exp, addr)
+			memoryIndexValue := builder.AllocateInstruction().AsIconst32(memoryIndex).Insert(builder).Return()
+			args := c.allocateVarLengthValues(5, c.execCtxPtrValue, timeout, exp, addr, memoryIndexValue)
 			memoryWaitRet := builder.AllocateInstruction().
 				AsCallIndirect(memoryWaitPtr, sig, args).
 				Insert(builder).Return()
 			state.push(memoryWaitRet)
 		case wasm.OpcodeAtomicMemoryNotify:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3204,20 +3344,21 @@ func (c *Compiler) lowerCurrentOpcode() {
 			c.storeCallerModuleContext()
 			count := state.pop()
 			baseAddr := state.pop()
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), 4)
+			addr := c.atomicMemOpSetup(memoryIndex, baseAddr, offset, 4)
 
 			memoryNotifyPtr := builder.AllocateInstruction().
 				AsLoad(c.execCtxPtrValue,
 					wazevoapi.ExecutionContextOffsetMemoryNotifyTrampolineAddress.U32(),
 					ssa.TypeI64,
 				).Insert(builder).Return()
-			args := c.allocateVarLengthValues(2, c.execCtxPtrValue, count, addr)
+			memoryIndexValue := builder.AllocateInstruction().AsIconst32(memoryIndex).Insert(builder).Return()
+			args := c.allocateVarLengthValues(4, c.execCtxPtrValue, count, addr, memoryIndexValue)
 			memoryNotifyRet := builder.AllocateInstruction().
 				AsCallIndirect(memoryNotifyPtr, &c.memoryNotifySig, args).
 				Insert(builder).Return()
 			state.push(memoryNotifyRet)
 		case wasm.OpcodeAtomicI32Load, wasm.OpcodeAtomicI64Load, wasm.OpcodeAtomicI32Load8U, wasm.OpcodeAtomicI32Load16U, wasm.OpcodeAtomicI64Load8U, wasm.OpcodeAtomicI64Load16U, wasm.OpcodeAtomicI64Load32U:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3244,11 +3385,11 @@ func (c *Compiler) lowerCurrentOpcode() {
 				typ = ssa.TypeI32
 			}
 
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), size)
+			addr := c.atomicMemOpSetup(memoryIndex, baseAddr, offset, size)
 			res := builder.AllocateInstruction().AsAtomicLoad(addr, size, typ).Insert(builder).Return()
 			state.push(res)
 		case wasm.OpcodeAtomicI32Store, wasm.OpcodeAtomicI64Store, wasm.OpcodeAtomicI32Store8, wasm.OpcodeAtomicI32Store16, wasm.OpcodeAtomicI64Store8, wasm.OpcodeAtomicI64Store16, wasm.OpcodeAtomicI64Store32:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3268,7 +3409,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 				size = 1
 			}
 
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), size)
+			addr := c.atomicMemOpSetup(memoryIndex, baseAddr, offset, size)
 			builder.AllocateInstruction().AsAtomicStore(addr, val, size).Insert(builder)
 		case wasm.OpcodeAtomicI32RmwAdd, wasm.OpcodeAtomicI64RmwAdd, wasm.OpcodeAtomicI32Rmw8AddU, wasm.OpcodeAtomicI32Rmw16AddU, wasm.OpcodeAtomicI64Rmw8AddU, wasm.OpcodeAtomicI64Rmw16AddU, wasm.OpcodeAtomicI64Rmw32AddU,
 			wasm.OpcodeAtomicI32RmwSub, wasm.OpcodeAtomicI64RmwSub, wasm.OpcodeAtomicI32Rmw8SubU, wasm.OpcodeAtomicI32Rmw16SubU, wasm.OpcodeAtomicI64Rmw8SubU, wasm.OpcodeAtomicI64Rmw16SubU, wasm.OpcodeAtomicI64Rmw32SubU,
@@ -3276,7 +3417,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			wasm.OpcodeAtomicI32RmwOr, wasm.OpcodeAtomicI64RmwOr, wasm.OpcodeAtomicI32Rmw8OrU, wasm.OpcodeAtomicI32Rmw16OrU, wasm.OpcodeAtomicI64Rmw8OrU, wasm.OpcodeAtomicI64Rmw16OrU, wasm.OpcodeAtomicI64Rmw32OrU,
 			wasm.OpcodeAtomicI32RmwXor, wasm.OpcodeAtomicI64RmwXor, wasm.OpcodeAtomicI32Rmw8XorU, wasm.OpcodeAtomicI32Rmw16XorU, wasm.OpcodeAtomicI64Rmw8XorU, wasm.OpcodeAtomicI64Rmw16XorU, wasm.OpcodeAtomicI64Rmw32XorU,
 			wasm.OpcodeAtomicI32RmwXchg, wasm.OpcodeAtomicI64RmwXchg, wasm.OpcodeAtomicI32Rmw8XchgU, wasm.OpcodeAtomicI32Rmw16XchgU, wasm.OpcodeAtomicI64Rmw8XchgU, wasm.OpcodeAtomicI64Rmw16XchgU, wasm.OpcodeAtomicI64Rmw32XchgU:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3361,11 +3502,11 @@ func (c *Compiler) lowerCurrentOpcode() {
 				}
 			}
 
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), size)
+			addr := c.atomicMemOpSetup(memoryIndex, baseAddr, offset, size)
 			res := builder.AllocateInstruction().AsAtomicRmw(rmwOp, addr, val, size).Insert(builder).Return()
 			state.push(res)
 		case wasm.OpcodeAtomicI32RmwCmpxchg, wasm.OpcodeAtomicI64RmwCmpxchg, wasm.OpcodeAtomicI32Rmw8CmpxchgU, wasm.OpcodeAtomicI32Rmw16CmpxchgU, wasm.OpcodeAtomicI64Rmw8CmpxchgU, wasm.OpcodeAtomicI64Rmw16CmpxchgU, wasm.OpcodeAtomicI64Rmw32CmpxchgU:
-			_, offset := c.readMemArg()
+			_, offset, memoryIndex := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3385,7 +3526,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			case wasm.OpcodeAtomicI32Rmw8CmpxchgU, wasm.OpcodeAtomicI64Rmw8CmpxchgU:
 				size = 1
 			}
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), size)
+			addr := c.atomicMemOpSetup(memoryIndex, baseAddr, offset, size)
 			res := builder.AllocateInstruction().AsAtomicCas(addr, exp, repl, size).Insert(builder).Return()
 			state.push(res)
 		case wasm.OpcodeAtomicFence:
@@ -3423,7 +3564,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		state.push(refFuncRet)
 
 	case wasm.OpcodeRefNull:
-		c.loweringState.pc++ // skips the reference type as we treat both of them as i64(0).
+		c.readI64s() // skips the heap type as we treat all the null references as i64(0).
 		if state.unreachable {
 			break
 		}
@@ -3440,6 +3581,19 @@ func (c *Compiler) lowerCurrentOpcode() {
 			Insert(builder).
 			Return()
 		state.push(icmp)
+	case wasm.OpcodeRefAsNonNull:
+		if state.unreachable {
+			break
+		}
+		r := state.peek()
+		zero := builder.AllocateInstruction().AsIconst64(0).Insert(builder).Return()
+		isNull := builder.AllocateInstruction().
+			AsIcmp(r, zero, ssa.IntegerCmpCondEqual).
+			Insert(builder).
+			Return()
+		builder.AllocateInstruction().
+			AsExitIfTrueWithCode(c.execCtxPtrValue, isNull, wazevoapi.ExitCodeNullReference).
+			Insert(builder)
 	case wasm.OpcodeTableSet:
 		tableIndex := c.readI32u()
 		if state.unreachable {
@@ -3473,6 +3627,97 @@ func (c *Compiler) lowerCurrentOpcode() {
 	c.loweringState.pc++
 }
 
+var (
+	// relaxedDotEvenLanes and relaxedDotOddLanes are the shuffle lane indexes which select the even and odd i16 lanes
+	// of the concatenation of two i16x8 vectors.
+	relaxedDotEvenLanes = []byte{0, 1, 4, 5, 8, 9, 12, 13, 16, 17, 20, 21, 24, 25, 28, 29}
+	relaxedDotOddLanes  = []byte{2, 3, 6, 7, 10, 11, 14, 15, 18, 19, 22, 23, 26, 27, 30, 31}
+)
+
+// lowerRelaxedVectorInstruction lowers the relaxed vector instruction of the given opcode with the same instructions
+// as the non-relaxed ones, so that the result is the deterministic one described in experimental.CoreFeaturesRelaxedSIMD
+// regardless of the ISA.
+func (c *Compiler) lowerRelaxedVectorInstruction(op wasm.OpcodeVecRelaxed) {
+	builder := c.ssaBuilder
+	state := c.state()
+	var ret ssa.Value
+	switch op {
+	case wasm.OpcodeVecI8x16RelaxedSwizzle:
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsSwizzle(v1, v2, ssa.VecLaneI8x16).Insert(builder).Return()
+	case wasm.OpcodeVecI32x4RelaxedTruncF32x4S, wasm.OpcodeVecI32x4RelaxedTruncF32x4U:
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().
+			AsVFcvtToIntSat(v1, ssa.VecLaneF32x4, op == wasm.OpcodeVecI32x4RelaxedTruncF32x4S).Insert(builder).Return()
+	case wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero, wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero:
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().
+			AsVFcvtToIntSat(v1, ssa.VecLaneF64x2, op == wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero).Insert(builder).Return()
+	case wasm.OpcodeVecF32x4RelaxedMadd, wasm.OpcodeVecF32x4RelaxedNmadd,
+		wasm.OpcodeVecF64x2RelaxedMadd, wasm.OpcodeVecF64x2RelaxedNmadd:
+		lane := ssa.VecLaneF32x4
+		if op == wasm.OpcodeVecF64x2RelaxedMadd || op == wasm.OpcodeVecF64x2RelaxedNmadd {
+			lane = ssa.VecLaneF64x2
+		}
+		v3 := state.pop()
+		v2 := state.pop()
+		v1 := state.pop()
+		mul := builder.AllocateInstruction().AsVFmul(v1, v2, lane).Insert(builder).Return()
+		if op == wasm.OpcodeVecF32x4RelaxedNmadd || op == wasm.OpcodeVecF64x2RelaxedNmadd {
+			ret = builder.AllocateInstruction().AsVFsub(v3, mul, lane).Insert(builder).Return()
+		} else {
+			ret = builder.AllocateInstruction().AsVFadd(mul, v3, lane).Insert(builder).Return()
+		}
+	case wasm.OpcodeVecI8x16RelaxedLaneselect, wasm.OpcodeVecI16x8RelaxedLaneselect,
+		wasm.OpcodeVecI32x4RelaxedLaneselect, wasm.OpcodeVecI64x2RelaxedLaneselect:
+		mask := state.pop()
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsVbitselect(mask, v1, v2).Insert(builder).Return()
+	case wasm.OpcodeVecF32x4RelaxedMin, wasm.OpcodeVecF64x2RelaxedMin:
+		lane := ssa.VecLaneF32x4
+		if op == wasm.OpcodeVecF64x2RelaxedMin {
+			lane = ssa.VecLaneF64x2
+		}
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsVFmin(v1, v2, lane).Insert(builder).Return()
+	case wasm.OpcodeVecF32x4RelaxedMax, wasm.OpcodeVecF64x2RelaxedMax:
+		lane := ssa.VecLaneF32x4
+		if op == wasm.OpcodeVecF64x2RelaxedMax {
+			lane = ssa.VecLaneF64x2
+		}
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsVFmax(v1, v2, lane).Insert(builder).Return()
+	case wasm.OpcodeVecI16x8RelaxedQ15mulrS:
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsSqmulRoundSat(v1, v2, ssa.VecLaneI16x8).Insert(builder).Return()
+	case wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S, wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS:
+		v3 := ssa.ValueInvalid
+		if op == wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS {
+			v3 = state.pop()
+		}
+		v2 := state.pop()
+		v1 := state.pop()
+		// The i16 products of the low and high halves, whose adjacent lanes are summed with a wrap-around.
+		lo := c.lowerExtMul(v1, v2, ssa.VecLaneI8x16, ssa.VecLaneI16x8, true, true)
+		hi := c.lowerExtMul(v1, v2, ssa.VecLaneI8x16, ssa.VecLaneI16x8, true, false)
+		even := builder.AllocateInstruction().AsShuffle(lo, hi, relaxedDotEvenLanes).Insert(builder).Return()
+		odd := builder.AllocateInstruction().AsShuffle(lo, hi, relaxedDotOddLanes).Insert(builder).Return()
+		ret = builder.AllocateInstruction().AsVIadd(even, odd, ssa.VecLaneI16x8).Insert(builder).Return()
+		if v3.Valid() {
+			ret = builder.AllocateInstruction().AsExtIaddPairwise(ret, ssa.VecLaneI16x8, true).Insert(builder).Return()
+			ret = builder.AllocateInstruction().AsVIadd(ret, v3, ssa.VecLaneI32x4).Insert(builder).Return()
+		}
+	default:
+		panic("BUG: unknown relaxed vector instruction: " + wasm.RelaxedVectorInstructionName(op))
+	}
+	state.push(ret)
+}
+
 func (c *Compiler) lowerExtMul(v1, v2 ssa.Value, from, to ssa.VecLane, signed, low bool) ssa.Value {
 	// TODO: The sequence `Widen; Widen; VIMul` can be substituted for a single instruction on some ISAs.
 	builder := c.ssaBuilder
@@ -3533,7 +3778,68 @@ func (c *Compiler) lowerAccessTableWithBoundsCheck(tableIndex uint32, elementOff
 	return calcElementAddressInTable.Return()
 }
 
-func (c *Compiler) lowerCallIndirect(typeIndex, tableIndex uint32) {
+// lowerReturn lowers the return of the current function with the results on the top of the stack.
+func (c *Compiler) lowerReturn() {
+	if c.needListener {
+		c.callListenerAfter()
+	}
+
+	results := c.nPeekDup(c.results())
+	c.insertReturn(results)
+	c.state().unreachable = true
+}
+
+// lowerBrIf lowers the conditional branch to the label at labelIndex taken when cond is non-zero.
+// The arguments to the target are peeked from the current stack, and the lowering continues in the
+// newly allocated block which corresponds to the fallthrough path.
+func (c *Compiler) lowerBrIf(cond ssa.Value, labelIndex uint32) {
+	builder := c.ssaBuilder
+	state := c.state()
+
+	targetBlk, argNum := state.brTargetArgNumFor(labelIndex)
+	args := c.nPeekDup(argNum)
+	var sealTargetBlk bool
+	if c.needListener && c.isFunctionReturn(targetBlk) { // In this case, we have to call the listener before returning.
+		// Save the currently active block.
+		current := builder.CurrentBlock()
+
+		// Allocate the trampoline block to the return where we call the listener.
+		targetBlk = builder.AllocateBasicBlock()
+		builder.SetCurrentBlock(targetBlk)
+		sealTargetBlk = true
+
+		c.callListenerAfter()
+		c.insertReturn(args)
+
+		args = ssa.ValuesNil
+
+		// Revert the current block.
+		builder.SetCurrentBlock(current)
+	}
+
+	// Insert the conditional jump to the target block.
+	brnz := builder.AllocateInstruction()
+	brnz.AsBrnz(cond, args, targetBlk)
+	builder.InsertInstruction(brnz)
+
+	if sealTargetBlk {
+		builder.Seal(targetBlk)
+	}
+
+	// Insert the unconditional jump to the Else block which corresponds to after br_if.
+	elseBlk := builder.AllocateBasicBlock()
+	c.insertJumpToBlock(ssa.ValuesNil, elseBlk)
+
+	// Now start translating the instructions after br_if.
+	builder.Seal(elseBlk) // Else of br_if has the current block as the only one successor.
+	builder.SetCurrentBlock(elseBlk)
+}
+
+// lowerCallIndirect lowers call_indirect, or return_call_indirect if isTailCall is true.
+//
+// Note that tail calls are lowered as regular calls followed by a return when the listener is enabled,
+// so that the listener can observe the results of the current function.
+func (c *Compiler) lowerCallIndirect(typeIndex, tableIndex uint32, isTailCall bool) {
 	builder := c.ssaBuilder
 	state := c.state()
 
@@ -3543,6 +3849,22 @@ func (c *Compiler) lowerCallIndirect(typeIndex, tableIndex uint32) {
 	loadFunctionInstancePtr.AsLoad(functionInstancePtrAddress, 0, ssa.TypeI64)
 	builder.InsertInstruction(loadFunctionInstancePtr)
 	functionInstancePtr := loadFunctionInstancePtr.Return()
+	c.lowerCallFunctionInstance(functionInstancePtr, typeIndex, isTailCall, wazevoapi.ExitCodeIndirectCallNullPointer)
+}
+
+// lowerCallRef lowers call_ref, or return_call_ref if isTailCall is true. The function reference
+// is the pointer to the function instance, so this shares the lowering with call_indirect after the table access.
+func (c *Compiler) lowerCallRef(typeIndex uint32, isTailCall bool) {
+	functionInstancePtr := c.state().pop()
+	c.lowerCallFunctionInstance(functionInstancePtr, typeIndex, isTailCall, wazevoapi.ExitCodeNullFunctionReference)
+}
+
+// lowerCallFunctionInstance lowers the call to the function instance pointed by functionInstancePtr after
+// checking that it is not null and its type matches the type at typeIndex. nullExitCode is the exit code used
+// when functionInstancePtr is null.
+func (c *Compiler) lowerCallFunctionInstance(functionInstancePtr ssa.Value, typeIndex uint32, isTailCall bool, nullExitCode wazevoapi.ExitCode) {
+	builder := c.ssaBuilder
+	state := c.state()
 
 	// Check if it is not the null pointer.
 	zero := builder.AllocateInstruction()
@@ -3552,7 +3874,7 @@ func (c *Compiler) lowerCallIndirect(typeIndex, tableIndex uint32) {
 	checkNull.AsIcmp(functionInstancePtr, zero.Return(), ssa.IntegerCmpCondEqual)
 	builder.InsertInstruction(checkNull)
 	exitIfNull := builder.AllocateInstruction()
-	exitIfNull.AsExitIfTrueWithCode(c.execCtxPtrValue, checkNull.Return(), wazevoapi.ExitCodeIndirectCallNullPointer)
+	exitIfNull.AsExitIfTrueWithCode(c.execCtxPtrValue, checkNull.Return(), nullExitCode)
 	builder.InsertInstruction(exitIfNull)
 
 	// We need to do the type check. First, load the target function instance's typeID.
@@ -3602,7 +3924,11 @@ func (c *Compiler) lowerCallIndirect(typeIndex, tableIndex uint32) {
 	c.storeCallerModuleContext()
 
 	call := builder.AllocateInstruction()
-	call.AsCallIndirect(executablePtr, c.signatures[typ], args)
+	if isTailCall {
+		call.AsTailCallReturnCallIndirect(executablePtr, c.signatures[typ], args)
+	} else {
+		call.AsCallIndirect(executablePtr, c.signatures[typ], args)
+	}
 	builder.InsertInstruction(call)
 
 	first, rest := call.Returns()
@@ -3613,24 +3939,41 @@ func (c *Compiler) lowerCallIndirect(typeIndex, tableIndex uint32) {
 		state.push(v)
 	}
 
+	if isTailCall {
+		// The tail call returns to the caller by itself, so nothing is executed after it.
+		c.lowerReturn()
+		return
+	}
 	c.reloadAfterCall()
+	if c.exceptionHandling {
+		c.checkPendingException(c.exceptionHandlerBlock())
+	}
 }
 
 // memOpSetup inserts the bounds check and calculates the address of the memory operation (loads/stores).
-func (c *Compiler) memOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
+//
+// The known safe bounds are only tracked for the memory at index zero, since they are keyed by the base address value.
+func (c *Compiler) memOpSetup(memoryIndex uint32, baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
+	if c.memory64[memoryIndex] {
+		return c.memOpSetup64(memoryIndex, baseAddr, constOffset, operationSizeInBytes)
+	}
+
 	address = ssa.ValueInvalid
 	builder := c.ssaBuilder
 
 	baseAddrID := baseAddr.ID()
 	ceil := constOffset + operationSizeInBytes
-	if known := c.getKnownSafeBound(baseAddrID); known.valid() {
+	if memoryIndex == 0 {
+		c.deriveKnownSafeBound(baseAddr)
+	}
+	if known := c.getKnownSafeBound(baseAddrID); memoryIndex == 0 && known.valid() {
 		// We reuse the calculated absolute address even if the bound is not known to be safe.
 		address = known.absoluteAddr
 		if ceil <= known.bound {
 			if !address.Valid() {
 				// This means that, the bound is known to be safe, but the memory base might have changed.
 				// So, we re-calculate the address.
-				memBase := c.getMemoryBaseValue(false)
+				memBase := c.getMemoryBaseValue(memoryIndex, false)
 				extBaseAddr := builder.AllocateInstruction().
 					AsUExtend(baseAddr, 32, 64).
 					Insert(builder).
@@ -3654,7 +3997,7 @@ func (c *Compiler) memOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBy
 		Return()
 
 	// Note: memLen is already zero extended to 64-bit space at the load time.
-	memLen := c.getMemoryLenValue(false)
+	memLen := c.getMemoryLenValue(memoryIndex, false)
 
 	// baseAddrPlusCeil = baseAddr + ceil
 	baseAddrPlusCeil := builder.AllocateInstruction()
@@ -3671,27 +4014,111 @@ func (c *Compiler) memOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBy
 
 	// Load the value from memBase + extBaseAddr.
 	if address == ssa.ValueInvalid { // Reuse the value if the memBase is already calculated at this point.
-		memBase := c.getMemoryBaseValue(false)
+		memBase := c.getMemoryBaseValue(memoryIndex, false)
 		address = builder.AllocateInstruction().
 			AsIadd(memBase, extBaseAddr).Insert(builder).Return()
 	}
 
 	// Record the bound ceil for this baseAddr is known to be safe for the subsequent memory access in the same block.
-	c.recordKnownSafeBound(baseAddrID, ceil, address)
+	if memoryIndex == 0 {
+		c.recordKnownSafeBound(baseAddrID, ceil, address)
+	}
+	return
+}
+
+// deriveKnownSafeBound records the known safe bound for baseAddr if it is the sum of a value with the known safe bound
+// and constants, e.g. the address of a field of a struct whose other field has been accessed.
+//
+// Suppose baseAddr = x + c where x + bound <= memory length. If c < bound, then x + c never overflows in 32-bit since the
+// memory length is at most 4GiB, hence baseAddr + (bound - c) <= memory length holds. Note that the opposite doesn't
+// hold, i.e. the bound of baseAddr tells nothing about x because baseAddr might have overflowed.
+func (c *Compiler) deriveKnownSafeBound(baseAddr ssa.Value) {
+	if c.getKnownSafeBound(baseAddr.ID()).valid() {
+		return
+	}
+
+	builder := c.ssaBuilder
+	var addend uint64
+	for v := baseAddr; ; {
+		instr := builder.InstructionOfValue(v)
+		if instr == nil || instr.Opcode() != ssa.OpcodeIadd {
+			return
+		}
+		x, y := instr.Arg2()
+		if yInstr := builder.InstructionOfValue(y); yInstr != nil && yInstr.Opcode() == ssa.OpcodeIconst {
+			addend += uint64(uint32(yInstr.ConstantVal()))
+		} else if xInstr := builder.InstructionOfValue(x); xInstr != nil && xInstr.Opcode() == ssa.OpcodeIconst {
+			addend += uint64(uint32(xInstr.ConstantVal()))
+			x = y
+		} else {
+			return
+		}
+
+		if known := c.getKnownSafeBound(x.ID()); known.valid() {
+			if addend < known.bound {
+				c.recordKnownSafeBound(baseAddr.ID(), known.bound-addend, ssa.ValueInvalid)
+			}
+			return
+		}
+		v = x
+	}
+}
+
+// memOpSetup64 is memOpSetup for 64-bit memories. baseAddr is already an i64, so the bounds check also has to
+// reject the case where baseAddr + constOffset + operationSizeInBytes wraps around.
+//
+// The constant offset of the loads and stores is 32-bit, so the upper 32 bits of constOffset are added to the
+// returned address here, and callers pass only the lower 32 bits to the load or store instruction.
+func (c *Compiler) memOpSetup64(memoryIndex uint32, baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
+	builder := c.ssaBuilder
+
+	ceil := constOffset + operationSizeInBytes
+	if ceil < constOffset {
+		ceil = math.MaxUint64 // Saturate so that the bounds check below always fails.
+	}
+	ceilConst := builder.AllocateInstruction().AsIconst64(ceil).Insert(builder).Return()
+	baseAddrPlusCeil := builder.AllocateInstruction().AsIadd(baseAddr, ceilConst).Insert(builder).Return()
+
+	// Check for the overflow: `baseAddrPlusCeil < baseAddr`.
+	overflow := builder.AllocateInstruction().
+		AsIcmp(baseAddrPlusCeil, baseAddr, ssa.IntegerCmpCondUnsignedLessThan).
+		Insert(builder).
+		Return()
+	builder.AllocateInstruction().
+		AsExitIfTrueWithCode(c.execCtxPtrValue, overflow, wazevoapi.ExitCodeMemoryOutOfBounds).
+		Insert(builder)
+
+	// Check for out of bounds memory access: `memLen >= baseAddrPlusCeil`.
+	memLen := c.getMemoryLenValue(memoryIndex, false)
+	cmp := builder.AllocateInstruction().
+		AsIcmp(memLen, baseAddrPlusCeil, ssa.IntegerCmpCondUnsignedLessThan).
+		Insert(builder).
+		Return()
+	builder.AllocateInstruction().
+		AsExitIfTrueWithCode(c.execCtxPtrValue, cmp, wazevoapi.ExitCodeMemoryOutOfBounds).
+		Insert(builder)
+
+	memBase := c.getMemoryBaseValue(memoryIndex, false)
+	address = builder.AllocateInstruction().AsIadd(memBase, baseAddr).Insert(builder).Return()
+	if upper := constOffset &^ math.MaxUint32; upper != 0 {
+		upperConst := builder.AllocateInstruction().AsIconst64(upper).Insert(builder).Return()
+		address = builder.AllocateInstruction().AsIadd(address, upperConst).Insert(builder).Return()
+	}
 	return
 }
 
 // atomicMemOpSetup inserts the bounds check and calculates the address of the memory operation (loads/stores), including
 // the constant offset and performs an alignment check on the final address.
-func (c *Compiler) atomicMemOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
+func (c *Compiler) atomicMemOpSetup(memoryIndex uint32, baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
 	builder := c.ssaBuilder
 
-	addrWithoutOffset := c.memOpSetup(baseAddr, constOffset, operationSizeInBytes)
+	addrWithoutOffset := c.memOpSetup(memoryIndex, baseAddr, constOffset, operationSizeInBytes)
 	var addr ssa.Value
-	if constOffset == 0 {
+	// The upper 32 bits of constOffset are already added by memOpSetup for 64-bit memories.
+	if lower := uint64(uint32(constOffset)); lower == 0 {
 		addr = addrWithoutOffset
 	} else {
-		offset := builder.AllocateInstruction().AsIconst64(constOffset).Insert(builder).Return()
+		offset := builder.AllocateInstruction().AsIconst64(lower).Insert(builder).Return()
 		addr = builder.AllocateInstruction().AsIadd(addrWithoutOffset, offset).Insert(builder).Return()
 	}
 
@@ -3723,6 +4150,22 @@ func (c *Compiler) memAlignmentCheck(addr ssa.Value, operationSizeInBytes uint64
 	builder.AllocateInstruction().AsExitIfTrueWithCode(c.execCtxPtrValue, cmp, wazevoapi.ExitCodeUnalignedAtomic).Insert(builder)
 }
 
+// consumeFuel subtracts cost from the fuel remaining in the execution context, and exits with
+// wazevoapi.ExitCodeOutOfFuel if not enough is remaining.
+func (c *Compiler) consumeFuel(cost uint64) {
+	builder := c.ssaBuilder
+	fuel := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetFuel.U32(), ssa.TypeI64).
+		Insert(builder).Return()
+	costValue := builder.AllocateInstruction().AsIconst64(cost).Insert(builder).Return()
+	outOfFuel := builder.AllocateInstruction().AsIcmp(fuel, costValue, ssa.IntegerCmpCondSignedLessThan).Insert(builder).Return()
+	builder.AllocateInstruction().AsExitIfTrueWithCode(c.execCtxPtrValue, outOfFuel, wazevoapi.ExitCodeOutOfFuel).Insert(builder)
+	remaining := builder.AllocateInstruction().AsIsub(fuel, costValue).Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsStore(ssa.OpcodeStore, remaining, c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetFuel.U32()).
+		Insert(builder)
+}
+
 func (c *Compiler) callMemmove(dst, src, size ssa.Value) {
 	args := c.allocateVarLengthValues(3, dst, src, size)
 	if size.Type() != ssa.TypeI64 {
@@ -3744,8 +4187,8 @@ func (c *Compiler) reloadAfterCall() {
 
 	// After calling any function, memory buffer might have changed. So we need to re-define the variable.
 	// However, if the memory is shared, we don't need to reload the memory base and length as the base will never change.
-	if c.needMemory && !c.memoryShared {
-		c.reloadMemoryBaseLen()
+	if c.needMemory {
+		c.reloadMemoryBaseLen(true)
 	}
 
 	// Also, any mutable Global can change.
@@ -3754,14 +4197,36 @@ func (c *Compiler) reloadAfterCall() {
 	}
 }
 
-func (c *Compiler) reloadMemoryBaseLen() {
-	_ = c.getMemoryBaseValue(true)
-	_ = c.getMemoryLenValue(true)
+// defineMemoryBaseLen defines the base and length of all the memories at the entry of the function, so that the
+// latest definitions can be found on every path. Unused ones are eliminated by the optimization passes.
+func (c *Compiler) defineMemoryBaseLen() {
+	for i, shared := range c.memoryShared {
+		_ = c.getMemoryBaseValue(uint32(i), true)
+		if !shared {
+			_ = c.getMemoryLenValue(uint32(i), true)
+		}
+	}
+}
+
+// reloadMemoryBaseLen reloads the base and length of all the memories. If skipShared is true,
+// shared memories are not reloaded since their base never changes.
+func (c *Compiler) reloadMemoryBaseLen(skipShared bool) {
+	reloaded := false
+	for i, shared := range c.memoryShared {
+		if skipShared && shared {
+			continue
+		}
+		_ = c.getMemoryBaseValue(uint32(i), true)
+		_ = c.getMemoryLenValue(uint32(i), true)
+		reloaded = true
+	}
 
-	// This function being called means that the memory base might have changed.
-	// Therefore, we need to clear the absolute addresses recorded in the known safe bounds
-	// because we cache the absolute address of the memory access per each base offset.
-	c.resetAbsoluteAddressInSafeBounds()
+	if reloaded {
+		// This function being called means that the memory base might have changed.
+		// Therefore, we need to clear the absolute addresses recorded in the known safe bounds
+		// because we cache the absolute address of the memory access per each base offset.
+		c.resetAbsoluteAddressInSafeBounds()
+	}
 }
 
 func (c *Compiler) setWasmGlobalValue(index wasm.Index, v ssa.Value) {
@@ -3822,19 +4287,29 @@ const (
 	memoryInstanceBufSizeOffset = memoryInstanceBufOffset + 8
 )
 
-func (c *Compiler) getMemoryBaseValue(forceReload bool) ssa.Value {
+// memoryOffsets returns the offset of either the *wasm.MemoryInstance of the imported memory, or the buffer base of the
+// local memory in the module context, depending on whether the memory at memoryIndex is imported.
+func (c *Compiler) memoryOffsets(memoryIndex uint32) (offset wazevoapi.Offset, imported bool) {
+	if memoryIndex < c.m.ImportMemoryCount {
+		return c.offset.ImportedMemoryOffset(memoryIndex), true
+	}
+	return c.offset.LocalMemoryBase(memoryIndex - c.m.ImportMemoryCount), false
+}
+
+// getMemoryBaseValue returns the base address of the memory at memoryIndex. Unless forceReload is true, this returns the
+// latest definition of the base, which is defined at the entry by defineMemoryBaseLen and redefined whenever it might
+// have changed, e.g. after calls. This keeps the base in a register across the blocks including the loops.
+func (c *Compiler) getMemoryBaseValue(memoryIndex uint32, forceReload bool) ssa.Value {
 	builder := c.ssaBuilder
-	variable := c.memoryBaseVariable
+	variable := c.memoryBaseVariables[memoryIndex]
 	if !forceReload {
-		if v := builder.FindValueInLinearPath(variable); v.Valid() {
-			return v
-		}
+		return builder.MustFindValue(variable)
 	}
 
 	var ret ssa.Value
-	if c.offset.LocalMemoryBegin < 0 {
+	if offset, imported := c.memoryOffsets(memoryIndex); imported {
 		loadMemInstPtr := builder.AllocateInstruction()
-		loadMemInstPtr.AsLoad(c.moduleCtxPtrValue, c.offset.ImportedMemoryBegin.U32(), ssa.TypeI64)
+		loadMemInstPtr.AsLoad(c.moduleCtxPtrValue, offset.U32(), ssa.TypeI64)
 		builder.InsertInstruction(loadMemInstPtr)
 		memInstPtr := loadMemInstPtr.Return()
 
@@ -3844,7 +4319,7 @@ func (c *Compiler) getMemoryBaseValue(forceReload bool) ssa.Value {
 		ret = loadBufPtr.Return()
 	} else {
 		load := builder.AllocateInstruction()
-		load.AsLoad(c.moduleCtxPtrValue, c.offset.LocalMemoryBase().U32(), ssa.TypeI64)
+		load.AsLoad(c.moduleCtxPtrValue, offset.U32(), ssa.TypeI64)
 		builder.InsertInstruction(load)
 		ret = load.Return()
 	}
@@ -3853,24 +4328,25 @@ func (c *Compiler) getMemoryBaseValue(forceReload bool) ssa.Value {
 	return ret
 }
 
-func (c *Compiler) getMemoryLenValue(forceReload bool) ssa.Value {
-	variable := c.memoryLenVariable
+// getMemoryLenValue returns the length of the memory at memoryIndex in the same way as getMemoryBaseValue, except that
+// the length of the shared memory is always reloaded as it might be grown by other threads at any time.
+func (c *Compiler) getMemoryLenValue(memoryIndex uint32, forceReload bool) ssa.Value {
+	variable := c.memoryLenVariables[memoryIndex]
+	shared := c.memoryShared[memoryIndex]
 	builder := c.ssaBuilder
-	if !forceReload && !c.memoryShared {
-		if v := builder.FindValueInLinearPath(variable); v.Valid() {
-			return v
-		}
+	if !forceReload && !shared {
+		return builder.MustFindValue(variable)
 	}
 
 	var ret ssa.Value
-	if c.offset.LocalMemoryBegin < 0 {
+	if offset, imported := c.memoryOffsets(memoryIndex); imported {
 		loadMemInstPtr := builder.AllocateInstruction()
-		loadMemInstPtr.AsLoad(c.moduleCtxPtrValue, c.offset.ImportedMemoryBegin.U32(), ssa.TypeI64)
+		loadMemInstPtr.AsLoad(c.moduleCtxPtrValue, offset.U32(), ssa.TypeI64)
 		builder.InsertInstruction(loadMemInstPtr)
 		memInstPtr := loadMemInstPtr.Return()
 
 		loadBufSizePtr := builder.AllocateInstruction()
-		if c.memoryShared {
+		if shared {
 			sizeOffset := builder.AllocateInstruction().AsIconst64(memoryInstanceBufSizeOffset).Insert(builder).Return()
 			addr := builder.AllocateInstruction().AsIadd(memInstPtr, sizeOffset).Insert(builder).Return()
 			loadBufSizePtr.AsAtomicLoad(addr, 8, ssa.TypeI64)
@@ -3881,13 +4357,17 @@ func (c *Compiler) getMemoryLenValue(forceReload bool) ssa.Value {
 
 		ret = loadBufSizePtr.Return()
 	} else {
+		lenOffset := offset + 8
 		load := builder.AllocateInstruction()
-		if c.memoryShared {
-			lenOffset := builder.AllocateInstruction().AsIconst64(c.offset.LocalMemoryLen().U64()).Insert(builder).Return()
-			addr := builder.AllocateInstruction().AsIadd(c.moduleCtxPtrValue, lenOffset).Insert(builder).Return()
+		if shared {
+			lenOffsetValue := builder.AllocateInstruction().AsIconst64(lenOffset.U64()).Insert(builder).Return()
+			addr := builder.AllocateInstruction().AsIadd(c.moduleCtxPtrValue, lenOffsetValue).Insert(builder).Return()
 			load.AsAtomicLoad(addr, 8, ssa.TypeI64)
+		} else if c.memory64[memoryIndex] {
+			// 64-bit memories can be larger than 4GiB.
+			load.AsLoad(c.moduleCtxPtrValue, lenOffset.U32(), ssa.TypeI64)
 		} else {
-			load.AsExtLoad(ssa.OpcodeUload32, c.moduleCtxPtrValue, c.offset.LocalMemoryLen().U32(), true)
+			load.AsExtLoad(ssa.OpcodeUload32, c.moduleCtxPtrValue, lenOffset.U32(), true)
 		}
 		builder.InsertInstruction(load)
 		ret = load.Return()
@@ -3977,7 +4457,11 @@ func (c *Compiler) readBlockType() *wasm.FunctionType {
 	state := c.state()
 
 	c.br.Reset(c.wasmFunctionBody[state.pc+1:])
-	bt, num, err := wasm.DecodeBlockType(c.m.TypeSection, c.br, api.CoreFeaturesV2)
+	features := api.CoreFeaturesV2
+	if c.exceptionHandling {
+		features |= experimental.CoreFeaturesExceptionHandling
+	}
+	bt, num, err := wasm.DecodeBlockType(c.m, c.br, features)
 	if err != nil {
 		panic(err) // shouldn't be reached since compilation comes after validation.
 	}
@@ -3986,7 +4470,21 @@ func (c *Compiler) readBlockType() *wasm.FunctionType {
 	return bt
 }
 
-func (c *Compiler) readMemArg() (align, offset uint32) {
+func (c *Compiler) readCatchClauses() []wasm.CatchClause {
+	state := c.state()
+
+	catches, num, err := wasm.DecodeCatchClauses(c.wasmFunctionBody[state.pc+1:])
+	if err != nil {
+		panic(err) // shouldn't be reached since compilation comes after validation.
+	}
+	state.pc += int(num)
+
+	return catches
+}
+
+// readMemArg reads the memarg immediate of a memory instruction. The offset is 64-bit as it can exceed 32 bits
+// for 64-bit memories.
+func (c *Compiler) readMemArg() (align uint32, offset uint64, memoryIndex uint32) {
 	state := c.state()
 
 	align, num, err := leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
@@ -3995,18 +4493,33 @@ func (c *Compiler) readMemArg() (align, offset uint32) {
 	}
 
 	state.pc += int(num)
-	offset, num, err = leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
+	if align&wasm.MemArgMemoryIndexFlag != 0 {
+		align &^= wasm.MemArgMemoryIndexFlag
+		memoryIndex, num, err = leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
+		if err != nil {
+			panic(fmt.Errorf("read memory index: %v", err))
+		}
+		state.pc += int(num)
+	}
+
+	if c.memory64[memoryIndex] {
+		offset, num, err = leb128.LoadUint64(c.wasmFunctionBody[state.pc+1:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		panic(fmt.Errorf("read memory offset: %v", err))
 	}
 
 	state.pc += int(num)
-	return align, offset
+	return align, offset, memoryIndex
 }
 
 // insertJumpToBlock inserts a jump instruction to the given block in the current block.
 func (c *Compiler) insertJumpToBlock(args ssa.Values, targetBlk ssa.BasicBlock) {
-	if targetBlk.ReturnBlock() {
+	if c.isFunctionReturn(targetBlk) {
 		if c.needListener {
 			c.callListenerAfter()
 		}
@@ -4120,13 +4633,15 @@ func (c *Compiler) callListenerBefore() {
 	beforeListenerPtr := builder.AllocateInstruction().
 		AsLoad(beforeListeners1stElement, uint32(c.wasmFunctionTypeIndex)*8 /* 8 bytes per index */, ssa.TypeI64).Insert(builder).Return()
 
-	entry := builder.EntryBlock()
-	ps := entry.Params()
-
-	args := c.allocateVarLengthValues(ps, c.execCtxPtrValue,
+	args := c.allocateVarLengthValues(len(c.wasmFunctionTyp.Params)+2, c.execCtxPtrValue,
 		builder.AllocateInstruction().AsIconst32(c.wasmLocalFunctionIndex).Insert(builder).Return())
-	for i := 2; i < ps; i++ {
-		args = args.Append(builder.VarLengthPool(), entry.Param(i))
+	if inlined := c.inlined; inlined != nil {
+		args = args.Append(builder.VarLengthPool(), inlined.args...)
+	} else {
+		entry := builder.EntryBlock()
+		for i := 2; i < entry.Params(); i++ {
+			args = args.Append(builder.VarLengthPool(), entry.Param(i))
+		}
 	}
 
 	beforeSig := c.listenerSignatures[c.wasmFunctionTyp][0]
@@ -4248,9 +4763,31 @@ func (c *Compiler) loadTableBaseAddr(tableInstancePtr ssa.Value) ssa.Value {
 	return loadTableBaseAddress.Return()
 }
 
-func (c *Compiler) boundsCheckInMemory(memLen, offset, size ssa.Value) {
+// popMemoryOperand pops an address or size operand of a bulk memory instruction and returns it as an i64.
+// The operand is zero-extended unless it is already an i64 of a 64-bit memory.
+func (c *Compiler) popMemoryOperand(is64 bool) ssa.Value {
+	v := c.state().pop()
+	if is64 {
+		return v
+	}
+	builder := c.ssaBuilder
+	return builder.AllocateInstruction().AsUExtend(v, 32, 64).Insert(builder).Return()
+}
+
+func (c *Compiler) boundsCheckInMemory(memoryIndex uint32, offset, size ssa.Value) {
 	builder := c.ssaBuilder
+	memLen := c.getMemoryLenValue(memoryIndex, false)
 	ceil := builder.AllocateInstruction().AsIadd(offset, size).Insert(builder).Return()
+	if c.memory64[memoryIndex] {
+		// The i64 operands of 64-bit memories can make offset + size wrap around.
+		overflow := builder.AllocateInstruction().
+			AsIcmp(ceil, offset, ssa.IntegerCmpCondUnsignedLessThan).
+			Insert(builder).
+			Return()
+		builder.AllocateInstruction().
+			AsExitIfTrueWithCode(c.execCtxPtrValue, overflow, wazevoapi.ExitCodeMemoryOutOfBounds).
+			Insert(builder)
+	}
 	cmp := builder.AllocateInstruction().
 		AsIcmp(memLen, ceil, ssa.IntegerCmpCondUnsignedLessThan).
 		Insert(builder).
@@ -4259,3 +4796,189 @@ func (c *Compiler) boundsCheckInMemory(memLen, offset, size ssa.Value) {
 		AsExitIfTrueWithCode(c.execCtxPtrValue, cmp, wazevoapi.ExitCodeMemoryOutOfBounds).
 		Insert(builder)
 }
+
+// callExceptionBuiltin calls the builtin function to throw or catch exceptions, and returns its result.
+func (c *Compiler) callExceptionBuiltin(op wazevoapi.ExceptionOp, arg ssa.Value) ssa.Value {
+	builder := c.ssaBuilder
+	// The builtin resolves the tags of the caller module.
+	c.storeCallerModuleContext()
+
+	opValue := builder.AllocateInstruction().AsIconst32(uint32(op)).Insert(builder).Return()
+	exceptionPtr := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue,
+			wazevoapi.ExecutionContextOffsetExceptionTrampolineAddress.U32(),
+			ssa.TypeI64,
+		).Insert(builder).Return()
+	args := c.allocateVarLengthValues(3, c.execCtxPtrValue, opValue, arg)
+	return builder.AllocateInstruction().
+		AsCallIndirect(exceptionPtr, &c.exceptionSig, args).
+		Insert(builder).Return()
+}
+
+// exceptionHandlerBlock returns the block to jump to when an exception is thrown at the current position: the handler
+// of the innermost try_table if any, or otherwise the block to propagate the exception to the caller.
+func (c *Compiler) exceptionHandlerBlock() ssa.BasicBlock {
+	frames := c.state().controlFrames
+	for i := len(frames) - 1; i >= 0; i-- {
+		if ctrl := &frames[i]; ctrl.kind == controlFrameKindTryTable {
+			if ctrl.handler == nil {
+				ctrl.handler = c.ssaBuilder.AllocateBasicBlock()
+			}
+			return ctrl.handler
+		}
+	}
+	return c.exceptionPropagationBlock()
+}
+
+// exceptionPropagationBlock returns the block to return from the function while the exception is pending.
+func (c *Compiler) exceptionPropagationBlock() ssa.BasicBlock {
+	if c.exceptionPropagateBlock == nil {
+		c.exceptionPropagateBlock = c.ssaBuilder.AllocateBasicBlock()
+	}
+	return c.exceptionPropagateBlock
+}
+
+// checkPendingException inserts the jump to the handler if the callee returned with a pending exception.
+func (c *Compiler) checkPendingException(handler ssa.BasicBlock) {
+	builder := c.ssaBuilder
+	pending := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue,
+			wazevoapi.ExecutionContextOffsetExceptionPending.U32(),
+			ssa.TypeI64,
+		).Insert(builder).Return()
+	builder.AllocateInstruction().AsBrnz(pending, ssa.ValuesNil, handler).Insert(builder)
+
+	continuation := builder.AllocateBasicBlock()
+	c.insertJumpToBlock(ssa.ValuesNil, continuation)
+	builder.Seal(continuation) // The current block is the only predecessor.
+	builder.SetCurrentBlock(continuation)
+}
+
+// lowerCatchClauses lowers the catch clauses of the given try_table into its handler, which tries each clause in order,
+// and jumps to the enclosing handler if none matches the pending exception.
+func (c *Compiler) lowerCatchClauses(ctrl *controlFrame) {
+	if ctrl.handler == nil {
+		return // No exception can be thrown in this try_table.
+	}
+
+	builder := c.ssaBuilder
+	state := c.state()
+	builder.Seal(ctrl.handler)
+	builder.SetCurrentBlock(ctrl.handler)
+	for i := range ctrl.catches {
+		clause := &ctrl.catches[i]
+		op, tagIndex := wazevoapi.ExceptionOpCatch, uint64(wazevoapi.ExceptionCatchAllTag)
+		if clause.Kind == wasm.CatchKindCatchRef || clause.Kind == wasm.CatchKindCatchAllRef {
+			op = wazevoapi.ExceptionOpCatchRef
+		}
+		if clause.Kind == wasm.CatchKindCatch || clause.Kind == wasm.CatchKindCatchRef {
+			tagIndex = uint64(clause.Tag)
+		}
+		tag := builder.AllocateInstruction().AsIconst64(tagIndex).Insert(builder).Return()
+		caught := c.callExceptionBuiltin(op, tag)
+
+		matched, next := builder.AllocateBasicBlock(), builder.AllocateBasicBlock()
+		builder.AllocateInstruction().AsBrnz(caught, ssa.ValuesNil, matched).Insert(builder)
+		c.insertJumpToBlock(ssa.ValuesNil, next)
+		builder.Seal(matched)
+		builder.Seal(next)
+
+		// The matched clause branches to its label with the values of the exception on top of the stack at the
+		// beginning of try_table.
+		builder.SetCurrentBlock(matched)
+		state.values = state.values[:ctrl.originalStackLenWithoutParam]
+		if tagIndex != wazevoapi.ExceptionCatchAllTag {
+			payload := builder.AllocateInstruction().
+				AsLoad(c.execCtxPtrValue,
+					wazevoapi.ExecutionContextOffsetExceptionPayload.U32(),
+					ssa.TypeI64,
+				).Insert(builder).Return()
+			typIndex, _ := c.m.TagTypeIndex(clause.Tag)
+			var offset uint32
+			for _, typ := range c.m.TypeSection[typIndex].Params {
+				v := builder.AllocateInstruction().
+					AsLoad(payload, offset, WasmTypeToSSAType(typ)).
+					Insert(builder).Return()
+				state.push(v)
+				offset += exceptionValueSize(typ)
+			}
+		}
+		if op == wazevoapi.ExceptionOpCatchRef {
+			state.push(caught)
+		}
+		targetBlk, argNum := state.brTargetArgNumFor(clause.Label)
+		args := c.nPeekDup(argNum)
+		c.insertJumpToBlock(args, targetBlk)
+
+		builder.SetCurrentBlock(next)
+	}
+	c.insertJumpToBlock(ssa.ValuesNil, c.exceptionHandlerBlock())
+}
+
+// lowerExceptionPropagation lowers the block to return from the function while the exception is pending. The results
+// are zero values which are never observed since the caller jumps to its handler.
+func (c *Compiler) lowerExceptionPropagation() {
+	builder := c.ssaBuilder
+	blk := c.exceptionPropagateBlock
+	builder.Seal(blk)
+	builder.SetCurrentBlock(blk)
+
+	results := c.allocateVarLengthValues(c.results())
+	for _, typ := range c.wasmFunctionTyp.Results {
+		results = results.Append(builder.VarLengthPool(), c.insertZeroValue(WasmTypeToSSAType(typ)))
+	}
+	builder.AllocateInstruction().AsReturn(results).Insert(builder)
+}
+
+// insertZeroValue inserts the zero value of the given type in the current block.
+func (c *Compiler) insertZeroValue(t ssa.Type) ssa.Value {
+	builder := c.ssaBuilder
+	instr := builder.AllocateInstruction()
+	switch t {
+	case ssa.TypeI32:
+		instr.AsIconst32(0)
+	case ssa.TypeI64:
+		instr.AsIconst64(0)
+	case ssa.TypeF32:
+		instr.AsF32const(0)
+	case ssa.TypeF64:
+		instr.AsF64const(0)
+	case ssa.TypeV128:
+		instr.AsVconst(0, 0)
+	}
+	return instr.Insert(builder).Return()
+}
+
+// exceptionValueSize returns the size of the value of the given type in the values of an exception.
+func exceptionValueSize(typ wasm.ValueType) uint32 {
+	if typ == wasm.ValueTypeV128 {
+		return 16
+	}
+	return 8
+}
+
+// insertSafepoint inserts the check of the stack sample requested by the sampling profiler, which calls the sample
+// trampoline only if requested so that the cost is a load and a branch otherwise.
+func (c *Compiler) insertSafepoint() {
+	builder := c.ssaBuilder
+	requested := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetSampleRequested.U32(), ssa.TypeI32).
+		Insert(builder).Return()
+
+	sampleBlk, continuation := builder.AllocateBasicBlock(), builder.AllocateBasicBlock()
+	brz := builder.AllocateInstruction()
+	brz.AsBrz(requested, ssa.ValuesNil, continuation)
+	builder.InsertInstruction(brz)
+	c.insertJumpToBlock(ssa.ValuesNil, sampleBlk)
+	builder.Seal(sampleBlk)
+
+	builder.SetCurrentBlock(sampleBlk)
+	samplePtr := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetSampleTrampolineAddress.U32(), ssa.TypeI64).
+		Insert(builder).Return()
+	args := c.allocateVarLengthValues(1, c.execCtxPtrValue)
+	builder.AllocateInstruction().AsCallIndirect(samplePtr, &c.checkModuleExitCodeSig, args).Insert(builder)
+	c.insertJumpToBlock(ssa.ValuesNil, continuation)
+	builder.Seal(continuation)
+	builder.SetCurrentBlock(continuation)
+}
@@ -6,14 +6,44 @@ import (
 	"unsafe"
 
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
-func buildHostModuleOpaque(m *wasm.Module, listeners []experimental.FunctionListener) moduleContextOpaque {
-	size := len(m.CodeSection)*16 + 32
+// newHostModuleContextOffsetData returns the offsets of the module context built by buildHostModuleOpaque. As with
+// the module context of Wasm modules, it begins with the pointer to the module instance, so that a function reference
+// to a host function can be resolved to its module instance. The globals follow the Go functions, so that importing
+// modules access them as if they were defined in Wasm. Host modules have no other fields.
+func newHostModuleContextOffsetData(m *wasm.Module) wazevoapi.ModuleContextOffsetData {
+	ret := wazevoapi.ModuleContextOffsetData{
+		LocalMemoryBegin:                    -1,
+		ImportedMemoryBegin:                 -1,
+		ImportedFunctionsBegin:              -1,
+		GlobalsBegin:                        -1,
+		TypeIDs1stElement:                   -1,
+		TablesBegin:                         -1,
+		BeforeListenerTrampolines1stElement: -1,
+		AfterListenerTrampolines1stElement:  -1,
+		DataInstances1stElement:             -1,
+		ElementInstances1stElement:          -1,
+	}
+	offset := wazevoapi.Offset(len(m.CodeSection)*16 + 32)
+	if globals := len(m.GlobalSection); globals > 0 {
+		ret.GlobalsBegin = offset
+		offset += wazevoapi.Offset(globals) * 16
+	}
+	ret.TotalSize = int(offset)
+	return ret
+}
+
+// buildHostModuleOpaque builds the module context of the host module instance mi, whose layout is described by
+// newHostModuleContextOffsetData. The globals are written by DoneInstantiation.
+func buildHostModuleOpaque(mi *wasm.ModuleInstance, listeners []experimental.FunctionListener) moduleContextOpaque {
+	m := mi.Source
+	size := len(m.CodeSection)*16 + 32 + len(m.GlobalSection)*16
 	ret := newAlignedOpaque(size)
 
-	binary.LittleEndian.PutUint64(ret[0:], uint64(uintptr(unsafe.Pointer(m))))
+	binary.LittleEndian.PutUint64(ret[0:], uint64(uintptr(unsafe.Pointer(mi))))
 
 	if len(listeners) > 0 {
 		//nolint:staticcheck
@@ -39,7 +69,7 @@ func hostModuleFromOpaque(opaqueBegin uintptr) *wasm.Module {
 	sh.Data = opaqueBegin
 	sh.Len = 32
 	sh.Cap = 32
-	return *(**wasm.Module)(unsafe.Pointer(&opaqueViewOverSlice[0]))
+	return (*(**wasm.ModuleInstance)(unsafe.Pointer(&opaqueViewOverSlice[0]))).Source
 }
 
 func hostModuleListenersSliceFromOpaque(opaqueBegin uintptr) []experimental.FunctionListener {
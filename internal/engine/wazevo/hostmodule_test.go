@@ -7,6 +7,7 @@ import (
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -54,7 +55,7 @@ func Test_buildHostModuleOpaque(t *testing.T) {
 	} {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			got := buildHostModuleOpaque(tc.m, tc.listeners)
+			got := buildHostModuleOpaque(&wasm.ModuleInstance{Source: tc.m}, tc.listeners)
 			opaque := uintptr(unsafe.Pointer(&got[0]))
 			require.Equal(t, tc.m, hostModuleFromOpaque(opaque))
 			if len(tc.listeners) > 0 {
@@ -66,3 +67,21 @@ func Test_buildHostModuleOpaque(t *testing.T) {
 		})
 	}
 }
+
+func Test_newHostModuleContextOffsetData(t *testing.T) {
+	m := &wasm.Module{
+		CodeSection:   []wasm.Code{{GoFunc: api.GoFunc(func(context.Context, []uint64) {})}},
+		GlobalSection: []wasm.Global{{}, {}},
+	}
+	offsets := newHostModuleContextOffsetData(m)
+	require.Equal(t, wazevoapi.Offset(48), offsets.GlobalsBegin)
+	require.Equal(t, wazevoapi.Offset(64), offsets.GlobalInstanceOffset(1))
+	require.Equal(t, 80, offsets.TotalSize)
+	require.Equal(t, wazevoapi.Offset(-1), offsets.LocalMemoryBegin)
+
+	opaque := buildHostModuleOpaque(&wasm.ModuleInstance{Source: m}, nil)
+	require.True(t, len(opaque) >= offsets.TotalSize)
+
+	offsets = newHostModuleContextOffsetData(&wasm.Module{})
+	require.Equal(t, wazevoapi.Offset(-1), offsets.GlobalsBegin)
+}
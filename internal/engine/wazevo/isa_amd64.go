@@ -7,6 +7,9 @@ import (
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/isa/amd64"
 )
 
+// simdSupported is true if the backend lowers the vector instructions.
+const simdSupported = true
+
 func newMachine() backend.Machine {
 	return amd64.NewBackend()
 }
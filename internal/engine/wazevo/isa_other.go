@@ -1,4 +1,4 @@
-//go:build !(amd64 || arm64)
+//go:build !(amd64 || arm64 || riscv64)
 
 package wazevo
 
@@ -6,6 +6,9 @@ import (
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
 )
 
+// simdSupported is true if the backend lowers the vector instructions.
+const simdSupported = false
+
 func newMachine() backend.Machine {
 	panic("unsupported architecture")
 }
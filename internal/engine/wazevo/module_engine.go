@@ -44,10 +44,8 @@ type (
 	//
 	// 	type moduleContextOpaque struct {
 	// 	    moduleInstance                            *wasm.ModuleInstance
-	// 	    localMemoryBufferPtr                      *byte                (optional)
-	// 	    localMemoryLength                         uint64               (optional)
-	// 	    importedMemoryInstance                    *wasm.MemoryInstance (optional)
-	// 	    importedMemoryOwnerOpaqueCtx              *byte                (optional)
+	// 	    localMemories                             [# of localMemories]localMemory       (optional)
+	// 	    importedMemories                          [# of importedMemories]importedMemory (optional)
 	// 	    importedFunctions                         [# of importedFunctions]functionInstance
 	//      importedGlobals                           []ImportedGlobal       (optional)
 	//      localGlobals                              []Global               (optional)
@@ -59,6 +57,16 @@ type (
 	//      elementInstances1stElement                []wasm.ElementInstance (optional)
 	// 	}
 	//
+	// 	type localMemory struct {
+	// 	    bufferPtr *byte
+	// 	    length    uint64
+	// 	}
+	//
+	// 	type importedMemory struct {
+	// 	    inst           *wasm.MemoryInstance
+	// 	    ownerOpaqueCtx *byte
+	// 	}
+	//
 	//  type ImportedGlobal struct {
 	// 		*Global
 	// 		_ uint64 // padding
@@ -106,7 +114,7 @@ func (m *moduleEngine) setupOpaque() {
 	if globalOffset := offsets.GlobalsBegin; globalOffset >= 0 {
 		for i, g := range inst.Globals {
 			if i < int(inst.Source.ImportGlobalCount) {
-				importedME := g.Me.(*moduleEngine)
+				importedME := moduleEngineOf(g.Me)
 				offset := importedME.parent.offsets.GlobalInstanceOffset(g.Index)
 				importedMEOpaque := importedME.opaque
 				binary.LittleEndian.PutUint64(opaque[globalOffset:],
@@ -119,12 +127,12 @@ func (m *moduleEngine) setupOpaque() {
 		}
 	}
 
-	if tableOffset := offsets.TablesBegin; tableOffset >= 0 {
-		// First we write the first element's address of typeIDs.
-		if len(inst.TypeIDs) > 0 {
-			binary.LittleEndian.PutUint64(opaque[offsets.TypeIDs1stElement:], uint64(uintptr(unsafe.Pointer(&inst.TypeIDs[0]))))
-		}
+	// First we write the first element's address of typeIDs.
+	if offsets.TypeIDs1stElement >= 0 && len(inst.TypeIDs) > 0 {
+		binary.LittleEndian.PutUint64(opaque[offsets.TypeIDs1stElement:], uint64(uintptr(unsafe.Pointer(&inst.TypeIDs[0]))))
+	}
 
+	if tableOffset := offsets.TablesBegin; tableOffset >= 0 {
 		// Then we write the table addresses.
 		for _, table := range inst.Tables {
 			binary.LittleEndian.PutUint64(opaque[tableOffset:], uint64(uintptr(unsafe.Pointer(table))))
@@ -146,6 +154,20 @@ func (m *moduleEngine) setupOpaque() {
 	}
 }
 
+// moduleEngineOf returns the *moduleEngine of the given wasm.ModuleEngine, which is either one or a
+// wasm.TieredModuleEngine delegating to one.
+func moduleEngineOf(me wasm.ModuleEngine) *moduleEngine {
+	if ret, ok := me.(*moduleEngine); ok {
+		return ret
+	}
+	for _, tier := range me.(wasm.TieredModuleEngine).ModuleEngines() {
+		if ret, ok := tier.(*moduleEngine); ok {
+			return ret
+		}
+	}
+	panic("BUG: the module doesn't run on the compiler")
+}
+
 // NewFunction implements wasm.ModuleEngine.
 func (m *moduleEngine) NewFunction(index wasm.Index) api.Function {
 	if wazevoapi.PrintMachineCodeHexPerFunctionDisassemblable {
@@ -188,6 +210,8 @@ func (m *moduleEngine) NewFunction(index wasm.Index) api.Function {
 	ce.execCtx.memoryWait32TrampolineAddress = &m.parent.sharedFunctions.memoryWait32Executable[0]
 	ce.execCtx.memoryWait64TrampolineAddress = &m.parent.sharedFunctions.memoryWait64Executable[0]
 	ce.execCtx.memoryNotifyTrampolineAddress = &m.parent.sharedFunctions.memoryNotifyExecutable[0]
+	ce.execCtx.exceptionTrampolineAddress = &m.parent.sharedFunctions.exceptionExecutable[0]
+	ce.execCtx.sampleTrampolineAddress = &m.parent.sharedFunctions.sampleExecutable[0]
 	ce.execCtx.memmoveAddress = memmovPtr
 	ce.init()
 	return ce
@@ -219,28 +243,42 @@ func (m *moduleEngine) OwnsGlobals() bool { return true }
 
 // MemoryGrown implements wasm.ModuleEngine.
 func (m *moduleEngine) MemoryGrown() {
+	if m.parent.offsets.LocalMemoryBegin < 0 {
+		// The module context doesn't cache the memory buffer, e.g. the one of a host module.
+		return
+	}
 	m.putLocalMemory()
 }
 
-// putLocalMemory writes the local memory buffer pointer and length to the opaque buffer.
+// putLocalMemory writes the local memory buffer pointers and lengths to the opaque buffer.
 func (m *moduleEngine) putLocalMemory() {
-	mem := m.module.MemoryInstance
-	offset := m.parent.offsets.LocalMemoryBegin
+	var importedCount, localCount wasm.Index = 0, 1
+	if src := m.module.Source; src != nil {
+		importedCount, localCount = src.ImportMemoryCount, wasm.Index(len(src.MemorySection))
+	}
+	for i := wasm.Index(0); i < localCount; i++ {
+		mem := m.module.MemoryInstance
+		if idx := importedCount + i; idx > 0 {
+			mem = m.module.Memories[idx]
+		}
+		offset := m.parent.offsets.LocalMemoryBase(i)
 
-	s := uint64(len(mem.Buffer))
-	var b uint64
-	if len(mem.Buffer) > 0 {
-		b = uint64(uintptr(unsafe.Pointer(&mem.Buffer[0])))
+		s := uint64(len(mem.Buffer))
+		var b uint64
+		if len(mem.Buffer) > 0 {
+			b = uint64(uintptr(unsafe.Pointer(&mem.Buffer[0])))
+		}
+		binary.LittleEndian.PutUint64(m.opaque[offset:], b)
+		binary.LittleEndian.PutUint64(m.opaque[offset+8:], s)
 	}
-	binary.LittleEndian.PutUint64(m.opaque[offset:], b)
-	binary.LittleEndian.PutUint64(m.opaque[offset+8:], s)
 }
 
 // ResolveImportedFunction implements wasm.ModuleEngine.
 func (m *moduleEngine) ResolveImportedFunction(index, descFunc, indexInImportedModule wasm.Index, importedModuleEngine wasm.ModuleEngine) {
 	executableOffset, moduleCtxOffset, typeIDOffset := m.parent.offsets.ImportedFunctionOffset(index)
-	importedME := importedModuleEngine.(*moduleEngine)
+	importedME := moduleEngineOf(importedModuleEngine)
 
+	indexInModule := indexInImportedModule
 	if int(indexInImportedModule) >= len(importedME.importedFunctions) {
 		indexInImportedModule -= wasm.Index(len(importedME.importedFunctions))
 	} else {
@@ -252,31 +290,36 @@ func (m *moduleEngine) ResolveImportedFunction(index, descFunc, indexInImportedM
 	offset := importedME.parent.functionOffsets[indexInImportedModule]
 	typeID := m.module.TypeIDs[descFunc]
 	executable := &importedME.parent.executable[offset]
-	// Write functionInstance.
+	// Write functionInstance, whose typeID and indexInModule share the last 8 bytes.
 	binary.LittleEndian.PutUint64(m.opaque[executableOffset:], uint64(uintptr(unsafe.Pointer(executable))))
 	binary.LittleEndian.PutUint64(m.opaque[moduleCtxOffset:], uint64(uintptr(unsafe.Pointer(importedME.opaquePtr))))
-	binary.LittleEndian.PutUint64(m.opaque[typeIDOffset:], uint64(typeID))
+	binary.LittleEndian.PutUint64(m.opaque[typeIDOffset:], uint64(typeID)|uint64(indexInModule)<<32)
 
 	// Write importedFunction so that it can be used by NewFunction.
-	m.importedFunctions[index] = importedFunction{me: importedME, indexInModule: indexInImportedModule}
+	m.importedFunctions[index] = importedFunction{me: importedME, indexInModule: indexInModule}
 }
 
 // ResolveImportedMemory implements wasm.ModuleEngine.
-func (m *moduleEngine) ResolveImportedMemory(importedModuleEngine wasm.ModuleEngine) {
-	importedME := importedModuleEngine.(*moduleEngine)
+func (m *moduleEngine) ResolveImportedMemory(index, indexInImportedModule wasm.Index, importedModuleEngine wasm.ModuleEngine) {
+	importedME := moduleEngineOf(importedModuleEngine)
 	inst := importedME.module
 
 	var memInstPtr uint64
 	var memOwnerOpaquePtr uint64
-	if offs := importedME.parent.offsets; offs.ImportedMemoryBegin >= 0 {
-		offset := offs.ImportedMemoryBegin
+	if offs := importedME.parent.offsets; offs.ImportedMemoryBegin >= 0 && indexInImportedModule < inst.Source.ImportMemoryCount {
+		// The memory is re-exported by the imported module, so copy its resolution.
+		offset := offs.ImportedMemoryOffset(indexInImportedModule)
 		memInstPtr = binary.LittleEndian.Uint64(importedME.opaque[offset:])
 		memOwnerOpaquePtr = binary.LittleEndian.Uint64(importedME.opaque[offset+8:])
 	} else {
-		memInstPtr = uint64(uintptr(unsafe.Pointer(inst.MemoryInstance)))
+		mem := inst.MemoryInstance
+		if indexInImportedModule > 0 {
+			mem = inst.Memories[indexInImportedModule]
+		}
+		memInstPtr = uint64(uintptr(unsafe.Pointer(mem)))
 		memOwnerOpaquePtr = uint64(uintptr(unsafe.Pointer(importedME.opaquePtr)))
 	}
-	offset := m.parent.offsets.ImportedMemoryBegin
+	offset := m.parent.offsets.ImportedMemoryOffset(index)
 	binary.LittleEndian.PutUint64(m.opaque[offset:], memInstPtr)
 	binary.LittleEndian.PutUint64(m.opaque[offset+8:], memOwnerOpaquePtr)
 }
@@ -285,9 +328,21 @@ func (m *moduleEngine) ResolveImportedMemory(importedModuleEngine wasm.ModuleEng
 func (m *moduleEngine) DoneInstantiation() {
 	if !m.module.Source.IsHostModule {
 		m.setupOpaque()
+	} else if globalOffset := m.parent.offsets.GlobalsBegin; globalOffset >= 0 {
+		// Host modules don't import globals.
+		for _, g := range m.module.Globals {
+			binary.LittleEndian.PutUint64(m.opaque[globalOffset:], g.Val)
+			binary.LittleEndian.PutUint64(m.opaque[globalOffset+8:], g.ValHi)
+			globalOffset += 16
+		}
 	}
 }
 
+// FunctionReference implements wasm.ModuleEngine.
+func (m *moduleEngine) FunctionReference(inst *wasm.ModuleInstance, funcIndex wasm.Index) (wasm.Reference, error) {
+	return moduleEngineOf(inst.Engine).FunctionInstanceReference(funcIndex), nil
+}
+
 // FunctionInstanceReference implements wasm.ModuleEngine.
 func (m *moduleEngine) FunctionInstanceReference(funcIndex wasm.Index) wasm.Reference {
 	if funcIndex < m.module.Source.ImportFunctionCount {
@@ -326,6 +381,12 @@ func (m *moduleEngine) LookupFunction(t *wasm.TableInstance, typeId wasm.Functio
 	return moduleInstanceFromOpaquePtr(tf.moduleContextOpaquePtr), tf.indexInModule
 }
 
+// LookupFunctionReference implements wasm.ModuleEngine.
+func (m *moduleEngine) LookupFunctionReference(ref wasm.Reference) (*wasm.ModuleInstance, wasm.Index) {
+	tf := wazevoapi.PtrFromUintptr[functionInstance](ref)
+	return moduleInstanceFromOpaquePtr(tf.moduleContextOpaquePtr), tf.indexInModule
+}
+
 func moduleInstanceFromOpaquePtr(ptr *byte) *wasm.ModuleInstance {
 	return *(**wasm.ModuleInstance)(unsafe.Pointer(ptr))
 }
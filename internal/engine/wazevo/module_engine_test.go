@@ -101,7 +101,7 @@ func TestModuleEngine_setupOpaque(t *testing.T) {
 					parent: &compiledModule{offsets: wazevoapi.ModuleContextOffsetData{ImportedMemoryBegin: -1}},
 				}
 				imported.opaquePtr = &imported.opaque[0]
-				m.ResolveImportedMemory(imported)
+				m.ResolveImportedMemory(0, 0, imported)
 
 				actualPtr := uintptr(binary.LittleEndian.Uint64(m.opaque[tc.offset.ImportedMemoryBegin:]))
 				expPtr := uintptr(unsafe.Pointer(tc.m.MemoryInstance))
@@ -192,26 +192,29 @@ func TestModuleEngine_ResolveImportedFunction(t *testing.T) {
 	m.ResolveImportedFunction(3, 5, 1, importing)
 
 	for i, tc := range []struct {
-		index      int
-		op         *byte
-		executable *byte
-		expTypeID  wasm.FunctionTypeID
+		index            int
+		op               *byte
+		executable       *byte
+		expTypeID        wasm.FunctionTypeID
+		expIndexInModule wasm.Index
 	}{
 		{index: 0, op: &op1, executable: &importing.parent.executable[1], expTypeID: 111},
 		{index: 1, op: &op2, executable: &imported.parent.executable[50], expTypeID: 888},
-		{index: 2, op: &op1, executable: &importing.parent.executable[10], expTypeID: 333},
-		{index: 3, op: &op1, executable: &importing.parent.executable[5], expTypeID: 222},
+		{index: 2, op: &op1, executable: &importing.parent.executable[10], expTypeID: 333, expIndexInModule: 2},
+		{index: 3, op: &op1, executable: &importing.parent.executable[5], expTypeID: 222, expIndexInModule: 1},
 	} {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			buf := m.opaque[begin+wazevoapi.FunctionInstanceSize*tc.index:]
 			actualExecutable := binary.LittleEndian.Uint64(buf)
 			actualOpaquePtr := binary.LittleEndian.Uint64(buf[8:])
-			actualTypeID := binary.LittleEndian.Uint64(buf[16:])
+			actualTypeID := binary.LittleEndian.Uint32(buf[16:])
+			actualIndexInModule := binary.LittleEndian.Uint32(buf[20:])
 			expExecutable := uint64(uintptr(unsafe.Pointer(tc.executable)))
 			expOpaquePtr := uint64(uintptr(unsafe.Pointer(tc.op)))
 			require.Equal(t, expExecutable, actualExecutable)
 			require.Equal(t, expOpaquePtr, actualOpaquePtr)
-			require.Equal(t, uint64(tc.expTypeID), actualTypeID)
+			require.Equal(t, uint32(tc.expTypeID), actualTypeID)
+			require.Equal(t, tc.expIndexInModule, actualIndexInModule)
 		})
 	}
 }
@@ -256,24 +259,27 @@ func TestModuleEngine_ResolveImportedFunction_recursive(t *testing.T) {
 	m.ResolveImportedFunction(1, 1, 1, importing)
 
 	for i, tc := range []struct {
-		index      int
-		op         *byte
-		executable *byte
-		expTypeID  wasm.FunctionTypeID
+		index            int
+		op               *byte
+		executable       *byte
+		expTypeID        wasm.FunctionTypeID
+		expIndexInModule wasm.Index
 	}{
 		{index: 0, op: &importedOp, executable: &imported.parent.executable[10], expTypeID: 999},
-		{index: 1, op: &importingOp, executable: &importing.parent.executable[500], expTypeID: 222},
+		{index: 1, op: &importingOp, executable: &importing.parent.executable[500], expTypeID: 222, expIndexInModule: 1},
 	} {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			buf := m.opaque[begin+wazevoapi.FunctionInstanceSize*tc.index:]
 			actualExecutable := binary.LittleEndian.Uint64(buf)
 			actualOpaquePtr := binary.LittleEndian.Uint64(buf[8:])
-			actualTypeID := binary.LittleEndian.Uint64(buf[16:])
+			actualTypeID := binary.LittleEndian.Uint32(buf[16:])
+			actualIndexInModule := binary.LittleEndian.Uint32(buf[20:])
 			expExecutable := uint64(uintptr(unsafe.Pointer(tc.executable)))
 			expOpaquePtr := uint64(uintptr(unsafe.Pointer(tc.op)))
 			require.Equal(t, expExecutable, actualExecutable)
 			require.Equal(t, expOpaquePtr, actualOpaquePtr)
-			require.Equal(t, uint64(tc.expTypeID), actualTypeID)
+			require.Equal(t, uint32(tc.expTypeID), actualTypeID)
+			require.Equal(t, tc.expIndexInModule, actualIndexInModule)
 		})
 	}
 }
@@ -290,16 +296,41 @@ func TestModuleEngine_ResolveImportedMemory_reexported(t *testing.T) {
 		parent: &compiledModule{offsets: wazevoapi.ModuleContextOffsetData{
 			ImportedMemoryBegin: 1000,
 		}},
+		module: &wasm.ModuleInstance{Source: &wasm.Module{ImportMemoryCount: 1}},
 		opaque: make([]byte, 2000),
 	}
 	binary.LittleEndian.PutUint64(importedME.opaque[1000:], 0x1234567890abcdef)
 	binary.LittleEndian.PutUint64(importedME.opaque[1000+8:], 0xabcdef1234567890)
 
-	m.ResolveImportedMemory(importedME)
+	m.ResolveImportedMemory(0, 0, importedME)
 	require.Equal(t, uint64(0x1234567890abcdef), binary.LittleEndian.Uint64(m.opaque[50:]))
 	require.Equal(t, uint64(0xabcdef1234567890), binary.LittleEndian.Uint64(m.opaque[50+8:]))
 }
 
+func TestModuleEngine_ResolveImportedMemory_index(t *testing.T) {
+	m := &moduleEngine{
+		parent: &compiledModule{offsets: wazevoapi.ModuleContextOffsetData{
+			ImportedMemoryBegin: 16,
+		}},
+		opaque: make([]byte, 100),
+	}
+
+	mem0, mem1 := &wasm.MemoryInstance{}, &wasm.MemoryInstance{}
+	importedME := &moduleEngine{
+		parent: &compiledModule{offsets: wazevoapi.ModuleContextOffsetData{ImportedMemoryBegin: -1}},
+		module: &wasm.ModuleInstance{MemoryInstance: mem0, Memories: []*wasm.MemoryInstance{mem0, mem1}, Source: &wasm.Module{}},
+		opaque: []byte{1, 2, 3},
+	}
+	importedME.opaquePtr = &importedME.opaque[0]
+
+	// Import the memory 1 of the imported module as the memory 1 of m.
+	m.ResolveImportedMemory(1, 1, importedME)
+	require.Equal(t, uint64(0), binary.LittleEndian.Uint64(m.opaque[16:]))
+	require.Equal(t, uint64(uintptr(unsafe.Pointer(mem1))), binary.LittleEndian.Uint64(m.opaque[16+16:]))
+	require.Equal(t, uint64(uintptr(unsafe.Pointer(importedME.opaquePtr))), binary.LittleEndian.Uint64(m.opaque[16+24:]))
+	runtime.KeepAlive(importedME)
+}
+
 func Test_functionInstance_offsets(t *testing.T) {
 	var fi functionInstance
 	require.Equal(t, wazevoapi.FunctionInstanceSize, int(unsafe.Sizeof(fi)))
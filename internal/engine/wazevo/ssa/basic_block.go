@@ -228,6 +228,31 @@ func (bb *basicBlock) insertInstruction(b *builder, next *Instruction) {
 	}
 }
 
+// insertInstructionBefore inserts the instruction before the given instruction in this block.
+func (bb *basicBlock) insertInstructionBefore(instr, before *Instruction) {
+	instr.prev, instr.next = before.prev, before
+	if prev := before.prev; prev != nil {
+		prev.next = instr
+	} else {
+		bb.rootInstr = instr
+	}
+	before.prev = instr
+}
+
+// removeInstruction removes the instruction from this block.
+func (bb *basicBlock) removeInstruction(instr *Instruction) {
+	if prev := instr.prev; prev != nil {
+		prev.next = instr.next
+	} else {
+		bb.rootInstr = instr.next
+	}
+	if next := instr.next; next != nil {
+		next.prev = instr.prev
+	} else {
+		bb.currentInstr = instr.prev
+	}
+}
+
 // NumPreds implements BasicBlock.NumPreds.
 func (bb *basicBlock) NumPreds() int {
 	return len(bb.preds)
@@ -189,6 +189,12 @@ type builder struct {
 	blkStack        []*basicBlock
 	blkStack2       []*basicBlock
 	redundantParams []redundantParam
+	cseEntries      map[cseKey]cseEntry
+	// valueDefinitionBlocks maps the ValueID to the block where the value is defined, used in passLoopInvariantCodeMotionOpt.
+	valueDefinitionBlocks []*basicBlock
+	// blockParamOwners maps the ValueID of block parameters to their owners, used in passDeadCodeEliminationOpt.
+	blockParamOwners []blockParamOwner
+	liveParamsStack  []Value
 
 	// blockIterCur is used to implement blockIteratorBegin and blockIteratorNext.
 	blockIterCur int
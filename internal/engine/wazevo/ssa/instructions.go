@@ -217,6 +217,16 @@ const (
 	// Note that this is different from call_indirect in Wasm, which also does type checking, etc.
 	OpcodeCallIndirect
 
+	// OpcodeTailCallReturnCall is the tail call variant of OpcodeCall: `returnvals = TailCallReturnCall FN, args...`
+	// This must be immediately followed by OpcodeReturn of the `returnvals`, so that backends can replace the current
+	// frame with the callee's one. Otherwise, this is lowered as OpcodeCall followed by OpcodeReturn.
+	OpcodeTailCallReturnCall
+
+	// OpcodeTailCallReturnCallIndirect is the tail call variant of OpcodeCallIndirect:
+	// `returnvals = TailCallReturnCallIndirect SIG, callee, args`.
+	// The same restriction as OpcodeTailCallReturnCall applies.
+	OpcodeTailCallReturnCallIndirect
+
 	// OpcodeSplat performs a vector splat operation: `v = Splat.lane x`.
 	OpcodeSplat
 
@@ -687,6 +697,23 @@ var (
 	returnTypesFnV128                    = func(b *builder, instr *Instruction) (t1 Type, ts []Type) { return TypeV128, nil }
 )
 
+// signatureResultTypes returns the result types of the given signature as returnTypesFn does.
+func signatureResultTypes(b *builder, sigID SignatureID) (t1 Type, ts []Type) {
+	sig, ok := b.signatures[sigID]
+	if !ok {
+		panic("BUG")
+	}
+	switch len(sig.Results) {
+	case 0:
+		t1 = typeInvalid
+	case 1:
+		t1 = sig.Results[0]
+	default:
+		t1, ts = sig.Results[0], sig.Results[1:]
+	}
+	return
+}
+
 // sideEffect provides the info to determine if an instruction has side effects which
 // is used to determine if it can be optimized out, interchanged with others, etc.
 type sideEffect byte
@@ -709,6 +736,8 @@ var instructionSideEffects = [opcodeEnd]sideEffect{
 	OpcodeIconst:                      sideEffectNone,
 	OpcodeCall:                        sideEffectStrict,
 	OpcodeCallIndirect:                sideEffectStrict,
+	OpcodeTailCallReturnCall:          sideEffectStrict,
+	OpcodeTailCallReturnCallIndirect:  sideEffectStrict,
 	OpcodeIadd:                        sideEffectNone,
 	OpcodeImul:                        sideEffectNone,
 	OpcodeIsub:                        sideEffectNone,
@@ -928,36 +957,16 @@ var instructionReturnTypes = [opcodeEnd]returnTypesFn{
 	OpcodeTrunc:           returnTypesFnSingle,
 	OpcodeNearest:         returnTypesFnSingle,
 	OpcodeCallIndirect: func(b *builder, instr *Instruction) (t1 Type, ts []Type) {
-		sigID := SignatureID(instr.u1)
-		sig, ok := b.signatures[sigID]
-		if !ok {
-			panic("BUG")
-		}
-		switch len(sig.Results) {
-		case 0:
-			t1 = typeInvalid
-		case 1:
-			t1 = sig.Results[0]
-		default:
-			t1, ts = sig.Results[0], sig.Results[1:]
-		}
-		return
+		return signatureResultTypes(b, SignatureID(instr.u1))
 	},
 	OpcodeCall: func(b *builder, instr *Instruction) (t1 Type, ts []Type) {
-		sigID := SignatureID(instr.u2)
-		sig, ok := b.signatures[sigID]
-		if !ok {
-			panic("BUG")
-		}
-		switch len(sig.Results) {
-		case 0:
-			t1 = typeInvalid
-		case 1:
-			t1 = sig.Results[0]
-		default:
-			t1, ts = sig.Results[0], sig.Results[1:]
-		}
-		return
+		return signatureResultTypes(b, SignatureID(instr.u2))
+	},
+	OpcodeTailCallReturnCallIndirect: func(b *builder, instr *Instruction) (t1 Type, ts []Type) {
+		return signatureResultTypes(b, SignatureID(instr.u1))
+	},
+	OpcodeTailCallReturnCall: func(b *builder, instr *Instruction) (t1 Type, ts []Type) {
+		return signatureResultTypes(b, SignatureID(instr.u2))
 	},
 	OpcodeLoad:                        returnTypesFnSingle,
 	OpcodeVZeroExtLoad:                returnTypesFnV128,
@@ -2164,10 +2173,16 @@ func (i *Instruction) AsCall(ref FuncRef, sig *Signature, args Values) {
 	sig.used = true
 }
 
+// AsTailCallReturnCall initializes this instruction as a tail call instruction with OpcodeTailCallReturnCall.
+func (i *Instruction) AsTailCallReturnCall(ref FuncRef, sig *Signature, args Values) {
+	i.AsCall(ref, sig, args)
+	i.opcode = OpcodeTailCallReturnCall
+}
+
 // CallData returns the call data for this instruction necessary for backends.
 func (i *Instruction) CallData() (ref FuncRef, sigID SignatureID, args []Value) {
-	if i.opcode != OpcodeCall {
-		panic("BUG: CallData only available for OpcodeCall")
+	if i.opcode != OpcodeCall && i.opcode != OpcodeTailCallReturnCall {
+		panic("BUG: CallData only available for OpcodeCall and OpcodeTailCallReturnCall")
 	}
 	ref = FuncRef(i.u1)
 	sigID = SignatureID(i.u2)
@@ -2193,10 +2208,17 @@ func (i *Instruction) AsCallGoRuntimeMemmove(funcPtr Value, sig *Signature, args
 	return i
 }
 
+// AsTailCallReturnCallIndirect initializes this instruction as a tail call instruction with OpcodeTailCallReturnCallIndirect.
+func (i *Instruction) AsTailCallReturnCallIndirect(funcPtr Value, sig *Signature, args Values) *Instruction {
+	i.AsCallIndirect(funcPtr, sig, args)
+	i.opcode = OpcodeTailCallReturnCallIndirect
+	return i
+}
+
 // CallIndirectData returns the call indirect data for this instruction necessary for backends.
 func (i *Instruction) CallIndirectData() (funcPtr Value, sigID SignatureID, args []Value, isGoMemmove bool) {
-	if i.opcode != OpcodeCallIndirect {
-		panic("BUG: CallIndirectData only available for OpcodeCallIndirect")
+	if i.opcode != OpcodeCallIndirect && i.opcode != OpcodeTailCallReturnCallIndirect {
+		panic("BUG: CallIndirectData only available for OpcodeCallIndirect and OpcodeTailCallReturnCallIndirect")
 	}
 	funcPtr = i.v
 	sigID = SignatureID(i.u1)
@@ -2487,13 +2509,13 @@ func (i *Instruction) Format(b Builder) string {
 		instSuffix = fmt.Sprintf(" %s, %s, %s", FloatCmpCond(i.u1), i.v.Format(b), i.v2.Format(b))
 	case OpcodeSExtend, OpcodeUExtend:
 		instSuffix = fmt.Sprintf(" %s, %d->%d", i.v.Format(b), i.u1>>8, i.u1&0xff)
-	case OpcodeCall, OpcodeCallIndirect:
+	case OpcodeCall, OpcodeCallIndirect, OpcodeTailCallReturnCall, OpcodeTailCallReturnCallIndirect:
 		view := i.vs.View()
 		vs := make([]string, len(view))
 		for idx := range vs {
 			vs[idx] = view[idx].Format(b)
 		}
-		if i.opcode == OpcodeCallIndirect {
+		if i.opcode == OpcodeCallIndirect || i.opcode == OpcodeTailCallReturnCallIndirect {
 			instSuffix = fmt.Sprintf(" %s:%s, %s", i.v.Format(b), SignatureID(i.u1), strings.Join(vs, ", "))
 		} else {
 			instSuffix = fmt.Sprintf(" %s:%s, %s", FuncRef(i.u1), SignatureID(i.u2), strings.Join(vs, ", "))
@@ -2701,6 +2723,10 @@ func (o Opcode) String() (ret string) {
 		return "Call"
 	case OpcodeCallIndirect:
 		return "CallIndirect"
+	case OpcodeTailCallReturnCall:
+		return "TailCallReturnCall"
+	case OpcodeTailCallReturnCallIndirect:
+		return "TailCallReturnCallIndirect"
 	case OpcodeSplat:
 		return "Splat"
 	case OpcodeSwizzle:
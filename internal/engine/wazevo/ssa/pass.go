@@ -22,6 +22,8 @@ func (b *builder) RunPasses() {
 func (b *builder) runPreBlockLayoutPasses() {
 	passSortSuccessors(b)
 	passDeadBlockEliminationOpt(b)
+	// Coalescing modifies the CFG, so this must be done before the dominators are calculated.
+	passBlockCoalescingOpt(b)
 	// The result of passCalculateImmediateDominators will be used by various passes below.
 	passCalculateImmediateDominators(b)
 	passRedundantPhiEliminationOpt(b)
@@ -31,13 +33,11 @@ func (b *builder) runPreBlockLayoutPasses() {
 	// 	WebAssembly program shouldn't result in irreducible CFG, but we should handle it properly in just in case.
 	// 	See FixIrreducible pass in LLVM: https://llvm.org/doxygen/FixIrreducible_8cpp_source.html
 
-	// TODO: implement more optimization passes like:
-	// 	block coalescing.
-	// 	Copy-propagation.
-	// 	Constant folding.
-	// 	Common subexpression elimination.
-	// 	Arithmetic simplifications.
-	// 	and more!
+	passCopyPropagationOpt(b)
+	passConstFoldingOpt(b)
+	// Constant folding makes more instructions identical, and CSE makes more instructions loop invariant.
+	passCommonSubexpressionEliminationOpt(b)
+	passLoopInvariantCodeMotionOpt(b)
 
 	// passDeadCodeEliminationOpt could be more accurate if we do this after other optimizations.
 	passDeadCodeEliminationOpt(b)
@@ -108,6 +108,62 @@ func passDeadBlockEliminationOpt(b *builder) {
 	}
 }
 
+// passBlockCoalescingOpt merges each block into its predecessor if the predecessor unconditionally jumps to it and it
+// has no other predecessors. This results in larger blocks for the following passes like
+// passCommonSubexpressionEliminationOpt, which are limited by the block boundaries in various ways.
+func passBlockCoalescingOpt(b *builder) {
+	for blk := b.blockIteratorBegin(); blk != nil; blk = b.blockIteratorNext() {
+		// Merging a block might result in another merge-able jump at the end, so repeat until none.
+		for {
+			tail := blk.currentInstr
+			if tail == nil || tail.opcode != OpcodeJump || len(blk.success) != 1 {
+				break
+			}
+			succ := blk.success[0]
+			if succ == blk || succ.ReturnBlock() || len(succ.preds) != 1 {
+				break
+			}
+
+			// The arguments of the jump become the values of the parameters.
+			args := tail.vs.View()
+			for i, param := range succ.params.View() {
+				b.alias(param, args[i])
+			}
+
+			// Replace the jump with the instructions of the successor.
+			blk.currentInstr = tail.prev
+			if blk.currentInstr == nil {
+				blk.rootInstr = succ.rootInstr
+			} else {
+				blk.currentInstr.next = succ.rootInstr
+				if succ.rootInstr != nil {
+					succ.rootInstr.prev = blk.currentInstr
+				}
+			}
+			if succ.currentInstr != nil {
+				blk.currentInstr = succ.currentInstr
+			}
+
+			// The successors of the merged block are now the ones of this block.
+			blk.success = append(blk.success[:0], succ.success...)
+			for _, next := range succ.success {
+				for i := range next.preds {
+					if pred := &next.preds[i]; pred.blk == succ {
+						pred.blk = blk
+					}
+				}
+				if next.singlePred == succ {
+					next.singlePred = blk
+				}
+			}
+
+			succ.rootInstr, succ.currentInstr = nil, nil
+			succ.success = succ.success[:0]
+			succ.invalid = true
+		}
+	}
+}
+
 // passRedundantPhiEliminationOpt eliminates the redundant PHIs (in our terminology, parameters of a block).
 // This requires the reverse post-order traversal to be calculated before calling this function,
 // hence passCalculateImmediateDominators must be called before this.
@@ -227,7 +283,8 @@ func passRedundantPhiEliminationOpt(b *builder) {
 }
 
 // passDeadCodeEliminationOpt traverses all the instructions, and calculates the reference count of each Value, and
-// eliminates all the unnecessary instructions whose ref count is zero.
+// eliminates all the unnecessary instructions whose ref count is zero, as well as the block parameters which are only
+// passed around between the blocks without being used, e.g. the memory length reloaded in a loop but never used.
 // The results are stored at builder.valueRefCounts. This also assigns a InstructionGroupID to each Instruction
 // during the process. This is the last SSA-level optimization pass and after this,
 // the SSA function is ready to be used by backends.
@@ -244,8 +301,36 @@ func passDeadCodeEliminationOpt(b *builder) {
 		}
 	}
 
+	// Record the owners of the block parameters. The ones of the entry block are the parameters of the function,
+	// and the ones of the return block are the results, so they are always alive and not recorded here.
+	paramOwners := b.blockParamOwners
+	if len(paramOwners) < nvid {
+		paramOwners = make([]blockParamOwner, nvid)
+	} else {
+		clear(paramOwners)
+	}
+	for blk := b.blockIteratorBegin(); blk != nil; blk = b.blockIteratorNext() {
+		if blk.EntryBlock() {
+			continue
+		}
+		for i, param := range blk.params.View() {
+			paramOwners[param.ID()] = blockParamOwner{blk: blk, index: i}
+		}
+	}
+	b.blockParamOwners = paramOwners
+
 	// First, we gather all the instructions with side effects.
 	liveInstructions := b.instStack[:0]
+	liveParams := b.liveParamsStack[:0]
+	// markLive marks the value as alive, which is either the result of an instruction or a block parameter.
+	markLive := func(v Value) {
+		if producingInst := b.InstructionOfValue(v); producingInst != nil {
+			liveInstructions = append(liveInstructions, producingInst)
+		} else if owner := &paramOwners[v.ID()]; owner.blk != nil && !owner.live {
+			owner.live = true
+			liveParams = append(liveParams, v)
+		}
+	}
 	// During the process, we will assign InstructionGroupID to each instruction, which is not
 	// relevant to dead code elimination, but we need in the backend.
 	var gid InstructionGroupID
@@ -264,8 +349,20 @@ func passDeadCodeEliminationOpt(b *builder) {
 		}
 	}
 
-	// Find all the instructions referenced by live instructions transitively.
-	for len(liveInstructions) > 0 {
+	// Find all the instructions and block parameters referenced by live instructions transitively.
+	for len(liveInstructions) > 0 || len(liveParams) > 0 {
+		if tail := len(liveParams) - 1; tail >= 0 {
+			// The arguments passed to the live parameter are alive.
+			owner := &paramOwners[liveParams[tail].ID()]
+			liveParams = liveParams[:tail]
+			for i := range owner.blk.preds {
+				branch := owner.blk.preds[i].branch
+				b.resolveArgumentAlias(branch)
+				markLive(branch.vs.View()[owner.index])
+			}
+			continue
+		}
+
 		tail := len(liveInstructions) - 1
 		live := liveInstructions[tail]
 		liveInstructions = liveInstructions[:tail]
@@ -281,32 +378,42 @@ func passDeadCodeEliminationOpt(b *builder) {
 
 		v1, v2, v3, vs := live.Args()
 		if v1.Valid() {
-			producingInst := b.InstructionOfValue(v1)
-			if producingInst != nil {
-				liveInstructions = append(liveInstructions, producingInst)
-			}
+			markLive(v1)
 		}
-
 		if v2.Valid() {
-			producingInst := b.InstructionOfValue(v2)
-			if producingInst != nil {
-				liveInstructions = append(liveInstructions, producingInst)
-			}
+			markLive(v2)
 		}
-
 		if v3.Valid() {
-			producingInst := b.InstructionOfValue(v3)
-			if producingInst != nil {
-				liveInstructions = append(liveInstructions, producingInst)
-			}
+			markLive(v3)
 		}
 
+		if isBranchWithArgs(live) && BasicBlockID(live.rValue) != basicBlockIDReturnBlock {
+			// The arguments of branches are alive only if the corresponding parameters are alive.
+			continue
+		}
 		for _, v := range vs {
-			producingInst := b.InstructionOfValue(v)
-			if producingInst != nil {
-				liveInstructions = append(liveInstructions, producingInst)
+			markLive(v)
+		}
+	}
+
+	// Remove the dead parameters and the corresponding arguments.
+	for blk := b.blockIteratorBegin(); blk != nil; blk = b.blockIteratorNext() {
+		if blk.EntryBlock() {
+			continue
+		}
+		params := blk.params.View()
+		for i := len(params) - 1; i >= 0; i-- {
+			if paramOwners[params[i].ID()].live {
+				continue
+			}
+			params = append(params[:i], params[i+1:]...)
+			for j := range blk.preds {
+				branch := blk.preds[j].branch
+				args := branch.vs.View()
+				branch.vs.Cut(len(append(args[:i], args[i+1:]...)))
 			}
 		}
+		blk.params.Cut(len(params))
 	}
 
 	// Now that all the live instructions are flagged as live=true, we eliminate all dead instructions.
@@ -344,6 +451,23 @@ func passDeadCodeEliminationOpt(b *builder) {
 	}
 
 	b.instStack = liveInstructions // we reuse the stack for the next iteration.
+	b.liveParamsStack = liveParams
+}
+
+// blockParamOwner is the block and the index of a block parameter, used in passDeadCodeEliminationOpt.
+type blockParamOwner struct {
+	blk   *basicBlock
+	index int
+	live  bool
+}
+
+// isBranchWithArgs returns true if the instruction is a branch whose arguments are passed to the target block.
+func isBranchWithArgs(instr *Instruction) bool {
+	switch instr.opcode {
+	case OpcodeJump, OpcodeBrz, OpcodeBrnz:
+		return true
+	}
+	return false
 }
 
 func (b *builder) incRefCount(id ValueID, from *Instruction) {
@@ -360,7 +484,7 @@ func passNopInstElimination(b *builder) {
 		for cur := blk.rootInstr; cur != nil; cur = cur.next {
 			switch cur.Opcode() {
 			// TODO: add more logics here.
-			case OpcodeIshl, OpcodeSshr, OpcodeUshr:
+			case OpcodeIshl, OpcodeSshr, OpcodeUshr, OpcodeRotl, OpcodeRotr:
 				x, amount := cur.Arg2()
 				definingInst := b.InstructionOfValue(amount)
 				if definingInst == nil {
@@ -384,6 +508,22 @@ func passNopInstElimination(b *builder) {
 	}
 }
 
+// passCopyPropagationOpt replaces the uses of copies with their sources. In our SSA, copies are the aliases created by
+// the previous passes, and the instructions which trivially result in one of their arguments. Resolving all of them
+// beforehand allows the following passes to compare the values as is.
+func passCopyPropagationOpt(b *builder) {
+	for blk := b.blockIteratorReversePostOrderBegin(); blk != nil; blk = b.blockIteratorReversePostOrderNext() {
+		for cur := blk.rootInstr; cur != nil; cur = cur.next {
+			b.resolveArgumentAlias(cur)
+			if cur.opcode == OpcodeSelect {
+				if _, x, y := cur.SelectData(); x == y {
+					b.alias(cur.Return(), x)
+				}
+			}
+		}
+	}
+}
+
 // passSortSuccessors sorts the successors of each block in the natural program order.
 func passSortSuccessors(b *builder) {
 	for i := 0; i < b.basicBlocksPool.Allocated(); i++ {
@@ -69,6 +69,8 @@ func passCalculateImmediateDominators(b *builder) {
 
 	for i, blk := range reversePostOrder {
 		blk.reversePostOrder = int32(i)
+		// Reset the state for the following passes.
+		blk.visited = 0
 	}
 
 	// Reuse the dominators slice if possible from the previous computation of function.
@@ -0,0 +1,334 @@
+package ssa
+
+import (
+	"math/bits"
+)
+
+// passConstFoldingOpt evaluates the integer instructions whose arguments are all constants, and replaces them with the
+// constant results in place. This also does the arithmetic simplifications, e.g. `x + 0` becomes `x`, so that the
+// results of the folding are propagated as far as possible in a single pass.
+//
+// Floating point instructions are not folded since the results might depend on the hardware, e.g. NaN bits.
+//
+// This requires the reverse post-order traversal to be calculated, hence passCalculateImmediateDominators must be
+// called before this. Since the arguments are defined before their uses in reverse post-order except via the block
+// parameters, all the foldable arguments are folded before their uses.
+func passConstFoldingOpt(b *builder) {
+	for blk := b.blockIteratorReversePostOrderBegin(); blk != nil; blk = b.blockIteratorReversePostOrderNext() {
+		for cur := blk.rootInstr; cur != nil; cur = cur.next {
+			b.resolveArgumentAlias(cur)
+			switch cur.opcode {
+			case OpcodeIadd, OpcodeIsub, OpcodeImul, OpcodeBand, OpcodeBor, OpcodeBxor,
+				OpcodeIshl, OpcodeUshr, OpcodeSshr, OpcodeRotl, OpcodeRotr:
+				x, y := cur.Arg2()
+				xc, xok := b.constantValue(x)
+				yc, yok := b.constantValue(y)
+				if xok && yok {
+					cur.replaceWithIconst(foldIntegerBinaryOp(cur.opcode, x.Type() == TypeI64, xc, yc))
+					continue
+				}
+				b.simplifyArithmetic(blk, cur)
+			case OpcodeUdiv, OpcodeUrem:
+				// Divisions by constants are never folded as the divisions by zero must trap, but can be simplified.
+				b.simplifyArithmetic(blk, cur)
+			case OpcodeIcmp:
+				x, y, c := cur.IcmpData()
+				xc, xok := b.constantValue(x)
+				yc, yok := b.constantValue(y)
+				if xok && yok {
+					cur.replaceWithIconst(foldIcmp(c, x.Type() == TypeI64, xc, yc))
+				} else if x == y {
+					cur.replaceWithIconst(foldIcmp(c, false, 0, 0))
+				}
+			case OpcodeClz, OpcodeCtz, OpcodePopcnt:
+				x := cur.Arg()
+				if xc, ok := b.constantValue(x); ok {
+					cur.replaceWithIconst(foldIntegerUnaryOp(cur.opcode, x.Type() == TypeI64, xc))
+				}
+			case OpcodeUExtend, OpcodeSExtend:
+				if xc, ok := b.constantValue(cur.Arg()); ok {
+					from, to, signed := cur.ExtendData()
+					if signed {
+						xc = uint64(int64(xc<<(64-from)) >> (64 - from))
+					} else {
+						xc &= 1<<from - 1
+					}
+					if to == 32 {
+						xc = uint64(uint32(xc))
+					}
+					cur.replaceWithIconst(xc)
+				}
+			case OpcodeIreduce:
+				if xc, ok := b.constantValue(cur.Arg()); ok {
+					cur.replaceWithIconst(uint64(uint32(xc)))
+				}
+			case OpcodeSelect:
+				c, x, y := cur.SelectData()
+				if cc, ok := b.constantValue(c); ok {
+					if cc != 0 {
+						b.alias(cur.Return(), x)
+					} else {
+						b.alias(cur.Return(), y)
+					}
+				}
+			case OpcodeExitIfTrueWithCode:
+				ctx, c, _ := cur.ExitIfTrueWithCodeData()
+				cc, ok := b.constantValue(c)
+				if !ok {
+					continue
+				}
+				if cc == 0 {
+					// Never exits, so this can be removed.
+					blk.removeInstruction(cur)
+				} else {
+					// Always exits. The condition is replaced with a comparison as the backends require it to be the
+					// one. This is rare, e.g. when dividing by the constant zero.
+					icmp := b.AllocateInstruction()
+					icmp.AsIcmp(ctx, ctx, IntegerCmpCondEqual)
+					cur.v2 = b.insertNewInstructionBefore(blk, icmp, cur)
+				}
+			}
+		}
+	}
+}
+
+// simplifyArithmetic simplifies the arithmetic instruction whose arguments are not all constants.
+func (b *builder) simplifyArithmetic(blk *basicBlock, cur *Instruction) {
+	x, y := cur.Arg2()
+	switch cur.opcode {
+	case OpcodeIadd, OpcodeImul, OpcodeBand, OpcodeBor, OpcodeBxor:
+		// These are commutative, so ensure the constant, if any, is the second argument.
+		if _, ok := b.constantValue(x); ok {
+			x, y = y, x
+			cur.v, cur.v2 = x, y
+		}
+	}
+
+	allOnes := uint64(1)<<x.Type().Bits() - 1 // Overflows to all ones for 64-bit.
+	yc, yok := b.constantValue(y)
+	switch cur.opcode {
+	case OpcodeIadd:
+		if yok && yc == 0 {
+			b.alias(cur.Return(), x)
+		}
+	case OpcodeIsub:
+		if yok && yc == 0 {
+			b.alias(cur.Return(), x)
+		} else if x == y {
+			cur.replaceWithIconst(0)
+		}
+	case OpcodeImul:
+		if !yok {
+			return
+		}
+		switch {
+		case yc == 0:
+			b.alias(cur.Return(), y)
+		case yc == 1:
+			b.alias(cur.Return(), x)
+		case bits.OnesCount64(yc) == 1:
+			// Multiplication by a power of two is a left shift.
+			amount := b.insertIconstBefore(blk, x.Type(), uint64(bits.TrailingZeros64(yc)), cur)
+			cur.AsIshl(x, amount)
+		}
+	case OpcodeBand:
+		switch {
+		case yok && yc == 0:
+			b.alias(cur.Return(), y)
+		case yok && yc == allOnes, x == y:
+			b.alias(cur.Return(), x)
+		}
+	case OpcodeBor:
+		switch {
+		case yok && yc == 0, x == y:
+			b.alias(cur.Return(), x)
+		case yok && yc == allOnes:
+			b.alias(cur.Return(), y)
+		}
+	case OpcodeBxor:
+		if yok && yc == 0 {
+			b.alias(cur.Return(), x)
+		} else if x == y {
+			cur.replaceWithIconst(0)
+		}
+	case OpcodeUdiv, OpcodeUrem:
+		// Only the divisions by powers of two are simplified, which never trap.
+		if !yok || bits.OnesCount64(yc) != 1 {
+			return
+		}
+		if cur.opcode == OpcodeUdiv {
+			if yc == 1 {
+				// The division itself must be removed as it is never eliminated otherwise.
+				b.alias(cur.Return(), x)
+				blk.removeInstruction(cur)
+				return
+			}
+			amount := b.insertIconstBefore(blk, x.Type(), uint64(bits.TrailingZeros64(yc)), cur)
+			cur.AsUshr(x, amount)
+		} else {
+			if yc == 1 {
+				cur.replaceWithIconst(0)
+				return
+			}
+			mask := b.insertIconstBefore(blk, x.Type(), yc-1, cur)
+			cur.AsBand(x, mask)
+		}
+		// The execution context argument is no longer needed.
+		cur.v3 = ValueInvalid
+	}
+}
+
+// constantValue returns the value of the integer constant if the given Value is defined by OpcodeIconst.
+func (b *builder) constantValue(v Value) (uint64, bool) {
+	instr := b.InstructionOfValue(v)
+	if instr == nil || instr.opcode != OpcodeIconst {
+		return 0, false
+	}
+	return instr.u1, true
+}
+
+// insertIconstBefore inserts a new integer constant instruction before the given instruction in the block.
+func (b *builder) insertIconstBefore(blk *basicBlock, typ Type, v uint64, before *Instruction) Value {
+	instr := b.AllocateInstruction()
+	if typ == TypeI64 {
+		instr.AsIconst64(v)
+	} else {
+		instr.AsIconst32(uint32(v))
+	}
+	return b.insertNewInstructionBefore(blk, instr, before)
+}
+
+// insertNewInstructionBefore inserts the newly allocated instruction before the given instruction in the block, and
+// returns its result. This must only be used for the instructions with a single result.
+func (b *builder) insertNewInstructionBefore(blk *basicBlock, instr, before *Instruction) Value {
+	t, _ := instructionReturnTypes[instr.opcode](b, instr)
+	instr.rValue = b.allocateValue(t).setInstructionID(instr.id)
+	blk.insertInstructionBefore(instr, before)
+	return instr.rValue
+}
+
+// replaceWithIconst replaces this instruction with the integer constant of the same type as its result.
+func (i *Instruction) replaceWithIconst(v uint64) {
+	if i.Return().Type() == TypeI64 {
+		i.AsIconst64(v)
+	} else {
+		i.AsIconst32(uint32(v))
+	}
+	i.v, i.v2, i.v3 = ValueInvalid, ValueInvalid, ValueInvalid
+}
+
+// foldIntegerBinaryOp returns the result of the binary integer operation on the constants.
+func foldIntegerBinaryOp(op Opcode, is64 bool, x, y uint64) uint64 {
+	if is64 {
+		switch op {
+		case OpcodeIadd:
+			return x + y
+		case OpcodeIsub:
+			return x - y
+		case OpcodeImul:
+			return x * y
+		case OpcodeBand:
+			return x & y
+		case OpcodeBor:
+			return x | y
+		case OpcodeBxor:
+			return x ^ y
+		case OpcodeIshl:
+			return x << (y % 64)
+		case OpcodeUshr:
+			return x >> (y % 64)
+		case OpcodeSshr:
+			return uint64(int64(x) >> (y % 64))
+		case OpcodeRotl:
+			return bits.RotateLeft64(x, int(y%64))
+		case OpcodeRotr:
+			return bits.RotateLeft64(x, -int(y%64))
+		}
+	} else {
+		x32, y32 := uint32(x), uint32(y)
+		switch op {
+		case OpcodeIadd:
+			return uint64(x32 + y32)
+		case OpcodeIsub:
+			return uint64(x32 - y32)
+		case OpcodeImul:
+			return uint64(x32 * y32)
+		case OpcodeBand:
+			return uint64(x32 & y32)
+		case OpcodeBor:
+			return uint64(x32 | y32)
+		case OpcodeBxor:
+			return uint64(x32 ^ y32)
+		case OpcodeIshl:
+			return uint64(x32 << (y32 % 32))
+		case OpcodeUshr:
+			return uint64(x32 >> (y32 % 32))
+		case OpcodeSshr:
+			return uint64(uint32(int32(x32) >> (y32 % 32)))
+		case OpcodeRotl:
+			return uint64(bits.RotateLeft32(x32, int(y32%32)))
+		case OpcodeRotr:
+			return uint64(bits.RotateLeft32(x32, -int(y32%32)))
+		}
+	}
+	panic("BUG: unsupported opcode for folding: " + op.String())
+}
+
+// foldIntegerUnaryOp returns the result of the unary integer operation on the constant.
+func foldIntegerUnaryOp(op Opcode, is64 bool, x uint64) uint64 {
+	switch op {
+	case OpcodeClz:
+		if is64 {
+			return uint64(bits.LeadingZeros64(x))
+		}
+		return uint64(bits.LeadingZeros32(uint32(x)))
+	case OpcodeCtz:
+		if is64 {
+			return uint64(bits.TrailingZeros64(x))
+		}
+		return uint64(bits.TrailingZeros32(uint32(x)))
+	case OpcodePopcnt:
+		if is64 {
+			return uint64(bits.OnesCount64(x))
+		}
+		return uint64(bits.OnesCount32(uint32(x)))
+	}
+	panic("BUG: unsupported opcode for folding: " + op.String())
+}
+
+// foldIcmp returns the result of the integer comparison on the constants.
+func foldIcmp(c IntegerCmpCond, is64 bool, x, y uint64) uint64 {
+	sx, sy := int64(x), int64(y)
+	if !is64 {
+		sx, sy = int64(int32(x)), int64(int32(y))
+	}
+	var ret bool
+	switch c {
+	case IntegerCmpCondEqual:
+		ret = x == y
+	case IntegerCmpCondNotEqual:
+		ret = x != y
+	case IntegerCmpCondSignedLessThan:
+		ret = sx < sy
+	case IntegerCmpCondSignedGreaterThanOrEqual:
+		ret = sx >= sy
+	case IntegerCmpCondSignedGreaterThan:
+		ret = sx > sy
+	case IntegerCmpCondSignedLessThanOrEqual:
+		ret = sx <= sy
+	case IntegerCmpCondUnsignedLessThan:
+		ret = x < y
+	case IntegerCmpCondUnsignedGreaterThanOrEqual:
+		ret = x >= y
+	case IntegerCmpCondUnsignedGreaterThan:
+		ret = x > y
+	case IntegerCmpCondUnsignedLessThanOrEqual:
+		ret = x <= y
+	default:
+		panic("BUG: invalid integer comparison condition")
+	}
+	if ret {
+		return 1
+	}
+	return 0
+}
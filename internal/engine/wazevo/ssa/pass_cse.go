@@ -0,0 +1,62 @@
+package ssa
+
+import "github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+
+// cseKey identifies the computation of an instruction that isMovable returns true for.
+type cseKey struct {
+	opcode    Opcode
+	typ       Type
+	u1, u2    uint64
+	v, v2, v3 Value
+}
+
+// cseEntry is the first instruction found for a cseKey, and the block it belongs to.
+type cseEntry struct {
+	instr *Instruction
+	blk   *basicBlock
+}
+
+// passCommonSubexpressionEliminationOpt replaces the result of each instruction with the one of the identical
+// instruction which dominates it if exists. The replaced instructions are eliminated by passDeadCodeEliminationOpt.
+//
+// The blocks are traversed in reverse post-order, so the dominating instructions are always found before the dominated
+// ones. This requires passCalculateImmediateDominators to be called before this.
+func passCommonSubexpressionEliminationOpt(b *builder) {
+	b.cseEntries = wazevoapi.ResetMap(b.cseEntries)
+	for blk := b.blockIteratorReversePostOrderBegin(); blk != nil; blk = b.blockIteratorReversePostOrderNext() {
+		for cur := blk.rootInstr; cur != nil; cur = cur.next {
+			b.resolveArgumentAlias(cur)
+			if !cur.isMovable() || cur.Constant() {
+				// Constants are materialized at each use by the backends, so they are not worth deduplicating.
+				continue
+			}
+
+			key := cseKey{opcode: cur.opcode, typ: cur.typ, u1: cur.u1, u2: cur.u2, v: cur.v, v2: cur.v2, v3: cur.v3}
+			if entry, ok := b.cseEntries[key]; ok && b.isDominatedBy(blk, entry.blk) {
+				b.alias(cur.Return(), entry.instr.Return())
+				continue
+			}
+			// Either the first one or the previous one doesn't dominate the rest of the blocks. In the latter case,
+			// the following blocks are more likely to be dominated by this one as they are close in reverse post-order.
+			b.cseEntries[key] = cseEntry{instr: cur, blk: blk}
+		}
+	}
+}
+
+// isMovable returns true if this instruction computes its only result solely from its arguments, hence it can be
+// moved anywhere its arguments are available, or replaced by an identical instruction.
+//
+// Loads are not movable as the memory might be modified in between. Comparisons are not movable either as the backends
+// fuse them with their users, e.g. OpcodeExitIfTrueWithCode requires the condition to be the comparison in the same
+// instruction group.
+func (i *Instruction) isMovable() bool {
+	if i.sideEffect() != sideEffectNone || len(i.rValues.View()) > 0 || len(i.vs.View()) > 0 {
+		return false
+	}
+	switch i.opcode {
+	case OpcodeLoad, OpcodeLoadSplat, OpcodeUload8, OpcodeUload16, OpcodeUload32, OpcodeSload8, OpcodeSload16,
+		OpcodeSload32, OpcodeVZeroExtLoad, OpcodeIcmp, OpcodeFcmp:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package ssa
+
+import "slices"
+
+// passLoopInvariantCodeMotionOpt hoists the instructions computing the same values in all the iterations of the loops
+// out of them, i.e. moves them to the end of the preheaders, the blocks from which the loops are entered.
+//
+// Only the instructions that Instruction.isMovable returns true for are hoisted, so that executing them in the
+// preheaders has no effect on the loops even if they were conditionally executed in the loops. Constants themselves
+// are not hoisted as the backends materialize them at each use, which often folds them into the using instructions.
+//
+// This requires passCalculateImmediateDominators to be called before this.
+func passLoopInvariantCodeMotionOpt(b *builder) {
+	// Record the blocks defining the values, so that we can tell whether the values are defined in the loops.
+	defs := b.valueDefinitionBlocks[:0]
+	defs = append(defs, make([]*basicBlock, b.nextValueID)...)
+	for _, blk := range b.reversePostOrderedBasicBlocks {
+		for _, param := range blk.params.View() {
+			defs[param.ID()] = blk
+		}
+		for cur := blk.rootInstr; cur != nil; cur = cur.next {
+			if cur.IsBranching() {
+				// The results of the branching instructions are their targets.
+				continue
+			}
+			if r := cur.rValue; r.Valid() {
+				defs[r.ID()] = blk
+			}
+			for _, r := range cur.rValues.View() {
+				defs[r.ID()] = blk
+			}
+		}
+	}
+	b.valueDefinitionBlocks = defs
+
+	// The inner loops come after the outer ones in reverse post-order. Process them first so that the instructions
+	// hoisted from the inner loops can be hoisted further out of the outer ones.
+	rpo := b.reversePostOrderedBasicBlocks
+	for i := len(rpo) - 1; i >= 0; i-- {
+		if header := rpo[i]; header.loopHeader {
+			b.hoistLoopInvariants(header)
+		}
+	}
+}
+
+// hoistLoopInvariants hoists the loop invariant instructions of the loop of the given header to its preheader.
+func (b *builder) hoistLoopInvariants(header *basicBlock) {
+	// The loop must have the only preheader which unconditionally jumps to the header, otherwise the hoisted
+	// instructions would be executed on the paths not entering the loop.
+	var preheader *basicBlock
+	latches := b.blkStack[:0]
+	for i := range header.preds {
+		pred := header.preds[i].blk
+		if pred.invalid {
+			continue
+		}
+		if b.isDominatedBy(pred, header) {
+			latches = append(latches, pred)
+		} else if preheader == nil {
+			preheader = pred
+		} else {
+			b.blkStack = latches
+			return
+		}
+	}
+	if preheader == nil || len(preheader.success) != 1 || preheader.currentInstr.opcode != OpcodeJump {
+		b.blkStack = latches
+		return
+	}
+
+	// Collect the blocks in the loop, which reach the latches without passing through the header.
+	body := append(b.blkStack2[:0], header)
+	header.visited = 1
+	stack := latches
+	reducible := true
+	for len(stack) > 0 {
+		tail := len(stack) - 1
+		blk := stack[tail]
+		stack = stack[:tail]
+		if blk.visited == 1 {
+			continue
+		}
+		blk.visited = 1
+		body = append(body, blk)
+		if !b.isDominatedBy(blk, header) {
+			// The loop has another entry. This shouldn't happen for WebAssembly, but just in case.
+			reducible = false
+		}
+		for i := range blk.preds {
+			if pred := blk.preds[i].blk; !pred.invalid && pred.visited != 1 {
+				stack = append(stack, pred)
+			}
+		}
+	}
+	b.blkStack = stack
+
+	if reducible {
+		// Visit the blocks in reverse post-order, so that the arguments are hoisted before their uses.
+		slices.SortFunc(body, func(i, j *basicBlock) int {
+			return int(i.reversePostOrder - j.reversePostOrder)
+		})
+		for _, blk := range body {
+			for cur := blk.rootInstr; cur != nil; {
+				next := cur.next
+				if b.loopInvariant(cur) {
+					b.hoistConstantArguments(cur, preheader)
+					blk.removeInstruction(cur)
+					preheader.insertInstructionBefore(cur, preheader.currentInstr)
+					b.valueDefinitionBlocks[cur.rValue.ID()] = preheader
+				}
+				cur = next
+			}
+		}
+	}
+
+	for _, blk := range body {
+		blk.visited = 0
+	}
+	b.blkStack2 = body
+}
+
+// loopInvariant returns true if the instruction is movable and all its arguments are either defined out of the loop
+// whose blocks are marked as visited, or constants.
+func (b *builder) loopInvariant(instr *Instruction) bool {
+	if !instr.isMovable() || instr.Constant() {
+		return false
+	}
+	b.resolveArgumentAlias(instr)
+	defs := b.valueDefinitionBlocks
+	for _, v := range [...]Value{instr.v, instr.v2, instr.v3} {
+		if !v.Valid() {
+			continue
+		}
+		if def := defs[v.ID()]; def != nil && def.visited == 1 {
+			if c := b.InstructionOfValue(v); c == nil || !c.Constant() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hoistConstantArguments copies the constant arguments of the instruction defined in the loop to the end of the
+// preheader, and replaces the arguments with the copies, so that the arguments are defined before the hoisted uses.
+func (b *builder) hoistConstantArguments(instr *Instruction, preheader *basicBlock) {
+	defs := b.valueDefinitionBlocks
+	for _, arg := range [...]*Value{&instr.v, &instr.v2, &instr.v3} {
+		v := *arg
+		if !v.Valid() {
+			continue
+		}
+		if def := defs[v.ID()]; def == nil || def.visited != 1 {
+			continue
+		}
+		c := b.InstructionOfValue(v)
+		cp := b.AllocateInstruction()
+		cp.opcode, cp.typ, cp.u1, cp.u2 = c.opcode, c.typ, c.u1, c.u2
+		*arg = b.insertNewInstructionBefore(preheader, cp, preheader.currentInstr)
+		defs = append(defs, make([]*basicBlock, int(b.nextValueID)-len(defs))...)
+		defs[arg.ID()] = preheader
+	}
+	b.valueDefinitionBlocks = defs
+}
@@ -3,6 +3,7 @@ package ssa
 import (
 	"testing"
 
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 )
 
@@ -291,6 +292,63 @@ blk0: ()
 blk1: () <-- (blk0)
 	v4:i32 = Iadd v2, v0
 	Return v4
+`,
+		},
+		{
+			name: "dead block parameters",
+			pass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry, loop, end := b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock()
+
+				b.SetCurrentBlock(entry)
+				x := entry.AddParam(b, TypeI32)
+				args := b.varLengthPool.Allocate(2).Append(&b.varLengthPool, x).Append(&b.varLengthPool, x)
+				b.AllocateInstruction().AsJump(args, loop).Insert(b)
+
+				// The first parameter is used, but the second one is only passed to itself.
+				b.SetCurrentBlock(loop)
+				used := loop.AddParam(b, TypeI32)
+				unused := loop.AddParam(b, TypeI32)
+				one := b.AllocateInstruction().AsIconst32(1).Insert(b).Return()
+				sub := b.AllocateInstruction().AsIsub(used, one).Insert(b).Return()
+				args = b.varLengthPool.Allocate(2).Append(&b.varLengthPool, sub).Append(&b.varLengthPool, unused)
+				b.AllocateInstruction().AsBrnz(sub, args, loop).Insert(b)
+				b.AllocateInstruction().AsJump(ValuesNil, end).Insert(b)
+
+				b.SetCurrentBlock(end)
+				ret := b.varLengthPool.Allocate(1).Append(&b.varLengthPool, used)
+				b.AllocateInstruction().AsReturn(ret).Insert(b)
+
+				b.Seal(entry)
+				b.Seal(loop)
+				b.Seal(end)
+				return nil
+			},
+			before: `
+blk0: (v0:i32)
+	Jump blk1, v0, v0
+
+blk1: (v1:i32,v2:i32) <-- (blk0,blk1)
+	v3:i32 = Iconst_32 0x1
+	v4:i32 = Isub v1, v3
+	Brnz v4, blk1, v4, v2
+	Jump blk2
+
+blk2: () <-- (blk1)
+	Return v1
+`,
+			after: `
+blk0: (v0:i32)
+	Jump blk1, v0
+
+blk1: (v1:i32) <-- (blk0,blk1)
+	v3:i32 = Iconst_32 0x1
+	v4:i32 = Isub v1, v3
+	Brnz v4, blk1, v4
+	Jump blk2
+
+blk2: () <-- (blk1)
+	Return v1
 `,
 		},
 		{
@@ -348,6 +406,337 @@ blk0: (v0:i32, v1:i64)
 	v8:i64 = Iconst_64 0x3d41
 	v9:i64 = Sshr v1, v8
 	Return v0, v1, v7, v9
+`,
+		},
+		{
+			name:     "block coalescing",
+			pass:     passBlockCoalescingOpt,
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry, b1, b2, b3 := b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock()
+
+				b.SetCurrentBlock(entry)
+				i32Param := entry.AddParam(b, TypeI32)
+				args := b.varLengthPool.Allocate(1).Append(&b.varLengthPool, i32Param)
+				b.AllocateInstruction().AsJump(args, b1).Insert(b)
+
+				// b1 has the only predecessor, so it can be merged into the entry.
+				b.SetCurrentBlock(b1)
+				b1Param := b1.AddParam(b, TypeI32)
+				add := b.AllocateInstruction().AsIadd(b1Param, b1Param).Insert(b).Return()
+				b.AllocateInstruction().AsBrnz(add, ValuesNil, b3).Insert(b)
+				b.AllocateInstruction().AsJump(ValuesNil, b2).Insert(b)
+
+				// b2 has the only predecessor, but it doesn't end with the unconditional jump.
+				b.SetCurrentBlock(b2)
+				b.AllocateInstruction().AsJump(ValuesNil, b3).Insert(b)
+
+				// b3 has two predecessors.
+				b.SetCurrentBlock(b3)
+				ret := b.varLengthPool.Allocate(1).Append(&b.varLengthPool, add)
+				b.AllocateInstruction().AsReturn(ret).Insert(b)
+
+				b.Seal(entry)
+				b.Seal(b1)
+				b.Seal(b2)
+				b.Seal(b3)
+				return func(t *testing.T) {
+					require.True(t, b1.(*basicBlock).invalid)
+					require.False(t, b2.(*basicBlock).invalid)
+					require.False(t, b3.(*basicBlock).invalid)
+				}
+			},
+			before: `
+blk0: (v0:i32)
+	Jump blk1, v0
+
+blk1: (v1:i32) <-- (blk0)
+	v2:i32 = Iadd v1, v1
+	Brnz v2, blk3
+	Jump blk2
+
+blk2: () <-- (blk1)
+	Jump blk3
+
+blk3: () <-- (blk1,blk2)
+	Return v2
+`,
+			after: `
+blk0: (v0:i32)
+	v2:i32 = Iadd v0, v0
+	Brnz v2, blk3
+	Jump blk2
+
+blk2: () <-- (blk0)
+	Jump blk3
+
+blk3: () <-- (blk0,blk2)
+	Return v2
+`,
+		},
+		{
+			name: "copy propagation",
+			pass: func(b *builder) {
+				passCalculateImmediateDominators(b)
+				passCopyPropagationOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry := b.AllocateBasicBlock()
+				b.SetCurrentBlock(entry)
+				c := entry.AddParam(b, TypeI32)
+				x := entry.AddParam(b, TypeI32)
+
+				aliased := b.allocateValue(TypeI32)
+				b.alias(aliased, x)
+				sel := b.AllocateInstruction().AsSelect(c, x, aliased).Insert(b).Return()
+				add := b.AllocateInstruction().AsIadd(sel, aliased).Insert(b).Return()
+
+				ret := b.varLengthPool.Allocate(1).Append(&b.varLengthPool, add)
+				b.AllocateInstruction().AsReturn(ret).Insert(b)
+				return nil
+			},
+			before: `
+blk0: (v0:i32, v1:i32)
+	v3:i32 = Select v0, v1, v2
+	v4:i32 = Iadd v3, v2
+	Return v4
+`,
+			after: `
+blk0: (v0:i32, v1:i32)
+	v4:i32 = Iadd v1, v1
+	Return v4
+`,
+		},
+		{
+			name: "constant folding",
+			pass: func(b *builder) {
+				passCalculateImmediateDominators(b)
+				passConstFoldingOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry := b.AllocateBasicBlock()
+				b.SetCurrentBlock(entry)
+				ctx := entry.AddParam(b, TypeI64)
+				i32Param := entry.AddParam(b, TypeI32)
+				i64Param := entry.AddParam(b, TypeI64)
+
+				// (10 + 20) * 0xffff_ffff = -30
+				ten := b.AllocateInstruction().AsIconst32(10).Insert(b).Return()
+				twenty := b.AllocateInstruction().AsIconst32(20).Insert(b).Return()
+				thirty := b.AllocateInstruction().AsIadd(ten, twenty).Insert(b).Return()
+				minusOne := b.AllocateInstruction().AsIconst32(0xffff_ffff).Insert(b).Return()
+				folded := b.AllocateInstruction().AsImul(thirty, minusOne).Insert(b).Return()
+
+				// 30 < 20 never holds, so this never exits.
+				cmp := b.AllocateInstruction().AsIcmp(thirty, twenty, IntegerCmpCondSignedLessThan).Insert(b).Return()
+				b.AllocateInstruction().AsExitIfTrueWithCode(ctx, cmp, wazevoapi.ExitCodeUnreachable).Insert(b)
+
+				// 0 + x = x
+				zero := b.AllocateInstruction().AsIconst32(0).Insert(b).Return()
+				addZero := b.AllocateInstruction().AsIadd(zero, i32Param).Insert(b).Return()
+				// x * 8 = x << 3
+				eight := b.AllocateInstruction().AsIconst32(8).Insert(b).Return()
+				mulEight := b.AllocateInstruction().AsImul(addZero, eight).Insert(b).Return()
+				// x / 16 = x >> 4
+				sixteen := b.AllocateInstruction().AsIconst64(16).Insert(b).Return()
+				divSixteen := b.AllocateInstruction().AsUDiv(i64Param, sixteen, ctx).Insert(b).Return()
+				// x % 16 = x & 15
+				remSixteen := b.AllocateInstruction().AsURem(i64Param, sixteen, ctx).Insert(b).Return()
+				// x - x = 0
+				subSelf := b.AllocateInstruction().AsIsub(i32Param, i32Param).Insert(b).Return()
+
+				ret := b.varLengthPool.Allocate(5)
+				for _, v := range []Value{folded, mulEight, divSixteen, remSixteen, subSelf} {
+					ret = ret.Append(&b.varLengthPool, v)
+				}
+				b.AllocateInstruction().AsReturn(ret).Insert(b)
+				return nil
+			},
+			before: `
+blk0: (v0:i64, v1:i32, v2:i64)
+	v3:i32 = Iconst_32 0xa
+	v4:i32 = Iconst_32 0x14
+	v5:i32 = Iadd v3, v4
+	v6:i32 = Iconst_32 0xffffffff
+	v7:i32 = Imul v5, v6
+	v8:i32 = Icmp lt_s, v5, v4
+	ExitIfTrue v8, v0, unreachable
+	v9:i32 = Iconst_32 0x0
+	v10:i32 = Iadd v9, v1
+	v11:i32 = Iconst_32 0x8
+	v12:i32 = Imul v10, v11
+	v13:i64 = Iconst_64 0x10
+	v14:i64 = Udiv v2, v13
+	v15:i64 = Urem v2, v13
+	v16:i32 = Isub v1, v1
+	Return v7, v12, v14, v15, v16
+`,
+			after: `
+blk0: (v0:i64, v1:i32, v2:i64)
+	v7:i32 = Iconst_32 0xffffffe2
+	v17:i32 = Iconst_32 0x3
+	v12:i32 = Ishl v1, v17
+	v18:i64 = Iconst_64 0x4
+	v14:i64 = Ushr v2, v18
+	v19:i64 = Iconst_64 0xf
+	v15:i64 = Band v2, v19
+	v16:i32 = Iconst_32 0x0
+	Return v7, v12, v14, v15, v16
+`,
+		},
+		{
+			name: "common subexpression elimination",
+			pass: func(b *builder) {
+				passCalculateImmediateDominators(b)
+				passCommonSubexpressionEliminationOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry, then, els, end := b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock()
+
+				b.SetCurrentBlock(entry)
+				x := entry.AddParam(b, TypeI32)
+				y := entry.AddParam(b, TypeI32)
+				add := b.AllocateInstruction().AsIadd(x, y).Insert(b).Return()
+				b.AllocateInstruction().AsBrnz(x, ValuesNil, els).Insert(b)
+				b.AllocateInstruction().AsJump(ValuesNil, then).Insert(b)
+
+				// The addition is dominated by the one in the entry, but the subtraction is not dominated by the one in
+				// the other branch.
+				b.SetCurrentBlock(then)
+				thenAdd := b.AllocateInstruction().AsIadd(x, y).Insert(b).Return()
+				thenSub := b.AllocateInstruction().AsIsub(thenAdd, y).Insert(b).Return()
+				args := b.varLengthPool.Allocate(1).Append(&b.varLengthPool, thenSub)
+				b.AllocateInstruction().AsJump(args, end).Insert(b)
+
+				b.SetCurrentBlock(els)
+				elsSub := b.AllocateInstruction().AsIsub(add, y).Insert(b).Return()
+				args = b.varLengthPool.Allocate(1).Append(&b.varLengthPool, elsSub)
+				b.AllocateInstruction().AsJump(args, end).Insert(b)
+
+				b.SetCurrentBlock(end)
+				param := end.AddParam(b, TypeI32)
+				endSub := b.AllocateInstruction().AsIsub(add, y).Insert(b).Return()
+				ret := b.varLengthPool.Allocate(2).Append(&b.varLengthPool, param).Append(&b.varLengthPool, endSub)
+				b.AllocateInstruction().AsReturn(ret).Insert(b)
+
+				b.Seal(entry)
+				b.Seal(then)
+				b.Seal(els)
+				b.Seal(end)
+				return nil
+			},
+			before: `
+blk0: (v0:i32, v1:i32)
+	v2:i32 = Iadd v0, v1
+	Brnz v0, blk2
+	Jump blk1
+
+blk1: () <-- (blk0)
+	v3:i32 = Iadd v0, v1
+	v4:i32 = Isub v3, v1
+	Jump blk3, v4
+
+blk2: () <-- (blk0)
+	v5:i32 = Isub v2, v1
+	Jump blk3, v5
+
+blk3: (v6:i32) <-- (blk1,blk2)
+	v7:i32 = Isub v2, v1
+	Return v6, v7
+`,
+			after: `
+blk0: (v0:i32, v1:i32)
+	v2:i32 = Iadd v0, v1
+	Brnz v0, blk2
+	Jump blk1
+
+blk1: () <-- (blk0)
+	v4:i32 = Isub v2, v1
+	Jump blk3, v4
+
+blk2: () <-- (blk0)
+	v5:i32 = Isub v2, v1
+	Jump blk3, v5
+
+blk3: (v6:i32) <-- (blk1,blk2)
+	v7:i32 = Isub v2, v1
+	Return v6, v7
+`,
+		},
+		{
+			name: "loop invariant code motion",
+			pass: func(b *builder) {
+				passCalculateImmediateDominators(b)
+				passLoopInvariantCodeMotionOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry, loop, end := b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock()
+
+				b.SetCurrentBlock(entry)
+				ctx := entry.AddParam(b, TypeI64)
+				x := entry.AddParam(b, TypeI32)
+				args := b.varLengthPool.Allocate(1).Append(&b.varLengthPool, x)
+				b.AllocateInstruction().AsJump(args, loop).Insert(b)
+
+				b.SetCurrentBlock(loop)
+				i := loop.AddParam(b, TypeI32)
+				three := b.AllocateInstruction().AsIconst32(3).Insert(b).Return()
+				// x * 3 and (x * 3) + x are invariant, so hoisted along with the copy of the constant.
+				mul := b.AllocateInstruction().AsImul(x, three).Insert(b).Return()
+				add := b.AllocateInstruction().AsIadd(mul, x).Insert(b).Return()
+				// The division might trap, so this must not be hoisted.
+				div := b.AllocateInstruction().AsUDiv(x, add, ctx).Insert(b).Return()
+				next := b.AllocateInstruction().AsIsub(i, div).Insert(b).Return()
+				args = b.varLengthPool.Allocate(1).Append(&b.varLengthPool, next)
+				b.AllocateInstruction().AsBrnz(next, args, loop).Insert(b)
+				b.AllocateInstruction().AsJump(ValuesNil, end).Insert(b)
+
+				b.SetCurrentBlock(end)
+				ret := b.varLengthPool.Allocate(1).Append(&b.varLengthPool, add)
+				b.AllocateInstruction().AsReturn(ret).Insert(b)
+
+				b.Seal(entry)
+				b.Seal(loop)
+				b.Seal(end)
+				return func(t *testing.T) {
+					require.True(t, loop.(*basicBlock).loopHeader)
+				}
+			},
+			before: `
+blk0: (v0:i64, v1:i32)
+	Jump blk1, v1
+
+blk1: (v2:i32) <-- (blk0,blk1)
+	v3:i32 = Iconst_32 0x3
+	v4:i32 = Imul v1, v3
+	v5:i32 = Iadd v4, v1
+	v6:i32 = Udiv v1, v5
+	v7:i32 = Isub v2, v6
+	Brnz v7, blk1, v7
+	Jump blk2
+
+blk2: () <-- (blk1)
+	Return v5
+`,
+			after: `
+blk0: (v0:i64, v1:i32)
+	v8:i32 = Iconst_32 0x3
+	v4:i32 = Imul v1, v8
+	v5:i32 = Iadd v4, v1
+	Jump blk1, v1
+
+blk1: (v2:i32) <-- (blk0,blk1)
+	v6:i32 = Udiv v1, v5
+	v7:i32 = Isub v2, v6
+	Brnz v7, blk1, v7
+	Jump blk2
+
+blk2: () <-- (blk1)
+	Return v5
 `,
 		},
 	} {
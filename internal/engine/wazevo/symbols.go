@@ -0,0 +1,116 @@
+package wazevo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// symbolWriter writes the symbols of the machine code for the profilers such as perf, which otherwise can't attribute
+// the samples in the code compiled at runtime. A nil *symbolWriter writes nothing.
+type symbolWriter struct {
+	perfMap *wazevoapi.PerfMap
+	jitDump *wazevoapi.JITDump
+}
+
+// newSymbolWriter returns the symbolWriter for the formats enabled in ctx, or nil if none is enabled.
+func newSymbolWriter(ctx context.Context) (*symbolWriter, error) {
+	var w symbolWriter
+	var err error
+	if enabled, _ := ctx.Value(expctxkeys.PerfMapKey{}).(bool); enabled {
+		if w.perfMap, err = wazevoapi.OpenPerfMap(); err != nil {
+			return nil, err
+		}
+	}
+	if enabled, _ := ctx.Value(expctxkeys.JITDumpKey{}).(bool); enabled {
+		if w.jitDump, err = wazevoapi.OpenJITDump(); err != nil {
+			return nil, err
+		}
+	}
+	if w.perfMap == nil && w.jitDump == nil {
+		return nil, nil
+	}
+	return &w, nil
+}
+
+// add writes the symbol of the code. lines are the source lines of the code if any, which are only in the jitdump.
+func (w *symbolWriter) add(code []byte, name string, lines []wazevoapi.JITDumpLine) {
+	if w == nil || len(code) == 0 {
+		return
+	}
+	if w.perfMap != nil {
+		w.perfMap.AddEntry(uintptr(unsafe.Pointer(&code[0])), uint64(len(code)), name)
+	}
+	if w.jitDump != nil {
+		w.jitDump.AddCode(code, name, lines)
+	}
+}
+
+// addModule writes the symbols of the entry preambles and the local functions of cm. The functions of the lazily
+// compiled modules are written by addFunction as they are compiled instead.
+func (w *symbolWriter) addModule(cm *compiledModule) {
+	if w == nil {
+		return
+	}
+	for i, exe := range cm.entryPreambles {
+		w.add(exe, fmt.Sprintf("entry_preamble::type=%s", cm.module.TypeSection[i].String()), nil)
+	}
+	if cm.lazy != nil {
+		return
+	}
+	for i, offset := range cm.functionOffsets {
+		// The functions are laid out in the index order, so each one ends where the next one starts.
+		end := len(cm.executable)
+		if i+1 < len(cm.functionOffsets) {
+			end = cm.functionOffsets[i+1]
+		}
+		w.addFunction(cm, wasm.Index(i), cm.executable[offset:end])
+	}
+}
+
+// addFunction writes the symbol of the local function of the given index whose machine code is code.
+func (w *symbolWriter) addFunction(cm *compiledModule, index wasm.Index, code []byte) {
+	if w == nil || len(code) == 0 {
+		return
+	}
+	m := cm.module
+	name := m.FunctionDefinition(index + m.ImportFunctionCount).DebugName()
+	if m.IsHostModule {
+		w.add(code, "trampoline:"+name, nil)
+		return
+	}
+	var lines []wazevoapi.JITDumpLine
+	if w.jitDump != nil && m.DWARFLines != nil {
+		lines = cm.sourceLines(code)
+	}
+	w.add(code, name, lines)
+}
+
+// sourceLines returns the source lines of the machine code in the executable, starting from where the line changes.
+func (cm *compiledModule) sourceLines(code []byte) (lines []wazevoapi.JITDumpLine) {
+	start := uintptr(unsafe.Pointer(&code[0]))
+	end := start + uintptr(len(code))
+	sm := cm.sourceMapOf(start)
+	if sm == nil {
+		return
+	}
+	offsets := sm.executableOffsets
+	for i := sort.Search(len(offsets), func(i int) bool { return offsets[i] >= start }); i < len(offsets) && offsets[i] < end; i++ {
+		locations := cm.module.DWARFLines.Locations(cm.decodeSourceOffset(sm.wasmBinaryOffsets[i]))
+		if len(locations) == 0 {
+			continue
+		}
+		// The first one is the innermost, which is the line of the instruction.
+		l := locations[0]
+		if n := len(lines); n > 0 && lines[n-1].File == l.File && lines[n-1].Line == uint32(l.Line) {
+			continue
+		}
+		lines = append(lines, wazevoapi.JITDumpLine{Addr: offsets[i], File: l.File, Line: uint32(l.Line)})
+	}
+	return
+}
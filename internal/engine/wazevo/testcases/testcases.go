@@ -1372,7 +1372,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0, // offset
@@ -1391,7 +1391,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i64, f32, f64}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0, // offset
@@ -1443,7 +1443,7 @@ var (
 				Results: []wasm.ValueType{i32},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0,
@@ -1459,7 +1459,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Results: []wasm.ValueType{i32, i32, i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 2, IsMaxEncoded: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 2, IsMaxEncoded: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 1,
@@ -1477,7 +1477,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{i32_i32, {}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0, 1},
 			CodeSection: []wasm.Code{
 				{Body: []byte{
@@ -1508,7 +1508,7 @@ var (
 				{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0},
 				{Name: "size", Type: wasm.ExternTypeFunc, Index: 0},
 			},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			TypeSection:     []wasm.FunctionType{v_i32},
 			FunctionSection: []wasm.Index{0},
 			CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeMemorySize, 0, wasm.OpcodeEnd}}},
@@ -1700,7 +1700,7 @@ var (
 				},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				// Basic loads (without extensions).
@@ -1890,7 +1890,7 @@ var (
 			},
 
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 4554},
+			MemorySection:   []wasm.Memory{{Min: 4554}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeBlock, 1, // Signature v_i64,
@@ -1941,7 +1941,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32, i64}, Results: []wasm.ValueType{i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0,
@@ -1958,7 +1958,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i64, i64}, Results: []wasm.ValueType{i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0,
@@ -1975,7 +1975,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0,
@@ -1994,7 +1994,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2031,7 +2031,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2068,7 +2068,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2105,7 +2105,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2142,7 +2142,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2179,7 +2179,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2216,7 +2216,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2267,7 +2267,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2311,7 +2311,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2355,7 +2355,7 @@ var (
 				Results: []wasm.ValueType{},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicFence, 0,
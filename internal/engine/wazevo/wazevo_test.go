@@ -72,4 +72,13 @@ func Test_ExecutionContextOffsets(t *testing.T) {
 	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.memoryWait32TrampolineAddress)), wazevoapi.ExecutionContextOffsetMemoryWait32TrampolineAddress)
 	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.memoryWait64TrampolineAddress)), wazevoapi.ExecutionContextOffsetMemoryWait64TrampolineAddress)
 	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.memoryNotifyTrampolineAddress)), wazevoapi.ExecutionContextOffsetMemoryNotifyTrampolineAddress)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.exceptionPending)), wazevoapi.ExecutionContextOffsetExceptionPending)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.exceptionPayload)), wazevoapi.ExecutionContextOffsetExceptionPayload)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.exceptionTrampolineAddress)), wazevoapi.ExecutionContextOffsetExceptionTrampolineAddress)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.fuel)), wazevoapi.ExecutionContextOffsetFuel)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.lazyFunctionIndex)), wazevoapi.ExecutionContextOffsetLazyFunctionIndex)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.lazyFunctionExecutable)), wazevoapi.ExecutionContextOffsetLazyFunctionExecutable)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.sampleRequested)), wazevoapi.ExecutionContextOffsetSampleRequested)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.sampleTrampolineAddress)), wazevoapi.ExecutionContextOffsetSampleTrampolineAddress)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.tailCallAddress)), wazevoapi.ExecutionContextOffsetTailCallAddress)
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -88,6 +89,8 @@ const (
 
 type (
 	verifierState struct {
+		// mux guards values, which are set by the functions compiled concurrently.
+		mux                    sync.Mutex
 		initialCompilationDone bool
 		maybeRandomizedIndexes []int
 		r                      *rand.Rand
@@ -136,11 +139,17 @@ func DeterministicCompilationVerifierGetRandomizedLocalFunctionIndex(ctx context
 // VerifyOrSetDeterministicCompilationContextValue verifies that the `newValue` is the same as the previous value for the given `scope`
 // and the current function name. If the previous value doesn't exist, it sets the value to the given `newValue`.
 //
-// If the verification fails, this prints the diff and exits the process.
+// If the verification fails, this prints the diff and exits the process. This does nothing for the functions compiled
+// lazily, which are compiled only once without the verifier context.
 func VerifyOrSetDeterministicCompilationContextValue(ctx context.Context, scope string, newValue string) {
+	verifierCtx, ok := ctx.Value(verifierStateContextKey{}).(*verifierState)
+	if !ok {
+		return
+	}
 	fn := ctx.Value(currentFunctionNameKey{}).(string)
 	key := fn + ": " + scope
-	verifierCtx := ctx.Value(verifierStateContextKey{}).(*verifierState)
+	verifierCtx.mux.Lock()
+	defer verifierCtx.mux.Unlock()
 	oldValue, ok := verifierCtx.values[key]
 	if !ok {
 		verifierCtx.values[key] = newValue
@@ -173,8 +182,24 @@ const NeedFunctionNameInContext = PrintSSA ||
 	PrintRegisterAllocated ||
 	PrintFinalizedMachineCode ||
 	PrintMachineCodeHexPerFunction ||
-	DeterministicCompilationVerifierEnabled ||
-	PerfMapEnabled
+	DeterministicCompilationVerifierEnabled
+
+// NeedSequentialCompilation is true when the options above print or record something while compiling each function,
+// in which case the functions are compiled one after another so that the output isn't interleaved. This is also true
+// for the deterministic compilation verifier, which compiles the functions in the randomized order with the same
+// compiler instances to find the state leaking from one function to the next.
+//
+// nolint
+const NeedSequentialCompilation = DeterministicCompilationVerifierEnabled ||
+	FrontEndLoggingEnabled ||
+	SSALoggingEnabled ||
+	RegAllocLoggingEnabled ||
+	PrintSSA ||
+	PrintOptimizedSSA ||
+	PrintSSAToBackendIRLowering ||
+	PrintRegisterAllocated ||
+	PrintFinalizedMachineCode ||
+	PrintMachineCodeHexPerFunction
 
 // SetCurrentFunctionName sets the current function name to the given `functionName`.
 func SetCurrentFunctionName(ctx context.Context, index int, functionName string) context.Context {
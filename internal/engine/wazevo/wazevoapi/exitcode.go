@@ -30,6 +30,14 @@ const (
 	ExitCodeMemoryWait64
 	ExitCodeMemoryNotify
 	ExitCodeUnalignedAtomic
+	ExitCodeException
+	ExitCodeNullReference
+	ExitCodeNullFunctionReference
+	ExitCodeOutOfFuel
+	// ExitCodeLazyCompile is an exit code for the first call to a function which is compiled lazily.
+	ExitCodeLazyCompile
+	// ExitCodeSample is an exit code for a safepoint reached while the sampling profiler requests a stack sample.
+	ExitCodeSample
 	exitCodeMax
 )
 
@@ -86,6 +94,18 @@ func (e ExitCode) String() string {
 		return "memory_wait64"
 	case ExitCodeMemoryNotify:
 		return "memory_notify"
+	case ExitCodeException:
+		return "exception"
+	case ExitCodeNullReference:
+		return "null_reference"
+	case ExitCodeNullFunctionReference:
+		return "null_function_reference"
+	case ExitCodeOutOfFuel:
+		return "out_of_fuel"
+	case ExitCodeLazyCompile:
+		return "lazy_compile"
+	case ExitCodeSample:
+		return "sample"
 	}
 	panic("TODO")
 }
@@ -107,3 +127,22 @@ func ExitCodeCallGoFunctionWithIndex(index int, withListener bool) ExitCode {
 func GoFunctionIndexFromExitCode(exitCode ExitCode) int {
 	return int(exitCode >> 8)
 }
+
+// ExceptionOp is the operation done by the builtin function for ExitCodeException.
+type ExceptionOp uint32
+
+const (
+	// ExceptionOpThrow creates the exception of the tag given by the index and makes it pending.
+	// This returns the pointer to the values of the exception, which must be stored by the caller.
+	ExceptionOpThrow ExceptionOp = iota
+	// ExceptionOpThrowRef makes the exception referenced by the given exnref pending.
+	ExceptionOpThrowRef
+	// ExceptionOpCatch returns non-zero and clears the pending exception if it has the tag given by the index,
+	// or any tag for ExceptionCatchAllTag. The values of the caught exception are stored in the executionContext.
+	ExceptionOpCatch
+	// ExceptionOpCatchRef is the same as ExceptionOpCatch, but returns the exnref of the caught exception.
+	ExceptionOpCatchRef
+)
+
+// ExceptionCatchAllTag is passed to ExceptionOpCatch and ExceptionOpCatchRef as the tag index to catch any exception.
+const ExceptionCatchAllTag = 0xffffffff
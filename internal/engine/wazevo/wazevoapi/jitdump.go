@@ -0,0 +1,149 @@
+package wazevoapi
+
+import (
+	"encoding/binary"
+	"os"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// JITDump writes the machine code and its symbols into the jitdump file of the process, /tmp/jit-<pid>.dump. Unlike
+// PerfMap, this includes the machine code and the source lines, so that "perf inject --jit" can create the ELF files
+// with which "perf report" and "perf annotate" show the instructions and the source lines of the functions.
+//
+// See https://github.com/torvalds/linux/blob/master/tools/perf/Documentation/jitdump-specification.txt
+type JITDump struct {
+	mux sync.Mutex
+	fh  *os.File
+	pid uint32
+	// codeIndex is the number of the code load records written so far, which must be unique in the file.
+	codeIndex uint64
+	// buf is reused to encode the records.
+	buf []byte
+}
+
+// JITDumpLine is the source line of the machine code at Addr.
+type JITDumpLine struct {
+	Addr uintptr
+	File string
+	Line uint32
+}
+
+var jitDump struct {
+	once sync.Once
+	d    *JITDump
+	err  error
+}
+
+// OpenJITDump returns the JITDump of the process, which is shared by all the engines.
+func OpenJITDump() (*JITDump, error) {
+	jitDump.once.Do(func() {
+		jitDump.d, jitDump.err = openJITDump()
+	})
+	return jitDump.d, jitDump.err
+}
+
+// The constants of the jitdump format.
+const (
+	jitDumpMagic      = 0x4A695444
+	jitDumpVersion    = 1
+	jitDumpHeaderSize = 40
+
+	jitDumpRecordCodeLoad   = 0
+	jitDumpRecordDebugInfo  = 2
+	jitDumpRecordHeaderSize = 16
+)
+
+// jitDumpELFMachine returns the e_machine of the ELF for the GOARCH.
+func jitDumpELFMachine() uint32 {
+	switch runtime.GOARCH {
+	case "amd64":
+		return 62 // EM_X86_64
+	case "arm64":
+		return 183 // EM_AARCH64
+	case "riscv64":
+		return 243 // EM_RISCV
+	default:
+		return 0 // EM_NONE
+	}
+}
+
+// AddCode writes the machine code with the name and its source lines if any.
+//
+// Note: The errors are ignored as the symbols only help profiling, which shouldn't fail the compilation.
+func (d *JITDump) AddCode(code []byte, name string, lines []JITDumpLine) {
+	if len(code) == 0 {
+		return
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	timestamp := jitDumpTimestamp()
+	d.buf = d.buf[:0]
+	if len(lines) > 0 {
+		// The debug info must precede the code load record of the same code.
+		d.buf = appendJITDumpDebugInfo(d.buf, timestamp, codeAddr(code), lines)
+	}
+	d.buf = appendJITDumpCodeLoad(d.buf, d.pid, timestamp, code, d.codeIndex, name)
+	d.codeIndex++
+	_, _ = d.fh.Write(d.buf)
+}
+
+// appendJITDumpHeader appends the file header.
+func appendJITDumpHeader(b []byte, pid uint32, timestamp uint64) []byte {
+	b = binary.LittleEndian.AppendUint32(b, jitDumpMagic)
+	b = binary.LittleEndian.AppendUint32(b, jitDumpVersion)
+	b = binary.LittleEndian.AppendUint32(b, jitDumpHeaderSize)
+	b = binary.LittleEndian.AppendUint32(b, jitDumpELFMachine())
+	b = binary.LittleEndian.AppendUint32(b, 0) // pad1
+	b = binary.LittleEndian.AppendUint32(b, pid)
+	b = binary.LittleEndian.AppendUint64(b, timestamp)
+	return binary.LittleEndian.AppendUint64(b, 0) // flags
+}
+
+// appendJITDumpRecordHeader appends the header of a record whose body has the given size.
+func appendJITDumpRecordHeader(b []byte, id uint32, bodySize int, timestamp uint64) []byte {
+	b = binary.LittleEndian.AppendUint32(b, id)
+	b = binary.LittleEndian.AppendUint32(b, uint32(jitDumpRecordHeaderSize+bodySize))
+	return binary.LittleEndian.AppendUint64(b, timestamp)
+}
+
+// appendJITDumpCodeLoad appends the record of the code loaded at its address.
+func appendJITDumpCodeLoad(b []byte, pid uint32, timestamp uint64, code []byte, index uint64, name string) []byte {
+	b = appendJITDumpRecordHeader(b, jitDumpRecordCodeLoad, 40+len(name)+1+len(code), timestamp)
+	addr := uint64(codeAddr(code))
+	b = binary.LittleEndian.AppendUint32(b, pid)
+	b = binary.LittleEndian.AppendUint32(b, pid)  // tid, which isn't meaningful as the code is shared by the threads.
+	b = binary.LittleEndian.AppendUint64(b, addr) // vma
+	b = binary.LittleEndian.AppendUint64(b, addr) // code_addr
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(code)))
+	b = binary.LittleEndian.AppendUint64(b, index)
+	b = append(b, name...)
+	b = append(b, 0)
+	return append(b, code...)
+}
+
+// appendJITDumpDebugInfo appends the record of the source lines of the code at addr.
+func appendJITDumpDebugInfo(b []byte, timestamp uint64, addr uintptr, lines []JITDumpLine) []byte {
+	size := 16
+	for i := range lines {
+		size += 16 + len(lines[i].File) + 1
+	}
+	b = appendJITDumpRecordHeader(b, jitDumpRecordDebugInfo, size, timestamp)
+	b = binary.LittleEndian.AppendUint64(b, uint64(addr))
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(lines)))
+	for i := range lines {
+		l := &lines[i]
+		b = binary.LittleEndian.AppendUint64(b, uint64(l.Addr))
+		b = binary.LittleEndian.AppendUint32(b, l.Line)
+		b = binary.LittleEndian.AppendUint32(b, 0) // discrim
+		b = append(b, l.File...)
+		b = append(b, 0)
+	}
+	return b
+}
+
+// codeAddr returns the address of the non-empty code.
+func codeAddr(code []byte) uintptr {
+	return uintptr(unsafe.Pointer(&code[0]))
+}
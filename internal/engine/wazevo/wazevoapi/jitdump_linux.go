@@ -0,0 +1,36 @@
+package wazevoapi
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+func openJITDump() (*JITDump, error) {
+	pid := os.Getpid()
+	filename := "/tmp/jit-" + strconv.Itoa(pid) + ".dump"
+	fh, err := os.OpenFile(filename, os.O_TRUNC|os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening jitdump: %w", err)
+	}
+	if _, err = fh.Write(appendJITDumpHeader(nil, uint32(pid), jitDumpTimestamp())); err != nil {
+		fh.Close()
+		return nil, fmt.Errorf("error writing jitdump: %w", err)
+	}
+	// perf finds the file by its executable mapping recorded by "perf record", which is never unmapped.
+	if _, err = syscall.Mmap(int(fh.Fd()), 0, os.Getpagesize(), syscall.PROT_READ|syscall.PROT_EXEC, syscall.MAP_PRIVATE); err != nil {
+		fh.Close()
+		return nil, fmt.Errorf("error mapping jitdump: %w", err)
+	}
+	return &JITDump{fh: fh, pid: uint32(pid)}, nil
+}
+
+// jitDumpTimestamp returns the time of CLOCK_MONOTONIC, which is used by "perf record -k mono".
+func jitDumpTimestamp() uint64 {
+	var ts syscall.Timespec
+	const clockMonotonic = 1
+	_, _, _ = syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockMonotonic, uintptr(unsafe.Pointer(&ts)), 0)
+	return uint64(ts.Nano())
+}
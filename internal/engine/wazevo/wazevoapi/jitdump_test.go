@@ -0,0 +1,48 @@
+package wazevoapi
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestJITDump_records(t *testing.T) {
+	header := appendJITDumpHeader(nil, 1234, 5678)
+	require.Equal(t, jitDumpHeaderSize, len(header))
+	require.Equal(t, []byte("DTiJ"), header[:4])
+	require.Equal(t, uint32(1234), binary.LittleEndian.Uint32(header[20:]))
+	require.Equal(t, uint64(5678), binary.LittleEndian.Uint64(header[24:]))
+
+	code := []byte{1, 2, 3, 4}
+	addr := codeAddr(code)
+	lines := []JITDumpLine{{Addr: addr, File: "a.c", Line: 10}, {Addr: addr + 2, File: "b.c", Line: 20}}
+	var b []byte
+	b = appendJITDumpDebugInfo(b, 5678, addr, lines)
+	b = appendJITDumpCodeLoad(b, 1234, 5678, code, 7, "foo")
+
+	// The records are walked by their sizes.
+	debugInfoSize := binary.LittleEndian.Uint32(b[4:])
+	require.Equal(t, uint32(jitDumpRecordDebugInfo), binary.LittleEndian.Uint32(b[0:]))
+	require.Equal(t, uint64(addr), binary.LittleEndian.Uint64(b[16:]))
+	require.Equal(t, uint64(2), binary.LittleEndian.Uint64(b[24:]))
+	entries := b[32:debugInfoSize]
+	require.Equal(t, uint64(addr), binary.LittleEndian.Uint64(entries[0:]))
+	require.Equal(t, uint32(10), binary.LittleEndian.Uint32(entries[8:]))
+	require.Equal(t, "a.c\x00", string(entries[16:20]))
+	require.Equal(t, uint64(addr+2), binary.LittleEndian.Uint64(entries[20:]))
+	require.Equal(t, uint32(20), binary.LittleEndian.Uint32(entries[28:]))
+	require.Equal(t, "b.c\x00", string(entries[36:]))
+
+	codeLoad := b[debugInfoSize:]
+	require.Equal(t, uint32(jitDumpRecordCodeLoad), binary.LittleEndian.Uint32(codeLoad[0:]))
+	require.Equal(t, uint32(len(codeLoad)), binary.LittleEndian.Uint32(codeLoad[4:]))
+	require.Equal(t, uint64(5678), binary.LittleEndian.Uint64(codeLoad[8:]))
+	require.Equal(t, uint32(1234), binary.LittleEndian.Uint32(codeLoad[16:]))
+	require.Equal(t, uint64(addr), binary.LittleEndian.Uint64(codeLoad[24:]))
+	require.Equal(t, uint64(addr), binary.LittleEndian.Uint64(codeLoad[32:]))
+	require.Equal(t, uint64(len(code)), binary.LittleEndian.Uint64(codeLoad[40:]))
+	require.Equal(t, uint64(7), binary.LittleEndian.Uint64(codeLoad[48:]))
+	require.Equal(t, "foo\x00", string(codeLoad[56:60]))
+	require.Equal(t, code, codeLoad[60:])
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package wazevoapi
+
+import "errors"
+
+func openJITDump() (*JITDump, error) {
+	return nil, errors.New("jitdump is only supported on Linux")
+}
+
+func jitDumpTimestamp() uint64 {
+	return 0
+}
@@ -53,6 +53,15 @@ const (
 	ExecutionContextOffsetMemoryWait32TrampolineAddress Offset = 1160
 	ExecutionContextOffsetMemoryWait64TrampolineAddress Offset = 1168
 	ExecutionContextOffsetMemoryNotifyTrampolineAddress Offset = 1176
+	ExecutionContextOffsetExceptionPending              Offset = 1184
+	ExecutionContextOffsetExceptionPayload              Offset = 1192
+	ExecutionContextOffsetExceptionTrampolineAddress    Offset = 1200
+	ExecutionContextOffsetFuel                          Offset = 1208
+	ExecutionContextOffsetLazyFunctionIndex             Offset = 1216
+	ExecutionContextOffsetLazyFunctionExecutable        Offset = 1224
+	ExecutionContextOffsetSampleRequested               Offset = 1232
+	ExecutionContextOffsetSampleTrampolineAddress       Offset = 1240
+	ExecutionContextOffsetTailCallAddress               Offset = 1248
 )
 
 // ModuleContextOffsetData allows the compilers to get the information about offsets to the fields of wazevo.moduleContextOpaque,
@@ -104,19 +113,30 @@ func (o Offset) U64() uint64 {
 	return uint64(o)
 }
 
-// LocalMemoryBase returns an offset of the first byte of the local memory.
-func (m *ModuleContextOffsetData) LocalMemoryBase() Offset {
-	return m.LocalMemoryBegin
+// LocalMemoryBase returns an offset of the first byte of the i-th local memory.
+// The index i does not include the imported memories.
+func (m *ModuleContextOffsetData) LocalMemoryBase(i wasm.Index) Offset {
+	if l := m.LocalMemoryBegin; l >= 0 {
+		return l + Offset(i)*16
+	}
+	return -1
 }
 
-// LocalMemoryLen returns an offset of the length of the local memory buffer.
-func (m *ModuleContextOffsetData) LocalMemoryLen() Offset {
+// LocalMemoryLen returns an offset of the length of the i-th local memory buffer.
+// The index i does not include the imported memories.
+func (m *ModuleContextOffsetData) LocalMemoryLen(i wasm.Index) Offset {
 	if l := m.LocalMemoryBegin; l >= 0 {
-		return l + 8
+		return l + Offset(i)*16 + 8
 	}
 	return -1
 }
 
+// ImportedMemoryOffset returns an offset of the i-th imported memory's *wasm.MemoryInstance.
+// The owner's moduleContextOpaque pointer follows it at the returned offset + 8.
+func (m *ModuleContextOffsetData) ImportedMemoryOffset(i wasm.Index) Offset {
+	return m.ImportedMemoryBegin + Offset(i)*16
+}
+
 // TableOffset returns an offset of the i-th table instance.
 func (m *ModuleContextOffsetData) TableOffset(tableIndex int) Offset {
 	return m.TablesBegin + Offset(tableIndex)*8
@@ -124,18 +144,21 @@ func (m *ModuleContextOffsetData) TableOffset(tableIndex int) Offset {
 
 // NewModuleContextOffsetData creates a ModuleContextOffsetData determining the structure of moduleContextOpaque for the given Module.
 // The structure is described in the comment of wazevo.moduleContextOpaque.
-func NewModuleContextOffsetData(m *wasm.Module, withListener bool) ModuleContextOffsetData {
+//
+// withTypeIDs must be true if the functions may check the types of function references without a table,
+// which is the case for call_ref of experimental.CoreFeaturesFunctionReferences.
+func NewModuleContextOffsetData(m *wasm.Module, withListener, withTypeIDs bool) ModuleContextOffsetData {
 	ret := ModuleContextOffsetData{}
 	var offset Offset
 
 	ret.ModuleInstanceOffset = 0
 	offset += 8
 
-	if m.MemorySection != nil {
+	if len(m.MemorySection) > 0 {
 		ret.LocalMemoryBegin = offset
-		// buffer base + memory size.
+		// buffer base + memory size per local memory.
 		const localMemorySizeInOpaqueModuleContext = 16
-		offset += localMemorySizeInOpaqueModuleContext
+		offset += localMemorySizeInOpaqueModuleContext * Offset(len(m.MemorySection))
 	} else {
 		// Indicates that there's no local memory
 		ret.LocalMemoryBegin = -1
@@ -146,7 +169,7 @@ func NewModuleContextOffsetData(m *wasm.Module, withListener bool) ModuleContext
 		// *wasm.MemoryInstance + imported memory's owner (moduleContextOpaque)
 		const importedMemorySizeInOpaqueModuleContext = 16
 		ret.ImportedMemoryBegin = offset
-		offset += importedMemorySizeInOpaqueModuleContext
+		offset += importedMemorySizeInOpaqueModuleContext * Offset(m.ImportMemoryCount)
 	} else {
 		// Indicates that there's no imported memory
 		ret.ImportedMemoryBegin = -1
@@ -172,16 +195,20 @@ func NewModuleContextOffsetData(m *wasm.Module, withListener bool) ModuleContext
 		ret.GlobalsBegin = -1
 	}
 
-	if tables := len(m.TableSection) + int(m.ImportTableCount); tables > 0 {
+	tables := len(m.TableSection) + int(m.ImportTableCount)
+	if tables > 0 || withTypeIDs {
 		offset = align8(offset)
 		ret.TypeIDs1stElement = offset
 		offset += 8 // First element of TypeIDs.
+	} else {
+		ret.TypeIDs1stElement = -1
+	}
 
+	if tables > 0 {
 		ret.TablesBegin = offset
 		// Pointers to *wasm.TableInstance.
 		offset += Offset(tables) * 8
 	} else {
-		ret.TypeIDs1stElement = -1
 		ret.TablesBegin = -1
 	}
 
@@ -12,6 +12,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 		name         string
 		m            *wasm.Module
 		withListener bool
+		withTypeIDs  bool
 		exp          ModuleContextOffsetData
 	}{
 		{
@@ -33,7 +34,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 		},
 		{
 			name: "local mem",
-			m:    &wasm.Module{MemorySection: &wasm.Memory{}},
+			m:    &wasm.Module{MemorySection: []wasm.Memory{{}}},
 			exp: ModuleContextOffsetData{
 				LocalMemoryBegin:                    8,
 				ImportedMemoryBegin:                 -1,
@@ -65,6 +66,23 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TotalSize:                           48, // 16 byte alignment.
 			},
 		},
+		{
+			name: "multiple memories",
+			m:    &wasm.Module{ImportMemoryCount: 2, MemorySection: []wasm.Memory{{}, {}, {}}},
+			exp: ModuleContextOffsetData{
+				LocalMemoryBegin:                    8,
+				ImportedMemoryBegin:                 56,
+				ImportedFunctionsBegin:              -1,
+				GlobalsBegin:                        -1,
+				TypeIDs1stElement:                   -1,
+				TablesBegin:                         -1,
+				BeforeListenerTrampolines1stElement: -1,
+				AfterListenerTrampolines1stElement:  -1,
+				DataInstances1stElement:             88,
+				ElementInstances1stElement:          96,
+				TotalSize:                           112, // 16 byte alignment.
+			},
+		},
 		{
 			name: "imported func",
 			m:    &wasm.Module{ImportFunctionCount: 10},
@@ -106,7 +124,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				ImportFunctionCount: 10,
 				ImportTableCount:    5,
 				TableSection:        make([]wasm.Table, 10),
-				MemorySection:       &wasm.Memory{},
+				MemorySection:       []wasm.Memory{{}},
 				GlobalSection:       make([]wasm.Global, 20),
 			},
 			exp: ModuleContextOffsetData{
@@ -131,7 +149,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				ImportFunctionCount: 10,
 				ImportTableCount:    5,
 				TableSection:        make([]wasm.Table, 10),
-				MemorySection:       &wasm.Memory{},
+				MemorySection:       []wasm.Memory{{}},
 				GlobalSection:       make([]wasm.Global, 20),
 			},
 			withListener: true,
@@ -150,10 +168,42 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TotalSize:                           32 + 10*FunctionInstanceSize + 16*30 + 8 + 8*15 + 32,
 			},
 		},
+		{
+			name:        "type ids without tables",
+			m:           &wasm.Module{},
+			withTypeIDs: true,
+			exp: ModuleContextOffsetData{
+				LocalMemoryBegin:                    -1,
+				ImportedMemoryBegin:                 -1,
+				ImportedFunctionsBegin:              -1,
+				GlobalsBegin:                        -1,
+				TypeIDs1stElement:                   8,
+				TablesBegin:                         -1,
+				BeforeListenerTrampolines1stElement: -1,
+				AfterListenerTrampolines1stElement:  -1,
+				DataInstances1stElement:             16,
+				ElementInstances1stElement:          24,
+				TotalSize:                           32, // 16 byte alignment.
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			got := NewModuleContextOffsetData(tc.m, tc.withListener)
+			got := NewModuleContextOffsetData(tc.m, tc.withListener, tc.withTypeIDs)
 			require.Equal(t, tc.exp, got)
 		})
 	}
 }
+
+func TestModuleContextOffsetData_memories(t *testing.T) {
+	m := NewModuleContextOffsetData(&wasm.Module{ImportMemoryCount: 2, MemorySection: []wasm.Memory{{}, {}}}, false, false)
+	require.Equal(t, Offset(8), m.LocalMemoryBase(0))
+	require.Equal(t, Offset(16), m.LocalMemoryLen(0))
+	require.Equal(t, Offset(24), m.LocalMemoryBase(1))
+	require.Equal(t, Offset(32), m.LocalMemoryLen(1))
+	require.Equal(t, Offset(40), m.ImportedMemoryOffset(0))
+	require.Equal(t, Offset(56), m.ImportedMemoryOffset(1))
+
+	m = NewModuleContextOffsetData(&wasm.Module{}, false, false)
+	require.Equal(t, Offset(-1), m.LocalMemoryBase(0))
+	require.Equal(t, Offset(-1), m.LocalMemoryLen(0))
+}
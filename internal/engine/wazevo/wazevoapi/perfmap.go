@@ -7,90 +7,40 @@ import (
 	"sync"
 )
 
-var PerfMap *Perfmap
+// PerfMap writes the symbols of the machine code into the perf map file of the process, /tmp/perf-<pid>.map, which
+// is read by perf to attribute the samples in the code compiled at runtime.
+//
+// See https://github.com/torvalds/linux/blob/master/tools/perf/Documentation/jit-interface.txt
+type PerfMap struct {
+	mux sync.Mutex
+	fh  *os.File
+}
 
-func init() {
-	if PerfMapEnabled {
-		pid := os.Getpid()
-		filename := "/tmp/perf-" + strconv.Itoa(pid) + ".map"
+var perfMap struct {
+	once sync.Once
+	m    *PerfMap
+	err  error
+}
 
+// OpenPerfMap returns the PerfMap of the process, which is shared by all the engines.
+func OpenPerfMap() (*PerfMap, error) {
+	perfMap.once.Do(func() {
+		filename := "/tmp/perf-" + strconv.Itoa(os.Getpid()) + ".map"
 		fh, err := os.OpenFile(filename, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0o644)
 		if err != nil {
-			panic(err)
+			perfMap.err = fmt.Errorf("error opening perf map: %w", err)
+			return
 		}
-
-		PerfMap = &Perfmap{fh: fh}
-	}
-}
-
-// Perfmap holds perfmap entries to be flushed into a perfmap file.
-type Perfmap struct {
-	entries []entry
-	mux     sync.Mutex
-	fh      *os.File
-}
-
-type entry struct {
-	index  int
-	offset int64
-	size   uint64
-	name   string
-}
-
-func (f *Perfmap) Lock() {
-	f.mux.Lock()
+		perfMap.m = &PerfMap{fh: fh}
+	})
+	return perfMap.m, perfMap.err
 }
 
-func (f *Perfmap) Unlock() {
-	f.mux.Unlock()
-}
-
-// AddModuleEntry adds a perfmap entry into the perfmap file.
-// index is the index of the function in the module, offset is the offset of the function in the module,
-// size is the size of the function, and name is the name of the function.
+// AddEntry writes the symbol of the machine code of the given size at addr.
 //
-// Note that the entries are not flushed into the perfmap file until Flush is called,
-// and the entries are module-scoped; Perfmap must be locked until Flush is called.
-func (f *Perfmap) AddModuleEntry(index int, offset int64, size uint64, name string) {
-	e := entry{index: index, offset: offset, size: size, name: name}
-	if f.entries == nil {
-		f.entries = []entry{e}
-		return
-	}
-	f.entries = append(f.entries, e)
-}
-
-// Flush writes the perfmap entries into the perfmap file where the entries are adjusted by the given `addr` and `functionOffsets`.
-func (f *Perfmap) Flush(addr uintptr, functionOffsets []int) {
-	defer func() {
-		_ = f.fh.Sync()
-	}()
-
-	for _, e := range f.entries {
-		if _, err := f.fh.WriteString(fmt.Sprintf("%x %s %s\n",
-			uintptr(e.offset)+addr+uintptr(functionOffsets[e.index]),
-			strconv.FormatUint(e.size, 16),
-			e.name,
-		)); err != nil {
-			panic(err)
-		}
-	}
-	f.entries = f.entries[:0]
-}
-
-// Clear clears the perfmap entries not yet flushed.
-func (f *Perfmap) Clear() {
-	f.entries = f.entries[:0]
-}
-
-// AddEntry writes a perfmap entry directly into the perfmap file, not using the entries.
-func (f *Perfmap) AddEntry(addr uintptr, size uint64, name string) {
-	_, err := f.fh.WriteString(fmt.Sprintf("%x %s %s\n",
-		addr,
-		strconv.FormatUint(size, 16),
-		name,
-	))
-	if err != nil {
-		panic(err)
-	}
+// Note: The errors are ignored as the symbols only help profiling, which shouldn't fail the compilation.
+func (f *PerfMap) AddEntry(addr uintptr, size uint64, name string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	_, _ = fmt.Fprintf(f.fh, "%x %x %s\n", addr, size, name)
 }
@@ -1,5 +0,0 @@
-//go:build perfmap
-
-package wazevoapi
-
-const PerfMapEnabled = true
@@ -0,0 +1,19 @@
+package expctxkeys
+
+// CompilationWorkersKey is a context.Context Value key. Its associated value
+// should be an int, which is the maximum number of goroutines used to compile
+// the functions of a module.
+type CompilationWorkersKey struct{}
+
+// LazyCompilationKey is a context.Context Value key. Its associated value
+// should be a bool, which is true if the functions of a module are compiled
+// on their first call.
+type LazyCompilationKey struct{}
+
+// PerfMapKey is a context.Context Value key. Its associated value should be a
+// bool, which is true if the compiler writes the symbols into the perf map.
+type PerfMapKey struct{}
+
+// JITDumpKey is a context.Context Value key. Its associated value should be a
+// bool, which is true if the compiler writes the machine code into the jitdump.
+type JITDumpKey struct{}
@@ -0,0 +1,7 @@
+package expctxkeys
+
+// CoverageCollectorKey is a context.Context Value key.
+// Its associated value should be a coverage.Collector.
+//
+// See experimental/coverage.WithCollector.
+type CoverageCollectorKey struct{}
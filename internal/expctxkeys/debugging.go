@@ -0,0 +1,7 @@
+package expctxkeys
+
+// DebuggerKey is a context.Context Value key.
+// Its associated value should be a debugging.Debugger.
+//
+// See experimental/debugging.WithDebugger.
+type DebuggerKey struct{}
@@ -0,0 +1,9 @@
+package expctxkeys
+
+// FuelCostsKey is a context.Context Value key. Its associated value should be
+// a *FuelCosts, which enables fuel metering of the compiled module.
+type FuelCostsKey struct{}
+
+// FuelKey is a context.Context Value key. Its associated value should be a
+// *int64 holding the fuel remaining for function calls made with the context.
+type FuelKey struct{}
@@ -0,0 +1,7 @@
+package expctxkeys
+
+// SamplerKey is a context.Context Value key.
+// Its associated value should be a sampling.Sampler.
+//
+// See experimental/profiling.WithProfiler.
+type SamplerKey struct{}
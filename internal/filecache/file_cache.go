@@ -1,49 +1,185 @@
 package filecache
 
 import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"hash/crc32"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-// New returns a new Cache implemented by fileCache.
+// New returns a new Cache implemented by fileCache, which doesn't limit the size of dir.
+//
+// The files in dir are not indexed, as the index is only used for the eviction and DirCache.Size.
 func New(dir string) Cache {
-	return newFileCache(dir)
+	return newFileCache(dir, 0)
 }
 
-func newFileCache(dir string) *fileCache {
-	return &fileCache{dirPath: dir}
+// NewDir returns a new DirCache persisting entries into dir, which evicts the least recently used entries when
+// the total size of the entries exceeds maxBytes. Zero or negative maxBytes means unlimited.
+func NewDir(dir string, maxBytes int64) (DirCache, error) {
+	fc := newFileCache(dir, maxBytes)
+	if err := fc.load(); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// DirCache is a Cache persisting entries into a directory.
+type DirCache interface {
+	Cache
+
+	// Size returns the total size of the entries in bytes.
+	Size() int64
+
+	// Prune removes the corrupted entries and the leftovers of the interrupted writes, and then evicts the least
+	// recently used entries until the total size fits the limit. This also picks up the entries added by other
+	// processes sharing the directory.
+	Prune() error
+}
+
+func newFileCache(dir string, maxBytes int64) *fileCache {
+	return &fileCache{dirPath: dir, maxBytes: maxBytes, entries: map[Key]*list.Element{}, lru: list.New()}
 }
 
 // fileCache persists compiled functions into dirPath.
 //
-// Note: this can be expanded to do binary signing/verification, set TTL on each entry, etc.
+// Each file consists of the header and the content, where the header holds the checksum of the content
+// so that the corrupted entries can be detected on Get.
 type fileCache struct {
-	dirPath string
+	dirPath  string
+	maxBytes int64
+
+	mux sync.Mutex
+	// entries maps the keys to the elements of lru, whose values are *fileCacheEntry.
+	entries map[Key]*list.Element
+	// lru holds the entries ordered from the most recently used to the least recently used.
+	lru *list.List
+	// size is the total size of the files in lru.
+	size int64
 }
 
+// fileCacheEntry is the element of fileCache.lru.
+type fileCacheEntry struct {
+	key  Key
+	size int64
+}
+
+// fileHeaderMagic is the first bytes of each file, followed by the CRC-32 of the content.
+var fileHeaderMagic = []byte{'W', 'Z', 'F', 'C'}
+
+const fileHeaderSize = 8
+
+// staleTmpFileAge is the age after which Prune considers the temporary files as the leftovers of interrupted writes.
+const staleTmpFileAge = time.Hour
+
+var crc = crc32.MakeTable(crc32.Castagnoli)
+
 func (fc *fileCache) path(key Key) string {
 	return path.Join(fc.dirPath, hex.EncodeToString(key[:]))
 }
 
+// load builds the index from the files in dirPath ordered by their modification time.
+func (fc *fileCache) load() error {
+	dirEntries, err := os.ReadDir(fc.dirPath)
+	if err != nil {
+		return err
+	}
+
+	type file struct {
+		key     Key
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	for _, e := range dirEntries {
+		key, ok := parseKey(e.Name())
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Deleted concurrently.
+		} else if err != nil {
+			return err
+		}
+		files = append(files, file{key: key, size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	fc.entries = make(map[Key]*list.Element, len(files))
+	fc.lru.Init()
+	fc.size = 0
+	for _, f := range files {
+		fc.entries[f.key] = fc.lru.PushBack(&fileCacheEntry{key: f.key, size: f.size})
+		fc.size += f.size
+	}
+	return nil
+}
+
+func parseKey(name string) (key Key, ok bool) {
+	if len(name) != hex.EncodedLen(len(key)) {
+		return
+	}
+	_, err := hex.Decode(key[:], []byte(name))
+	return key, err == nil
+}
+
 func (fc *fileCache) Get(key Key) (content io.ReadCloser, ok bool, err error) {
-	f, err := os.Open(fc.path(key))
+	p := fc.path(key)
+	b, err := os.ReadFile(p)
 	if errors.Is(err, os.ErrNotExist) {
+		fc.forget(key)
 		return nil, false, nil
 	} else if err != nil {
 		return nil, false, err
-	} else {
-		return f, true, nil
 	}
+
+	body, valid := verify(b)
+	if !valid {
+		// Treat the corrupted entry as a miss, so that the module is recompiled and the entry is overwritten.
+		return nil, false, fc.Delete(key)
+	}
+
+	// The modification time records the last use, so that the order is restored by load.
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	fc.touch(key, int64(len(b)))
+	return io.NopCloser(bytes.NewReader(body)), true, nil
+}
+
+// verify returns the content of the file b if its checksum is valid.
+func verify(b []byte) (body []byte, ok bool) {
+	if len(b) < fileHeaderSize || !bytes.Equal(b[:len(fileHeaderMagic)], fileHeaderMagic) {
+		return nil, false
+	}
+	body = b[fileHeaderSize:]
+	return body, binary.LittleEndian.Uint32(b[len(fileHeaderMagic):fileHeaderSize]) == crc32.Checksum(body, crc)
 }
 
 func (fc *fileCache) Add(key Key, content io.Reader) (err error) {
 	path := fc.path(key)
 	dirPath, fileName := filepath.Split(path)
 
+	body, err := io.ReadAll(content)
+	if err != nil {
+		return
+	}
+	header := make([]byte, fileHeaderSize)
+	copy(header, fileHeaderMagic)
+	binary.LittleEndian.PutUint32(header[len(fileHeaderMagic):], crc32.Checksum(body, crc))
+
 	file, err := os.CreateTemp(dirPath, fileName+".*.tmp")
 	if err != nil {
 		return
@@ -54,7 +190,10 @@ func (fc *fileCache) Add(key Key, content io.Reader) (err error) {
 			_ = os.Remove(file.Name())
 		}
 	}()
-	if _, err = io.Copy(file, content); err != nil {
+	if _, err = file.Write(header); err != nil {
+		return
+	}
+	if _, err = file.Write(body); err != nil {
 		return
 	}
 	if err = file.Sync(); err != nil {
@@ -63,8 +202,12 @@ func (fc *fileCache) Add(key Key, content io.Reader) (err error) {
 	if err = file.Close(); err != nil {
 		return
 	}
-	err = os.Rename(file.Name(), path)
-	return
+	if err = os.Rename(file.Name(), path); err != nil {
+		return
+	}
+
+	fc.touch(key, int64(len(header)+len(body)))
+	return fc.evict()
 }
 
 func (fc *fileCache) Delete(key Key) (err error) {
@@ -72,5 +215,96 @@ func (fc *fileCache) Delete(key Key) (err error) {
 	if errors.Is(err, os.ErrNotExist) {
 		err = nil
 	}
+	fc.forget(key)
 	return
 }
+
+// Size implements DirCache.Size.
+func (fc *fileCache) Size() int64 {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	return fc.size
+}
+
+// Prune implements DirCache.Prune.
+func (fc *fileCache) Prune() error {
+	dirEntries, err := os.ReadDir(fc.dirPath)
+	if err != nil {
+		return err
+	}
+	for _, e := range dirEntries {
+		name := e.Name()
+		p := path.Join(fc.dirPath, name)
+		if strings.HasSuffix(name, ".tmp") {
+			if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > staleTmpFileAge {
+				_ = os.Remove(p)
+			}
+			continue
+		}
+		key, ok := parseKey(name)
+		if !ok {
+			continue
+		}
+		b, err := os.ReadFile(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if _, valid := verify(b); !valid {
+			if err = fc.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	if err = fc.load(); err != nil {
+		return err
+	}
+	return fc.evict()
+}
+
+// touch records the use of the entry for key whose file has the given size.
+func (fc *fileCache) touch(key Key, size int64) {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	if elem, ok := fc.entries[key]; ok {
+		entry := elem.Value.(*fileCacheEntry)
+		fc.size += size - entry.size
+		entry.size = size
+		fc.lru.MoveToFront(elem)
+	} else {
+		fc.entries[key] = fc.lru.PushFront(&fileCacheEntry{key: key, size: size})
+		fc.size += size
+	}
+}
+
+// forget removes the entry for key from the index.
+func (fc *fileCache) forget(key Key) {
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	if elem, ok := fc.entries[key]; ok {
+		fc.size -= elem.Value.(*fileCacheEntry).size
+		fc.lru.Remove(elem)
+		delete(fc.entries, key)
+	}
+}
+
+// evict removes the least recently used entries until the total size fits maxBytes.
+func (fc *fileCache) evict() error {
+	if fc.maxBytes <= 0 {
+		return nil
+	}
+	fc.mux.Lock()
+	defer fc.mux.Unlock()
+	for fc.size > fc.maxBytes {
+		elem := fc.lru.Back()
+		entry := elem.Value.(*fileCacheEntry)
+		if err := os.Remove(fc.path(entry.key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		fc.size -= entry.size
+		fc.lru.Remove(elem)
+		delete(fc.entries, entry.key)
+	}
+	return nil
+}
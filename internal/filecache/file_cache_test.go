@@ -2,15 +2,19 @@ package filecache
 
 import (
 	"bytes"
+	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"os"
+	"path"
 	"testing"
+	"time"
 
 	"github.com/tetratelabs/wazero/internal/testing/require"
 )
 
 func TestFileCache_Add(t *testing.T) {
-	fc := newFileCache(t.TempDir())
+	fc := newFileCache(t.TempDir(), 0)
 
 	t.Run("not exist", func(t *testing.T) {
 		content := []byte{1, 2, 3, 4, 5}
@@ -23,7 +27,7 @@ func TestFileCache_Add(t *testing.T) {
 		require.NoError(t, err)
 
 		// Check if the saved content is the same as the given one.
-		require.Equal(t, content, cached)
+		require.Equal(t, encodeFile(content), cached)
 	})
 
 	t.Run("already exists", func(t *testing.T) {
@@ -35,7 +39,7 @@ func TestFileCache_Add(t *testing.T) {
 		p := fc.path(id)
 		f, err := os.Create(p)
 		require.NoError(t, err)
-		_, err = f.Write(content)
+		_, err = f.Write(encodeFile(content))
 		require.NoError(t, err)
 		require.NoError(t, f.Close())
 
@@ -47,12 +51,12 @@ func TestFileCache_Add(t *testing.T) {
 		require.NoError(t, err)
 
 		// Check if the saved content is the same as the given one.
-		require.Equal(t, content, cached)
+		require.Equal(t, encodeFile(content), cached)
 	})
 }
 
 func TestFileCache_Delete(t *testing.T) {
-	fc := newFileCache(t.TempDir())
+	fc := newFileCache(t.TempDir(), 0)
 	t.Run("non-exist", func(t *testing.T) {
 		id := Key{0}
 		err := fc.Delete(id)
@@ -81,7 +85,7 @@ func TestFileCache_Delete(t *testing.T) {
 }
 
 func TestFileCache_Get(t *testing.T) {
-	fc := newFileCache(t.TempDir())
+	fc := newFileCache(t.TempDir(), 0)
 
 	t.Run("exist", func(t *testing.T) {
 		content := []byte{1, 2, 3, 4, 5}
@@ -91,7 +95,7 @@ func TestFileCache_Get(t *testing.T) {
 		p := fc.path(id)
 		f, err := os.Create(p)
 		require.NoError(t, err)
-		_, err = f.Write(content)
+		_, err = f.Write(encodeFile(content))
 		require.NoError(t, err)
 		require.NoError(t, f.Close())
 
@@ -120,3 +124,133 @@ func TestFileCache_path(t *testing.T) {
 	actual := fc.path(Key{1, 2, 3, 4, 5})
 	require.Equal(t, "/tmp/.wazero/0102030405000000000000000000000000000000000000000000000000000000", actual)
 }
+
+func TestFileCache_Get_corrupted(t *testing.T) {
+	fc := newFileCache(t.TempDir(), 0)
+
+	id := Key{1, 2, 3}
+	require.NoError(t, fc.Add(id, bytes.NewReader([]byte{1, 2, 3, 4, 5})))
+
+	// Flip a bit of the content.
+	p := fc.path(id)
+	b, err := os.ReadFile(p)
+	require.NoError(t, err)
+	b[len(b)-1] ^= 1
+	require.NoError(t, os.WriteFile(p, b, 0o600))
+
+	// The corrupted entry is a miss, and is deleted.
+	_, ok, err := fc.Get(id)
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, err = os.Stat(p)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.Equal(t, int64(0), fc.Size())
+}
+
+func TestFileCache_evict(t *testing.T) {
+	const entrySize = fileHeaderSize + 10
+	fc := newFileCache(t.TempDir(), 2*entrySize)
+
+	content := make([]byte, 10)
+	a, b, c := Key{1}, Key{2}, Key{3}
+	require.NoError(t, fc.Add(a, bytes.NewReader(content)))
+	require.NoError(t, fc.Add(b, bytes.NewReader(content)))
+	require.Equal(t, int64(2*entrySize), fc.Size())
+
+	// Use a so that b becomes the least recently used.
+	_, ok, err := fc.Get(a)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, fc.Add(c, bytes.NewReader(content)))
+	require.Equal(t, int64(2*entrySize), fc.Size())
+	for _, tc := range []struct {
+		key      Key
+		expected bool
+	}{{a, true}, {b, false}, {c, true}} {
+		_, ok, err = fc.Get(tc.key)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, ok)
+	}
+
+	// An entry larger than the limit is not kept.
+	require.NoError(t, fc.Add(b, bytes.NewReader(make([]byte, 3*entrySize))))
+	_, ok, err = fc.Get(b)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestNewDir(t *testing.T) {
+	dir := t.TempDir()
+	fc := newFileCache(dir, 0)
+	content := make([]byte, 10)
+	require.NoError(t, fc.Add(Key{1}, bytes.NewReader(content)))
+	require.NoError(t, fc.Add(Key{2}, bytes.NewReader(content)))
+	// Make Key{1} the least recently used one on the file system.
+	old := time.Now().Add(-time.Minute)
+	require.NoError(t, os.Chtimes(fc.path(Key{1}), old, old))
+	// Unrelated files are ignored.
+	require.NoError(t, os.WriteFile(path.Join(dir, "README"), content, 0o600))
+
+	// New doesn't index the existing files, but still reads them.
+	c := New(dir)
+	require.Equal(t, int64(0), c.(*fileCache).Size())
+	_, ok, err := c.Get(Key{1})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, os.Chtimes(fc.path(Key{1}), old, old))
+
+	dc, err := NewDir(dir, fileHeaderSize+10)
+	require.NoError(t, err)
+	require.Equal(t, int64(2*(fileHeaderSize+10)), dc.Size())
+
+	// The limit is applied on the next write, which evicts Key{1}.
+	require.NoError(t, dc.Add(Key{2}, bytes.NewReader(content)))
+	require.Equal(t, int64(fileHeaderSize+10), dc.Size())
+	_, err = os.Stat(fc.path(Key{1}))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = NewDir(path.Join(dir, "non-existent"), 0)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileCache_Prune(t *testing.T) {
+	dir := t.TempDir()
+	fc := newFileCache(dir, 0)
+	content := make([]byte, 10)
+	require.NoError(t, fc.Add(Key{1}, bytes.NewReader(content)))
+
+	// Written by another process.
+	other := newFileCache(dir, 0)
+	require.NoError(t, other.Add(Key{2}, bytes.NewReader(content)))
+	// Corrupted.
+	require.NoError(t, os.WriteFile(fc.path(Key{3}), content, 0o600))
+	// Leftovers of interrupted writes.
+	staleTmp, freshTmp := fc.path(Key{4})+".1.tmp", fc.path(Key{5})+".2.tmp"
+	require.NoError(t, os.WriteFile(staleTmp, content, 0o600))
+	old := time.Now().Add(-2 * staleTmpFileAge)
+	require.NoError(t, os.Chtimes(staleTmp, old, old))
+	require.NoError(t, os.WriteFile(freshTmp, content, 0o600))
+
+	require.NoError(t, fc.Prune())
+	require.Equal(t, int64(2*(fileHeaderSize+10)), fc.Size())
+	for _, p := range []string{fc.path(Key{3}), staleTmp} {
+		_, err := os.Stat(p)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+	_, err := os.Stat(freshTmp)
+	require.NoError(t, err)
+
+	// With the limit, the least recently used entries are evicted.
+	fc.maxBytes = fileHeaderSize + 10
+	require.NoError(t, fc.Prune())
+	require.Equal(t, int64(fileHeaderSize+10), fc.Size())
+}
+
+// encodeFile returns the file content of the entry whose content is b.
+func encodeFile(b []byte) []byte {
+	header := make([]byte, fileHeaderSize)
+	copy(header, fileHeaderMagic)
+	binary.LittleEndian.PutUint32(header[len(fileHeaderMagic):], crc32.Checksum(b, crc))
+	return append(header, b...)
+}
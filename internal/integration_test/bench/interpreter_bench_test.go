@@ -0,0 +1,80 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// interpreterKernels are the loop bodies in the same form as optimizationKernels, each of which exercises the
+// superinstructions of the interpreter.
+var interpreterKernels = []struct {
+	name string
+	body []byte
+}{
+	{
+		// acc = acc + ((i*x) ^ (i << 3)) - (x >> 1), which is fused into the arithmetic on locals.
+		name: "arithmetic",
+		body: []byte{
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Mul,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 3, wasm.OpcodeI32Shl,
+			wasm.OpcodeI32Xor,
+			wasm.OpcodeLocalGet, 2, wasm.OpcodeI32Add,
+			wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 1, wasm.OpcodeI32ShrU,
+			wasm.OpcodeI32Sub,
+			wasm.OpcodeLocalSet, 2,
+		},
+	},
+	{
+		// if i < x { acc += 1 }; if (i & 1) == 0 { acc += 3 }, which is fused into the comparisons and branches.
+		name: "compare_branch",
+		body: []byte{
+			wasm.OpcodeBlock, 0x40,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32GeU, wasm.OpcodeBrIf, 0,
+			wasm.OpcodeLocalGet, 2, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeLocalSet, 2,
+			wasm.OpcodeEnd,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32And,
+			wasm.OpcodeI32Eqz,
+			wasm.OpcodeIf, 0x40,
+			wasm.OpcodeLocalGet, 2, wasm.OpcodeI32Const, 3, wasm.OpcodeI32Add, wasm.OpcodeLocalSet, 2,
+			wasm.OpcodeEnd,
+		},
+	},
+}
+
+// BenchmarkInterpreter measures the CPU-bound loops run by the interpreter, which benefit from its superinstructions.
+func BenchmarkInterpreter(b *testing.B) {
+	const iterations, x = 100_000, 12345
+	kernels := append(interpreterKernels[:len(interpreterKernels):len(interpreterKernels)], optimizationKernels...)
+	for _, k := range kernels {
+		bin := optimizationKernelWasm(k.body)
+		// The result of the compiler is used to check that the superinstructions are correct.
+		var expected uint64
+		if platform.CompilerSupported() {
+			expected = runOptimizationKernel(b, wazero.NewRuntimeConfigCompiler(), bin, iterations, x)
+		}
+		b.Run(k.name, func(b *testing.B) {
+			r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigInterpreter())
+			defer r.Close(testCtx)
+			m, err := r.Instantiate(testCtx, bin)
+			if err != nil {
+				b.Fatal(err)
+			}
+			initOptimizationKernelMemory(m)
+			run := m.ExportedFunction("run")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				res, err := run.Call(testCtx, iterations, x)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if platform.CompilerSupported() && res[0] != expected {
+					b.Fatalf("expected %d, but got %d", expected, res[0])
+				}
+			}
+		})
+	}
+}
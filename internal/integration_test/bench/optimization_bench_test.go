@@ -0,0 +1,169 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// optimizationKernels are the bodies of the loops, each of which updates the accumulator in the local 2 with the loop
+// counter in the local 0 and the param in the local 1. Each one exercises a specific optimization of the compiler.
+var optimizationKernels = []struct {
+	name string
+	body []byte
+}{
+	{
+		// acc += (x*31 + x) ^ i, where x*31 + x is loop invariant.
+		name: "loop_invariant",
+		body: []byte{
+			wasm.OpcodeLocalGet, 2,
+			wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 31, wasm.OpcodeI32Mul,
+			wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Xor,
+			wasm.OpcodeI32Add,
+			wasm.OpcodeLocalSet, 2,
+		},
+	},
+	{
+		// acc += (i*x + x) * (i*x + x), where i*x + x is computed twice.
+		name: "common_subexpression",
+		body: []byte{
+			wasm.OpcodeLocalGet, 2,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Mul,
+			wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Mul,
+			wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add,
+			wasm.OpcodeI32Mul,
+			wasm.OpcodeI32Add,
+			wasm.OpcodeLocalSet, 2,
+		},
+	},
+	{
+		// acc += i*(4*4) + i/8 + (i+0), which is simplified to shifts.
+		name: "constant_folding",
+		body: []byte{
+			wasm.OpcodeLocalGet, 2,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 4, wasm.OpcodeI32Const, 4, wasm.OpcodeI32Mul, wasm.OpcodeI32Mul,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 8, wasm.OpcodeI32DivU,
+			wasm.OpcodeI32Add,
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 0, wasm.OpcodeI32Add,
+			wasm.OpcodeI32Add,
+			wasm.OpcodeI32Add,
+			wasm.OpcodeLocalSet, 2,
+		},
+	},
+	{
+		// acc += mem[i & 0xffff] * x, which resembles the inner loops of checksums and codecs.
+		name: "memory_checksum",
+		body: concat(
+			[]byte{wasm.OpcodeLocalGet, 2, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const},
+			leb128.EncodeInt32(0xffff),
+			[]byte{
+				wasm.OpcodeI32And,
+				wasm.OpcodeI32Load8U, 0, 0,
+				wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Mul,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeLocalSet, 2,
+			},
+		),
+	},
+}
+
+// optimizationKernelWasm returns the module exporting "run" of type (n i32, x i32) -> i32, which runs the kernel
+// body n times and returns the accumulator.
+func optimizationKernelWasm(body []byte) []byte {
+	return binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{{
+			Params:  []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI32},
+			Results: []wasm.ValueType{wasm.ValueTypeI32},
+		}},
+		FunctionSection: []wasm.Index{0},
+		MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true}},
+		CodeSection: []wasm.Code{{
+			LocalTypes: []wasm.ValueType{wasm.ValueTypeI32},
+			Body: concat(
+				[]byte{wasm.OpcodeLoop, 0x40},
+				body,
+				[]byte{
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub,
+					wasm.OpcodeLocalTee, 0,
+					wasm.OpcodeBrIf, 0,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLocalGet, 2,
+					wasm.OpcodeEnd,
+				},
+			),
+		}},
+		ExportSection: []wasm.Export{{Name: "run", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+}
+
+func concat(bs ...[]byte) (ret []byte) {
+	for _, b := range bs {
+		ret = append(ret, b...)
+	}
+	return
+}
+
+// BenchmarkOptimizations measures the CPU-bound loops which benefit from the optimization passes of the compiler.
+func BenchmarkOptimizations(b *testing.B) {
+	if !platform.CompilerSupported() {
+		b.Skip()
+	}
+
+	const iterations, x = 100_000, 12345
+	for _, k := range optimizationKernels {
+		bin := optimizationKernelWasm(k.body)
+		// The result of the interpreter is used to check that the optimized code is correct.
+		expected := runOptimizationKernel(b, wazero.NewRuntimeConfigInterpreter(), bin, iterations, x)
+		b.Run(k.name, func(b *testing.B) {
+			r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigCompiler())
+			defer r.Close(testCtx)
+			m, err := r.Instantiate(testCtx, bin)
+			if err != nil {
+				b.Fatal(err)
+			}
+			initOptimizationKernelMemory(m)
+			run := m.ExportedFunction("run")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				res, err := run.Call(testCtx, iterations, x)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if res[0] != expected {
+					b.Fatalf("expected %d, but got %d", expected, res[0])
+				}
+			}
+		})
+	}
+}
+
+func runOptimizationKernel(b *testing.B, config wazero.RuntimeConfig, bin []byte, iterations, x uint64) uint64 {
+	r := wazero.NewRuntimeWithConfig(testCtx, config)
+	defer r.Close(testCtx)
+	m, err := r.Instantiate(testCtx, bin)
+	if err != nil {
+		b.Fatal(err)
+	}
+	initOptimizationKernelMemory(m)
+	res, err := m.ExportedFunction("run").Call(testCtx, iterations, x)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return res[0]
+}
+
+// initOptimizationKernelMemory fills the memory with the arbitrary but deterministic bytes.
+func initOptimizationKernelMemory(m api.Module) {
+	mem := m.Memory()
+	for i := uint32(0); i < mem.Size(); i++ {
+		mem.WriteByte(i, byte(i*31+7))
+	}
+}
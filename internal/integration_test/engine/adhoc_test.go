@@ -55,6 +55,9 @@ var tests = map[string]testCase{
 	"ensures invocations terminate on module close":                    {f: testEnsureTerminationOnClose},
 	"call host function indirectly":                                    {f: callHostFunctionIndirect},
 	"lookup function":                                                  {f: testLookupFunction},
+	"exported table":                                                   {f: testExportedTable},
+	"host module memory, globals and tables":                           {f: testHostModuleMemoryGlobalsTables},
+	"fuel metering":                                                    {f: testFuelMetering},
 	"memory grow in recursive call":                                    {f: testMemoryGrowInRecursiveCall},
 	"call":                                                             {f: testCall},
 	"module memory":                                                    {f: testModuleMemory},
@@ -62,6 +65,7 @@ var tests = map[string]testCase{
 	"before listener globals":                                          {f: testBeforeListenerGlobals},
 	"before listener stack iterator":                                   {f: testBeforeListenerStackIterator},
 	"before listener stack iterator offsets":                           {f: testListenerStackIteratorOffset},
+	"inlined function":                                                 {f: testInlinedFunction},
 	"many params many results / doubler":                               {f: testManyParamsResultsDoubler},
 	"many params many results / doubler / listener":                    {f: testManyParamsResultsDoublerListener},
 	"many params many results / call_many_consts":                      {f: testManyParamsResultsCallManyConsts},
@@ -84,10 +88,24 @@ func TestEngineCompiler(t *testing.T) {
 	runAllTests(t, tests, wazero.NewRuntimeConfigCompiler().WithCloseOnContextDone(true), false)
 }
 
+func TestEngineCompiler_lazy(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, tests, wazero.NewRuntimeConfigCompiler().WithCloseOnContextDone(true).WithLazyCompilation(true), false)
+}
+
 func TestEngineInterpreter(t *testing.T) {
 	runAllTests(t, tests, wazero.NewRuntimeConfigInterpreter().WithCloseOnContextDone(true), false)
 }
 
+func TestEngineTiered(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, tests, wazero.NewRuntimeConfigTiered().WithCloseOnContextDone(true), false)
+}
+
 type arbitrary struct{}
 
 // testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
@@ -855,7 +873,7 @@ func testMultipleInstantiation(t *testing.T, r wazero.Runtime) {
 	bin := binaryencoding.EncodeModule(&wasm.Module{
 		TypeSection:     []wasm.FunctionType{{}},
 		FunctionSection: []wasm.Index{0},
-		MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+		MemorySection:   []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
 		CodeSection: []wasm.Code{{
 			Body: []byte{
 				wasm.OpcodeI32Const, 1, // i32.const 1    ;; memory offset
@@ -956,6 +974,455 @@ func testLookupFunction(t *testing.T, r wazero.Runtime) {
 	})
 }
 
+func testExportedTable(t *testing.T, r wazero.Runtime) {
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+		WithFunc(func() uint32 { return 7 }).Export("seven").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	other, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeI32Const, 8, wasm.OpcodeEnd}}},
+		ExportSection:   []wasm.Export{{Name: "eight", Type: wasm.ExternTypeFunc, Index: 0}},
+		NameSection:     &wasm.NameSection{ModuleName: "other"},
+	}))
+	require.NoError(t, err)
+
+	max := uint32(5)
+	inst, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		},
+		ImportSection:       []wasm.Import{{Module: "env", Name: "seven", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+		ImportFunctionCount: 1,
+		FunctionSection:     []wasm.Index{0, 1},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeEnd}},
+			// call_indirect: (offset) -> the result of calling the function at offset of the table.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCallIndirect, 0, 0, wasm.OpcodeEnd}},
+		},
+		TableSection: []wasm.Table{
+			{Min: 3, Max: &max, Type: wasm.RefTypeFuncref},
+			{Min: 1, Type: wasm.RefTypeExternref},
+		},
+		ElementSection: []wasm.ElementSegment{
+			{
+				OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+				TableIndex: 0,
+				Init:       []wasm.Index{1, 0},
+				Type:       wasm.RefTypeFuncref,
+			},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "call_indirect", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+			{Name: "externs", Type: wasm.ExternTypeTable, Index: 1},
+		},
+	}))
+	require.NoError(t, err)
+
+	callIndirect := func(offset uint32) (uint64, error) {
+		res, err := inst.ExportedFunction("call_indirect").Call(testCtx, uint64(offset))
+		if err != nil {
+			return 0, err
+		}
+		return res[0], nil
+	}
+
+	require.Nil(t, inst.ExportedTable("missing"))
+	defs := inst.ExportedTableDefinitions()
+	require.Equal(t, 2, len(defs))
+
+	tbl := inst.ExportedTable("table")
+	def := tbl.Definition()
+	require.Equal(t, []string{"table"}, def.ExportNames())
+	require.Equal(t, api.ValueTypeFuncref, def.RefType())
+	require.Equal(t, uint32(3), def.Min())
+	defMax, ok := def.Max()
+	require.True(t, ok)
+	require.Equal(t, uint32(5), defMax)
+	require.Equal(t, def, defs["table"])
+
+	t.Run("get and set", func(t *testing.T) {
+		require.Equal(t, uint32(3), tbl.Size())
+
+		_, err := tbl.Get(3)
+		require.EqualError(t, err, "offset 3 out of range of table size 3")
+		require.EqualError(t, tbl.Set(3, 0), "offset 3 out of range of table size 3")
+
+		v, err := tbl.Get(2)
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), v)
+
+		// A non-null funcref can't be set, as it may not be a valid function.
+		v, err = tbl.Get(0)
+		require.NoError(t, err)
+		require.NotEqual(t, uint64(0), v)
+		require.EqualError(t, tbl.Set(2, v), fmt.Sprintf("non-null funcref %#x must be set by SetFunction", v))
+		require.EqualError(t, tbl.Set(2, 42), "non-null funcref 0x2a must be set by SetFunction")
+		_, err = callIndirect(2)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeInvalidTableAccess)
+
+		f, err := tbl.Function(0)
+		require.NoError(t, err)
+		require.NoError(t, tbl.SetFunction(2, f))
+		res, err := callIndirect(2)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), res)
+
+		require.NoError(t, tbl.Set(2, 0))
+		_, err = callIndirect(2)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeInvalidTableAccess)
+	})
+
+	t.Run("function", func(t *testing.T) {
+		f, err := tbl.Function(0)
+		require.NoError(t, err)
+		res, err := f.Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), res[0])
+
+		// The host function is looked up from the table, as it cannot be exported from a host module.
+		f, err = tbl.Function(1)
+		require.NoError(t, err)
+		require.Equal(t, "env.seven", f.Definition().DebugName())
+		res, err = f.Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(7), res[0])
+
+		f, err = tbl.Function(2)
+		require.NoError(t, err)
+		require.Nil(t, f)
+
+		_, err = tbl.Function(3)
+		require.EqualError(t, err, "offset 3 out of range of table size 3")
+	})
+
+	t.Run("set function", func(t *testing.T) {
+		seven, err := tbl.Function(1)
+		require.NoError(t, err)
+
+		for _, tc := range []struct {
+			name     string
+			fn       api.Function
+			expected uint64
+		}{
+			{name: "host", fn: seven, expected: 7},
+			{name: "other module", fn: other.ExportedFunction("eight"), expected: 8},
+			{name: "same module", fn: inst.ExportedFunction("call_indirect")},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				require.NoError(t, tbl.SetFunction(2, tc.fn))
+				f, err := tbl.Function(2)
+				require.NoError(t, err)
+				require.Equal(t, tc.fn.Definition().DebugName(), f.Definition().DebugName())
+				if tc.expected != 0 {
+					res, err := callIndirect(2)
+					require.NoError(t, err)
+					require.Equal(t, tc.expected, res)
+				} else {
+					_, err := callIndirect(2)
+					require.ErrorIs(t, err, wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+				}
+			})
+		}
+
+		require.NoError(t, tbl.SetFunction(2, nil))
+		_, err = callIndirect(2)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeInvalidTableAccess)
+	})
+
+	t.Run("grow", func(t *testing.T) {
+		ref, err := tbl.Get(0)
+		require.NoError(t, err)
+		_, err = tbl.Grow(2, ref)
+		require.EqualError(t, err, fmt.Sprintf("non-null funcref %#x must be set by SetFunction", ref))
+		require.Equal(t, uint32(3), tbl.Size())
+
+		prev, err := tbl.Grow(2, 0)
+		require.NoError(t, err)
+		require.Equal(t, uint32(3), prev)
+		require.Equal(t, uint32(5), tbl.Size())
+		_, err = callIndirect(4)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeInvalidTableAccess)
+
+		f, err := tbl.Function(0)
+		require.NoError(t, err)
+		require.NoError(t, tbl.SetFunction(4, f))
+		res, err := callIndirect(4)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), res)
+
+		_, err = tbl.Grow(1, 0)
+		require.EqualError(t, err, "cannot grow table of size 5 by 1 elements")
+		require.Equal(t, uint32(5), tbl.Size())
+	})
+
+	t.Run("externref", func(t *testing.T) {
+		externs := inst.ExportedTable("externs")
+		require.Equal(t, api.ValueTypeExternref, externs.Definition().RefType())
+
+		require.NoError(t, externs.Set(0, 42))
+		v, err := externs.Get(0)
+		require.NoError(t, err)
+		require.Equal(t, uint64(42), v)
+
+		_, err = externs.Function(0)
+		require.EqualError(t, err, "table of externref is not a table of funcref")
+		err = externs.SetFunction(0, other.ExportedFunction("eight"))
+		require.EqualError(t, err, "table of externref is not a table of funcref")
+	})
+
+	t.Run("invalid function", func(t *testing.T) {
+		r2 := wazero.NewRuntime(testCtx)
+		defer r2.Close(testCtx)
+		foreign, err := r2.Instantiate(testCtx, binaryencoding.EncodeModule(&wasm.Module{
+			TypeSection:     []wasm.FunctionType{{Results: []wasm.ValueType{i32}}},
+			FunctionSection: []wasm.Index{0},
+			CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeI32Const, 9, wasm.OpcodeEnd}}},
+			ExportSection:   []wasm.Export{{Name: "nine", Type: wasm.ExternTypeFunc, Index: 0}},
+			NameSection:     &wasm.NameSection{ModuleName: "foreign"},
+		}))
+		require.NoError(t, err)
+		err = tbl.SetFunction(2, foreign.ExportedFunction("nine"))
+		require.EqualError(t, err, "function foreign.$0 is defined by a module of another runtime")
+
+		eight := other.ExportedFunction("eight")
+		require.NoError(t, other.Close(testCtx))
+		err = tbl.SetFunction(2, eight)
+		require.EqualError(t, err, "function other.$0 is defined by a closed module")
+	})
+}
+
+func testHostModuleMemoryGlobalsTables(t *testing.T, r wazero.Runtime) {
+	host, err := r.NewHostModuleBuilder("env").
+		NewMemoryBuilder().WithMinPages(1).WithMaxPages(2).Export("memory").
+		NewGlobalBuilder().WithValue(api.ValueTypeI32, api.EncodeU32(1024)).WithMutable().Export("__stack_pointer").
+		NewGlobalBuilder().WithValue(api.ValueTypeF64, api.EncodeF64(1.5)).Export("pi").
+		NewTableBuilder().WithMin(2).Export("table").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	inst, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32, i32}},
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{f64}},
+		},
+		ImportSection: []wasm.Import{
+			{Module: "env", Name: "memory", Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1, Max: 2, IsMaxEncoded: true}},
+			{Module: "env", Name: "__stack_pointer", Type: wasm.ExternTypeGlobal, DescGlobal: wasm.GlobalType{ValType: i32, Mutable: true}},
+			{Module: "env", Name: "pi", Type: wasm.ExternTypeGlobal, DescGlobal: wasm.GlobalType{ValType: f64}},
+			{Module: "env", Name: "table", Type: wasm.ExternTypeTable, DescTable: wasm.Table{Min: 2, Type: wasm.RefTypeFuncref}},
+		},
+		ImportMemoryCount: 1,
+		ImportGlobalCount: 2,
+		ImportTableCount:  1,
+		FunctionSection:   []wasm.Index{0, 1, 2, 2, 3, 4, 1, 2},
+		CodeSection: []wasm.Code{
+			// store: (addr, val) -> stores the byte val at addr.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Store8, 0, 0, wasm.OpcodeEnd}},
+			// load: (addr) -> the byte at addr.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load8U, 0, 0, wasm.OpcodeEnd}},
+			// grow: () -> the result of growing the memory by one page.
+			{Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeEnd}},
+			// get_sp: () -> __stack_pointer.
+			{Body: []byte{wasm.OpcodeGlobalGet, 0, wasm.OpcodeEnd}},
+			// set_sp: (v) -> sets __stack_pointer to v.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeGlobalSet, 0, wasm.OpcodeEnd}},
+			// get_pi: () -> pi.
+			{Body: []byte{wasm.OpcodeGlobalGet, 1, wasm.OpcodeEnd}},
+			// call_indirect: (offset) -> the result of calling the function at offset of the table.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCallIndirect, 2, 0, wasm.OpcodeEnd}},
+			// five: () -> 5.
+			{Body: []byte{wasm.OpcodeI32Const, 5, wasm.OpcodeEnd}},
+		},
+		ElementSection: []wasm.ElementSegment{
+			{
+				OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+				TableIndex: 0,
+				Init:       []wasm.Index{7},
+				Type:       wasm.RefTypeFuncref,
+			},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "store", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "load", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "grow", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "get_sp", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "set_sp", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "get_pi", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "call_indirect", Type: wasm.ExternTypeFunc, Index: 6},
+		},
+	}))
+	require.NoError(t, err)
+
+	call := func(name string, params ...uint64) uint64 {
+		res, err := inst.ExportedFunction(name).Call(testCtx, params...)
+		require.NoError(t, err)
+		if len(res) == 0 {
+			return 0
+		}
+		return res[0]
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		mem := host.ExportedMemory("memory")
+		require.Equal(t, uint32(wasm.MemoryPageSize), mem.Size())
+		max, ok := mem.Definition().Max()
+		require.True(t, ok)
+		require.Equal(t, uint32(2), max)
+
+		call("store", 10, 42)
+		b, ok := mem.ReadByte(10)
+		require.True(t, ok)
+		require.Equal(t, byte(42), b)
+
+		require.True(t, mem.WriteByte(11, 43))
+		require.Equal(t, uint64(43), call("load", 11))
+
+		require.Equal(t, uint64(1), call("grow"))
+		require.Equal(t, uint32(2*wasm.MemoryPageSize), mem.Size())
+		require.True(t, mem.WriteByte(wasm.MemoryPageSize+1, 44))
+		require.Equal(t, uint64(44), call("load", uint64(wasm.MemoryPageSize+1)))
+		require.Equal(t, uint64(0xffffffff), call("grow"))
+	})
+
+	t.Run("globals", func(t *testing.T) {
+		sp := host.ExportedGlobal("__stack_pointer").(api.MutableGlobal)
+		require.Equal(t, api.ValueTypeI32, sp.Type())
+		require.Equal(t, uint64(1024), sp.Get())
+		require.Equal(t, uint64(1024), call("get_sp"))
+
+		call("set_sp", 512)
+		require.Equal(t, uint64(512), sp.Get())
+
+		sp.Set(256)
+		require.Equal(t, uint64(256), call("get_sp"))
+
+		pi := host.ExportedGlobal("pi")
+		_, mutable := pi.(api.MutableGlobal)
+		require.False(t, mutable)
+		require.Equal(t, 1.5, api.DecodeF64(pi.Get()))
+		require.Equal(t, 1.5, api.DecodeF64(call("get_pi")))
+	})
+
+	t.Run("table", func(t *testing.T) {
+		table := host.ExportedTable("table")
+		require.Equal(t, api.ValueTypeFuncref, table.Definition().RefType())
+		require.Equal(t, uint32(2), table.Size())
+
+		five, err := table.Function(0)
+		require.NoError(t, err)
+		res, err := five.Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), res[0])
+
+		_, err = inst.ExportedFunction("call_indirect").Call(testCtx, 1)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeInvalidTableAccess)
+		require.NoError(t, table.SetFunction(1, five))
+		require.Equal(t, uint64(5), call("call_indirect", 1))
+	})
+}
+
+// testFuelMetering ensures the fuel is consumed deterministically, and can be refilled by host functions.
+func testFuelMetering(t *testing.T, r wazero.Runtime) {
+	var observed []int64
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context) {
+		observed = append(observed, experimental.RemainingFuel(ctx))
+		experimental.AddFuel(ctx, 10)
+	}).Export("refill").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:         []wasm.FunctionType{{}, {Params: []wasm.ValueType{i32}}},
+		ImportSection:       []wasm.Import{{Module: "env", Name: "refill", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+		ImportFunctionCount: 1,
+		FunctionSection:     []wasm.Index{1, 1},
+		CodeSection: []wasm.Code{
+			// count: (n) -> decrements n down to zero in a loop, consuming 1 + 5*n + 2 fuel with the default costs.
+			{Body: []byte{
+				wasm.OpcodeLoop, 0x40,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeLocalTee, 0,
+				wasm.OpcodeBrIf, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			// count_refill: same as count, but calls refill at each iteration.
+			{Body: []byte{
+				wasm.OpcodeLoop, 0x40,
+				wasm.OpcodeCall, 0,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeLocalTee, 0,
+				wasm.OpcodeBrIf, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "count", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "count_refill", Type: wasm.ExternTypeFunc, Index: 2},
+		},
+	})
+
+	compileCtx := experimental.WithFuelMetering(testCtx, experimental.DefaultFuelCosts())
+	metered, err := r.InstantiateWithConfig(compileCtx, bin, wazero.NewModuleConfig().WithName("metered"))
+	require.NoError(t, err)
+	count := metered.ExportedFunction("count")
+
+	t.Run("consumed", func(t *testing.T) {
+		ctx := experimental.WithFuel(testCtx, 100)
+		_, err := count.Call(ctx, 10)
+		require.NoError(t, err)
+		require.Equal(t, int64(100-53), experimental.RemainingFuel(ctx))
+	})
+
+	t.Run("out of fuel", func(t *testing.T) {
+		ctx := experimental.WithFuel(testCtx, 20)
+		_, err := count.Call(ctx, 10)
+		require.ErrorIs(t, err, sys.ErrOutOfFuel)
+		// The loop header and the first three iterations were charged, but not the fourth.
+		require.Equal(t, int64(4), experimental.RemainingFuel(ctx))
+
+		// The module isn't closed, so the call succeeds after refilling.
+		experimental.AddFuel(ctx, 100)
+		_, err = count.Call(ctx, 10)
+		require.NoError(t, err)
+		require.Equal(t, int64(104-53), experimental.RemainingFuel(ctx))
+	})
+
+	t.Run("refilled by host", func(t *testing.T) {
+		observed = observed[:0]
+		ctx := experimental.WithFuel(testCtx, 2)
+		_, err := metered.ExportedFunction("count_refill").Call(ctx, 100)
+		require.NoError(t, err)
+		// Each iteration consumes 6 and refills 10.
+		require.Equal(t, int64(2-1+4*100-2), experimental.RemainingFuel(ctx))
+		require.Equal(t, 100, len(observed))
+		require.Equal(t, []int64{0, 4, 8}, observed[:3])
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		_, err := count.Call(testCtx, 10)
+		require.NoError(t, err)
+	})
+
+	t.Run("not metered", func(t *testing.T) {
+		unmetered, err := r.InstantiateWithConfig(testCtx, bin, wazero.NewModuleConfig().WithName("unmetered"))
+		require.NoError(t, err)
+		ctx := experimental.WithFuel(testCtx, 1)
+		_, err = unmetered.ExportedFunction("count").Call(ctx, 10)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), experimental.RemainingFuel(ctx))
+	})
+}
+
 func testMemoryGrowInRecursiveCall(t *testing.T, r wazero.Runtime) {
 	const hostModuleName = "env"
 	const hostFnName = "grow_memory"
@@ -992,7 +1459,7 @@ func testMemoryGrowInRecursiveCall(t *testing.T, r wazero.Runtime) {
 				Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeDrop, wasm.OpcodeEnd},
 			},
 		},
-		MemorySection:   &wasm.Memory{Max: 1000},
+		MemorySection:   []wasm.Memory{{Max: 1000}},
 		ImportSection:   []wasm.Import{{Module: hostModuleName, Name: hostFnName, DescFunc: 0}},
 		ImportPerModule: map[string][]*wasm.Import{hostModuleName: {{Module: hostModuleName, Name: hostFnName, DescFunc: 0}}},
 		ExportSection: []wasm.Export{
@@ -1078,7 +1545,7 @@ func testModuleMemory(t *testing.T, r wazero.Runtime) {
 	bin := binaryencoding.EncodeModule(&wasm.Module{
 		TypeSection:     []wasm.FunctionType{{Params: []api.ValueType{api.ValueTypeI32}, ParamNumInUint64: 1}, {}},
 		FunctionSection: []wasm.Index{0, 1},
-		MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 20},
+		MemorySection:   []wasm.Memory{{Min: 1, Cap: 1, Max: 20}},
 		DataSection: []wasm.DataSegment{
 			{
 				Passive: true,
@@ -1626,6 +2093,86 @@ func testListenerStackIteratorOffset(t *testing.T, r wazero.Runtime) {
 	require.Zero(t, len(expectedStacks), "expected more stacks")
 }
 
+// testInlinedFunction ensures that the frames of the small functions, which the compiler inlines into their callers,
+// still appear in the stack traces and the stack iterators.
+func testInlinedFunction(t *testing.T, r wazero.Runtime) {
+	i32i32_i32 := wasm.FunctionType{
+		Params:  []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI32},
+		Results: []wasm.ValueType{wasm.ValueTypeI32},
+	}
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{i32i32_i32},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection: []wasm.Code{
+			{Body: []byte{ // main: div(x, y) + 1
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeCall, 1,
+				wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{ // div: x / y
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeI32DivU,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []wasm.Export{{Name: "main", Type: wasm.ExternTypeFunc, Index: 0}},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "inlined",
+			FunctionNames: wasm.NameMap{{Index: 0, Name: "main"}, {Index: 1, Name: "div"}},
+		},
+	})
+
+	t.Run("trap", func(t *testing.T) {
+		m, err := r.Instantiate(testCtx, bin)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, m.Close(testCtx))
+		}()
+
+		main := m.ExportedFunction("main")
+		res, err := main.Call(testCtx, 10, 2)
+		require.NoError(t, err)
+		require.Equal(t, uint64(6), res[0])
+
+		_, err = main.Call(testCtx, 10, 0)
+		require.Equal(t, `wasm error: integer divide by zero
+wasm stack trace:
+	inlined.div(i32,i32) i32
+	inlined.main(i32,i32) i32`, err.Error())
+	})
+
+	t.Run("listener", func(t *testing.T) {
+		var stacks [][]string
+		var aborted []string
+		ctx := experimental.WithFunctionListenerFactory(testCtx, &fnListener{
+			beforeFn: func(_ context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, si experimental.StackIterator) {
+				var stack []string
+				for si.Next() {
+					stack = append(stack, si.Function().Definition().DebugName())
+				}
+				stacks = append(stacks, stack)
+			},
+			abortFn: func(_ context.Context, _ api.Module, def api.FunctionDefinition, _ any) {
+				aborted = append(aborted, def.DebugName())
+			},
+		})
+		m, err := r.Instantiate(ctx, bin)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, m.Close(testCtx))
+		}()
+
+		_, err = m.ExportedFunction("main").Call(testCtx, 10, 0)
+		require.Error(t, err)
+		require.Equal(t, [][]string{
+			{"inlined.main"},
+			{"inlined.div", "inlined.main"},
+		}, stacks)
+		require.Equal(t, []string{"inlined.div", "inlined.main"}, aborted)
+	})
+}
+
 // fnListener implements both experimental.FunctionListenerFactory and experimental.FunctionListener for testing.
 type fnListener struct {
 	beforeFn func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator)
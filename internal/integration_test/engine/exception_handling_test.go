@@ -0,0 +1,288 @@
+package adhoc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var exceptionHandlingTests = map[string]testCase{
+	"catch":                 {f: testExceptionHandlingCatch},
+	"catch from callee":     {f: testExceptionHandlingCatchFromCallee},
+	"catch_all":             {f: testExceptionHandlingCatchAll},
+	"catch_ref and rethrow": {f: testExceptionHandlingRethrow},
+	"catch in loop":         {f: testExceptionHandlingCatchInLoop},
+	"uncaught":              {f: testExceptionHandlingUncaught},
+	"host throws":           {f: testExceptionHandlingHostThrows},
+	"host catches":          {f: testExceptionHandlingHostCatches},
+}
+
+const exceptionHandlingFeatures = api.CoreFeaturesV2 | experimental.CoreFeaturesExceptionHandling
+
+func TestExceptionHandlingNotEnabled(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	_, err := r.CompileModule(testCtx, exceptionHandlingWasm)
+	require.EqualError(t, err, "tag section not supported as feature \"exception-handling\" is disabled")
+}
+
+func TestExceptionHandlingCompiler(t *testing.T) {
+	if !platform.CompilerSupports(exceptionHandlingFeatures) {
+		t.Skip()
+	}
+	runAllTests(t, exceptionHandlingTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(exceptionHandlingFeatures), true)
+}
+
+func TestExceptionHandlingInterpreter(t *testing.T) {
+	runAllTests(t, exceptionHandlingTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(exceptionHandlingFeatures), false)
+}
+
+// exceptionHandlingWasm imports "env" instantiated by instantiateExceptionHandlingModule, and exports the tag "e" of
+// type (i32) and the functions used by the tests. The comments on each function show the signature and the result.
+var exceptionHandlingWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32}},
+		{Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		{},
+		{Results: []wasm.ValueType{i32, wasm.ValueTypeExnref}},
+	},
+	ImportSection: []wasm.Import{
+		{Module: "env", Name: "throw", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: "env", Name: "call_and_catch", Type: wasm.ExternTypeFunc, DescFunc: 2},
+	},
+	// Tag 0 is of type (i32), and tag 1 has no params.
+	TagSection:      []wasm.Index{0, 3},
+	FunctionSection: []wasm.Index{0, 1, 2, 2, 2, 2, 2, 2},
+	CodeSection: []wasm.Code{
+		// throw: (x) -> throws tag 0 with x.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeThrow, 0,
+			wasm.OpcodeEnd,
+		}},
+		// catch: () -> 50 + 42, where 42 is thrown and caught in the same function.
+		{Body: []byte{
+			wasm.OpcodeI32Const, 50,
+			wasm.OpcodeBlock, i32,
+			wasm.OpcodeTryTable, 0x40, 1, wasm.CatchKindCatch, 0, 0,
+			wasm.OpcodeI32Const, 1,
+			wasm.OpcodeI32Const, 42,
+			wasm.OpcodeThrow, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Const, 0x7f, // -1
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Add,
+			wasm.OpcodeEnd,
+		}},
+		// catch_from_callee: (x) -> 50 + x, where x is thrown by the callee.
+		{Body: []byte{
+			wasm.OpcodeI32Const, 50,
+			wasm.OpcodeBlock, i32,
+			wasm.OpcodeTryTable, 0x40, 1, wasm.CatchKindCatch, 0, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 2,
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Const, 0x7f, // -1
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Add,
+			wasm.OpcodeEnd,
+		}},
+		// catch_all: (x) -> x if x is non-zero, otherwise 7. The latter throws tag 1 which is caught by catch_all.
+		{Body: []byte{
+			wasm.OpcodeBlock, i32,
+			wasm.OpcodeBlock, 0x40,
+			wasm.OpcodeTryTable, 0x40, 2, wasm.CatchKindCatch, 0, 1, wasm.CatchKindCatchAll, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeIf, 0x40,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeThrow, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeThrow, 1,
+			wasm.OpcodeEnd,
+			wasm.OpcodeUnreachable,
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Const, 7,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+		// rethrow: (x) -> x, where x is caught with catch_ref, rethrown by throw_ref, and then caught again.
+		{Body: []byte{
+			wasm.OpcodeBlock, i32,
+			wasm.OpcodeTryTable, 0x40, 1, wasm.CatchKindCatch, 0, 0,
+			wasm.OpcodeBlock, 4, // (i32, exnref)
+			wasm.OpcodeTryTable, 0x40, 1, wasm.CatchKindCatchRef, 0, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 2,
+			wasm.OpcodeEnd,
+			wasm.OpcodeUnreachable,
+			wasm.OpcodeEnd,
+			wasm.OpcodeThrowRef,
+			wasm.OpcodeEnd,
+			wasm.OpcodeUnreachable,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+		// catch_in_loop: (n) -> the sum of 1..n, each of which is thrown by the callee and caught.
+		{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeBlock, i32,
+			wasm.OpcodeTryTable, 0x40, 1, wasm.CatchKindCatch, 0, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 2,
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI32Add,
+			wasm.OpcodeLocalSet, 1,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Const, 1,
+			wasm.OpcodeI32Sub,
+			wasm.OpcodeLocalTee, 0,
+			wasm.OpcodeBrIf, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeEnd,
+		}},
+		// catch_host: (x) -> x + 1, which is thrown by the host function "env.throw".
+		{Body: []byte{
+			wasm.OpcodeBlock, i32,
+			wasm.OpcodeTryTable, 0x40, 1, wasm.CatchKindCatch, 0, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Const, 0x7f, // -1
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+		// call_and_catch: (x) -> x * 2, where the host function "env.call_and_catch" catches x thrown by "throw".
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 1,
+			wasm.OpcodeEnd,
+		}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "e", Type: wasm.ExternTypeTag, Index: 0},
+		{Name: "throw", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "catch", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "catch_from_callee", Type: wasm.ExternTypeFunc, Index: 4},
+		{Name: "catch_all", Type: wasm.ExternTypeFunc, Index: 5},
+		{Name: "rethrow", Type: wasm.ExternTypeFunc, Index: 6},
+		{Name: "catch_in_loop", Type: wasm.ExternTypeFunc, Index: 7},
+		{Name: "catch_host", Type: wasm.ExternTypeFunc, Index: 8},
+		{Name: "call_and_catch", Type: wasm.ExternTypeFunc, Index: 9},
+	},
+})
+
+func instantiateExceptionHandlingModule(t *testing.T, r wazero.Runtime) api.Module {
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			// mod is the caller, which exports the tag.
+			tag := experimental.ExportedTag(mod, "e")
+			panic(&experimental.Exception{Tag: tag, Values: []uint64{stack[0] + 1}})
+		}), []api.ValueType{i32}, nil).
+		Export("throw").
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			_, err := mod.ExportedFunction("throw").Call(ctx, stack[0])
+			var exc *experimental.Exception
+			if !errors.As(err, &exc) || exc.Tag != experimental.ExportedTag(mod, "e") {
+				panic(err)
+			}
+			stack[0] = exc.Values[0] * 2
+		}), []api.ValueType{i32}, []api.ValueType{i32}).
+		Export("call_and_catch").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	mod, err := r.Instantiate(testCtx, exceptionHandlingWasm)
+	require.NoError(t, err)
+	return mod
+}
+
+func testExceptionHandlingCatch(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingModule(t, r)
+
+	res, err := mod.ExportedFunction("catch").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(92), res[0])
+}
+
+func testExceptionHandlingCatchFromCallee(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingModule(t, r)
+
+	res, err := mod.ExportedFunction("catch_from_callee").Call(testCtx, 5)
+	require.NoError(t, err)
+	require.Equal(t, uint64(55), res[0])
+}
+
+func testExceptionHandlingCatchAll(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingModule(t, r)
+
+	f := mod.ExportedFunction("catch_all")
+	res, err := f.Call(testCtx, 3)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), res[0])
+
+	res, err = f.Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), res[0])
+}
+
+func testExceptionHandlingRethrow(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingModule(t, r)
+
+	res, err := mod.ExportedFunction("rethrow").Call(testCtx, 9)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), res[0])
+}
+
+func testExceptionHandlingCatchInLoop(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingModule(t, r)
+
+	res, err := mod.ExportedFunction("catch_in_loop").Call(testCtx, 1000)
+	require.NoError(t, err)
+	require.Equal(t, uint64(500500), res[0])
+}
+
+func testExceptionHandlingUncaught(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingModule(t, r)
+
+	f := mod.ExportedFunction("throw")
+	_, err := f.Call(testCtx, 3)
+	var exc *experimental.Exception
+	require.True(t, errors.As(err, &exc))
+	require.Equal(t, experimental.ExportedTag(mod, "e"), exc.Tag)
+	require.Equal(t, []uint64{3}, exc.Values)
+
+	// The function is still usable after the exception.
+	res, err := mod.ExportedFunction("catch_from_callee").Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(51), res[0])
+}
+
+func testExceptionHandlingHostThrows(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingModule(t, r)
+
+	res, err := mod.ExportedFunction("catch_host").Call(testCtx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), res[0])
+}
+
+func testExceptionHandlingHostCatches(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingModule(t, r)
+
+	res, err := mod.ExportedFunction("call_and_catch").Call(testCtx, 21)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
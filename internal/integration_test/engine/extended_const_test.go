@@ -0,0 +1,159 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var extendedConstTests = map[string]testCase{
+	"globals":       {f: testExtendedConstGlobals},
+	"data segments": {f: testExtendedConstDataSegments},
+	"elements":      {f: testExtendedConstElements},
+}
+
+const extendedConstFeatures = api.CoreFeaturesV2 | experimental.CoreFeaturesExtendedConst
+
+func TestExtendedConstNotEnabled(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	_, err := r.CompileModule(testCtx, extendedConstWasm)
+	require.EqualError(t, err, "global[0]: constant expression has been not terminated")
+}
+
+func TestExtendedConstCompiler(t *testing.T) {
+	if !platform.CompilerSupports(extendedConstFeatures) {
+		t.Skip()
+	}
+	runAllTests(t, extendedConstTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(extendedConstFeatures), true)
+}
+
+func TestExtendedConstInterpreter(t *testing.T) {
+	runAllTests(t, extendedConstTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(extendedConstFeatures), false)
+}
+
+// extendedConstBase is the value of the imported global "env.base", like the memory base of a dynamically linked
+// module.
+const extendedConstBase = 8
+
+// extendedConstEnvWasm exports the global "base" imported by extendedConstWasm.
+var extendedConstEnvWasm = binaryencoding.EncodeModule(&wasm.Module{
+	GlobalSection: []wasm.Global{{
+		Type: wasm.GlobalType{ValType: i32},
+		Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{extendedConstBase}},
+	}},
+	ExportSection: []wasm.Export{{Name: "base", Type: wasm.ExternTypeGlobal, Index: 0}},
+})
+
+// extendedConstWasm uses extended constant expressions relative to the imported global "env.base" for its globals,
+// data segment and element segment.
+var extendedConstWasm = binaryencoding.EncodeModule(&wasm.Module{
+	ImportSection: []wasm.Import{{
+		Module: "env", Name: "base", Type: wasm.ExternTypeGlobal,
+		DescGlobal: wasm.GlobalType{ValType: i32},
+	}},
+	ImportGlobalCount: 1,
+	TypeSection: []wasm.FunctionType{
+		{Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+	},
+	FunctionSection: []wasm.Index{0, 1},
+	CodeSection: []wasm.Code{
+		// answer: () -> 42.
+		{Body: []byte{wasm.OpcodeI32Const, 42, wasm.OpcodeEnd}},
+		// call: (i) -> calls the function at the table index i.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCallIndirect, 0, 0,
+			wasm.OpcodeEnd,
+		}},
+	},
+	GlobalSection: []wasm.Global{
+		{
+			// base + 16
+			Type: wasm.GlobalType{ValType: i32},
+			Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Add, Data: []byte{
+				wasm.OpcodeGlobalGet, 0,
+				wasm.OpcodeI32Const, 16,
+				wasm.OpcodeI32Add,
+			}},
+		},
+		{
+			// (3 - 10) * 2
+			Type: wasm.GlobalType{ValType: i64},
+			Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI64Mul, Data: []byte{
+				wasm.OpcodeI64Const, 3,
+				wasm.OpcodeI64Const, 10,
+				wasm.OpcodeI64Sub,
+				wasm.OpcodeI64Const, 2,
+				wasm.OpcodeI64Mul,
+			}},
+		},
+	},
+	MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
+	DataSection: []wasm.DataSegment{
+		{
+			// base * 2
+			OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Mul, Data: []byte{
+				wasm.OpcodeGlobalGet, 0,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeI32Mul,
+			}},
+			Init: []byte("hello"),
+		},
+	},
+	TableSection: []wasm.Table{{Min: 4, Type: wasm.RefTypeFuncref}},
+	ElementSection: []wasm.ElementSegment{
+		{
+			// base - 5
+			OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Sub, Data: []byte{
+				wasm.OpcodeGlobalGet, 0,
+				wasm.OpcodeI32Const, 5,
+				wasm.OpcodeI32Sub,
+			}},
+			Init: []wasm.Index{0},
+			Type: wasm.RefTypeFuncref,
+		},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "g32", Type: wasm.ExternTypeGlobal, Index: 1},
+		{Name: "g64", Type: wasm.ExternTypeGlobal, Index: 2},
+		{Name: "call", Type: wasm.ExternTypeFunc, Index: 1},
+	},
+})
+
+func instantiateExtendedConst(t *testing.T, r wazero.Runtime) api.Module {
+	_, err := r.InstantiateWithConfig(testCtx, extendedConstEnvWasm, wazero.NewModuleConfig().WithName("env"))
+	require.NoError(t, err)
+	mod, err := r.Instantiate(testCtx, extendedConstWasm)
+	require.NoError(t, err)
+	return mod
+}
+
+func testExtendedConstGlobals(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExtendedConst(t, r)
+	require.Equal(t, uint64(extendedConstBase+16), mod.ExportedGlobal("g32").Get())
+	require.Equal(t, int64((3-10)*2), int64(mod.ExportedGlobal("g64").Get()))
+}
+
+func testExtendedConstDataSegments(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExtendedConst(t, r)
+	buf, ok := mod.Memory().Read(extendedConstBase*2, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(buf))
+}
+
+func testExtendedConstElements(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExtendedConst(t, r)
+	res, err := mod.ExportedFunction("call").Call(testCtx, extendedConstBase-5)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	_, err = mod.ExportedFunction("call").Call(testCtx, 0)
+	require.Error(t, err)
+}
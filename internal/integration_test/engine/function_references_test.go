@@ -0,0 +1,224 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var functionReferencesTests = map[string]testCase{
+	"call_ref":        {f: testFunctionReferencesCallRef},
+	"return_call_ref": {f: testFunctionReferencesReturnCallRef},
+	"null":            {f: testFunctionReferencesNull},
+	"br_on_null":      {f: testFunctionReferencesBrOnNull},
+	"typed select":    {f: testFunctionReferencesTypedSelect},
+}
+
+const functionReferencesFeatures = api.CoreFeaturesV2 | experimental.CoreFeaturesFunctionReferences |
+	experimental.CoreFeaturesTailCall
+
+func TestFunctionReferencesNotEnabled(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	_, err := r.CompileModule(testCtx, functionReferencesWasm)
+	require.EqualError(t, err, "invalid function[1] export[\"apply\"]: call_ref invalid as feature \"function-references\" is disabled")
+}
+
+func TestFunctionReferencesCompiler(t *testing.T) {
+	if !platform.CompilerSupports(functionReferencesFeatures) {
+		t.Skip()
+	}
+	runAllTests(t, functionReferencesTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(functionReferencesFeatures), true)
+}
+
+func TestFunctionReferencesInterpreter(t *testing.T) {
+	runAllTests(t, functionReferencesTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(functionReferencesFeatures), false)
+}
+
+// functionReferencesRefOrNull is the instruction sequence which pushes (ref.func 0) if the local 1 is zero,
+// or otherwise (ref.null func).
+var functionReferencesRefOrNull = []byte{
+	wasm.OpcodeLocalGet, 1,
+	wasm.OpcodeIf, wasm.ValueTypeFuncref,
+	wasm.OpcodeRefNull, wasm.ValueTypeFuncref,
+	wasm.OpcodeElse,
+	wasm.OpcodeRefFunc, 0,
+	wasm.OpcodeEnd,
+}
+
+// functionReferencesWasm exports "double" of type (i32) -> i32, and the functions of type (i32, i32) -> i32
+// which call "double" with the first param via a function reference. The reference is null if the second param
+// is non-zero.
+var functionReferencesWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+	},
+	FunctionSection: []wasm.Index{0, 1, 1, 1, 1, 1},
+	CodeSection: []wasm.Code{
+		// double: (x) -> x * 2
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Const, 2,
+			wasm.OpcodeI32Mul,
+			wasm.OpcodeEnd,
+		}},
+		// apply: (x, null) -> call_ref(x, ref)
+		{Body: concat(
+			[]byte{wasm.OpcodeLocalGet, 0},
+			functionReferencesRefOrNull,
+			[]byte{wasm.OpcodeCallRef, 0, wasm.OpcodeEnd},
+		)},
+		// tail_apply: (x, null) -> return_call_ref(x, ref)
+		{Body: concat(
+			[]byte{wasm.OpcodeLocalGet, 0},
+			functionReferencesRefOrNull,
+			[]byte{wasm.OpcodeReturnCallRef, 0, wasm.OpcodeEnd},
+		)},
+		// as_non_null: (x, null) -> call_ref(x, ref.as_non_null(ref))
+		{Body: concat(
+			[]byte{wasm.OpcodeLocalGet, 0},
+			functionReferencesRefOrNull,
+			[]byte{wasm.OpcodeRefAsNonNull, wasm.OpcodeCallRef, 0, wasm.OpcodeEnd},
+		)},
+		// br_on_null: (x, null) -> call_ref(x, ref), or -1 if ref is null.
+		{Body: concat(
+			[]byte{
+				wasm.OpcodeBlock, 0x40,
+				wasm.OpcodeLocalGet, 0,
+			},
+			functionReferencesRefOrNull,
+			[]byte{
+				wasm.OpcodeBrOnNull, 0,
+				wasm.OpcodeCallRef, 0,
+				wasm.OpcodeReturn,
+				wasm.OpcodeEnd,
+				wasm.OpcodeI32Const, 0x7f, // -1
+				wasm.OpcodeEnd,
+			},
+		)},
+		// br_on_non_null: (x, null) -> call_ref(x, ref), or -2 if ref is null.
+		{LocalTypes: []wasm.ValueType{wasm.ValueTypeFuncref}, Body: concat(
+			[]byte{wasm.OpcodeBlock, wasm.ValueTypeFuncref},
+			functionReferencesRefOrNull,
+			[]byte{
+				wasm.OpcodeBrOnNonNull, 0,
+				wasm.OpcodeI32Const, 0x7e, // -2
+				wasm.OpcodeReturn,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalSet, 2,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeCallRef, 0,
+				wasm.OpcodeEnd,
+			},
+		)},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "double", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "apply", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "tail_apply", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "as_non_null", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "br_on_null", Type: wasm.ExternTypeFunc, Index: 4},
+		{Name: "br_on_non_null", Type: wasm.ExternTypeFunc, Index: 5},
+	},
+})
+
+func concat(bodies ...[]byte) (ret []byte) {
+	for _, b := range bodies {
+		ret = append(ret, b...)
+	}
+	return
+}
+
+func testFunctionReferencesCallRef(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, functionReferencesWasm)
+	require.NoError(t, err)
+
+	for _, name := range []string{"apply", "as_non_null"} {
+		res, err := mod.ExportedFunction(name).Call(testCtx, 21, 0)
+		require.NoError(t, err)
+		require.Equal(t, uint64(42), res[0])
+	}
+}
+
+func testFunctionReferencesReturnCallRef(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, functionReferencesWasm)
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("tail_apply").Call(testCtx, 21, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testFunctionReferencesNull(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, functionReferencesWasm)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name, expErr string
+	}{
+		{name: "apply", expErr: "null function reference"},
+		{name: "tail_apply", expErr: "null function reference"},
+		{name: "as_non_null", expErr: "null reference"},
+	} {
+		_, err = mod.ExportedFunction(tc.name).Call(testCtx, 21, 1)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "wasm error: "+tc.expErr+"\n")
+	}
+}
+
+func testFunctionReferencesBrOnNull(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, functionReferencesWasm)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		null     uint64
+		expected int32
+	}{
+		{name: "br_on_null", null: 0, expected: 42},
+		{name: "br_on_null", null: 1, expected: -1},
+		{name: "br_on_non_null", null: 0, expected: 42},
+		{name: "br_on_non_null", null: 1, expected: -2},
+	} {
+		res, err := mod.ExportedFunction(tc.name).Call(testCtx, 21, tc.null)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, int32(res[0]))
+	}
+}
+
+func testFunctionReferencesTypedSelect(t *testing.T, r wazero.Runtime) {
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{
+			// is_null: (c) -> ref.is_null(select (ref null func) (ref.func 0) (ref.null func) c)
+			{Body: []byte{
+				wasm.OpcodeRefFunc, 0,
+				wasm.OpcodeRefNull, 0x73, // ref.null nofunc
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeTypedSelect, 1, wasm.ValueTypePrefixRefNull, 0x70, // (ref null func)
+				wasm.OpcodeRefIsNull,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []wasm.Export{{Name: "is_null", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+
+	mod, err := r.Instantiate(testCtx, bin)
+	require.NoError(t, err)
+
+	for _, c := range []uint64{0, 1} {
+		res, err := mod.ExportedFunction("is_null").Call(testCtx, c)
+		require.NoError(t, err)
+		require.Equal(t, 1-c, res[0])
+	}
+}
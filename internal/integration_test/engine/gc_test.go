@@ -0,0 +1,279 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var gcTests = map[string]testCase{
+	"struct":     {f: testGCStruct},
+	"array":      {f: testGCArray},
+	"i31":        {f: testGCI31},
+	"subtyping":  {f: testGCSubtyping},
+	"collection": {f: testGCCollection},
+}
+
+const gcFeatures = api.CoreFeaturesV2 | experimental.CoreFeaturesGC
+
+func TestGCNotEnabled(t *testing.T) {
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigInterpreter().
+		WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesFunctionReferences))
+	_, err := r.CompileModule(testCtx, gcWasm)
+	require.EqualError(t, err, "section type: read 0-th type: sub type invalid as feature \"gc\" is disabled")
+}
+
+func TestGCCompiler(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(gcFeatures))
+	_, err := r.CompileModule(testCtx, gcWasm)
+	require.EqualError(t, err, "GC is not supported by the compiler: use the interpreter")
+
+	// Only the modules using GC are rejected.
+	mod, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{{Name: "add", Type: wasm.ExternTypeFunc, Index: 0}},
+	}))
+	require.NoError(t, err)
+	res, err := mod.ExportedFunction("add").Call(testCtx, 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, res)
+}
+
+func TestGCInterpreter(t *testing.T) {
+	runAllTests(t, gcTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(gcFeatures), false)
+}
+
+const (
+	// gcPointType is (struct (field (mut i32)) (field i64)), which is not final.
+	gcPointType = iota
+	// gcBytesType is (array (mut i8)).
+	gcBytesType
+	// gcPoint3Type is (sub final gcPointType (struct (field (mut i32)) (field i64) (field i32))).
+	gcPoint3Type
+	gcI32I64ToI32Type
+	gcI32I32ToI32Type
+	gcI32ToI32Type
+)
+
+func gcOp(op wasm.OpcodeGC, immediates ...byte) []byte {
+	return append([]byte{wasm.OpcodeGCPrefix, byte(op)}, immediates...)
+}
+
+var gcWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		gcPointType:       {},
+		gcBytesType:       {},
+		gcPoint3Type:      {},
+		gcI32I64ToI32Type: {Params: []wasm.ValueType{i32, i64}, Results: []wasm.ValueType{i32}},
+		gcI32I32ToI32Type: {Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+		gcI32ToI32Type:    {Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+	},
+	CompositeTypes: []wasm.CompositeType{
+		gcPointType: {
+			Kind:     wasm.CompositeTypeKindStruct,
+			Fields:   []wasm.FieldType{{StorageType: i32, Mutable: true}, {StorageType: i64}},
+			RecGroup: gcPointType,
+		},
+		gcBytesType: {
+			Kind:     wasm.CompositeTypeKindArray,
+			Fields:   []wasm.FieldType{{StorageType: wasm.StorageTypeI8, Mutable: true}},
+			Final:    true,
+			RecGroup: gcBytesType,
+		},
+		gcPoint3Type: {
+			Kind:      wasm.CompositeTypeKindStruct,
+			Fields:    []wasm.FieldType{{StorageType: i32, Mutable: true}, {StorageType: i64}, {StorageType: i32}},
+			SuperType: uint32Ptr(gcPointType),
+			Final:     true,
+			RecGroup:  gcPoint3Type,
+		},
+		gcI32I64ToI32Type: {Kind: wasm.CompositeTypeKindFunc, Final: true, RecGroup: gcI32I64ToI32Type},
+		gcI32I32ToI32Type: {Kind: wasm.CompositeTypeKindFunc, Final: true, RecGroup: gcI32I32ToI32Type},
+		gcI32ToI32Type:    {Kind: wasm.CompositeTypeKindFunc, Final: true, RecGroup: gcI32ToI32Type},
+	},
+	FunctionSection: []wasm.Index{gcI32I64ToI32Type, gcI32I32ToI32Type, gcI32ToI32Type, gcI32ToI32Type, gcI32ToI32Type},
+	CodeSection: []wasm.Code{
+		// point: (x, y) -> p.x + i32.wrap(p.y) where p = struct.new(x, y) and then p.x is incremented.
+		{LocalTypes: []wasm.ValueType{wasm.ValueTypeStructref}, Body: concat(
+			[]byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1},
+			gcOp(wasm.OpcodeGCStructNew, gcPointType),
+			[]byte{
+				wasm.OpcodeLocalSet, 2,
+				wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeLocalGet, 2,
+			},
+			gcOp(wasm.OpcodeGCStructGet, gcPointType, 0),
+			[]byte{wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add},
+			gcOp(wasm.OpcodeGCStructSet, gcPointType, 0),
+			[]byte{wasm.OpcodeLocalGet, 2},
+			gcOp(wasm.OpcodeGCStructGet, gcPointType, 0),
+			[]byte{wasm.OpcodeLocalGet, 2},
+			gcOp(wasm.OpcodeGCStructGet, gcPointType, 1),
+			[]byte{wasm.OpcodeI32WrapI64, wasm.OpcodeI32Add, wasm.OpcodeEnd},
+		)},
+		// bytes: (len, index) -> array.get_s(a, index) + array.len(a) where a = array.new_default(len) and then
+		// a[len-1] is set to -1.
+		{LocalTypes: []wasm.ValueType{wasm.ValueTypeArrayref}, Body: concat(
+			[]byte{wasm.OpcodeLocalGet, 0},
+			gcOp(wasm.OpcodeGCArrayNewDefault, gcBytesType),
+			[]byte{
+				wasm.OpcodeLocalSet, 2,
+				wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Sub,
+				wasm.OpcodeI32Const, 0x7f, // -1
+			},
+			gcOp(wasm.OpcodeGCArraySet, gcBytesType),
+			[]byte{wasm.OpcodeLocalGet, 2, wasm.OpcodeLocalGet, 1},
+			gcOp(wasm.OpcodeGCArrayGetS, gcBytesType),
+			[]byte{wasm.OpcodeLocalGet, 2},
+			gcOp(wasm.OpcodeGCArrayLen),
+			[]byte{wasm.OpcodeI32Add, wasm.OpcodeEnd},
+		)},
+		// i31: (x) -> i31.get_s(ref.i31(x))
+		{Body: concat(
+			[]byte{wasm.OpcodeLocalGet, 0},
+			gcOp(wasm.OpcodeGCRefI31),
+			gcOp(wasm.OpcodeGCI31GetS),
+			[]byte{wasm.OpcodeEnd},
+		)},
+		// is_point3: (which) -> ref.test (ref gcPoint3Type) p + struct.get(ref.cast (ref gcPointType) p, 0)
+		// where p is a point3 if which is non-zero, or otherwise a point.
+		{LocalTypes: []wasm.ValueType{wasm.ValueTypeStructref}, Body: concat(
+			[]byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeIf, wasm.ValueTypeStructref,
+				wasm.OpcodeI32Const, 10,
+				wasm.OpcodeI64Const, 0,
+				wasm.OpcodeI32Const, 0,
+			},
+			gcOp(wasm.OpcodeGCStructNew, gcPoint3Type),
+			[]byte{
+				wasm.OpcodeElse,
+				wasm.OpcodeI32Const, 20,
+				wasm.OpcodeI64Const, 0,
+			},
+			gcOp(wasm.OpcodeGCStructNew, gcPointType),
+			[]byte{wasm.OpcodeEnd, wasm.OpcodeLocalTee, 1},
+			gcOp(wasm.OpcodeGCRefTest, gcPoint3Type),
+			[]byte{wasm.OpcodeLocalGet, 1},
+			gcOp(wasm.OpcodeGCRefCast, gcPointType),
+			gcOp(wasm.OpcodeGCStructGet, gcPointType, 0),
+			[]byte{wasm.OpcodeI32Add, wasm.OpcodeEnd},
+		)},
+		// allocate: (n) -> 0 after allocating n arrays of 64 KiB which are immediately dropped.
+		{Body: concat(
+			[]byte{
+				wasm.OpcodeLoop, 0x40,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeIf, 0x40,
+				wasm.OpcodeI32Const, 0x80, 0x80, 0x04, // 65536
+			},
+			gcOp(wasm.OpcodeGCArrayNewDefault, gcBytesType),
+			[]byte{
+				wasm.OpcodeDrop,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Sub,
+				wasm.OpcodeLocalSet, 0,
+				wasm.OpcodeBr, 1,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeEnd,
+			},
+		)},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "point", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "bytes", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "i31", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "is_point3", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "allocate", Type: wasm.ExternTypeFunc, Index: 4},
+	},
+})
+
+func uint32Ptr(v uint32) *uint32 {
+	return &v
+}
+
+func testGCStruct(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, gcWasm)
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("point").Call(testCtx, 10, 100)
+	require.NoError(t, err)
+	require.Equal(t, uint64(111), res[0])
+}
+
+func testGCArray(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, gcWasm)
+	require.NoError(t, err)
+
+	bytes := mod.ExportedFunction("bytes")
+	res, err := bytes.Call(testCtx, 10, 9)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10-1), res[0])
+	res, err = bytes.Call(testCtx, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), res[0])
+
+	_, err = bytes.Call(testCtx, 10, 10)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds array access")
+}
+
+func testGCI31(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, gcWasm)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		x, expected int32
+	}{
+		{x: 1, expected: 1},
+		{x: -1, expected: -1},
+		{x: 0x40000000, expected: -0x40000000}, // The 31st bit is the sign bit.
+	} {
+		res, err := mod.ExportedFunction("i31").Call(testCtx, api.EncodeI32(tc.x))
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, int32(res[0]))
+	}
+}
+
+func testGCSubtyping(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, gcWasm)
+	require.NoError(t, err)
+
+	isPoint3 := mod.ExportedFunction("is_point3")
+	res, err := isPoint3.Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1+10), res[0])
+	res, err = isPoint3.Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0+20), res[0])
+}
+
+func testGCCollection(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, gcWasm)
+	require.NoError(t, err)
+
+	// Each element takes 8 bytes in the heap, so this allocates 4 GiB in total, which can only succeed in a
+	// reasonable memory footprint if the unreachable arrays are collected.
+	res, err := mod.ExportedFunction("allocate").Call(testCtx, 1<<13)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+}
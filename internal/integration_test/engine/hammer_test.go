@@ -33,6 +33,13 @@ func TestEngineInterpreter_hammer(t *testing.T) {
 	runAllTests(t, hammers, wazero.NewRuntimeConfigInterpreter(), false)
 }
 
+func TestEngineTiered_hammer(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, hammers, wazero.NewRuntimeConfigTiered(), false)
+}
+
 func concurrentCompilationInstantiationExecution(t *testing.T, r wazero.Runtime) {
 	P := 16              // max count of goroutines
 	if testing.Short() { // Adjust down if `-test.short`
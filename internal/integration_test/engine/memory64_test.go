@@ -0,0 +1,254 @@
+package adhoc
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var memory64Tests = map[string]testCase{
+	"load and store": {f: testMemory64LoadStore},
+	"data segments":  {f: testMemory64DataSegments},
+	"size and grow":  {f: testMemory64SizeGrow},
+	"copy and fill":  {f: testMemory64CopyFill},
+	"out of bounds":  {f: testMemory64OutOfBounds},
+	"host access":    {f: testMemory64HostAccess},
+}
+
+const memory64Features = api.CoreFeaturesV2 | experimental.CoreFeaturesMemory64
+
+func TestMemory64NotEnabled(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	_, err := r.CompileModule(testCtx, memory64Wasm)
+	require.EqualError(t, err, "section memory: 64-bit memory requested but memory64 feature not enabled")
+}
+
+func TestMemory64Compiler(t *testing.T) {
+	if !platform.CompilerSupports(memory64Features) {
+		t.Skip()
+	}
+	runAllTests(t, memory64Tests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(memory64Features), true)
+}
+
+func TestMemory64Interpreter(t *testing.T) {
+	runAllTests(t, memory64Tests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(memory64Features), false)
+}
+
+// memory64HighOffset is the memarg offset of "load_high", which doesn't fit in 32 bits.
+const memory64HighOffset = uint64(1) << 32
+
+// memory64Wasm defines a 64-bit memory which is initialized to "hello" at offset 8 and can grow up to three pages.
+// The memory is exported as "mem".
+var memory64Wasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i64, i32}},
+		{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i32}},
+		{Results: []wasm.ValueType{i64}},
+		{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i64}},
+		{Params: []wasm.ValueType{i64, i64, i64}},
+		{Params: []wasm.ValueType{i64, i32, i64}},
+	},
+	MemorySection:   []wasm.Memory{{Min: 1, Cap: 1, Max: 3, IsMaxEncoded: true, Is64: true}},
+	FunctionSection: []wasm.Index{0, 1, 1, 2, 3, 4, 5},
+	CodeSection: []wasm.Code{
+		// store: (addr, val) -> stores the byte val at addr.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI32Store8, 0, 0,
+			wasm.OpcodeEnd,
+		}},
+		// load: (addr) -> the byte at addr.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Load8U, 0, 0,
+			wasm.OpcodeEnd,
+		}},
+		// load_high: (addr) -> the byte at addr+memory64HighOffset.
+		{Body: append(append([]byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Load8U, 0,
+		}, leb128.EncodeUint64(memory64HighOffset)...),
+			wasm.OpcodeEnd,
+		)},
+		// size: () -> the page size of the memory.
+		{Body: []byte{
+			wasm.OpcodeMemorySize, 0,
+			wasm.OpcodeEnd,
+		}},
+		// grow: (pages) -> the result of memory.grow.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeMemoryGrow, 0,
+			wasm.OpcodeEnd,
+		}},
+		// copy: (dst, src, n) -> copies n bytes from src to dst.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeLocalGet, 2,
+			wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0, 0,
+			wasm.OpcodeEnd,
+		}},
+		// fill: (addr, val, n) -> fills n bytes at addr with val.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeLocalGet, 2,
+			wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 0,
+			wasm.OpcodeEnd,
+		}},
+	},
+	DataSection: []wasm.DataSegment{
+		{
+			OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: leb128.EncodeInt64(8)},
+			Init:             []byte("hello"),
+		},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0},
+		{Name: "store", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "load", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "load_high", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "size", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "grow", Type: wasm.ExternTypeFunc, Index: 4},
+		{Name: "copy", Type: wasm.ExternTypeFunc, Index: 5},
+		{Name: "fill", Type: wasm.ExternTypeFunc, Index: 6},
+	},
+})
+
+func testMemory64LoadStore(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("store").Call(testCtx, pageSize-1, 42)
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("load").Call(testCtx, pageSize-1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	b, ok := experimental.AsMemory64(mod.Memory()).ReadUint8(pageSize - 1)
+	require.True(t, ok)
+	require.Equal(t, byte(42), b)
+}
+
+func testMemory64DataSegments(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm)
+	require.NoError(t, err)
+
+	buf, ok := experimental.AsMemory64(mod.ExportedMemory("mem")).Read(8, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(buf))
+
+	res, err := mod.ExportedFunction("load").Call(testCtx, 9)
+	require.NoError(t, err)
+	require.Equal(t, uint64('e'), res[0])
+}
+
+func testMemory64SizeGrow(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm)
+	require.NoError(t, err)
+
+	size, grow := mod.ExportedFunction("size"), mod.ExportedFunction("grow")
+	res, err := size.Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = grow.Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = size.Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+	require.Equal(t, 2*pageSize, experimental.AsMemory64(mod.Memory()).Size())
+
+	// The failure is -1 as an i64.
+	for _, pages := range []uint64{2, math.MaxUint32, math.MaxUint32 + 1, math.MaxUint64} {
+		res, err = grow.Call(testCtx, pages)
+		require.NoError(t, err)
+		require.Equal(t, uint64(math.MaxUint64), res[0])
+	}
+
+	// The grown area must be accessible.
+	_, err = mod.ExportedFunction("store").Call(testCtx, pageSize+1, 7)
+	require.NoError(t, err)
+	res, err = mod.ExportedFunction("load").Call(testCtx, pageSize+1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), res[0])
+}
+
+func testMemory64CopyFill(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("copy").Call(testCtx, 100, 8, 5)
+	require.NoError(t, err)
+	buf, ok := mod.Memory().Read(100, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(buf))
+
+	_, err = mod.ExportedFunction("fill").Call(testCtx, 200, 7, 3)
+	require.NoError(t, err)
+	buf, ok = mod.Memory().Read(199, 5)
+	require.True(t, ok)
+	require.Equal(t, []byte{0, 7, 7, 7, 0}, buf)
+}
+
+func testMemory64OutOfBounds(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		params []uint64
+	}{
+		{name: "load", params: []uint64{pageSize}},
+		// The address doesn't fit in 32 bits.
+		{name: "load", params: []uint64{memory64HighOffset}},
+		{name: "load", params: []uint64{math.MaxUint64}},
+		{name: "load_high", params: []uint64{0}},
+		// addr+offset wraps around to zero.
+		{name: "load_high", params: []uint64{math.MaxUint64 - memory64HighOffset + 1}},
+		{name: "copy", params: []uint64{0, 1, math.MaxUint64}},
+		{name: "copy", params: []uint64{math.MaxUint64, 0, 1}},
+		{name: "fill", params: []uint64{1, 0, math.MaxUint64}},
+		{name: "fill", params: []uint64{math.MaxUint64, 0, 1}},
+	} {
+		_, err = mod.ExportedFunction(tc.name).Call(testCtx, tc.params...)
+		require.Error(t, err, "%s%v", tc.name, tc.params)
+		require.Contains(t, err.Error(), "out of bounds memory access")
+	}
+}
+
+func testMemory64HostAccess(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm)
+	require.NoError(t, err)
+
+	mem := experimental.AsMemory64(mod.Memory())
+	require.NotNil(t, mem)
+	require.True(t, mem.WriteUint32Le(16, 0xdeadbeef))
+	v, ok := mem.ReadUint32Le(16)
+	require.True(t, ok)
+	require.Equal(t, uint32(0xdeadbeef), v)
+
+	res, err := mod.ExportedFunction("load").Call(testCtx, 16)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0xef), res[0])
+
+	// Offsets which don't fit in 32 bits are rejected rather than truncated.
+	_, ok = mem.ReadUint8(memory64HighOffset)
+	require.False(t, ok)
+	require.False(t, mem.WriteUint8(memory64HighOffset+16, 1))
+	_, ok = mem.Read(math.MaxUint64, 2)
+	require.False(t, ok)
+}
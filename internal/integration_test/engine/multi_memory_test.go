@@ -0,0 +1,305 @@
+package adhoc
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var multiMemoryTests = map[string]testCase{
+	"load and store":  {f: testMultiMemoryLoadStore},
+	"data segments":   {f: testMultiMemoryDataSegments},
+	"size and grow":   {f: testMultiMemorySizeGrow},
+	"copy and fill":   {f: testMultiMemoryCopyFill},
+	"out of bounds":   {f: testMultiMemoryOutOfBounds},
+	"imported memory": {f: testMultiMemoryImported},
+}
+
+const multiMemoryFeatures = api.CoreFeaturesV2 | experimental.CoreFeaturesMultiMemory
+
+func TestMultiMemoryNotEnabled(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	_, err := r.CompileModule(testCtx, multiMemoryWasm)
+	require.EqualError(t, err, "section memory: at most one memory allowed in module, but read 2")
+}
+
+func TestMultiMemoryCompiler(t *testing.T) {
+	if !platform.CompilerSupports(multiMemoryFeatures) {
+		t.Skip()
+	}
+	runAllTests(t, multiMemoryTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(multiMemoryFeatures), true)
+}
+
+func TestMultiMemoryInterpreter(t *testing.T) {
+	runAllTests(t, multiMemoryTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(multiMemoryFeatures), false)
+}
+
+const (
+	memArgMemory1 = 0x40 // wasm.MemArgMemoryIndexFlag with the alignment zero.
+	pageSize      = uint64(wasm.MemoryPageSize)
+)
+
+// multiMemoryWasm defines two memories: memory 0 is initialized to "ab", and memory 1 is initialized to "hello" and can
+// grow up to three pages. Both are exported as "mem0" and "mem1" respectively.
+var multiMemoryWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32, i32}},
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		{Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32, i32, i32}},
+	},
+	MemorySection: []wasm.Memory{
+		{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+		{Min: 1, Cap: 1, Max: 3, IsMaxEncoded: true},
+	},
+	FunctionSection: []wasm.Index{0, 1, 1, 2, 1, 3, 3},
+	CodeSection: []wasm.Code{
+		// store1: (addr, val) -> stores the byte val at addr of memory 1.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI32Store8, memArgMemory1, 1, 0,
+			wasm.OpcodeEnd,
+		}},
+		// load0: (addr) -> the byte at addr of memory 0.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Load8U, 0, 0,
+			wasm.OpcodeEnd,
+		}},
+		// load1: (addr) -> the byte at addr of memory 1.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Load8U, memArgMemory1, 1, 0,
+			wasm.OpcodeEnd,
+		}},
+		// size1: () -> the page size of memory 1.
+		{Body: []byte{
+			wasm.OpcodeMemorySize, 1,
+			wasm.OpcodeEnd,
+		}},
+		// grow1: (pages) -> the result of memory.grow on memory 1.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeMemoryGrow, 1,
+			wasm.OpcodeEnd,
+		}},
+		// copy1to0: (dst, src, n) -> copies n bytes from src of memory 1 to dst of memory 0.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeLocalGet, 2,
+			wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0, 1,
+			wasm.OpcodeEnd,
+		}},
+		// fill1: (addr, val, n) -> fills n bytes at addr of memory 1 with val.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeLocalGet, 2,
+			wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 1,
+			wasm.OpcodeEnd,
+		}},
+	},
+	DataSection: []wasm.DataSegment{
+		{
+			OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+			Init:             []byte("ab"),
+		},
+		{
+			MemoryIndex:      1,
+			OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+			Init:             []byte("hello"),
+		},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "mem0", Type: wasm.ExternTypeMemory, Index: 0},
+		{Name: "mem1", Type: wasm.ExternTypeMemory, Index: 1},
+		{Name: "store1", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "load0", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "load1", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "size1", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "grow1", Type: wasm.ExternTypeFunc, Index: 4},
+		{Name: "copy1to0", Type: wasm.ExternTypeFunc, Index: 5},
+		{Name: "fill1", Type: wasm.ExternTypeFunc, Index: 6},
+	},
+})
+
+// multiMemoryImportingWasm imports "mem1" of multiMemoryWasm instantiated as "multi" as its memory 0, and defines
+// its own memory 1.
+var multiMemoryImportingWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+	},
+	ImportSection: []wasm.Import{
+		{Module: "multi", Name: "mem1", Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1}},
+	},
+	MemorySection:   []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
+	FunctionSection: []wasm.Index{0, 0},
+	CodeSection: []wasm.Code{
+		// load0: (addr) -> the byte at addr of the imported memory.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Load8U, 0, 0,
+			wasm.OpcodeEnd,
+		}},
+		// grow0: (pages) -> the result of memory.grow on the imported memory.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeMemoryGrow, 0,
+			wasm.OpcodeEnd,
+		}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "load0", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "grow0", Type: wasm.ExternTypeFunc, Index: 1},
+	},
+})
+
+func testMultiMemoryLoadStore(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, multiMemoryWasm)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("store1").Call(testCtx, 10, 42)
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("load1").Call(testCtx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+	res, err = mod.ExportedFunction("load0").Call(testCtx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+
+	b, ok := experimental.MemoryAt(mod, 1).ReadByte(10)
+	require.True(t, ok)
+	require.Equal(t, byte(42), b)
+	b, ok = mod.ExportedMemory("mem1").ReadByte(10)
+	require.True(t, ok)
+	require.Equal(t, byte(42), b)
+	b, ok = mod.Memory().ReadByte(10)
+	require.True(t, ok)
+	require.Equal(t, byte(0), b)
+	require.Nil(t, experimental.MemoryAt(mod, 2))
+}
+
+func testMultiMemoryDataSegments(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, multiMemoryWasm)
+	require.NoError(t, err)
+
+	buf, ok := mod.Memory().Read(0, 2)
+	require.True(t, ok)
+	require.Equal(t, "ab", string(buf))
+	buf, ok = experimental.MemoryAt(mod, 1).Read(0, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(buf))
+
+	res, err := mod.ExportedFunction("load1").Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64('e'), res[0])
+}
+
+func testMultiMemorySizeGrow(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, multiMemoryWasm)
+	require.NoError(t, err)
+
+	size1, grow1 := mod.ExportedFunction("size1"), mod.ExportedFunction("grow1")
+	res, err := size1.Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = grow1.Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = size1.Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+	require.Equal(t, uint32(wasm.MemoryPageSize*2), experimental.MemoryAt(mod, 1).Size())
+	require.Equal(t, uint32(wasm.MemoryPageSize), mod.Memory().Size())
+
+	// Exceeds the maximum of three pages.
+	res, err = grow1.Call(testCtx, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0xffffffff), uint32(res[0]))
+
+	// The grown area must be accessible.
+	_, err = mod.ExportedFunction("store1").Call(testCtx, pageSize+1, 7)
+	require.NoError(t, err)
+	res, err = mod.ExportedFunction("load1").Call(testCtx, pageSize+1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), res[0])
+}
+
+func testMultiMemoryCopyFill(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, multiMemoryWasm)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("copy1to0").Call(testCtx, 100, 0, 5)
+	require.NoError(t, err)
+	buf, ok := mod.Memory().Read(100, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(buf))
+
+	_, err = mod.ExportedFunction("fill1").Call(testCtx, 200, 7, 3)
+	require.NoError(t, err)
+	buf, ok = experimental.MemoryAt(mod, 1).Read(199, 5)
+	require.True(t, ok)
+	require.Equal(t, []byte{0, 7, 7, 7, 0}, buf)
+	buf, ok = mod.Memory().Read(200, 3)
+	require.True(t, ok)
+	require.Equal(t, []byte{0, 0, 0}, buf)
+}
+
+func testMultiMemoryOutOfBounds(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, multiMemoryWasm)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("load1").Call(testCtx, pageSize)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+
+	// Memory 1 can be larger than memory 0, so copying from the grown area must be checked against memory 0.
+	_, err = mod.ExportedFunction("grow1").Call(testCtx, 1)
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("copy1to0").Call(testCtx, 0, pageSize, pageSize)
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("copy1to0").Call(testCtx, 1, pageSize, pageSize)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "out of bounds memory access")
+}
+
+func testMultiMemoryImported(t *testing.T, r wazero.Runtime) {
+	exporting, err := r.InstantiateWithConfig(testCtx, multiMemoryWasm, wazero.NewModuleConfig().WithName("multi"))
+	require.NoError(t, err)
+	importing, err := r.Instantiate(testCtx, multiMemoryImportingWasm)
+	require.NoError(t, err)
+
+	res, err := importing.ExportedFunction("load0").Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64('h'), res[0])
+
+	// Growing the imported memory is visible from the exporting module.
+	res, err = importing.ExportedFunction("grow0").Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+	res, err = exporting.ExportedFunction("size1").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+
+	// Writes by the exporting module are visible through the import.
+	_, err = exporting.ExportedFunction("store1").Call(testCtx, pageSize+5, 9)
+	require.NoError(t, err)
+	res, err = importing.ExportedFunction("load0").Call(testCtx, pageSize+5)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), res[0])
+
+	require.Equal(t, experimental.MemoryAt(exporting, 1), importing.Memory())
+	require.Equal(t, uint32(wasm.MemoryPageSize), experimental.MemoryAt(importing, 1).Size())
+}
@@ -0,0 +1,272 @@
+package adhoc
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var relaxedSIMDTests = map[string]testCase{
+	"instructions": {f: testRelaxedSIMDInstructions},
+}
+
+const relaxedSIMDFeatures = api.CoreFeaturesV2 | experimental.CoreFeaturesRelaxedSIMD
+
+func TestRelaxedSIMDNotEnabled(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	_, err := r.CompileModule(testCtx, relaxedSIMDWasm)
+	require.EqualError(t, err, "invalid function[0] export[\"i8x16.relaxed_swizzle\"]: i8x16.relaxed_swizzle invalid as feature \"relaxed-simd\" is disabled")
+}
+
+func TestRelaxedSIMDCompiler(t *testing.T) {
+	if !platform.CompilerSupports(relaxedSIMDFeatures) {
+		t.Skip()
+	}
+	runAllTests(t, relaxedSIMDTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(relaxedSIMDFeatures), true)
+}
+
+func TestRelaxedSIMDInterpreter(t *testing.T) {
+	runAllTests(t, relaxedSIMDTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(relaxedSIMDFeatures), false)
+}
+
+func i8x16(v ...int8) (ret []byte) {
+	for _, x := range v {
+		ret = append(ret, byte(x))
+	}
+	return
+}
+
+func i16x8(v ...int16) (ret []byte) {
+	for _, x := range v {
+		ret = binary.LittleEndian.AppendUint16(ret, uint16(x))
+	}
+	return
+}
+
+func i32x4(v ...int64) (ret []byte) {
+	for _, x := range v {
+		ret = binary.LittleEndian.AppendUint32(ret, uint32(x))
+	}
+	return
+}
+
+func f32x4(v ...float32) (ret []byte) {
+	for _, x := range v {
+		ret = binary.LittleEndian.AppendUint32(ret, math.Float32bits(x))
+	}
+	return
+}
+
+func f64x2(v ...float64) (ret []byte) {
+	for _, x := range v {
+		ret = binary.LittleEndian.AppendUint64(ret, math.Float64bits(x))
+	}
+	return
+}
+
+// relaxedSIMDCases are the relaxed instructions with their operands and the expected result, which is the same on
+// every platform and engine. The inputs are chosen where the results allowed by the proposal differ.
+var relaxedSIMDCases = []struct {
+	op       wasm.OpcodeVecRelaxed
+	operands [][]byte
+	exp      []byte
+}{
+	{
+		op: wasm.OpcodeVecI8x16RelaxedSwizzle,
+		operands: [][]byte{
+			i8x16(0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f),
+			i8x16(0, 15, 16, -128, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, -1),
+		},
+		exp: i8x16(0x10, 0x1f, 0, 0, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0),
+	},
+	{
+		op:       wasm.OpcodeVecI32x4RelaxedTruncF32x4S,
+		operands: [][]byte{f32x4(float32(math.NaN()), -1e10, 1e10, -1.5)},
+		exp:      i32x4(0, 0x80000000, 0x7fffffff, 0xffffffff),
+	},
+	{
+		op:       wasm.OpcodeVecI32x4RelaxedTruncF32x4U,
+		operands: [][]byte{f32x4(float32(math.NaN()), -1, 1e10, 1.5)},
+		exp:      i32x4(0, 0, 0xffffffff, 1),
+	},
+	{
+		op:       wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero,
+		operands: [][]byte{f64x2(math.NaN(), -1e20)},
+		exp:      i32x4(0, 0x80000000, 0, 0),
+	},
+	{
+		op:       wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero,
+		operands: [][]byte{f64x2(1e20, 2.5)},
+		exp:      i32x4(0xffffffff, 2, 0, 0),
+	},
+	{
+		// The product of the first lanes is 1-2^-46 which is rounded to 1, so the result would be -2^-46 if fused.
+		op: wasm.OpcodeVecF32x4RelaxedMadd,
+		operands: [][]byte{
+			f32x4(1+0x1p-23, 2, 0, 1),
+			f32x4(1-0x1p-23, 3, 0, 1),
+			f32x4(-1, 4, 0, 0.5),
+		},
+		exp: f32x4(0, 10, 0, 1.5),
+	},
+	{
+		op: wasm.OpcodeVecF32x4RelaxedNmadd,
+		operands: [][]byte{
+			f32x4(1+0x1p-23, 2, 0, 1),
+			f32x4(1-0x1p-23, 3, 0, 1),
+			f32x4(1, 4, 0, 0.5),
+		},
+		exp: f32x4(0, -2, 0, -0.5),
+	},
+	{
+		op: wasm.OpcodeVecF64x2RelaxedMadd,
+		operands: [][]byte{
+			f64x2(1+0x1p-52, 2),
+			f64x2(1-0x1p-52, 3),
+			f64x2(-1, 4),
+		},
+		exp: f64x2(0, 10),
+	},
+	{
+		op: wasm.OpcodeVecF64x2RelaxedNmadd,
+		operands: [][]byte{
+			f64x2(1+0x1p-52, 2),
+			f64x2(1-0x1p-52, 3),
+			f64x2(1, 4),
+		},
+		exp: f64x2(0, -2),
+	},
+	{
+		// The mask selects bits rather than lanes by their top bit.
+		op: wasm.OpcodeVecI8x16RelaxedLaneselect,
+		operands: [][]byte{
+			i32x4(0xffffffff, 0xffffffff, 0xffffffff, 0xffffffff),
+			i32x4(0, 0, 0, 0),
+			i32x4(0x0f0f0f0f, 0x80000000, 0x7fffffff, 0x00ff00ff),
+		},
+		exp: i32x4(0x0f0f0f0f, 0x80000000, 0x7fffffff, 0x00ff00ff),
+	},
+	{
+		op: wasm.OpcodeVecI16x8RelaxedLaneselect,
+		operands: [][]byte{
+			i32x4(0x12345678, 0x12345678, 0, 0),
+			i32x4(0, 0, 0x9abcdef0, 0x9abcdef0),
+			i32x4(0xffff0000, 0x0000ffff, 0x00008000, 0),
+		},
+		exp: i32x4(0x12340000, 0x00005678, 0x9abc5ef0, 0x9abcdef0),
+	},
+	{
+		op: wasm.OpcodeVecI32x4RelaxedLaneselect,
+		operands: [][]byte{
+			i32x4(1, 2, 3, 4),
+			i32x4(5, 6, 7, 8),
+			i32x4(0xffffffff, 0, 0x80000000, 0x00000001),
+		},
+		exp: i32x4(1, 6, 7, 8),
+	},
+	{
+		op: wasm.OpcodeVecI64x2RelaxedLaneselect,
+		operands: [][]byte{
+			i32x4(1, 2, 3, 4),
+			i32x4(5, 6, 7, 8),
+			i32x4(0xffffffff, 0xffffffff, 0, 0x80000000),
+		},
+		exp: i32x4(1, 2, 7, 8),
+	},
+	{
+		op:       wasm.OpcodeVecF32x4RelaxedMin,
+		operands: [][]byte{f32x4(float32(math.Copysign(0, -1)), 0, 1, -1), f32x4(0, float32(math.Copysign(0, -1)), 2, -2)},
+		exp:      f32x4(float32(math.Copysign(0, -1)), float32(math.Copysign(0, -1)), 1, -2),
+	},
+	{
+		op:       wasm.OpcodeVecF32x4RelaxedMax,
+		operands: [][]byte{f32x4(float32(math.Copysign(0, -1)), 0, 1, -1), f32x4(0, float32(math.Copysign(0, -1)), 2, -2)},
+		exp:      f32x4(0, 0, 2, -1),
+	},
+	{
+		op:       wasm.OpcodeVecF64x2RelaxedMin,
+		operands: [][]byte{f64x2(0, 1), f64x2(math.Copysign(0, -1), 2)},
+		exp:      f64x2(math.Copysign(0, -1), 1),
+	},
+	{
+		op:       wasm.OpcodeVecF64x2RelaxedMax,
+		operands: [][]byte{f64x2(math.Copysign(0, -1), 1), f64x2(0, 2)},
+		exp:      f64x2(0, 2),
+	},
+	{
+		op:       wasm.OpcodeVecI16x8RelaxedQ15mulrS,
+		operands: [][]byte{i16x8(math.MinInt16, 0x4000, -0x4000, 1, 0, 0, 0, 0), i16x8(math.MinInt16, 0x4000, 0x4000, 1, 0, 0, 0, 0)},
+		exp:      i16x8(math.MaxInt16, 0x2000, -0x2000, 0, 0, 0, 0, 0),
+	},
+	{
+		// The sum of the first products overflows and wraps around.
+		op: wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S,
+		operands: [][]byte{
+			i8x16(-128, -128, 1, 2, -1, 2, 127, 127, 0, 0, 0, 0, 0, 0, 5, 6),
+			i8x16(-128, -128, 3, 4, 5, -6, 127, 127, 0, 0, 0, 0, 0, 0, 7, 8),
+		},
+		exp: i16x8(math.MinInt16, 11, -17, 32258, 0, 0, 0, 83),
+	},
+	{
+		op: wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS,
+		operands: [][]byte{
+			i8x16(-128, -128, 1, 2, -1, 2, 127, 127, 0, 0, 0, 0, 0, 0, 5, 6),
+			i8x16(-128, -128, 3, 4, 5, -6, 127, 127, 0, 0, 0, 0, 0, 0, 7, 8),
+			i32x4(1, 2, 3, 0xffffffff),
+		},
+		exp: i32x4(math.MinInt16+11+1, 32258-17+2, 3, 83-1),
+	},
+}
+
+// relaxedSIMDWasm exports a function for each of relaxedSIMDCases named after its instruction, which loads the
+// operands from the memory at offsets 0, 16 and 32, and stores the result at 48.
+var relaxedSIMDWasm = func() []byte {
+	m := &wasm.Module{
+		TypeSection:   []wasm.FunctionType{{}},
+		MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
+	}
+	for i, tc := range relaxedSIMDCases {
+		body := []byte{wasm.OpcodeI32Const, 48}
+		for j := range tc.operands {
+			body = append(body, wasm.OpcodeI32Const, byte(j*16), wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Load, 4, 0)
+		}
+		body = append(body,
+			wasm.OpcodeVecPrefix, byte(tc.op&0x7f)|0x80, byte(tc.op>>7),
+			wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Store, 4, 0,
+			wasm.OpcodeEnd,
+		)
+		m.FunctionSection = append(m.FunctionSection, 0)
+		m.CodeSection = append(m.CodeSection, wasm.Code{Body: body})
+		m.ExportSection = append(m.ExportSection, wasm.Export{
+			Name: wasm.RelaxedVectorInstructionName(tc.op), Type: wasm.ExternTypeFunc, Index: wasm.Index(i),
+		})
+	}
+	return binaryencoding.EncodeModule(m)
+}()
+
+func testRelaxedSIMDInstructions(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, relaxedSIMDWasm)
+	require.NoError(t, err)
+
+	for _, tc := range relaxedSIMDCases {
+		name := wasm.RelaxedVectorInstructionName(tc.op)
+		t.Run(name, func(t *testing.T) {
+			for i, operand := range tc.operands {
+				require.True(t, mod.Memory().Write(uint32(i*16), operand))
+			}
+			_, err := mod.ExportedFunction(name).Call(testCtx)
+			require.NoError(t, err)
+			actual, ok := mod.Memory().Read(48, 16)
+			require.True(t, ok)
+			require.Equal(t, tc.exp, actual)
+		})
+	}
+}
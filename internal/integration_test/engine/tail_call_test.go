@@ -0,0 +1,418 @@
+package adhoc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var tailCallTests = map[string]testCase{
+	"mutual recursion":        {f: testTailCallMutualRecursion},
+	"indirect":                {f: testTailCallIndirect},
+	"indirect many arguments": {f: testTailCallIndirectManyArguments},
+	"host function":           {f: testTailCallHostFunction},
+	"stack arguments":         {f: testTailCallStackArguments},
+}
+
+// tailCallInterpreterTests are run only by the interpreter, as the compiler rejects them. See TestTailCallCompiler_unsupported.
+var tailCallInterpreterTests = map[string]testCase{
+	"different stack argument sizes": {f: testTailCallDifferentStackArgumentSizes},
+	"listener":                       {f: testTailCallListener},
+}
+
+const tailCallFeatures = api.CoreFeaturesV2 | experimental.CoreFeaturesTailCall
+
+func TestTailCallNotEnabled(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	_, err := r.CompileModule(testCtx, tailCallMutualRecursionWasm)
+	require.EqualError(t, err, "invalid function[0] export[\"is_even\"]: return_call invalid as feature \"tail-call\" is disabled")
+}
+
+func TestTailCallCompiler(t *testing.T) {
+	if !platform.CompilerSupports(tailCallFeatures) {
+		t.Skip()
+	}
+	runAllTests(t, tailCallTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(tailCallFeatures), true)
+}
+
+func TestTailCallCompiler_unsupported(t *testing.T) {
+	if !platform.CompilerSupports(tailCallFeatures) {
+		t.Skip()
+	}
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(tailCallFeatures))
+	defer r.Close(testCtx)
+
+	// The frame of the caller cannot be reused, so these are rejected instead of growing the stack on each tail call.
+	_, err := r.CompileModule(testCtx, tailCallDifferentStackArgumentSizesWasm)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), backend.ErrTailCallStackArgs.Error())
+
+	_, err = r.CompileModule(withTailCallListener(testCtx, new(int)), tailCallMutualRecursionWasm)
+	require.EqualError(t, err, "tail calls with function listeners are not supported by the compiler: use the interpreter")
+}
+
+func TestTailCallInterpreter(t *testing.T) {
+	config := wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(tailCallFeatures)
+	runAllTests(t, tailCallTests, config, false)
+	runAllTests(t, tailCallInterpreterTests, config, false)
+}
+
+// tailCallMutualRecursionWasm exports "is_even" and "is_odd" of type (i64) -> i32 which call each other via return_call.
+var tailCallMutualRecursionWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i32}}},
+	FunctionSection: []wasm.Index{0, 0},
+	CodeSection: []wasm.Code{
+		{Body: tailCallCountdownBody(1, 1)},
+		{Body: tailCallCountdownBody(0, 0)},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "is_even", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "is_odd", Type: wasm.ExternTypeFunc, Index: 1},
+	},
+})
+
+// tailCallCountdownBody returns the body of the function of type (i64) -> i32 which returns `ret` if the param is zero,
+// or otherwise calls the function at `next` with the param decremented.
+func tailCallCountdownBody(ret byte, next wasm.Index) []byte {
+	return []byte{
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI64Eqz,
+		wasm.OpcodeIf, 0x40,
+		wasm.OpcodeI32Const, ret,
+		wasm.OpcodeReturn,
+		wasm.OpcodeEnd,
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI64Const, 1,
+		wasm.OpcodeI64Sub,
+		wasm.OpcodeTailCallReturnCall, byte(next),
+		wasm.OpcodeEnd,
+	}
+}
+
+func testTailCallMutualRecursion(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, tailCallMutualRecursionWasm)
+	require.NoError(t, err)
+
+	// The depth is far beyond the limit of the call stack, so this only succeeds if the frames are reused.
+	const depth = 10_000_000
+	for _, tc := range []struct {
+		name     string
+		n        uint64
+		expected uint64
+	}{
+		{name: "is_even", n: depth, expected: 1},
+		{name: "is_odd", n: depth, expected: 0},
+	} {
+		res, err := mod.ExportedFunction(tc.name).Call(testCtx, tc.n)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, res[0])
+	}
+}
+
+func testTailCallIndirect(t *testing.T, r wazero.Runtime) {
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+		},
+		FunctionSection: []wasm.Index{0, 0, 0, 1},
+		TableSection:    []wasm.Table{{Min: 3, Type: wasm.RefTypeFuncref}},
+		ElementSection: []wasm.ElementSegment{{
+			OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+			Init:       []wasm.Index{0, 1, 2},
+			Type:       wasm.RefTypeFuncref,
+		}},
+		CodeSection: []wasm.Code{
+			// double: (x) -> x * 2
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeI32Mul,
+				wasm.OpcodeEnd,
+			}},
+			// inc: (x) -> x + 1
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			}},
+			// countdown: (n) -> 0, which calls itself via the table until n reaches zero.
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Eqz,
+				wasm.OpcodeIf, 0x40,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeReturn,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Sub,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeTailCallReturnCallIndirect, 0, 0,
+				wasm.OpcodeEnd,
+			}},
+			// dispatch: (x, index) -> table[index](x)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeTailCallReturnCallIndirect, 0, 0,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "countdown", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "dispatch", Type: wasm.ExternTypeFunc, Index: 3},
+		},
+	})
+
+	mod, err := r.Instantiate(testCtx, bin)
+	require.NoError(t, err)
+
+	dispatch := mod.ExportedFunction("dispatch")
+	res, err := dispatch.Call(testCtx, 10, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), res[0])
+	res, err = dispatch.Call(testCtx, 10, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), res[0])
+
+	_, err = dispatch.Call(testCtx, 10, 3)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid table access")
+
+	res, err = mod.ExportedFunction("countdown").Call(testCtx, 10_000_000)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+}
+
+func testTailCallIndirectManyArguments(t *testing.T, r wazero.Runtime) {
+	// The params take all the argument registers on amd64 with the execution and module contexts, but none of them
+	// is passed on the stack.
+	typ := wasm.FunctionType{Params: []wasm.ValueType{i64, i64, i64, i64, i64, i64, i64}, Results: []wasm.ValueType{i64}}
+
+	// countdown: (n, acc, p2, ..., p6) returns acc if n is zero, and otherwise calls itself via the table with
+	// (n-1, acc+p6, p2, ..., p6).
+	body := []byte{
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI64Eqz,
+		wasm.OpcodeIf, 0x40,
+		wasm.OpcodeLocalGet, 1,
+		wasm.OpcodeReturn,
+		wasm.OpcodeEnd,
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI64Const, 1,
+		wasm.OpcodeI64Sub,
+		wasm.OpcodeLocalGet, 1,
+		wasm.OpcodeLocalGet, 6,
+		wasm.OpcodeI64Add,
+	}
+	for i := byte(2); i <= 6; i++ {
+		body = append(body, wasm.OpcodeLocalGet, i)
+	}
+	body = append(body, wasm.OpcodeI32Const, 0, wasm.OpcodeTailCallReturnCallIndirect, 0, 0, wasm.OpcodeEnd)
+
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{typ},
+		FunctionSection: []wasm.Index{0},
+		TableSection:    []wasm.Table{{Min: 1, Type: wasm.RefTypeFuncref}},
+		ElementSection: []wasm.ElementSegment{{
+			OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+			Init:       []wasm.Index{0},
+			Type:       wasm.RefTypeFuncref,
+		}},
+		CodeSection:   []wasm.Code{{Body: body}},
+		ExportSection: []wasm.Export{{Name: "countdown", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+
+	mod, err := r.Instantiate(testCtx, bin)
+	require.NoError(t, err)
+
+	const depth = 10_000_000
+	res, err := mod.ExportedFunction("countdown").Call(testCtx, depth, 0, 2, 3, 4, 5, 6)
+	require.NoError(t, err)
+	require.Equal(t, uint64(depth*6), res[0])
+}
+
+func testTailCallHostFunction(t *testing.T, r wazero.Runtime) {
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithFunc(func(x uint32) uint32 { return x + 100 }).
+		Export("add100").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+		ImportSection: []wasm.Import{
+			{Module: "env", Name: "add100", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		},
+		FunctionSection: []wasm.Index{0},
+		TableSection:    []wasm.Table{{Min: 1, Type: wasm.RefTypeFuncref}},
+		ElementSection: []wasm.ElementSegment{{
+			OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+			Init:       []wasm.Index{0},
+			Type:       wasm.RefTypeFuncref,
+		}},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Eqz,
+			wasm.OpcodeIf, 0x40,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeTailCallReturnCall, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeTailCallReturnCallIndirect, 0, 0,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{{Name: "f", Type: wasm.ExternTypeFunc, Index: 1}},
+	})
+
+	mod, err := r.Instantiate(testCtx, bin)
+	require.NoError(t, err)
+
+	// Zero goes through return_call, and others through return_call_indirect.
+	for _, x := range []uint64{0, 1, 5} {
+		res, err := mod.ExportedFunction("f").Call(testCtx, x)
+		require.NoError(t, err)
+		require.Equal(t, x+100, res[0])
+	}
+}
+
+// tailCallManyParamsType has enough params to pass some of them on the stack on all the supported architectures.
+var tailCallManyParamsType = wasm.FunctionType{
+	Params:  []wasm.ValueType{i64, i64, i64, i64, i64, i64, i64, i64, i64, i64, i64, i64},
+	Results: []wasm.ValueType{i64},
+}
+
+func testTailCallStackArguments(t *testing.T, r wazero.Runtime) {
+	// rotate: (n, acc, p2, ..., p11) returns acc if n is zero, and otherwise calls itself with
+	// (n-1, acc+p11*n, p3, ..., p11, p2) which rotates the params passed on the stack.
+	body := []byte{
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI64Eqz,
+		wasm.OpcodeIf, 0x40,
+		wasm.OpcodeLocalGet, 1,
+		wasm.OpcodeReturn,
+		wasm.OpcodeEnd,
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI64Const, 1,
+		wasm.OpcodeI64Sub,
+		wasm.OpcodeLocalGet, 1,
+		wasm.OpcodeLocalGet, 11,
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI64Mul,
+		wasm.OpcodeI64Add,
+	}
+	for i := byte(3); i <= 11; i++ {
+		body = append(body, wasm.OpcodeLocalGet, i)
+	}
+	body = append(body, wasm.OpcodeLocalGet, 2, wasm.OpcodeTailCallReturnCall, 0, wasm.OpcodeEnd)
+
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{tailCallManyParamsType},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: body}},
+		ExportSection:   []wasm.Export{{Name: "rotate", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+
+	mod, err := r.Instantiate(testCtx, bin)
+	require.NoError(t, err)
+
+	params := []uint64{100_000, 0, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	expected := append([]uint64(nil), params...)
+	for expected[0] != 0 {
+		n := expected[0]
+		next := []uint64{n - 1, expected[1] + expected[11]*n}
+		next = append(next, expected[3:]...)
+		expected = append(next, expected[2])
+	}
+
+	res, err := mod.ExportedFunction("rotate").Call(testCtx, params...)
+	require.NoError(t, err)
+	require.Equal(t, expected[1], res[0])
+}
+
+// tailCallDifferentStackArgumentSizesWasm exports "one_to_many" and "many_to_one" which call the function taking the
+// different size of the arguments passed on the stack via return_call.
+var tailCallDifferentStackArgumentSizesWasm = func() []byte {
+	sum := []byte{wasm.OpcodeLocalGet, 0}
+	for i := byte(1); i < 12; i++ {
+		sum = append(sum, wasm.OpcodeLocalGet, i, wasm.OpcodeI64Add)
+	}
+	sum = append(sum, wasm.OpcodeEnd)
+
+	one := []byte{wasm.OpcodeLocalGet, 0}
+	for i := byte(1); i < 12; i++ {
+		one = append(one, wasm.OpcodeI64Const, i)
+	}
+	one = append(one, wasm.OpcodeTailCallReturnCall, 0, wasm.OpcodeEnd)
+
+	return binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			tailCallManyParamsType,
+			{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i64}},
+		},
+		FunctionSection: []wasm.Index{0, 1, 0, 1},
+		CodeSection: []wasm.Code{
+			// sum: (p0, ..., p11) -> p0 + ... + p11
+			{Body: sum},
+			// one_to_many: (x) -> sum(x, 1, ..., 11)
+			{Body: one},
+			// many_to_one: (p0, ..., p11) -> inc(p11)
+			{Body: []byte{wasm.OpcodeLocalGet, 11, wasm.OpcodeTailCallReturnCall, 3, wasm.OpcodeEnd}},
+			// inc: (x) -> x + 1
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI64Const, 1, wasm.OpcodeI64Add, wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "one_to_many", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "many_to_one", Type: wasm.ExternTypeFunc, Index: 2},
+		},
+	})
+}()
+
+func testTailCallDifferentStackArgumentSizes(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, tailCallDifferentStackArgumentSizesWasm)
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("one_to_many").Call(testCtx, 1000)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000+66), res[0])
+
+	res, err = mod.ExportedFunction("many_to_one").Call(testCtx, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), res[0])
+}
+
+// withTailCallListener returns the context with the function listener which counts the calls into before.
+func withTailCallListener(ctx context.Context, before *int) context.Context {
+	return experimental.WithFunctionListenerFactory(ctx, experimental.FunctionListenerFactoryFunc(
+		func(api.FunctionDefinition) experimental.FunctionListener {
+			return experimental.FunctionListenerFunc(
+				func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {
+					*before++
+				})
+		}))
+}
+
+func testTailCallListener(t *testing.T, r wazero.Runtime) {
+	var before int
+	ctx := withTailCallListener(testCtx, &before)
+	mod, err := r.Instantiate(ctx, tailCallMutualRecursionWasm)
+	require.NoError(t, err)
+
+	// With a listener, each tail call is observed as a regular call.
+	res, err := mod.ExportedFunction("is_even").Call(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+	require.Equal(t, 11, before)
+}
@@ -327,6 +327,12 @@ var spectestWasm []byte
 // Run runs all the test inside the testDataFS file system where all the cases are described
 // via JSON files created from wast2json.
 func Run(t *testing.T, testDataFS embed.FS, ctx context.Context, config wazero.RuntimeConfig) {
+	RunFiltered(t, testDataFS, ctx, config, nil)
+}
+
+// RunFiltered is like Run, but only runs the test cases whose name (without .wast!) satisfies include.
+// A nil include runs all the cases.
+func RunFiltered(t *testing.T, testDataFS embed.FS, ctx context.Context, config wazero.RuntimeConfig, include func(caseName string) bool) {
 	files, err := testDataFS.ReadDir("testdata")
 	require.NoError(t, err)
 
@@ -343,6 +349,9 @@ func Run(t *testing.T, testDataFS embed.FS, ctx context.Context, config wazero.R
 	require.True(t, len(caseNames) > 0, "len(caseNames)=%d (not greater than zero)", len(caseNames))
 
 	for _, f := range caseNames {
+		if include != nil && !include(f) {
+			continue
+		}
 		RunCase(t, testDataFS, f, ctx, config, -1, 0, math.MaxInt)
 	}
 }
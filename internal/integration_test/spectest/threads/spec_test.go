@@ -19,10 +19,14 @@ var testcases embed.FS
 const enabledFeatures = api.CoreFeaturesV2 | experimental.CoreFeaturesThreads
 
 func TestCompiler(t *testing.T) {
-	if !platform.CompilerSupported() {
-		t.Skip()
+	features := enabledFeatures
+	if !platform.CompilerSupports(features) {
+		// e.g. riscv64 where the compiler doesn't support SIMD, which is not used by the threads cases.
+		if features &^= api.CoreFeatureSIMD; !platform.CompilerSupports(features) {
+			t.Skip()
+		}
 	}
-	spectest.Run(t, testcases, context.Background(), wazero.NewRuntimeConfigCompiler().WithCoreFeatures(enabledFeatures))
+	spectest.Run(t, testcases, context.Background(), wazero.NewRuntimeConfigCompiler().WithCoreFeatures(features))
 }
 
 func TestInterpreter(t *testing.T) {
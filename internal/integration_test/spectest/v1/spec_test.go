@@ -11,7 +11,7 @@ import (
 )
 
 func TestCompiler(t *testing.T) {
-	if !platform.CompilerSupported() {
+	if !platform.CompilerSupports(api.CoreFeaturesV1) {
 		t.Skip()
 	}
 	spectest.Run(t, Testcases, context.Background(), wazero.NewRuntimeConfigCompiler().WithCoreFeatures(api.CoreFeaturesV1))
@@ -2,6 +2,7 @@ package v2
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/tetratelabs/wazero"
@@ -14,11 +15,21 @@ const enabledFeatures = api.CoreFeaturesV2
 
 func TestCompiler(t *testing.T) {
 	if !platform.CompilerSupported() {
+		if platform.CompilerSupports(enabledFeatures &^ api.CoreFeatureSIMD) {
+			// e.g. riscv64 where the compiler doesn't support SIMD.
+			spectest.RunFiltered(t, Testcases, context.Background(),
+				wazero.NewRuntimeConfigCompiler().WithCoreFeatures(enabledFeatures&^api.CoreFeatureSIMD), notSIMD)
+			return
+		}
 		t.Skip()
 	}
 	spectest.Run(t, Testcases, context.Background(), wazero.NewRuntimeConfigCompiler().WithCoreFeatures(enabledFeatures))
 }
 
+func notSIMD(caseName string) bool {
+	return !strings.HasPrefix(caseName, "simd_")
+}
+
 func TestInterpreter(t *testing.T) {
 	spectest.Run(t, Testcases, context.Background(), wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(enabledFeatures))
 }
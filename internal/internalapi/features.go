@@ -0,0 +1,21 @@
+package internalapi
+
+import "fmt"
+
+// coreFeatureNames holds the names of the api.CoreFeatures defined in the experimental package, which the api package
+// cannot import.
+var coreFeatureNames = map[uint64]string{}
+
+// RegisterCoreFeatureName registers the name returned by api.CoreFeatures String for the feature. This must only be
+// called while initializing the package defining the feature, as the names are read without locking.
+func RegisterCoreFeatureName(feature uint64, name string) {
+	if prev, ok := coreFeatureNames[feature]; ok {
+		panic(fmt.Sprintf("BUG: feature %#x is already named %q", feature, prev))
+	}
+	coreFeatureNames[feature] = name
+}
+
+// CoreFeatureName returns the name registered for the feature by RegisterCoreFeatureName, or "" if there is none.
+func CoreFeatureName(feature uint64) string {
+	return coreFeatureNames[feature]
+}
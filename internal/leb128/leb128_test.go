@@ -148,6 +148,15 @@ func TestDecodeUint64(t *testing.T) {
 			require.Equal(t, c.exp, actual)
 			require.Equal(t, uint64(len(c.bytes)), num)
 		}
+
+		actual, num, err = DecodeUint64(bytes.NewReader(c.bytes))
+		if c.expErr {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, c.exp, actual)
+			require.Equal(t, uint64(len(c.bytes)), num)
+		}
 	}
 }
 
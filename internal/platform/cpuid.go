@@ -13,6 +13,12 @@ type CpuFeatureFlags interface {
 
 type CpuFeature uint64
 
+// CompilerCpuFeatures returns the bits of CpuFeatureFlags.Raw for the features which the code generated by the
+// compiler depends on. The code compiled on this CPU runs on any other CPU which has at least these features.
+func CompilerCpuFeatures() uint64 {
+	return CpuFeatures.Raw() & compilerRawCpuFeatures
+}
+
 const (
 	// CpuFeatureAmd64SSE3 is the flag to query CpuFeatureFlags.Has for SSEv3 capabilities on amd64
 	CpuFeatureAmd64SSE3 CpuFeature = 1
@@ -5,6 +5,10 @@ package platform
 // CpuFeatures exposes the capabilities for this CPU, queried via the Has, HasExtra methods.
 var CpuFeatures = loadCpuFeatureFlags()
 
+// compilerRawCpuFeatures is the bits of cpuFeatureFlags.Raw for the features used by the compiler: SSE4.1 is required
+// by the compiler, and ABM enables LZCNT, TZCNT and POPCNT.
+const compilerRawCpuFeatures = 1<<1 | 1<<3
+
 // cpuFeatureFlags implements CpuFeatureFlags interface.
 type cpuFeatureFlags struct {
 	flags      uint64
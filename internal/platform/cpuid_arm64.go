@@ -7,6 +7,10 @@ import "runtime"
 // CpuFeatures exposes the capabilities for this CPU, queried via the Has, HasExtra methods.
 var CpuFeatures = loadCpuFeatureFlags()
 
+// compilerRawCpuFeatures is the bits of cpuFeatureFlags.Raw for the features used by the compiler: the atomic
+// instructions are required by the threads feature.
+const compilerRawCpuFeatures = 1 << 0
+
 // cpuFeatureFlags implements CpuFeatureFlags interface.
 type cpuFeatureFlags struct {
 	isar0 uint64
@@ -4,6 +4,9 @@ package platform
 
 var CpuFeatures CpuFeatureFlags = &cpuFeatureFlags{}
 
+// compilerRawCpuFeatures is zero as no features are reported on unsupported platforms.
+const compilerRawCpuFeatures = 0
+
 // cpuFeatureFlags implements CpuFeatureFlags for unsupported platforms.
 type cpuFeatureFlags struct{}
 
@@ -0,0 +1,23 @@
+//go:build !tinygo
+
+package platform
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// sysRiscvFlushIcache is the riscv_flush_icache system call number, which is not defined in the syscall package.
+//
+// See https://docs.kernel.org/arch/riscv/cmodx.html
+const sysRiscvFlushIcache = 259
+
+// flushICache makes the instructions written to b visible to the instruction fetch. Unlike amd64 and arm64,
+// riscv64 does not guarantee the coherence between the data and instruction caches after mprotect.
+func flushICache(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	start := uintptr(unsafe.Pointer(&b[0]))
+	_, _, _ = syscall.Syscall(sysRiscvFlushIcache, start, start+uintptr(len(b)), 0)
+}
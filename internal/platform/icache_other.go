@@ -0,0 +1,6 @@
+//go:build (linux || darwin) && !tinygo && !(linux && riscv64)
+
+package platform
+
+// flushICache is a no-op except on linux/riscv64. See icache_linux_riscv64.go.
+func flushICache([]byte) {}
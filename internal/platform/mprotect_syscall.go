@@ -6,5 +6,8 @@ import "syscall"
 
 // MprotectRX is like syscall.Mprotect with RX permission.
 func MprotectRX(b []byte) (err error) {
-	return syscall.Mprotect(b, syscall.PROT_READ|syscall.PROT_EXEC)
+	if err = syscall.Mprotect(b, syscall.PROT_READ|syscall.PROT_EXEC); err == nil {
+		flushICache(b)
+	}
+	return
 }
@@ -17,6 +17,10 @@ func CompilerSupported() bool {
 }
 
 func CompilerSupports(features api.CoreFeatures) bool {
+	if features.IsEnabled(experimental.CoreFeaturesGC) {
+		// The garbage collected heap is only implemented by the interpreter.
+		return false
+	}
 	switch runtime.GOOS {
 	case "linux", "darwin", "freebsd", "netbsd", "dragonfly", "windows":
 		if runtime.GOARCH == "arm64" {
@@ -25,6 +29,10 @@ func CompilerSupports(features api.CoreFeatures) bool {
 			}
 			return true
 		}
+		if runtime.GOARCH == "riscv64" && runtime.GOOS == "linux" {
+			// The riscv64 backend has no vector instruction support, so the compiler rejects the modules using SIMD.
+			return !features.IsEnabled(api.CoreFeatureSIMD)
+		}
 		fallthrough
 	case "solaris", "illumos":
 		return runtime.GOARCH == "amd64" && CpuFeatures.Has(CpuFeatureAmd64SSE4_1)
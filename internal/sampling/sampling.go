@@ -0,0 +1,29 @@
+// Package sampling defines the contract between the engines and the sampling profiler in experimental/profiling.
+package sampling
+
+import (
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
+)
+
+// Sampler collects the stack samples of the calls of the functions compiled with wasm.Module Profiling.
+type Sampler interface {
+	// Start is called when such a call starts. The sampler sets requested to non-zero, atomically, whenever it wants
+	// a stack sample, which the engine takes at the next safepoint and resets requested to zero. stop is called
+	// when the call returns.
+	Start(requested *uint32) (stop func())
+
+	// Sample records the stack of a call, ordered from the innermost frame. The stack is only valid during the call.
+	Sample(stack []Frame)
+}
+
+// Frame is a frame of a stack sample.
+type Frame struct {
+	// Function is the definition of the function of the frame.
+	Function api.FunctionDefinition
+	// DWARFLines is the DWARF line information of the module defining Function, or nil if not available.
+	DWARFLines *wasmdebug.DWARFLines
+	// SourceOffset is the offset of the current instruction of the frame in the Wasm binary, which is only valid if
+	// DWARFLines is non-nil.
+	SourceOffset uint64
+}
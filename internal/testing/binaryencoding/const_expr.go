@@ -5,6 +5,12 @@ import (
 )
 
 func encodeConstantExpression(expr wasm.ConstantExpression) (ret []byte) {
+	if expr.IsExtended() {
+		// Data already contains the encoding of all the instructions.
+		ret = append(ret, expr.Data...)
+		ret = append(ret, wasm.OpcodeEnd)
+		return
+	}
 	if expr.Opcode == wasm.OpcodeVecV128Const {
 		ret = append(ret, wasm.OpcodeVecPrefix)
 	}
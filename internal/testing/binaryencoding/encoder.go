@@ -13,7 +13,7 @@ var sizePrefixedName = []byte{4, 'n', 'a', 'm', 'e'}
 func EncodeModule(m *wasm.Module) (bytes []byte) {
 	bytes = append(Magic, version...)
 	if m.SectionElementCount(wasm.SectionIDType) > 0 {
-		bytes = append(bytes, encodeTypeSection(m.TypeSection)...)
+		bytes = append(bytes, encodeTypeSection(m.TypeSection, m.CompositeTypes)...)
 	}
 	if m.SectionElementCount(wasm.SectionIDImport) > 0 {
 		bytes = append(bytes, encodeImportSection(m.ImportSection)...)
@@ -27,6 +27,9 @@ func EncodeModule(m *wasm.Module) (bytes []byte) {
 	if m.SectionElementCount(wasm.SectionIDMemory) > 0 {
 		bytes = append(bytes, encodeMemorySection(m.MemorySection)...)
 	}
+	if m.SectionElementCount(wasm.SectionIDTag) > 0 {
+		bytes = append(bytes, encodeTagSection(m.TagSection)...)
+	}
 	if m.SectionElementCount(wasm.SectionIDGlobal) > 0 {
 		bytes = append(bytes, encodeGlobalSection(m.GlobalSection)...)
 	}
@@ -1,6 +1,7 @@
 package binaryencoding
 
 import (
+	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
@@ -13,3 +14,51 @@ func EncodeFunctionType(t *wasm.FunctionType) []byte {
 	data := append([]byte{0x60}, EncodeValTypes(t.Params)...)
 	return append(data, EncodeValTypes(t.Results)...)
 }
+
+// encodeSubType returns the type definition of experimental.CoreFeaturesGC, prefixed with its super type if any.
+//
+// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md#type-definitions
+func encodeSubType(ft *wasm.FunctionType, ct *wasm.CompositeType) (data []byte) {
+	if ct.SuperType != nil || !ct.Final {
+		if ct.Final {
+			data = append(data, wasm.TypePrefixSubFinal)
+		} else {
+			data = append(data, wasm.TypePrefixSub)
+		}
+		if ct.SuperType != nil {
+			data = append(data, 1)
+			data = append(data, leb128.EncodeUint32(*ct.SuperType)...)
+		} else {
+			data = append(data, 0)
+		}
+	}
+	switch ct.Kind {
+	case wasm.CompositeTypeKindStruct:
+		data = append(data, wasm.CompositeTypeKindStruct)
+		data = append(data, leb128.EncodeUint32(uint32(len(ct.Fields)))...)
+		for i := range ct.Fields {
+			data = append(data, encodeFieldType(&ct.Fields[i])...)
+		}
+	case wasm.CompositeTypeKindArray:
+		data = append(data, wasm.CompositeTypeKindArray)
+		data = append(data, encodeFieldType(&ct.Fields[0])...)
+	default:
+		data = append(data, EncodeFunctionType(ft)...)
+	}
+	return
+}
+
+// encodeFieldType returns the type of a struct field or an array element. Fields referencing a concrete type are
+// encoded as nullable references.
+func encodeFieldType(f *wasm.FieldType) (data []byte) {
+	if wasm.IsReferenceValueType(f.StorageType) && f.HeapType >= 0 {
+		data = append(data, wasm.ValueTypePrefixRefNull)
+		data = append(data, leb128.EncodeInt64(int64(f.HeapType))...)
+	} else {
+		data = append(data, f.StorageType)
+	}
+	if f.Mutable {
+		return append(data, 1)
+	}
+	return append(data, 0)
+}
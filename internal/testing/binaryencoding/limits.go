@@ -9,7 +9,9 @@ import (
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#limits%E2%91%A6
 //
 // Extended in threads proposal: https://webassembly.github.io/threads/core/binary/types.html#limits
-func EncodeLimitsType(min uint32, max *uint32, shared bool) []byte {
+//
+// Extended in memory64 proposal: https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md#binary-format
+func EncodeLimitsType(min uint32, max *uint32, shared, is64 bool) []byte {
 	var flag uint32
 	if max != nil {
 		flag = 0x01
@@ -17,9 +19,14 @@ func EncodeLimitsType(min uint32, max *uint32, shared bool) []byte {
 	if shared {
 		flag |= 0x02
 	}
-	ret := append(leb128.EncodeUint32(flag), leb128.EncodeUint32(min)...)
+	encode := leb128.EncodeUint32
+	if is64 {
+		flag |= 0x04
+		encode = func(v uint32) []byte { return leb128.EncodeUint64(uint64(v)) }
+	}
+	ret := append(leb128.EncodeUint32(flag), encode(min)...)
 	if max != nil {
-		ret = append(ret, leb128.EncodeUint32(*max)...)
+		ret = append(ret, encode(*max)...)
 	}
 	return ret
 }
@@ -16,7 +16,10 @@ func encodeSection(sectionID wasm.SectionID, contents []byte) []byte {
 //
 // See EncodeFunctionType
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#type-section%E2%91%A0
-func encodeTypeSection(types []wasm.FunctionType) []byte {
+func encodeTypeSection(types []wasm.FunctionType, composites []wasm.CompositeType) []byte {
+	if composites != nil {
+		return encodeGCTypeSection(types, composites)
+	}
 	contents := leb128.EncodeUint32(uint32(len(types)))
 	for i := range types {
 		t := &types[i]
@@ -25,6 +28,31 @@ func encodeTypeSection(types []wasm.FunctionType) []byte {
 	return encodeSection(wasm.SectionIDType, contents)
 }
 
+// encodeGCTypeSection encodes a wasm.SectionIDType with the recursion groups and the sub types of
+// experimental.CoreFeaturesGC.
+//
+// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md#binary-format
+func encodeGCTypeSection(types []wasm.FunctionType, composites []wasm.CompositeType) []byte {
+	var groups uint32
+	var contents []byte
+	for start := 0; start < len(composites); {
+		end := start + 1
+		for end < len(composites) && composites[end].RecGroup == wasm.Index(start) {
+			end++
+		}
+		if end-start > 1 {
+			contents = append(contents, wasm.TypePrefixRec)
+			contents = append(contents, leb128.EncodeUint32(uint32(end-start))...)
+		}
+		for i := start; i < end; i++ {
+			contents = append(contents, encodeSubType(&types[i], &composites[i])...)
+		}
+		groups++
+		start = end
+	}
+	return encodeSection(wasm.SectionIDType, append(leb128.EncodeUint32(groups), contents...))
+}
+
 // encodeImportSection encodes a wasm.SectionIDImport for the given imports in WebAssembly 1.0 (20191205) Binary
 // Format.
 //
@@ -84,11 +112,27 @@ func encodeTableSection(tables []wasm.Table) []byte {
 //
 // See EncodeMemory
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-section%E2%91%A0
-func encodeMemorySection(memory *wasm.Memory) []byte {
-	contents := append([]byte{1}, EncodeMemory(memory)...)
+func encodeMemorySection(memories []wasm.Memory) []byte {
+	contents := leb128.EncodeUint32(uint32(len(memories)))
+	for i := range memories {
+		contents = append(contents, EncodeMemory(&memories[i])...)
+	}
 	return encodeSection(wasm.SectionIDMemory, contents)
 }
 
+// encodeTagSection encodes a wasm.SectionIDTag for the given tag type indexes in the exception handling proposal's
+// Binary Format.
+//
+// See https://webassembly.github.io/exception-handling/core/binary/modules.html#tag-section
+func encodeTagSection(tags []wasm.Index) []byte {
+	contents := leb128.EncodeUint32(uint32(len(tags)))
+	for _, typeIdx := range tags {
+		contents = append(contents, 0x00) // attribute: exception
+		contents = append(contents, leb128.EncodeUint32(typeIdx)...)
+	}
+	return encodeSection(wasm.SectionIDTag, contents)
+}
+
 // encodeGlobalSection encodes a wasm.SectionIDGlobal for the given globals in WebAssembly 1.0 (20191205) Binary
 // Format.
 //
@@ -8,5 +8,10 @@ import (
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-table
 func EncodeTable(i *wasm.Table) []byte {
-	return append([]byte{i.Type}, EncodeLimitsType(i.Min, i.Max, false)...)
+	if i.Init != nil {
+		// A table with an initializer is prefixed with 0x40 0x00.
+		data := append([]byte{0x40, 0x00, i.Type}, EncodeLimitsType(i.Min, i.Max, false, false)...)
+		return append(data, encodeConstantExpression(*i.Init)...)
+	}
+	return append([]byte{i.Type}, EncodeLimitsType(i.Min, i.Max, false, false)...)
 }
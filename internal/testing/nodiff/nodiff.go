@@ -252,8 +252,8 @@ func ensureDummyImports(r wazero.Runtime, origin *wasm.Module, requireNoError fu
 					Type: imp.DescGlobal, Init: wasm.ConstantExpression{Opcode: opcode, Data: data},
 				})
 			case wasm.ExternTypeMemory:
-				m.MemorySection = imp.DescMem
-				index = 0
+				index = uint32(len(m.MemorySection))
+				m.MemorySection = append(m.MemorySection, *imp.DescMem)
 			case wasm.ExternTypeTable:
 				index = uint32(len(m.TableSection))
 				m.TableSection = append(m.TableSection, imp.DescTable)
@@ -6,12 +6,14 @@ import (
 	"io"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/ieee754"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
 func decodeConstantExpression(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret *wasm.ConstantExpression) error {
+	start := r.Size() - int64(r.Len())
 	b, err := r.ReadByte()
 	if err != nil {
 		return fmt.Errorf("read opcode: %v", err)
@@ -46,9 +48,18 @@ func decodeConstantExpression(r *bytes.Reader, enabledFeatures api.CoreFeatures,
 		if err := enabledFeatures.RequireEnabled(api.CoreFeatureBulkMemoryOperations); err != nil {
 			return fmt.Errorf("ref.null is not supported as %w", err)
 		}
+		if enabledFeatures.IsEnabled(experimental.CoreFeaturesFunctionReferences) {
+			// The immediate is a heap type, whose abstract ones are encoded the same as the reference types.
+			_, err = wasm.DecodeHeapType(r, enabledFeatures)
+			break
+		}
 		reftype, err := r.ReadByte()
 		if err != nil {
 			return fmt.Errorf("read reference type for ref.null: %w", err)
+		} else if reftype == wasm.RefTypeExnref {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err != nil {
+				return fmt.Errorf("ref.null exn is not supported as %w", err)
+			}
 		} else if reftype != wasm.RefTypeFuncref && reftype != wasm.RefTypeExternref {
 			return fmt.Errorf("invalid type for ref.null: 0x%x", reftype)
 		}
@@ -80,6 +91,12 @@ func decodeConstantExpression(r *bytes.Reader, enabledFeatures api.CoreFeatures,
 		} else if n != 16 {
 			return fmt.Errorf("read vector const instruction immediates: needs 16 bytes but was %d bytes", n)
 		}
+	case wasm.OpcodeGCPrefix:
+		if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return fmt.Errorf("gc instructions are not supported as %w", err)
+		}
+		// GC instructions, such as struct.new_default, are decoded as an extended constant expression.
+		return decodeExtendedConstantExpression(r, enabledFeatures, start, b, ret)
 	default:
 		return fmt.Errorf("%v for const expression opt code: %#x", ErrInvalidByte, b)
 	}
@@ -93,6 +110,15 @@ func decodeConstantExpression(r *bytes.Reader, enabledFeatures api.CoreFeatures,
 	}
 
 	if b != wasm.OpcodeEnd {
+		if enabledFeatures.IsEnabled(experimental.CoreFeaturesGC) {
+			return decodeExtendedConstantExpression(r, enabledFeatures, start, b, ret)
+		}
+		switch opcode {
+		case wasm.OpcodeI32Const, wasm.OpcodeI64Const, wasm.OpcodeGlobalGet:
+			if enabledFeatures.IsEnabled(experimental.CoreFeaturesExtendedConst) {
+				return decodeExtendedConstantExpression(r, enabledFeatures, start, b, ret)
+			}
+		}
 		return fmt.Errorf("constant expression has been not terminated")
 	}
 
@@ -103,3 +129,107 @@ func decodeConstantExpression(r *bytes.Reader, enabledFeatures api.CoreFeatures,
 	ret.Opcode = opcode
 	return nil
 }
+
+// decodeExtendedConstantExpression decodes the rest of an extended constant expression which starts at the offset
+// start of r. next is the opcode of the next instruction, which has already been read.
+//
+// See wasm.ConstantExpression IsExtended for how the decoded expression is represented.
+func decodeExtendedConstantExpression(r *bytes.Reader, enabledFeatures api.CoreFeatures, start int64, next byte, ret *wasm.ConstantExpression) (err error) {
+	gc := enabledFeatures.IsEnabled(experimental.CoreFeaturesGC)
+	var last wasm.Opcode
+	for b := next; b != wasm.OpcodeEnd; {
+		switch b {
+		case wasm.OpcodeI32Const:
+			_, _, err = leb128.DecodeInt32(r)
+		case wasm.OpcodeI64Const:
+			_, _, err = leb128.DecodeInt64(r)
+		case wasm.OpcodeGlobalGet:
+			_, _, err = leb128.DecodeUint32(r)
+		case wasm.OpcodeI32Add, wasm.OpcodeI32Sub, wasm.OpcodeI32Mul,
+			wasm.OpcodeI64Add, wasm.OpcodeI64Sub, wasm.OpcodeI64Mul:
+			if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesExtendedConst); err != nil {
+				return fmt.Errorf("%s is not supported in const expression as %w", wasm.InstructionName(b), err)
+			}
+		case wasm.OpcodeF32Const, wasm.OpcodeF64Const, wasm.OpcodeRefNull, wasm.OpcodeRefFunc,
+			wasm.OpcodeVecPrefix, wasm.OpcodeGCPrefix:
+			if !gc {
+				return fmt.Errorf("%v for const expression opt code: %#x", ErrInvalidByte, b)
+			}
+			err = decodeConstantExpressionGCInstruction(r, enabledFeatures, b)
+		default:
+			return fmt.Errorf("%v for const expression opt code: %#x", ErrInvalidByte, b)
+		}
+		if err != nil {
+			return fmt.Errorf("read value: %v", err)
+		}
+
+		last = b
+		if b, err = r.ReadByte(); err != nil {
+			return fmt.Errorf("look for end opcode: %v", err)
+		}
+	}
+
+	// As each constant or global.get pushes a value, the expression can only leave a single value if the last
+	// instruction is an arithmetic or GC one.
+	ret.Opcode = last
+	if !ret.IsExtended() {
+		if gc {
+			return fmt.Errorf("extended constant expression must end with an arithmetic or GC instruction but was %s",
+				wasm.InstructionName(last))
+		}
+		return fmt.Errorf("extended constant expression must end with an arithmetic instruction but was %s",
+			wasm.InstructionName(last))
+	}
+
+	ret.Data = make([]byte, r.Size()-int64(r.Len())-1-start)
+	if _, err = r.ReadAt(ret.Data, start); err != nil {
+		return fmt.Errorf("error re-buffering ConstantExpression.Data")
+	}
+	return nil
+}
+
+// decodeConstantExpressionGCInstruction skips the immediates of the instructions which are only allowed in a multi
+// instruction constant expression with experimental.CoreFeaturesGC.
+func decodeConstantExpressionGCInstruction(r *bytes.Reader, enabledFeatures api.CoreFeatures, opcode wasm.Opcode) (err error) {
+	switch opcode {
+	case wasm.OpcodeF32Const:
+		_, err = r.Seek(4, io.SeekCurrent)
+	case wasm.OpcodeF64Const:
+		_, err = r.Seek(8, io.SeekCurrent)
+	case wasm.OpcodeRefNull:
+		_, err = wasm.DecodeHeapType(r, enabledFeatures)
+	case wasm.OpcodeRefFunc:
+		_, _, err = leb128.DecodeUint32(r)
+	case wasm.OpcodeVecPrefix:
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureSIMD); err != nil {
+			return fmt.Errorf("vector instructions are not supported as %w", err)
+		}
+		var vecOp byte
+		if vecOp, err = r.ReadByte(); err != nil {
+			return
+		} else if vecOp != wasm.OpcodeVecV128Const {
+			return fmt.Errorf("invalid vector opcode for const expression: %#x", vecOp)
+		}
+		_, err = r.Seek(16, io.SeekCurrent)
+	case wasm.OpcodeGCPrefix:
+		var gcOp wasm.OpcodeGC
+		if gcOp, _, err = leb128.DecodeUint32(r); err != nil {
+			return
+		}
+		switch gcOp {
+		case wasm.OpcodeGCStructNew, wasm.OpcodeGCStructNewDefault, wasm.OpcodeGCArrayNew, wasm.OpcodeGCArrayNewDefault:
+			_, _, err = leb128.DecodeUint32(r)
+		case wasm.OpcodeGCArrayNewFixed:
+			if _, _, err = leb128.DecodeUint32(r); err == nil {
+				_, _, err = leb128.DecodeUint32(r)
+			}
+		case wasm.OpcodeGCRefI31, wasm.OpcodeGCAnyConvertExtern, wasm.OpcodeGCExternConvertAny:
+		default:
+			return fmt.Errorf("invalid gc opcode for const expression: %#x", gcOp)
+		}
+	}
+	if err == nil && r.Len() == 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
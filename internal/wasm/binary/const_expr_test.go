@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -93,6 +94,62 @@ func TestDecodeConstantExpression(t *testing.T) {
 	}
 }
 
+func TestDecodeConstantExpression_extended(t *testing.T) {
+	tests := []struct {
+		in  []byte
+		exp wasm.ConstantExpression
+	}{
+		{
+			in: []byte{
+				wasm.OpcodeGlobalGet, 0,
+				wasm.OpcodeI32Const, 0x80, 0x01, // 128 in varint encoding.
+				wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			},
+			exp: wasm.ConstantExpression{
+				Opcode: wasm.OpcodeI32Add,
+				Data: []byte{
+					wasm.OpcodeGlobalGet, 0,
+					wasm.OpcodeI32Const, 0x80, 0x01,
+					wasm.OpcodeI32Add,
+				},
+			},
+		},
+		{
+			in: []byte{
+				wasm.OpcodeI64Const, 1,
+				wasm.OpcodeI64Const, 2,
+				wasm.OpcodeI64Sub,
+				wasm.OpcodeI64Const, 3,
+				wasm.OpcodeI64Mul,
+				wasm.OpcodeEnd,
+			},
+			exp: wasm.ConstantExpression{
+				Opcode: wasm.OpcodeI64Mul,
+				Data: []byte{
+					wasm.OpcodeI64Const, 1,
+					wasm.OpcodeI64Const, 2,
+					wasm.OpcodeI64Sub,
+					wasm.OpcodeI64Const, 3,
+					wasm.OpcodeI64Mul,
+				},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		tc := tt
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var actual wasm.ConstantExpression
+			err := decodeConstantExpression(bytes.NewReader(tc.in),
+				api.CoreFeaturesV2|experimental.CoreFeaturesExtendedConst, &actual)
+			require.NoError(t, err)
+			require.Equal(t, tc.exp, actual)
+			require.True(t, actual.IsExtended())
+		})
+	}
+}
+
 func TestDecodeConstantExpression_errors(t *testing.T) {
 	tests := []struct {
 		in          []byte
@@ -178,6 +235,54 @@ func TestDecodeConstantExpression_errors(t *testing.T) {
 			expectedErr: "read vector const instruction immediates: needs 16 bytes but was 8 bytes",
 			features:    api.CoreFeatureSIMD,
 		},
+		{
+			in: []byte{
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			},
+			expectedErr: "constant expression has been not terminated",
+			features:    api.CoreFeaturesV2,
+		},
+		{
+			in: []byte{
+				wasm.OpcodeF32Const, 0, 0, 0, 0,
+				wasm.OpcodeF32Const, 0, 0, 0, 0,
+				wasm.OpcodeF32Add,
+				wasm.OpcodeEnd,
+			},
+			expectedErr: "constant expression has been not terminated",
+			features:    api.CoreFeaturesV2 | experimental.CoreFeaturesExtendedConst,
+		},
+		{
+			in: []byte{
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeI32DivS,
+				wasm.OpcodeEnd,
+			},
+			expectedErr: "invalid byte for const expression opt code: 0x6d",
+			features:    api.CoreFeaturesV2 | experimental.CoreFeaturesExtendedConst,
+		},
+		{
+			in: []byte{
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeEnd,
+			},
+			expectedErr: "extended constant expression must end with an arithmetic instruction but was i32.const",
+			features:    api.CoreFeaturesV2 | experimental.CoreFeaturesExtendedConst,
+		},
+		{
+			in: []byte{
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Const, 2,
+				wasm.OpcodeI32Add,
+			},
+			expectedErr: "look for end opcode: EOF",
+			features:    api.CoreFeaturesV2 | experimental.CoreFeaturesExtendedConst,
+		},
 	}
 
 	for _, tt := range tests {
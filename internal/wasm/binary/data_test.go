@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -97,6 +98,25 @@ func Test_decodeDataSegment(t *testing.T) {
 			expErr:   "memory index must be zero but was 1",
 			features: api.CoreFeatureBulkMemoryOperations,
 		},
+		{
+			in: []byte{
+				0x2,
+				0x1, // Memory index.
+				// Const expression.
+				wasm.OpcodeI32Const, 0x1, wasm.OpcodeEnd,
+				// Two initial data.
+				0x2, 0xf, 0xf,
+			},
+			exp: wasm.DataSegment{
+				MemoryIndex: 1,
+				OffsetExpression: wasm.ConstantExpression{
+					Opcode: wasm.OpcodeI32Const,
+					Data:   []byte{0x1},
+				},
+				Init: []byte{0xf, 0xf},
+			},
+			features: api.CoreFeatureBulkMemoryOperations | experimental.CoreFeaturesMultiMemory,
+		},
 		{
 			in: []byte{
 				0x2,
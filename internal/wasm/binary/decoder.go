@@ -8,6 +8,7 @@ import (
 	"io"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/internal/wasmdebug"
@@ -104,20 +105,25 @@ func DecodeModule(
 				m.NameSection, err = decodeNameSection(r, uint64(limit))
 			}
 		case wasm.SectionIDType:
-			m.TypeSection, err = decodeTypeSection(enabledFeatures, r)
+			m.TypeSection, m.CompositeTypes, err = decodeTypeSection(enabledFeatures, r)
 		case wasm.SectionIDImport:
-			m.ImportSection, m.ImportPerModule, m.ImportFunctionCount, m.ImportGlobalCount, m.ImportMemoryCount, m.ImportTableCount, err = decodeImportSection(r, memSizer, memoryLimitPages, enabledFeatures)
+			m.ImportSection, m.ImportPerModule, m.ImportFunctionCount, m.ImportGlobalCount, m.ImportMemoryCount, m.ImportTableCount, m.ImportTagCount, err = decodeImportSection(r, memSizer, memoryLimitPages, m, enabledFeatures)
 			if err != nil {
 				return nil, err // avoid re-wrapping the error.
 			}
 		case wasm.SectionIDFunction:
 			m.FunctionSection, err = decodeFunctionSection(r)
 		case wasm.SectionIDTable:
-			m.TableSection, err = decodeTableSection(r, enabledFeatures)
+			m.TableSection, err = decodeTableSection(r, m, enabledFeatures)
 		case wasm.SectionIDMemory:
 			m.MemorySection, err = decodeMemorySection(r, enabledFeatures, memSizer, memoryLimitPages)
+		case wasm.SectionIDTag:
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err != nil {
+				return nil, fmt.Errorf("tag section not supported as %v", err)
+			}
+			m.TagSection, err = decodeTagSection(r)
 		case wasm.SectionIDGlobal:
-			if m.GlobalSection, err = decodeGlobalSection(r, enabledFeatures); err != nil {
+			if m.GlobalSection, err = decodeGlobalSection(r, m, enabledFeatures); err != nil {
 				return nil, err // avoid re-wrapping the error.
 			}
 		case wasm.SectionIDExport:
@@ -128,9 +134,9 @@ func DecodeModule(
 			}
 			m.StartSection, err = decodeStartSection(r)
 		case wasm.SectionIDElement:
-			m.ElementSection, err = decodeElementSection(r, enabledFeatures)
+			m.ElementSection, err = decodeElementSection(r, m, enabledFeatures)
 		case wasm.SectionIDCode:
-			m.CodeSection, err = decodeCodeSection(r)
+			m.CodeSection, err = decodeCodeSection(r, m, enabledFeatures)
 		case wasm.SectionIDData:
 			m.DataSection, err = decodeDataSection(r, enabledFeatures)
 		case wasm.SectionIDDataCount:
@@ -165,29 +171,39 @@ func DecodeModule(
 }
 
 // memorySizer derives min, capacity and max pages from decoded wasm.
-type memorySizer func(minPages uint32, maxPages *uint32) (min uint32, capacity uint32, max uint32)
+type memorySizer func(minPages uint32, maxPages *uint32, is64 bool) (min uint32, capacity uint32, max uint32)
 
 // newMemorySizer sets capacity to minPages unless max is defined and
 // memoryCapacityFromMax is true.
 func newMemorySizer(memoryLimitPages uint32, memoryCapacityFromMax bool) memorySizer {
-	return func(minPages uint32, maxPages *uint32) (min, capacity, max uint32) {
+	return func(minPages uint32, maxPages *uint32, is64 bool) (min, capacity, max uint32) {
+		limit := limitMemoryPages(memoryLimitPages, is64)
 		if maxPages != nil {
 			if memoryCapacityFromMax {
 				return minPages, *maxPages, *maxPages
 			}
 			// This is an invalid value: let it propagate, we will fail later.
-			if *maxPages > wasm.MemoryLimitPages {
+			if !is64 && *maxPages > wasm.MemoryLimitPages {
 				return minPages, minPages, *maxPages
 			}
 			// This is a valid value, but it goes over the run-time limit: return the limit.
-			if *maxPages > memoryLimitPages {
-				return minPages, minPages, memoryLimitPages
+			if *maxPages > limit {
+				return minPages, minPages, limit
 			}
 			return minPages, minPages, *maxPages
 		}
 		if memoryCapacityFromMax {
-			return minPages, memoryLimitPages, memoryLimitPages
+			return minPages, limit, limit
 		}
-		return minPages, minPages, memoryLimitPages
+		return minPages, minPages, limit
+	}
+}
+
+// limitMemoryPages returns the run-time limit of pages for a memory: only a 64-bit memory can have more pages than
+// wasm.MemoryLimitPages.
+func limitMemoryPages(memoryLimitPages uint32, is64 bool) uint32 {
+	if !is64 && memoryLimitPages > wasm.MemoryLimitPages {
+		return wasm.MemoryLimitPages
 	}
+	return memoryLimitPages
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -42,7 +43,7 @@ func decodeElementInitValueVector(r *bytes.Reader) ([]wasm.Index, error) {
 	return vec, nil
 }
 
-func decodeElementConstExprVector(r *bytes.Reader, elemType wasm.RefType, enabledFeatures api.CoreFeatures) ([]wasm.Index, error) {
+func decodeElementConstExprVector(r *bytes.Reader, m *wasm.Module, elemType wasm.RefType, enabledFeatures api.CoreFeatures) ([]wasm.Index, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get the size of constexpr vector: %w", err)
@@ -56,7 +57,7 @@ func decodeElementConstExprVector(r *bytes.Reader, elemType wasm.RefType, enable
 		}
 		switch expr.Opcode {
 		case wasm.OpcodeRefFunc:
-			if elemType != wasm.RefTypeFuncref {
+			if !wasm.IsSubtypeValueType(wasm.RefTypeFuncref, elemType) {
 				return nil, fmt.Errorf("element type mismatch: want %s, but constexpr has funcref", wasm.RefTypeName(elemType))
 			}
 			v, _, _ := leb128.LoadUint32(expr.Data)
@@ -65,9 +66,16 @@ func decodeElementConstExprVector(r *bytes.Reader, elemType wasm.RefType, enable
 			}
 			vec[i] = v
 		case wasm.OpcodeRefNull:
-			if elemType != expr.Data[0] {
+			refType := expr.Data[0]
+			if ht, _, err := leb128.LoadInt64(expr.Data); err == nil && ht >= 0 {
+				// ref.null of a concrete type.
+				if refType, err = m.HeapTypeValueType(wasm.HeapType(ht)); err != nil {
+					return nil, err
+				}
+			}
+			if refType != elemType && !wasm.IsSubtypeValueType(refType, elemType) {
 				return nil, fmt.Errorf("element type mismatch: want %s, but constexpr has %s",
-					wasm.RefTypeName(elemType), wasm.RefTypeName(expr.Data[0]))
+					wasm.RefTypeName(elemType), wasm.RefTypeName(refType))
 			}
 			vec[i] = wasm.ElementInitNullReference
 		case wasm.OpcodeGlobalGet:
@@ -82,14 +90,24 @@ func decodeElementConstExprVector(r *bytes.Reader, elemType wasm.RefType, enable
 	return vec, nil
 }
 
-func decodeElementRefType(r *bytes.Reader) (ret wasm.RefType, err error) {
+func decodeElementRefType(r *bytes.Reader, m *wasm.Module, enabledFeatures api.CoreFeatures) (ret wasm.RefType, err error) {
 	ret, err = r.ReadByte()
 	if err != nil {
 		err = fmt.Errorf("read element ref type: %w", err)
 		return
 	}
 	if ret != wasm.RefTypeFuncref && ret != wasm.RefTypeExternref {
-		return 0, errors.New("ref type must be funcref or externref for element as of WebAssembly 2.0")
+		if !enabledFeatures.IsEnabled(experimental.CoreFeaturesFunctionReferences) {
+			return 0, errors.New("ref type must be funcref or externref for element as of WebAssembly 2.0")
+		}
+		if err = r.UnreadByte(); err != nil {
+			return
+		}
+		if ret, err = m.DecodeValueType(r, enabledFeatures); err != nil {
+			return 0, fmt.Errorf("read element ref type: %w", err)
+		} else if !wasm.IsReferenceValueType(ret) || ret == wasm.RefTypeExnref {
+			return 0, fmt.Errorf("invalid element ref type: %s", wasm.ValueTypeName(ret))
+		}
 	}
 	return
 }
@@ -115,7 +133,7 @@ const (
 	elementSegmentPrefixDeclarativeConstExprVector
 )
 
-func decodeElementSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret *wasm.ElementSegment) error {
+func decodeElementSegment(r *bytes.Reader, m *wasm.Module, enabledFeatures api.CoreFeatures, ret *wasm.ElementSegment) error {
 	prefix, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return fmt.Errorf("read element prefix: %w", err)
@@ -205,7 +223,7 @@ func decodeElementSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret
 			return fmt.Errorf("read expr for offset: %w", err)
 		}
 
-		ret.Init, err = decodeElementConstExprVector(r, wasm.RefTypeFuncref, enabledFeatures)
+		ret.Init, err = decodeElementConstExprVector(r, m, wasm.RefTypeFuncref, enabledFeatures)
 		if err != nil {
 			return err
 		}
@@ -213,11 +231,11 @@ func decodeElementSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret
 		ret.Type = wasm.RefTypeFuncref
 		return nil
 	case elementSegmentPrefixPassiveConstExprVector:
-		ret.Type, err = decodeElementRefType(r)
+		ret.Type, err = decodeElementRefType(r, m, enabledFeatures)
 		if err != nil {
 			return err
 		}
-		ret.Init, err = decodeElementConstExprVector(r, ret.Type, enabledFeatures)
+		ret.Init, err = decodeElementConstExprVector(r, m, ret.Type, enabledFeatures)
 		if err != nil {
 			return err
 		}
@@ -239,12 +257,12 @@ func decodeElementSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret
 			return fmt.Errorf("read expr for offset: %w", err)
 		}
 
-		ret.Type, err = decodeElementRefType(r)
+		ret.Type, err = decodeElementRefType(r, m, enabledFeatures)
 		if err != nil {
 			return err
 		}
 
-		ret.Init, err = decodeElementConstExprVector(r, ret.Type, enabledFeatures)
+		ret.Init, err = decodeElementConstExprVector(r, m, ret.Type, enabledFeatures)
 		if err != nil {
 			return err
 		}
@@ -252,11 +270,11 @@ func decodeElementSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret
 		ret.Mode = wasm.ElementModeActive
 		return nil
 	case elementSegmentPrefixDeclarativeConstExprVector:
-		ret.Type, err = decodeElementRefType(r)
+		ret.Type, err = decodeElementRefType(r, m, enabledFeatures)
 		if err != nil {
 			return err
 		}
-		ret.Init, err = decodeElementConstExprVector(r, ret.Type, enabledFeatures)
+		ret.Init, err = decodeElementConstExprVector(r, m, ret.Type, enabledFeatures)
 		if err != nil {
 			return err
 		}
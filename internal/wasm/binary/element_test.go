@@ -114,7 +114,7 @@ func Test_decodeElementConstExprVector(t *testing.T) {
 	for i, tt := range tests {
 		tc := tt
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			actual, err := decodeElementConstExprVector(bytes.NewReader(tc.in), tc.refType, tc.features)
+			actual, err := decodeElementConstExprVector(bytes.NewReader(tc.in), &wasm.Module{}, tc.refType, tc.features)
 			require.NoError(t, err)
 			require.Equal(t, tc.exp, actual)
 		})
@@ -178,7 +178,7 @@ func Test_decodeElementConstExprVector_errors(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := decodeElementConstExprVector(bytes.NewReader(tc.in), tc.refType, tc.features)
+			_, err := decodeElementConstExprVector(bytes.NewReader(tc.in), &wasm.Module{}, tc.refType, tc.features)
 			require.EqualError(t, err, tc.expErr)
 		})
 	}
@@ -453,7 +453,7 @@ func TestDecodeElementSegment(t *testing.T) {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
 			var actual wasm.ElementSegment
-			err := decodeElementSegment(bytes.NewReader(tc.in), tc.features, &actual)
+			err := decodeElementSegment(bytes.NewReader(tc.in), &wasm.Module{}, tc.features, &actual)
 			if tc.expErr != "" {
 				require.EqualError(t, err, tc.expErr)
 			} else {
@@ -466,6 +466,6 @@ func TestDecodeElementSegment(t *testing.T) {
 
 func TestDecodeElementSegment_errors(t *testing.T) {
 	var actual wasm.ElementSegment
-	err := decodeElementSegment(bytes.NewReader([]byte{1}), api.CoreFeatureMultiValue, &actual)
+	err := decodeElementSegment(bytes.NewReader([]byte{1}), &wasm.Module{}, api.CoreFeatureMultiValue, &actual)
 	require.EqualError(t, err, `non-zero prefix for element segment is invalid as feature "bulk-memory-operations" is disabled`)
 }
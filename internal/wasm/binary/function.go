@@ -19,12 +19,25 @@ func decodeFunctionType(enabledFeatures api.CoreFeatures, r *bytes.Reader, ret *
 		return fmt.Errorf("%w: %#x != 0x60", ErrInvalidByte, b)
 	}
 
+	if err = decodeFunctionSignature(enabledFeatures, r, ret, nil); err != nil {
+		return err
+	}
+
+	// cache the key for the function type
+	_ = ret.String()
+
+	return nil
+}
+
+// decodeFunctionSignature decodes the parameter and result types of a function type, following its leading byte.
+// See decodeValueTypes for fixups.
+func decodeFunctionSignature(enabledFeatures api.CoreFeatures, r *bytes.Reader, ret *wasm.FunctionType, fixups *[]typeFixup) (err error) {
 	paramCount, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return fmt.Errorf("could not read parameter count: %w", err)
 	}
 
-	paramTypes, err := decodeValueTypes(r, paramCount)
+	paramTypes, err := decodeValueTypes(r, paramCount, enabledFeatures, fixups)
 	if err != nil {
 		return fmt.Errorf("could not read parameter types: %w", err)
 	}
@@ -41,16 +54,12 @@ func decodeFunctionType(enabledFeatures api.CoreFeatures, r *bytes.Reader, ret *
 		}
 	}
 
-	resultTypes, err := decodeValueTypes(r, resultCount)
+	resultTypes, err := decodeValueTypes(r, resultCount, enabledFeatures, fixups)
 	if err != nil {
 		return fmt.Errorf("could not read result types: %w", err)
 	}
 
 	ret.Params = paramTypes
 	ret.Results = resultTypes
-
-	// cache the key for the function type
-	_ = ret.String()
-
 	return nil
 }
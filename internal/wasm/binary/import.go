@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -14,6 +15,7 @@ func decodeImport(
 	idx uint32,
 	memorySizer memorySizer,
 	memoryLimitPages uint32,
+	m *wasm.Module,
 	enabledFeatures api.CoreFeatures,
 	ret *wasm.Import,
 ) (err error) {
@@ -37,11 +39,15 @@ func decodeImport(
 	case wasm.ExternTypeFunc:
 		ret.DescFunc, _, err = leb128.DecodeUint32(r)
 	case wasm.ExternTypeTable:
-		err = decodeTable(r, enabledFeatures, &ret.DescTable)
+		err = decodeTable(r, m, enabledFeatures, &ret.DescTable)
 	case wasm.ExternTypeMemory:
 		ret.DescMem, err = decodeMemory(r, enabledFeatures, memorySizer, memoryLimitPages)
 	case wasm.ExternTypeGlobal:
-		ret.DescGlobal, err = decodeGlobalType(r)
+		ret.DescGlobal, err = decodeGlobalType(r, m, enabledFeatures)
+	case wasm.ExternTypeTag:
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err == nil {
+			ret.DescTag, err = decodeTagType(r)
+		}
 	default:
 		err = fmt.Errorf("%w: invalid byte for importdesc: %#x", ErrInvalidByte, b)
 	}
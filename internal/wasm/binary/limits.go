@@ -3,45 +3,68 @@ package binary
 import (
 	"bytes"
 	"fmt"
+	"math"
 
 	"github.com/tetratelabs/wazero/internal/leb128"
 )
 
+// memory64MaxPages is the maximum number of pages of a 64-bit memory: 2^48 pages of 2^16 bytes span the whole 64-bit
+// address space.
+//
+// See https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md#validation
+const memory64MaxPages = 1 << 48
+
 // decodeLimitsType returns the `limitsType` (min, max) decoded with the WebAssembly 1.0 (20191205) Binary Format.
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#limits%E2%91%A6
 //
 // Extended in threads proposal: https://webassembly.github.io/threads/core/binary/types.html#limits
-func decodeLimitsType(r *bytes.Reader) (min uint32, max *uint32, shared bool, err error) {
+//
+// Extended in memory64 proposal: https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md#binary-format
+// The limits of 64-bit memories are encoded as u64, and are saturated to math.MaxUint32 here as no memory
+// can have as many pages.
+func decodeLimitsType(r *bytes.Reader) (min uint32, max *uint32, shared, is64 bool, err error) {
 	var flag byte
 	if flag, err = r.ReadByte(); err != nil {
 		err = fmt.Errorf("read leading byte: %v", err)
 		return
 	}
 
-	switch flag {
-	case 0x00, 0x02:
-		min, _, err = leb128.DecodeUint32(r)
-		if err != nil {
-			err = fmt.Errorf("read min of limit: %v", err)
-		}
-	case 0x01, 0x03:
-		min, _, err = leb128.DecodeUint32(r)
-		if err != nil {
-			err = fmt.Errorf("read min of limit: %v", err)
-			return
-		}
+	if flag > 0x07 {
+		err = fmt.Errorf("%v for limits: %#x not in (0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07)", ErrInvalidByte, flag)
+		return
+	}
+	shared = flag&0x02 != 0
+	is64 = flag&0x04 != 0
+
+	if min, err = decodeLimit(r, is64); err != nil {
+		err = fmt.Errorf("read min of limit: %v", err)
+		return
+	}
+	if flag&0x01 != 0 {
 		var m uint32
-		if m, _, err = leb128.DecodeUint32(r); err != nil {
+		if m, err = decodeLimit(r, is64); err != nil {
 			err = fmt.Errorf("read max of limit: %v", err)
 		} else {
 			max = &m
 		}
-	default:
-		err = fmt.Errorf("%v for limits: %#x not in (0x00, 0x01, 0x02, 0x03)", ErrInvalidByte, flag)
 	}
-
-	shared = flag == 0x02 || flag == 0x03
-
 	return
 }
+
+// decodeLimit decodes a single value of limits, which is u64 when is64 is true, and u32 otherwise.
+func decodeLimit(r *bytes.Reader, is64 bool) (uint32, error) {
+	if !is64 {
+		v, _, err := leb128.DecodeUint32(r)
+		return v, err
+	}
+	v, _, err := leb128.DecodeUint64(r)
+	if err != nil {
+		return 0, err
+	} else if v > memory64MaxPages {
+		return 0, fmt.Errorf("memory size must be at most %d pages", uint64(memory64MaxPages))
+	} else if v > math.MaxUint32 {
+		return math.MaxUint32, nil
+	}
+	return uint32(v), nil
+}
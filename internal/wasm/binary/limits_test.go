@@ -19,6 +19,7 @@ func TestLimitsType(t *testing.T) {
 		min      uint32
 		max      *uint32
 		shared   bool
+		is64     bool
 		expected []byte
 	}{
 		{
@@ -76,22 +77,90 @@ func TestLimitsType(t *testing.T) {
 			shared:   true,
 			expected: []byte{0x3, 0xff, 0xff, 0xff, 0xff, 0xf, 0xff, 0xff, 0xff, 0xff, 0xf},
 		},
+		{
+			name:     "min 0, 64-bit",
+			is64:     true,
+			expected: []byte{0x4, 0},
+		},
+		{
+			name:     "min 0, max largest, 64-bit",
+			max:      &largest,
+			is64:     true,
+			expected: []byte{0x5, 0, 0xff, 0xff, 0xff, 0xff, 0xf},
+		},
+		{
+			name:     "min largest max largest, shared, 64-bit",
+			min:      largest,
+			max:      &largest,
+			shared:   true,
+			is64:     true,
+			expected: []byte{0x7, 0xff, 0xff, 0xff, 0xff, 0xf, 0xff, 0xff, 0xff, 0xff, 0xf},
+		},
 	}
 
 	for _, tt := range tests {
 		tc := tt
 
-		b := binaryencoding.EncodeLimitsType(tc.min, tc.max, tc.shared)
+		b := binaryencoding.EncodeLimitsType(tc.min, tc.max, tc.shared, tc.is64)
 		t.Run(fmt.Sprintf("encode - %s", tc.name), func(t *testing.T) {
 			require.Equal(t, tc.expected, b)
 		})
 
 		t.Run(fmt.Sprintf("decode - %s", tc.name), func(t *testing.T) {
-			min, max, shared, err := decodeLimitsType(bytes.NewReader(b))
+			min, max, shared, is64, err := decodeLimitsType(bytes.NewReader(b))
 			require.NoError(t, err)
 			require.Equal(t, min, tc.min)
 			require.Equal(t, max, tc.max)
 			require.Equal(t, shared, tc.shared)
+			require.Equal(t, is64, tc.is64)
+		})
+	}
+}
+
+func TestLimitsType_64(t *testing.T) {
+	largest := uint32(math.MaxUint32)
+
+	tests := []struct {
+		name        string
+		input       []byte
+		expectedMin uint32
+		expectedMax *uint32
+		expectedErr string
+	}{
+		{
+			name:        "saturates to uint32",
+			input:       []byte{0x5, 0x80, 0x80, 0x80, 0x80, 0x10, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40},
+			expectedMin: largest,
+			expectedMax: &largest,
+		},
+		{
+			name:        "min over 2^48 pages",
+			input:       []byte{0x4, 0x81, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40},
+			expectedErr: "read min of limit: memory size must be at most 281474976710656 pages",
+		},
+		{
+			name:        "max over 2^48 pages",
+			input:       []byte{0x5, 0x0, 0x81, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40},
+			expectedErr: "read max of limit: memory size must be at most 281474976710656 pages",
+		},
+		{
+			name:        "invalid flag",
+			input:       []byte{0x8, 0x0},
+			expectedErr: "invalid byte for limits: 0x8 not in (0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07)",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			min, max, _, _, err := decodeLimitsType(bytes.NewReader(tc.input))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.expectedMin, min)
+				require.Equal(t, tc.expectedMax, max)
+			}
 		})
 	}
 }
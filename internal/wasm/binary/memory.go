@@ -15,14 +15,18 @@ import (
 func decodeMemory(
 	r *bytes.Reader,
 	enabledFeatures api.CoreFeatures,
-	memorySizer func(minPages uint32, maxPages *uint32) (min, capacity, max uint32),
+	memorySizer memorySizer,
 	memoryLimitPages uint32,
 ) (*wasm.Memory, error) {
-	min, maxP, shared, err := decodeLimitsType(r)
+	min, maxP, shared, is64, err := decodeLimitsType(r)
 	if err != nil {
 		return nil, err
 	}
 
+	if is64 && !enabledFeatures.IsEnabled(experimental.CoreFeaturesMemory64) {
+		return nil, fmt.Errorf("64-bit memory requested but memory64 feature not enabled")
+	}
+
 	if shared {
 		if !enabledFeatures.IsEnabled(experimental.CoreFeaturesThreads) {
 			return nil, fmt.Errorf("shared memory requested but threads feature not enabled")
@@ -35,8 +39,8 @@ func decodeMemory(
 		}
 	}
 
-	min, capacity, max := memorySizer(min, maxP)
-	mem := &wasm.Memory{Min: min, Cap: capacity, Max: max, IsMaxEncoded: maxP != nil, IsShared: shared}
+	min, capacity, max := memorySizer(min, maxP, is64)
+	mem := &wasm.Memory{Min: min, Cap: capacity, Max: max, IsMaxEncoded: maxP != nil, IsShared: shared, Is64: is64}
 
-	return mem, mem.Validate(memoryLimitPages)
+	return mem, mem.Validate(limitMemoryPages(memoryLimitPages, is64))
 }
@@ -3,6 +3,7 @@ package binary
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
@@ -15,6 +16,7 @@ import (
 func Test_newMemorySizer(t *testing.T) {
 	zero := uint32(0)
 	ten := uint32(10)
+	largest := uint32(math.MaxUint32)
 	defaultLimit := wasm.MemoryLimitPages
 
 	tests := []struct {
@@ -23,6 +25,7 @@ func Test_newMemorySizer(t *testing.T) {
 		limit                                      uint32
 		min                                        uint32
 		max                                        *uint32
+		is64                                       bool
 		expectedMin, expectedCapacity, expectedMax uint32
 	}{
 		{
@@ -105,13 +108,40 @@ func Test_newMemorySizer(t *testing.T) {
 			expectedCapacity: 0,
 			expectedMax:      5,
 		},
+		{
+			name:             "limit over 4GiB",
+			limit:            defaultLimit * 2,
+			min:              0,
+			expectedMin:      0,
+			expectedCapacity: 0,
+			expectedMax:      defaultLimit,
+		},
+		{
+			name:             "limit over 4GiB, 64-bit",
+			limit:            defaultLimit * 2,
+			min:              0,
+			is64:             true,
+			expectedMin:      0,
+			expectedCapacity: 0,
+			expectedMax:      defaultLimit * 2,
+		},
+		{
+			name:             "max > memoryLimitPages, 64-bit",
+			limit:            defaultLimit,
+			min:              0,
+			max:              &largest,
+			is64:             true,
+			expectedMin:      0,
+			expectedCapacity: 0,
+			expectedMax:      defaultLimit,
+		},
 	}
 
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
 			sizer := newMemorySizer(tc.limit, tc.memoryCapacityFromMax)
-			min, capacity, max := sizer(tc.min, tc.max)
+			min, capacity, max := sizer(tc.min, tc.max, tc.is64)
 			require.Equal(t, tc.expectedMin, min)
 			require.Equal(t, tc.expectedCapacity, capacity)
 			require.Equal(t, tc.expectedMax, max)
@@ -170,6 +200,11 @@ func TestMemoryType(t *testing.T) {
 			input:    &wasm.Memory{Max: 1, IsMaxEncoded: true, IsShared: true},
 			expected: []byte{0x3, 0, 1},
 		},
+		{
+			name:     "min 0, max 1, 64-bit",
+			input:    &wasm.Memory{Max: 1, IsMaxEncoded: true, Is64: true},
+			expected: []byte{0x5, 0, 1},
+		},
 	}
 
 	for _, tt := range tests {
@@ -193,6 +228,9 @@ func TestMemoryType(t *testing.T) {
 			if tc.input.IsShared {
 				features = features.SetEnabled(experimental.CoreFeaturesThreads, true)
 			}
+			if tc.input.Is64 {
+				features = features.SetEnabled(experimental.CoreFeaturesMemory64, true)
+			}
 			binary, err := decodeMemory(bytes.NewReader(b), features, newMemorySizer(tmax, false), tmax)
 			require.NoError(t, err)
 			require.Equal(t, binary, expectedDecoded)
@@ -204,10 +242,12 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 	max := wasm.MemoryLimitPages
 
 	tests := []struct {
-		name           string
-		input          []byte
-		threadsEnabled bool
-		expectedErr    string
+		name             string
+		input            []byte
+		threadsEnabled   bool
+		memory64Enabled  bool
+		memoryLimitPages uint32
+		expectedErr      string
 	}{
 		{
 			name:        "max < min",
@@ -217,12 +257,12 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 		{
 			name:        "min > limit",
 			input:       []byte{0x0, 0xff, 0xff, 0xff, 0xff, 0xf},
-			expectedErr: "min 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "min 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "max > limit",
 			input:       []byte{0x1, 0, 0xff, 0xff, 0xff, 0xff, 0xf},
-			expectedErr: "max 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "max 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "shared but no threads",
@@ -235,6 +275,24 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 			threadsEnabled: true,
 			expectedErr:    "shared memory requires a maximum size to be specified",
 		},
+		{
+			name:        "64-bit but no memory64",
+			input:       []byte{0x4, 0},
+			expectedErr: "64-bit memory requested but memory64 feature not enabled",
+		},
+		{
+			name:             "min > limit over 4GiB",
+			input:            []byte{0x0, 0x81, 0x80, 0x4},
+			memoryLimitPages: max * 2,
+			expectedErr:      "min 65537 pages (4 Gi) over limit of 65536 pages (4 Gi)",
+		},
+		{
+			name:             "min > limit, 64-bit",
+			input:            []byte{0x4, 0x81, 0x80, 0x8},
+			memory64Enabled:  true,
+			memoryLimitPages: max * 2,
+			expectedErr:      "min 131073 pages (8 Gi) over limit of 131072 pages (8 Gi)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -248,7 +306,12 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 				// Allow test to work if threads is ever added to default features by explicitly removing threads features
 				features = features.SetEnabled(experimental.CoreFeaturesThreads, false)
 			}
-			_, err := decodeMemory(bytes.NewReader(tc.input), features, newMemorySizer(max, false), max)
+			features = features.SetEnabled(experimental.CoreFeaturesMemory64, tc.memory64Enabled)
+			limit := max
+			if tc.memoryLimitPages != 0 {
+				limit = tc.memoryLimitPages
+			}
+			_, err := decodeMemory(bytes.NewReader(tc.input), features, newMemorySizer(limit, false), limit)
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
@@ -6,23 +6,157 @@ import (
 	"io"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
-func decodeTypeSection(enabledFeatures api.CoreFeatures, r *bytes.Reader) ([]wasm.FunctionType, error) {
+// decodeTypeSection returns the types in the type section. The returned wasm.CompositeType is nil unless any type
+// is defined with a syntax of experimental.CoreFeaturesGC. See wasm.Module CompositeTypes.
+func decodeTypeSection(enabledFeatures api.CoreFeatures, r *bytes.Reader) ([]wasm.FunctionType, []wasm.CompositeType, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
-		return nil, fmt.Errorf("get size of vector: %w", err)
+		return nil, nil, fmt.Errorf("get size of vector: %w", err)
 	}
 
-	result := make([]wasm.FunctionType, vs)
+	result := make([]wasm.FunctionType, 0, vs)
+	composites := make([]wasm.CompositeType, 0, vs)
+	var fixups []typeFixup
 	for i := uint32(0); i < vs; i++ {
-		if err = decodeFunctionType(enabledFeatures, r, &result[i]); err != nil {
-			return nil, fmt.Errorf("read %d-th type: %v", i, err)
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %d-th type: read leading byte: %w", i, err)
+		}
+
+		recGroup, recCount := wasm.Index(len(result)), uint32(1)
+		if b == wasm.TypePrefixRec {
+			if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+				return nil, nil, fmt.Errorf("read %d-th type: recursion group invalid as %v", i, err)
+			}
+			if recCount, _, err = leb128.DecodeUint32(r); err != nil {
+				return nil, nil, fmt.Errorf("read %d-th type: get size of recursion group: %v", i, err)
+			}
+		} else if err = r.UnreadByte(); err != nil {
+			return nil, nil, err
+		}
+
+		for j := uint32(0); j < recCount; j++ {
+			result = append(result, wasm.FunctionType{})
+			composites = append(composites, wasm.CompositeType{RecGroup: recGroup, Final: true})
+			idx := len(result) - 1
+			if err = decodeSubType(enabledFeatures, r, &result[idx], &composites[idx], &fixups); err != nil {
+				return nil, nil, fmt.Errorf("read %d-th type: %v", idx, err)
+			}
 		}
 	}
-	return result, nil
+
+	// Resolve the references to concrete types now that all the types are known.
+	m := &wasm.Module{TypeSection: result, CompositeTypes: composites}
+	for _, f := range fixups {
+		if *f.vt, err = m.HeapTypeValueType(f.ht); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	gc := false
+	for i := range result {
+		// cache the key for the function type
+		_ = result[i].String()
+		ct := &composites[i]
+		if ct.Kind != wasm.CompositeTypeKindFunc || ct.SuperType != nil || !ct.Final || ct.RecGroup != wasm.Index(i) {
+			gc = true
+		}
+	}
+	if !gc {
+		composites = nil
+	}
+	return result, composites, nil
+}
+
+// decodeSubType decodes a type definition, optionally prefixed with its super type.
+func decodeSubType(enabledFeatures api.CoreFeatures, r *bytes.Reader, ft *wasm.FunctionType, ct *wasm.CompositeType, fixups *[]typeFixup) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("read leading byte: %w", err)
+	}
+
+	if b == wasm.TypePrefixSub || b == wasm.TypePrefixSubFinal {
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return fmt.Errorf("sub type invalid as %v", err)
+		}
+		ct.Final = b == wasm.TypePrefixSubFinal
+		n, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return fmt.Errorf("get size of super types: %w", err)
+		} else if n > 1 {
+			return fmt.Errorf("at most one super type allowed, but read %d", n)
+		} else if n == 1 {
+			super, _, err := leb128.DecodeUint32(r)
+			if err != nil {
+				return fmt.Errorf("read super type: %w", err)
+			}
+			ct.SuperType = &super
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return fmt.Errorf("read leading byte: %w", err)
+		}
+	}
+
+	ct.Kind = b
+	switch b {
+	case wasm.CompositeTypeKindFunc:
+		return decodeFunctionSignature(enabledFeatures, r, ft, fixups)
+	case wasm.CompositeTypeKindStruct, wasm.CompositeTypeKindArray:
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return fmt.Errorf("%w: %#x != 0x60", ErrInvalidByte, b)
+		}
+		n := uint32(1)
+		if b == wasm.CompositeTypeKindStruct {
+			if n, _, err = leb128.DecodeUint32(r); err != nil {
+				return fmt.Errorf("get size of fields: %w", err)
+			}
+		}
+		ct.Fields = make([]wasm.FieldType, n)
+		for i := range ct.Fields {
+			if err = decodeFieldType(enabledFeatures, r, &ct.Fields[i], fixups); err != nil {
+				return fmt.Errorf("read field[%d]: %w", i, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %#x != 0x60", ErrInvalidByte, b)
+	}
+}
+
+// decodeFieldType decodes the type of a struct field or an array element.
+func decodeFieldType(enabledFeatures api.CoreFeatures, r *bytes.Reader, ret *wasm.FieldType, fixups *[]typeFixup) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("read storage type: %w", err)
+	}
+	if b == wasm.StorageTypeI8 || b == wasm.StorageTypeI16 {
+		ret.StorageType = b
+	} else {
+		if err = r.UnreadByte(); err != nil {
+			return err
+		}
+		if ret.StorageType, ret.HeapType, err = wasm.DecodeValueType(r, enabledFeatures); err != nil {
+			return err
+		}
+		if ret.StorageType == 0 {
+			*fixups = append(*fixups, typeFixup{vt: &ret.StorageType, ht: ret.HeapType})
+		}
+	}
+
+	switch mut, err := r.ReadByte(); {
+	case err != nil:
+		return fmt.Errorf("read mutablity: %w", err)
+	case mut == 0x01:
+		ret.Mutable = true
+	case mut != 0x00:
+		return fmt.Errorf("%w for mutability: %#x != 0x00 or 0x01", ErrInvalidByte, mut)
+	}
+	return nil
 }
 
 // decodeImportSection decodes the decoded import segments plus the count per wasm.ExternType.
@@ -30,10 +164,11 @@ func decodeImportSection(
 	r *bytes.Reader,
 	memorySizer memorySizer,
 	memoryLimitPages uint32,
+	m *wasm.Module,
 	enabledFeatures api.CoreFeatures,
 ) (result []wasm.Import,
 	perModule map[string][]*wasm.Import,
-	funcCount, globalCount, memoryCount, tableCount wasm.Index, err error,
+	funcCount, globalCount, memoryCount, tableCount, tagCount wasm.Index, err error,
 ) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
@@ -45,7 +180,7 @@ func decodeImportSection(
 	result = make([]wasm.Import, vs)
 	for i := uint32(0); i < vs; i++ {
 		imp := &result[i]
-		if err = decodeImport(r, i, memorySizer, memoryLimitPages, enabledFeatures, imp); err != nil {
+		if err = decodeImport(r, i, memorySizer, memoryLimitPages, m, enabledFeatures, imp); err != nil {
 			return
 		}
 		switch imp.Type {
@@ -61,6 +196,9 @@ func decodeImportSection(
 		case wasm.ExternTypeTable:
 			imp.IndexPerType = tableCount
 			tableCount++
+		case wasm.ExternTypeTag:
+			imp.IndexPerType = tagCount
+			tagCount++
 		}
 		perModule[imp.Module] = append(perModule[imp.Module], imp)
 	}
@@ -82,7 +220,7 @@ func decodeFunctionSection(r *bytes.Reader) ([]uint32, error) {
 	return result, err
 }
 
-func decodeTableSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]wasm.Table, error) {
+func decodeTableSection(r *bytes.Reader, m *wasm.Module, enabledFeatures api.CoreFeatures) ([]wasm.Table, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return nil, fmt.Errorf("error reading size")
@@ -95,7 +233,7 @@ func decodeTableSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]wa
 
 	ret := make([]wasm.Table, vs)
 	for i := range ret {
-		err = decodeTable(r, enabledFeatures, &ret[i])
+		err = decodeTable(r, m, enabledFeatures, &ret[i])
 		if err != nil {
 			return nil, err
 		}
@@ -108,22 +246,30 @@ func decodeMemorySection(
 	enabledFeatures api.CoreFeatures,
 	memorySizer memorySizer,
 	memoryLimitPages uint32,
-) (*wasm.Memory, error) {
+) ([]wasm.Memory, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return nil, fmt.Errorf("error reading size")
 	}
-	if vs > 1 {
+	if vs > 1 && !enabledFeatures.IsEnabled(experimental.CoreFeaturesMultiMemory) {
 		return nil, fmt.Errorf("at most one memory allowed in module, but read %d", vs)
 	} else if vs == 0 {
 		// memory count can be zero.
 		return nil, nil
 	}
 
-	return decodeMemory(r, enabledFeatures, memorySizer, memoryLimitPages)
+	ret := make([]wasm.Memory, vs)
+	for i := range ret {
+		mem, err := decodeMemory(r, enabledFeatures, memorySizer, memoryLimitPages)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = *mem
+	}
+	return ret, nil
 }
 
-func decodeGlobalSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]wasm.Global, error) {
+func decodeGlobalSection(r *bytes.Reader, m *wasm.Module, enabledFeatures api.CoreFeatures) ([]wasm.Global, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return nil, fmt.Errorf("get size of vector: %w", err)
@@ -131,13 +277,28 @@ func decodeGlobalSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]w
 
 	result := make([]wasm.Global, vs)
 	for i := uint32(0); i < vs; i++ {
-		if err = decodeGlobal(r, enabledFeatures, &result[i]); err != nil {
+		if err = decodeGlobal(r, m, enabledFeatures, &result[i]); err != nil {
 			return nil, fmt.Errorf("global[%d]: %w", i, err)
 		}
 	}
 	return result, nil
 }
 
+func decodeTagSection(r *bytes.Reader) ([]wasm.Index, error) {
+	vs, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return nil, fmt.Errorf("get size of vector: %w", err)
+	}
+
+	result := make([]wasm.Index, vs)
+	for i := uint32(0); i < vs; i++ {
+		if result[i], err = decodeTagType(r); err != nil {
+			return nil, fmt.Errorf("tag[%d]: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
 func decodeExportSection(r *bytes.Reader) ([]wasm.Export, map[string]*wasm.Export, error) {
 	vs, _, sizeErr := leb128.DecodeUint32(r)
 	if sizeErr != nil {
@@ -169,7 +330,7 @@ func decodeStartSection(r *bytes.Reader) (*wasm.Index, error) {
 	return &vs, nil
 }
 
-func decodeElementSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]wasm.ElementSegment, error) {
+func decodeElementSection(r *bytes.Reader, m *wasm.Module, enabledFeatures api.CoreFeatures) ([]wasm.ElementSegment, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return nil, fmt.Errorf("get size of vector: %w", err)
@@ -177,14 +338,14 @@ func decodeElementSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]
 
 	result := make([]wasm.ElementSegment, vs)
 	for i := uint32(0); i < vs; i++ {
-		if err = decodeElementSegment(r, enabledFeatures, &result[i]); err != nil {
+		if err = decodeElementSegment(r, m, enabledFeatures, &result[i]); err != nil {
 			return nil, fmt.Errorf("read element: %w", err)
 		}
 	}
 	return result, nil
 }
 
-func decodeCodeSection(r *bytes.Reader) ([]wasm.Code, error) {
+func decodeCodeSection(r *bytes.Reader, m *wasm.Module, enabledFeatures api.CoreFeatures) ([]wasm.Code, error) {
 	codeSectionStart := uint64(r.Len())
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
@@ -193,7 +354,7 @@ func decodeCodeSection(r *bytes.Reader) ([]wasm.Code, error) {
 
 	result := make([]wasm.Code, vs)
 	for i := uint32(0); i < vs; i++ {
-		err = decodeCode(r, codeSectionStart, &result[i])
+		err = decodeCode(r, codeSectionStart, m, enabledFeatures, &result[i])
 		if err != nil {
 			return nil, fmt.Errorf("read %d-th code segment: %v", i, err)
 		}
@@ -5,11 +5,101 @@ import (
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
+func TestDecodeTypeSection_GC(t *testing.T) {
+	features := api.CoreFeaturesV2 | experimental.CoreFeaturesFunctionReferences | experimental.CoreFeaturesGC
+	super := wasm.Index(1)
+	// (rec (type (struct (field (mut i8)) (field (ref null 1)))) (type (sub (struct (field i32)))))
+	// (type (sub final 1 (struct (field i32) (field (mut v128)))))
+	// (type (array (mut i16)))
+	// (type (func (param anyref)))
+	types := []wasm.FunctionType{{}, {}, {}, {}, {Params: []wasm.ValueType{wasm.ValueTypeAnyref}}}
+	composites := []wasm.CompositeType{
+		{
+			Kind: wasm.CompositeTypeKindStruct,
+			Fields: []wasm.FieldType{
+				{StorageType: wasm.StorageTypeI8, Mutable: true},
+				{StorageType: wasm.ValueTypeStructref, HeapType: 1},
+			},
+			Final: true,
+		},
+		{Kind: wasm.CompositeTypeKindStruct, Fields: []wasm.FieldType{{StorageType: wasm.ValueTypeI32}}},
+		{
+			Kind: wasm.CompositeTypeKindStruct,
+			Fields: []wasm.FieldType{
+				{StorageType: wasm.ValueTypeI32},
+				{StorageType: wasm.ValueTypeV128, Mutable: true},
+			},
+			SuperType: &super,
+			Final:     true,
+			RecGroup:  2,
+		},
+		{
+			Kind:     wasm.CompositeTypeKindArray,
+			Fields:   []wasm.FieldType{{StorageType: wasm.StorageTypeI16, Mutable: true}},
+			Final:    true,
+			RecGroup: 3,
+		},
+		{Kind: wasm.CompositeTypeKindFunc, Final: true, RecGroup: 4},
+	}
+	bin := binaryencoding.EncodeModule(&wasm.Module{TypeSection: types, CompositeTypes: composites})
+
+	m, err := DecodeModule(bin, features, wasm.MemoryLimitPages, false, false, false)
+	require.NoError(t, err)
+	for i := range types {
+		_ = types[i].String()
+	}
+	require.Equal(t, types, m.TypeSection)
+	require.Equal(t, composites, m.CompositeTypes)
+
+	_, err = DecodeModule(bin, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, false, false)
+	require.EqualError(t, err, `section type: read 0-th type: recursion group invalid as feature "gc" is disabled`)
+}
+
+func TestDecodeTypeSection_GC_Errors(t *testing.T) {
+	features := api.CoreFeaturesV2 | experimental.CoreFeaturesFunctionReferences | experimental.CoreFeaturesGC
+	tests := []struct {
+		name        string
+		input       []byte
+		expectedErr string
+	}{
+		{
+			name:        "unknown composite type",
+			input:       []byte{0x01, 0x5d},
+			expectedErr: "read 0-th type: invalid byte: 0x5d != 0x60",
+		},
+		{
+			name:        "invalid storage type",
+			input:       []byte{0x01, wasm.CompositeTypeKindArray, 0x76, 0x00},
+			expectedErr: "read 0-th type: read field[0]: invalid value type: 118",
+		},
+		{
+			name:        "unknown concrete type",
+			input:       []byte{0x01, wasm.CompositeTypeKindArray, wasm.ValueTypePrefixRefNull, 0x01, 0x00},
+			expectedErr: "unknown type 1",
+		},
+		{
+			name:        "too many super types",
+			input:       []byte{0x01, wasm.TypePrefixSub, 0x02, 0x00, 0x00, wasm.CompositeTypeKindStruct, 0x00},
+			expectedErr: "read 0-th type: at most one super type allowed, but read 2",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := decodeTypeSection(features, bytes.NewReader(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
 func TestTableSection(t *testing.T) {
 	three := uint32(3)
 	tests := []struct {
@@ -45,7 +135,7 @@ func TestTableSection(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			tables, err := decodeTableSection(bytes.NewReader(tc.input), api.CoreFeatureReferenceTypes)
+			tables, err := decodeTableSection(bytes.NewReader(tc.input), &wasm.Module{}, api.CoreFeatureReferenceTypes)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, tables)
 		})
@@ -75,7 +165,7 @@ func TestTableSection_Errors(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := decodeTableSection(bytes.NewReader(tc.input), tc.features)
+			_, err := decodeTableSection(bytes.NewReader(tc.input), &wasm.Module{}, tc.features)
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
@@ -88,7 +178,8 @@ func TestMemorySection(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    []byte
-		expected *wasm.Memory
+		features api.CoreFeatures
+		expected []wasm.Memory
 	}{
 		{
 			name: "min and min with max",
@@ -96,7 +187,21 @@ func TestMemorySection(t *testing.T) {
 				0x01,             // 1 memory
 				0x01, 0x02, 0x03, // (memory 2 3)
 			},
-			expected: &wasm.Memory{Min: 2, Cap: 2, Max: three, IsMaxEncoded: true},
+			features: api.CoreFeaturesV2,
+			expected: []wasm.Memory{{Min: 2, Cap: 2, Max: three, IsMaxEncoded: true}},
+		},
+		{
+			name: "multiple memories",
+			input: []byte{
+				0x02,       // 2 memories
+				0x00, 0x01, // (memory 1)
+				0x01, 0x02, 0x03, // (memory 2 3)
+			},
+			features: api.CoreFeaturesV2 | experimental.CoreFeaturesMultiMemory,
+			expected: []wasm.Memory{
+				{Min: 1, Cap: 1, Max: max},
+				{Min: 2, Cap: 2, Max: three, IsMaxEncoded: true},
+			},
 		},
 	}
 
@@ -104,7 +209,7 @@ func TestMemorySection(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			memories, err := decodeMemorySection(bytes.NewReader(tc.input), api.CoreFeaturesV2, newMemorySizer(max, false), max)
+			memories, err := decodeMemorySection(bytes.NewReader(tc.input), tc.features, newMemorySizer(max, false), max)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, memories)
 		})
@@ -140,6 +245,45 @@ func TestMemorySection_Errors(t *testing.T) {
 	}
 }
 
+func TestDecodeTagSection(t *testing.T) {
+	input := []byte{
+		0x02,       // 2 tags
+		0x00, 0x01, // (tag (type 1))
+		0x00, 0x00, // (tag (type 0))
+	}
+	tags, err := decodeTagSection(bytes.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []wasm.Index{1, 0}, tags)
+}
+
+func TestDecodeTagSection_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []byte
+		expectedErr string
+	}{
+		{
+			name:        "invalid attribute",
+			input:       []byte{0x01, 0x01, 0x00},
+			expectedErr: "tag[0]: invalid byte for tag attribute: 0x1 != 0x00",
+		},
+		{
+			name:        "missing type index",
+			input:       []byte{0x01, 0x00},
+			expectedErr: "tag[0]: read type index: EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeTagSection(bytes.NewReader(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
 func TestDecodeExportSection(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -5,26 +5,49 @@ import (
 	"fmt"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
 // decodeTable returns the wasm.Table decoded with the WebAssembly 1.0 (20191205) Binary Format.
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-table
-func decodeTable(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret *wasm.Table) (err error) {
-	ret.Type, err = r.ReadByte()
+func decodeTable(r *bytes.Reader, m *wasm.Module, enabledFeatures api.CoreFeatures, ret *wasm.Table) (err error) {
+	b, err := r.ReadByte()
 	if err != nil {
 		return fmt.Errorf("read leading byte: %v", err)
 	}
 
-	if ret.Type != wasm.RefTypeFuncref {
+	if b != wasm.RefTypeFuncref {
 		if err = enabledFeatures.RequireEnabled(api.CoreFeatureReferenceTypes); err != nil {
 			return fmt.Errorf("table type funcref is invalid: %w", err)
 		}
 	}
 
-	var shared bool
-	ret.Min, ret.Max, shared, err = decodeLimitsType(r)
+	// A table with an initializer is prefixed with 0x40 0x00.
+	// See https://github.com/WebAssembly/function-references/blob/main/proposals/function-references/Overview.md#tables
+	hasInit := b == 0x40
+	if hasInit {
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesFunctionReferences); err != nil {
+			return fmt.Errorf("table initializer is invalid: %w", err)
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return fmt.Errorf("read table initializer prefix: %v", err)
+		} else if b != 0x00 {
+			return fmt.Errorf("%w for table initializer prefix: %#x != 0x00", ErrInvalidByte, b)
+		}
+	} else if err = r.UnreadByte(); err != nil {
+		return err
+	}
+
+	if ret.Type, err = m.DecodeValueType(r, enabledFeatures); err != nil {
+		return fmt.Errorf("read reference type: %v", err)
+	} else if !wasm.IsReferenceValueType(ret.Type) {
+		return fmt.Errorf("table type must be a reference type but was %s", wasm.ValueTypeName(ret.Type))
+	}
+
+	var shared, is64 bool
+	ret.Min, ret.Max, shared, is64, err = decodeLimitsType(r)
 	if err != nil {
 		return fmt.Errorf("read limits: %v", err)
 	}
@@ -39,5 +62,14 @@ func decodeTable(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret *wasm.Ta
 	if shared {
 		return fmt.Errorf("tables cannot be marked as shared")
 	}
+	if is64 {
+		return fmt.Errorf("tables cannot be 64-bit")
+	}
+	if hasInit {
+		ret.Init = &wasm.ConstantExpression{}
+		if err = decodeConstantExpression(r, enabledFeatures, ret.Init); err != nil {
+			return fmt.Errorf("read table initializer: %v", err)
+		}
+	}
 	return
 }
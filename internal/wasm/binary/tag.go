@@ -0,0 +1,28 @@
+package binary
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// decodeTagType returns the type index of a tag decoded with the exception handling proposal's Binary Format.
+//
+// See https://webassembly.github.io/exception-handling/core/binary/types.html#tag-types
+func decodeTagType(r *bytes.Reader) (wasm.Index, error) {
+	attr, err := r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("read attribute: %w", err)
+	}
+	if attr != 0x00 {
+		return 0, fmt.Errorf("%w for tag attribute: %#x != 0x00", ErrInvalidByte, attr)
+	}
+
+	typeIdx, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return 0, fmt.Errorf("read type index: %w", err)
+	}
+	return typeIdx, nil
+}
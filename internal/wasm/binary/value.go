@@ -7,28 +7,38 @@ import (
 	"unicode/utf8"
 	"unsafe"
 
+	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
-func decodeValueTypes(r *bytes.Reader, num uint32) ([]wasm.ValueType, error) {
+// typeFixup is a value type in the type section which refers to a concrete type. It is resolved once the whole section
+// is decoded, as a type can refer to the ones defined after it.
+type typeFixup struct {
+	vt *wasm.ValueType
+	ht wasm.HeapType
+}
+
+// decodeValueTypes decodes num value types. References to concrete types are appended to fixups, or are invalid if
+// fixups is nil.
+func decodeValueTypes(r *bytes.Reader, num uint32, enabledFeatures api.CoreFeatures, fixups *[]typeFixup) ([]wasm.ValueType, error) {
 	if num == 0 {
 		return nil, nil
 	}
 
 	ret := make([]wasm.ValueType, num)
-	_, err := io.ReadFull(r, ret)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, v := range ret {
-		switch v {
-		case wasm.ValueTypeI32, wasm.ValueTypeF32, wasm.ValueTypeI64, wasm.ValueTypeF64,
-			wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeV128:
-		default:
-			return nil, fmt.Errorf("invalid value type: %d", v)
+	for i := range ret {
+		vt, ht, err := wasm.DecodeValueType(r, enabledFeatures)
+		if err != nil {
+			return nil, err
+		}
+		if vt == 0 {
+			if fixups == nil {
+				return nil, fmt.Errorf("unknown type %d", ht)
+			}
+			*fixups = append(*fixups, typeFixup{vt: &ret[i], ht: ht})
 		}
+		ret[i] = vt
 	}
 	return ret, nil
 }
@@ -2,6 +2,7 @@ package wasm
 
 import (
 	"context"
+	"io"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
@@ -34,6 +35,19 @@ type Engine interface {
 	NewModuleEngine(module *Module, instance *ModuleInstance) (ModuleEngine, error)
 }
 
+// ArtifactEngine is an Engine which can export the native code compiled for a module, and import it instead of compiling
+// the module again, e.g. to compile modules ahead of time.
+type ArtifactEngine interface {
+	Engine
+
+	// ExportCompiledModule writes the native code compiled for the module by CompileModule into w.
+	ExportCompiledModule(module *Module, w io.Writer) error
+
+	// ImportCompiledModule reads the native code written by ExportCompiledModule from r, and uses it for the module
+	// instead of compiling it. The other parameters are the same as CompileModule.
+	ImportCompiledModule(ctx context.Context, module *Module, listeners []experimental.FunctionListener, ensureTermination bool, r io.Reader) error
+}
+
 // ModuleEngine implements function calls for a given module.
 type ModuleEngine interface {
 	// DoneInstantiation is called at the end of the instantiation of the module.
@@ -50,11 +64,18 @@ type ModuleEngine interface {
 	ResolveImportedFunction(index, descFunc, indexInImportedModule Index, importedModuleEngine ModuleEngine)
 
 	// ResolveImportedMemory is called when this module imports a memory from another module.
-	ResolveImportedMemory(importedModuleEngine ModuleEngine)
+	// 	- `index` is the memory Index of this imported memory.
+	// 	- `indexInImportedModule` is the memory Index of the imported memory in the imported module.
+	//	- `importedModuleEngine` is the ModuleEngine for the imported ModuleInstance.
+	ResolveImportedMemory(index, indexInImportedModule Index, importedModuleEngine ModuleEngine)
 
 	// LookupFunction returns the FunctionModule and the Index of the function in the returned ModuleInstance at the given offset in the table.
 	LookupFunction(t *TableInstance, typeId FunctionTypeID, tableOffset Index) (*ModuleInstance, Index)
 
+	// LookupFunctionReference returns the ModuleInstance and the Index of the function in it of the given non-null
+	// Reference, which was returned by FunctionInstanceReference of any ModuleEngine of the same Engine.
+	LookupFunctionReference(ref Reference) (*ModuleInstance, Index)
+
 	// GetGlobalValue returns the value of the global variable at the given Index.
 	// Only called when OwnsGlobals() returns true, and must not be called for imported globals
 	GetGlobalValue(idx Index) (lo, hi uint64)
@@ -71,6 +92,29 @@ type ModuleEngine interface {
 	// the initialization via ElementSegment.
 	FunctionInstanceReference(funcIndex Index) Reference
 
+	// FunctionReference returns the Reference to the function at the given Index in the ModuleInstance, which may be
+	// another module, for the tables of this ModuleEngine, e.g. for api.Table.SetFunction. An error is returned if the
+	// function can't be referenced by them, e.g. because it runs on another tier.
+	FunctionReference(m *ModuleInstance, funcIndex Index) (Reference, error)
+
 	// MemoryGrown notifies the engine that the memory has grown.
 	MemoryGrown()
 }
+
+// ModuleFunction is implemented by the api.Function returned by ModuleEngine.NewFunction, so that the function can
+// be stored in a TableInstance.
+type ModuleFunction interface {
+	// ModuleFunction returns the ModuleInstance defining this function and its Index in it.
+	ModuleFunction() (*ModuleInstance, Index)
+}
+
+// TieredModuleEngine is implemented by a ModuleEngine which runs its module on the ModuleEngine of one of several
+// Engines, e.g. on the interpreter until the compiler is done with the module.
+//
+// Engines use this to find their own ModuleEngine for a ModuleInstance whose Engine is a TieredModuleEngine.
+type TieredModuleEngine interface {
+	ModuleEngine
+
+	// ModuleEngines returns the ModuleEngines this delegates to, which are nil until they are created.
+	ModuleEngines() []ModuleEngine
+}
@@ -0,0 +1,124 @@
+package wasm
+
+import (
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+// TagInstance represents a tag instance in a store.
+// Tags are compared by identity, so each instantiation of a module creates distinct instances for the tags it defines.
+//
+// See https://webassembly.github.io/exception-handling/core/exec/runtime.html#tag-instances
+//
+// This implements experimental.Tag.
+type TagInstance struct {
+	// Type is the type of the tag. The results are always empty.
+	Type *FunctionType
+}
+
+// ParamTypes implements experimental.Tag.
+func (t *TagInstance) ParamTypes() []api.ValueType {
+	return t.Type.Params
+}
+
+// buildTags creates the tag instances defined in the module.
+func (m *ModuleInstance) buildTags(module *Module) {
+	for i, typeIdx := range module.TagSection {
+		m.Tags[int(module.ImportTagCount)+i] = &TagInstance{Type: &module.TypeSection[typeIdx]}
+	}
+}
+
+// ExportedTag returns the tag exported under the given name, or nil if it isn't exported.
+//
+// This is used by experimental.ExportedTag.
+func (m *ModuleInstance) ExportedTag(name string) experimental.Tag {
+	exp, err := m.getExport(name, ExternTypeTag)
+	if err != nil {
+		return nil
+	}
+	return m.Tags[exp.Index]
+}
+
+// MaximumExceptionRefs is the maximum number of distinct exceptions which can be referenced by exnref values during
+// a function call. Ref panics with wasmruntime.ErrRuntimeTooManyExceptionReferences once it's reached, instead of
+// letting the index in the lower 32 bits of exnref values wrap around.
+const MaximumExceptionRefs = 1 << 20
+
+// exceptionRefsGeneration is incremented whenever ExceptionRefs is reused for a new function call, so that
+// exnref values created in a previous call do not resolve to unrelated exceptions.
+var exceptionRefsGeneration atomic.Uint32
+
+// ExceptionRefs holds the exceptions referenced by the exnref values created during a function call.
+//
+// An exnref value is encoded as the generation of the ExceptionRefs in the upper 32 bits, and one-based index of the
+// exception in the lower 32 bits. Zero is the null reference.
+type ExceptionRefs struct {
+	generation uint64
+	exceptions []*experimental.Exception
+	// indexes maps the exceptions to their one-based index in exceptions, so that catching the same exception
+	// again, for example after throw_ref, doesn't consume another entry.
+	indexes map[*experimental.Exception]uint32
+}
+
+// Ref returns the exnref value referencing the given exception.
+//
+// The same exnref value is returned for the same exception until Reset. This panics with
+// wasmruntime.ErrRuntimeTooManyExceptionReferences if MaximumExceptionRefs distinct exceptions are already referenced.
+func (r *ExceptionRefs) Ref(exc *experimental.Exception) uint64 {
+	if r.generation == 0 {
+		var g uint32
+		for g == 0 { // Skip zero on overflow, as it means no generation is assigned.
+			g = exceptionRefsGeneration.Add(1)
+		}
+		r.generation = uint64(g) << 32
+	}
+	if i, ok := r.indexes[exc]; ok {
+		return r.generation | uint64(i)
+	}
+	if len(r.exceptions) >= MaximumExceptionRefs {
+		panic(wasmruntime.ErrRuntimeTooManyExceptionReferences)
+	}
+	if r.indexes == nil {
+		r.indexes = make(map[*experimental.Exception]uint32)
+	}
+	r.exceptions = append(r.exceptions, exc)
+	i := uint32(len(r.exceptions))
+	r.indexes[exc] = i
+	return r.generation | uint64(i)
+}
+
+// Get returns the exception referenced by the exnref value, or nil if it's either null or was not created by Ref
+// since the last Reset.
+func (r *ExceptionRefs) Get(ref uint64) *experimental.Exception {
+	if r.generation == 0 || ref&^0xffffffff != r.generation {
+		return nil
+	}
+	i := uint32(ref)
+	if i == 0 || int(i) > len(r.exceptions) {
+		return nil
+	}
+	return r.exceptions[i-1]
+}
+
+// Reset invalidates all the exnref values created by Ref.
+func (r *ExceptionRefs) Reset() {
+	if r.generation == 0 {
+		return
+	}
+	for i := range r.exceptions {
+		r.exceptions[i] = nil
+	}
+	r.exceptions = r.exceptions[:0]
+	for exc := range r.indexes {
+		delete(r.indexes, exc)
+	}
+	r.generation = 0
+}
+
+// Exceptions returns the exceptions referenced by the exnref values created since the last Reset.
+func (r *ExceptionRefs) Exceptions() []*experimental.Exception {
+	return r.exceptions
+}
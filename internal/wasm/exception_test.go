@@ -0,0 +1,54 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+func TestExceptionRefs(t *testing.T) {
+	var r ExceptionRefs
+	exc1, exc2 := &experimental.Exception{}, &experimental.Exception{}
+
+	ref1 := r.Ref(exc1)
+	ref2 := r.Ref(exc2)
+	require.NotEqual(t, ref1, ref2)
+	require.Equal(t, exc1, r.Get(ref1))
+	require.Equal(t, exc2, r.Get(ref2))
+	require.Nil(t, r.Get(0))
+
+	// The same exception is referenced by the same value, e.g. when caught again after throw_ref.
+	require.Equal(t, ref1, r.Ref(exc1))
+	require.Equal(t, 2, len(r.Exceptions()))
+
+	r.Reset()
+	require.Nil(t, r.Get(ref1))
+	require.Nil(t, r.Get(ref2))
+	require.Equal(t, 0, len(r.Exceptions()))
+
+	// References created after Reset don't resolve the ones created before.
+	ref3 := r.Ref(exc2)
+	require.NotEqual(t, ref1, ref3)
+	require.Nil(t, r.Get(ref1))
+	require.Equal(t, exc2, r.Get(ref3))
+}
+
+func TestExceptionRefs_Ref_tooMany(t *testing.T) {
+	var r ExceptionRefs
+	for i := 0; i < MaximumExceptionRefs; i++ {
+		r.Ref(&experimental.Exception{})
+	}
+	// Exceptions already referenced can still be referenced.
+	first := r.Exceptions()[0]
+	require.Equal(t, first, r.Get(r.Ref(first)))
+
+	err := require.CapturePanic(func() { r.Ref(&experimental.Exception{}) })
+	require.Equal(t, wasmruntime.ErrRuntimeTooManyExceptionReferences, err)
+
+	// Reset makes room for new references.
+	r.Reset()
+	exc := &experimental.Exception{}
+	require.Equal(t, exc, r.Get(r.Ref(exc)))
+}
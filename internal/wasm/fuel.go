@@ -0,0 +1,63 @@
+package wasm
+
+import "github.com/tetratelabs/wazero/experimental"
+
+// IsBlockBoundary returns true if the fuel is charged right before the instruction at the beginning of body, which ends
+// the block of the instructions executed all together. See FuelCost.
+func IsBlockBoundary(body []byte) bool {
+	_, charge := FuelCost(&noFuelCosts, body)
+	return charge
+}
+
+// noFuelCosts is used by IsBlockBoundary, where only the boundaries matter.
+var noFuelCosts experimental.FuelCosts
+
+// FuelCost returns the fuel consumed by the instruction at the beginning of body, according to costs, and whether the
+// engines must charge the fuel consumed so far right before executing it.
+//
+// The fuel is charged before every instruction which can transfer the control somewhere other than the next
+// instruction, or which can be reached from somewhere other than the previous instruction. As a result, the
+// instructions between two charges are executed all together unless one of them traps, so charging their costs at
+// once is deterministic. Calls are also charged before, so that host functions observe the up-to-date fuel.
+func FuelCost(costs *experimental.FuelCosts, body []byte) (cost uint32, charge bool) {
+	switch op := body[0]; op {
+	case OpcodeLoop, OpcodeIf, OpcodeElse, OpcodeEnd, OpcodeBr, OpcodeBrIf, OpcodeBrTable, OpcodeReturn,
+		OpcodeUnreachable, OpcodeThrow, OpcodeThrowRef, OpcodeBrOnNull, OpcodeBrOnNonNull:
+		return costs.Control, true
+	case OpcodeNop, OpcodeBlock, OpcodeTryTable, OpcodeDrop, OpcodeSelect, OpcodeTypedSelect:
+		return costs.Control, false
+	case OpcodeCall, OpcodeCallIndirect, OpcodeTailCallReturnCall, OpcodeTailCallReturnCallIndirect,
+		OpcodeCallRef, OpcodeReturnCallRef:
+		return costs.Call, true
+	case OpcodeLocalGet, OpcodeLocalSet, OpcodeLocalTee, OpcodeGlobalGet, OpcodeGlobalSet:
+		return costs.Variable, false
+	case OpcodeTableGet, OpcodeTableSet, OpcodeRefNull, OpcodeRefIsNull, OpcodeRefFunc, OpcodeRefEq, OpcodeRefAsNonNull:
+		return costs.Reference, false
+	case OpcodeGCPrefix:
+		switch OpcodeGC(body[1]) {
+		case OpcodeGCBrOnCast, OpcodeGCBrOnCastFail:
+			return costs.Control, true
+		default:
+			return costs.Reference, false
+		}
+	case OpcodeMiscPrefix:
+		switch OpcodeMisc(body[1]) {
+		case OpcodeMiscMemoryInit, OpcodeMiscDataDrop, OpcodeMiscMemoryCopy, OpcodeMiscMemoryFill:
+			return costs.Memory, false
+		case OpcodeMiscTableInit, OpcodeMiscElemDrop, OpcodeMiscTableCopy, OpcodeMiscTableGrow, OpcodeMiscTableSize,
+			OpcodeMiscTableFill:
+			return costs.Reference, false
+		default: // Saturating truncations.
+			return costs.Numeric, false
+		}
+	case OpcodeVecPrefix:
+		return costs.Vector, false
+	case OpcodeAtomicPrefix:
+		return costs.Memory, false
+	default:
+		if op >= OpcodeI32Load && op <= OpcodeMemoryGrow {
+			return costs.Memory, false
+		}
+		return costs.Numeric, false
+	}
+}
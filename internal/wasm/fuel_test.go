@@ -0,0 +1,51 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestFuelCost(t *testing.T) {
+	costs := &experimental.FuelCosts{Control: 1, Call: 2, Variable: 3, Memory: 4, Reference: 5, Numeric: 6, Vector: 7}
+	tests := []struct {
+		name           string
+		body           []byte
+		expectedCost   uint32
+		expectedCharge bool
+	}{
+		{name: "loop", body: []byte{OpcodeLoop, 0x40}, expectedCost: 1, expectedCharge: true},
+		{name: "br_if", body: []byte{OpcodeBrIf, 0}, expectedCost: 1, expectedCharge: true},
+		{name: "end", body: []byte{OpcodeEnd}, expectedCost: 1, expectedCharge: true},
+		{name: "block", body: []byte{OpcodeBlock, 0x40}, expectedCost: 1},
+		{name: "drop", body: []byte{OpcodeDrop}, expectedCost: 1},
+		{name: "br_on_cast", body: []byte{OpcodeGCPrefix, byte(OpcodeGCBrOnCast)}, expectedCost: 1, expectedCharge: true},
+		{name: "call", body: []byte{OpcodeCall, 0}, expectedCost: 2, expectedCharge: true},
+		{name: "return_call_indirect", body: []byte{OpcodeTailCallReturnCallIndirect, 0, 0}, expectedCost: 2, expectedCharge: true},
+		{name: "local.get", body: []byte{OpcodeLocalGet, 0}, expectedCost: 3},
+		{name: "global.set", body: []byte{OpcodeGlobalSet, 0}, expectedCost: 3},
+		{name: "i32.load", body: []byte{OpcodeI32Load, 0, 0}, expectedCost: 4},
+		{name: "memory.grow", body: []byte{OpcodeMemoryGrow, 0}, expectedCost: 4},
+		{name: "memory.copy", body: []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0}, expectedCost: 4},
+		{name: "i32.atomic.load", body: []byte{OpcodeAtomicPrefix, OpcodeAtomicI32Load, 0, 0}, expectedCost: 4},
+		{name: "table.get", body: []byte{OpcodeTableGet, 0}, expectedCost: 5},
+		{name: "table.fill", body: []byte{OpcodeMiscPrefix, OpcodeMiscTableFill, 0}, expectedCost: 5},
+		{name: "ref.func", body: []byte{OpcodeRefFunc, 0}, expectedCost: 5},
+		{name: "struct.new", body: []byte{OpcodeGCPrefix, byte(OpcodeGCStructNew), 0}, expectedCost: 5},
+		{name: "i32.const", body: []byte{OpcodeI32Const, 0}, expectedCost: 6},
+		{name: "f64.sqrt", body: []byte{OpcodeF64Sqrt}, expectedCost: 6},
+		{name: "i32.trunc_sat_f32_s", body: []byte{OpcodeMiscPrefix, OpcodeMiscI32TruncSatF32S}, expectedCost: 6},
+		{name: "i32x4.add", body: []byte{OpcodeVecPrefix, OpcodeVecI32x4Add}, expectedCost: 7},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			cost, charge := FuelCost(costs, tc.body)
+			require.Equal(t, tc.expectedCost, cost)
+			require.Equal(t, tc.expectedCharge, charge)
+			require.Equal(t, tc.expectedCharge, IsBlockBoundary(tc.body))
+		})
+	}
+}
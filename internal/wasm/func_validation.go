@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -21,19 +23,28 @@ const maximumValuesOnStack = 1 << 27
 // * idx is the index in the FunctionSection
 // * functions are the function index, which is prefixed by imports. The value is the TypeSection index.
 // * globals are the global index, which is prefixed by imports.
-// * memory is the potentially imported memory and can be nil.
+// * memories are the memory index, which is prefixed by imports.
 // * table is the potentially imported table and can be nil.
 // * declaredFunctionIndexes is the set of function indexes declared by declarative element segments which can be acceed by OpcodeRefFunc instruction.
 //
 // Returns an error if the instruction sequence is not valid,
 // or potentially it can exceed the maximum number of values on the stack.
 func (m *Module) validateFunction(sts *stacks, enabledFeatures api.CoreFeatures, idx Index, functions []Index,
-	globals []GlobalType, memory *Memory, tables []Table, declaredFunctionIndexes map[Index]struct{}, br *bytes.Reader,
+	globals []GlobalType, memories []*Memory, tables []Table, declaredFunctionIndexes map[Index]struct{}, br *bytes.Reader,
 ) error {
-	return m.validateFunctionWithMaxStackValues(sts, enabledFeatures, idx, functions, globals, memory, tables, maximumValuesOnStack, declaredFunctionIndexes, br)
+	return m.validateFunctionWithMaxStackValues(sts, enabledFeatures, idx, functions, globals, memories, tables, maximumValuesOnStack, declaredFunctionIndexes, br)
 }
 
-func readMemArg(pc uint64, body []byte) (align, offset uint32, read uint64, err error) {
+// MemArgMemoryIndexFlag is set in the alignment of a memarg immediate when the index of the memory follows the
+// alignment. This is only valid when experimental.CoreFeaturesMultiMemory is enabled.
+//
+// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md#binary-format
+const MemArgMemoryIndexFlag = 1 << 6
+
+// readMemArg reads the memarg immediate at pc, and returns the alignment without MemArgMemoryIndexFlag, the offset,
+// the type of the address operand of the memory and the number of bytes read. This also errs if the memory index is
+// out of range of memories.
+func readMemArg(pc uint64, body []byte, enabledFeatures api.CoreFeatures, memories []*Memory) (align uint32, offset uint64, addressType ValueType, read uint64, err error) {
 	align, num, err := leb128.LoadUint32(body[pc:])
 	if err != nil {
 		err = fmt.Errorf("read memory align: %v", err)
@@ -41,14 +52,41 @@ func readMemArg(pc uint64, body []byte) (align, offset uint32, read uint64, err
 	}
 	read += num
 
-	offset, num, err = leb128.LoadUint32(body[pc+num:])
+	var memoryIndex uint32
+	if align&MemArgMemoryIndexFlag != 0 {
+		if !enabledFeatures.IsEnabled(experimental.CoreFeaturesMultiMemory) {
+			// Without multi-memory, the flag is just a part of too large alignment.
+			err = fmt.Errorf("invalid memory alignment")
+			return
+		}
+		align &^= MemArgMemoryIndexFlag
+		memoryIndex, num, err = leb128.LoadUint32(body[pc+read:])
+		if err != nil {
+			err = fmt.Errorf("read memory index: %v", err)
+			return
+		}
+		read += num
+	}
+	if memoryIndex >= uint32(len(memories)) {
+		err = fmt.Errorf("unknown memory %d", memoryIndex)
+		return
+	}
+
+	mem := memories[memoryIndex]
+	if mem.Is64 {
+		offset, num, err = leb128.LoadUint64(body[pc+read:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(body[pc+read:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		err = fmt.Errorf("read memory offset: %v", err)
 		return
 	}
 
 	read += num
-	return align, offset, read, nil
+	return align, offset, mem.AddressType(), read, nil
 }
 
 // validateFunctionWithMaxStackValues is like validateFunction, but allows overriding maxStackValues for testing.
@@ -61,7 +99,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 	idx Index,
 	functions []Index,
 	globals []GlobalType,
-	memory *Memory,
+	memories []*Memory,
 	tables []Table,
 	maxStackValues int,
 	declaredFunctionIndexes map[Index]struct{},
@@ -100,11 +138,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 		}
 
 		if OpcodeI32Load <= op && op <= OpcodeI64Store32 {
-			if memory == nil {
+			if len(memories) == 0 {
 				return fmt.Errorf("memory must exist for %s", InstructionName(op))
 			}
 			pc++
-			align, _, read, err := readMemArg(pc, body)
+			align, _, addressType, read, err := readMemArg(pc, body, enabledFeatures, memories)
 			if err != nil {
 				return err
 			}
@@ -114,7 +152,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -122,7 +160,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeF32)
@@ -133,7 +171,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeF32Store:
@@ -143,14 +181,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeF32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Load:
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -158,7 +196,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeF64)
@@ -169,7 +207,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeF64Store:
@@ -179,14 +217,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeF64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI32Load8S:
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -194,7 +232,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -202,7 +240,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -213,7 +251,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Store8:
@@ -223,14 +261,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI32Load16S, OpcodeI32Load16U:
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -238,7 +276,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -249,7 +287,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Store16:
@@ -259,14 +297,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Load32S, OpcodeI64Load32U:
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -277,12 +315,12 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			}
 		} else if OpcodeMemorySize <= op && op <= OpcodeMemoryGrow {
-			if memory == nil {
+			if len(memories) == 0 {
 				return fmt.Errorf("memory must exist for %s", InstructionName(op))
 			}
 			pc++
@@ -290,17 +328,22 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			if err != nil {
 				return fmt.Errorf("read immediate: %v", err)
 			}
-			if val != 0 || num != 1 {
+			if enabledFeatures.IsEnabled(experimental.CoreFeaturesMultiMemory) {
+				if val >= uint32(len(memories)) {
+					return fmt.Errorf("unknown memory %d for %s", val, InstructionName(op))
+				}
+			} else if val != 0 || num != 1 {
 				return fmt.Errorf("memory instruction reserved bytes not zero with 1 byte")
 			}
+			addressType := memories[val].AddressType()
 			switch Opcode(op) {
 			case OpcodeMemoryGrow:
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
-				valueTypeStack.push(ValueTypeI32)
+				valueTypeStack.push(addressType)
 			case OpcodeMemorySize:
-				valueTypeStack.push(ValueTypeI32)
+				valueTypeStack.push(addressType)
 			}
 			pc += num - 1
 		} else if OpcodeI32Const <= op && op <= OpcodeF64Const {
@@ -501,8 +544,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					if actual == valueTypeUnknown {
 						// Re-assign the expected type to unknown.
 						defaultLabelType[index] = valueTypeUnknown
-					} else if actual != exp {
+					} else if !IsSubtypeValueType(actual, exp) {
 						return typeMismatchError(true, OpcodeBrTableName, actual, exp, i)
+					} else {
+						// The other labels are checked against the actual type, which can be a subtype of the expected one.
+						defaultLabelType[index] = actual
 					}
 				}
 			} else {
@@ -526,7 +572,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					return fmt.Errorf("inconsistent block type length for %s at %d; %v (ln=%d) != %v (l=%d)", OpcodeBrTableName, l, defaultLabelType, ln, tableLabelType, l)
 				}
 				for i := range defaultLabelType {
-					if defaultLabelType[i] != valueTypeUnknown && defaultLabelType[i] != tableLabelType[i] {
+					if defaultLabelType[i] != valueTypeUnknown && !IsSubtypeValueType(defaultLabelType[i], tableLabelType[i]) {
 						return fmt.Errorf("incosistent block type for %s at %d", OpcodeBrTableName, l)
 					}
 				}
@@ -534,7 +580,13 @@ func (m *Module) validateFunctionWithMaxStackValues(
 
 			// br_table instruction is stack-polymorphic.
 			valueTypeStack.unreachable()
-		} else if op == OpcodeCall {
+		} else if op == OpcodeCall || op == OpcodeTailCallReturnCall {
+			if op == OpcodeTailCallReturnCall {
+				if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesTailCall); err != nil {
+					return fmt.Errorf("%s invalid as %v", OpcodeTailCallReturnCallName, err)
+				}
+				m.tailCallInstructions = true
+			}
 			pc++
 			index, num, err := leb128.LoadUint32(body[pc:])
 			if err != nil {
@@ -547,13 +599,27 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			funcType := &m.TypeSection[functions[index]]
 			for i := 0; i < len(funcType.Params); i++ {
 				if err := valueTypeStack.popAndVerifyType(funcType.Params[len(funcType.Params)-1-i]); err != nil {
-					return fmt.Errorf("type mismatch on %s operation param type: %v", OpcodeCallName, err)
+					return fmt.Errorf("type mismatch on %s operation param type: %v", InstructionName(op), err)
 				}
 			}
-			for _, exp := range funcType.Results {
-				valueTypeStack.push(exp)
+			if op == OpcodeTailCallReturnCall {
+				if err := validateTailCallResults(functionType, funcType); err != nil {
+					return err
+				}
+				// return_call instruction is stack-polymorphic.
+				valueTypeStack.unreachable()
+			} else {
+				for _, exp := range funcType.Results {
+					valueTypeStack.push(exp)
+				}
+			}
+		} else if op == OpcodeCallIndirect || op == OpcodeTailCallReturnCallIndirect {
+			if op == OpcodeTailCallReturnCallIndirect {
+				if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesTailCall); err != nil {
+					return fmt.Errorf("%s invalid as %v", OpcodeTailCallReturnCallIndirectName, err)
+				}
+				m.tailCallInstructions = true
 			}
-		} else if op == OpcodeCallIndirect {
 			pc++
 			typeIndex, num, err := leb128.LoadUint32(body[pc:])
 			if err != nil {
@@ -561,8 +627,8 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 			pc += num
 
-			if int(typeIndex) >= len(m.TypeSection) {
-				return fmt.Errorf("invalid type index at %s: %d", OpcodeCallIndirectName, typeIndex)
+			if int(typeIndex) >= len(m.TypeSection) || !m.IsFunctionType(typeIndex) {
+				return fmt.Errorf("invalid type index at %s: %d", InstructionName(op), typeIndex)
 			}
 
 			tableIndex, num, err := leb128.LoadUint32(body[pc:])
@@ -581,21 +647,29 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 
 			table := tables[tableIndex]
-			if table.Type != RefTypeFuncref {
-				return fmt.Errorf("table is not funcref type but was %s for %s", RefTypeName(table.Type), OpcodeCallIndirectName)
+			if !IsSubtypeValueType(table.Type, RefTypeFuncref) {
+				return fmt.Errorf("table is not funcref type but was %s for %s", RefTypeName(table.Type), InstructionName(op))
 			}
 
 			if err = valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
-				return fmt.Errorf("cannot pop the offset in table for %s", OpcodeCallIndirectName)
+				return fmt.Errorf("cannot pop the offset in table for %s", InstructionName(op))
 			}
 			funcType := &m.TypeSection[typeIndex]
 			for i := 0; i < len(funcType.Params); i++ {
 				if err = valueTypeStack.popAndVerifyType(funcType.Params[len(funcType.Params)-1-i]); err != nil {
-					return fmt.Errorf("type mismatch on %s operation input type", OpcodeCallIndirectName)
+					return fmt.Errorf("type mismatch on %s operation input type", InstructionName(op))
 				}
 			}
-			for _, exp := range funcType.Results {
-				valueTypeStack.push(exp)
+			if op == OpcodeTailCallReturnCallIndirect {
+				if err := validateTailCallResults(functionType, funcType); err != nil {
+					return err
+				}
+				// return_call_indirect instruction is stack-polymorphic.
+				valueTypeStack.unreachable()
+			} else {
+				for _, exp := range funcType.Results {
+					valueTypeStack.push(exp)
+				}
 			}
 		} else if OpcodeI32Eqz <= op && op <= OpcodeI64Extend32S {
 			switch op {
@@ -817,11 +891,33 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			switch op {
 			case OpcodeRefNull:
 				pc++
+				if enabledFeatures.IsEnabled(experimental.CoreFeaturesFunctionReferences) {
+					vt, num, err := m.refNullType(body[pc:])
+					if err != nil {
+						return fmt.Errorf("unknown type for ref.null: %v", err)
+					} else if vt == ValueTypeExnref {
+						if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err != nil {
+							return fmt.Errorf("ref.null exn invalid as %v", err)
+						}
+					} else if !enabledFeatures.IsEnabled(experimental.CoreFeaturesGC) && topReferenceType(vt) == ValueTypeAnyref {
+						return fmt.Errorf("ref.null %s invalid as %v", ValueTypeName(vt),
+							enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC))
+					}
+					m.gcInstructions = m.gcInstructions || isGCValueType(vt)
+					valueTypeStack.push(vt)
+					pc += uint64(num) - 1
+					break
+				}
 				switch reftype := body[pc]; reftype {
 				case ValueTypeExternref:
 					valueTypeStack.push(ValueTypeExternref)
 				case ValueTypeFuncref:
 					valueTypeStack.push(ValueTypeFuncref)
+				case ValueTypeExnref:
+					if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err != nil {
+						return fmt.Errorf("ref.null exn invalid as %v", err)
+					}
+					valueTypeStack.push(ValueTypeExnref)
 				default:
 					return fmt.Errorf("unknown type for ref.null: 0x%x", reftype)
 				}
@@ -829,7 +925,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				tp, err := valueTypeStack.pop()
 				if err != nil {
 					return fmt.Errorf("cannot pop the operand for ref.is_null: %v", err)
-				} else if !isReferenceValueType(tp) && tp != valueTypeUnknown {
+				} else if !IsReferenceValueType(tp) && tp != valueTypeUnknown {
 					return fmt.Errorf("type mismatch: expected reference type but was %s", ValueTypeName(tp))
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -930,10 +1026,9 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					}
 					pc += num - 1
 				case OpcodeMiscMemoryInit, OpcodeMiscMemoryCopy, OpcodeMiscMemoryFill:
-					if memory == nil {
+					if len(memories) == 0 {
 						return fmt.Errorf("memory must exist for %s", MiscInstructionName(miscOpcode))
 					}
-					params = []ValueType{ValueTypeI32, ValueTypeI32, ValueTypeI32}
 
 					if miscOpcode == OpcodeMiscMemoryInit {
 						if m.DataCountSection == nil {
@@ -953,23 +1048,33 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					}
 
 					pc++
-					val, num, err := leb128.LoadUint32(body[pc:])
+					memoryIndex, num, err := validateBulkMemoryIndex(body[pc:], enabledFeatures, memories, miscOpcode)
 					if err != nil {
-						return fmt.Errorf("failed to read memory index for %s: %v", MiscInstructionName(miscOpcode), err)
-					}
-					if val != 0 || num != 1 {
-						return fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", MiscInstructionName(miscOpcode))
+						return err
 					}
-					if miscOpcode == OpcodeMiscMemoryCopy {
+					pc += num - 1
+					// Note that params are in the popping order, so the last operand comes first.
+					addressType := memories[memoryIndex].AddressType()
+					switch miscOpcode {
+					case OpcodeMiscMemoryInit:
+						params = []ValueType{ValueTypeI32, ValueTypeI32, addressType}
+					case OpcodeMiscMemoryFill:
+						params = []ValueType{addressType, ValueTypeI32, addressType}
+					case OpcodeMiscMemoryCopy:
 						pc++
-						// memory.copy needs two memory index which are reserved as zero.
-						val, num, err := leb128.LoadUint32(body[pc:])
+						// memory.copy needs two memory indexes: the destination and the source.
+						srcMemoryIndex, num, err := validateBulkMemoryIndex(body[pc:], enabledFeatures, memories, miscOpcode)
 						if err != nil {
-							return fmt.Errorf("failed to read memory index for %s: %v", MiscInstructionName(miscOpcode), err)
+							return err
 						}
-						if val != 0 || num != 1 {
-							return fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", MiscInstructionName(miscOpcode))
+						pc += num - 1
+						// The length is 32-bit unless both memories are 64-bit.
+						srcAddressType := memories[srcMemoryIndex].AddressType()
+						lenType := ValueTypeI32
+						if addressType == ValueTypeI64 && srcAddressType == ValueTypeI64 {
+							lenType = ValueTypeI64
 						}
+						params = []ValueType{lenType, srcAddressType, addressType}
 					}
 
 				case OpcodeMiscTableInit:
@@ -997,7 +1102,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						return fmt.Errorf("table of index %d not found", tableIndex)
 					}
 
-					if m.ElementSection[elementIndex].Type != tables[tableIndex].Type {
+					if !IsSubtypeValueType(m.ElementSection[elementIndex].Type, tables[tableIndex].Type) {
 						return fmt.Errorf("type mismatch for table.init: element type %s does not match table type %s",
 							RefTypeName(m.ElementSection[elementIndex].Type),
 							RefTypeName(tables[tableIndex].Type),
@@ -1044,7 +1149,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						return fmt.Errorf("table of index %d not found", srcTableIndex)
 					}
 
-					if tables[srcTableIndex].Type != tables[dstTableIndex].Type {
+					if !IsSubtypeValueType(tables[srcTableIndex].Type, tables[dstTableIndex].Type) {
 						return fmt.Errorf("table type mismatch for table.copy: %s (src) != %s (dst)",
 							RefTypeName(tables[srcTableIndex].Type), RefTypeName(tables[dstTableIndex].Type))
 					}
@@ -1094,9 +1199,17 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				return fmt.Errorf("unknown misc opcode %#x", miscOpcode)
 			}
 		} else if op == OpcodeVecPrefix {
+			m.vectorInstructions = true
 			pc++
 			// Vector instructions come with two bytes where the first byte is always OpcodeVecPrefix,
 			// and the second byte determines the actual instruction.
+			if relaxedOpcode, ok := RelaxedVectorOpcode(body[pc:]); ok {
+				if err := validateRelaxedVectorInstruction(relaxedOpcode, enabledFeatures, valueTypeStack); err != nil {
+					return err
+				}
+				pc++ // Skip the second byte of the opcode.
+				continue
+			}
 			vecOpcode := body[pc]
 			if err := enabledFeatures.RequireEnabled(api.CoreFeatureSIMD); err != nil {
 				return fmt.Errorf("%s invalid as %v", vectorInstructionName[vecOpcode], err)
@@ -1120,11 +1233,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				OpcodeVecV128Load32x2s, OpcodeVecV128Load32x2u, OpcodeVecV128Load8Splat, OpcodeVecV128Load16Splat,
 				OpcodeVecV128Load32Splat, OpcodeVecV128Load64Splat,
 				OpcodeVecV128Load32zero, OpcodeVecV128Load64zero:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, _, addressType, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
@@ -1153,16 +1266,16 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > maxAlign {
 					return fmt.Errorf("invalid memory alignment %d for %s", align, VectorInstructionName(vecOpcode))
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", VectorInstructionName(vecOpcode), err)
 				}
 				valueTypeStack.push(ValueTypeV128)
 			case OpcodeVecV128Store:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, _, addressType, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
@@ -1173,16 +1286,16 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeVecV128StoreName, err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeVecV128StoreName, err)
 				}
 			case OpcodeVecV128Load8Lane, OpcodeVecV128Load16Lane, OpcodeVecV128Load32Lane, OpcodeVecV128Load64Lane:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				attr := vecLoadLanes[vecOpcode]
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, _, addressType, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
@@ -1200,17 +1313,17 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
 				valueTypeStack.push(ValueTypeV128)
 			case OpcodeVecV128Store8Lane, OpcodeVecV128Store16Lane, OpcodeVecV128Store32Lane, OpcodeVecV128Store64Lane:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				attr := vecStoreLanes[vecOpcode]
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, _, addressType, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
@@ -1228,7 +1341,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
 			case OpcodeVecI8x16ExtractLaneS,
@@ -1399,10 +1512,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 		} else if op == OpcodeBlock {
 			br.Reset(body[pc+1:])
-			bt, num, err := DecodeBlockType(m.TypeSection, br, enabledFeatures)
+			bt, num, err := DecodeBlockType(m, br, enabledFeatures)
 			if err != nil {
 				return fmt.Errorf("read block: %w", err)
 			}
+			m.gcInstructions = m.gcInstructions || slices.ContainsFunc(bt.Results, isGCValueType)
 			controlBlockStack.push(pc, 0, 0, bt, num, 0)
 			if err = valueTypeStack.popParams(op, bt.Params, false); err != nil {
 				return err
@@ -1413,6 +1527,64 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 			valueTypeStack.pushStackLimit(len(bt.Params))
 			pc += num
+		} else if op == OpcodeTryTable {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeTryTableName, err)
+			}
+			br.Reset(body[pc+1:])
+			bt, num, err := DecodeBlockType(m, br, enabledFeatures)
+			if err != nil {
+				return fmt.Errorf("read block: %w", err)
+			}
+			m.gcInstructions = m.gcInstructions || slices.ContainsFunc(bt.Results, isGCValueType)
+			catches, catchesNum, err := DecodeCatchClauses(body[pc+1+num:])
+			if err != nil {
+				return fmt.Errorf("read catch clauses: %w", err)
+			}
+			// Catch clauses branch to the labels outside the try_table, so check them before pushing the block.
+			for i := range catches {
+				if err = m.validateCatchClause(&catches[i], controlBlockStack); err != nil {
+					return fmt.Errorf("invalid catch clause[%d] for %s: %w", i, OpcodeTryTableName, err)
+				}
+			}
+			controlBlockStack.push(pc, 0, 0, bt, num+catchesNum, op)
+			if err = valueTypeStack.popParams(op, bt.Params, false); err != nil {
+				return err
+			}
+			// Plus we have to push any block params again.
+			for _, p := range bt.Params {
+				valueTypeStack.push(p)
+			}
+			valueTypeStack.pushStackLimit(len(bt.Params))
+			pc += num + catchesNum
+		} else if op == OpcodeThrow {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeThrowName, err)
+			}
+			pc++
+			index, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			}
+			pc += num - 1
+			tagType := m.TagType(index)
+			if tagType == nil {
+				return fmt.Errorf("invalid tag index %d for %s", index, OpcodeThrowName)
+			}
+			if err = valueTypeStack.popParams(op, tagType.Params, false); err != nil {
+				return err
+			}
+			// throw instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
+		} else if op == OpcodeThrowRef {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeThrowRefName, err)
+			}
+			if err := valueTypeStack.popAndVerifyType(ValueTypeExnref); err != nil {
+				return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeThrowRefName, err)
+			}
+			// throw_ref instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
 		} else if op == OpcodeAtomicPrefix {
 			pc++
 			// Atomic instructions come with two bytes where the first byte is always OpcodeAtomicPrefix,
@@ -1433,10 +1605,10 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 
 			// All atomic operations except fence (checked above) require memory
-			if memory == nil {
+			if len(memories) == 0 {
 				return fmt.Errorf("memory must exist for %s", AtomicInstructionName(atomicOpcode))
 			}
-			align, _, read, err := readMemArg(pc, body)
+			align, _, addressType, read, err := readMemArg(pc, body, enabledFeatures, memories)
 			if err != nil {
 				return err
 			}
@@ -1449,7 +1621,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1463,7 +1635,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1477,7 +1649,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1485,7 +1657,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1493,7 +1665,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1501,7 +1673,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align != 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1509,7 +1681,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align != 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1517,7 +1689,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align != 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1525,7 +1697,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1533,7 +1705,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1544,7 +1716,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI64Store:
@@ -1554,7 +1726,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI32Store8:
@@ -1564,7 +1736,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI32Store16:
@@ -1574,7 +1746,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI64Store8:
@@ -1584,7 +1756,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI64Store16:
@@ -1594,7 +1766,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI64Store32:
@@ -1604,7 +1776,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI32RmwAdd, OpcodeAtomicI32RmwSub, OpcodeAtomicI32RmwAnd, OpcodeAtomicI32RmwOr, OpcodeAtomicI32RmwXor, OpcodeAtomicI32RmwXchg:
@@ -1614,7 +1786,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1625,7 +1797,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1636,7 +1808,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1647,7 +1819,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1658,7 +1830,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1669,7 +1841,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1680,7 +1852,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1694,7 +1866,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1708,7 +1880,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1722,7 +1894,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1736,7 +1908,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1750,7 +1922,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1764,7 +1936,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1778,7 +1950,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1787,10 +1959,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 		} else if op == OpcodeLoop {
 			br.Reset(body[pc+1:])
-			bt, num, err := DecodeBlockType(m.TypeSection, br, enabledFeatures)
+			bt, num, err := DecodeBlockType(m, br, enabledFeatures)
 			if err != nil {
 				return fmt.Errorf("read block: %w", err)
 			}
+			m.gcInstructions = m.gcInstructions || slices.ContainsFunc(bt.Results, isGCValueType)
 			controlBlockStack.push(pc, 0, 0, bt, num, op)
 			if err = valueTypeStack.popParams(op, bt.Params, false); err != nil {
 				return err
@@ -1803,10 +1976,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			pc += num
 		} else if op == OpcodeIf {
 			br.Reset(body[pc+1:])
-			bt, num, err := DecodeBlockType(m.TypeSection, br, enabledFeatures)
+			bt, num, err := DecodeBlockType(m, br, enabledFeatures)
 			if err != nil {
 				return fmt.Errorf("read block: %w", err)
 			}
+			m.gcInstructions = m.gcInstructions || slices.ContainsFunc(bt.Results, isGCValueType)
 			controlBlockStack.push(pc, 0, 0, bt, num, op)
 			if err = valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 				return fmt.Errorf("cannot pop the operand for 'if': %v", err)
@@ -1911,11 +2085,26 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				}
 				pc++
 				tp := body[pc]
-				if tp != ValueTypeI32 && tp != ValueTypeI64 && tp != ValueTypeF32 && tp != ValueTypeF64 &&
+				if enabledFeatures.IsEnabled(experimental.CoreFeaturesFunctionReferences) {
+					br.Reset(body[pc:])
+					if tp, err = m.DecodeValueType(br, enabledFeatures); err != nil {
+						return fmt.Errorf("invalid type for %s: %v", OpcodeTypedSelectName, err)
+					}
+					pc += uint64(len(body[pc:])-br.Len()) - 1
+				} else if tp != ValueTypeI32 && tp != ValueTypeI64 && tp != ValueTypeF32 && tp != ValueTypeF64 &&
 					tp != api.ValueTypeExternref && tp != ValueTypeFuncref && tp != ValueTypeV128 {
 					return fmt.Errorf("invalid type %s for %s", ValueTypeName(tp), OpcodeTypedSelectName)
 				}
-			} else if isReferenceValueType(v1) || isReferenceValueType(v2) {
+				m.gcInstructions = m.gcInstructions || isGCValueType(tp)
+				if IsReferenceValueType(tp) {
+					// The operands can be subtypes of the declared type, so the result is the declared type.
+					if (v1 != valueTypeUnknown && !IsSubtypeValueType(v1, tp)) || (v2 != valueTypeUnknown && !IsSubtypeValueType(v2, tp)) {
+						return fmt.Errorf("type mismatch on 1st and 2nd select operands")
+					}
+					valueTypeStack.push(tp)
+					continue
+				}
+			} else if IsReferenceValueType(v1) || IsReferenceValueType(v2) {
 				return fmt.Errorf("reference types cannot be used for non typed select instruction")
 			}
 
@@ -1927,6 +2116,112 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			} else {
 				valueTypeStack.push(v1)
 			}
+		} else if op == OpcodeCallRef || op == OpcodeReturnCallRef {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesFunctionReferences); err != nil {
+				return fmt.Errorf("%s invalid as %v", InstructionName(op), err)
+			}
+			if op == OpcodeReturnCallRef {
+				if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesTailCall); err != nil {
+					return fmt.Errorf("%s invalid as %v", OpcodeReturnCallRefName, err)
+				}
+				m.tailCallInstructions = true
+			}
+			pc++
+			typeIndex, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			}
+			pc += num - 1
+			if int(typeIndex) >= len(m.TypeSection) || !m.IsFunctionType(typeIndex) {
+				return fmt.Errorf("invalid type index at %s: %d", InstructionName(op), typeIndex)
+			}
+			if err = valueTypeStack.popAndVerifyType(ValueTypeFuncref); err != nil {
+				return fmt.Errorf("cannot pop the function reference for %s: %v", InstructionName(op), err)
+			}
+			funcType := &m.TypeSection[typeIndex]
+			for i := 0; i < len(funcType.Params); i++ {
+				if err = valueTypeStack.popAndVerifyType(funcType.Params[len(funcType.Params)-1-i]); err != nil {
+					return fmt.Errorf("type mismatch on %s operation input type", InstructionName(op))
+				}
+			}
+			if op == OpcodeReturnCallRef {
+				if err := validateTailCallResults(functionType, funcType); err != nil {
+					return err
+				}
+				// return_call_ref instruction is stack-polymorphic.
+				valueTypeStack.unreachable()
+			} else {
+				for _, exp := range funcType.Results {
+					valueTypeStack.push(exp)
+				}
+			}
+		} else if op == OpcodeRefAsNonNull {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesFunctionReferences); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeRefAsNonNullName, err)
+			}
+			tp, err := valueTypeStack.pop()
+			if err != nil {
+				return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeRefAsNonNullName, err)
+			} else if !IsReferenceValueType(tp) && tp != valueTypeUnknown {
+				return fmt.Errorf("type mismatch: expected reference type but was %s", ValueTypeName(tp))
+			}
+			valueTypeStack.push(tp)
+		} else if op == OpcodeBrOnNull || op == OpcodeBrOnNonNull {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesFunctionReferences); err != nil {
+				return fmt.Errorf("%s invalid as %v", InstructionName(op), err)
+			}
+			pc++
+			index, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			} else if int(index) >= len(controlBlockStack.stack) {
+				return fmt.Errorf("invalid %s operation: index out of range", InstructionName(op))
+			}
+			pc += num - 1
+			tp, err := valueTypeStack.pop()
+			if err != nil {
+				return fmt.Errorf("cannot pop the operand for %s: %v", InstructionName(op), err)
+			} else if !IsReferenceValueType(tp) && tp != valueTypeUnknown {
+				return fmt.Errorf("type mismatch: expected reference type but was %s", ValueTypeName(tp))
+			}
+			targetResultType := controlBlockStack.stack[len(controlBlockStack.stack)-int(index)-1].labelTypes()
+			if op == OpcodeBrOnNull {
+				if err = valueTypeStack.popResults(op, targetResultType, false); err != nil {
+					return err
+				}
+				for _, t := range targetResultType {
+					valueTypeStack.push(t)
+				}
+				// The reference is left on the stack when it's not null.
+				valueTypeStack.push(tp)
+			} else {
+				// The non-null reference is passed to the label as its last value.
+				if err = popBranchOnReference(valueTypeStack, InstructionName(op), targetResultType, tp); err != nil {
+					return err
+				}
+			}
+		} else if op == OpcodeRefEq {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeRefEqName, err)
+			}
+			m.gcInstructions = true
+			for i := 0; i < 2; i++ {
+				if err := valueTypeStack.popAndVerifyType(ValueTypeEqref); err != nil {
+					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeRefEqName, err)
+				}
+			}
+			valueTypeStack.push(ValueTypeI32)
+		} else if op == OpcodeGCPrefix {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeGCPrefixName, err)
+			}
+			m.gcInstructions = true
+			pc++
+			read, err := m.validateGCInstruction(body[pc:], enabledFeatures, valueTypeStack, controlBlockStack, br)
+			if err != nil {
+				return err
+			}
+			pc += read - 1
 		} else if op == OpcodeUnreachable {
 			// unreachable instruction is stack-polymorphic.
 			valueTypeStack.unreachable()
@@ -2083,7 +2378,7 @@ func (s *valueTypeStack) popAndVerifyType(expected ValueType) error {
 	if !ok {
 		return fmt.Errorf("%s missing", ValueTypeName(expected))
 	}
-	if have != expected && have != valueTypeUnknown && expected != valueTypeUnknown {
+	if !IsSubtypeValueType(have, expected) && have != valueTypeUnknown && expected != valueTypeUnknown {
 		return fmt.Errorf("type mismatch: expected %s, but was %s", ValueTypeName(expected), ValueTypeName(have))
 	}
 	return nil
@@ -2165,13 +2460,414 @@ func (s *valueTypeStack) requireStackValues(
 	// Finally, check the types of the values:
 	for i, v := range s.requireStackValuesTmp {
 		nextWant := want[countWanted-i-1] // have is in reverse order (stack)
-		if v != nextWant && v != valueTypeUnknown && nextWant != valueTypeUnknown {
+		if !IsSubtypeValueType(v, nextWant) && v != valueTypeUnknown && nextWant != valueTypeUnknown {
 			return typeMismatchError(isParam, context, v, nextWant, i)
 		}
 	}
 	return nil
 }
 
+// validateTailCallResults ensures the results of the tail-called function type match the ones of the current function,
+// as the callee returns directly to the caller of the current function.
+func validateTailCallResults(current, callee *FunctionType) error {
+	if len(current.Results) != len(callee.Results) {
+		return fmt.Errorf("type mismatch on tail call: callee type %s has different results than the function type %s", callee, current)
+	}
+	for i, r := range callee.Results {
+		if !IsSubtypeValueType(r, current.Results[i]) {
+			return fmt.Errorf("type mismatch on tail call: callee type %s has different results than the function type %s", callee, current)
+		}
+	}
+	return nil
+}
+
+// popBranchOnReference pops the values passed to the label of br_on_non_null, br_on_cast or br_on_cast_fail, whose
+// last value is the reference of the type tp, and pushes back the rest of them.
+func popBranchOnReference(valueTypeStack *valueTypeStack, name string, labelTypes []ValueType, tp ValueType) error {
+	if len(labelTypes) == 0 {
+		return fmt.Errorf("type mismatch: the label of %s must have a reference type result", name)
+	}
+	last := labelTypes[len(labelTypes)-1]
+	if tp != valueTypeUnknown && !IsSubtypeValueType(tp, last) {
+		return typeMismatchError(false, name, tp, last, 0)
+	}
+	rest := labelTypes[:len(labelTypes)-1]
+	if err := valueTypeStack.requireStackValues(false, name, rest, false); err != nil {
+		return err
+	}
+	for _, t := range rest {
+		valueTypeStack.push(t)
+	}
+	return nil
+}
+
+// maximumArrayNewFixedLength is the maximum number of operands of array.new_fixed, which is the same limit as the
+// one of the JS API.
+const maximumArrayNewFixedLength = 10000
+
+// validateGCInstruction validates the instruction prefixed by OpcodeGCPrefix at the beginning of body, and returns
+// the number of bytes read.
+func (m *Module) validateGCInstruction(body []byte, enabledFeatures api.CoreFeatures, valueTypeStack *valueTypeStack,
+	controlBlockStack *controlBlockStack, br *bytes.Reader,
+) (uint64, error) {
+	gcOp, pc, err := leb128.LoadUint32(body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read gc opcode: %v", err)
+	}
+	name := GCInstructionName(gcOp)
+	readIndex := func() (Index, error) {
+		v, num, err := leb128.LoadUint32(body[pc:])
+		if err != nil {
+			return 0, fmt.Errorf("read immediate of %s: %v", name, err)
+		}
+		pc += num
+		return v, nil
+	}
+	readCompositeType := func(kind CompositeTypeKind) (*CompositeType, error) {
+		index, err := readIndex()
+		if err != nil {
+			return nil, err
+		}
+		ct, err := m.compositeTypeOf(index, kind)
+		if err != nil {
+			return nil, fmt.Errorf("invalid type for %s: %v", name, err)
+		}
+		return ct, nil
+	}
+	readHeapType := func() (ValueType, error) {
+		br.Reset(body[pc:])
+		ht, err := DecodeHeapType(br, enabledFeatures)
+		if err != nil {
+			return 0, fmt.Errorf("read heap type of %s: %v", name, err)
+		}
+		pc += uint64(len(body[pc:]) - br.Len())
+		vt, err := m.HeapTypeValueType(ht)
+		if err != nil {
+			return 0, fmt.Errorf("invalid heap type for %s: %v", name, err)
+		}
+		return vt, nil
+	}
+	pop := func(vt ValueType) error {
+		if err := valueTypeStack.popAndVerifyType(vt); err != nil {
+			return fmt.Errorf("cannot pop the operand for %s: %v", name, err)
+		}
+		return nil
+	}
+	popAll := func(vts ...ValueType) error {
+		for _, vt := range vts {
+			if err := pop(vt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	requireMutable := func(f *FieldType) error {
+		if !f.Mutable {
+			return fmt.Errorf("%s to immutable field", name)
+		}
+		return nil
+	}
+	requirePacked := func(f *FieldType, packed bool) error {
+		if f.IsPacked() != packed {
+			if packed {
+				return fmt.Errorf("%s must be used for packed fields", name)
+			}
+			return fmt.Errorf("%s must not be used for packed fields", name)
+		}
+		return nil
+	}
+	readDataIndex := func() error {
+		if m.DataCountSection == nil {
+			return fmt.Errorf("%s requires data count section", name)
+		}
+		index, err := readIndex()
+		if err != nil {
+			return err
+		}
+		if int(index) >= len(m.DataSection) {
+			return fmt.Errorf("index %d out of range of data section(len=%d)", index, len(m.DataSection))
+		}
+		return nil
+	}
+	readElementIndex := func(want ValueType) error {
+		index, err := readIndex()
+		if err != nil {
+			return err
+		}
+		if int(index) >= len(m.ElementSection) {
+			return fmt.Errorf("index %d out of range of element section(len=%d)", index, len(m.ElementSection))
+		}
+		if have := m.ElementSection[index].Type; !IsSubtypeValueType(have, want) {
+			return fmt.Errorf("type mismatch for %s: element type %s is not a subtype of %s", name, RefTypeName(have), ValueTypeName(want))
+		}
+		return nil
+	}
+
+	switch gcOp {
+	case OpcodeGCStructNew, OpcodeGCStructNewDefault:
+		ct, err := readCompositeType(CompositeTypeKindStruct)
+		if err != nil {
+			return 0, err
+		}
+		if gcOp == OpcodeGCStructNew {
+			for i := len(ct.Fields) - 1; i >= 0; i-- {
+				if err = pop(ct.Fields[i].ValueType()); err != nil {
+					return 0, err
+				}
+			}
+		}
+		valueTypeStack.push(ValueTypeStructref)
+	case OpcodeGCStructGet, OpcodeGCStructGetS, OpcodeGCStructGetU, OpcodeGCStructSet:
+		ct, err := readCompositeType(CompositeTypeKindStruct)
+		if err != nil {
+			return 0, err
+		}
+		fieldIndex, err := readIndex()
+		if err != nil {
+			return 0, err
+		} else if int(fieldIndex) >= len(ct.Fields) {
+			return 0, fmt.Errorf("unknown field %d for %s", fieldIndex, name)
+		}
+		field := &ct.Fields[fieldIndex]
+		switch gcOp {
+		case OpcodeGCStructSet:
+			if err = requireMutable(field); err != nil {
+				return 0, err
+			}
+			if err = popAll(field.ValueType(), ValueTypeStructref); err != nil {
+				return 0, err
+			}
+		default:
+			if err = requirePacked(field, gcOp != OpcodeGCStructGet); err != nil {
+				return 0, err
+			}
+			if err = pop(ValueTypeStructref); err != nil {
+				return 0, err
+			}
+			valueTypeStack.push(field.ValueType())
+		}
+	case OpcodeGCArrayNew, OpcodeGCArrayNewDefault, OpcodeGCArrayNewFixed, OpcodeGCArrayNewData, OpcodeGCArrayNewElem:
+		ct, err := readCompositeType(CompositeTypeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		elem := &ct.Fields[0]
+		switch gcOp {
+		case OpcodeGCArrayNew:
+			err = popAll(ValueTypeI32, elem.ValueType())
+		case OpcodeGCArrayNewDefault:
+			err = pop(ValueTypeI32)
+		case OpcodeGCArrayNewFixed:
+			var n uint32
+			if n, err = readIndex(); err != nil {
+				return 0, err
+			} else if n > maximumArrayNewFixedLength {
+				return 0, fmt.Errorf("too many operands for %s: %d", name, n)
+			}
+			for i := uint32(0); i < n && err == nil; i++ {
+				err = pop(elem.ValueType())
+			}
+		case OpcodeGCArrayNewData:
+			if IsReferenceValueType(elem.StorageType) {
+				return 0, fmt.Errorf("%s requires numeric or vector array", name)
+			}
+			if err = readDataIndex(); err != nil {
+				return 0, err
+			}
+			err = popAll(ValueTypeI32, ValueTypeI32)
+		case OpcodeGCArrayNewElem:
+			if !IsReferenceValueType(elem.StorageType) {
+				return 0, fmt.Errorf("%s requires reference type array", name)
+			}
+			if err = readElementIndex(elem.ValueType()); err != nil {
+				return 0, err
+			}
+			err = popAll(ValueTypeI32, ValueTypeI32)
+		}
+		if err != nil {
+			return 0, err
+		}
+		valueTypeStack.push(ValueTypeArrayref)
+	case OpcodeGCArrayGet, OpcodeGCArrayGetS, OpcodeGCArrayGetU:
+		ct, err := readCompositeType(CompositeTypeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		elem := &ct.Fields[0]
+		if err = requirePacked(elem, gcOp != OpcodeGCArrayGet); err != nil {
+			return 0, err
+		}
+		if err = popAll(ValueTypeI32, ValueTypeArrayref); err != nil {
+			return 0, err
+		}
+		valueTypeStack.push(elem.ValueType())
+	case OpcodeGCArraySet, OpcodeGCArrayFill:
+		ct, err := readCompositeType(CompositeTypeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		elem := &ct.Fields[0]
+		if err = requireMutable(elem); err != nil {
+			return 0, err
+		}
+		if gcOp == OpcodeGCArrayFill {
+			err = popAll(ValueTypeI32, elem.ValueType(), ValueTypeI32, ValueTypeArrayref)
+		} else {
+			err = popAll(elem.ValueType(), ValueTypeI32, ValueTypeArrayref)
+		}
+		if err != nil {
+			return 0, err
+		}
+	case OpcodeGCArrayLen:
+		if err = pop(ValueTypeArrayref); err != nil {
+			return 0, err
+		}
+		valueTypeStack.push(ValueTypeI32)
+	case OpcodeGCArrayCopy:
+		dst, err := readCompositeType(CompositeTypeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		src, err := readCompositeType(CompositeTypeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		dstElem, srcElem := &dst.Fields[0], &src.Fields[0]
+		if err = requireMutable(dstElem); err != nil {
+			return 0, err
+		}
+		if srcElem.IsPacked() || dstElem.IsPacked() || !IsReferenceValueType(dstElem.StorageType) {
+			if srcElem.StorageType != dstElem.StorageType {
+				return 0, fmt.Errorf("type mismatch for %s: %s != %s", name, StorageTypeName(srcElem.StorageType), StorageTypeName(dstElem.StorageType))
+			}
+		} else if !IsSubtypeValueType(srcElem.ValueType(), dstElem.ValueType()) {
+			return 0, fmt.Errorf("type mismatch for %s: %s is not a subtype of %s", name, ValueTypeName(srcElem.ValueType()), ValueTypeName(dstElem.ValueType()))
+		}
+		if err = popAll(ValueTypeI32, ValueTypeI32, ValueTypeArrayref, ValueTypeI32, ValueTypeArrayref); err != nil {
+			return 0, err
+		}
+	case OpcodeGCArrayInitData, OpcodeGCArrayInitElem:
+		ct, err := readCompositeType(CompositeTypeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		elem := &ct.Fields[0]
+		if err = requireMutable(elem); err != nil {
+			return 0, err
+		}
+		if gcOp == OpcodeGCArrayInitData {
+			if IsReferenceValueType(elem.StorageType) {
+				return 0, fmt.Errorf("%s requires numeric or vector array", name)
+			}
+			err = readDataIndex()
+		} else {
+			if !IsReferenceValueType(elem.StorageType) {
+				return 0, fmt.Errorf("%s requires reference type array", name)
+			}
+			err = readElementIndex(elem.ValueType())
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err = popAll(ValueTypeI32, ValueTypeI32, ValueTypeI32, ValueTypeArrayref); err != nil {
+			return 0, err
+		}
+	case OpcodeGCRefTest, OpcodeGCRefTestNull, OpcodeGCRefCast, OpcodeGCRefCastNull:
+		vt, err := readHeapType()
+		if err != nil {
+			return 0, err
+		}
+		if err = pop(topReferenceType(vt)); err != nil {
+			return 0, err
+		}
+		if gcOp == OpcodeGCRefTest || gcOp == OpcodeGCRefTestNull {
+			valueTypeStack.push(ValueTypeI32)
+		} else {
+			valueTypeStack.push(vt)
+		}
+	case OpcodeGCBrOnCast, OpcodeGCBrOnCastFail:
+		if pc >= uint64(len(body)) {
+			return 0, fmt.Errorf("read immediate of %s: %v", name, io.ErrUnexpectedEOF)
+		}
+		// The flags only tell the nullability of the types, which isn't tracked. See HeapType.
+		if flags := body[pc]; flags > 3 {
+			return 0, fmt.Errorf("invalid flags for %s: %#x", name, flags)
+		}
+		pc++
+		label, err := readIndex()
+		if err != nil {
+			return 0, err
+		} else if int(label) >= len(controlBlockStack.stack) {
+			return 0, fmt.Errorf("invalid %s operation: index out of range", name)
+		}
+		from, err := readHeapType()
+		if err != nil {
+			return 0, err
+		}
+		to, err := readHeapType()
+		if err != nil {
+			return 0, err
+		}
+		if !IsSubtypeValueType(to, from) {
+			return 0, fmt.Errorf("type mismatch for %s: %s is not a subtype of %s", name, ValueTypeName(to), ValueTypeName(from))
+		}
+		if err = pop(from); err != nil {
+			return 0, err
+		}
+		labelTypes := controlBlockStack.stack[len(controlBlockStack.stack)-int(label)-1].labelTypes()
+		// br_on_cast branches with the casted reference, and br_on_cast_fail with the original one.
+		branched, remaining := to, from
+		if gcOp == OpcodeGCBrOnCastFail {
+			branched, remaining = from, to
+		}
+		if err = popBranchOnReference(valueTypeStack, name, labelTypes, branched); err != nil {
+			return 0, err
+		}
+		valueTypeStack.push(remaining)
+	case OpcodeGCAnyConvertExtern:
+		if err = pop(ValueTypeExternref); err != nil {
+			return 0, err
+		}
+		valueTypeStack.push(ValueTypeAnyref)
+	case OpcodeGCExternConvertAny:
+		if err = pop(ValueTypeAnyref); err != nil {
+			return 0, err
+		}
+		valueTypeStack.push(ValueTypeExternref)
+	case OpcodeGCRefI31:
+		if err = pop(ValueTypeI32); err != nil {
+			return 0, err
+		}
+		valueTypeStack.push(ValueTypeI31ref)
+	case OpcodeGCI31GetS, OpcodeGCI31GetU:
+		if err = pop(ValueTypeI31ref); err != nil {
+			return 0, err
+		}
+		valueTypeStack.push(ValueTypeI32)
+	default:
+		return 0, fmt.Errorf("invalid gc instruction %#x", gcOp)
+	}
+	return pc, nil
+}
+
+// validateBulkMemoryIndex validates the memory index immediate of memory.init, memory.copy or memory.fill at the
+// beginning of body, and returns the number of bytes read. The index is a reserved zero byte unless
+// experimental.CoreFeaturesMultiMemory is enabled.
+func validateBulkMemoryIndex(body []byte, enabledFeatures api.CoreFeatures, memories []*Memory, miscOpcode OpcodeMisc) (Index, uint64, error) {
+	val, num, err := leb128.LoadUint32(body)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory index for %s: %v", MiscInstructionName(miscOpcode), err)
+	}
+	if enabledFeatures.IsEnabled(experimental.CoreFeaturesMultiMemory) {
+		if val >= uint32(len(memories)) {
+			return 0, 0, fmt.Errorf("unknown memory %d for %s", val, MiscInstructionName(miscOpcode))
+		}
+	} else if val != 0 || num != 1 {
+		return 0, 0, fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", MiscInstructionName(miscOpcode))
+	}
+	return val, num, nil
+}
+
 // typeMismatchError returns an error similar to go compiler's error on type mismatch.
 func typeMismatchError(isParam bool, context string, have ValueType, want ValueType, i int) error {
 	var ret strings.Builder
@@ -2264,13 +2960,108 @@ type controlBlock struct {
 	op Opcode
 }
 
+// labelTypes returns the types of the values which branches to this block carry.
+func (c *controlBlock) labelTypes() []ValueType {
+	if c.op == OpcodeLoop {
+		return c.blockType.Params
+	}
+	return c.blockType.Results
+}
+
+// validateCatchClause ensures the label of the catch clause is in range, and its type matches the values the clause
+// branches with.
+func (m *Module) validateCatchClause(c *CatchClause, controlBlockStack *controlBlockStack) error {
+	if int(c.Label) >= len(controlBlockStack.stack) {
+		return fmt.Errorf("label index out of range: %d", c.Label)
+	}
+	target := &controlBlockStack.stack[len(controlBlockStack.stack)-int(c.Label)-1]
+	var labelTypes []ValueType
+	if target.op == OpcodeLoop {
+		labelTypes = target.blockType.Params
+	} else {
+		labelTypes = target.blockType.Results
+	}
+
+	var want []ValueType
+	switch c.Kind {
+	case CatchKindCatch, CatchKindCatchRef:
+		tagType := m.TagType(c.Tag)
+		if tagType == nil {
+			return fmt.Errorf("invalid tag index %d", c.Tag)
+		}
+		want = tagType.Params
+		if c.Kind == CatchKindCatchRef {
+			want = append(want[:len(want):len(want)], ValueTypeExnref)
+		}
+	case CatchKindCatchAllRef:
+		want = []ValueType{ValueTypeExnref}
+	}
+	if !bytes.Equal(labelTypes, want) {
+		var have, expected strings.Builder
+		writeValueTypes(want, &have)
+		writeValueTypes(labelTypes, &expected)
+		return fmt.Errorf("type mismatch: have (%s), but the label wants (%s)", have.String(), expected.String())
+	}
+	return nil
+}
+
+// CatchClause is a catch clause of OpcodeTryTable.
+type CatchClause struct {
+	// Kind is one of CatchKindCatch, CatchKindCatchRef, CatchKindCatchAll or CatchKindCatchAllRef.
+	Kind byte
+	// Tag is the tag index matched by CatchKindCatch and CatchKindCatchRef.
+	Tag Index
+	// Label is the relative depth of the branch target, counted from outside the try_table.
+	Label Index
+}
+
+// DecodeCatchClauses decodes the vector of catch clauses following the block type of OpcodeTryTable, and returns
+// them along with the number of bytes read.
+func DecodeCatchClauses(body []byte) ([]CatchClause, uint64, error) {
+	count, read, err := leb128.LoadUint32(body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read count: %w", err)
+	}
+	var ret []CatchClause
+	if count > 0 {
+		ret = make([]CatchClause, 0, count)
+	}
+	for i := uint32(0); i < count; i++ {
+		if read >= uint64(len(body)) {
+			return nil, 0, fmt.Errorf("unexpected end of catch clauses")
+		}
+		c := CatchClause{Kind: body[read]}
+		read++
+		switch c.Kind {
+		case CatchKindCatch, CatchKindCatchRef:
+			tag, num, err := leb128.LoadUint32(body[read:])
+			if err != nil {
+				return nil, 0, fmt.Errorf("read tag index: %w", err)
+			}
+			c.Tag = tag
+			read += num
+		case CatchKindCatchAll, CatchKindCatchAllRef:
+		default:
+			return nil, 0, fmt.Errorf("invalid catch clause kind: %#x", c.Kind)
+		}
+		label, num, err := leb128.LoadUint32(body[read:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("read label index: %w", err)
+		}
+		c.Label = label
+		read += num
+		ret = append(ret, c)
+	}
+	return ret, read, nil
+}
+
 // DecodeBlockType decodes the type index from a positive 33-bit signed integer. Negative numbers indicate up to one
 // WebAssembly 1.0 (20191205) compatible result type. Positive numbers are decoded when `enabledFeatures` include
 // CoreFeatureMultiValue and include an index in the Module.TypeSection.
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-blocktype
 // See https://github.com/WebAssembly/spec/blob/wg-2.0.draft1/proposals/multi-value/Overview.md
-func DecodeBlockType(types []FunctionType, r *bytes.Reader, enabledFeatures api.CoreFeatures) (*FunctionType, uint64, error) {
+func DecodeBlockType(m *Module, r *bytes.Reader, enabledFeatures api.CoreFeatures) (*FunctionType, uint64, error) {
 	raw, num, err := leb128.DecodeInt33AsInt64(r)
 	if err != nil {
 		return nil, 0, fmt.Errorf("decode int33: %w", err)
@@ -2294,28 +3085,90 @@ func DecodeBlockType(types []FunctionType, r *bytes.Reader, enabledFeatures api.
 		ret = blockType_v_funcref
 	case -17: // 0x6f in original byte = externref
 		ret = blockType_v_externref
+	case -23: // 0x69 in original byte = exnref
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err != nil {
+			return nil, num, fmt.Errorf("block with exnref result invalid as %v", err)
+		}
+		ret = blockType_v_exnref
+	case -29, -28: // 0x63 and 0x64 in original byte = (ref null ht) and (ref ht)
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesFunctionReferences); err != nil {
+			return nil, num, fmt.Errorf("block with typed reference result invalid as %v", err)
+		}
+		before := r.Len()
+		ht, err := DecodeHeapType(r, enabledFeatures)
+		if err != nil {
+			return nil, num, err
+		}
+		num += uint64(before - r.Len())
+		vt, err := m.HeapTypeValueType(ht)
+		if err != nil {
+			return nil, num, err
+		}
+		ret = referenceBlockType(vt)
+	case -13, -14, -15, -18, -19, -20, -21, -22: // 0x73 to 0x71 and 0x6e to 0x6a in original byte = GC shorthands
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return nil, num, fmt.Errorf("block with %s result invalid as %v", ValueTypeName(byte(raw&0x7f)), err)
+		}
+		ret = referenceBlockType(byte(raw & 0x7f))
 	default:
 		if err = enabledFeatures.RequireEnabled(api.CoreFeatureMultiValue); err != nil {
 			return nil, num, fmt.Errorf("block with function type return invalid as %v", err)
 		}
-		if raw < 0 || (raw >= int64(len(types))) {
+		if raw < 0 || (raw >= int64(len(m.TypeSection))) || !m.IsFunctionType(Index(raw)) {
 			return nil, 0, fmt.Errorf("type index out of range: %d", raw)
 		}
-		ret = &types[raw]
+		ret = &m.TypeSection[raw]
 	}
 	return ret, num, err
 }
 
+// referenceBlockType returns the block type whose result is the reference type.
+func referenceBlockType(vt ValueType) *FunctionType {
+	switch vt {
+	case ValueTypeFuncref:
+		return blockType_v_funcref
+	case ValueTypeExternref:
+		return blockType_v_externref
+	case ValueTypeExnref:
+		return blockType_v_exnref
+	case ValueTypeAnyref:
+		return blockType_v_anyref
+	case ValueTypeEqref:
+		return blockType_v_eqref
+	case ValueTypeI31ref:
+		return blockType_v_i31ref
+	case ValueTypeStructref:
+		return blockType_v_structref
+	case ValueTypeArrayref:
+		return blockType_v_arrayref
+	case ValueTypeNullref:
+		return blockType_v_nullref
+	case ValueTypeNullexternref:
+		return blockType_v_nullexternref
+	default: // ValueTypeNullfuncref
+		return blockType_v_nullfuncref
+	}
+}
+
 // These block types are defined as globals in order to avoid allocations in DecodeBlockType.
 var (
-	blockType_v_v         = &FunctionType{}
-	blockType_v_i32       = &FunctionType{Results: []ValueType{ValueTypeI32}, ResultNumInUint64: 1}
-	blockType_v_i64       = &FunctionType{Results: []ValueType{ValueTypeI64}, ResultNumInUint64: 1}
-	blockType_v_f32       = &FunctionType{Results: []ValueType{ValueTypeF32}, ResultNumInUint64: 1}
-	blockType_v_f64       = &FunctionType{Results: []ValueType{ValueTypeF64}, ResultNumInUint64: 1}
-	blockType_v_v128      = &FunctionType{Results: []ValueType{ValueTypeV128}, ResultNumInUint64: 2}
-	blockType_v_funcref   = &FunctionType{Results: []ValueType{ValueTypeFuncref}, ResultNumInUint64: 1}
-	blockType_v_externref = &FunctionType{Results: []ValueType{ValueTypeExternref}, ResultNumInUint64: 1}
+	blockType_v_v             = &FunctionType{}
+	blockType_v_i32           = &FunctionType{Results: []ValueType{ValueTypeI32}, ResultNumInUint64: 1}
+	blockType_v_i64           = &FunctionType{Results: []ValueType{ValueTypeI64}, ResultNumInUint64: 1}
+	blockType_v_f32           = &FunctionType{Results: []ValueType{ValueTypeF32}, ResultNumInUint64: 1}
+	blockType_v_f64           = &FunctionType{Results: []ValueType{ValueTypeF64}, ResultNumInUint64: 1}
+	blockType_v_v128          = &FunctionType{Results: []ValueType{ValueTypeV128}, ResultNumInUint64: 2}
+	blockType_v_funcref       = &FunctionType{Results: []ValueType{ValueTypeFuncref}, ResultNumInUint64: 1}
+	blockType_v_externref     = &FunctionType{Results: []ValueType{ValueTypeExternref}, ResultNumInUint64: 1}
+	blockType_v_exnref        = &FunctionType{Results: []ValueType{ValueTypeExnref}, ResultNumInUint64: 1}
+	blockType_v_anyref        = &FunctionType{Results: []ValueType{ValueTypeAnyref}, ResultNumInUint64: 1}
+	blockType_v_eqref         = &FunctionType{Results: []ValueType{ValueTypeEqref}, ResultNumInUint64: 1}
+	blockType_v_i31ref        = &FunctionType{Results: []ValueType{ValueTypeI31ref}, ResultNumInUint64: 1}
+	blockType_v_structref     = &FunctionType{Results: []ValueType{ValueTypeStructref}, ResultNumInUint64: 1}
+	blockType_v_arrayref      = &FunctionType{Results: []ValueType{ValueTypeArrayref}, ResultNumInUint64: 1}
+	blockType_v_nullref       = &FunctionType{Results: []ValueType{ValueTypeNullref}, ResultNumInUint64: 1}
+	blockType_v_nullexternref = &FunctionType{Results: []ValueType{ValueTypeNullexternref}, ResultNumInUint64: 1}
+	blockType_v_nullfuncref   = &FunctionType{Results: []ValueType{ValueTypeNullfuncref}, ResultNumInUint64: 1}
 )
 
 // SplitCallStack returns the input stack resliced to the count of params and
@@ -2334,3 +3187,38 @@ func SplitCallStack(ft *FunctionType, stack []uint64) (params []uint64, results
 	}
 	return
 }
+
+// validateRelaxedVectorInstruction validates the relaxed vector instruction of the given opcode. Relaxed vector
+// instructions don't have immediates, so this only verifies the types of the operands.
+func validateRelaxedVectorInstruction(op OpcodeVecRelaxed, enabledFeatures api.CoreFeatures, valueTypeStack *valueTypeStack) error {
+	name := RelaxedVectorInstructionName(op)
+	if name == "" {
+		return fmt.Errorf("invalid relaxed vector opcode %#x", op)
+	}
+	if err := enabledFeatures.RequireEnabled(api.CoreFeatureSIMD); err != nil {
+		return fmt.Errorf("%s invalid as %v", name, err)
+	}
+	if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesRelaxedSIMD); err != nil {
+		return fmt.Errorf("%s invalid as %v", name, err)
+	}
+
+	var operands int
+	switch op {
+	case OpcodeVecI32x4RelaxedTruncF32x4S, OpcodeVecI32x4RelaxedTruncF32x4U,
+		OpcodeVecI32x4RelaxedTruncF64x2SZero, OpcodeVecI32x4RelaxedTruncF64x2UZero:
+		operands = 1
+	case OpcodeVecI8x16RelaxedSwizzle, OpcodeVecF32x4RelaxedMin, OpcodeVecF32x4RelaxedMax,
+		OpcodeVecF64x2RelaxedMin, OpcodeVecF64x2RelaxedMax, OpcodeVecI16x8RelaxedQ15mulrS,
+		OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+		operands = 2
+	default: // madd, nmadd, laneselect and dot_add.
+		operands = 3
+	}
+	for i := 0; i < operands; i++ {
+		if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
+			return fmt.Errorf("cannot pop the operand for %s: %v", name, err)
+		}
+	}
+	valueTypeStack.push(ValueTypeV128)
+	return nil
+}
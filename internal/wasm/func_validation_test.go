@@ -302,7 +302,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 					DataCountSection: &c,
 				}
 				err := m.validateFunction(&stacks{}, api.CoreFeatureBulkMemoryOperations,
-					0, []Index{0}, nil, &Memory{}, []Table{{}, {}}, nil, bytes.NewReader(nil))
+					0, []Index{0}, nil, []*Memory{{}}, []Table{{}, {}}, nil, bytes.NewReader(nil))
 				require.NoError(t, err)
 			})
 		}
@@ -313,7 +313,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			dataSection         []DataSegment
 			elementSection      []ElementSegment
 			dataCountSectionNil bool
-			memory              *Memory
+			memories            []*Memory
 			tables              []Table
 			flag                api.CoreFeatures
 			expectedErr         string
@@ -322,7 +322,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      nil,
+				memories:    nil,
 				expectedErr: "memory must exist for memory.init",
 			},
 			{
@@ -339,48 +339,48 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "failed to read data segment index for memory.init: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 100 /* data section out of range */},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "index 100 out of range of data section(len=1)",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "failed to read memory index for memory.init: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "memory.init reserved byte must be zero encoded with 1 byte",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
@@ -393,20 +393,20 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:                []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop},
 				dataCountSectionNil: true,
-				memory:              &Memory{},
+				memories:            []*Memory{{}},
 				flag:                api.CoreFeatureBulkMemoryOperations,
 				expectedErr:         `data.drop requires data count section`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "failed to read data segment index for data.drop: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop, 100 /* data section out of range */},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "index 100 out of range of data section(len=1)",
 			},
@@ -414,7 +414,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      nil,
+				memories:    nil,
 				expectedErr: "memory must exist for memory.copy",
 			},
 			{
@@ -425,44 +425,44 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: `failed to read memory index for memory.copy: EOF`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "failed to read memory index for memory.copy: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "memory.copy reserved byte must be zero encoded with 1 byte",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			// memory.fill
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      nil,
+				memories:    nil,
 				expectedErr: "memory must exist for memory.fill",
 			},
 			{
@@ -473,31 +473,31 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: `failed to read memory index for memory.fill: EOF`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: `memory.fill reserved byte must be zero encoded with 1 byte`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			// table.init
@@ -666,7 +666,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 					c := uint32(0)
 					m.DataCountSection = &c
 				}
-				err := m.validateFunction(&stacks{}, tc.flag, 0, []Index{0}, nil, tc.memory, tc.tables, nil, bytes.NewReader(nil))
+				err := m.validateFunction(&stacks{}, tc.flag, 0, []Index{0}, nil, tc.memories, tc.tables, nil, bytes.NewReader(nil))
 				require.EqualError(t, err, tc.expectedErr)
 			})
 		}
@@ -2221,7 +2221,7 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 			}}},
 		}
 		err := m.validateFunction(&stacks{}, api.CoreFeatureReferenceTypes,
-			0, []Index{0}, nil, &Memory{}, []Table{{Type: RefTypeFuncref}}, nil, bytes.NewReader(nil))
+			0, []Index{0}, nil, []*Memory{{}}, []Table{{Type: RefTypeFuncref}}, nil, bytes.NewReader(nil))
 		require.NoError(t, err)
 	})
 	t.Run("non zero table index", func(t *testing.T) {
@@ -2236,12 +2236,12 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 		}
 		t.Run("disabled", func(t *testing.T) {
 			err := m.validateFunction(&stacks{}, api.CoreFeaturesV1,
-				0, []Index{0}, nil, &Memory{}, []Table{{}, {}}, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, []Table{{}, {}}, nil, bytes.NewReader(nil))
 			require.EqualError(t, err, "table index must be zero but was 100: feature \"reference-types\" is disabled")
 		})
 		t.Run("enabled but out of range", func(t *testing.T) {
 			err := m.validateFunction(&stacks{}, api.CoreFeatureReferenceTypes,
-				0, []Index{0}, nil, &Memory{}, []Table{{}, {}}, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, []Table{{}, {}}, nil, bytes.NewReader(nil))
 			require.EqualError(t, err, "unknown table index: 100")
 		})
 	})
@@ -2256,11 +2256,895 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 			}}},
 		}
 		err := m.validateFunction(&stacks{}, api.CoreFeatureReferenceTypes,
-			0, []Index{0}, nil, &Memory{}, []Table{{Type: RefTypeExternref}}, nil, bytes.NewReader(nil))
+			0, []Index{0}, nil, []*Memory{{}}, []Table{{Type: RefTypeExternref}}, nil, bytes.NewReader(nil))
 		require.EqualError(t, err, "table is not funcref type but was externref for call_indirect")
 	})
 }
 
+func TestModule_funcValidation_TailCall(t *testing.T) {
+	tests := []struct {
+		name        string
+		types       []FunctionType
+		body        []byte
+		features    api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name:  "return_call",
+			types: []FunctionType{i32_i32},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeTailCallReturnCall, 0,
+				OpcodeEnd,
+			},
+			features: experimental.CoreFeaturesTailCall,
+		},
+		{
+			name:  "return_call followed by unreachable code",
+			types: []FunctionType{i32_i32},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeTailCallReturnCall, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			features: experimental.CoreFeaturesTailCall,
+		},
+		{
+			name:  "return_call disabled",
+			types: []FunctionType{i32_i32},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeTailCallReturnCall, 0,
+				OpcodeEnd,
+			},
+			features:    api.CoreFeaturesV2,
+			expectedErr: `return_call invalid as feature "tail-call" is disabled`,
+		},
+		{
+			name:  "return_call param mismatch",
+			types: []FunctionType{i32_i32},
+			body: []byte{
+				OpcodeTailCallReturnCall, 0,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesTailCall,
+			expectedErr: "type mismatch on return_call operation param type: i32 missing",
+		},
+		{
+			name:  "return_call result mismatch",
+			types: []FunctionType{i32_i32, i32_v},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeTailCallReturnCall, 1,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesTailCall,
+			expectedErr: "type mismatch on tail call: callee type i32_v has different results than the function type i32_i32",
+		},
+		{
+			name:  "return_call_indirect",
+			types: []FunctionType{i32_i32},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeI32Const, 0,
+				OpcodeTailCallReturnCallIndirect, 0, 0,
+				OpcodeEnd,
+			},
+			features: experimental.CoreFeaturesTailCall,
+		},
+		{
+			name:  "return_call_indirect disabled",
+			types: []FunctionType{i32_i32},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeI32Const, 0,
+				OpcodeTailCallReturnCallIndirect, 0, 0,
+				OpcodeEnd,
+			},
+			features:    api.CoreFeaturesV2,
+			expectedErr: `return_call_indirect invalid as feature "tail-call" is disabled`,
+		},
+		{
+			name:  "return_call_indirect result mismatch",
+			types: []FunctionType{i32_i32, i32_v},
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeI32Const, 0,
+				OpcodeTailCallReturnCallIndirect, 1, 0,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesTailCall,
+			expectedErr: "type mismatch on tail call: callee type i32_v has different results than the function type i32_i32",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     tc.types,
+				FunctionSection: []Index{0},
+				CodeSection:     []Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(&stacks{}, tc.features,
+				0, []Index{0, 1}, nil, nil, []Table{{Type: RefTypeFuncref}}, nil, bytes.NewReader(nil))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_FunctionReferences(t *testing.T) {
+	features := api.CoreFeaturesV2 | experimental.CoreFeaturesFunctionReferences
+	tests := []struct {
+		name        string
+		body        []byte
+		features    api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name: "call_ref",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeRefFunc, 0,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "call_ref disabled",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeRefFunc, 0,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+			},
+			features:    api.CoreFeaturesV2,
+			expectedErr: `call_ref invalid as feature "function-references" is disabled`,
+		},
+		{
+			name: "call_ref without reference",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeCallRef, 0,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: "cannot pop the function reference for call_ref: type mismatch: expected funcref, but was i32",
+		},
+		{
+			name: "call_ref non function type",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeRefFunc, 0,
+				OpcodeCallRef, 1,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: "invalid type index at call_ref: 1",
+		},
+		{
+			name: "return_call_ref",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeRefFunc, 0,
+				OpcodeReturnCallRef, 0,
+				OpcodeEnd,
+			},
+			features: features | experimental.CoreFeaturesTailCall,
+		},
+		{
+			name: "return_call_ref without tail call",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeRefFunc, 0,
+				OpcodeReturnCallRef, 0,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: `return_call_ref invalid as feature "tail-call" is disabled`,
+		},
+		{
+			name: "ref.as_non_null",
+			body: []byte{
+				OpcodeRefFunc, 0,
+				OpcodeRefAsNonNull,
+				OpcodeDrop,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "br_on_null",
+			body: []byte{
+				OpcodeBlock, 0x40,
+				OpcodeRefFunc, 0,
+				OpcodeBrOnNull, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "br_on_non_null",
+			body: []byte{
+				OpcodeBlock, ValueTypeFuncref,
+				OpcodeRefFunc, 0,
+				OpcodeBrOnNonNull, 0,
+				OpcodeRefNull, ValueTypeFuncref,
+				OpcodeEnd,
+				OpcodeDrop,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "br_on_non_null without reference result",
+			body: []byte{
+				OpcodeBlock, 0x40,
+				OpcodeRefFunc, 0,
+				OpcodeBrOnNonNull, 0,
+				OpcodeEnd,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: "type mismatch: the label of br_on_non_null must have a reference type result",
+		},
+		{
+			name: "typed select",
+			body: []byte{
+				OpcodeRefFunc, 0,
+				OpcodeRefNull, byte(HeapTypeNoFunc & 0x7f),
+				OpcodeLocalGet, 0,
+				OpcodeTypedSelect, 1, ValueTypePrefixRef, 0,
+				OpcodeDrop,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features: features,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []FunctionType{i32_i32, {}},
+				CompositeTypes:  []CompositeType{{Kind: CompositeTypeKindFunc}, {Kind: CompositeTypeKindStruct}},
+				FunctionSection: []Index{0},
+				CodeSection:     []Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(&stacks{}, tc.features,
+				0, []Index{0}, nil, nil, nil, map[Index]struct{}{0: {}}, bytes.NewReader(nil))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_GC(t *testing.T) {
+	features := api.CoreFeaturesV2 | experimental.CoreFeaturesGC
+	// The type 0 is (struct (field (mut i32)) (field i64)), 1 is (array (mut i8)), 2 is (array i32),
+	// and 3 is the type of the function, i.e. (i32) -> i32.
+	m := &Module{
+		TypeSection: []FunctionType{{}, {}, {}, i32_i32},
+		CompositeTypes: []CompositeType{
+			{Kind: CompositeTypeKindStruct, Fields: []FieldType{{StorageType: ValueTypeI32, Mutable: true}, {StorageType: ValueTypeI64}}},
+			{Kind: CompositeTypeKindArray, Fields: []FieldType{{StorageType: StorageTypeI8, Mutable: true}}, RecGroup: 1},
+			{Kind: CompositeTypeKindArray, Fields: []FieldType{{StorageType: ValueTypeI32}}, RecGroup: 2},
+			{Kind: CompositeTypeKindFunc, RecGroup: 3},
+		},
+		FunctionSection: []Index{3},
+	}
+	tests := []struct {
+		name        string
+		body        []byte
+		features    api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name: "struct",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeI64Const, 1,
+				OpcodeGCPrefix, byte(OpcodeGCStructNew), 0,
+				OpcodeGCPrefix, byte(OpcodeGCStructGet), 0, 0,
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "struct disabled",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeI64Const, 1,
+				OpcodeGCPrefix, byte(OpcodeGCStructNew), 0,
+				OpcodeGCPrefix, byte(OpcodeGCStructGet), 0, 0,
+				OpcodeEnd,
+			},
+			features:    api.CoreFeaturesV2,
+			expectedErr: `gc_prefix invalid as feature "gc" is disabled`,
+		},
+		{
+			name: "struct.new type mismatch",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeLocalGet, 0,
+				OpcodeGCPrefix, byte(OpcodeGCStructNew), 0,
+				OpcodeDrop,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: "cannot pop the operand for struct.new: type mismatch: expected i64, but was i32",
+		},
+		{
+			name: "struct.set immutable",
+			body: []byte{
+				OpcodeGCPrefix, byte(OpcodeGCStructNewDefault), 0,
+				OpcodeI64Const, 1,
+				OpcodeGCPrefix, byte(OpcodeGCStructSet), 0, 1,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: "struct.set to immutable field",
+		},
+		{
+			name: "struct.get_s non packed",
+			body: []byte{
+				OpcodeGCPrefix, byte(OpcodeGCStructNewDefault), 0,
+				OpcodeGCPrefix, byte(OpcodeGCStructGetS), 0, 0,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: "struct.get_s must be used for packed fields",
+		},
+		{
+			name: "struct.new non struct type",
+			body: []byte{
+				OpcodeGCPrefix, byte(OpcodeGCStructNewDefault), 1,
+				OpcodeDrop,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: "invalid type for struct.new_default: type 1 is not a struct type",
+		},
+		{
+			name: "array",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeGCPrefix, byte(OpcodeGCArrayNewDefault), 1,
+				OpcodeI32Const, 0,
+				OpcodeGCPrefix, byte(OpcodeGCArrayGetU), 1,
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "array.len",
+			body: []byte{
+				OpcodeI32Const, 1,
+				OpcodeI32Const, 2,
+				OpcodeGCPrefix, byte(OpcodeGCArrayNewFixed), 2, 2,
+				OpcodeGCPrefix, byte(OpcodeGCArrayLen),
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "array.set immutable",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeGCPrefix, byte(OpcodeGCArrayNewDefault), 2,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeGCPrefix, byte(OpcodeGCArraySet), 2,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: "array.set to immutable field",
+		},
+		{
+			name: "i31",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeGCPrefix, byte(OpcodeGCRefI31),
+				OpcodeGCPrefix, byte(OpcodeGCI31GetS),
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "ref.test and ref.cast",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeGCPrefix, byte(OpcodeGCRefI31),
+				OpcodeGCPrefix, byte(OpcodeGCRefCast), byte(HeapTypeEq & 0x7f),
+				OpcodeGCPrefix, byte(OpcodeGCRefTestNull), 0,
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "br_on_cast",
+			body: []byte{
+				OpcodeBlock, ValueTypeStructref,
+				OpcodeGCPrefix, byte(OpcodeGCStructNewDefault), 0,
+				OpcodeGCPrefix, byte(OpcodeGCBrOnCast), 0b11, 0, byte(HeapTypeStruct & 0x7f), 0,
+				OpcodeEnd,
+				OpcodeDrop,
+				OpcodeLocalGet, 0,
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "extern conversions",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeGCPrefix, byte(OpcodeGCRefI31),
+				OpcodeGCPrefix, byte(OpcodeGCExternConvertAny),
+				OpcodeGCPrefix, byte(OpcodeGCAnyConvertExtern),
+				OpcodeGCPrefix, byte(OpcodeGCRefTest), byte(HeapTypeI31 & 0x7f),
+				OpcodeEnd,
+			},
+			features: features,
+		},
+		{
+			name: "unknown gc instruction",
+			body: []byte{
+				OpcodeGCPrefix, 0x1f,
+				OpcodeEnd,
+			},
+			features:    features,
+			expectedErr: "invalid gc instruction 0x1f",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m.CodeSection = []Code{{Body: tc.body}}
+			err := m.validateFunction(&stacks{}, tc.features,
+				0, []Index{3}, nil, nil, nil, nil, bytes.NewReader(nil))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_ExceptionHandling(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		features    api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name: "try_table catch",
+			body: []byte{
+				OpcodeBlock, ValueTypeI32,
+				OpcodeTryTable, 0x40, 1, CatchKindCatch, 0, 0,
+				OpcodeLocalGet, 0,
+				OpcodeThrow, 0,
+				OpcodeEnd,
+				OpcodeI32Const, 0,
+				OpcodeEnd,
+				OpcodeEnd,
+			},
+			features: experimental.CoreFeaturesExceptionHandling,
+		},
+		{
+			name: "try_table catch_ref and throw_ref",
+			body: []byte{
+				OpcodeBlock, 0x2, // (i32, exnref) as the type index 2
+				OpcodeTryTable, 0x40, 1, CatchKindCatchRef, 0, 0,
+				OpcodeLocalGet, 0,
+				OpcodeThrow, 0,
+				OpcodeEnd,
+				OpcodeUnreachable,
+				OpcodeEnd,
+				OpcodeThrowRef,
+				OpcodeEnd,
+			},
+			features: api.CoreFeatureMultiValue | experimental.CoreFeaturesExceptionHandling,
+		},
+		{
+			name: "try_table catch_all",
+			body: []byte{
+				OpcodeBlock, 0x40,
+				OpcodeTryTable, 0x40, 1, CatchKindCatchAll, 0,
+				OpcodeLocalGet, 0,
+				OpcodeThrow, 0,
+				OpcodeEnd,
+				OpcodeEnd,
+				OpcodeI32Const, 0,
+				OpcodeEnd,
+			},
+			features: experimental.CoreFeaturesExceptionHandling,
+		},
+		{
+			name: "try_table disabled",
+			body: []byte{
+				OpcodeTryTable, 0x40, 0,
+				OpcodeEnd,
+				OpcodeI32Const, 0,
+				OpcodeEnd,
+			},
+			features:    api.CoreFeaturesV2,
+			expectedErr: `try_table invalid as feature "exception-handling" is disabled`,
+		},
+		{
+			name: "try_table label out of range",
+			body: []byte{
+				OpcodeTryTable, 0x40, 1, CatchKindCatchAll, 1,
+				OpcodeEnd,
+				OpcodeI32Const, 0,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesExceptionHandling,
+			expectedErr: "invalid catch clause[0] for try_table: label index out of range: 1",
+		},
+		{
+			name: "try_table label type mismatch",
+			body: []byte{
+				OpcodeBlock, 0x40,
+				OpcodeTryTable, 0x40, 1, CatchKindCatch, 0, 0,
+				OpcodeEnd,
+				OpcodeEnd,
+				OpcodeI32Const, 0,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesExceptionHandling,
+			expectedErr: "invalid catch clause[0] for try_table: type mismatch: have (i32), but the label wants ()",
+		},
+		{
+			name: "try_table invalid tag",
+			body: []byte{
+				OpcodeBlock, ValueTypeI32,
+				OpcodeTryTable, 0x40, 1, CatchKindCatch, 1, 0,
+				OpcodeEnd,
+				OpcodeI32Const, 0,
+				OpcodeEnd,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesExceptionHandling,
+			expectedErr: "invalid catch clause[0] for try_table: invalid tag index 1",
+		},
+		{
+			name: "throw disabled",
+			body: []byte{
+				OpcodeLocalGet, 0,
+				OpcodeThrow, 0,
+				OpcodeEnd,
+			},
+			features:    api.CoreFeaturesV2,
+			expectedErr: `throw invalid as feature "exception-handling" is disabled`,
+		},
+		{
+			name: "throw invalid tag",
+			body: []byte{
+				OpcodeThrow, 1,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesExceptionHandling,
+			expectedErr: "invalid tag index 1 for throw",
+		},
+		{
+			name: "throw param mismatch",
+			body: []byte{
+				OpcodeThrow, 0,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesExceptionHandling,
+			expectedErr: "not enough params for throw block\n\thave ()\n\twant (i32)",
+		},
+		{
+			name: "throw_ref disabled",
+			body: []byte{
+				OpcodeRefNull, RefTypeExternref,
+				OpcodeThrowRef,
+				OpcodeEnd,
+			},
+			features:    api.CoreFeaturesV2,
+			expectedErr: `throw_ref invalid as feature "exception-handling" is disabled`,
+		},
+		{
+			name: "throw_ref type mismatch",
+			body: []byte{
+				OpcodeRefNull, RefTypeExternref,
+				OpcodeThrowRef,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesExceptionHandling,
+			expectedErr: "cannot pop the operand for throw_ref: type mismatch: expected exnref, but was externref",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []FunctionType{i32_i32, i32_v, initFt(nil, []ValueType{i32, ValueTypeExnref})},
+				FunctionSection: []Index{0},
+				TagSection:      []Index{1},
+				CodeSection:     []Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|tc.features,
+				0, []Index{0}, nil, nil, nil, nil, bytes.NewReader(nil))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_MultiMemory(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		features    api.CoreFeatures
+		expectedErr string
+	}{
+		{
+			name: "load and store with memory index",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2 | MemArgMemoryIndexFlag, 1, 0, // memory 1, align 2, offset 0
+				OpcodeI32Store, 0x2 | MemArgMemoryIndexFlag, 1, 0, // memory 1, align 2, offset 0
+				OpcodeEnd,
+			},
+			features: experimental.CoreFeaturesMultiMemory,
+		},
+		{
+			name: "load with unknown memory index",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2 | MemArgMemoryIndexFlag, 2, 0, // memory 2, align 2, offset 0
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesMultiMemory,
+			expectedErr: "unknown memory 2",
+		},
+		{
+			name: "load with memory index disabled",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2 | MemArgMemoryIndexFlag, 1, 0, // memory 1, align 2, offset 0
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			expectedErr: "invalid memory alignment",
+		},
+		{
+			name: "memory.size and memory.grow",
+			body: []byte{
+				OpcodeMemorySize, 1,
+				OpcodeMemoryGrow, 1,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			features: experimental.CoreFeaturesMultiMemory,
+		},
+		{
+			name: "memory.size unknown memory",
+			body: []byte{
+				OpcodeMemorySize, 2,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesMultiMemory,
+			expectedErr: "unknown memory 2 for memory.size",
+		},
+		{
+			name: "memory.size memory index disabled",
+			body: []byte{
+				OpcodeMemorySize, 1,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			expectedErr: "memory instruction reserved bytes not zero with 1 byte",
+		},
+		{
+			name: "memory.copy between memories",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 1, 0,
+				OpcodeEnd,
+			},
+			features: experimental.CoreFeaturesMultiMemory,
+		},
+		{
+			name: "memory.fill unknown memory",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryFill, 2,
+				OpcodeEnd,
+			},
+			features:    experimental.CoreFeaturesMultiMemory,
+			expectedErr: "unknown memory 2 for memory.fill",
+		},
+		{
+			name: "memory.copy memory index disabled",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1,
+				OpcodeEnd,
+			},
+			expectedErr: "memory.copy reserved byte must be zero encoded with 1 byte",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []FunctionType{v_v},
+				FunctionSection: []Index{0},
+				CodeSection:     []Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|tc.features,
+				0, []Index{0}, nil, []*Memory{{}, {}}, nil, nil, bytes.NewReader(nil))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_funcValidation_Memory64(t *testing.T) {
+	one := uint32(1)
+	tests := []struct {
+		name        string
+		body        []byte
+		expectedErr string
+	}{
+		{
+			name: "load and store with i64 address",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI64Const, 0,
+				OpcodeI32Load, 0x2, 0x80, 0x80, 0x80, 0x80, 0x10, // align 2, offset 1<<32
+				OpcodeI32Store, 0x2, 0,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "load with i32 address",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			expectedErr: "type mismatch: expected i64, but was i32",
+		},
+		{
+			name: "load from 32-bit memory with offset over 32 bits",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2 | MemArgMemoryIndexFlag, 1, 0x80, 0x80, 0x80, 0x80, 0x10, // memory 1, align 2, offset 1<<32
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			expectedErr: "read memory offset: overflows a 32-bit integer",
+		},
+		{
+			name: "memory.size and memory.grow",
+			body: []byte{
+				OpcodeMemorySize, 0,
+				OpcodeMemoryGrow, 0,
+				OpcodeI64Const, 0,
+				OpcodeI64Eq,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.grow with i32 delta",
+			body: []byte{
+				OpcodeI32Const, 1,
+				OpcodeMemoryGrow, 0,
+				OpcodeDrop,
+				OpcodeEnd,
+			},
+			expectedErr: "type mismatch: expected i64, but was i32",
+		},
+		{
+			name: "memory.init",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.fill",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI64Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.copy from 32-bit to 64-bit memory",
+			body: []byte{
+				OpcodeI64Const, 0, // 64-bit destination
+				OpcodeI32Const, 0, // 32-bit source
+				OpcodeI32Const, 0, // the size is i32 unless both are 64-bit.
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.copy with i64 size between mixed memories",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Const, 0,
+				OpcodeI64Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1,
+				OpcodeEnd,
+			},
+			expectedErr: "cannot pop the operand for memory.copy: type mismatch: expected i32, but was i64",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:      []FunctionType{v_v},
+				FunctionSection:  []Index{0},
+				CodeSection:      []Code{{Body: tc.body}},
+				DataSection:      []DataSegment{{}},
+				DataCountSection: &one,
+			}
+			err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|experimental.CoreFeaturesMultiMemory|experimental.CoreFeaturesMemory64,
+				0, []Index{0}, nil, []*Memory{{Is64: true}, {}}, nil, nil, bytes.NewReader(nil))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestModule_funcValidation_RefTypes(t *testing.T) {
 	tests := []struct {
 		name                    string
@@ -3178,7 +4062,7 @@ func TestModule_funcValidation_SIMD(t *testing.T) {
 				CodeSection:     []Code{{Body: tc.body}},
 			}
 			err := m.validateFunction(&stacks{}, api.CoreFeatureSIMD,
-				0, []Index{0}, nil, &Memory{}, nil, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, nil, nil, bytes.NewReader(nil))
 			require.NoError(t, err)
 		})
 	}
@@ -3324,12 +4208,84 @@ func TestModule_funcValidation_SIMD_error(t *testing.T) {
 				CodeSection:     []Code{{Body: tc.body}},
 			}
 			err := m.validateFunction(&stacks{}, tc.flag,
-				0, []Index{0}, nil, &Memory{}, nil, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, nil, nil, bytes.NewReader(nil))
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
 }
 
+func TestModule_funcValidation_RelaxedSIMD(t *testing.T) {
+	const features = api.CoreFeaturesV2 | experimental.CoreFeaturesRelaxedSIMD
+	relaxed := func(op OpcodeVecRelaxed) []byte {
+		return []byte{OpcodeVecPrefix, byte(op&0x7f) | 0x80, byte(op >> 7)}
+	}
+	v128Const := []byte{OpcodeVecPrefix, OpcodeVecV128Const, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	for _, tc := range []struct {
+		op       OpcodeVecRelaxed
+		operands int
+	}{
+		{op: OpcodeVecI8x16RelaxedSwizzle, operands: 2},
+		{op: OpcodeVecI32x4RelaxedTruncF32x4S, operands: 1},
+		{op: OpcodeVecI32x4RelaxedTruncF32x4U, operands: 1},
+		{op: OpcodeVecI32x4RelaxedTruncF64x2SZero, operands: 1},
+		{op: OpcodeVecI32x4RelaxedTruncF64x2UZero, operands: 1},
+		{op: OpcodeVecF32x4RelaxedMadd, operands: 3},
+		{op: OpcodeVecF32x4RelaxedNmadd, operands: 3},
+		{op: OpcodeVecF64x2RelaxedMadd, operands: 3},
+		{op: OpcodeVecF64x2RelaxedNmadd, operands: 3},
+		{op: OpcodeVecI8x16RelaxedLaneselect, operands: 3},
+		{op: OpcodeVecI16x8RelaxedLaneselect, operands: 3},
+		{op: OpcodeVecI32x4RelaxedLaneselect, operands: 3},
+		{op: OpcodeVecI64x2RelaxedLaneselect, operands: 3},
+		{op: OpcodeVecF32x4RelaxedMin, operands: 2},
+		{op: OpcodeVecF32x4RelaxedMax, operands: 2},
+		{op: OpcodeVecF64x2RelaxedMin, operands: 2},
+		{op: OpcodeVecF64x2RelaxedMax, operands: 2},
+		{op: OpcodeVecI16x8RelaxedQ15mulrS, operands: 2},
+		{op: OpcodeVecI16x8RelaxedDotI8x16I7x16S, operands: 2},
+		{op: OpcodeVecI32x4RelaxedDotI8x16I7x16AddS, operands: 3},
+	} {
+		tc := tc
+		name := RelaxedVectorInstructionName(tc.op)
+		t.Run(name, func(t *testing.T) {
+			var body []byte
+			for i := 0; i < tc.operands; i++ {
+				body = append(body, v128Const...)
+			}
+			body = append(body, relaxed(tc.op)...)
+			body = append(body, OpcodeDrop, OpcodeEnd)
+
+			m := &Module{
+				TypeSection:     []FunctionType{v_v},
+				FunctionSection: []Index{0},
+				CodeSection:     []Code{{Body: body}},
+			}
+			err := m.validateFunction(&stacks{}, features, 0, []Index{0}, nil, nil, nil, nil, bytes.NewReader(nil))
+			require.NoError(t, err)
+
+			err = m.validateFunction(&stacks{}, api.CoreFeaturesV2, 0, []Index{0}, nil, nil, nil, nil, bytes.NewReader(nil))
+			require.EqualError(t, err, name+" invalid as feature \"relaxed-simd\" is disabled")
+
+			// Missing one operand.
+			m.CodeSection[0].Body = body[len(v128Const):]
+			err = m.validateFunction(&stacks{}, features, 0, []Index{0}, nil, nil, nil, nil, bytes.NewReader(nil))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "cannot pop the operand for "+name)
+		})
+	}
+
+	t.Run("invalid opcode", func(t *testing.T) {
+		m := &Module{
+			TypeSection:     []FunctionType{v_v},
+			FunctionSection: []Index{0},
+			CodeSection:     []Code{{Body: append(relaxed(0x114), OpcodeEnd)}},
+		}
+		err := m.validateFunction(&stacks{}, features, 0, []Index{0}, nil, nil, nil, nil, bytes.NewReader(nil))
+		require.EqualError(t, err, "invalid relaxed vector opcode 0x114")
+	})
+}
+
 func TestDecodeBlockType(t *testing.T) {
 	t.Run("primitive", func(t *testing.T) {
 		for _, tc := range []struct {
@@ -3374,12 +4330,43 @@ func TestDecodeBlockType(t *testing.T) {
 		}
 		for index := range types {
 			expected := &types[index]
-			actual, read, err := DecodeBlockType(types, bytes.NewReader([]byte{byte(index)}), api.CoreFeatureMultiValue)
+			actual, read, err := DecodeBlockType(&Module{TypeSection: types}, bytes.NewReader([]byte{byte(index)}), api.CoreFeatureMultiValue)
 			require.NoError(t, err)
 			require.Equal(t, uint64(1), read)
 			require.Equal(t, expected, actual)
 		}
 	})
+	t.Run("reference type", func(t *testing.T) {
+		m := &Module{
+			TypeSection:    []FunctionType{{}, {}},
+			CompositeTypes: []CompositeType{{Kind: CompositeTypeKindFunc}, {Kind: CompositeTypeKindStruct}},
+		}
+		features := api.CoreFeaturesV2 | experimental.CoreFeaturesFunctionReferences | experimental.CoreFeaturesGC
+		for _, tc := range []struct {
+			name string
+			in   []byte
+			exp  ValueType
+		}{
+			{name: "(ref null func)", in: []byte{ValueTypePrefixRefNull, 0x70}, exp: ValueTypeFuncref},
+			{name: "(ref 0)", in: []byte{ValueTypePrefixRef, 0}, exp: ValueTypeFuncref},
+			{name: "(ref null 1)", in: []byte{ValueTypePrefixRefNull, 1}, exp: ValueTypeStructref},
+			{name: "anyref", in: []byte{ValueTypeAnyref}, exp: ValueTypeAnyref},
+			{name: "nullfuncref", in: []byte{ValueTypeNullfuncref}, exp: ValueTypeNullfuncref},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				actual, read, err := DecodeBlockType(m, bytes.NewReader(tc.in), features)
+				require.NoError(t, err)
+				require.Equal(t, uint64(len(tc.in)), read)
+				require.Equal(t, []ValueType{tc.exp}, actual.Results)
+			})
+		}
+
+		_, _, err := DecodeBlockType(m, bytes.NewReader([]byte{ValueTypePrefixRef, 2}), features)
+		require.EqualError(t, err, "unknown type 2")
+		_, _, err = DecodeBlockType(m, bytes.NewReader([]byte{ValueTypeAnyref}), api.CoreFeaturesV2)
+		require.EqualError(t, err, "block with anyref result invalid as feature \"gc\" is disabled")
+	})
 }
 
 // TestFuncValidation_UnreachableBrTable_NotModifyTypes ensures that we do not modify the
@@ -4272,7 +5259,7 @@ func TestModule_funcValidation_Atomic(t *testing.T) {
 
 				t.Run("with memory", func(t *testing.T) {
 					err := m.validateFunction(&stacks{}, experimental.CoreFeaturesThreads,
-						0, []Index{0}, nil, &Memory{}, []Table{}, nil, bytes.NewReader(nil))
+						0, []Index{0}, nil, []*Memory{{}}, []Table{}, nil, bytes.NewReader(nil))
 					require.NoError(t, err)
 				})
 
@@ -4301,7 +5288,7 @@ func TestModule_funcValidation_Atomic(t *testing.T) {
 			CodeSection:     []Code{{Body: body}},
 		}
 		err := m.validateFunction(&stacks{}, experimental.CoreFeaturesThreads,
-			0, []Index{0}, nil, &Memory{}, []Table{}, nil, bytes.NewReader(nil))
+			0, []Index{0}, nil, []*Memory{{}}, []Table{}, nil, bytes.NewReader(nil))
 		require.Error(t, err, "invalid immediate value for atomic.fence")
 	})
 
@@ -4864,7 +5851,7 @@ func TestModule_funcValidation_Atomic(t *testing.T) {
 					CodeSection:     []Code{{Body: body}},
 				}
 				err := m.validateFunction(&stacks{}, experimental.CoreFeaturesThreads,
-					0, []Index{0}, nil, &Memory{}, []Table{}, nil, bytes.NewReader(nil))
+					0, []Index{0}, nil, []*Memory{{}}, []Table{}, nil, bytes.NewReader(nil))
 				require.Error(t, err, "invalid memory alignment")
 			})
 		}
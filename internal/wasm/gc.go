@@ -0,0 +1,428 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/leb128"
+)
+
+// HeapType is the heap type of a reference type, which is introduced by experimental.CoreFeaturesFunctionReferences.
+// A non-negative value is the index of a type in Module.TypeSection, and a negative one is an abstract heap type.
+//
+// Note: wazero represents a reference type as the ValueType of its abstract heap type, or as ValueTypeFuncref,
+// ValueTypeStructref or ValueTypeArrayref for a concrete function, struct or array type. Nullability isn't tracked
+// either. This makes validation more permissive than the specification, so the instructions which depend on a
+// concrete type check the referenced value at runtime instead.
+type HeapType int64
+
+const (
+	// HeapTypeNoFunc is the bottom type of HeapTypeFunc.
+	HeapTypeNoFunc HeapType = -0x0d
+	// HeapTypeNoExtern is the bottom type of HeapTypeExtern.
+	HeapTypeNoExtern HeapType = -0x0e
+	// HeapTypeNone is the bottom type of HeapTypeAny.
+	HeapTypeNone HeapType = -0x0f
+	// HeapTypeFunc is the top type of functions.
+	HeapTypeFunc HeapType = -0x10
+	// HeapTypeExtern is the top type of host references.
+	HeapTypeExtern HeapType = -0x11
+	// HeapTypeAny is the top type of internal objects.
+	HeapTypeAny HeapType = -0x12
+	// HeapTypeEq is the type of internal objects which can be compared with ref.eq.
+	HeapTypeEq HeapType = -0x13
+	// HeapTypeI31 is the type of unboxed 31-bit integers.
+	HeapTypeI31 HeapType = -0x14
+	// HeapTypeStruct is the top type of structs.
+	HeapTypeStruct HeapType = -0x15
+	// HeapTypeArray is the top type of arrays.
+	HeapTypeArray HeapType = -0x16
+	// HeapTypeExn is the type of exceptions.
+	HeapTypeExn HeapType = -0x17
+)
+
+const (
+	// ValueTypePrefixRefNull is the prefix of the nullable reference type "(ref null ht)".
+	ValueTypePrefixRefNull = 0x63
+	// ValueTypePrefixRef is the prefix of the non-nullable reference type "(ref ht)".
+	ValueTypePrefixRef = 0x64
+)
+
+// abstractHeapTypeValueType returns the ValueType of the abstract heap type, or false if ht is not abstract.
+func abstractHeapTypeValueType(ht HeapType) (ValueType, bool) {
+	switch ht {
+	case HeapTypeFunc, HeapTypeExtern, HeapTypeExn, HeapTypeNoFunc, HeapTypeNoExtern, HeapTypeNone,
+		HeapTypeAny, HeapTypeEq, HeapTypeI31, HeapTypeStruct, HeapTypeArray:
+		// The shorthand of an abstract heap type is the lower 7 bits of its encoding.
+		return ValueType(ht & 0x7f), true
+	}
+	return 0, false
+}
+
+// DecodeHeapType decodes the heap type encoded as a 33-bit signed integer.
+func DecodeHeapType(r *bytes.Reader, enabledFeatures api.CoreFeatures) (HeapType, error) {
+	raw, _, err := leb128.DecodeInt33AsInt64(r)
+	if err != nil {
+		return 0, fmt.Errorf("read heap type: %w", err)
+	}
+	ht := HeapType(raw)
+	if ht >= 0 {
+		return ht, nil
+	}
+	if _, ok := abstractHeapTypeValueType(ht); !ok {
+		return 0, fmt.Errorf("invalid heap type: %d", ht)
+	}
+	if IsGCHeapType(ht) {
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return 0, fmt.Errorf("heap type %s invalid as %w", ValueTypeName(ValueType(ht&0x7f)), err)
+		}
+	}
+	return ht, nil
+}
+
+// DecodeValueType decodes a value type, including the reference types of experimental.CoreFeaturesFunctionReferences.
+// The returned HeapType is the heap type of a reference type. When it refers to a concrete type, the returned
+// ValueType is zero and must be resolved with Module.HeapTypeValueType.
+func DecodeValueType(r *bytes.Reader, enabledFeatures api.CoreFeatures) (ValueType, HeapType, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch b {
+	case ValueTypeI32, ValueTypeF32, ValueTypeI64, ValueTypeF64, ValueTypeV128:
+		return b, 0, nil
+	case ValueTypeExternref, ValueTypeFuncref, ValueTypeExnref:
+		return b, HeapType(int8(b | 0x80)), nil
+	case ValueTypeAnyref, ValueTypeEqref, ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref,
+		ValueTypeNullref, ValueTypeNullexternref, ValueTypeNullfuncref:
+		if enabledFeatures.IsEnabled(experimental.CoreFeaturesGC) {
+			return b, HeapType(int8(b | 0x80)), nil
+		}
+	case ValueTypePrefixRefNull, ValueTypePrefixRef:
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesFunctionReferences); err != nil {
+			return 0, 0, fmt.Errorf("typed reference invalid as %w", err)
+		}
+		ht, err := DecodeHeapType(r, enabledFeatures)
+		if err != nil {
+			return 0, 0, err
+		}
+		vt, _ := abstractHeapTypeValueType(ht)
+		return vt, ht, nil
+	}
+	return 0, 0, fmt.Errorf("invalid value type: %d", b)
+}
+
+// DecodeValueType is the same as the package-level DecodeValueType, except that references to the concrete types
+// of this module are resolved. See HeapType.
+func (m *Module) DecodeValueType(r *bytes.Reader, enabledFeatures api.CoreFeatures) (ValueType, error) {
+	vt, ht, err := DecodeValueType(r, enabledFeatures)
+	if err != nil || vt != 0 {
+		return vt, err
+	}
+	return m.HeapTypeValueType(ht)
+}
+
+// IsGCHeapType returns true if the abstract heap type is introduced by experimental.CoreFeaturesGC.
+func IsGCHeapType(ht HeapType) bool {
+	switch ht {
+	case HeapTypeNoFunc, HeapTypeNoExtern, HeapTypeNone,
+		HeapTypeAny, HeapTypeEq, HeapTypeI31, HeapTypeStruct, HeapTypeArray:
+		return true
+	}
+	return false
+}
+
+// isGCValueType returns true if the reference type is introduced by experimental.CoreFeaturesGC. The bottom types of
+// funcref and externref are excluded, as they only hold the null reference of the type, which needs no GC heap.
+func isGCValueType(vt ValueType) bool {
+	switch vt {
+	case ValueTypeAnyref, ValueTypeEqref, ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref, ValueTypeNullref:
+		return true
+	}
+	return false
+}
+
+// CompositeTypeKind is the kind of the type defined in the type section.
+type CompositeTypeKind = byte
+
+const (
+	// CompositeTypeKindFunc is a function type, which is the only kind before experimental.CoreFeaturesGC.
+	CompositeTypeKindFunc CompositeTypeKind = 0x60
+	// CompositeTypeKindStruct is a struct type.
+	CompositeTypeKindStruct CompositeTypeKind = 0x5f
+	// CompositeTypeKindArray is an array type.
+	CompositeTypeKindArray CompositeTypeKind = 0x5e
+)
+
+const (
+	// TypePrefixRec is the prefix of a recursion group in the type section.
+	TypePrefixRec = 0x4e
+	// TypePrefixSub is the prefix of a subtype in the type section.
+	TypePrefixSub = 0x50
+	// TypePrefixSubFinal is the prefix of a final subtype in the type section.
+	TypePrefixSubFinal = 0x4f
+)
+
+const (
+	// StorageTypeI8 is the packed 8-bit integer storage type of a field.
+	StorageTypeI8 = 0x78
+	// StorageTypeI16 is the packed 16-bit integer storage type of a field.
+	StorageTypeI16 = 0x77
+)
+
+// FieldType is the type of a struct field or array element.
+type FieldType struct {
+	// StorageType is either a ValueType, StorageTypeI8 or StorageTypeI16.
+	StorageType byte
+	// HeapType is the heap type of StorageType if it is a reference type.
+	HeapType HeapType
+	// Mutable is true if the field can be set.
+	Mutable bool
+}
+
+// IsPacked returns true if the field is a packed integer.
+func (f *FieldType) IsPacked() bool {
+	return f.StorageType == StorageTypeI8 || f.StorageType == StorageTypeI16
+}
+
+// ValueType returns the type of the value of the field on the stack.
+func (f *FieldType) ValueType() ValueType {
+	if f.IsPacked() {
+		return ValueTypeI32
+	}
+	return f.StorageType
+}
+
+// StorageTypeName returns the name of the storage type of a field.
+func StorageTypeName(t byte) string {
+	switch t {
+	case StorageTypeI8:
+		return "i8"
+	case StorageTypeI16:
+		return "i16"
+	}
+	return ValueTypeName(t)
+}
+
+// CompositeType is a type defined in the type section with experimental.CoreFeaturesGC.
+//
+// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md#type-definitions
+type CompositeType struct {
+	// Kind is the kind of this type. The signature of a function type is defined in Module.TypeSection.
+	Kind CompositeTypeKind
+	// Fields are the fields of a struct type, or the element of an array type as the only field.
+	Fields []FieldType
+	// SuperType is the index of the declared super type, or nil if there is none.
+	SuperType *Index
+	// Final is true if this type cannot have subtypes.
+	Final bool
+	// RecGroup is the index of the first type in the recursion group of this type.
+	RecGroup Index
+}
+
+// IsFunctionType returns true if the type at the index is a function type.
+func (m *Module) IsFunctionType(index Index) bool {
+	if index >= Index(len(m.TypeSection)) {
+		return false
+	}
+	return m.CompositeTypes == nil || m.CompositeTypes[index].Kind == CompositeTypeKindFunc
+}
+
+// CompositeTypeKind returns the kind of the type at the index, which must be in range.
+func (m *Module) CompositeTypeKind(index Index) CompositeTypeKind {
+	if m.CompositeTypes == nil {
+		return CompositeTypeKindFunc
+	}
+	return m.CompositeTypes[index].Kind
+}
+
+// compositeTypeOf returns the struct or array type at the index, or errs if it's not of the kind.
+func (m *Module) compositeTypeOf(index Index, kind CompositeTypeKind) (*CompositeType, error) {
+	if index >= Index(len(m.TypeSection)) {
+		return nil, fmt.Errorf("unknown type %d", index)
+	}
+	if m.CompositeTypeKind(index) != kind {
+		return nil, fmt.Errorf("type %d is not a%s type", index, compositeTypeKindName(kind))
+	}
+	return &m.CompositeTypes[index], nil
+}
+
+func compositeTypeKindName(kind CompositeTypeKind) string {
+	switch kind {
+	case CompositeTypeKindStruct:
+		return " struct"
+	case CompositeTypeKindArray:
+		return "n array"
+	}
+	return " function"
+}
+
+// HeapTypeValueType returns the ValueType representing the references to the heap type. See HeapType.
+func (m *Module) HeapTypeValueType(ht HeapType) (ValueType, error) {
+	if ht < 0 {
+		if vt, ok := abstractHeapTypeValueType(ht); ok {
+			return vt, nil
+		}
+		return 0, fmt.Errorf("invalid heap type: %d", ht)
+	}
+	if ht >= HeapType(len(m.TypeSection)) {
+		return 0, fmt.Errorf("unknown type %d", ht)
+	}
+	switch m.CompositeTypeKind(Index(ht)) {
+	case CompositeTypeKindStruct:
+		return ValueTypeStructref, nil
+	case CompositeTypeKindArray:
+		return ValueTypeArrayref, nil
+	default:
+		return ValueTypeFuncref, nil
+	}
+}
+
+// IsSubtypeValueType returns true if a value of the type have can be used as a value of the type want.
+func IsSubtypeValueType(have, want ValueType) bool {
+	if have == want {
+		return true
+	}
+	switch want {
+	case ValueTypeAnyref:
+		return have == ValueTypeEqref || have == ValueTypeI31ref || have == ValueTypeStructref ||
+			have == ValueTypeArrayref || have == ValueTypeNullref
+	case ValueTypeEqref:
+		return have == ValueTypeI31ref || have == ValueTypeStructref || have == ValueTypeArrayref ||
+			have == ValueTypeNullref
+	case ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref:
+		return have == ValueTypeNullref
+	case ValueTypeFuncref:
+		return have == ValueTypeNullfuncref
+	case ValueTypeExternref:
+		return have == ValueTypeNullexternref
+	}
+	return false
+}
+
+// topReferenceType returns the top type of the hierarchy which the reference type belongs to.
+func topReferenceType(vt ValueType) ValueType {
+	switch vt {
+	case ValueTypeFuncref, ValueTypeNullfuncref:
+		return ValueTypeFuncref
+	case ValueTypeExternref, ValueTypeNullexternref:
+		return ValueTypeExternref
+	case ValueTypeExnref:
+		return ValueTypeExnref
+	}
+	return ValueTypeAnyref
+}
+
+// isHeapSubtype returns true if the heap type sub matches the heap type super, following the declared super types.
+func (m *Module) isHeapSubtype(sub, super HeapType) bool {
+	if sub == super {
+		return true
+	}
+	if sub >= 0 && super >= 0 {
+		if m.CompositeTypes == nil {
+			// No type declares its super type.
+			return false
+		}
+		for {
+			ct := &m.CompositeTypes[sub]
+			if ct.SuperType == nil {
+				return false
+			}
+			if sub = HeapType(*ct.SuperType); sub == super {
+				return true
+			}
+		}
+	}
+	subType, _ := m.HeapTypeValueType(sub)
+	superType, _ := m.HeapTypeValueType(super)
+	if super >= 0 {
+		// Only the bottom type can be a subtype of a concrete type.
+		return subType != superType && IsSubtypeValueType(subType, superType)
+	}
+	return IsSubtypeValueType(subType, superType)
+}
+
+// isFieldSubtype returns true if the field type sub matches the field type super.
+func (m *Module) isFieldSubtype(sub, super *FieldType) bool {
+	if sub.Mutable != super.Mutable {
+		return false
+	}
+	if sub.IsPacked() || super.IsPacked() || !IsReferenceValueType(super.StorageType) {
+		return sub.StorageType == super.StorageType
+	}
+	if !IsReferenceValueType(sub.StorageType) {
+		return false
+	}
+	if sub.Mutable {
+		// Mutable fields are invariant.
+		return sub.HeapType == super.HeapType
+	}
+	return m.isHeapSubtype(sub.HeapType, super.HeapType)
+}
+
+// validateCompositeTypes ensures the declared super types are valid.
+func (m *Module) validateCompositeTypes() error {
+	for i := range m.CompositeTypes {
+		ct := &m.CompositeTypes[i]
+		if ct.SuperType == nil {
+			continue
+		}
+		superIndex := *ct.SuperType
+		if superIndex >= Index(i) {
+			return fmt.Errorf("type %d: super type %d must be defined before its subtypes", i, superIndex)
+		}
+		super := &m.CompositeTypes[superIndex]
+		if super.Final {
+			return fmt.Errorf("type %d: super type %d is final", i, superIndex)
+		}
+		if super.Kind != ct.Kind {
+			return fmt.Errorf("type %d: kind mismatch with super type %d", i, superIndex)
+		}
+
+		var ok bool
+		switch ct.Kind {
+		case CompositeTypeKindStruct, CompositeTypeKindArray:
+			ok = len(ct.Fields) >= len(super.Fields)
+			for j := 0; ok && j < len(super.Fields); j++ {
+				ok = m.isFieldSubtype(&ct.Fields[j], &super.Fields[j])
+			}
+		case CompositeTypeKindFunc:
+			sub, superFunc := &m.TypeSection[i], &m.TypeSection[superIndex]
+			ok = len(sub.Params) == len(superFunc.Params) && len(sub.Results) == len(superFunc.Results)
+			for j := 0; ok && j < len(sub.Params); j++ {
+				ok = IsSubtypeValueType(superFunc.Params[j], sub.Params[j])
+			}
+			for j := 0; ok && j < len(sub.Results); j++ {
+				ok = IsSubtypeValueType(sub.Results[j], superFunc.Results[j])
+			}
+		}
+		if !ok {
+			return fmt.Errorf("type %d: not a subtype of super type %d", i, superIndex)
+		}
+	}
+	return nil
+}
+
+// isFunctionSubtype returns true if the function type sub can be used in place of the function type super, taking
+// the subtyping of the reference types into account.
+func isFunctionSubtype(sub, super *FunctionType) bool {
+	if bytes.Equal(sub.Params, super.Params) && bytes.Equal(sub.Results, super.Results) {
+		return true
+	}
+	if len(sub.Params) != len(super.Params) || len(sub.Results) != len(super.Results) {
+		return false
+	}
+	for i := range sub.Params {
+		if !IsSubtypeValueType(super.Params[i], sub.Params[i]) {
+			return false
+		}
+	}
+	for i := range sub.Results {
+		if !IsSubtypeValueType(sub.Results[i], super.Results[i]) {
+			return false
+		}
+	}
+	return true
+}
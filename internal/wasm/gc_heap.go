@@ -0,0 +1,526 @@
+package wasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// GCType is the runtime representation of a struct or array type of experimental.CoreFeaturesGC.
+//
+// Types are canonicalized per Store by their recursion group, so that the equivalent types defined in different
+// modules are represented by the same *GCType and can be compared by identity.
+type GCType struct {
+	// Kind is either CompositeTypeKindStruct or CompositeTypeKindArray.
+	Kind CompositeTypeKind
+	// Fields are the fields of a struct, or the element of an array as the only field.
+	Fields []FieldType
+	// Super is the declared super type, or nil if there is none.
+	Super *GCType
+
+	// offsets are the index of each struct field in GCObject.Values.
+	offsets []int
+	// size is the number of uint64 in GCObject.Values for a struct, or per element for an array.
+	size int
+}
+
+// IsSubtypeOf returns true if t is super or one of its declared subtypes.
+func (t *GCType) IsSubtypeOf(super *GCType) bool {
+	for ; t != nil; t = t.Super {
+		if t == super {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldOffset returns the index of the struct field in GCObject.Values.
+func (t *GCType) FieldOffset(field Index) int {
+	return t.offsets[field]
+}
+
+// Size returns the number of uint64 in GCObject.Values of a struct, or per element of an array.
+func (t *GCType) Size() int {
+	return t.size
+}
+
+func newGCType(ct *CompositeType) *GCType {
+	t := &GCType{Kind: ct.Kind, Fields: ct.Fields}
+	if ct.Kind == CompositeTypeKindArray {
+		t.size = fieldSize(&ct.Fields[0])
+		return t
+	}
+	t.offsets = make([]int, len(ct.Fields))
+	for i := range ct.Fields {
+		t.offsets[i] = t.size
+		t.size += fieldSize(&ct.Fields[i])
+	}
+	return t
+}
+
+// fieldSize returns the number of uint64 which a value of the field occupies.
+func fieldSize(f *FieldType) int {
+	if f.StorageType == ValueTypeV128 {
+		return 2
+	}
+	return 1
+}
+
+// PackFieldValue truncates the value to the storage type of the field if it is packed.
+func PackFieldValue(f *FieldType, v uint64) uint64 {
+	switch f.StorageType {
+	case StorageTypeI8:
+		return uint64(uint8(v))
+	case StorageTypeI16:
+		return uint64(uint16(v))
+	}
+	return v
+}
+
+// UnpackFieldValue returns the value of the field on the stack. A packed value is sign-extended if signed is true.
+func UnpackFieldValue(f *FieldType, v uint64, signed bool) uint64 {
+	if signed {
+		switch f.StorageType {
+		case StorageTypeI8:
+			return uint64(uint32(int32(int8(v))))
+		case StorageTypeI16:
+			return uint64(uint32(int32(int16(v))))
+		}
+	}
+	return v
+}
+
+// canonicalGCTypes returns the canonical GCType of each type in the module, which is index-correlated with
+// Module.TypeSection. The entries of function types are nil.
+func (s *Store) canonicalGCTypes(module *Module) []*GCType {
+	if module.CompositeTypes == nil {
+		return nil
+	}
+	ret := make([]*GCType, len(module.TypeSection))
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.gcTypes == nil {
+		s.gcTypes = map[string][]*GCType{}
+	}
+	for start := 0; start < len(module.CompositeTypes); {
+		end := start + 1
+		for end < len(module.CompositeTypes) && module.CompositeTypes[end].RecGroup == Index(start) {
+			end++
+		}
+		key := gcRecGroupKey(module, ret, start, end)
+		group, ok := s.gcTypes[key]
+		if !ok {
+			group = make([]*GCType, end-start)
+			for i := start; i < end; i++ {
+				if ct := &module.CompositeTypes[i]; ct.Kind != CompositeTypeKindFunc {
+					group[i-start] = newGCType(ct)
+				}
+			}
+			for i := start; i < end; i++ {
+				if ct := &module.CompositeTypes[i]; ct.SuperType != nil && group[i-start] != nil {
+					if super := *ct.SuperType; int(super) >= start {
+						group[i-start].Super = group[int(super)-start]
+					} else {
+						group[i-start].Super = ret[super]
+					}
+				}
+			}
+			s.gcTypes[key] = group
+		}
+		copy(ret[start:end], group)
+		start = end
+	}
+	return ret
+}
+
+// gcRecGroupKey returns the key identifying the recursion group of the types in [start, end) up to iso-recursive
+// equivalence. The types referenced inside the group are identified by their relative index, and the others by the
+// identity of their canonical GCType, or by their signature if they are function types.
+func gcRecGroupKey(module *Module, canonical []*GCType, start, end int) string {
+	var b strings.Builder
+	writeHeapType := func(ht HeapType) {
+		switch {
+		case ht < 0:
+			b.WriteString(strconv.FormatInt(int64(ht), 10))
+		case int(ht) >= start && int(ht) < end:
+			b.WriteString("r")
+			b.WriteString(strconv.Itoa(int(ht) - start))
+		case module.CompositeTypes[ht].Kind == CompositeTypeKindFunc:
+			b.WriteString("f(")
+			b.WriteString(module.TypeSection[ht].key())
+			b.WriteString(")")
+		default:
+			fmt.Fprintf(&b, "c%p", canonical[ht])
+		}
+	}
+	for i := start; i < end; i++ {
+		ct := &module.CompositeTypes[i]
+		b.WriteString("{")
+		b.WriteByte(ct.Kind)
+		if ct.Final {
+			b.WriteString("final")
+		}
+		if ct.SuperType != nil {
+			b.WriteString(" sub ")
+			writeHeapType(HeapType(*ct.SuperType))
+		}
+		if ct.Kind == CompositeTypeKindFunc {
+			b.WriteString(module.TypeSection[i].key())
+		}
+		for j := range ct.Fields {
+			f := &ct.Fields[j]
+			b.WriteString(" ")
+			b.WriteByte(f.StorageType)
+			if f.Mutable {
+				b.WriteString("mut")
+			}
+			if IsReferenceValueType(f.StorageType) {
+				writeHeapType(f.HeapType)
+			}
+		}
+		b.WriteString("}")
+	}
+	return b.String()
+}
+
+// GCObject is a struct or array allocated in GCHeap.
+type GCObject struct {
+	// Type is the type of the object, or nil if the object boxes an external reference converted with
+	// any.convert_extern, which is held in Values[0].
+	Type *GCType
+	// Values holds the fields of a struct, or the elements of an array. A v128 value occupies two entries.
+	Values []uint64
+}
+
+// ArrayLen returns the number of elements of an array.
+func (o *GCObject) ArrayLen() int {
+	return len(o.Values) / o.Type.size
+}
+
+// GCRootScanner is implemented by engines to report the references held by a function call in progress.
+type GCRootScanner interface {
+	// ScanGCRoots calls mark with each value which may be a reference to a GCObject.
+	ScanGCRoots(mark func(v uint64))
+}
+
+const (
+	// gcRefI31Bit is set on i31 references, which are not allocated in the heap.
+	gcRefI31Bit = 1
+	// gcRefExternBit is set on internal references converted to external references with extern.convert_any.
+	gcRefExternBit = 1 << 63
+	// gcRefGenerationMask is the mask of the generation of a slot in the upper half of a reference.
+	gcRefGenerationMask = 0x7fffffff
+	// gcMinimumCollectionThreshold is the minimum number of allocations between two collections.
+	gcMinimumCollectionThreshold = 1024
+)
+
+// MaximumGCObjectSize is the maximum length of GCObject.Values, which limits the length of arrays.
+const MaximumGCObjectSize = 1 << 27
+
+// GCRefI31 returns the i31 reference of the lower 31 bits of v.
+func GCRefI31(v uint32) uint64 {
+	return uint64(v&0x7fffffff)<<1 | gcRefI31Bit
+}
+
+// IsGCRefI31 returns true if the non-null internal reference is an i31 reference.
+func IsGCRefI31(ref uint64) bool {
+	return ref&gcRefI31Bit != 0
+}
+
+// GCRefI31Value returns the value of the i31 reference, which is sign-extended to 32 bits if signed is true.
+func GCRefI31Value(ref uint64, signed bool) uint32 {
+	v := uint32(ref>>1) & 0x7fffffff
+	if signed {
+		v = uint32(int32(v<<1) >> 1)
+	}
+	return v
+}
+
+// GCHeap is the garbage collected heap of a Store, which holds the structs and arrays of experimental.CoreFeaturesGC.
+//
+// A reference to a GCObject is encoded as the generation of its slot in the upper 32 bits, and the one-based index of
+// the slot shifted by one in the lower 32 bits. The lowest bit distinguishes i31 references, which are not allocated,
+// and zero is the null reference. The generation of a slot is incremented when its object is collected, so that a stale
+// reference doesn't resolve to an unrelated object.
+//
+// Objects are collected by mark and sweep. The roots are the globals, tables and element instances of the module
+// instances in the store, and the stacks of the function calls in progress. Collection only happens on allocation
+// while all the other calls in progress are at safepoints, so that the roots are consistent: they are either
+// allocating too, or calling host functions. See EnterCall and EnterHostCall. References held by the host outside of
+// function calls are not roots.
+type GCHeap struct {
+	// mux guards all the fields below. Get only needs the read lock.
+	mux sync.RWMutex
+
+	slots []gcSlot
+	free  []uint32
+
+	// allocated is the number of allocations since the last collection, and threshold is the number which triggers
+	// the next collection.
+	allocated, threshold int
+
+	modules map[*ModuleInstance]struct{}
+	calls   map[GCRootScanner]int
+	// activeCalls is the number of function calls in progress, and suspended is the number of the ones which
+	// hold references without a GCRootScanner, such as instantiations.
+	activeCalls, suspended int
+	// hostCalls is the number of the calls in progress which are calling host functions.
+	hostCalls int
+
+	// allocating is the number of the calls waiting for mux in Allocate. This is not guarded by mux, as it's
+	// incremented before locking it.
+	allocating atomic.Int32
+}
+
+type gcSlot struct {
+	obj        *GCObject
+	generation uint32
+	marked     bool
+}
+
+// NewGCHeap returns a new empty GCHeap.
+func NewGCHeap() *GCHeap {
+	return &GCHeap{
+		threshold: gcMinimumCollectionThreshold,
+		modules:   map[*ModuleInstance]struct{}{},
+		calls:     map[GCRootScanner]int{},
+	}
+}
+
+// EnterCall must be called before a function call starts with the scanner of its stack. ExitCall must be called with
+// the same scanner when the call finishes.
+//
+// The scanner is nil if the references held by the call cannot be scanned, which suspends the collection until the
+// call finishes.
+func (h *GCHeap) EnterCall(scanner GCRootScanner) {
+	h.mux.Lock()
+	h.activeCalls++
+	if scanner != nil {
+		h.calls[scanner]++
+	} else {
+		h.suspended++
+	}
+	h.mux.Unlock()
+}
+
+// ExitCall is the counterpart of EnterCall.
+func (h *GCHeap) ExitCall(scanner GCRootScanner) {
+	h.mux.Lock()
+	h.activeCalls--
+	if scanner != nil {
+		if n := h.calls[scanner]; n > 1 {
+			h.calls[scanner] = n - 1
+		} else {
+			delete(h.calls, scanner)
+		}
+	} else {
+		h.suspended--
+	}
+	h.mux.Unlock()
+}
+
+// EnterHostCall must be called by a function call in progress before calling a host function, and ExitHostCall after
+// it returns. The stack of the call must not change in between, so that the other calls can scan it to collect garbage.
+func (h *GCHeap) EnterHostCall() {
+	h.mux.Lock()
+	h.hostCalls++
+	h.mux.Unlock()
+}
+
+// ExitHostCall is the counterpart of EnterHostCall. This waits for the collection in progress, if any.
+func (h *GCHeap) ExitHostCall() {
+	h.mux.Lock()
+	h.hostCalls--
+	h.mux.Unlock()
+}
+
+// addModule registers the module instance whose globals, tables and element instances are roots.
+func (h *GCHeap) addModule(m *ModuleInstance) {
+	h.mux.Lock()
+	h.modules[m] = struct{}{}
+	h.mux.Unlock()
+}
+
+// removeModule is the counterpart of addModule.
+func (h *GCHeap) removeModule(m *ModuleInstance) {
+	h.mux.Lock()
+	delete(h.modules, m)
+	h.mux.Unlock()
+}
+
+// Allocate allocates an object of the type with size zero values, and returns the reference to it along with the
+// object to initialize.
+//
+// As this may collect garbage, values which the caller holds must be reachable from the roots, for example, the
+// operands of the instruction must be still on the stack. The caller must be one of the calls in progress. See EnterCall.
+func (h *GCHeap) Allocate(t *GCType, size int) (uint64, *GCObject) {
+	obj := &GCObject{Type: t, Values: make([]uint64, size)}
+
+	// The call is at a safepoint while waiting for the lock, so the others can collect garbage meanwhile.
+	h.allocating.Add(1)
+	h.mux.Lock()
+	h.allocating.Add(-1)
+	defer h.mux.Unlock()
+	if h.allocated++; h.allocated >= h.threshold && h.suspended == 0 && h.atSafepoints() {
+		h.collect()
+	}
+
+	var index uint32
+	if n := len(h.free); n > 0 {
+		index = h.free[n-1]
+		h.free = h.free[:n-1]
+	} else {
+		index = uint32(len(h.slots))
+		h.slots = append(h.slots, gcSlot{generation: 1})
+	}
+	slot := &h.slots[index]
+	slot.obj = obj
+	return uint64(slot.generation)<<32 | uint64(index+1)<<1, obj
+}
+
+// atSafepoints returns true if all the calls in progress except the caller are at safepoints. This must be called
+// with mux locked. The calls which start waiting for the lock meanwhile are at safepoints too, so this never reports
+// a running call as one.
+func (h *GCHeap) atSafepoints() bool {
+	return h.activeCalls-h.hostCalls-int(h.allocating.Load()) == 1
+}
+
+// Get returns the object referenced by the non-i31 reference, or nil if it's null or stale.
+func (h *GCHeap) Get(ref uint64) *GCObject {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	if slot := h.slot(ref); slot != nil {
+		return slot.obj
+	}
+	return nil
+}
+
+// slot returns the slot referenced by ref if it holds an object, or nil otherwise.
+func (h *GCHeap) slot(ref uint64) *gcSlot {
+	if ref&gcRefI31Bit != 0 {
+		return nil
+	}
+	index := uint32(ref) >> 1
+	if index == 0 || int(index) > len(h.slots) {
+		return nil
+	}
+	slot := &h.slots[index-1]
+	if slot.obj == nil || slot.generation != uint32(ref>>32) {
+		return nil
+	}
+	return slot
+}
+
+// ExternConvertAny returns the external reference of the internal reference, as extern.convert_any.
+func (h *GCHeap) ExternConvertAny(ref uint64) uint64 {
+	if ref == 0 {
+		return 0
+	}
+	if obj := h.Get(ref); obj != nil && obj.Type == nil {
+		// Unbox the external reference converted with any.convert_extern.
+		return obj.Values[0]
+	}
+	return ref | gcRefExternBit
+}
+
+// AnyConvertExtern returns the internal reference of the external reference, as any.convert_extern. An external
+// reference which is not converted from an internal one is boxed in a new object, so this may collect garbage as
+// Allocate.
+func (h *GCHeap) AnyConvertExtern(ref uint64) uint64 {
+	if ref == 0 {
+		return 0
+	}
+	if ref&gcRefExternBit != 0 {
+		return ref &^ gcRefExternBit
+	}
+	boxed, obj := h.Allocate(nil, 1)
+	obj.Values[0] = ref
+	return boxed
+}
+
+// collect frees the objects unreachable from the roots. This must be called with mux locked.
+func (h *GCHeap) collect() {
+	var worklist []*GCObject
+	mark := func(v uint64) {
+		if slot := h.slot(v &^ gcRefExternBit); slot != nil && !slot.marked {
+			slot.marked = true
+			worklist = append(worklist, slot.obj)
+		}
+	}
+
+	for m := range h.modules {
+		for _, g := range m.Globals {
+			if g != nil && IsReferenceValueType(g.Type.ValType) {
+				mark(g.Val)
+			}
+		}
+		for _, t := range m.Tables {
+			if t != nil {
+				for _, r := range t.References {
+					mark(uint64(r))
+				}
+			}
+		}
+		for _, elems := range m.ElementInstances {
+			for _, r := range elems {
+				mark(uint64(r))
+			}
+		}
+	}
+	for scanner := range h.calls {
+		scanner.ScanGCRoots(mark)
+	}
+
+	for len(worklist) > 0 {
+		obj := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		t := obj.Type
+		if t == nil {
+			continue // Boxed external references don't reference objects.
+		}
+		if t.Kind == CompositeTypeKindArray {
+			if IsReferenceValueType(t.Fields[0].StorageType) {
+				for _, v := range obj.Values {
+					mark(v)
+				}
+			}
+			continue
+		}
+		for i := range t.Fields {
+			if IsReferenceValueType(t.Fields[i].StorageType) {
+				mark(obj.Values[t.offsets[i]])
+			}
+		}
+	}
+
+	live := 0
+	for i := range h.slots {
+		slot := &h.slots[i]
+		if slot.marked {
+			slot.marked = false
+			live++
+		} else if slot.obj != nil {
+			slot.obj = nil
+			if slot.generation = (slot.generation + 1) & gcRefGenerationMask; slot.generation == 0 {
+				slot.generation = 1
+			}
+			h.free = append(h.free, uint32(i))
+		}
+	}
+	h.allocated = 0
+	h.threshold = live
+	if h.threshold < gcMinimumCollectionThreshold {
+		h.threshold = gcMinimumCollectionThreshold
+	}
+}
+
+// GCHeap returns the garbage collected heap of the store, or nil unless experimental.CoreFeaturesGC is enabled.
+func (m *ModuleInstance) GCHeap() *GCHeap {
+	if m.s == nil {
+		return nil
+	}
+	return m.s.gcHeap
+}
@@ -0,0 +1,207 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestStore_canonicalGCTypes(t *testing.T) {
+	s := newStore()
+	super := Index(0)
+	// The type 0 is a struct with a reference to itself, 1 is its final subtype and 2 is an array of i32.
+	module := func() *Module {
+		return &Module{
+			TypeSection: []FunctionType{{}, {}, {}, {Params: []ValueType{ValueTypeI32}}},
+			CompositeTypes: []CompositeType{
+				{Kind: CompositeTypeKindStruct, Fields: []FieldType{{StorageType: ValueTypeStructref, HeapType: 0}}},
+				{Kind: CompositeTypeKindStruct, Fields: []FieldType{
+					{StorageType: ValueTypeStructref, HeapType: 0},
+					{StorageType: ValueTypeV128, Mutable: true},
+					{StorageType: StorageTypeI8},
+				}, SuperType: &super, Final: true, RecGroup: 1},
+				{Kind: CompositeTypeKindArray, Fields: []FieldType{{StorageType: ValueTypeI32}}, Final: true, RecGroup: 2},
+				{Kind: CompositeTypeKindFunc, Final: true, RecGroup: 3},
+			},
+		}
+	}
+
+	types := s.canonicalGCTypes(module())
+	require.Equal(t, 4, len(types))
+	require.Nil(t, types[3])
+	require.True(t, types[1].IsSubtypeOf(types[0]))
+	require.False(t, types[0].IsSubtypeOf(types[1]))
+	require.False(t, types[2].IsSubtypeOf(types[0]))
+
+	require.Equal(t, 1, types[0].Size())
+	require.Equal(t, 4, types[1].Size())
+	require.Equal(t, 3, types[1].FieldOffset(2))
+	require.Equal(t, 1, types[2].Size())
+
+	// The equivalent types of another module are canonicalized to the same ones.
+	other := s.canonicalGCTypes(module())
+	require.Equal(t, types, other)
+	for i := range types {
+		require.True(t, types[i] == other[i])
+	}
+
+	// The type without the declared super type is distinct.
+	m := module()
+	m.CompositeTypes[1].SuperType = nil
+	require.False(t, s.canonicalGCTypes(m)[1] == types[1])
+
+	require.Nil(t, s.canonicalGCTypes(&Module{TypeSection: []FunctionType{{}}}))
+}
+
+func TestGCRefI31(t *testing.T) {
+	for _, tc := range []struct {
+		v                uint32
+		signed, unsigned uint32
+	}{
+		{v: 0, signed: 0, unsigned: 0},
+		{v: 1, signed: 1, unsigned: 1},
+		{v: 0x3fffffff, signed: 0x3fffffff, unsigned: 0x3fffffff},
+		{v: 0x40000000, signed: 0xc0000000, unsigned: 0x40000000},
+		{v: 0xffffffff, signed: 0xffffffff, unsigned: 0x7fffffff},
+	} {
+		ref := GCRefI31(tc.v)
+		require.NotEqual(t, uint64(0), ref)
+		require.True(t, IsGCRefI31(ref))
+		require.Equal(t, tc.signed, GCRefI31Value(ref, true))
+		require.Equal(t, tc.unsigned, GCRefI31Value(ref, false))
+	}
+}
+
+// gcRoots implements GCRootScanner with the references it holds.
+type gcRoots struct{ refs []uint64 }
+
+func (r *gcRoots) ScanGCRoots(mark func(v uint64)) {
+	for _, ref := range r.refs {
+		mark(ref)
+	}
+}
+
+func TestGCHeap(t *testing.T) {
+	structType := newGCType(&CompositeType{
+		Kind:   CompositeTypeKindStruct,
+		Fields: []FieldType{{StorageType: ValueTypeAnyref, Mutable: true}, {StorageType: ValueTypeI64}},
+	})
+	arrayType := newGCType(&CompositeType{Kind: CompositeTypeKindArray, Fields: []FieldType{{StorageType: StorageTypeI16}}})
+
+	t.Run("allocate", func(t *testing.T) {
+		h := NewGCHeap()
+		ref, obj := h.Allocate(arrayType, 10)
+		require.False(t, IsGCRefI31(ref))
+		require.Equal(t, 10, obj.ArrayLen())
+		require.True(t, h.Get(ref) == obj)
+		require.Nil(t, h.Get(0))
+		require.Nil(t, h.Get(GCRefI31(1)))
+	})
+
+	t.Run("collect", func(t *testing.T) {
+		h := NewGCHeap()
+		scanner := &gcRoots{}
+		h.EnterCall(scanner)
+		defer h.ExitCall(scanner)
+
+		// The struct is a root, and the array is only reachable from the struct.
+		root, obj := h.Allocate(structType, structType.Size())
+		scanner.refs = append(scanner.refs, root)
+		array, _ := h.Allocate(arrayType, 1)
+		obj.Values[0] = array
+		garbage, _ := h.Allocate(arrayType, 1)
+
+		h.mux.Lock()
+		h.collect()
+		h.mux.Unlock()
+
+		require.True(t, h.Get(root) == obj)
+		require.NotNil(t, h.Get(array))
+		require.Nil(t, h.Get(garbage))
+
+		// The slot of the collected object is reused, but the stale reference still doesn't resolve to it.
+		reused, _ := h.Allocate(arrayType, 1)
+		require.Equal(t, uint32(garbage), uint32(reused))
+		require.NotEqual(t, garbage, reused)
+		require.Nil(t, h.Get(garbage))
+	})
+
+	t.Run("threshold", func(t *testing.T) {
+		h := NewGCHeap()
+		scanner := &gcRoots{}
+
+		// Nothing is collected without a call in progress, nor while a call cannot be scanned.
+		first, _ := h.Allocate(arrayType, 1)
+		h.EnterCall(nil)
+		for i := 0; i < gcMinimumCollectionThreshold; i++ {
+			h.Allocate(arrayType, 1)
+		}
+		h.ExitCall(nil)
+		require.NotNil(t, h.Get(first))
+
+		h.EnterCall(scanner)
+		defer h.ExitCall(scanner)
+		for i := 0; i < gcMinimumCollectionThreshold; i++ {
+			h.Allocate(arrayType, 1)
+		}
+		require.Nil(t, h.Get(first))
+	})
+
+	t.Run("concurrent calls", func(t *testing.T) {
+		h := NewGCHeap()
+		running, calling := &gcRoots{}, &gcRoots{}
+		h.EnterCall(running)
+		defer h.ExitCall(running)
+		h.EnterCall(calling)
+		defer h.ExitCall(calling)
+
+		root, _ := h.Allocate(arrayType, 1)
+		calling.refs = append(calling.refs, root)
+		first, _ := h.Allocate(arrayType, 1)
+
+		// Nothing is collected while the other call may be changing its stack.
+		for i := 0; i < gcMinimumCollectionThreshold; i++ {
+			h.Allocate(arrayType, 1)
+		}
+		require.NotNil(t, h.Get(first))
+
+		// The stack of the other call is scanned while it's calling a host function.
+		h.EnterHostCall()
+		for i := 0; i < gcMinimumCollectionThreshold; i++ {
+			h.Allocate(arrayType, 1)
+		}
+		h.ExitHostCall()
+		require.Nil(t, h.Get(first))
+		require.NotNil(t, h.Get(root))
+
+		// The other call is also at a safepoint while it's waiting to allocate.
+		second, _ := h.Allocate(arrayType, 1)
+		h.allocating.Add(1)
+		for i := 0; i < gcMinimumCollectionThreshold; i++ {
+			h.Allocate(arrayType, 1)
+		}
+		h.allocating.Add(-1)
+		require.Nil(t, h.Get(second))
+		require.NotNil(t, h.Get(root))
+	})
+
+	t.Run("extern conversions", func(t *testing.T) {
+		h := NewGCHeap()
+		require.Equal(t, uint64(0), h.ExternConvertAny(0))
+		require.Equal(t, uint64(0), h.AnyConvertExtern(0))
+
+		// An internal reference round-trips without allocation.
+		ref, _ := h.Allocate(arrayType, 1)
+		for _, r := range []uint64{ref, GCRefI31(5)} {
+			extern := h.ExternConvertAny(r)
+			require.NotEqual(t, r, extern)
+			require.Equal(t, r, h.AnyConvertExtern(extern))
+		}
+
+		// A host reference is boxed, and unboxed when converted back.
+		const host = 0x1234_5678
+		boxed := h.AnyConvertExtern(host)
+		require.Nil(t, h.Get(boxed).Type)
+		require.Equal(t, uint64(host), h.ExternConvertAny(boxed))
+	})
+}
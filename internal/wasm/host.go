@@ -1,10 +1,13 @@
 package wasm
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasmdebug"
 )
 
@@ -44,6 +47,37 @@ func (f *HostFunc) WithGoModuleFunc(fn api.GoModuleFunc) *HostFunc {
 	return &ret
 }
 
+// HostMemory is a memory defined in a host module, used for AddHostDefinitions.
+type HostMemory struct {
+	// ExportName is the name the memory is exported as.
+	ExportName string
+
+	// Memory is the type of the memory, whose Cap and Max are already sized
+	// according to the runtime configuration.
+	Memory Memory
+}
+
+// HostGlobal is a global defined in a host module, used for AddHostDefinitions.
+type HostGlobal struct {
+	// ExportName is the name the global is exported as.
+	ExportName string
+
+	// Type is the type of the global, which must be a numeric type other than v128.
+	Type GlobalType
+
+	// Val is the initial value of the global, encoded as documented on api.ValueType.
+	Val uint64
+}
+
+// HostTable is a table defined in a host module, used for AddHostDefinitions.
+type HostTable struct {
+	// ExportName is the name the table is exported as.
+	ExportName string
+
+	// Table is the type of the table. Its elements are initially null.
+	Table Table
+}
+
 // NewHostModule is defined internally for use in WASI tests and to keep the code size in the root directory small.
 func NewHostModule(
 	moduleName string,
@@ -177,3 +211,93 @@ func (m *Module) maybeAddType(params, results []ValueType, enabledFeatures api.C
 	m.TypeSection = append(m.TypeSection, FunctionType{Params: params, Results: results})
 	return result, nil
 }
+
+// AddHostDefinitions adds the memories, globals and tables to m, the result of NewHostModule, and exports them.
+//
+// Note: Their types are validated along with the rest of m by Module.Validate.
+func (m *Module) AddHostDefinitions(memories []HostMemory, globals []HostGlobal, tables []HostTable, enabledFeatures api.CoreFeatures) error {
+	moduleName := m.NameSection.ModuleName
+
+	exportCount := len(m.ExportSection) + len(memories) + len(globals) + len(tables)
+	if exportCount == len(m.ExportSection) {
+		return nil
+	}
+	exports := make([]Export, len(m.ExportSection), exportCount)
+	copy(exports, m.ExportSection)
+	addExport := func(typ ExternType, name string, idx Index) error {
+		for i := range exports {
+			if exports[i].Name == name {
+				return fmt.Errorf("%s[%s.%s] export name conflicts with another %s",
+					ExternTypeName(typ), moduleName, name, ExternTypeName(exports[i].Type))
+			}
+		}
+		exports = append(exports, Export{Type: typ, Name: name, Index: idx})
+		return nil
+	}
+
+	for i := range memories {
+		hm := &memories[i]
+		if hm.Memory.IsShared {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesThreads); err != nil {
+				return fmt.Errorf("memory[%s.%s] shared memory requested but threads feature not enabled", moduleName, hm.ExportName)
+			} else if !hm.Memory.IsMaxEncoded {
+				return fmt.Errorf("memory[%s.%s] shared memory requires a maximum size to be specified", moduleName, hm.ExportName)
+			}
+		}
+		if err := addExport(ExternTypeMemory, hm.ExportName, Index(len(m.MemorySection))); err != nil {
+			return err
+		}
+		m.MemorySection = append(m.MemorySection, hm.Memory)
+	}
+
+	for i := range globals {
+		hg := &globals[i]
+		var init ConstantExpression
+		switch hg.Type.ValType {
+		case ValueTypeI32:
+			init = ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(int32(hg.Val))}
+		case ValueTypeI64:
+			init = ConstantExpression{Opcode: OpcodeI64Const, Data: leb128.EncodeInt64(int64(hg.Val))}
+		case ValueTypeF32:
+			init = ConstantExpression{Opcode: OpcodeF32Const, Data: binary.LittleEndian.AppendUint32(nil, uint32(hg.Val))}
+		case ValueTypeF64:
+			init = ConstantExpression{Opcode: OpcodeF64Const, Data: binary.LittleEndian.AppendUint64(nil, hg.Val)}
+		default:
+			return fmt.Errorf("global[%s.%s] type %s is unsupported", moduleName, hg.ExportName, ValueTypeName(hg.Type.ValType))
+		}
+		if err := addExport(ExternTypeGlobal, hg.ExportName, Index(len(m.GlobalSection))); err != nil {
+			return err
+		}
+		m.GlobalSection = append(m.GlobalSection, Global{Type: hg.Type, Init: init})
+	}
+
+	for i := range tables {
+		ht := &tables[i]
+		switch ht.Table.Type {
+		case RefTypeFuncref:
+		case RefTypeExternref:
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureReferenceTypes); err != nil {
+				return fmt.Errorf("table[%s.%s] type externref is invalid: %w", moduleName, ht.ExportName, err)
+			}
+		default:
+			return fmt.Errorf("table[%s.%s] type %s is unsupported", moduleName, ht.ExportName, ValueTypeName(ht.Table.Type))
+		}
+		if ht.Table.Min > MaximumFunctionIndex {
+			return fmt.Errorf("table[%s.%s] min must be at most %d", moduleName, ht.ExportName, MaximumFunctionIndex)
+		} else if max := ht.Table.Max; max != nil && *max < ht.Table.Min {
+			return fmt.Errorf("table[%s.%s] min %d > max %d", moduleName, ht.ExportName, ht.Table.Min, *max)
+		}
+		if err := addExport(ExternTypeTable, ht.ExportName, Index(len(m.TableSection))); err != nil {
+			return err
+		}
+		m.TableSection = append(m.TableSection, ht.Table)
+	}
+
+	// Exports point into ExportSection, so they are rebuilt after it is reallocated.
+	m.ExportSection = exports
+	m.Exports = make(map[string]*Export, len(exports))
+	for i := range exports {
+		m.Exports[exports[i].Name] = &exports[i]
+	}
+	return nil
+}
@@ -42,6 +42,49 @@ const (
 	OpcodeCall         Opcode = 0x10
 	OpcodeCallIndirect Opcode = 0x11
 
+	// Below are toggled with CoreFeaturesTailCall
+
+	// OpcodeTailCallReturnCall is the tail call variant of OpcodeCall: the callee replaces the current frame,
+	// and its results are returned to the caller of the current function.
+	OpcodeTailCallReturnCall Opcode = 0x12
+	// OpcodeTailCallReturnCallIndirect is the tail call variant of OpcodeCallIndirect.
+	OpcodeTailCallReturnCallIndirect Opcode = 0x13
+
+	// Below are toggled with CoreFeaturesExceptionHandling
+
+	// OpcodeThrow is a stack-polymorphic opcode that throws an exception of the tag given by the immediate, with
+	// the tag's parameters popped from the stack as the payload.
+	OpcodeThrow Opcode = 0x08
+	// OpcodeThrowRef is a stack-polymorphic opcode that rethrows the exception referenced by the exnref popped
+	// from the stack.
+	OpcodeThrowRef Opcode = 0x0a
+	// OpcodeTryTable is a block whose catch clauses branch to the enclosing labels when an exception thrown
+	// inside the block matches them. The immediates are the block type followed by the vector of catch clauses.
+	OpcodeTryTable Opcode = 0x1f
+
+	// Below are toggled with CoreFeaturesFunctionReferences
+
+	// OpcodeCallRef calls the function referenced by the typed function reference popped from the stack. The
+	// immediate is the index of the function type.
+	OpcodeCallRef Opcode = 0x14
+	// OpcodeReturnCallRef is the tail call variant of OpcodeCallRef.
+	OpcodeReturnCallRef Opcode = 0x15
+	// OpcodeRefAsNonNull traps if the reference on top of the stack is null.
+	OpcodeRefAsNonNull Opcode = 0xd4
+	// OpcodeBrOnNull branches to the label given by the immediate if the reference popped from the stack is null,
+	// and otherwise pushes it back.
+	OpcodeBrOnNull Opcode = 0xd5
+	// OpcodeBrOnNonNull branches to the label given by the immediate with the reference popped from the stack if
+	// it is not null.
+	OpcodeBrOnNonNull Opcode = 0xd6
+
+	// Below are toggled with CoreFeaturesGC
+
+	// OpcodeRefEq pops two eqref values, and pushes 1 if they reference the same object, 0 otherwise.
+	OpcodeRefEq Opcode = 0xd3
+	// OpcodeGCPrefix is the prefix of the struct, array, i31 and cast instructions. See OpcodeGC.
+	OpcodeGCPrefix Opcode = 0xfb
+
 	// parametric instructions
 
 	OpcodeDrop        Opcode = 0x1a
@@ -973,19 +1016,35 @@ const (
 )
 
 var instructionNames = [256]string{
-	OpcodeUnreachable:       OpcodeUnreachableName,
-	OpcodeNop:               OpcodeNopName,
-	OpcodeBlock:             OpcodeBlockName,
-	OpcodeLoop:              OpcodeLoopName,
-	OpcodeIf:                OpcodeIfName,
-	OpcodeElse:              OpcodeElseName,
-	OpcodeEnd:               OpcodeEndName,
-	OpcodeBr:                OpcodeBrName,
-	OpcodeBrIf:              OpcodeBrIfName,
-	OpcodeBrTable:           OpcodeBrTableName,
-	OpcodeReturn:            OpcodeReturnName,
-	OpcodeCall:              OpcodeCallName,
-	OpcodeCallIndirect:      OpcodeCallIndirectName,
+	OpcodeUnreachable:  OpcodeUnreachableName,
+	OpcodeNop:          OpcodeNopName,
+	OpcodeBlock:        OpcodeBlockName,
+	OpcodeLoop:         OpcodeLoopName,
+	OpcodeIf:           OpcodeIfName,
+	OpcodeElse:         OpcodeElseName,
+	OpcodeEnd:          OpcodeEndName,
+	OpcodeBr:           OpcodeBrName,
+	OpcodeBrIf:         OpcodeBrIfName,
+	OpcodeBrTable:      OpcodeBrTableName,
+	OpcodeReturn:       OpcodeReturnName,
+	OpcodeCall:         OpcodeCallName,
+	OpcodeCallIndirect: OpcodeCallIndirectName,
+
+	OpcodeTailCallReturnCall:         OpcodeTailCallReturnCallName,
+	OpcodeTailCallReturnCallIndirect: OpcodeTailCallReturnCallIndirectName,
+
+	OpcodeThrow:    OpcodeThrowName,
+	OpcodeThrowRef: OpcodeThrowRefName,
+	OpcodeTryTable: OpcodeTryTableName,
+
+	OpcodeCallRef:       OpcodeCallRefName,
+	OpcodeReturnCallRef: OpcodeReturnCallRefName,
+	OpcodeRefAsNonNull:  OpcodeRefAsNonNullName,
+	OpcodeBrOnNull:      OpcodeBrOnNullName,
+	OpcodeBrOnNonNull:   OpcodeBrOnNonNullName,
+	OpcodeRefEq:         OpcodeRefEqName,
+	OpcodeGCPrefix:      OpcodeGCPrefixName,
+
 	OpcodeDrop:              OpcodeDropName,
 	OpcodeSelect:            OpcodeSelectName,
 	OpcodeTypedSelect:       OpcodeTypedSelectName,
@@ -1166,6 +1225,39 @@ var instructionNames = [256]string{
 	OpcodeVecPrefix:  OpcodeVecPrefixName,
 }
 
+const (
+	OpcodeTailCallReturnCallName         = "return_call"
+	OpcodeTailCallReturnCallIndirectName = "return_call_indirect"
+)
+
+const (
+	OpcodeThrowName    = "throw"
+	OpcodeThrowRefName = "throw_ref"
+	OpcodeTryTableName = "try_table"
+)
+
+const (
+	OpcodeCallRefName       = "call_ref"
+	OpcodeReturnCallRefName = "return_call_ref"
+	OpcodeRefAsNonNullName  = "ref.as_non_null"
+	OpcodeBrOnNullName      = "br_on_null"
+	OpcodeBrOnNonNullName   = "br_on_non_null"
+	OpcodeRefEqName         = "ref.eq"
+	OpcodeGCPrefixName      = "gc_prefix"
+)
+
+// Catch clause kinds of OpcodeTryTable.
+const (
+	// CatchKindCatch catches exceptions of the given tag, and branches with the payload.
+	CatchKindCatch byte = 0x00
+	// CatchKindCatchRef catches exceptions of the given tag, and branches with the payload followed by exnref.
+	CatchKindCatchRef byte = 0x01
+	// CatchKindCatchAll catches all exceptions, and branches without values.
+	CatchKindCatchAll byte = 0x02
+	// CatchKindCatchAllRef catches all exceptions, and branches with exnref.
+	CatchKindCatchAllRef byte = 0x03
+)
+
 // InstructionName returns the instruction corresponding to this binary Opcode.
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#a7-index-of-instructions
 func InstructionName(oc Opcode) string {
@@ -1704,6 +1796,106 @@ func VectorInstructionName(oc OpcodeVec) (ret string) {
 	return vectorInstructionName[oc]
 }
 
+// OpcodeVecRelaxed represents an opcode of the relaxed vector instructions, which are prefixed by OpcodeVecPrefix as
+// other vector instructions are, but whose opcodes don't fit in a single byte. The opcode is encoded as a two-byte
+// LEB128 unsigned integer, and the second byte is always 0x02.
+//
+// These opcodes are toggled with CoreFeaturesRelaxedSIMD.
+type OpcodeVecRelaxed = uint32
+
+const (
+	OpcodeVecI8x16RelaxedSwizzle           OpcodeVecRelaxed = 0x100
+	OpcodeVecI32x4RelaxedTruncF32x4S       OpcodeVecRelaxed = 0x101
+	OpcodeVecI32x4RelaxedTruncF32x4U       OpcodeVecRelaxed = 0x102
+	OpcodeVecI32x4RelaxedTruncF64x2SZero   OpcodeVecRelaxed = 0x103
+	OpcodeVecI32x4RelaxedTruncF64x2UZero   OpcodeVecRelaxed = 0x104
+	OpcodeVecF32x4RelaxedMadd              OpcodeVecRelaxed = 0x105
+	OpcodeVecF32x4RelaxedNmadd             OpcodeVecRelaxed = 0x106
+	OpcodeVecF64x2RelaxedMadd              OpcodeVecRelaxed = 0x107
+	OpcodeVecF64x2RelaxedNmadd             OpcodeVecRelaxed = 0x108
+	OpcodeVecI8x16RelaxedLaneselect        OpcodeVecRelaxed = 0x109
+	OpcodeVecI16x8RelaxedLaneselect        OpcodeVecRelaxed = 0x10a
+	OpcodeVecI32x4RelaxedLaneselect        OpcodeVecRelaxed = 0x10b
+	OpcodeVecI64x2RelaxedLaneselect        OpcodeVecRelaxed = 0x10c
+	OpcodeVecF32x4RelaxedMin               OpcodeVecRelaxed = 0x10d
+	OpcodeVecF32x4RelaxedMax               OpcodeVecRelaxed = 0x10e
+	OpcodeVecF64x2RelaxedMin               OpcodeVecRelaxed = 0x10f
+	OpcodeVecF64x2RelaxedMax               OpcodeVecRelaxed = 0x110
+	OpcodeVecI16x8RelaxedQ15mulrS          OpcodeVecRelaxed = 0x111
+	OpcodeVecI16x8RelaxedDotI8x16I7x16S    OpcodeVecRelaxed = 0x112
+	OpcodeVecI32x4RelaxedDotI8x16I7x16AddS OpcodeVecRelaxed = 0x113
+)
+
+// vecRelaxedOpcodeSecondByte is the second byte of the LEB128 encoding of all the OpcodeVecRelaxed.
+const vecRelaxedOpcodeSecondByte = 0x02
+
+// RelaxedVectorOpcode returns the OpcodeVecRelaxed encoded at the beginning of body, which starts right after
+// OpcodeVecPrefix, and true. This returns false if body starts with a non-relaxed vector instruction, whose opcode
+// is then body[0].
+//
+// Note: the opcode of a non-relaxed vector instruction is either a single byte below 0x80 or two bytes of which the
+// second one is 0x01, and such a trailing byte is treated as OpcodeNop.
+func RelaxedVectorOpcode(body []byte) (OpcodeVecRelaxed, bool) {
+	if len(body) < 2 || body[0]&0x80 == 0 || body[1] != vecRelaxedOpcodeSecondByte {
+		return 0, false
+	}
+	return OpcodeVecRelaxed(body[0]&0x7f) | vecRelaxedOpcodeSecondByte<<7, true
+}
+
+const (
+	OpcodeVecI8x16RelaxedSwizzleName           = "i8x16.relaxed_swizzle"
+	OpcodeVecI32x4RelaxedTruncF32x4SName       = "i32x4.relaxed_trunc_f32x4_s"
+	OpcodeVecI32x4RelaxedTruncF32x4UName       = "i32x4.relaxed_trunc_f32x4_u"
+	OpcodeVecI32x4RelaxedTruncF64x2SZeroName   = "i32x4.relaxed_trunc_f64x2_s_zero"
+	OpcodeVecI32x4RelaxedTruncF64x2UZeroName   = "i32x4.relaxed_trunc_f64x2_u_zero"
+	OpcodeVecF32x4RelaxedMaddName              = "f32x4.relaxed_madd"
+	OpcodeVecF32x4RelaxedNmaddName             = "f32x4.relaxed_nmadd"
+	OpcodeVecF64x2RelaxedMaddName              = "f64x2.relaxed_madd"
+	OpcodeVecF64x2RelaxedNmaddName             = "f64x2.relaxed_nmadd"
+	OpcodeVecI8x16RelaxedLaneselectName        = "i8x16.relaxed_laneselect"
+	OpcodeVecI16x8RelaxedLaneselectName        = "i16x8.relaxed_laneselect"
+	OpcodeVecI32x4RelaxedLaneselectName        = "i32x4.relaxed_laneselect"
+	OpcodeVecI64x2RelaxedLaneselectName        = "i64x2.relaxed_laneselect"
+	OpcodeVecF32x4RelaxedMinName               = "f32x4.relaxed_min"
+	OpcodeVecF32x4RelaxedMaxName               = "f32x4.relaxed_max"
+	OpcodeVecF64x2RelaxedMinName               = "f64x2.relaxed_min"
+	OpcodeVecF64x2RelaxedMaxName               = "f64x2.relaxed_max"
+	OpcodeVecI16x8RelaxedQ15mulrSName          = "i16x8.relaxed_q15mulr_s"
+	OpcodeVecI16x8RelaxedDotI8x16I7x16SName    = "i16x8.relaxed_dot_i8x16_i7x16_s"
+	OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName = "i32x4.relaxed_dot_i8x16_i7x16_add_s"
+)
+
+var relaxedVectorInstructionNames = [...]string{
+	OpcodeVecI8x16RelaxedSwizzle - 0x100:           OpcodeVecI8x16RelaxedSwizzleName,
+	OpcodeVecI32x4RelaxedTruncF32x4S - 0x100:       OpcodeVecI32x4RelaxedTruncF32x4SName,
+	OpcodeVecI32x4RelaxedTruncF32x4U - 0x100:       OpcodeVecI32x4RelaxedTruncF32x4UName,
+	OpcodeVecI32x4RelaxedTruncF64x2SZero - 0x100:   OpcodeVecI32x4RelaxedTruncF64x2SZeroName,
+	OpcodeVecI32x4RelaxedTruncF64x2UZero - 0x100:   OpcodeVecI32x4RelaxedTruncF64x2UZeroName,
+	OpcodeVecF32x4RelaxedMadd - 0x100:              OpcodeVecF32x4RelaxedMaddName,
+	OpcodeVecF32x4RelaxedNmadd - 0x100:             OpcodeVecF32x4RelaxedNmaddName,
+	OpcodeVecF64x2RelaxedMadd - 0x100:              OpcodeVecF64x2RelaxedMaddName,
+	OpcodeVecF64x2RelaxedNmadd - 0x100:             OpcodeVecF64x2RelaxedNmaddName,
+	OpcodeVecI8x16RelaxedLaneselect - 0x100:        OpcodeVecI8x16RelaxedLaneselectName,
+	OpcodeVecI16x8RelaxedLaneselect - 0x100:        OpcodeVecI16x8RelaxedLaneselectName,
+	OpcodeVecI32x4RelaxedLaneselect - 0x100:        OpcodeVecI32x4RelaxedLaneselectName,
+	OpcodeVecI64x2RelaxedLaneselect - 0x100:        OpcodeVecI64x2RelaxedLaneselectName,
+	OpcodeVecF32x4RelaxedMin - 0x100:               OpcodeVecF32x4RelaxedMinName,
+	OpcodeVecF32x4RelaxedMax - 0x100:               OpcodeVecF32x4RelaxedMaxName,
+	OpcodeVecF64x2RelaxedMin - 0x100:               OpcodeVecF64x2RelaxedMinName,
+	OpcodeVecF64x2RelaxedMax - 0x100:               OpcodeVecF64x2RelaxedMaxName,
+	OpcodeVecI16x8RelaxedQ15mulrS - 0x100:          OpcodeVecI16x8RelaxedQ15mulrSName,
+	OpcodeVecI16x8RelaxedDotI8x16I7x16S - 0x100:    OpcodeVecI16x8RelaxedDotI8x16I7x16SName,
+	OpcodeVecI32x4RelaxedDotI8x16I7x16AddS - 0x100: OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName,
+}
+
+// RelaxedVectorInstructionName returns the instruction name corresponding to the relaxed vector Opcode.
+func RelaxedVectorInstructionName(oc OpcodeVecRelaxed) (ret string) {
+	if i := oc - OpcodeVecI8x16RelaxedSwizzle; i < uint32(len(relaxedVectorInstructionNames)) {
+		ret = relaxedVectorInstructionNames[i]
+	}
+	return
+}
+
 const (
 	OpcodeAtomicMemoryNotifyName = "memory.atomic.notify"
 	OpcodeAtomicMemoryWait32Name = "memory.atomic.wait32"
@@ -1864,3 +2056,119 @@ var atomicInstructionName = map[OpcodeAtomic]string{
 func AtomicInstructionName(oc OpcodeAtomic) (ret string) {
 	return atomicInstructionName[oc]
 }
+
+// OpcodeGC represents an opcode of the struct, array, i31 and cast instructions which has multi-byte encoding and is
+// prefixed by OpcodeGCPrefix. Unlike other prefixed opcodes, this is encoded as a LEB128 unsigned integer.
+//
+// These opcodes are toggled with CoreFeaturesGC.
+type OpcodeGC = uint32
+
+const (
+	OpcodeGCStructNew        OpcodeGC = 0x00
+	OpcodeGCStructNewDefault OpcodeGC = 0x01
+	OpcodeGCStructGet        OpcodeGC = 0x02
+	OpcodeGCStructGetS       OpcodeGC = 0x03
+	OpcodeGCStructGetU       OpcodeGC = 0x04
+	OpcodeGCStructSet        OpcodeGC = 0x05
+	OpcodeGCArrayNew         OpcodeGC = 0x06
+	OpcodeGCArrayNewDefault  OpcodeGC = 0x07
+	OpcodeGCArrayNewFixed    OpcodeGC = 0x08
+	OpcodeGCArrayNewData     OpcodeGC = 0x09
+	OpcodeGCArrayNewElem     OpcodeGC = 0x0a
+	OpcodeGCArrayGet         OpcodeGC = 0x0b
+	OpcodeGCArrayGetS        OpcodeGC = 0x0c
+	OpcodeGCArrayGetU        OpcodeGC = 0x0d
+	OpcodeGCArraySet         OpcodeGC = 0x0e
+	OpcodeGCArrayLen         OpcodeGC = 0x0f
+	OpcodeGCArrayFill        OpcodeGC = 0x10
+	OpcodeGCArrayCopy        OpcodeGC = 0x11
+	OpcodeGCArrayInitData    OpcodeGC = 0x12
+	OpcodeGCArrayInitElem    OpcodeGC = 0x13
+	OpcodeGCRefTest          OpcodeGC = 0x14
+	OpcodeGCRefTestNull      OpcodeGC = 0x15
+	OpcodeGCRefCast          OpcodeGC = 0x16
+	OpcodeGCRefCastNull      OpcodeGC = 0x17
+	OpcodeGCBrOnCast         OpcodeGC = 0x18
+	OpcodeGCBrOnCastFail     OpcodeGC = 0x19
+	OpcodeGCAnyConvertExtern OpcodeGC = 0x1a
+	OpcodeGCExternConvertAny OpcodeGC = 0x1b
+	OpcodeGCRefI31           OpcodeGC = 0x1c
+	OpcodeGCI31GetS          OpcodeGC = 0x1d
+	OpcodeGCI31GetU          OpcodeGC = 0x1e
+)
+
+const (
+	OpcodeGCStructNewName        = "struct.new"
+	OpcodeGCStructNewDefaultName = "struct.new_default"
+	OpcodeGCStructGetName        = "struct.get"
+	OpcodeGCStructGetSName       = "struct.get_s"
+	OpcodeGCStructGetUName       = "struct.get_u"
+	OpcodeGCStructSetName        = "struct.set"
+	OpcodeGCArrayNewName         = "array.new"
+	OpcodeGCArrayNewDefaultName  = "array.new_default"
+	OpcodeGCArrayNewFixedName    = "array.new_fixed"
+	OpcodeGCArrayNewDataName     = "array.new_data"
+	OpcodeGCArrayNewElemName     = "array.new_elem"
+	OpcodeGCArrayGetName         = "array.get"
+	OpcodeGCArrayGetSName        = "array.get_s"
+	OpcodeGCArrayGetUName        = "array.get_u"
+	OpcodeGCArraySetName         = "array.set"
+	OpcodeGCArrayLenName         = "array.len"
+	OpcodeGCArrayFillName        = "array.fill"
+	OpcodeGCArrayCopyName        = "array.copy"
+	OpcodeGCArrayInitDataName    = "array.init_data"
+	OpcodeGCArrayInitElemName    = "array.init_elem"
+	OpcodeGCRefTestName          = "ref.test"
+	OpcodeGCRefTestNullName      = "ref.test_null"
+	OpcodeGCRefCastName          = "ref.cast"
+	OpcodeGCRefCastNullName      = "ref.cast_null"
+	OpcodeGCBrOnCastName         = "br_on_cast"
+	OpcodeGCBrOnCastFailName     = "br_on_cast_fail"
+	OpcodeGCAnyConvertExternName = "any.convert_extern"
+	OpcodeGCExternConvertAnyName = "extern.convert_any"
+	OpcodeGCRefI31Name           = "ref.i31"
+	OpcodeGCI31GetSName          = "i31.get_s"
+	OpcodeGCI31GetUName          = "i31.get_u"
+)
+
+var gcInstructionNames = [...]string{
+	OpcodeGCStructNew:        OpcodeGCStructNewName,
+	OpcodeGCStructNewDefault: OpcodeGCStructNewDefaultName,
+	OpcodeGCStructGet:        OpcodeGCStructGetName,
+	OpcodeGCStructGetS:       OpcodeGCStructGetSName,
+	OpcodeGCStructGetU:       OpcodeGCStructGetUName,
+	OpcodeGCStructSet:        OpcodeGCStructSetName,
+	OpcodeGCArrayNew:         OpcodeGCArrayNewName,
+	OpcodeGCArrayNewDefault:  OpcodeGCArrayNewDefaultName,
+	OpcodeGCArrayNewFixed:    OpcodeGCArrayNewFixedName,
+	OpcodeGCArrayNewData:     OpcodeGCArrayNewDataName,
+	OpcodeGCArrayNewElem:     OpcodeGCArrayNewElemName,
+	OpcodeGCArrayGet:         OpcodeGCArrayGetName,
+	OpcodeGCArrayGetS:        OpcodeGCArrayGetSName,
+	OpcodeGCArrayGetU:        OpcodeGCArrayGetUName,
+	OpcodeGCArraySet:         OpcodeGCArraySetName,
+	OpcodeGCArrayLen:         OpcodeGCArrayLenName,
+	OpcodeGCArrayFill:        OpcodeGCArrayFillName,
+	OpcodeGCArrayCopy:        OpcodeGCArrayCopyName,
+	OpcodeGCArrayInitData:    OpcodeGCArrayInitDataName,
+	OpcodeGCArrayInitElem:    OpcodeGCArrayInitElemName,
+	OpcodeGCRefTest:          OpcodeGCRefTestName,
+	OpcodeGCRefTestNull:      OpcodeGCRefTestNullName,
+	OpcodeGCRefCast:          OpcodeGCRefCastName,
+	OpcodeGCRefCastNull:      OpcodeGCRefCastNullName,
+	OpcodeGCBrOnCast:         OpcodeGCBrOnCastName,
+	OpcodeGCBrOnCastFail:     OpcodeGCBrOnCastFailName,
+	OpcodeGCAnyConvertExtern: OpcodeGCAnyConvertExternName,
+	OpcodeGCExternConvertAny: OpcodeGCExternConvertAnyName,
+	OpcodeGCRefI31:           OpcodeGCRefI31Name,
+	OpcodeGCI31GetS:          OpcodeGCI31GetSName,
+	OpcodeGCI31GetU:          OpcodeGCI31GetUName,
+}
+
+// GCInstructionName returns the instruction name corresponding to the GC Opcode.
+func GCInstructionName(oc OpcodeGC) (ret string) {
+	if oc < uint32(len(gcInstructionNames)) {
+		ret = gcInstructionNames[oc]
+	}
+	return
+}
@@ -25,6 +25,10 @@ const (
 	// MemoryLimitPages is maximum number of pages defined (2^16).
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#grow-mem
 	MemoryLimitPages = uint32(65536)
+	// MemoryLimitPages64 is maximum number of pages of a 64-bit memory (2^31) in wazero. This is much lower than the
+	// 2^48 pages allowed by the specification, and keeps any change of the number of pages in the range of int32.
+	// See https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
+	MemoryLimitPages64 = uint32(1 << 31)
 	// MemoryPageSizeInBits satisfies the relation: "1 << MemoryPageSizeInBits == MemoryPageSize".
 	MemoryPageSizeInBits = 16
 )
@@ -48,6 +52,8 @@ type MemoryInstance struct {
 	Buffer        []byte
 	Min, Cap, Max uint32
 	Shared        bool
+	// Is64 is true if the memory is addressed with 64-bit integers.
+	Is64 bool
 	// definition is known at compile time.
 	definition api.MemoryDefinition
 
@@ -99,6 +105,7 @@ func NewMemoryInstance(memSec *Memory, allocator experimental.MemoryAllocator, m
 		Cap:               memoryBytesNumToPages(uint64(cap(buffer))),
 		Max:               memSec.Max,
 		Shared:            memSec.IsShared,
+		Is64:              memSec.Is64,
 		expBuffer:         expBuffer,
 		ownerModuleEngine: moduleEngine,
 	}
@@ -109,6 +116,14 @@ func (m *MemoryInstance) Definition() api.MemoryDefinition {
 	return m.definition
 }
 
+// addressType returns the type of the operands which address the memory.
+func (m *MemoryInstance) addressType() ValueType {
+	if m.Is64 {
+		return ValueTypeI64
+	}
+	return ValueTypeI32
+}
+
 // Size implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Size() uint32 {
 	return uint32(len(m.Buffer))
@@ -290,7 +305,7 @@ func (m *MemoryInstance) Pages() (result uint32) {
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-instances%E2%91%A0
 func PagesToUnitOfBytes(pages uint32) string {
-	k := pages * 64
+	k := uint64(pages) * 64
 	if k < 1024 {
 		return fmt.Sprintf("%d Ki", k)
 	}
@@ -376,7 +391,7 @@ func (m *MemoryInstance) writeUint64Le(offset uint32, v uint64) bool {
 }
 
 // Wait32 suspends the caller until the offset is notified by a different agent.
-func (m *MemoryInstance) Wait32(offset uint32, exp uint32, timeout int64, reader func(mem *MemoryInstance, offset uint32) uint32) uint64 {
+func (m *MemoryInstance) Wait32(offset uint64, exp uint32, timeout int64, reader func(mem *MemoryInstance, offset uint64) uint32) uint64 {
 	w := m.getWaiters(offset)
 	w.mux.Lock()
 
@@ -390,7 +405,7 @@ func (m *MemoryInstance) Wait32(offset uint32, exp uint32, timeout int64, reader
 }
 
 // Wait64 suspends the caller until the offset is notified by a different agent.
-func (m *MemoryInstance) Wait64(offset uint32, exp uint64, timeout int64, reader func(mem *MemoryInstance, offset uint32) uint64) uint64 {
+func (m *MemoryInstance) Wait64(offset uint64, exp uint64, timeout int64, reader func(mem *MemoryInstance, offset uint64) uint64) uint64 {
 	w := m.getWaiters(offset)
 	w.mux.Lock()
 
@@ -439,7 +454,7 @@ func (m *MemoryInstance) wait(w *waiters, timeout int64) uint64 {
 	}
 }
 
-func (m *MemoryInstance) getWaiters(offset uint32) *waiters {
+func (m *MemoryInstance) getWaiters(offset uint64) *waiters {
 	wAny, ok := m.waiters.Load(offset)
 	if !ok {
 		// The first time an address is waited on, simultaneous waits will cause extra allocations.
@@ -452,7 +467,7 @@ func (m *MemoryInstance) getWaiters(offset uint32) *waiters {
 }
 
 // Notify wakes up at most count waiters at the given offset.
-func (m *MemoryInstance) Notify(offset uint32, count uint32) uint32 {
+func (m *MemoryInstance) Notify(offset uint64, count uint32) uint32 {
 	wAny, ok := m.waiters.Load(offset)
 	if !ok {
 		return 0
@@ -474,3 +489,165 @@ func (m *MemoryInstance) Notify(offset uint32, count uint32) uint32 {
 
 	return res
 }
+
+// AsMemory64 implements the same method as documented on experimental.AsMemory64.
+func (m *MemoryInstance) AsMemory64() experimental.Memory64 {
+	return m.As64()
+}
+
+// As64 returns the view of this memory with 64-bit offsets.
+func (m *MemoryInstance) As64() MemoryInstance64 {
+	return MemoryInstance64{m}
+}
+
+// MemoryInstance64 is the view of a MemoryInstance with 64-bit offsets, and implements experimental.Memory64.
+//
+// Note: This has the same size as a pointer, so passing it by value or converting it to an interface doesn't allocate.
+type MemoryInstance64 struct {
+	*MemoryInstance
+}
+
+// compile-time check to ensure MemoryInstance64 implements experimental.Memory64
+var _ experimental.Memory64 = MemoryInstance64{}
+
+// Size implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) Size() uint64 {
+	return uint64(len(m.Buffer))
+}
+
+// Grow implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) Grow(delta uint64) (result uint64, ok bool) {
+	if delta > uint64(MemoryLimitPages64) {
+		return 0, false
+	}
+	previous, ok := m.MemoryInstance.Grow(uint32(delta))
+	return uint64(previous), ok
+}
+
+// ReadUint8 implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) ReadUint8(offset uint64) (byte, bool) {
+	if !m.hasSize(offset, 1) {
+		return 0, false
+	}
+	return m.Buffer[offset], true
+}
+
+// ReadUint16Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) ReadUint16Le(offset uint64) (uint16, bool) {
+	if !m.hasSize(offset, 2) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(m.Buffer[offset : offset+2]), true
+}
+
+// ReadUint32Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) ReadUint32Le(offset uint64) (uint32, bool) {
+	if !m.hasSize(offset, 4) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(m.Buffer[offset : offset+4]), true
+}
+
+// ReadFloat32Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) ReadFloat32Le(offset uint64) (float32, bool) {
+	v, ok := m.ReadUint32Le(offset)
+	if !ok {
+		return 0, false
+	}
+	return math.Float32frombits(v), true
+}
+
+// ReadUint64Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) ReadUint64Le(offset uint64) (uint64, bool) {
+	if !m.hasSize(offset, 8) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(m.Buffer[offset : offset+8]), true
+}
+
+// ReadFloat64Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) ReadFloat64Le(offset uint64) (float64, bool) {
+	v, ok := m.ReadUint64Le(offset)
+	if !ok {
+		return 0, false
+	}
+	return math.Float64frombits(v), true
+}
+
+// Read implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) Read(offset, byteCount uint64) ([]byte, bool) {
+	if !m.hasSize(offset, byteCount) {
+		return nil, false
+	}
+	return m.Buffer[offset : offset+byteCount : offset+byteCount], true
+}
+
+// WriteUint8 implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) WriteUint8(offset uint64, v byte) bool {
+	if !m.hasSize(offset, 1) {
+		return false
+	}
+	m.Buffer[offset] = v
+	return true
+}
+
+// WriteUint16Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) WriteUint16Le(offset uint64, v uint16) bool {
+	if !m.hasSize(offset, 2) {
+		return false
+	}
+	binary.LittleEndian.PutUint16(m.Buffer[offset:], v)
+	return true
+}
+
+// WriteUint32Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) WriteUint32Le(offset uint64, v uint32) bool {
+	if !m.hasSize(offset, 4) {
+		return false
+	}
+	binary.LittleEndian.PutUint32(m.Buffer[offset:], v)
+	return true
+}
+
+// WriteFloat32Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) WriteFloat32Le(offset uint64, v float32) bool {
+	return m.WriteUint32Le(offset, math.Float32bits(v))
+}
+
+// WriteUint64Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) WriteUint64Le(offset uint64, v uint64) bool {
+	if !m.hasSize(offset, 8) {
+		return false
+	}
+	binary.LittleEndian.PutUint64(m.Buffer[offset:], v)
+	return true
+}
+
+// WriteFloat64Le implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) WriteFloat64Le(offset uint64, v float64) bool {
+	return m.WriteUint64Le(offset, math.Float64bits(v))
+}
+
+// Write implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) Write(offset uint64, val []byte) bool {
+	if !m.hasSize(offset, uint64(len(val))) {
+		return false
+	}
+	copy(m.Buffer[offset:], val)
+	return true
+}
+
+// WriteString implements the same method as documented on experimental.Memory64.
+func (m MemoryInstance64) WriteString(offset uint64, val string) bool {
+	if !m.hasSize(offset, uint64(len(val))) {
+		return false
+	}
+	copy(m.Buffer[offset:], val)
+	return true
+}
+
+// hasSize returns true if Len is sufficient for byteCount at the given offset.
+func (m MemoryInstance64) hasSize(offset uint64, byteCount uint64) bool {
+	size := uint64(len(m.Buffer))
+	return byteCount <= size && offset <= size-byteCount // prevents overflow on add
+}
@@ -137,6 +137,36 @@ func TestMemoryInstance_ReadByte(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestMemoryInstance64(t *testing.T) {
+	const pageSize = uint64(MemoryPageSize)
+	m := &MemoryInstance{Buffer: make([]byte, pageSize), Min: 1, Cap: 1, Max: 3, Is64: true, ownerModuleEngine: &mockModuleEngine{}}
+	mem := experimental.AsMemory64(m)
+	require.Equal(t, m.As64(), mem)
+	require.Equal(t, pageSize, mem.Size())
+
+	require.True(t, mem.WriteUint64Le(pageSize-8, math.MaxUint64))
+	v, ok := mem.ReadUint64Le(pageSize - 8)
+	require.True(t, ok)
+	require.Equal(t, uint64(math.MaxUint64), v)
+
+	// Offsets which don't fit in 32 bits must not be truncated.
+	for _, offset := range []uint64{pageSize, math.MaxUint32 + 1, math.MaxUint64} {
+		_, ok = mem.ReadUint8(offset)
+		require.False(t, ok)
+		require.False(t, mem.WriteUint8(offset, 1))
+	}
+	_, ok = mem.Read(math.MaxUint64, 2)
+	require.False(t, ok)
+
+	// Deltas which don't fit in 32 bits must not be truncated.
+	_, ok = mem.Grow(math.MaxUint32 + 2)
+	require.False(t, ok)
+	previous, ok := mem.Grow(2)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), previous)
+	require.Equal(t, 3*pageSize, mem.Size())
+}
+
 func TestPagesToUnitOfBytes(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -166,7 +196,7 @@ func TestPagesToUnitOfBytes(t *testing.T) {
 		{
 			name:     "max uint32",
 			pages:    math.MaxUint32,
-			expected: "3 Ti",
+			expected: "255 Ti",
 		},
 	}
 
@@ -877,8 +907,8 @@ func TestNewMemoryInstance_Shared(t *testing.T) {
 }
 
 func TestMemoryInstance_WaitNotifyOnce(t *testing.T) {
-	reader := func(mem *MemoryInstance, offset uint32) uint32 {
-		val, _ := mem.ReadUint32Le(offset)
+	reader := func(mem *MemoryInstance, offset uint64) uint32 {
+		val, _ := mem.As64().ReadUint32Le(offset)
 		return val
 	}
 	t.Run("no waiters", func(t *testing.T) {
@@ -989,7 +1019,7 @@ func notifyWaiters(t *testing.T, mem *MemoryInstance, offset, count, exp int) {
 		if tries > 100 {
 			t.Fatal("too many tries waiting for wait and notify to converge")
 		}
-		n := mem.Notify(uint32(offset), uint32(count))
+		n := mem.Notify(uint64(offset), uint32(count))
 		cur += int(n)
 		time.Sleep(1 * time.Millisecond)
 		tries++
@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -36,6 +36,13 @@ type Module struct {
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#types%E2%91%A0%E2%91%A0
 	TypeSection []FunctionType
 
+	// CompositeTypes is nil unless the type section uses the struct and array types, subtyping or recursion groups of
+	// experimental.CoreFeaturesGC. Otherwise, this is index-correlated with TypeSection.
+	//
+	// Note: The TypeSection entry of a struct or array type is an empty FunctionType, which cannot be used as the
+	// type of functions, blocks or tags. See IsFunctionType.
+	CompositeTypes []CompositeType
+
 	// ImportSection contains imported functions, tables, memories or globals required for instantiation
 	// (Store.Instantiate).
 	//
@@ -45,12 +52,13 @@ type Module struct {
 	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#import-section%E2%91%A0
 	ImportSection []Import
-	// ImportFunctionCount ImportGlobalCount ImportMemoryCount, and ImportTableCount are
+	// ImportFunctionCount ImportGlobalCount ImportMemoryCount, ImportTableCount and ImportTagCount are
 	// the cached import count per ExternType set during decoding.
 	ImportFunctionCount,
 	ImportGlobalCount,
 	ImportMemoryCount,
-	ImportTableCount Index
+	ImportTableCount,
+	ImportTagCount Index
 	// ImportPerModule maps a module name to the list of Import to be imported from the module.
 	// This is used to do fast import resolution during instantiation.
 	ImportPerModule map[string][]*Import
@@ -86,16 +94,26 @@ type Module struct {
 	// MemorySection contains each memory defined in this module.
 	//
 	// Note: The memory Index space begins with imported memories and ends with those defined in this module.
-	// For example, if there are two imported memories and one defined in this module, the memory Index 3 is defined in
-	// this module at TableSection[0].
+	// For example, if there are two imported memories and one defined in this module, the memory Index 2 is defined in
+	// this module at MemorySection[0].
 	//
 	// Note: Version 1.0 (20191205) of the WebAssembly spec allows at most one memory definition per module, so the
-	// length of the MemorySection can be zero or one, and can only be one if there is no imported memory.
+	// length of the MemorySection can be zero or one, and can only be one if there is no imported memory. This is
+	// relaxed by experimental.CoreFeaturesMultiMemory.
 	//
 	// Note: In the Binary Format, this is SectionIDMemory.
 	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-section%E2%91%A0
-	MemorySection *Memory
+	MemorySection []Memory
+
+	// TagSection contains the index in TypeSection of each tag defined in this module.
+	//
+	// Note: The tag Index space begins with imported tags and ends with those defined in this module.
+	//
+	// Note: In the Binary Format, this is SectionIDTag.
+	//
+	// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md#tag-section
+	TagSection []Index
 
 	// GlobalSection contains each global defined in this module.
 	//
@@ -172,6 +190,33 @@ type Module struct {
 	// IsHostModule true if this is the host module, false otherwise.
 	IsHostModule bool
 
+	// FuelCosts is non-nil when the functions of this module consume fuel. See FuelCost.
+	//
+	// Note: This must be set before AssignModuleID, as it changes the compiled code.
+	FuelCosts *experimental.FuelCosts
+
+	// Profiling is true when the functions of this module are compiled with the safepoints where the sampling
+	// profiler can take the stack samples. See experimental/profiling.
+	//
+	// Note: This must be set before AssignModuleID, as it changes the compiled code.
+	Profiling bool
+
+	// Debugging is true when the functions of this module are compiled with the hooks where the debugger can stop
+	// before each instruction. See experimental/debugging.
+	//
+	// Note: This must be set before AssignModuleID, as it changes the compiled code.
+	Debugging bool
+
+	// Coverage is true when the functions of this module are compiled with the counters of the blocks executed. See
+	// experimental/coverage.
+	//
+	// Note: This must be set before AssignModuleID, as it changes the compiled code.
+	Coverage bool
+
+	// DebugBinary is the Wasm binary of the module, which is only retained when Debugging so that the debuggers can
+	// serve it to the clients reading the code.
+	DebugBinary []byte
+
 	// functionDefinitionSectionInitOnce guards FunctionDefinitionSection so that it is initialized exactly once.
 	functionDefinitionSectionInitOnce sync.Once
 
@@ -181,12 +226,87 @@ type Module struct {
 	// MemoryDefinitionSection is a wazero-specific section.
 	MemoryDefinitionSection []MemoryDefinition
 
+	// TableDefinitionSection is a wazero-specific section.
+	TableDefinitionSection []TableDefinition
+
+	// vectorInstructions is set by Validate if any function has vector instructions. See UsesV128.
+	vectorInstructions bool
+
+	// gcInstructions is set by Validate if any function has instructions or block types introduced by
+	// experimental.CoreFeaturesGC. See UsesGC.
+	gcInstructions bool
+
+	// tailCallInstructions is set by Validate if any function has instructions introduced by
+	// experimental.CoreFeaturesTailCall. See UsesTailCall.
+	tailCallInstructions bool
+
 	// DWARFLines is used to emit DWARF based stack trace. This is created from the multiple custom sections
 	// as described in https://yurydelendik.github.io/webassembly-dwarf/, though it is not specified in the Wasm
 	// specification: https://github.com/WebAssembly/debugging/issues/1
 	DWARFLines *wasmdebug.DWARFLines
 }
 
+// UsesV128 returns true if the module has any value of ValueTypeV128, which requires the engine to support
+// api.CoreFeatureSIMD. This is only valid after Validate.
+func (m *Module) UsesV128() bool {
+	return m.vectorInstructions || m.hasValueType(func(vt ValueType) bool { return vt == ValueTypeV128 })
+}
+
+// UsesGC returns true if the module has any type or instruction introduced by experimental.CoreFeaturesGC, which
+// requires the engine to support it. This is only valid after Validate.
+func (m *Module) UsesGC() bool {
+	return m.gcInstructions || m.CompositeTypes != nil || m.hasValueType(isGCValueType)
+}
+
+// UsesTailCall returns true if the module has any instruction introduced by experimental.CoreFeaturesTailCall. This is
+// only valid after Validate.
+func (m *Module) UsesTailCall() bool {
+	return m.tailCallInstructions
+}
+
+// hasValueType returns true if any value type of the module other than the ones in the function bodies satisfies f.
+func (m *Module) hasValueType(f func(ValueType) bool) bool {
+	for i := range m.TypeSection {
+		t := &m.TypeSection[i]
+		if slices.ContainsFunc(t.Params, f) || slices.ContainsFunc(t.Results, f) {
+			return true
+		}
+	}
+	for i := range m.ImportSection {
+		switch imp := &m.ImportSection[i]; imp.Type {
+		case ExternTypeGlobal:
+			if f(imp.DescGlobal.ValType) {
+				return true
+			}
+		case ExternTypeTable:
+			if f(imp.DescTable.Type) {
+				return true
+			}
+		}
+	}
+	for i := range m.GlobalSection {
+		if f(m.GlobalSection[i].Type.ValType) {
+			return true
+		}
+	}
+	for i := range m.TableSection {
+		if f(m.TableSection[i].Type) {
+			return true
+		}
+	}
+	for i := range m.ElementSection {
+		if f(m.ElementSection[i].Type) {
+			return true
+		}
+	}
+	for i := range m.CodeSection {
+		if slices.ContainsFunc(m.CodeSection[i].LocalTypes, f) {
+			return true
+		}
+	}
+	return false
+}
+
 // ModuleID represents sha256 hash value uniquely assigned to Module.
 type ModuleID = [sha256.Size]byte
 
@@ -214,6 +334,24 @@ func (m *Module) AssignModuleID(wasm []byte, listeners []experimental.FunctionLi
 	// Write the flag of ensureTermination to the checksum.
 	m.ID[0] = boolToByte(withEnsureTermination)
 	h.Write(m.ID[:1])
+	// Write the flag of the profiling safepoints to the checksum only if enabled, to keep the existing IDs stable.
+	if m.Profiling {
+		h.Write([]byte("profiling"))
+	}
+	// Likewise, write the flag of the debugger hooks only if enabled.
+	if m.Debugging {
+		h.Write([]byte("debugging"))
+	}
+	if m.Coverage {
+		h.Write([]byte("coverage"))
+	}
+	// Write the fuel costs to the checksum if the fuel is metered.
+	if c := m.FuelCosts; c != nil {
+		for i, cost := range [...]uint32{c.Control, c.Call, c.Variable, c.Memory, c.Reference, c.Numeric, c.Vector} {
+			binary.LittleEndian.PutUint32(m.ID[i*4:], cost)
+		}
+		h.Write(m.ID[:28])
+	}
 	// Get checksum by passing the slice underlying m.ID.
 	h.Sum(m.ID[:0])
 }
@@ -257,17 +395,48 @@ func (m *Module) typeOfFunction(funcIdx Index) *FunctionType {
 	return &m.TypeSection[typeIdx]
 }
 
+// TagType returns the wasm.FunctionType for the given tag space index or nil.
+func (m *Module) TagType(tagIdx Index) *FunctionType {
+	typeIdx, ok := m.TagTypeIndex(tagIdx)
+	if !ok {
+		return nil
+	}
+	return &m.TypeSection[typeIdx]
+}
+
+// TagTypeIndex returns the index in TypeSection of the type of the given tag space index, or false if out of range.
+func (m *Module) TagTypeIndex(tagIdx Index) (typeIdx Index, ok bool) {
+	if tagIdx < m.ImportTagCount {
+		for i := range m.ImportSection {
+			imp := &m.ImportSection[i]
+			if imp.Type == ExternTypeTag && imp.IndexPerType == tagIdx {
+				typeIdx = imp.DescTag
+				break
+			}
+		}
+	} else if tagSectionIdx := tagIdx - m.ImportTagCount; tagSectionIdx < uint32(len(m.TagSection)) {
+		typeIdx = m.TagSection[tagSectionIdx]
+	} else {
+		return 0, false
+	}
+	return typeIdx, typeIdx < uint32(len(m.TypeSection))
+}
+
 func (m *Module) Validate(enabledFeatures api.CoreFeatures) error {
 	for i := range m.TypeSection {
 		tp := &m.TypeSection[i]
 		tp.CacheNumInUint64()
 	}
 
+	if err := m.validateCompositeTypes(); err != nil {
+		return err
+	}
+
 	if err := m.validateStartSection(); err != nil {
 		return err
 	}
 
-	functions, globals, memory, tables, err := m.AllDeclarations()
+	functions, globals, memories, tables, err := m.AllDeclarations()
 	if err != nil {
 		return err
 	}
@@ -276,20 +445,24 @@ func (m *Module) Validate(enabledFeatures api.CoreFeatures) error {
 		return err
 	}
 
-	if err = m.validateGlobals(globals, uint32(len(functions)), MaximumGlobals); err != nil {
+	if err = m.validateTags(); err != nil {
 		return err
 	}
 
-	if err = m.validateMemory(memory, globals, enabledFeatures); err != nil {
+	if err = m.validateGlobals(enabledFeatures, globals, uint32(len(functions)), MaximumGlobals); err != nil {
 		return err
 	}
 
-	if err = m.validateExports(enabledFeatures, functions, globals, memory, tables); err != nil {
+	if err = m.validateMemory(memories, globals, enabledFeatures); err != nil {
+		return err
+	}
+
+	if err = m.validateExports(enabledFeatures, functions, globals, memories, tables); err != nil {
 		return err
 	}
 
 	if m.CodeSection != nil {
-		if err = m.validateFunctions(enabledFeatures, functions, globals, memory, tables, MaximumFunctionIndex); err != nil {
+		if err = m.validateFunctions(enabledFeatures, functions, globals, memories, tables, MaximumFunctionIndex); err != nil {
 			return err
 		}
 	} // No need to validate host functions as NewHostModule validates
@@ -320,7 +493,21 @@ func (m *Module) validateStartSection() error {
 	return nil
 }
 
-func (m *Module) validateGlobals(globals []GlobalType, numFuncts, maxGlobals uint32) error {
+func (m *Module) validateTags() error {
+	for i, typeIdx := range m.TagSection {
+		if typeIdx >= uint32(len(m.TypeSection)) {
+			return fmt.Errorf("invalid %s[%d]: type index out of range", SectionIDName(SectionIDTag), i)
+		} else if !m.IsFunctionType(typeIdx) {
+			return fmt.Errorf("invalid %s[%d]: type %d is not a function type", SectionIDName(SectionIDTag), i, typeIdx)
+		}
+		if len(m.TypeSection[typeIdx].Results) > 0 {
+			return fmt.Errorf("invalid %s[%d]: non-empty tag result type", SectionIDName(SectionIDTag), i)
+		}
+	}
+	return nil
+}
+
+func (m *Module) validateGlobals(enabledFeatures api.CoreFeatures, globals []GlobalType, numFuncts, maxGlobals uint32) error {
 	if uint32(len(globals)) > maxGlobals {
 		return fmt.Errorf("too many globals in a module")
 	}
@@ -328,16 +515,22 @@ func (m *Module) validateGlobals(globals []GlobalType, numFuncts, maxGlobals uin
 	// Global initialization constant expression can only reference the imported globals.
 	// See the note on https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#constant-expressions%E2%91%A0
 	importedGlobals := globals[:m.ImportGlobalCount]
+	gc := enabledFeatures.IsEnabled(experimental.CoreFeaturesGC)
 	for i := range m.GlobalSection {
 		g := &m.GlobalSection[i]
-		if err := validateConstExpression(importedGlobals, numFuncts, &g.Init, g.Type.ValType); err != nil {
+		if gc {
+			// With GC, the previously defined globals can also be referenced.
+			// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md#constant-expressions
+			importedGlobals = globals[:m.ImportGlobalCount+Index(i)]
+		}
+		if err := m.validateConstExpression(importedGlobals, numFuncts, &g.Init, g.Type.ValType); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memory *Memory, tables []Table, maximumFunctionIndex uint32) error {
+func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memories []*Memory, tables []Table, maximumFunctionIndex uint32) error {
 	if uint32(len(functions)) > maximumFunctionIndex {
 		return fmt.Errorf("too many functions (%d) in a module", len(functions))
 	}
@@ -366,12 +559,14 @@ func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions [
 	for idx, typeIndex := range m.FunctionSection {
 		if typeIndex >= typeCount {
 			return fmt.Errorf("invalid %s: type section index %d out of range", m.funcDesc(SectionIDFunction, Index(idx)), typeIndex)
+		} else if !m.IsFunctionType(typeIndex) {
+			return fmt.Errorf("invalid %s: type %d is not a function type", m.funcDesc(SectionIDFunction, Index(idx)), typeIndex)
 		}
 		c := &m.CodeSection[idx]
 		if c.GoFunc != nil {
 			continue
 		}
-		if err = m.validateFunction(vs, enabledFeatures, Index(idx), functions, globals, memory, tables, declaredFuncIndexes, br); err != nil {
+		if err = m.validateFunction(vs, enabledFeatures, Index(idx), functions, globals, memories, tables, declaredFuncIndexes, br); err != nil {
 			return fmt.Errorf("invalid %s: %w", m.funcDesc(SectionIDFunction, Index(idx)), err)
 		}
 	}
@@ -417,6 +612,25 @@ func (m *Module) declaredFunctionIndexes() (ret map[Index]struct{}, err error) {
 				return
 			}
 			ret[index] = struct{}{}
+		} else if g.Init.IsExtended() {
+			if err = collectConstExprFunctionIndexes(g.Init.Data, ret); err != nil {
+				err = fmt.Errorf("%s[%d] failed to initialize: %w", SectionIDName(SectionIDGlobal), i, err)
+				return
+			}
+		}
+	}
+
+	for i := range m.TableSection {
+		if init := m.TableSection[i].Init; init != nil {
+			if init.Opcode == OpcodeRefFunc {
+				index, _, _ := leb128.LoadUint32(init.Data)
+				ret[index] = struct{}{}
+			} else if init.IsExtended() {
+				if err = collectConstExprFunctionIndexes(init.Data, ret); err != nil {
+					err = fmt.Errorf("%s[%d] failed to initialize: %w", SectionIDName(SectionIDTable), i, err)
+					return
+				}
+			}
 		}
 	}
 
@@ -431,6 +645,73 @@ func (m *Module) declaredFunctionIndexes() (ret map[Index]struct{}, err error) {
 	return
 }
 
+// collectConstExprFunctionIndexes adds the function indexes referenced by ref.func in the instructions of an
+// extended constant expression to ret.
+func collectConstExprFunctionIndexes(data []byte, ret map[Index]struct{}) error {
+	for pc := 0; pc < len(data); {
+		n, err := constExprInstructionLength(data[pc:])
+		if err != nil {
+			return err
+		}
+		if data[pc] == OpcodeRefFunc {
+			index, _, err := leb128.LoadUint32(data[pc+1:])
+			if err != nil {
+				return err
+			}
+			ret[index] = struct{}{}
+		}
+		pc += n
+	}
+	return nil
+}
+
+// constExprInstructionLength returns the length in bytes of the instruction at the beginning of data, which is in an
+// extended constant expression.
+func constExprInstructionLength(data []byte) (int, error) {
+	var num uint64
+	var err error
+	switch op := data[0]; op {
+	case OpcodeI32Const:
+		_, num, err = leb128.LoadInt32(data[1:])
+	case OpcodeI64Const, OpcodeRefNull:
+		_, num, err = leb128.LoadInt64(data[1:])
+	case OpcodeGlobalGet, OpcodeRefFunc:
+		_, num, err = leb128.LoadUint32(data[1:])
+	case OpcodeF32Const:
+		num = 4
+	case OpcodeF64Const:
+		num = 8
+	case OpcodeVecPrefix:
+		num = 17
+	case OpcodeI32Add, OpcodeI32Sub, OpcodeI32Mul, OpcodeI64Add, OpcodeI64Sub, OpcodeI64Mul:
+	case OpcodeGCPrefix:
+		var gcOp OpcodeGC
+		if gcOp, num, err = leb128.LoadUint32(data[1:]); err != nil {
+			break
+		}
+		immediates := 0
+		switch gcOp {
+		case OpcodeGCStructNew, OpcodeGCStructNewDefault, OpcodeGCArrayNew, OpcodeGCArrayNewDefault:
+			immediates = 1
+		case OpcodeGCArrayNewFixed:
+			immediates = 2
+		}
+		for i := 0; i < immediates; i++ {
+			var n uint64
+			if _, n, err = leb128.LoadUint32(data[1+num:]); err != nil {
+				break
+			}
+			num += n
+		}
+	default:
+		return 0, fmt.Errorf("invalid opcode for const expression: 0x%x", op)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 1 + int(num), nil
+}
+
 func (m *Module) funcDesc(sectionID SectionID, sectionIndex Index) string {
 	// Try to improve the error message by collecting any exports:
 	var exportNames []string
@@ -449,17 +730,22 @@ func (m *Module) funcDesc(sectionID SectionID, sectionIndex Index) string {
 	return fmt.Sprintf("%s[%d] export[%s]", sectionIDName, sectionIndex, strings.Join(exportNames, ","))
 }
 
-func (m *Module) validateMemory(memory *Memory, globals []GlobalType, _ api.CoreFeatures) error {
-	var activeElementCount int
+func (m *Module) validateMemory(memories []*Memory, globals []GlobalType, enabledFeatures api.CoreFeatures) error {
+	if len(memories) > 1 {
+		if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesMultiMemory); err != nil {
+			return fmt.Errorf("at most one memory allowed in module as %w", err)
+		}
+	}
+
 	for i := range m.DataSection {
 		d := &m.DataSection[i]
-		if !d.IsPassive() {
-			activeElementCount++
+		if !d.IsPassive() && d.MemoryIndex >= uint32(len(memories)) {
+			if len(memories) == 0 {
+				return fmt.Errorf("unknown memory")
+			}
+			return fmt.Errorf("%s[%d]: unknown memory %d", SectionIDName(SectionIDData), i, d.MemoryIndex)
 		}
 	}
-	if activeElementCount > 0 && memory == nil {
-		return fmt.Errorf("unknown memory")
-	}
 
 	// Constant expression can only reference imported globals.
 	// https://github.com/WebAssembly/spec/blob/5900d839f38641989a9d8df2df4aee0513365d39/test/core/data.wast#L84-L91
@@ -467,7 +753,7 @@ func (m *Module) validateMemory(memory *Memory, globals []GlobalType, _ api.Core
 	for i := range m.DataSection {
 		d := &m.DataSection[i]
 		if !d.IsPassive() {
-			if err := validateConstExpression(importedGlobals, 0, &d.OffsetExpression, ValueTypeI32); err != nil {
+			if err := m.validateConstExpression(importedGlobals, 0, &d.OffsetExpression, memories[d.MemoryIndex].AddressType()); err != nil {
 				return fmt.Errorf("calculate offset: %w", err)
 			}
 		}
@@ -485,6 +771,8 @@ func (m *Module) validateImports(enabledFeatures api.CoreFeatures) error {
 		case ExternTypeFunc:
 			if int(imp.DescFunc) >= len(m.TypeSection) {
 				return fmt.Errorf("invalid import[%q.%q] function: type index out of range", imp.Module, imp.Name)
+			} else if !m.IsFunctionType(imp.DescFunc) {
+				return fmt.Errorf("invalid import[%q.%q] function: type %d is not a function type", imp.Module, imp.Name, imp.DescFunc)
 			}
 		case ExternTypeGlobal:
 			if !imp.DescGlobal.Mutable {
@@ -493,12 +781,21 @@ func (m *Module) validateImports(enabledFeatures api.CoreFeatures) error {
 			if err := enabledFeatures.RequireEnabled(api.CoreFeatureMutableGlobal); err != nil {
 				return fmt.Errorf("invalid import[%q.%q] global: %w", imp.Module, imp.Name, err)
 			}
+		case ExternTypeTag:
+			if int(imp.DescTag) >= len(m.TypeSection) {
+				return fmt.Errorf("invalid import[%q.%q] tag: type index out of range", imp.Module, imp.Name)
+			} else if !m.IsFunctionType(imp.DescTag) {
+				return fmt.Errorf("invalid import[%q.%q] tag: type %d is not a function type", imp.Module, imp.Name, imp.DescTag)
+			}
+			if len(m.TypeSection[imp.DescTag].Results) > 0 {
+				return fmt.Errorf("invalid import[%q.%q] tag: non-empty tag result type", imp.Module, imp.Name)
+			}
 		}
 	}
 	return nil
 }
 
-func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memory *Memory, tables []Table) error {
+func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memories []*Memory, tables []Table) error {
 	for i := range m.ExportSection {
 		exp := &m.ExportSection[i]
 		index := exp.Index
@@ -518,21 +815,29 @@ func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []I
 				return fmt.Errorf("invalid export[%q] global[%d]: %w", exp.Name, index, err)
 			}
 		case ExternTypeMemory:
-			if index > 0 || memory == nil {
+			if index >= uint32(len(memories)) {
 				return fmt.Errorf("memory for export[%q] out of range", exp.Name)
 			}
 		case ExternTypeTable:
 			if index >= uint32(len(tables)) {
 				return fmt.Errorf("table for export[%q] out of range", exp.Name)
 			}
+		case ExternTypeTag:
+			if index >= m.ImportTagCount+uint32(len(m.TagSection)) {
+				return fmt.Errorf("tag for export[%q] out of range", exp.Name)
+			}
 		}
 	}
 	return nil
 }
 
-func validateConstExpression(globals []GlobalType, numFuncs uint32, expr *ConstantExpression, expectedType ValueType) (err error) {
+func (m *Module) validateConstExpression(globals []GlobalType, numFuncs uint32, expr *ConstantExpression, expectedType ValueType) (err error) {
 	var actualType ValueType
 	switch expr.Opcode {
+	case OpcodeI32Add, OpcodeI32Sub, OpcodeI32Mul, OpcodeI64Add, OpcodeI64Sub, OpcodeI64Mul, OpcodeGCPrefix:
+		if actualType, err = m.validateExtendedConstExpression(globals, numFuncs, expr.Data); err != nil {
+			return err
+		}
 	case OpcodeI32Const:
 		// Treat constants as signed as their interpretation is not yet known per /RATIONALE.md
 		_, _, err = leb128.LoadInt32(expr.Data)
@@ -569,14 +874,9 @@ func validateConstExpression(globals []GlobalType, numFuncs uint32, expr *Consta
 		}
 		actualType = globals[id].ValType
 	case OpcodeRefNull:
-		if len(expr.Data) == 0 {
-			return fmt.Errorf("read reference type for ref.null: %w", io.ErrShortBuffer)
-		}
-		reftype := expr.Data[0]
-		if reftype != RefTypeFuncref && reftype != RefTypeExternref {
-			return fmt.Errorf("invalid type for ref.null: 0x%x", reftype)
+		if actualType, _, err = m.refNullType(expr.Data); err != nil {
+			return err
 		}
-		actualType = reftype
 	case OpcodeRefFunc:
 		index, _, err := leb128.LoadUint32(expr.Data)
 		if err != nil {
@@ -594,13 +894,188 @@ func validateConstExpression(globals []GlobalType, numFuncs uint32, expr *Consta
 		return fmt.Errorf("invalid opcode for const expression: 0x%x", expr.Opcode)
 	}
 
-	if actualType != expectedType {
+	if !IsSubtypeValueType(actualType, expectedType) {
 		return fmt.Errorf("const expression type mismatch expected %s but got %s",
 			ValueTypeName(expectedType), ValueTypeName(actualType))
 	}
 	return nil
 }
 
+// refNullType returns the type of the reference pushed by ref.null whose immediate is at the beginning of data,
+// along with the number of bytes of the immediate.
+func (m *Module) refNullType(data []byte) (ValueType, int, error) {
+	if len(data) == 0 {
+		return 0, 0, fmt.Errorf("read reference type for ref.null: %w", io.ErrShortBuffer)
+	}
+	r := bytes.NewReader(data)
+	ht, num, err := leb128.DecodeInt33AsInt64(r)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid type for ref.null: 0x%x", data[0])
+	}
+	if ht < 0 {
+		vt, ok := abstractHeapTypeValueType(HeapType(ht))
+		if !ok {
+			return 0, 0, fmt.Errorf("invalid type for ref.null: 0x%x", data[0])
+		}
+		return vt, int(num), nil
+	}
+	vt, err := m.HeapTypeValueType(HeapType(ht))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid type for ref.null: %w", err)
+	}
+	return vt, int(num), nil
+}
+
+// validateExtendedConstExpression validates the instructions of an extended constant expression, and returns the
+// type of the resulting value.
+func (m *Module) validateExtendedConstExpression(globals []GlobalType, numFuncs uint32, data []byte) (ValueType, error) {
+	var stack []ValueType
+	pop := func(want ValueType) bool {
+		l := len(stack)
+		if l == 0 || !IsSubtypeValueType(stack[l-1], want) {
+			return false
+		}
+		stack = stack[:l-1]
+		return true
+	}
+	for pc := 0; pc < len(data); {
+		op := data[pc]
+		pc++
+		switch op {
+		case OpcodeI32Const:
+			_, num, err := leb128.LoadInt32(data[pc:])
+			if err != nil {
+				return 0, fmt.Errorf("read i32: %w", err)
+			}
+			pc += int(num)
+			stack = append(stack, ValueTypeI32)
+		case OpcodeI64Const:
+			_, num, err := leb128.LoadInt64(data[pc:])
+			if err != nil {
+				return 0, fmt.Errorf("read i64: %w", err)
+			}
+			pc += int(num)
+			stack = append(stack, ValueTypeI64)
+		case OpcodeF32Const:
+			pc += 4
+			stack = append(stack, ValueTypeF32)
+		case OpcodeF64Const:
+			pc += 8
+			stack = append(stack, ValueTypeF64)
+		case OpcodeVecPrefix:
+			// v128.const is the only vector instruction accepted by the decoder.
+			pc += 17
+			stack = append(stack, ValueTypeV128)
+		case OpcodeGlobalGet:
+			id, num, err := leb128.LoadUint32(data[pc:])
+			if err != nil {
+				return 0, fmt.Errorf("read index of global: %w", err)
+			}
+			if uint32(len(globals)) <= id {
+				return 0, fmt.Errorf("global index out of range")
+			}
+			pc += int(num)
+			stack = append(stack, globals[id].ValType)
+		case OpcodeRefNull:
+			vt, num, err := m.refNullType(data[pc:])
+			if err != nil {
+				return 0, err
+			}
+			pc += num
+			stack = append(stack, vt)
+		case OpcodeRefFunc:
+			index, num, err := leb128.LoadUint32(data[pc:])
+			if err != nil {
+				return 0, fmt.Errorf("read i32: %w", err)
+			} else if index >= numFuncs {
+				return 0, fmt.Errorf("ref.func index out of range [%d] with length %d", index, numFuncs-1)
+			}
+			pc += int(num)
+			stack = append(stack, ValueTypeFuncref)
+		case OpcodeI32Add, OpcodeI32Sub, OpcodeI32Mul, OpcodeI64Add, OpcodeI64Sub, OpcodeI64Mul:
+			t := ValueTypeI32
+			if op >= OpcodeI64Add {
+				t = ValueTypeI64
+			}
+			if l := len(stack); l < 2 || stack[l-1] != t || stack[l-2] != t {
+				return 0, fmt.Errorf("type mismatch on %s in const expression", InstructionName(op))
+			}
+			stack = stack[:len(stack)-1]
+		case OpcodeGCPrefix:
+			gcOp, num, err := leb128.LoadUint32(data[pc:])
+			if err != nil {
+				return 0, fmt.Errorf("read gc opcode: %w", err)
+			}
+			pc += int(num)
+			var typeIndex, fixedLen uint32
+			switch gcOp {
+			case OpcodeGCStructNew, OpcodeGCStructNewDefault, OpcodeGCArrayNew, OpcodeGCArrayNewDefault, OpcodeGCArrayNewFixed:
+				if typeIndex, num, err = leb128.LoadUint32(data[pc:]); err != nil {
+					return 0, fmt.Errorf("read type index: %w", err)
+				}
+				pc += int(num)
+				if gcOp == OpcodeGCArrayNewFixed {
+					if fixedLen, num, err = leb128.LoadUint32(data[pc:]); err != nil {
+						return 0, fmt.Errorf("read array length: %w", err)
+					}
+					pc += int(num)
+				}
+			}
+
+			ok := true
+			switch gcOp {
+			case OpcodeGCStructNew, OpcodeGCStructNewDefault:
+				ct, err := m.compositeTypeOf(typeIndex, CompositeTypeKindStruct)
+				if err != nil {
+					return 0, fmt.Errorf("%s: %w", GCInstructionName(gcOp), err)
+				}
+				if gcOp == OpcodeGCStructNew {
+					for i := len(ct.Fields) - 1; ok && i >= 0; i-- {
+						ok = pop(ct.Fields[i].ValueType())
+					}
+				}
+				stack = append(stack, ValueTypeStructref)
+			case OpcodeGCArrayNew, OpcodeGCArrayNewDefault, OpcodeGCArrayNewFixed:
+				ct, err := m.compositeTypeOf(typeIndex, CompositeTypeKindArray)
+				if err != nil {
+					return 0, fmt.Errorf("%s: %w", GCInstructionName(gcOp), err)
+				}
+				switch gcOp {
+				case OpcodeGCArrayNew:
+					ok = pop(ValueTypeI32) && pop(ct.Fields[0].ValueType())
+				case OpcodeGCArrayNewDefault:
+					ok = pop(ValueTypeI32)
+				default:
+					for i := uint32(0); ok && i < fixedLen; i++ {
+						ok = pop(ct.Fields[0].ValueType())
+					}
+				}
+				stack = append(stack, ValueTypeArrayref)
+			case OpcodeGCRefI31:
+				ok = pop(ValueTypeI32)
+				stack = append(stack, ValueTypeI31ref)
+			case OpcodeGCAnyConvertExtern:
+				ok = pop(ValueTypeExternref)
+				stack = append(stack, ValueTypeAnyref)
+			case OpcodeGCExternConvertAny:
+				ok = pop(ValueTypeAnyref)
+				stack = append(stack, ValueTypeExternref)
+			default:
+				return 0, fmt.Errorf("invalid opcode for const expression: %s", GCInstructionName(gcOp))
+			}
+			if !ok {
+				return 0, fmt.Errorf("type mismatch on %s in const expression", GCInstructionName(gcOp))
+			}
+		default:
+			return 0, fmt.Errorf("invalid opcode for const expression: 0x%x", op)
+		}
+	}
+	if len(stack) != 1 {
+		return 0, fmt.Errorf("const expression must result in a single value but was %d values", len(stack))
+	}
+	return stack[0], nil
+}
+
 func (m *Module) validateDataCountSection() (err error) {
 	if m.DataCountSection != nil && int(*m.DataCountSection) != len(m.DataSection) {
 		err = fmt.Errorf("data count section (%d) doesn't match the length of data section (%d)",
@@ -609,9 +1084,7 @@ func (m *Module) validateDataCountSection() (err error) {
 	return
 }
 
-func (m *ModuleInstance) buildGlobals(module *Module, funcRefResolver func(funcIndex Index) Reference) {
-	importedGlobals := m.Globals[:module.ImportGlobalCount]
-
+func (m *ModuleInstance) buildGlobals(module *Module, funcRefResolver func(funcIndex Index) Reference) (err error) {
 	me := m.Engine
 	engineOwnGlobal := me.OwnsGlobals()
 	for i := Index(0); i < Index(len(module.GlobalSection)); i++ {
@@ -623,8 +1096,16 @@ func (m *ModuleInstance) buildGlobals(module *Module, funcRefResolver func(funcI
 		}
 		m.Globals[i+module.ImportGlobalCount] = g
 		g.Type = gs.Type
-		g.initialize(importedGlobals, &gs.Init, funcRefResolver)
+		if m.s != nil && m.s.gcHeap != nil && gs.Init.IsExtended() {
+			// With experimental.CoreFeaturesGC, the preceding globals can be referenced as well as imported ones.
+			if g.Val, g.ValHi, err = m.executeGCConstExpression(m.Globals[:i+module.ImportGlobalCount], gs.Init.Data, funcRefResolver); err != nil {
+				return fmt.Errorf("%s[%d] failed to initialize: %w", SectionIDName(SectionIDGlobal), i, err)
+			}
+			continue
+		}
+		g.initialize(m.Globals[:i+module.ImportGlobalCount], &gs.Init, funcRefResolver)
 	}
+	return
 }
 
 func paramNames(localNames IndirectNameMap, funcIdx uint32, paramLen int) []string {
@@ -648,10 +1129,14 @@ func paramNames(localNames IndirectNameMap, funcIdx uint32, paramLen int) []stri
 }
 
 func (m *ModuleInstance) buildMemory(module *Module, allocator experimental.MemoryAllocator) {
-	memSec := module.MemorySection
-	if memSec != nil {
-		m.MemoryInstance = NewMemoryInstance(memSec, allocator, m.Engine)
-		m.MemoryInstance.definition = &module.MemoryDefinitionSection[0]
+	for i := range module.MemorySection {
+		idx := module.ImportMemoryCount + Index(i)
+		mem := NewMemoryInstance(&module.MemorySection[i], allocator, m.Engine)
+		mem.definition = &module.MemoryDefinitionSection[idx]
+		m.Memories[idx] = mem
+	}
+	if len(m.Memories) > 0 {
+		m.MemoryInstance = m.Memories[0]
 	}
 }
 
@@ -757,6 +1242,8 @@ type Import struct {
 	DescMem *Memory
 	// DescGlobal is the inlined GlobalType when Type equals ExternTypeGlobal
 	DescGlobal GlobalType
+	// DescTag is the index in Module.TypeSection when Type equals ExternTypeTag
+	DescTag Index
 	// IndexPerType has the index of this import per ExternType.
 	IndexPerType Index
 }
@@ -768,6 +1255,16 @@ type Memory struct {
 	IsMaxEncoded bool
 	// IsShared true if the memory is shared for access from multiple agents.
 	IsShared bool
+	// Is64 true if the memory is addressed with 64-bit integers.
+	Is64 bool
+}
+
+// AddressType returns the type of the operands which address the memory.
+func (m *Memory) AddressType() ValueType {
+	if m.Is64 {
+		return ValueTypeI64
+	}
+	return ValueTypeI32
 }
 
 // Validate ensures values assigned to Min, Cap and Max are within valid thresholds.
@@ -803,11 +1300,28 @@ type Global struct {
 	Init ConstantExpression
 }
 
+// ConstantExpression is a constant expression, such as the initializer of a global. Opcode and Data are the
+// opcode and the immediates of the single instruction of the expression, unless IsExtended.
 type ConstantExpression struct {
 	Opcode Opcode
 	Data   []byte
 }
 
+// IsExtended returns true if this is an extended constant expression, which consists of multiple instructions.
+// In that case, Opcode is the last instruction, which is one of the integer add, sub or mul instructions, or
+// OpcodeGCPrefix if it is a GC instruction, and Data is the encoding of all the instructions excluding the
+// terminating OpcodeEnd.
+//
+// See https://github.com/WebAssembly/extended-const/blob/main/proposals/extended-const/Overview.md
+// See https://github.com/WebAssembly/gc/blob/main/proposals/gc/MVP.md#constant-expressions
+func (e *ConstantExpression) IsExtended() bool {
+	switch e.Opcode {
+	case OpcodeI32Add, OpcodeI32Sub, OpcodeI32Mul, OpcodeI64Add, OpcodeI64Sub, OpcodeI64Mul, OpcodeGCPrefix:
+		return true
+	}
+	return false
+}
+
 // Export is the binary representation of an export indicated by Type
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-export
 type Export struct {
@@ -849,6 +1363,9 @@ type DataSegment struct {
 	OffsetExpression ConstantExpression
 	Init             []byte
 	Passive          bool
+	// MemoryIndex is the index of the memory which an active data segment is applied to. This is always zero
+	// unless experimental.CoreFeaturesMultiMemory is enabled.
+	MemoryIndex Index
 }
 
 // IsPassive returns true if this data segment is "passive" in the sense that memory offset and
@@ -932,7 +1449,7 @@ type NameMapAssoc struct {
 }
 
 // AllDeclarations returns all declarations for functions, globals, memories and tables in a module including imported ones.
-func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, memory *Memory, tables []Table, err error) {
+func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, memories []*Memory, tables []Table, err error) {
 	for i := range m.ImportSection {
 		imp := &m.ImportSection[i]
 		switch imp.Type {
@@ -941,7 +1458,7 @@ func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, mem
 		case ExternTypeGlobal:
 			globals = append(globals, imp.DescGlobal)
 		case ExternTypeMemory:
-			memory = imp.DescMem
+			memories = append(memories, imp.DescMem)
 		case ExternTypeTable:
 			tables = append(tables, imp.DescTable)
 		}
@@ -952,12 +1469,8 @@ func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, mem
 		g := &m.GlobalSection[i]
 		globals = append(globals, g.Type)
 	}
-	if m.MemorySection != nil {
-		if memory != nil { // shouldn't be possible due to Validate
-			err = errors.New("at most one table allowed in module")
-			return
-		}
-		memory = m.MemorySection
+	for i := range m.MemorySection {
+		memories = append(memories, &m.MemorySection[i])
 	}
 	if m.TableSection != nil {
 		tables = append(tables, m.TableSection...)
@@ -993,6 +1506,11 @@ const (
 	// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#data-count-section
 	// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/appendix/changes.html#bulk-memory-and-table-instructions
 	SectionIDDataCount
+
+	// SectionIDTag may exist when experimental.CoreFeaturesExceptionHandling is enabled.
+	//
+	// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md#tag-section
+	SectionIDTag
 )
 
 // SectionIDName returns the canonical name of a module section.
@@ -1025,6 +1543,8 @@ func SectionIDName(sectionID SectionID) string {
 		return "data"
 	case SectionIDDataCount:
 		return "data_count"
+	case SectionIDTag:
+		return "tag"
 	}
 	return "unknown"
 }
@@ -1038,24 +1558,71 @@ const (
 	ValueTypeF32 = api.ValueTypeF32
 	ValueTypeF64 = api.ValueTypeF64
 	// TODO: ValueTypeV128 is not exposed in the api pkg yet.
-	ValueTypeV128 ValueType = 0x7b
-	// TODO: ValueTypeFuncref is not exposed in the api pkg yet.
-	ValueTypeFuncref   ValueType = 0x70
+	ValueTypeV128      ValueType = 0x7b
+	ValueTypeFuncref             = api.ValueTypeFuncref
 	ValueTypeExternref           = api.ValueTypeExternref
+	// ValueTypeExnref is a reference to an exception, and is toggled with experimental.CoreFeaturesExceptionHandling.
+	ValueTypeExnref ValueType = 0x69
+
+	// The following reference types are toggled with experimental.CoreFeaturesGC. A reference to a concrete struct or
+	// array type is represented by ValueTypeStructref or ValueTypeArrayref respectively. See HeapType.
+
+	// ValueTypeAnyref is the top type of the references to internal objects, i.e. i31, struct and array references.
+	ValueTypeAnyref ValueType = 0x6e
+	// ValueTypeEqref is a reference which can be compared with ref.eq.
+	ValueTypeEqref ValueType = 0x6d
+	// ValueTypeI31ref is an unboxed 31-bit integer.
+	ValueTypeI31ref ValueType = 0x6c
+	// ValueTypeStructref is a reference to a struct.
+	ValueTypeStructref ValueType = 0x6b
+	// ValueTypeArrayref is a reference to an array.
+	ValueTypeArrayref ValueType = 0x6a
+	// ValueTypeNullref is the bottom type of ValueTypeAnyref, whose only value is null.
+	ValueTypeNullref ValueType = 0x71
+	// ValueTypeNullexternref is the bottom type of ValueTypeExternref, whose only value is null.
+	ValueTypeNullexternref ValueType = 0x72
+	// ValueTypeNullfuncref is the bottom type of ValueTypeFuncref, whose only value is null.
+	ValueTypeNullfuncref ValueType = 0x73
 )
 
 // ValueTypeName is an alias of api.ValueTypeName defined to simplify imports.
 func ValueTypeName(t ValueType) string {
-	if t == ValueTypeFuncref {
+	switch t {
+	case ValueTypeFuncref:
 		return "funcref"
-	} else if t == ValueTypeV128 {
+	case ValueTypeV128:
 		return "v128"
+	case ValueTypeExnref:
+		return "exnref"
+	case ValueTypeAnyref:
+		return "anyref"
+	case ValueTypeEqref:
+		return "eqref"
+	case ValueTypeI31ref:
+		return "i31ref"
+	case ValueTypeStructref:
+		return "structref"
+	case ValueTypeArrayref:
+		return "arrayref"
+	case ValueTypeNullref:
+		return "nullref"
+	case ValueTypeNullexternref:
+		return "nullexternref"
+	case ValueTypeNullfuncref:
+		return "nullfuncref"
 	}
 	return api.ValueTypeName(t)
 }
 
-func isReferenceValueType(vt ValueType) bool {
-	return vt == ValueTypeExternref || vt == ValueTypeFuncref
+// IsReferenceValueType returns true if the value type is a reference type.
+func IsReferenceValueType(vt ValueType) bool {
+	switch vt {
+	case ValueTypeExternref, ValueTypeFuncref, ValueTypeExnref,
+		ValueTypeAnyref, ValueTypeEqref, ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref,
+		ValueTypeNullref, ValueTypeNullexternref, ValueTypeNullfuncref:
+		return true
+	}
+	return false
 }
 
 // ExternType is an alias of api.ExternType defined to simplify imports.
@@ -1070,9 +1637,16 @@ const (
 	ExternTypeMemoryName = api.ExternTypeMemoryName
 	ExternTypeGlobal     = api.ExternTypeGlobal
 	ExternTypeGlobalName = api.ExternTypeGlobalName
+	// ExternTypeTag is toggled with experimental.CoreFeaturesExceptionHandling.
+	// This is not exposed in the api pkg as tags are only accessible via the experimental pkg.
+	ExternTypeTag     ExternType = 0x04
+	ExternTypeTagName            = "tag"
 )
 
 // ExternTypeName is an alias of api.ExternTypeName defined to simplify imports.
 func ExternTypeName(t ValueType) string {
+	if t == ExternTypeTag {
+		return ExternTypeTagName
+	}
 	return api.ExternTypeName(t)
 }
@@ -155,6 +155,12 @@ func (m *ModuleInstance) ensureResourcesClosed(ctx context.Context) (err error)
 		m.Sys = nil
 	}
 
+	for _, mem := range m.Memories {
+		if mem != nil && mem.expBuffer != nil {
+			mem.expBuffer.Free()
+			mem.expBuffer = nil
+		}
+	}
 	if mem := m.MemoryInstance; mem != nil {
 		if mem.expBuffer != nil {
 			mem.expBuffer.Free()
@@ -168,6 +174,10 @@ func (m *ModuleInstance) ensureResourcesClosed(ctx context.Context) (err error)
 		}
 		m.CodeCloser = nil
 	}
+
+	if m.s != nil && m.s.gcHeap != nil {
+		m.s.gcHeap.removeModule(m)
+	}
 	return err
 }
 
@@ -176,29 +186,75 @@ func (m *ModuleInstance) Memory() api.Memory {
 	return m.MemoryInstance
 }
 
+// MemoryAt implements the same method as documented on experimental.MemoryAt.
+func (m *ModuleInstance) MemoryAt(index uint32) api.Memory {
+	if mem := m.memoryInstanceAt(index); mem != nil {
+		return mem
+	}
+	return nil // Don't return a nil *MemoryInstance as a non-nil api.Memory.
+}
+
+// memoryInstanceAt returns the memory instance at the given memory index, or nil if there is none.
+func (m *ModuleInstance) memoryInstanceAt(index Index) *MemoryInstance {
+	if index == 0 {
+		return m.MemoryInstance
+	} else if int(index) < len(m.Memories) {
+		return m.Memories[index]
+	}
+	return nil
+}
+
 // ExportedMemory implements the same method as documented on api.Module.
 func (m *ModuleInstance) ExportedMemory(name string) api.Memory {
-	_, err := m.getExport(name, ExternTypeMemory)
+	exp, err := m.getExport(name, ExternTypeMemory)
 	if err != nil {
 		return nil
 	}
-	// We Assume that we have at most one memory.
-	return m.MemoryInstance
+	if mem := m.memoryInstanceAt(exp.Index); mem != nil {
+		return mem
+	}
+	return nil
 }
 
 // ExportedMemoryDefinitions implements the same method as documented on
 // api.Module.
 func (m *ModuleInstance) ExportedMemoryDefinitions() map[string]api.MemoryDefinition {
-	// Special case as we currently only support one memory.
-	if mem := m.MemoryInstance; mem != nil {
-		// Now, find out if it is exported
-		for name, exp := range m.Exports {
-			if exp.Type == ExternTypeMemory {
-				return map[string]api.MemoryDefinition{name: mem.definition}
+	ret := map[string]api.MemoryDefinition{}
+	for name, exp := range m.Exports {
+		if exp.Type == ExternTypeMemory {
+			if mem := m.memoryInstanceAt(exp.Index); mem != nil {
+				ret[name] = mem.definition
+			}
+		}
+	}
+	return ret
+}
+
+// ExportedTable implements the same method as documented on api.Module.
+func (m *ModuleInstance) ExportedTable(name string) api.Table {
+	exp, err := m.getExport(name, ExternTypeTable)
+	if err != nil {
+		return nil
+	}
+	t := m.Tables[exp.Index]
+	if t.definition == nil {
+		return nil // Consistent with ExportedTableDefinitions.
+	}
+	return &exportedTable{m: m, t: t}
+}
+
+// ExportedTableDefinitions implements the same method as documented on
+// api.Module.
+func (m *ModuleInstance) ExportedTableDefinitions() map[string]api.TableDefinition {
+	ret := map[string]api.TableDefinition{}
+	for name, exp := range m.Exports {
+		if exp.Type == ExternTypeTable {
+			if d := m.Tables[exp.Index].definition; d != nil {
+				ret[name] = d
 			}
 		}
 	}
-	return map[string]api.MemoryDefinition{}
+	return ret
 }
 
 // ExportedFunction implements the same method as documented on api.Module.
@@ -13,18 +13,23 @@ import (
 // Currently, this is only used by emscripten which needs to do call_indirect-like operation in the host function.
 func (m *ModuleInstance) LookupFunction(t *TableInstance, typeId FunctionTypeID, tableOffset Index) api.Function {
 	fm, index := m.Engine.LookupFunction(t, typeId, tableOffset)
+	return m.functionIn(fm, index)
+}
+
+// functionIn returns the api.Function for the function at the index in fm, which is looked up by m.
+func (m *ModuleInstance) functionIn(fm *ModuleInstance, index Index) api.Function {
 	if source := fm.Source; source.IsHostModule {
 		// This case, the found function is a host function stored in the table. Generally, Engine.NewFunction are only
 		// responsible for calling Wasm-defined functions (not designed for calling Go functions!). Hence we need to wrap
 		// the host function as a special case.
-		def := &source.FunctionDefinitionSection[index]
+		def := source.FunctionDefinition(index)
 		goF := source.CodeSection[index].GoFunc
 		switch typed := goF.(type) {
 		case api.GoFunction:
 			// GoFunction doesn't need looked up module.
-			return &lookedUpGoFunction{def: def, g: goFunctionAsGoModuleFunction(typed)}
+			return &lookedUpGoFunction{def: def, module: fm, g: goFunctionAsGoModuleFunction(typed)}
 		case api.GoModuleFunction:
-			return &lookedUpGoFunction{def: def, lookedUpModule: m, g: typed}
+			return &lookedUpGoFunction{def: def, module: fm, lookedUpModule: m, g: typed}
 		default:
 			panic(fmt.Sprintf("unexpected GoFunc type: %T", goF))
 		}
@@ -37,6 +42,8 @@ func (m *ModuleInstance) LookupFunction(t *TableInstance, typeId FunctionTypeID,
 type lookedUpGoFunction struct {
 	internalapi.WazeroOnly
 	def *FunctionDefinition
+	// module is the host module defining this Go function.
+	module *ModuleInstance
 	// lookedUpModule is the *ModuleInstance from which this Go function is looked up, i.e. owner of the table.
 	lookedUpModule *ModuleInstance
 	g              api.GoModuleFunction
@@ -52,6 +59,9 @@ func goFunctionAsGoModuleFunction(g api.GoFunction) api.GoModuleFunction {
 // Definition implements api.Function.
 func (l *lookedUpGoFunction) Definition() api.FunctionDefinition { return l.def }
 
+// ModuleFunction implements ModuleFunction.
+func (l *lookedUpGoFunction) ModuleFunction() (*ModuleInstance, Index) { return l.module, l.def.index }
+
 // Call implements api.Function.
 func (l *lookedUpGoFunction) Call(ctx context.Context, params ...uint64) ([]uint64, error) {
 	typ := l.def.Functype
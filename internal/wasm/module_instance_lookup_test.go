@@ -21,7 +21,8 @@ func TestModuleInstance_LookupFunction(t *testing.T) {
 				called++
 			})},
 		},
-		FunctionDefinitionSection: []FunctionDefinition{{}, {}},
+		TypeSection:     []FunctionType{{}},
+		FunctionSection: []Index{0, 0},
 	}
 
 	me := &mockModuleEngine{
@@ -36,7 +37,7 @@ func TestModuleInstance_LookupFunction(t *testing.T) {
 		gf, ok := m.LookupFunction(nil, 0, 0).(*lookedUpGoFunction)
 		require.True(t, ok)
 		require.Nil(t, gf.lookedUpModule) // GoFunction doesn't need looked up module.
-		require.Equal(t, &hostModule.FunctionDefinitionSection[0], gf.def)
+		require.Equal(t, hostModule.FunctionDefinition(0), gf.def)
 		err := gf.CallWithStack(context.Background(), nil)
 		require.NoError(t, err)
 
@@ -44,7 +45,7 @@ func TestModuleInstance_LookupFunction(t *testing.T) {
 		require.True(t, ok)
 		require.Equal(t, m, gmf.lookedUpModule)
 		require.Equal(t, hostModule.CodeSection[1].GoFunc, gmf.g)
-		require.Equal(t, &hostModule.FunctionDefinitionSection[1], gmf.def)
+		require.Equal(t, hostModule.FunctionDefinition(1), gmf.def)
 		err = gmf.CallWithStack(context.Background(), nil)
 		require.NoError(t, err)
 
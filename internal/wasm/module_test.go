@@ -88,7 +88,7 @@ func TestMemory_Validate(t *testing.T) {
 		{
 			name:        "cap > maxLimit",
 			mem:         &Memory{Min: 2, Cap: math.MaxUint32, Max: 2},
-			expectedErr: "capacity 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "capacity 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "max < min",
@@ -98,12 +98,12 @@ func TestMemory_Validate(t *testing.T) {
 		{
 			name:        "min > limit",
 			mem:         &Memory{Min: math.MaxUint32},
-			expectedErr: "min 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "min 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "max > limit",
 			mem:         &Memory{Max: math.MaxUint32, IsMaxEncoded: true},
-			expectedErr: "max 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "max 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 	}
 
@@ -126,7 +126,7 @@ func TestModule_allDeclarations(t *testing.T) {
 		module            *Module
 		expectedFunctions []Index
 		expectedGlobals   []GlobalType
-		expectedMemory    *Memory
+		expectedMemories  []*Memory
 		expectedTables    []Table
 	}{
 		// Functions.
@@ -174,13 +174,20 @@ func TestModule_allDeclarations(t *testing.T) {
 			module: &Module{
 				ImportSection: []Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Max: 10}}},
 			},
-			expectedMemory: &Memory{Min: 1, Max: 10},
+			expectedMemories: []*Memory{{Min: 1, Max: 10}},
 		},
 		{
 			module: &Module{
-				MemorySection: &Memory{Min: 100},
+				MemorySection: []Memory{{Min: 100}},
 			},
-			expectedMemory: &Memory{Min: 100},
+			expectedMemories: []*Memory{{Min: 100}},
+		},
+		{
+			module: &Module{
+				ImportSection: []Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Max: 10}}},
+				MemorySection: []Memory{{Min: 100}, {Min: 200}},
+			},
+			expectedMemories: []*Memory{{Min: 1, Max: 10}, {Min: 100}, {Min: 200}},
 		},
 		// Tables.
 		{
@@ -200,12 +207,12 @@ func TestModule_allDeclarations(t *testing.T) {
 	for i, tt := range tests {
 		tc := tt
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
-			functions, globals, memory, tables, err := tc.module.AllDeclarations()
+			functions, globals, memories, tables, err := tc.module.AllDeclarations()
 			require.NoError(t, err)
 			require.Equal(t, tc.expectedFunctions, functions)
 			require.Equal(t, tc.expectedGlobals, globals)
 			require.Equal(t, tc.expectedTables, tables)
-			require.Equal(t, tc.expectedMemory, memory)
+			require.Equal(t, tc.expectedMemories, memories)
 		})
 	}
 }
@@ -213,7 +220,7 @@ func TestModule_allDeclarations(t *testing.T) {
 func TestValidateConstExpression(t *testing.T) {
 	t.Run("invalid opcode", func(t *testing.T) {
 		expr := ConstantExpression{Opcode: OpcodeNop}
-		err := validateConstExpression(nil, 0, &expr, valueTypeUnknown)
+		err := (&Module{}).validateConstExpression(nil, 0, &expr, valueTypeUnknown)
 		require.Error(t, err)
 	})
 	for _, vt := range []ValueType{ValueTypeI32, ValueTypeI64, ValueTypeF32, ValueTypeF64} {
@@ -235,7 +242,7 @@ func TestValidateConstExpression(t *testing.T) {
 					expr.Opcode = OpcodeF64Const
 				}
 
-				err := validateConstExpression(nil, 0, &expr, vt)
+				err := (&Module{}).validateConstExpression(nil, 0, &expr, vt)
 				require.NoError(t, err)
 			})
 			t.Run("invalid", func(t *testing.T) {
@@ -251,7 +258,7 @@ func TestValidateConstExpression(t *testing.T) {
 				case ValueTypeF64:
 					expr.Opcode = OpcodeF64Const
 				}
-				err := validateConstExpression(nil, 0, &expr, vt)
+				err := (&Module{}).validateConstExpression(nil, 0, &expr, vt)
 				require.Error(t, err)
 			})
 		})
@@ -259,21 +266,21 @@ func TestValidateConstExpression(t *testing.T) {
 	t.Run("ref types", func(t *testing.T) {
 		t.Run("ref.func", func(t *testing.T) {
 			expr := &ConstantExpression{Data: []byte{5}, Opcode: OpcodeRefFunc}
-			err := validateConstExpression(nil, 10, expr, ValueTypeFuncref)
+			err := (&Module{}).validateConstExpression(nil, 10, expr, ValueTypeFuncref)
 			require.NoError(t, err)
-			err = validateConstExpression(nil, 2, expr, ValueTypeFuncref)
+			err = (&Module{}).validateConstExpression(nil, 2, expr, ValueTypeFuncref)
 			require.EqualError(t, err, "ref.func index out of range [5] with length 1")
 		})
 		t.Run("ref.null", func(t *testing.T) {
-			err := validateConstExpression(nil, 0,
+			err := (&Module{}).validateConstExpression(nil, 0,
 				&ConstantExpression{Data: []byte{ValueTypeFuncref}, Opcode: OpcodeRefNull},
 				ValueTypeFuncref)
 			require.NoError(t, err)
-			err = validateConstExpression(nil, 0,
+			err = (&Module{}).validateConstExpression(nil, 0,
 				&ConstantExpression{Data: []byte{ValueTypeExternref}, Opcode: OpcodeRefNull},
 				ValueTypeExternref)
 			require.NoError(t, err)
-			err = validateConstExpression(nil, 0,
+			err = (&Module{}).validateConstExpression(nil, 0,
 				&ConstantExpression{Data: []byte{0xff}, Opcode: OpcodeRefNull},
 				ValueTypeExternref)
 			require.EqualError(t, err, "invalid type for ref.null: 0xff")
@@ -283,14 +290,14 @@ func TestValidateConstExpression(t *testing.T) {
 		t.Run("failed to read global index", func(t *testing.T) {
 			// Empty data for global index is invalid.
 			expr := &ConstantExpression{Data: make([]byte, 0), Opcode: OpcodeGlobalGet}
-			err := validateConstExpression(nil, 0, expr, valueTypeUnknown)
+			err := (&Module{}).validateConstExpression(nil, 0, expr, valueTypeUnknown)
 			require.Error(t, err)
 		})
 		t.Run("global index out of range", func(t *testing.T) {
 			// Data holds the index in leb128 and this time the value exceeds len(globals) (=0).
 			expr := &ConstantExpression{Data: []byte{1}, Opcode: OpcodeGlobalGet}
 			var globals []GlobalType
-			err := validateConstExpression(globals, 0, expr, valueTypeUnknown)
+			err := (&Module{}).validateConstExpression(globals, 0, expr, valueTypeUnknown)
 			require.Error(t, err)
 		})
 
@@ -303,7 +310,7 @@ func TestValidateConstExpression(t *testing.T) {
 					expr := &ConstantExpression{Data: []byte{0}, Opcode: OpcodeGlobalGet}
 					globals := []GlobalType{{ValType: valueTypeUnknown}}
 
-					err := validateConstExpression(globals, 0, expr, vt)
+					err := (&Module{}).validateConstExpression(globals, 0, expr, vt)
 					require.Error(t, err)
 				})
 			}
@@ -317,12 +324,96 @@ func TestValidateConstExpression(t *testing.T) {
 					expr := &ConstantExpression{Data: []byte{0}, Opcode: OpcodeGlobalGet}
 					globals := []GlobalType{{ValType: vt}}
 
-					err := validateConstExpression(globals, 0, expr, vt)
+					err := (&Module{}).validateConstExpression(globals, 0, expr, vt)
 					require.NoError(t, err)
 				})
 			}
 		})
 	})
+	t.Run("extended", func(t *testing.T) {
+		globals := []GlobalType{{ValType: ValueTypeI32}, {ValType: ValueTypeI64}}
+		tests := []struct {
+			name        string
+			expr        ConstantExpression
+			vt          ValueType
+			expectedErr string
+		}{
+			{
+				name: "i32.add",
+				expr: ConstantExpression{Opcode: OpcodeI32Add, Data: []byte{
+					OpcodeGlobalGet, 0, OpcodeI32Const, 1, OpcodeI32Add,
+				}},
+				vt: ValueTypeI32,
+			},
+			{
+				name: "i64.mul of i64.sub",
+				expr: ConstantExpression{Opcode: OpcodeI64Mul, Data: []byte{
+					OpcodeGlobalGet, 1, OpcodeI64Const, 1, OpcodeI64Sub, OpcodeI64Const, 2, OpcodeI64Mul,
+				}},
+				vt: ValueTypeI64,
+			},
+			{
+				name: "result type mismatch",
+				expr: ConstantExpression{Opcode: OpcodeI32Add, Data: []byte{
+					OpcodeI32Const, 1, OpcodeI32Const, 1, OpcodeI32Add,
+				}},
+				vt:          ValueTypeI64,
+				expectedErr: "const expression type mismatch expected i64 but got i32",
+			},
+			{
+				name: "operand type mismatch",
+				expr: ConstantExpression{Opcode: OpcodeI32Sub, Data: []byte{
+					OpcodeGlobalGet, 1, OpcodeI32Const, 1, OpcodeI32Sub,
+				}},
+				vt:          ValueTypeI32,
+				expectedErr: "type mismatch on i32.sub in const expression",
+			},
+			{
+				name: "missing operand",
+				expr: ConstantExpression{Opcode: OpcodeI32Mul, Data: []byte{
+					OpcodeI32Const, 1, OpcodeI32Mul,
+				}},
+				vt:          ValueTypeI32,
+				expectedErr: "type mismatch on i32.mul in const expression",
+			},
+			{
+				name: "too many values",
+				expr: ConstantExpression{Opcode: OpcodeI32Add, Data: []byte{
+					OpcodeI32Const, 1, OpcodeI32Const, 1, OpcodeI32Const, 1, OpcodeI32Add,
+				}},
+				vt:          ValueTypeI32,
+				expectedErr: "const expression must result in a single value but was 2 values",
+			},
+			{
+				name: "global index out of range",
+				expr: ConstantExpression{Opcode: OpcodeI32Add, Data: []byte{
+					OpcodeGlobalGet, 2, OpcodeI32Const, 1, OpcodeI32Add,
+				}},
+				vt:          ValueTypeI32,
+				expectedErr: "global index out of range",
+			},
+			{
+				name: "invalid opcode",
+				expr: ConstantExpression{Opcode: OpcodeI32Add, Data: []byte{
+					OpcodeI32Const, 1, OpcodeI32Const, 1, OpcodeI32DivS, OpcodeI32Add,
+				}},
+				vt:          ValueTypeI32,
+				expectedErr: "invalid opcode for const expression: 0x6d",
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				err := (&Module{}).validateConstExpression(globals, 0, &tc.expr, tc.vt)
+				if tc.expectedErr == "" {
+					require.NoError(t, err)
+				} else {
+					require.EqualError(t, err, tc.expectedErr)
+				}
+			})
+		}
+	})
 }
 
 func TestModule_Validate_Errors(t *testing.T) {
@@ -396,7 +487,7 @@ func TestModule_validateStartSection(t *testing.T) {
 func TestModule_validateGlobals(t *testing.T) {
 	t.Run("too many globals", func(t *testing.T) {
 		m := Module{}
-		err := m.validateGlobals(make([]GlobalType, 10), 0, 9)
+		err := m.validateGlobals(api.CoreFeaturesV2, make([]GlobalType, 10), 0, 9)
 		require.Error(t, err)
 		require.EqualError(t, err, "too many globals in a module")
 	})
@@ -408,7 +499,7 @@ func TestModule_validateGlobals(t *testing.T) {
 				Init: ConstantExpression{Opcode: OpcodeGlobalGet, Data: []byte{1}},
 			},
 		}}
-		err := m.validateGlobals(nil, 0, 9)
+		err := m.validateGlobals(api.CoreFeaturesV2, nil, 0, 9)
 		require.Error(t, err)
 		require.EqualError(t, err, "global index out of range")
 	})
@@ -419,7 +510,7 @@ func TestModule_validateGlobals(t *testing.T) {
 				Init: ConstantExpression{Opcode: OpcodeUnreachable},
 			},
 		}}
-		err := m.validateGlobals(nil, 0, 9)
+		err := m.validateGlobals(api.CoreFeaturesV2, nil, 0, 9)
 		require.Error(t, err)
 		require.EqualError(t, err, "invalid opcode for const expression: 0x0")
 	})
@@ -430,7 +521,7 @@ func TestModule_validateGlobals(t *testing.T) {
 				Init: ConstantExpression{Opcode: OpcodeI32Const, Data: const0},
 			},
 		}}
-		err := m.validateGlobals(nil, 0, 9)
+		err := m.validateGlobals(api.CoreFeaturesV2, nil, 0, 9)
 		require.NoError(t, err)
 	})
 	t.Run("ok with imported global", func(t *testing.T) {
@@ -449,7 +540,7 @@ func TestModule_validateGlobals(t *testing.T) {
 			{ValType: ValueTypeI32}, // Imported one.
 			{},                      // the local one trying to validate.
 		}
-		err := m.validateGlobals(globalDeclarations, 0, 9)
+		err := m.validateGlobals(api.CoreFeaturesV2, globalDeclarations, 0, 9)
 		require.NoError(t, err)
 	})
 }
@@ -540,6 +631,136 @@ func TestModule_validateFunctions(t *testing.T) {
 	})
 }
 
+func TestModule_UsesV128(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *Module
+		expected bool
+	}{
+		{
+			name:  "no v128",
+			input: &Module{TypeSection: []FunctionType{i32_i32}, GlobalSection: []Global{{Type: GlobalType{ValType: ValueTypeI64}}}},
+		},
+		{
+			name:     "type",
+			input:    &Module{TypeSection: []FunctionType{v_v, {Results: []ValueType{ValueTypeV128}}}},
+			expected: true,
+		},
+		{
+			name:     "imported global",
+			input:    &Module{ImportSection: []Import{{Type: ExternTypeGlobal, DescGlobal: GlobalType{ValType: ValueTypeV128}}}},
+			expected: true,
+		},
+		{
+			name:     "global",
+			input:    &Module{GlobalSection: []Global{{Type: GlobalType{ValType: ValueTypeV128}}}},
+			expected: true,
+		},
+		{
+			name:     "local",
+			input:    &Module{CodeSection: []Code{{LocalTypes: []ValueType{ValueTypeI32, ValueTypeV128}}}},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.input.UsesV128())
+		})
+	}
+
+	t.Run("instruction", func(t *testing.T) {
+		// No v128 value in the types, but the vector instructions are used to compute an i32.
+		m := Module{
+			TypeSection:     []FunctionType{v_v},
+			FunctionSection: []uint32{0},
+			CodeSection: []Code{{Body: []byte{
+				OpcodeVecPrefix, OpcodeVecV128Const, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+				OpcodeVecPrefix, OpcodeVecI32x4ExtractLane, 0,
+				OpcodeDrop, OpcodeEnd,
+			}}},
+		}
+		require.False(t, m.UsesV128())
+		err := m.validateFunctions(api.CoreFeaturesV2, []uint32{0}, nil, nil, nil, MaximumFunctionIndex)
+		require.NoError(t, err)
+		require.True(t, m.UsesV128())
+	})
+}
+
+func TestModule_UsesGC(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *Module
+		expected bool
+	}{
+		{
+			name: "no GC",
+			input: &Module{
+				TypeSection:  []FunctionType{{Params: []ValueType{ValueTypeFuncref, ValueTypeExternref}}},
+				TableSection: []Table{{Type: RefTypeFuncref}},
+			},
+		},
+		{
+			name: "bottom of funcref",
+			input: &Module{
+				TypeSection: []FunctionType{{Results: []ValueType{ValueTypeNullfuncref, ValueTypeNullexternref}}},
+			},
+		},
+		{
+			name:     "composite types",
+			input:    &Module{TypeSection: []FunctionType{{}}, CompositeTypes: []CompositeType{{Kind: CompositeTypeKindStruct}}},
+			expected: true,
+		},
+		{
+			name:     "global",
+			input:    &Module{GlobalSection: []Global{{Type: GlobalType{ValType: ValueTypeAnyref}}}},
+			expected: true,
+		},
+		{
+			name:     "imported table",
+			input:    &Module{ImportSection: []Import{{Type: ExternTypeTable, DescTable: Table{Type: ValueTypeI31ref}}}},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.input.UsesGC())
+		})
+	}
+
+	t.Run("instruction", func(t *testing.T) {
+		// No GC type in the module, but ref.null none is of nullref.
+		m := Module{
+			TypeSection:     []FunctionType{v_v},
+			FunctionSection: []uint32{0},
+			CodeSection:     []Code{{Body: []byte{OpcodeRefNull, 0x71, OpcodeDrop, OpcodeEnd}}},
+		}
+		require.False(t, m.UsesGC())
+		features := api.CoreFeaturesV2 | experimental.CoreFeaturesFunctionReferences | experimental.CoreFeaturesGC
+		err := m.validateFunctions(features, []uint32{0}, nil, nil, nil, MaximumFunctionIndex)
+		require.NoError(t, err)
+		require.True(t, m.UsesGC())
+	})
+}
+
+func TestModule_UsesTailCall(t *testing.T) {
+	m := Module{
+		TypeSection:     []FunctionType{v_v},
+		FunctionSection: []uint32{0, 0},
+		CodeSection: []Code{
+			{Body: []byte{OpcodeCall, 1, OpcodeEnd}},
+			{Body: []byte{OpcodeTailCallReturnCall, 0, OpcodeEnd}},
+		},
+	}
+	require.False(t, m.UsesTailCall())
+	err := m.validateFunctions(api.CoreFeaturesV2|experimental.CoreFeaturesTailCall, []uint32{0, 0}, nil, nil, nil, MaximumFunctionIndex)
+	require.NoError(t, err)
+	require.True(t, m.UsesTailCall())
+}
+
 func TestModule_validateMemory(t *testing.T) {
 	t.Run("active data segment exits but memory not declared", func(t *testing.T) {
 		m := Module{DataSection: []DataSegment{{OffsetExpression: ConstantExpression{}}}}
@@ -553,7 +774,7 @@ func TestModule_validateMemory(t *testing.T) {
 				Opcode: OpcodeUnreachable, // Invalid!
 			},
 		}}}
-		err := m.validateMemory(&Memory{}, nil, api.CoreFeaturesV1)
+		err := m.validateMemory([]*Memory{{}}, nil, api.CoreFeaturesV1)
 		require.EqualError(t, err, "calculate offset: invalid opcode for const expression: 0x0")
 	})
 	t.Run("ok", func(t *testing.T) {
@@ -564,9 +785,38 @@ func TestModule_validateMemory(t *testing.T) {
 				Data:   leb128.EncodeInt32(1),
 			},
 		}}}
-		err := m.validateMemory(&Memory{}, nil, api.CoreFeaturesV1)
+		err := m.validateMemory([]*Memory{{}}, nil, api.CoreFeaturesV1)
 		require.NoError(t, err)
 	})
+	t.Run("multiple memories disabled", func(t *testing.T) {
+		m := Module{}
+		err := m.validateMemory([]*Memory{{}, {}}, nil, api.CoreFeaturesV2)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "at most one memory allowed in module")
+	})
+	t.Run("multiple memories", func(t *testing.T) {
+		m := Module{DataSection: []DataSegment{{
+			MemoryIndex: 1,
+			Init:        []byte{0x1},
+			OffsetExpression: ConstantExpression{
+				Opcode: OpcodeI32Const,
+				Data:   leb128.EncodeInt32(1),
+			},
+		}}}
+		err := m.validateMemory([]*Memory{{}, {}}, nil, api.CoreFeaturesV2|experimental.CoreFeaturesMultiMemory)
+		require.NoError(t, err)
+	})
+	t.Run("data segment unknown memory", func(t *testing.T) {
+		m := Module{DataSection: []DataSegment{{
+			MemoryIndex: 2,
+			OffsetExpression: ConstantExpression{
+				Opcode: OpcodeI32Const,
+				Data:   leb128.EncodeInt32(1),
+			},
+		}}}
+		err := m.validateMemory([]*Memory{{}, {}}, nil, api.CoreFeaturesV2|experimental.CoreFeaturesMultiMemory)
+		require.EqualError(t, err, "data[0]: unknown memory 2")
+	})
 }
 
 func TestModule_validateImports(t *testing.T) {
@@ -651,7 +901,7 @@ func TestModule_validateExports(t *testing.T) {
 		exportSection   []Export
 		functions       []Index
 		globals         []GlobalType
-		memory          *Memory
+		memories        []*Memory
 		tables          []Table
 		expectedErr     string
 	}{
@@ -718,7 +968,7 @@ func TestModule_validateExports(t *testing.T) {
 			name:            "memory",
 			enabledFeatures: api.CoreFeaturesV1,
 			exportSection:   []Export{{Type: ExternTypeMemory, Index: 0}},
-			memory:          &Memory{},
+			memories:        []*Memory{{}},
 		},
 		{
 			name:            "memory out of range",
@@ -733,7 +983,7 @@ func TestModule_validateExports(t *testing.T) {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
 			m := Module{ExportSection: tc.exportSection}
-			err := m.validateExports(tc.enabledFeatures, tc.functions, tc.globals, tc.memory, tc.tables)
+			err := m.validateExports(tc.enabledFeatures, tc.functions, tc.globals, tc.memories, tc.tables)
 			if tc.expectedErr != "" {
 				require.EqualError(t, err, tc.expectedErr)
 			} else {
@@ -846,15 +1096,27 @@ func TestModule_buildMemoryInstance(t *testing.T) {
 		min := uint32(1)
 		max := uint32(10)
 		mDef := MemoryDefinition{moduleName: "foo"}
-		m := ModuleInstance{}
+		m := ModuleInstance{Memories: make([]*MemoryInstance, 1)}
 		m.buildMemory(&Module{
-			MemorySection:           &Memory{Min: min, Cap: min, Max: max},
+			MemorySection:           []Memory{{Min: min, Cap: min, Max: max}},
 			MemoryDefinitionSection: []MemoryDefinition{mDef},
 		}, nil)
 		mem := m.MemoryInstance
 		require.Equal(t, min, mem.Min)
 		require.Equal(t, max, mem.Max)
 		require.Equal(t, &mDef, mem.definition)
+		require.Equal(t, mem, m.Memories[0])
+	})
+	t.Run("multiple", func(t *testing.T) {
+		m := ModuleInstance{Memories: make([]*MemoryInstance, 3)}
+		m.buildMemory(&Module{
+			ImportMemoryCount:       1,
+			MemorySection:           []Memory{{Min: 1, Cap: 1, Max: 1}, {Min: 2, Cap: 2, Max: 2}},
+			MemoryDefinitionSection: []MemoryDefinition{{}, {}, {}},
+		}, nil)
+		require.Nil(t, m.Memories[0])
+		require.Equal(t, uint32(1), m.Memories[1].Min)
+		require.Equal(t, uint32(2), m.Memories[2].Min)
 	})
 }
 
@@ -1088,6 +1350,23 @@ func TestModule_AssignModuleID(t *testing.T) {
 		require.False(t, exist, i)
 		exists[id] = struct{}{}
 	}
+
+	// Ensures that the fuel costs also produce different IDs.
+	for i, costs := range []experimental.FuelCosts{{}, {Control: 1}, {Vector: 1}, experimental.DefaultFuelCosts()} {
+		m := Module{FuelCosts: &costs}
+		m.AssignModuleID([]byte{1, 2, 3}, nil, false)
+		_, exist := exists[m.ID]
+		require.False(t, exist, i)
+		exists[m.ID] = struct{}{}
+	}
+
+	// Ensures that the debugger hooks and the coverage counters also produce different IDs.
+	for i, m := range []*Module{{Debugging: true}, {Coverage: true}, {Debugging: true, Coverage: true}} {
+		m.AssignModuleID([]byte{1, 2, 3}, nil, false)
+		_, exist := exists[m.ID]
+		require.False(t, exist, i)
+		exists[m.ID] = struct{}{}
+	}
 }
 
 type mockListener struct{}
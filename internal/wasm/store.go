@@ -14,6 +14,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/internalapi"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
 	"github.com/tetratelabs/wazero/sys"
 )
 
@@ -60,6 +61,13 @@ type (
 		// Note: this is fixed to 2^27 but have this a field for testability.
 		functionMaxTypes uint32
 
+		// gcTypes maps the key of each recursion group to its canonical GCType. See canonicalGCTypes.
+		gcTypes map[string][]*GCType // guarded by mux
+
+		// gcHeap holds the structs and arrays allocated by modules, and is nil unless experimental.CoreFeaturesGC
+		// is enabled.
+		gcHeap *GCHeap
+
 		// mux is used to guard the fields from concurrent access.
 		mux sync.RWMutex
 	}
@@ -74,11 +82,18 @@ type (
 	ModuleInstance struct {
 		internalapi.WazeroOnlyType
 
-		ModuleName     string
-		Exports        map[string]*Export
-		Globals        []*GlobalInstance
+		ModuleName string
+		Exports    map[string]*Export
+		Globals    []*GlobalInstance
+		// MemoryInstance is the memory at index zero, or nil if the module has no memory.
 		MemoryInstance *MemoryInstance
-		Tables         []*TableInstance
+		// Memories holds the memory instances, indexed by memory index. Imported
+		// memories come first.
+		Memories []*MemoryInstance
+		Tables   []*TableInstance
+		// Tags holds the tag instances, indexed by tag index. Imported tags
+		// come first.
+		Tags []*TagInstance
 
 		// Engine implements function calls for this module.
 		Engine ModuleEngine
@@ -87,6 +102,10 @@ type (
 		// This is necessary to achieve fast runtime type checking for indirect function calls at runtime.
 		TypeIDs []FunctionTypeID
 
+		// GCTypes is index-correlated with types and holds the canonical GCType of struct and array types, which is
+		// used at runtime to allocate and cast objects. This is nil unless the module defines such types.
+		GCTypes []*GCType
+
 		// DataInstances holds data segments bytes of the module.
 		// This is only used by bulk memory operations.
 		//
@@ -174,7 +193,7 @@ func (m *ModuleInstance) GetFunctionTypeID(t *FunctionType) FunctionTypeID {
 func (m *ModuleInstance) buildElementInstances(elements []ElementSegment) {
 	m.ElementInstances = make([][]Reference, len(elements))
 	for i, elm := range elements {
-		if elm.Type == RefTypeFuncref && elm.Mode == ElementModePassive {
+		if elm.Mode == ElementModePassive && (elm.Type == RefTypeFuncref || m.s.gcHeap != nil) {
 			// Only passive elements can be access as element instances.
 			// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/syntax/modules.html#element-segments
 			inits := elm.Init
@@ -202,17 +221,7 @@ func (m *ModuleInstance) applyElements(elems []ElementSegment) {
 			len(elem.Init) == 0 {
 			continue
 		}
-		var offset uint32
-		if elem.OffsetExpr.Opcode == OpcodeGlobalGet {
-			// Ignore error as it's already validated.
-			globalIdx, _, _ := leb128.LoadUint32(elem.OffsetExpr.Data)
-			global := m.Globals[globalIdx]
-			offset = uint32(global.Val)
-		} else {
-			// Ignore error as it's already validated.
-			o, _, _ := leb128.LoadInt32(elem.OffsetExpr.Data)
-			offset = uint32(o)
-		}
+		offset := uint32(executeConstExpressionI32(m.Globals, &elem.OffsetExpr))
 
 		table := m.Tables[elem.TableIndex]
 		references := table.References
@@ -256,9 +265,7 @@ func (m *ModuleInstance) validateData(data []DataSegment) (err error) {
 	for i := range data {
 		d := &data[i]
 		if !d.IsPassive() {
-			offset := int(executeConstExpressionI32(m.Globals, &d.OffsetExpression))
-			ceil := offset + len(d.Init)
-			if offset < 0 || ceil > len(m.MemoryInstance.Buffer) {
+			if _, ok := m.dataOffset(d, m.memoryInstanceAt(d.MemoryIndex)); !ok {
 				return fmt.Errorf("%s[%d]: out of bounds memory access", SectionIDName(SectionIDData), i)
 			}
 		}
@@ -275,16 +282,33 @@ func (m *ModuleInstance) applyData(data []DataSegment) error {
 		d := &data[i]
 		m.DataInstances[i] = d.Init
 		if !d.IsPassive() {
-			offset := executeConstExpressionI32(m.Globals, &d.OffsetExpression)
-			if offset < 0 || int(offset)+len(d.Init) > len(m.MemoryInstance.Buffer) {
+			mem := m.memoryInstanceAt(d.MemoryIndex)
+			offset, ok := m.dataOffset(d, mem)
+			if !ok {
 				return fmt.Errorf("%s[%d]: out of bounds memory access", SectionIDName(SectionIDData), i)
 			}
-			copy(m.MemoryInstance.Buffer[offset:], d.Init)
+			copy(mem.Buffer[offset:], d.Init)
 		}
 	}
 	return nil
 }
 
+// dataOffset returns the offset of the active data segment in the given memory, and false if the segment doesn't fit
+// in the memory. The offset is an i64 for a 64-bit memory, and an i32 otherwise.
+func (m *ModuleInstance) dataOffset(d *DataSegment, mem *MemoryInstance) (offset uint64, ok bool) {
+	if mem.Is64 {
+		offset = uint64(executeConstExpressionI64(m.Globals, &d.OffsetExpression))
+	} else {
+		offset32 := executeConstExpressionI32(m.Globals, &d.OffsetExpression)
+		if offset32 < 0 {
+			return 0, false
+		}
+		offset = uint64(offset32)
+	}
+	ceil := offset + uint64(len(d.Init))
+	return offset, ceil >= offset && ceil <= uint64(len(mem.Buffer))
+}
+
 // GetExport returns an export of the given name and type or errs if not exported or the wrong type.
 func (m *ModuleInstance) getExport(name string, et ExternType) (*Export, error) {
 	exp, ok := m.Exports[name]
@@ -298,7 +322,7 @@ func (m *ModuleInstance) getExport(name string, et ExternType) (*Export, error)
 }
 
 func NewStore(enabledFeatures api.CoreFeatures, engine Engine) *Store {
-	return &Store{
+	s := &Store{
 		nameToModule:     map[string]*ModuleInstance{},
 		nameToModuleCap:  nameToModuleShrinkThreshold,
 		EnabledFeatures:  enabledFeatures,
@@ -306,6 +330,10 @@ func NewStore(enabledFeatures api.CoreFeatures, engine Engine) *Store {
 		typeIDs:          map[string]FunctionTypeID{},
 		functionMaxTypes: maximumFunctionTypes,
 	}
+	if enabledFeatures.IsEnabled(experimental.CoreFeaturesGC) {
+		s.gcHeap = NewGCHeap()
+	}
+	return s
 }
 
 // Instantiate uses name instead of the Module.NameSection ModuleName as it allows instantiating the same module under
@@ -348,6 +376,8 @@ func (s *Store) instantiate(
 
 	m.Tables = make([]*TableInstance, int(module.ImportTableCount)+len(module.TableSection))
 	m.Globals = make([]*GlobalInstance, int(module.ImportGlobalCount)+len(module.GlobalSection))
+	m.Tags = make([]*TagInstance, int(module.ImportTagCount)+len(module.TagSection))
+	m.Memories = make([]*MemoryInstance, int(module.ImportMemoryCount)+len(module.MemorySection))
 	m.Engine, err = s.Engine.NewModuleEngine(module, m)
 	if err != nil {
 		return nil, err
@@ -366,7 +396,31 @@ func (s *Store) instantiate(
 
 	allocator, _ := ctx.Value(expctxkeys.MemoryAllocatorKey{}).(experimental.MemoryAllocator)
 
-	m.buildGlobals(module, m.Engine.FunctionInstanceReference)
+	h, gcInitializing := s.gcHeap, false
+	if h != nil {
+		// Constant expressions can allocate objects, so collection is suspended as if a function call is in
+		// progress until the module instance holds them.
+		m.GCTypes = s.canonicalGCTypes(module)
+		h.addModule(m)
+		h.EnterCall(nil)
+		gcInitializing = true
+		defer func() {
+			if gcInitializing {
+				h.ExitCall(nil)
+			}
+			if err != nil {
+				h.removeModule(m)
+			}
+		}()
+	}
+
+	if err = m.buildGlobals(module, m.Engine.FunctionInstanceReference); err != nil {
+		return nil, err
+	}
+	if err = m.initTables(module); err != nil {
+		return nil, err
+	}
+	m.buildTags(module)
 	m.buildMemory(module, allocator)
 	m.Exports = module.Exports
 	for _, exp := range m.Exports {
@@ -395,6 +449,11 @@ func (s *Store) instantiate(
 
 	m.applyElements(module.ElementSection)
 
+	if gcInitializing {
+		h.ExitCall(nil)
+		gcInitializing = false
+	}
+
 	m.Engine.DoneInstantiation()
 
 	// Execute the start function.
@@ -480,7 +539,13 @@ func (m *ModuleInstance) resolveImports(ctx context.Context, module *Module) (er
 				importedTable.involvingModuleInstancesMutex.Unlock()
 			case ExternTypeMemory:
 				expected := i.DescMem
-				importedMemory := importedModule.MemoryInstance
+				importedMemory := importedModule.memoryInstanceAt(imported.Index)
+
+				if expected.Is64 != importedMemory.Is64 {
+					err = errorInvalidImport(i, fmt.Errorf("address type mismatch: %s != %s",
+						ValueTypeName(expected.AddressType()), ValueTypeName(importedMemory.addressType())))
+					return
+				}
 
 				if expected.Min > memoryBytesNumToPages(uint64(len(importedMemory.Buffer))) {
 					err = errorMinSizeMismatch(i, expected.Min, importedMemory.Min)
@@ -491,8 +556,11 @@ func (m *ModuleInstance) resolveImports(ctx context.Context, module *Module) (er
 					err = errorMaxSizeMismatch(i, expected.Max, importedMemory.Max)
 					return
 				}
-				m.MemoryInstance = importedMemory
-				m.Engine.ResolveImportedMemory(importedModule.Engine)
+				m.Memories[i.IndexPerType] = importedMemory
+				if i.IndexPerType == 0 {
+					m.MemoryInstance = importedMemory
+				}
+				m.Engine.ResolveImportedMemory(i.IndexPerType, imported.Index, importedModule.Engine)
 			case ExternTypeGlobal:
 				expected := i.DescGlobal
 				importedGlobal := importedModule.Globals[imported.Index]
@@ -509,6 +577,14 @@ func (m *ModuleInstance) resolveImports(ctx context.Context, module *Module) (er
 					return
 				}
 				m.Globals[i.IndexPerType] = importedGlobal
+			case ExternTypeTag:
+				expected := &module.TypeSection[i.DescTag]
+				importedTag := importedModule.Tags[imported.Index]
+				if !importedTag.Type.EqualsSignature(expected.Params, expected.Results) {
+					err = errorInvalidImport(i, fmt.Errorf("tag type mismatch: %s != %s", expected, importedTag.Type))
+					return
+				}
+				m.Tags[i.IndexPerType] = importedTag
 			}
 		}
 	}
@@ -542,10 +618,197 @@ func executeConstExpressionI32(importedGlobals []*GlobalInstance, expr *Constant
 		id, _, _ := leb128.LoadUint32(expr.Data)
 		g := importedGlobals[id]
 		ret = int32(g.Val)
+	default:
+		if expr.IsExtended() {
+			ret = int32(executeExtendedConstExpression(importedGlobals, expr.Data))
+		}
+	}
+	return
+}
+
+func executeConstExpressionI64(importedGlobals []*GlobalInstance, expr *ConstantExpression) (ret int64) {
+	switch expr.Opcode {
+	case OpcodeI64Const:
+		ret, _, _ = leb128.LoadInt64(expr.Data)
+	case OpcodeGlobalGet:
+		id, _, _ := leb128.LoadUint32(expr.Data)
+		g := importedGlobals[id]
+		ret = int64(g.Val)
+	default:
+		if expr.IsExtended() {
+			ret = int64(executeExtendedConstExpression(importedGlobals, expr.Data))
+		}
 	}
 	return
 }
 
+// executeExtendedConstExpression executes the instructions of an extended constant expression, and returns the
+// resulting value. As with executeConstExpressionI32, the expression must have been validated.
+func executeExtendedConstExpression(importedGlobals []*GlobalInstance, data []byte) uint64 {
+	var stack []uint64
+	for pc := 0; pc < len(data); {
+		op := data[pc]
+		pc++
+		switch op {
+		case OpcodeI32Const:
+			v, num, _ := leb128.LoadInt32(data[pc:])
+			pc += int(num)
+			stack = append(stack, uint64(uint32(v)))
+		case OpcodeI64Const:
+			v, num, _ := leb128.LoadInt64(data[pc:])
+			pc += int(num)
+			stack = append(stack, uint64(v))
+		case OpcodeGlobalGet:
+			id, num, _ := leb128.LoadUint32(data[pc:])
+			pc += int(num)
+			stack = append(stack, importedGlobals[id].Val)
+		default:
+			x1, x2 := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			var v uint64
+			switch op {
+			case OpcodeI32Add, OpcodeI64Add:
+				v = x1 + x2
+			case OpcodeI32Sub, OpcodeI64Sub:
+				v = x1 - x2
+			case OpcodeI32Mul, OpcodeI64Mul:
+				v = x1 * x2
+			}
+			if op < OpcodeI64Add {
+				v = uint64(uint32(v))
+			}
+			stack[len(stack)-1] = v
+		}
+	}
+	return stack[0]
+}
+
+// executeGCConstExpression executes the instructions of an extended constant expression with
+// experimental.CoreFeaturesGC, which can allocate structs and arrays, and returns the resulting value. As with
+// executeConstExpressionI32, the expression must have been validated. The upper 64 bits are only used by a v128 value.
+func (m *ModuleInstance) executeGCConstExpression(globals []*GlobalInstance, data []byte, funcRefResolver func(funcIndex Index) Reference) (lo, hi uint64, err error) {
+	// A v128 value occupies two entries in the stack as the values on the stack of function calls.
+	var stack []uint64
+	pop := func(n int) []uint64 {
+		ret := stack[len(stack)-n:]
+		stack = stack[:len(stack)-n]
+		return ret
+	}
+	popField := func(f *FieldType, dst []uint64) {
+		copy(dst, pop(fieldSize(f)))
+		dst[0] = PackFieldValue(f, dst[0])
+	}
+	var isV128 bool
+	for pc := 0; pc < len(data); {
+		op := data[pc]
+		n, _ := constExprInstructionLength(data[pc:])
+		immediates := data[pc+1 : pc+n]
+		pc += n
+		isV128 = false
+		switch op {
+		case OpcodeI32Const:
+			v, _, _ := leb128.LoadInt32(immediates)
+			stack = append(stack, uint64(uint32(v)))
+		case OpcodeI64Const:
+			v, _, _ := leb128.LoadInt64(immediates)
+			stack = append(stack, uint64(v))
+		case OpcodeF32Const:
+			stack = append(stack, uint64(binary.LittleEndian.Uint32(immediates)))
+		case OpcodeF64Const:
+			stack = append(stack, binary.LittleEndian.Uint64(immediates))
+		case OpcodeVecPrefix:
+			// The first byte of the immediates is the opcode OpcodeVecV128Const.
+			stack = append(stack, binary.LittleEndian.Uint64(immediates[1:9]), binary.LittleEndian.Uint64(immediates[9:17]))
+			isV128 = true
+		case OpcodeGlobalGet:
+			id, _, _ := leb128.LoadUint32(immediates)
+			g := globals[id]
+			stack = append(stack, g.Val)
+			if isV128 = g.Type.ValType == ValueTypeV128; isV128 {
+				stack = append(stack, g.ValHi)
+			}
+		case OpcodeRefNull:
+			stack = append(stack, 0)
+		case OpcodeRefFunc:
+			id, _, _ := leb128.LoadUint32(immediates)
+			stack = append(stack, uint64(funcRefResolver(id)))
+		case OpcodeI32Add, OpcodeI32Sub, OpcodeI32Mul, OpcodeI64Add, OpcodeI64Sub, OpcodeI64Mul:
+			x2 := pop(1)[0]
+			x1 := &stack[len(stack)-1]
+			switch op {
+			case OpcodeI32Add, OpcodeI64Add:
+				*x1 += x2
+			case OpcodeI32Sub, OpcodeI64Sub:
+				*x1 -= x2
+			case OpcodeI32Mul, OpcodeI64Mul:
+				*x1 *= x2
+			}
+			if op < OpcodeI64Add {
+				*x1 = uint64(uint32(*x1))
+			}
+		case OpcodeGCPrefix:
+			gcOp, num, _ := leb128.LoadUint32(immediates)
+			immediates = immediates[num:]
+			var t *GCType
+			switch gcOp {
+			case OpcodeGCStructNew, OpcodeGCStructNewDefault, OpcodeGCArrayNew, OpcodeGCArrayNewDefault, OpcodeGCArrayNewFixed:
+				typeIndex, num, _ := leb128.LoadUint32(immediates)
+				immediates = immediates[num:]
+				t = m.GCTypes[typeIndex]
+			}
+
+			heap := m.s.gcHeap
+			var ref uint64
+			switch gcOp {
+			case OpcodeGCStructNew, OpcodeGCStructNewDefault:
+				var obj *GCObject
+				ref, obj = heap.Allocate(t, t.size)
+				if gcOp == OpcodeGCStructNew {
+					for i := len(t.Fields) - 1; i >= 0; i-- {
+						popField(&t.Fields[i], obj.Values[t.offsets[i]:])
+					}
+				}
+			case OpcodeGCArrayNew, OpcodeGCArrayNewDefault, OpcodeGCArrayNewFixed:
+				var length uint32
+				if gcOp == OpcodeGCArrayNewFixed {
+					length, _, _ = leb128.LoadUint32(immediates)
+				} else {
+					length = uint32(pop(1)[0])
+				}
+				if uint64(length)*uint64(t.size) > MaximumGCObjectSize {
+					return 0, 0, wasmruntime.ErrRuntimeArrayTooLarge
+				}
+				var obj *GCObject
+				ref, obj = heap.Allocate(t, int(length)*t.size)
+				elem := &t.Fields[0]
+				switch gcOp {
+				case OpcodeGCArrayNew:
+					init := make([]uint64, t.size)
+					popField(elem, init)
+					for i := 0; i < len(obj.Values); i += t.size {
+						copy(obj.Values[i:], init)
+					}
+				case OpcodeGCArrayNewFixed:
+					for i := int(length) - 1; i >= 0; i-- {
+						popField(elem, obj.Values[i*t.size:])
+					}
+				}
+			case OpcodeGCRefI31:
+				ref = GCRefI31(uint32(pop(1)[0]))
+			case OpcodeGCAnyConvertExtern:
+				ref = heap.AnyConvertExtern(pop(1)[0])
+			case OpcodeGCExternConvertAny:
+				ref = heap.ExternConvertAny(pop(1)[0])
+			}
+			stack = append(stack, ref)
+		}
+	}
+	if isV128 {
+		return stack[0], stack[1], nil
+	}
+	return stack[0], 0, nil
+}
+
 // initialize initializes the value of this global instance given the const expr and imported globals.
 // funcRefResolver is called to get the actual funcref (engine specific) from the OpcodeRefFunc const expr.
 //
@@ -579,7 +842,7 @@ func (g *GlobalInstance) initialize(importedGlobals []*GlobalInstance, expr *Con
 			g.Val = importedG.Val
 		case ValueTypeV128:
 			g.Val, g.ValHi = importedG.Val, importedG.ValHi
-		case ValueTypeFuncref, ValueTypeExternref:
+		default: // Reference types.
 			g.Val = importedG.Val
 		}
 	case OpcodeRefNull:
@@ -592,6 +855,10 @@ func (g *GlobalInstance) initialize(importedGlobals []*GlobalInstance, expr *Con
 		g.Val = uint64(funcRefResolver(v))
 	case OpcodeVecV128Const:
 		g.Val, g.ValHi = binary.LittleEndian.Uint64(expr.Data[0:8]), binary.LittleEndian.Uint64(expr.Data[8:16])
+	default:
+		if expr.IsExtended() {
+			g.Val = executeExtendedConstExpression(importedGlobals, expr.Data)
+		}
 	}
 }
 
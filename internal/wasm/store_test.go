@@ -32,14 +32,14 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory not exported, one page",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 			},
 		},
 		{
 			name: "memory exported, different name",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				ExportSection:           []Export{{Type: ExternTypeMemory, Name: "momory", Index: 0}},
 			},
@@ -47,7 +47,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, but zero length",
 			input: &Module{
-				MemorySection:           &Memory{},
+				MemorySection:           []Memory{{}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				Exports:                 map[string]*Export{"memory": {Type: ExternTypeMemory, Name: "memory"}},
 			},
@@ -56,7 +56,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, one page",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				Exports:                 map[string]*Export{"memory": {Type: ExternTypeMemory, Name: "memory"}},
 			},
@@ -66,7 +66,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, two pages",
 			input: &Module{
-				MemorySection:           &Memory{Min: 2, Cap: 2},
+				MemorySection:           []Memory{{Min: 2, Cap: 2}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				Exports:                 map[string]*Export{"memory": {Type: ExternTypeMemory, Name: "memory"}},
 			},
@@ -153,7 +153,7 @@ func TestStore_CloseWithExitCode(t *testing.T) {
 				ImportFunctionCount:     1,
 				TypeSection:             []FunctionType{v_v},
 				ImportSection:           []Import{{Type: ExternTypeFunc, Module: importedModuleName, Name: "fn", DescFunc: 0}},
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				GlobalSection:           []Global{{Type: GlobalType{}, Init: ConstantExpression{Opcode: OpcodeI32Const, Data: const1}}},
 				TableSection:            []Table{{Min: 10}},
@@ -200,7 +200,7 @@ func TestStore_hammer(t *testing.T) {
 		TypeSection:             []FunctionType{v_v},
 		FunctionSection:         []uint32{0},
 		CodeSection:             []Code{{Body: []byte{OpcodeEnd}}},
-		MemorySection:           &Memory{Min: 1, Cap: 1},
+		MemorySection:           []Memory{{Min: 1, Cap: 1}},
 		MemoryDefinitionSection: []MemoryDefinition{{}},
 		GlobalSection: []Global{{
 			Type: GlobalType{ValType: ValueTypeI32},
@@ -259,7 +259,7 @@ func TestStore_hammer_close(t *testing.T) {
 		TypeSection:             []FunctionType{v_v},
 		FunctionSection:         []uint32{0},
 		CodeSection:             []Code{{Body: []byte{OpcodeEnd}}},
-		MemorySection:           &Memory{Min: 1, Cap: 1},
+		MemorySection:           []Memory{{Min: 1, Cap: 1}},
 		MemoryDefinitionSection: []MemoryDefinition{{}},
 		GlobalSection: []Global{{
 			Type: GlobalType{ValType: ValueTypeI32},
@@ -450,6 +450,11 @@ func (e *mockModuleEngine) LookupFunction(_ *TableInstance, _ FunctionTypeID, of
 	return nil, 0
 }
 
+// LookupFunctionReference implements the same method as documented on wasm.ModuleEngine.
+func (e *mockModuleEngine) LookupFunctionReference(Reference) (*ModuleInstance, Index) {
+	return nil, 0
+}
+
 // CompiledModuleCount implements the same method as documented on wasm.Engine.
 func (e *mockEngine) CompiledModuleCount() uint32 { return 0 }
 
@@ -484,13 +489,18 @@ func (e *mockModuleEngine) FunctionInstanceReference(i Index) Reference {
 	return e.functionRefs[i]
 }
 
+// FunctionReference implements the same method as documented on wasm.ModuleEngine.
+func (e *mockModuleEngine) FunctionReference(m *ModuleInstance, i Index) (Reference, error) {
+	return m.Engine.FunctionInstanceReference(i), nil
+}
+
 // ResolveImportedFunction implements the same method as documented on wasm.ModuleEngine.
 func (e *mockModuleEngine) ResolveImportedFunction(index, _, importedIndex Index, _ ModuleEngine) {
 	e.resolveImportsCalled[index] = importedIndex
 }
 
 // ResolveImportedMemory implements the same method as documented on wasm.ModuleEngine.
-func (e *mockModuleEngine) ResolveImportedMemory(imp ModuleEngine) {
+func (e *mockModuleEngine) ResolveImportedMemory(_, _ Index, imp ModuleEngine) {
 	e.importedMemModEngine = imp
 }
 
@@ -693,6 +703,54 @@ func TestGlobalInstance_initialize(t *testing.T) {
 		require.Equal(t, uint64(0x1), g.Val)
 		require.Equal(t, uint64(0x2), g.ValHi)
 	})
+
+	t.Run("extended", func(t *testing.T) {
+		importedGlobals := []*GlobalInstance{
+			{Type: GlobalType{ValType: ValueTypeI32}, Val: 0xffff_fff0},
+			{Type: GlobalType{ValType: ValueTypeI64}, Val: 10},
+		}
+		tests := []struct {
+			name string
+			expr *ConstantExpression
+			vt   ValueType
+			val  uint64
+		}{
+			{
+				// i32 arithmetic wraps around.
+				name: "i32.add",
+				expr: &ConstantExpression{Opcode: OpcodeI32Add, Data: []byte{
+					OpcodeGlobalGet, 0, OpcodeI32Const, 0x20, OpcodeI32Add,
+				}},
+				vt:  ValueTypeI32,
+				val: 0x10,
+			},
+			{
+				name: "i32.sub",
+				expr: &ConstantExpression{Opcode: OpcodeI32Sub, Data: []byte{
+					OpcodeI32Const, 1, OpcodeI32Const, 2, OpcodeI32Sub,
+				}},
+				vt:  ValueTypeI32,
+				val: 0xffff_ffff,
+			},
+			{
+				name: "i64.mul of i64.sub",
+				expr: &ConstantExpression{Opcode: OpcodeI64Mul, Data: []byte{
+					OpcodeGlobalGet, 1, OpcodeI64Const, 3, OpcodeI64Sub, OpcodeI64Const, 0x7f, OpcodeI64Mul,
+				}},
+				vt:  ValueTypeI64,
+				val: 0xffff_ffff_ffff_fff9, // (10-3) * -1
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				g := &GlobalInstance{Type: GlobalType{ValType: tc.vt}}
+				g.initialize(importedGlobals, tc.expr, nil)
+				require.Equal(t, tc.val, g.Val)
+			})
+		}
+	})
 }
 
 func Test_resolveImports(t *testing.T) {
@@ -850,7 +908,7 @@ func Test_resolveImports(t *testing.T) {
 				ModuleName: moduleName,
 				Engine:     importedME,
 			}
-			m := &ModuleInstance{s: s, Engine: &mockModuleEngine{resolveImportsCalled: map[Index]Index{}}}
+			m := &ModuleInstance{s: s, Memories: make([]*MemoryInstance, 1), Engine: &mockModuleEngine{resolveImportsCalled: map[Index]Index{}}}
 			err := m.resolveImports(context.Background(), &Module{
 				ImportPerModule: map[string][]*Import{
 					moduleName: {{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: &Memory{Max: max}}},
@@ -954,6 +1012,17 @@ func TestModuleInstance_applyData(t *testing.T) {
 		require.Equal(t, []byte{0xa, 0xf, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x5}, m.MemoryInstance.Buffer)
 		require.Equal(t, [][]byte{{0xa, 0xf}, {0x1, 0x5}}, m.DataInstances)
 	})
+	t.Run("multiple memories", func(t *testing.T) {
+		mem0, mem1 := &MemoryInstance{Buffer: make([]byte, 2)}, &MemoryInstance{Buffer: make([]byte, 2)}
+		m := &ModuleInstance{MemoryInstance: mem0, Memories: []*MemoryInstance{mem0, mem1}}
+		err := m.applyData([]DataSegment{
+			{OffsetExpression: ConstantExpression{Opcode: OpcodeI32Const, Data: const0}, Init: []byte{0xa}},
+			{MemoryIndex: 1, OffsetExpression: ConstantExpression{Opcode: OpcodeI32Const, Data: const1}, Init: []byte{0xb}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []byte{0xa, 0x0}, mem0.Buffer)
+		require.Equal(t, []byte{0x0, 0xb}, mem1.Buffer)
+	})
 	t.Run("error", func(t *testing.T) {
 		m := &ModuleInstance{MemoryInstance: &MemoryInstance{Buffer: make([]byte, 5)}}
 		err := m.applyData([]DataSegment{
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/internalapi"
 	"github.com/tetratelabs/wazero/internal/leb128"
 )
 
@@ -14,6 +15,9 @@ type Table struct {
 	Min  uint32
 	Max  *uint32
 	Type RefType
+	// Init is the initial value of the elements, or nil if they are null. This is only set with
+	// experimental.CoreFeaturesFunctionReferences.
+	Init *ConstantExpression
 }
 
 // RefType is either RefTypeFuncref or RefTypeExternref as of WebAssembly core 2.0.
@@ -24,6 +28,8 @@ const (
 	RefTypeFuncref = ValueTypeFuncref
 	// RefTypeExternref represents a reference to a host object, which is not currently supported in wazero.
 	RefTypeExternref = ValueTypeExternref
+	// RefTypeExnref represents a reference to an exception. See experimental.CoreFeaturesExceptionHandling.
+	RefTypeExnref = ValueTypeExnref
 )
 
 func RefTypeName(t RefType) (ret string) {
@@ -32,6 +38,8 @@ func RefTypeName(t RefType) (ret string) {
 		ret = "funcref"
 	case RefTypeExternref:
 		ret = "externref"
+	case RefTypeExnref:
+		ret = "exnref"
 	default:
 		ret = fmt.Sprintf("unknown(0x%x)", t)
 	}
@@ -57,7 +65,8 @@ const (
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#syntax-elem
 type ElementSegment struct {
 	// OffsetExpr returns the table element offset to apply to Init indices.
-	// Note: This can be validated prior to instantiation unless it includes OpcodeGlobalGet (an imported global).
+	// Note: This can be validated prior to instantiation unless it includes OpcodeGlobalGet (an imported global) or
+	// is extended.
 	OffsetExpr ConstantExpression
 
 	// TableIndex is the table's index to which this element segment is applied.
@@ -133,6 +142,9 @@ type TableInstance struct {
 	// Type is either RefTypeFuncref or RefTypeExternRef.
 	Type RefType
 
+	// definition is known at compile time.
+	definition api.TableDefinition
+
 	// The following is only used when the table is exported.
 
 	// involvingModuleInstances is a set of module instances which are involved in the table instance.
@@ -228,6 +240,11 @@ func (m *Module) validateTable(enabledFeatures api.CoreFeatures, tables []Table,
 						return err
 					}
 				}
+			} else if elem.OffsetExpr.IsExtended() {
+				// The offset of an extended constant expression is only known at instantiation.
+				if err := m.validateConstExpression(m.importedGlobalTypes(), 0, &elem.OffsetExpr, ValueTypeI32); err != nil {
+					return fmt.Errorf("%s[%d] has an invalid const expression: %w", SectionIDName(SectionIDElement), idx, err)
+				}
 			} else {
 				return fmt.Errorf("%s[%d] has an invalid const expression: %s", SectionIDName(SectionIDElement), idx, InstructionName(oc))
 			}
@@ -236,6 +253,17 @@ func (m *Module) validateTable(enabledFeatures api.CoreFeatures, tables []Table,
 	return nil
 }
 
+// importedGlobalTypes returns the types of the imported globals, which are the only ones accessible from constant
+// expressions.
+func (m *Module) importedGlobalTypes() (globals []GlobalType) {
+	for i := range m.ImportSection {
+		if imp := &m.ImportSection[i]; imp.Type == ExternTypeGlobal {
+			globals = append(globals, imp.DescGlobal)
+		}
+	}
+	return
+}
+
 // buildTable returns TableInstances if the module defines or imports a table.
 //   - importedTables: returned as `tables` unmodified.
 //   - importedGlobals: include all instantiated, imported globals.
@@ -248,10 +276,14 @@ func (m *ModuleInstance) buildTables(module *Module, skipBoundCheck bool) (err e
 	for i := range module.TableSection {
 		tsec := &module.TableSection[i]
 		// The module defining the table is the one that sets its Min/Max etc.
-		m.Tables[idx] = &TableInstance{
+		t := &TableInstance{
 			References: make([]Reference, tsec.Min), Min: tsec.Min, Max: tsec.Max,
 			Type: tsec.Type,
 		}
+		if int(idx) < len(module.TableDefinitionSection) {
+			t.definition = &module.TableDefinitionSection[idx]
+		}
+		m.Tables[idx] = t
 		idx++
 	}
 
@@ -259,17 +291,7 @@ func (m *ModuleInstance) buildTables(module *Module, skipBoundCheck bool) (err e
 		for elemI := range module.ElementSection { // Do not loop over the value since elementSegments is a slice of value.
 			elem := &module.ElementSection[elemI]
 			table := m.Tables[elem.TableIndex]
-			var offset uint32
-			if elem.OffsetExpr.Opcode == OpcodeGlobalGet {
-				// Ignore error as it's already validated.
-				globalIdx, _, _ := leb128.LoadUint32(elem.OffsetExpr.Data)
-				global := m.Globals[globalIdx]
-				offset = uint32(global.Val)
-			} else { // i32.const
-				// Ignore error as it's already validated.
-				o, _, _ := leb128.LoadInt32(elem.OffsetExpr.Data)
-				offset = uint32(o)
-			}
+			offset := uint32(executeConstExpressionI32(m.Globals, &elem.OffsetExpr))
 
 			// Check to see if we are out-of-bounds
 			initCount := uint64(len(elem.Init))
@@ -281,6 +303,32 @@ func (m *ModuleInstance) buildTables(module *Module, skipBoundCheck bool) (err e
 	return
 }
 
+// initTables fills the tables defined in the module with their initial value, which is only set with
+// experimental.CoreFeaturesFunctionReferences. This must be called after the globals are built.
+func (m *ModuleInstance) initTables(module *Module) (err error) {
+	for i := range module.TableSection {
+		tsec := &module.TableSection[i]
+		if tsec.Init == nil {
+			continue
+		}
+		var v uint64
+		if tsec.Init.IsExtended() {
+			if v, _, err = m.executeGCConstExpression(m.Globals, tsec.Init.Data, m.Engine.FunctionInstanceReference); err != nil {
+				return fmt.Errorf("%s[%d] failed to initialize: %w", SectionIDName(SectionIDTable), i, err)
+			}
+		} else {
+			g := &GlobalInstance{Type: GlobalType{ValType: tsec.Type}}
+			g.initialize(m.Globals, tsec.Init, m.Engine.FunctionInstanceReference)
+			v = g.Val
+		}
+		references := m.Tables[module.ImportTableCount+Index(i)].References
+		for j := range references {
+			references[j] = Reference(v)
+		}
+	}
+	return
+}
+
 // checkSegmentBounds fails if the capacity needed for an ElementSegment.Init is larger than limitsType.Min
 //
 // WebAssembly 1.0 (20191205) doesn't forbid growing to accommodate element segments, and spectests are inconsistent.
@@ -337,3 +385,130 @@ func (t *TableInstance) Grow(delta uint32, initialRef Reference) (currentLen uin
 	}
 	return
 }
+
+// addInvolvingModuleInstance adds m to involvingModuleInstances unless it is already there.
+func (t *TableInstance) addInvolvingModuleInstance(m *ModuleInstance) {
+	t.involvingModuleInstancesMutex.Lock()
+	defer t.involvingModuleInstancesMutex.Unlock()
+	for _, involved := range t.involvingModuleInstances {
+		if involved == m {
+			return
+		}
+	}
+	t.involvingModuleInstances = append(t.involvingModuleInstances, m)
+}
+
+// exportedTable implements api.Table for the TableInstance t exported from the ModuleInstance m.
+type exportedTable struct {
+	internalapi.WazeroOnlyType
+	m *ModuleInstance
+	t *TableInstance
+}
+
+// Definition implements the same method as documented on api.Table.
+func (e *exportedTable) Definition() api.TableDefinition {
+	return e.t.definition
+}
+
+// Size implements the same method as documented on api.Table.
+func (e *exportedTable) Size() uint32 {
+	return uint32(len(e.t.References))
+}
+
+// Grow implements the same method as documented on api.Table.
+func (e *exportedTable) Grow(delta uint32, init uint64) (previousSize uint32, err error) {
+	if err = e.checkRef(init); err != nil {
+		return 0, err
+	}
+	if previousSize = e.t.Grow(delta, Reference(init)); previousSize == 0xffffffff {
+		return 0, fmt.Errorf("cannot grow table of size %d by %d elements", len(e.t.References), delta)
+	}
+	return
+}
+
+// Get implements the same method as documented on api.Table.
+func (e *exportedTable) Get(offset uint32) (uint64, error) {
+	if err := e.checkOffset(offset); err != nil {
+		return 0, err
+	}
+	return uint64(e.t.References[offset]), nil
+}
+
+// Set implements the same method as documented on api.Table.
+func (e *exportedTable) Set(offset uint32, v uint64) error {
+	if err := e.checkOffset(offset); err != nil {
+		return err
+	} else if err = e.checkRef(v); err != nil {
+		return err
+	}
+	e.t.References[offset] = Reference(v)
+	return nil
+}
+
+// Function implements the same method as documented on api.Table.
+func (e *exportedTable) Function(offset uint32) (api.Function, error) {
+	if err := e.checkFuncref(offset); err != nil {
+		return nil, err
+	}
+	ref := e.t.References[offset]
+	if ref == 0 {
+		return nil, nil
+	}
+	fm, index := e.m.Engine.LookupFunctionReference(ref)
+	return e.m.functionIn(fm, index), nil
+}
+
+// SetFunction implements the same method as documented on api.Table.
+func (e *exportedTable) SetFunction(offset uint32, fn api.Function) error {
+	if err := e.checkFuncref(offset); err != nil {
+		return err
+	}
+	if fn == nil {
+		e.t.References[offset] = 0
+		return nil
+	}
+
+	mf, ok := fn.(ModuleFunction)
+	if !ok {
+		return fmt.Errorf("function %s is not defined by a module", fn.Definition().DebugName())
+	}
+	fm, index := mf.ModuleFunction()
+	if fm.s != e.m.s {
+		return fmt.Errorf("function %s is defined by a module of another runtime", fn.Definition().DebugName())
+	} else if fm.IsClosed() {
+		return fmt.Errorf("function %s is defined by a closed module", fn.Definition().DebugName())
+	}
+	// The reference is built by the engine of the table's module, as the module defining the function may run on
+	// another tier.
+	ref, err := e.m.Engine.FunctionReference(fm, index)
+	if err != nil {
+		return fmt.Errorf("function %s cannot be set: %w", fn.Definition().DebugName(), err)
+	}
+	// The module defining the function must be alive as long as the table holds a reference to it.
+	e.t.addInvolvingModuleInstance(fm)
+	e.t.References[offset] = ref
+	return nil
+}
+
+func (e *exportedTable) checkOffset(offset uint32) error {
+	if size := len(e.t.References); int(offset) >= size {
+		return fmt.Errorf("offset %d out of range of table size %d", offset, size)
+	}
+	return nil
+}
+
+// checkRef returns an error unless v can be stored into the table. The engines use a funcref as the pointer to the
+// function, so a non-null funcref must only be stored by SetFunction.
+func (e *exportedTable) checkRef(v uint64) error {
+	if e.t.Type == RefTypeFuncref && v != 0 {
+		return fmt.Errorf("non-null funcref %#x must be set by SetFunction", v)
+	}
+	return nil
+}
+
+func (e *exportedTable) checkFuncref(offset uint32) error {
+	if e.t.Type != RefTypeFuncref {
+		return fmt.Errorf("table of %s is not a table of funcref", RefTypeName(e.t.Type))
+	}
+	return e.checkOffset(offset)
+}
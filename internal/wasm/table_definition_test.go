@@ -0,0 +1,125 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestModule_BuildTableDefinitions(t *testing.T) {
+	three := uint32(3)
+	tests := []struct {
+		name            string
+		m               *Module
+		expected        []TableDefinition
+		expectedImports []api.TableDefinition
+		expectedExports map[string]api.TableDefinition
+	}{
+		{
+			name:            "no exports",
+			m:               &Module{},
+			expectedExports: map[string]api.TableDefinition{},
+		},
+		{
+			name: "no tables",
+			m: &Module{
+				ExportSection: []Export{{Type: ExternTypeGlobal, Index: 0}},
+				GlobalSection: []Global{{}},
+			},
+			expectedExports: map[string]api.TableDefinition{},
+		},
+		{
+			name:            "defines funcref table{0,}",
+			m:               &Module{TableSection: []Table{{Type: RefTypeFuncref}}},
+			expected:        []TableDefinition{{index: 0, table: &Table{Type: RefTypeFuncref}}},
+			expectedExports: map[string]api.TableDefinition{},
+		},
+		{
+			name: "exports imported externref table{0,} and defined funcref table{2,3}",
+			m: &Module{
+				ImportTableCount: 1,
+				ImportSection: []Import{{
+					Type:      ExternTypeTable,
+					Module:    "env",
+					Name:      "table",
+					DescTable: Table{Type: RefTypeExternref},
+				}},
+				ExportSection: []Export{
+					{Name: "imported_table", Type: ExternTypeTable, Index: 0},
+					{Name: "table_index=1", Type: ExternTypeTable, Index: 1},
+					{Name: "", Type: ExternTypeGlobal, Index: 0},
+				},
+				GlobalSection: []Global{{}},
+				TableSection:  []Table{{Type: RefTypeFuncref, Min: 2, Max: &three}},
+			},
+			expected: []TableDefinition{
+				{
+					index:       0,
+					importDesc:  &[2]string{"env", "table"},
+					exportNames: []string{"imported_table"},
+					table:       &Table{Type: RefTypeExternref},
+				},
+				{
+					index:       1,
+					exportNames: []string{"table_index=1"},
+					table:       &Table{Type: RefTypeFuncref, Min: 2, Max: &three},
+				},
+			},
+			expectedImports: []api.TableDefinition{
+				&TableDefinition{
+					index:       0,
+					importDesc:  &[2]string{"env", "table"},
+					exportNames: []string{"imported_table"},
+					table:       &Table{Type: RefTypeExternref},
+				},
+			},
+			expectedExports: map[string]api.TableDefinition{
+				"imported_table": &TableDefinition{
+					index:       0,
+					importDesc:  &[2]string{"env", "table"},
+					exportNames: []string{"imported_table"},
+					table:       &Table{Type: RefTypeExternref},
+				},
+				"table_index=1": &TableDefinition{
+					index:       1,
+					exportNames: []string{"table_index=1"},
+					table:       &Table{Type: RefTypeFuncref, Min: 2, Max: &three},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.m.BuildTableDefinitions()
+			require.Equal(t, tc.expected, tc.m.TableDefinitionSection)
+			require.Equal(t, tc.expectedImports, tc.m.ImportedTables())
+			require.Equal(t, tc.expectedExports, tc.m.ExportedTables())
+		})
+	}
+}
+
+func TestTableDefinition(t *testing.T) {
+	three := uint32(3)
+	d := &TableDefinition{
+		moduleName: "test",
+		index:      1,
+		table:      &Table{Type: RefTypeFuncref, Min: 2, Max: &three},
+	}
+	require.Equal(t, "test", d.ModuleName())
+	require.Equal(t, uint32(1), d.Index())
+	require.Equal(t, api.ValueTypeFuncref, d.RefType())
+	require.Equal(t, uint32(2), d.Min())
+	max, ok := d.Max()
+	require.True(t, ok)
+	require.Equal(t, uint32(3), max)
+
+	_, _, isImport := d.Import()
+	require.False(t, isImport)
+
+	d.table.Max = nil
+	_, ok = d.Max()
+	require.False(t, ok)
+}
@@ -704,6 +704,21 @@ func TestModule_validateTable_Errors(t *testing.T) {
 			},
 			expectedErr: "element[0] (global.get 0): out of range of imported globals",
 		},
+		{
+			name: "extended offset type mismatch",
+			input: &Module{
+				TableSection: []Table{{Type: RefTypeFuncref}},
+				ElementSection: []ElementSegment{
+					{
+						OffsetExpr: ConstantExpression{Opcode: OpcodeI64Add, Data: []byte{
+							OpcodeI64Const, 1, OpcodeI64Const, 1, OpcodeI64Add,
+						}},
+						Type: RefTypeFuncref,
+					},
+				},
+			},
+			expectedErr: "element[0] has an invalid const expression: const expression type mismatch expected i32 but got i64",
+		},
 	}
 
 	for _, tt := range tests {
@@ -1113,6 +1128,42 @@ func TestTableInstance_Grow(t *testing.T) {
 	}
 }
 
+func TestModuleInstance_ExportedTable(t *testing.T) {
+	withDefinition := &TableInstance{References: make([]Reference, 1), Type: RefTypeFuncref, definition: &TableDefinition{}}
+	m := &ModuleInstance{
+		Tables: []*TableInstance{withDefinition, {References: make([]Reference, 1), Type: RefTypeFuncref}},
+		Exports: map[string]*Export{
+			"with":    {Type: ExternTypeTable, Index: 0},
+			"without": {Type: ExternTypeTable, Index: 1},
+		},
+	}
+
+	// A table without the definition is neither returned nor listed.
+	require.Nil(t, m.ExportedTable("without"))
+	defs := m.ExportedTableDefinitions()
+	require.Equal(t, 1, len(defs))
+
+	tbl := m.ExportedTable("with")
+	require.NotNil(t, tbl)
+	require.Equal(t, defs["with"], tbl.Definition())
+
+	// Only the null funcref can be set or grown.
+	require.EqualError(t, tbl.Set(0, 1), "non-null funcref 0x1 must be set by SetFunction")
+	_, err := tbl.Grow(1, 1)
+	require.EqualError(t, err, "non-null funcref 0x1 must be set by SetFunction")
+	require.Equal(t, uint32(1), tbl.Size())
+	require.NoError(t, tbl.Set(0, 0))
+	prev, err := tbl.Grow(1, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), prev)
+
+	// The externref is opaque, so can be any value.
+	withDefinition.Type = RefTypeExternref
+	require.NoError(t, tbl.Set(0, 1))
+	_, err = tbl.Grow(1, 1)
+	require.NoError(t, err)
+}
+
 func Test_unwrapElementInitGlobalReference(t *testing.T) {
 	actual, ok := unwrapElementInitGlobalReference(12345 | elementInitImportedGlobalReferenceType)
 	require.True(t, ok)
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/wasmruntime"
 	"github.com/tetratelabs/wazero/sys"
 )
@@ -128,11 +129,17 @@ func (s *stackTrace) FromRecovered(recovered interface{}) error {
 		return exitErr
 	}
 
+	if exc, ok := recovered.(*experimental.Exception); ok { // Don't wrap an exception, so that the host can catch it.
+		return exc
+	}
+
 	stack := strings.Join(s.lines, "\n\t")
 
 	// If the error was internal, don't mention it was recovered.
 	if wasmErr, ok := recovered.(*wasmruntime.Error); ok {
 		return fmt.Errorf("wasm error: %w\nwasm stack trace:\n\t%s", wasmErr, stack)
+	} else if recovered == sys.ErrOutOfFuel {
+		return fmt.Errorf("wasm error: %w\nwasm stack trace:\n\t%s", sys.ErrOutOfFuel, stack)
 	}
 
 	// If we have a runtime.Error, something severe happened which should include the stack trace. This could be
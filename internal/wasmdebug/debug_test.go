@@ -8,6 +8,7 @@ import (
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasmruntime"
+	"github.com/tetratelabs/wazero/sys"
 )
 
 func TestFuncName(t *testing.T) {
@@ -117,6 +118,17 @@ wasm stack trace:
 	x.y()`,
 			expectUnwrap: wasmruntime.ErrRuntimeStackOverflow,
 		},
+		{
+			name: "sys.ErrOutOfFuel",
+			build: func(builder ErrorBuilder) error {
+				builder.AddFrame("x.y", nil, nil, nil)
+				return builder.FromRecovered(sys.ErrOutOfFuel)
+			},
+			expectedErr: `wasm error: out of fuel
+wasm stack trace:
+	x.y()`,
+			expectUnwrap: sys.ErrOutOfFuel,
+		},
 	}
 
 	for _, tt := range tests {
@@ -46,9 +46,30 @@ func isTombstoneAddr(addr uint64) bool {
 		addr32 == 0 // This covers 1 <<32.
 }
 
+// Location is a source code location of an instruction.
+type Location struct {
+	File         string
+	Line, Column int64
+	// Inlined is true if the location is in a function inlined into the one of the next Location.
+	Inlined bool
+}
+
 // Line returns the line information for the given instructionOffset which is an offset in
 // the code section of the original Wasm binary. Returns empty string if the info is not found.
 func (d *DWARFLines) Line(instructionOffset uint64) (ret []string) {
+	locations := d.Locations(instructionOffset)
+	prefix := fmt.Sprintf("%#x: ", instructionOffset)
+	for i, l := range locations {
+		if i == 1 {
+			prefix = strings.Repeat(" ", len(prefix))
+		}
+		ret = append(ret, formatLine(prefix, l.File, l.Line, l.Column, l.Inlined))
+	}
+	return
+}
+
+// Locations is like Line, but returns the locations, starting from the innermost inlined function if any.
+func (d *DWARFLines) Locations(instructionOffset uint64) (ret []Location) {
 	if d == nil {
 		return
 	}
@@ -178,11 +199,9 @@ entry:
 
 	// In the inlined case, the line info is the innermost inlined function call.
 	inlined := len(inlinedRoutines) != 0
-	prefix := fmt.Sprintf("%#x: ", instructionOffset)
-	ret = append(ret, formatLine(prefix, le.File.Name, int64(le.Line), int64(le.Column), inlined))
+	ret = append(ret, Location{File: le.File.Name, Line: int64(le.Line), Column: int64(le.Column), Inlined: inlined})
 
 	if inlined {
-		prefix = strings.Repeat(" ", len(prefix))
 		files := lineReader.Files()
 		// inlinedRoutines contain the inlined call information in the reverse order (children is higher than parent),
 		// so we traverse the reverse order and emit the inlined calls.
@@ -198,11 +217,82 @@ entry:
 			fileName := files[fileIndex]
 			line, _ := inlined.Val(dwarf.AttrCallLine).(int64)
 			col, _ := inlined.Val(dwarf.AttrCallColumn).(int64)
-			ret = append(ret, formatLine(prefix, fileName.Name, line, col,
+			ret = append(ret, Location{File: fileName.Name, Line: line, Column: col,
 				// Last one is the origin of the inlined function calls.
-				i != 0))
+				Inlined: i != 0})
+		}
+	}
+	return
+}
+
+// Row is a row of the line number table, which is the source code location of the instructions from Address up to
+// the Address of the next Row.
+type Row struct {
+	// Address is the offset of the instruction in the code section of the original Wasm binary.
+	Address      uint64
+	File         string
+	Line, Column int64
+	// IsStmt is true if the instruction is the beginning of a statement, which is where a breakpoint should be placed.
+	IsStmt bool
+	// EndSequence is true if Address is the first byte after the end of a sequence of the instructions, in which case
+	// the instructions from Address have no location.
+	EndSequence bool
+}
+
+// Rows returns the rows of the line number tables of all the compilation units, sorted by Address. Unlike Locations,
+// this doesn't take the inlined functions into account, so a Row is the location in the innermost one.
+func (d *DWARFLines) Rows() (ret []Row) {
+	if d == nil {
+		return
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	r := d.d.Reader()
+	for {
+		ent, err := r.Next()
+		if err != nil || ent == nil {
+			break
+		}
+		if ent.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+		r.SkipChildren()
+
+		lineReader, err := d.d.LineReader(ent)
+		if err != nil || lineReader == nil {
+			continue
+		}
+		var le dwarf.LineEntry
+		// tombstone is true while reading a sequence which starts at a tombstone address, which is removed by linker.
+		tombstone, sequenceStart := false, true
+		for {
+			if err = lineReader.Next(&le); err != nil {
+				break
+			}
+			if sequenceStart {
+				tombstone = isTombstoneAddr(le.Address)
+			}
+			sequenceStart = le.EndSequence
+			if tombstone {
+				continue
+			}
+			row := Row{Address: le.Address, IsStmt: le.IsStmt, EndSequence: le.EndSequence}
+			if !le.EndSequence && le.File != nil {
+				row.File, row.Line, row.Column = le.File.Name, int64(le.Line), int64(le.Column)
+			}
+			ret = append(ret, row)
 		}
 	}
+	// Sort the end of a sequence before the rows at the same address, which are the beginning of another sequence.
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Address != ret[j].Address {
+			return ret[i].Address < ret[j].Address
+		}
+		return ret[i].EndSequence && !ret[j].EndSequence
+	})
 	return
 }
 
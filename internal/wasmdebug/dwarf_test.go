@@ -11,6 +11,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
 )
 
 func TestDWARFLines_Line_Zig(t *testing.T) {
@@ -236,3 +237,35 @@ func TestDWARFLines_Line_TinyGo(t *testing.T) {
 		})
 	}
 }
+
+func TestDWARFLines_Rows(t *testing.T) {
+	mod, err := binary.DecodeModule(dwarftestdata.ZigCCWasm, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, true, false)
+	require.NoError(t, err)
+	require.NotNil(t, mod.DWARFLines)
+
+	rows := mod.DWARFLines.Rows()
+	require.True(t, len(rows) > 0)
+	stmts := map[int64]bool{}
+	for i, row := range rows {
+		if i > 0 {
+			require.True(t, rows[i-1].Address <= row.Address)
+		}
+		if row.EndSequence || row.Line == 0 {
+			continue
+		}
+		// The rows are consistent with the innermost locations.
+		locations := mod.DWARFLines.Locations(row.Address)
+		require.True(t, len(locations) > 0)
+		require.Equal(t, locations[0].File, row.File)
+		require.Equal(t, locations[0].Line, row.Line)
+		if row.IsStmt && strings.HasSuffix(row.File, "zig-cc/main.c") {
+			stmts[row.Line] = true
+		}
+	}
+	for _, line := range []int64{5, 6, 7, 11, 12} {
+		require.True(t, stmts[line], line)
+	}
+
+	var nilLines *wasmdebug.DWARFLines
+	require.Nil(t, nilLines.Rows())
+}
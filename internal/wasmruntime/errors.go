@@ -33,6 +33,36 @@ var (
 	ErrRuntimeExpectedSharedMemory = New("expected shared memory")
 	// ErrRuntimeTooManyWaiters indicates that atomic.wait was called with too many waiters.
 	ErrRuntimeTooManyWaiters = New("too many waiters")
+	// ErrRuntimeNullExceptionReference indicates that throw_ref was executed with a null exception reference.
+	ErrRuntimeNullExceptionReference = New("null exception reference")
+	// ErrRuntimeInvalidExceptionReference indicates that throw_ref was executed with an exception reference
+	// which was not created during the current function call, for example one stored in a global by a
+	// previous call.
+	ErrRuntimeInvalidExceptionReference = New("invalid exception reference")
+	// ErrRuntimeTooManyExceptionReferences indicates that the program caught more distinct exceptions with catch_ref
+	// or catch_all_ref than can be referenced during a single function call.
+	ErrRuntimeTooManyExceptionReferences = New("too many exception references")
+	// ErrRuntimeNullFunctionReference indicates that call_ref or return_call_ref was executed with a null function
+	// reference.
+	ErrRuntimeNullFunctionReference = New("null function reference")
+	// ErrRuntimeNullReference indicates that ref.as_non_null was executed with a null reference.
+	ErrRuntimeNullReference = New("null reference")
+	// ErrRuntimeNullStructReference indicates that a struct instruction was executed with a null reference.
+	ErrRuntimeNullStructReference = New("null structure reference")
+	// ErrRuntimeNullArrayReference indicates that an array instruction was executed with a null reference.
+	ErrRuntimeNullArrayReference = New("null array reference")
+	// ErrRuntimeNullI31Reference indicates that i31.get_s or i31.get_u was executed with a null reference.
+	ErrRuntimeNullI31Reference = New("null i31 reference")
+	// ErrRuntimeInvalidGCReference indicates that the program used a reference to a struct or array which has
+	// been garbage collected, for example one held by the host outside a function call.
+	ErrRuntimeInvalidGCReference = New("invalid reference")
+	// ErrRuntimeCastFailure indicates that ref.cast was executed with a reference not matching the type, or that a
+	// reference used by a struct, array or call_ref instruction was not of the expected type.
+	ErrRuntimeCastFailure = New("cast failure")
+	// ErrRuntimeOutOfBoundsArrayAccess indicates that the program tried to access an array out of its bounds.
+	ErrRuntimeOutOfBoundsArrayAccess = New("out of bounds array access")
+	// ErrRuntimeArrayTooLarge indicates that the program tried to allocate an array larger than the limit.
+	ErrRuntimeArrayTooLarge = New("array too large")
 )
 
 // Error is returned by a wasm.Engine during the execution of Wasm functions, and they indicate that the Wasm runtime
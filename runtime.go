@@ -1,13 +1,16 @@
 package wazero
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"sync/atomic"
 
 	"github.com/tetratelabs/wazero/api"
 	experimentalapi "github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/engine/interpreter"
+	"github.com/tetratelabs/wazero/internal/engine/tiered"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo"
 	"github.com/tetratelabs/wazero/internal/expctxkeys"
 	"github.com/tetratelabs/wazero/internal/platform"
@@ -102,6 +105,33 @@ type Runtime interface {
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#name-section%E2%91%A0
 	CompileModule(ctx context.Context, binary []byte) (CompiledModule, error)
 
+	// ExportCompiledModule writes the native code of a module compiled by this
+	// Runtime to w, so that it can be loaded by ImportCompiledModule without
+	// recompiling, for example in a build step ahead of deployment.
+	//
+	// The artifact embeds the source binary and is only valid for the same
+	// wazero version, architecture, operating system and RuntimeConfig as
+	// this Runtime, and for CPUs with the features the native code uses.
+	//
+	// # Notes
+	//
+	//   - This is only supported by the compiler, see NewRuntimeConfigCompiler.
+	//   - This requires RuntimeConfig.WithCompiledModuleExport, so that the
+	//     compiled module retains its source binary.
+	//   - Modules compiled with RuntimeConfig.WithLazyCompilation cannot be exported.
+	ExportCompiledModule(ctx context.Context, compiled CompiledModule, w io.Writer) error
+
+	// ImportCompiledModule reads an artifact written by ExportCompiledModule
+	// and returns the CompiledModule without recompiling its functions.
+	//
+	// An error is returned if the artifact is corrupt or was produced by a
+	// different wazero version, platform, CPU or configuration, in which case
+	// the caller should fall back to CompileModule.
+	//
+	// Function listeners and fuel costs are read from ctx the same way as
+	// CompileModule, and must match the ones used to export the module.
+	ImportCompiledModule(ctx context.Context, r io.Reader) (CompiledModule, error)
+
 	// InstantiateModule instantiates the module or errs for reasons including
 	// exit or validation.
 	//
@@ -160,13 +190,27 @@ func NewRuntimeWithConfig(ctx context.Context, rConfig RuntimeConfig) Runtime {
 			configKind = engineKindInterpreter
 		}
 	}
+	// The tiered engine keeps the modules which the compiler rejects, e.g. the ones using SIMD on riscv64, on the
+	// interpreter, so it only needs the compiler to support the other features.
+	if configKind == engineKindTiered && !platform.CompilerSupports(config.enabledFeatures&^api.CoreFeatureSIMD) {
+		configKind = engineKindInterpreter
+	}
 	if configEngine == nil {
-		if configKind == engineKindCompiler {
+		switch configKind {
+		case engineKindCompiler:
 			configEngine = wazevo.NewEngine
-		} else {
+		case engineKindTiered:
+			configEngine = tiered.NewEngine
+		default:
 			configEngine = interpreter.NewEngine
 		}
 	}
+	if config.symbolExport&SymbolExportPerfMap != 0 {
+		ctx = context.WithValue(ctx, expctxkeys.PerfMapKey{}, true)
+	}
+	if config.symbolExport&SymbolExportJITDump != 0 {
+		ctx = context.WithValue(ctx, expctxkeys.JITDumpKey{}, true)
+	}
 	var engine wasm.Engine
 	var cacheImpl *cache
 	if c := config.cache; c != nil {
@@ -187,6 +231,10 @@ func NewRuntimeWithConfig(ctx context.Context, rConfig RuntimeConfig) Runtime {
 		dwarfDisabled:         config.dwarfDisabled,
 		storeCustomSections:   config.storeCustomSections,
 		ensureTermination:     config.ensureTermination,
+		compilationWorkers:    config.compilationWorkers,
+		lazyCompilation:       config.lazyCompilation,
+		compiledModuleExport:  config.compiledModuleExport,
+		engineKind:            configKind,
 	}
 }
 
@@ -208,7 +256,13 @@ type runtime struct {
 	// See /RATIONALE.md
 	closed atomic.Uint64
 
-	ensureTermination bool
+	ensureTermination  bool
+	compilationWorkers int
+	lazyCompilation    bool
+	// compiledModuleExport retains the source binary of compiled modules for ExportCompiledModule.
+	compiledModuleExport bool
+	// engineKind is the resolved kind of the engine, which is never engineKindAuto.
+	engineKind engineKind
 }
 
 // Module implements Runtime.Module.
@@ -231,38 +285,66 @@ func (r *runtime) CompileModule(ctx context.Context, binary []byte) (CompiledMod
 		return nil, err
 	}
 
+	c, listeners, err := r.decodeModule(ctx, binary)
+	if err != nil {
+		return nil, err
+	}
+	if r.compilationWorkers > 0 {
+		ctx = context.WithValue(ctx, expctxkeys.CompilationWorkersKey{}, r.compilationWorkers)
+	}
+	if r.lazyCompilation {
+		ctx = context.WithValue(ctx, expctxkeys.LazyCompilationKey{}, true)
+	}
+	if err = r.store.Engine.CompileModule(ctx, c.module, listeners, r.ensureTermination); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// decodeModule decodes and validates the binary into a compiledModule, which is not compiled by the engine yet.
+func (r *runtime) decodeModule(ctx context.Context, binary []byte) (*compiledModule, []experimentalapi.FunctionListener, error) {
 	internal, err := binaryformat.DecodeModule(binary, r.enabledFeatures,
 		r.memoryLimitPages, r.memoryCapacityFromMax, !r.dwarfDisabled, r.storeCustomSections)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	} else if err = internal.Validate(r.enabledFeatures); err != nil {
 		// TODO: decoders should validate before returning, as that allows
 		// them to err with the correct position in the wasm binary.
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Now that the module is validated, cache the memory definitions.
-	// TODO: lazy initialization of memory definition.
+	// Now that the module is validated, cache the memory and table definitions.
+	// TODO: lazy initialization of memory and table definitions.
 	internal.BuildMemoryDefinitions()
+	internal.BuildTableDefinitions()
 
 	c := &compiledModule{module: internal, compiledEngine: r.store.Engine}
+	if r.compiledModuleExport && r.engineKind == engineKindCompiler {
+		// Copy the binary, as the caller may modify it and an imported one is a slice of the whole artifact.
+		c.binary = bytes.Clone(binary)
+	}
 
 	// typeIDs are static and compile-time known.
 	typeIDs, err := r.store.GetFunctionTypeIDs(internal.TypeSection)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	c.typeIDs = typeIDs
 
 	listeners, err := buildFunctionListeners(ctx, internal)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	internal.AssignModuleID(binary, listeners, r.ensureTermination)
-	if err = r.store.Engine.CompileModule(ctx, internal, listeners, r.ensureTermination); err != nil {
-		return nil, err
+	if costs, ok := ctx.Value(expctxkeys.FuelCostsKey{}).(*experimentalapi.FuelCosts); ok {
+		internal.FuelCosts = costs
 	}
-	return c, nil
+	internal.Profiling = ctx.Value(expctxkeys.SamplerKey{}) != nil
+	internal.Coverage = ctx.Value(expctxkeys.CoverageCollectorKey{}) != nil
+	if internal.Debugging = ctx.Value(expctxkeys.DebuggerKey{}) != nil; internal.Debugging {
+		internal.DebugBinary = binary
+	}
+	internal.AssignModuleID(binary, listeners, r.ensureTermination)
+	return c, listeners, nil
 }
 
 func buildFunctionListeners(ctx context.Context, internal *wasm.Module) ([]experimentalapi.FunctionListener, error) {
@@ -98,7 +98,7 @@ func TestRuntime_CompileModule(t *testing.T) {
 		{
 			name: "MemorySection, but not exported",
 			wasm: &wasm.Module{
-				MemorySection: &wasm.Memory{Min: 2, Max: 3, IsMaxEncoded: true},
+				MemorySection: []wasm.Memory{{Min: 2, Max: 3, IsMaxEncoded: true}},
 			},
 			expected: func(compiled CompiledModule) {
 				require.Nil(t, compiled.ImportedMemories())
@@ -108,7 +108,7 @@ func TestRuntime_CompileModule(t *testing.T) {
 		{
 			name: "MemorySection exported",
 			wasm: &wasm.Module{
-				MemorySection: &wasm.Memory{Min: 2, Max: 3, IsMaxEncoded: true},
+				MemorySection: []wasm.Memory{{Min: 2, Max: 3, IsMaxEncoded: true}},
 				ExportSection: []wasm.Export{{
 					Type:  wasm.ExternTypeMemory,
 					Name:  "memory",
@@ -170,7 +170,7 @@ func TestRuntime_CompileModule_Errors(t *testing.T) {
 		},
 		{
 			name:        "memory has too many pages",
-			wasm:        binaryencoding.EncodeModule(&wasm.Module{MemorySection: &wasm.Memory{Min: 2, Cap: 2, Max: 70000, IsMaxEncoded: true}}),
+			wasm:        binaryencoding.EncodeModule(&wasm.Module{MemorySection: []wasm.Memory{{Min: 2, Cap: 2, Max: 70000, IsMaxEncoded: true}}}),
 			expectedErr: "section memory: max 70000 pages (4 Gi) over limit of 65536 pages (4 Gi)",
 		},
 	}
@@ -203,7 +203,7 @@ func TestModule_Memory(t *testing.T) {
 		{
 			name: "memory exported, one page",
 			wasm: binaryencoding.EncodeModule(&wasm.Module{
-				MemorySection: &wasm.Memory{Min: 1},
+				MemorySection: []wasm.Memory{{Min: 1}},
 				ExportSection: []wasm.Export{{Name: "memory", Type: api.ExternTypeMemory}},
 			}),
 			expected:    true,
@@ -375,7 +375,7 @@ func TestRuntime_Instantiate_DoesntEnforce_Start(t *testing.T) {
 	defer r.Close(testCtx)
 
 	binary := binaryencoding.EncodeModule(&wasm.Module{
-		MemorySection: &wasm.Memory{Min: 1},
+		MemorySection: []wasm.Memory{{Min: 1}},
 		ExportSection: []wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
 	})
 
@@ -623,6 +623,7 @@ func TestHostFunctionWithCustomContext(t *testing.T) {
 	}{
 		{name: "compiler", config: NewRuntimeConfigCompiler()},
 		{name: "interpreter", config: NewRuntimeConfigInterpreter()},
+		{name: "tiered", config: NewRuntimeConfigTiered()},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			const fistString = "hello"
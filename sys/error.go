@@ -3,6 +3,7 @@ package sys
 
 import (
 	"context"
+	"errors"
 	"fmt"
 )
 
@@ -15,6 +16,19 @@ const (
 	ExitCodeDeadlineExceeded uint32 = 0xefffffff
 )
 
+// ErrOutOfFuel is wrapped by the error returned to a caller of api.Function
+// when the call ran out of the fuel set with experimental.WithFuel.
+//
+// Here's an example of how to check for it:
+//
+//	if _, err := fn.Call(ctx); errors.Is(err, sys.ErrOutOfFuel) {
+//		// The call was stopped before it completed.
+//	}
+//
+// Note: Unlike ExitError, this doesn't close the api.Module, so the caller
+// can add fuel and call a function again.
+var ErrOutOfFuel = errors.New("out of fuel")
+
 // ExitError is returned to a caller of api.Function when api.Module CloseWithExitCode was invoked,
 // or context.Context passed to api.Function Call was canceled or reached the Timeout.
 //